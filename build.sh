@@ -159,6 +159,20 @@ build_binaries() {
         log_error "Failed to build alxnet!"
         exit 1
     fi
+
+    # Build the WebAssembly verifier with its JavaScript loader
+    log_info "Building alxnet.wasm..."
+    mkdir -p "$OUT_DIR/wasm"
+    if GOOS=js GOARCH=wasm "$GO_BIN" build -trimpath -o "$OUT_DIR/wasm/alxnet.wasm" ./cmd/alxnet-wasm; then
+        GOROOT_DIR="$("$GO_BIN" env GOROOT)"
+        cp "$ROOT_DIR/cmd/alxnet-wasm/alxnet.js" "$OUT_DIR/wasm/"
+        cp "$GOROOT_DIR/lib/wasm/wasm_exec.js" "$OUT_DIR/wasm/" 2>/dev/null ||
+            cp "$GOROOT_DIR/misc/wasm/wasm_exec.js" "$OUT_DIR/wasm/"
+        log_success "alxnet.wasm built successfully"
+    else
+        log_error "Failed to build alxnet.wasm!"
+        exit 1
+    fi
 }
 
 # Security audit of built binaries
@@ -0,0 +1,45 @@
+// alxnet.js loads alxnet.wasm, built from cmd/alxnet-wasm, and resolves
+// with the alxnet object it defines. Load Go's wasm_exec.js first; it ships
+// in $(go env GOROOT)/lib/wasm (misc/wasm before Go 1.24).
+//
+//   const alxnet = await loadAlxNet('alxnet.wasm');
+//   if (!alxnet.verifyContent(bytes, cid)) throw new Error('content does not match');
+//   const m = alxnet.verifyManifest(manifestBytes, siteID);
+//   if (m.error) throw new Error(m.error);
+//
+// In Node, pass the module's bytes instead of a URL.
+(function (root) {
+  'use strict';
+
+  let loading = null;
+
+  // loadAlxNet instantiates the module from a URL or its bytes once and
+  // resolves with root.alxnet.
+  function loadAlxNet(source) {
+    if (root.alxnet) return Promise.resolve(root.alxnet);
+    if (loading) return loading;
+    if (typeof root.Go !== 'function') {
+      return Promise.reject(new Error('load wasm_exec.js before alxnet.js'));
+    }
+    loading = (async () => {
+      let bytes = source;
+      if (typeof source === 'string' || source instanceof URL) {
+        const resp = await fetch(source);
+        if (!resp.ok) throw new Error('fetching ' + source + ': ' + resp.status);
+        bytes = await resp.arrayBuffer();
+      }
+      const go = new root.Go();
+      const ready = new Promise((resolve) => { root.alxnetReady = resolve; });
+      const { instance } = await WebAssembly.instantiate(bytes, go.importObject);
+      go.run(instance);
+      await ready;
+      delete root.alxnetReady;
+      return root.alxnet;
+    })();
+    loading.catch(() => { loading = null; });
+    return loading;
+  }
+
+  root.loadAlxNet = loadAlxNet;
+  if (typeof module === 'object' && module.exports) module.exports = { loadAlxNet };
+})(typeof globalThis !== 'undefined' ? globalThis : this);
@@ -0,0 +1,184 @@
+//go:build js && wasm
+
+// Command alxnet-wasm exposes AlxNet's record verification to JavaScript,
+// so a web app or browser extension can check what a gateway or peer sent
+// without trusting it. Build it with
+//
+//	GOOS=js GOARCH=wasm go build -o alxnet.wasm ./cmd/alxnet-wasm
+//
+// and load it with Go's wasm_exec.js, or with alxnet.js next to this file.
+// Once running it defines a global alxnet object whose functions take
+// Uint8Array and string arguments:
+//
+//	alxnet.cid(bytes)                            -> hex CID or {error}
+//	alxnet.siteID(publicKey)                     -> {site_id} or {error}
+//	alxnet.verifySignature(publicKey, msg, sig)  -> bool
+//	alxnet.verifyContent(bytes, cid)             -> bool
+//	alxnet.parseManifest(bytes)                  -> manifest or {error}
+//	alxnet.verifyManifest(bytes, siteID)         -> manifest or {error}
+//	alxnet.verifyFileRecord(bytes, siteID, path) -> record or {error}
+//	alxnet.verifyUpdateRecord(bytes, siteID)     -> record or {error}
+//
+// An empty siteID or path skips that check. parseManifest decodes without
+// verifying; the verify functions only return records whose signatures
+// check out. Bad arguments make the bool functions return false.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"syscall/js"
+
+	"alxnet/internal/core"
+	"alxnet/internal/verify"
+)
+
+// version is the binding's API version, alxnet.version in JavaScript.
+const version = "1"
+
+func main() {
+	js.Global().Set("alxnet", js.ValueOf(map[string]interface{}{
+		"version":            version,
+		"cid":                js.FuncOf(cid),
+		"siteID":             js.FuncOf(siteID),
+		"verifySignature":    js.FuncOf(verifySignature),
+		"verifyContent":      js.FuncOf(verifyContent),
+		"parseManifest":      js.FuncOf(parseManifest),
+		"verifyManifest":     js.FuncOf(verifyManifest),
+		"verifyFileRecord":   js.FuncOf(verifyFileRecord),
+		"verifyUpdateRecord": js.FuncOf(verifyUpdateRecord),
+	}))
+	if ready := js.Global().Get("alxnetReady"); ready.Type() == js.TypeFunction {
+		ready.Invoke()
+	}
+	select {}
+}
+
+// maxBytes bounds byte arguments: blobs are at most core.MaxContentSize.
+const maxBytes = core.MaxContentSize
+
+// bytesArg copies args[i], a Uint8Array, into Go.
+func bytesArg(args []js.Value, i int) ([]byte, error) {
+	if i >= len(args) || !args[i].InstanceOf(js.Global().Get("Uint8Array")) {
+		return nil, fmt.Errorf("argument %d must be a Uint8Array", i+1)
+	}
+	n := args[i].Get("length").Int()
+	if n > maxBytes {
+		return nil, fmt.Errorf("argument %d too large: %d > %d bytes", i+1, n, maxBytes)
+	}
+	b := make([]byte, n)
+	js.CopyBytesToGo(b, args[i])
+	return b, nil
+}
+
+// stringArg returns args[i], or "" if it is missing, null or undefined.
+func stringArg(args []js.Value, i int) (string, error) {
+	if i >= len(args) || args[i].IsNull() || args[i].IsUndefined() {
+		return "", nil
+	}
+	if args[i].Type() != js.TypeString {
+		return "", fmt.Errorf("argument %d must be a string", i+1)
+	}
+	return args[i].String(), nil
+}
+
+// result converts v to a JavaScript object through JSON, or describes err.
+func result(v interface{}, err error) interface{} {
+	if err == nil {
+		var b []byte
+		if b, err = json.Marshal(v); err == nil {
+			return js.Global().Get("JSON").Call("parse", string(b))
+		}
+	}
+	return js.ValueOf(map[string]interface{}{"error": err.Error()})
+}
+
+func cid(_ js.Value, args []js.Value) interface{} {
+	b, err := bytesArg(args, 0)
+	if err != nil {
+		return result(nil, err)
+	}
+	return core.CIDForBytes(b)
+}
+
+func siteID(_ js.Value, args []js.Value) interface{} {
+	pub, err := bytesArg(args, 0)
+	if err != nil {
+		return result(nil, err)
+	}
+	id, err := verify.SiteID(pub)
+	return result(map[string]string{"site_id": id}, err)
+}
+
+func verifySignature(_ js.Value, args []js.Value) interface{} {
+	var parts [3][]byte
+	for i := range parts {
+		b, err := bytesArg(args, i)
+		if err != nil {
+			return false
+		}
+		parts[i] = b
+	}
+	return verify.Signature(parts[0], parts[1], parts[2])
+}
+
+func verifyContent(_ js.Value, args []js.Value) interface{} {
+	b, err := bytesArg(args, 0)
+	if err != nil {
+		return false
+	}
+	want, err := stringArg(args, 1)
+	if err != nil || want == "" {
+		return false
+	}
+	return verify.Content(b, want)
+}
+
+func parseManifest(_ js.Value, args []js.Value) interface{} {
+	b, err := bytesArg(args, 0)
+	if err != nil {
+		return result(nil, err)
+	}
+	return result(verify.ParseManifest(b))
+}
+
+func verifyManifest(_ js.Value, args []js.Value) interface{} {
+	b, site, _, err := recordArgs(args)
+	if err != nil {
+		return result(nil, err)
+	}
+	return result(verify.CheckManifest(b, site))
+}
+
+func verifyFileRecord(_ js.Value, args []js.Value) interface{} {
+	b, site, path, err := recordArgs(args)
+	if err != nil {
+		return result(nil, err)
+	}
+	return result(verify.CheckFileRecord(b, site, path))
+}
+
+func verifyUpdateRecord(_ js.Value, args []js.Value) interface{} {
+	b, site, _, err := recordArgs(args)
+	if err != nil {
+		return result(nil, err)
+	}
+	return result(verify.CheckUpdateRecord(b, site))
+}
+
+// recordArgs reads (bytes, siteID, path) arguments.
+func recordArgs(args []js.Value) ([]byte, string, string, error) {
+	b, err := bytesArg(args, 0)
+	if err != nil {
+		return nil, "", "", err
+	}
+	site, err := stringArg(args, 1)
+	if err != nil {
+		return nil, "", "", err
+	}
+	path, err := stringArg(args, 2)
+	if err != nil {
+		return nil, "", "", err
+	}
+	return b, site, path, nil
+}
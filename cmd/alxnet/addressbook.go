@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+func addressBookUsage() {
+	fmt.Println("AlxNet Address Book - names of your own for sites you visit often")
+	fmt.Println("")
+	fmt.Println("Usage: alxnet addressbook <command> [options]")
+	fmt.Println("")
+	fmt.Println("Commands:")
+	fmt.Println("  list                                   List the address book")
+	fmt.Println("  add -name NAME -target SITE [-note TEXT]")
+	fmt.Println("                                         Save a site ID or site name under NAME, replacing an entry of that name")
+	fmt.Println("  remove -name NAME                      Remove an entry")
+	fmt.Println("")
+	fmt.Println("Options for every command:")
+	fmt.Println("  -api URL  Browser interface of the node (default: http://localhost:8080)")
+	fmt.Println("")
+	fmt.Println("Entries are kept by the node and typed into the browser page like site names.")
+}
+
+type aliasEntry struct {
+	Name   string    `json:"name"`
+	Target string    `json:"target"`
+	Note   string    `json:"note"`
+	Added  time.Time `json:"added"`
+}
+
+func cmdAddressBook(args []string) {
+	if len(args) < 1 {
+		addressBookUsage()
+		os.Exit(2)
+	}
+	cmd := args[0]
+	fs := flag.NewFlagSet("addressbook "+cmd, flag.ExitOnError)
+	fs.Usage = addressBookUsage
+	api := fs.String("api", "http://localhost:8080", "browser interface of the node")
+
+	var run func() error
+	switch cmd {
+	case "list":
+		run = func() error { return addressBookList(*api) }
+	case "add":
+		name := fs.String("name", "", "name to save the site under")
+		target := fs.String("target", "", "site ID or site name")
+		note := fs.String("note", "", "note shown with the entry")
+		run = func() error {
+			if *name == "" || *target == "" {
+				return errors.New("-name and -target are required")
+			}
+			var resp struct {
+				Alias aliasEntry `json:"alias"`
+			}
+			body := map[string]string{"name": *name, "target": *target, "note": *note}
+			if err := walletAPI(*api, http.MethodPost, "/api/addressbook", body, &resp); err != nil {
+				return err
+			}
+			fmt.Printf("%s -> %s\n", resp.Alias.Name, resp.Alias.Target)
+			return nil
+		}
+	case "remove":
+		name := fs.String("name", "", "entry name")
+		run = func() error {
+			if *name == "" {
+				return errors.New("-name is required")
+			}
+			var resp struct {
+				Name string `json:"name"`
+			}
+			if err := walletAPI(*api, http.MethodPost, "/api/addressbook/delete", map[string]string{"name": *name}, &resp); err != nil {
+				return err
+			}
+			fmt.Printf("Removed %s\n", resp.Name)
+			return nil
+		}
+	default:
+		addressBookUsage()
+		os.Exit(2)
+	}
+	_ = fs.Parse(args[1:])
+
+	if err := run(); err != nil {
+		log.Fatalf("addressbook %s: %v", cmd, err)
+	}
+}
+
+func addressBookList(api string) error {
+	var resp struct {
+		Aliases []aliasEntry `json:"aliases"`
+	}
+	if err := walletAPI(api, http.MethodGet, "/api/addressbook", nil, &resp); err != nil {
+		return err
+	}
+	for _, a := range resp.Aliases {
+		line := fmt.Sprintf("%-16s  %s", a.Name, a.Target)
+		if a.Note != "" {
+			line += "  # " + a.Note
+		}
+		fmt.Println(line)
+	}
+	fmt.Printf("%d entries\n", len(resp.Aliases))
+	return nil
+}
@@ -0,0 +1,616 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// adminTokenFile is where `alxnet start` keeps the admin token, relative
+// to the data directory.
+const adminTokenFile = "admin.token"
+
+func adminUsage() {
+	fmt.Println("AlxNet Admin - manage a running node")
+	fmt.Println("")
+	fmt.Println("Usage: alxnet admin <command> [options]")
+	fmt.Println("")
+	fmt.Println("Commands:")
+	fmt.Println("  peers                        List connected and recently seen peers")
+	fmt.Println("  bans                         List banned peers")
+	fmt.Println("  ban -peer ID -for 24h        Ban a peer and drop its connections")
+	fmt.Println("  unban -peer ID               Lift a ban")
+	fmt.Println("  pins                         List pinned sites")
+	fmt.Println("  pin -site ID                 Pin a site and announce it")
+	fmt.Println("  unpin -site ID               Unpin a site")
+	fmt.Println("  gc                           Reclaim space from the store's value log")
+	fmt.Println("  recount                      Count the store's keys again for the storage statistics")
+	fmt.Println("  denylist                     List denied site IDs and content CIDs")
+	fmt.Println("  deny -id ID [-reason TEXT]   Refuse to store or serve a site or content")
+	fmt.Println("  allow -id ID                 Remove an ID from the denylist")
+	fmt.Println("  gateways                     List the gateway authorizations this node holds")
+	fmt.Println("  gateway-add -proof PROOF     Install a site owner's authorization for this gateway")
+	fmt.Println("  gateway-remove -host HOST -site ID")
+	fmt.Println("                               Remove a gateway authorization")
+	fmt.Println("  nodes                        List the remote nodes shown in this node's UI")
+	fmt.Println("  node-add -name NAME -url URL [-node-token-file PATH]")
+	fmt.Println("                               Register another node's management interface; its token")
+	fmt.Println("                               is read from -node-token-file, ALXNET_NODE_TOKEN or stdin")
+	fmt.Println("  node-remove -name NAME       Remove a registered node")
+	fmt.Println("  tokens                       List the API tokens issued")
+	fmt.Println("  token-issue -name NAME -role ROLE")
+	fmt.Println("                               Issue an API token with role viewer, publisher or admin")
+	fmt.Println("  token-revoke -name NAME      Revoke an API token")
+	fmt.Println("  prove -peer ID -site ID|-cid CID")
+	fmt.Println("                               Challenge a peer to prove it stores a site's file or a blob")
+	fmt.Println("  reload                       Reload the node's configuration")
+	fmt.Println("  log-level [-set LEVEL]       Show or change the log level (debug, info, warn, error)")
+	fmt.Println("  audit [-kind K] [-peer ID] [-site ID] [-cid CID] [-since 1h] [-limit N]")
+	fmt.Println("                               Show rejected updates, deletes, browse requests, connections and logins")
+	fmt.Println("")
+	fmt.Println("Options for every command:")
+	fmt.Println("  -api URL          Node management interface (default: http://localhost:8082)")
+	fmt.Println("  -token TOKEN      Admin token (default: ALXNET_ADMIN_TOKEN or -token-file)")
+	fmt.Println("  -token-file PATH  File holding the admin token (default: ./data/admin.token)")
+}
+
+// adminClient calls a node's /api/admin endpoints.
+type adminClient struct {
+	api   string
+	token string
+	http  *http.Client
+}
+
+// adminFlags adds the connection flags to fs; call connect after parsing.
+func adminFlags(fs *flag.FlagSet) func() (*adminClient, error) {
+	api := fs.String("api", "http://localhost:8082", "node management interface")
+	token := fs.String("token", "", "admin token")
+	tokenFile := fs.String("token-file", filepath.Join("data", adminTokenFile), "file holding the admin token")
+	return func() (*adminClient, error) {
+		t := *token
+		if t == "" {
+			t = os.Getenv("ALXNET_ADMIN_TOKEN")
+		}
+		if t == "" {
+			b, err := os.ReadFile(*tokenFile)
+			if err != nil {
+				return nil, fmt.Errorf("no admin token: pass -token, set ALXNET_ADMIN_TOKEN or point -token-file at the node's %s (%w)", adminTokenFile, err)
+			}
+			t = strings.TrimSpace(string(b))
+		}
+		return &adminClient{api: strings.TrimRight(*api, "/"), token: t, http: &http.Client{Timeout: 2 * time.Minute}}, nil
+	}
+}
+
+// call sends body (nil for GET) to path and decodes the JSON answer.
+func (c *adminClient) call(path string, body, out interface{}) error {
+	method, reader := http.MethodGet, io.Reader(nil)
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		method, reader = http.MethodPost, bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, c.api+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("node returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("invalid response: %w", err)
+	}
+	return nil
+}
+
+func cmdAdmin(args []string) {
+	if len(args) < 1 {
+		adminUsage()
+		os.Exit(2)
+	}
+	cmd := args[0]
+	fs := flag.NewFlagSet("admin "+cmd, flag.ExitOnError)
+	fs.Usage = adminUsage
+	connect := adminFlags(fs)
+
+	var run func(c *adminClient) error
+	switch cmd {
+	case "peers":
+		run = adminPeers
+	case "bans":
+		run = func(c *adminClient) error { return adminBans(c, nil) }
+	case "ban", "unban":
+		id := fs.String("peer", "", "peer ID")
+		d := fs.Duration("for", 24*time.Hour, "ban duration")
+		run = func(c *adminClient) error {
+			if *id == "" {
+				return errors.New("-peer is required")
+			}
+			return adminBans(c, map[string]interface{}{"peer": *id, "duration": d.String(), "unban": cmd == "unban"})
+		}
+	case "pins":
+		run = func(c *adminClient) error { return adminPins(c, nil) }
+	case "pin", "unpin":
+		site := fs.String("site", "", "site ID")
+		run = func(c *adminClient) error {
+			if *site == "" {
+				return errors.New("-site is required")
+			}
+			return adminPins(c, map[string]interface{}{"site_id": *site, "pinned": cmd == "pin"})
+		}
+	case "gc":
+		run = adminGC
+	case "recount":
+		run = adminRecount
+	case "denylist":
+		run = func(c *adminClient) error { return adminDenylist(c, nil) }
+	case "deny", "allow":
+		id := fs.String("id", "", "site ID or content CID")
+		reason := fs.String("reason", "", "why the ID is denied")
+		run = func(c *adminClient) error {
+			if *id == "" {
+				return errors.New("-id is required")
+			}
+			return adminDenylist(c, map[string]interface{}{"id": *id, "reason": *reason, "remove": cmd == "allow"})
+		}
+	case "gateways":
+		run = func(c *adminClient) error { return adminGateways(c, nil) }
+	case "gateway-add":
+		proof := fs.String("proof", "", "authorization from `alxnet keytool gateway-auth` or the wallet")
+		run = func(c *adminClient) error {
+			if *proof == "" {
+				return errors.New("-proof is required")
+			}
+			return adminGateways(c, map[string]interface{}{"proof": *proof})
+		}
+	case "gateway-remove":
+		host := fs.String("host", "", "gateway host name")
+		site := fs.String("site", "", "site ID")
+		run = func(c *adminClient) error {
+			if *host == "" || *site == "" {
+				return errors.New("-host and -site are required")
+			}
+			return adminGateways(c, map[string]interface{}{"host": *host, "site_id": *site, "remove": true})
+		}
+	case "nodes":
+		run = func(c *adminClient) error { return adminNodes(c, nil) }
+	case "node-add":
+		name := fs.String("name", "", "name to show the node under")
+		nodeURL := fs.String("url", "", "the node's management interface, such as http://10.0.0.2:8082")
+		nodeToken := fs.String("node-token", "", "the node's admin token (visible to other users; prefer -node-token-file)")
+		nodeTokenFile := fs.String("node-token-file", "", "file holding the node's admin token")
+		run = func(c *adminClient) error {
+			if *name == "" || *nodeURL == "" {
+				return errors.New("-name and -url are required")
+			}
+			t, err := readNodeToken(*nodeToken, *nodeTokenFile, os.Stdin)
+			if err != nil {
+				return err
+			}
+			return adminNodes(c, map[string]interface{}{"name": *name, "url": *nodeURL, "token": t})
+		}
+	case "node-remove":
+		name := fs.String("name", "", "registered node name")
+		run = func(c *adminClient) error {
+			if *name == "" {
+				return errors.New("-name is required")
+			}
+			return adminNodes(c, map[string]interface{}{"name": *name, "remove": true})
+		}
+	case "tokens":
+		run = func(c *adminClient) error { return adminTokens(c, nil) }
+	case "token-issue":
+		name := fs.String("name", "", "name of the token, such as grafana")
+		role := fs.String("role", "viewer", "viewer, publisher or admin")
+		run = func(c *adminClient) error {
+			if *name == "" {
+				return errors.New("-name is required")
+			}
+			return adminTokens(c, map[string]interface{}{"name": *name, "role": *role})
+		}
+	case "token-revoke":
+		name := fs.String("name", "", "token name")
+		run = func(c *adminClient) error {
+			if *name == "" {
+				return errors.New("-name is required")
+			}
+			return adminTokens(c, map[string]interface{}{"name": *name, "revoke": true})
+		}
+	case "prove":
+		id := fs.String("peer", "", "peer ID")
+		site := fs.String("site", "", "site ID; a random file of the site is challenged")
+		cid := fs.String("cid", "", "content CID")
+		run = func(c *adminClient) error {
+			if *id == "" || (*site == "") == (*cid == "") {
+				return errors.New("-peer and one of -site or -cid are required")
+			}
+			return adminProve(c, map[string]string{"peer": *id, "site_id": *site, "cid": *cid})
+		}
+	case "reload":
+		run = adminReload
+	case "audit":
+		q := url.Values{}
+		kind := fs.String("kind", "", "update, delete, comment, moderation, pointer, appdata, domain, browse, connection, proof or auth")
+		peerID := fs.String("peer", "", "peer ID")
+		site := fs.String("site", "", "site ID")
+		cid := fs.String("cid", "", "record or content CID")
+		since := fs.Duration("since", 0, "only entries newer than this")
+		limit := fs.Int("limit", 0, "most entries to show (default 100)")
+		run = func(c *adminClient) error {
+			for k, v := range map[string]string{"kind": *kind, "peer": *peerID, "site": *site, "cid": *cid} {
+				if v != "" {
+					q.Set(k, v)
+				}
+			}
+			if *since > 0 {
+				q.Set("since", time.Now().Add(-*since).UTC().Format(time.RFC3339))
+			}
+			if *limit > 0 {
+				q.Set("limit", strconv.Itoa(*limit))
+			}
+			return adminAudit(c, q)
+		}
+	case "log-level":
+		level := fs.String("set", "", "new log level")
+		run = func(c *adminClient) error {
+			var body interface{}
+			if *level != "" {
+				body = map[string]string{"level": *level}
+			}
+			return adminLogLevel(c, body)
+		}
+	default:
+		adminUsage()
+		os.Exit(2)
+	}
+	_ = fs.Parse(args[1:])
+
+	c, err := connect()
+	if err == nil {
+		err = run(c)
+	}
+	if err != nil {
+		log.Fatalf("admin %s: %v", cmd, err)
+	}
+}
+
+func adminPeers(c *adminClient) error {
+	var resp struct {
+		Peers []struct {
+			Peer       string    `json:"peer"`
+			Connected  bool      `json:"connected"`
+			Address    string    `json:"address"`
+			Reputation int       `json:"reputation"`
+			LastSeen   time.Time `json:"last_seen"`
+			Agent      string    `json:"agent"`
+
+			GossipScore float64 `json:"gossip_score"`
+
+			ProofsPassed int `json:"proofs_passed"`
+			ProofsFailed int `json:"proofs_failed"`
+
+			BytesServed   int64 `json:"bytes_served"`
+			BytesReceived int64 `json:"bytes_received"`
+			Leecher       bool  `json:"leecher"`
+		} `json:"peers"`
+	}
+	if err := c.call("/api/admin/peers", nil, &resp); err != nil {
+		return err
+	}
+	for _, p := range resp.Peers {
+		state := "seen     "
+		if p.Connected {
+			state = "connected"
+		}
+		traffic := fmt.Sprintf("out %d in %d", p.BytesServed, p.BytesReceived)
+		if p.Leecher {
+			traffic += " leecher"
+		}
+		fmt.Printf("%s  %s  rep %4d  score %7.1f  proofs %d/%d  %s  %s  %s\n", p.Peer, state, p.Reputation, p.GossipScore,
+			p.ProofsPassed, p.ProofsPassed+p.ProofsFailed, traffic, p.Agent, p.Address)
+	}
+	fmt.Printf("%d peers\n", len(resp.Peers))
+	return nil
+}
+
+func adminBans(c *adminClient, body interface{}) error {
+	var resp struct {
+		Bans []struct {
+			Peer  string    `json:"peer"`
+			Until time.Time `json:"until"`
+		} `json:"bans"`
+	}
+	if err := c.call("/api/admin/bans", body, &resp); err != nil {
+		return err
+	}
+	for _, b := range resp.Bans {
+		fmt.Printf("%s  until %s\n", b.Peer, b.Until.Local().Format(time.RFC3339))
+	}
+	fmt.Printf("%d bans\n", len(resp.Bans))
+	return nil
+}
+
+func adminPins(c *adminClient, body interface{}) error {
+	var resp struct {
+		Pins []string `json:"pins"`
+	}
+	if err := c.call("/api/admin/pins", body, &resp); err != nil {
+		return err
+	}
+	for _, p := range resp.Pins {
+		fmt.Println(p)
+	}
+	fmt.Printf("%d pinned sites\n", len(resp.Pins))
+	return nil
+}
+
+func adminGC(c *adminClient) error {
+	var resp struct {
+		Rewritten int    `json:"rewritten"`
+		Duration  string `json:"duration"`
+	}
+	if err := c.call("/api/admin/gc", struct{}{}, &resp); err != nil {
+		return err
+	}
+	fmt.Printf("rewrote %d value log files in %s\n", resp.Rewritten, resp.Duration)
+	return nil
+}
+
+func adminRecount(c *adminClient) error {
+	var resp struct {
+		Started bool `json:"started"`
+	}
+	if err := c.call("/api/admin/stats/recount", struct{}{}, &resp); err != nil {
+		return err
+	}
+	if !resp.Started {
+		fmt.Println("a recount is already running")
+		return nil
+	}
+	fmt.Println("recount started; the node UI's storage statistics update when it finishes")
+	return nil
+}
+
+func adminDenylist(c *adminClient, body interface{}) error {
+	var resp struct {
+		Entries []struct {
+			ID     string    `json:"id"`
+			Reason string    `json:"reason"`
+			Added  time.Time `json:"added"`
+		} `json:"entries"`
+	}
+	if err := c.call("/api/admin/denylist", body, &resp); err != nil {
+		return err
+	}
+	for _, e := range resp.Entries {
+		fmt.Printf("%s  %s  %s\n", e.ID, e.Added.Local().Format(time.RFC3339), e.Reason)
+	}
+	fmt.Printf("%d denied\n", len(resp.Entries))
+	return nil
+}
+
+func adminGateways(c *adminClient, body interface{}) error {
+	var resp struct {
+		Authorizations []struct {
+			SiteID  string    `json:"site_id"`
+			Host    string    `json:"host"`
+			Expires time.Time `json:"expires"`
+			Expired bool      `json:"expired"`
+		} `json:"authorizations"`
+	}
+	if err := c.call("/api/admin/gateway", body, &resp); err != nil {
+		return err
+	}
+	for _, a := range resp.Authorizations {
+		state := "until " + a.Expires.Local().Format(time.RFC3339)
+		if a.Expired {
+			state = "expired " + a.Expires.Local().Format(time.RFC3339)
+		}
+		fmt.Printf("%s  %s  %s\n", a.Host, a.SiteID, state)
+	}
+	fmt.Printf("%d authorizations\n", len(resp.Authorizations))
+	return nil
+}
+
+func adminNodes(c *adminClient, body interface{}) error {
+	var resp struct {
+		Nodes []struct {
+			Name  string    `json:"name"`
+			URL   string    `json:"url"`
+			Added time.Time `json:"added"`
+		} `json:"nodes"`
+	}
+	if err := c.call("/api/admin/nodes", body, &resp); err != nil {
+		return err
+	}
+	for _, n := range resp.Nodes {
+		fmt.Printf("%-16s  %s  added %s\n", n.Name, n.URL, n.Added.Local().Format(time.RFC3339))
+	}
+	fmt.Printf("%d nodes\n", len(resp.Nodes))
+	return nil
+}
+
+func adminTokens(c *adminClient, body interface{}) error {
+	var resp struct {
+		Token  string `json:"token"`
+		Tokens []struct {
+			Name    string    `json:"name"`
+			Role    string    `json:"role"`
+			Created time.Time `json:"created"`
+		} `json:"tokens"`
+	}
+	if err := c.call("/api/admin/tokens", body, &resp); err != nil {
+		return err
+	}
+	if resp.Token != "" {
+		fmt.Printf("token: %s (shown once)\n", resp.Token)
+		return nil
+	}
+	for _, t := range resp.Tokens {
+		fmt.Printf("%-16s  %-9s  issued %s\n", t.Name, t.Role, t.Created.Local().Format(time.RFC3339))
+	}
+	fmt.Printf("%d tokens\n", len(resp.Tokens))
+	return nil
+}
+
+func adminProve(c *adminClient, body interface{}) error {
+	var resp struct {
+		Proof struct {
+			CID    string        `json:"cid"`
+			Offset int64         `json:"offset"`
+			Length int           `json:"length"`
+			Result string        `json:"result"`
+			RTT    time.Duration `json:"rtt"`
+		} `json:"proof"`
+	}
+	if err := c.call("/api/admin/proof", body, &resp); err != nil {
+		return err
+	}
+	p := resp.Proof
+	fmt.Printf("%s  bytes %d-%d  %s  in %s\n", p.CID, p.Offset, p.Offset+int64(p.Length), p.Result, p.RTT.Round(time.Millisecond))
+	if p.Result != "ok" {
+		return fmt.Errorf("peer failed the storage challenge (%s)", p.Result)
+	}
+	return nil
+}
+
+func adminReload(c *adminClient) error {
+	var resp struct {
+		Applied map[string]interface{} `json:"applied"`
+	}
+	if err := c.call("/api/admin/config/reload", struct{}{}, &resp); err != nil {
+		return err
+	}
+	for k, v := range resp.Applied {
+		fmt.Printf("%s: %v\n", k, v)
+	}
+	fmt.Println("configuration reloaded")
+	return nil
+}
+
+func adminAudit(c *adminClient, q url.Values) error {
+	var resp struct {
+		Entries []struct {
+			Time       time.Time `json:"time"`
+			Kind       string    `json:"kind"`
+			Peer       string    `json:"peer"`
+			Client     string    `json:"client"`
+			SiteID     string    `json:"site_id"`
+			RecordCID  string    `json:"record_cid"`
+			ContentCID string    `json:"content_cid"`
+			Reason     string    `json:"reason"`
+		} `json:"entries"`
+	}
+	path := "/api/admin/audit"
+	if len(q) > 0 {
+		path += "?" + q.Encode()
+	}
+	if err := c.call(path, nil, &resp); err != nil {
+		return err
+	}
+	for _, e := range resp.Entries {
+		fmt.Printf("%s  %-10s  %s  %s\n", e.Time.Local().Format(time.RFC3339), e.Kind, e.Peer, e.Reason)
+		for _, f := range [][2]string{{"client", e.Client}, {"site", e.SiteID}, {"record", e.RecordCID}, {"content", e.ContentCID}} {
+			if f[1] != "" {
+				fmt.Printf("    %-8s %s\n", f[0], f[1])
+			}
+		}
+	}
+	fmt.Printf("%d rejections\n", len(resp.Entries))
+	return nil
+}
+
+func adminLogLevel(c *adminClient, body interface{}) error {
+	var resp struct {
+		Level string `json:"level"`
+	}
+	if err := c.call("/api/admin/log/level", body, &resp); err != nil {
+		return err
+	}
+	fmt.Printf("log level: %s\n", resp.Level)
+	return nil
+}
+
+// readNodeToken returns a remote node's admin token from the flag value,
+// the file at path, ALXNET_NODE_TOKEN or the first line of in, in that
+// order, so it need not appear in the process list.
+func readNodeToken(flagValue, path string, in io.Reader) (string, error) {
+	if flagValue != "" {
+		return flagValue, nil
+	}
+	if path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		if t := strings.TrimSpace(string(b)); t != "" {
+			return t, nil
+		}
+		return "", fmt.Errorf("%s is empty", path)
+	}
+	if t := os.Getenv("ALXNET_NODE_TOKEN"); t != "" {
+		return t, nil
+	}
+	fmt.Fprint(os.Stderr, "Node token: ")
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("failed to read the node token: %w", err)
+	}
+	if t := strings.TrimSpace(line); t != "" {
+		return t, nil
+	}
+	return "", errors.New("the node's admin token is required")
+}
+
+// loadAdminToken returns the node's admin token: ALXNET_ADMIN_TOKEN if set,
+// otherwise the token in path, created with a random value on first start.
+func loadAdminToken(path string) (string, error) {
+	if t := os.Getenv("ALXNET_ADMIN_TOKEN"); t != "" {
+		return t, nil
+	}
+	b, err := os.ReadFile(path)
+	if err == nil {
+		if t := strings.TrimSpace(string(b)); t != "" {
+			return t, nil
+		}
+		return "", fmt.Errorf("%s is empty", path)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	t := hex.EncodeToString(raw)
+	if err := os.WriteFile(path, []byte(t+"\n"), 0o600); err != nil {
+		return "", err
+	}
+	return t, nil
+}
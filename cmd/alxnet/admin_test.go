@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadNodeToken(t *testing.T) {
+	t.Setenv("ALXNET_NODE_TOKEN", "")
+	path := filepath.Join(t.TempDir(), "node.token")
+	if err := os.WriteFile(path, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	stdin := strings.NewReader("from-stdin\n")
+
+	if got, _ := readNodeToken("from-flag", path, stdin); got != "from-flag" {
+		t.Errorf("with -node-token: got %q", got)
+	}
+	if got, _ := readNodeToken("", path, stdin); got != "from-file" {
+		t.Errorf("with -node-token-file: got %q", got)
+	}
+	if got, _ := readNodeToken("", "", stdin); got != "from-stdin" {
+		t.Errorf("from stdin: got %q", got)
+	}
+	t.Setenv("ALXNET_NODE_TOKEN", "from-env")
+	if got, _ := readNodeToken("", "", stdin); got != "from-env" {
+		t.Errorf("with ALXNET_NODE_TOKEN: got %q", got)
+	}
+	t.Setenv("ALXNET_NODE_TOKEN", "")
+	if _, err := readNodeToken("", "", strings.NewReader("\n")); err == nil {
+		t.Error("accepted an empty token")
+	}
+}
+
+func TestLoadAdminToken(t *testing.T) {
+	t.Setenv("ALXNET_ADMIN_TOKEN", "")
+	path := filepath.Join(t.TempDir(), adminTokenFile)
+
+	first, err := loadAdminToken(path)
+	if err != nil {
+		t.Fatalf("loadAdminToken: %v", err)
+	}
+	if len(first) != 64 {
+		t.Errorf("generated token %q, want 64 hex characters", first)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0o600 {
+		t.Errorf("token file mode = %v, %v, want 0600", fi.Mode().Perm(), err)
+	}
+	if again, err := loadAdminToken(path); err != nil || again != first {
+		t.Errorf("second load = %q, %v, want the stored token", again, err)
+	}
+
+	t.Setenv("ALXNET_ADMIN_TOKEN", "from-env")
+	if got, _ := loadAdminToken(path); got != "from-env" {
+		t.Errorf("with ALXNET_ADMIN_TOKEN: got %q", got)
+	}
+
+	t.Setenv("ALXNET_ADMIN_TOKEN", "")
+	if err := os.WriteFile(path, []byte("\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadAdminToken(path); err == nil {
+		t.Error("accepted an empty token file")
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"alxnet/internal/core"
+	"alxnet/internal/store"
+)
+
+// Benchmark limits. Each case writes at most maxBenchCaseBytes so that a run
+// with large payloads stays within a few hundred MB of scratch disk.
+const (
+	maxBenchOps         = 1000000
+	maxBenchConcurrency = 1024
+	maxBenchCaseBytes   = 64 * 1024 * 1024
+)
+
+func benchUsage() {
+	fmt.Println("AlxNet Bench - measure storage throughput and latency")
+	fmt.Println("")
+	fmt.Println("Usage: alxnet bench store [options]")
+	fmt.Println("")
+	fmt.Println("Writes and reads records and content in a scratch Badger database and")
+	fmt.Println("reports throughput with p50/p95/p99 latencies for every combination of")
+	fmt.Println("payload size and concurrency.")
+	fmt.Println("")
+	fmt.Println("Options:")
+	fmt.Println("  -dir PATH            Scratch directory (default: temporary, removed afterwards)")
+	fmt.Println("  -ops 1000            Operations per case (capped at 64 MiB written per case)")
+	fmt.Println("  -sizes 256,4k,64k,1m Payload sizes")
+	fmt.Println("  -concurrency 1,8     Concurrent workers")
+	fmt.Println("  -payload random      random (incompressible) or text")
+	fmt.Println("  -compress            zstd-compress content as a node does (default: true)")
+	fmt.Println("  -cache-size 0        Content cache in bytes (0 measures Badger alone)")
+}
+
+func cmdBench(args []string) {
+	if len(args) < 1 || args[0] != "store" {
+		benchUsage()
+		os.Exit(2)
+	}
+	fs := flag.NewFlagSet("bench store", flag.ExitOnError)
+	fs.Usage = benchUsage
+	dir := fs.String("dir", "", "scratch directory")
+	ops := fs.Int("ops", 1000, "operations per case")
+	sizesFlag := fs.String("sizes", "256,4k,64k,1m", "payload sizes")
+	concFlag := fs.String("concurrency", "1,8", "concurrent workers")
+	payload := fs.String("payload", "random", "random or text")
+	compress := fs.Bool("compress", true, "compress content")
+	cacheSize := fs.Int64("cache-size", 0, "content cache bytes")
+	_ = fs.Parse(args[1:])
+
+	if *ops < 1 || *ops > maxBenchOps {
+		log.Fatalf("bench: -ops must be between 1 and %d", maxBenchOps)
+	}
+	if *payload != "random" && *payload != "text" {
+		log.Fatalf("bench: -payload must be random or text")
+	}
+	sizes, err := parseSizeList(*sizesFlag, 1, store.MaxValueLength)
+	if err != nil {
+		log.Fatalf("bench: -sizes: %v", err)
+	}
+	levels, err := parseSizeList(*concFlag, 1, maxBenchConcurrency)
+	if err != nil {
+		log.Fatalf("bench: -concurrency: %v", err)
+	}
+
+	scratch := *dir
+	if scratch == "" {
+		scratch, err = os.MkdirTemp("", "alxnet-bench-")
+		if err != nil {
+			log.Fatalf("bench: %v", err)
+		}
+		defer os.RemoveAll(scratch)
+	}
+	db, err := store.Open(scratch)
+	if err != nil {
+		log.Fatalf("bench: %v", err)
+	}
+	defer db.Close()
+	db.SetCompression(*compress)
+	db.SetContentCacheSize(*cacheSize)
+
+	const row = "%-8s %-4s %6s %5s %7s %10s %9s %10s %10s %10s\n"
+	fmt.Printf(row, "KIND", "OP", "SIZE", "CONC", "OPS", "OPS/S", "MB/S", "P50", "P95", "P99")
+	for _, kind := range []string{"record", "content"} {
+		for _, size := range sizes {
+			for _, conc := range levels {
+				n := *ops
+				if n*size > maxBenchCaseBytes {
+					n = max(maxBenchCaseBytes/size, conc)
+				}
+				put, get, err := benchStoreCase(db, kind, size, conc, n, *payload == "text")
+				if err != nil {
+					log.Fatalf("bench: %s %s: %v", kind, formatSize(size), err)
+				}
+				for _, r := range []struct {
+					op  string
+					res benchResult
+				}{{"put", put}, {"get", get}} {
+					p50, p95, p99 := r.res.percentiles()
+					secs := r.res.elapsed.Seconds()
+					fmt.Printf(row, kind, r.op, formatSize(size), strconv.Itoa(conc), strconv.Itoa(r.res.ops),
+						fmt.Sprintf("%.0f", float64(r.res.ops)/secs),
+						fmt.Sprintf("%.1f", float64(r.res.ops*size)/secs/(1<<20)),
+						roundLatency(p50), roundLatency(p95), roundLatency(p99))
+				}
+			}
+		}
+	}
+}
+
+type benchResult struct {
+	ops       int
+	elapsed   time.Duration
+	latencies []time.Duration
+}
+
+// percentiles returns the nearest-rank p50, p95 and p99 latencies.
+func (r benchResult) percentiles() (p50, p95, p99 time.Duration) {
+	lat := append([]time.Duration{}, r.latencies...)
+	sort.Slice(lat, func(i, j int) bool { return lat[i] < lat[j] })
+	rank := func(q float64) time.Duration {
+		if len(lat) == 0 {
+			return 0
+		}
+		return lat[int(math.Ceil(q*float64(len(lat))))-1]
+	}
+	return rank(0.50), rank(0.95), rank(0.99)
+}
+
+// benchStoreCase writes n unique payloads of size bytes with conc workers,
+// then reads all of them back.
+func benchStoreCase(db *store.Store, kind string, size, conc, n int, text bool) (benchResult, benchResult, error) {
+	base := make([]byte, size)
+	if text {
+		const line = "<p>The quick brown fox jumps over the lazy dog.</p>\n"
+		for i := range base {
+			base[i] = line[i%len(line)]
+		}
+	} else if _, err := rand.Read(base); err != nil {
+		return benchResult{}, benchResult{}, err
+	}
+
+	// Each payload differs in its first bytes; keys are computed up front so
+	// that hashing is not part of the measured latency.
+	payload := func(buf []byte, i int) []byte {
+		copy(buf, base)
+		var idx [8]byte
+		binary.BigEndian.PutUint64(idx[:], uint64(i))
+		copy(buf, idx[:min(8, size)])
+		return buf
+	}
+	keys := make([]string, n)
+	buf := make([]byte, size)
+	for i := range keys {
+		if kind == "record" {
+			keys[i] = core.CIDForBytes(payload(buf, i))
+		} else {
+			keys[i] = core.CIDForContent(payload(buf, i))
+		}
+	}
+
+	put, err := runBench(n, conc, size, func(buf []byte, i int) error {
+		if kind == "record" {
+			return db.PutRecord(keys[i], payload(buf, i))
+		}
+		return db.PutContent(keys[i], payload(buf, i))
+	})
+	if err != nil {
+		return put, benchResult{}, err
+	}
+	get, err := runBench(n, conc, size, func(_ []byte, i int) error {
+		var data []byte
+		var err error
+		if kind == "record" {
+			data, err = db.GetRecord(keys[i])
+		} else {
+			data, err = db.GetContent(keys[i])
+		}
+		if err == nil && len(data) != size {
+			err = fmt.Errorf("read %d bytes, want %d", len(data), size)
+		}
+		return err
+	})
+	return put, get, err
+}
+
+// runBench runs op for indices 0..n-1 on conc workers, each with its own
+// scratch buffer of size bytes, and records every operation's latency.
+func runBench(n, conc, size int, op func(buf []byte, i int) error) (benchResult, error) {
+	var next atomic.Int64
+	var firstErr error
+	var errOnce sync.Once
+	perWorker := make([][]time.Duration, conc)
+
+	var wg sync.WaitGroup
+	start := time.Now()
+	for w := 0; w < conc; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			buf := make([]byte, size)
+			for {
+				i := int(next.Add(1)) - 1
+				if i >= n {
+					return
+				}
+				t := time.Now()
+				if err := op(buf, i); err != nil {
+					errOnce.Do(func() { firstErr = err })
+					return
+				}
+				perWorker[w] = append(perWorker[w], time.Since(t))
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	res := benchResult{ops: n, elapsed: time.Since(start)}
+	for _, lat := range perWorker {
+		res.latencies = append(res.latencies, lat...)
+	}
+	return res, firstErr
+}
+
+// parseSizeList parses a comma-separated list of sizes with optional k/m/g
+// suffixes (powers of 1024), each within [lo, hi].
+func parseSizeList(s string, lo, hi int) ([]int, error) {
+	var out []int
+	for _, field := range strings.Split(s, ",") {
+		field = strings.ToLower(strings.TrimSpace(field))
+		num, mult := field, 1
+		switch {
+		case strings.HasSuffix(field, "k"):
+			num, mult = strings.TrimSuffix(field, "k"), 1<<10
+		case strings.HasSuffix(field, "m"):
+			num, mult = strings.TrimSuffix(field, "m"), 1<<20
+		case strings.HasSuffix(field, "g"):
+			num, mult = strings.TrimSuffix(field, "g"), 1<<30
+		}
+		v, err := strconv.Atoi(num)
+		if err != nil || v < 1 || v > hi/mult || v*mult < lo {
+			return nil, fmt.Errorf("invalid value %q (allowed %d..%d)", field, lo, hi)
+		}
+		out = append(out, v*mult)
+	}
+	return out, nil
+}
+
+func formatSize(n int) string {
+	switch {
+	case n >= 1<<20 && n%(1<<20) == 0:
+		return fmt.Sprintf("%dm", n>>20)
+	case n >= 1<<10 && n%(1<<10) == 0:
+		return fmt.Sprintf("%dk", n>>10)
+	}
+	return strconv.Itoa(n)
+}
+
+func roundLatency(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond)
+	case d >= time.Microsecond:
+		return d.Round(100 * time.Nanosecond)
+	}
+	return d
+}
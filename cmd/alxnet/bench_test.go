@@ -0,0 +1,47 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSizeList(t *testing.T) {
+	tests := []struct {
+		in      string
+		hi      int
+		want    []int
+		wantErr bool
+	}{
+		{"256,4k,1M", 1 << 30, []int{256, 4096, 1 << 20}, false},
+		{" 8 , 16 ", 1024, []int{8, 16}, false},
+		{"1024", 1024, []int{1024}, false},
+		{"2k", 1024, nil, true},
+		{"0", 1024, nil, true},
+		{"-4", 1024, nil, true},
+		{"", 1024, nil, true},
+		{"1G", 1 << 30, []int{1 << 30}, false},
+		{"4g", 1 << 30, nil, true},
+		{"9999999999999m", 1 << 30, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseSizeList(tt.in, 1, tt.hi)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseSizeList(%q) = %v, %v; want %v, err %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestBenchPercentiles(t *testing.T) {
+	var r benchResult
+	for i := 100; i >= 1; i-- {
+		r.latencies = append(r.latencies, time.Duration(i)*time.Millisecond)
+	}
+	p50, p95, p99 := r.percentiles()
+	if p50 != 50*time.Millisecond || p95 != 95*time.Millisecond || p99 != 99*time.Millisecond {
+		t.Errorf("percentiles() = %v, %v, %v; want 50ms, 95ms, 99ms", p50, p95, p99)
+	}
+	if p50, _, _ := (benchResult{}).percentiles(); p50 != 0 {
+		t.Errorf("percentiles() of no samples = %v, want 0", p50)
+	}
+}
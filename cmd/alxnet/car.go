@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+func carUsage() {
+	fmt.Println("AlxNet CAR - exchange site content with IPFS as CAR archives")
+	fmt.Println("")
+	fmt.Println("Usage: alxnet car <export|import> [options]")
+	fmt.Println("")
+	fmt.Println("Commands:")
+	fmt.Println("  export -domain NAME -o site.car   Write a site's records and files to a CAR file")
+	fmt.Println("  import -i site.car                Store the blocks of a CAR file on the node")
+	fmt.Println("")
+	fmt.Println("Options:")
+	fmt.Println("  -api URL          Node management interface (default: http://localhost:8082)")
+	fmt.Println("")
+	fmt.Println("Blocks map to CIDv1 raw/sha2-256, so exported archives can be pinned with")
+	fmt.Println("`ipfs dag import` and raw blocks from IPFS archives are imported as content.")
+}
+
+func cmdCAR(args []string) {
+	if len(args) < 1 {
+		carUsage()
+		os.Exit(2)
+	}
+	switch args[0] {
+	case "export":
+		carExport(args[1:])
+	case "import":
+		carImport(args[1:])
+	default:
+		carUsage()
+		os.Exit(2)
+	}
+}
+
+func carExport(args []string) {
+	fs := flag.NewFlagSet("car export", flag.ExitOnError)
+	fs.Usage = carUsage
+	domain := fs.String("domain", "", "site name or site ID")
+	out := fs.String("o", "", "output CAR file")
+	api := fs.String("api", "http://localhost:8082", "node management interface")
+	_ = fs.Parse(args)
+
+	if *domain == "" || *out == "" {
+		carUsage()
+		os.Exit(2)
+	}
+	query := url.Values{}
+	query.Set("domain", strings.TrimSuffix(*domain, ".bn"))
+
+	client := &http.Client{Timeout: 5 * time.Minute}
+	resp, err := client.Get(strings.TrimRight(*api, "/") + "/api/storage/car/export?" + query.Encode())
+	if err != nil {
+		log.Fatalf("car export: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		log.Fatalf("car export: node returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	f, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		log.Fatalf("car export: %v", err)
+	}
+	n, err := io.Copy(f, resp.Body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(*out)
+		log.Fatalf("car export: %v", err)
+	}
+	fmt.Printf("wrote %s (%d bytes)\n", *out, n)
+}
+
+func carImport(args []string) {
+	fs := flag.NewFlagSet("car import", flag.ExitOnError)
+	fs.Usage = carUsage
+	in := fs.String("i", "", "input CAR file")
+	api := fs.String("api", "http://localhost:8082", "node management interface")
+	_ = fs.Parse(args)
+
+	if *in == "" {
+		carUsage()
+		os.Exit(2)
+	}
+	f, err := os.Open(*in)
+	if err != nil {
+		log.Fatalf("car import: %v", err)
+	}
+	defer f.Close()
+
+	client := &http.Client{Timeout: 5 * time.Minute}
+	resp, err := client.Post(strings.TrimRight(*api, "/")+"/api/storage/car/import", "application/vnd.ipld.car", f)
+	if err != nil {
+		log.Fatalf("car import: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		log.Fatalf("car import: node returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var result struct {
+		Blocks          int    `json:"blocks"`
+		Skipped         int    `json:"skipped"`
+		SiteID          string `json:"site_id"`
+		HeadApplied     bool   `json:"head_applied"`
+		HeadError       string `json:"head_error"`
+		ManifestApplied bool   `json:"manifest_applied"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		log.Fatalf("car import: invalid response: %v", err)
+	}
+
+	fmt.Printf("blocks:   %d imported, %d skipped\n", result.Blocks, result.Skipped)
+	if result.SiteID == "" {
+		return
+	}
+	fmt.Printf("site:     %s\n", result.SiteID)
+	switch {
+	case result.HeadApplied:
+		fmt.Println("head:     applied")
+	case result.HeadError != "":
+		fmt.Printf("head:     not applied (%s)\n", result.HeadError)
+	}
+	if result.ManifestApplied {
+		fmt.Println("manifest: installed")
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"alxnet/internal/p2p"
+)
+
+func checkUsage() {
+	fmt.Println("AlxNet Check - report how available a site is before sharing it")
+	fmt.Println("")
+	fmt.Println("Usage: alxnet check -domain NAME [options]")
+	fmt.Println("")
+	fmt.Println("Resolves the name on a running node, walks the site's files and asks a")
+	fmt.Println("random sample of connected peers which of them they store.")
+	fmt.Println("")
+	fmt.Println("Options:")
+	fmt.Println("  -domain NAME      Site name (mysite or mysite.bn) or 64-char site ID")
+	fmt.Println("  -peers 5          Number of random peers to query")
+	fmt.Println("  -api URL          Node management interface (default: http://localhost:8082)")
+	fmt.Println("")
+	fmt.Println("Exits with status 1 if any file is missing from every queried peer.")
+}
+
+type checkReport struct {
+	Domain    string `json:"domain"`
+	SiteID    string `json:"site_id"`
+	Queried   int    `json:"queried"`
+	Responded int    `json:"responded"`
+	Missing   int    `json:"missing"`
+	Status    string `json:"status"`
+	Files     []struct {
+		Path    string `json:"path"`
+		CID     string `json:"cid"`
+		Holders int    `json:"holders"`
+		Local   bool   `json:"local"`
+	} `json:"files"`
+}
+
+func cmdCheck(args []string) {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	fs.Usage = checkUsage
+	domain := fs.String("domain", "", "site name or site ID")
+	peers := fs.Int("peers", 5, "number of random peers to query")
+	api := fs.String("api", "http://localhost:8082", "node management interface")
+	_ = fs.Parse(args)
+
+	if *domain == "" {
+		checkUsage()
+		os.Exit(2)
+	}
+	// Links are often written with the .bn suffix; the registry stores bare names.
+	name := strings.TrimSuffix(*domain, ".bn")
+
+	query := url.Values{}
+	query.Set("domain", name)
+	query.Set("peers", strconv.Itoa(*peers))
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(strings.TrimRight(*api, "/") + "/api/network/check?" + query.Encode())
+	if err != nil {
+		log.Fatalf("check: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		log.Fatalf("check: node returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var report checkReport
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		log.Fatalf("check: invalid response: %v", err)
+	}
+
+	fmt.Printf("site:   %s (%s)\n", *domain, report.SiteID)
+	fmt.Printf("peers:  %d queried, %d responded\n", report.Queried, report.Responded)
+	fmt.Println("")
+	for _, f := range report.Files {
+		mark := "ok     "
+		if f.Holders == 0 {
+			mark = "MISSING"
+		}
+		local := ""
+		if !f.Local {
+			local = " (not stored locally)"
+		}
+		fmt.Printf("  %s %d/%d  %s  %s%s\n", mark, f.Holders, report.Responded, p2p.Short(f.CID), f.Path, local)
+	}
+	fmt.Println("")
+	fmt.Printf("result: %s", report.Status)
+	if report.Missing > 0 {
+		fmt.Printf(" (%d of %d files missing)", report.Missing, len(report.Files))
+	}
+	fmt.Println("")
+
+	if report.Missing > 0 || report.Responded == 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"alxnet/internal/core"
+	"alxnet/internal/ntp"
+
+	"go.uber.org/zap"
+)
+
+// clockWarnOffset is how far the local clock may be off before start logs
+// a warning.
+const clockWarnOffset = 5 * time.Second
+
+// checkClock compares the local clock with an NTP server and logs how far
+// off it is. Peers reject records dated more than the max clock skew ahead
+// of their clocks, so a clock running fast gets this node's updates
+// rejected and one running slow makes it reject its peers'.
+func checkClock(ctx context.Context, server string, logger *zap.Logger) {
+	res, err := ntp.Query(ctx, server)
+	if err != nil {
+		logger.Warn("could not check the local clock", zap.String("server", server), zap.Error(err))
+		return
+	}
+	fields := []zap.Field{
+		zap.String("server", server),
+		zap.Duration("offset", res.Offset),
+		zap.Duration("max_clock_skew", core.MaxClockSkew()),
+	}
+	switch skew := res.Offset.Abs(); {
+	case skew >= core.MaxClockSkew():
+		logger.Error("local clock is off by more than the max clock skew; peers will reject records published here and this node will reject theirs", fields...)
+	case skew >= clockWarnOffset:
+		logger.Warn("local clock is drifting; enable network time sync", fields...)
+	default:
+		logger.Debug("local clock in sync", fields...)
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"alxnet/internal/store"
+)
+
+// doctorCheck mirrors the checks returned by /api/admin/doctor.
+type doctorCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+	Fix    string `json:"fix,omitempty"`
+}
+
+func cmdDoctor(args []string) {
+	fset := flag.NewFlagSet("doctor", flag.ExitOnError)
+	dataDir := fset.String("data", "./data", "node data directory")
+	connect := adminFlags(fset)
+	_ = fset.Parse(args)
+
+	checks := dataDirChecks(*dataDir)
+
+	c, err := connect()
+	var remote struct {
+		Checks []doctorCheck `json:"checks"`
+	}
+	if err == nil {
+		err = c.call("/api/admin/doctor", nil, &remote)
+	}
+	switch {
+	case err == nil:
+		checks = append(checks, remote.Checks...)
+	case isConnRefused(err):
+		checks = append(checks, doctorCheck{Name: "node", Status: "fail",
+			Detail: "no node answering: " + err.Error(),
+			Fix:    "start it with `alxnet start`, or pass -api with the node UI address; connectivity checks need a running node"})
+		checks = append(checks, offlineStoreCheck(*dataDir))
+	default:
+		checks = append(checks, doctorCheck{Name: "node", Status: "fail", Detail: err.Error(),
+			Fix: "pass the node's admin token with -token, ALXNET_ADMIN_TOKEN or -token-file"})
+		checks = append(checks, offlineStoreCheck(*dataDir))
+	}
+
+	failed := printChecks(checks)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// printChecks prints checks and returns how many failed.
+func printChecks(checks []doctorCheck) int {
+	failed := 0
+	for _, c := range checks {
+		fmt.Printf("[%-4s] %-10s %s\n", c.Status, c.Name, c.Detail)
+		if c.Fix != "" {
+			fmt.Printf("       %-10s fix: %s\n", "", c.Fix)
+		}
+		if c.Status == "fail" {
+			failed++
+		}
+	}
+	if failed == 0 {
+		fmt.Println("no problems found")
+	} else {
+		fmt.Printf("%d checks failed\n", failed)
+	}
+	return failed
+}
+
+// isConnRefused reports whether err means nothing is listening at the API.
+func isConnRefused(err error) bool {
+	var urlErr *url.Error
+	var opErr *net.OpError
+	return errors.As(err, &urlErr) && errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// dataDirChecks checks the data directory exists, is writable and keeps
+// secrets away from other users.
+func dataDirChecks(dir string) []doctorCheck {
+	c := doctorCheck{Name: "data-dir"}
+	fi, err := os.Stat(dir)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		c.Status, c.Detail = "warn", dir+" does not exist"
+		c.Fix = "it is created by `alxnet start`; pass -data if the node uses another directory"
+		return []doctorCheck{c}
+	case err != nil:
+		c.Status, c.Detail, c.Fix = "fail", err.Error(), "check the path and its permissions"
+		return []doctorCheck{c}
+	case !fi.IsDir():
+		c.Status, c.Detail, c.Fix = "fail", dir+" is not a directory", "point -data at the node's data directory"
+		return []doctorCheck{c}
+	}
+	probe, err := os.CreateTemp(dir, ".doctor-*")
+	if err != nil {
+		c.Status, c.Detail = "fail", dir+" is not writable: "+err.Error()
+		c.Fix = "give the user running the node write access, e.g. chown -R $USER " + dir
+		return []doctorCheck{c}
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+	c.Status, c.Detail = "ok", dir+" is writable"
+	checks := []doctorCheck{c}
+
+	if runtime.GOOS == "windows" {
+		return checks
+	}
+	perm := doctorCheck{Name: "secrets", Status: "ok", Detail: "admin token and wallets are private"}
+	var exposed []string
+	secrets := []string{filepath.Join(dir, adminTokenFile)}
+	wallets, _ := filepath.Glob(filepath.Join(dir, "wallets", "*.wallet"))
+	for _, path := range append(secrets, wallets...) {
+		if fi, err := os.Stat(path); err == nil && fi.Mode().Perm()&0o077 != 0 {
+			exposed = append(exposed, path)
+		}
+	}
+	if fi.Mode().Perm()&0o002 != 0 {
+		exposed = append(exposed, dir+" (world-writable)")
+	}
+	if len(exposed) > 0 {
+		perm.Status = "warn"
+		perm.Detail = fmt.Sprintf("readable or writable by other users: %v", exposed)
+		perm.Fix = "chmod 600 the files and chmod 700 " + dir
+	}
+	return append(checks, perm)
+}
+
+// offlineStoreCheck verifies the store directly while no node holds it.
+func offlineStoreCheck(dir string) doctorCheck {
+	c := doctorCheck{Name: "store"}
+	if _, err := os.Stat(filepath.Join(dir, "MANIFEST")); err != nil {
+		c.Status, c.Detail = "skip", "no store in "+dir
+		return c
+	}
+	s, err := store.Open(dir)
+	if err != nil {
+		c.Status, c.Detail = "fail", err.Error()
+		c.Fix = "if a node is running, point -api at it; otherwise check the directory's permissions"
+		return c
+	}
+	defer s.Close()
+	report, err := s.CheckIntegrity(0)
+	if err != nil {
+		c.Status, c.Detail = "fail", err.Error()
+		return c
+	}
+	c.Detail = fmt.Sprintf("%d records, %d content, %d manifests, %d file records checked",
+		report.Records, report.Content, report.Manifests, report.FileRecords)
+	if report.ProblemCount == 0 {
+		c.Status = "ok"
+		return c
+	}
+	c.Status = "fail"
+	c.Detail += fmt.Sprintf("; %d problems: %v", report.ProblemCount, report.Problems)
+	c.Fix = "back up the data directory, then republish the affected sites or fetch them again from peers"
+	return c
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestDataDirChecks(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Chmod(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	status := func(checks []doctorCheck, name string) string {
+		for _, c := range checks {
+			if c.Name == name {
+				return c.Status
+			}
+		}
+		return ""
+	}
+
+	if got := status(dataDirChecks(filepath.Join(dir, "missing")), "data-dir"); got != "warn" {
+		t.Errorf("missing directory: status %q, want warn", got)
+	}
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if got := status(dataDirChecks(file), "data-dir"); got != "fail" {
+		t.Errorf("file instead of directory: status %q, want fail", got)
+	}
+	if got := status(dataDirChecks(dir), "data-dir"); got != "ok" {
+		t.Errorf("writable directory: status %q, want ok", got)
+	}
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+	token := filepath.Join(dir, adminTokenFile)
+	if err := os.WriteFile(token, []byte("secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if got := status(dataDirChecks(dir), "secrets"); got != "ok" {
+		t.Errorf("private token: status %q, want ok", got)
+	}
+	if err := os.Chmod(token, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := status(dataDirChecks(dir), "secrets"); got != "warn" {
+		t.Errorf("world-readable token: status %q, want warn", got)
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"alxnet/internal/config"
+	"alxnet/internal/store"
+	"alxnet/internal/webserver"
+
+	"go.uber.org/zap"
+)
+
+// replicationStateFile holds, in a follower's data directory, the leader
+// version its store has caught up to.
+const replicationStateFile = "replication.next"
+
+// follower pulls a leader node's store changes into its own store.
+type follower struct {
+	leader string
+	token  string
+	db     *store.Store
+	state  string // path of the replicationStateFile
+	next   uint64 // leader version to pull changes after
+	client *http.Client
+	logger *zap.Logger
+}
+
+// newFollower resumes following from the data directory's state, or starts
+// a new follower on an empty store.
+func newFollower(db *store.Store, dataDir string, cfg config.ReplicationConfig, logger *zap.Logger) (*follower, error) {
+	f := &follower{
+		leader: strings.TrimRight(cfg.Leader, "/"),
+		token:  cfg.Token,
+		db:     db,
+		state:  filepath.Join(dataDir, replicationStateFile),
+		client: &http.Client{}, // a first pull copies the whole store, so no timeout
+		logger: logger,
+	}
+	data, err := os.ReadFile(f.state)
+	switch {
+	case err == nil:
+		if f.next, err = strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64); err != nil {
+			return nil, fmt.Errorf("corrupt %s", f.state)
+		}
+	case errors.Is(err, os.ErrNotExist):
+		// Loading into a store with writes of its own would mix up versions
+		empty, err := db.Empty()
+		if err != nil {
+			return nil, err
+		}
+		if !empty {
+			return nil, fmt.Errorf("%w: a new follower needs an empty data directory", store.ErrNotEmpty)
+		}
+	default:
+		return nil, err
+	}
+	return f, nil
+}
+
+// pull applies the leader's changes since the last pull.
+func (f *follower) pull(ctx context.Context) error {
+	url := fmt.Sprintf("%s/api/admin/replication?since=%d", f.leader, f.next)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+f.token)
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("leader answered %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if err := f.db.ApplyChanges(resp.Body); err != nil {
+		return err
+	}
+	// The trailer is only there once the whole stream has been read
+	next, err := strconv.ParseUint(resp.Trailer.Get(webserver.ReplicationNextHeader), 10, 64)
+	if err != nil {
+		return errors.New("change stream ended early")
+	}
+	if next == f.next {
+		return nil
+	}
+	tmp := f.state + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.FormatUint(next, 10)+"\n"), 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, f.state); err != nil {
+		return err
+	}
+	f.next = next
+	return nil
+}
+
+// run pulls every interval until ctx ends.
+func (f *follower) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	failing := false
+	for {
+		err := f.pull(ctx)
+		switch {
+		case ctx.Err() != nil:
+			return
+		case err != nil && !failing:
+			f.logger.Warn("replication from leader failing", zap.String("leader", f.leader), zap.Error(err))
+			failing = true
+		case err != nil:
+			f.logger.Debug("replication from leader failed", zap.Error(err))
+		case failing:
+			f.logger.Info("replication from leader resumed", zap.Uint64("version", f.next))
+			failing = false
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runFollower serves the browser interface read-only from db while keeping
+// it in step with the leader, until the process is signalled. Restarting
+// the node without a leader promotes it.
+func runFollower(db *store.Store, dataDir string, cfg config.ReplicationConfig, port int, uiCfg config.UIConfig, logger *zap.Logger) {
+	f, err := newFollower(db, dataDir, cfg, logger)
+	if err != nil {
+		log.Fatalf("Failed to start follower: %v", err)
+	}
+	ws := webserver.NewBrowserServer(db, nil, logger, port)
+	ws.SetReadOnly()
+	if err := ws.SetUI(uiCfg); err != nil {
+		log.Fatalf("Failed to load web interface assets: %v", err)
+	}
+	if err := ws.Start(); err != nil {
+		log.Fatalf("Failed to start browser server: %v", err)
+	}
+	defer func() {
+		if err := ws.Stop(); err != nil {
+			logger.Error("Failed to stop browser server", zap.Error(err))
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		f.run(ctx, cfg.Interval)
+		close(done)
+	}()
+	logger.Info("following leader", zap.String("leader", f.leader), zap.Uint64("version", f.next))
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	<-sigChan
+	cancel()
+	<-done // the store closes after the last pull stops
+}
+
+// promoteFollower drops the replication state of a former follower being
+// started as a leader, so following again later starts from scratch.
+func promoteFollower(dataDir string, logger *zap.Logger) {
+	state := filepath.Join(dataDir, replicationStateFile)
+	if _, err := os.Stat(state); err != nil {
+		return
+	}
+	if err := os.Remove(state); err != nil {
+		logger.Warn("failed to remove replication state", zap.String("file", state), zap.Error(err))
+		return
+	}
+	logger.Info("former follower promoted to leader", zap.String("dir", dataDir))
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"os/signal"
+	"runtime/debug"
+	"strings"
+	"syscall"
+	"time"
+
+	"alxnet/internal/config"
+	"alxnet/internal/core"
+	"alxnet/internal/logging"
+	"alxnet/internal/p2p"
+	"alxnet/internal/store"
+	"alxnet/internal/webserver"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"go.uber.org/zap"
+)
+
+// Gateway limits and defaults. A gateway keeps a small store for what
+// gossip brings it and the blobs it fetched in memory.
+const (
+	gatewayMinCache     = 1 << 20
+	gatewayMaxCache     = 1 << 30
+	gatewayMinMemory    = 32 << 20
+	gatewayContentCache = 8 << 20 // the store's own cache of local content
+	gatewayMaxPeers     = 1000
+	gatewayMaxTTL       = 24 * time.Hour
+	gatewayWorkers      = 2 // gossip validation workers
+)
+
+func gatewayUsage() {
+	fmt.Println("AlxNet Gateway - serve sites from peers, read-only")
+	fmt.Println("")
+	fmt.Println("Usage: alxnet gateway [options]")
+	fmt.Println("")
+	fmt.Println("Runs the browser interface and name resolver against remote peers,")
+	fmt.Println("without the wallet or node management interfaces, tuned for a small")
+	fmt.Println("VPS or a phone. Only GET and HEAD requests are answered.")
+	fmt.Println("")
+	fmt.Println("Options:")
+	fmt.Println("  -data ./gateway-data    Data directory (default: ./gateway-data)")
+	fmt.Println("  -port 8080              HTTP port")
+	fmt.Println("  -node-port 0            P2P node port (default: auto)")
+	fmt.Println("  -listen A,B             P2P listen multiaddrs (default: IPv4 and IPv6 at -node-port)")
+	fmt.Println("  -interfaces eth0        Listen on these network interfaces only (default: all)")
+	fmt.Println("  -bootstrap A,B          Comma-separated bootstrap multiaddrs")
+	fmt.Println("  -config alxnet.yaml     Configuration file for logging and bootstrap peers")
+	fmt.Println("  -swarm-key swarm.key    Join the private network of this key (default: swarm.key in -data if present)")
+	fmt.Println("  -cache 64m              Memory for blobs fetched from peers (k/m/g suffixes)")
+	fmt.Println("  -manifest-ttl 5m        How long a site's manifest from peers is reused")
+	fmt.Println("  -name-ttl 15m           How long a site name resolved by peers is reused")
+	fmt.Println("  -max-age 1m             Cache-Control max-age of site files (0 = none)")
+	fmt.Println("  -max-peers 16           Connected peers at most")
+	fmt.Println("  -memory-limit 0         Soft limit for the Go heap, such as 256m (0 = none)")
+}
+
+// gatewayConfig is the parsed command line of alxnet gateway.
+type gatewayConfig struct {
+	dataDir     string
+	port        int
+	nodePort    int
+	listen      []string
+	interfaces  []string
+	bootstrap   []string
+	configPath  string
+	swarmKey    string
+	web         webserver.GatewayOptions
+	maxPeers    int
+	memoryLimit int64
+}
+
+// parseGatewayFlags parses and checks the arguments of alxnet gateway.
+func parseGatewayFlags(args []string) (*gatewayConfig, error) {
+	fs := flag.NewFlagSet("gateway", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	dataDir := fs.String("data", "./gateway-data", "data directory")
+	port := fs.Int("port", 8080, "HTTP port")
+	nodePort := fs.Int("node-port", 0, "P2P node port (0 = auto)")
+	listen := fs.String("listen", "", "comma-separated P2P listen multiaddrs")
+	interfaces := fs.String("interfaces", "", "comma-separated network interfaces to listen on")
+	bootstrap := fs.String("bootstrap", "", "comma-separated bootstrap multiaddrs")
+	configPath := fs.String("config", "", "YAML configuration file")
+	swarmKey := fs.String("swarm-key", "", "private network key file")
+	cache := fs.String("cache", "64m", "memory for blobs fetched from peers")
+	manifestTTL := fs.Duration("manifest-ttl", 5*time.Minute, "how long a manifest from peers is reused")
+	nameTTL := fs.Duration("name-ttl", 15*time.Minute, "how long a name resolved by peers is reused")
+	maxAge := fs.Duration("max-age", time.Minute, "Cache-Control max-age of site files")
+	maxPeers := fs.Int("max-peers", 16, "connected peers at most")
+	memoryLimit := fs.String("memory-limit", "0", "soft limit for the Go heap")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+
+	c := &gatewayConfig{dataDir: *dataDir, port: *port, nodePort: *nodePort, configPath: *configPath, swarmKey: *swarmKey, maxPeers: *maxPeers,
+		listen: splitList(*listen), interfaces: splitList(*interfaces)}
+	if c.port < 1 || c.port > 65535 {
+		return nil, fmt.Errorf("invalid -port %d", c.port)
+	}
+	if c.nodePort < 0 || c.nodePort > 65535 {
+		return nil, fmt.Errorf("invalid -node-port %d", c.nodePort)
+	}
+	if c.maxPeers < 1 || c.maxPeers > gatewayMaxPeers {
+		return nil, fmt.Errorf("-max-peers must be 1 to %d", gatewayMaxPeers)
+	}
+	for _, addr := range c.listen {
+		if _, err := ma.NewMultiaddr(addr); err != nil {
+			return nil, fmt.Errorf("invalid -listen address %q", addr)
+		}
+	}
+	c.bootstrap = splitList(*bootstrap)
+	cacheBytes, err := parseSize(*cache, gatewayMinCache, gatewayMaxCache)
+	if err != nil {
+		return nil, fmt.Errorf("-cache: %w", err)
+	}
+	if *memoryLimit != "0" {
+		limit, err := parseSize(*memoryLimit, gatewayMinMemory, math.MaxInt)
+		if err != nil {
+			return nil, fmt.Errorf("-memory-limit: %w", err)
+		}
+		c.memoryLimit = int64(limit)
+	}
+	for _, d := range []struct {
+		name   string
+		value  time.Duration
+		lowest time.Duration
+	}{{"-manifest-ttl", *manifestTTL, time.Second}, {"-name-ttl", *nameTTL, time.Second}, {"-max-age", *maxAge, 0}} {
+		if d.value < d.lowest || d.value > gatewayMaxTTL {
+			return nil, fmt.Errorf("%s must be between %v and %v", d.name, d.lowest, gatewayMaxTTL)
+		}
+	}
+	c.web = webserver.GatewayOptions{
+		AssetCacheBytes: cacheBytes,
+		ManifestTTL:     *manifestTTL,
+		NameTTL:         *nameTTL,
+		MaxAge:          *maxAge,
+	}
+	return c, nil
+}
+
+// parseSize parses a single size such as 64m within [lo, hi].
+func parseSize(s string, lo, hi int) (int, error) {
+	if strings.Contains(s, ",") {
+		return 0, fmt.Errorf("invalid value %q: one size expected", s)
+	}
+	sizes, err := parseSizeList(s, lo, hi)
+	if err != nil {
+		return 0, err
+	}
+	return sizes[0], nil
+}
+
+func cmdGateway(args []string) {
+	c, err := parseGatewayFlags(args)
+	if errors.Is(err, flag.ErrHelp) {
+		gatewayUsage()
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "alxnet gateway: %v\n\n", err)
+		gatewayUsage()
+		os.Exit(2)
+	}
+	if c.memoryLimit > 0 {
+		debug.SetMemoryLimit(c.memoryLimit)
+	}
+
+	cfg, err := config.Load(c.configPath)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	logLevel, err := zap.ParseAtomicLevel(cfg.LogLevel)
+	if err != nil {
+		log.Fatalf("Invalid log level: %v", err)
+	}
+	if err := cfg.Logging.Validate(); err != nil {
+		log.Fatalf("Invalid logging options: %v", err)
+	}
+	logger, logFiles, err := logging.New(cfg.Logging, logLevel)
+	if err != nil {
+		log.Fatalf("Failed to create logger: %v", err)
+	}
+	defer func() {
+		_ = logger.Sync()
+		_ = logFiles.Close()
+	}()
+	defer zap.RedirectStdLog(logger.Named("stdlog"))()
+	core.SetMaxClockSkew(cfg.Security.MaxClockSkew)
+
+	if err := os.MkdirAll(c.dataDir, 0755); err != nil {
+		log.Fatalf("Failed to create data directory: %v", err)
+	}
+	// Gateways run small unless configured otherwise
+	dbOpts := cfg.Storage.Badger.StoreOptions()
+	if dbOpts.Profile == store.ProfileDefault {
+		dbOpts.Profile = store.ProfileGateway
+	}
+	db, err := store.OpenWithOptions(c.dataDir, dbOpts)
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	db.SetContentCacheSize(gatewayContentCache)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	bootstrapPeers := append(append([]string{}, c.bootstrap...), cfg.Network.BootstrapPeers...)
+	if len(bootstrapPeers) == 0 {
+		logger.Warn("no bootstrap peers; the gateway only finds peers on the local network")
+	}
+	nodeCfg := p2p.DefaultNodeConfig()
+	nodeCfg.MaxPeers = c.maxPeers
+	nodeCfg.ValidationWorkers = gatewayWorkers
+	nodeCfg.MaxRequestsPerWindow = cfg.Security.RateLimit
+	nodeCfg.EnableRateLimiting = cfg.Security.EnableRateLimiting
+	nodeCfg.Logger = logger.Named("p2p")
+	nodeCfg.FetchSlots = cfg.Network.Fetch.Slots
+	nodeCfg.FetchShares = fetchShares(cfg.Network.Fetch)
+	nodeCfg.ActivationTimes = cfg.Network.ActivationTimes()
+	applyProxyConfig(nodeCfg, cfg.Network, c.dataDir)
+	if c.swarmKey == "" {
+		c.swarmKey = cfg.Network.SwarmKey
+	}
+	if nodeCfg.PrivateNetworkKey, err = loadNodeSwarmKey(c.swarmKey, c.dataDir); err != nil {
+		log.Fatalf("Failed to load private network key: %v", err)
+	}
+	listenAddrs := nodeListenAddrs(c.listen, cfg.Network, c.nodePort)
+	nodeCfg.ListenAddrs = listenAddrs[1:]
+	nodeCfg.Interfaces = cfg.Network.Interfaces
+	if len(c.interfaces) > 0 {
+		nodeCfg.Interfaces = c.interfaces
+	}
+	node, err := p2p.New(ctx, db, listenAddrs[0], bootstrapPeers, nodeCfg)
+	if err != nil {
+		log.Fatalf("Failed to create P2P node: %v", err)
+	}
+	if err := node.Start(ctx); err != nil {
+		log.Fatalf("Failed to start P2P node: %v", err)
+	}
+	defer node.Host.Close()
+
+	gateway := webserver.NewBrowserServer(db, node, logger.Named("browser"), c.port)
+	if err := gateway.SetUI(cfg.UI); err != nil {
+		log.Fatalf("Failed to load web interface assets: %v", err)
+	}
+	gateway.SetGateway(c.web)
+	gateway.SetAPILimits(cfg.Security.APILimits())
+	gateway.SetAllowedHosts(cfg.Security.AllowedHosts)
+	if err := gateway.Start(); err != nil {
+		log.Fatalf("Failed to start gateway: %v", err)
+	}
+	defer func() {
+		if err := gateway.Stop(); err != nil {
+			logger.Error("Failed to stop gateway", zap.Error(err))
+		}
+	}()
+
+	logger.Info("gateway started",
+		zap.Int("port", c.port),
+		zap.String("id", node.Host.ID().String()),
+		zap.Int("bootstrap_peers", len(bootstrapPeers)),
+		zap.Int("cache_bytes", c.web.AssetCacheBytes),
+		zap.Int64("memory_limit", c.memoryLimit))
+	fmt.Printf("AlxNet gateway serving http://localhost:%d (read-only), press Ctrl+C to stop\n", c.port)
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	<-sigChan
+	logger.Info("shutting down gateway")
+}
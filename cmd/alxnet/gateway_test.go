@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseGatewayFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+		check   func(*gatewayConfig) bool
+	}{
+		{"defaults", nil, "", func(c *gatewayConfig) bool {
+			return c.port == 8080 && c.web.AssetCacheBytes == 64<<20 && c.web.ManifestTTL == 5*time.Minute &&
+				c.web.MaxAge == time.Minute && c.maxPeers == 16 && c.memoryLimit == 0
+		}},
+		{"tuned", []string{"-cache", "1g", "-memory-limit", "256m", "-max-age", "0", "-bootstrap", "/ip4/10.0.0.1/tcp/4001, ,/ip4/10.0.0.2/tcp/4001"}, "", func(c *gatewayConfig) bool {
+			return c.web.AssetCacheBytes == 1<<30 && c.memoryLimit == 256<<20 && c.web.MaxAge == 0 && len(c.bootstrap) == 2
+		}},
+		{"listen", []string{"-listen", "/ip6/::/tcp/4001,/ip4/0.0.0.0/tcp/4001", "-interfaces", "eth0"}, "", func(c *gatewayConfig) bool {
+			return len(c.listen) == 2 && c.listen[0] == "/ip6/::/tcp/4001" && len(c.interfaces) == 1
+		}},
+		{"listen not a multiaddr", []string{"-listen", "[::]:4001"}, "-listen", nil},
+		{"cache too small", []string{"-cache", "512k"}, "-cache", nil},
+		{"cache too large", []string{"-cache", "2g"}, "-cache", nil},
+		{"cache list", []string{"-cache", "8m,16m"}, "one size", nil},
+		{"memory limit too small", []string{"-memory-limit", "1m"}, "-memory-limit", nil},
+		{"zero manifest ttl", []string{"-manifest-ttl", "0"}, "-manifest-ttl", nil},
+		{"negative max-age", []string{"-max-age", "-1s"}, "-max-age", nil},
+		{"name ttl too long", []string{"-name-ttl", "48h"}, "-name-ttl", nil},
+		{"bad port", []string{"-port", "70000"}, "-port", nil},
+		{"no peers", []string{"-max-peers", "0"}, "-max-peers", nil},
+		{"extra argument", []string{"serve"}, "unexpected", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := parseGatewayFlags(tt.args)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseGatewayFlags(%q) error = %v, want %q", tt.args, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseGatewayFlags(%q): %v", tt.args, err)
+			}
+			if !tt.check(c) {
+				t.Errorf("parseGatewayFlags(%q) = %+v", tt.args, c)
+			}
+		})
+	}
+}
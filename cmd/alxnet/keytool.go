@@ -0,0 +1,555 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"alxnet/internal/core"
+	bncrypto "alxnet/internal/crypto"
+	"alxnet/internal/p2p"
+	"alxnet/internal/release"
+	"alxnet/internal/wallet"
+)
+
+func keytoolUsage() {
+	fmt.Println("AlxNet Keytool - offline key inspection and signing")
+	fmt.Println("")
+	fmt.Println("Usage: alxnet keytool <command> [options]")
+	fmt.Println("")
+	fmt.Println("Commands:")
+	fmt.Println("  derive         Derive a site key from a mnemonic and label")
+	fmt.Println("  siteid         Print the siteID for a site public key")
+	fmt.Println("  sign           Sign a file with a site key")
+	fmt.Println("  verify         Verify a detached signature over a file")
+	fmt.Println("  verify-record  Verify the signatures of a CBOR UpdateRecord")
+	fmt.Println("  convert        Convert a key between hex, base64 and PEM")
+	fmt.Println("  vanity         Find a site label whose siteID starts with a hex prefix")
+	fmt.Println("  gateway-auth   Authorize a gateway host to mirror a site")
+	fmt.Println("  verify-gateway Verify a gateway authorization")
+	fmt.Println("  swarm-key      Create a private network key, or print a key's fingerprint")
+	fmt.Println("  release-sign   Sign a release manifest for `alxnet update`")
+	fmt.Println("")
+	fmt.Println("The mnemonic is read from -mnemonic, ALXNET_MNEMONIC, or stdin, and the")
+	fmt.Println("BIP-39 passphrase from -passphrase or ALXNET_MNEMONIC_PASSPHRASE.")
+	fmt.Println("")
+	fmt.Println("Examples:")
+	fmt.Println("  alxnet keytool derive -label mysite")
+	fmt.Println("  alxnet keytool sign -label mysite -in index.html")
+	fmt.Println("  alxnet keytool verify -pub <hex> -sig <hex> -in index.html")
+	fmt.Println("  alxnet keytool convert -key <hex> -from hex -to pem")
+	fmt.Println("  alxnet keytool vanity -prefix cafe -label shop")
+	fmt.Println("  alxnet keytool gateway-auth -label mysite -host mirror.example.org")
+	fmt.Println("  alxnet keytool verify-gateway -proof <proof> -host mirror.example.org -site <siteID>")
+	fmt.Println("  alxnet keytool swarm-key -out ./data/swarm.key")
+	fmt.Println("  alxnet keytool release-sign -key <hex> -in release.json > release.signed.json")
+}
+
+func cmdKeytool(args []string) {
+	if len(args) < 1 {
+		keytoolUsage()
+		return
+	}
+
+	var err error
+	switch args[0] {
+	case "derive":
+		err = keytoolDerive(args[1:])
+	case "siteid":
+		err = keytoolSiteID(args[1:])
+	case "sign":
+		err = keytoolSign(args[1:])
+	case "verify":
+		err = keytoolVerify(args[1:])
+	case "verify-record":
+		err = keytoolVerifyRecord(args[1:])
+	case "convert":
+		err = keytoolConvert(args[1:])
+	case "vanity":
+		err = keytoolVanity(args[1:])
+	case "gateway-auth":
+		err = keytoolGatewayAuth(args[1:])
+	case "verify-gateway":
+		err = keytoolVerifyGateway(args[1:])
+	case "swarm-key":
+		err = keytoolSwarmKey(args[1:])
+	case "release-sign":
+		err = keytoolReleaseSign(args[1:])
+	default:
+		keytoolUsage()
+		return
+	}
+	if err != nil {
+		log.Fatalf("keytool %s: %v", args[0], err)
+	}
+}
+
+func keytoolDerive(args []string) error {
+	fs := flag.NewFlagSet("keytool derive", flag.ExitOnError)
+	mnemonic := fs.String("mnemonic", "", "BIP-39 mnemonic phrase")
+	passphrase := fs.String("passphrase", os.Getenv("ALXNET_MNEMONIC_PASSPHRASE"), "optional BIP-39 passphrase (25th word)")
+	label := fs.String("label", "", "site label")
+	format := fs.String("format", bncrypto.FormatHex, "output format: hex, base64 or pem")
+	showPriv := fs.Bool("private", false, "also print the private key")
+	_ = fs.Parse(args)
+
+	pub, priv, err := deriveSiteKeyFromFlags(*mnemonic, *passphrase, *label)
+	if err != nil {
+		return err
+	}
+
+	pubStr, err := bncrypto.EncodePublicKey(pub, *format)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("label:      %s\n", *label)
+	fmt.Printf("site_id:    %s\n", core.SiteIDFromPub(pub))
+	fmt.Printf("public_key: %s\n", strings.TrimSpace(pubStr))
+	if *showPriv {
+		privStr, err := bncrypto.EncodePrivateKey(priv, *format)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("private_key: %s\n", strings.TrimSpace(privStr))
+	}
+	return nil
+}
+
+func keytoolSiteID(args []string) error {
+	fs := flag.NewFlagSet("keytool siteid", flag.ExitOnError)
+	pubStr := fs.String("pub", "", "site public key")
+	format := fs.String("format", bncrypto.FormatHex, "public key format: hex, base64 or pem")
+	_ = fs.Parse(args)
+
+	pub, err := bncrypto.ParsePublicKey(*pubStr, *format)
+	if err != nil {
+		return err
+	}
+	fmt.Println(core.SiteIDFromPub(pub))
+	return nil
+}
+
+func keytoolSign(args []string) error {
+	fs := flag.NewFlagSet("keytool sign", flag.ExitOnError)
+	mnemonic := fs.String("mnemonic", "", "BIP-39 mnemonic phrase")
+	passphrase := fs.String("passphrase", os.Getenv("ALXNET_MNEMONIC_PASSPHRASE"), "optional BIP-39 passphrase (25th word)")
+	label := fs.String("label", "", "site label")
+	keyStr := fs.String("key", "", "private key to sign with instead of mnemonic+label")
+	keyFormat := fs.String("keyformat", bncrypto.FormatHex, "private key format: hex, base64 or pem")
+	in := fs.String("in", "-", "file to sign (- for stdin)")
+	format := fs.String("format", bncrypto.FormatHex, "signature output format: hex or base64")
+	_ = fs.Parse(args)
+
+	var priv ed25519.PrivateKey
+	var err error
+	if *keyStr != "" {
+		priv, err = bncrypto.ParsePrivateKey(*keyStr, *keyFormat)
+	} else {
+		_, priv, err = deriveSiteKeyFromFlags(*mnemonic, *passphrase, *label)
+	}
+	if err != nil {
+		return err
+	}
+
+	data, err := readInput(*in)
+	if err != nil {
+		return err
+	}
+	sig := ed25519.Sign(priv, data)
+	out, err := encodeBytes(sig, *format)
+	if err != nil {
+		return err
+	}
+	fmt.Println(out)
+	return nil
+}
+
+func keytoolVerify(args []string) error {
+	fs := flag.NewFlagSet("keytool verify", flag.ExitOnError)
+	pubStr := fs.String("pub", "", "public key")
+	format := fs.String("format", bncrypto.FormatHex, "public key format: hex, base64 or pem")
+	sigStr := fs.String("sig", "", "signature")
+	sigFormat := fs.String("sigformat", bncrypto.FormatHex, "signature format: hex or base64")
+	in := fs.String("in", "-", "signed file (- for stdin)")
+	_ = fs.Parse(args)
+
+	pub, err := bncrypto.ParsePublicKey(*pubStr, *format)
+	if err != nil {
+		return err
+	}
+	sig, err := bncrypto.DecodeBytes(*sigStr, *sigFormat)
+	if err != nil {
+		return fmt.Errorf("invalid signature: %w", err)
+	}
+	if len(sig) != ed25519.SignatureSize {
+		return fmt.Errorf("invalid signature length: %d (expected %d)", len(sig), ed25519.SignatureSize)
+	}
+	data, err := readInput(*in)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(pub, data, sig) {
+		return errors.New("signature INVALID")
+	}
+	fmt.Println("signature OK")
+	return nil
+}
+
+func keytoolVerifyRecord(args []string) error {
+	fs := flag.NewFlagSet("keytool verify-record", flag.ExitOnError)
+	in := fs.String("in", "-", "CBOR encoded UpdateRecord (- for stdin)")
+	_ = fs.Parse(args)
+
+	data, err := readInput(*in)
+	if err != nil {
+		return err
+	}
+	var rec core.UpdateRecord
+	if err := core.UnmarshalCBOR(data, &rec); err != nil {
+		return fmt.Errorf("failed to decode record: %w", err)
+	}
+
+	fmt.Printf("version:     %s\n", rec.Version)
+	fmt.Printf("site_id:     %s\n", core.SiteIDFromPub(rec.SitePub))
+	fmt.Printf("seq:         %d\n", rec.Seq)
+	fmt.Printf("prev_cid:    %s\n", rec.PrevCID)
+	fmt.Printf("content_cid: %s\n", rec.ContentCID)
+
+	// Nodes store records under the CID of their canonical encoding, which
+	// may differ from the input bytes.
+	canonical, err := core.CanonicalMarshal(&rec)
+	if err != nil {
+		return fmt.Errorf("failed to re-encode record: %w", err)
+	}
+	fmt.Printf("record_cid:  %s\n", core.CIDForBytes(canonical))
+
+	if err := rec.CheckFormat(); err != nil {
+		fmt.Printf("format:      INVALID (%v)\n", err)
+	} else {
+		fmt.Println("format:      OK")
+	}
+	structOK := true
+	if err := rec.Validate(); err != nil {
+		structOK = false
+		fmt.Printf("structure:   INVALID (%v)\n", err)
+	} else {
+		fmt.Println("structure:   OK")
+	}
+
+	linkOK := false
+	if len(rec.SitePub) == ed25519.PublicKeySize {
+		linkPre := bncrypto.PreimageLink(rec.SitePub, rec.UpdatePub, rec.Seq, rec.PrevCID, rec.ContentCID, rec.TS)
+		linkOK = ed25519.Verify(ed25519.PublicKey(rec.SitePub), linkPre, rec.LinkSig)
+	}
+	fmt.Printf("link_sig:    %s\n", okString(linkOK))
+
+	updateOK := false
+	if noUS, err := core.CanonicalMarshalNoUpdateSig(&rec); err == nil && len(rec.UpdatePub) == ed25519.PublicKeySize {
+		updateOK = ed25519.Verify(ed25519.PublicKey(rec.UpdatePub), bncrypto.PreimageUpdate(noUS), rec.UpdateSig)
+	}
+	fmt.Printf("update_sig:  %s\n", okString(updateOK))
+
+	if !linkOK || !updateOK {
+		return errors.New("record signature verification failed")
+	}
+	if !structOK {
+		return errors.New("record failed validation")
+	}
+	return nil
+}
+
+func keytoolConvert(args []string) error {
+	fs := flag.NewFlagSet("keytool convert", flag.ExitOnError)
+	keyStr := fs.String("key", "", "key to convert; use @file to read from a file")
+	from := fs.String("from", bncrypto.FormatHex, "source format: hex, base64 or pem")
+	to := fs.String("to", bncrypto.FormatHex, "target format: hex, base64 or pem")
+	private := fs.Bool("private", false, "treat the input as a private key")
+	_ = fs.Parse(args)
+
+	input := *keyStr
+	if strings.HasPrefix(input, "@") {
+		b, err := os.ReadFile(input[1:])
+		if err != nil {
+			return err
+		}
+		input = string(b)
+	}
+
+	var out string
+	var err error
+	if *private {
+		var priv ed25519.PrivateKey
+		if priv, err = bncrypto.ParsePrivateKey(input, *from); err != nil {
+			return err
+		}
+		out, err = bncrypto.EncodePrivateKey(priv, *to)
+	} else {
+		var pub ed25519.PublicKey
+		if pub, err = bncrypto.ParsePublicKey(input, *from); err != nil {
+			return err
+		}
+		out, err = bncrypto.EncodePublicKey(pub, *to)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Println(strings.TrimSpace(out))
+	return nil
+}
+
+// maxVanityThreads bounds -threads of keytool vanity.
+const maxVanityThreads = 256
+
+func keytoolVanity(args []string) error {
+	fs := flag.NewFlagSet("keytool vanity", flag.ExitOnError)
+	mnemonic := fs.String("mnemonic", "", "BIP-39 mnemonic phrase")
+	passphrase := fs.String("passphrase", os.Getenv("ALXNET_MNEMONIC_PASSPHRASE"), "optional BIP-39 passphrase (25th word)")
+	prefix := fs.String("prefix", "", "hex prefix the siteID should start with")
+	label := fs.String("label", "site", "label base; labels tried are <label>-0, <label>-1, ...")
+	start := fs.Uint64("start", 0, "first index to try, to resume an earlier search")
+	threads := fs.Int("threads", runtime.NumCPU(), "worker goroutines")
+	interval := fs.Duration("progress", 5*time.Second, "progress report interval (0 disables)")
+	_ = fs.Parse(args)
+
+	p := strings.ToLower(*prefix)
+	if err := wallet.ValidVanityPrefix(p); err != nil {
+		return err
+	}
+	if *threads < 1 || *threads > maxVanityThreads {
+		return fmt.Errorf("-threads must be between 1 and %d", maxVanityThreads)
+	}
+	phrase, err := readMnemonic(*mnemonic)
+	if err != nil {
+		return err
+	}
+	master, err := wallet.MasterKeyFromMnemonic(phrase, *passphrase)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	expected := wallet.VanityAttempts(len(p))
+	fmt.Fprintf(os.Stderr, "searching for siteID prefix %q with %d threads, about %d labels expected\n", p, *threads, expected)
+
+	var tried atomic.Uint64
+	began := time.Now()
+	if *interval > 0 {
+		go func() {
+			ticker := time.NewTicker(*interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					n := tried.Load()
+					rate := float64(n) / time.Since(began).Seconds()
+					fmt.Fprintf(os.Stderr, "tried %d labels (%.0f/s, %.1f%% of expected), next start %d\n",
+						n, rate, 100*float64(n)/float64(expected), *start+n)
+				}
+			}
+		}()
+	}
+
+	match, err := wallet.FindVanityLabel(ctx, master, *label, p, *start, *threads, &tried)
+	if err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("stopped after %d labels; resume with -start %d", tried.Load(), *start+tried.Load())
+		}
+		return err
+	}
+	fmt.Printf("label:   %s\n", match.Label)
+	fmt.Printf("site_id: %s\n", match.SiteID)
+	fmt.Printf("tried:   %d labels in %s\n", tried.Load(), time.Since(began).Round(time.Second))
+	fmt.Println("Add a site with this label to the wallet to use its key.")
+	return nil
+}
+
+func keytoolGatewayAuth(args []string) error {
+	fs := flag.NewFlagSet("keytool gateway-auth", flag.ExitOnError)
+	mnemonic := fs.String("mnemonic", "", "BIP-39 mnemonic phrase")
+	passphrase := fs.String("passphrase", os.Getenv("ALXNET_MNEMONIC_PASSPHRASE"), "optional BIP-39 passphrase (25th word)")
+	label := fs.String("label", "", "site label")
+	host := fs.String("host", "", "gateway host name, such as mirror.example.org")
+	days := fs.Int("days", 90, "days the authorization is valid")
+	_ = fs.Parse(args)
+
+	maxDays := int(core.MaxGatewayAuthDuration / (24 * time.Hour))
+	if *days < 1 || *days > maxDays {
+		return fmt.Errorf("-days must be between 1 and %d", maxDays)
+	}
+	h := strings.TrimSuffix(strings.ToLower(*host), ".")
+	if !core.ValidGatewayHost(h) {
+		return fmt.Errorf("invalid -host %q: use the gateway's DNS name without a port", *host)
+	}
+	_, priv, err := deriveSiteKeyFromFlags(*mnemonic, *passphrase, *label)
+	if err != nil {
+		return err
+	}
+	auth, err := p2p.BuildGatewayAuth(priv, h, time.Now().Add(time.Duration(*days)*24*time.Hour))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("site_id: %s\n", core.SiteIDFromPub(auth.SitePub))
+	fmt.Printf("host:    %s\n", auth.Host)
+	fmt.Printf("expires: %s\n", time.Unix(auth.Expires, 0).Format(time.RFC3339))
+	fmt.Printf("proof:   %s\n", p2p.EncodeGatewayAuth(auth))
+	fmt.Println("Give the proof to the gateway's operator to install with `alxnet admin gateway-add`.")
+	return nil
+}
+
+func keytoolVerifyGateway(args []string) error {
+	fs := flag.NewFlagSet("keytool verify-gateway", flag.ExitOnError)
+	proof := fs.String("proof", "", "gateway authorization, as sent in the X-AlxNet-Gateway-Auth header")
+	host := fs.String("host", "", "host the proof must name (optional)")
+	site := fs.String("site", "", "siteID the proof must be signed by (optional)")
+	_ = fs.Parse(args)
+
+	if *proof == "" {
+		return errors.New("-proof is required")
+	}
+	auth, err := p2p.DecodeGatewayAuth(strings.TrimSpace(*proof))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("site_id: %s\n", core.SiteIDFromPub(auth.SitePub))
+	fmt.Printf("host:    %s\n", auth.Host)
+	fmt.Printf("expires: %s\n", time.Unix(auth.Expires, 0).Format(time.RFC3339))
+	if err := p2p.VerifyGatewayAuth(auth, time.Now()); err != nil {
+		return err
+	}
+	if *host != "" && strings.TrimSuffix(strings.ToLower(*host), ".") != auth.Host {
+		return fmt.Errorf("proof is for %s, not %s", auth.Host, *host)
+	}
+	if *site != "" && !strings.EqualFold(*site, core.SiteIDFromPub(auth.SitePub)) {
+		return fmt.Errorf("proof is signed by site %s, not %s", core.SiteIDFromPub(auth.SitePub), *site)
+	}
+	fmt.Println("authorization OK")
+	return nil
+}
+
+// deriveSiteKeyFromFlags derives the key of a site label, reading the
+// mnemonic as readMnemonic does when the flag is empty.
+func deriveSiteKeyFromFlags(mnemonic, passphrase, label string) (ed25519.PublicKey, ed25519.PrivateKey, error) {
+	if label == "" {
+		return nil, nil, errors.New("-label is required")
+	}
+	phrase, err := readMnemonic(mnemonic)
+	if err != nil {
+		return nil, nil, err
+	}
+	master, err := wallet.MasterKeyFromMnemonic(phrase, passphrase)
+	if err != nil {
+		return nil, nil, err
+	}
+	return wallet.DeriveSiteKey(master, label)
+}
+
+// keytoolReleaseSign signs a release manifest with the release publisher's
+// key. Builds check it against the key set in release.PublisherKey.
+func keytoolReleaseSign(args []string) error {
+	fs := flag.NewFlagSet("keytool release-sign", flag.ExitOnError)
+	keyStr := fs.String("key", "", "release publisher private key")
+	keyFormat := fs.String("keyformat", bncrypto.FormatHex, "private key format: hex, base64 or pem")
+	in := fs.String("in", "-", "release manifest JSON (- for stdin)")
+	_ = fs.Parse(args)
+
+	priv, err := bncrypto.ParsePrivateKey(*keyStr, *keyFormat)
+	if err != nil {
+		return err
+	}
+	data, err := readInput(*in)
+	if err != nil {
+		return err
+	}
+	var m release.Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return fmt.Errorf("invalid release manifest: %w", err)
+	}
+	signed, err := release.Sign(&m, priv)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(signed))
+	fmt.Fprintf(os.Stderr, "Signed release %s with publisher key %s\n", m.Version, hex.EncodeToString(priv.Public().(ed25519.PublicKey)))
+	return nil
+}
+
+func readInput(path string) ([]byte, error) {
+	if path == "-" || path == "" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
+
+func encodeBytes(b []byte, format string) (string, error) {
+	switch strings.ToLower(format) {
+	case bncrypto.FormatHex, "":
+		return hex.EncodeToString(b), nil
+	case bncrypto.FormatBase64:
+		return base64.StdEncoding.EncodeToString(b), nil
+	default:
+		return "", fmt.Errorf("unsupported format: %s", format)
+	}
+}
+
+func okString(ok bool) string {
+	if ok {
+		return "OK"
+	}
+	return "INVALID"
+}
+
+func keytoolSwarmKey(args []string) error {
+	fs := flag.NewFlagSet("keytool swarm-key", flag.ExitOnError)
+	out := fs.String("out", "", "write a new key to this file, which must not exist")
+	in := fs.String("in", "", "print the fingerprint of this key file instead")
+	_ = fs.Parse(args)
+
+	switch {
+	case *in != "" && *out != "":
+		return errors.New("use -out or -in, not both")
+	case *in != "":
+		key, err := p2p.LoadSwarmKey(*in)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("fingerprint: %s\n", p2p.SwarmKeyFingerprint(key))
+		return nil
+	case *out == "":
+		return errors.New("-out is required")
+	}
+	key, err := p2p.GenerateSwarmKey()
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(p2p.EncodeSwarmKey(key)); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("fingerprint: %s\n", p2p.SwarmKeyFingerprint(key))
+	fmt.Printf("Copy %s to every node of the network, as swarm.key in its data directory or with -swarm-key.\n", *out)
+	return nil
+}
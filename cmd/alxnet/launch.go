@@ -0,0 +1,437 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"syscall"
+	"time"
+
+	"alxnet/internal/logging"
+)
+
+// launcherStopTimeout is how long the node gets to shut down after SIGTERM
+// before it is killed.
+const launcherStopTimeout = 15 * time.Second
+
+// launcher starts and stops a node as a child process and reports on it
+// through a small control page, so the platform can be run without a
+// terminal.
+type launcher struct {
+	exe     string
+	args    []string
+	logPath string
+	nodeAPI string
+	uis     map[string]string
+	token   string
+	http    *http.Client
+
+	mu      sync.Mutex
+	cmd     *exec.Cmd
+	done    chan struct{}
+	lastErr string
+}
+
+// launcherStatus is returned by the control page's /status endpoint.
+type launcherStatus struct {
+	Running bool              `json:"running"`
+	PID     int               `json:"pid,omitempty"`
+	Peers   int               `json:"peers"`
+	Sync    string            `json:"sync"`
+	Error   string            `json:"error,omitempty"`
+	UIs     map[string]string `json:"uis"`
+}
+
+func cmdLaunch(args []string) {
+	fs := flag.NewFlagSet("launch", flag.ExitOnError)
+	listen := fs.String("listen", "127.0.0.1:8079", "control page address (loopback only)")
+	dataDir := fs.String("data", "./data", "data directory")
+	nodePort := fs.String("node-port", "0", "P2P node port (0 = auto)")
+	browserPort := fs.String("browser-port", "8080", "Browser web interface port")
+	walletPort := fs.String("wallet-port", "8081", "Wallet management web interface port")
+	nodeUIPort := fs.String("node-ui-port", "8082", "Node management web interface port")
+	configPath := fs.String("config", "", "YAML configuration file passed to the node")
+	noBrowser := fs.Bool("no-browser", false, "do not open the control page")
+	noStart := fs.Bool("no-start", false, "wait for Start instead of starting the node")
+	_ = fs.Parse(args)
+
+	host, _, err := net.SplitHostPort(*listen)
+	if err != nil || !isLoopback(host) {
+		log.Fatalf("-listen must be a loopback address such as 127.0.0.1:8079")
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		log.Fatalf("Cannot locate the alxnet binary: %v", err)
+	}
+	if err := os.MkdirAll(*dataDir, 0755); err != nil {
+		log.Fatalf("Failed to create data directory: %v", err)
+	}
+
+	nodeArgs := []string{"start", "-data", *dataDir, "-node-port", *nodePort,
+		"-browser-port", *browserPort, "-wallet-port", *walletPort, "-node-ui-port", *nodeUIPort}
+	if *configPath != "" {
+		nodeArgs = append(nodeArgs, "-config", *configPath)
+	}
+	l, err := newLauncher(exe, nodeArgs, filepath.Join(*dataDir, "launcher.log"), map[string]string{
+		"browser": "http://localhost:" + *browserPort,
+		"wallet":  "http://localhost:" + *walletPort,
+		"node":    "http://localhost:" + *nodeUIPort,
+	})
+	if err != nil {
+		log.Fatalf("Failed to create launcher: %v", err)
+	}
+
+	ln, err := net.Listen("tcp", *listen)
+	if err != nil {
+		log.Fatalf("Failed to listen on %s: %v", *listen, err)
+	}
+	srv := &http.Server{Handler: l.handler(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Control page stopped: %v", err)
+		}
+	}()
+
+	if !*noStart {
+		if err := l.start(); err != nil {
+			log.Printf("Failed to start node: %v", err)
+		}
+	}
+	page := "http://" + ln.Addr().String()
+	fmt.Printf("AlxNet launcher running at %s (node output in %s)\n", page, l.logPath)
+	if !*noBrowser {
+		if err := openBrowser(page); err != nil {
+			fmt.Printf("Open %s in your web browser: %v\n", page, err)
+		}
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	<-sigChan
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_ = srv.Shutdown(ctx)
+	if err := l.stop(); err != nil {
+		log.Printf("Failed to stop node: %v", err)
+	}
+}
+
+func newLauncher(exe string, args []string, logPath string, uis map[string]string) (*launcher, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return &launcher{
+		exe:     exe,
+		args:    args,
+		logPath: logPath,
+		nodeAPI: uis["node"],
+		uis:     uis,
+		token:   hex.EncodeToString(b),
+		http:    &http.Client{Timeout: 3 * time.Second},
+	}, nil
+}
+
+// start runs the node unless it is already running. Its output goes to
+// the launcher log, rotated at 10 MiB.
+func (l *launcher) start() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.cmd != nil {
+		return nil
+	}
+	out, err := logging.OpenRotatingFile(l.logPath, 10<<20, 0, 3)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(l.exe, l.args...)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	if err := cmd.Start(); err != nil {
+		out.Close()
+		return err
+	}
+	done := make(chan struct{})
+	l.cmd, l.done, l.lastErr = cmd, done, ""
+	go func() {
+		err := cmd.Wait()
+		out.Close()
+		l.mu.Lock()
+		if l.cmd == cmd {
+			l.cmd = nil
+			if err != nil {
+				l.lastErr = fmt.Sprintf("node exited: %v (see %s)", err, l.logPath)
+			}
+		}
+		l.mu.Unlock()
+		close(done)
+	}()
+	return nil
+}
+
+// stop asks the node to shut down and kills it if it has not exited within
+// launcherStopTimeout. Platforms without SIGTERM kill it straight away.
+func (l *launcher) stop() error {
+	l.mu.Lock()
+	cmd, done := l.cmd, l.done
+	l.cmd = nil
+	l.mu.Unlock()
+	if cmd == nil {
+		return nil
+	}
+	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		if err := cmd.Process.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+			return err
+		}
+	}
+	select {
+	case <-done:
+		return nil
+	case <-time.After(launcherStopTimeout):
+	}
+	if err := cmd.Process.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return err
+	}
+	<-done
+	return nil
+}
+
+// status reports whether the node runs and, once its management interface
+// answers, its peer count and whether gossip validation is keeping up.
+func (l *launcher) status() launcherStatus {
+	l.mu.Lock()
+	st := launcherStatus{Running: l.cmd != nil, Error: l.lastErr, UIs: l.uis}
+	if l.cmd != nil {
+		st.PID = l.cmd.Process.Pid
+	}
+	l.mu.Unlock()
+	if !st.Running {
+		st.Sync = "stopped"
+		return st
+	}
+
+	var peers struct {
+		Count int `json:"count"`
+	}
+	var node struct {
+		Validation struct {
+			Queued int `json:"queued"`
+		} `json:"validation"`
+	}
+	if err := l.getJSON("/api/node/peers", &peers); err != nil {
+		st.Sync = "starting"
+		return st
+	}
+	if err := l.getJSON("/api/node/status", &node); err != nil {
+		st.Sync = "starting"
+		return st
+	}
+	st.Peers = peers.Count
+	st.Sync = syncState(peers.Count, node.Validation.Queued)
+	return st
+}
+
+// syncState summarises peer count and validation backlog for the page.
+func syncState(peers, queued int) string {
+	switch {
+	case peers == 0:
+		return "no peers"
+	case queued > 0:
+		return fmt.Sprintf("syncing (%d updates queued)", queued)
+	default:
+		return "up to date"
+	}
+}
+
+func (l *launcher) getJSON(path string, out interface{}) error {
+	resp, err := l.http.Get(l.nodeAPI + path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", path, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(out)
+}
+
+// handler serves the control page. Requests must name a loopback host, so
+// a rebound DNS name cannot reach it, and start/stop need the per-run
+// token from the page in a header, which other origins cannot send.
+func (l *launcher) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_ = launcherPage.Execute(w, l.token)
+	})
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		writeLauncherJSON(w, l.status())
+	})
+	action := func(fn func() error) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			if r.Header.Get("X-Launcher-Token") != l.token {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+			if err := fn(); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			writeLauncherJSON(w, l.status())
+		}
+	}
+	mux.HandleFunc("/start", action(l.start))
+	mux.HandleFunc("/stop", action(l.stop))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if !isLoopback(host) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func writeLauncherJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// isLoopback reports whether host is localhost or a loopback IP.
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// openBrowser opens url in the user's default browser.
+func openBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	case "darwin":
+		cmd = exec.Command("open", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
+
+var launcherPage = template.Must(template.New("launcher").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="UTF-8">
+<meta name="viewport" content="width=device-width, initial-scale=1.0">
+<title>AlxNet</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif; background: #f5f7fa; color: #2d3748; margin: 0; }
+.card { max-width: 420px; margin: 60px auto; background: #fff; border-radius: 12px; padding: 28px; box-shadow: 0 4px 16px rgba(0,0,0,.08); }
+h1 { margin: 0 0 20px; font-size: 1.5em; }
+.row { display: flex; justify-content: space-between; padding: 8px 0; border-bottom: 1px solid #edf2f7; }
+.dot { display: inline-block; width: 10px; height: 10px; border-radius: 50%; background: #a0aec0; margin-right: 6px; }
+.dot.on { background: #38a169; }
+button { width: 100%; padding: 12px; margin-top: 20px; border: 0; border-radius: 8px; font-size: 1em; color: #fff; background: #667eea; cursor: pointer; }
+button.stop { background: #e53e3e; }
+.links a { display: block; margin-top: 10px; color: #667eea; }
+.links.off a { pointer-events: none; color: #a0aec0; }
+#error { color: #e53e3e; font-size: .9em; margin-top: 12px; }
+</style>
+</head>
+<body>
+<div class="card">
+<h1>AlxNet</h1>
+<div class="row"><span>Node</span><span><span id="dot" class="dot"></span><span id="state">…</span></span></div>
+<div class="row"><span>Peers</span><span id="peers">-</span></div>
+<div class="row"><span>Sync</span><span id="sync">-</span></div>
+<button id="toggle">…</button>
+<div id="error"></div>
+<div id="links" class="links off">
+<a id="browser" target="_blank" rel="noopener">Browse sites</a>
+<a id="wallet" target="_blank" rel="noopener">Wallet</a>
+<a id="node" target="_blank" rel="noopener">Node management</a>
+</div>
+</div>
+<script>
+const token = {{.}};
+let running = false;
+
+function render(s) {
+  running = s.running;
+  document.getElementById('dot').className = running ? 'dot on' : 'dot';
+  document.getElementById('state').textContent = running ? 'running' : 'stopped';
+  document.getElementById('peers').textContent = running ? s.peers : '-';
+  document.getElementById('sync').textContent = s.sync;
+  document.getElementById('error').textContent = s.error || '';
+  const button = document.getElementById('toggle');
+  button.textContent = running ? 'Stop node' : 'Start node';
+  button.className = running ? 'stop' : '';
+  document.getElementById('links').className = running ? 'links' : 'links off';
+  for (const ui of ['browser', 'wallet', 'node']) {
+    document.getElementById(ui).href = s.uis[ui];
+  }
+}
+
+async function refresh() {
+  try {
+    render(await (await fetch('/status')).json());
+  } catch (e) {
+    document.getElementById('error').textContent = 'Launcher not responding';
+  }
+}
+
+document.getElementById('toggle').addEventListener('click', async () => {
+  const button = document.getElementById('toggle');
+  button.disabled = true;
+  try {
+    const resp = await fetch(running ? '/stop' : '/start', {method: 'POST', headers: {'X-Launcher-Token': token}});
+    if (!resp.ok) {
+      document.getElementById('error').textContent = await resp.text();
+    } else {
+      render(await resp.json());
+    }
+  } finally {
+    button.disabled = false;
+  }
+});
+
+refresh();
+setInterval(refresh, 3000);
+</script>
+</body>
+</html>
+`))
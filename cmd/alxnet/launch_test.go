@@ -0,0 +1,85 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestLauncherHandler(t *testing.T) {
+	l, err := newLauncher("alxnet", nil, filepath.Join(t.TempDir(), "launcher.log"), map[string]string{"node": "http://127.0.0.1:1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := l.handler()
+
+	tests := []struct {
+		name   string
+		method string
+		host   string
+		path   string
+		token  string
+		want   int
+	}{
+		{"page", http.MethodGet, "127.0.0.1:8079", "/", "", http.StatusOK},
+		{"status", http.MethodGet, "localhost:8079", "/status", "", http.StatusOK},
+		{"rebound host", http.MethodGet, "evil.example:8079", "/status", "", http.StatusForbidden},
+		{"stop without token", http.MethodPost, "127.0.0.1:8079", "/stop", "", http.StatusForbidden},
+		{"stop with wrong token", http.MethodPost, "127.0.0.1:8079", "/stop", "nope", http.StatusForbidden},
+		{"stop with GET", http.MethodGet, "127.0.0.1:8079", "/stop", l.token, http.StatusMethodNotAllowed},
+		{"stop", http.MethodPost, "[::1]:8079", "/stop", l.token, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Host = tt.host
+			if tt.token != "" {
+				req.Header.Set("X-Launcher-Token", tt.token)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestLauncherStartStop(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sleep")
+	}
+	sleep, err := exec.LookPath("sleep")
+	if err != nil {
+		t.Skip("sleep not found")
+	}
+	l, err := newLauncher(sleep, []string{"60"}, filepath.Join(t.TempDir(), "launcher.log"), map[string]string{"node": "http://127.0.0.1:1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.start(); err != nil {
+		t.Fatal(err)
+	}
+	st := l.status()
+	if !st.Running || st.PID == 0 || st.Sync != "starting" {
+		t.Fatalf("after start: %+v", st)
+	}
+	if err := l.start(); err != nil {
+		t.Fatal(err)
+	}
+	if got := l.status().PID; got != st.PID {
+		t.Errorf("second start replaced the process: pid %d, want %d", got, st.PID)
+	}
+
+	if err := l.stop(); err != nil {
+		t.Fatal(err)
+	}
+	st = l.status()
+	if st.Running || st.Sync != "stopped" || st.Error != "" {
+		t.Errorf("after stop: %+v", st)
+	}
+}
@@ -2,20 +2,42 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
 
+	"alxnet/internal/config"
+	"alxnet/internal/core"
+	"alxnet/internal/logging"
 	"alxnet/internal/p2p"
+	"alxnet/internal/statsexport"
 	"alxnet/internal/store"
+	"alxnet/internal/webhook"
 	"alxnet/internal/webserver"
 
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
+// logStreamSize is how many recent log entries the node UI's log viewer
+// can show on connecting.
+const logStreamSize = 2000
+
+// swarmKeyFile is the private network key picked up from the data
+// directory when none is configured.
+const swarmKeyFile = "swarm.key"
+
+// onionKeyFile keeps the key of the node's onion service, relative to the
+// data directory.
+const onionKeyFile = "onion_key"
+
 func main() {
 	if len(os.Args) < 2 {
 		usage()
@@ -25,6 +47,32 @@ func main() {
 	switch os.Args[1] {
 	case "start", "run":
 		cmdStart()
+	case "launch":
+		cmdLaunch(os.Args[2:])
+	case "keytool":
+		cmdKeytool(os.Args[2:])
+	case "wallet":
+		cmdWallet(os.Args[2:])
+	case "check":
+		cmdCheck(os.Args[2:])
+	case "root":
+		cmdRoot(os.Args[2:])
+	case "addressbook":
+		cmdAddressBook(os.Args[2:])
+	case "admin":
+		cmdAdmin(os.Args[2:])
+	case "doctor":
+		cmdDoctor(os.Args[2:])
+	case "car":
+		cmdCAR(os.Args[2:])
+	case "gateway":
+		cmdGateway(os.Args[2:])
+	case "bench":
+		cmdBench(os.Args[2:])
+	case "update":
+		cmdUpdate(os.Args[2:])
+	case "version":
+		cmdVersion()
 	default:
 		usage()
 	}
@@ -36,14 +84,41 @@ func usage() {
 	fmt.Println("Commands:")
 	fmt.Println("  start    Start the complete AlxNet platform")
 	fmt.Println("  run      Alias for start")
+	fmt.Println("  launch   Start and stop the node from a control page in your web browser")
+	fmt.Println("  keytool  Offline key derivation, signing and conversion")
+	fmt.Println("  wallet   Wallet maintenance (info, upgrade, stats, delete)")
+	fmt.Println("  check    Report a site's availability across peers")
+	fmt.Println("  root     Compute a site directory's root hash, or check a gateway serves it")
+	fmt.Println("  addressbook  Name sites you visit often (list, add, remove)")
+	fmt.Println("  admin    Manage a running node (peers, bans, pins, gc, denylist, reload)")
+	fmt.Println("  doctor   Diagnose ports, NAT, clock, storage and bootstrap peers")
+	fmt.Println("  car      Export or import sites as IPFS CAR archives")
+	fmt.Println("  bench    Benchmark the storage layer (bench store)")
+	fmt.Println("  gateway  Serve sites from peers read-only, without wallet or node UIs")
+	fmt.Println("  update   Install the latest signed release (update -check, update -rollback)")
+	fmt.Println("  version  Print the release and protocol version of this build")
 	fmt.Println("")
 	fmt.Println("Options for start:")
 	fmt.Println("  -data ./data            Data directory (default: ./data)")
 	fmt.Println("  -node-port 4001         P2P node port (default: auto)")
+	fmt.Println("  -listen A,B             P2P listen multiaddrs, such as /ip6/::/tcp/4001 (default: IPv4 and IPv6 at -node-port)")
+	fmt.Println("  -interfaces eth0,wg0    Listen on these network interfaces only (default: all)")
 	fmt.Println("  -browser-port 8080      Browser web interface port")
 	fmt.Println("  -wallet-port 8081       Wallet management web interface port")
 	fmt.Println("  -node-ui-port 8082      Node management web interface port")
 	fmt.Println("  -bootstrap ADDR         Bootstrap node address")
+	fmt.Println("  -gossip-compress 4096   Compress gossiped content from this size (default: off)")
+	fmt.Println("  -gossip-inline 65536    Largest content sent inline with updates (default: 65536)")
+	fmt.Println("  -pprof localhost:6060   Serve runtime profiles at /debug/pprof/ (default: off)")
+	fmt.Println("  -config alxnet.yaml     Configuration file, re-read on SIGHUP (default: none)")
+	fmt.Println("  -log-format json        Log encoder: console or json (default: console)")
+	fmt.Println("  -log-output stderr,node.log  Log destinations, files rotate by size (default: stderr)")
+	fmt.Println("  -ui-assets ./ui         Serve web interface files from this directory first (default: embedded)")
+	fmt.Println("  -swarm-key swarm.key    Join the private network of this key (default: swarm.key in -data if present)")
+	fmt.Println("  -sitemap                Let peers' search indexers list the sites stored here (default: off)")
+	fmt.Println("  -indexer                Crawl peers' sitemaps into the search page (default: off)")
+	fmt.Println("  -read-only              Serve only the browser interface from -data, a snapshot root or a stopped node's store")
+	fmt.Println("  -follow http://leader:8082  Copy a leader node's store and serve it read-only (token: ALXNET_REPLICATION_TOKEN)")
 	fmt.Println("")
 	fmt.Println("Examples:")
 	fmt.Println("  alxnet start                                    # Start with all defaults")
@@ -60,53 +135,215 @@ func cmdStart() {
 	fs := flag.NewFlagSet("start", flag.ExitOnError)
 	dataDir := fs.String("data", "./data", "data directory")
 	nodePort := fs.String("node-port", "0", "P2P node port (0 = auto)")
+	listen := fs.String("listen", "", "comma-separated P2P listen multiaddrs (default: IPv4 and IPv6 at -node-port)")
+	interfaces := fs.String("interfaces", "", "comma-separated network interfaces to listen on (default: all)")
 	browserPort := fs.String("browser-port", "8080", "Browser web interface port")
 	walletPort := fs.String("wallet-port", "8081", "Wallet management web interface port")
 	nodeUIPort := fs.String("node-ui-port", "8082", "Node management web interface port")
 	bootstrap := fs.String("bootstrap", "", "bootstrap node multiaddr")
+	gossipCompress := fs.Int("gossip-compress", 0, "zstd-compress gossiped content of at least this many bytes (0 = off)")
+	gossipInline := fs.Int("gossip-inline", p2p.DefaultGossipInlineLimit, "largest content in bytes sent inline with updates; larger content is fetched by CID")
+	pprofAddr := fs.String("pprof", "", "serve pprof profiles on this address, e.g. localhost:6060")
+	configPath := fs.String("config", "", "YAML configuration file, re-read on SIGHUP")
+	logFormat := fs.String("log-format", "", "log encoder: console or json (default from configuration)")
+	logOutputs := fs.String("log-output", "", "comma-separated log destinations: stderr, stdout or file paths")
+	uiAssets := fs.String("ui-assets", "", "directory whose files replace the embedded web interface files")
+	swarmKey := fs.String("swarm-key", "", "private network key file (default: network.swarm_key, or swarm.key in the data directory)")
+	sitemap := fs.Bool("sitemap", false, "serve a signed list of the sites stored here to search indexers")
+	indexer := fs.Bool("indexer", false, "crawl the sitemaps of peers into the local search index")
+	readOnly := fs.Bool("read-only", false, "serve only the browser interface from a store snapshot root or a stopped node's data directory")
+	follow := fs.String("follow", "", "follow the leader node with this management URL as a hot standby")
 	_ = fs.Parse(os.Args[2:])
 
-	// Setup logging
-	logger, err := zap.NewDevelopment()
+	// Configuration from -config and ALXNET_* variables
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	// Setup logging; the level follows configuration reloads and the admin API
+	logLevel, err := zap.ParseAtomicLevel(cfg.LogLevel)
+	if err != nil {
+		log.Fatalf("Invalid log level: %v", err)
+	}
+	logCfg := cfg.Logging
+	if *logFormat != "" {
+		logCfg.Format = *logFormat
+	}
+	if *logOutputs != "" {
+		logCfg.Outputs = strings.Split(*logOutputs, ",")
+	}
+	if err := logCfg.Validate(); err != nil {
+		log.Fatalf("Invalid logging options: %v", err)
+	}
+	logger, logFiles, err := logging.New(logCfg, logLevel)
 	if err != nil {
 		log.Fatalf("Failed to create logger: %v", err)
 	}
 	defer func() {
-		if err := logger.Sync(); err != nil {
-			log.Printf("Failed to sync logger: %v", err)
+		// Sync of a terminal fails on some platforms; files are synced on close
+		_ = logger.Sync()
+		if err := logFiles.Close(); err != nil {
+			log.Printf("Failed to close log files: %v", err)
 		}
 	}()
 
+	// Recent entries are kept for the node UI's log viewer, including
+	// lines written through the standard library's log package
+	logStream := logging.NewStream(logStreamSize)
+	streamTo := zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		return zapcore.NewTee(c, logStream.Core(logLevel))
+	})
+	logger = logger.WithOptions(streamTo)
+	defer zap.RedirectStdLog(logger.Named("stdlog"))()
+
+	// Rejected messages and requests go to their own stream when configured
+	auditLogger := logger.Named("audit")
+	if len(logCfg.AuditOutputs) > 0 {
+		auditCfg := logCfg
+		auditCfg.Outputs = logCfg.AuditOutputs
+		var auditFiles io.Closer
+		auditLogger, auditFiles, err = logging.New(auditCfg, zap.NewAtomicLevelAt(zap.InfoLevel))
+		if err != nil {
+			log.Fatalf("Failed to create audit logger: %v", err)
+		}
+		auditLogger = auditLogger.Named("audit").WithOptions(streamTo)
+		defer auditFiles.Close()
+	}
+
+	// Record timestamps further ahead than this are rejected
+	core.SetMaxClockSkew(cfg.Security.MaxClockSkew)
+	// The NTP query would go around a SOCKS proxy
+	if cfg.Network.NTPServer != "" && cfg.Network.SOCKS5Proxy == "" {
+		go checkClock(context.Background(), cfg.Network.NTPServer, logger)
+	}
+
+	if *pprofAddr != "" {
+		pprofServer := startPprof(*pprofAddr, logger)
+		defer pprofServer.Close()
+	}
+
+	// Theme and branding shared by the web interfaces
+	uiCfg := cfg.UI
+	if *uiAssets != "" {
+		uiCfg.AssetsDir = *uiAssets
+	}
+
+	// Cooperative indexing, off unless the flag or configuration enables it
+	searchCfg := cfg.Search
+	searchCfg.Sitemap = searchCfg.Sitemap || *sitemap
+	searchCfg.Indexer = searchCfg.Indexer || *indexer
+
+	if *readOnly {
+		// A replica runs no node; it only serves what the store holds
+		runReplica(*dataDir, mustParseInt(*browserPort), uiCfg, logger)
+		return
+	}
+
 	// Ensure data directory exists
 	if err := os.MkdirAll(*dataDir, 0755); err != nil {
 		log.Fatalf("Failed to create data directory: %v", err)
 	}
 
-	// Open database
-	db, err := store.Open(*dataDir)
+	// Open database, tuned by the configured profile
+	db, err := store.OpenWithOptions(*dataDir, cfg.Storage.Badger.StoreOptions())
 	if err != nil {
 		log.Fatalf("Failed to open database: %v", err)
 	}
 	defer db.Close()
 
+	// Content cache and optional cold storage
+	storageCfg := cfg.Storage
+	db.SetContentCacheSize(storageCfg.ContentCacheSize)
+	db.SetCompression(storageCfg.EnableCompression)
+
+	blobCfg := storageCfg.Blob
+	if blobCfg.Backend == "s3" {
+		backend, err := store.NewS3Backend(store.S3Config{
+			Endpoint:        blobCfg.S3Endpoint,
+			Region:          blobCfg.S3Region,
+			Bucket:          blobCfg.S3Bucket,
+			Prefix:          blobCfg.S3Prefix,
+			AccessKeyID:     blobCfg.S3AccessKeyID,
+			SecretAccessKey: blobCfg.S3SecretAccessKey,
+		})
+		if err != nil {
+			log.Fatalf("Failed to configure S3 blob backend: %v", err)
+		}
+		db.SetBlobBackend(backend, blobCfg.Threshold, blobCfg.CacheTTL)
+		logger.Info("large content offloaded to S3",
+			zap.String("endpoint", blobCfg.S3Endpoint),
+			zap.String("bucket", blobCfg.S3Bucket),
+			zap.Int("threshold", blobCfg.Threshold))
+	}
+
+	// A follower copies the leader's store until restarted without a leader
+	replCfg := cfg.Replication
+	if *follow != "" {
+		replCfg.Leader = *follow
+		if err := replCfg.Validate(); err != nil {
+			log.Fatalf("Invalid replication options: %v", err)
+		}
+	}
+	if replCfg.Leader != "" {
+		runFollower(db, *dataDir, replCfg, mustParseInt(*browserPort), uiCfg, logger)
+		return
+	}
+	promoteFollower(*dataDir, logger)
+
 	// Setup context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// Create and start P2P node
-	var listenAddr string
-	if *nodePort == "0" {
-		listenAddr = "/ip4/0.0.0.0/tcp/0" // Auto-assign port
-	} else {
-		listenAddr = fmt.Sprintf("/ip4/0.0.0.0/tcp/%s", *nodePort)
+	// Snapshots for read-only replicas, see runReplica
+	if storageCfg.SnapshotDir != "" {
+		go writeSnapshots(ctx, db, storageCfg.SnapshotDir, storageCfg.SnapshotInterval, logger)
 	}
 
-	var bootstrapPeers []string
+	// Site history is pruned down to the retention policies, if any
+	retention := store.RetentionRules{Default: storageCfg.Retention.Default, Pinned: storageCfg.Retention.Pinned}
+	go applyRetention(ctx, db, retention, storageCfg.Retention.Interval, logger.Named("retention"))
+
+	// Site events go to the configured webhooks
+	hooks := webhook.New(logger.Named("webhook"))
+	hooks.Start(ctx, webhookHooks(cfg.Webhooks))
+	db.SetEventSink(func(e store.Event) { hooks.Send(e.Type, e.Data) })
+
+	// Create and start P2P node
+	listenAddrs := nodeListenAddrs(splitList(*listen), cfg.Network, mustParseInt(*nodePort))
+
+	var flagBootstrap []string
 	if *bootstrap != "" {
-		bootstrapPeers = []string{*bootstrap}
+		flagBootstrap = []string{*bootstrap}
 	}
+	bootstrapPeers := append(append([]string{}, flagBootstrap...), cfg.Network.BootstrapPeers...)
 
-	node, err := p2p.New(ctx, db, listenAddr, bootstrapPeers, nil)
+	nodeCfg := p2p.DefaultNodeConfig()
+	nodeCfg.GossipCompressThreshold = *gossipCompress
+	nodeCfg.GossipInlineLimit = *gossipInline
+	nodeCfg.MaxPeers = cfg.Network.MaxPeers
+	nodeCfg.MaxRequestsPerWindow = cfg.Security.RateLimit
+	nodeCfg.EnableRateLimiting = cfg.Security.EnableRateLimiting
+	nodeCfg.Logger = logger.Named("p2p")
+	nodeCfg.AuditLogger = auditLogger
+	nodeCfg.ServeSitemap = searchCfg.Sitemap
+	nodeCfg.ShardQuota = cfg.Storage.ShardQuota
+	nodeCfg.FetchSlots = cfg.Network.Fetch.Slots
+	nodeCfg.FetchShares = fetchShares(cfg.Network.Fetch)
+	nodeCfg.ListenAddrs = listenAddrs[1:]
+	nodeCfg.Interfaces = cfg.Network.Interfaces
+	nodeCfg.ActivationTimes = cfg.Network.ActivationTimes()
+	if *interfaces != "" {
+		nodeCfg.Interfaces = splitList(*interfaces)
+	}
+	applyProxyConfig(nodeCfg, cfg.Network, *dataDir)
+	if *swarmKey == "" {
+		*swarmKey = cfg.Network.SwarmKey
+	}
+	if nodeCfg.PrivateNetworkKey, err = loadNodeSwarmKey(*swarmKey, *dataDir); err != nil {
+		log.Fatalf("Failed to load private network key: %v", err)
+	}
+	node, err := p2p.New(ctx, db, listenAddrs[0], bootstrapPeers, nodeCfg)
 	if err != nil {
 		log.Fatalf("Failed to create P2P node: %v", err)
 	}
@@ -124,10 +361,9 @@ func cmdStart() {
 		if tcp := addr.String(); tcp != "" {
 			logger.Info("P2P node listening", zap.String("address", tcp))
 			// Extract port from address like /ip4/0.0.0.0/tcp/4001
-			if len(addr.Protocols()) > 1 {
+			if actualNodePort == "" && len(addr.Protocols()) > 1 {
 				actualNodePort = addr.String()[len(addr.String())-4:]
 			}
-			break
 		}
 	}
 
@@ -135,10 +371,28 @@ func cmdStart() {
 		zap.String("id", node.Host.ID().String()),
 		zap.String("port", actualNodePort))
 
+	// Statistics pushed to InfluxDB or Graphite
+	if sc := cfg.StatsExport; sc.Format != "" {
+		exporter, err := statsexport.New(sc.Format, sc.Endpoint, sc.Token, sc.Prefix, map[string]string{"peer": node.Host.ID().String()})
+		if err != nil {
+			log.Fatalf("Failed to configure stats export: %v", err)
+		}
+		go exporter.Run(ctx, sc.Interval, node.MetricsNow, logger.Named("stats"))
+		logger.Info("pushing stats", zap.String("format", sc.Format), zap.String("endpoint", sc.Endpoint), zap.Duration("interval", sc.Interval))
+	}
+
 	// Start all web servers
 
 	// 1. Browser Interface (existing functionality)
-	browserServer := webserver.NewBrowserServer(db, node, logger, mustParseInt(*browserPort))
+	browserServer := webserver.NewBrowserServer(db, node, logger.Named("browser"), mustParseInt(*browserPort))
+	browserServer.SetAPILimits(cfg.Security.APILimits())
+	browserServer.SetAllowedHosts(cfg.Security.AllowedHosts)
+	if err := browserServer.SetUI(uiCfg); err != nil {
+		log.Fatalf("Failed to load web interface assets: %v", err)
+	}
+	if searchCfg.Indexer {
+		browserServer.EnableIndexer(searchCfg.CrawlInterval)
+	}
 	if err := browserServer.Start(); err != nil {
 		log.Fatalf("Failed to start browser server: %v", err)
 	}
@@ -148,8 +402,22 @@ func cmdStart() {
 		}
 	}()
 
+	// The admin token, and the tokens it issues, guard the wallet and node
+	// management APIs
+	adminToken, err := loadAdminToken(filepath.Join(*dataDir, adminTokenFile))
+	if err != nil {
+		log.Fatalf("Failed to load admin token: %v", err)
+	}
+
 	// 2. Wallet Management Interface (new)
-	walletServer := webserver.NewWalletServer(db, node, logger, mustParseInt(*walletPort))
+	walletServer := webserver.NewWalletServer(db, node, logger.Named("wallet"), mustParseInt(*walletPort))
+	walletServer.SetAdminToken(adminToken)
+	walletServer.SetRequireToken(cfg.Security.RequireAPIToken)
+	walletServer.SetAPILimits(cfg.Security.APILimits())
+	walletServer.SetAllowedHosts(cfg.Security.AllowedHosts)
+	if err := walletServer.SetUI(uiCfg); err != nil {
+		log.Fatalf("Failed to load web interface assets: %v", err)
+	}
 	if err := walletServer.Start(); err != nil {
 		log.Fatalf("Failed to start wallet server: %v", err)
 	}
@@ -160,7 +428,30 @@ func cmdStart() {
 	}()
 
 	// 3. Node Management Interface (new)
-	nodeUIServer := webserver.NewNodeServer(db, node, logger, mustParseInt(*nodeUIPort))
+	nodeUIServer := webserver.NewNodeServer(db, node, logger.Named("nodeui"), mustParseInt(*nodeUIPort))
+	nodeUIServer.SetAdminToken(adminToken)
+	nodeUIServer.SetRequireToken(cfg.Security.RequireAPIToken)
+	nodeUIServer.SetAPILimits(cfg.Security.APILimits())
+	nodeUIServer.SetAllowedHosts(cfg.Security.AllowedHosts)
+	reloader := &nodeReloader{
+		ctx:       ctx,
+		path:      *configPath,
+		bootstrap: flagBootstrap,
+		level:     logLevel,
+		node:      node,
+		db:        db,
+		hooks:     hooks,
+		logger:    logger,
+		cacheSize: storageCfg.ContentCacheSize,
+	}
+	nodeUIServer.SetReloadFunc(reloader.reload)
+	nodeUIServer.SetLogLevel(logLevel)
+	nodeUIServer.SetLogStream(logStream)
+	nodeUIServer.SetNTPServer(cfg.Network.NTPServer)
+	nodeUIServer.SetRetention(retention)
+	if err := nodeUIServer.SetUI(uiCfg); err != nil {
+		log.Fatalf("Failed to load web interface assets: %v", err)
+	}
 	if err := nodeUIServer.Start(); err != nil {
 		log.Fatalf("Failed to start node UI server: %v", err)
 	}
@@ -185,16 +476,26 @@ func cmdStart() {
 	fmt.Printf("   🔗 Node Management:        http://localhost:%s\n", *nodeUIPort)
 	fmt.Printf("   📡 P2P Node Port:          %s\n", actualNodePort)
 	fmt.Printf("   📂 Data Directory:         %s\n", *dataDir)
+	if fp := node.PrivateNetwork(); fp != "" {
+		fmt.Printf("   🔒 Private Network:        %s\n", fp)
+	}
 	fmt.Println("=====================================")
 	fmt.Println("")
 	fmt.Println("   Open your web browser and navigate to any of the URLs above")
 	fmt.Println("   Press Ctrl+C to stop all services")
 	fmt.Println("")
 
-	// Wait for shutdown signal
+	// Wait for shutdown signal; SIGHUP reloads the configuration
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	<-sigChan
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	for sig := range sigChan {
+		if sig != syscall.SIGHUP {
+			break
+		}
+		if _, err := reloader.reload(); err != nil {
+			logger.Error("Configuration reload failed", zap.Error(err))
+		}
+	}
 
 	logger.Info("Shutting down AlxNet Platform...")
 	fmt.Println("\nShutting down all services...")
@@ -207,3 +508,66 @@ func mustParseInt(s string) int {
 	}
 	return port
 }
+
+// nodeListenAddrs returns the P2P listen addresses: listen if given, else
+// network.listen_addrs, else every IPv4 and IPv6 address at port. A node
+// published as an onion service defaults to IPv4 loopback, so peers come
+// in through Tor only.
+func nodeListenAddrs(listen []string, nc config.NetworkConfig, port int) []string {
+	switch {
+	case len(listen) > 0:
+		return listen
+	case len(nc.ListenAddrs) > 0:
+		return nc.ListenAddrs
+	case nc.Onion.Enabled:
+		return []string{fmt.Sprintf("/ip4/127.0.0.1/tcp/%d", port)}
+	}
+	return p2p.WildcardListenAddrs(port)
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// fetchShares returns the request slot shares of fc by priority class.
+func fetchShares(fc config.FetchConfig) map[p2p.Priority]int {
+	return map[p2p.Priority]int{
+		p2p.PriorityInteractive: fc.Interactive,
+		p2p.PriorityBackground:  fc.Background,
+	}
+}
+
+// applyProxyConfig routes the node's connections through the configured
+// SOCKS5 proxy and publishes it as an onion service, whose key is kept in
+// dataDir.
+func applyProxyConfig(nodeCfg *p2p.NodeConfig, nc config.NetworkConfig, dataDir string) {
+	nodeCfg.SOCKS5Proxy = nc.SOCKS5Proxy
+	if nc.Onion.Enabled {
+		nodeCfg.OnionService = &p2p.OnionServiceConfig{
+			ControlAddr:     nc.Onion.ControlAddr,
+			ControlPassword: nc.Onion.ControlPassword,
+			KeyFile:         filepath.Join(dataDir, onionKeyFile),
+			Port:            nc.Onion.Port,
+		}
+	}
+}
+
+// loadNodeSwarmKey returns the private network key in path, or in
+// swarmKeyFile in dataDir if path is empty and that file exists. Nil means
+// the public network.
+func loadNodeSwarmKey(path, dataDir string) ([]byte, error) {
+	if path == "" {
+		path = filepath.Join(dataDir, swarmKeyFile)
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+	}
+	return p2p.LoadSwarmKey(path)
+}
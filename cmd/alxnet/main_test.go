@@ -0,0 +1,35 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"alxnet/internal/p2p"
+)
+
+func TestLoadNodeSwarmKey(t *testing.T) {
+	key, _ := p2p.GenerateSwarmKey()
+	dataDir, empty := t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(dataDir, swarmKeyFile), p2p.EncodeSwarmKey(key), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name, path, dataDir string
+		want                []byte
+		wantErr             bool
+	}{
+		{"public network", "", empty, nil, false},
+		{"data directory", "", dataDir, key, false},
+		{"explicit file", filepath.Join(dataDir, swarmKeyFile), empty, key, false},
+		// A configured key that is missing must not fall back to the public network
+		{"missing file", filepath.Join(empty, "swarm.key"), dataDir, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := loadNodeSwarmKey(tt.path, tt.dataDir)
+		if (err != nil) != tt.wantErr || !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: loadNodeSwarmKey = %x, %v; want %x, err %v", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
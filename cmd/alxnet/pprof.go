@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// startPprof serves the runtime profiles under /debug/pprof/ on addr. It has
+// its own listener so profiles are never reachable through the web UIs.
+func startPprof(addr string, logger *zap.Logger) *http.Server {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			logger.Warn("pprof is listening on a non-loopback address", zap.String("addr", addr))
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Error("pprof server failed", zap.Error(err))
+		}
+	}()
+	logger.Info("pprof endpoints enabled", zap.String("url", "http://"+addr+"/debug/pprof/"))
+	return srv
+}
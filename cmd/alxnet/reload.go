@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	"alxnet/internal/config"
+	"alxnet/internal/core"
+	"alxnet/internal/p2p"
+	"alxnet/internal/store"
+	"alxnet/internal/webhook"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// nodeReloader re-reads the node configuration on SIGHUP or an admin
+// request and applies the values that can change while the node runs:
+// log level, peer rate limit, peer limit, content cache size, bootstrap
+// peers, max clock skew and webhooks. Everything else needs a restart.
+type nodeReloader struct {
+	ctx       context.Context
+	path      string
+	bootstrap []string // from -bootstrap, kept across reloads
+	level     zap.AtomicLevel
+	node      *p2p.Node
+	db        *store.Store
+	hooks     *webhook.Dispatcher
+	logger    *zap.Logger
+
+	mu        sync.Mutex
+	cacheSize int64
+}
+
+// reload applies the configuration and returns what it set. Nothing is
+// changed if the configuration does not load or validate.
+func (r *nodeReloader) reload() (map[string]interface{}, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cfg, err := config.Load(r.path)
+	if err != nil {
+		return nil, err
+	}
+	level, err := zapcore.ParseLevel(cfg.LogLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	r.level.SetLevel(level)
+	if err := r.node.SetRateLimit(cfg.Security.RateLimit, p2p.RateLimitWindow, cfg.Security.EnableRateLimiting); err != nil {
+		return nil, err
+	}
+	if err := r.node.SetMaxPeers(cfg.Network.MaxPeers); err != nil {
+		return nil, err
+	}
+	if cfg.Storage.ContentCacheSize != r.cacheSize {
+		// Resizing starts an empty cache, so only do it on a change
+		r.db.SetContentCacheSize(cfg.Storage.ContentCacheSize)
+		r.cacheSize = cfg.Storage.ContentCacheSize
+	}
+	peers := append(append([]string{}, r.bootstrap...), cfg.Network.BootstrapPeers...)
+	bootstrap := r.node.SetBootstrapPeers(r.ctx, peers)
+	core.SetMaxClockSkew(cfg.Security.MaxClockSkew)
+	r.hooks.SetHooks(webhookHooks(cfg.Webhooks))
+
+	applied := map[string]interface{}{
+		"log_level":            level.String(),
+		"rate_limit":           cfg.Security.RateLimit,
+		"enable_rate_limiting": cfg.Security.EnableRateLimiting,
+		"max_peers":            cfg.Network.MaxPeers,
+		"content_cache_size":   cfg.Storage.ContentCacheSize,
+		"bootstrap_peers":      bootstrap,
+		"max_clock_skew":       cfg.Security.MaxClockSkew.String(),
+		"webhooks":             len(cfg.Webhooks),
+	}
+	r.logger.Info("configuration reloaded", zap.Any("applied", applied))
+	return applied, nil
+}
+
+// webhookHooks converts configured webhooks for the dispatcher.
+func webhookHooks(cfgs []config.WebhookConfig) []webhook.Hook {
+	hooks := make([]webhook.Hook, 0, len(cfgs))
+	for _, c := range cfgs {
+		hooks = append(hooks, webhook.Hook{URL: c.URL, Secret: c.Secret, Events: c.Events})
+	}
+	return hooks
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"alxnet/internal/config"
+	"alxnet/internal/store"
+	"alxnet/internal/webserver"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// replicaPollInterval is how often a replica looks for a newer snapshot.
+	replicaPollInterval = 10 * time.Second
+	// replicaDrainDelay is how long a replaced snapshot stays open for the
+	// requests still reading it.
+	replicaDrainDelay = 30 * time.Second
+)
+
+// writeSnapshots writes a snapshot of db under root now and then every
+// interval, until ctx ends.
+func writeSnapshots(ctx context.Context, db *store.Store, root string, interval time.Duration, logger *zap.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		start := time.Now()
+		if dir, err := db.WriteSnapshot(root); err != nil {
+			logger.Error("store snapshot failed", zap.String("root", root), zap.Error(err))
+		} else {
+			logger.Info("store snapshot written", zap.String("dir", dir), zap.Duration("took", time.Since(start)))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// replicaView is the browser interface over one read-only store.
+type replicaView struct {
+	dir string
+	db  *store.Store
+	ws  *webserver.WebServer
+}
+
+func openReplicaView(dir string, port int, uiCfg config.UIConfig, logger *zap.Logger) (*replicaView, error) {
+	db, err := store.OpenReadOnly(dir)
+	if err != nil {
+		return nil, err
+	}
+	ws := webserver.NewBrowserServer(db, nil, logger, port)
+	if err := ws.SetUI(uiCfg); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &replicaView{dir: dir, db: db, ws: ws}, nil
+}
+
+func (v *replicaView) close() {
+	_ = v.ws.Stop()
+	_ = v.db.Close()
+}
+
+// runReplica serves the browser interface read-only from dir until the
+// process is signalled. dir is either a snapshot root written by a node
+// with storage.snapshot_dir, whose newest snapshot is served and followed,
+// or the data directory of a node that is not running.
+func runReplica(dir string, port int, uiCfg config.UIConfig, logger *zap.Logger) {
+	snapshot, err := store.CurrentSnapshot(dir)
+	if err != nil {
+		log.Fatalf("Failed to read snapshots: %v", err)
+	}
+	open := dir
+	if snapshot != "" {
+		open = snapshot
+	}
+	first, err := openReplicaView(open, port, uiCfg, logger)
+	if err != nil {
+		log.Fatalf("Failed to open read-only store: %v", err)
+	}
+	var current atomic.Pointer[replicaView]
+	current.Store(first)
+
+	srv := &http.Server{
+		Addr: fmt.Sprintf(":%d", port),
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			current.Load().ws.Handler().ServeHTTP(w, r)
+		}),
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 10 * time.Second,
+		IdleTimeout:  60 * time.Second,
+	}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Error("replica server error", zap.Error(err))
+		}
+	}()
+	logger.Info("serving read-only replica", zap.String("store", open), zap.Int("port", port))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if snapshot != "" {
+		go followSnapshots(ctx, dir, &current, port, uiCfg, logger)
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	<-sigChan
+	cancel()
+	shutdown, done := context.WithTimeout(context.Background(), 5*time.Second)
+	defer done()
+	_ = srv.Shutdown(shutdown)
+	current.Load().close()
+}
+
+// followSnapshots switches current to each newer snapshot under root.
+func followSnapshots(ctx context.Context, root string, current *atomic.Pointer[replicaView], port int, uiCfg config.UIConfig, logger *zap.Logger) {
+	ticker := time.NewTicker(replicaPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		dir, err := store.CurrentSnapshot(root)
+		if err != nil || dir == "" || dir == current.Load().dir {
+			continue
+		}
+		next, err := openReplicaView(dir, port, uiCfg, logger)
+		if err != nil {
+			logger.Warn("failed to open newer snapshot", zap.String("dir", dir), zap.Error(err))
+			continue
+		}
+		old := current.Swap(next)
+		logger.Info("switched to newer snapshot", zap.String("dir", dir))
+		time.AfterFunc(replicaDrainDelay, old.close)
+	}
+}
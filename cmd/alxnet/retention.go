@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"alxnet/internal/store"
+
+	"go.uber.org/zap"
+)
+
+// applyRetention prunes site history in db down to rules and the sites' own
+// policies every interval, until ctx ends. The first run waits an interval,
+// so a node restarted with a stricter policy by mistake can be stopped
+// before it prunes.
+func applyRetention(ctx context.Context, db *store.Store, rules store.RetentionRules, interval time.Duration, logger *zap.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		r, err := db.ApplyRetention(rules)
+		if err != nil {
+			logger.Error("retention run failed", zap.Error(err))
+			continue
+		}
+		for _, p := range r.Problems {
+			logger.Warn("retention skipped a site", zap.String("problem", p))
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"alxnet/internal/core"
+	"alxnet/internal/fingerprint"
+	"alxnet/internal/p2p"
+	"alxnet/internal/verify"
+)
+
+// maxServedFile bounds the files read from a gateway while checking a site.
+const maxServedFile = 100 << 20
+
+func rootUsage() {
+	fmt.Println("AlxNet Root - compute and check a site's root hash")
+	fmt.Println("")
+	fmt.Println("Usage: alxnet root -dir DIR [-list]")
+	fmt.Println("       alxnet root -site NAME -expect ROOT [-gateway URL]")
+	fmt.Println("")
+	fmt.Println("The root hash digests the paths and contents of a site's files, the file map")
+	fmt.Println("its manifest signs, and does not change with the version or publish time.")
+	fmt.Println("Compute it from the site's directory before publishing and share it with the")
+	fmt.Println("name. Readers then check that a gateway serves exactly those files: every file")
+	fmt.Println("is downloaded as the gateway serves it and hashed here.")
+	fmt.Println("")
+	fmt.Println("Options:")
+	fmt.Println("  -dir DIR          Site directory, as published with its files at their relative paths")
+	fmt.Println("  -list             Also list each file with its content CID")
+	fmt.Println("  -site NAME        Site name (mysite or mysite.bn) or 64-char site ID")
+	fmt.Println("  -expect ROOT      Root hash the publisher shared")
+	fmt.Println("  -gateway URL      Gateway serving the site (default: http://localhost:8080)")
+	fmt.Println("")
+	fmt.Println("Exits with status 1 if the root differs or a served file does not match it.")
+}
+
+func cmdRoot(args []string) {
+	fs := flag.NewFlagSet("root", flag.ExitOnError)
+	fs.Usage = rootUsage
+	dir := fs.String("dir", "", "site directory")
+	list := fs.Bool("list", false, "list each file with its content CID")
+	site := fs.String("site", "", "site name or site ID")
+	expect := fs.String("expect", "", "root hash the publisher shared")
+	gateway := fs.String("gateway", "http://localhost:8080", "gateway serving the site")
+	_ = fs.Parse(args)
+
+	switch {
+	case *dir != "":
+		root, files, err := dirRoot(*dir)
+		if err != nil {
+			log.Fatalf("root: %v", err)
+		}
+		if *list {
+			for _, p := range sortedPaths(files) {
+				fmt.Printf("  %s  %s\n", p2p.Short(files[p]), p)
+			}
+			fmt.Println("")
+		}
+		fmt.Printf("root:   %s (%d files)\n", root, len(files))
+		if *expect != "" && !strings.EqualFold(*expect, root) {
+			fmt.Printf("result: differs from %s\n", *expect)
+			os.Exit(1)
+		}
+	case *site != "" && *expect != "":
+		client := &http.Client{Timeout: 60 * time.Second}
+		ok, err := checkServedRoot(client, strings.TrimRight(*gateway, "/"), strings.TrimSuffix(*site, ".bn"), strings.ToLower(*expect), os.Stdout)
+		if err != nil {
+			log.Fatalf("root: %v", err)
+		}
+		if !ok {
+			os.Exit(1)
+		}
+	default:
+		rootUsage()
+		os.Exit(2)
+	}
+}
+
+// dirRoot returns the root hash of the files under dir and the file map it
+// digests, path to content CID. Operating system litter is skipped, as
+// uploads do; a file that could not be published is an error.
+func dirRoot(dir string) (string, map[string]string, error) {
+	files := make(map[string]string)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if base := path.Base(rel); strings.HasPrefix(rel, "__MACOSX/") || base == ".DS_Store" || base == "Thumbs.db" {
+			return nil
+		}
+		if err := core.ValidateFilePath(rel); err != nil {
+			return fmt.Errorf("%s cannot be published: %v", rel, err)
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		files[rel] = core.CIDForContent(data)
+		return nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	if len(files) == 0 {
+		return "", nil, fmt.Errorf("no files in %s", dir)
+	}
+	root, err := verify.ManifestFilesCID(files)
+	return root, files, err
+}
+
+// checkServedRoot checks that the gateway serves site with the files of
+// root. The gateway names the files; their map must hash to root, and each
+// file as served must match its CID. Markdown and text files the gateway
+// renders as reader-mode pages can only be checked by their source, which
+// is fetched by CID instead; they are reported as rendered.
+func checkServedRoot(client *http.Client, gateway, site, root string, out io.Writer) (bool, error) {
+	var v struct {
+		SiteID string            `json:"site_id"`
+		Files  map[string]string `json:"files"`
+	}
+	if err := getJSON(client, gateway+"/api/site/verify/"+url.PathEscape(site)+"?peers=0", &v); err != nil {
+		return false, err
+	}
+	got, err := verify.ManifestFilesCID(v.Files)
+	if err != nil {
+		return false, err
+	}
+	fmt.Fprintf(out, "site:   %s (%s)\n", site, v.SiteID)
+	fmt.Fprintf(out, "root:   %s\n", got)
+	if got != root {
+		fmt.Fprintf(out, "result: the gateway's files differ from root %s\n", root)
+		return false, nil
+	}
+
+	fmt.Fprintln(out, "")
+	bad, rendered := 0, 0
+	for _, p := range sortedPaths(v.Files) {
+		cid := v.Files[p]
+		mark := "ok      "
+		served, err := fetchCID(client, gateway+"/"+v.SiteID+"/"+escapePath(p))
+		switch {
+		case err != nil:
+			mark = "MISSING "
+			bad++
+		case served != cid:
+			// Reader-mode pages differ from their source; the source itself
+			// must still be served
+			if !readerSource(p) {
+				mark = "DIFFERS "
+				bad++
+			} else if raw, err := fetchCID(client, gateway+fingerprint.AssetPath(cid, path.Base(p))); err == nil && raw == cid {
+				mark = "RENDERED"
+				rendered++
+			} else {
+				mark = "DIFFERS "
+				bad++
+			}
+		}
+		fmt.Fprintf(out, "  %s  %s  %s\n", mark, p2p.Short(cid), p)
+	}
+	fmt.Fprintln(out, "")
+	switch {
+	case bad > 0:
+		fmt.Fprintf(out, "result: %d of %d files not served as published\n", bad, len(v.Files))
+	case rendered > 0:
+		fmt.Fprintf(out, "result: matches (%d files served rendered, only their source was checked)\n", rendered)
+	default:
+		fmt.Fprintln(out, "result: matches")
+	}
+	return bad == 0, nil
+}
+
+// readerSource reports whether the file at p can be served in reader mode.
+func readerSource(p string) bool {
+	switch strings.ToLower(path.Ext(p)) {
+	case ".md", ".markdown", ".txt":
+		return true
+	}
+	return false
+}
+
+// fetchCID downloads u and returns the CID of the response.
+func fetchCID(client *http.Client, u string) (string, error) {
+	resp, err := client.Get(u)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s: %s", u, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxServedFile))
+	if err != nil {
+		return "", err
+	}
+	return core.CIDForContent(data), nil
+}
+
+func getJSON(client *http.Client, u string, v interface{}) error {
+	resp, err := client.Get(u)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("gateway returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("invalid response: %v", err)
+	}
+	return nil
+}
+
+// escapePath escapes each segment of a site file path for a URL.
+func escapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+func sortedPaths(files map[string]string) []string {
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
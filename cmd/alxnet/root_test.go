@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"alxnet/internal/core"
+	"alxnet/internal/fingerprint"
+	"alxnet/internal/publisher"
+	"alxnet/internal/store"
+	"alxnet/internal/verify"
+
+	"go.uber.org/zap"
+)
+
+func TestDirRootMatchesPublished(t *testing.T) {
+	dir := t.TempDir()
+	site := map[string]string{
+		"index.html":   "<h1>hi</h1>",
+		"css/site.css": "h1 {}",
+		"notes.md":     "# Notes",
+	}
+	for p, content := range site {
+		if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(p)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, p), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".DS_Store"), []byte("litter"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	root, files, err := dirRoot(dir)
+	if err != nil || len(files) != 3 {
+		t.Fatalf("dirRoot = %s %v, %v", root, files, err)
+	}
+
+	s, err := store.NewMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := publisher.New(s, nil, zap.NewNop())
+	var m *publisher.Manifest
+	for path, mimeType := range map[string]string{"index.html": "text/html", "css/site.css": "text/css", "notes.md": "text/markdown; render=html"} {
+		if _, m, err = p.AddFile(context.Background(), priv, path, []byte(site[path]), mimeType); err != nil {
+			t.Fatalf("AddFile(%s): %v", path, err)
+		}
+	}
+	if published, _ := verify.ManifestFilesCID(m.Files); published != root {
+		t.Errorf("published root %s, directory root %s", published, root)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "run.exe"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := dirRoot(dir); err == nil || !strings.Contains(err.Error(), "run.exe") {
+		t.Errorf("dirRoot with an unpublishable file: %v", err)
+	}
+}
+
+func TestCheckServedRoot(t *testing.T) {
+	const siteID = "aa00000000000000000000000000000000000000000000000000000000000000"
+	source := map[string]string{"index.html": "<h1>hi</h1>", "notes.md": "# Notes"}
+	served := map[string]string{"index.html": "<h1>hi</h1>", "notes.md": "<html>rendered</html>"}
+	files := map[string]string{}
+	byCID := map[string]string{}
+	for p, content := range source {
+		files[p] = core.CIDForContent([]byte(content))
+		byCID[files[p]] = content
+	}
+	root, _ := verify.ManifestFilesCID(files)
+
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/site/verify/mysite":
+			json.NewEncoder(w).Encode(map[string]interface{}{"site_id": siteID, "files": files})
+		case strings.HasPrefix(r.URL.Path, fingerprint.PathPrefix):
+			cid, _, _ := fingerprint.ParsePath(r.URL.Path)
+			io.WriteString(w, byCID[cid])
+		default:
+			content, ok := served[strings.TrimPrefix(r.URL.Path, "/"+siteID+"/")]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			io.WriteString(w, content)
+		}
+	}))
+	defer gateway.Close()
+
+	check := func(want string) (bool, string) {
+		var out strings.Builder
+		ok, err := checkServedRoot(gateway.Client(), gateway.URL, "mysite", want, &out)
+		if err != nil {
+			t.Fatalf("checkServedRoot: %v", err)
+		}
+		return ok, out.String()
+	}
+	if ok, out := check(root); !ok || !strings.Contains(out, "RENDERED") {
+		t.Errorf("untampered site: %v\n%s", ok, out)
+	}
+	if ok, out := check(strings.Repeat("0", 64)); ok || !strings.Contains(out, "differ") {
+		t.Errorf("other root: %v\n%s", ok, out)
+	}
+
+	served["index.html"] = "<h1>injected</h1>"
+	if ok, out := check(root); ok || !strings.Contains(out, "DIFFERS") {
+		t.Errorf("tampered file: %v\n%s", ok, out)
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"alxnet/internal/p2p"
+	"alxnet/internal/release"
+)
+
+// version is the release this build belongs to.
+var version = strings.TrimPrefix(p2p.AgentVersion, "alxnet/")
+
+func updateUsage() {
+	fmt.Println("AlxNet Update - install the latest signed release")
+	fmt.Println("")
+	fmt.Println("Usage: alxnet update [options]")
+	fmt.Println("")
+	fmt.Println("Fetches the release channel's manifest, checks it is signed by the release")
+	fmt.Println("publisher key built into this binary, downloads the binary for this platform,")
+	fmt.Println("checks its SHA-256 and swaps it for the running one. The new binary must start")
+	fmt.Println("and report its version, or the old one is put back. The replaced binary is kept")
+	fmt.Println("next to it with a .old suffix. Restart the node afterwards to run the release.")
+	fmt.Println("")
+	fmt.Println("Options:")
+	fmt.Println("  -check            Only report whether a newer release is available")
+	fmt.Println("  -url URL          Release channel URL (default: built in, or ALXNET_UPDATE_URL)")
+	fmt.Println("  -channel NAME     Channel the manifest must be signed for (default: built in, or")
+	fmt.Println("                    ALXNET_UPDATE_CHANNEL)")
+	fmt.Println("  -force            Install the channel's release even if it is not newer")
+	fmt.Println("  -rollback         Put back the binary the last update replaced")
+	fmt.Println("")
+	fmt.Println("Exits with status 1 on failure; with -check, also when an update is available.")
+}
+
+func cmdVersion() {
+	fmt.Printf("alxnet %s (protocol %d, %s/%s)\n", version, p2p.ProtocolVersion, runtime.GOOS, runtime.GOARCH)
+}
+
+func cmdUpdate(args []string) {
+	fs := flag.NewFlagSet("update", flag.ExitOnError)
+	fs.Usage = updateUsage
+	check := fs.Bool("check", false, "only report whether a newer release is available")
+	channelURL := fs.String("url", "", "release channel URL")
+	channel := fs.String("channel", "", "channel the manifest must be signed for")
+	force := fs.Bool("force", false, "install even if the release is not newer")
+	rollback := fs.Bool("rollback", false, "put back the binary the last update replaced")
+	_ = fs.Parse(args)
+
+	exe, err := os.Executable()
+	if err == nil {
+		exe, err = filepath.EvalSymlinks(exe)
+	}
+	if err != nil {
+		log.Fatalf("update: cannot find the running binary: %v", err)
+	}
+	if *rollback {
+		if err := release.Rollback(exe); err != nil {
+			log.Fatalf("update: %v", err)
+		}
+		fmt.Printf("Rolled back %s; restart the node to run it.\n", exe)
+		return
+	}
+
+	url := *channelURL
+	if url == "" {
+		url = os.Getenv("ALXNET_UPDATE_URL")
+	}
+	if url == "" {
+		url = release.ChannelURL
+	}
+	if url == "" {
+		log.Fatal("update: no release channel; pass -url or set ALXNET_UPDATE_URL")
+	}
+	name := *channel
+	if name == "" {
+		name = os.Getenv("ALXNET_UPDATE_CHANNEL")
+	}
+	if name == "" {
+		name = release.Channel
+	}
+	pub, err := release.Publisher()
+	if err != nil {
+		log.Fatalf("update: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+	client := &http.Client{}
+	m, err := fetchRelease(ctx, client, url, pub, name)
+	if err != nil {
+		log.Fatalf("update: %v", err)
+	}
+	newer, err := release.Newer(m.Version, version)
+	if err != nil {
+		log.Fatalf("update: %v", err)
+	}
+	fmt.Printf("running: %s\n", version)
+	fmt.Printf("release: %s (%s, %s)\n", m.Version, m.Channel, m.Released.Format("2006-01-02"))
+	if m.Notes != "" {
+		fmt.Printf("notes:   %s\n", m.Notes)
+	}
+	if !newer && !*force {
+		fmt.Println("Up to date.")
+		return
+	}
+	if *check {
+		fmt.Println("An update is available; run `alxnet update` to install it.")
+		os.Exit(1)
+	}
+
+	b, ok := m.Binary(runtime.GOOS, runtime.GOARCH)
+	if !ok {
+		log.Fatalf("update: release %s has no binary for %s/%s", m.Version, runtime.GOOS, runtime.GOARCH)
+	}
+	fmt.Printf("Downloading %s (%d bytes)...\n", b.URL, b.Size)
+	path, err := release.Download(ctx, client, b, filepath.Dir(exe))
+	if err != nil {
+		log.Fatalf("update: %v", err)
+	}
+	defer os.Remove(path)
+	if err := release.Install(exe, path, func(exe string) error { return checkBinary(exe, m.Version) }); err != nil {
+		log.Fatalf("update: %v", err)
+	}
+	fmt.Printf("Installed %s as %s; the previous binary is %s.\n", m.Version, exe, exe+release.PreviousSuffix)
+	fmt.Println("Restart the node to run it; `alxnet update -rollback` puts the previous one back.")
+}
+
+// fetchRelease downloads and verifies the signed manifest of channel at url.
+func fetchRelease(ctx context.Context, client *http.Client, url string, pub []byte, channel string) (*release.Manifest, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("release channel returned %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, release.MaxManifestSize+1))
+	if err != nil {
+		return nil, err
+	}
+	return release.Open(data, pub, channel)
+}
+
+// checkBinary runs `exe version` and checks it reports want.
+func checkBinary(exe, want string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, exe, "version").Output()
+	if err != nil {
+		return err
+	}
+	if !bytes.Contains(out, []byte(" "+strings.TrimPrefix(want, "v")+" ")) {
+		return fmt.Errorf("it reports %q, not version %s", strings.TrimSpace(string(out)), want)
+	}
+	return nil
+}
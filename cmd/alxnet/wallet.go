@@ -0,0 +1,557 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"alxnet/internal/core"
+	"alxnet/internal/p2p"
+	"alxnet/internal/wallet"
+)
+
+func walletUsage() {
+	fmt.Println("AlxNet Wallet - offline wallet maintenance")
+	fmt.Println("")
+	fmt.Println("Usage: alxnet wallet <command> [options]")
+	fmt.Println("")
+	fmt.Println("Commands:")
+	fmt.Println("  info      Show the encryption parameters of a wallet file")
+	fmt.Println("  list      List the wallet's sites with their head, names and replication")
+	fmt.Println("  upgrade   Re-encrypt a wallet file with stronger KDF parameters")
+	fmt.Println("  stats     Show replication stats for the wallet's sites from a running node")
+	fmt.Println("  delete    Sign a delete record for a site record and/or content and broadcast it")
+	fmt.Println("")
+	fmt.Println("The mnemonic is read from -mnemonic, ALXNET_MNEMONIC, or stdin. Wallets created")
+	fmt.Println("with a BIP-39 passphrase also need -passphrase or ALXNET_MNEMONIC_PASSPHRASE.")
+	fmt.Println("")
+	fmt.Println("Examples:")
+	fmt.Println("  alxnet wallet info -file ./data/wallets/main.wallet")
+	fmt.Println("  alxnet wallet list -file main.wallet -format table")
+	fmt.Println("  alxnet wallet upgrade -file ./data/wallets/main.wallet")
+	fmt.Println("  alxnet wallet upgrade -file main.wallet -kdf scrypt -n 18")
+	fmt.Println("  alxnet wallet stats -file main.wallet -api http://localhost:8081")
+	fmt.Println("  alxnet wallet delete -file main.wallet -label mysite -record <cid> [-content <cid>]")
+}
+
+func cmdWallet(args []string) {
+	if len(args) < 1 {
+		walletUsage()
+		return
+	}
+
+	var err error
+	switch args[0] {
+	case "info":
+		err = walletInfo(args[1:])
+	case "list":
+		err = walletList(args[1:])
+	case "upgrade":
+		err = walletUpgrade(args[1:])
+	case "stats":
+		err = walletStats(args[1:])
+	case "delete":
+		err = walletDelete(args[1:])
+	default:
+		walletUsage()
+		return
+	}
+	if err != nil {
+		log.Fatalf("wallet %s: %v", args[0], err)
+	}
+}
+
+func walletInfo(args []string) error {
+	fs := flag.NewFlagSet("wallet info", flag.ExitOnError)
+	file := fs.String("file", "", "encrypted wallet file")
+	_ = fs.Parse(args)
+
+	if *file == "" {
+		return errors.New("-file is required")
+	}
+	enc, err := wallet.Load(*file)
+	if err != nil {
+		return err
+	}
+	params, err := wallet.WalletKDFParams(enc)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("file: %s\n", *file)
+	fmt.Printf("kdf:  %s\n", params)
+	if err := params.Validate(); err != nil {
+		fmt.Printf("status: unsupported (%v)\n", err)
+	} else if params.WeakerThan(wallet.DefaultKDFParams()) {
+		fmt.Printf("status: weaker than current default %s, run `alxnet wallet upgrade`\n", wallet.DefaultKDFParams())
+	} else {
+		fmt.Println("status: up to date")
+	}
+	return nil
+}
+
+// siteStatus is one site of `alxnet wallet list`. The fields after SiteID
+// come from a node and stay empty when none is reachable.
+type siteStatus struct {
+	Label         string    `json:"label"`
+	SiteID        string    `json:"site_id"`
+	Seq           uint64    `json:"seq,omitempty"`
+	HeadCID       string    `json:"head_cid,omitempty"`
+	ContentCID    string    `json:"content_cid,omitempty"`
+	Domains       []string  `json:"domains"`
+	LastPublished time.Time `json:"last_published"`     // by the node, else the wallet's last update
+	Replicas      *int      `json:"replicas,omitempty"` // peers holding the latest head
+	Error         string    `json:"error,omitempty"`
+}
+
+func walletList(args []string) error {
+	fs := flag.NewFlagSet("wallet list", flag.ExitOnError)
+	file := fs.String("file", "", "encrypted wallet file")
+	mnemonic := fs.String("mnemonic", "", "wallet mnemonic")
+	passphrase := fs.String("passphrase", os.Getenv("ALXNET_MNEMONIC_PASSPHRASE"), "optional BIP-39 passphrase (25th word)")
+	api := fs.String("api", "http://localhost:8081", "wallet server to ask for heads, names and replication; empty to list offline")
+	format := fs.String("format", "text", "output format: text, table or json")
+	_ = fs.Parse(args)
+
+	if *file == "" {
+		return errors.New("-file is required")
+	}
+	if *format != "text" && *format != "table" && *format != "json" {
+		return fmt.Errorf("unsupported format: %s", *format)
+	}
+	enc, err := wallet.Load(*file)
+	if err != nil {
+		return err
+	}
+	phrase, err := readMnemonic(*mnemonic)
+	if err != nil {
+		return err
+	}
+	w, err := wallet.DecryptWallet(enc, phrase, *passphrase)
+	if err != nil {
+		return err
+	}
+
+	sites := make([]*siteStatus, 0, len(w.Sites))
+	byID := make(map[string]*siteStatus, len(w.Sites))
+	for label, meta := range w.Sites {
+		st := &siteStatus{Label: label, SiteID: meta.SiteID, Domains: []string{}, LastPublished: meta.LastUpdated}
+		sites = append(sites, st)
+		byID[meta.SiteID] = st
+	}
+	sort.Slice(sites, func(i, j int) bool { return sites[i].Label < sites[j].Label })
+
+	if *api != "" && len(sites) > 0 {
+		if err := addNodeStatus(*api, w, byID); err != nil {
+			fmt.Fprintf(os.Stderr, "node at %s not reachable, listing the wallet only: %v\n", *api, err)
+		}
+	}
+
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(sites)
+	case "table":
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "LABEL\tSEQ\tHEAD\tCONTENT\tNAMES\tPUBLISHED\tREPLICAS\tSITE ID")
+		for _, st := range sites {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", st.Label, formatSeq(st.Seq), shortCID(st.HeadCID),
+				shortCID(st.ContentCID), strings.Join(st.Domains, ","), formatStatTime(st.LastPublished), formatReplicas(st.Replicas), st.SiteID)
+		}
+		return tw.Flush()
+	}
+	if len(sites) == 0 {
+		fmt.Println("wallet has no sites")
+	}
+	for _, st := range sites {
+		fmt.Printf("%s (%s)\n", st.Label, st.SiteID)
+		if st.Error != "" {
+			fmt.Printf("  status:         %s\n", st.Error)
+		}
+		if st.HeadCID != "" {
+			fmt.Printf("  head:           seq %d, %s\n", st.Seq, st.HeadCID)
+			fmt.Printf("  content:        %s\n", st.ContentCID)
+		}
+		if len(st.Domains) > 0 {
+			fmt.Printf("  names:          %s\n", strings.Join(st.Domains, ", "))
+		}
+		fmt.Printf("  last published: %s\n", formatStatTime(st.LastPublished))
+		if st.Replicas != nil {
+			fmt.Printf("  replicas:       %d\n", *st.Replicas)
+		}
+	}
+	return nil
+}
+
+// addNodeStatus fills in the heads, names and replication of the sites in
+// byID from the wallet server at api.
+func addNodeStatus(api string, w *wallet.Wallet, byID map[string]*siteStatus) error {
+	stats, err := fetchSiteStats(api, w)
+	if err != nil {
+		return err
+	}
+	for _, stat := range stats {
+		st := byID[stat.SiteID]
+		if st == nil {
+			continue
+		}
+		if stat.Error != "" {
+			st.Error = stat.Error
+			continue
+		}
+		replicas := stat.AckedPeers
+		st.Seq, st.HeadCID, st.ContentCID, st.Replicas = stat.Seq, stat.HeadCID, stat.ContentCID, &replicas
+		if !stat.LastPublished.IsZero() {
+			st.LastPublished = stat.LastPublished
+		}
+	}
+
+	siteIDs := make([]string, 0, len(byID))
+	for siteID := range byID {
+		siteIDs = append(siteIDs, siteID)
+	}
+	var names struct {
+		Domains map[string]string `json:"domains"`
+	}
+	if err := walletAPI(api, http.MethodPost, "/api/domains/list-wallet", map[string][]string{"site_ids": siteIDs}, &names); err != nil {
+		return err
+	}
+	for domain, siteID := range names.Domains {
+		if st := byID[siteID]; st != nil {
+			st.Domains = append(st.Domains, domain)
+		}
+	}
+	for _, st := range byID {
+		sort.Strings(st.Domains)
+	}
+	return nil
+}
+
+func formatSeq(seq uint64) string {
+	if seq == 0 {
+		return "-"
+	}
+	return strconv.FormatUint(seq, 10)
+}
+
+func formatReplicas(n *int) string {
+	if n == nil {
+		return "-"
+	}
+	return strconv.Itoa(*n)
+}
+
+// shortCID abbreviates a CID for the table.
+func shortCID(cid string) string {
+	if cid == "" {
+		return "-"
+	}
+	if len(cid) > 12 {
+		return cid[:12] + "…"
+	}
+	return cid
+}
+
+func walletUpgrade(args []string) error {
+	defaults := wallet.DefaultKDFParams()
+	scryptDefaults := wallet.DefaultScryptParams()
+
+	fs := flag.NewFlagSet("wallet upgrade", flag.ExitOnError)
+	file := fs.String("file", "", "encrypted wallet file to upgrade in place")
+	mnemonic := fs.String("mnemonic", "", "wallet mnemonic")
+	passphrase := fs.String("passphrase", os.Getenv("ALXNET_MNEMONIC_PASSPHRASE"), "optional BIP-39 passphrase (25th word)")
+	kdf := fs.String("kdf", defaults.KDF, "key derivation function: argon2id or scrypt")
+	t := fs.Uint("t", uint(defaults.Time), "argon2id iterations")
+	m := fs.Uint("m", uint(defaults.MemoryMiB), "argon2id memory in MiB")
+	p := fs.Uint("p", 0, "parallelism (default depends on -kdf)")
+	n := fs.Uint("n", uint(scryptDefaults.LogN), "scrypt cost as log2(N)")
+	r := fs.Uint("r", uint(scryptDefaults.R), "scrypt block size")
+	force := fs.Bool("force", false, "re-encrypt even if the wallet already meets the target parameters")
+	_ = fs.Parse(args)
+
+	if *file == "" {
+		return errors.New("-file is required")
+	}
+
+	var params wallet.KDFParams
+	switch *kdf {
+	case wallet.KDFArgon2id:
+		params = wallet.KDFParams{KDF: wallet.KDFArgon2id, Time: uint32(*t), MemoryMiB: uint32(*m), Threads: defaults.Threads}
+	case wallet.KDFScrypt:
+		params = wallet.KDFParams{KDF: wallet.KDFScrypt, LogN: uint8(*n), R: uint32(*r), Threads: scryptDefaults.Threads}
+	default:
+		return fmt.Errorf("unsupported kdf: %s", *kdf)
+	}
+	if *p != 0 {
+		if *p > 255 {
+			return fmt.Errorf("parallelism out of range: %d", *p)
+		}
+		params.Threads = uint8(*p)
+	}
+	if err := params.Validate(); err != nil {
+		return err
+	}
+
+	enc, err := wallet.Load(*file)
+	if err != nil {
+		return err
+	}
+	current, err := wallet.WalletKDFParams(enc)
+	if err != nil {
+		return err
+	}
+	if !*force && !current.WeakerThan(params) {
+		fmt.Printf("wallet already uses %s, nothing to do (use -force to re-encrypt anyway)\n", current)
+		return nil
+	}
+
+	phrase, err := readMnemonic(*mnemonic)
+	if err != nil {
+		return err
+	}
+	upgraded, err := wallet.UpgradeWallet(enc, phrase, *passphrase, params)
+	if err != nil {
+		return err
+	}
+
+	// Keep the previous file until the new one is written.
+	backup := *file + ".bak"
+	if err := wallet.Save(backup, enc); err != nil {
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+	if err := wallet.Save(*file, upgraded); err != nil {
+		return fmt.Errorf("failed to write upgraded wallet (backup kept at %s): %w", backup, err)
+	}
+	if err := os.Remove(backup); err != nil {
+		log.Printf("Failed to remove backup %s: %v", backup, err)
+	}
+
+	fmt.Printf("upgraded %s: %s -> %s\n", *file, current, params)
+	return nil
+}
+
+func walletStats(args []string) error {
+	fs := flag.NewFlagSet("wallet stats", flag.ExitOnError)
+	file := fs.String("file", "", "encrypted wallet file")
+	mnemonic := fs.String("mnemonic", "", "wallet mnemonic")
+	passphrase := fs.String("passphrase", os.Getenv("ALXNET_MNEMONIC_PASSPHRASE"), "optional BIP-39 passphrase (25th word)")
+	api := fs.String("api", "http://localhost:8081", "wallet server of the node that publishes the sites")
+	_ = fs.Parse(args)
+
+	if *file == "" {
+		return errors.New("-file is required")
+	}
+	enc, err := wallet.Load(*file)
+	if err != nil {
+		return err
+	}
+	phrase, err := readMnemonic(*mnemonic)
+	if err != nil {
+		return err
+	}
+	w, err := wallet.DecryptWallet(enc, phrase, *passphrase)
+	if err != nil {
+		return err
+	}
+	if len(w.Sites) == 0 {
+		fmt.Println("wallet has no sites")
+		return nil
+	}
+
+	labels := make(map[string]string, len(w.Sites))
+	for label, meta := range w.Sites {
+		labels[meta.SiteID] = label
+	}
+	stats, err := fetchSiteStats(*api, w)
+	if err != nil {
+		return err
+	}
+
+	for _, st := range stats {
+		fmt.Printf("%s (%s)\n", labels[st.SiteID], st.SiteID)
+		if st.Error != "" {
+			fmt.Printf("  status:         %s\n", st.Error)
+			continue
+		}
+		fmt.Printf("  head:           seq %d, %s\n", st.Seq, st.HeadCID)
+		fmt.Printf("  acked peers:    %d\n", st.AckedPeers)
+		fmt.Printf("  providers:      %d of %d queried\n", st.Providers, st.QueriedPeers)
+		fmt.Printf("  last published: %s\n", formatStatTime(st.LastPublished))
+		fmt.Printf("  last ack:       %s\n", formatStatTime(st.LastAck))
+	}
+	return nil
+}
+
+func walletDelete(args []string) error {
+	fs := flag.NewFlagSet("wallet delete", flag.ExitOnError)
+	file := fs.String("file", "", "encrypted wallet file")
+	label := fs.String("label", "", "site label in the wallet")
+	record := fs.String("record", "", "CID of the update record to delete")
+	content := fs.String("content", "", "CID of the content to delete")
+	mnemonic := fs.String("mnemonic", "", "wallet mnemonic")
+	passphrase := fs.String("passphrase", os.Getenv("ALXNET_MNEMONIC_PASSPHRASE"), "optional BIP-39 passphrase (25th word)")
+	api := fs.String("api", "http://localhost:8081", "wallet server of the node that broadcasts the delete")
+	_ = fs.Parse(args)
+
+	if *file == "" || *label == "" {
+		return errors.New("-file and -label are required")
+	}
+	if *record == "" && *content == "" {
+		return errors.New("-record or -content is required")
+	}
+	enc, err := wallet.Load(*file)
+	if err != nil {
+		return err
+	}
+	phrase, err := readMnemonic(*mnemonic)
+	if err != nil {
+		return err
+	}
+	w, err := wallet.DecryptWallet(enc, phrase, *passphrase)
+	if err != nil {
+		return err
+	}
+	if _, ok := w.Sites[*label]; !ok {
+		return fmt.Errorf("no site labelled %q in the wallet", *label)
+	}
+	master, err := wallet.MasterKeyFromMnemonic(phrase, *passphrase)
+	if err != nil {
+		return err
+	}
+	meta, _, priv, err := w.EnsureSite(master, *label)
+	if err != nil {
+		return err
+	}
+
+	// Signed here; the node only relays the record
+	del, err := p2p.BuildDelete(priv, *record, *content)
+	if err != nil {
+		return err
+	}
+	recBytes, err := core.MarshalCanonical(del)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(map[string][]byte{"record": recBytes})
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Post(strings.TrimRight(*api, "/")+"/api/wallet/delete", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("node returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	fmt.Printf("site:    %s (%s)\n", *label, meta.SiteID)
+	if del.TargetRec != "" {
+		fmt.Printf("record:  %s\n", del.TargetRec)
+	}
+	if del.TargetCont != "" {
+		fmt.Printf("content: %s\n", del.TargetCont)
+	}
+	fmt.Println("delete record broadcast")
+	return nil
+}
+
+// siteStat is the replication of one site as reported by a node.
+type siteStat struct {
+	p2p.SiteStats
+	Error string `json:"error"`
+}
+
+// fetchSiteStats asks the wallet server at api for the replication stats of
+// every site of w.
+func fetchSiteStats(api string, w *wallet.Wallet) ([]siteStat, error) {
+	query := url.Values{}
+	for _, meta := range w.Sites {
+		query.Add("site_id", meta.SiteID)
+	}
+	var result struct {
+		Stats []siteStat `json:"stats"`
+	}
+	if err := walletAPI(api, http.MethodGet, "/api/wallet/site-stats?"+query.Encode(), nil, &result); err != nil {
+		return nil, err
+	}
+	return result.Stats, nil
+}
+
+// walletAPI calls a wallet server endpoint and decodes its JSON answer into
+// out.
+func walletAPI(api, method, path string, body interface{}, out interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, strings.TrimRight(api, "/")+path, reqBody)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("node returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("invalid response: %w", err)
+	}
+	return nil
+}
+
+func formatStatTime(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return t.Local().Format(time.RFC3339)
+}
+
+// readMnemonic returns the mnemonic from the flag value, the environment,
+// or the first line of stdin, in that order.
+func readMnemonic(flagValue string) (string, error) {
+	if flagValue != "" {
+		return flagValue, nil
+	}
+	if v := os.Getenv("ALXNET_MNEMONIC"); v != "" {
+		return v, nil
+	}
+	fmt.Fprint(os.Stderr, "Mnemonic: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("failed to read mnemonic: %w", err)
+	}
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return "", errors.New("mnemonic is required")
+	}
+	return line, nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"alxnet/internal/wallet"
+)
+
+func TestAddNodeStatus(t *testing.T) {
+	blog, shop := strings.Repeat("aa", 32), strings.Repeat("bb", 32)
+	published := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/wallet/site-stats":
+			json.NewEncoder(w).Encode(map[string]interface{}{"stats": []interface{}{
+				map[string]interface{}{"site_id": blog, "seq": 4, "head_cid": "head", "content_cid": "content", "acked_peers": 3, "last_published": published},
+				map[string]interface{}{"site_id": shop, "error": "site has no local head"},
+			}})
+		case "/api/domains/list-wallet":
+			json.NewEncoder(w).Encode(map[string]interface{}{"domains": map[string]string{"myblog": blog, "blog2": blog, "other": strings.Repeat("cc", 32)}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	w := wallet.New()
+	w.Sites["blog"] = &wallet.SiteMeta{Label: "blog", SiteID: blog}
+	w.Sites["shop"] = &wallet.SiteMeta{Label: "shop", SiteID: shop}
+	byID := map[string]*siteStatus{
+		blog: {Label: "blog", SiteID: blog, Domains: []string{}},
+		shop: {Label: "shop", SiteID: shop, Domains: []string{}},
+	}
+	if err := addNodeStatus(srv.URL, w, byID); err != nil {
+		t.Fatalf("addNodeStatus() error = %v", err)
+	}
+
+	got := byID[blog]
+	if got.Seq != 4 || got.HeadCID != "head" || got.ContentCID != "content" || got.Replicas == nil || *got.Replicas != 3 ||
+		!got.LastPublished.Equal(published) || strings.Join(got.Domains, ",") != "blog2,myblog" {
+		t.Errorf("blog = %+v", got)
+	}
+	if got := byID[shop]; got.Error == "" || got.Replicas != nil || len(got.Domains) != 0 {
+		t.Errorf("shop = %+v, want the node's error only", got)
+	}
+
+	if err := addNodeStatus("http://127.0.0.1:1", w, byID); err == nil {
+		t.Error("addNodeStatus() of an unreachable node succeeded")
+	}
+}
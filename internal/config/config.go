@@ -3,11 +3,19 @@ package config
 import (
 	"errors"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
+	"alxnet/internal/core"
+	"alxnet/internal/store"
+
+	ma "github.com/multiformats/go-multiaddr"
 	"gopkg.in/yaml.v3"
 )
 
@@ -17,6 +25,9 @@ type Config struct {
 	Environment string `yaml:"environment"` // "development", "staging", "production"
 	LogLevel    string `yaml:"log_level"`   // "debug", "info", "warn", "error"
 
+	// Log encoding and destinations
+	Logging LoggingConfig `yaml:"logging"`
+
 	// Network configuration
 	Network NetworkConfig `yaml:"network"`
 
@@ -31,17 +42,72 @@ type Config struct {
 
 	// Node configuration
 	Node NodeConfig `yaml:"node"`
+
+	// Theme and branding of the web interfaces
+	UI UIConfig `yaml:"ui"`
+
+	// Sitemap serving and crawling for search
+	Search SearchConfig `yaml:"search"`
+
+	// Following a leader node as a hot standby
+	Replication ReplicationConfig `yaml:"replication"`
+
+	// URLs told about site events
+	Webhooks []WebhookConfig `yaml:"webhooks"`
+
+	// Pushing node statistics to InfluxDB or Graphite
+	StatsExport StatsExportConfig `yaml:"stats_export"`
+}
+
+// LoggingConfig selects the log encoder and where logs go
+type LoggingConfig struct {
+	Format     string        `yaml:"format"`      // "console" or "json"
+	Outputs    []string      `yaml:"outputs"`     // "stderr", "stdout" or file paths
+	MaxSizeMB  int           `yaml:"max_size_mb"` // rotate a log file at this size
+	MaxAge     time.Duration `yaml:"max_age"`     // delete rotated files older than this, 0 keeps them
+	MaxBackups int           `yaml:"max_backups"` // rotated files kept per log, 0 keeps all
+
+	// Destinations for the audit stream of rejected messages and requests;
+	// empty sends it to Outputs
+	AuditOutputs []string `yaml:"audit_outputs"`
 }
 
 // NetworkConfig holds network-related settings
 type NetworkConfig struct {
 	ListenAddr     string        `yaml:"listen_addr"`
+	ListenAddrs    []string      `yaml:"listen_addrs"` // P2P multiaddrs to listen on, empty for IPv4 and IPv6 at the node port
+	Interfaces     []string      `yaml:"interfaces"`   // network interfaces to listen on, empty for all
 	BootstrapPeers []string      `yaml:"bootstrap_peers"`
 	MaxPeers       int           `yaml:"max_peers"`
 	PeerTimeout    time.Duration `yaml:"peer_timeout"`
 	EnableMDNS     bool          `yaml:"enable_mdns"`
 	EnableNAT      bool          `yaml:"enable_nat"`
 	EnableRelay    bool          `yaml:"enable_relay"`
+	NTPServer      string        `yaml:"ntp_server"`   // checked against the local clock at start, "" disables
+	SOCKS5Proxy    string        `yaml:"socks5_proxy"` // host:port P2P connections go through, such as Tor's; "" dials directly
+	SwarmKey       string        `yaml:"swarm_key"`    // private network key file; "" uses swarm.key in the data directory if present
+	Onion          OnionConfig   `yaml:"onion"`
+	Fetch          FetchConfig   `yaml:"fetch"`
+
+	// Activations move scheduled protocol rule changes, by feature, to
+	// another RFC 3339 time or "never". For private and test networks only.
+	Activations map[string]string `yaml:"activations"`
+}
+
+// FetchConfig divides the node's requests to peers between page loads and
+// background work
+type FetchConfig struct {
+	Slots       int `yaml:"slots"`       // requests for content and update records in flight at once
+	Interactive int `yaml:"interactive"` // percent of slots page loads and embedded fetches may hold
+	Background  int `yaml:"background"`  // percent of slots sync, re-seeding, erasure repair and crawling may hold
+}
+
+// OnionConfig publishes the node as a Tor onion service
+type OnionConfig struct {
+	Enabled         bool   `yaml:"enabled"`
+	ControlAddr     string `yaml:"control_addr"`     // Tor's control port, "" for 127.0.0.1:9051
+	ControlPassword string `yaml:"control_password"` // "" authenticates with Tor's cookie file
+	Port            int    `yaml:"port"`             // onion service port, 0 for the node's TCP port
 }
 
 // SecurityConfig holds security-related settings
@@ -56,17 +122,56 @@ type SecurityConfig struct {
 	RequireStrongPassphrase bool          `yaml:"require_strong_passphrase"`
 	MaxLoginAttempts        int           `yaml:"max_login_attempts"`
 	SessionTimeout          time.Duration `yaml:"session_timeout"`
+	MaxClockSkew            time.Duration `yaml:"max_clock_skew"`    // how far in the future record timestamps may be
+	RequireAPIToken         bool          `yaml:"require_api_token"` // every wallet and node API endpoint needs a token
+	APIRateLimit            int           `yaml:"api_rate_limit"`    // HTTP API requests per minute per client address
+	AllowedHosts            []string      `yaml:"allowed_hosts"`     // host names, besides IPs and localhost, the UIs are reached by
 }
 
 // StorageConfig holds storage-related settings
 type StorageConfig struct {
-	DataDir           string        `yaml:"data_dir"`
-	MaxFileSize       int64         `yaml:"max_file_size"`
-	CleanupInterval   time.Duration `yaml:"cleanup_interval"`
-	MaxRetries        int           `yaml:"max_retries"`
-	RetryDelay        time.Duration `yaml:"retry_delay"`
-	EnableCompression bool          `yaml:"enable_compression"`
-	EnableEncryption  bool          `yaml:"enable_encryption"`
+	DataDir           string          `yaml:"data_dir"`
+	MaxFileSize       int64           `yaml:"max_file_size"`
+	CleanupInterval   time.Duration   `yaml:"cleanup_interval"`
+	MaxRetries        int             `yaml:"max_retries"`
+	RetryDelay        time.Duration   `yaml:"retry_delay"`
+	EnableCompression bool            `yaml:"enable_compression"`
+	EnableEncryption  bool            `yaml:"enable_encryption"`
+	ContentCacheSize  int64           `yaml:"content_cache_size"` // bytes of content kept in memory, 0 disables
+	ShardQuota        int64           `yaml:"shard_quota"`        // bytes of erasure shards held for other nodes, 0 disables
+	SnapshotDir       string          `yaml:"snapshot_dir"`       // store snapshots for read-only replicas, "" disables
+	SnapshotInterval  time.Duration   `yaml:"snapshot_interval"`
+	Retention         RetentionConfig `yaml:"retention"`
+	Blob              BlobConfig      `yaml:"blob"`
+	Badger            BadgerConfig    `yaml:"badger"`
+}
+
+// BadgerConfig tunes the database for how the node is used
+type BadgerConfig struct {
+	Profile      string `yaml:"profile"`        // "", "seeder", "gateway" or "laptop"
+	ValueLogSize int64  `yaml:"value_log_size"` // bytes per value log file, 0 for the profile's
+	MemTableSize int64  `yaml:"memtable_size"`  // bytes per memtable, 0 for the profile's
+	Compression  string `yaml:"compression"`    // block compression: "none", "snappy" or "zstd"; "" for the profile's
+}
+
+// RetentionConfig sets how much history of stored sites is kept
+type RetentionConfig struct {
+	Default  core.RetentionPolicy `yaml:"default"`  // sites not pinned: "full", "heads" or "last:N"
+	Pinned   core.RetentionPolicy `yaml:"pinned"`   // pinned sites without a policy of their own
+	Interval time.Duration        `yaml:"interval"` // time between retention runs
+}
+
+// BlobConfig selects an optional backend for large content blobs
+type BlobConfig struct {
+	Backend           string        `yaml:"backend"`   // "" (Badger only) or "s3"
+	Threshold         int           `yaml:"threshold"` // bytes; content at least this large is offloaded
+	CacheTTL          time.Duration `yaml:"cache_ttl"` // lifetime of local copies of offloaded blobs
+	S3Endpoint        string        `yaml:"s3_endpoint"`
+	S3Region          string        `yaml:"s3_region"`
+	S3Bucket          string        `yaml:"s3_bucket"`
+	S3Prefix          string        `yaml:"s3_prefix"`
+	S3AccessKeyID     string        `yaml:"s3_access_key_id"`
+	S3SecretAccessKey string        `yaml:"s3_secret_access_key"`
 }
 
 // WalletConfig holds wallet-related settings
@@ -88,12 +193,63 @@ type NodeConfig struct {
 	GarbageCollection bool  `yaml:"garbage_collection"`
 }
 
+// UIConfig sets the look of the browser, wallet and node interfaces
+type UIConfig struct {
+	Theme     string `yaml:"theme"`      // "classic", "light", "dark", "auto" or a theme in assets_dir
+	Brand     string `yaml:"brand"`      // name shown in page titles and headers
+	LogoURL   string `yaml:"logo_url"`   // image shown in page headers, "" for none
+	Accent    string `yaml:"accent"`     // #rgb or #rrggbb button color, "" for the theme's
+	AssetsDir string `yaml:"assets_dir"` // files here replace the embedded pages, partials and static files
+
+	ReaderRemoteImages bool `yaml:"reader_remote_images"` // reader-mode pages load images from other hosts, revealing the reader's address
+}
+
+// SearchConfig controls the cooperative indexing protocol
+type SearchConfig struct {
+	Sitemap       bool          `yaml:"sitemap"`        // serve a signed list of the sites stored here
+	Indexer       bool          `yaml:"indexer"`        // crawl the sitemaps of peers into the search index
+	CrawlInterval time.Duration `yaml:"crawl_interval"` // time between crawls
+}
+
+// ReplicationConfig makes a node a follower that copies a leader node's
+// store and serves it read-only
+type ReplicationConfig struct {
+	Leader   string        `yaml:"leader"`   // node management URL of the leader, "" for none
+	Token    string        `yaml:"token"`    // the leader's admin token
+	Interval time.Duration `yaml:"interval"` // time between pulls
+}
+
+// WebhookConfig is one URL that node events are posted to, signed with
+// HMAC-SHA256 under Secret
+type WebhookConfig struct {
+	URL    string   `yaml:"url"`
+	Secret string   `yaml:"secret"`
+	Events []string `yaml:"events"` // event types to send, empty for all
+}
+
+// StatsExportConfig pushes the node's statistics to a metrics pipeline
+type StatsExportConfig struct {
+	Format   string        `yaml:"format"`   // "influx", "graphite" or "" for none
+	Endpoint string        `yaml:"endpoint"` // InfluxDB write URL or Graphite host:port
+	Token    string        `yaml:"token"`    // InfluxDB API token, "" for none
+	Prefix   string        `yaml:"prefix"`   // InfluxDB measurement or Graphite path prefix
+	Interval time.Duration `yaml:"interval"` // time between pushes
+}
+
 // DefaultConfig returns a configuration with sensible defaults
 func DefaultConfig() *Config {
 	return &Config{
 		Environment: "development",
 		LogLevel:    "info",
 
+		Logging: LoggingConfig{
+			Format:     "console",
+			Outputs:    []string{"stderr"},
+			MaxSizeMB:  100,
+			MaxAge:     7 * 24 * time.Hour,
+			MaxBackups: 5,
+		},
+
 		Network: NetworkConfig{
 			ListenAddr:     "/ip4/0.0.0.0/tcp/4001",
 			BootstrapPeers: []string{},
@@ -102,6 +258,12 @@ func DefaultConfig() *Config {
 			EnableMDNS:     true,
 			EnableNAT:      true,
 			EnableRelay:    false,
+			NTPServer:      "pool.ntp.org",
+			Fetch: FetchConfig{
+				Slots:       16,
+				Interactive: 100,
+				Background:  25,
+			},
 		},
 
 		Security: SecurityConfig{
@@ -115,6 +277,8 @@ func DefaultConfig() *Config {
 			RequireStrongPassphrase: true,
 			MaxLoginAttempts:        5,
 			SessionTimeout:          24 * time.Hour,
+			MaxClockSkew:            time.Hour,
+			APIRateLimit:            600,
 		},
 
 		Storage: StorageConfig{
@@ -125,6 +289,15 @@ func DefaultConfig() *Config {
 			RetryDelay:        100 * time.Millisecond,
 			EnableCompression: true,
 			EnableEncryption:  true,
+			ContentCacheSize:  64 * 1024 * 1024, // 64MB
+			SnapshotInterval:  15 * time.Minute,
+			Retention: RetentionConfig{
+				Interval: time.Hour,
+			},
+			Blob: BlobConfig{
+				Threshold: 1024 * 1024, // 1MB
+				CacheTTL:  time.Hour,
+			},
 		},
 
 		Wallet: WalletConfig{
@@ -143,6 +316,24 @@ func DefaultConfig() *Config {
 			MaxMemoryUsage:    100 * 1024 * 1024, // 100MB
 			GarbageCollection: true,
 		},
+
+		StatsExport: StatsExportConfig{
+			Prefix:   "alxnet",
+			Interval: time.Minute,
+		},
+
+		UI: UIConfig{
+			Theme: "classic",
+			Brand: "AlxNet",
+		},
+
+		Search: SearchConfig{
+			CrawlInterval: 30 * time.Minute,
+		},
+
+		Replication: ReplicationConfig{
+			Interval: 5 * time.Second,
+		},
 	}
 }
 
@@ -165,38 +356,178 @@ func LoadFromFile(path string) (*Config, error) {
 	return config, nil
 }
 
+// Load reads the YAML file at path, if path is not empty, applies ALXNET_*
+// environment overrides on top and validates the result. Nodes call it on
+// start and again for every configuration reload.
+func Load(path string) (*Config, error) {
+	config := DefaultConfig()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		if err := yaml.Unmarshal(data, config); err != nil {
+			return nil, fmt.Errorf("failed to parse config file: %w", err)
+		}
+	}
+	config.applyEnvironment()
+
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return config, nil
+}
+
 // LoadFromEnvironment loads configuration from environment variables
 func LoadFromEnvironment() *Config {
 	config := DefaultConfig()
+	config.applyEnvironment()
+	return config
+}
 
+// applyEnvironment overrides c with ALXNET_* environment variables
+func (c *Config) applyEnvironment() {
 	// Override with ALXNET_* environment variables (no legacy prefixes)
 	if v := os.Getenv("ALXNET_ENV"); v != "" {
-		config.Environment = v
+		c.Environment = v
 	}
 	if v := os.Getenv("ALXNET_LOG_LEVEL"); v != "" {
-		config.LogLevel = v
+		c.LogLevel = v
+	}
+	if v := os.Getenv("ALXNET_LOG_FORMAT"); v != "" {
+		c.Logging.Format = v
+	}
+	if v := os.Getenv("ALXNET_LOG_OUTPUTS"); v != "" {
+		c.Logging.Outputs = strings.Split(v, ",")
+	}
+	if v := os.Getenv("ALXNET_AUDIT_OUTPUTS"); v != "" {
+		c.Logging.AuditOutputs = strings.Split(v, ",")
 	}
 	if v := os.Getenv("ALXNET_LISTEN_ADDR"); v != "" {
-		config.Network.ListenAddr = v
+		c.Network.ListenAddr = v
+	}
+	if v := os.Getenv("ALXNET_LISTEN_ADDRS"); v != "" {
+		c.Network.ListenAddrs = strings.Split(v, ",")
+	}
+	if v := os.Getenv("ALXNET_INTERFACES"); v != "" {
+		c.Network.Interfaces = strings.Split(v, ",")
 	}
 	if v := os.Getenv("ALXNET_BOOTSTRAP_PEERS"); v != "" {
-		config.Network.BootstrapPeers = strings.Split(v, ",")
+		c.Network.BootstrapPeers = strings.Split(v, ",")
 	}
 	if v := os.Getenv("ALXNET_MAX_PEERS"); v != "" {
 		if val, err := strconv.Atoi(v); err == nil {
-			config.Network.MaxPeers = val
+			c.Network.MaxPeers = val
+		}
+	}
+	if v := os.Getenv("ALXNET_NTP_SERVER"); v != "" {
+		c.Network.NTPServer = v
+	}
+	if v := os.Getenv("ALXNET_MAX_CLOCK_SKEW"); v != "" {
+		if val, err := time.ParseDuration(v); err == nil {
+			c.Security.MaxClockSkew = val
 		}
 	}
 	if v := os.Getenv("ALXNET_MAX_CONTENT_SIZE"); v != "" {
 		if val, err := strconv.ParseInt(v, 10, 64); err == nil {
-			config.Security.MaxContentSize = val
+			c.Security.MaxContentSize = val
 		}
 	}
 	if v := os.Getenv("ALXNET_DATA_DIR"); v != "" {
-		config.Storage.DataDir = v
+		c.Storage.DataDir = v
+	}
+	if v := os.Getenv("ALXNET_COMPRESSION"); v != "" {
+		if val, err := strconv.ParseBool(v); err == nil {
+			c.Storage.EnableCompression = val
+		}
+	}
+	if v := os.Getenv("ALXNET_CONTENT_CACHE_SIZE"); v != "" {
+		if val, err := strconv.ParseInt(v, 10, 64); err == nil {
+			c.Storage.ContentCacheSize = val
+		}
+	}
+	if v := os.Getenv("ALXNET_SHARD_QUOTA"); v != "" {
+		if val, err := strconv.ParseInt(v, 10, 64); err == nil {
+			c.Storage.ShardQuota = val
+		}
+	}
+	if v := os.Getenv("ALXNET_SNAPSHOT_DIR"); v != "" {
+		c.Storage.SnapshotDir = v
+	}
+	if v := os.Getenv("ALXNET_REPLICATION_LEADER"); v != "" {
+		c.Replication.Leader = v
+	}
+	if v := os.Getenv("ALXNET_REPLICATION_TOKEN"); v != "" {
+		c.Replication.Token = v
+	}
+	if v := os.Getenv("ALXNET_STATS_ENDPOINT"); v != "" {
+		c.StatsExport.Endpoint = v
+	}
+	if v := os.Getenv("ALXNET_STATS_TOKEN"); v != "" {
+		c.StatsExport.Token = v
+	}
+	if v := os.Getenv("ALXNET_UI_THEME"); v != "" {
+		c.UI.Theme = v
+	}
+	if v := os.Getenv("ALXNET_UI_BRAND"); v != "" {
+		c.UI.Brand = v
+	}
+	if v := os.Getenv("ALXNET_UI_ASSETS_DIR"); v != "" {
+		c.UI.AssetsDir = v
+	}
+	if v := os.Getenv("ALXNET_UI_READER_REMOTE_IMAGES"); v != "" {
+		if val, err := strconv.ParseBool(v); err == nil {
+			c.UI.ReaderRemoteImages = val
+		}
+	}
+	if v := os.Getenv("ALXNET_BLOB_BACKEND"); v != "" {
+		c.Storage.Blob.Backend = v
+	}
+	if v := os.Getenv("ALXNET_BLOB_THRESHOLD"); v != "" {
+		if val, err := strconv.Atoi(v); err == nil {
+			c.Storage.Blob.Threshold = val
+		}
+	}
+	if v := os.Getenv("ALXNET_BLOB_CACHE_TTL"); v != "" {
+		if val, err := time.ParseDuration(v); err == nil {
+			c.Storage.Blob.CacheTTL = val
+		}
+	}
+	if v := os.Getenv("ALXNET_API_RATE_LIMIT"); v != "" {
+		if val, err := strconv.Atoi(v); err == nil {
+			c.Security.APIRateLimit = val
+		}
+	}
+	if v := os.Getenv("ALXNET_REQUIRE_API_TOKEN"); v != "" {
+		if val, err := strconv.ParseBool(v); err == nil {
+			c.Security.RequireAPIToken = val
+		}
+	}
+	if v := os.Getenv("ALXNET_ALLOWED_HOSTS"); v != "" {
+		c.Security.AllowedHosts = strings.Split(v, ",")
+	}
+	if v := os.Getenv("ALXNET_ONION"); v != "" {
+		if val, err := strconv.ParseBool(v); err == nil {
+			c.Network.Onion.Enabled = val
+		}
+	}
+	for env, dst := range map[string]*string{
+		"ALXNET_SOCKS5_PROXY":         &c.Network.SOCKS5Proxy,
+		"ALXNET_SWARM_KEY":            &c.Network.SwarmKey,
+		"ALXNET_TOR_CONTROL_ADDR":     &c.Network.Onion.ControlAddr,
+		"ALXNET_TOR_CONTROL_PASSWORD": &c.Network.Onion.ControlPassword,
+		"ALXNET_STORAGE_PROFILE":      &c.Storage.Badger.Profile,
+		"ALXNET_S3_ENDPOINT":          &c.Storage.Blob.S3Endpoint,
+		"ALXNET_S3_REGION":            &c.Storage.Blob.S3Region,
+		"ALXNET_S3_BUCKET":            &c.Storage.Blob.S3Bucket,
+		"ALXNET_S3_PREFIX":            &c.Storage.Blob.S3Prefix,
+		"ALXNET_S3_ACCESS_KEY_ID":     &c.Storage.Blob.S3AccessKeyID,
+		"ALXNET_S3_SECRET_ACCESS_KEY": &c.Storage.Blob.S3SecretAccessKey,
+	} {
+		if v := os.Getenv(env); v != "" {
+			*dst = v
+		}
 	}
-
-	return config
 }
 
 // SaveToFile saves configuration to a YAML file
@@ -241,6 +572,11 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("invalid log level: %s (must be one of: %v)", c.LogLevel, validLevels)
 	}
 
+	// Validate logging configuration
+	if err := c.Logging.Validate(); err != nil {
+		return fmt.Errorf("logging config: %w", err)
+	}
+
 	// Validate network configuration
 	if err := c.Network.Validate(); err != nil {
 		return fmt.Errorf("network config: %w", err)
@@ -266,6 +602,70 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("node config: %w", err)
 	}
 
+	// Validate search configuration
+	if err := c.Search.Validate(); err != nil {
+		return fmt.Errorf("search config: %w", err)
+	}
+
+	// Validate interface configuration
+	if err := c.UI.Validate(); err != nil {
+		return fmt.Errorf("ui config: %w", err)
+	}
+
+	// Validate replication configuration
+	if err := c.Replication.Validate(); err != nil {
+		return fmt.Errorf("replication config: %w", err)
+	}
+
+	// Validate stats export configuration
+	if err := c.StatsExport.Validate(); err != nil {
+		return fmt.Errorf("stats export config: %w", err)
+	}
+
+	// Validate webhooks
+	for i := range c.Webhooks {
+		if err := c.Webhooks[i].Validate(); err != nil {
+			return fmt.Errorf("webhook %d: %w", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+// Validate performs validation of LoggingConfig
+func (lc *LoggingConfig) Validate() error {
+	if lc.Format != "console" && lc.Format != "json" {
+		return fmt.Errorf("invalid log format: %q (must be console or json)", lc.Format)
+	}
+	if len(lc.Outputs) == 0 {
+		return errors.New("at least one log output is required")
+	}
+	if len(lc.Outputs) > 8 {
+		return errors.New("too many log outputs (max 8)")
+	}
+	if len(lc.AuditOutputs) > 8 {
+		return errors.New("too many audit outputs (max 8)")
+	}
+	for _, out := range append(append([]string{}, lc.Outputs...), lc.AuditOutputs...) {
+		if strings.TrimSpace(out) == "" {
+			return errors.New("log output cannot be empty")
+		}
+	}
+	if lc.MaxSizeMB <= 0 {
+		return errors.New("max_size_mb must be positive")
+	}
+	if lc.MaxSizeMB > 10240 {
+		return errors.New("max_size_mb too high (max 10240)")
+	}
+	if lc.MaxAge < 0 {
+		return errors.New("max_age cannot be negative")
+	}
+	if lc.MaxBackups < 0 {
+		return errors.New("max_backups cannot be negative")
+	}
+	if lc.MaxBackups > 1000 {
+		return errors.New("max_backups too high (max 1000)")
+	}
 	return nil
 }
 
@@ -283,9 +683,70 @@ func (nc *NetworkConfig) Validate() error {
 	if nc.PeerTimeout > 5*time.Minute {
 		return errors.New("peer_timeout too high (max 5 minutes)")
 	}
+	for _, a := range nc.ListenAddrs {
+		if _, err := ma.NewMultiaddr(a); err != nil {
+			return fmt.Errorf("invalid listen_addrs entry %q: %w", a, err)
+		}
+	}
+	for _, name := range nc.Interfaces {
+		if name == "" {
+			return errors.New("interfaces entries cannot be empty")
+		}
+	}
+	if nc.SOCKS5Proxy != "" {
+		if _, port, err := net.SplitHostPort(nc.SOCKS5Proxy); err != nil || port == "" {
+			return fmt.Errorf("socks5_proxy %q must be host:port", nc.SOCKS5Proxy)
+		}
+	}
+	if nc.Fetch.Slots < 1 || nc.Fetch.Slots > 256 {
+		return fmt.Errorf("invalid fetch.slots: %d (must be between 1 and 256)", nc.Fetch.Slots)
+	}
+	if nc.Fetch.Interactive < 1 || nc.Fetch.Interactive > 100 {
+		return fmt.Errorf("invalid fetch.interactive: %d (must be a percentage between 1 and 100)", nc.Fetch.Interactive)
+	}
+	if nc.Fetch.Background < 1 || nc.Fetch.Background > 100 {
+		return fmt.Errorf("invalid fetch.background: %d (must be a percentage between 1 and 100)", nc.Fetch.Background)
+	}
+	if nc.Onion.Enabled {
+		// Otherwise the node would still dial peers from its own address
+		if nc.SOCKS5Proxy == "" {
+			return errors.New("onion.enabled needs socks5_proxy")
+		}
+		if nc.Onion.Port < 0 || nc.Onion.Port > 65535 {
+			return fmt.Errorf("onion.port %d out of range", nc.Onion.Port)
+		}
+	}
+	for feature, at := range nc.Activations {
+		if feature == "" || len(feature) > 64 {
+			return fmt.Errorf("invalid activations feature %q", feature)
+		}
+		if _, err := parseActivation(at); err != nil {
+			return fmt.Errorf("invalid activations time for %s: %w", feature, err)
+		}
+	}
 	return nil
 }
 
+// ActivationTimes returns the configured activation times by feature, a
+// zero time for "never".
+func (nc *NetworkConfig) ActivationTimes() map[string]time.Time {
+	if len(nc.Activations) == 0 {
+		return nil
+	}
+	times := make(map[string]time.Time, len(nc.Activations))
+	for feature, at := range nc.Activations {
+		times[feature], _ = parseActivation(at)
+	}
+	return times
+}
+
+func parseActivation(at string) (time.Time, error) {
+	if at == "never" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, at)
+}
+
 // Validate performs validation of SecurityConfig
 func (sc *SecurityConfig) Validate() error {
 	if sc.MaxContentSize <= 0 {
@@ -312,6 +773,17 @@ func (sc *SecurityConfig) Validate() error {
 	if sc.RateLimit > 10000 {
 		return errors.New("rate_limit too high (max 10000)")
 	}
+	if sc.APIRateLimit <= 0 {
+		return errors.New("api_rate_limit must be positive")
+	}
+	if sc.APIRateLimit > 100000 {
+		return errors.New("api_rate_limit too high (max 100000)")
+	}
+	for _, h := range sc.AllowedHosts {
+		if h == "" || strings.ContainsAny(h, ":/") {
+			return fmt.Errorf("allowed host %q must be a host name without a scheme or port", h)
+		}
+	}
 	if sc.BanDuration <= 0 {
 		return errors.New("ban_duration must be positive")
 	}
@@ -330,9 +802,24 @@ func (sc *SecurityConfig) Validate() error {
 	if sc.SessionTimeout > 30*24*time.Hour { // 30 days
 		return errors.New("session_timeout too high (max 30 days)")
 	}
+	if sc.MaxClockSkew < time.Minute {
+		return errors.New("max_clock_skew too low (min 1 minute)")
+	}
+	if sc.MaxClockSkew > 24*time.Hour {
+		return errors.New("max_clock_skew too high (max 24 hours)")
+	}
 	return nil
 }
 
+// APILimits returns the HTTP API requests a client may make per minute,
+// 0 with rate limiting disabled, and the failed logins before a lockout.
+func (sc *SecurityConfig) APILimits() (perMinute, maxFailures int) {
+	if !sc.EnableRateLimiting {
+		return 0, sc.MaxLoginAttempts
+	}
+	return sc.APIRateLimit, sc.MaxLoginAttempts
+}
+
 // Validate performs validation of StorageConfig
 func (sc *StorageConfig) Validate() error {
 	if sc.DataDir == "" {
@@ -362,6 +849,69 @@ func (sc *StorageConfig) Validate() error {
 	if sc.RetryDelay > 10*time.Second {
 		return errors.New("retry_delay too high (max 10 seconds)")
 	}
+	if sc.ContentCacheSize < 0 {
+		return errors.New("content_cache_size cannot be negative")
+	}
+	if sc.ContentCacheSize > 16<<30 { // 16GB
+		return errors.New("content_cache_size too high (max 16GB)")
+	}
+	if sc.SnapshotDir != "" && sc.SnapshotInterval < time.Minute {
+		return errors.New("snapshot_interval too low (min 1 minute)")
+	}
+	if sc.ShardQuota < 0 {
+		return errors.New("shard_quota cannot be negative")
+	}
+	if sc.Retention.Interval < time.Minute || sc.Retention.Interval > 24*time.Hour {
+		return fmt.Errorf("invalid retention interval: %s (must be between 1m and 24h)", sc.Retention.Interval)
+	}
+	if err := sc.Badger.Validate(); err != nil {
+		return err
+	}
+	return sc.Blob.Validate()
+}
+
+// Validate performs validation of BadgerConfig
+func (bc *BadgerConfig) Validate() error {
+	if bc.ValueLogSize != 0 && (bc.ValueLogSize < 1<<20 || bc.ValueLogSize >= 2<<30) {
+		return fmt.Errorf("invalid badger value_log_size: %d (must be at least 1MB and under 2GB)", bc.ValueLogSize)
+	}
+	if bc.MemTableSize != 0 && (bc.MemTableSize < 1<<20 || bc.MemTableSize > 1<<30) {
+		return fmt.Errorf("invalid badger memtable_size: %d (must be between 1MB and 1GB)", bc.MemTableSize)
+	}
+	return bc.StoreOptions().Validate()
+}
+
+// StoreOptions returns the options to open the store with
+func (bc *BadgerConfig) StoreOptions() store.Options {
+	return store.Options{
+		Profile:          bc.Profile,
+		ValueLogFileSize: bc.ValueLogSize,
+		MemTableSize:     bc.MemTableSize,
+		Compression:      bc.Compression,
+	}
+}
+
+// Validate performs validation of BlobConfig
+func (bc *BlobConfig) Validate() error {
+	switch bc.Backend {
+	case "":
+		return nil
+	case "s3":
+	default:
+		return fmt.Errorf("unknown blob backend: %q", bc.Backend)
+	}
+	if bc.Threshold <= 0 {
+		return errors.New("blob threshold must be positive")
+	}
+	if bc.CacheTTL <= 0 {
+		return errors.New("blob cache_ttl must be positive")
+	}
+	if bc.S3Endpoint == "" || bc.S3Bucket == "" {
+		return errors.New("s3 blob backend requires s3_endpoint and s3_bucket")
+	}
+	if bc.S3AccessKeyID == "" || bc.S3SecretAccessKey == "" {
+		return errors.New("s3 blob backend requires credentials")
+	}
 	return nil
 }
 
@@ -408,6 +958,106 @@ func (nc *NodeConfig) Validate() error {
 	return nil
 }
 
+var (
+	themeName   = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+	accentColor = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+)
+
+// Validate performs validation of UIConfig
+func (uc *UIConfig) Validate() error {
+	if !themeName.MatchString(uc.Theme) {
+		return fmt.Errorf("invalid theme: %q (lowercase letters, digits and dashes, up to 32)", uc.Theme)
+	}
+	if strings.TrimSpace(uc.Brand) == "" {
+		return errors.New("brand cannot be empty")
+	}
+	if len(uc.Brand) > 64 {
+		return errors.New("brand too long (max 64 bytes)")
+	}
+	if len(uc.LogoURL) > 1024 {
+		return errors.New("logo_url too long (max 1024 bytes)")
+	}
+	if uc.LogoURL != "" && !strings.HasPrefix(uc.LogoURL, "/") &&
+		!strings.HasPrefix(uc.LogoURL, "https://") && !strings.HasPrefix(uc.LogoURL, "http://") {
+		return fmt.Errorf("invalid logo_url: %q (must be a path or an http(s) URL)", uc.LogoURL)
+	}
+	if uc.Accent != "" && !accentColor.MatchString(uc.Accent) {
+		return fmt.Errorf("invalid accent: %q (must be #rgb or #rrggbb)", uc.Accent)
+	}
+	return nil
+}
+
+// Validate performs validation of SearchConfig
+func (sc *SearchConfig) Validate() error {
+	if sc.CrawlInterval < time.Minute {
+		return errors.New("crawl_interval must be at least 1m")
+	}
+	return nil
+}
+
+// Validate performs validation of ReplicationConfig
+func (rc *ReplicationConfig) Validate() error {
+	if rc.Leader == "" {
+		return nil
+	}
+	u, err := url.Parse(rc.Leader)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid leader: %q (must be an http or https URL)", rc.Leader)
+	}
+	if rc.Token == "" {
+		return errors.New("token is required with a leader")
+	}
+	if rc.Interval < time.Second || rc.Interval > time.Hour {
+		return fmt.Errorf("invalid interval: %s (must be between 1s and 1h)", rc.Interval)
+	}
+	return nil
+}
+
+var statsPrefix = regexp.MustCompile(`^[A-Za-z0-9_-]+(\.[A-Za-z0-9_-]+)*$`)
+
+// Validate performs validation of StatsExportConfig
+func (sc *StatsExportConfig) Validate() error {
+	switch sc.Format {
+	case "":
+		return nil
+	case "influx":
+		u, err := url.Parse(sc.Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid endpoint: %q (must be an http or https URL for influx)", sc.Endpoint)
+		}
+	case "graphite":
+		if _, _, err := net.SplitHostPort(sc.Endpoint); err != nil {
+			return fmt.Errorf("invalid endpoint: %q (must be host:port for graphite)", sc.Endpoint)
+		}
+	default:
+		return fmt.Errorf("invalid format: %q (must be influx or graphite)", sc.Format)
+	}
+	if !statsPrefix.MatchString(sc.Prefix) {
+		return fmt.Errorf("invalid prefix: %q (letters, digits, '_', '-' and '.' only)", sc.Prefix)
+	}
+	if sc.Interval < 10*time.Second || sc.Interval > time.Hour {
+		return fmt.Errorf("invalid interval: %s (must be between 10s and 1h)", sc.Interval)
+	}
+	return nil
+}
+
+// Validate performs validation of WebhookConfig
+func (wc *WebhookConfig) Validate() error {
+	u, err := url.Parse(wc.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid url: %q (must be an http or https URL)", wc.URL)
+	}
+	if wc.Secret == "" {
+		return errors.New("secret is required")
+	}
+	for _, e := range wc.Events {
+		if !slices.Contains(store.EventTypes, e) {
+			return fmt.Errorf("unknown event: %q (must be one of %s)", e, strings.Join(store.EventTypes, ", "))
+		}
+	}
+	return nil
+}
+
 // GetString returns a string configuration value with fallback
 func (c *Config) GetString(key string, fallback string) string {
 	// This is a simplified implementation
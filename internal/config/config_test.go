@@ -0,0 +1,242 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string // written to a temp file; "" loads without a file
+		env     map[string]string
+		check   func(*Config) bool
+		wantErr bool
+	}{
+		{
+			name:  "defaults",
+			check: func(c *Config) bool { return c.LogLevel == "info" && c.Security.RateLimit == 100 },
+		},
+		{
+			name: "file",
+			file: "log_level: debug\nsecurity:\n  rate_limit: 20\nnetwork:\n  max_peers: 8\n  bootstrap_peers: [/ip4/10.0.0.1/tcp/4001]\n",
+			check: func(c *Config) bool {
+				return c.LogLevel == "debug" && c.Security.RateLimit == 20 && c.Network.MaxPeers == 8 &&
+					len(c.Network.BootstrapPeers) == 1 && c.Security.BanDuration > 0
+			},
+		},
+		{
+			name:  "environment overrides file",
+			file:  "log_level: debug\n",
+			env:   map[string]string{"ALXNET_LOG_LEVEL": "warn"},
+			check: func(c *Config) bool { return c.LogLevel == "warn" },
+		},
+		{name: "invalid level", file: "log_level: loud\n", wantErr: true},
+		{name: "rate limit out of range", file: "security:\n  rate_limit: 0\n", wantErr: true},
+		{name: "not YAML", file: "log_level: [\n", wantErr: true},
+		{
+			name: "logging",
+			file: "logging:\n  format: json\n  outputs: [stderr, /var/log/alxnet/node.log]\n  max_backups: 3\n",
+			check: func(c *Config) bool {
+				return c.Logging.Format == "json" && len(c.Logging.Outputs) == 2 && c.Logging.MaxSizeMB == 100
+			},
+		},
+		{name: "unknown log format", file: "logging:\n  format: xml\n", wantErr: true},
+		{name: "no log outputs", file: "logging:\n  outputs: []\n", wantErr: true},
+		{
+			name: "ui",
+			file: "ui:\n  theme: dark\n  accent: \"#0a0\"\n  logo_url: /_alxnet/ui/img/logo.svg\n",
+			env:  map[string]string{"ALXNET_UI_BRAND": "Acme Net"},
+			check: func(c *Config) bool {
+				return c.UI.Theme == "dark" && c.UI.Brand == "Acme Net" && c.UI.Accent == "#0a0"
+			},
+		},
+		{name: "theme path", file: "ui:\n  theme: ../secrets\n", wantErr: true},
+		{name: "accent not a color", file: "ui:\n  accent: \"red;}body{display:none\"\n", wantErr: true},
+		{name: "script logo", file: "ui:\n  logo_url: \"javascript:alert(1)\"\n", wantErr: true},
+		{
+			name: "search",
+			file: "search:\n  sitemap: true\n  indexer: true\n  crawl_interval: 2h\n",
+			check: func(c *Config) bool {
+				return c.Search.Sitemap && c.Search.Indexer && c.Search.CrawlInterval == 2*time.Hour
+			},
+		},
+		{name: "crawl interval too short", file: "search:\n  crawl_interval: 10s\n", wantErr: true},
+		{
+			name:  "shard quota",
+			file:  "storage:\n  shard_quota: 1024\n",
+			env:   map[string]string{"ALXNET_SHARD_QUOTA": "1073741824"},
+			check: func(c *Config) bool { return c.Storage.ShardQuota == 1<<30 },
+		},
+		{name: "negative shard quota", file: "storage:\n  shard_quota: -1\n", wantErr: true},
+		{
+			name: "retention",
+			file: "storage:\n  retention:\n    default: heads\n    pinned: last:10\n    interval: 30m\n",
+			check: func(c *Config) bool {
+				r := c.Storage.Retention
+				return r.Default.Keep == 1 && r.Pinned.Keep == 10 && r.Interval == 30*time.Minute
+			},
+		},
+		{name: "retention defaults to full", check: func(c *Config) bool { return c.Storage.Retention.Default.Full() && c.Storage.Retention.Pinned.Full() }},
+		{name: "unknown retention policy", file: "storage:\n  retention:\n    pinned: newest\n", wantErr: true},
+		{name: "retention interval too short", file: "storage:\n  retention:\n    interval: 5s\n", wantErr: true},
+		{
+			name: "tor",
+			file: "network:\n  socks5_proxy: 127.0.0.1:9050\n  onion:\n    enabled: true\n    port: 4001\n",
+			env:  map[string]string{"ALXNET_TOR_CONTROL_ADDR": "127.0.0.1:9151", "ALXNET_SWARM_KEY": "/etc/alxnet/swarm.key"},
+			check: func(c *Config) bool {
+				return c.Network.SOCKS5Proxy == "127.0.0.1:9050" && c.Network.Onion.Enabled && c.Network.SwarmKey == "/etc/alxnet/swarm.key" &&
+					c.Network.Onion.Port == 4001 && c.Network.Onion.ControlAddr == "127.0.0.1:9151"
+			},
+		},
+		{name: "proxy without port", file: "network:\n  socks5_proxy: localhost\n", wantErr: true},
+		{name: "onion without proxy", file: "network:\n  onion:\n    enabled: true\n", wantErr: true},
+		{
+			name:  "clock",
+			file:  "network:\n  ntp_server: \"\"\nsecurity:\n  max_clock_skew: 10m\n",
+			env:   map[string]string{"ALXNET_MAX_CLOCK_SKEW": "5m"},
+			check: func(c *Config) bool { return c.Network.NTPServer == "" && c.Security.MaxClockSkew == 5*time.Minute },
+		},
+		{
+			name: "snapshots",
+			file: "storage:\n  snapshot_interval: 5m\n",
+			env:  map[string]string{"ALXNET_SNAPSHOT_DIR": "/var/lib/alxnet/snapshots"},
+			check: func(c *Config) bool {
+				return c.Storage.SnapshotDir != "" && c.Storage.SnapshotInterval == 5*time.Minute
+			},
+		},
+		{name: "snapshots too often", file: "storage:\n  snapshot_dir: snaps\n  snapshot_interval: 10s\n", wantErr: true},
+		{
+			name: "replication",
+			file: "replication:\n  leader: http://leader:8082\n  interval: 2s\n",
+			env:  map[string]string{"ALXNET_REPLICATION_TOKEN": "secret"},
+			check: func(c *Config) bool {
+				return c.Replication.Leader == "http://leader:8082" && c.Replication.Token == "secret" && c.Replication.Interval == 2*time.Second
+			},
+		},
+		{name: "replication without token", file: "replication:\n  leader: http://leader:8082\n", wantErr: true},
+		{name: "replication leader not a URL", file: "replication:\n  leader: leader:8082\n  token: secret\n", wantErr: true},
+		{
+			name: "webhooks",
+			file: "webhooks:\n  - url: https://chat.example/hook\n    secret: s3cret\n    events: [site.head, pin.incomplete]\n",
+			check: func(c *Config) bool {
+				return len(c.Webhooks) == 1 && c.Webhooks[0].Secret == "s3cret" && len(c.Webhooks[0].Events) == 2
+			},
+		},
+		{name: "webhook without secret", file: "webhooks:\n  - url: https://chat.example/hook\n", wantErr: true},
+		{name: "webhook unknown event", file: "webhooks:\n  - url: https://chat.example/hook\n    secret: s\n    events: [site.deleted]\n", wantErr: true},
+		{
+			name: "stats export",
+			file: "stats_export:\n  format: influx\n  endpoint: http://influx:8086/api/v2/write?org=ops&bucket=alxnet\n  interval: 30s\n",
+			env:  map[string]string{"ALXNET_STATS_TOKEN": "tok"},
+			check: func(c *Config) bool {
+				return c.StatsExport.Format == "influx" && c.StatsExport.Token == "tok" && c.StatsExport.Prefix == "alxnet" && c.StatsExport.Interval == 30*time.Second
+			},
+		},
+		{name: "graphite endpoint without port", file: "stats_export:\n  format: graphite\n  endpoint: graphite\n", wantErr: true},
+		{name: "unknown stats format", file: "stats_export:\n  format: statsd\n  endpoint: localhost:8125\n", wantErr: true},
+		{
+			name: "listen addresses",
+			file: "network:\n  listen_addrs: [/ip4/0.0.0.0/tcp/4001, /ip6/::/tcp/4001]\n",
+			env:  map[string]string{"ALXNET_INTERFACES": "eth0,wg0"},
+			check: func(c *Config) bool {
+				return len(c.Network.ListenAddrs) == 2 && len(c.Network.Interfaces) == 2 && c.Network.Interfaces[1] == "wg0"
+			},
+		},
+		{
+			name:  "fetch shares",
+			file:  "network:\n  fetch:\n    background: 50\n",
+			check: func(c *Config) bool { return c.Network.Fetch.Background == 50 && c.Network.Fetch.Slots == 16 },
+		},
+		{name: "fetch share over 100", file: "network:\n  fetch:\n    interactive: 150\n", wantErr: true},
+		{
+			name: "activations",
+			file: "network:\n  activations:\n    record-v3: \"2027-01-01T00:00:00Z\"\n    strict-paths: never\n",
+			check: func(c *Config) bool {
+				times := c.Network.ActivationTimes()
+				return times["record-v3"].Equal(time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)) && times["strict-paths"].IsZero() && len(times) == 2
+			},
+		},
+		{
+			name: "allowed hosts",
+			env:  map[string]string{"ALXNET_ALLOWED_HOSTS": "alxnet.lan,node.example"},
+			check: func(c *Config) bool {
+				return len(c.Security.AllowedHosts) == 2 && c.Security.AllowedHosts[1] == "node.example"
+			},
+		},
+		{name: "allowed host with a port", file: "security:\n  allowed_hosts: [\"alxnet.lan:8080\"]\n", wantErr: true},
+		{name: "activation not a time", file: "network:\n  activations:\n    record-v3: soon\n", wantErr: true},
+		{
+			name: "badger profile",
+			file: "storage:\n  badger:\n    memtable_size: 33554432\n    compression: zstd\n",
+			env:  map[string]string{"ALXNET_STORAGE_PROFILE": "laptop"},
+			check: func(c *Config) bool {
+				o := c.Storage.Badger.StoreOptions()
+				return o.Profile == "laptop" && o.MemTableSize == 32<<20 && o.Compression == "zstd"
+			},
+		},
+		{name: "unknown badger profile", file: "storage:\n  badger:\n    profile: server\n", wantErr: true},
+		{name: "badger value log too large", file: "storage:\n  badger:\n    value_log_size: 4294967296\n", wantErr: true},
+		{name: "listen address not a multiaddr", file: "network:\n  listen_addrs: [\"0.0.0.0:4001\"]\n", wantErr: true},
+		{
+			name:  "require API token",
+			env:   map[string]string{"ALXNET_REQUIRE_API_TOKEN": "true"},
+			check: func(c *Config) bool { return c.Security.RequireAPIToken },
+		},
+		{
+			name:  "API rate limit",
+			env:   map[string]string{"ALXNET_API_RATE_LIMIT": "120"},
+			check: func(c *Config) bool { p, f := c.Security.APILimits(); return p == 120 && f == 5 },
+		},
+		{
+			name:  "reader remote images",
+			env:   map[string]string{"ALXNET_UI_READER_REMOTE_IMAGES": "true"},
+			check: func(c *Config) bool { return c.UI.ReaderRemoteImages },
+		},
+		{name: "API rate limit zero", file: "security:\n  api_rate_limit: 0\n", wantErr: true},
+		{name: "clock skew too tight", file: "security:\n  max_clock_skew: 30s\n", wantErr: true},
+		{name: "clock skew too loose", file: "security:\n  max_clock_skew: 48h\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ALXNET_LOG_LEVEL", "")
+			t.Setenv("ALXNET_UI_BRAND", "")
+			t.Setenv("ALXNET_SHARD_QUOTA", "")
+			t.Setenv("ALXNET_STORAGE_PROFILE", "")
+			t.Setenv("ALXNET_NTP_SERVER", "")
+			t.Setenv("ALXNET_MAX_CLOCK_SKEW", "")
+			t.Setenv("ALXNET_SNAPSHOT_DIR", "")
+			t.Setenv("ALXNET_REPLICATION_LEADER", "")
+			t.Setenv("ALXNET_REPLICATION_TOKEN", "")
+			t.Setenv("ALXNET_STATS_TOKEN", "")
+			t.Setenv("ALXNET_INTERFACES", "")
+			t.Setenv("ALXNET_REQUIRE_API_TOKEN", "")
+			t.Setenv("ALXNET_API_RATE_LIMIT", "")
+			t.Setenv("ALXNET_ALLOWED_HOSTS", "")
+			t.Setenv("ALXNET_UI_READER_REMOTE_IMAGES", "")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			path := ""
+			if tt.file != "" {
+				path = filepath.Join(t.TempDir(), "alxnet.yaml")
+				if err := os.WriteFile(path, []byte(tt.file), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			cfg, err := Load(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !tt.check(cfg) {
+				t.Errorf("Load() = %+v", cfg)
+			}
+		})
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("Load() of a missing file succeeded")
+	}
+}
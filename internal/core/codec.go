@@ -0,0 +1,51 @@
+package core
+
+import (
+	"bytes"
+	"io"
+	"sync"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// Shared CBOR codecs. Building an EncMode or DecMode validates and compiles
+// its options, which is wasted work when done per message on the gossip
+// path; modes are immutable and safe for concurrent use.
+var (
+	canonicalEncMode, _ = cbor.CanonicalEncOptions().UserBufferEncMode()
+	plainDecMode, _     = cbor.DecOptions{}.DecMode()
+)
+
+// maxPooledBuffer keeps buffers that grew for a large manifest from being
+// held by the pool indefinitely.
+const maxPooledBuffer = 64 * 1024
+
+var encBufPool = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+// MarshalCanonical encodes v in canonical CBOR, the encoding that CIDs and
+// signatures are computed over.
+func MarshalCanonical(v any) ([]byte, error) {
+	buf := encBufPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer func() {
+		if buf.Cap() <= maxPooledBuffer {
+			encBufPool.Put(buf)
+		}
+	}()
+	if err := canonicalEncMode.MarshalToBuffer(v, buf); err != nil {
+		return nil, err
+	}
+	return bytes.Clone(buf.Bytes()), nil
+}
+
+// UnmarshalCBOR decodes b with default options. Stored records carry
+// untagged times, so this is the mode for reading them back.
+func UnmarshalCBOR(b []byte, v any) error {
+	return plainDecMode.Unmarshal(b, v)
+}
+
+// NewCBORDecoder returns a stream decoder with the same options as
+// UnmarshalCBOR. Callers bound r themselves.
+func NewCBORDecoder(r io.Reader) *cbor.Decoder {
+	return plainDecMode.NewDecoder(r)
+}
@@ -0,0 +1,76 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+func TestMarshalCanonical(t *testing.T) {
+	fresh, err := cbor.CanonicalEncOptions().EncMode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := []any{
+		&UpdateRecord{Version: RecordV1, SitePub: make([]byte, 32), Seq: 7, ContentCID: "ab", TS: 1},
+		&WebsiteManifest{Version: "1.0", Files: map[string]string{"b.html": "2", "a.html": "1"}},
+		map[string]int{"z": 1, "a": 2, "m": 3},
+		bytes.Repeat([]byte{1}, 2*maxPooledBuffer), // grows the pooled buffer past the limit
+	}
+	for i, v := range values {
+		want, err := fresh.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := MarshalCanonical(v)
+		if err != nil || !bytes.Equal(got, want) {
+			t.Errorf("value %d: MarshalCanonical() differs from a fresh canonical EncMode (err %v)", i, err)
+		}
+	}
+
+	// Results must not share the pooled buffer
+	first, _ := MarshalCanonical("first")
+	snapshot := bytes.Clone(first)
+	if _, err := MarshalCanonical("second value"); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first, snapshot) {
+		t.Error("MarshalCanonical() result changed by a later call")
+	}
+}
+
+func TestMarshalCanonicalConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				want := fmt.Sprintf("goroutine %d value %d", g, i)
+				b, err := MarshalCanonical(want)
+				var got string
+				if err == nil {
+					err = UnmarshalCBOR(b, &got)
+				}
+				if err != nil || got != want {
+					t.Errorf("round trip = %q, %v; want %q", got, err, want)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func BenchmarkMarshalCanonical(b *testing.B) {
+	rec := &UpdateRecord{Version: RecordV1, SitePub: make([]byte, 32), Seq: 1, ContentCID: CIDForBytes(nil), UpdatePub: make([]byte, 32), LinkSig: make([]byte, 64), UpdateSig: make([]byte, 64)}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := CanonicalMarshal(rec); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
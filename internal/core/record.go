@@ -0,0 +1,152 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// RecordVersion is the format of an update record. Version 1 records carry
+// it as the text "v1", which their signatures and CIDs cover, so it is
+// kept that way; from version 2 on it is an unsigned integer.
+//
+// Version 2 has the fields of version 1 and defines the rest of the key
+// space. Keys below FirstExtensionKey are reserved for the format itself
+// and must be absent. Keys from FirstExtensionKey on are extensions: nodes
+// that do not know one ignore it but keep it, since the update signature
+// covers it. A field that every node must understand takes a new version.
+// Version 1 has no extensions: a record with any other key is invalid.
+type RecordVersion uint64
+
+// Update record formats
+const (
+	RecordV1 RecordVersion = 1
+	RecordV2 RecordVersion = 2
+
+	// LatestRecordVersion is the newest format this build validates
+	LatestRecordVersion = RecordV2
+)
+
+// FirstExtensionKey is the lowest CBOR key of a version 2 extension field.
+const FirstExtensionKey = 32
+
+// updateRecordKeys is the number of keys, from 0, of UpdateRecord's fields.
+const updateRecordKeys = 9
+
+// strictDecMode fails on keys a struct has no field for, which lets
+// UpdateRecord decode in one pass unless the record has extensions.
+var strictDecMode, _ = cbor.DecOptions{ExtraReturnErrors: cbor.ExtraDecErrorUnknownField}.DecMode()
+
+func (v RecordVersion) String() string {
+	return fmt.Sprintf("v%d", uint64(v))
+}
+
+// MarshalCBOR encodes version 1 as "v1" and later versions as integers.
+func (v RecordVersion) MarshalCBOR() ([]byte, error) {
+	if v == RecordV1 {
+		return canonicalEncMode.Marshal("v1")
+	}
+	return canonicalEncMode.Marshal(uint64(v))
+}
+
+// UnmarshalCBOR accepts only the encodings MarshalCBOR produces, so that a
+// decoded record encodes back to the bytes it was signed as.
+func (v *RecordVersion) UnmarshalCBOR(data []byte) error {
+	var raw interface{}
+	if err := plainDecMode.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	switch x := raw.(type) {
+	case string:
+		if x == "v1" {
+			*v = RecordV1
+			return nil
+		}
+	case uint64:
+		if x >= uint64(RecordV2) {
+			*v = RecordVersion(x)
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid record version %v", raw)
+}
+
+// updateRecordFields is UpdateRecord without its codec methods.
+type updateRecordFields UpdateRecord
+
+// MarshalCBOR encodes the record's fields and extensions in canonical CBOR.
+func (ur UpdateRecord) MarshalCBOR() ([]byte, error) {
+	fields := updateRecordFields(ur)
+	if len(ur.Extensions) == 0 {
+		return canonicalEncMode.Marshal(fields)
+	}
+	data, err := canonicalEncMode.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	merged := make(map[uint64]cbor.RawMessage, updateRecordKeys+len(ur.Extensions))
+	if err := plainDecMode.Unmarshal(data, &merged); err != nil {
+		return nil, err
+	}
+	for key, value := range ur.Extensions {
+		if key < updateRecordKeys {
+			return nil, fmt.Errorf("extension key %d is a record field", key)
+		}
+		merged[key] = value
+	}
+	return canonicalEncMode.Marshal(merged)
+}
+
+// UnmarshalCBOR decodes a record, keeping fields it does not know in
+// Extensions.
+func (ur *UpdateRecord) UnmarshalCBOR(data []byte) error {
+	var fields updateRecordFields
+	err := strictDecMode.Unmarshal(data, &fields)
+	var unknown *cbor.UnknownFieldError
+	if errors.As(err, &unknown) {
+		err = ur.decodeExtensions(data, &fields)
+	}
+	if err != nil {
+		return err
+	}
+	*ur = UpdateRecord(fields)
+	return nil
+}
+
+func (ur *UpdateRecord) decodeExtensions(data []byte, fields *updateRecordFields) error {
+	if err := plainDecMode.Unmarshal(data, fields); err != nil {
+		return err
+	}
+	var all map[uint64]cbor.RawMessage
+	if err := plainDecMode.Unmarshal(data, &all); err != nil {
+		return fmt.Errorf("record keys must be integers: %w", err)
+	}
+	fields.Extensions = make(map[uint64]cbor.RawMessage)
+	for key, value := range all {
+		if key >= updateRecordKeys {
+			fields.Extensions[key] = value
+		}
+	}
+	return nil
+}
+
+// CheckFormat checks the record's version and that its fields follow the
+// rules of that version.
+func (ur *UpdateRecord) CheckFormat() error {
+	switch {
+	case ur.Version == 0:
+		return errors.New("version is required")
+	case ur.Version > LatestRecordVersion:
+		return fmt.Errorf("unsupported record version %s", ur.Version)
+	}
+	for key := range ur.Extensions {
+		if ur.Version == RecordV1 {
+			return fmt.Errorf("unknown field %d in a v1 record", key)
+		}
+		if key < FirstExtensionKey {
+			return fmt.Errorf("reserved field %d in a %s record", key, ur.Version)
+		}
+	}
+	return nil
+}
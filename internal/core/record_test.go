@@ -0,0 +1,94 @@
+package core
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// legacyRecord is UpdateRecord as builds before RecordVersion encoded it.
+type legacyRecord struct {
+	Version    string `cbor:"0,keyasint"`
+	SitePub    []byte `cbor:"1,keyasint"`
+	Seq        uint64 `cbor:"2,keyasint"`
+	PrevCID    string `cbor:"3,keyasint"`
+	ContentCID string `cbor:"4,keyasint"`
+	TS         int64  `cbor:"5,keyasint"`
+	UpdatePub  []byte `cbor:"6,keyasint"`
+	LinkSig    []byte `cbor:"7,keyasint"`
+	UpdateSig  []byte `cbor:"8,keyasint"`
+}
+
+func TestRecordV1Migration(t *testing.T) {
+	old, err := MarshalCanonical(legacyRecord{Version: "v1", SitePub: make([]byte, 32), Seq: 3, PrevCID: "ab", ContentCID: "cd", TS: 100, UpdateSig: make([]byte, 64)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rec UpdateRecord
+	if err := UnmarshalCBOR(old, &rec); err != nil {
+		t.Fatalf("decoding a v1 record: %v", err)
+	}
+	if rec.Version != RecordV1 || rec.Seq != 3 || rec.Extensions != nil {
+		t.Errorf("decoded %+v", rec)
+	}
+	// Signatures and CIDs cover the encoding, so it must not change
+	again, err := CanonicalMarshal(&rec)
+	if err != nil || !bytes.Equal(again, old) {
+		t.Errorf("v1 record re-encodes differently (err %v)", err)
+	}
+	noSig, _ := CanonicalMarshalNoUpdateSig(&rec)
+	oldNoSig, _ := MarshalCanonical(legacyRecord{Version: "v1", SitePub: make([]byte, 32), Seq: 3, PrevCID: "ab", ContentCID: "cd", TS: 100})
+	if !bytes.Equal(noSig, oldNoSig) {
+		t.Error("v1 signing preimage differs from the legacy one")
+	}
+
+	for _, version := range []string{"1.0", "1", "v2", ""} {
+		data, _ := MarshalCanonical(legacyRecord{Version: version, Seq: 1})
+		if err := UnmarshalCBOR(data, &UpdateRecord{}); err == nil {
+			t.Errorf("record version %q decoded", version)
+		}
+	}
+}
+
+func TestRecordV2Extensions(t *testing.T) {
+	rec := UpdateRecord{
+		Version:    RecordV2,
+		SitePub:    make([]byte, 32),
+		Seq:        1,
+		ContentCID: "cd",
+		TS:         100,
+		Extensions: map[uint64]cbor.RawMessage{40: {0x63, 'n', 'e', 'w'}, 33: {0x01}},
+	}
+	data, err := CanonicalMarshal(&rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var raw map[uint64]cbor.RawMessage
+	if err := UnmarshalCBOR(data, &raw); err != nil || len(raw) != 11 || !bytes.Equal(raw[0], []byte{0x02}) {
+		t.Fatalf("v2 encoding = %x (%v); want 11 integer keys and version 2 as an integer", data, err)
+	}
+	if again, _ := MarshalCanonical(raw); !bytes.Equal(again, data) {
+		t.Error("v2 encoding is not canonical")
+	}
+
+	var got UpdateRecord
+	if err := UnmarshalCBOR(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Version != RecordV2 || len(got.Extensions) != 2 || !bytes.Equal(got.Extensions[40], rec.Extensions[40]) {
+		t.Fatalf("decoded %+v", got)
+	}
+	if again, _ := CanonicalMarshal(&got); !bytes.Equal(again, data) {
+		t.Error("v2 record with extensions re-encodes differently")
+	}
+
+	rec.Extensions = map[uint64]cbor.RawMessage{4: {0x01}}
+	if _, err := CanonicalMarshal(&rec); err == nil {
+		t.Error("extension overriding ContentCID encoded")
+	}
+	textKey, _ := MarshalCanonical(map[any]any{uint64(0): uint64(2), "name": "x"})
+	if err := UnmarshalCBOR(textKey, &UpdateRecord{}); err == nil {
+		t.Error("record with a text key decoded")
+	}
+}
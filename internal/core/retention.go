@@ -0,0 +1,68 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MaxRetainedVersions bounds the N of a "last:N" retention policy.
+const MaxRetainedVersions = 1000000
+
+// RetentionPolicy says how much of a site's history a node keeps: its
+// update records and website manifests, and the content only they use.
+// Keep is the number of versions kept, newest first; 0 keeps them all.
+//
+// Policies are written "full", "heads" (the current version only) or
+// "last:N".
+type RetentionPolicy struct {
+	Keep int
+}
+
+// ParseRetentionPolicy parses a policy in the form String returns. ""
+// is "full".
+func ParseRetentionPolicy(s string) (RetentionPolicy, error) {
+	switch s {
+	case "", "full":
+		return RetentionPolicy{}, nil
+	case "heads":
+		return RetentionPolicy{Keep: 1}, nil
+	}
+	n, ok := strings.CutPrefix(s, "last:")
+	if !ok {
+		return RetentionPolicy{}, fmt.Errorf("unknown retention policy %q, want full, heads or last:N", s)
+	}
+	keep, err := strconv.Atoi(n)
+	if err != nil || keep < 1 || keep > MaxRetainedVersions {
+		return RetentionPolicy{}, fmt.Errorf("retention policy %q: N must be 1 to %d", s, MaxRetainedVersions)
+	}
+	return RetentionPolicy{Keep: keep}, nil
+}
+
+// Full reports whether the policy keeps the whole history.
+func (p RetentionPolicy) Full() bool { return p.Keep == 0 }
+
+func (p RetentionPolicy) String() string {
+	switch p.Keep {
+	case 0:
+		return "full"
+	case 1:
+		return "heads"
+	}
+	return "last:" + strconv.Itoa(p.Keep)
+}
+
+// MarshalText writes the policy as String does, for JSON and YAML.
+func (p RetentionPolicy) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText parses a policy with ParseRetentionPolicy.
+func (p *RetentionPolicy) UnmarshalText(text []byte) error {
+	parsed, err := ParseRetentionPolicy(string(text))
+	if err != nil {
+		return err
+	}
+	*p = parsed
+	return nil
+}
@@ -0,0 +1,57 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseRetentionPolicy(t *testing.T) {
+	tests := []struct {
+		in      string
+		keep    int
+		str     string
+		wantErr bool
+	}{
+		{"", 0, "full", false},
+		{"full", 0, "full", false},
+		{"heads", 1, "heads", false},
+		{"last:1", 1, "heads", false},
+		{"last:10", 10, "last:10", false},
+		{"last:1000000", MaxRetainedVersions, "last:1000000", false},
+		{"last:0", 0, "", true},
+		{"last:-3", 0, "", true},
+		{"last:1000001", 0, "", true},
+		{"last:", 0, "", true},
+		{"last:ten", 0, "", true},
+		{"newest", 0, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			p, err := ParseRetentionPolicy(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRetentionPolicy(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if p.Keep != tt.keep || p.String() != tt.str {
+				t.Errorf("ParseRetentionPolicy(%q) = %d %q, want %d %q", tt.in, p.Keep, p, tt.keep, tt.str)
+			}
+		})
+	}
+}
+
+func TestRetentionPolicyJSON(t *testing.T) {
+	var v struct {
+		Policy RetentionPolicy `json:"policy"`
+	}
+	if err := json.Unmarshal([]byte(`{"policy":"last:5"}`), &v); err != nil || v.Policy.Keep != 5 {
+		t.Fatalf("Unmarshal = %+v, %v", v, err)
+	}
+	if b, _ := json.Marshal(v); string(b) != `{"policy":"last:5"}` {
+		t.Errorf("Marshal = %s", b)
+	}
+	if err := json.Unmarshal([]byte(`{"policy":"last:0"}`), &v); err == nil {
+		t.Error("Unmarshal accepted last:0")
+	}
+}
@@ -3,11 +3,15 @@ package core
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"path"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"github.com/fxamacker/cbor/v2"
 )
@@ -16,12 +20,17 @@ import (
 const (
 	MaxContentSize    = 10 * 1024 * 1024 // 10MB limit
 	MaxFileCount      = 1000             // Maximum files per website
+	MaxPreloadFiles   = 32               // Maximum critical files a manifest lists
 	MaxPathLength     = 255              // Maximum file path length
 	MaxRecordSize     = 1024 * 1024      // 1MB limit for records
 	MinSequenceNumber = 1
 	MaxSequenceNumber = 1<<63 - 1 // Max uint63
 )
 
+// DefaultMaxClockSkew is how far ahead of the local clock a record
+// timestamp may be before the record is rejected.
+const DefaultMaxClockSkew = time.Hour
+
 // Allowed file extensions for security
 var AllowedExtensions = []string{
 	".html", ".htm", ".css", ".js", ".png", ".jpg", ".jpeg",
@@ -34,21 +43,26 @@ var AllowedExtensions = []string{
 // a per-update ephemeral key via LinkSig; the update body is signed by the
 // ephemeral key. The record is encoded with canonical CBOR.
 type UpdateRecord struct {
-	Version    string `cbor:"0,keyasint"`
-	SitePub    []byte `cbor:"1,keyasint"` // 32B ed25519 pub
-	Seq        uint64 `cbor:"2,keyasint"`
-	PrevCID    string `cbor:"3,keyasint"` // hex sha256 of previous record encoding
-	ContentCID string `cbor:"4,keyasint"` // hex sha256 of content bytes
-	TS         int64  `cbor:"5,keyasint"` // unix seconds
-	UpdatePub  []byte `cbor:"6,keyasint"` // 32B ed25519 pub (ephemeral per update)
-	LinkSig    []byte `cbor:"7,keyasint"` // sig by SitePriv over link preimage
-	UpdateSig  []byte `cbor:"8,keyasint"` // sig by UpdatePriv over record preimage
+	Version    RecordVersion `cbor:"0,keyasint"`
+	SitePub    []byte        `cbor:"1,keyasint"` // 32B ed25519 pub
+	Seq        uint64        `cbor:"2,keyasint"`
+	PrevCID    string        `cbor:"3,keyasint"` // hex sha256 of previous record encoding
+	ContentCID string        `cbor:"4,keyasint"` // hex sha256 of content bytes
+	TS         int64         `cbor:"5,keyasint"` // unix seconds
+	UpdatePub  []byte        `cbor:"6,keyasint"` // 32B ed25519 pub (ephemeral per update)
+	LinkSig    []byte        `cbor:"7,keyasint"` // sig by SitePriv over link preimage
+	UpdateSig  []byte        `cbor:"8,keyasint"` // sig by UpdatePriv over record preimage
+
+	// Extensions holds the fields of a v2 record this build does not know,
+	// by key, so that the record encodes back to the bytes it was signed
+	// as. See RecordVersion.
+	Extensions map[uint64]cbor.RawMessage `cbor:"-"`
 }
 
 // Validate performs comprehensive validation of an UpdateRecord
 func (ur *UpdateRecord) Validate() error {
-	if ur.Version == "" {
-		return errors.New("version is required")
+	if err := ur.CheckFormat(); err != nil {
+		return err
 	}
 	if len(ur.SitePub) != 32 {
 		return fmt.Errorf("invalid site public key length: %d (expected 32)", len(ur.SitePub))
@@ -56,11 +70,8 @@ func (ur *UpdateRecord) Validate() error {
 	if ur.Seq < MinSequenceNumber || ur.Seq > MaxSequenceNumber {
 		return fmt.Errorf("sequence number out of range: %d (must be between %d and %d)", ur.Seq, MinSequenceNumber, MaxSequenceNumber)
 	}
-	if ur.TS <= 0 {
-		return fmt.Errorf("invalid timestamp: %d", ur.TS)
-	}
-	if ur.TS > time.Now().Unix()+3600 { // Allow 1 hour clock skew
-		return fmt.Errorf("timestamp too far in future: %d", ur.TS)
+	if err := CheckTimestamp(ur.TS); err != nil {
+		return err
 	}
 	if len(ur.UpdatePub) != 32 {
 		return fmt.Errorf("invalid update public key length: %d (expected 32)", len(ur.UpdatePub))
@@ -98,6 +109,18 @@ type WebsiteManifest struct {
 	UpdatePub []byte            `cbor:"7,keyasint"` // 32B ed25519 pub (ephemeral per update)
 	LinkSig   []byte            `cbor:"8,keyasint"` // sig by SitePriv over link preimage
 	UpdateSig []byte            `cbor:"9,keyasint"` // sig by UpdatePriv over manifest preimage
+
+	// NoIndex asks nodes not to list the site in sitemaps and indexers not
+	// to crawl it, like a robots "noindex". It is covered by UpdateSig, so
+	// nodes that predate it cannot verify manifests that set it.
+	NoIndex bool `cbor:"10,keyasint,omitempty"`
+
+	// Preload lists the site's critical files, such as the stylesheets and
+	// scripts the main page needs to render, for nodes to fetch before the
+	// rest. Every entry is a path in Files. Like NoIndex it is covered by
+	// UpdateSig, so nodes that predate it cannot verify manifests that set
+	// it.
+	Preload []string `cbor:"11,keyasint,omitempty"`
 }
 
 // Validate performs comprehensive validation of a WebsiteManifest
@@ -111,11 +134,8 @@ func (wm *WebsiteManifest) Validate() error {
 	if wm.Seq < MinSequenceNumber || wm.Seq > MaxSequenceNumber {
 		return fmt.Errorf("sequence number out of range: %d (must be between %d and %d)", wm.Seq, MinSequenceNumber, MaxSequenceNumber)
 	}
-	if wm.TS <= 0 {
-		return fmt.Errorf("invalid timestamp: %d", wm.TS)
-	}
-	if wm.TS > time.Now().Unix()+3600 { // Allow 1 hour clock skew
-		return fmt.Errorf("timestamp too far in future: %d", wm.TS)
+	if err := CheckTimestamp(wm.TS); err != nil {
+		return err
 	}
 	if wm.MainFile == "" {
 		return errors.New("main file is required")
@@ -134,6 +154,19 @@ func (wm *WebsiteManifest) Validate() error {
 			return fmt.Errorf("invalid content CID for %s: %s", path, cid)
 		}
 	}
+	if len(wm.Preload) > MaxPreloadFiles {
+		return fmt.Errorf("too many preload files: %d (maximum %d)", len(wm.Preload), MaxPreloadFiles)
+	}
+	preload := make(map[string]bool, len(wm.Preload))
+	for _, path := range wm.Preload {
+		if _, ok := wm.Files[path]; !ok {
+			return fmt.Errorf("preload file %s is not part of the website", path)
+		}
+		if preload[path] {
+			return fmt.Errorf("preload file %s is listed twice", path)
+		}
+		preload[path] = true
+	}
 	if len(wm.UpdatePub) != 32 {
 		return fmt.Errorf("invalid update public key length: %d (expected 32)", len(wm.UpdatePub))
 	}
@@ -185,11 +218,8 @@ func (fr *FileRecord) Validate() error {
 	if !isValidMimeType(fr.MimeType) {
 		return fmt.Errorf("invalid MIME type: %s", fr.MimeType)
 	}
-	if fr.TS <= 0 {
-		return fmt.Errorf("invalid timestamp: %d", fr.TS)
-	}
-	if fr.TS > time.Now().Unix()+3600 { // Allow 1 hour clock skew
-		return fmt.Errorf("timestamp too far in future: %d", fr.TS)
+	if err := CheckTimestamp(fr.TS); err != nil {
+		return err
 	}
 	if len(fr.UpdatePub) != 32 {
 		return fmt.Errorf("invalid update public key length: %d (expected 32)", len(fr.UpdatePub))
@@ -230,11 +260,8 @@ func (dr *DeleteRecord) Validate() error {
 	if dr.TargetCont != "" && !isValidHexString(dr.TargetCont) {
 		return fmt.Errorf("invalid target content CID format: %s", dr.TargetCont)
 	}
-	if dr.TS <= 0 {
-		return fmt.Errorf("invalid timestamp: %d", dr.TS)
-	}
-	if dr.TS > time.Now().Unix()+3600 { // Allow 1 hour clock skew
-		return fmt.Errorf("timestamp too far in future: %d", dr.TS)
+	if err := CheckTimestamp(dr.TS); err != nil {
+		return err
 	}
 	if len(dr.Sig) != 64 {
 		return fmt.Errorf("invalid signature length: %d (expected 64)", len(dr.Sig))
@@ -242,6 +269,519 @@ func (dr *DeleteRecord) Validate() error {
 	return nil
 }
 
+// HostingRequest asks a specific node to pin a site until Expires. Only the
+// site owner can issue one, since it is signed by the site key.
+type HostingRequest struct {
+	Version  string `cbor:"0,keyasint"`
+	SitePub  []byte `cbor:"1,keyasint"`
+	HostPeer string `cbor:"2,keyasint"` // libp2p peer ID of the hosting node
+	Expires  int64  `cbor:"3,keyasint"` // unix seconds
+	TS       int64  `cbor:"4,keyasint"`
+	Sig      []byte `cbor:"5,keyasint"` // Ed25519 by SitePriv over PreimageHostingRequest
+}
+
+// MaxHostingDuration caps how far ahead a hosting request may expire.
+const MaxHostingDuration = 365 * 24 * time.Hour
+
+// Validate performs structural validation of a HostingRequest. It does not
+// verify the signature.
+func (hr *HostingRequest) Validate() error {
+	if hr.Version == "" {
+		return errors.New("version is required")
+	}
+	if len(hr.SitePub) != 32 {
+		return fmt.Errorf("invalid site public key length: %d (expected 32)", len(hr.SitePub))
+	}
+	if hr.HostPeer == "" {
+		return errors.New("host peer is required")
+	}
+	if err := CheckTimestamp(hr.TS); err != nil {
+		return err
+	}
+	if hr.Expires <= hr.TS {
+		return errors.New("expiry must be after the request timestamp")
+	}
+	if hr.Expires > hr.TS+int64(MaxHostingDuration/time.Second) {
+		return fmt.Errorf("hosting duration too long (maximum %v)", MaxHostingDuration)
+	}
+	if len(hr.Sig) != 64 {
+		return fmt.Errorf("invalid signature length: %d (expected 64)", len(hr.Sig))
+	}
+	return nil
+}
+
+// MaxCommentLength caps the body of a comment, in bytes.
+const MaxCommentLength = 2000
+
+// CommentRecord is a short message a visitor appends to a site, signed by
+// the visitor's own key. Comments are never edited; the site owner hides or
+// shows them with ModerationRecords.
+type CommentRecord struct {
+	Version   string `cbor:"0,keyasint"`
+	SiteID    string `cbor:"1,keyasint"` // site commented on
+	AuthorPub []byte `cbor:"2,keyasint"` // 32B ed25519 pub of the visitor
+	ReplyTo   string `cbor:"3,keyasint"` // CID of a comment on the same site (optional)
+	Body      string `cbor:"4,keyasint"`
+	TS        int64  `cbor:"5,keyasint"`
+	Sig       []byte `cbor:"6,keyasint"` // Ed25519 by AuthorPriv over PreimageComment
+}
+
+// Validate performs structural validation of a CommentRecord. It does not
+// verify the signature.
+func (cr *CommentRecord) Validate() error {
+	if cr.Version == "" {
+		return errors.New("version is required")
+	}
+	if len(cr.SiteID) != 64 || !isValidHexString(cr.SiteID) {
+		return fmt.Errorf("invalid site ID: %q", cr.SiteID)
+	}
+	if len(cr.AuthorPub) != 32 {
+		return fmt.Errorf("invalid author public key length: %d (expected 32)", len(cr.AuthorPub))
+	}
+	if cr.ReplyTo != "" && (len(cr.ReplyTo) != 64 || !isValidHexString(cr.ReplyTo)) {
+		return fmt.Errorf("invalid reply CID: %q", cr.ReplyTo)
+	}
+	if strings.TrimSpace(cr.Body) == "" {
+		return errors.New("comment body is required")
+	}
+	if len(cr.Body) > MaxCommentLength {
+		return fmt.Errorf("comment too long: %d bytes (maximum %d)", len(cr.Body), MaxCommentLength)
+	}
+	if !utf8.ValidString(cr.Body) {
+		return errors.New("comment body is not valid UTF-8")
+	}
+	if err := CheckTimestamp(cr.TS); err != nil {
+		return err
+	}
+	if len(cr.Sig) != 64 {
+		return fmt.Errorf("invalid signature length: %d (expected 64)", len(cr.Sig))
+	}
+	return nil
+}
+
+// ModerationRecord sets the moderation flags of one comment. Only the owner
+// of the commented site can issue one; the newest record for a comment wins.
+type ModerationRecord struct {
+	Version    string `cbor:"0,keyasint"`
+	SitePub    []byte `cbor:"1,keyasint"`
+	CommentCID string `cbor:"2,keyasint"`
+	Hidden     bool   `cbor:"3,keyasint"`
+	TS         int64  `cbor:"4,keyasint"`
+	Sig        []byte `cbor:"5,keyasint"` // Ed25519 by SitePriv over PreimageModeration
+}
+
+// Validate performs structural validation of a ModerationRecord. It does
+// not verify the signature.
+func (mr *ModerationRecord) Validate() error {
+	if mr.Version == "" {
+		return errors.New("version is required")
+	}
+	if len(mr.SitePub) != 32 {
+		return fmt.Errorf("invalid site public key length: %d (expected 32)", len(mr.SitePub))
+	}
+	if len(mr.CommentCID) != 64 || !isValidHexString(mr.CommentCID) {
+		return fmt.Errorf("invalid comment CID: %q", mr.CommentCID)
+	}
+	if err := CheckTimestamp(mr.TS); err != nil {
+		return err
+	}
+	if len(mr.Sig) != 64 {
+		return fmt.Errorf("invalid signature length: %d (expected 64)", len(mr.Sig))
+	}
+	return nil
+}
+
+// Pointer limits
+const (
+	MaxPointerNameLength = 64
+)
+
+// PointerRecord is a mutable, signed name -> CID pointer owned by a key,
+// such as "latest-release" or "status". Unlike site updates pointers carry
+// no history: the record with the highest Seq for an owner and name wins.
+type PointerRecord struct {
+	Version  string `cbor:"0,keyasint"`
+	OwnerPub []byte `cbor:"1,keyasint"` // 32B ed25519 pub
+	Name     string `cbor:"2,keyasint"`
+	Target   string `cbor:"3,keyasint"` // CID the name points at
+	Seq      uint64 `cbor:"4,keyasint"`
+	TS       int64  `cbor:"5,keyasint"`
+	Sig      []byte `cbor:"6,keyasint"` // Ed25519 by OwnerPriv over PreimagePointer
+}
+
+// ValidPointerName reports whether name is 1-64 characters of lowercase
+// letters, digits, '.', '_' and '-'.
+func ValidPointerName(name string) bool {
+	if name == "" || len(name) > MaxPointerNameLength {
+		return false
+	}
+	for _, c := range name {
+		if !((c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '.' || c == '_' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// Validate performs structural validation of a PointerRecord. It does not
+// verify the signature.
+func (pr *PointerRecord) Validate() error {
+	if pr.Version == "" {
+		return errors.New("version is required")
+	}
+	if len(pr.OwnerPub) != 32 {
+		return fmt.Errorf("invalid owner public key length: %d (expected 32)", len(pr.OwnerPub))
+	}
+	if !ValidPointerName(pr.Name) {
+		return fmt.Errorf("invalid pointer name: %q", pr.Name)
+	}
+	if len(pr.Target) != 64 || !isValidHexString(pr.Target) {
+		return fmt.Errorf("invalid pointer target: %q", pr.Target)
+	}
+	if pr.Seq == 0 {
+		return errors.New("sequence number must be greater than 0")
+	}
+	if err := CheckTimestamp(pr.TS); err != nil {
+		return err
+	}
+	if len(pr.Sig) != 64 {
+		return fmt.Errorf("invalid signature length: %d (expected 64)", len(pr.Sig))
+	}
+	return nil
+}
+
+// Domain marketplace limits
+const (
+	// MaxDomainOfferDuration caps how long a domain offer stays open.
+	MaxDomainOfferDuration = 30 * 24 * time.Hour
+	// MaxDomainOfferNote caps the terms attached to an offer, in bytes.
+	MaxDomainOfferNote = 280
+)
+
+// ValidSiteName reports whether name is 3-32 characters of lowercase
+// letters, digits, '_' and '-', starting with a letter or digit.
+func ValidSiteName(name string) bool {
+	if len(name) < 3 || len(name) > 32 {
+		return false
+	}
+	if !((name[0] >= 'a' && name[0] <= 'z') || (name[0] >= '0' && name[0] <= '9')) {
+		return false
+	}
+	for _, c := range name {
+		if !((c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '_' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// DomainOffer offers a site name to another site. It is signed by the key
+// of the site the name is registered to and names the site allowed to take
+// it; the name moves once that site signs a DomainAccept before Expires.
+// Note carries the seller's terms, such as a price, which the network does
+// not enforce.
+type DomainOffer struct {
+	Version   string `cbor:"0,keyasint"`
+	Domain    string `cbor:"1,keyasint"`
+	SellerPub []byte `cbor:"2,keyasint"` // 32B ed25519 pub of the site holding the name
+	Buyer     string `cbor:"3,keyasint"` // site ID the name is offered to
+	Note      string `cbor:"4,keyasint"`
+	Expires   int64  `cbor:"5,keyasint"` // unix seconds
+	TS        int64  `cbor:"6,keyasint"`
+	Sig       []byte `cbor:"7,keyasint"` // Ed25519 by SellerPriv over PreimageDomainOffer
+}
+
+// Validate performs structural validation of a DomainOffer. It does not
+// verify the signature.
+func (do *DomainOffer) Validate() error {
+	if do.Version == "" {
+		return errors.New("version is required")
+	}
+	if !ValidSiteName(do.Domain) {
+		return fmt.Errorf("invalid domain name: %q", do.Domain)
+	}
+	if len(do.SellerPub) != 32 {
+		return fmt.Errorf("invalid seller public key length: %d (expected 32)", len(do.SellerPub))
+	}
+	if len(do.Buyer) != 64 || !isValidHexString(do.Buyer) {
+		return fmt.Errorf("invalid buyer site ID: %q", do.Buyer)
+	}
+	if do.Buyer == SiteIDFromPub(do.SellerPub) {
+		return errors.New("a domain cannot be offered to the site holding it")
+	}
+	if len(do.Note) > MaxDomainOfferNote {
+		return fmt.Errorf("note too long: %d bytes (maximum %d)", len(do.Note), MaxDomainOfferNote)
+	}
+	if !utf8.ValidString(do.Note) {
+		return errors.New("note is not valid UTF-8")
+	}
+	if err := CheckTimestamp(do.TS); err != nil {
+		return err
+	}
+	if do.Expires <= do.TS {
+		return errors.New("expiry must be after the offer timestamp")
+	}
+	if do.Expires > do.TS+int64(MaxDomainOfferDuration/time.Second) {
+		return fmt.Errorf("offer duration too long (maximum %v)", MaxDomainOfferDuration)
+	}
+	if len(do.Sig) != 64 {
+		return fmt.Errorf("invalid signature length: %d (expected 64)", len(do.Sig))
+	}
+	return nil
+}
+
+// DomainAccept takes up a DomainOffer. It is signed by the key of the site
+// the offer names as buyer.
+type DomainAccept struct {
+	Version  string `cbor:"0,keyasint"`
+	OfferCID string `cbor:"1,keyasint"` // CID of the canonical offer
+	BuyerPub []byte `cbor:"2,keyasint"` // 32B ed25519 pub of the buying site
+	TS       int64  `cbor:"3,keyasint"`
+	Sig      []byte `cbor:"4,keyasint"` // Ed25519 by BuyerPriv over PreimageDomainAccept
+}
+
+// Validate performs structural validation of a DomainAccept. It does not
+// verify the signature or check it against the offer.
+func (da *DomainAccept) Validate() error {
+	if da.Version == "" {
+		return errors.New("version is required")
+	}
+	if len(da.OfferCID) != 64 || !isValidHexString(da.OfferCID) {
+		return fmt.Errorf("invalid offer CID: %q", da.OfferCID)
+	}
+	if len(da.BuyerPub) != 32 {
+		return fmt.Errorf("invalid buyer public key length: %d (expected 32)", len(da.BuyerPub))
+	}
+	if err := CheckTimestamp(da.TS); err != nil {
+		return err
+	}
+	if len(da.Sig) != 64 {
+		return fmt.Errorf("invalid signature length: %d (expected 64)", len(da.Sig))
+	}
+	return nil
+}
+
+// MaxGatewayAuthDuration caps how long a gateway authorization is valid.
+const MaxGatewayAuthDuration = 365 * 24 * time.Hour
+
+// maxGatewayHost bounds a gateway host name, as DNS does.
+const maxGatewayHost = 253
+
+// ValidGatewayHost reports whether host is a lowercase DNS name of at least
+// two labels, without a port or trailing dot.
+func ValidGatewayHost(host string) bool {
+	if len(host) == 0 || len(host) > maxGatewayHost {
+		return false
+	}
+	labels := strings.Split(host, ".")
+	if len(labels) < 2 {
+		return false
+	}
+	for _, label := range labels {
+		if len(label) == 0 || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if !((c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// GatewayAuth is a site owner's statement that the HTTP gateway at Host is
+// an official mirror of the site until Expires. Gateways serve it with the
+// site's pages so visitors can tell them from gateways nobody authorized.
+type GatewayAuth struct {
+	Version string `cbor:"0,keyasint"`
+	SitePub []byte `cbor:"1,keyasint"` // 32B ed25519 pub of the site
+	Host    string `cbor:"2,keyasint"` // gateway host name, see ValidGatewayHost
+	Expires int64  `cbor:"3,keyasint"` // unix seconds
+	TS      int64  `cbor:"4,keyasint"`
+	Sig     []byte `cbor:"5,keyasint"` // Ed25519 by SitePriv over PreimageGatewayAuth
+}
+
+// Validate performs structural validation of a GatewayAuth. It does not
+// verify the signature or check expiry against the clock.
+func (ga *GatewayAuth) Validate() error {
+	if ga.Version == "" {
+		return errors.New("version is required")
+	}
+	if len(ga.SitePub) != 32 {
+		return fmt.Errorf("invalid site public key length: %d (expected 32)", len(ga.SitePub))
+	}
+	if !ValidGatewayHost(ga.Host) {
+		return fmt.Errorf("invalid gateway host: %q", ga.Host)
+	}
+	if err := CheckTimestamp(ga.TS); err != nil {
+		return err
+	}
+	if ga.Expires <= ga.TS {
+		return errors.New("expiry must be after the authorization timestamp")
+	}
+	if ga.Expires > ga.TS+int64(MaxGatewayAuthDuration/time.Second) {
+		return fmt.Errorf("authorization valid too long (maximum %v)", MaxGatewayAuthDuration)
+	}
+	if len(ga.Sig) != 64 {
+		return fmt.Errorf("invalid signature length: %d (expected 64)", len(ga.Sig))
+	}
+	return nil
+}
+
+// MaxContributorGrantDuration caps how long a contributor grant is valid.
+const MaxContributorGrantDuration = 365 * 24 * time.Hour
+
+// MaxGrantPaths bounds the paths one contributor grant lists.
+const MaxGrantPaths = 64
+
+// ContributorGrant is a site owner's permission for ContributorPub to sign
+// file records for some paths of the site until Expires. A path ending in
+// "/" covers every file below it. Contributed files reach readers only
+// when the owner accepts them and publishes a manifest with them.
+type ContributorGrant struct {
+	Version        string   `cbor:"0,keyasint"`
+	SitePub        []byte   `cbor:"1,keyasint"` // 32B ed25519 pub of the site
+	ContributorPub []byte   `cbor:"2,keyasint"` // 32B ed25519 pub that signs the files
+	Paths          []string `cbor:"3,keyasint"`
+	Expires        int64    `cbor:"4,keyasint"` // unix seconds
+	TS             int64    `cbor:"5,keyasint"`
+	Sig            []byte   `cbor:"6,keyasint"` // Ed25519 by SitePriv over PreimageContributorGrant
+}
+
+// Validate performs structural validation of a ContributorGrant. It does
+// not verify the signature or check expiry against the clock.
+func (cg *ContributorGrant) Validate() error {
+	if cg.Version == "" {
+		return errors.New("version is required")
+	}
+	if len(cg.SitePub) != 32 {
+		return fmt.Errorf("invalid site public key length: %d (expected 32)", len(cg.SitePub))
+	}
+	if len(cg.ContributorPub) != 32 {
+		return fmt.Errorf("invalid contributor public key length: %d (expected 32)", len(cg.ContributorPub))
+	}
+	if string(cg.ContributorPub) == string(cg.SitePub) {
+		return errors.New("contributor key is the site key")
+	}
+	if len(cg.Paths) == 0 || len(cg.Paths) > MaxGrantPaths {
+		return fmt.Errorf("a grant lists 1 to %d paths, not %d", MaxGrantPaths, len(cg.Paths))
+	}
+	for _, p := range cg.Paths {
+		if err := validateGrantPath(p); err != nil {
+			return err
+		}
+	}
+	if err := CheckTimestamp(cg.TS); err != nil {
+		return err
+	}
+	if cg.Expires <= cg.TS {
+		return errors.New("expiry must be after the grant timestamp")
+	}
+	if cg.Expires > cg.TS+int64(MaxContributorGrantDuration/time.Second) {
+		return fmt.Errorf("grant valid too long (maximum %v)", MaxContributorGrantDuration)
+	}
+	if len(cg.Sig) != 64 {
+		return fmt.Errorf("invalid signature length: %d (expected 64)", len(cg.Sig))
+	}
+	return nil
+}
+
+// validateGrantPath checks one path of a grant: a file path, or a folder
+// ending in "/".
+func validateGrantPath(p string) error {
+	dir, isDir := strings.CutSuffix(p, "/")
+	if !isDir {
+		if err := ValidateFilePath(p); err != nil {
+			return fmt.Errorf("invalid grant path %q: %w", p, err)
+		}
+		return nil
+	}
+	if dir == "" || len(p) > MaxPathLength || strings.Contains(dir, "..") || strings.Contains(dir, "//") ||
+		strings.HasPrefix(dir, "/") || strings.Contains(dir, "\\") {
+		return fmt.Errorf("invalid grant folder: %q", p)
+	}
+	return nil
+}
+
+// Allows reports whether the grant covers the file at path.
+func (cg *ContributorGrant) Allows(path string) bool {
+	for _, p := range cg.Paths {
+		if p == path || strings.HasSuffix(p, "/") && strings.HasPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Application data limits
+const (
+	MaxAppDataKeyLength = 128
+	MaxAppDataValueSize = 16 << 10
+)
+
+// AppDataRecord is one key of a site's application data: a JSON value
+// signed by the site's key, where apps hosted on the site keep settings
+// and state. Like pointers, records carry no history: the record with the
+// highest Seq for a site and key wins. A record without a value deletes
+// the key.
+type AppDataRecord struct {
+	Version string `cbor:"0,keyasint"`
+	SitePub []byte `cbor:"1,keyasint"` // 32B ed25519 pub
+	Key     string `cbor:"2,keyasint"`
+	Value   []byte `cbor:"3,keyasint"` // JSON, empty when deleted
+	Seq     uint64 `cbor:"4,keyasint"`
+	TS      int64  `cbor:"5,keyasint"`
+	Sig     []byte `cbor:"6,keyasint"` // Ed25519 by SitePriv over PreimageAppData
+}
+
+// ValidAppDataKey reports whether key is 1-128 characters of lowercase
+// letters, digits, '.', '_', '-' and '/', with '/' only between segments.
+func ValidAppDataKey(key string) bool {
+	if key == "" || len(key) > MaxAppDataKeyLength {
+		return false
+	}
+	for _, seg := range strings.Split(key, "/") {
+		if !ValidPointerName(seg) || seg == "." || seg == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// Deleted reports whether the record deletes its key.
+func (ar *AppDataRecord) Deleted() bool { return len(ar.Value) == 0 }
+
+// Validate performs structural validation of an AppDataRecord. It does not
+// verify the signature.
+func (ar *AppDataRecord) Validate() error {
+	if ar.Version == "" {
+		return errors.New("version is required")
+	}
+	if len(ar.SitePub) != 32 {
+		return fmt.Errorf("invalid site public key length: %d (expected 32)", len(ar.SitePub))
+	}
+	if !ValidAppDataKey(ar.Key) {
+		return fmt.Errorf("invalid app data key: %q", ar.Key)
+	}
+	if len(ar.Value) > MaxAppDataValueSize {
+		return fmt.Errorf("app data value too large: %d bytes (maximum %d)", len(ar.Value), MaxAppDataValueSize)
+	}
+	if !ar.Deleted() && !json.Valid(ar.Value) {
+		return errors.New("app data value is not JSON")
+	}
+	if ar.Seq == 0 {
+		return errors.New("sequence number must be greater than 0")
+	}
+	if err := CheckTimestamp(ar.TS); err != nil {
+		return err
+	}
+	if len(ar.Sig) != 64 {
+		return fmt.Errorf("invalid signature length: %d (expected 64)", len(ar.Sig))
+	}
+	return nil
+}
+
 // WebsiteFileInfo provides metadata about a file in a website
 type WebsiteFileInfo struct {
 	Path        string    `json:"path"`
@@ -258,60 +798,90 @@ type WebsiteInfo struct {
 	Files       map[string]WebsiteFileInfo `json:"files"`
 	FileCount   int                        `json:"file_count"`
 	LastUpdated time.Time                  `json:"last_updated"`
+	NoIndex     bool                       `json:"no_index"`
+	Preload     []string                   `json:"preload,omitempty"`
 }
 
 func CanonicalMarshalNoUpdateSig(r *UpdateRecord) ([]byte, error) {
 	tmp := *r
 	tmp.UpdateSig = nil
-	enc, err := cbor.CanonicalEncOptions().EncMode()
-	if err != nil {
-		return nil, err
-	}
-	return enc.Marshal(tmp)
+	return MarshalCanonical(tmp)
 }
 
 func CanonicalMarshal(r *UpdateRecord) ([]byte, error) {
-	enc, err := cbor.CanonicalEncOptions().EncMode()
-	if err != nil {
-		return nil, err
-	}
-	return enc.Marshal(r)
+	return MarshalCanonical(r)
 }
 
 func CanonicalMarshalWebsiteManifest(wm *WebsiteManifest) ([]byte, error) {
-	enc, err := cbor.CanonicalEncOptions().EncMode()
-	if err != nil {
-		return nil, err
-	}
-	return enc.Marshal(wm)
+	return MarshalCanonical(wm)
 }
 
 func CanonicalMarshalWebsiteManifestNoUpdateSig(wm *WebsiteManifest) ([]byte, error) {
 	tmp := *wm
 	tmp.UpdateSig = nil
-	enc, err := cbor.CanonicalEncOptions().EncMode()
-	if err != nil {
-		return nil, err
-	}
-	return enc.Marshal(tmp)
+	return MarshalCanonical(tmp)
 }
 
 func CanonicalMarshalFileRecord(fr *FileRecord) ([]byte, error) {
-	enc, err := cbor.CanonicalEncOptions().EncMode()
-	if err != nil {
-		return nil, err
-	}
-	return enc.Marshal(fr)
+	return MarshalCanonical(fr)
 }
 
 func CanonicalMarshalFileRecordNoUpdateSig(fr *FileRecord) ([]byte, error) {
 	tmp := *fr
 	tmp.UpdateSig = nil
-	enc, err := cbor.CanonicalEncOptions().EncMode()
-	if err != nil {
-		return nil, err
-	}
-	return enc.Marshal(tmp)
+	return MarshalCanonical(tmp)
+}
+
+func CanonicalMarshalHostingRequest(hr *HostingRequest) ([]byte, error) {
+	return MarshalCanonical(hr)
+}
+
+func CanonicalMarshalCommentNoSig(cr *CommentRecord) ([]byte, error) {
+	tmp := *cr
+	tmp.Sig = nil
+	return MarshalCanonical(tmp)
+}
+
+func CanonicalMarshalModerationNoSig(mr *ModerationRecord) ([]byte, error) {
+	tmp := *mr
+	tmp.Sig = nil
+	return MarshalCanonical(tmp)
+}
+
+func CanonicalMarshalPointerNoSig(pr *PointerRecord) ([]byte, error) {
+	tmp := *pr
+	tmp.Sig = nil
+	return MarshalCanonical(tmp)
+}
+
+func CanonicalMarshalAppDataNoSig(ar *AppDataRecord) ([]byte, error) {
+	tmp := *ar
+	tmp.Sig = nil
+	return MarshalCanonical(tmp)
+}
+
+func CanonicalMarshalDomainOfferNoSig(do *DomainOffer) ([]byte, error) {
+	tmp := *do
+	tmp.Sig = nil
+	return MarshalCanonical(tmp)
+}
+
+func CanonicalMarshalDomainAcceptNoSig(da *DomainAccept) ([]byte, error) {
+	tmp := *da
+	tmp.Sig = nil
+	return MarshalCanonical(tmp)
+}
+
+func CanonicalMarshalGatewayAuthNoSig(ga *GatewayAuth) ([]byte, error) {
+	tmp := *ga
+	tmp.Sig = nil
+	return MarshalCanonical(tmp)
+}
+
+func CanonicalMarshalContributorGrantNoSig(cg *ContributorGrant) ([]byte, error) {
+	tmp := *cg
+	tmp.Sig = nil
+	return MarshalCanonical(tmp)
 }
 
 func CIDForBytes(b []byte) string {
@@ -327,6 +897,34 @@ func NowTS() int64 {
 	return time.Now().Unix()
 }
 
+var maxClockSkew atomic.Int64 // nanoseconds, 0 for DefaultMaxClockSkew
+
+// SetMaxClockSkew sets how far in the future record timestamps may be for
+// every Validate method; d <= 0 restores DefaultMaxClockSkew.
+func SetMaxClockSkew(d time.Duration) {
+	maxClockSkew.Store(max(int64(d), 0))
+}
+
+// MaxClockSkew returns how far in the future record timestamps may be.
+func MaxClockSkew() time.Duration {
+	if d := time.Duration(maxClockSkew.Load()); d > 0 {
+		return d
+	}
+	return DefaultMaxClockSkew
+}
+
+// CheckTimestamp rejects a record timestamp that is not set or lies more
+// than MaxClockSkew ahead of the local clock.
+func CheckTimestamp(ts int64) error {
+	if ts <= 0 {
+		return fmt.Errorf("invalid timestamp: %d", ts)
+	}
+	if ts > time.Now().Add(MaxClockSkew()).Unix() {
+		return fmt.Errorf("timestamp too far in future: %d", ts)
+	}
+	return nil
+}
+
 func SiteIDFromPub(sitePub []byte) string {
 	sum := sha256.Sum256(sitePub)
 	return hex.EncodeToString(sum[:])
@@ -447,16 +1045,30 @@ func ValidateFilePath(path string) error {
 		return fmt.Errorf("file path too long: %d > %d", len(path), MaxPathLength)
 	}
 
+	if !utf8.ValidString(path) {
+		return errors.New("file path is not valid UTF-8")
+	}
+	for _, r := range path {
+		if r < 0x20 || r == 0x7f || r == '\\' {
+			return fmt.Errorf("file path contains %q", r)
+		}
+	}
+
 	// Prevent path traversal attacks
 	if strings.Contains(path, "..") || strings.Contains(path, "//") {
 		return errors.New("invalid file path: contains path traversal elements")
 	}
 
-	// Check for absolute paths
-	if filepath.IsAbs(path) {
+	// Check for absolute paths, on any OS
+	if strings.HasPrefix(path, "/") || filepath.IsAbs(path) || (len(path) >= 2 && path[1] == ':') {
 		return errors.New("absolute file paths are not allowed")
 	}
 
+	// Paths are stored in the form CanonicalFilePath gives them
+	if clean, _ := CanonicalFilePath(path); clean != path {
+		return fmt.Errorf("file path is not canonical: use %q", clean)
+	}
+
 	// Check file extension
 	ext := strings.ToLower(filepath.Ext(path))
 	if ext == "" {
@@ -486,6 +1098,31 @@ func ValidateFilePath(path string) error {
 	return nil
 }
 
+// CanonicalFilePath returns a file path given by a user or an archive in
+// the form file records store it: relative and slash-separated, with
+// backslashes taken as slashes and "." segments and repeated slashes
+// dropped. It reports false for paths that climb out of the site with
+// ".." or contain control characters. The site's root is "".
+func CanonicalFilePath(p string) (string, bool) {
+	for _, r := range p {
+		if r < 0x20 || r == 0x7f {
+			return "", false
+		}
+	}
+	p = strings.Trim(strings.ReplaceAll(p, "\\", "/"), "/")
+	if p == "" {
+		return "", true
+	}
+	clean := path.Clean(p)
+	if clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", false
+	}
+	if clean == "." {
+		return "", true
+	}
+	return clean, true
+}
+
 // ValidateContentSize validates content size against security limits
 func ValidateContentSize(size int64) error {
 	if size <= 0 {
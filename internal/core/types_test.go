@@ -5,6 +5,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/fxamacker/cbor/v2"
 )
 
 func TestUpdateRecordValidation(t *testing.T) {
@@ -17,7 +19,7 @@ func TestUpdateRecordValidation(t *testing.T) {
 		{
 			name: "valid record",
 			record: UpdateRecord{
-				Version:    "1.0",
+				Version:    RecordV1,
 				SitePub:    make([]byte, 32),
 				Seq:        1,
 				PrevCID:    "abc123",
@@ -47,7 +49,7 @@ func TestUpdateRecordValidation(t *testing.T) {
 		{
 			name: "invalid site public key length",
 			record: UpdateRecord{
-				Version:    "1.0",
+				Version:    RecordV1,
 				SitePub:    make([]byte, 16), // Too short
 				Seq:        1,
 				PrevCID:    "abc123",
@@ -63,7 +65,7 @@ func TestUpdateRecordValidation(t *testing.T) {
 		{
 			name: "sequence number zero",
 			record: UpdateRecord{
-				Version:    "1.0",
+				Version:    RecordV1,
 				SitePub:    make([]byte, 32),
 				Seq:        0, // Invalid
 				PrevCID:    "abc123",
@@ -79,7 +81,7 @@ func TestUpdateRecordValidation(t *testing.T) {
 		{
 			name: "invalid timestamp",
 			record: UpdateRecord{
-				Version:    "1.0",
+				Version:    RecordV1,
 				SitePub:    make([]byte, 32),
 				Seq:        1,
 				PrevCID:    "abc123",
@@ -95,7 +97,7 @@ func TestUpdateRecordValidation(t *testing.T) {
 		{
 			name: "future timestamp",
 			record: UpdateRecord{
-				Version:    "1.0",
+				Version:    RecordV1,
 				SitePub:    make([]byte, 32),
 				Seq:        1,
 				PrevCID:    "abc123",
@@ -111,7 +113,7 @@ func TestUpdateRecordValidation(t *testing.T) {
 		{
 			name: "invalid update public key length",
 			record: UpdateRecord{
-				Version:    "1.0",
+				Version:    RecordV1,
 				SitePub:    make([]byte, 32),
 				Seq:        1,
 				PrevCID:    "abc123",
@@ -127,7 +129,7 @@ func TestUpdateRecordValidation(t *testing.T) {
 		{
 			name: "invalid link signature length",
 			record: UpdateRecord{
-				Version:    "1.0",
+				Version:    RecordV1,
 				SitePub:    make([]byte, 32),
 				Seq:        1,
 				PrevCID:    "abc123",
@@ -143,7 +145,7 @@ func TestUpdateRecordValidation(t *testing.T) {
 		{
 			name: "invalid update signature length",
 			record: UpdateRecord{
-				Version:    "1.0",
+				Version:    RecordV1,
 				SitePub:    make([]byte, 32),
 				Seq:        1,
 				PrevCID:    "abc123",
@@ -159,7 +161,7 @@ func TestUpdateRecordValidation(t *testing.T) {
 		{
 			name: "missing content CID",
 			record: UpdateRecord{
-				Version:    "1.0",
+				Version:    RecordV1,
 				SitePub:    make([]byte, 32),
 				Seq:        1,
 				PrevCID:    "abc123",
@@ -175,7 +177,7 @@ func TestUpdateRecordValidation(t *testing.T) {
 		{
 			name: "invalid hex string in prev CID",
 			record: UpdateRecord{
-				Version:    "1.0",
+				Version:    RecordV1,
 				SitePub:    make([]byte, 32),
 				Seq:        1,
 				PrevCID:    "invalid-hex!", // Invalid hex
@@ -191,7 +193,7 @@ func TestUpdateRecordValidation(t *testing.T) {
 		{
 			name: "invalid hex string in content CID",
 			record: UpdateRecord{
-				Version:    "1.0",
+				Version:    RecordV1,
 				SitePub:    make([]byte, 32),
 				Seq:        1,
 				PrevCID:    "abc123",
@@ -204,6 +206,68 @@ func TestUpdateRecordValidation(t *testing.T) {
 			wantErr: true,
 			errMsg:  "invalid content CID format",
 		},
+		{
+			name: "v2 record",
+			record: UpdateRecord{
+				Version:    RecordV2,
+				SitePub:    make([]byte, 32),
+				Seq:        1,
+				ContentCID: "def456",
+				TS:         time.Now().Unix(),
+				UpdatePub:  make([]byte, 32),
+				LinkSig:    make([]byte, 64),
+				UpdateSig:  make([]byte, 64),
+				Extensions: map[uint64]cbor.RawMessage{40: {0xf5}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "unsupported version",
+			record: UpdateRecord{
+				Version:    RecordVersion(3),
+				SitePub:    make([]byte, 32),
+				Seq:        1,
+				ContentCID: "def456",
+				TS:         time.Now().Unix(),
+				UpdatePub:  make([]byte, 32),
+				LinkSig:    make([]byte, 64),
+				UpdateSig:  make([]byte, 64),
+			},
+			wantErr: true,
+			errMsg:  "unsupported record version v3",
+		},
+		{
+			name: "field unknown to v1",
+			record: UpdateRecord{
+				Version:    RecordV1,
+				SitePub:    make([]byte, 32),
+				Seq:        1,
+				ContentCID: "def456",
+				TS:         time.Now().Unix(),
+				UpdatePub:  make([]byte, 32),
+				LinkSig:    make([]byte, 64),
+				UpdateSig:  make([]byte, 64),
+				Extensions: map[uint64]cbor.RawMessage{40: {0xf5}},
+			},
+			wantErr: true,
+			errMsg:  "unknown field 40 in a v1 record",
+		},
+		{
+			name: "reserved v2 field",
+			record: UpdateRecord{
+				Version:    RecordV2,
+				SitePub:    make([]byte, 32),
+				Seq:        1,
+				ContentCID: "def456",
+				TS:         time.Now().Unix(),
+				UpdatePub:  make([]byte, 32),
+				LinkSig:    make([]byte, 64),
+				UpdateSig:  make([]byte, 64),
+				Extensions: map[uint64]cbor.RawMessage{12: {0xf5}},
+			},
+			wantErr: true,
+			errMsg:  "reserved field 12",
+		},
 	}
 
 	for _, tt := range tests {
@@ -467,6 +531,252 @@ func TestDeleteRecordValidation(t *testing.T) {
 	}
 }
 
+func TestHostingRequestValidation(t *testing.T) {
+	now := time.Now().Unix()
+	valid := func() HostingRequest {
+		return HostingRequest{
+			Version:  "v1",
+			SitePub:  make([]byte, 32),
+			HostPeer: "12D3KooWExample",
+			Expires:  now + 86400,
+			TS:       now,
+			Sig:      make([]byte, 64),
+		}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*HostingRequest)
+		errMsg string
+	}{
+		{"valid request", func(*HostingRequest) {}, ""},
+		{"missing version", func(r *HostingRequest) { r.Version = "" }, "version is required"},
+		{"short site key", func(r *HostingRequest) { r.SitePub = make([]byte, 16) }, "invalid site public key length"},
+		{"missing host", func(r *HostingRequest) { r.HostPeer = "" }, "host peer is required"},
+		{"zero timestamp", func(r *HostingRequest) { r.TS = 0 }, "invalid timestamp"},
+		{"future timestamp", func(r *HostingRequest) { r.TS = now + 7200; r.Expires = now + 86400 }, "timestamp too far in future"},
+		{"expiry before timestamp", func(r *HostingRequest) { r.Expires = now }, "expiry must be after"},
+		{"duration too long", func(r *HostingRequest) { r.Expires = now + int64(MaxHostingDuration/time.Second) + 1 }, "hosting duration too long"},
+		{"maximum duration", func(r *HostingRequest) { r.Expires = now + int64(MaxHostingDuration/time.Second) }, ""},
+		{"short signature", func(r *HostingRequest) { r.Sig = make([]byte, 32) }, "invalid signature length"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := valid()
+			tt.modify(&req)
+			err := req.Validate()
+			if (err != nil) != (tt.errMsg != "") {
+				t.Fatalf("Validate() error = %v, want error %q", err, tt.errMsg)
+			}
+			if err != nil && !contains(err.Error(), tt.errMsg) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.errMsg)
+			}
+		})
+	}
+}
+
+func TestDomainOfferValidation(t *testing.T) {
+	now := time.Now().Unix()
+	valid := func() DomainOffer {
+		return DomainOffer{
+			Version:   "v1",
+			Domain:    "shop",
+			SellerPub: make([]byte, 32),
+			Buyer:     strings.Repeat("b", 64),
+			Note:      "10 coffees",
+			Expires:   now + 86400,
+			TS:        now,
+			Sig:       make([]byte, 64),
+		}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*DomainOffer)
+		errMsg string
+	}{
+		{"valid offer", func(*DomainOffer) {}, ""},
+		{"missing version", func(o *DomainOffer) { o.Version = "" }, "version is required"},
+		{"short name", func(o *DomainOffer) { o.Domain = "ab" }, "invalid domain name"},
+		{"uppercase name", func(o *DomainOffer) { o.Domain = "Shop" }, "invalid domain name"},
+		{"short seller key", func(o *DomainOffer) { o.SellerPub = make([]byte, 16) }, "invalid seller public key length"},
+		{"malformed buyer", func(o *DomainOffer) { o.Buyer = "shop" }, "invalid buyer site ID"},
+		{"offered to itself", func(o *DomainOffer) { o.Buyer = SiteIDFromPub(o.SellerPub) }, "cannot be offered to the site holding it"},
+		{"long note", func(o *DomainOffer) { o.Note = strings.Repeat("x", MaxDomainOfferNote+1) }, "note too long"},
+		{"invalid UTF-8 note", func(o *DomainOffer) { o.Note = "\xff" }, "not valid UTF-8"},
+		{"future timestamp", func(o *DomainOffer) { o.TS = now + 7200; o.Expires = now + 86400 }, "timestamp too far in future"},
+		{"expiry before timestamp", func(o *DomainOffer) { o.Expires = now }, "expiry must be after"},
+		{"open too long", func(o *DomainOffer) { o.Expires = now + int64(MaxDomainOfferDuration/time.Second) + 1 }, "offer duration too long"},
+		{"short signature", func(o *DomainOffer) { o.Sig = make([]byte, 32) }, "invalid signature length"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			offer := valid()
+			tt.modify(&offer)
+			err := offer.Validate()
+			if (err != nil) != (tt.errMsg != "") {
+				t.Fatalf("Validate() error = %v, want error %q", err, tt.errMsg)
+			}
+			if err != nil && !contains(err.Error(), tt.errMsg) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.errMsg)
+			}
+		})
+	}
+}
+
+func TestGatewayAuthValidation(t *testing.T) {
+	now := time.Now().Unix()
+	valid := func() GatewayAuth {
+		return GatewayAuth{
+			Version: "v1",
+			SitePub: make([]byte, 32),
+			Host:    "mirror.example.org",
+			Expires: now + 86400,
+			TS:      now,
+			Sig:     make([]byte, 64),
+		}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*GatewayAuth)
+		errMsg string
+	}{
+		{"valid authorization", func(*GatewayAuth) {}, ""},
+		{"missing version", func(a *GatewayAuth) { a.Version = "" }, "version is required"},
+		{"short site key", func(a *GatewayAuth) { a.SitePub = make([]byte, 16) }, "invalid site public key length"},
+		{"single label", func(a *GatewayAuth) { a.Host = "localhost" }, "invalid gateway host"},
+		{"uppercase host", func(a *GatewayAuth) { a.Host = "Mirror.example.org" }, "invalid gateway host"},
+		{"host with port", func(a *GatewayAuth) { a.Host = "mirror.example.org:8080" }, "invalid gateway host"},
+		{"trailing dot", func(a *GatewayAuth) { a.Host = "mirror.example.org." }, "invalid gateway host"},
+		{"leading hyphen", func(a *GatewayAuth) { a.Host = "-mirror.example.org" }, "invalid gateway host"},
+		{"long label", func(a *GatewayAuth) { a.Host = strings.Repeat("a", 64) + ".org" }, "invalid gateway host"},
+		{"future timestamp", func(a *GatewayAuth) { a.TS = now + 7200; a.Expires = now + 86400 }, "timestamp too far in future"},
+		{"expiry before timestamp", func(a *GatewayAuth) { a.Expires = now }, "expiry must be after"},
+		{"valid too long", func(a *GatewayAuth) { a.Expires = now + int64(MaxGatewayAuthDuration/time.Second) + 1 }, "valid too long"},
+		{"short signature", func(a *GatewayAuth) { a.Sig = make([]byte, 32) }, "invalid signature length"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth := valid()
+			tt.modify(&auth)
+			err := auth.Validate()
+			if (err != nil) != (tt.errMsg != "") {
+				t.Fatalf("Validate() error = %v, want error %q", err, tt.errMsg)
+			}
+			if err != nil && !contains(err.Error(), tt.errMsg) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.errMsg)
+			}
+		})
+	}
+}
+
+func TestContributorGrantValidation(t *testing.T) {
+	now := time.Now().Unix()
+	valid := func() ContributorGrant {
+		contributor := make([]byte, 32)
+		contributor[0] = 1
+		return ContributorGrant{
+			Version:        "v1",
+			SitePub:        make([]byte, 32),
+			ContributorPub: contributor,
+			Paths:          []string{"blog/", "about.html"},
+			Expires:        now + 86400,
+			TS:             now,
+			Sig:            make([]byte, 64),
+		}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*ContributorGrant)
+		errMsg string
+	}{
+		{"valid grant", func(*ContributorGrant) {}, ""},
+		{"missing version", func(g *ContributorGrant) { g.Version = "" }, "version is required"},
+		{"short contributor key", func(g *ContributorGrant) { g.ContributorPub = make([]byte, 16) }, "invalid contributor public key length"},
+		{"contributor is site", func(g *ContributorGrant) { g.ContributorPub = make([]byte, 32) }, "contributor key is the site key"},
+		{"no paths", func(g *ContributorGrant) { g.Paths = nil }, "1 to"},
+		{"too many paths", func(g *ContributorGrant) { g.Paths = make([]string, MaxGrantPaths+1) }, "1 to"},
+		{"traversal", func(g *ContributorGrant) { g.Paths = []string{"../secret.html"} }, "invalid grant path"},
+		{"root folder", func(g *ContributorGrant) { g.Paths = []string{"/"} }, "invalid grant folder"},
+		{"absolute folder", func(g *ContributorGrant) { g.Paths = []string{"/blog/"} }, "invalid grant folder"},
+		{"traversal folder", func(g *ContributorGrant) { g.Paths = []string{"blog/../"} }, "invalid grant folder"},
+		{"expiry before timestamp", func(g *ContributorGrant) { g.Expires = now }, "expiry must be after"},
+		{"valid too long", func(g *ContributorGrant) { g.Expires = now + int64(MaxContributorGrantDuration/time.Second) + 1 }, "valid too long"},
+		{"short signature", func(g *ContributorGrant) { g.Sig = make([]byte, 32) }, "invalid signature length"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			grant := valid()
+			tt.modify(&grant)
+			err := grant.Validate()
+			if (err != nil) != (tt.errMsg != "") {
+				t.Fatalf("Validate() error = %v, want error %q", err, tt.errMsg)
+			}
+			if err != nil && !contains(err.Error(), tt.errMsg) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.errMsg)
+			}
+		})
+	}
+
+	grant := valid()
+	for path, want := range map[string]bool{
+		"about.html":       true,
+		"blog/post.md":     true,
+		"blog/2024/a.html": true,
+		"blog":             false,
+		"blogroll.html":    false,
+		"index.html":       false,
+	} {
+		if got := grant.Allows(path); got != want {
+			t.Errorf("Allows(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestCheckTimestamp(t *testing.T) {
+	t.Cleanup(func() { SetMaxClockSkew(0) })
+	now := time.Now().Unix()
+	tests := []struct {
+		name   string
+		skew   time.Duration
+		ts     int64
+		errMsg string
+	}{
+		{"zero", 0, 0, "invalid timestamp"},
+		{"negative", 0, -1, "invalid timestamp"},
+		{"now", 0, now, ""},
+		{"within default skew", 0, now + 1800, ""},
+		{"beyond default skew", 0, now + 7200, "timestamp too far in future"},
+		{"within configured skew", 5 * time.Minute, now + 60, ""},
+		{"beyond configured skew", 5 * time.Minute, now + 600, "timestamp too far in future"},
+		{"wider configured skew", 3 * time.Hour, now + 7200, ""},
+		{"past", 5 * time.Minute, now - 86400, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetMaxClockSkew(tt.skew)
+			err := CheckTimestamp(tt.ts)
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("CheckTimestamp(%d) = %v, want nil", tt.ts, err)
+				}
+			} else if err == nil || !contains(err.Error(), tt.errMsg) {
+				t.Errorf("CheckTimestamp(%d) = %v, want %q", tt.ts, err, tt.errMsg)
+			}
+		})
+	}
+	SetMaxClockSkew(0)
+	if got := MaxClockSkew(); got != DefaultMaxClockSkew {
+		t.Errorf("MaxClockSkew() after reset = %s, want %s", got, DefaultMaxClockSkew)
+	}
+}
+
 func TestValidateFilePath(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -488,6 +798,12 @@ func TestValidateFilePath(t *testing.T) {
 		{"invalid extension", "script.bat", true, "file extension not allowed"},
 		{"reserved name", "con.txt", true, "file name is reserved"},
 		{"reserved name with extension", "lpt1.html", true, "file name is reserved"},
+		{"control character", "a\tb.html", true, "file path contains"},
+		{"backslash", `css\style.css`, true, "file path contains"},
+		{"not UTF-8", "caf\xe9.html", true, "not valid UTF-8"},
+		{"drive letter", "c:/index.html", true, "absolute file paths are not allowed"},
+		{"dot segment", "css/./style.css", true, "not canonical"},
+		{"trailing slash", "css/", true, "not canonical"},
 	}
 
 	for _, tt := range tests {
@@ -506,6 +822,34 @@ func TestValidateFilePath(t *testing.T) {
 	}
 }
 
+func TestCanonicalFilePath(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"", "", true},
+		{"/", "", true},
+		{"/index.html", "index.html", true},
+		{"css/./site.css", "css/site.css", true},
+		{"css//site.css", "css/site.css", true},
+		{"docs/", "docs", true},
+		{"a/../b.html", "b.html", true},
+		{`css\site.css`, "css/site.css", true},
+		{"../secret", "", false},
+		{"a/../../secret", "", false},
+		{`..\secret`, "", false},
+		{"bad\x00name", "", false},
+		{"line\nbreak.html", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := CanonicalFilePath(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("CanonicalFilePath(%q) = %q, %v; want %q, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
 func TestValidateContentSize(t *testing.T) {
 	tests := []struct {
 		name    string
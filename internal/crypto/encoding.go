@@ -0,0 +1,146 @@
+package crypto
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Supported textual key encodings
+const (
+	FormatHex    = "hex"
+	FormatBase64 = "base64"
+	FormatPEM    = "pem"
+)
+
+// EncodePublicKey renders an Ed25519 public key in the requested format.
+func EncodePublicKey(pub ed25519.PublicKey, format string) (string, error) {
+	if len(pub) != ed25519.PublicKeySize {
+		return "", fmt.Errorf("invalid public key length: %d (expected %d)", len(pub), ed25519.PublicKeySize)
+	}
+	switch strings.ToLower(format) {
+	case FormatHex, "":
+		return hex.EncodeToString(pub), nil
+	case FormatBase64:
+		return base64.StdEncoding.EncodeToString(pub), nil
+	case FormatPEM:
+		der, err := x509.MarshalPKIXPublicKey(pub)
+		if err != nil {
+			return "", err
+		}
+		return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+	default:
+		return "", fmt.Errorf("unsupported key format: %s", format)
+	}
+}
+
+// EncodePrivateKey renders an Ed25519 private key in the requested format.
+// Hex and base64 encode the full 64-byte key; PEM uses PKCS#8.
+func EncodePrivateKey(priv ed25519.PrivateKey, format string) (string, error) {
+	if len(priv) != ed25519.PrivateKeySize {
+		return "", fmt.Errorf("invalid private key length: %d (expected %d)", len(priv), ed25519.PrivateKeySize)
+	}
+	switch strings.ToLower(format) {
+	case FormatHex, "":
+		return hex.EncodeToString(priv), nil
+	case FormatBase64:
+		return base64.StdEncoding.EncodeToString(priv), nil
+	case FormatPEM:
+		der, err := x509.MarshalPKCS8PrivateKey(priv)
+		if err != nil {
+			return "", err
+		}
+		return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+	default:
+		return "", fmt.Errorf("unsupported key format: %s", format)
+	}
+}
+
+// DecodeBytes decodes s using the given format (hex or base64). The format
+// must be explicit: many base64 strings are also valid hex, so guessing would
+// silently return the wrong bytes.
+func DecodeBytes(s, format string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, errors.New("empty input")
+	}
+	switch strings.ToLower(format) {
+	case FormatHex, "":
+		return hex.DecodeString(s)
+	case FormatBase64:
+		return base64.StdEncoding.DecodeString(s)
+	default:
+		return nil, fmt.Errorf("unsupported encoding: %s", format)
+	}
+}
+
+// ParsePublicKey decodes an Ed25519 public key encoded in the given format.
+func ParsePublicKey(s, format string) (ed25519.PublicKey, error) {
+	if strings.ToLower(format) == FormatPEM {
+		block, _ := pem.Decode([]byte(strings.TrimSpace(s)))
+		if block == nil {
+			return nil, errors.New("no PEM block found")
+		}
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse PEM public key: %w", err)
+		}
+		pub, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return nil, errors.New("PEM public key is not Ed25519")
+		}
+		return pub, nil
+	}
+	b, err := DecodeBytes(s, format)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key length: %d (expected %d)", len(b), ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(b), nil
+}
+
+// ParsePrivateKey decodes an Ed25519 private key encoded in the given format.
+// PEM input must be PKCS#8; hex and base64 may hold either the 64-byte key or
+// its 32-byte seed.
+func ParsePrivateKey(s, format string) (ed25519.PrivateKey, error) {
+	if strings.ToLower(format) == FormatPEM {
+		block, _ := pem.Decode([]byte(strings.TrimSpace(s)))
+		if block == nil {
+			return nil, errors.New("no PEM block found")
+		}
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse PEM private key: %w", err)
+		}
+		priv, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			return nil, errors.New("PEM private key is not Ed25519")
+		}
+		return priv, nil
+	}
+	b, err := DecodeBytes(s, format)
+	if err != nil {
+		return nil, err
+	}
+	switch len(b) {
+	case ed25519.PrivateKeySize:
+		priv := ed25519.PrivateKey(b)
+		// The second half of an Ed25519 private key is its public key;
+		// reject inputs where the two halves don't belong together.
+		if !ed25519.NewKeyFromSeed(priv.Seed()).Equal(priv) {
+			return nil, errors.New("private key does not match its embedded public key")
+		}
+		return priv, nil
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(b), nil
+	default:
+		return nil, fmt.Errorf("invalid private key length: %d (expected %d or %d)", len(b), ed25519.PrivateKeySize, ed25519.SeedSize)
+	}
+}
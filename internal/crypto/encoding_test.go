@@ -0,0 +1,168 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"strings"
+	"testing"
+)
+
+func TestPublicKeyRoundTrip(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	for _, format := range []string{FormatHex, FormatBase64, FormatPEM} {
+		t.Run(format, func(t *testing.T) {
+			encoded, err := EncodePublicKey(pub, format)
+			if err != nil {
+				t.Fatalf("EncodePublicKey(%s) error = %v", format, err)
+			}
+			got, err := ParsePublicKey(encoded, format)
+			if err != nil {
+				t.Fatalf("ParsePublicKey(%s) error = %v", format, err)
+			}
+			if !got.Equal(pub) {
+				t.Errorf("ParsePublicKey(%s) returned a different key", format)
+			}
+		})
+	}
+}
+
+func TestPrivateKeyRoundTrip(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	for _, format := range []string{FormatHex, FormatBase64, FormatPEM} {
+		t.Run(format, func(t *testing.T) {
+			encoded, err := EncodePrivateKey(priv, format)
+			if err != nil {
+				t.Fatalf("EncodePrivateKey(%s) error = %v", format, err)
+			}
+			got, err := ParsePrivateKey(encoded, format)
+			if err != nil {
+				t.Fatalf("ParsePrivateKey(%s) error = %v", format, err)
+			}
+			if !got.Equal(priv) {
+				t.Errorf("ParsePrivateKey(%s) returned a different key", format)
+			}
+		})
+	}
+}
+
+func TestParsePrivateKeyFromSeed(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		input  string
+		format string
+	}{
+		{"hex seed", hex.EncodeToString(priv.Seed()), FormatHex},
+		{"base64 seed", base64.StdEncoding.EncodeToString(priv.Seed()), FormatBase64},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePrivateKey(tt.input, tt.format)
+			if err != nil {
+				t.Fatalf("ParsePrivateKey() error = %v", err)
+			}
+			if !got.Equal(priv) {
+				t.Errorf("ParsePrivateKey() did not expand the seed to the original key")
+			}
+		})
+	}
+}
+
+func TestParseKeyRejectsBadInput(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey: %v", err)
+	}
+	ecPubDER, err := x509.MarshalPKIXPublicKey(&ecKey.PublicKey)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey: %v", err)
+	}
+	ecPrivDER, err := x509.MarshalPKCS8PrivateKey(ecKey)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey: %v", err)
+	}
+	ecPubPEM := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: ecPubDER}))
+	ecPrivPEM := string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: ecPrivDER}))
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	mismatched := append([]byte{}, priv...)
+	mismatched[40] ^= 0xff
+
+	tests := []struct {
+		name    string
+		private bool
+		input   string
+		format  string
+		errMsg  string
+	}{
+		{"short public key", false, hex.EncodeToString(make([]byte, 16)), FormatHex, "invalid public key length"},
+		{"long public key", false, base64.StdEncoding.EncodeToString(make([]byte, 33)), FormatBase64, "invalid public key length"},
+		{"non-Ed25519 public PEM", false, ecPubPEM, FormatPEM, "not Ed25519"},
+		{"public key without PEM block", false, "deadbeef", FormatPEM, "no PEM block"},
+		{"bad private key length", true, hex.EncodeToString(make([]byte, 48)), FormatHex, "invalid private key length"},
+		{"non-Ed25519 private PEM", true, ecPrivPEM, FormatPEM, "not Ed25519"},
+		{"mismatched private key halves", true, hex.EncodeToString(mismatched), FormatHex, "does not match"},
+		{"unknown format", false, "00", "base58", "unsupported encoding"},
+		{"empty input", true, "  ", FormatHex, "empty input"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.private {
+				_, err = ParsePrivateKey(tt.input, tt.format)
+			} else {
+				_, err = ParsePublicKey(tt.input, tt.format)
+			}
+			if err == nil {
+				t.Fatalf("expected error containing %q, got nil", tt.errMsg)
+			}
+			if !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.errMsg)
+			}
+		})
+	}
+}
+
+func TestDecodeBytesDoesNotGuess(t *testing.T) {
+	// "deadbeef" is valid hex and valid base64; the decoded bytes must
+	// depend only on the requested format.
+	const input = "deadbeef"
+
+	fromHex, err := DecodeBytes(input, FormatHex)
+	if err != nil {
+		t.Fatalf("DecodeBytes(hex) error = %v", err)
+	}
+	fromB64, err := DecodeBytes(input, FormatBase64)
+	if err != nil {
+		t.Fatalf("DecodeBytes(base64) error = %v", err)
+	}
+	if len(fromHex) != 4 || len(fromB64) != 6 {
+		t.Errorf("DecodeBytes lengths = %d (hex), %d (base64), want 4 and 6", len(fromHex), len(fromB64))
+	}
+
+	if _, err := DecodeBytes("not hex!", FormatHex); err == nil {
+		t.Error("DecodeBytes(hex) accepted non-hex input")
+	}
+}
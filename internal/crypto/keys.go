@@ -4,6 +4,7 @@ import (
 	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/sha256"
+	"encoding/binary"
 )
 
 func GenerateSiteKey() (ed25519.PublicKey, ed25519.PrivateKey, error) {
@@ -37,6 +38,24 @@ func PreimageLink(sitePub, updatePub []byte, seq uint64, prevCID, contentCID str
 	return h.Sum(nil)
 }
 
+// PreimageFileLink binds SitePub to the UpdatePub of a file record for a
+// given (path, contentCID, ts). This is signed by the long-term Site private
+// key.
+func PreimageFileLink(sitePub, updatePub []byte, path, contentCID string, ts int64) []byte {
+	h := sha256.New()
+	h.Write([]byte("bn-filelink-v1"))
+	h.Write(sitePub)
+	h.Write(updatePub)
+	var n [8]byte
+	binary.BigEndian.PutUint64(n[:], uint64(len(path)))
+	h.Write(n[:]) // length-prefixed: paths are free-form
+	h.Write([]byte(path))
+	h.Write([]byte(contentCID))
+	binary.BigEndian.PutUint64(n[:], uint64(ts))
+	h.Write(n[:])
+	return h.Sum(nil)
+}
+
 // PreimageUpdate is signed by the per-update ephemeral key over the record
 // bytes with UpdateSig field cleared, then prefixed with a domain tag.
 func PreimageUpdate(recordBytes []byte) []byte {
@@ -44,6 +63,84 @@ func PreimageUpdate(recordBytes []byte) []byte {
 	return sum[:]
 }
 
+// PreimageComment is signed by a visitor's key over the comment record bytes
+// with Sig cleared, under a domain separation tag.
+func PreimageComment(recordBytes []byte) []byte {
+	sum := sha256.Sum256(append([]byte("bn-comment-v1"), recordBytes...))
+	return sum[:]
+}
+
+// PreimageModeration is signed by the Site private key over the moderation
+// record bytes with Sig cleared, under a domain separation tag.
+func PreimageModeration(recordBytes []byte) []byte {
+	sum := sha256.Sum256(append([]byte("bn-mod-v1"), recordBytes...))
+	return sum[:]
+}
+
+// PreimagePointer is signed by the owner's private key over the pointer
+// record bytes with Sig cleared, under a domain separation tag.
+func PreimagePointer(recordBytes []byte) []byte {
+	sum := sha256.Sum256(append([]byte("bn-pointer-v1"), recordBytes...))
+	return sum[:]
+}
+
+// PreimageAppData is signed by the site's private key over the app data
+// record bytes with Sig cleared, under a domain separation tag.
+func PreimageAppData(recordBytes []byte) []byte {
+	sum := sha256.Sum256(append([]byte("bn-appdata-v1"), recordBytes...))
+	return sum[:]
+}
+
+// PreimageDomainOffer is signed by the selling site's key over the domain
+// offer bytes with Sig cleared, under a domain separation tag.
+func PreimageDomainOffer(recordBytes []byte) []byte {
+	sum := sha256.Sum256(append([]byte("bn-domoffer-v1"), recordBytes...))
+	return sum[:]
+}
+
+// PreimageDomainAccept is signed by the buying site's key over the domain
+// acceptance bytes with Sig cleared, under a domain separation tag.
+func PreimageDomainAccept(recordBytes []byte) []byte {
+	sum := sha256.Sum256(append([]byte("bn-domaccept-v1"), recordBytes...))
+	return sum[:]
+}
+
+// PreimageGatewayAuth is signed by a site's key over a gateway
+// authorization with Sig cleared, under a domain separation tag.
+func PreimageGatewayAuth(recordBytes []byte) []byte {
+	sum := sha256.Sum256(append([]byte("bn-gateway-v1"), recordBytes...))
+	return sum[:]
+}
+
+// PreimageContributorGrant is signed by a site's key over a contributor
+// grant with Sig cleared, under a domain separation tag.
+func PreimageContributorGrant(recordBytes []byte) []byte {
+	sum := sha256.Sum256(append([]byte("bn-grant-v1"), recordBytes...))
+	return sum[:]
+}
+
+// PreimageSitemap is signed by a node's libp2p key over a sitemap page with
+// Sig cleared, under a domain separation tag.
+func PreimageSitemap(pageBytes []byte) []byte {
+	sum := sha256.Sum256(append([]byte("bn-sitemap-v1"), pageBytes...))
+	return sum[:]
+}
+
+// StorageProofDigest answers a storage challenge: a hash of the challenged
+// byte range of a blob, bound to the challenger's nonce so it cannot be
+// computed ahead of time or replayed.
+func StorageProofDigest(nonce []byte, cid string, offset int64, data []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte("bn-proof-v1"))
+	h.Write(nonce)
+	h.Write([]byte(cid))
+	var o [8]byte
+	binary.BigEndian.PutUint64(o[:], uint64(offset))
+	h.Write(o[:])
+	h.Write(data)
+	return h.Sum(nil)
+}
+
 // PreimageDelete is signed by the Site private key to authorize deletion of a
 // specific record/content. Fields are concatenated in fixed order under a
 // domain separation tag.
@@ -61,3 +158,21 @@ func PreimageDelete(sitePub []byte, targetRecCID, targetContentCID string, ts in
 	h.Write(t[:])
 	return h.Sum(nil)
 }
+
+// PreimageHostingRequest is signed by the Site private key to ask a specific
+// node (identified by its libp2p peer ID) to host the site until expires.
+func PreimageHostingRequest(sitePub []byte, hostPeer string, expires, ts int64) []byte {
+	h := sha256.New()
+	h.Write([]byte("bn-host-v1"))
+	h.Write(sitePub)
+	h.Write([]byte(hostPeer))
+	var t [8]byte
+	for _, v := range []int64{expires, ts} {
+		u := uint64(v)
+		for i := 0; i < 8; i++ {
+			t[7-i] = byte(u >> (8 * i))
+		}
+		h.Write(t[:])
+	}
+	return h.Sum(nil)
+}
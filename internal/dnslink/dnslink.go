@@ -0,0 +1,195 @@
+// Package dnslink maps traditional DNS names to AlxNet sites using TXT
+// records published under _alxnet.<domain>.
+//
+// A domain opts in with a record of the form
+//
+//	_alxnet.example.com. TXT "alxnet=<64 hex site ID>"
+//
+// Any other TXT strings on the name are ignored. Records naming more than
+// one distinct site are rejected rather than picking one.
+package dnslink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RecordPrefix is the label queried in front of the domain.
+const RecordPrefix = "_alxnet."
+
+// ValuePrefix marks TXT strings that carry a site ID.
+const ValuePrefix = "alxnet="
+
+// Cache defaults
+const (
+	DefaultTTL      = 5 * time.Minute
+	DefaultNegTTL   = 1 * time.Minute
+	MaxCacheEntries = 1024
+	LookupTimeout   = 5 * time.Second
+	maxDomainLength = 253
+	maxLabelLength  = 63
+)
+
+// ErrNoRecord is returned when a domain has no usable _alxnet TXT record.
+var ErrNoRecord = errors.New("no _alxnet TXT record")
+
+// LookupTXTFunc resolves TXT records for a fully qualified name.
+type LookupTXTFunc func(ctx context.Context, name string) ([]string, error)
+
+type cacheEntry struct {
+	siteID  string
+	err     error
+	expires time.Time
+}
+
+// Resolver resolves domains to site IDs and caches the answers.
+type Resolver struct {
+	lookup LookupTXTFunc
+	ttl    time.Duration
+	negTTL time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cacheEntry
+}
+
+// NewResolver returns a resolver using the system DNS resolver.
+func NewResolver() *Resolver {
+	return NewResolverWithLookup(net.DefaultResolver.LookupTXT)
+}
+
+// NewResolverWithLookup returns a resolver using a custom TXT lookup.
+func NewResolverWithLookup(lookup LookupTXTFunc) *Resolver {
+	return &Resolver{
+		lookup: lookup,
+		ttl:    DefaultTTL,
+		negTTL: DefaultNegTTL,
+		cache:  make(map[string]cacheEntry),
+	}
+}
+
+// IsDomain reports whether name looks like a DNS name that may carry an
+// _alxnet record: at least two labels, LDH characters only, and not an
+// IP address or a .bn site name.
+func IsDomain(name string) bool {
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+	if len(name) == 0 || len(name) > maxDomainLength {
+		return false
+	}
+	if net.ParseIP(name) != nil || strings.HasSuffix(name, ".bn") {
+		return false
+	}
+	labels := strings.Split(name, ".")
+	if len(labels) < 2 {
+		return false
+	}
+	for _, label := range labels {
+		if len(label) == 0 || len(label) > maxLabelLength {
+			return false
+		}
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if !((c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// ParseTXT extracts the site ID from the TXT strings of an _alxnet name.
+func ParseTXT(records []string) (string, error) {
+	var siteID string
+	for _, rec := range records {
+		rec = strings.TrimSpace(rec)
+		if !strings.HasPrefix(rec, ValuePrefix) {
+			continue
+		}
+		id := strings.TrimPrefix(rec, ValuePrefix)
+		if !isSiteID(id) {
+			return "", fmt.Errorf("malformed site ID in TXT record: %q", rec)
+		}
+		if siteID != "" && siteID != id {
+			return "", errors.New("TXT records name more than one site")
+		}
+		siteID = id
+	}
+	if siteID == "" {
+		return "", ErrNoRecord
+	}
+	return siteID, nil
+}
+
+// Resolve returns the site ID published for domain.
+func (r *Resolver) Resolve(ctx context.Context, domain string) (string, error) {
+	domain = strings.TrimSuffix(strings.ToLower(domain), ".")
+	if !IsDomain(domain) {
+		return "", fmt.Errorf("invalid domain: %q", domain)
+	}
+
+	now := time.Now()
+	r.mu.Lock()
+	if e, ok := r.cache[domain]; ok && now.Before(e.expires) {
+		r.mu.Unlock()
+		return e.siteID, e.err
+	}
+	r.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, LookupTimeout)
+	defer cancel()
+	records, err := r.lookup(ctx, RecordPrefix+domain)
+	var siteID string
+	if err == nil {
+		siteID, err = ParseTXT(records)
+	} else {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			err = ErrNoRecord
+		} else {
+			// Transient failures are not cached
+			return "", err
+		}
+	}
+
+	ttl := r.ttl
+	if err != nil {
+		ttl = r.negTTL
+	}
+	r.mu.Lock()
+	if len(r.cache) >= MaxCacheEntries {
+		r.evictLocked(now)
+	}
+	r.cache[domain] = cacheEntry{siteID: siteID, err: err, expires: now.Add(ttl)}
+	r.mu.Unlock()
+	return siteID, err
+}
+
+// evictLocked drops expired entries, or everything if none have expired.
+func (r *Resolver) evictLocked(now time.Time) {
+	for k, e := range r.cache {
+		if !now.Before(e.expires) {
+			delete(r.cache, k)
+		}
+	}
+	if len(r.cache) >= MaxCacheEntries {
+		r.cache = make(map[string]cacheEntry)
+	}
+}
+
+func isSiteID(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	for _, c := range s {
+		if !((c >= '0' && c <= '9') || (c >= 'a' && c <= 'f')) {
+			return false
+		}
+	}
+	return true
+}
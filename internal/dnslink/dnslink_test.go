@@ -0,0 +1,121 @@
+package dnslink
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+const testSiteID = "aa00000000000000000000000000000000000000000000000000000000000000"
+const otherSiteID = "bb00000000000000000000000000000000000000000000000000000000000000"
+
+func TestIsDomain(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"example.com", true},
+		{"Blog.Example.COM.", true},
+		{"my-site.example.org", true},
+		{"localhost", false},
+		{"mysite", false},
+		{"mysite.bn", false},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"-bad.example.com", false},
+		{"bad-.example.com", false},
+		{"a..b", false},
+		{"under_score.example.com", false},
+		{strings.Repeat("a", 64) + ".com", false},
+		{strings.Repeat("a.", 127) + "com", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsDomain(tt.name); got != tt.want {
+				t.Errorf("IsDomain(%q) = %v, want %v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTXT(t *testing.T) {
+	tests := []struct {
+		name    string
+		records []string
+		want    string
+		wantErr bool
+	}{
+		{"single record", []string{"alxnet=" + testSiteID}, testSiteID, false},
+		{"ignores unrelated strings", []string{"v=spf1 -all", " alxnet=" + testSiteID + " "}, testSiteID, false},
+		{"duplicate records agree", []string{"alxnet=" + testSiteID, "alxnet=" + testSiteID}, testSiteID, false},
+		{"conflicting records", []string{"alxnet=" + testSiteID, "alxnet=" + otherSiteID}, "", true},
+		{"uppercase hex", []string{"alxnet=" + strings.ToUpper(testSiteID)}, "", true},
+		{"short ID", []string{"alxnet=abcd"}, "", true},
+		{"bare ID without prefix", []string{testSiteID}, "", true},
+		{"no records", nil, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTXT(tt.records)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTXT() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseTXT() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolverCaching(t *testing.T) {
+	calls := make(map[string]int)
+	failNext := false
+	r := NewResolverWithLookup(func(ctx context.Context, name string) ([]string, error) {
+		calls[name]++
+		if failNext {
+			failNext = false
+			return nil, errors.New("temporary failure")
+		}
+		switch name {
+		case "_alxnet.example.com":
+			return []string{"alxnet=" + testSiteID}, nil
+		default:
+			return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+		}
+	})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		got, err := r.Resolve(ctx, "Example.com.")
+		if err != nil || got != testSiteID {
+			t.Fatalf("Resolve() = %q, %v; want %q", got, err, testSiteID)
+		}
+	}
+	if calls["_alxnet.example.com"] != 1 {
+		t.Errorf("lookups for example.com = %d, want 1 (cached)", calls["_alxnet.example.com"])
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := r.Resolve(ctx, "missing.example.org"); !errors.Is(err, ErrNoRecord) {
+			t.Fatalf("Resolve() error = %v, want ErrNoRecord", err)
+		}
+	}
+	if calls["_alxnet.missing.example.org"] != 1 {
+		t.Errorf("lookups for missing domain = %d, want 1 (negative cache)", calls["_alxnet.missing.example.org"])
+	}
+
+	failNext = true
+	if _, err := r.Resolve(ctx, "flaky.example.net"); err == nil {
+		t.Fatal("Resolve() should surface lookup failures")
+	}
+	if _, err := r.Resolve(ctx, "flaky.example.net"); !errors.Is(err, ErrNoRecord) {
+		t.Errorf("Resolve() after transient failure error = %v, want a fresh lookup", err)
+	}
+
+	if _, err := r.Resolve(ctx, "localhost"); err == nil {
+		t.Error("Resolve() should reject names that are not domains")
+	}
+}
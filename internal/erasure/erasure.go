@@ -0,0 +1,151 @@
+// Package erasure splits a blob into Reed-Solomon shards so that any Data
+// of its Data+Parity shards are enough to rebuild it. Shards are named by
+// their content CID, like any other blob, so the peers holding them can be
+// checked and challenged with the usual protocols.
+package erasure
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"alxnet/internal/core"
+
+	"github.com/klauspost/reedsolomon"
+)
+
+// Shard count limits for a layout.
+const (
+	MaxDataShards   = 16
+	MaxParityShards = 16
+)
+
+// Layout describes how a blob was split. Shards lists the CIDs of the data
+// shards followed by the parity shards.
+type Layout struct {
+	Data   int      `cbor:"k" json:"data"`
+	Parity int      `cbor:"m" json:"parity"`
+	Size   int64    `cbor:"n" json:"size"`
+	Shards []string `cbor:"s" json:"shards"`
+}
+
+// checkCounts bounds the shard counts of a layout.
+func checkCounts(data, parity int) error {
+	if data < 1 || data > MaxDataShards {
+		return fmt.Errorf("data shards must be between 1 and %d", MaxDataShards)
+	}
+	if parity < 1 || parity > MaxParityShards {
+		return fmt.Errorf("parity shards must be between 1 and %d", MaxParityShards)
+	}
+	return nil
+}
+
+// Validate checks a layout read from storage or another peer.
+func (l *Layout) Validate() error {
+	if err := checkCounts(l.Data, l.Parity); err != nil {
+		return err
+	}
+	if l.Size < 1 || l.Size > int64(l.Data)*core.MaxContentSize {
+		return fmt.Errorf("invalid size %d", l.Size)
+	}
+	if len(l.Shards) != l.Data+l.Parity {
+		return fmt.Errorf("layout lists %d shards, want %d", len(l.Shards), l.Data+l.Parity)
+	}
+	for i, cid := range l.Shards {
+		if len(cid) != 64 {
+			return fmt.Errorf("invalid CID for shard %d", i)
+		}
+	}
+	return nil
+}
+
+// ShardSize is the length of every shard of the layout.
+func (l *Layout) ShardSize() int {
+	return int((l.Size + int64(l.Data) - 1) / int64(l.Data))
+}
+
+// Split encodes data into data+parity shards. No shard may exceed
+// core.MaxContentSize, so data can be at most data*core.MaxContentSize
+// bytes.
+func Split(data []byte, dataShards, parityShards int) (*Layout, [][]byte, error) {
+	if err := checkCounts(dataShards, parityShards); err != nil {
+		return nil, nil, err
+	}
+	if len(data) == 0 {
+		return nil, nil, errors.New("nothing to encode")
+	}
+	if int64(len(data)) > int64(dataShards)*core.MaxContentSize {
+		return nil, nil, fmt.Errorf("%d bytes do not fit in %d shards", len(data), dataShards)
+	}
+	enc, err := reedsolomon.New(dataShards, parityShards)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Split pads into the spare capacity of its argument; hand it a copy
+	shards, err := enc.Split(bytes.Clone(data))
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := enc.Encode(shards); err != nil {
+		return nil, nil, err
+	}
+	l := &Layout{Data: dataShards, Parity: parityShards, Size: int64(len(data)), Shards: make([]string, len(shards))}
+	for i, s := range shards {
+		l.Shards[i] = core.CIDForContent(s)
+	}
+	return l, shards, nil
+}
+
+// Reconstruct fills in the nil entries of shards, which must hold one entry
+// per shard of the layout. Shards that do not match their CID are treated
+// as missing, and regenerated shards are checked against theirs.
+func Reconstruct(l *Layout, shards [][]byte) error {
+	if err := l.Validate(); err != nil {
+		return err
+	}
+	if len(shards) != len(l.Shards) {
+		return fmt.Errorf("got %d shards, want %d", len(shards), len(l.Shards))
+	}
+	have := 0
+	for i, s := range shards {
+		if s != nil && (len(s) != l.ShardSize() || core.CIDForContent(s) != l.Shards[i]) {
+			shards[i] = nil
+		}
+		if shards[i] != nil {
+			have++
+		}
+	}
+	if have < l.Data {
+		return fmt.Errorf("only %d of the %d shards needed are intact", have, l.Data)
+	}
+	if have == len(shards) {
+		return nil
+	}
+	enc, err := reedsolomon.New(l.Data, l.Parity)
+	if err != nil {
+		return err
+	}
+	if err := enc.Reconstruct(shards); err != nil {
+		return err
+	}
+	for i, s := range shards {
+		if core.CIDForContent(s) != l.Shards[i] {
+			return fmt.Errorf("regenerated shard %d does not match its CID", i)
+		}
+	}
+	return nil
+}
+
+// Join rebuilds the blob from shards, as for Reconstruct.
+func Join(l *Layout, shards [][]byte) ([]byte, error) {
+	if err := Reconstruct(l, shards); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	buf.Grow(int(l.Size))
+	for _, s := range shards[:l.Data] {
+		buf.Write(s)
+	}
+	buf.Truncate(int(l.Size))
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,92 @@
+package erasure
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSplitJoin(t *testing.T) {
+	data := bytes.Repeat([]byte("erasure coded site "), 1001)
+	l, shards, err := Split(data, 4, 2)
+	if err != nil {
+		t.Fatalf("Split: %v", err)
+	}
+	if len(shards) != 6 || len(l.Shards) != 6 || l.Size != int64(len(data)) {
+		t.Fatalf("layout = %+v with %d shards", l, len(shards))
+	}
+
+	tests := []struct {
+		name   string
+		damage func(s [][]byte)
+		ok     bool
+	}{
+		{"intact", func(s [][]byte) {}, true},
+		{"two data shards lost", func(s [][]byte) { s[0], s[3] = nil, nil }, true},
+		{"parity lost", func(s [][]byte) { s[4], s[5] = nil, nil }, true},
+		{"corrupt shard", func(s [][]byte) { s[1][0] ^= 1 }, true},
+		{"truncated shard", func(s [][]byte) { s[2] = s[2][:10] }, true},
+		{"three lost", func(s [][]byte) { s[0], s[1], s[5] = nil, nil, nil }, false},
+		{"two lost and one corrupt", func(s [][]byte) { s[0], s[1] = nil, nil; s[2][0] ^= 1 }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := make([][]byte, len(shards))
+			for i := range shards {
+				s[i] = bytes.Clone(shards[i])
+			}
+			tt.damage(s)
+			got, err := Join(l, s)
+			if !tt.ok {
+				if err == nil {
+					t.Error("Join() succeeded with too few intact shards")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Join: %v", err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Error("Join() returned other bytes")
+			}
+			for i := range s {
+				if !bytes.Equal(s[i], shards[i]) {
+					t.Errorf("shard %d not regenerated", i)
+				}
+			}
+		})
+	}
+}
+
+func TestLayoutBounds(t *testing.T) {
+	tests := []struct {
+		name         string
+		data, parity int
+		size         int
+	}{
+		{"no data shards", 0, 2, 10},
+		{"too many data shards", MaxDataShards + 1, 2, 10},
+		{"no parity", 3, 0, 10},
+		{"too much parity", 3, MaxParityShards + 1, 10},
+		{"empty", 3, 2, 0},
+	}
+	for _, tt := range tests {
+		if _, _, err := Split(make([]byte, tt.size), tt.data, tt.parity); err == nil {
+			t.Errorf("%s: Split() accepted the layout", tt.name)
+		}
+	}
+
+	l, shards, err := Split([]byte("small"), 2, 1)
+	if err != nil {
+		t.Fatalf("Split: %v", err)
+	}
+	bad := *l
+	bad.Shards = bad.Shards[:2]
+	if _, err := Join(&bad, shards); err == nil {
+		t.Error("Join() accepted a layout missing a shard")
+	}
+	bad = *l
+	bad.Size = 1 << 40
+	if _, err := Join(&bad, shards); err == nil {
+		t.Error("Join() accepted an oversized layout")
+	}
+}
@@ -0,0 +1,297 @@
+// Package fingerprint rewrites the relative asset references of a website,
+// in HTML and CSS, to CID-addressed gateway paths. Every version of a site
+// that keeps a stylesheet, script or image unchanged then points at the
+// same URL, which browsers may cache forever since its bytes cannot change.
+//
+// References to HTML pages are left alone, so navigation keeps the site's
+// own paths, and the files stay reachable under their original paths too.
+// A stylesheet is itself fingerprinted only once every reference it makes
+// into the site was rewritten, since relative URLs in it resolve against
+// the path it is served from.
+package fingerprint
+
+import (
+	"bytes"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"alxnet/internal/core"
+
+	"golang.org/x/net/html"
+)
+
+// PathPrefix is where gateways serve content by CID, as
+// PathPrefix + cid + "/" + name.
+const PathPrefix = "/_alxnet/cid/"
+
+// AssetPath returns the gateway path of content cid. The name only gives
+// the response its MIME type and browsers a file name.
+func AssetPath(cid, name string) string {
+	return PathPrefix + cid + "/" + url.PathEscape(name)
+}
+
+// ParsePath splits a gateway path made by AssetPath. The CID is not
+// checked.
+func ParsePath(p string) (cid, name string, ok bool) {
+	rest, ok := strings.CutPrefix(p, PathPrefix)
+	if !ok {
+		return "", "", false
+	}
+	cid, name, ok = strings.Cut(rest, "/")
+	if !ok || cid == "" || name == "" || strings.Contains(name, "/") {
+		return "", "", false
+	}
+	return cid, name, true
+}
+
+// Rewrite returns the files of a site with the asset references of their
+// HTML and CSS rewritten, and how many references were rewritten. Other
+// files are returned as they are; files is not modified. The result only
+// depends on the files, so the same folder always publishes the same CIDs.
+func Rewrite(files map[string][]byte) (map[string][]byte, int) {
+	r := &rewriter{
+		files: files,
+		out:   make(map[string][]byte, len(files)),
+		cids:  make(map[string]string),
+		state: make(map[string]int),
+	}
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		switch {
+		case core.IsHTMLFile(p):
+			r.out[p] = r.rewriteHTML(p, files[p])
+		case isCSS(p):
+			r.asset(p) // rewrites it
+		default:
+			r.out[p] = files[p]
+		}
+	}
+	return r.out, r.count
+}
+
+const (
+	visiting = iota + 1
+	done
+)
+
+type rewriter struct {
+	files map[string][]byte
+	out   map[string][]byte
+	cids  map[string]string // fingerprinted assets by path
+	state map[string]int    // stylesheets being or already rewritten
+	count int
+}
+
+func isCSS(p string) bool { return strings.EqualFold(path.Ext(p), ".css") }
+
+// asset returns the CID of the site file at p if references to it can be
+// rewritten, rewriting it first if it is a stylesheet.
+func (r *rewriter) asset(p string) (string, bool) {
+	if cid, ok := r.cids[p]; ok {
+		return cid, true
+	}
+	data, ok := r.files[p]
+	if !ok || core.IsHTMLFile(p) {
+		return "", false
+	}
+	if !isCSS(p) {
+		cid := core.CIDForContent(data)
+		r.cids[p] = cid
+		return cid, true
+	}
+	if r.state[p] != 0 {
+		return "", false // a cycle of imports, or left relative before
+	}
+	r.state[p] = visiting
+	out, complete := r.rewriteCSS(p, data)
+	r.out[p] = out
+	r.state[p] = done
+	if !complete {
+		return "", false
+	}
+	cid := core.CIDForContent(out)
+	r.cids[p] = cid
+	return cid, true
+}
+
+// ref rewrites one reference made by the file at from. It reports whether
+// the reference still points into the site by a relative path.
+func (r *rewriter) ref(from, ref string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(ref))
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Opaque != "" || u.Path == "" || strings.HasPrefix(u.Path, "/") {
+		return ref, false
+	}
+	target := path.Join(path.Dir(from), u.Path)
+	if target == ".." || strings.HasPrefix(target, "../") {
+		return ref, false
+	}
+	if _, ok := r.files[target]; !ok {
+		return ref, false // broken from either path
+	}
+	cid, ok := r.asset(target)
+	if !ok {
+		return ref, true
+	}
+	r.count++
+	out := AssetPath(cid, path.Base(target))
+	if u.RawQuery != "" {
+		out += "?" + u.RawQuery
+	}
+	if u.Fragment != "" {
+		out += "#" + u.EscapedFragment()
+	}
+	return out, false
+}
+
+var (
+	cssURL    = regexp.MustCompile(`url\(\s*(?:"([^"]*)"|'([^']*)'|([^)'"\s]*))\s*\)`)
+	cssImport = regexp.MustCompile(`@import\s+(?:"([^"]*)"|'([^']*)')`)
+)
+
+// rewriteCSS rewrites the url() and @import references of a stylesheet at
+// from. It reports whether no relative reference into the site was left.
+func (r *rewriter) rewriteCSS(from string, css []byte) ([]byte, bool) {
+	complete := true
+	replace := func(re *regexp.Regexp, format string, src []byte) []byte {
+		return re.ReplaceAllFunc(src, func(m []byte) []byte {
+			sub := re.FindSubmatch(m)
+			var ref []byte
+			for _, s := range sub[1:] {
+				if s != nil {
+					ref = s
+					break
+				}
+			}
+			out, relative := r.ref(from, string(ref))
+			if relative {
+				complete = false
+			}
+			if out == string(ref) {
+				return m
+			}
+			return []byte(strings.Replace(format, "%", out, 1))
+		})
+	}
+	css = replace(cssURL, `url("%")`, css)
+	css = replace(cssImport, `@import "%"`, css)
+	return css, complete
+}
+
+// rewrittenAttrs are the attributes of each element that load an asset.
+// Links, <a href> included, are not rewritten.
+var rewrittenAttrs = map[string][]string{
+	"link":   {"href"},
+	"script": {"src"},
+	"img":    {"src", "srcset"},
+	"source": {"src", "srcset"},
+	"video":  {"src", "poster"},
+	"audio":  {"src"},
+	"track":  {"src"},
+	"input":  {"src"},
+	"embed":  {"src"},
+	"object": {"data"},
+}
+
+// rewriteHTML rewrites the asset references of the page at from: the
+// attributes in rewrittenAttrs, style attributes and <style> elements.
+// Only tags that change are re-serialized. Pages with a <base> element
+// resolve references elsewhere and are left as they are.
+func (r *rewriter) rewriteHTML(from string, page []byte) []byte {
+	var out bytes.Buffer
+	z := html.NewTokenizer(bytes.NewReader(page))
+	inStyle := false
+	count := r.count
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return out.Bytes()
+		}
+		raw := append([]byte(nil), z.Raw()...) // Token lowercases names in place
+		switch tt {
+		case html.TextToken:
+			if inStyle {
+				css, _ := r.rewriteCSS(from, raw)
+				out.Write(css)
+				continue
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			if tok.Data == "base" {
+				r.count = count
+				return page
+			}
+			inStyle = tok.Data == "style" && tt == html.StartTagToken
+			if r.rewriteTag(from, &tok) {
+				out.WriteString(tok.String())
+				continue
+			}
+		case html.EndTagToken:
+			inStyle = false
+		}
+		out.Write(raw)
+	}
+}
+
+// rewriteTag rewrites the references of one tag, reporting whether any
+// changed.
+func (r *rewriter) rewriteTag(from string, tok *html.Token) bool {
+	attrs := rewrittenAttrs[tok.Data]
+	if tok.Data == "script" && attrValue(tok, "type") == "module" ||
+		tok.Data == "link" && strings.Contains(attrValue(tok, "rel"), "modulepreload") {
+		attrs = nil // modules resolve their imports against their own URL
+	}
+	changed := false
+	for i, a := range tok.Attr {
+		if a.Namespace != "" {
+			continue
+		}
+		val := a.Val
+		switch {
+		case a.Key == "style":
+			css, _ := r.rewriteCSS(from, []byte(val))
+			val = string(css)
+		case a.Key == "srcset" && slices.Contains(attrs, a.Key):
+			val = r.rewriteSrcset(from, val)
+		case slices.Contains(attrs, a.Key):
+			val, _ = r.ref(from, val)
+		}
+		if val != a.Val {
+			tok.Attr[i].Val = val
+			changed = true
+		}
+	}
+	return changed
+}
+
+// rewriteSrcset rewrites each candidate URL of a srcset attribute.
+func (r *rewriter) rewriteSrcset(from, srcset string) string {
+	candidates := strings.Split(srcset, ",")
+	for i, c := range candidates {
+		fields := strings.Fields(c)
+		if len(fields) == 0 {
+			continue
+		}
+		if ref, _ := r.ref(from, fields[0]); ref != fields[0] {
+			fields[0] = ref
+			candidates[i] = strings.Join(fields, " ")
+		}
+	}
+	return strings.Join(candidates, ",")
+}
+
+func attrValue(tok *html.Token, key string) string {
+	for _, a := range tok.Attr {
+		if a.Key == key {
+			return strings.ToLower(strings.TrimSpace(a.Val))
+		}
+	}
+	return ""
+}
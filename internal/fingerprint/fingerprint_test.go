@@ -0,0 +1,87 @@
+package fingerprint
+
+import (
+	"strings"
+	"testing"
+
+	"alxnet/internal/core"
+)
+
+func TestRewrite(t *testing.T) {
+	logo := []byte("png bytes")
+	font := []byte("font bytes")
+	files := map[string][]byte{
+		"index.html": []byte(`<!DOCTYPE html><HTML><head>` +
+			`<link rel="stylesheet" href="css/site.css?v=2">` +
+			`<script src="app.js"></script><script type="module" src="mod.js"></script>` +
+			`<style>body { background: url(img/logo.png) }</style></head>` +
+			`<body><a href="about.html">About</a> <a href="img/logo.png">logo</a>` +
+			`<img src="img/logo.png" srcset="img/logo.png 1x, img/missing.png 2x" alt="A &amp; B">` +
+			`<img src="https://example.com/x.png"><img src="icons.svg#home"></body></HTML>`),
+		"css/site.css":      []byte(`@import 'base.css'; h1 { background: url("../img/logo.png"); }`),
+		"css/base.css":      []byte(`@font-face { src: url(fonts/a.woff2) }`),
+		"css/fonts/a.woff2": font,
+		"css/loop-a.css":    []byte(`@import "loop-b.css";`),
+		"css/loop-b.css":    []byte(`@import "loop-a.css";`),
+		"img/logo.png":      logo,
+		"app.js":            []byte("console.log(1)"),
+		"mod.js":            []byte("import './util.js'"),
+		"icons.svg":         []byte("<svg/>"),
+		"about.html":        []byte(`<base href="/"><img src="img/logo.png">`),
+	}
+	out, n := Rewrite(files)
+
+	logoPath := AssetPath(core.CIDForContent(logo), "logo.png")
+	fontPath := AssetPath(core.CIDForContent(font), "a.woff2")
+	base := `@font-face { src: url("` + fontPath + `") }`
+	if got := string(out["css/base.css"]); got != base {
+		t.Errorf("base.css = %s", got)
+	}
+	site := `@import "` + AssetPath(core.CIDForContent([]byte(base)), "base.css") + `"; h1 { background: url("` + logoPath + `"); }`
+	if got := string(out["css/site.css"]); got != site {
+		t.Errorf("site.css = %s", got)
+	}
+	sitePath := AssetPath(core.CIDForContent([]byte(site)), "site.css")
+
+	page := string(out["index.html"])
+	for _, want := range []string{
+		`<HTML><head>`, // untouched tags keep their bytes
+		`href="` + sitePath + `?v=2"`,
+		`src="` + AssetPath(core.CIDForContent(files["app.js"]), "app.js") + `"`,
+		`<script type="module" src="mod.js">`,
+		`background: url("` + logoPath + `")`,
+		`<a href="about.html">`, `<a href="img/logo.png">`,
+		`srcset="` + logoPath + ` 1x, img/missing.png 2x" alt="A &amp; B"`,
+		`src="https://example.com/x.png"`,
+		`src="` + AssetPath(core.CIDForContent(files["icons.svg"]), "icons.svg") + `#home"`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("index.html lacks %s:\n%s", want, page)
+		}
+	}
+	if n != 9 {
+		t.Errorf("Rewrite() rewrote %d references, want 9", n)
+	}
+
+	// Cycles, pages with <base> and other files are left as they are
+	for _, p := range []string{"css/loop-a.css", "css/loop-b.css", "about.html", "mod.js"} {
+		if string(out[p]) != string(files[p]) {
+			t.Errorf("%s = %s, want it unchanged", p, out[p])
+		}
+	}
+	if len(out) != len(files) {
+		t.Errorf("Rewrite() returned %d files, want %d", len(out), len(files))
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	cid := strings.Repeat("ab", 32)
+	if c, name, ok := ParsePath(AssetPath(cid, "my logo.png")); !ok || c != cid || name != "my%20logo.png" {
+		t.Errorf("ParsePath() = %s %s %v", c, name, ok)
+	}
+	for _, p := range []string{"/_alxnet/cid/", "/_alxnet/cid/" + cid, "/_alxnet/cid/" + cid + "/a/b", "/other/" + cid + "/a"} {
+		if _, _, ok := ParsePath(p); ok {
+			t.Errorf("ParsePath(%q) succeeded", p)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+// Package logging builds the node's zap logger from configuration:
+// console or JSON encoding, one or more destinations, and size-based
+// rotation with age and count retention for log files.
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"alxnet/internal/config"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// New builds a logger writing cfg's format to each of its outputs at
+// level. Closing the returned io.Closer flushes and closes log files.
+func New(cfg config.LoggingConfig, level zap.AtomicLevel) (*zap.Logger, io.Closer, error) {
+	var enc zapcore.Encoder
+	switch cfg.Format {
+	case "json":
+		enc = zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	case "console":
+		enc = zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig())
+	default:
+		return nil, nil, fmt.Errorf("unknown log format %q", cfg.Format)
+	}
+
+	var files closers
+	var sinks []zapcore.WriteSyncer
+	for _, out := range cfg.Outputs {
+		switch out {
+		case "stderr":
+			sinks = append(sinks, zapcore.Lock(os.Stderr))
+		case "stdout":
+			sinks = append(sinks, zapcore.Lock(os.Stdout))
+		default:
+			f, err := OpenRotatingFile(out, int64(cfg.MaxSizeMB)<<20, cfg.MaxAge, cfg.MaxBackups)
+			if err != nil {
+				files.Close()
+				return nil, nil, fmt.Errorf("log output %s: %w", out, err)
+			}
+			files = append(files, f)
+			sinks = append(sinks, f)
+		}
+	}
+	if len(sinks) == 0 {
+		return nil, nil, errors.New("no log outputs")
+	}
+
+	core := zapcore.NewCore(enc, zapcore.NewMultiWriteSyncer(sinks...), level)
+	logger := zap.New(core, zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel))
+	return logger, files, nil
+}
+
+// closers closes log files in order, returning the first error.
+type closers []*RotatingFile
+
+func (c closers) Close() error {
+	var first error
+	for _, f := range c {
+		if err := f.Sync(); err != nil && first == nil {
+			first = err
+		}
+		if err := f.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -0,0 +1,58 @@
+package logging
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"alxnet/internal/config"
+
+	"go.uber.org/zap"
+)
+
+func TestNew(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "node.log")
+	cfg := config.LoggingConfig{Format: "json", Outputs: []string{path}, MaxSizeMB: 1}
+	level := zap.NewAtomicLevelAt(zap.InfoLevel)
+
+	logger, files, err := New(cfg, level)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	logger.Debug("hidden")
+	logger.Info("shown", zap.String("k", "v"))
+	level.SetLevel(zap.DebugLevel)
+	logger.Debug("now shown")
+	if err := files.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2:\n%s", len(lines), b)
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("line is not JSON: %v", err)
+	}
+	if entry["msg"] != "shown" || entry["k"] != "v" {
+		t.Errorf("entry = %v", entry)
+	}
+
+	bad := []config.LoggingConfig{
+		{Format: "xml", Outputs: []string{"stderr"}, MaxSizeMB: 1},
+		{Format: "console", MaxSizeMB: 1},
+		{Format: "console", Outputs: []string{filepath.Join(path, "sub.log")}, MaxSizeMB: 1},
+	}
+	for _, cfg := range bad {
+		if _, _, err := New(cfg, level); err == nil {
+			t.Errorf("New(%+v) succeeded", cfg)
+		}
+	}
+}
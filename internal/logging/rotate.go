@@ -0,0 +1,155 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat is the timestamp added to rotated file names.
+const backupTimeFormat = "20060102T150405.000"
+
+// RotatingFile is a log file that is renamed aside once it reaches a size
+// limit. Rotated files past MaxBackups or older than MaxAge are removed.
+type RotatingFile struct {
+	path       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+	now  func() time.Time
+}
+
+// OpenRotatingFile opens path for appending, creating it and its directory
+// if needed. maxAge and maxBackups of zero keep rotated files.
+func OpenRotatingFile(path string, maxSize int64, maxAge time.Duration, maxBackups int) (*RotatingFile, error) {
+	if maxSize <= 0 {
+		return nil, fmt.Errorf("log file size limit must be positive")
+	}
+	r := &RotatingFile{path: path, maxSize: maxSize, maxAge: maxAge, maxBackups: maxBackups, now: time.Now}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *RotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f, r.size = f, fi.Size()
+	return nil
+}
+
+// Write appends p, rotating first if p would take the file over its limit.
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return 0, os.ErrClosed
+	}
+	if r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Sync flushes the current file to disk.
+func (r *RotatingFile) Sync() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return nil
+	}
+	return r.f.Sync()
+}
+
+// Close closes the current file.
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
+
+// rotate moves the current file aside, opens a fresh one and removes
+// old backups. Callers hold r.mu.
+func (r *RotatingFile) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+	r.f = nil
+	ext := filepath.Ext(r.path)
+	backup := strings.TrimSuffix(r.path, ext) + "-" + r.now().UTC().Format(backupTimeFormat) + ext
+	if err := os.Rename(r.path, backup); err != nil {
+		return err
+	}
+	if err := r.open(); err != nil {
+		return err
+	}
+	r.prune()
+	return nil
+}
+
+// prune removes rotated files beyond the backup and age limits. Errors
+// are ignored; a leftover backup is retried on the next rotation.
+func (r *RotatingFile) prune() {
+	if r.maxBackups == 0 && r.maxAge == 0 {
+		return
+	}
+	ext := filepath.Ext(r.path)
+	prefix := filepath.Base(strings.TrimSuffix(r.path, ext)) + "-"
+	entries, err := os.ReadDir(filepath.Dir(r.path))
+	if err != nil {
+		return
+	}
+
+	type backup struct {
+		name string
+		t    time.Time
+	}
+	var backups []backup
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+		t, err := time.Parse(backupTimeFormat, strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext))
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backup{name, t})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].t.After(backups[j].t) })
+
+	cutoff := r.now().Add(-r.maxAge)
+	for i, b := range backups {
+		if (r.maxBackups > 0 && i >= r.maxBackups) || (r.maxAge > 0 && b.t.Before(cutoff)) {
+			_ = os.Remove(filepath.Join(filepath.Dir(r.path), b.name))
+		}
+	}
+}
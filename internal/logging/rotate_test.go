@@ -0,0 +1,83 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotatingFile(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxBackups  int
+		maxAge      time.Duration
+		writes      int
+		wantBackups int
+	}{
+		{"keeps all", 0, 0, 5, 4},
+		{"backup limit", 2, 0, 5, 2},
+		{"age limit", 0, 90 * time.Minute, 5, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "logs", "node.log")
+			r, err := OpenRotatingFile(path, 10, tt.maxAge, tt.maxBackups)
+			if err != nil {
+				t.Fatalf("OpenRotatingFile: %v", err)
+			}
+			defer r.Close()
+
+			// Each write fills the file, so every later one rotates; the
+			// clock moves an hour per write to give backups distinct ages
+			clock := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+			r.now = func() time.Time { return clock }
+			for i := 0; i < tt.writes; i++ {
+				if _, err := r.Write([]byte("0123456789")); err != nil {
+					t.Fatalf("Write: %v", err)
+				}
+				clock = clock.Add(time.Hour)
+			}
+
+			entries, _ := os.ReadDir(filepath.Dir(path))
+			backups := 0
+			for _, e := range entries {
+				if strings.HasPrefix(e.Name(), "node-") && strings.HasSuffix(e.Name(), ".log") {
+					backups++
+				}
+			}
+			if backups != tt.wantBackups {
+				t.Errorf("%d rotated files, want %d", backups, tt.wantBackups)
+			}
+			if b, _ := os.ReadFile(path); string(b) != "0123456789" {
+				t.Errorf("current file = %q", b)
+			}
+		})
+	}
+}
+
+func TestRotatingFileAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "node.log")
+	if err := os.WriteFile(path, []byte("old\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := OpenRotatingFile(path, 1<<20, 0, 0)
+	if err != nil {
+		t.Fatalf("OpenRotatingFile: %v", err)
+	}
+	if _, err := r.Write([]byte("new\n")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	r.Close()
+	if b, _ := os.ReadFile(path); string(b) != "old\nnew\n" {
+		t.Errorf("file = %q, want the old line kept", b)
+	}
+	if _, err := r.Write([]byte("x")); err == nil {
+		t.Error("Write after Close succeeded")
+	}
+	if _, err := OpenRotatingFile(path, 0, 0, 0); err == nil {
+		t.Error("OpenRotatingFile accepted a zero size limit")
+	}
+}
@@ -0,0 +1,182 @@
+package logging
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Entry is one log entry as the log stream hands it out.
+type Entry struct {
+	Seq     uint64                 `json:"seq"`
+	Time    time.Time              `json:"time"`
+	Level   string                 `json:"level"`
+	Logger  string                 `json:"logger,omitempty"` // module, see Filter
+	Message string                 `json:"message"`
+	Caller  string                 `json:"caller,omitempty"`
+	Fields  map[string]interface{} `json:"fields,omitempty"`
+}
+
+// Filter selects entries at or above Level whose logger is Module or one
+// of its children, such as "p2p.audit" for "p2p". An empty Module matches
+// every entry.
+type Filter struct {
+	Level  zapcore.Level
+	Module string
+}
+
+// Match reports whether e passes f.
+func (f Filter) Match(e Entry) bool {
+	level, err := zapcore.ParseLevel(e.Level)
+	if err != nil || level < f.Level {
+		return false
+	}
+	return f.Module == "" || e.Logger == f.Module || strings.HasPrefix(e.Logger, f.Module+".")
+}
+
+// Stream keeps the newest log entries in memory and passes new ones on to
+// subscribers, so they can be followed without reading log files. Add it
+// to a logger with Core.
+type Stream struct {
+	mu   sync.Mutex
+	ring []Entry // the newest len(ring) entries, oldest at next%len(ring)
+	next uint64  // sequence number of the next entry
+	subs map[*Subscription]struct{}
+}
+
+// NewStream creates a stream that keeps the newest size entries.
+func NewStream(size int) *Stream {
+	return &Stream{ring: make([]Entry, 0, size), subs: make(map[*Subscription]struct{})}
+}
+
+func (s *Stream) add(e Entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e.Seq = s.next
+	s.next++
+	if len(s.ring) < cap(s.ring) {
+		s.ring = append(s.ring, e)
+	} else if cap(s.ring) > 0 {
+		s.ring[e.Seq%uint64(cap(s.ring))] = e
+	}
+	for sub := range s.subs {
+		if !sub.filter.Match(e) {
+			continue
+		}
+		select {
+		case sub.c <- e:
+		default:
+			sub.dropped++
+		}
+	}
+}
+
+// Recent returns up to n of the newest entries matching f, oldest first.
+func (s *Stream) Recent(n int, f Filter) []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []Entry
+	size := uint64(len(s.ring))
+	for i := uint64(0); i < size && len(out) < n; i++ {
+		e := s.ring[(s.next-1-i)%uint64(cap(s.ring))]
+		if f.Match(e) {
+			out = append(out, e)
+		}
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out
+}
+
+// Subscription receives the entries matching its filter that are logged
+// after it was made. Entries that arrive while C is full are dropped and
+// counted.
+type Subscription struct {
+	c       chan Entry
+	filter  Filter
+	dropped uint64 // guarded by the stream's mu
+	stream  *Stream
+}
+
+// Subscribe starts passing entries matching f to a subscription holding up
+// to buffer of them. Close it when done.
+func (s *Stream) Subscribe(f Filter, buffer int) *Subscription {
+	sub := &Subscription{c: make(chan Entry, buffer), filter: f, stream: s}
+	s.mu.Lock()
+	s.subs[sub] = struct{}{}
+	s.mu.Unlock()
+	return sub
+}
+
+// C delivers the entries.
+func (sub *Subscription) C() <-chan Entry { return sub.c }
+
+// Dropped returns how many entries were dropped since it was last called.
+func (sub *Subscription) Dropped() uint64 {
+	sub.stream.mu.Lock()
+	defer sub.stream.mu.Unlock()
+	n := sub.dropped
+	sub.dropped = 0
+	return n
+}
+
+// Close stops the subscription.
+func (sub *Subscription) Close() {
+	sub.stream.mu.Lock()
+	defer sub.stream.mu.Unlock()
+	delete(sub.stream.subs, sub)
+}
+
+// Core returns a zap core that adds entries enabled by level to s. Combine
+// it with the logger's own core with zapcore.NewTee.
+func (s *Stream) Core(level zapcore.LevelEnabler) zapcore.Core {
+	return &streamCore{LevelEnabler: level, stream: s}
+}
+
+type streamCore struct {
+	zapcore.LevelEnabler
+	stream *Stream
+	fields []zapcore.Field
+}
+
+func (c *streamCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.fields = append(c.fields[:len(c.fields):len(c.fields)], fields...)
+	return &clone
+}
+
+func (c *streamCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *streamCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	e := Entry{
+		Time:    ent.Time,
+		Level:   ent.Level.String(),
+		Logger:  ent.LoggerName,
+		Message: ent.Message,
+	}
+	if ent.Caller.Defined {
+		e.Caller = ent.Caller.TrimmedPath()
+	}
+	if len(c.fields)+len(fields) > 0 {
+		enc := zapcore.NewMapObjectEncoder()
+		for _, f := range c.fields {
+			f.AddTo(enc)
+		}
+		for _, f := range fields {
+			f.AddTo(enc)
+		}
+		e.Fields = enc.Fields
+	}
+	c.stream.add(e)
+	return nil
+}
+
+func (c *streamCore) Sync() error { return nil }
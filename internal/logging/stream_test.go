@@ -0,0 +1,59 @@
+package logging
+
+import (
+	"errors"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestStream(t *testing.T) {
+	stream := NewStream(3)
+	logger := zap.New(stream.Core(zap.DebugLevel))
+	p2p := logger.Named("p2p").With(zap.String("peer", "abc"))
+
+	sub := stream.Subscribe(Filter{Level: zap.WarnLevel, Module: "p2p"}, 1)
+	defer sub.Close()
+
+	logger.Info("started")
+	p2p.Debug("dialing")
+	p2p.Named("audit").Warn("rejected", zap.Error(errors.New("bad signature")))
+	p2p.Error("lost") // the subscription's buffer is full
+	logger.Named("p2pool").Error("other module")
+
+	got := <-sub.C()
+	if got.Message != "rejected" || got.Logger != "p2p.audit" || got.Fields["peer"] != "abc" || got.Fields["error"] != "bad signature" {
+		t.Errorf("subscribed entry = %+v", got)
+	}
+	if n := sub.Dropped(); n != 1 {
+		t.Errorf("Dropped() = %d, want 1", n)
+	}
+
+	tests := []struct {
+		name   string
+		n      int
+		filter Filter
+		want   []string
+	}{
+		// Only the newest three entries are kept
+		{"all kept", 10, Filter{Level: zap.DebugLevel}, []string{"rejected", "lost", "other module"}},
+		{"newest", 1, Filter{Level: zap.DebugLevel}, []string{"other module"}},
+		{"module", 10, Filter{Level: zap.DebugLevel, Module: "p2p"}, []string{"rejected", "lost"}},
+		{"level", 10, Filter{Level: zap.ErrorLevel}, []string{"lost", "other module"}},
+		{"no match", 10, Filter{Level: zapcore.FatalLevel}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries := stream.Recent(tt.n, tt.filter)
+			if len(entries) != len(tt.want) {
+				t.Fatalf("Recent = %+v, want %v", entries, tt.want)
+			}
+			for i, e := range entries {
+				if e.Message != tt.want[i] {
+					t.Errorf("Recent[%d] = %q, want %q", i, e.Message, tt.want[i])
+				}
+			}
+		})
+	}
+}
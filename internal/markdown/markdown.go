@@ -0,0 +1,750 @@
+// Package markdown renders Markdown to HTML for sites published as text.
+//
+// It covers the common subset of CommonMark and GitHub tables: ATX and
+// setext headings, paragraphs, emphasis, strikethrough, code spans, fenced
+// and indented code, block quotes, nested lists, thematic breaks, tables,
+// links, autolinks and images. Raw HTML is not passed through: everything
+// the source says is escaped, and links keep only http, https and mailto
+// URLs or relative ones, so a rendered page cannot run scripts. Images on
+// other hosts become links unless Options.RemoteImages is set, so a page
+// does not load anything from outside its site when it is opened.
+package markdown
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Document is a rendered Markdown source.
+type Document struct {
+	Title string // text of the first top-level heading, if any
+	HTML  string // body HTML
+}
+
+// Options change how Render treats what a page would load.
+type Options struct {
+	RemoteImages bool // keep images with http and https URLs as images
+}
+
+// Render converts src to HTML with the default options.
+func Render(src []byte) Document {
+	return RenderWith(src, Options{})
+}
+
+// RenderWith converts src to HTML.
+func RenderWith(src []byte, opts Options) Document {
+	r := &renderer{ids: make(map[string]int), opts: opts}
+	text := strings.ReplaceAll(string(src), "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	text = strings.ReplaceAll(text, "\x00", "�")
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = expandTabs(line)
+	}
+	var b strings.Builder
+	r.blocks(&b, lines, false)
+	return Document{Title: r.title, HTML: b.String()}
+}
+
+// Text renders plain text as preformatted HTML.
+func Text(src []byte) string {
+	text := strings.ReplaceAll(string(src), "\r\n", "\n")
+	return "<pre class=\"text\">" + html.EscapeString(text) + "</pre>\n"
+}
+
+type renderer struct {
+	opts  Options
+	title string
+	ids   map[string]int // heading IDs in use, for unique anchors
+}
+
+var (
+	atxHeading   = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	thematic     = regexp.MustCompile(`^ {0,3}(?:(?:\*[ \t]*){3,}|(?:-[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	fenceOpen    = regexp.MustCompile("^( {0,3})(`{3,}|~{3,})[ \t]*([^`\\s]*)")
+	setextH1     = regexp.MustCompile(`^ {0,3}=+[ \t]*$`)
+	setextH2     = regexp.MustCompile(`^ {0,3}-+[ \t]*$`)
+	listMarker   = regexp.MustCompile(`^( {0,3})([-*+]|(\d{1,9})[.)])( +|$)`)
+	tableDivider = regexp.MustCompile(`^ *\|? *:?-+:? *(\| *:?-+:? *)*\|? *$`)
+)
+
+func expandTabs(line string) string {
+	if !strings.Contains(line, "\t") {
+		return line
+	}
+	var b strings.Builder
+	col := 0
+	for _, c := range line {
+		if c == '\t' {
+			n := 4 - col%4
+			b.WriteString(strings.Repeat(" ", n))
+			col += n
+			continue
+		}
+		b.WriteRune(c)
+		col++
+	}
+	return b.String()
+}
+
+func isBlank(line string) bool { return strings.TrimSpace(line) == "" }
+
+func indentOf(line string) int { return len(line) - len(strings.TrimLeft(line, " ")) }
+
+// blocks renders lines as a sequence of blocks. In a tight list item,
+// paragraphs are written without <p>.
+func (r *renderer) blocks(b *strings.Builder, lines []string, tight bool) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case isBlank(line):
+			i++
+		case fenceOpen.MatchString(line):
+			i = r.fencedCode(b, lines, i)
+		case atxHeading.MatchString(line):
+			m := atxHeading.FindStringSubmatch(line)
+			r.heading(b, len(m[1]), m[2])
+			i++
+		case thematic.MatchString(line):
+			b.WriteString("<hr>\n")
+			i++
+		case strings.HasPrefix(strings.TrimLeft(line, " "), ">") && indentOf(line) < 4:
+			i = r.blockquote(b, lines, i)
+		case listMarker.MatchString(line):
+			i = r.list(b, lines, i)
+		case indentOf(line) >= 4:
+			i = r.indentedCode(b, lines, i)
+		case i+1 < len(lines) && strings.Contains(line, "|") && tableDivider.MatchString(lines[i+1]) && strings.Contains(lines[i+1], "-"):
+			i = r.table(b, lines, i)
+		default:
+			i = r.paragraph(b, lines, i, tight)
+		}
+	}
+}
+
+// interrupts reports whether line starts a block that ends a paragraph.
+func interrupts(line string) bool {
+	if isBlank(line) || fenceOpen.MatchString(line) || atxHeading.MatchString(line) || thematic.MatchString(line) {
+		return true
+	}
+	if strings.HasPrefix(strings.TrimLeft(line, " "), ">") && indentOf(line) < 4 {
+		return true
+	}
+	if m := listMarker.FindStringSubmatch(line); m != nil && strings.TrimSpace(line[len(m[0]):]) != "" {
+		return m[3] == "" || m[3] == "1" // only lists starting at 1 interrupt
+	}
+	return false
+}
+
+func (r *renderer) paragraph(b *strings.Builder, lines []string, i int, tight bool) int {
+	var para []string
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if len(para) > 0 {
+			if setextH1.MatchString(line) {
+				r.heading(b, 1, strings.Join(para, "\n"))
+				return i + 1
+			}
+			if setextH2.MatchString(line) {
+				r.heading(b, 2, strings.Join(para, "\n"))
+				return i + 1
+			}
+			if interrupts(line) {
+				break
+			}
+		}
+		para = append(para, line)
+	}
+	for j, line := range para {
+		line = strings.TrimLeft(line, " ")
+		if j < len(para)-1 && (strings.HasSuffix(line, "  ") || strings.HasSuffix(line, "\\")) {
+			line = strings.TrimRight(strings.TrimSuffix(strings.TrimRight(line, " "), "\\"), " ") + "\x00"
+		} else {
+			line = strings.TrimRight(line, " ")
+		}
+		para[j] = line
+	}
+	text := r.inline(strings.Join(para, "\n"))
+	if tight {
+		b.WriteString(text)
+		b.WriteString("\n")
+	} else {
+		b.WriteString("<p>" + text + "</p>\n")
+	}
+	return i
+}
+
+func (r *renderer) heading(b *strings.Builder, level int, text string) {
+	text = strings.TrimSpace(text)
+	plain := plainText(text)
+	if level == 1 && r.title == "" {
+		r.title = plain
+	}
+	id := slug(plain)
+	if n := r.ids[id]; n > 0 {
+		r.ids[id] = n + 1
+		id = fmt.Sprintf("%s-%d", id, n)
+	} else {
+		r.ids[id] = 1
+	}
+	fmt.Fprintf(b, "<h%d id=\"%s\">%s</h%d>\n", level, html.EscapeString(id), r.inline(text), level)
+}
+
+// slug makes a heading anchor: lowercase letters and digits joined by '-'.
+func slug(text string) string {
+	var b strings.Builder
+	dash := false
+	for _, c := range strings.ToLower(text) {
+		if unicode.IsLetter(c) || unicode.IsDigit(c) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			dash = false
+			b.WriteRune(c)
+		} else {
+			dash = true
+		}
+	}
+	if b.Len() == 0 {
+		return "section"
+	}
+	return b.String()
+}
+
+func (r *renderer) fencedCode(b *strings.Builder, lines []string, i int) int {
+	m := fenceOpen.FindStringSubmatch(lines[i])
+	indent, fence, info := len(m[1]), m[2], m[3]
+	var code []string
+	for i++; i < len(lines); i++ {
+		trimmed := strings.TrimLeft(lines[i], " ")
+		if indentOf(lines[i]) < 4 && strings.HasPrefix(trimmed, fence[:1]) &&
+			len(strings.TrimRight(trimmed, " ")) >= len(fence) &&
+			strings.Trim(strings.TrimRight(trimmed, " "), fence[:1]) == "" {
+			i++
+			break
+		}
+		line := lines[i]
+		line = line[min(indent, indentOf(line)):]
+		code = append(code, line)
+	}
+	writeCode(b, code, info)
+	return i
+}
+
+func (r *renderer) indentedCode(b *strings.Builder, lines []string, i int) int {
+	var code []string
+	for ; i < len(lines) && (indentOf(lines[i]) >= 4 || isBlank(lines[i])); i++ {
+		if isBlank(lines[i]) {
+			code = append(code, "")
+		} else {
+			code = append(code, lines[i][4:])
+		}
+	}
+	for len(code) > 0 && code[len(code)-1] == "" {
+		code = code[:len(code)-1]
+	}
+	writeCode(b, code, "")
+	return i
+}
+
+func writeCode(b *strings.Builder, code []string, lang string) {
+	b.WriteString("<pre><code")
+	if lang != "" {
+		b.WriteString(" class=\"language-" + html.EscapeString(lang) + "\"")
+	}
+	b.WriteString(">")
+	for _, line := range code {
+		b.WriteString(html.EscapeString(line) + "\n")
+	}
+	b.WriteString("</code></pre>\n")
+}
+
+func (r *renderer) blockquote(b *strings.Builder, lines []string, i int) int {
+	var inner []string
+	for ; i < len(lines); i++ {
+		trimmed := strings.TrimLeft(lines[i], " ")
+		switch {
+		case strings.HasPrefix(trimmed, ">") && indentOf(lines[i]) < 4:
+			trimmed = trimmed[1:]
+			if strings.HasPrefix(trimmed, " ") {
+				trimmed = trimmed[1:]
+			}
+			inner = append(inner, trimmed)
+		case len(inner) > 0 && !isBlank(inner[len(inner)-1]) && !interrupts(lines[i]):
+			inner = append(inner, lines[i]) // lazy continuation of a paragraph
+		default:
+			goto done
+		}
+	}
+done:
+	b.WriteString("<blockquote>\n")
+	r.blocks(b, inner, false)
+	b.WriteString("</blockquote>\n")
+	return i
+}
+
+// listItem is one item's lines, with the item's indentation removed.
+type listItem struct {
+	lines []string
+}
+
+func (r *renderer) list(b *strings.Builder, lines []string, i int) int {
+	first := listMarker.FindStringSubmatch(lines[i])
+	ordered := first[3] != ""
+	delim := first[2][len(first[2])-1:]
+	var items []listItem
+	tight := true
+	blankBefore := false
+	for i < len(lines) {
+		m := listMarker.FindStringSubmatch(lines[i])
+		if m == nil || (m[3] != "") != ordered || m[2][len(m[2])-1:] != delim {
+			break
+		}
+		if blankBefore && len(items) > 0 {
+			tight = false
+		}
+		width := len(m[0])
+		if m[4] == "" || len(m[4]) > 4 {
+			width = len(m[1]) + len(m[2]) + 1
+		}
+		item := listItem{lines: []string{lines[i][min(width, len(lines[i])):]}}
+		i++
+		blankBefore = false
+		for i < len(lines) {
+			line := lines[i]
+			if isBlank(line) {
+				item.lines = append(item.lines, "")
+				blankBefore = true
+				i++
+				continue
+			}
+			if indentOf(line) >= width {
+				if blankBefore {
+					tight = false // blank line between blocks of one item
+				}
+				item.lines = append(item.lines, line[width:])
+				blankBefore = false
+				i++
+				continue
+			}
+			if !blankBefore && !interrupts(line) && !listMarker.MatchString(line) {
+				item.lines = append(item.lines, line) // lazy continuation
+				i++
+				continue
+			}
+			break
+		}
+		for len(item.lines) > 0 && item.lines[len(item.lines)-1] == "" {
+			item.lines = item.lines[:len(item.lines)-1]
+		}
+		items = append(items, item)
+		if !blankBefore {
+			continue
+		}
+		if i >= len(lines) || !listMarker.MatchString(lines[i]) {
+			break
+		}
+	}
+
+	if ordered {
+		start, _ := strconv.Atoi(first[3])
+		if start != 1 {
+			fmt.Fprintf(b, "<ol start=\"%d\">\n", start)
+		} else {
+			b.WriteString("<ol>\n")
+		}
+	} else {
+		b.WriteString("<ul>\n")
+	}
+	for _, item := range items {
+		b.WriteString("<li>")
+		if !tight {
+			b.WriteString("\n")
+		}
+		r.blocks(b, item.lines, tight)
+		b.WriteString("</li>\n")
+	}
+	if ordered {
+		b.WriteString("</ol>\n")
+	} else {
+		b.WriteString("</ul>\n")
+	}
+	return i
+}
+
+func splitRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, "\\|") {
+		line = line[:len(line)-1]
+	}
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case line[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(line[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+func (r *renderer) table(b *strings.Builder, lines []string, i int) int {
+	header := splitRow(lines[i])
+	var aligns []string
+	for _, d := range splitRow(lines[i+1]) {
+		switch left, right := strings.HasPrefix(d, ":"), strings.HasSuffix(d, ":"); {
+		case left && right:
+			aligns = append(aligns, "center")
+		case right:
+			aligns = append(aligns, "right")
+		case left:
+			aligns = append(aligns, "left")
+		default:
+			aligns = append(aligns, "")
+		}
+	}
+	row := func(tag string, cells []string) {
+		b.WriteString("<tr>")
+		for c := range header {
+			text := ""
+			if c < len(cells) {
+				text = cells[c]
+			}
+			if c < len(aligns) && aligns[c] != "" {
+				fmt.Fprintf(b, "<%s style=\"text-align: %s\">%s</%s>", tag, aligns[c], r.inline(text), tag)
+			} else {
+				fmt.Fprintf(b, "<%s>%s</%s>", tag, r.inline(text), tag)
+			}
+		}
+		b.WriteString("</tr>\n")
+	}
+	b.WriteString("<table>\n<thead>\n")
+	row("th", header)
+	b.WriteString("</thead>\n<tbody>\n")
+	for i += 2; i < len(lines) && !isBlank(lines[i]) && strings.Contains(lines[i], "|"); i++ {
+		row("td", splitRow(lines[i]))
+	}
+	b.WriteString("</tbody>\n</table>\n")
+	return i
+}
+
+// inline renders the spans of a paragraph or heading. A NUL marks a hard
+// line break.
+func (r *renderer) inline(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && isPunct(s[i+1]):
+			b.WriteString(html.EscapeString(s[i+1 : i+2]))
+			i += 2
+		case c == 0:
+			b.WriteString("<br>")
+			i++
+		case c == '`':
+			n := runLength(s, i, '`')
+			if end := findCodeClose(s, i+n, n); end >= 0 {
+				code := strings.ReplaceAll(s[i+n:end], "\n", " ")
+				if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.TrimSpace(code) != "" {
+					code = code[1 : len(code)-1]
+				}
+				b.WriteString("<code>" + html.EscapeString(code) + "</code>")
+				i = end + n
+			} else {
+				b.WriteString(s[i : i+n])
+				i += n
+			}
+		case c == '!' && i+1 < len(s) && s[i+1] == '[':
+			if text, dest, title, end, ok := parseLink(s, i+1); ok {
+				u, alt := safeURL(dest), plainText(text)
+				if remoteURL(u) && !r.opts.RemoteImages {
+					// Link the image instead of loading it from its host
+					if alt == "" {
+						alt = u
+					}
+					fmt.Fprintf(&b, "<a class=\"remote-image\" href=\"%s\">%s</a>", html.EscapeString(u), html.EscapeString(alt))
+				} else {
+					fmt.Fprintf(&b, "<img src=\"%s\" alt=\"%s\"", html.EscapeString(u), html.EscapeString(alt))
+					if title != "" {
+						fmt.Fprintf(&b, " title=\"%s\"", html.EscapeString(title))
+					}
+					b.WriteString(">")
+				}
+				i = end
+			} else {
+				b.WriteString("!")
+				i++
+			}
+		case c == '[':
+			if text, dest, title, end, ok := parseLink(s, i); ok {
+				if u := safeURL(dest); u != "" {
+					fmt.Fprintf(&b, "<a href=\"%s\"", html.EscapeString(u))
+					if title != "" {
+						fmt.Fprintf(&b, " title=\"%s\"", html.EscapeString(title))
+					}
+					b.WriteString(">" + r.inline(text) + "</a>")
+				} else {
+					b.WriteString(r.inline(text))
+				}
+				i = end
+			} else {
+				b.WriteString("[")
+				i++
+			}
+		case c == '<':
+			if end := strings.IndexByte(s[i:], '>'); end > 0 && autolink(s[i+1:i+end]) != "" {
+				target := s[i+1 : i+end]
+				fmt.Fprintf(&b, "<a href=\"%s\">%s</a>", html.EscapeString(autolink(target)), html.EscapeString(target))
+				i += end + 1
+			} else {
+				b.WriteString("&lt;")
+				i++
+			}
+		case c == '*' || c == '_' || c == '~':
+			n := runLength(s, i, c)
+			if end, tags := findEmphasis(s, i, n); end >= 0 {
+				inner := s[i+n : end]
+				b.WriteString(openTags(tags) + r.inline(inner) + closeTags(tags))
+				i = end + n
+			} else {
+				b.WriteString(s[i : i+n])
+				i += n
+			}
+		default:
+			j := i + 1
+			for j < len(s) && !strings.ContainsRune("\\\x00`![<*_~", rune(s[j])) {
+				j++
+			}
+			b.WriteString(html.EscapeString(s[i:j]))
+			i = j
+		}
+	}
+	return b.String()
+}
+
+func isPunct(c byte) bool {
+	return c < 0x80 && unicode.IsPunct(rune(c)) || strings.IndexByte("$+<=>^`|~", c) >= 0
+}
+
+func runLength(s string, i int, c byte) int {
+	n := 0
+	for i+n < len(s) && s[i+n] == c {
+		n++
+	}
+	return n
+}
+
+// findCodeClose finds a backtick run of exactly n from i.
+func findCodeClose(s string, i, n int) int {
+	for i < len(s) {
+		j := strings.IndexByte(s[i:], '`')
+		if j < 0 {
+			return -1
+		}
+		j += i
+		m := runLength(s, j, '`')
+		if m == n {
+			return j
+		}
+		i = j + m
+	}
+	return -1
+}
+
+// findEmphasis finds where a delimiter run of n c's at i closes and the
+// tags it stands for.
+func findEmphasis(s string, i, n int) (int, []string) {
+	c := s[i]
+	var tags []string
+	switch {
+	case c == '~' && n == 2:
+		tags = []string{"del"}
+	case c == '~':
+		return -1, nil
+	case n == 1:
+		tags = []string{"em"}
+	case n == 2:
+		tags = []string{"strong"}
+	case n == 3:
+		tags = []string{"em", "strong"}
+	default:
+		return -1, nil
+	}
+	// An opening run must be followed by non-space; '_' must not be
+	// inside a word.
+	if i+n >= len(s) || s[i+n] == ' ' || s[i+n] == '\n' {
+		return -1, nil
+	}
+	if c == '_' && i > 0 && isWordByte(s[i-1]) {
+		return -1, nil
+	}
+	for j := i + n + 1; j < len(s); j++ {
+		if s[j] == '`' {
+			if end := findCodeClose(s, j+runLength(s, j, '`'), runLength(s, j, '`')); end >= 0 {
+				j = end + runLength(s, end, '`') - 1
+				continue
+			}
+		}
+		if s[j] != c {
+			continue
+		}
+		m := runLength(s, j, c)
+		if m == n && s[j-1] != ' ' && s[j-1] != '\n' && !(c == '_' && j+m < len(s) && isWordByte(s[j+m])) {
+			return j, tags
+		}
+		j += m - 1
+	}
+	return -1, nil
+}
+
+func isWordByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
+
+func openTags(tags []string) string {
+	var s string
+	for _, t := range tags {
+		s += "<" + t + ">"
+	}
+	return s
+}
+
+func closeTags(tags []string) string {
+	var s string
+	for i := len(tags) - 1; i >= 0; i-- {
+		s += "</" + tags[i] + ">"
+	}
+	return s
+}
+
+// parseLink parses [text](dest "title") at s[i] == '['.
+func parseLink(s string, i int) (text, dest, title string, end int, ok bool) {
+	depth := 0
+	j := i
+	for ; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+			continue
+		case '[':
+			depth++
+		case ']':
+			depth--
+		}
+		if depth == 0 {
+			break
+		}
+	}
+	if j >= len(s) || j+1 >= len(s) || s[j+1] != '(' {
+		return "", "", "", 0, false
+	}
+	text = s[i+1 : j]
+	k := j + 2
+	depth = 1
+	for ; k < len(s); k++ {
+		switch s[k] {
+		case '\\':
+			k++
+			continue
+		case '(':
+			depth++
+		case ')':
+			depth--
+		}
+		if depth == 0 {
+			break
+		}
+	}
+	if k >= len(s) {
+		return "", "", "", 0, false
+	}
+	inside := strings.TrimSpace(s[j+2 : k])
+	if strings.HasPrefix(inside, "<") {
+		if e := strings.IndexByte(inside, '>'); e > 0 {
+			dest, inside = inside[1:e], strings.TrimSpace(inside[e+1:])
+		}
+	} else if sp := strings.IndexAny(inside, " \n"); sp >= 0 {
+		dest, inside = inside[:sp], strings.TrimSpace(inside[sp:])
+	} else {
+		dest, inside = inside, ""
+	}
+	if len(inside) >= 2 && (inside[0] == '"' && inside[len(inside)-1] == '"' || inside[0] == '\'' && inside[len(inside)-1] == '\'') {
+		title = inside[1 : len(inside)-1]
+	} else if inside != "" {
+		return "", "", "", 0, false
+	}
+	return text, dest, title, k + 1, true
+}
+
+// safeURL returns u if it is relative or uses http, https or mailto, and ""
+// otherwise.
+func safeURL(u string) string {
+	u = strings.TrimSpace(u)
+	scheme := u
+	if k := strings.IndexAny(u, ":/?#"); k >= 0 && u[k] == ':' {
+		scheme = strings.ToLower(u[:k])
+		switch scheme {
+		case "http", "https", "mailto":
+			return u
+		}
+		return ""
+	}
+	return u
+}
+
+// remoteURL reports whether u, a URL safeURL kept, points to another host.
+func remoteURL(u string) bool {
+	lower := strings.ToLower(u)
+	return strings.HasPrefix(lower, "http:") || strings.HasPrefix(lower, "https:") || strings.HasPrefix(lower, "//")
+}
+
+// autolink returns the URL of an autolink body such as https://example.com
+// or someone@example.com, or "" if it is not one.
+func autolink(s string) string {
+	if strings.ContainsAny(s, " <>\n") {
+		return ""
+	}
+	lower := strings.ToLower(s)
+	if strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") {
+		return s
+	}
+	if at := strings.IndexByte(s, '@'); at > 0 && strings.Contains(s[at:], ".") && !strings.Contains(s, ":") {
+		return "mailto:" + s
+	}
+	return ""
+}
+
+// plainText strips Markdown punctuation from inline text, for titles, alt
+// text and anchors.
+func plainText(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && isPunct(s[i+1]):
+			b.WriteByte(s[i+1])
+			i++
+		case c == '[':
+			if text, _, _, end, ok := parseLink(s, i); ok {
+				b.WriteString(plainText(text))
+				i = end - 1
+			}
+		case c == '!' && i+1 < len(s) && s[i+1] == '[':
+		case strings.IndexByte("*_`~\x00", c) >= 0:
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
@@ -0,0 +1,70 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{"heading", "# Hello *world*", `<h1 id="hello-world">Hello <em>world</em></h1>` + "\n"},
+		{"setext", "Title\n=====\nSub\n---", `<h1 id="title">Title</h1>` + "\n" + `<h2 id="sub">Sub</h2>` + "\n"},
+		{"duplicate ids", "## A\n## A", `<h2 id="a">A</h2>` + "\n" + `<h2 id="a-1">A</h2>` + "\n"},
+		{"paragraphs", "one\ntwo\n\nthree", "<p>one\ntwo</p>\n<p>three</p>\n"},
+		{"hard break", "one  \ntwo", "<p>one<br>\ntwo</p>\n"},
+		{"emphasis", "**b** _i_ ~~s~~ snake_case_name", "<p><strong>b</strong> <em>i</em> <del>s</del> snake_case_name</p>\n"},
+		{"code span", "use `a < b` here", "<p>use <code>a &lt; b</code> here</p>\n"},
+		{"escapes", `\*not em\* <b>`, "<p>*not em* &lt;b&gt;</p>\n"},
+		{"link", `[docs](guide.md "Guide")`, `<p><a href="guide.md" title="Guide">docs</a></p>` + "\n"},
+		{"unsafe link", `[x](javascript:alert(1))`, "<p>x</p>\n"},
+		{"image", `![a cat](cat.png)`, `<p><img src="cat.png" alt="a cat"></p>` + "\n"},
+		{"remote image", `![a cat](https://example.com/cat.png)`, `<p><a class="remote-image" href="https://example.com/cat.png">a cat</a></p>` + "\n"},
+		{"remote image without alt", `![](//example.com/cat.png)`, `<p><a class="remote-image" href="//example.com/cat.png">//example.com/cat.png</a></p>` + "\n"},
+		{"autolink", "<https://example.com/?a=1&b=2>", `<p><a href="https://example.com/?a=1&amp;b=2">https://example.com/?a=1&amp;b=2</a></p>` + "\n"},
+		{"fenced code", "```go\nfmt.Println(\"<hi>\")\n```", `<pre><code class="language-go">fmt.Println(&#34;&lt;hi&gt;&#34;)` + "\n</code></pre>\n"},
+		{"indented code", "    x := 1\n\n    y := 2", "<pre><code>x := 1\n\ny := 2\n</code></pre>\n"},
+		{"blockquote", "> quoted\nlazy", "<blockquote>\n<p>quoted\nlazy</p>\n</blockquote>\n"},
+		{"tight list", "- a\n- b\n  - c", "<ul>\n<li>a\n</li>\n<li>b\n<ul>\n<li>c\n</li>\n</ul>\n</li>\n</ul>\n"},
+		{"loose list", "1. a\n\n2. b", "<ol>\n<li>\n<p>a</p>\n</li>\n<li>\n<p>b</p>\n</li>\n</ol>\n"},
+		{"list start", "3) c", "<ol start=\"3\">\n<li>c\n</li>\n</ol>\n"},
+		{"rule", "a\n\n***", "<p>a</p>\n<hr>\n"},
+		{"table", "| a | b |\n|:--|--:|\n| 1 | 2 |", "<table>\n<thead>\n" +
+			`<tr><th style="text-align: left">a</th><th style="text-align: right">b</th></tr>` + "\n</thead>\n<tbody>\n" +
+			`<tr><td style="text-align: left">1</td><td style="text-align: right">2</td></tr>` + "\n</tbody>\n</table>\n"},
+	}
+	for _, tt := range tests {
+		if got := Render([]byte(tt.src)).HTML; got != tt.want {
+			t.Errorf("%s: Render(%q) =\n%s\nwant\n%s", tt.name, tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestRenderTitle(t *testing.T) {
+	doc := Render([]byte("intro\n\n## Not this\n\n# The [Title](x.md)\n\n# Later"))
+	if doc.Title != "The Title" {
+		t.Errorf("Title = %q", doc.Title)
+	}
+	if doc := Render([]byte("no headings")); doc.Title != "" {
+		t.Errorf("Title = %q, want none", doc.Title)
+	}
+}
+
+func TestRenderEscapesHTML(t *testing.T) {
+	src := "<script>alert(1)</script>\n\n[a](\"><script>)\n\n![x](javascript:alert(1))\n\n\x00"
+	got := Render([]byte(src)).HTML
+	if strings.Contains(got, "<script") || strings.Contains(got, "javascript:") || strings.Contains(got, "\x00") {
+		t.Errorf("Render() = %s", got)
+	}
+	if got := Text([]byte("a <b> & c")); got != "<pre class=\"text\">a &lt;b&gt; &amp; c</pre>\n" {
+		t.Errorf("Text() = %s", got)
+	}
+}
+
+func TestRenderRemoteImages(t *testing.T) {
+	got := RenderWith([]byte("![a cat](https://example.com/cat.png)"), Options{RemoteImages: true}).HTML
+	if want := `<p><img src="https://example.com/cat.png" alt="a cat"></p>` + "\n"; got != want {
+		t.Errorf("RenderWith() = %s, want %s", got, want)
+	}
+}
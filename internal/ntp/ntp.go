@@ -0,0 +1,134 @@
+// Package ntp asks an NTP server how far the local clock is off, using a
+// single SNTP (RFC 4330) exchange.
+package ntp
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// DefaultServer is the NTP server queried when none is configured.
+const DefaultServer = "pool.ntp.org"
+
+// DefaultTimeout bounds a query whose context has no deadline.
+const DefaultTimeout = 5 * time.Second
+
+const (
+	packetSize = 48
+	// ntpEpochOffset is the number of seconds from 1900, the NTP epoch, to
+	// the Unix epoch.
+	ntpEpochOffset = 2208988800
+
+	modeClient = 3
+	modeServer = 4
+	version    = 4
+	leapAlarm  = 3 // server clock is not synchronized
+)
+
+// Result is the outcome of one query.
+type Result struct {
+	Server  string        `json:"server"`
+	Offset  time.Duration `json:"offset"` // add to the local clock to get the server's
+	RTT     time.Duration `json:"rtt"`
+	Stratum int           `json:"stratum"`
+}
+
+// Query asks server ("host" or "host:port", port 123 by default) for the
+// time and returns the local clock's offset from it.
+func Query(ctx context.Context, server string) (Result, error) {
+	res := Result{Server: server}
+	addr := server
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		addr = net.JoinHostPort(server, "123")
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultTimeout)
+		defer cancel()
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", addr)
+	if err != nil {
+		return res, err
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return res, err
+	}
+
+	req := make([]byte, packetSize)
+	req[0] = version<<3 | modeClient
+	sent := time.Now()
+	// The server echoes our transmit time as its originate time, which ties
+	// the answer to this request
+	putTime(req[40:], sent)
+	if _, err := conn.Write(req); err != nil {
+		return res, err
+	}
+	resp := make([]byte, packetSize+1)
+	n, err := conn.Read(resp)
+	if err != nil {
+		return res, err
+	}
+	rtt := time.Since(sent)
+	received := sent.Add(rtt)
+	if err := parse(resp[:n], req[40:48], &res); err != nil {
+		return res, err
+	}
+
+	// t1 and t4 are local, t2 and t3 the server's receive and transmit times
+	t2 := getTime(resp[32:40])
+	t3 := getTime(resp[40:48])
+	res.Offset = (t2.Sub(sent) + t3.Sub(received)) / 2
+	res.RTT = max(rtt-t3.Sub(t2), 0)
+	return res, nil
+}
+
+// parse checks a server answer to the request whose transmit timestamp was
+// origin and fills in the stratum.
+func parse(b, origin []byte, res *Result) error {
+	if len(b) != packetSize {
+		return fmt.Errorf("ntp: answer is %d bytes, want %d", len(b), packetSize)
+	}
+	if mode := b[0] & 7; mode != modeServer {
+		return fmt.Errorf("ntp: answer has mode %d, want %d", mode, modeServer)
+	}
+	if b[0]>>6 == leapAlarm {
+		return errors.New("ntp: server clock is not synchronized")
+	}
+	stratum := int(b[1])
+	if stratum == 0 || stratum > 15 {
+		return fmt.Errorf("ntp: server refused the request (stratum %d, %q)", stratum, b[12:16])
+	}
+	if string(b[24:32]) != string(origin) {
+		return errors.New("ntp: answer does not match the request")
+	}
+	if binary.BigEndian.Uint64(b[40:48]) == 0 {
+		return errors.New("ntp: answer has no transmit time")
+	}
+	res.Stratum = stratum
+	return nil
+}
+
+// putTime writes t as a 64-bit NTP timestamp.
+func putTime(b []byte, t time.Time) {
+	secs := uint64(t.Unix() + ntpEpochOffset)
+	frac := uint64(t.Nanosecond()) << 32 / 1e9
+	binary.BigEndian.PutUint64(b, secs<<32|frac)
+}
+
+// getTime reads a 64-bit NTP timestamp. Seconds with the top bit clear are
+// taken to be in era 1, which starts in 2036.
+func getTime(b []byte) time.Time {
+	secs := int64(binary.BigEndian.Uint32(b))
+	frac := int64(binary.BigEndian.Uint32(b[4:]))
+	if secs&0x80000000 == 0 {
+		secs += 1 << 32
+	}
+	return time.Unix(secs-ntpEpochOffset, frac*1e9>>32)
+}
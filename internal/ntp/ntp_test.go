@@ -0,0 +1,125 @@
+package ntp
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serve answers one request on a local UDP socket with the packet reply
+// builds from it, and returns the socket's address.
+func serve(t *testing.T, reply func(req []byte) []byte) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		buf := make([]byte, 512)
+		n, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		conn.WriteTo(reply(buf[:n]), from)
+	}()
+	return conn.LocalAddr().String()
+}
+
+// answer is a valid server reply to req from a clock offset ahead of ours.
+func answer(req []byte, offset time.Duration) []byte {
+	b := make([]byte, packetSize)
+	b[0] = version<<3 | modeServer
+	b[1] = 2
+	copy(b[24:32], req[40:48])
+	now := time.Now().Add(offset)
+	putTime(b[32:], now)
+	putTime(b[40:], now)
+	return b
+}
+
+func TestQuery(t *testing.T) {
+	tests := []struct {
+		name   string
+		reply  func(req []byte) []byte
+		offset time.Duration
+		errMsg string
+	}{
+		{"in sync", func(req []byte) []byte { return answer(req, 0) }, 0, ""},
+		{"server ahead", func(req []byte) []byte { return answer(req, 90*time.Second) }, 90 * time.Second, ""},
+		{"server behind", func(req []byte) []byte { return answer(req, -2*time.Hour) }, -2 * time.Hour, ""},
+		{"kiss of death", func(req []byte) []byte {
+			b := answer(req, 0)
+			b[1] = 0
+			copy(b[12:], "RATE")
+			return b
+		}, 0, "refused"},
+		{"not a server", func(req []byte) []byte {
+			b := answer(req, 0)
+			b[0] = version<<3 | modeClient
+			return b
+		}, 0, "mode"},
+		{"unsynchronized", func(req []byte) []byte {
+			b := answer(req, 0)
+			b[0] |= leapAlarm << 6
+			return b
+		}, 0, "not synchronized"},
+		{"spoofed", func(req []byte) []byte {
+			b := answer(req, time.Hour)
+			b[31]++
+			return b
+		}, 0, "does not match"},
+		{"short", func(req []byte) []byte { return answer(req, 0)[:40] }, 0, "40 bytes"},
+		{"long", func(req []byte) []byte { return append(answer(req, 0), 0) }, 0, "49 bytes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			res, err := Query(ctx, serve(t, tt.reply))
+			if tt.errMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+					t.Errorf("Query() error = %v, want %q", err, tt.errMsg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Query: %v", err)
+			}
+			if d := (res.Offset - tt.offset).Abs(); d > time.Second {
+				t.Errorf("offset = %s, want %s", res.Offset, tt.offset)
+			}
+			if res.Stratum != 2 {
+				t.Errorf("stratum = %d, want 2", res.Stratum)
+			}
+		})
+	}
+}
+
+func TestQueryTimeout(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket: %v", err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := Query(ctx, conn.LocalAddr().String()); err == nil {
+		t.Error("Query() of a silent server succeeded")
+	}
+}
+
+func TestTimestamps(t *testing.T) {
+	for _, want := range []time.Time{
+		time.Date(2026, 10, 16, 12, 0, 0, 500_000_000, time.UTC),
+		time.Date(2040, 1, 1, 0, 0, 0, 0, time.UTC), // NTP era 1
+	} {
+		b := make([]byte, 8)
+		putTime(b, want)
+		if got := getTime(b); got.Sub(want).Abs() > time.Microsecond {
+			t.Errorf("round trip of %s = %s", want, got)
+		}
+	}
+}
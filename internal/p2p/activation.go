@@ -0,0 +1,121 @@
+package p2p
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"alxnet/internal/core"
+)
+
+// Rule changes that would split the network if nodes applied them at
+// different times, such as a new record version or a stricter validation
+// rule, are scheduled. Each has a feature name and an activation time.
+// Nodes that implement the change advertise the feature in the hello
+// handshake, so operators can watch how many peers are ready. Validation
+// applies the new rule to records timestamped at or after the activation
+// time. Record timestamps thus serve as block heights do elsewhere: every
+// node judges a record the same way, whenever and however often it sees it.
+
+// Activation schedules a rule change.
+type Activation struct {
+	Feature string    // advertised by nodes that implement the change
+	At      time.Time // records from this time on follow the new rule
+	Summary string
+}
+
+// FeatureRecordV2 is advertised by nodes that validate update records of
+// format v2 (see core.RecordVersion).
+const FeatureRecordV2 = "record-v2"
+
+// Activations is the schedule of this build, oldest first. Entries stay
+// after their time so that older records are still judged by the old rule.
+var Activations = []Activation{
+	{
+		Feature: FeatureRecordV2,
+		At:      time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC),
+		Summary: "update records may use format v2; v1 records stay valid",
+	},
+}
+
+// ActivationStatus reports a scheduled rule change.
+type ActivationStatus struct {
+	Feature string     `json:"feature"`
+	Summary string     `json:"summary,omitempty"`
+	At      *time.Time `json:"at,omitempty"` // nil if disabled on this node
+	Active  bool       `json:"active"`
+	Ready   int        `json:"ready"` // connected peers advertising the feature
+	Peers   int        `json:"peers"` // connected peers that completed the handshake
+}
+
+// activationTime returns when feature activates on this node, zero for
+// never, and whether it is scheduled at all.
+func (n *Node) activationTime(feature string) (time.Time, bool) {
+	i := slices.IndexFunc(Activations, func(a Activation) bool { return a.Feature == feature })
+	if i < 0 {
+		return time.Time{}, false
+	}
+	if n.config != nil {
+		if at, ok := n.config.ActivationTimes[feature]; ok {
+			return at, true
+		}
+	}
+	return Activations[i].At, true
+}
+
+// Active reports whether the rule change of feature applies to a record
+// timestamped ts, in Unix seconds.
+func (n *Node) Active(feature string, ts int64) bool {
+	at, ok := n.activationTime(feature)
+	return ok && !at.IsZero() && ts >= at.Unix()
+}
+
+// checkRecordVersion applies the record format schedule. Format v1 stays
+// valid, so both are accepted from the activation of format v2 until a
+// later release schedules the end of v1.
+func (n *Node) checkRecordVersion(r *core.UpdateRecord) error {
+	if err := r.CheckFormat(); err != nil {
+		return err
+	}
+	if r.Version == core.RecordV2 && !n.Active(FeatureRecordV2, r.TS) {
+		return errors.New("record format v2 is not active at the record's time")
+	}
+	return nil
+}
+
+// ActivationStatus reports every scheduled rule change with the readiness
+// of connected peers.
+func (n *Node) ActivationStatus() []ActivationStatus {
+	now := time.Now().Unix()
+	out := make([]ActivationStatus, 0, len(Activations))
+	n.caps.mu.RLock()
+	defer n.caps.mu.RUnlock()
+	for _, a := range Activations {
+		st := ActivationStatus{Feature: a.Feature, Summary: a.Summary, Active: n.Active(a.Feature, now)}
+		if at, _ := n.activationTime(a.Feature); !at.IsZero() {
+			at = at.UTC()
+			st.At = &at
+		}
+		for _, c := range n.caps.peers {
+			if c.Legacy {
+				continue
+			}
+			st.Peers++
+			if slices.Contains(c.Features, a.Feature) {
+				st.Ready++
+			}
+		}
+		out = append(out, st)
+	}
+	return out
+}
+
+// scheduledFeatures lists the features of the activation schedule, which
+// this build implements.
+func scheduledFeatures() []string {
+	features := make([]string, 0, len(Activations))
+	for _, a := range Activations {
+		features = append(features, a.Feature)
+	}
+	return features
+}
@@ -0,0 +1,141 @@
+package p2p
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"alxnet/internal/core"
+
+	"github.com/fxamacker/cbor/v2"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	pb "github.com/libp2p/go-libp2p-pubsub/pb"
+	peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestActivation(t *testing.T) {
+	at := time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+	saved := Activations
+	Activations = []Activation{{Feature: "record-v9", At: at, Summary: "test rule"}}
+	defer func() { Activations = saved }()
+
+	n := &Node{config: DefaultNodeConfig()}
+	if n.Active("record-v9", at.Unix()-1) || !n.Active("record-v9", at.Unix()) {
+		t.Error("record-v9 does not activate at its scheduled time")
+	}
+	if n.Active("unscheduled", at.Unix()) {
+		t.Error("an unscheduled feature is active")
+	}
+	if !slices.Contains(n.Features(), "record-v9") {
+		t.Errorf("Features() = %v, missing the scheduled feature", n.Features())
+	}
+
+	earlier := at.Add(-time.Hour)
+	n.config.ActivationTimes = map[string]time.Time{"record-v9": earlier}
+	if !n.Active("record-v9", earlier.Unix()) {
+		t.Error("configured activation time ignored")
+	}
+	n.config.ActivationTimes["record-v9"] = time.Time{}
+	if n.Active("record-v9", at.Add(time.Hour).Unix()) {
+		t.Error("a feature configured never to activate is active")
+	}
+
+	n.config.ActivationTimes = nil
+	n.setCapabilities(peer.ID("ready"), &PeerCapabilities{Features: []string{"record-v9"}})
+	n.setCapabilities(peer.ID("behind"), &PeerCapabilities{Features: []string{FeatureSitemap}})
+	n.setCapabilities(peer.ID("legacy"), &PeerCapabilities{Legacy: true})
+	st := n.ActivationStatus()
+	if len(st) != 1 || st[0].Ready != 1 || st[0].Peers != 2 || st[0].At == nil || !st[0].At.Equal(at) {
+		t.Errorf("ActivationStatus() = %+v", st)
+	}
+}
+
+func TestRecordFormatSchedule(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	n := newTestNode(t, ctx)
+	_, sitePriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prev := ""
+	apply := func(env *GossipUpdate, recCID string, err error) (core.RecordVersion, error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var rec core.UpdateRecord
+		if err := cborUnmarshal(env.Record, &rec); err != nil {
+			t.Fatal(err)
+		}
+		err = n.ValidateAndApply(&rec, env.Content)
+		if err == nil {
+			prev = recCID
+		}
+		return rec.Version, err
+	}
+	v2 := func(seq uint64, extensions map[uint64]cbor.RawMessage) (*GossipUpdate, string, error) {
+		return signUpdate(sitePriv, []byte(fmt.Sprint(seq)), core.UpdateRecord{
+			Version: core.RecordV2, Seq: seq, PrevCID: prev, TS: time.Now().Unix(), Extensions: extensions,
+		})
+	}
+
+	n.config.ActivationTimes = map[string]time.Time{FeatureRecordV2: time.Now().Add(time.Hour)}
+	if v, err := apply(n.BuildUpdate(sitePriv, nil, []byte("1"), 1, prev)); err != nil || v != core.RecordV1 {
+		t.Fatalf("update before the activation = %s, %v; want v1", v, err)
+	}
+	if _, err := apply(v2(2, nil)); err == nil {
+		t.Error("v2 record accepted before the activation")
+	}
+
+	n.config.ActivationTimes[FeatureRecordV2] = time.Now().Add(-time.Hour)
+	if v, err := apply(n.BuildUpdate(sitePriv, nil, []byte("2"), 2, prev)); err != nil || v != core.RecordV2 {
+		t.Fatalf("update after the activation = %s, %v; want v2", v, err)
+	}
+	// Extensions are signed and kept; reserved fields are not allowed
+	if _, err := apply(v2(3, map[uint64]cbor.RawMessage{40: {0xf5}})); err != nil {
+		t.Errorf("v2 record with an extension: %v", err)
+	}
+	if _, err := apply(v2(4, map[uint64]cbor.RawMessage{9: {0xf5}})); err == nil {
+		t.Error("v2 record with a reserved field accepted")
+	}
+	// Both formats are valid during the migration
+	if v, err := apply(SignUpdate(sitePriv, []byte("4"), 4, prev)); err != nil || v != core.RecordV1 {
+		t.Errorf("v1 update after the activation = %s, %v", v, err)
+	}
+	if seq, head, _ := n.Store.GetHead(core.SiteIDFromPub(sitePriv.Public().(ed25519.PublicKey))); seq != 4 || head != prev {
+		t.Errorf("head = %d %s, want 4 %s", seq, head, prev)
+	}
+}
+
+func TestGossipRejectsInactiveRecordVersion(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	n := newTestNode(t, ctx)
+	_, sitePriv, _ := ed25519.GenerateKey(rand.Reader)
+	env, _, err := signUpdate(sitePriv, []byte("v2"), core.UpdateRecord{Version: core.RecordV2, Seq: 1, TS: time.Now().Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	validate := func() pubsub.ValidationResult {
+		return n.validateGossip(ctx, peer.ID("sender"), &pubsub.Message{Message: &pb.Message{Data: mustMarshal(*env)}})
+	}
+
+	n.config.ActivationTimes = map[string]time.Time{FeatureRecordV2: time.Now().Add(time.Hour)}
+	if got := validate(); got != pubsub.ValidationReject {
+		t.Errorf("v2 record before the activation: validator = %v, want reject", got)
+	}
+	if got := n.Audit(AuditFilter{Kind: AuditUpdate}); len(got) == 0 || !strings.Contains(got[0].Reason, "not active") {
+		t.Errorf("audit = %+v", got)
+	}
+	n.config.ActivationTimes[FeatureRecordV2] = time.Now().Add(-time.Hour)
+	if got := validate(); got != pubsub.ValidationAccept {
+		t.Errorf("v2 record after the activation: validator = %v, want accept", got)
+	}
+}
@@ -0,0 +1,131 @@
+package p2p
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	peer "github.com/libp2p/go-libp2p/core/peer"
+	"go.uber.org/zap"
+)
+
+// Operator controls: peer bans and the store's denylist.
+
+// MaxBanDuration bounds bans set through BanPeer.
+const MaxBanDuration = 365 * 24 * time.Hour
+
+// ErrDenied rejects updates for sites or content on the denylist.
+var ErrDenied = errors.New("site or content is on the denylist")
+
+// Ban is a banned peer and when the ban ends.
+type Ban struct {
+	Peer  peer.ID   `json:"peer"`
+	Until time.Time `json:"until"`
+}
+
+// PeerStatus is what the node knows about a peer's behaviour.
+type PeerStatus struct {
+	Peer       peer.ID   `json:"peer"`
+	Connected  bool      `json:"connected"`
+	Address    string    `json:"address,omitempty"`
+	Reputation int       `json:"reputation"`
+	LastSeen   time.Time `json:"last_seen"`
+	Agent      string    `json:"agent,omitempty"`
+
+	// GossipSub score, negative after invalid gossip; see gossip.go
+	GossipScore float64 `json:"gossip_score"`
+
+	// Storage challenges this node put to the peer, see ChallengeStorage
+	ProofsPassed int        `json:"proofs_passed"`
+	ProofsFailed int        `json:"proofs_failed"`
+	LastProof    *time.Time `json:"last_proof,omitempty"`
+
+	// Content traffic, see Bandwidth
+	PeerBandwidth
+	Leecher bool `json:"leecher"`
+}
+
+// BanPeer refuses connections from id for d and drops current ones.
+func (n *Node) BanPeer(id peer.ID, d time.Duration) (time.Time, error) {
+	if d <= 0 || d > MaxBanDuration {
+		return time.Time{}, fmt.Errorf("ban duration must be between 1s and %s", MaxBanDuration)
+	}
+	if id == n.Host.ID() {
+		return time.Time{}, fmt.Errorf("cannot ban this node")
+	}
+	until := time.Now().Add(d)
+	n.mu.Lock()
+	n.bannedPeers[id] = until
+	n.mu.Unlock()
+	if err := n.Host.Network().ClosePeer(id); err != nil {
+		n.logger.Debug("closing banned peer failed", zap.String("peer", id.String()), zap.Error(err))
+	}
+	n.logger.Info("peer banned", zap.String("peer", id.String()), zap.Time("until", until))
+	return until, nil
+}
+
+// UnbanPeer lifts a ban. It reports whether id was banned.
+func (n *Node) UnbanPeer(id peer.ID) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	_, banned := n.bannedPeers[id]
+	delete(n.bannedPeers, id)
+	return banned
+}
+
+// Bans lists active bans, ending soonest first.
+func (n *Node) Bans() []Ban {
+	now := time.Now()
+	n.mu.RLock()
+	bans := make([]Ban, 0, len(n.bannedPeers))
+	for id, until := range n.bannedPeers {
+		if until.After(now) {
+			bans = append(bans, Ban{Peer: id, Until: until})
+		}
+	}
+	n.mu.RUnlock()
+	sort.Slice(bans, func(i, j int) bool { return bans[i].Until.Before(bans[j].Until) })
+	return bans
+}
+
+// PeerStatuses lists connected peers and recently seen ones with their
+// reputation.
+func (n *Node) PeerStatuses() []PeerStatus {
+	byID := make(map[peer.ID]*PeerStatus)
+	for _, id := range n.Host.Network().Peers() {
+		st := &PeerStatus{Peer: id, Connected: true}
+		if conns := n.Host.Network().ConnsToPeer(id); len(conns) > 0 {
+			st.Address = conns[0].RemoteMultiaddr().String()
+		}
+		if caps, ok := n.PeerCapabilities(id); ok {
+			st.Agent = caps.Agent
+		}
+		st.GossipScore = n.GossipScore(id)
+		byID[id] = st
+	}
+	n.mu.RLock()
+	for id, info := range n.peers {
+		st, ok := byID[id]
+		if !ok {
+			st = &PeerStatus{Peer: id}
+			byID[id] = st
+		}
+		st.Reputation, st.LastSeen = info.Reputation, info.LastSeen
+		st.ProofsPassed, st.ProofsFailed = info.ProofsPassed, info.ProofsFailed
+		st.PeerBandwidth = PeerBandwidth{Served: info.BytesServed, Received: info.BytesReceived}
+		st.Leecher = st.PeerBandwidth.Leecher()
+		if !info.LastProof.IsZero() {
+			last := info.LastProof
+			st.LastProof = &last
+		}
+	}
+	n.mu.RUnlock()
+
+	out := make([]PeerStatus, 0, len(byID))
+	for _, st := range byID {
+		out = append(out, *st)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Peer < out[j].Peer })
+	return out
+}
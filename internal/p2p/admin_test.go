@@ -0,0 +1,70 @@
+package p2p
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"alxnet/internal/core"
+)
+
+func TestBanPeer(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	n := newTestNode(t, ctx)
+	other := newTestNode(t, ctx)
+	id := other.Host.ID()
+
+	tests := []struct {
+		name    string
+		d       time.Duration
+		wantErr bool
+	}{
+		{"zero", 0, true},
+		{"negative", -time.Hour, true},
+		{"too long", MaxBanDuration + time.Hour, true},
+		{"one hour", time.Hour, false},
+	}
+	for _, tt := range tests {
+		if _, err := n.BanPeer(id, tt.d); (err != nil) != tt.wantErr {
+			t.Errorf("%s: BanPeer() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+	if _, err := n.BanPeer(n.Host.ID(), time.Hour); err == nil {
+		t.Error("BanPeer() banned the node itself")
+	}
+	if bans := n.Bans(); len(bans) != 1 || bans[0].Peer != id {
+		t.Errorf("Bans() = %v", bans)
+	}
+	if err := n.validatePeer(id); err == nil {
+		t.Error("banned peer passes validation")
+	}
+	if !n.UnbanPeer(id) || len(n.Bans()) != 0 {
+		t.Error("UnbanPeer() did not lift the ban")
+	}
+}
+
+func TestDeniedSiteRejected(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	n := newTestNode(t, ctx)
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+
+	if _, err := n.Store.Deny(core.SiteIDFromPub(pub), "abuse"); err != nil {
+		t.Fatalf("Deny: %v", err)
+	}
+	env, _, err := SignUpdate(priv, []byte("<h1>hi</h1>"), 1, "")
+	if err != nil {
+		t.Fatalf("SignUpdate: %v", err)
+	}
+	var rec core.UpdateRecord
+	if err := cborUnmarshal(env.Record, &rec); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if err := n.ValidateAndApply(&rec, env.Content); !errors.Is(err, ErrDenied) {
+		t.Errorf("ValidateAndApply() of a denied site: err = %v, want ErrDenied", err)
+	}
+}
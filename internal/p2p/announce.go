@@ -0,0 +1,430 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"alxnet/internal/core"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"go.uber.org/zap"
+)
+
+// Head announcements tell peers which heads a node seeds. Every
+// AnnounceInterval a node publishes the head of each site it pins. A node
+// holding an older head of one of those sites fetches the missing update
+// records from the announcer with get_record requests. It checks that they
+// chain onto its head and applies them through the validation pool, so a
+// node that was offline catches up without waiting for the next publish.
+const (
+	AnnounceInterval = time.Minute
+	// MaxAnnouncedHeads bounds one announcement; nodes pinning more sites
+	// announce them in turns.
+	MaxAnnouncedHeads = 256
+	// maxSyncRecords is the most update records fetched to catch up with an
+	// announced head; sites further behind are left alone.
+	maxSyncRecords = 64
+	// maxConcurrentSyncs bounds announcements being synced at once; others
+	// are skipped until they are announced again.
+	maxConcurrentSyncs = 4
+	syncTimeout        = 2 * time.Minute
+)
+
+// GossipAnnounce lists heads the publishing node holds and pins. It is
+// vouched for by the message signature only; records fetched for it are
+// verified like any other update.
+type GossipAnnounce struct {
+	Heads []AnnouncedHead
+}
+
+func init() {
+	RegisterRecordType(RecordType{Name: "announce", Decode: decodeAnnounce, Apply: applyAnnounce})
+}
+
+func decodeAnnounce(data []byte) (any, AuditEntry, error) {
+	a := new(GossipAnnounce)
+	if err := cborUnmarshal(data, a); err != nil || len(a.Heads) == 0 {
+		return nil, AuditEntry{}, nil
+	}
+	e := AuditEntry{Kind: AuditAnnounce}
+	if err := checkAnnounce(a); err != nil {
+		return nil, e, err
+	}
+	return a, e, nil
+}
+
+func applyAnnounce(ctx context.Context, n *Node, m GossipMessage) error {
+	n.handleAnnounce(ctx, m.Origin, m.Record.(*GossipAnnounce))
+	return nil
+}
+
+// AnnouncedHead is one site head in an announcement.
+type AnnouncedHead struct {
+	SiteID  string `cbor:"s"`
+	Seq     uint64 `cbor:"q"`
+	HeadCID string `cbor:"c"`
+}
+
+// headSync tracks announcements and the syncs they start.
+type headSync struct {
+	mu      sync.Mutex
+	offset  int             // next pinned site to announce
+	syncing map[string]bool // site IDs being synced
+	active  atomic.Int32    // announcements being synced
+}
+
+// begin claims siteID for a sync; false if one is running already.
+func (s *headSync) begin(siteID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.syncing[siteID] {
+		return false
+	}
+	if s.syncing == nil {
+		s.syncing = make(map[string]bool)
+	}
+	s.syncing[siteID] = true
+	return true
+}
+
+func (s *headSync) end(siteID string) {
+	s.mu.Lock()
+	delete(s.syncing, siteID)
+	s.mu.Unlock()
+}
+
+// checkAnnounce validates an announcement's structure.
+func checkAnnounce(a *GossipAnnounce) error {
+	if len(a.Heads) > MaxAnnouncedHeads {
+		return fmt.Errorf("announcement lists %d heads, more than %d", len(a.Heads), MaxAnnouncedHeads)
+	}
+	for _, h := range a.Heads {
+		if !isHexID(h.SiteID) || !isHexID(h.HeadCID) {
+			return errors.New("malformed site ID or head CID")
+		}
+		if h.Seq < core.MinSequenceNumber || h.Seq > core.MaxSequenceNumber {
+			return fmt.Errorf("sequence number out of range: %d", h.Seq)
+		}
+	}
+	return nil
+}
+
+func (n *Node) announceLoop(ctx context.Context) {
+	ticker := time.NewTicker(AnnounceInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := n.AnnounceHeads(ctx); err != nil {
+				n.logger.Debug("head announcement failed", zap.Error(err))
+			}
+		}
+	}
+}
+
+// AnnounceHeads publishes the heads of up to MaxAnnouncedHeads pinned
+// sites, starting after the last site announced before.
+func (n *Node) AnnounceHeads(ctx context.Context) error {
+	sites, err := n.Store.ListPinnedSites()
+	if err != nil {
+		return err
+	}
+	ann := n.nextAnnouncement(sites)
+	if len(ann.Heads) == 0 {
+		return nil
+	}
+	b, err := cborMarshal(ann)
+	if err != nil {
+		return err
+	}
+	return n.Topic.Publish(ctx, b)
+}
+
+func (n *Node) nextAnnouncement(sites []string) GossipAnnounce {
+	var ann GossipAnnounce
+	if len(sites) == 0 {
+		return ann
+	}
+	n.sync.mu.Lock()
+	start := n.sync.offset % len(sites)
+	n.sync.mu.Unlock()
+	i := 0
+	for ; i < len(sites) && len(ann.Heads) < MaxAnnouncedHeads; i++ {
+		siteID := sites[(start+i)%len(sites)]
+		if has, err := n.Store.HasHead(siteID); err != nil || !has {
+			continue
+		}
+		seq, headCID, err := n.Store.GetHead(siteID)
+		if err != nil {
+			continue
+		}
+		ann.Heads = append(ann.Heads, AnnouncedHead{SiteID: siteID, Seq: seq, HeadCID: headCID})
+	}
+	n.sync.mu.Lock()
+	n.sync.offset = (start + i) % len(sites)
+	n.sync.mu.Unlock()
+	return ann
+}
+
+// handleAnnounce syncs the announced heads that are newer than this node's
+// in the background.
+func (n *Node) handleAnnounce(ctx context.Context, origin peer.ID, ann *GossipAnnounce) {
+	if origin == n.Host.ID() {
+		return
+	}
+	var behind []AnnouncedHead
+	for _, h := range ann.Heads {
+		if n.Store.IsDenied(h.SiteID) {
+			continue
+		}
+		if has, err := n.Store.HasHead(h.SiteID); err != nil || !has {
+			continue
+		}
+		seq, _, err := n.Store.GetHead(h.SiteID)
+		if err != nil || h.Seq <= seq {
+			continue
+		}
+		if h.Seq-seq > maxSyncRecords {
+			n.logger.Debug("announced head too far ahead to sync", zap.String("site", Short(h.SiteID)), zap.Uint64("seq", h.Seq), zap.Uint64("local_seq", seq))
+			continue
+		}
+		behind = append(behind, h)
+	}
+	if len(behind) == 0 {
+		return
+	}
+	if n.sync.active.Add(1) > maxConcurrentSyncs {
+		n.sync.active.Add(-1)
+		n.logger.Debug("too many syncs running, skipped announcement", zap.String("peer", origin.String()))
+		return
+	}
+	go func() {
+		defer n.sync.active.Add(-1)
+		ctx, cancel := context.WithTimeout(ctx, syncTimeout)
+		defer cancel()
+		for _, h := range behind {
+			e := AuditEntry{Kind: AuditAnnounce, Peer: origin.String(), SiteID: h.SiteID, RecordCID: h.HeadCID}
+			if err := n.syncHead(ctx, origin, h); err != nil {
+				n.audited(e, err)
+			}
+		}
+	}()
+}
+
+// syncHead fetches the update records from the local head of h.SiteID up
+// to h from p and hands them to the validation pool, oldest first. Peers
+// advertising FeatureRecordSync are sent a Bloom filter of the records held
+// here and answer with the ones missing; records the filter wrongly claims
+// are then fetched one by one, as are all records from other peers. Every
+// request is sent at background priority.
+func (n *Node) syncHead(ctx context.Context, p peer.ID, h AnnouncedHead) error {
+	if !n.sync.begin(h.SiteID) {
+		return nil
+	}
+	defer n.sync.end(h.SiteID)
+	seq, headCID, err := n.Store.GetHead(h.SiteID)
+	if err != nil || h.Seq <= seq {
+		return err
+	}
+	var missing map[string]*core.UpdateRecord
+	if n.PeerSupports(p, FeatureRecordSync) {
+		filter, err := n.recordFilter(h.SiteID)
+		if err == nil {
+			err = n.scheduled(ctx, PriorityBackground, func() (err error) {
+				missing, err = n.RequestMissingRecords(ctx, peer.AddrInfo{ID: p}, h.SiteID, filter)
+				return err
+			})
+		}
+		if err != nil {
+			n.logger.Debug("missing records request failed", zap.String("peer", p.String()), zap.String("site", Short(h.SiteID)), zap.Error(err))
+		}
+	}
+
+	chain := make([]*core.UpdateRecord, 0, h.Seq-seq)
+	cids := make([]string, 0, h.Seq-seq)
+	cid := h.HeadCID
+	for want := h.Seq; want > seq; want-- {
+		rec, ok := missing[cid]
+		if !ok {
+			err = n.scheduled(ctx, PriorityBackground, func() (err error) {
+				rec, err = n.RequestUpdateRecord(ctx, peer.AddrInfo{ID: p}, cid)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("fetch update %d: %w", want, err)
+			}
+		}
+		if core.SiteIDFromPub(rec.SitePub) != h.SiteID || rec.Seq != want {
+			return fmt.Errorf("record %s is not update %d of the site", Short(cid), want)
+		}
+		chain, cids = append(chain, rec), append(cids, cid)
+		cid = rec.PrevCID
+	}
+	if cid != headCID {
+		return fmt.Errorf("announced head does not extend local head %s", Short(headCID))
+	}
+	n.dispatch(ctx, h.SiteID, func() error {
+		e := AuditEntry{Kind: AuditUpdate, Peer: p.String(), SiteID: h.SiteID}
+		for i := len(chain) - 1; i >= 0; i-- {
+			if err := n.ValidateAndApply(chain[i], nil); err != nil {
+				e.RecordCID, e.ContentCID = cids[i], chain[i].ContentCID
+				return n.audited(e, err)
+			}
+		}
+		return nil
+	})
+	return nil
+}
+
+// recordFilter returns a Bloom filter of the update records of siteID this
+// node holds.
+func (n *Node) recordFilter(siteID string) (*cidFilter, error) {
+	heads, err := n.Store.ListHeads(siteID)
+	if err != nil {
+		return nil, err
+	}
+	f := newCIDFilter(len(heads))
+	for _, h := range heads {
+		f.add(h.CID)
+	}
+	return f, nil
+}
+
+// browseRespRecords answers get_missing with update records, newest first.
+type browseRespRecords struct {
+	Ok      bool     `cbor:"ok"`
+	Records [][]byte `cbor:"r,omitempty"`
+}
+
+// serveMissingRecords answers a get_missing request with up to
+// maxSyncRecords update records of req.SiteID that are not in req.Filter,
+// newest first.
+func (n *Node) serveMissingRecords(s network.Stream, from peer.ID, req browseReq) {
+	var resp browseRespRecords
+	e := AuditEntry{Kind: AuditBrowse, Peer: from.String(), SiteID: req.SiteID}
+	switch {
+	case !isHexID(req.SiteID):
+		e.Reason = "malformed site ID"
+	case req.Filter == nil:
+		e.Reason = "missing bloom filter"
+	case n.Store.IsDenied(req.SiteID):
+		e.Reason = ErrDenied.Error()
+	}
+	if e.Reason == "" {
+		if err := req.Filter.check(); err != nil {
+			e.Reason = err.Error()
+		}
+	}
+	if e.Reason != "" {
+		n.audit(e)
+	} else if heads, err := n.Store.ListHeads(req.SiteID); err == nil && len(heads) > 0 {
+		resp.Ok = true
+		for _, h := range heads {
+			if len(resp.Records) == maxSyncRecords {
+				break
+			}
+			if req.Filter.has(h.CID) {
+				continue
+			}
+			if data, err := n.Store.GetRecord(h.CID); err == nil && data != nil {
+				resp.Records = append(resp.Records, data)
+			}
+		}
+	}
+	b, _ := cborMarshal(resp)
+	if _, err := s.Write(b); err != nil {
+		log.Printf("handleBrowseStream: write failed: %v", err)
+	}
+}
+
+// RequestMissingRecords sends p a filter of the update records of siteID
+// held here and returns the verified records of siteID p answers with, by
+// CID.
+func (n *Node) RequestMissingRecords(ctx context.Context, p peer.AddrInfo, siteID string, filter *cidFilter) (map[string]*core.UpdateRecord, error) {
+	respBytes, err := n.browseRequest(ctx, p, browseReq{Type: "get_missing", SiteID: siteID, Filter: filter})
+	if err != nil {
+		return nil, err
+	}
+	var resp browseRespRecords
+	if err := core.UnmarshalCBOR(respBytes, &resp); err != nil {
+		return nil, err
+	}
+	if !resp.Ok {
+		return nil, ErrRecordNotFound
+	}
+	if len(resp.Records) > maxSyncRecords {
+		return nil, fmt.Errorf("peer %s sent %d records, more than %d", Short(p.ID.String()), len(resp.Records), maxSyncRecords)
+	}
+	recs := make(map[string]*core.UpdateRecord, len(resp.Records))
+	for _, data := range resp.Records {
+		rec, err := decodeUpdateRecord(data)
+		if err != nil {
+			return nil, err
+		}
+		if core.SiteIDFromPub(rec.SitePub) != siteID {
+			return nil, fmt.Errorf("peer %s sent a record of another site", Short(p.ID.String()))
+		}
+		recs[core.CIDForBytes(data)] = rec
+	}
+	return recs, nil
+}
+
+// serveUpdateRecord answers a get_record request with the update record
+// stored under req.CID.
+func (n *Node) serveUpdateRecord(s network.Stream, from peer.ID, req browseReq) {
+	var resp browseRespRecord
+	if !isHexID(req.CID) {
+		n.audit(AuditEntry{Kind: AuditBrowse, Peer: from.String(), RecordCID: req.CID, Reason: "malformed record CID"})
+	} else if data, err := n.Store.GetRecord(req.CID); err == nil && data != nil {
+		var rec core.UpdateRecord
+		if err := cborUnmarshal(data, &rec); err == nil {
+			if siteID := core.SiteIDFromPub(rec.SitePub); n.Store.IsDenied(siteID) {
+				n.audit(AuditEntry{Kind: AuditBrowse, Peer: from.String(), SiteID: siteID, RecordCID: req.CID, Reason: ErrDenied.Error()})
+			} else {
+				resp = browseRespRecord{Ok: true, CID: req.CID, Data: data}
+			}
+		}
+	}
+	b, _ := cborMarshal(resp)
+	if _, err := s.Write(b); err != nil {
+		log.Printf("handleBrowseStream: write failed: %v", err)
+	}
+}
+
+// RequestUpdateRecord asks p for the update record with CID cid. Its
+// signatures are checked; whether it fits a site's chain is up to the
+// caller.
+func (n *Node) RequestUpdateRecord(ctx context.Context, p peer.AddrInfo, cid string) (*core.UpdateRecord, error) {
+	data, err := n.requestSiteRecord(ctx, p, browseReq{Type: "get_record", CID: cid})
+	if err != nil {
+		return nil, err
+	}
+	if core.CIDForBytes(data) != cid {
+		return nil, fmt.Errorf("peer %s sent another record than %s", Short(p.ID.String()), Short(cid))
+	}
+	return decodeUpdateRecord(data)
+}
+
+// decodeUpdateRecord decodes an update record and checks its structure and
+// signatures.
+func decodeUpdateRecord(data []byte) (*core.UpdateRecord, error) {
+	var rec core.UpdateRecord
+	if err := cborUnmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("invalid update record: %w", err)
+	}
+	if err := rec.Validate(); err != nil {
+		return nil, err
+	}
+	if err := verifyUpdateSigs(&rec); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
@@ -0,0 +1,115 @@
+package p2p
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"alxnet/internal/core"
+
+	peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+// publishUpdates applies updates 1..count of the site owning sitePriv to n.
+func publishUpdates(t *testing.T, n *Node, sitePriv ed25519.PrivateKey, count int) {
+	t.Helper()
+	prev := ""
+	for seq := 1; seq <= count; seq++ {
+		env, cid, err := SignUpdate(sitePriv, []byte(fmt.Sprintf("v%d", seq)), uint64(seq), prev)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var rec core.UpdateRecord
+		cborUnmarshal(env.Record, &rec)
+		if err := n.ValidateAndApply(&rec, env.Content); err != nil {
+			t.Fatalf("apply update %d: %v", seq, err)
+		}
+		prev = cid
+	}
+}
+
+func TestAnnounceSyncsHeads(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	seeder, behind := newTestNode(t, ctx), newTestNode(t, ctx)
+	for _, n := range []*Node{seeder, behind} {
+		if err := n.Start(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, sitePriv, _ := ed25519.GenerateKey(nil)
+	siteID := core.SiteIDFromPub(sitePriv.Public().(ed25519.PublicKey))
+	publishUpdates(t, seeder, sitePriv, 4)
+	if err := seeder.Store.PinSite(siteID); err != nil {
+		t.Fatal(err)
+	}
+	// behind saw the first update only
+	heads, err := seeder.Store.ListHeads(siteID)
+	if err != nil || len(heads) != 4 {
+		t.Fatalf("ListHeads = %v, %v", heads, err)
+	}
+	first, _ := seeder.Store.GetRecord(heads[3].CID)
+	var rec core.UpdateRecord
+	cborUnmarshal(first, &rec)
+	if err := behind.ValidateAndApply(&rec, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := behind.Host.Connect(ctx, peer.AddrInfo{ID: seeder.Host.ID(), Addrs: seeder.Host.Addrs()}); err != nil {
+		t.Fatal(err)
+	}
+	wantSeq, wantCID, _ := seeder.Store.GetHead(siteID)
+	for ctx.Err() == nil {
+		if seq, cid, _ := behind.Store.GetHead(siteID); seq == wantSeq && cid == wantCID {
+			return
+		}
+		if err := seeder.AnnounceHeads(ctx); err != nil {
+			t.Fatalf("AnnounceHeads: %v", err)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	seq, _, _ := behind.Store.GetHead(siteID)
+	t.Fatalf("behind is at update %d, want %d; audit %+v", seq, wantSeq, behind.Audit(AuditFilter{}))
+}
+
+func TestRequestMissingRecords(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	seeder, behind := newTestNode(t, ctx), newTestNode(t, ctx)
+	for _, n := range []*Node{seeder, behind} {
+		if err := n.Start(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, sitePriv, _ := ed25519.GenerateKey(nil)
+	siteID := core.SiteIDFromPub(sitePriv.Public().(ed25519.PublicKey))
+	publishUpdates(t, seeder, sitePriv, 5)
+	heads, _ := seeder.Store.ListHeads(siteID)
+	seederInfo := peer.AddrInfo{ID: seeder.Host.ID(), Addrs: seeder.Host.Addrs()}
+
+	// behind holds updates 1 and 2
+	held := newCIDFilter(2)
+	held.add(heads[4].CID)
+	held.add(heads[3].CID)
+	recs, err := behind.RequestMissingRecords(ctx, seederInfo, siteID, held)
+	if err != nil {
+		t.Fatalf("RequestMissingRecords: %v", err)
+	}
+	for _, h := range heads {
+		rec, ok := recs[h.CID]
+		if wantOK := h.Seq > 2; ok != wantOK || ok && rec.Seq != h.Seq {
+			t.Errorf("update %d: got %v, want %v", h.Seq, ok, wantOK)
+		}
+	}
+
+	if _, err := behind.RequestMissingRecords(ctx, seederInfo, siteID, &cidFilter{Bits: []byte{0}, K: maxBloomHashes + 1}); err == nil {
+		t.Error("RequestMissingRecords with an invalid filter succeeded")
+	}
+	if got := seeder.Audit(AuditFilter{Kind: AuditBrowse}); len(got) == 0 || !strings.Contains(got[0].Reason, "hashes") {
+		t.Errorf("audit = %+v, want the invalid filter", got)
+	}
+}
@@ -0,0 +1,132 @@
+package p2p
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"log"
+	"time"
+
+	"alxnet/internal/core"
+	bncrypto "alxnet/internal/crypto"
+)
+
+// GossipAppData carries an app data record.
+type GossipAppData struct {
+	AppData []byte // canonical CBOR of AppDataRecord
+}
+
+func init() {
+	RegisterRecordType(envelopedRecord("appdata", AuditAppData,
+		func(env *GossipAppData) []byte { return env.AppData },
+		func(rec *core.AppDataRecord, e *AuditEntry) { e.SiteID = core.SiteIDFromPub(rec.SitePub) },
+		verifyAppData, (*Node).handleAppData))
+}
+
+// ErrAppDataNotKept is returned for app data of sites this node neither
+// stores nor follows; other sites' app data is not kept.
+var ErrAppDataNotKept = errors.New("app data site not stored or followed")
+
+// BuildAppData signs the JSON value of a site's app data key, or its
+// deletion for an empty value. seq must be higher than that of the site's
+// previous record for key.
+func BuildAppData(sitePriv ed25519.PrivateKey, key string, value []byte, seq uint64) (*core.AppDataRecord, error) {
+	rec := &core.AppDataRecord{
+		Version: "v1",
+		SitePub: sitePriv.Public().(ed25519.PublicKey),
+		Key:     key,
+		Value:   value,
+		Seq:     seq,
+		TS:      time.Now().Unix(),
+	}
+	b, err := core.CanonicalMarshalAppDataNoSig(rec)
+	if err != nil {
+		return nil, err
+	}
+	rec.Sig = ed25519.Sign(sitePriv, bncrypto.PreimageAppData(b))
+	return rec, rec.Validate()
+}
+
+// NextAppDataSeq returns a sequence number for a new record of siteID's
+// app data key: the current time in milliseconds, or one past the stored
+// record if that is higher.
+func (n *Node) NextAppDataSeq(siteID, key string) (uint64, error) {
+	seq := uint64(time.Now().UnixMilli())
+	cur, err := n.Store.GetAppData(siteID, key)
+	if err != nil {
+		return 0, err
+	}
+	if cur != nil && cur.Seq >= seq {
+		seq = cur.Seq + 1
+	}
+	return seq, nil
+}
+
+// verifyAppData checks structure and signature of an app data record.
+func verifyAppData(rec *core.AppDataRecord) error {
+	if err := rec.Validate(); err != nil {
+		return err
+	}
+	b, err := core.CanonicalMarshalAppDataNoSig(rec)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(ed25519.PublicKey(rec.SitePub), bncrypto.PreimageAppData(b), rec.Sig) {
+		return errors.New("invalid app data signature")
+	}
+	return nil
+}
+
+// ApplyAppData verifies and stores an app data record of a site this node
+// stores or follows. It reports whether the record replaced the stored one.
+func (n *Node) ApplyAppData(rec *core.AppDataRecord) (bool, error) {
+	return n.applyAppData(rec, false)
+}
+
+func (n *Node) applyAppData(rec *core.AppDataRecord, local bool) (bool, error) {
+	if err := verifyAppData(rec); err != nil {
+		return false, err
+	}
+	siteID := core.SiteIDFromPub(rec.SitePub)
+	if !local && !n.storesSite(siteID) {
+		f, err := n.Store.GetFollow(siteID)
+		if err != nil {
+			return false, err
+		}
+		if f == nil {
+			return false, ErrAppDataNotKept
+		}
+	}
+	b, err := core.MarshalCanonical(rec)
+	if err != nil {
+		return false, err
+	}
+	return n.Store.PutAppData(siteID, rec, b)
+}
+
+// PublishAppData stores an app data record locally and gossips it.
+func (n *Node) PublishAppData(ctx context.Context, rec *core.AppDataRecord) error {
+	applied, err := n.applyAppData(rec, true)
+	if err != nil {
+		return err
+	}
+	if !applied {
+		return errors.New("an app data record with the same or a higher sequence is already stored")
+	}
+	b, err := cborMarshal(GossipAppData{AppData: mustMarshal(rec)})
+	if err != nil {
+		return err
+	}
+	return n.Topic.Publish(ctx, b)
+}
+
+func (n *Node) handleAppData(rec *core.AppDataRecord) error {
+	_, err := n.ApplyAppData(rec)
+	if errors.Is(err, ErrAppDataNotKept) {
+		return nil
+	}
+	if err != nil {
+		log.Printf("reject app data: %v", err)
+	}
+	return err
+}
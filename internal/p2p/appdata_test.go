@@ -0,0 +1,96 @@
+package p2p
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"alxnet/internal/core"
+)
+
+func TestAppData(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	n := newTestNode(t, ctx)
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	siteID := core.SiteIDFromPub(pub)
+
+	rec, err := BuildAppData(priv, "config/theme", []byte(`{"dark":true}`), 1)
+	if err != nil {
+		t.Fatalf("BuildAppData: %v", err)
+	}
+	if _, err := n.ApplyAppData(rec); !errors.Is(err, ErrAppDataNotKept) {
+		t.Fatalf("app data of an unknown site: err = %v, want ErrAppDataNotKept", err)
+	}
+	if _, err := n.Store.FollowSite(siteID, ""); err != nil {
+		t.Fatalf("FollowSite: %v", err)
+	}
+	if ok, err := n.ApplyAppData(rec); err != nil || !ok {
+		t.Fatalf("ApplyAppData = %v, %v", ok, err)
+	}
+
+	tampered := *rec
+	tampered.Value, tampered.Seq = []byte(`{"dark":false}`), 5
+	if _, err := n.ApplyAppData(&tampered); err == nil {
+		t.Error("app data with a value not matching its signature was accepted")
+	}
+
+	// A deletion is kept, so replaying the old value does not restore it
+	deleted, _ := BuildAppData(priv, "config/theme", nil, 2)
+	if ok, err := n.ApplyAppData(deleted); err != nil || !ok {
+		t.Fatalf("ApplyAppData(deletion) = %v, %v", ok, err)
+	}
+	if ok, err := n.ApplyAppData(rec); err != nil || ok {
+		t.Errorf("ApplyAppData(replay) = %v, %v, want not applied", ok, err)
+	}
+	got, err := n.Store.GetAppData(siteID, "config/theme")
+	if err != nil || got == nil || !got.Deleted() {
+		t.Fatalf("GetAppData = %+v, %v", got, err)
+	}
+
+	seq, err := n.NextAppDataSeq(siteID, "config/theme")
+	if err != nil || seq <= 2 {
+		t.Errorf("NextAppDataSeq = %d, %v", seq, err)
+	}
+	scores, _ := BuildAppData(priv, "scores", []byte(`[3,1,2]`), seq)
+	if ok, err := n.ApplyAppData(scores); err != nil || !ok {
+		t.Fatalf("ApplyAppData(scores) = %v, %v", ok, err)
+	}
+	list, err := n.Store.ListAppData(siteID)
+	if err != nil || len(list) != 2 || list[0].Key != "config/theme" || list[1].Key != "scores" {
+		t.Errorf("ListAppData = %+v, %v", list, err)
+	}
+}
+
+func TestAppDataValidate(t *testing.T) {
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+
+	tests := []struct {
+		name  string
+		key   string
+		value string
+		seq   uint64
+	}{
+		{"empty key", "", `1`, 1},
+		{"uppercase key", "Theme", `1`, 1},
+		{"leading slash", "/theme", `1`, 1},
+		{"empty segment", "config//theme", `1`, 1},
+		{"dot segment", "config/../theme", `1`, 1},
+		{"key too long", strings.Repeat("a", core.MaxAppDataKeyLength+1), `1`, 1},
+		{"not JSON", "theme", `{dark}`, 1},
+		{"value too large", "theme", `"` + strings.Repeat("a", core.MaxAppDataValueSize) + `"`, 1},
+		{"zero seq", "theme", `1`, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := BuildAppData(priv, tt.key, []byte(tt.value), tt.seq); err == nil {
+				t.Error("BuildAppData() succeeded")
+			}
+		})
+	}
+}
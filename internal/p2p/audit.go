@@ -0,0 +1,142 @@
+package p2p
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// AuditLogSize is how many rejections the node keeps for the admin API.
+const AuditLogSize = 1000
+
+// maxAuditField bounds peer-supplied IDs and reasons kept in the audit log.
+const maxAuditField = 256
+
+// Audit entry kinds.
+const (
+	AuditUpdate     = "update"
+	AuditDelete     = "delete"
+	AuditComment    = "comment"
+	AuditModeration = "moderation"
+	AuditPointer    = "pointer"
+	AuditAppData    = "appdata"
+	AuditBrowse     = "browse"
+	AuditConnection = "connection"
+	AuditProof      = "proof"
+	AuditDomain     = "domain"
+	AuditAnnounce   = "announce"
+	AuditAuth       = "auth" // failed logins and tokens on the HTTP APIs
+)
+
+// AuditEntry is a gossip message, browse request, connection or HTTP
+// login the node refused, and why.
+type AuditEntry struct {
+	Time       time.Time `json:"time"`
+	Kind       string    `json:"kind"`
+	Peer       string    `json:"peer,omitempty"`
+	Client     string    `json:"client,omitempty"` // HTTP client address of auth entries
+	SiteID     string    `json:"site_id,omitempty"`
+	RecordCID  string    `json:"record_cid,omitempty"`
+	ContentCID string    `json:"content_cid,omitempty"`
+	Reason     string    `json:"reason"`
+}
+
+// AuditFilter selects audit entries; empty fields match everything. CID
+// matches either the record or the content CID.
+type AuditFilter struct {
+	Kind   string
+	Peer   string
+	SiteID string
+	CID    string
+	Since  time.Time
+	Limit  int // at most AuditLogSize; zero returns all matches
+}
+
+func (f AuditFilter) match(e *AuditEntry) bool {
+	return (f.Kind == "" || e.Kind == f.Kind) &&
+		(f.Peer == "" || e.Peer == f.Peer) &&
+		(f.SiteID == "" || e.SiteID == f.SiteID) &&
+		(f.CID == "" || e.RecordCID == f.CID || e.ContentCID == f.CID) &&
+		!e.Time.Before(f.Since)
+}
+
+// auditLog keeps the newest AuditLogSize entries in a ring.
+type auditLog struct {
+	mu      sync.Mutex
+	entries []AuditEntry
+	next    int
+}
+
+func (a *auditLog) add(e AuditEntry) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.entries) < AuditLogSize {
+		a.entries = append(a.entries, e)
+		return
+	}
+	a.entries[a.next] = e
+	a.next = (a.next + 1) % AuditLogSize
+}
+
+// query returns matching entries, newest first.
+func (a *auditLog) query(f AuditFilter) []AuditEntry {
+	if f.Limit <= 0 || f.Limit > AuditLogSize {
+		f.Limit = AuditLogSize
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	out := []AuditEntry{}
+	for i := 0; i < len(a.entries) && len(out) < f.Limit; i++ {
+		// Walk back from the newest entry
+		idx := (a.next - 1 - i + 2*len(a.entries)) % len(a.entries)
+		if e := &a.entries[idx]; f.match(e) {
+			out = append(out, *e)
+		}
+	}
+	return out
+}
+
+// Audit returns recent rejections matching f, newest first.
+func (n *Node) Audit(f AuditFilter) []AuditEntry {
+	return n.audits.query(f)
+}
+
+// RecordAudit adds a rejection outside the node, such as a failed login on
+// an HTTP API, to the audit log.
+func (n *Node) RecordAudit(e AuditEntry) {
+	n.audit(e)
+}
+
+// audit records a rejection and writes it to the audit logger.
+func (n *Node) audit(e AuditEntry) {
+	e.Time = time.Now()
+	e.Client = clipAudit(e.Client)
+	e.SiteID, e.RecordCID = clipAudit(e.SiteID), clipAudit(e.RecordCID)
+	e.ContentCID, e.Reason = clipAudit(e.ContentCID), clipAudit(e.Reason)
+	n.audits.add(e)
+	n.auditLogger.Info("rejected",
+		zap.String("kind", e.Kind),
+		zap.String("peer", e.Peer),
+		zap.String("client", e.Client),
+		zap.String("site", e.SiteID),
+		zap.String("record", e.RecordCID),
+		zap.String("content", e.ContentCID),
+		zap.String("reason", e.Reason))
+}
+
+// audited records err, if any, against e and returns it.
+func (n *Node) audited(e AuditEntry, err error) error {
+	if err != nil {
+		e.Reason = err.Error()
+		n.audit(e)
+	}
+	return err
+}
+
+func clipAudit(s string) string {
+	if len(s) > maxAuditField {
+		return s[:maxAuditField]
+	}
+	return s
+}
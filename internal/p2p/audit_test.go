@@ -0,0 +1,88 @@
+package p2p
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestAuditLog(t *testing.T) {
+	var a auditLog
+	start := time.Now()
+	for i := 0; i < AuditLogSize+10; i++ {
+		kind := AuditUpdate
+		if i%2 == 1 {
+			kind = AuditBrowse
+		}
+		a.add(AuditEntry{
+			Time:      start.Add(time.Duration(i) * time.Second),
+			Kind:      kind,
+			Peer:      fmt.Sprintf("peer%d", i%3),
+			RecordCID: fmt.Sprintf("rec%d", i),
+			Reason:    "test",
+		})
+	}
+
+	tests := []struct {
+		name      string
+		filter    AuditFilter
+		wantCount int
+		wantFirst string // record CID of the newest match
+	}{
+		{"all, ring wrapped", AuditFilter{}, AuditLogSize, fmt.Sprintf("rec%d", AuditLogSize+9)},
+		{"limit", AuditFilter{Limit: 5}, 5, fmt.Sprintf("rec%d", AuditLogSize+9)},
+		{"kind", AuditFilter{Kind: AuditUpdate, Limit: 3}, 3, fmt.Sprintf("rec%d", AuditLogSize+8)},
+		{"cid", AuditFilter{CID: "rec500"}, 1, "rec500"},
+		{"evicted cid", AuditFilter{CID: "rec0"}, 0, ""},
+		{"since", AuditFilter{Since: start.Add(time.Duration(AuditLogSize+7) * time.Second)}, 3, fmt.Sprintf("rec%d", AuditLogSize+9)},
+		{"peer and kind", AuditFilter{Peer: "peer0", Kind: AuditBrowse}, AuditLogSize / 6, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := a.query(tt.filter)
+			if len(got) != tt.wantCount {
+				t.Fatalf("got %d entries, want %d", len(got), tt.wantCount)
+			}
+			if tt.wantFirst != "" && got[0].RecordCID != tt.wantFirst {
+				t.Errorf("newest entry = %s, want %s", got[0].RecordCID, tt.wantFirst)
+			}
+			for i := 1; i < len(got); i++ {
+				if got[i].Time.After(got[i-1].Time) {
+					t.Fatal("entries not newest first")
+				}
+			}
+		})
+	}
+}
+
+func TestAuditRecordsRejections(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	n := newTestNode(t, ctx)
+	client := newTestNode(t, ctx)
+	const siteID = "aa00000000000000000000000000000000000000000000000000000000000000"
+	if _, err := n.Store.Deny(siteID, "abuse"); err != nil {
+		t.Fatalf("Deny: %v", err)
+	}
+
+	info := peer.AddrInfo{ID: n.Host.ID(), Addrs: n.Host.Addrs()}
+	if _, _, _, err := client.RequestHead(ctx, info, siteID); err == nil {
+		t.Error("RequestHead() of a denied site succeeded")
+	}
+	got := n.Audit(AuditFilter{Kind: AuditBrowse})
+	if len(got) != 1 || got[0].SiteID != siteID || got[0].Peer != client.Host.ID().String() || got[0].Reason != ErrDenied.Error() {
+		t.Fatalf("audit = %+v", got)
+	}
+
+	long := make([]byte, 4*maxAuditField)
+	for i := range long {
+		long[i] = 'a'
+	}
+	n.audit(AuditEntry{Kind: AuditUpdate, SiteID: string(long), Reason: string(long)})
+	if e := n.Audit(AuditFilter{Kind: AuditUpdate})[0]; len(e.SiteID) != maxAuditField || len(e.Reason) != maxAuditField {
+		t.Errorf("peer-supplied fields not clipped: %d, %d", len(e.SiteID), len(e.Reason))
+	}
+}
@@ -0,0 +1,79 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+
+	peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Availability reports how many sampled peers hold each content CID.
+type Availability struct {
+	SiteID    string         `json:"site_id"`
+	Queried   int            `json:"queried"`   // peers sampled
+	Responded int            `json:"responded"` // peers that answered
+	Holders   map[string]int `json:"holders"`   // content CID -> peers holding it
+}
+
+// CheckAvailability asks up to samplePeers randomly chosen connected peers
+// which of cids they store. Peers that fail to answer count as not holding
+// anything, so the result is a lower bound on availability.
+func (n *Node) CheckAvailability(ctx context.Context, siteID string, cids []string, samplePeers int) (*Availability, error) {
+	if samplePeers <= 0 {
+		return nil, errors.New("sample size must be positive")
+	}
+
+	peers := n.Host.Network().Peers()
+	rand.Shuffle(len(peers), func(i, j int) { peers[i], peers[j] = peers[j], peers[i] })
+	if len(peers) > samplePeers {
+		peers = peers[:samplePeers]
+	}
+
+	report := &Availability{
+		SiteID:  siteID,
+		Queried: len(peers),
+		Holders: make(map[string]int, len(cids)),
+	}
+	unique := cids[:0:0]
+	for _, cid := range cids {
+		if _, seen := report.Holders[cid]; !seen {
+			report.Holders[cid] = 0
+			unique = append(unique, cid)
+		}
+	}
+	cids = unique
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, p := range peers {
+		wg.Add(1)
+		go func(p peer.ID) {
+			defer wg.Done()
+			held := make([]string, 0, len(cids))
+			for start := 0; start < len(cids); start += MaxHaveCIDs {
+				end := min(start+MaxHaveCIDs, len(cids))
+				ack, err := n.queryHave(ctx, p, haveReq{SiteID: siteID, CIDs: cids[start:end]})
+				if err != nil {
+					return
+				}
+				for i, ok := range ack.Content {
+					if ok {
+						held = append(held, cids[start+i])
+					}
+				}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Responded++
+			for _, cid := range held {
+				report.Holders[cid]++
+			}
+		}(p)
+	}
+	wg.Wait()
+
+	return report, nil
+}
@@ -0,0 +1,74 @@
+package p2p
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestCheckAvailability(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	const siteID = "aa00000000000000000000000000000000000000000000000000000000000000"
+	const indexCID = "1100000000000000000000000000000000000000000000000000000000000000"
+	const styleCID = "2200000000000000000000000000000000000000000000000000000000000000"
+	const logoCID = "3300000000000000000000000000000000000000000000000000000000000000"
+
+	checker := newTestNode(t, ctx)
+	full := newTestNode(t, ctx)
+	partial := newTestNode(t, ctx)
+
+	for _, cid := range []string{indexCID, styleCID} {
+		if err := full.Store.PutContent(cid, []byte(cid)); err != nil {
+			t.Fatalf("PutContent: %v", err)
+		}
+	}
+	if err := partial.Store.PutContent(indexCID, []byte(indexCID)); err != nil {
+		t.Fatalf("PutContent: %v", err)
+	}
+
+	for _, other := range []*Node{full, partial} {
+		info := peer.AddrInfo{ID: other.Host.ID(), Addrs: other.Host.Addrs()}
+		if err := checker.Host.Connect(ctx, info); err != nil {
+			t.Fatalf("Connect: %v", err)
+		}
+	}
+
+	// indexCID appears twice to check that duplicates are counted once.
+	report, err := checker.CheckAvailability(ctx, siteID, []string{indexCID, styleCID, logoCID, indexCID}, 5)
+	if err != nil {
+		t.Fatalf("CheckAvailability() error = %v", err)
+	}
+	if report.Queried != 2 || report.Responded != 2 {
+		t.Errorf("queried/responded = %d/%d, want 2/2", report.Queried, report.Responded)
+	}
+
+	tests := []struct {
+		cid  string
+		want int
+	}{
+		{indexCID, 2},
+		{styleCID, 1},
+		{logoCID, 0},
+	}
+	for _, tt := range tests {
+		if got := report.Holders[tt.cid]; got != tt.want {
+			t.Errorf("holders[%s] = %d, want %d", Short(tt.cid), got, tt.want)
+		}
+	}
+
+	report, err = checker.CheckAvailability(ctx, siteID, []string{indexCID}, 1)
+	if err != nil {
+		t.Fatalf("CheckAvailability() error = %v", err)
+	}
+	if report.Queried != 1 {
+		t.Errorf("queried = %d, want sample of 1", report.Queried)
+	}
+
+	if _, err := checker.CheckAvailability(ctx, siteID, []string{indexCID}, 0); err == nil {
+		t.Error("CheckAvailability() with zero sample size should fail")
+	}
+}
@@ -0,0 +1,123 @@
+package p2p
+
+import (
+	"sync/atomic"
+
+	peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Bandwidth accounting counts, per peer, the content bytes this node served
+// it over BrowseProto and the content bytes it gave back, either in answer
+// to this node's requests or inline in gossiped updates. While more content
+// requests are in flight than NodeConfig.MaxConcurrentServes, the node is
+// saturated and NodeConfig.ServePolicy decides which peers are still
+// answered; by default peers that only take are turned away first.
+
+// DefaultMaxConcurrentServes is the default for
+// NodeConfig.MaxConcurrentServes.
+const DefaultMaxConcurrentServes = 32
+
+const (
+	// LeecherMinServed is how many bytes a peer is served before it can
+	// count as a leecher, so that new peers get a fair start.
+	LeecherMinServed = 1 << 20
+	// LeecherRatio is how many times more than it gave back a leecher has
+	// been served.
+	LeecherRatio = 10
+)
+
+// PeerBandwidth is the content traffic exchanged with a peer.
+type PeerBandwidth struct {
+	Served   int64 `json:"bytes_served"`   // bytes this node sent the peer
+	Received int64 `json:"bytes_received"` // bytes the peer sent this node
+}
+
+// Leecher reports whether the peer took far more than it gave.
+func (b PeerBandwidth) Leecher() bool {
+	return b.Served >= LeecherMinServed && b.Received*LeecherRatio < b.Served
+}
+
+// ServePolicy decides whether to answer a content request from a peer with
+// traffic b; saturated reports whether the node is over its concurrent
+// serve limit.
+type ServePolicy func(b PeerBandwidth, saturated bool) bool
+
+// DefaultServePolicy answers everyone until the node is saturated, and then
+// only peers that are not leechers.
+func DefaultServePolicy(b PeerBandwidth, saturated bool) bool {
+	return !saturated || !b.Leecher()
+}
+
+// BandwidthStats are the node's traffic totals since start.
+type BandwidthStats struct {
+	Served   int64 `json:"bytes_served"`
+	Received int64 `json:"bytes_received"`
+	InFlight int64 `json:"in_flight"` // content requests being answered
+	Deferred int64 `json:"deferred"`  // requests the serve policy turned away
+	Limit    int   `json:"limit"`     // concurrent requests before saturation
+}
+
+// bandwidthCounters holds the node-wide totals.
+type bandwidthCounters struct {
+	served, received, inFlight, deferred atomic.Int64
+}
+
+// Bandwidth returns the content traffic exchanged with p.
+func (n *Node) Bandwidth(p peer.ID) PeerBandwidth {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	if info, ok := n.peers[p]; ok {
+		return PeerBandwidth{Served: info.BytesServed, Received: info.BytesReceived}
+	}
+	return PeerBandwidth{}
+}
+
+// BandwidthStats returns the node's traffic totals.
+func (n *Node) BandwidthStats() BandwidthStats {
+	return BandwidthStats{
+		Served:   n.bandwidth.served.Load(),
+		Received: n.bandwidth.received.Load(),
+		InFlight: n.bandwidth.inFlight.Load(),
+		Deferred: n.bandwidth.deferred.Load(),
+		Limit:    n.maxConcurrentServes(),
+	}
+}
+
+func (n *Node) maxConcurrentServes() int {
+	if n.config == nil || n.config.MaxConcurrentServes <= 0 {
+		return DefaultMaxConcurrentServes
+	}
+	return n.config.MaxConcurrentServes
+}
+
+// addBandwidth adds served and received bytes to p's account.
+func (n *Node) addBandwidth(p peer.ID, served, received int64) {
+	n.bandwidth.served.Add(served)
+	n.bandwidth.received.Add(received)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	info, ok := n.peers[p]
+	if !ok {
+		info = &PeerInfo{ID: p}
+		n.peers[p] = info
+	}
+	info.BytesServed += served
+	info.BytesReceived += received
+}
+
+// admitServe counts a content request from p as in flight and asks the
+// serve policy whether to answer it. When it returns true, the caller must
+// call the returned function once the answer is sent.
+func (n *Node) admitServe(p peer.ID) (func(), bool) {
+	saturated := n.bandwidth.inFlight.Add(1) > int64(n.maxConcurrentServes())
+	policy := DefaultServePolicy
+	if n.config != nil && n.config.ServePolicy != nil {
+		policy = n.config.ServePolicy
+	}
+	if !policy(n.Bandwidth(p), saturated) {
+		n.bandwidth.inFlight.Add(-1)
+		n.bandwidth.deferred.Add(1)
+		return nil, false
+	}
+	return func() { n.bandwidth.inFlight.Add(-1) }, true
+}
@@ -0,0 +1,102 @@
+package p2p
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"alxnet/internal/core"
+	"alxnet/internal/store"
+
+	peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestServePolicy(t *testing.T) {
+	tests := []struct {
+		name      string
+		b         PeerBandwidth
+		saturated bool
+		leecher   bool
+		serve     bool
+	}{
+		{"new peer", PeerBandwidth{}, true, false, true},
+		{"below the minimum", PeerBandwidth{Served: LeecherMinServed - 1}, true, false, true},
+		{"leecher, idle node", PeerBandwidth{Served: LeecherMinServed}, false, true, true},
+		{"leecher, saturated node", PeerBandwidth{Served: LeecherMinServed}, true, true, false},
+		{"gives back a little", PeerBandwidth{Served: 10 * LeecherMinServed, Received: LeecherMinServed}, true, false, true},
+		{"gives back too little", PeerBandwidth{Served: 10 * LeecherMinServed, Received: LeecherMinServed - 1}, true, true, false},
+	}
+	for _, tt := range tests {
+		if got := tt.b.Leecher(); got != tt.leecher {
+			t.Errorf("%s: Leecher() = %v, want %v", tt.name, got, tt.leecher)
+		}
+		if got := DefaultServePolicy(tt.b, tt.saturated); got != tt.serve {
+			t.Errorf("%s: DefaultServePolicy() = %v, want %v", tt.name, got, tt.serve)
+		}
+	}
+}
+
+func TestBandwidthAccounting(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	db, err := store.NewMemory()
+	if err != nil {
+		t.Fatalf("store.NewMemory: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	var (
+		mu   sync.Mutex
+		seen []PeerBandwidth
+	)
+	cfg := DefaultNodeConfig()
+	cfg.MaxConcurrentServes = 1
+	// Answer each peer once
+	cfg.ServePolicy = func(b PeerBandwidth, saturated bool) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		if saturated {
+			t.Error("a single request saturated the node")
+		}
+		seen = append(seen, b)
+		return b.Served == 0
+	}
+	seeder, err := New(ctx, db, "/ip4/127.0.0.1/tcp/0", nil, cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { seeder.Host.Close() })
+	client := newTestNode(t, ctx)
+
+	content := bytes.Repeat([]byte("seed "), 1000)
+	cid := core.CIDForContent(content)
+	if err := seeder.Store.PutContent(cid, content); err != nil {
+		t.Fatalf("PutContent: %v", err)
+	}
+	info := peer.AddrInfo{ID: seeder.Host.ID(), Addrs: seeder.Host.Addrs()}
+	if _, err := client.RequestContent(ctx, info, cid); err != nil {
+		t.Fatalf("RequestContent: %v", err)
+	}
+	if _, err := client.RequestContent(ctx, info, cid); err == nil || err.Error() != "peer busy" {
+		t.Errorf("second request error = %v, want peer busy", err)
+	}
+
+	want := int64(len(content))
+	if got := seeder.Bandwidth(client.Host.ID()); got.Served != want || got.Received != 0 {
+		t.Errorf("seeder's account of the client = %+v, want %d served", got, want)
+	}
+	if got := client.Bandwidth(seeder.Host.ID()); got.Received != want || got.Served != 0 {
+		t.Errorf("client's account of the seeder = %+v, want %d received", got, want)
+	}
+	stats := seeder.BandwidthStats()
+	if stats.Served != want || stats.Deferred != 1 || stats.InFlight != 0 || stats.Limit != 1 {
+		t.Errorf("seeder stats = %+v", stats)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(seen) != 2 || seen[1].Served != want {
+		t.Errorf("policy saw %+v, want the first transfer on the second request", seen)
+	}
+}
@@ -0,0 +1,80 @@
+package p2p
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+)
+
+// Bloom filter settings. A node syncing a site sends a filter of the
+// record CIDs it holds, so the peer only returns records it lacks. At 1%
+// false positives a record the node lacks is now and then left out; the
+// node notices the gap in the chain and fetches that record by CID.
+const (
+	bloomFalsePositive = 0.01
+	bloomMinBytes      = 64
+	// MaxBloomBytes bounds a filter on the wire; 64 KiB holds about 55000
+	// CIDs at 1% false positives.
+	MaxBloomBytes  = 64 * 1024
+	maxBloomHashes = 16
+)
+
+// cidFilter is a Bloom filter of CIDs, using double hashing over the
+// digest a hex SHA-256 CID already is.
+type cidFilter struct {
+	Bits []byte `cbor:"b"`
+	K    uint8  `cbor:"k"`
+}
+
+// newCIDFilter returns a filter sized for count CIDs.
+func newCIDFilter(count int) *cidFilter {
+	bits := math.Ceil(-float64(max(count, 1)) * math.Log(bloomFalsePositive) / (math.Ln2 * math.Ln2))
+	size := min(max(int(bits+7)/8, bloomMinBytes), MaxBloomBytes)
+	k := math.Round(float64(size*8) / float64(max(count, 1)) * math.Ln2)
+	return &cidFilter{Bits: make([]byte, size), K: uint8(min(max(k, 1), maxBloomHashes))}
+}
+
+// check rejects filters a peer could use to make this node work too hard.
+func (f *cidFilter) check() error {
+	if len(f.Bits) == 0 || len(f.Bits) > MaxBloomBytes {
+		return fmt.Errorf("bloom filter of %d bytes, want 1 to %d", len(f.Bits), MaxBloomBytes)
+	}
+	if f.K == 0 || f.K > maxBloomHashes {
+		return fmt.Errorf("bloom filter with %d hashes, want 1 to %d", f.K, maxBloomHashes)
+	}
+	return nil
+}
+
+func (f *cidFilter) hashes(cid string) (uint64, uint64) {
+	b, err := hex.DecodeString(cid)
+	if err != nil || len(b) < 16 {
+		sum := sha256.Sum256([]byte(cid))
+		b = sum[:]
+	}
+	// A zero step would test the same bit k times
+	return binary.BigEndian.Uint64(b), binary.BigEndian.Uint64(b[8:]) | 1
+}
+
+func (f *cidFilter) add(cid string) {
+	m := uint64(len(f.Bits)) * 8
+	h1, h2 := f.hashes(cid)
+	for i := uint64(0); i < uint64(f.K); i++ {
+		bit := (h1 + i*h2) % m
+		f.Bits[bit/8] |= 1 << (bit % 8)
+	}
+}
+
+// has reports whether cid may be in the filter; false means it is not.
+func (f *cidFilter) has(cid string) bool {
+	m := uint64(len(f.Bits)) * 8
+	h1, h2 := f.hashes(cid)
+	for i := uint64(0); i < uint64(f.K); i++ {
+		bit := (h1 + i*h2) % m
+		if f.Bits[bit/8]&(1<<(bit%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
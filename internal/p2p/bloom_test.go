@@ -0,0 +1,60 @@
+package p2p
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"alxnet/internal/core"
+)
+
+func TestCIDFilter(t *testing.T) {
+	for _, count := range []int{0, 10, 1000, 20000} {
+		t.Run(fmt.Sprint(count), func(t *testing.T) {
+			f := newCIDFilter(count)
+			if err := f.check(); err != nil {
+				t.Fatalf("check: %v", err)
+			}
+			for i := 0; i < count; i++ {
+				f.add(core.CIDForBytes([]byte(fmt.Sprint("held", i))))
+			}
+			for i := 0; i < count; i++ {
+				if cid := core.CIDForBytes([]byte(fmt.Sprint("held", i))); !f.has(cid) {
+					t.Fatalf("has(%s) = false for an added CID", cid)
+				}
+			}
+			const probes = 10000
+			hits := 0
+			for i := 0; i < probes; i++ {
+				if f.has(core.CIDForBytes([]byte(fmt.Sprint("missing", i)))) {
+					hits++
+				}
+			}
+			if rate := float64(hits) / probes; rate > 3*bloomFalsePositive {
+				t.Errorf("false positive rate %.3f, want about %.2f", rate, bloomFalsePositive)
+			}
+		})
+	}
+}
+
+func TestCIDFilterCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		f       cidFilter
+		wantErr string
+	}{
+		{"valid", cidFilter{Bits: make([]byte, 64), K: 7}, ""},
+		{"empty", cidFilter{K: 7}, "0 bytes"},
+		{"oversized", cidFilter{Bits: make([]byte, MaxBloomBytes+1), K: 7}, "bytes"},
+		{"no hashes", cidFilter{Bits: make([]byte, 64)}, "0 hashes"},
+		{"too many hashes", cidFilter{Bits: make([]byte, 64), K: maxBloomHashes + 1}, "hashes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.f.check()
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("check = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"alxnet/internal/core"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// ErrRecordNotFound is returned when a peer has no manifest or file record
+// for a site.
+var ErrRecordNotFound = errors.New("record not found")
+
+// browseRespRecord answers get_manifest and get_file_record with the
+// signed encoding the node stores and its CID.
+type browseRespRecord struct {
+	Ok   bool   `cbor:"ok"`
+	CID  string `cbor:"c,omitempty"`
+	Data []byte `cbor:"d,omitempty"`
+}
+
+// serveSiteRecord answers a get_manifest or get_file_record request.
+func (n *Node) serveSiteRecord(s network.Stream, from peer.ID, req browseReq) {
+	var resp browseRespRecord
+	cid, data, err := n.siteRecord(req)
+	switch {
+	case err == nil:
+		resp = browseRespRecord{Ok: true, CID: cid, Data: data}
+	case errors.Is(err, ErrRecordNotFound):
+	default:
+		n.audit(AuditEntry{Kind: AuditBrowse, Peer: from.String(), SiteID: req.SiteID, Reason: err.Error()})
+	}
+	b, _ := cborMarshal(resp)
+	if _, err := s.Write(b); err != nil {
+		log.Printf("handleBrowseStream: write failed: %v", err)
+	}
+}
+
+// siteRecord looks up the current manifest of req.SiteID, or for
+// get_file_record the file record of req.Path. Only file records matching
+// the current manifest are given out, so files the owner has saved but not
+// published stay private.
+func (n *Node) siteRecord(req browseReq) (string, []byte, error) {
+	if !isHexID(req.SiteID) {
+		return "", nil, errors.New("malformed site ID")
+	}
+	if req.Type == "get_file_record" {
+		if err := core.ValidateFilePath(req.Path); err != nil {
+			return "", nil, fmt.Errorf("invalid file path: %w", err)
+		}
+	}
+	if n.Store.IsDenied(req.SiteID) {
+		return "", nil, ErrDenied
+	}
+	if !n.Store.HasWebsiteManifest(req.SiteID) {
+		return "", nil, ErrRecordNotFound
+	}
+	manifestData, err := n.Store.GetCurrentWebsiteManifest(req.SiteID)
+	if err != nil {
+		return "", nil, ErrRecordNotFound
+	}
+	if req.Type == "get_manifest" {
+		return core.CIDForBytes(manifestData), manifestData, nil
+	}
+
+	var m core.WebsiteManifest
+	if err := core.UnmarshalCBOR(manifestData, &m); err != nil {
+		return "", nil, ErrRecordNotFound
+	}
+	contentCID, ok := m.Files[req.Path]
+	if !ok {
+		return "", nil, ErrRecordNotFound
+	}
+	data, err := n.Store.GetFileRecordByPath(req.SiteID, req.Path)
+	if err != nil {
+		return "", nil, ErrRecordNotFound
+	}
+	var rec core.FileRecord
+	if err := core.UnmarshalCBOR(data, &rec); err != nil || rec.ContentCID != contentCID {
+		return "", nil, ErrRecordNotFound
+	}
+	return core.CIDForBytes(data), data, nil
+}
+
+// requestSiteRecord sends req to p and returns the record it answers with,
+// checked against the CID it was sent with.
+func (n *Node) requestSiteRecord(ctx context.Context, p peer.AddrInfo, req browseReq) ([]byte, error) {
+	respBytes, err := n.browseRequest(ctx, p, req)
+	if err != nil {
+		return nil, err
+	}
+	var resp browseRespRecord
+	if err := core.UnmarshalCBOR(respBytes, &resp); err != nil {
+		return nil, err
+	}
+	if !resp.Ok {
+		return nil, ErrRecordNotFound
+	}
+	if core.CIDForBytes(resp.Data) != resp.CID {
+		return nil, fmt.Errorf("peer %s sent a record not matching its CID", Short(p.ID.String()))
+	}
+	return resp.Data, nil
+}
+
+// browseRequest sends req to p and returns the raw answer.
+func (n *Node) browseRequest(ctx context.Context, p peer.AddrInfo, req browseReq) ([]byte, error) {
+	if err := n.Host.Connect(ctx, p); err != nil {
+		return nil, err
+	}
+	s, err := n.Host.NewStream(ctx, p.ID, BrowseProto)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+	if err := s.SetWriteDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		return nil, err
+	}
+	if err := s.SetReadDeadline(time.Now().Add(10 * time.Second)); err != nil {
+		return nil, err
+	}
+	b, _ := cborMarshal(req)
+	if _, err := s.Write(b); err != nil {
+		return nil, err
+	}
+	if closer, ok := s.(interface{ CloseWrite() error }); ok {
+		if err := closer.CloseWrite(); err != nil {
+			log.Printf("browseRequest: failed to close write side: %v", err)
+		}
+	}
+
+	respBytes := readAllWithTimeout(s, 5*time.Second)
+	if len(respBytes) == 0 {
+		return nil, errors.New("no response data")
+	}
+	return respBytes, nil
+}
+
+// RequestManifest asks p for the current website manifest of siteID and
+// verifies that the site's key signed it. It returns ErrRecordNotFound when
+// p has none, for example for single-file sites.
+func (n *Node) RequestManifest(ctx context.Context, p peer.AddrInfo, siteID string) (*core.WebsiteManifest, error) {
+	data, err := n.requestSiteRecord(ctx, p, browseReq{Type: "get_manifest", SiteID: siteID})
+	if err != nil {
+		return nil, err
+	}
+	var m core.WebsiteManifest
+	if err := core.UnmarshalCBOR(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	if err := VerifySiteManifest(&m, siteID); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// RequestFileRecord asks p for the file record of path in siteID's current
+// manifest and verifies that the site's key signed it for that path.
+func (n *Node) RequestFileRecord(ctx context.Context, p peer.AddrInfo, siteID, path string) (*core.FileRecord, error) {
+	data, err := n.requestSiteRecord(ctx, p, browseReq{Type: "get_file_record", SiteID: siteID, Path: path})
+	if err != nil {
+		return nil, err
+	}
+	var rec core.FileRecord
+	if err := core.UnmarshalCBOR(data, &rec); err != nil {
+		return nil, fmt.Errorf("invalid file record: %w", err)
+	}
+	if err := VerifySiteFileRecord(&rec, siteID, path); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
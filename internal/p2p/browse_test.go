@@ -0,0 +1,104 @@
+package p2p
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"alxnet/internal/core"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// putSignedFile stores a file and its signed file record on n.
+func putSignedFile(t *testing.T, n *Node, priv ed25519.PrivateKey, path string, data []byte) string {
+	t.Helper()
+	siteID := core.SiteIDFromPub(priv.Public().(ed25519.PublicKey))
+	cid := core.CIDForContent(data)
+	if err := n.Store.PutContent(cid, data); err != nil {
+		t.Fatalf("PutContent: %v", err)
+	}
+	rec, err := BuildFileRecord(priv, path, cid, "text/html")
+	if err != nil {
+		t.Fatalf("BuildFileRecord: %v", err)
+	}
+	recData, err := core.CanonicalMarshalFileRecord(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Store.PutFileRecord(siteID, path, core.CIDForBytes(recData), recData); err != nil {
+		t.Fatalf("PutFileRecord: %v", err)
+	}
+	return cid
+}
+
+func TestBrowseSiteRecords(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	seeder, client := newTestNode(t, ctx), newTestNode(t, ctx)
+	info := peer.AddrInfo{ID: seeder.Host.ID(), Addrs: seeder.Host.Addrs()}
+
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	siteID := core.SiteIDFromPub(priv.Public().(ed25519.PublicKey))
+	files := map[string]string{
+		"index.html": putSignedFile(t, seeder, priv, "index.html", []byte("<h1>published</h1>")),
+		"about.html": putSignedFile(t, seeder, priv, "about.html", []byte("<h1>about</h1>")),
+	}
+	m, err := BuildWebsiteManifest(priv, 1, "", "index.html", files, false, nil)
+	if err != nil {
+		t.Fatalf("BuildWebsiteManifest: %v", err)
+	}
+	mData, err := core.CanonicalMarshalWebsiteManifest(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := seeder.Store.PutWebsiteManifest(siteID, core.CIDForBytes(mData), mData); err != nil {
+		t.Fatalf("PutWebsiteManifest: %v", err)
+	}
+	// Saved after publishing, so not part of the site yet
+	putSignedFile(t, seeder, priv, "about.html", []byte("<h1>draft</h1>"))
+	putSignedFile(t, seeder, priv, "new.html", []byte("<h1>new</h1>"))
+
+	got, err := client.RequestManifest(ctx, info, siteID)
+	if err != nil {
+		t.Fatalf("RequestManifest: %v", err)
+	}
+	if got.MainFile != "index.html" || got.Files["about.html"] != files["about.html"] {
+		t.Errorf("manifest = %+v", got)
+	}
+	rec, err := client.RequestFileRecord(ctx, info, siteID, "index.html")
+	if err != nil {
+		t.Fatalf("RequestFileRecord: %v", err)
+	}
+	if rec.ContentCID != files["index.html"] {
+		t.Errorf("file record content = %s, want %s", rec.ContentCID, files["index.html"])
+	}
+
+	const otherSite = "bb00000000000000000000000000000000000000000000000000000000000000"
+	tests := []struct {
+		name       string
+		site, path string
+		audited    bool
+	}{
+		{"draft of a published file", siteID, "about.html", false},
+		{"unpublished file", siteID, "new.html", false},
+		{"unknown site", otherSite, "index.html", false},
+		{"path outside the site", siteID, "../index.html", true},
+		{"malformed site ID", "not-a-site", "index.html", true},
+	}
+	for _, tt := range tests {
+		if _, err := client.RequestFileRecord(ctx, info, tt.site, tt.path); !errors.Is(err, ErrRecordNotFound) {
+			t.Errorf("%s: RequestFileRecord() = %v, want ErrRecordNotFound", tt.name, err)
+		}
+		audited := len(seeder.Audit(AuditFilter{Kind: AuditBrowse, SiteID: tt.site})) > 0
+		if audited != tt.audited {
+			t.Errorf("%s: audited = %v, want %v", tt.name, audited, tt.audited)
+		}
+	}
+	if _, err := client.RequestManifest(ctx, info, otherSite); !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("RequestManifest(unknown site) = %v, want ErrRecordNotFound", err)
+	}
+}
@@ -0,0 +1,222 @@
+package p2p
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"log"
+	"time"
+
+	"alxnet/internal/core"
+	bncrypto "alxnet/internal/crypto"
+)
+
+// GossipComment carries a visitor's comment on a site.
+type GossipComment struct {
+	Comment []byte // canonical CBOR of CommentRecord
+}
+
+// GossipModeration carries a site owner's moderation of a comment.
+type GossipModeration struct {
+	Moderation []byte // canonical CBOR of ModerationRecord
+}
+
+func init() {
+	RegisterRecordType(envelopedRecord("comment", AuditComment,
+		func(env *GossipComment) []byte { return env.Comment },
+		func(rec *core.CommentRecord, e *AuditEntry) { e.SiteID = rec.SiteID },
+		verifyComment, (*Node).handleComment))
+	RegisterRecordType(envelopedRecord("moderation", AuditModeration,
+		func(env *GossipModeration) []byte { return env.Moderation },
+		func(rec *core.ModerationRecord, e *AuditEntry) { e.SiteID = core.SiteIDFromPub(rec.SitePub) },
+		verifyModeration, (*Node).handleModeration))
+}
+
+// ErrSiteNotStored is returned for comments on sites this node does not
+// store; nodes only keep the comments of sites they serve.
+var ErrSiteNotStored = errors.New("site not stored on this node")
+
+// BuildComment signs a comment on siteID with a visitor's key. replyTo is
+// the CID of the comment answered, or empty.
+func BuildComment(authorPriv ed25519.PrivateKey, siteID, replyTo, body string) (*core.CommentRecord, error) {
+	rec := &core.CommentRecord{
+		Version:   "v1",
+		SiteID:    siteID,
+		AuthorPub: authorPriv.Public().(ed25519.PublicKey),
+		ReplyTo:   replyTo,
+		Body:      body,
+		TS:        time.Now().Unix(),
+	}
+	b, err := core.CanonicalMarshalCommentNoSig(rec)
+	if err != nil {
+		return nil, err
+	}
+	rec.Sig = ed25519.Sign(authorPriv, bncrypto.PreimageComment(b))
+	return rec, rec.Validate()
+}
+
+// BuildModeration signs moderation flags for a comment with the site key.
+func BuildModeration(sitePriv ed25519.PrivateKey, commentCID string, hidden bool) (*core.ModerationRecord, error) {
+	rec := &core.ModerationRecord{
+		Version:    "v1",
+		SitePub:    sitePriv.Public().(ed25519.PublicKey),
+		CommentCID: commentCID,
+		Hidden:     hidden,
+		TS:         time.Now().Unix(),
+	}
+	b, err := core.CanonicalMarshalModerationNoSig(rec)
+	if err != nil {
+		return nil, err
+	}
+	rec.Sig = ed25519.Sign(sitePriv, bncrypto.PreimageModeration(b))
+	return rec, rec.Validate()
+}
+
+// verifyComment checks structure and signature of a comment.
+func verifyComment(rec *core.CommentRecord) error {
+	if err := rec.Validate(); err != nil {
+		return err
+	}
+	b, err := core.CanonicalMarshalCommentNoSig(rec)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(ed25519.PublicKey(rec.AuthorPub), bncrypto.PreimageComment(b), rec.Sig) {
+		return errors.New("invalid comment signature")
+	}
+	return nil
+}
+
+// verifyModeration checks structure and signature of a moderation record.
+func verifyModeration(rec *core.ModerationRecord) error {
+	if err := rec.Validate(); err != nil {
+		return err
+	}
+	b, err := core.CanonicalMarshalModerationNoSig(rec)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(ed25519.PublicKey(rec.SitePub), bncrypto.PreimageModeration(b), rec.Sig) {
+		return errors.New("invalid moderation signature")
+	}
+	return nil
+}
+
+func (n *Node) storesSite(siteID string) bool {
+	if n.Store.HasWebsiteManifest(siteID) {
+		return true
+	}
+	has, _ := n.Store.HasHead(siteID)
+	return has
+}
+
+// ApplyComment verifies and stores a comment on a site this node stores,
+// returning its CID.
+func (n *Node) ApplyComment(rec *core.CommentRecord) (string, error) {
+	if err := verifyComment(rec); err != nil {
+		return "", err
+	}
+	if !n.storesSite(rec.SiteID) {
+		return "", ErrSiteNotStored
+	}
+	if rec.ReplyTo != "" {
+		parent, err := n.Store.GetComment(rec.ReplyTo)
+		if err != nil {
+			return "", err
+		}
+		if parent != nil && parent.SiteID != rec.SiteID {
+			return "", errors.New("reply to a comment on another site")
+		}
+	}
+	b, err := core.MarshalCanonical(rec)
+	if err != nil {
+		return "", err
+	}
+	cid := core.CIDForBytes(b)
+	if _, err := n.Store.PutComment(cid, rec, b); err != nil {
+		return "", err
+	}
+	return cid, nil
+}
+
+// ApplyModeration verifies a moderation record against the owner of the
+// comment's site and stores it.
+func (n *Node) ApplyModeration(rec *core.ModerationRecord) error {
+	if err := verifyModeration(rec); err != nil {
+		return err
+	}
+	if !n.storesSite(core.SiteIDFromPub(rec.SitePub)) {
+		return ErrSiteNotStored
+	}
+	comment, err := n.Store.GetComment(rec.CommentCID)
+	if err != nil {
+		return err
+	}
+	if comment == nil {
+		return errors.New("unknown comment")
+	}
+	if comment.SiteID != core.SiteIDFromPub(rec.SitePub) {
+		return errors.New("moderation not signed by the site owner")
+	}
+	b, err := core.MarshalCanonical(rec)
+	if err != nil {
+		return err
+	}
+	_, err = n.Store.PutModeration(rec, b)
+	return err
+}
+
+// PublishComment stores a comment locally, if this node stores the site,
+// and gossips it. It returns the comment's CID.
+func (n *Node) PublishComment(ctx context.Context, rec *core.CommentRecord) (string, error) {
+	cid, err := n.ApplyComment(rec)
+	if errors.Is(err, ErrSiteNotStored) {
+		b, merr := core.MarshalCanonical(rec)
+		if merr != nil {
+			return "", merr
+		}
+		cid, err = core.CIDForBytes(b), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	b, err := cborMarshal(GossipComment{Comment: mustMarshal(rec)})
+	if err != nil {
+		return "", err
+	}
+	return cid, n.Topic.Publish(ctx, b)
+}
+
+// PublishModeration applies a moderation record locally and gossips it.
+func (n *Node) PublishModeration(ctx context.Context, rec *core.ModerationRecord) error {
+	if err := n.ApplyModeration(rec); err != nil && !errors.Is(err, ErrSiteNotStored) {
+		return err
+	}
+	b, err := cborMarshal(GossipModeration{Moderation: mustMarshal(rec)})
+	if err != nil {
+		return err
+	}
+	return n.Topic.Publish(ctx, b)
+}
+
+func (n *Node) handleComment(rec *core.CommentRecord) error {
+	_, err := n.ApplyComment(rec)
+	if errors.Is(err, ErrSiteNotStored) {
+		return nil
+	}
+	if err != nil {
+		log.Printf("reject comment: %v", err)
+	}
+	return err
+}
+
+func (n *Node) handleModeration(rec *core.ModerationRecord) error {
+	err := n.ApplyModeration(rec)
+	if errors.Is(err, ErrSiteNotStored) {
+		return nil
+	}
+	if err != nil {
+		log.Printf("reject moderation: %v", err)
+	}
+	return err
+}
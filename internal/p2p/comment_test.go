@@ -0,0 +1,125 @@
+package p2p
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"alxnet/internal/core"
+	bncrypto "alxnet/internal/crypto"
+)
+
+func TestCommentsAndModeration(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	n := newTestNode(t, ctx)
+	sitePub, sitePriv, _ := ed25519.GenerateKey(rand.Reader)
+	_, visitorPriv, _ := ed25519.GenerateKey(rand.Reader)
+	_, otherSitePriv, _ := ed25519.GenerateKey(rand.Reader)
+	siteID := core.SiteIDFromPub(sitePub)
+
+	comment, err := BuildComment(visitorPriv, siteID, "", "Nice site!")
+	if err != nil {
+		t.Fatalf("BuildComment: %v", err)
+	}
+	if _, err := n.ApplyComment(comment); !errors.Is(err, ErrSiteNotStored) {
+		t.Fatalf("comment on an unknown site: err = %v, want ErrSiteNotStored", err)
+	}
+
+	env, _, err := n.BuildUpdate(sitePriv, sitePub, []byte("<h1>guestbook</h1>"), 1, "")
+	if err != nil {
+		t.Fatalf("BuildUpdate: %v", err)
+	}
+	var rec core.UpdateRecord
+	if err := cborUnmarshal(env.Record, &rec); err != nil {
+		t.Fatalf("unmarshal record: %v", err)
+	}
+	if err := n.ValidateAndApply(&rec, nil); err != nil {
+		t.Fatalf("ValidateAndApply: %v", err)
+	}
+
+	cid, err := n.ApplyComment(comment)
+	if err != nil {
+		t.Fatalf("ApplyComment: %v", err)
+	}
+
+	tampered := *comment
+	tampered.Body = "Buy cheap stuff"
+	if _, err := n.ApplyComment(&tampered); err == nil {
+		t.Error("comment with a body not matching its signature was accepted")
+	}
+
+	// Only the site owner can moderate
+	forged, err := BuildModeration(otherSitePriv, cid, true)
+	if err != nil {
+		t.Fatalf("BuildModeration: %v", err)
+	}
+	if err := n.ApplyModeration(forged); err == nil {
+		t.Error("moderation by another site's key was accepted")
+	}
+
+	hide, err := BuildModeration(sitePriv, cid, true)
+	if err != nil {
+		t.Fatalf("BuildModeration: %v", err)
+	}
+	if err := n.ApplyModeration(hide); err != nil {
+		t.Fatalf("ApplyModeration: %v", err)
+	}
+	visible, _ := n.Store.ListComments(siteID, 10, false)
+	all, _ := n.Store.ListComments(siteID, 10, true)
+	if len(visible) != 0 || len(all) != 1 || !all[0].Hidden || all[0].CID != cid {
+		t.Errorf("after hiding: visible %d, all %+v", len(visible), all)
+	}
+
+	// An older moderation record does not undo a newer one
+	stale := *hide
+	stale.Hidden, stale.TS = false, hide.TS-10
+	b, _ := core.CanonicalMarshalModerationNoSig(&stale)
+	stale.Sig = ed25519.Sign(sitePriv, bncrypto.PreimageModeration(b))
+	if err := n.ApplyModeration(&stale); err != nil {
+		t.Fatalf("ApplyModeration(stale): %v", err)
+	}
+	if all, _ := n.Store.ListComments(siteID, 10, true); len(all) != 1 || !all[0].Hidden {
+		t.Error("stale moderation record was applied")
+	}
+
+	reply, err := BuildComment(visitorPriv, siteID, cid, "Thanks!")
+	if err != nil {
+		t.Fatalf("BuildComment(reply): %v", err)
+	}
+	if _, err := n.ApplyComment(reply); err != nil {
+		t.Errorf("ApplyComment(reply): %v", err)
+	}
+}
+
+func TestCommentValidate(t *testing.T) {
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	const siteID = "aa00000000000000000000000000000000000000000000000000000000000000"
+
+	tests := []struct {
+		name    string
+		siteID  string
+		replyTo string
+		body    string
+	}{
+		{"bad site ID", "not-a-site", "", "hi"},
+		{"empty body", siteID, "", "   "},
+		{"body too long", siteID, "", string(make([]byte, core.MaxCommentLength+1))},
+		{"invalid UTF-8", siteID, "", "\xff\xfe"},
+		{"bad reply CID", siteID, "xyz", "hi"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := BuildComment(priv, tt.siteID, tt.replyTo, tt.body); err == nil {
+				t.Error("BuildComment() succeeded")
+			}
+		})
+	}
+	if _, err := BuildModeration(priv, "short", true); err == nil {
+		t.Error("BuildModeration() accepted an invalid comment CID")
+	}
+}
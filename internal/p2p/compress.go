@@ -0,0 +1,59 @@
+package p2p
+
+import (
+	"errors"
+	"fmt"
+
+	"alxnet/internal/core"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// GossipEncodingZstd marks GossipUpdate.Content as zstd-compressed.
+const GossipEncodingZstd = "zstd"
+
+// DefaultGossipCompressThreshold is the content size above which updates
+// are compressed when compression is enabled.
+const DefaultGossipCompressThreshold = 4 * 1024
+
+var (
+	gossipEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+	gossipDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderConcurrency(0), zstd.WithDecoderMaxMemory(core.MaxContentSize))
+)
+
+// compressGossip compresses the inline content of env in place when it is
+// at least threshold bytes and compression actually saves space.
+func compressGossip(env *GossipUpdate, threshold int) {
+	if threshold <= 0 || env.Encoding != "" || len(env.Content) < threshold {
+		return
+	}
+	c := gossipEncoder.EncodeAll(env.Content, make([]byte, 0, len(env.Content)/2))
+	if len(c) >= len(env.Content) {
+		return
+	}
+	env.Content = c
+	env.Encoding = GossipEncodingZstd
+}
+
+// decompressGossip returns the plain inline content of env. Decompressed
+// content is bounded by core.MaxContentSize.
+func decompressGossip(env GossipUpdate) ([]byte, error) {
+	switch env.Encoding {
+	case "":
+		return env.Content, nil
+	case GossipEncodingZstd:
+		if len(env.Content) == 0 {
+			return nil, errors.New("empty compressed content")
+		}
+		out, err := gossipDecoder.DecodeAll(env.Content, nil)
+		if err != nil {
+			return nil, fmt.Errorf("decompress content: %w", err)
+		}
+		if len(out) > core.MaxContentSize {
+			return nil, errors.New("decompressed content too large")
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("unknown content encoding %q", env.Encoding)
+	}
+}
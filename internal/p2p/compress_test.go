@@ -0,0 +1,72 @@
+package p2p
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"alxnet/internal/core"
+)
+
+func TestGossipCompression(t *testing.T) {
+	text := []byte(strings.Repeat("<p>hello alxnet</p>\n", 500))
+
+	tests := []struct {
+		name      string
+		content   []byte
+		threshold int
+		wantEnc   string
+	}{
+		{"disabled", text, 0, ""},
+		{"below threshold", text, len(text) + 1, ""},
+		{"compressed", text, 1024, GossipEncodingZstd},
+		{"no content", nil, 1, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := GossipUpdate{Record: []byte{1}, Content: append([]byte{}, tt.content...)}
+			compressGossip(&env, tt.threshold)
+			if env.Encoding != tt.wantEnc {
+				t.Fatalf("Encoding = %q, want %q", env.Encoding, tt.wantEnc)
+			}
+			if tt.wantEnc != "" && len(env.Content) >= len(tt.content) {
+				t.Errorf("compressed size %d not below %d", len(env.Content), len(tt.content))
+			}
+
+			// Round trip through the wire encoding
+			b, err := cborMarshal(env)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got GossipUpdate
+			if err := cborUnmarshal(b, &got); err != nil {
+				t.Fatal(err)
+			}
+			content, err := decompressGossip(got)
+			if err != nil || !bytes.Equal(content, tt.content) {
+				t.Errorf("decompressGossip() = %d bytes, %v; want original", len(content), err)
+			}
+		})
+	}
+}
+
+func TestDecompressGossipRejects(t *testing.T) {
+	bomb := gossipEncoder.EncodeAll(make([]byte, core.MaxContentSize+1), nil)
+
+	tests := []struct {
+		name string
+		env  GossipUpdate
+	}{
+		{"unknown encoding", GossipUpdate{Content: []byte("x"), Encoding: "gzip"}},
+		{"corrupt stream", GossipUpdate{Content: []byte("not zstd"), Encoding: GossipEncodingZstd}},
+		{"empty stream", GossipUpdate{Encoding: GossipEncodingZstd}},
+		{"oversized content", GossipUpdate{Content: bomb, Encoding: GossipEncodingZstd}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decompressGossip(tt.env); err == nil {
+				t.Error("decompressGossip() accepted bad input")
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package p2p
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"alxnet/internal/core"
+)
+
+func TestApplyDeleteOwnership(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	n := newTestNode(t, ctx)
+
+	type site struct {
+		priv  ed25519.PrivateKey
+		id    string
+		heads []string
+	}
+	newSite := func() *site {
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatalf("GenerateKey: %v", err)
+		}
+		return &site{priv: priv, id: core.SiteIDFromPub(pub)}
+	}
+	publish := func(s *site, content []byte) {
+		t.Helper()
+		prev := ""
+		if len(s.heads) > 0 {
+			prev = s.heads[len(s.heads)-1]
+		}
+		env, recCID, err := SignUpdate(s.priv, content, uint64(len(s.heads)+1), prev)
+		if err != nil {
+			t.Fatalf("SignUpdate: %v", err)
+		}
+		var rec core.UpdateRecord
+		if err := cborUnmarshal(env.Record, &rec); err != nil {
+			t.Fatal(err)
+		}
+		if err := n.ValidateAndApply(&rec, content); err != nil {
+			t.Fatalf("ValidateAndApply: %v", err)
+		}
+		s.heads = append(s.heads, recCID)
+	}
+	deleteAs := func(s *site, targetRec, targetCont string) error {
+		del, err := BuildDelete(s.priv, targetRec, targetCont)
+		if err != nil {
+			t.Fatalf("BuildDelete: %v", err)
+		}
+		return n.ApplyDelete(*del)
+	}
+	has := func(cid string) bool {
+		ok, _ := n.Store.HasContent(cid)
+		return ok
+	}
+
+	a, b, stranger := newSite(), newSite(), newSite()
+	shared, own := []byte("<p>shared</p>"), []byte("<p>b only</p>")
+	publish(a, shared)
+	publish(b, own)
+	publish(b, shared)
+	sharedCID, ownCID := core.CIDForContent(shared), core.CIDForContent(own)
+
+	tests := []struct {
+		name       string
+		signer     *site
+		rec, cont  string
+		wantErr    error
+		sharedHeld bool
+		ownHeld    bool
+	}{
+		{"another site's record", b, a.heads[0], "", ErrNotSiteOwner, true, true},
+		{"another site's record by prefix", b, a.heads[0][:12], "", ErrNotSiteOwner, true, true},
+		{"content of no site of the signer", stranger, "", sharedCID, ErrNotSiteOwner, true, true},
+		{"not held here", stranger, core.CIDForContent([]byte("x")), core.CIDForContent([]byte("y")), nil, true, true},
+		{"own record and content another site shares", b, b.heads[1], sharedCID, nil, true, true},
+		{"own content", b, "", ownCID, nil, true, false},
+	}
+	for _, tt := range tests {
+		err := deleteAs(tt.signer, tt.rec, tt.cont)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: ApplyDelete() = %v, want %v", tt.name, err, tt.wantErr)
+		}
+		if _, head, _ := n.Store.GetHead(a.id); head != a.heads[0] {
+			t.Errorf("%s: site A head = %s, want %s", tt.name, head, a.heads[0])
+		}
+		if has(sharedCID) != tt.sharedHeld || has(ownCID) != tt.ownHeld {
+			t.Errorf("%s: shared held %v, own held %v; want %v, %v", tt.name, has(sharedCID), has(ownCID), tt.sharedHeld, tt.ownHeld)
+		}
+	}
+	if seq, head, _ := n.Store.GetHead(b.id); seq != 1 || head != b.heads[0] {
+		t.Errorf("site B head = %d %s, want 1 %s after deleting its second update", seq, head, b.heads[0])
+	}
+}
@@ -0,0 +1,254 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	network "github.com/libp2p/go-libp2p/core/network"
+	peer "github.com/libp2p/go-libp2p/core/peer"
+	protocol "github.com/libp2p/go-libp2p/core/protocol"
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// DialbackProto asks a peer to open a TCP connection back to the
+// requester's port, showing whether the node is reachable from outside.
+// The peer only dials the IP address the request arrived from, so it
+// cannot be used to probe other hosts.
+const DialbackProto protocol.ID = "/alxnet/dialback/1.0.0"
+
+// DialbackTimeout bounds a dial-back round trip, including the dial.
+const DialbackTimeout = 10 * time.Second
+
+// dialbackDialTimeout bounds the peer's dial to the requester.
+const dialbackDialTimeout = 5 * time.Second
+
+// maxDialbackMessage caps dial-back messages, which carry a port, a flag
+// and a short error.
+const maxDialbackMessage = 1024
+
+var dialbackDecMode, _ = cbor.DecOptions{MaxMapPairs: 16}.DecMode()
+
+type dialbackReq struct {
+	Port int `cbor:"p"`
+}
+
+type dialbackResp struct {
+	Reachable bool   `cbor:"r"`
+	Error     string `cbor:"e,omitempty"`
+	Time      int64  `cbor:"t"` // peer's clock, Unix milliseconds
+}
+
+// DialbackResult is one peer's view of this node's reachability.
+type DialbackResult struct {
+	Peer        string        `json:"peer"`
+	Reachable   bool          `json:"reachable"`
+	Error       string        `json:"error,omitempty"`
+	ClockOffset time.Duration `json:"clock_offset"` // peer clock minus ours
+	RTT         time.Duration `json:"rtt"`
+}
+
+// CheckReachability asks up to samplePeers random connected peers to dial
+// port on this node. Peers that fail to answer are reported with an error.
+func (n *Node) CheckReachability(ctx context.Context, port, samplePeers int) ([]DialbackResult, error) {
+	if port <= 0 || port > 65535 {
+		return nil, errors.New("port must be between 1 and 65535")
+	}
+	if samplePeers <= 0 {
+		return nil, errors.New("sample size must be positive")
+	}
+	peers := n.Host.Network().Peers()
+	rand.Shuffle(len(peers), func(i, j int) { peers[i], peers[j] = peers[j], peers[i] })
+	if len(peers) > samplePeers {
+		peers = peers[:samplePeers]
+	}
+
+	results := make([]DialbackResult, len(peers))
+	var wg sync.WaitGroup
+	for i, p := range peers {
+		wg.Add(1)
+		go func(i int, p peer.ID) {
+			defer wg.Done()
+			results[i] = n.queryDialback(ctx, p, port)
+		}(i, p)
+	}
+	wg.Wait()
+	return results, nil
+}
+
+func (n *Node) queryDialback(ctx context.Context, p peer.ID, port int) DialbackResult {
+	res := DialbackResult{Peer: p.String()}
+	ctx, cancel := context.WithTimeout(ctx, DialbackTimeout)
+	defer cancel()
+
+	start := time.Now()
+	resp, err := func() (*dialbackResp, error) {
+		s, err := n.Host.NewStream(ctx, p, DialbackProto)
+		if err != nil {
+			return nil, err
+		}
+		defer s.Close()
+		if err := s.SetDeadline(time.Now().Add(DialbackTimeout)); err != nil {
+			return nil, err
+		}
+		b, err := cborMarshal(dialbackReq{Port: port})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := s.Write(b); err != nil {
+			return nil, err
+		}
+		if err := s.CloseWrite(); err != nil {
+			return nil, err
+		}
+		var resp dialbackResp
+		if err := dialbackDecMode.NewDecoder(io.LimitReader(s, maxDialbackMessage)).Decode(&resp); err != nil {
+			return nil, err
+		}
+		return &resp, nil
+	}()
+	end := time.Now()
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	res.Reachable, res.Error, res.RTT = resp.Reachable, resp.Error, end.Sub(start)
+	if resp.Time > 0 {
+		mid := start.Add(res.RTT / 2)
+		res.ClockOffset = time.UnixMilli(resp.Time).Sub(mid).Round(time.Millisecond)
+	}
+	return res
+}
+
+func (n *Node) handleDialbackStream(s network.Stream) {
+	defer s.Close()
+	from := s.Conn().RemotePeer()
+	if err := s.SetDeadline(time.Now().Add(DialbackTimeout)); err != nil {
+		return
+	}
+	if !n.rateLimiter.allow(from.String(), time.Now()) {
+		n.audit(AuditEntry{Kind: AuditBrowse, Peer: from.String(), Reason: "dial-back over the rate limit"})
+		return
+	}
+
+	var req dialbackReq
+	if err := dialbackDecMode.NewDecoder(io.LimitReader(s, maxDialbackMessage)).Decode(&req); err != nil {
+		log.Printf("handleDialbackStream: bad request from %s: %v", from, err)
+		return
+	}
+
+	var resp dialbackResp
+	if req.Port <= 0 || req.Port > 65535 {
+		resp.Error = "invalid port"
+	} else if ip, err := remoteIP(s.Conn().RemoteMultiaddr()); err != nil {
+		resp.Error = err.Error()
+	} else {
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort(ip.String(), strconv.Itoa(req.Port)), dialbackDialTimeout)
+		if err != nil {
+			resp.Error = fmt.Sprintf("dial %s port %d: connection failed", ip, req.Port)
+		} else {
+			conn.Close()
+			resp.Reachable = true
+		}
+	}
+	resp.Time = time.Now().UnixMilli()
+	b, _ := cborMarshal(resp)
+	if _, err := s.Write(b); err != nil {
+		log.Printf("handleDialbackStream: write failed: %v", err)
+	}
+}
+
+// remoteIP is the IP address of a peer's connection.
+func remoteIP(addr ma.Multiaddr) (net.IP, error) {
+	ip, err := manet.ToIP(addr)
+	if err != nil {
+		return nil, errors.New("connection has no IP address")
+	}
+	return ip, nil
+}
+
+// NATStatus classifies the node's reachability from dial-back results:
+// "public", "firewalled" (has a public address but peers cannot dial it),
+// "behind-nat" (only private addresses) or "unknown" (no answers).
+func (n *Node) NATStatus(results []DialbackResult) string {
+	answered := false
+	for _, r := range results {
+		if r.Reachable {
+			return "public"
+		}
+		if r.Error != "" && r.RTT > 0 {
+			answered = true
+		}
+	}
+	if !answered {
+		return "unknown"
+	}
+	for _, a := range n.Host.Addrs() {
+		if manet.IsPublicAddr(a) {
+			return "firewalled"
+		}
+	}
+	return "behind-nat"
+}
+
+// ListenPort returns the TCP port the node listens on, or 0.
+func (n *Node) ListenPort() int {
+	for _, a := range n.Host.Network().ListenAddresses() {
+		if v, err := a.ValueForProtocol(ma.P_TCP); err == nil {
+			if port, err := strconv.Atoi(v); err == nil && port > 0 {
+				return port
+			}
+		}
+	}
+	return 0
+}
+
+// BootstrapResult reports whether a bootstrap peer could be reached.
+type BootstrapResult struct {
+	Addr      string        `json:"addr"`
+	Reachable bool          `json:"reachable"`
+	Error     string        `json:"error,omitempty"`
+	Took      time.Duration `json:"took"`
+}
+
+// CheckBootstrap dials every bootstrap peer, reusing existing connections.
+func (n *Node) CheckBootstrap(ctx context.Context) []BootstrapResult {
+	n.mu.RLock()
+	addrs := n.BootstrapAddrs
+	n.mu.RUnlock()
+
+	results := make([]BootstrapResult, len(addrs))
+	var wg sync.WaitGroup
+	for i, m := range addrs {
+		results[i].Addr = m.String()
+		ai, err := peer.AddrInfoFromP2pAddr(m)
+		if err != nil {
+			results[i].Error = "address has no /p2p peer ID"
+			continue
+		}
+		wg.Add(1)
+		go func(r *BootstrapResult, ai peer.AddrInfo) {
+			defer wg.Done()
+			cctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			defer cancel()
+			start := time.Now()
+			if err := n.Host.Connect(cctx, ai); err != nil {
+				r.Error = err.Error()
+			} else {
+				r.Reachable = true
+			}
+			r.Took = time.Since(start).Round(time.Millisecond)
+		}(&results[i], *ai)
+	}
+	wg.Wait()
+	return results
+}
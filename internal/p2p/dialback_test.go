@@ -0,0 +1,73 @@
+package p2p
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestCheckReachability(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	n, helper := newTestNode(t, ctx), newTestNode(t, ctx)
+
+	if _, err := n.CheckReachability(ctx, 70000, 1); err == nil {
+		t.Error("CheckReachability() accepted an invalid port")
+	}
+	if got, _ := n.CheckReachability(ctx, n.ListenPort(), 3); len(got) != 0 || n.NATStatus(got) != "unknown" {
+		t.Errorf("without peers: %+v", got)
+	}
+
+	if err := n.Host.Connect(ctx, peer.AddrInfo{ID: helper.Host.ID(), Addrs: helper.Host.Addrs()}); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	port := n.ListenPort()
+	if port == 0 {
+		t.Fatal("ListenPort() = 0")
+	}
+	got, err := n.CheckReachability(ctx, port, 3)
+	if err != nil || len(got) != 1 {
+		t.Fatalf("CheckReachability() = %+v, %v", got, err)
+	}
+	if !got[0].Reachable || got[0].Peer != helper.Host.ID().String() || got[0].ClockOffset.Abs() > time.Second {
+		t.Errorf("listening port: %+v", got[0])
+	}
+	if n.NATStatus(got) != "public" {
+		t.Errorf("NATStatus() = %s, want public", n.NATStatus(got))
+	}
+
+	// A port nothing listens on
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+	got, _ = n.CheckReachability(ctx, closed, 3)
+	if len(got) != 1 || got[0].Reachable || got[0].Error == "" {
+		t.Errorf("closed port: %+v", got)
+	}
+	if n.NATStatus(got) != "behind-nat" {
+		t.Errorf("NATStatus() with loopback addresses = %s, want behind-nat", n.NATStatus(got))
+	}
+}
+
+func TestCheckBootstrap(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	n, other := newTestNode(t, ctx), newTestNode(t, ctx)
+
+	good := other.Host.Addrs()[0].String() + "/p2p/" + other.Host.ID().String()
+	n.SetBootstrapPeers(ctx, []string{good, "/ip4/127.0.0.1/tcp/1"})
+	got := n.CheckBootstrap(ctx)
+	if len(got) != 2 {
+		t.Fatalf("CheckBootstrap() = %+v", got)
+	}
+	if !got[0].Reachable || got[1].Reachable || got[1].Error == "" {
+		t.Errorf("CheckBootstrap() = %+v", got)
+	}
+}
@@ -0,0 +1,518 @@
+package p2p
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"time"
+
+	"alxnet/internal/core"
+	"alxnet/internal/erasure"
+	"alxnet/internal/store"
+
+	"github.com/fxamacker/cbor/v2"
+	network "github.com/libp2p/go-libp2p/core/network"
+	peer "github.com/libp2p/go-libp2p/core/peer"
+	protocol "github.com/libp2p/go-libp2p/core/protocol"
+	"go.uber.org/zap"
+)
+
+// ShardProto stores and drops erasure shards on cooperating nodes. A node
+// pinning a site with erasure coding splits an export of the site into
+// Reed-Solomon shards and places each on a different peer, so that the site
+// can be rebuilt from any Data of them. Nodes only answer it when
+// NodeConfig.ShardQuota is set. Held shards are ordinary content, fetched
+// back over BrowseProto and checked over HaveProto.
+const ShardProto protocol.ID = "/alxnet/shard/1.0.0"
+
+// ShardTimeout bounds a shard transfer, which may carry up to
+// core.MaxContentSize bytes.
+const ShardTimeout = 60 * time.Second
+
+// Erasure set states
+const (
+	ErasureHealthy       = "healthy"       // every shard was found on its holder
+	ErasureDegraded      = "degraded"      // shards are missing but the site is recoverable
+	ErasureUnrecoverable = "unrecoverable" // fewer than Data shards are left
+)
+
+const (
+	maxShardMessage  = core.MaxContentSize + 1024
+	maxShardResponse = 1024
+)
+
+var shardDecMode, _ = cbor.DecOptions{MaxMapPairs: 16}.DecMode()
+
+type shardMsg struct {
+	Op     string `cbor:"op"`  // store or drop
+	SetID  string `cbor:"set"` // erasure.Layout CID
+	SiteID string `cbor:"s,omitempty"`
+	Index  int    `cbor:"i,omitempty"`
+	CID    string `cbor:"c,omitempty"`
+	Data   []byte `cbor:"d,omitempty"`
+}
+
+type shardResp struct {
+	Ok    bool   `cbor:"ok"`
+	Error string `cbor:"e,omitempty"`
+}
+
+// ErasureSet is a site this node pinned with erasure coding, and where its
+// shards are.
+type ErasureSet struct {
+	ID       string         `cbor:"id" json:"id"` // CID of the canonical layout
+	SiteID   string         `cbor:"site" json:"site_id"`
+	Version  string         `cbor:"v" json:"version"` // manifest or head record CID encoded
+	Layout   erasure.Layout `cbor:"l" json:"layout"`
+	Holders  []string       `cbor:"h" json:"holders"` // peer per shard, "" while a shard has no holder
+	Status   string         `cbor:"st" json:"status"`
+	Created  time.Time      `cbor:"c" json:"created"`
+	Checked  time.Time      `cbor:"ck,omitempty" json:"checked,omitempty"`
+	Repaired int            `cbor:"r" json:"repaired"` // shards regenerated since creation
+	Error    string         `cbor:"e,omitempty" json:"error,omitempty"`
+}
+
+// ShardQuota is how many bytes of shards this node holds for others at
+// most; zero when it holds none.
+func (n *Node) ShardQuota() int64 {
+	if n.config == nil {
+		return 0
+	}
+	return n.config.ShardQuota
+}
+
+// ShardPeers lists the connected peers that hold shards for others.
+func (n *Node) ShardPeers() []peer.ID {
+	var peers []peer.ID
+	for _, p := range n.Host.Network().Peers() {
+		if n.PeerSupports(p, FeatureShards) {
+			peers = append(peers, p)
+		}
+	}
+	return peers
+}
+
+// siteVersion identifies the stored version of siteID: its manifest CID,
+// or its head record CID for single-file sites.
+func (n *Node) siteVersion(siteID string) string {
+	if n.Store.HasWebsiteManifest(siteID) {
+		if data, err := n.Store.GetCurrentWebsiteManifest(siteID); err == nil {
+			return core.CIDForBytes(data)
+		}
+		return ""
+	}
+	if _, headCID, err := n.Store.GetHead(siteID); err == nil {
+		return headCID
+	}
+	return ""
+}
+
+// ErasurePin pins siteID and spreads it as data+parity shards over as many
+// connected peers advertising FeatureShards. It replaces a previous set for
+// the site, whose shards are dropped once the new ones are placed.
+func (n *Node) ErasurePin(ctx context.Context, siteID string, data, parity int) (*ErasureSet, error) {
+	version := n.siteVersion(siteID)
+	if version == "" {
+		return nil, errors.New("site is not stored on this node")
+	}
+	var buf bytes.Buffer
+	if _, err := n.Store.ExportSiteCAR(&buf, siteID); err != nil {
+		return nil, fmt.Errorf("export site: %w", err)
+	}
+	layout, shards, err := erasure.Split(buf.Bytes(), data, parity)
+	if err != nil {
+		return nil, err
+	}
+	lb, err := core.MarshalCanonical(layout)
+	if err != nil {
+		return nil, err
+	}
+	set := &ErasureSet{
+		ID:      core.CIDForBytes(lb),
+		SiteID:  siteID,
+		Version: version,
+		Layout:  *layout,
+		Holders: make([]string, len(shards)),
+		Created: time.Now().UTC(),
+	}
+
+	candidates := n.ShardPeers()
+	if len(candidates) < len(shards) {
+		return nil, fmt.Errorf("need %d peers holding shards, %d connected", len(shards), len(candidates))
+	}
+	for i := range shards {
+		for len(candidates) > 0 && set.Holders[i] == "" {
+			p := candidates[0]
+			candidates = candidates[1:]
+			if err := n.storeShard(ctx, p, set, i, shards[i]); err != nil {
+				n.logger.Debug("shard not placed", zap.String("peer", p.String()), zap.Error(err))
+				continue
+			}
+			set.Holders[i] = p.String()
+		}
+		if set.Holders[i] == "" {
+			n.dropShards(ctx, set.ID, set.Holders)
+			return nil, fmt.Errorf("no peer accepted shard %d", i)
+		}
+	}
+	set.Status = ErasureHealthy
+	set.Checked = set.Created
+
+	if err := n.Store.PinSite(siteID); err != nil {
+		return nil, err
+	}
+	if old, err := n.ErasureSet(siteID); err == nil && old.ID != set.ID {
+		n.dropShards(ctx, old.ID, old.Holders)
+	}
+	if err := n.putErasureSet(set); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// ErasureSet returns the erasure set of siteID.
+func (n *Node) ErasureSet(siteID string) (*ErasureSet, error) {
+	b, err := n.Store.GetErasureSet(siteID)
+	if err != nil {
+		return nil, err
+	}
+	var set ErasureSet
+	if err := core.UnmarshalCBOR(b, &set); err != nil {
+		return nil, err
+	}
+	return &set, nil
+}
+
+// ErasureSets lists the sites this node pinned with erasure coding.
+func (n *Node) ErasureSets() ([]*ErasureSet, error) {
+	blobs, err := n.Store.ListErasureSets()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*ErasureSet, 0, len(blobs))
+	for _, b := range blobs {
+		var set ErasureSet
+		if err := core.UnmarshalCBOR(b, &set); err != nil {
+			continue
+		}
+		out = append(out, &set)
+	}
+	slices.SortFunc(out, func(a, b *ErasureSet) int { return a.Created.Compare(b.Created) })
+	return out, nil
+}
+
+// RemoveErasureSet drops the shards of siteID from their holders and
+// forgets the set. The site stays pinned.
+func (n *Node) RemoveErasureSet(ctx context.Context, siteID string) error {
+	set, err := n.ErasureSet(siteID)
+	if err != nil {
+		return err
+	}
+	n.dropShards(ctx, set.ID, set.Holders)
+	return n.Store.DeleteErasureSet(siteID)
+}
+
+// RecoverErasureSet fetches shards of siteID from their holders and
+// rebuilds the CAR export they were split from.
+func (n *Node) RecoverErasureSet(ctx context.Context, siteID string) ([]byte, error) {
+	set, err := n.ErasureSet(siteID)
+	if err != nil {
+		return nil, err
+	}
+	shards := n.fetchShards(ctx, set, set.Layout.Data)
+	return erasure.Join(&set.Layout, shards)
+}
+
+// fetchShards fetches shards of set from their holders, at background
+// priority, until want of them match their CIDs. Shards not fetched are
+// nil.
+func (n *Node) fetchShards(ctx context.Context, set *ErasureSet, want int) [][]byte {
+	shards := make([][]byte, len(set.Layout.Shards))
+	got := 0
+	for i, holder := range set.Holders {
+		if got == want {
+			break
+		}
+		p, err := peer.Decode(holder)
+		if err != nil {
+			continue
+		}
+		var data []byte
+		err = n.scheduled(ctx, PriorityBackground, func() (err error) {
+			data, err = n.RequestContent(ctx, peer.AddrInfo{ID: p}, set.Layout.Shards[i])
+			return err
+		})
+		if err != nil || core.CIDForContent(data) != set.Layout.Shards[i] {
+			continue
+		}
+		shards[i] = data
+		got++
+	}
+	return shards
+}
+
+// maintainErasure checks that the holders of every erasure set still have
+// their shard, regenerates lost shards on other peers, and re-encodes sets
+// whose site changed since they were made.
+func (n *Node) maintainErasure(ctx context.Context) {
+	sets, err := n.ErasureSets()
+	if err != nil {
+		n.logger.Warn("failed to list erasure sets", zap.Error(err))
+		return
+	}
+	for _, set := range sets {
+		if v := n.siteVersion(set.SiteID); v != "" && v != set.Version {
+			_, err := n.ErasurePin(ctx, set.SiteID, set.Layout.Data, set.Layout.Parity)
+			if err == nil {
+				continue
+			}
+			// Keep the old shards healthy until the new version can be placed
+			n.logger.Debug("re-encoding erasure set failed", zap.String("site", Short(set.SiteID)), zap.Error(err))
+		}
+		was := set.Status
+		n.repairErasureSet(ctx, set)
+		if was == ErasureHealthy && set.Status != ErasureHealthy {
+			n.Store.Emit(store.EventPinIncomplete, map[string]interface{}{
+				"site_id": set.SiteID, "set_id": set.ID, "status": set.Status, "error": set.Error,
+			})
+		}
+		if err := n.putErasureSet(set); err != nil {
+			n.logger.Warn("failed to save erasure set", zap.String("site", Short(set.SiteID)), zap.Error(err))
+		}
+	}
+}
+
+// repairErasureSet asks each holder of set for its shard and regenerates
+// the missing shards onto new peers.
+func (n *Node) repairErasureSet(ctx context.Context, set *ErasureSet) {
+	set.Checked = time.Now().UTC()
+	set.Error = ""
+	var lost []int
+	for i, holder := range set.Holders {
+		if !n.holdsShard(ctx, holder, set, i) {
+			lost = append(lost, i)
+		}
+	}
+	if len(lost) == 0 {
+		set.Status = ErasureHealthy
+		return
+	}
+	if len(set.Holders)-len(lost) < set.Layout.Data {
+		set.Status = ErasureUnrecoverable
+		set.Error = fmt.Sprintf("%d of %d shards left, %d needed", len(set.Holders)-len(lost), len(set.Holders), set.Layout.Data)
+		// A node that still stores the site starts over from it
+		if n.siteVersion(set.SiteID) == set.Version {
+			if fresh, err := n.ErasurePin(ctx, set.SiteID, set.Layout.Data, set.Layout.Parity); err == nil {
+				*set = *fresh
+			}
+		}
+		return
+	}
+
+	set.Status = ErasureDegraded
+	shards := n.fetchShards(ctx, set, set.Layout.Data)
+	for _, i := range lost {
+		shards[i] = nil
+	}
+	if err := erasure.Reconstruct(&set.Layout, shards); err != nil {
+		set.Error = err.Error()
+		return
+	}
+	candidates := slices.DeleteFunc(n.ShardPeers(), func(p peer.ID) bool {
+		return slices.Contains(set.Holders, p.String())
+	})
+	for _, i := range lost {
+		set.Holders[i] = ""
+		for len(candidates) > 0 && set.Holders[i] == "" {
+			p := candidates[0]
+			candidates = candidates[1:]
+			if err := n.storeShard(ctx, p, set, i, shards[i]); err != nil {
+				n.logger.Debug("shard not placed", zap.String("peer", p.String()), zap.Error(err))
+				continue
+			}
+			set.Holders[i] = p.String()
+			set.Repaired++
+		}
+	}
+	if slices.Contains(set.Holders, "") {
+		set.Error = "not enough peers to hold every shard"
+		return
+	}
+	set.Status = ErasureHealthy
+}
+
+// holdsShard asks holder whether it has shard i of set.
+func (n *Node) holdsShard(ctx context.Context, holder string, set *ErasureSet, i int) bool {
+	p, err := peer.Decode(holder)
+	if err != nil {
+		return false
+	}
+	ack, err := n.queryHave(ctx, p, haveReq{SiteID: set.SiteID, CIDs: []string{set.Layout.Shards[i]}})
+	return err == nil && ack.Content[0]
+}
+
+func (n *Node) putErasureSet(set *ErasureSet) error {
+	b, err := cborMarshal(set)
+	if err != nil {
+		return err
+	}
+	return n.Store.PutErasureSet(set.SiteID, b)
+}
+
+func (n *Node) storeShard(ctx context.Context, p peer.ID, set *ErasureSet, i int, data []byte) error {
+	return n.sendShard(ctx, p, shardMsg{Op: "store", SetID: set.ID, SiteID: set.SiteID, Index: i, CID: set.Layout.Shards[i], Data: data})
+}
+
+// dropShards asks the holders of a set to drop its shards, as far as they
+// can be reached.
+func (n *Node) dropShards(ctx context.Context, setID string, holders []string) {
+	seen := make(map[string]bool)
+	for _, holder := range holders {
+		p, err := peer.Decode(holder)
+		if err != nil || seen[holder] {
+			continue
+		}
+		seen[holder] = true
+		if err := n.sendShard(ctx, p, shardMsg{Op: "drop", SetID: setID}); err != nil {
+			n.logger.Debug("shard drop failed", zap.String("peer", holder), zap.Error(err))
+		}
+	}
+}
+
+func (n *Node) sendShard(ctx context.Context, p peer.ID, msg shardMsg) error {
+	ctx, cancel := context.WithTimeout(ctx, ShardTimeout)
+	defer cancel()
+
+	s, err := n.Host.NewStream(ctx, p, ShardProto)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	if err := s.SetDeadline(time.Now().Add(ShardTimeout)); err != nil {
+		return err
+	}
+	b, err := cborMarshal(msg)
+	if err != nil {
+		return err
+	}
+	if _, err := s.Write(b); err != nil {
+		return err
+	}
+	if err := s.CloseWrite(); err != nil {
+		return err
+	}
+	var resp shardResp
+	if err := shardDecMode.NewDecoder(io.LimitReader(s, maxShardResponse)).Decode(&resp); err != nil {
+		return err
+	}
+	if !resp.Ok {
+		return fmt.Errorf("peer refused: %s", resp.Error)
+	}
+	return nil
+}
+
+func (n *Node) handleShardStream(s network.Stream) {
+	defer s.Close()
+	from := s.Conn().RemotePeer()
+	if err := s.SetDeadline(time.Now().Add(ShardTimeout)); err != nil {
+		return
+	}
+	if !n.rateLimiter.allow(from.String(), time.Now()) {
+		n.audit(AuditEntry{Kind: AuditBrowse, Peer: from.String(), Reason: "shard request over the rate limit"})
+		return
+	}
+
+	var msg shardMsg
+	if err := shardDecMode.NewDecoder(io.LimitReader(s, maxShardMessage)).Decode(&msg); err != nil {
+		log.Printf("handleShardStream: bad request from %s: %v", from, err)
+		n.audit(AuditEntry{Kind: AuditBrowse, Peer: from.String(), Reason: "malformed shard request: " + err.Error()})
+		return
+	}
+	var resp shardResp
+	if err := n.handleShardMsg(from, msg); err != nil {
+		resp.Error = err.Error()
+	} else {
+		resp.Ok = true
+	}
+	b, err := cborMarshal(resp)
+	if err != nil {
+		return
+	}
+	if _, err := s.Write(b); err != nil {
+		log.Printf("handleShardStream: write failed: %v", err)
+	}
+}
+
+func (n *Node) handleShardMsg(from peer.ID, msg shardMsg) error {
+	if !isHexID(msg.SetID) {
+		return errors.New("invalid set ID")
+	}
+	switch msg.Op {
+	case "store":
+		return n.receiveShard(from, msg)
+	case "drop":
+		shards, err := n.Store.ListShards()
+		if err != nil {
+			return err
+		}
+		// Only the node that stored a shard may drop it
+		for _, r := range shards {
+			if r.SetID == msg.SetID && r.From == from.String() {
+				if err := n.Store.DeleteShard(r.CID); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	default:
+		n.audit(AuditEntry{Kind: AuditBrowse, Peer: from.String(), Reason: "unknown shard operation"})
+		return errors.New("unknown operation")
+	}
+}
+
+func (n *Node) receiveShard(from peer.ID, msg shardMsg) error {
+	switch {
+	case !isHexID(msg.SiteID) || !isHexID(msg.CID):
+		return errors.New("invalid site ID or CID")
+	case msg.Index < 0 || msg.Index >= erasure.MaxDataShards+erasure.MaxParityShards:
+		return errors.New("shard index out of range")
+	case len(msg.Data) == 0 || len(msg.Data) > core.MaxContentSize:
+		return errors.New("invalid shard size")
+	case core.CIDForContent(msg.Data) != msg.CID:
+		n.audit(AuditEntry{Kind: AuditBrowse, Peer: from.String(), SiteID: msg.SiteID, ContentCID: msg.CID, Reason: "shard does not match its CID"})
+		return errors.New("shard does not match its CID")
+	case n.Store.IsDenied(msg.SiteID) || n.Store.IsDenied(msg.CID):
+		return ErrDenied
+	}
+
+	n.shardMu.Lock()
+	defer n.shardMu.Unlock()
+	used, err := n.Store.ShardBytes()
+	if err != nil {
+		return err
+	}
+	if old, err := n.Store.GetShard(msg.CID); err != nil {
+		return err
+	} else if old != nil {
+		used -= old.Size
+	}
+	if used+int64(len(msg.Data)) > n.config.ShardQuota {
+		return errors.New("shard quota exceeded")
+	}
+	if err := n.Store.PutContent(msg.CID, msg.Data); err != nil {
+		return err
+	}
+	return n.Store.PutShard(&store.ShardRecord{
+		CID:    msg.CID,
+		SetID:  msg.SetID,
+		SiteID: msg.SiteID,
+		Index:  msg.Index,
+		From:   from.String(),
+		Size:   int64(len(msg.Data)),
+		Stored: time.Now().UTC(),
+	})
+}
@@ -0,0 +1,161 @@
+package p2p
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"alxnet/internal/core"
+	"alxnet/internal/store"
+
+	peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+// newShardNode starts a node that holds up to quota bytes of shards.
+func newShardNode(t *testing.T, ctx context.Context, quota int64) *Node {
+	t.Helper()
+	db, err := store.NewMemory()
+	if err != nil {
+		t.Fatalf("store.NewMemory: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	cfg := DefaultNodeConfig()
+	cfg.ShardQuota = quota
+	n, err := New(ctx, db, "/ip4/127.0.0.1/tcp/0", nil, cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { n.Host.Close() })
+	return n
+}
+
+func TestErasurePin(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	owner := newTestNode(t, ctx)
+	const siteID = "aa00000000000000000000000000000000000000000000000000000000000000"
+	page := bytes.Repeat([]byte("<p>erasure coded page</p>"), 200)
+	rec, err := core.MarshalCanonical(&core.UpdateRecord{Version: core.RecordV1, Seq: 1, ContentCID: core.CIDForContent(page), TS: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	headCID := core.CIDForBytes(rec)
+	if err := owner.Store.PutContent(core.CIDForContent(page), page); err != nil {
+		t.Fatalf("PutContent: %v", err)
+	}
+	if err := owner.Store.PutRecord(headCID, rec); err != nil {
+		t.Fatalf("PutRecord: %v", err)
+	}
+	if err := owner.Store.PutHead(siteID, 1, headCID); err != nil {
+		t.Fatalf("PutHead: %v", err)
+	}
+
+	holders := make(map[string]*Node)
+	connect := func(n *Node) {
+		t.Helper()
+		if err := owner.Host.Connect(ctx, peer.AddrInfo{ID: n.Host.ID(), Addrs: n.Host.Addrs()}); err != nil {
+			t.Fatalf("Connect: %v", err)
+		}
+		waitForCapabilities(t, owner, n.Host.ID())
+	}
+	for i := 0; i < 4; i++ {
+		n := newShardNode(t, ctx, 1<<20)
+		holders[n.Host.ID().String()] = n
+		connect(n)
+	}
+	tiny := newShardNode(t, ctx, 10) // too small for any shard
+	connect(tiny)
+	connect(newTestNode(t, ctx)) // holds no shards
+
+	if _, err := owner.ErasurePin(ctx, siteID, 4, 4); err == nil {
+		t.Error("ErasurePin() succeeded with fewer peers than shards")
+	}
+	if _, err := owner.ErasurePin(ctx, "bb"+siteID[2:], 2, 2); err == nil {
+		t.Error("ErasurePin() succeeded for a site not stored here")
+	}
+	set, err := owner.ErasurePin(ctx, siteID, 2, 2)
+	if err != nil {
+		t.Fatalf("ErasurePin: %v", err)
+	}
+	if set.Status != ErasureHealthy || set.Version != headCID || !owner.Store.IsPinned(siteID) {
+		t.Errorf("set = %+v, want a healthy pinned set of the head", set)
+	}
+	for i, h := range set.Holders {
+		n := holders[h]
+		if n == nil {
+			t.Fatalf("shard %d placed on %s, not a holder", i, h)
+		}
+		if r, err := n.Store.GetShard(set.Layout.Shards[i]); err != nil || r == nil || r.From != owner.Host.ID().String() {
+			t.Errorf("holder of shard %d has record %+v, %v", i, r, err)
+		}
+	}
+
+	// Only the node that stored a shard may drop it, and shards must match
+	// their CID
+	other := set.Holders[1]
+	otherID, _ := peer.Decode(other)
+	if err := tiny.Host.Connect(ctx, peer.AddrInfo{ID: otherID, Addrs: holders[other].Host.Addrs()}); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	if err := tiny.sendShard(ctx, otherID, shardMsg{Op: "drop", SetID: set.ID}); err != nil {
+		t.Fatalf("drop: %v", err)
+	}
+	if ok, _ := holders[other].Store.HasContent(set.Layout.Shards[1]); !ok {
+		t.Error("a peer dropped a shard it did not store")
+	}
+	bad := shardMsg{Op: "store", SetID: set.ID, SiteID: siteID, CID: set.Layout.Shards[0], Data: []byte("forged")}
+	if err := tiny.sendShard(ctx, otherID, bad); err == nil {
+		t.Error("a shard not matching its CID was stored")
+	}
+
+	// A holder leaves; the next check regenerates its shard on a new peer
+	spare := newShardNode(t, ctx, 1<<20)
+	holders[spare.Host.ID().String()] = spare
+	connect(spare)
+	lost := set.Holders[0]
+	holders[lost].Host.Close()
+
+	owner.maintainErasure(ctx)
+	set, err = owner.ErasureSet(siteID)
+	if err != nil {
+		t.Fatalf("ErasureSet: %v", err)
+	}
+	if set.Status != ErasureHealthy || set.Repaired != 1 || set.Holders[0] != spare.Host.ID().String() {
+		t.Fatalf("after repair set = %+v, want shard 0 on the spare", set)
+	}
+	if ok, _ := spare.Store.HasContent(set.Layout.Shards[0]); !ok {
+		t.Error("regenerated shard not stored on the spare")
+	}
+
+	car, err := owner.RecoverErasureSet(ctx, siteID)
+	if err != nil {
+		t.Fatalf("RecoverErasureSet: %v", err)
+	}
+	dst, err := store.NewMemory()
+	if err != nil {
+		t.Fatalf("store.NewMemory: %v", err)
+	}
+	defer dst.Close()
+	res, err := dst.ImportCAR(bytes.NewReader(car))
+	if err != nil {
+		t.Fatalf("ImportCAR: %v", err)
+	}
+	if res.Site == nil || res.Site.Head != headCID {
+		t.Errorf("recovered export = %+v, want head %s", res.Site, headCID)
+	}
+
+	if err := owner.RemoveErasureSet(ctx, siteID); err != nil {
+		t.Fatalf("RemoveErasureSet: %v", err)
+	}
+	for i, h := range set.Holders {
+		if ok, _ := holders[h].Store.HasContent(set.Layout.Shards[i]); ok {
+			t.Errorf("shard %d still held after the set was removed", i)
+		}
+	}
+	if sets, _ := owner.ErasureSets(); slices.ContainsFunc(sets, func(s *ErasureSet) bool { return s.SiteID == siteID }) {
+		t.Error("removed set still listed")
+	}
+}
@@ -0,0 +1,77 @@
+package p2p
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"alxnet/internal/core"
+	bncrypto "alxnet/internal/crypto"
+)
+
+// Gateway authorizations travel as the unpadded URL-safe base64 of their
+// canonical CBOR, short enough for an HTTP header.
+
+// MaxGatewayProof bounds an encoded gateway authorization.
+const MaxGatewayProof = 1024
+
+// BuildGatewayAuth signs an authorization for the gateway at host to mirror
+// the site of sitePriv until expires.
+func BuildGatewayAuth(sitePriv ed25519.PrivateKey, host string, expires time.Time) (*core.GatewayAuth, error) {
+	rec := &core.GatewayAuth{
+		Version: "v1",
+		SitePub: sitePriv.Public().(ed25519.PublicKey),
+		Host:    host,
+		Expires: expires.Unix(),
+		TS:      time.Now().Unix(),
+	}
+	b, err := core.CanonicalMarshalGatewayAuthNoSig(rec)
+	if err != nil {
+		return nil, err
+	}
+	rec.Sig = ed25519.Sign(sitePriv, bncrypto.PreimageGatewayAuth(b))
+	return rec, rec.Validate()
+}
+
+// VerifyGatewayAuth checks structure and signature of a gateway
+// authorization and that it has not expired at now.
+func VerifyGatewayAuth(rec *core.GatewayAuth, now time.Time) error {
+	if err := rec.Validate(); err != nil {
+		return err
+	}
+	b, err := core.CanonicalMarshalGatewayAuthNoSig(rec)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(ed25519.PublicKey(rec.SitePub), bncrypto.PreimageGatewayAuth(b), rec.Sig) {
+		return errors.New("invalid gateway authorization signature")
+	}
+	if now.Unix() >= rec.Expires {
+		return errors.New("gateway authorization has expired")
+	}
+	return nil
+}
+
+// EncodeGatewayAuth returns the transport encoding of rec.
+func EncodeGatewayAuth(rec *core.GatewayAuth) string {
+	return base64.RawURLEncoding.EncodeToString(mustMarshal(rec))
+}
+
+// DecodeGatewayAuth parses an encoded gateway authorization. It does not
+// verify it.
+func DecodeGatewayAuth(s string) (*core.GatewayAuth, error) {
+	if len(s) > MaxGatewayProof {
+		return nil, fmt.Errorf("gateway authorization too long: %d bytes (maximum %d)", len(s), MaxGatewayProof)
+	}
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("gateway authorization is not unpadded URL-safe base64: %w", err)
+	}
+	var rec core.GatewayAuth
+	if err := core.UnmarshalCBOR(b, &rec); err != nil {
+		return nil, fmt.Errorf("invalid gateway authorization: %w", err)
+	}
+	return &rec, nil
+}
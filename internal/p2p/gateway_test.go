@@ -0,0 +1,59 @@
+package p2p
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGatewayAuth(t *testing.T) {
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	now := time.Now()
+	rec, err := BuildGatewayAuth(priv, "mirror.example.org", now.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("BuildGatewayAuth: %v", err)
+	}
+	proof := EncodeGatewayAuth(rec)
+	if len(proof) > MaxGatewayProof {
+		t.Fatalf("encoded authorization is %d bytes", len(proof))
+	}
+	decoded, err := DecodeGatewayAuth(proof)
+	if err != nil {
+		t.Fatalf("DecodeGatewayAuth: %v", err)
+	}
+	if err := VerifyGatewayAuth(decoded, now); err != nil {
+		t.Fatalf("VerifyGatewayAuth: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		proof  string
+		now    time.Time
+		errMsg string
+	}{
+		{"expired", proof, now.Add(25 * time.Hour), "has expired"},
+		{"padded base64", proof + "=", now, "not unpadded URL-safe base64"},
+		{"too long", strings.Repeat("A", MaxGatewayProof+1), now, "too long"},
+		{"not CBOR", "AAAA", now, "invalid gateway authorization"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, err := DecodeGatewayAuth(tt.proof)
+			if err == nil {
+				err = VerifyGatewayAuth(rec, tt.now)
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("error = %v, want %q", err, tt.errMsg)
+			}
+		})
+	}
+
+	// The signature covers the host, so a gateway cannot claim another's
+	tampered := *decoded
+	tampered.Host = "evil.example.org"
+	if err := VerifyGatewayAuth(&tampered, now); err == nil || !strings.Contains(err.Error(), "invalid gateway authorization signature") {
+		t.Errorf("authorization moved to another host: error = %v", err)
+	}
+}
@@ -0,0 +1,236 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"alxnet/internal/core"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Gossip on the updates topic is checked twice. The topic validator runs
+// inside GossipSub before a message is delivered or forwarded and checks
+// what holds on every node: encoding, record structure, content CIDs and
+// signatures. A message failing those is rejected, so it goes no further
+// and counts against the score of the peer that sent it. What depends on
+// this node's store, such as sequence numbers and the denylist, is checked
+// when the validation pool applies the message.
+
+// Peer scoring of the updates topic. Only invalid messages are scored: the
+// penalty grows with the square of their count, so a peer sending one by
+// mistake loses little, while one flooding forgeries soon drops below the
+// thresholds. The count decays to nothing over an hour.
+const (
+	invalidMessageWeight = -10
+	invalidMessageDecay  = time.Hour
+
+	// Below these scores GossipSub stops gossiping with a peer, stops
+	// publishing to it, and finally ignores everything it sends.
+	gossipScoreGossipThreshold   = -100  // about 3 invalid messages
+	gossipScorePublishThreshold  = -250  // 5
+	gossipScoreGraylistThreshold = -1000 // 10
+
+	// gossipScoreInterval is how often the node copies the scores.
+	gossipScoreInterval = 5 * time.Second
+)
+
+func gossipScoreParams() *pubsub.PeerScoreParams {
+	return &pubsub.PeerScoreParams{
+		SkipAtomicValidation: true,
+		Topics: map[string]*pubsub.TopicScoreParams{
+			Topic: {
+				SkipAtomicValidation:           true,
+				TopicWeight:                    1,
+				TimeInMeshQuantum:              time.Second, // unused, but a divisor
+				InvalidMessageDeliveriesWeight: invalidMessageWeight,
+				InvalidMessageDeliveriesDecay:  pubsub.ScoreParameterDecay(invalidMessageDecay),
+			},
+		},
+		AppSpecificScore: func(peer.ID) float64 { return 0 },
+		DecayInterval:    pubsub.DefaultDecayInterval,
+		DecayToZero:      pubsub.DefaultDecayToZero,
+		// Reconnecting does not wipe the slate
+		RetainScore: invalidMessageDecay,
+	}
+}
+
+func gossipScoreThresholds() *pubsub.PeerScoreThresholds {
+	return &pubsub.PeerScoreThresholds{
+		GossipThreshold:   gossipScoreGossipThreshold,
+		PublishThreshold:  gossipScorePublishThreshold,
+		GraylistThreshold: gossipScoreGraylistThreshold,
+	}
+}
+
+// gossipScores keeps the scores GossipSub last reported.
+type gossipScores struct {
+	mu     sync.RWMutex
+	scores map[peer.ID]float64
+}
+
+func (s *gossipScores) update(scores map[peer.ID]float64) {
+	s.mu.Lock()
+	s.scores = scores
+	s.mu.Unlock()
+}
+
+func (s *gossipScores) get(p peer.ID) float64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.scores[p]
+}
+
+// GossipScore returns p's GossipSub score as of the last few seconds;
+// negative after it sent invalid messages.
+func (n *Node) GossipScore(p peer.ID) float64 {
+	return n.gossipScores.get(p)
+}
+
+// gossipMsg is a message the topic validator decoded and checked.
+type gossipMsg struct {
+	audit  AuditEntry
+	kind   *RecordType
+	record any
+	origin peer.ID // publisher of the message
+}
+
+// gossipUpdate is an update record with the content sent along.
+type gossipUpdate struct {
+	rec     *core.UpdateRecord
+	content []byte // decompressed
+	relayed int    // content bytes as received
+}
+
+// validateGossip is the updates topic validator. Messages of a kind this
+// node does not know, such as the "bn-alive" heartbeats of older nodes, are
+// ignored: neither delivered, forwarded nor scored.
+func (n *Node) validateGossip(ctx context.Context, from peer.ID, msg *pubsub.Message) pubsub.ValidationResult {
+	g, err := decodeGossip(msg.GetData())
+	if err == nil && g != nil && g.kind.Check != nil {
+		err = g.kind.Check(n, g.record)
+	}
+	if err != nil {
+		if !msg.Local {
+			e := g.audit
+			e.Peer = from.String()
+			n.audited(e, err)
+		}
+		return pubsub.ValidationReject
+	}
+	if g == nil {
+		return pubsub.ValidationIgnore
+	}
+	g.origin = msg.GetFrom()
+	msg.ValidatorData = g
+	return pubsub.ValidationAccept
+}
+
+// decodeGossip decodes a message of the updates topic as the first
+// registered record type that recognises it, and checks it as far as that
+// does not depend on this node's state. It returns nil for kinds it does
+// not know. On error the returned message only carries its audit entry.
+func decodeGossip(data []byte) (*gossipMsg, error) {
+	for i := range recordTypes {
+		t := &recordTypes[i]
+		rec, e, err := t.Decode(data)
+		if rec == nil && err == nil {
+			continue
+		}
+		return &gossipMsg{audit: e, kind: t, record: rec}, err
+	}
+	return nil, nil
+}
+
+// applyGossip applies a message the topic validator accepted.
+func (n *Node) applyGossip(ctx context.Context, from peer.ID, g *gossipMsg) error {
+	return g.kind.Apply(ctx, n, GossipMessage{Record: g.record, From: from, Origin: g.origin})
+}
+
+func init() {
+	RegisterRecordType(RecordType{Name: "update", Decode: decodeUpdate, Check: checkUpdateVersion, Apply: applyUpdate})
+	RegisterRecordType(RecordType{Name: "delete", Decode: decodeDelete, Apply: applyDelete})
+}
+
+func decodeUpdate(data []byte) (any, AuditEntry, error) {
+	var env GossipUpdate
+	if err := cborUnmarshal(data, &env); err != nil || len(env.Record) == 0 {
+		return nil, AuditEntry{}, nil
+	}
+	e := AuditEntry{Kind: AuditUpdate, RecordCID: core.CIDForBytes(env.Record)}
+	r := new(core.UpdateRecord)
+	if err := cborUnmarshal(env.Record, r); err != nil {
+		return nil, e, fmt.Errorf("malformed record: %w", err)
+	}
+	e.SiteID, e.ContentCID = core.SiteIDFromPub(r.SitePub), r.ContentCID
+	u, err := checkUpdate(r, env)
+	if err != nil {
+		return nil, e, err
+	}
+	return u, e, nil
+}
+
+// checkUpdateVersion applies the node's record format schedule.
+func checkUpdateVersion(n *Node, rec any) error {
+	return n.checkRecordVersion(rec.(*gossipUpdate).rec)
+}
+
+// checkUpdate verifies the update record and the content sent with it.
+func checkUpdate(r *core.UpdateRecord, env GossipUpdate) (*gossipUpdate, error) {
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	if err := verifyUpdateSigs(r); err != nil {
+		return nil, err
+	}
+	content, err := decompressGossip(env)
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > 0 && core.CIDForContent(content) != r.ContentCID {
+		return nil, errors.New("content CID mismatch")
+	}
+	return &gossipUpdate{rec: r, content: content, relayed: len(env.Content)}, nil
+}
+
+func applyUpdate(ctx context.Context, n *Node, m GossipMessage) error {
+	u := m.Record.(*gossipUpdate)
+	if err := n.ValidateAndApply(u.rec, u.content); err != nil {
+		log.Printf("reject update: %v", err)
+		return err
+	}
+	// Relaying valid content counts as giving back
+	if u.relayed > 0 {
+		n.addBandwidth(m.From, 0, int64(u.relayed))
+	} else {
+		n.fetchDeferred(ctx, core.SiteIDFromPub(u.rec.SitePub), u.rec.ContentCID, m.Origin)
+	}
+	return nil
+}
+
+func decodeDelete(data []byte) (any, AuditEntry, error) {
+	var env GossipDelete
+	if err := cborUnmarshal(data, &env); err != nil || len(env.Delete) == 0 {
+		return nil, AuditEntry{}, nil
+	}
+	e := AuditEntry{Kind: AuditDelete}
+	del := new(core.DeleteRecord)
+	if err := cborUnmarshal(env.Delete, del); err != nil {
+		return nil, e, fmt.Errorf("malformed record: %w", err)
+	}
+	e.SiteID = core.SiteIDFromPub(del.SitePub)
+	e.RecordCID, e.ContentCID = del.TargetRec, del.TargetCont
+	if err := verifyDelete(del); err != nil {
+		return nil, e, err
+	}
+	return del, e, nil
+}
+
+func applyDelete(ctx context.Context, n *Node, m GossipMessage) error {
+	return n.ApplyDelete(*m.Record.(*core.DeleteRecord))
+}
@@ -0,0 +1,140 @@
+package p2p
+
+import (
+	"context"
+	"crypto/ed25519"
+	"strings"
+	"testing"
+	"time"
+
+	"alxnet/internal/core"
+
+	libp2p "github.com/libp2p/go-libp2p"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+// forgedUpdate is a well-formed update whose content does not match its
+// signed content CID.
+func forgedUpdate(t *testing.T, sitePriv ed25519.PrivateKey) []byte {
+	t.Helper()
+	env, _, err := SignUpdate(sitePriv, []byte("signed"), 1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.Content = []byte("swapped")
+	return mustMarshal(*env)
+}
+
+func TestDecodeGossip(t *testing.T) {
+	_, sitePriv, _ := ed25519.GenerateKey(nil)
+	_, otherPriv, _ := ed25519.GenerateKey(nil)
+	siteID := core.SiteIDFromPub(sitePriv.Public().(ed25519.PublicKey))
+
+	update, _, _ := SignUpdate(sitePriv, []byte("hello"), 1, "")
+	badSig := *update
+	var rec core.UpdateRecord
+	cborUnmarshal(update.Record, &rec)
+	rec.UpdateSig = append([]byte(nil), rec.UpdateSig...)
+	rec.UpdateSig[0] ^= 1
+	badSig.Record = mustMarshal(rec)
+	badEncoding := *update
+	badEncoding.Encoding = "gzip"
+
+	comment, _ := BuildComment(otherPriv, siteID, "", "first!")
+	forgedComment := *comment
+	forgedComment.Body = "edited"
+	del, _ := BuildDelete(sitePriv, strings.Repeat("ab", 32), "")
+	forgedDel := *del
+	forgedDel.SitePub = otherPriv.Public().(ed25519.PublicKey)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		kind    string // "" for messages ignored as unknown
+		wantErr string
+	}{
+		{"update", mustMarshal(*update), AuditUpdate, ""},
+		{"update signature", mustMarshal(badSig), AuditUpdate, "invalid update signature"},
+		{"update content", forgedUpdate(t, sitePriv), AuditUpdate, "content CID mismatch"},
+		{"update encoding", mustMarshal(badEncoding), AuditUpdate, "unknown content encoding"},
+		{"update record", mustMarshal(GossipUpdate{Record: []byte{0xff}}), AuditUpdate, "malformed record"},
+		{"comment", mustMarshal(GossipComment{Comment: mustMarshal(comment)}), AuditComment, ""},
+		{"comment signature", mustMarshal(GossipComment{Comment: mustMarshal(&forgedComment)}), AuditComment, "invalid comment signature"},
+		{"delete", mustMarshal(GossipDelete{Delete: mustMarshal(del)}), AuditDelete, ""},
+		{"delete signer", mustMarshal(GossipDelete{Delete: mustMarshal(&forgedDel)}), AuditDelete, "invalid delete signature"},
+		{"announce", mustMarshal(GossipAnnounce{Heads: []AnnouncedHead{{SiteID: siteID, Seq: 3, HeadCID: siteID}}}), AuditAnnounce, ""},
+		{"announce site ID", mustMarshal(GossipAnnounce{Heads: []AnnouncedHead{{SiteID: "../x", Seq: 3, HeadCID: siteID}}}), AuditAnnounce, "malformed"},
+		{"announce seq", mustMarshal(GossipAnnounce{Heads: []AnnouncedHead{{SiteID: siteID, HeadCID: siteID}}}), AuditAnnounce, "out of range"},
+		{"announce size", mustMarshal(GossipAnnounce{Heads: make([]AnnouncedHead, MaxAnnouncedHeads+1)}), AuditAnnounce, "more than"},
+		{"heartbeat", []byte("bn-alive"), "", ""},
+		{"unknown kind", mustMarshal(map[string]string{"Future": "record"}), "", ""},
+		{"not cbor", []byte("hello"), "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := decodeGossip(tt.data)
+			if tt.kind == "" {
+				if g != nil || err != nil {
+					t.Fatalf("decodeGossip = %+v, %v; want an unknown message", g, err)
+				}
+				return
+			}
+			if g == nil || g.audit.Kind != tt.kind {
+				t.Fatalf("decodeGossip = %+v, want kind %s", g, tt.kind)
+			}
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("decodeGossip error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGossipValidatorPenalizesSender(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	n := newTestNode(t, ctx)
+	if err := n.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// A peer running plain GossipSub, which forwards anything
+	h, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { h.Close() })
+	ps, err := pubsub.NewGossipSub(ctx, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	topic, err := ps.Join(Topic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := topic.Subscribe(); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Connect(ctx, peer.AddrInfo{ID: n.Host.ID(), Addrs: n.Host.Addrs()}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, sitePriv, _ := ed25519.GenerateKey(nil)
+	filter := AuditFilter{Kind: AuditUpdate, Peer: h.ID().String()}
+	for len(n.Audit(filter)) == 0 && ctx.Err() == nil {
+		topic.Publish(ctx, forgedUpdate(t, sitePriv))
+		time.Sleep(100 * time.Millisecond)
+	}
+	if got := n.Audit(filter); len(got) == 0 || got[0].Reason != "content CID mismatch" {
+		t.Fatalf("audit = %+v", got)
+	}
+	for n.GossipScore(h.ID()) >= 0 && ctx.Err() == nil {
+		time.Sleep(200 * time.Millisecond)
+	}
+	if score := n.GossipScore(h.ID()); score >= 0 {
+		t.Fatalf("GossipScore = %v after invalid messages, want negative", score)
+	}
+	if has, _ := n.Store.HasHead(core.SiteIDFromPub(sitePriv.Public().(ed25519.PublicKey))); has {
+		t.Error("forged update was applied")
+	}
+}
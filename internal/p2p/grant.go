@@ -0,0 +1,104 @@
+package p2p
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"alxnet/internal/core"
+	bncrypto "alxnet/internal/crypto"
+	"alxnet/internal/verify"
+)
+
+// Contributor grants travel like gateway authorizations, as the unpadded
+// URL-safe base64 of their canonical CBOR. A contributor signs file records
+// with their own key, the same way an owner signs them with the site key;
+// the owner checks them against the grant before drafting them.
+
+// MaxGrantProof bounds an encoded contributor grant.
+const MaxGrantProof = 16 << 10
+
+// BuildContributorGrant signs a grant letting contributorPub sign files at
+// paths of the site of sitePriv until expires.
+func BuildContributorGrant(sitePriv ed25519.PrivateKey, contributorPub ed25519.PublicKey, paths []string, expires time.Time) (*core.ContributorGrant, error) {
+	rec := &core.ContributorGrant{
+		Version:        "v1",
+		SitePub:        sitePriv.Public().(ed25519.PublicKey),
+		ContributorPub: contributorPub,
+		Paths:          paths,
+		Expires:        expires.Unix(),
+		TS:             time.Now().Unix(),
+	}
+	b, err := core.CanonicalMarshalContributorGrantNoSig(rec)
+	if err != nil {
+		return nil, err
+	}
+	rec.Sig = ed25519.Sign(sitePriv, bncrypto.PreimageContributorGrant(b))
+	return rec, rec.Validate()
+}
+
+// VerifyContributorGrant checks structure and signature of a contributor
+// grant and that it has not expired at now.
+func VerifyContributorGrant(rec *core.ContributorGrant, now time.Time) error {
+	if err := rec.Validate(); err != nil {
+		return err
+	}
+	b, err := core.CanonicalMarshalContributorGrantNoSig(rec)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(ed25519.PublicKey(rec.SitePub), bncrypto.PreimageContributorGrant(b), rec.Sig) {
+		return errors.New("invalid contributor grant signature")
+	}
+	if now.Unix() >= rec.Expires {
+		return errors.New("contributor grant has expired")
+	}
+	return nil
+}
+
+// VerifyContribution checks that rec is a file record signed by the
+// contributor of grant, for a path the grant covers, and that the grant is
+// valid at now for the site siteID.
+func VerifyContribution(rec *core.FileRecord, grant *core.ContributorGrant, siteID string, now time.Time) error {
+	if err := VerifyContributorGrant(grant, now); err != nil {
+		return err
+	}
+	if core.SiteIDFromPub(grant.SitePub) != siteID {
+		return errors.New("contributor grant is for another site")
+	}
+	if err := verify.FileRecord(rec); err != nil {
+		return err
+	}
+	if !bytes.Equal(rec.SitePub, grant.ContributorPub) {
+		return errors.New("file record is not signed by the granted contributor")
+	}
+	if !grant.Allows(rec.Path) {
+		return fmt.Errorf("contributor grant does not cover %q", rec.Path)
+	}
+	return nil
+}
+
+// EncodeContributorGrant returns the transport encoding of rec.
+func EncodeContributorGrant(rec *core.ContributorGrant) string {
+	return base64.RawURLEncoding.EncodeToString(mustMarshal(rec))
+}
+
+// DecodeContributorGrant parses an encoded contributor grant. It does not
+// verify it.
+func DecodeContributorGrant(s string) (*core.ContributorGrant, error) {
+	if len(s) > MaxGrantProof {
+		return nil, fmt.Errorf("contributor grant too long: %d bytes (maximum %d)", len(s), MaxGrantProof)
+	}
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("contributor grant is not unpadded URL-safe base64: %w", err)
+	}
+	var rec core.ContributorGrant
+	if err := core.UnmarshalCBOR(b, &rec); err != nil {
+		return nil, fmt.Errorf("invalid contributor grant: %w", err)
+	}
+	return &rec, nil
+}
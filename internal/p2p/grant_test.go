@@ -0,0 +1,70 @@
+package p2p
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"alxnet/internal/core"
+)
+
+func TestContributorGrant(t *testing.T) {
+	_, sitePriv, _ := ed25519.GenerateKey(rand.Reader)
+	contribPub, contribPriv, _ := ed25519.GenerateKey(rand.Reader)
+	siteID := core.SiteIDFromPub(sitePriv.Public().(ed25519.PublicKey))
+	now := time.Now()
+	grant, err := BuildContributorGrant(sitePriv, contribPub, []string{"blog/", "about.html"}, now.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("BuildContributorGrant: %v", err)
+	}
+	decoded, err := DecodeContributorGrant(EncodeContributorGrant(grant))
+	if err != nil {
+		t.Fatalf("DecodeContributorGrant: %v", err)
+	}
+	if err := VerifyContributorGrant(decoded, now); err != nil {
+		t.Fatalf("VerifyContributorGrant: %v", err)
+	}
+
+	post, err := BuildFileRecord(contribPriv, "blog/post.md", core.CIDForBytes([]byte("post")), "text/markdown")
+	if err != nil {
+		t.Fatalf("BuildFileRecord: %v", err)
+	}
+	if err := VerifyContribution(post, decoded, siteID, now); err != nil {
+		t.Fatalf("VerifyContribution: %v", err)
+	}
+
+	index, _ := BuildFileRecord(contribPriv, "index.html", core.CIDForBytes([]byte("index")), "text/html")
+	_, otherPriv, _ := ed25519.GenerateKey(rand.Reader)
+	forged, _ := BuildFileRecord(otherPriv, "blog/post.md", core.CIDForBytes([]byte("post")), "text/markdown")
+	tampered := *decoded
+	tampered.Paths = []string{"index.html"}
+
+	tests := []struct {
+		name   string
+		rec    *core.FileRecord
+		grant  *core.ContributorGrant
+		siteID string
+		now    time.Time
+		errMsg string
+	}{
+		{"path not granted", index, decoded, siteID, now, "does not cover"},
+		{"other signer", forged, decoded, siteID, now, "not signed by the granted contributor"},
+		{"other site", post, decoded, core.SiteIDFromPub(contribPub), now, "another site"},
+		{"expired", post, decoded, siteID, now.Add(25 * time.Hour), "has expired"},
+		{"paths changed", index, &tampered, siteID, now, "invalid contributor grant signature"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyContribution(tt.rec, tt.grant, tt.siteID, tt.now)
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("error = %v, want %q", err, tt.errMsg)
+			}
+		})
+	}
+
+	if _, err := DecodeContributorGrant(strings.Repeat("A", MaxGrantProof+1)); err == nil || !strings.Contains(err.Error(), "too long") {
+		t.Errorf("oversized grant: error = %v", err)
+	}
+}
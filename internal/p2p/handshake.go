@@ -0,0 +1,268 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	network "github.com/libp2p/go-libp2p/core/network"
+	peer "github.com/libp2p/go-libp2p/core/peer"
+	protocol "github.com/libp2p/go-libp2p/core/protocol"
+	msmux "github.com/multiformats/go-multistream"
+)
+
+// HelloProto exchanges wire protocol versions and optional features right
+// after two nodes connect, so that a node only uses what a peer understands
+// and incompatible peers are reported instead of failing to parse each
+// other's messages.
+const HelloProto protocol.ID = "/alxnet/hello/1.0.0"
+
+// Wire protocol versions spoken by this build. ProtocolVersion goes up for
+// changes older nodes can still talk to; MinProtocolVersion only goes up
+// when support for an old version is dropped.
+const (
+	ProtocolVersion    = 1
+	MinProtocolVersion = 1
+)
+
+// AgentVersion identifies this implementation to peers.
+const AgentVersion = "alxnet/1.0.0"
+
+// Optional features a node may advertise.
+const (
+	FeatureGossipZstd = "gossip-zstd" // reads zstd-compressed GossipUpdate content
+	FeatureHave       = "have"        // answers HaveProto
+	FeatureHosting    = "hosting"     // answers HostingProto
+	FeatureDialback   = "dialback"    // answers DialbackProto
+	FeatureProof      = "proof"       // answers ProofProto
+	FeatureSitemap    = "sitemap"     // answers SitemapProto, see NodeConfig.ServeSitemap
+	FeatureShards     = "shards"      // answers ShardProto, see NodeConfig.ShardQuota
+	FeatureRecordSync = "record-sync" // answers get_missing browse requests
+)
+
+// LocalFeatures lists the features every node of this build advertises;
+// see Node.Features for the optional ones.
+var LocalFeatures = []string{FeatureGossipZstd, FeatureHave, FeatureHosting, FeatureDialback, FeatureProof, FeatureRecordSync}
+
+// HelloTimeout bounds a single hello round trip.
+const HelloTimeout = 5 * time.Second
+
+// Hello message limits
+const (
+	maxHelloMessage  = 4 * 1024
+	maxHelloFeatures = 32
+	maxFeatureLength = 64
+	maxAgentLength   = 128
+)
+
+var helloDecMode, _ = cbor.DecOptions{MaxArrayElements: maxHelloFeatures, MaxMapPairs: 16}.DecMode()
+
+type hello struct {
+	Version    uint
+	MinVersion uint
+	Features   []string
+	Agent      string
+}
+
+// PeerCapabilities is what a connected peer advertised.
+type PeerCapabilities struct {
+	Version  uint     `json:"version"` // negotiated version, 0 for legacy peers
+	Features []string `json:"features"`
+	Agent    string   `json:"agent,omitempty"`
+	Legacy   bool     `json:"legacy"` // peer predates the hello protocol
+}
+
+type capabilityTable struct {
+	mu    sync.RWMutex
+	peers map[peer.ID]*PeerCapabilities
+}
+
+// Features lists the features this node advertises: LocalFeatures, those
+// of scheduled rule changes (see Activations) and the optional ones its
+// configuration enables.
+func (n *Node) Features() []string {
+	features := append(slices.Clone(LocalFeatures), scheduledFeatures()...)
+	if n.config != nil && n.config.ServeSitemap {
+		features = append(features, FeatureSitemap)
+	}
+	if n.config != nil && n.config.ShardQuota > 0 {
+		features = append(features, FeatureShards)
+	}
+	return features
+}
+
+func (n *Node) localHello() hello {
+	return hello{
+		Version:    ProtocolVersion,
+		MinVersion: MinProtocolVersion,
+		Features:   n.Features(),
+		Agent:      AgentVersion,
+	}
+}
+
+// validate checks a peer's hello against the message limits.
+func (h hello) validate() error {
+	if h.Version == 0 || h.MinVersion > h.Version {
+		return fmt.Errorf("invalid version range %d..%d", h.MinVersion, h.Version)
+	}
+	if len(h.Features) > maxHelloFeatures {
+		return errors.New("too many features")
+	}
+	for _, f := range h.Features {
+		if f == "" || len(f) > maxFeatureLength {
+			return fmt.Errorf("invalid feature name %q", f)
+		}
+	}
+	if len(h.Agent) > maxAgentLength {
+		return errors.New("agent too long")
+	}
+	return nil
+}
+
+// negotiateVersion returns the highest version both sides speak.
+func negotiateVersion(local, remote hello) (uint, error) {
+	if err := remote.validate(); err != nil {
+		return 0, err
+	}
+	minVersion := max(remote.MinVersion, 1)
+	if remote.Version < local.MinVersion {
+		return 0, fmt.Errorf("peer speaks protocol v%d, this node needs at least v%d", remote.Version, local.MinVersion)
+	}
+	if local.Version < minVersion {
+		return 0, fmt.Errorf("peer needs protocol v%d, this node speaks up to v%d", minVersion, local.Version)
+	}
+	return min(local.Version, remote.Version), nil
+}
+
+// helloPeer runs the handshake with p and records its capabilities. Peers
+// without the hello protocol are recorded as legacy; incompatible peers are
+// disconnected.
+func (n *Node) helloPeer(ctx context.Context, p peer.ID) error {
+	ctx, cancel := context.WithTimeout(ctx, HelloTimeout)
+	defer cancel()
+
+	s, err := n.Host.NewStream(ctx, p, HelloProto)
+	if errors.Is(err, msmux.ErrNotSupported[protocol.ID]{}) {
+		n.setCapabilities(p, &PeerCapabilities{Legacy: true})
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	if err := s.SetDeadline(time.Now().Add(HelloTimeout)); err != nil {
+		return err
+	}
+	b, err := cborMarshal(n.localHello())
+	if err != nil {
+		return err
+	}
+	if _, err := s.Write(b); err != nil {
+		return err
+	}
+	if err := s.CloseWrite(); err != nil {
+		return err
+	}
+
+	var remote hello
+	if err := helloDecMode.NewDecoder(io.LimitReader(s, maxHelloMessage)).Decode(&remote); err != nil {
+		return err
+	}
+	return n.acceptHello(p, remote)
+}
+
+func (n *Node) handleHelloStream(s network.Stream) {
+	defer s.Close()
+	remotePeer := s.Conn().RemotePeer()
+
+	if err := s.SetDeadline(time.Now().Add(HelloTimeout)); err != nil {
+		return
+	}
+	var remote hello
+	if err := helloDecMode.NewDecoder(io.LimitReader(s, maxHelloMessage)).Decode(&remote); err != nil {
+		log.Printf("handleHelloStream: bad hello from %s: %v", remotePeer, err)
+		return
+	}
+	// Answer before judging compatibility so the peer can report why
+	b, _ := cborMarshal(n.localHello())
+	if _, err := s.Write(b); err != nil {
+		log.Printf("handleHelloStream: write failed: %v", err)
+		return
+	}
+	if err := n.acceptHello(remotePeer, remote); err != nil {
+		log.Printf("handleHelloStream: %s: %v", remotePeer, err)
+	}
+}
+
+// acceptHello records the capabilities of p, or disconnects it when no
+// common protocol version exists.
+func (n *Node) acceptHello(p peer.ID, remote hello) error {
+	version, err := negotiateVersion(n.localHello(), remote)
+	if err != nil {
+		n.updatePeerReputation(p, -5)
+		_ = n.Host.Network().ClosePeer(p)
+		return fmt.Errorf("incompatible peer: %w", err)
+	}
+	n.setCapabilities(p, &PeerCapabilities{
+		Version:  version,
+		Features: slices.Clone(remote.Features),
+		Agent:    remote.Agent,
+	})
+	return nil
+}
+
+func (n *Node) setCapabilities(p peer.ID, c *PeerCapabilities) {
+	n.caps.mu.Lock()
+	defer n.caps.mu.Unlock()
+	if n.caps.peers == nil {
+		n.caps.peers = make(map[peer.ID]*PeerCapabilities)
+	}
+	n.caps.peers[p] = c
+}
+
+func (n *Node) forgetCapabilities(p peer.ID) {
+	n.caps.mu.Lock()
+	defer n.caps.mu.Unlock()
+	delete(n.caps.peers, p)
+}
+
+// PeerCapabilities returns what p advertised, if the handshake has
+// completed.
+func (n *Node) PeerCapabilities(p peer.ID) (PeerCapabilities, bool) {
+	n.caps.mu.RLock()
+	defer n.caps.mu.RUnlock()
+	c, ok := n.caps.peers[p]
+	if !ok {
+		return PeerCapabilities{}, false
+	}
+	out := *c
+	out.Features = slices.Clone(c.Features)
+	return out, true
+}
+
+// PeerSupports reports whether p advertised feature. Peers whose handshake
+// has not completed support nothing optional.
+func (n *Node) PeerSupports(p peer.ID, feature string) bool {
+	n.caps.mu.RLock()
+	defer n.caps.mu.RUnlock()
+	c, ok := n.caps.peers[p]
+	return ok && slices.Contains(c.Features, feature)
+}
+
+// topicPeersSupport reports whether every peer in the update topic mesh
+// advertised feature.
+func (n *Node) topicPeersSupport(feature string) bool {
+	for _, p := range n.Topic.ListPeers() {
+		if !n.PeerSupports(p, feature) {
+			return false
+		}
+	}
+	return true
+}
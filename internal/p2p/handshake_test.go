@@ -0,0 +1,119 @@
+package p2p
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	libp2p "github.com/libp2p/go-libp2p"
+	peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestNegotiateVersion(t *testing.T) {
+	local := hello{Version: 3, MinVersion: 2}
+	tests := []struct {
+		name    string
+		remote  hello
+		want    uint
+		wantErr bool
+	}{
+		{"same", hello{Version: 3, MinVersion: 2}, 3, false},
+		{"older peer", hello{Version: 2, MinVersion: 1}, 2, false},
+		{"newer peer", hello{Version: 5, MinVersion: 3}, 3, false},
+		{"peer too old", hello{Version: 1, MinVersion: 1}, 0, true},
+		{"peer too new", hello{Version: 6, MinVersion: 4}, 0, true},
+		{"no version", hello{}, 0, true},
+		{"inverted range", hello{Version: 2, MinVersion: 3}, 0, true},
+		{"too many features", hello{Version: 3, Features: make([]string, maxHelloFeatures+1)}, 0, true},
+		{"empty feature", hello{Version: 3, Features: []string{""}}, 0, true},
+		{"long feature", hello{Version: 3, Features: []string{strings.Repeat("f", maxFeatureLength+1)}}, 0, true},
+		{"long agent", hello{Version: 3, Agent: strings.Repeat("a", maxAgentLength+1)}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := negotiateVersion(local, tt.remote)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("negotiateVersion() = %d, %v; want %d, err %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+// waitForCapabilities polls until n has finished the handshake with p.
+func waitForCapabilities(t *testing.T, n *Node, p peer.ID) PeerCapabilities {
+	t.Helper()
+	deadline := time.Now().Add(HelloTimeout)
+	for time.Now().Before(deadline) {
+		if c, ok := n.PeerCapabilities(p); ok {
+			return c
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("no capabilities recorded for %s", p)
+	return PeerCapabilities{}
+}
+
+func TestHelloHandshake(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	a := newTestNode(t, ctx)
+	b := newTestNode(t, ctx)
+	if err := a.Host.Connect(ctx, peer.AddrInfo{ID: b.Host.ID(), Addrs: b.Host.Addrs()}); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	for _, pair := range []struct{ self, other *Node }{{a, b}, {b, a}} {
+		c := waitForCapabilities(t, pair.self, pair.other.Host.ID())
+		if c.Legacy || c.Version != ProtocolVersion || c.Agent != AgentVersion {
+			t.Errorf("capabilities = %+v, want v%d from %s", c, ProtocolVersion, AgentVersion)
+		}
+		if !pair.self.PeerSupports(pair.other.Host.ID(), FeatureGossipZstd) {
+			t.Errorf("PeerSupports(%s) = false", FeatureGossipZstd)
+		}
+		if pair.self.PeerSupports(pair.other.Host.ID(), "chunking") {
+			t.Error("PeerSupports() = true for a feature that was not advertised")
+		}
+	}
+
+	// Disconnecting forgets the peer
+	if err := a.Host.Network().ClosePeer(b.Host.ID()); err != nil {
+		t.Fatalf("ClosePeer: %v", err)
+	}
+	deadline := time.Now().Add(HelloTimeout)
+	for {
+		if _, ok := a.PeerCapabilities(b.Host.ID()); !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("capabilities kept after disconnect")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestHelloLegacyPeer(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	current := newTestNode(t, ctx)
+	// A bare host neither answers nor sends hellos, like a node built
+	// before the handshake existed.
+	legacy, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	if err != nil {
+		t.Fatalf("libp2p.New: %v", err)
+	}
+	t.Cleanup(func() { legacy.Close() })
+
+	if err := current.Host.Connect(ctx, peer.AddrInfo{ID: legacy.ID(), Addrs: legacy.Addrs()}); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	c := waitForCapabilities(t, current, legacy.ID())
+	if !c.Legacy || c.Version != 0 || len(c.Features) != 0 {
+		t.Errorf("capabilities = %+v, want legacy", c)
+	}
+	if current.PeerSupports(legacy.ID(), FeatureGossipZstd) {
+		t.Error("legacy peer reported as supporting compression")
+	}
+}
@@ -0,0 +1,221 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Head cache settings. Page loads for sites this node does not store need
+// the site's head; caching it briefly saves a network round trip per
+// request, and remembering misses stops repeated lookups of unknown sites.
+const (
+	HeadCacheTTL         = 30 * time.Second
+	HeadNegativeCacheTTL = 10 * time.Second
+	maxHeadCacheEntries  = 4096
+	// headLookupPeers is how many connected peers LookupHead asks at once.
+	headLookupPeers = 3
+)
+
+// ErrHeadNotFound is returned when peers answered but none has the site.
+var ErrHeadNotFound = errors.New("head not found")
+
+// HeadInfo is a site head as reported by a peer.
+type HeadInfo struct {
+	Seq        uint64  `json:"seq"`
+	HeadCID    string  `json:"head_cid"`
+	ContentCID string  `json:"content_cid"`
+	Peer       peer.ID `json:"peer"` // who reported it
+}
+
+// HeadCacheStats reports head cache effectiveness.
+type HeadCacheStats struct {
+	Hits         uint64 `json:"hits"`
+	NegativeHits uint64 `json:"negative_hits"`
+	Misses       uint64 `json:"misses"`
+	Entries      int    `json:"entries"`
+}
+
+type headEntry struct {
+	head    HeadInfo
+	found   bool
+	expires time.Time
+}
+
+type headCache struct {
+	mu      sync.Mutex
+	entries map[string]headEntry
+	stats   HeadCacheStats
+	now     func() time.Time
+}
+
+func (c *headCache) clock() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
+
+// get returns the cached head of siteID. ok is false on a miss; found is
+// false for a cached miss.
+func (c *headCache) get(siteID string) (head HeadInfo, found, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[siteID]
+	if !ok || !c.clock().Before(e.expires) {
+		c.stats.Misses++
+		return HeadInfo{}, false, false
+	}
+	if e.found {
+		c.stats.Hits++
+	} else {
+		c.stats.NegativeHits++
+	}
+	return e.head, e.found, true
+}
+
+// put caches head unless a fresher entry with a higher seq is present.
+func (c *headCache) put(siteID string, head HeadInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.clock()
+	if e, ok := c.entries[siteID]; ok && e.found && e.head.Seq > head.Seq && now.Before(e.expires) {
+		return
+	}
+	c.store(siteID, headEntry{head: head, found: true, expires: now.Add(HeadCacheTTL)}, now)
+}
+
+// putMissing records that no peer has siteID.
+func (c *headCache) putMissing(siteID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.clock()
+	c.store(siteID, headEntry{expires: now.Add(HeadNegativeCacheTTL)}, now)
+}
+
+func (c *headCache) store(siteID string, e headEntry, now time.Time) {
+	if c.entries == nil {
+		c.entries = make(map[string]headEntry)
+	}
+	if _, ok := c.entries[siteID]; !ok && len(c.entries) >= maxHeadCacheEntries {
+		for id, old := range c.entries {
+			if !now.Before(old.expires) {
+				delete(c.entries, id)
+			}
+		}
+		// Still full of live entries: drop an arbitrary one
+		for id := range c.entries {
+			if len(c.entries) < maxHeadCacheEntries {
+				break
+			}
+			delete(c.entries, id)
+		}
+	}
+	c.entries[siteID] = e
+}
+
+func (c *headCache) invalidate(siteID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, siteID)
+}
+
+func (c *headCache) snapshot() HeadCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st := c.stats
+	st.Entries = len(c.entries)
+	return st
+}
+
+// LookupHead returns the newest head of siteID known to connected peers,
+// asking up to three of them in parallel. Answers are cached for
+// HeadCacheTTL and "no peer has it" for HeadNegativeCacheTTL; lookups that
+// fail for other reasons are not cached.
+func (n *Node) LookupHead(ctx context.Context, siteID string) (HeadInfo, error) {
+	if head, found, ok := n.heads.get(siteID); ok {
+		if !found {
+			return HeadInfo{}, ErrHeadNotFound
+		}
+		return head, nil
+	}
+
+	peers := n.Host.Network().Peers()
+	if len(peers) == 0 {
+		return HeadInfo{}, errors.New("no connected peers")
+	}
+	if len(peers) > headLookupPeers {
+		peers = peers[:headLookupPeers]
+	}
+
+	type answer struct {
+		head HeadInfo
+		err  error
+	}
+	answers := make(chan answer, len(peers))
+	for _, p := range peers {
+		go func(p peer.ID) {
+			seq, headCID, contentCID, err := n.RequestHead(ctx, peer.AddrInfo{ID: p}, siteID)
+			answers <- answer{HeadInfo{Seq: seq, HeadCID: headCID, ContentCID: contentCID, Peer: p}, err}
+		}(p)
+	}
+
+	var best *HeadInfo
+	notFound := 0
+	var lastErr error
+	for range peers {
+		a := <-answers
+		switch {
+		case a.err == nil:
+			if best == nil || a.head.Seq > best.Seq {
+				h := a.head
+				best = &h
+			}
+		case errors.Is(a.err, ErrHeadNotFound):
+			notFound++
+		default:
+			lastErr = a.err
+		}
+	}
+
+	switch {
+	case best != nil:
+		return *best, nil // cached by RequestHead
+	case notFound == len(peers):
+		n.heads.putMissing(siteID)
+		return HeadInfo{}, ErrHeadNotFound
+	case lastErr != nil:
+		return HeadInfo{}, lastErr
+	}
+	return HeadInfo{}, ErrHeadNotFound
+}
+
+// NextSeqBase returns the head the next update of siteID must chain to: the
+// local head, or the head peers report if that is newer. A zero HeadInfo
+// means the next update is the site's first. Peers that cannot be reached
+// within lookupTimeout are ignored.
+func (n *Node) NextSeqBase(ctx context.Context, siteID string, lookupTimeout time.Duration) (HeadInfo, error) {
+	var base HeadInfo
+	if has, err := n.Store.HasHead(siteID); err != nil {
+		return base, err
+	} else if has {
+		if base.Seq, base.HeadCID, err = n.Store.GetHead(siteID); err != nil {
+			return base, err
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, lookupTimeout)
+	defer cancel()
+	n.heads.invalidate(siteID)
+	if remote, err := n.LookupHead(ctx, siteID); err == nil && remote.Seq > base.Seq {
+		base = remote
+	}
+	return base, nil
+}
+
+// HeadCacheStats returns head cache counters.
+func (n *Node) HeadCacheStats() HeadCacheStats {
+	return n.heads.snapshot()
+}
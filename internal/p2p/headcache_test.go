@@ -0,0 +1,101 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"alxnet/internal/core"
+
+	peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestHeadCache(t *testing.T) {
+	now := time.Unix(1000, 0)
+	c := &headCache{now: func() time.Time { return now }}
+
+	if _, _, ok := c.get("a"); ok {
+		t.Fatal("get() hit on an empty cache")
+	}
+	c.put("a", HeadInfo{Seq: 2, HeadCID: "h2"})
+	c.put("a", HeadInfo{Seq: 1, HeadCID: "h1"}) // older answer from another peer
+	if h, found, ok := c.get("a"); !ok || !found || h.Seq != 2 {
+		t.Errorf("get() = %+v, %v, %v; want seq 2", h, found, ok)
+	}
+	c.putMissing("b")
+	if _, found, ok := c.get("b"); !ok || found {
+		t.Errorf("get() of a missing site = found %v, ok %v; want cached miss", found, ok)
+	}
+
+	now = now.Add(HeadNegativeCacheTTL)
+	if _, _, ok := c.get("b"); ok {
+		t.Error("negative entry outlived HeadNegativeCacheTTL")
+	}
+	if _, _, ok := c.get("a"); !ok {
+		t.Error("positive entry expired before HeadCacheTTL")
+	}
+	now = now.Add(HeadCacheTTL)
+	if _, _, ok := c.get("a"); ok {
+		t.Error("positive entry outlived HeadCacheTTL")
+	}
+	c.put("a", HeadInfo{Seq: 1}) // stale entries do not block older seqs
+	if h, _, _ := c.get("a"); h.Seq != 1 {
+		t.Errorf("get() after expiry = seq %d, want 1", h.Seq)
+	}
+
+	for i := 0; i < maxHeadCacheEntries+10; i++ {
+		c.putMissing(fmt.Sprintf("site-%d", i))
+	}
+	if st := c.snapshot(); st.Entries > maxHeadCacheEntries {
+		t.Errorf("cache holds %d entries, limit %d", st.Entries, maxHeadCacheEntries)
+	}
+}
+
+func TestLookupHead(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	holder := newTestNode(t, ctx)
+	browser := newTestNode(t, ctx)
+	if err := browser.Host.Connect(ctx, peer.AddrInfo{ID: holder.Host.ID(), Addrs: holder.Host.Addrs()}); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	const siteID = "aa00000000000000000000000000000000000000000000000000000000000000"
+	const contentCID = "cc00000000000000000000000000000000000000000000000000000000000000"
+	rec, err := core.CanonicalMarshal(&core.UpdateRecord{Version: core.RecordV1, Seq: 4, ContentCID: contentCID, TS: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	headCID := core.CIDForBytes(rec)
+	if err := holder.Store.PutRecord(headCID, rec); err != nil {
+		t.Fatal(err)
+	}
+	if err := holder.Store.PutHead(siteID, 4, headCID); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		head, err := browser.LookupHead(ctx, siteID)
+		if err != nil {
+			t.Fatalf("LookupHead() error = %v", err)
+		}
+		if head.Seq != 4 || head.HeadCID != headCID || head.ContentCID != contentCID || head.Peer != holder.Host.ID() {
+			t.Errorf("LookupHead() = %+v", head)
+		}
+	}
+
+	const unknown = "bb00000000000000000000000000000000000000000000000000000000000000"
+	for i := 0; i < 2; i++ {
+		if _, err := browser.LookupHead(ctx, unknown); !errors.Is(err, ErrHeadNotFound) {
+			t.Fatalf("LookupHead() of an unknown site error = %v, want ErrHeadNotFound", err)
+		}
+	}
+
+	st := browser.HeadCacheStats()
+	if st.Hits != 1 || st.NegativeHits != 1 || st.Misses != 2 {
+		t.Errorf("stats = %+v, want 1 hit, 1 negative hit, 2 misses", st)
+	}
+}
@@ -0,0 +1,454 @@
+package p2p
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"alxnet/internal/core"
+	bncrypto "alxnet/internal/crypto"
+
+	"github.com/fxamacker/cbor/v2"
+	network "github.com/libp2p/go-libp2p/core/network"
+	peer "github.com/libp2p/go-libp2p/core/peer"
+	protocol "github.com/libp2p/go-libp2p/core/protocol"
+	"go.uber.org/zap"
+)
+
+// HostingProto carries delegated hosting requests, their acceptance and
+// periodic availability reports between a publisher and a hosting node.
+const HostingProto protocol.ID = "/alxnet/hosting/1.0.0"
+
+// Hosting agreement directions in the store
+const (
+	HostingIncoming = "in"  // sites this node hosts for others
+	HostingOutgoing = "out" // requests sent by this node's publishers
+)
+
+// Hosting agreement states
+const (
+	HostingPending  = "pending"
+	HostingAccepted = "accepted"
+	HostingRejected = "rejected"
+	HostingExpired  = "expired"
+)
+
+// MaxPendingHosting bounds unanswered incoming requests so that a peer
+// cannot fill the store with them.
+const MaxPendingHosting = 100
+
+const maxHostingMessage = 4 * 1024
+
+var hostingMsgDecMode, _ = cbor.DecOptions{MaxArrayElements: 16, MaxMapPairs: 16}.DecMode()
+
+// HostingReport is the availability a hosting node last reported.
+type HostingReport struct {
+	HasHead bool      `cbor:"hh" json:"has_head"`
+	Seq     uint64    `cbor:"s" json:"seq"`
+	HeadCID string    `cbor:"h" json:"head_cid"`
+	At      time.Time `cbor:"at" json:"at"`
+}
+
+// HostingAgreement is one side's view of a delegated hosting arrangement.
+type HostingAgreement struct {
+	ID         string         `cbor:"id" json:"id"` // CID of the canonical request
+	SiteID     string         `cbor:"site" json:"site_id"`
+	Peer       string         `cbor:"peer" json:"peer"` // the other party
+	Request    []byte         `cbor:"req" json:"-"`     // canonical HostingRequest
+	Expires    time.Time      `cbor:"exp" json:"expires"`
+	Status     string         `cbor:"st" json:"status"`
+	Created    time.Time      `cbor:"c" json:"created"`
+	Updated    time.Time      `cbor:"u" json:"updated"`
+	LastReport *HostingReport `cbor:"rep,omitempty" json:"last_report,omitempty"`
+	// LastProof checks the last report of an outgoing agreement: the
+	// publisher challenges the host for a random file of the site.
+	LastProof *StorageProof `cbor:"pr,omitempty" json:"last_proof,omitempty"`
+}
+
+type hostingMsg struct {
+	Type    string         `cbor:"t"` // request, accepted, rejected, report
+	ID      string         `cbor:"i,omitempty"`
+	Request []byte         `cbor:"r,omitempty"`
+	Report  *HostingReport `cbor:"rep,omitempty"`
+}
+
+type hostingResp struct {
+	Ok    bool   `cbor:"ok"`
+	Error string `cbor:"e,omitempty"`
+}
+
+// BuildHostingRequest signs a request asking hostPeer to host the site of
+// sitePriv for the given duration.
+func BuildHostingRequest(sitePriv ed25519.PrivateKey, hostPeer peer.ID, duration time.Duration) (*core.HostingRequest, error) {
+	if duration <= 0 || duration > core.MaxHostingDuration {
+		return nil, fmt.Errorf("hosting duration out of range: %v", duration)
+	}
+	ts := time.Now().Unix()
+	req := &core.HostingRequest{
+		Version:  "v1",
+		SitePub:  sitePriv.Public().(ed25519.PublicKey),
+		HostPeer: hostPeer.String(),
+		Expires:  ts + int64(duration/time.Second),
+		TS:       ts,
+	}
+	req.Sig = ed25519.Sign(sitePriv, bncrypto.PreimageHostingRequest(req.SitePub, req.HostPeer, req.Expires, req.TS))
+	return req, nil
+}
+
+// verifyHostingRequest checks structure and signature of a canonical request.
+func verifyHostingRequest(b []byte) (*core.HostingRequest, error) {
+	var req core.HostingRequest
+	if err := cborUnmarshal(b, &req); err != nil {
+		return nil, err
+	}
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	pre := bncrypto.PreimageHostingRequest(req.SitePub, req.HostPeer, req.Expires, req.TS)
+	if !ed25519.Verify(ed25519.PublicKey(req.SitePub), pre, req.Sig) {
+		return nil, errors.New("invalid hosting request signature")
+	}
+	return &req, nil
+}
+
+// RequestHosting sends a signed request to the hosting node at host and
+// records it as an outgoing agreement.
+func (n *Node) RequestHosting(ctx context.Context, host peer.AddrInfo, req *core.HostingRequest) (*HostingAgreement, error) {
+	if req.HostPeer != host.ID.String() {
+		return nil, errors.New("request is for a different host")
+	}
+	b, err := core.CanonicalMarshalHostingRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := verifyHostingRequest(b); err != nil {
+		return nil, err
+	}
+
+	if err := n.Host.Connect(ctx, host); err != nil {
+		return nil, err
+	}
+	if err := n.sendHosting(ctx, host.ID, hostingMsg{Type: "request", Request: b}); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	a := &HostingAgreement{
+		ID:      core.CIDForBytes(b),
+		SiteID:  core.SiteIDFromPub(req.SitePub),
+		Peer:    host.ID.String(),
+		Request: b,
+		Expires: time.Unix(req.Expires, 0),
+		Status:  HostingPending,
+		Created: now,
+		Updated: now,
+	}
+	return a, n.putAgreement(HostingOutgoing, a)
+}
+
+// RespondHosting accepts or rejects a pending incoming request. Accepting
+// pins the site and asks the publisher to re-announce it.
+func (n *Node) RespondHosting(ctx context.Context, id string, accept bool) (*HostingAgreement, error) {
+	a, err := n.getAgreement(HostingIncoming, id)
+	if err != nil {
+		return nil, err
+	}
+	if a.Status != HostingPending {
+		return nil, fmt.Errorf("agreement is %s", a.Status)
+	}
+	if time.Now().After(a.Expires) {
+		a.Status = HostingExpired
+		a.Updated = time.Now()
+		_ = n.putAgreement(HostingIncoming, a)
+		return nil, errors.New("request has expired")
+	}
+
+	msgType := HostingRejected
+	a.Status = HostingRejected
+	if accept {
+		if err := n.Store.PinSite(a.SiteID); err != nil {
+			return nil, err
+		}
+		msgType = HostingAccepted
+		a.Status = HostingAccepted
+	}
+	a.Updated = time.Now()
+	if err := n.putAgreement(HostingIncoming, a); err != nil {
+		return nil, err
+	}
+
+	publisher, err := peer.Decode(a.Peer)
+	if err == nil {
+		err = n.sendHosting(ctx, publisher, hostingMsg{Type: msgType, ID: a.ID})
+	}
+	if err != nil {
+		n.logger.Warn("failed to notify publisher", zap.String("agreement", Short(a.ID)), zap.Error(err))
+	}
+	return a, nil
+}
+
+// HostingAgreements lists agreements in one direction.
+func (n *Node) HostingAgreements(direction string) ([]*HostingAgreement, error) {
+	blobs, err := n.Store.ListHostingAgreements(direction)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*HostingAgreement, 0, len(blobs))
+	for _, b := range blobs {
+		var a HostingAgreement
+		if err := core.UnmarshalCBOR(b, &a); err != nil {
+			continue
+		}
+		out = append(out, &a)
+	}
+	return out, nil
+}
+
+// maintainHosting expires lapsed incoming agreements and reports the
+// availability of accepted ones to their publishers.
+func (n *Node) maintainHosting(ctx context.Context) {
+	agreements, err := n.HostingAgreements(HostingIncoming)
+	if err != nil {
+		n.logger.Warn("failed to list hosting agreements", zap.Error(err))
+		return
+	}
+
+	active := make(map[string]bool)
+	var expired []*HostingAgreement
+	for _, a := range agreements {
+		if a.Status != HostingAccepted {
+			continue
+		}
+		if time.Now().After(a.Expires) {
+			expired = append(expired, a)
+			continue
+		}
+		active[a.SiteID] = true
+
+		report := n.hostingReport(a.SiteID)
+		a.LastReport = report
+		a.Updated = time.Now()
+		_ = n.putAgreement(HostingIncoming, a)
+
+		publisher, err := peer.Decode(a.Peer)
+		if err != nil {
+			continue
+		}
+		if err := n.sendHosting(ctx, publisher, hostingMsg{Type: "report", ID: a.ID, Report: report}); err != nil {
+			n.logger.Debug("hosting report failed", zap.String("agreement", Short(a.ID)), zap.Error(err))
+		}
+	}
+
+	for _, a := range expired {
+		a.Status = HostingExpired
+		a.Updated = time.Now()
+		_ = n.putAgreement(HostingIncoming, a)
+		// Another active agreement may still cover the same site
+		if !active[a.SiteID] {
+			_ = n.Store.UnpinSite(a.SiteID)
+		}
+	}
+}
+
+func (n *Node) hostingReport(siteID string) *HostingReport {
+	report := &HostingReport{At: time.Now().UTC()}
+	if has, _ := n.Store.HasHead(siteID); has {
+		if seq, headCID, err := n.Store.GetHead(siteID); err == nil {
+			report.HasHead = true
+			report.Seq = seq
+			report.HeadCID = headCID
+		}
+	}
+	return report
+}
+
+func (n *Node) sendHosting(ctx context.Context, p peer.ID, msg hostingMsg) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	s, err := n.Host.NewStream(ctx, p, HostingProto)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	if err := s.SetDeadline(time.Now().Add(10 * time.Second)); err != nil {
+		return err
+	}
+
+	b, err := cborMarshal(msg)
+	if err != nil {
+		return err
+	}
+	if _, err := s.Write(b); err != nil {
+		return err
+	}
+	if err := s.CloseWrite(); err != nil {
+		return err
+	}
+
+	var resp hostingResp
+	if err := core.NewCBORDecoder(io.LimitReader(s, maxHostingMessage)).Decode(&resp); err != nil {
+		return err
+	}
+	if !resp.Ok {
+		return fmt.Errorf("peer refused: %s", resp.Error)
+	}
+	return nil
+}
+
+func (n *Node) handleHostingStream(s network.Stream) {
+	defer s.Close()
+	if err := s.SetDeadline(time.Now().Add(10 * time.Second)); err != nil {
+		return
+	}
+
+	remote := s.Conn().RemotePeer()
+	var msg hostingMsg
+	if err := hostingMsgDecMode.NewDecoder(io.LimitReader(s, maxHostingMessage)).Decode(&msg); err != nil {
+		log.Printf("handleHostingStream: bad message from %s: %v", remote, err)
+		return
+	}
+
+	var resp hostingResp
+	if err := n.handleHostingMsg(remote, msg); err != nil {
+		resp.Error = err.Error()
+	} else {
+		resp.Ok = true
+	}
+	b, _ := cborMarshal(resp)
+	if _, err := s.Write(b); err != nil {
+		log.Printf("handleHostingStream: write failed: %v", err)
+	}
+}
+
+func (n *Node) handleHostingMsg(remote peer.ID, msg hostingMsg) error {
+	switch msg.Type {
+	case "request":
+		return n.receiveHostingRequest(remote, msg.Request)
+	case HostingAccepted, HostingRejected, "report":
+		a, err := n.getAgreement(HostingOutgoing, msg.ID)
+		if err != nil {
+			return errors.New("unknown agreement")
+		}
+		// Only the node the request was addressed to may update it
+		if a.Peer != remote.String() {
+			return errors.New("not the hosting node for this agreement")
+		}
+		switch msg.Type {
+		case "report":
+			if msg.Report == nil {
+				return errors.New("missing report")
+			}
+			a.LastReport = msg.Report
+		default:
+			a.Status = msg.Type
+		}
+		a.Updated = time.Now()
+		if err := n.putAgreement(HostingOutgoing, a); err != nil {
+			return err
+		}
+		if msg.Type == "report" && msg.Report.HasHead && a.Status == HostingAccepted {
+			go n.verifyHostingReport(remote, a.ID)
+		}
+		if msg.Type == HostingAccepted {
+			// Push the current head so the new host can start serving it
+			go func() {
+				if err := n.announceHead(context.Background(), a.SiteID); err != nil {
+					n.logger.Debug("announce after acceptance failed", zap.Error(err))
+				}
+			}()
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown message type: %s", msg.Type)
+	}
+}
+
+// verifyHostingReport challenges the host of an outgoing agreement that
+// reported holding the site, and keeps the outcome with the agreement.
+func (n *Node) verifyHostingReport(host peer.ID, id string) {
+	a, err := n.getAgreement(HostingOutgoing, id)
+	if err != nil {
+		return
+	}
+	proof, err := n.ChallengeSite(context.Background(), host, a.SiteID)
+	if err != nil {
+		n.logger.Debug("hosting storage challenge failed", zap.String("agreement", Short(id)), zap.Error(err))
+		return
+	}
+	// Re-read: a report or status change may have arrived meanwhile
+	if a, err = n.getAgreement(HostingOutgoing, id); err != nil {
+		return
+	}
+	a.LastProof = proof
+	_ = n.putAgreement(HostingOutgoing, a)
+}
+
+func (n *Node) receiveHostingRequest(remote peer.ID, b []byte) error {
+	req, err := verifyHostingRequest(b)
+	if err != nil {
+		return err
+	}
+	if req.HostPeer != n.Host.ID().String() {
+		return errors.New("request is addressed to another node")
+	}
+	if time.Now().Unix() >= req.Expires {
+		return errors.New("request has expired")
+	}
+
+	id := core.CIDForBytes(b)
+	if _, err := n.getAgreement(HostingIncoming, id); err == nil {
+		return nil // already known
+	}
+	existing, err := n.HostingAgreements(HostingIncoming)
+	if err != nil {
+		return err
+	}
+	pending := 0
+	for _, a := range existing {
+		if a.Status == HostingPending {
+			pending++
+		}
+	}
+	if pending >= MaxPendingHosting {
+		return errors.New("too many pending hosting requests")
+	}
+
+	now := time.Now()
+	a := &HostingAgreement{
+		ID:      id,
+		SiteID:  core.SiteIDFromPub(req.SitePub),
+		Peer:    remote.String(),
+		Request: b,
+		Expires: time.Unix(req.Expires, 0),
+		Status:  HostingPending,
+		Created: now,
+		Updated: now,
+	}
+	n.logger.Info("received hosting request", zap.String("site", Short(a.SiteID)), zap.String("from", remote.String()))
+	return n.putAgreement(HostingIncoming, a)
+}
+
+func (n *Node) putAgreement(direction string, a *HostingAgreement) error {
+	b, err := cborMarshal(a)
+	if err != nil {
+		return err
+	}
+	return n.Store.PutHostingAgreement(direction, a.ID, b)
+}
+
+func (n *Node) getAgreement(direction, id string) (*HostingAgreement, error) {
+	b, err := n.Store.GetHostingAgreement(direction, id)
+	if err != nil {
+		return nil, err
+	}
+	var a HostingAgreement
+	if err := core.UnmarshalCBOR(b, &a); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
@@ -0,0 +1,143 @@
+package p2p
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"alxnet/internal/core"
+
+	peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestHostingAgreementFlow(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	publisher := newTestNode(t, ctx)
+	host := newTestNode(t, ctx)
+	hostInfo := peer.AddrInfo{ID: host.Host.ID(), Addrs: host.Host.Addrs()}
+
+	_, sitePriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	siteID := core.SiteIDFromPub(sitePriv.Public().(ed25519.PublicKey))
+
+	req, err := BuildHostingRequest(sitePriv, host.Host.ID(), 24*time.Hour)
+	if err != nil {
+		t.Fatalf("BuildHostingRequest() error = %v", err)
+	}
+	sent, err := publisher.RequestHosting(ctx, hostInfo, req)
+	if err != nil {
+		t.Fatalf("RequestHosting() error = %v", err)
+	}
+
+	incoming, err := host.HostingAgreements(HostingIncoming)
+	if err != nil || len(incoming) != 1 {
+		t.Fatalf("incoming agreements = %d (%v), want 1", len(incoming), err)
+	}
+	if incoming[0].ID != sent.ID || incoming[0].SiteID != siteID || incoming[0].Status != HostingPending {
+		t.Fatalf("incoming agreement = %+v, want pending %s", incoming[0], Short(sent.ID))
+	}
+
+	if _, err := host.RespondHosting(ctx, sent.ID, true); err != nil {
+		t.Fatalf("RespondHosting() error = %v", err)
+	}
+	if !host.Store.IsPinned(siteID) {
+		t.Error("accepting a hosting request should pin the site")
+	}
+	if _, err := host.RespondHosting(ctx, sent.ID, false); err == nil {
+		t.Error("RespondHosting() on an accepted agreement should fail")
+	}
+
+	out, err := publisher.getAgreement(HostingOutgoing, sent.ID)
+	if err != nil {
+		t.Fatalf("getAgreement: %v", err)
+	}
+	if out.Status != HostingAccepted {
+		t.Errorf("outgoing status = %s, want %s", out.Status, HostingAccepted)
+	}
+
+	const headCID = "bb00000000000000000000000000000000000000000000000000000000000000"
+	if err := host.Store.PutHead(siteID, 3, headCID); err != nil {
+		t.Fatalf("PutHead: %v", err)
+	}
+	host.maintainHosting(ctx)
+
+	out, err = publisher.getAgreement(HostingOutgoing, sent.ID)
+	if err != nil {
+		t.Fatalf("getAgreement: %v", err)
+	}
+	if out.LastReport == nil || !out.LastReport.HasHead || out.LastReport.Seq != 3 || out.LastReport.HeadCID != headCID {
+		t.Errorf("last report = %+v, want head seq 3", out.LastReport)
+	}
+}
+
+func TestHostingRequestRejected(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	publisher := newTestNode(t, ctx)
+	host := newTestNode(t, ctx)
+	other := newTestNode(t, ctx)
+	hostInfo := peer.AddrInfo{ID: host.Host.ID(), Addrs: host.Host.Addrs()}
+
+	_, sitePriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	// A request addressed to another node must not be accepted.
+	misdirected, err := BuildHostingRequest(sitePriv, other.Host.ID(), time.Hour)
+	if err != nil {
+		t.Fatalf("BuildHostingRequest() error = %v", err)
+	}
+	if _, err := publisher.RequestHosting(ctx, hostInfo, misdirected); err == nil {
+		t.Error("RequestHosting() for a different host should fail")
+	}
+
+	// A tampered request must fail signature verification.
+	tampered, err := BuildHostingRequest(sitePriv, host.Host.ID(), time.Hour)
+	if err != nil {
+		t.Fatalf("BuildHostingRequest() error = %v", err)
+	}
+	tampered.Expires += 3600
+	if _, err := publisher.RequestHosting(ctx, hostInfo, tampered); err == nil {
+		t.Error("RequestHosting() with a bad signature should fail")
+	}
+
+	if _, err := BuildHostingRequest(sitePriv, host.Host.ID(), core.MaxHostingDuration+time.Hour); err == nil {
+		t.Error("BuildHostingRequest() beyond the maximum duration should fail")
+	}
+
+	req, err := BuildHostingRequest(sitePriv, host.Host.ID(), time.Hour)
+	if err != nil {
+		t.Fatalf("BuildHostingRequest() error = %v", err)
+	}
+	sent, err := publisher.RequestHosting(ctx, hostInfo, req)
+	if err != nil {
+		t.Fatalf("RequestHosting() error = %v", err)
+	}
+	if _, err := host.RespondHosting(ctx, sent.ID, false); err != nil {
+		t.Fatalf("RespondHosting() error = %v", err)
+	}
+	siteID := core.SiteIDFromPub(req.SitePub)
+	if host.Store.IsPinned(siteID) {
+		t.Error("rejecting a hosting request should not pin the site")
+	}
+	out, err := publisher.getAgreement(HostingOutgoing, sent.ID)
+	if err != nil {
+		t.Fatalf("getAgreement: %v", err)
+	}
+	if out.Status != HostingRejected {
+		t.Errorf("outgoing status = %s, want %s", out.Status, HostingRejected)
+	}
+
+	// Status updates from a third party are ignored.
+	if err := publisher.handleHostingMsg(other.Host.ID(), hostingMsg{Type: HostingAccepted, ID: sent.ID}); err == nil {
+		t.Error("status update from a node other than the host should fail")
+	}
+}
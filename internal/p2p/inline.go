@@ -0,0 +1,60 @@
+package p2p
+
+import (
+	"context"
+	"time"
+
+	peer "github.com/libp2p/go-libp2p/core/peer"
+	"go.uber.org/zap"
+)
+
+// Updates carry their content inline only up to NodeConfig.GossipInlineLimit
+// bytes, so messages on the updates topic stay small however large a site's
+// files are. Larger content is announced by its CID in the record alone,
+// and nodes fetch it through the want list: content of a pinned site as
+// soon as its update is applied, any other only when it is first read.
+
+// DefaultGossipInlineLimit is the default for NodeConfig.GossipInlineLimit.
+const DefaultGossipInlineLimit = 64 * 1024
+
+// deferredFetchTimeout bounds fetching the content of a pinned site's
+// update that came without it.
+const deferredFetchTimeout = 2 * time.Minute
+
+// gossipInlineLimit returns the largest content sent inline with an update.
+func (n *Node) gossipInlineLimit() int {
+	if n.config == nil || n.config.GossipInlineLimit <= 0 {
+		return DefaultGossipInlineLimit
+	}
+	return n.config.GossipInlineLimit
+}
+
+// inlineGossip drops the plain content of env if it is over limit bytes.
+func inlineGossip(env *GossipUpdate, limit int) {
+	if env.Encoding == "" && len(env.Content) > limit {
+		env.Content = nil
+	}
+}
+
+// fetchDeferred fetches in the background the content cid of an update of
+// siteID that came without it, asking origin first, if the site is pinned
+// and the content not stored.
+func (n *Node) fetchDeferred(ctx context.Context, siteID, cid string, origin peer.ID) {
+	if cid == "" || !n.Store.IsPinned(siteID) {
+		return
+	}
+	if has, _ := n.Store.HasContent(cid); has {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(ctx, deferredFetchTimeout)
+		defer cancel()
+		data, err := n.Want(ctx, cid, origin, PriorityBackground)
+		if err == nil {
+			err = n.Store.PutContent(cid, data)
+		}
+		if err != nil {
+			n.logger.Debug("deferred content fetch failed", zap.String("site", Short(siteID)), zap.String("cid", Short(cid)), zap.Error(err))
+		}
+	}()
+}
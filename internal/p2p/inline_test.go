@@ -0,0 +1,75 @@
+package p2p
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"testing"
+	"time"
+
+	"alxnet/internal/core"
+
+	peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestInlineGossip(t *testing.T) {
+	content := make([]byte, 100)
+	env := GossipUpdate{Record: []byte{1}, Content: content}
+	if inlineGossip(&env, 100); len(env.Content) != 100 {
+		t.Error("content at the limit was dropped")
+	}
+	if inlineGossip(&env, 99); env.Content != nil {
+		t.Error("content over the limit was kept")
+	}
+	// Relayed compressed content is left alone
+	env = GossipUpdate{Record: []byte{1}, Content: content, Encoding: GossipEncodingZstd}
+	if inlineGossip(&env, 10); env.Content == nil {
+		t.Error("compressed content was dropped")
+	}
+}
+
+func TestFetchDeferredContent(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	seeder, pinner := newTestNode(t, ctx), newTestNode(t, ctx)
+	for _, n := range []*Node{seeder, pinner} {
+		if err := n.Start(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, sitePriv, _ := ed25519.GenerateKey(nil)
+	siteID := core.SiteIDFromPub(sitePriv.Public().(ed25519.PublicKey))
+	content := bytes.Repeat([]byte("x"), DefaultGossipInlineLimit+1)
+	env, _, err := SignUpdate(sitePriv, content, 1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rec core.UpdateRecord
+	cborUnmarshal(env.Record, &rec)
+	if err := seeder.ValidateAndApply(&rec, content); err != nil {
+		t.Fatal(err)
+	}
+	if err := pinner.Store.PinSite(siteID); err != nil {
+		t.Fatal(err)
+	}
+	if err := pinner.Host.Connect(ctx, peer.AddrInfo{ID: seeder.Host.ID(), Addrs: seeder.Host.Addrs()}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The update arrives without its content, as the seeder would send it
+	m := GossipMessage{Record: &gossipUpdate{rec: &rec}, From: seeder.Host.ID(), Origin: seeder.Host.ID()}
+	if err := applyUpdate(ctx, pinner, m); err != nil {
+		t.Fatal(err)
+	}
+	for ctx.Err() == nil {
+		if has, _ := pinner.Store.HasContent(rec.ContentCID); has {
+			if got, err := pinner.Store.GetContent(rec.ContentCID); err != nil || !bytes.Equal(got, content) {
+				t.Fatalf("fetched content: %d bytes, %v", len(got), err)
+			}
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatal("content of the pinned site was not fetched")
+}
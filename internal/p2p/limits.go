@@ -0,0 +1,140 @@
+package p2p
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	peer "github.com/libp2p/go-libp2p/core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+	"go.uber.org/zap"
+)
+
+// Limits a running node can change without a restart.
+
+// allow records a request from key at now and reports whether key is
+// within the limit for the current window.
+func (rl *RateLimiter) allow(key string, now time.Time) bool {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if rl.disabled {
+		return true
+	}
+	cutoff := now.Add(-rl.window)
+	recent := rl.requests[key][:0]
+	for _, t := range rl.requests[key] {
+		if t.After(cutoff) {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) >= rl.maxRequests {
+		rl.requests[key] = recent
+		return false
+	}
+	rl.requests[key] = append(recent, now)
+	return true
+}
+
+// prune forgets keys with no requests in the current window.
+func (rl *RateLimiter) prune(now time.Time) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	cutoff := now.Add(-rl.window)
+	for key, times := range rl.requests {
+		if len(times) == 0 || !times[len(times)-1].After(cutoff) {
+			delete(rl.requests, key)
+		}
+	}
+}
+
+// SetRateLimit changes how many browse requests a peer may make per
+// window; enabled false lifts the limit.
+func (n *Node) SetRateLimit(maxRequests int, window time.Duration, enabled bool) error {
+	if maxRequests <= 0 || window <= 0 {
+		return fmt.Errorf("rate limit must allow at least one request per positive window")
+	}
+	rl := n.rateLimiter
+	rl.mu.Lock()
+	rl.maxRequests, rl.window, rl.disabled = maxRequests, window, !enabled
+	rl.mu.Unlock()
+	return nil
+}
+
+// SetMaxPeers changes how many peers the node accepts. Peers already
+// connected stay; new connections over the limit are closed.
+func (n *Node) SetMaxPeers(max int) error {
+	if max <= 0 {
+		return fmt.Errorf("max peers must be positive")
+	}
+	n.maxPeers.Store(int64(max))
+	return nil
+}
+
+// overPeerLimit reports whether a new connection from id takes the node
+// over its peer limit. Bootstrap peers are always accepted.
+func (n *Node) overPeerLimit(net network.Network, id peer.ID) bool {
+	if int64(len(net.Peers())) <= n.maxPeers.Load() {
+		return false
+	}
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	for _, m := range n.BootstrapAddrs {
+		if ai, err := peer.AddrInfoFromP2pAddr(m); err == nil && ai.ID == id {
+			return false
+		}
+	}
+	return true
+}
+
+// SetBootstrapPeers replaces the bootstrap list and dials peers that were
+// not on it before. It returns how many addresses are in use; invalid ones
+// are logged and skipped.
+func (n *Node) SetBootstrapPeers(ctx context.Context, addrs []string) int {
+	var maddrs []ma.Multiaddr
+	for _, s := range addrs {
+		if s == "" {
+			continue
+		}
+		m, err := ma.NewMultiaddr(s)
+		if err != nil {
+			n.logger.Warn("invalid bootstrap address", zap.String("addr", s), zap.Error(err))
+			continue
+		}
+		maddrs = append(maddrs, m)
+	}
+
+	n.mu.Lock()
+	old := n.BootstrapAddrs
+	n.BootstrapAddrs = maddrs
+	n.mu.Unlock()
+
+	var added []ma.Multiaddr
+	for _, m := range maddrs {
+		known := false
+		for _, o := range old {
+			if o.Equal(m) {
+				known = true
+				break
+			}
+		}
+		if !known {
+			added = append(added, m)
+		}
+	}
+	n.connectBootstrap(ctx, added)
+	return len(maddrs)
+}
+
+// connectBootstrap dials addrs in the background.
+func (n *Node) connectBootstrap(ctx context.Context, addrs []ma.Multiaddr) {
+	for _, m := range addrs {
+		if ai, err := peer.AddrInfoFromP2pAddr(m); err == nil {
+			go func(info peer.AddrInfo) {
+				cctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+				defer cancel()
+				_ = n.Host.Connect(cctx, info)
+			}(*ai)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package p2p
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestRateLimiter(t *testing.T) {
+	rl := &RateLimiter{requests: make(map[string][]time.Time), maxRequests: 3, window: time.Minute}
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		if !rl.allow("a", now) {
+			t.Fatalf("request %d refused", i+1)
+		}
+	}
+	if rl.allow("a", now) {
+		t.Error("fourth request in the window allowed")
+	}
+	if !rl.allow("b", now) {
+		t.Error("limit shared between peers")
+	}
+	if !rl.allow("a", now.Add(time.Minute+time.Second)) {
+		t.Error("request after the window refused")
+	}
+
+	rl.prune(now.Add(3 * time.Minute))
+	if len(rl.requests) != 0 {
+		t.Errorf("prune kept %d idle peers", len(rl.requests))
+	}
+}
+
+func TestSetLimits(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	n := newTestNode(t, ctx)
+	now := time.Now()
+
+	if err := n.SetRateLimit(0, time.Minute, true); err == nil {
+		t.Error("SetRateLimit() accepted zero requests")
+	}
+	if err := n.SetRateLimit(1, time.Minute, true); err != nil {
+		t.Fatalf("SetRateLimit: %v", err)
+	}
+	if !n.rateLimiter.allow("p", now) || n.rateLimiter.allow("p", now) {
+		t.Error("new rate limit not applied")
+	}
+	if err := n.SetRateLimit(1, time.Minute, false); err != nil {
+		t.Fatalf("SetRateLimit: %v", err)
+	}
+	if !n.rateLimiter.allow("p", now) {
+		t.Error("disabled rate limit still refuses requests")
+	}
+
+	if err := n.SetMaxPeers(0); err == nil {
+		t.Error("SetMaxPeers() accepted zero")
+	}
+
+	other, third := newTestNode(t, ctx), newTestNode(t, ctx)
+	for _, peerNode := range []*Node{other, third} {
+		if err := n.Host.Connect(ctx, peer.AddrInfo{ID: peerNode.Host.ID(), Addrs: peerNode.Host.Addrs()}); err != nil {
+			t.Fatalf("Connect: %v", err)
+		}
+	}
+	addr := other.Host.Addrs()[0].String() + "/p2p/" + other.Host.ID().String()
+	if got := n.SetBootstrapPeers(ctx, []string{addr, "not-a-multiaddr", ""}); got != 1 {
+		t.Errorf("SetBootstrapPeers() = %d, want 1", got)
+	}
+	if err := n.SetMaxPeers(1); err != nil {
+		t.Fatalf("SetMaxPeers: %v", err)
+	}
+	if n.overPeerLimit(n.Host.Network(), other.Host.ID()) {
+		t.Error("bootstrap peer counted against the peer limit")
+	}
+	if !n.overPeerLimit(n.Host.Network(), third.Host.ID()) {
+		t.Error("peer over the limit accepted")
+	}
+}
@@ -0,0 +1,99 @@
+package p2p
+
+import (
+	"fmt"
+	"net"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// WildcardListenAddrs returns TCP listen addresses for every IPv4 and IPv6
+// address of the machine at port, 0 letting the system pick. Where IPv6 is
+// unavailable the node listens on IPv4 alone.
+func WildcardListenAddrs(port int) []string {
+	return []string{
+		fmt.Sprintf("/ip4/0.0.0.0/tcp/%d", port),
+		fmt.Sprintf("/ip6/::/tcp/%d", port),
+	}
+}
+
+// listenAddrs returns the addresses to listen on: listen and extra, less
+// duplicates. With ifaces named, wildcard hosts are narrowed to the
+// addresses of those network interfaces, link-local ones aside, and any
+// other host must be one of them.
+func listenAddrs(listen string, extra, ifaces []string) ([]string, error) {
+	var allowed []net.IP
+	for _, name := range ifaces {
+		ifc, err := net.InterfaceByName(name)
+		if err != nil {
+			return nil, fmt.Errorf("interface %q: %w", name, err)
+		}
+		addrs, err := ifc.Addrs()
+		if err != nil {
+			return nil, fmt.Errorf("interface %q: %w", name, err)
+		}
+		for _, a := range addrs {
+			if ipn, ok := a.(*net.IPNet); ok && !ipn.IP.IsLinkLocalUnicast() {
+				allowed = append(allowed, ipn.IP)
+			}
+		}
+	}
+
+	var out []string
+	seen := make(map[string]bool)
+	add := func(s string) {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	for _, s := range append([]string{listen}, extra...) {
+		if s == "" {
+			continue
+		}
+		a, err := ma.NewMultiaddr(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid listen address %q: %w", s, err)
+		}
+		if len(ifaces) == 0 {
+			add(a.String())
+			continue
+		}
+		first, rest := ma.SplitFirst(a)
+		code := first.Protocol().Code
+		if code != ma.P_IP4 && code != ma.P_IP6 {
+			return nil, fmt.Errorf("listen address %q has no IP to match against interfaces", s)
+		}
+		ip := net.ParseIP(first.Value())
+		if !ip.IsUnspecified() {
+			for _, ok := range allowed {
+				if ok.Equal(ip) {
+					add(a.String())
+					break
+				}
+			}
+			if !seen[a.String()] {
+				return nil, fmt.Errorf("listen address %q is not on interfaces %v", s, ifaces)
+			}
+			continue
+		}
+		for _, ok := range allowed {
+			if (ok.To4() != nil) != (code == ma.P_IP4) {
+				continue
+			}
+			host := "/ip6/"
+			if code == ma.P_IP4 {
+				host = "/ip4/"
+			}
+			narrowed := host + ok.String()
+			if rest != nil {
+				narrowed += rest.String()
+			}
+			add(narrowed)
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no listen address on interfaces %v", ifaces)
+	}
+	return out, nil
+}
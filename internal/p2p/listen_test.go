@@ -0,0 +1,52 @@
+package p2p
+
+import (
+	"net"
+	"slices"
+	"testing"
+)
+
+func TestListenAddrs(t *testing.T) {
+	got, err := listenAddrs("/ip4/0.0.0.0/tcp/4001", []string{"/ip6/::/tcp/4001", "/ip4/0.0.0.0/tcp/4001"}, nil)
+	if err != nil {
+		t.Fatalf("listenAddrs: %v", err)
+	}
+	if want := WildcardListenAddrs(4001); !slices.Equal(got, want) {
+		t.Errorf("listenAddrs = %v, want %v", got, want)
+	}
+	if _, err := listenAddrs("0.0.0.0:4001", nil, nil); err == nil {
+		t.Error("listenAddrs accepted a host:port")
+	}
+
+	// Narrowing to the loopback interface
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		t.Skip(err)
+	}
+	lo := ""
+	for _, ifc := range ifaces {
+		if ifc.Flags&net.FlagLoopback != 0 {
+			lo = ifc.Name
+			break
+		}
+	}
+	if lo == "" {
+		t.Skip("no loopback interface")
+	}
+	got, err = listenAddrs("/ip4/0.0.0.0/tcp/0", nil, []string{lo})
+	if err != nil {
+		t.Fatalf("listenAddrs on %s: %v", lo, err)
+	}
+	if !slices.Contains(got, "/ip4/127.0.0.1/tcp/0") || slices.Contains(got, "/ip4/0.0.0.0/tcp/0") {
+		t.Errorf("listenAddrs on %s = %v, want the loopback address instead of the wildcard", lo, got)
+	}
+	if _, err := listenAddrs("/ip4/127.0.0.1/tcp/0", nil, []string{lo}); err != nil {
+		t.Errorf("loopback address on %s rejected: %v", lo, err)
+	}
+	if _, err := listenAddrs("/ip4/192.0.2.1/tcp/0", nil, []string{lo}); err == nil {
+		t.Errorf("address outside %s accepted", lo)
+	}
+	if _, err := listenAddrs("/ip4/0.0.0.0/tcp/0", nil, []string{"no-such-if0"}); err == nil {
+		t.Error("unknown interface accepted")
+	}
+}
@@ -0,0 +1,240 @@
+package p2p
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"alxnet/internal/core"
+	bncrypto "alxnet/internal/crypto"
+)
+
+// GossipDomainOffer carries an offer of a site name to another site.
+type GossipDomainOffer struct {
+	Offer []byte // canonical CBOR of DomainOffer
+}
+
+// GossipDomainAccept carries an accepted offer along with the offer, so
+// that nodes which did not keep the offer can apply it.
+type GossipDomainAccept struct {
+	Offer  []byte // canonical CBOR of DomainOffer
+	Accept []byte // canonical CBOR of DomainAccept
+}
+
+// domainAcceptance is a decoded GossipDomainAccept.
+type domainAcceptance struct {
+	offer  *core.DomainOffer
+	accept *core.DomainAccept
+}
+
+func init() {
+	// Acceptances carry their offer, so they are tried before offers
+	RegisterRecordType(RecordType{Name: "domain-accept", Decode: decodeDomainAccept, Apply: applyDomainAccept})
+	RegisterRecordType(envelopedRecord("domain-offer", AuditDomain,
+		func(env *GossipDomainOffer) []byte { return env.Offer },
+		func(rec *core.DomainOffer, e *AuditEntry) { e.SiteID = core.SiteIDFromPub(rec.SellerPub) },
+		verifyDomainOffer, (*Node).handleDomainOffer))
+}
+
+func decodeDomainAccept(data []byte) (any, AuditEntry, error) {
+	var env GossipDomainAccept
+	if err := cborUnmarshal(data, &env); err != nil || len(env.Accept) == 0 {
+		return nil, AuditEntry{}, nil
+	}
+	e := AuditEntry{Kind: AuditDomain, RecordCID: core.CIDForBytes(env.Accept)}
+	d := &domainAcceptance{offer: new(core.DomainOffer), accept: new(core.DomainAccept)}
+	if err := cborUnmarshal(env.Offer, d.offer); err != nil {
+		return nil, e, fmt.Errorf("malformed offer: %w", err)
+	}
+	if err := cborUnmarshal(env.Accept, d.accept); err != nil {
+		return nil, e, fmt.Errorf("malformed record: %w", err)
+	}
+	// Keyed by seller, so a seller's offers and transfers apply in order
+	e.SiteID = core.SiteIDFromPub(d.offer.SellerPub)
+	if err := verifyDomainAccept(d.offer, d.accept); err != nil {
+		return nil, e, err
+	}
+	return d, e, nil
+}
+
+func applyDomainAccept(ctx context.Context, n *Node, m GossipMessage) error {
+	d := m.Record.(*domainAcceptance)
+	return n.handleDomainAccept(d.offer, d.accept)
+}
+
+// ErrOfferNotKept is returned for offers of names this node does not have
+// registered to the seller, between sites it does not store; other nodes'
+// offers are not kept.
+var ErrOfferNotKept = errors.New("domain offer not relevant to this node")
+
+// BuildDomainOffer signs an offer of domain to the site buyer with the key
+// of the site holding it, open until expires.
+func BuildDomainOffer(sellerPriv ed25519.PrivateKey, domain, buyer, note string, expires time.Time) (*core.DomainOffer, error) {
+	rec := &core.DomainOffer{
+		Version:   "v1",
+		Domain:    domain,
+		SellerPub: sellerPriv.Public().(ed25519.PublicKey),
+		Buyer:     buyer,
+		Note:      note,
+		Expires:   expires.Unix(),
+		TS:        time.Now().Unix(),
+	}
+	b, err := core.CanonicalMarshalDomainOfferNoSig(rec)
+	if err != nil {
+		return nil, err
+	}
+	rec.Sig = ed25519.Sign(sellerPriv, bncrypto.PreimageDomainOffer(b))
+	return rec, rec.Validate()
+}
+
+// BuildDomainAccept signs the acceptance of the offer stored as offerCID
+// with the buying site's key.
+func BuildDomainAccept(buyerPriv ed25519.PrivateKey, offerCID string) (*core.DomainAccept, error) {
+	rec := &core.DomainAccept{
+		Version:  "v1",
+		OfferCID: offerCID,
+		BuyerPub: buyerPriv.Public().(ed25519.PublicKey),
+		TS:       time.Now().Unix(),
+	}
+	b, err := core.CanonicalMarshalDomainAcceptNoSig(rec)
+	if err != nil {
+		return nil, err
+	}
+	rec.Sig = ed25519.Sign(buyerPriv, bncrypto.PreimageDomainAccept(b))
+	return rec, rec.Validate()
+}
+
+// verifyDomainOffer checks structure and signature of a domain offer.
+func verifyDomainOffer(rec *core.DomainOffer) error {
+	if err := rec.Validate(); err != nil {
+		return err
+	}
+	b, err := core.CanonicalMarshalDomainOfferNoSig(rec)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(ed25519.PublicKey(rec.SellerPub), bncrypto.PreimageDomainOffer(b), rec.Sig) {
+		return errors.New("invalid domain offer signature")
+	}
+	return nil
+}
+
+// verifyDomainAccept checks an acceptance against the offer it takes up:
+// both signatures, that it names the offer, that the offer's buyer signed
+// it before the offer expired, and that the offer has not long expired.
+func verifyDomainAccept(offer *core.DomainOffer, accept *core.DomainAccept) error {
+	if err := verifyDomainOffer(offer); err != nil {
+		return err
+	}
+	if err := accept.Validate(); err != nil {
+		return err
+	}
+	if accept.OfferCID != core.CIDForBytes(mustMarshal(offer)) {
+		return errors.New("acceptance does not name the offer")
+	}
+	if core.SiteIDFromPub(accept.BuyerPub) != offer.Buyer {
+		return errors.New("acceptance not signed by the offer's buyer")
+	}
+	if accept.TS < offer.TS || accept.TS > offer.Expires {
+		return errors.New("offer was not open when accepted")
+	}
+	// Old acceptances replayed to nodes that have never seen them are void
+	if time.Now().Add(-core.MaxClockSkew()).Unix() > offer.Expires {
+		return errors.New("offer has expired")
+	}
+	b, err := core.CanonicalMarshalDomainAcceptNoSig(accept)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(ed25519.PublicKey(accept.BuyerPub), bncrypto.PreimageDomainAccept(b), accept.Sig) {
+		return errors.New("invalid domain acceptance signature")
+	}
+	return nil
+}
+
+// ApplyDomainOffer verifies and stores an offer. Offers are kept if the
+// name is registered to the seller here or if this node stores the seller
+// or the buyer, so both sides can see them.
+func (n *Node) ApplyDomainOffer(rec *core.DomainOffer) (string, error) {
+	if err := verifyDomainOffer(rec); err != nil {
+		return "", err
+	}
+	if time.Now().Unix() > rec.Expires {
+		return "", errors.New("offer has expired")
+	}
+	seller := core.SiteIDFromPub(rec.SellerPub)
+	owner, err := n.Store.ResolveDomain(rec.Domain)
+	switch {
+	case err == nil && owner != seller:
+		return "", ErrOfferNotKept
+	case err != nil && !n.storesSite(seller) && !n.storesSite(rec.Buyer):
+		return "", ErrOfferNotKept
+	}
+	b := mustMarshal(rec)
+	cid := core.CIDForBytes(b)
+	return cid, n.Store.PutDomainOffer(cid, rec, b)
+}
+
+// ApplyDomainAccept verifies an accepted offer and moves the name to the
+// buyer if it is registered to the seller here. It reports whether the
+// name moved.
+func (n *Node) ApplyDomainAccept(offer *core.DomainOffer, accept *core.DomainAccept) (bool, error) {
+	if err := verifyDomainAccept(offer, accept); err != nil {
+		return false, err
+	}
+	return n.Store.TransferDomain(offer.Domain, core.SiteIDFromPub(offer.SellerPub), offer.Buyer, offer.TS)
+}
+
+// PublishDomainOffer stores an offer of a name registered to the seller
+// here and gossips it. It returns the offer's CID.
+func (n *Node) PublishDomainOffer(ctx context.Context, rec *core.DomainOffer) (string, error) {
+	owner, err := n.Store.ResolveDomain(rec.Domain)
+	if err != nil || owner != core.SiteIDFromPub(rec.SellerPub) {
+		return "", fmt.Errorf("domain %q is not registered to the offering site", rec.Domain)
+	}
+	cid, err := n.ApplyDomainOffer(rec)
+	if err != nil {
+		return "", err
+	}
+	b, err := cborMarshal(GossipDomainOffer{Offer: mustMarshal(rec)})
+	if err != nil {
+		return "", err
+	}
+	return cid, n.Topic.Publish(ctx, b)
+}
+
+// PublishDomainAccept applies an acceptance locally and gossips it with
+// its offer. It reports whether the name moved on this node.
+func (n *Node) PublishDomainAccept(ctx context.Context, offer *core.DomainOffer, accept *core.DomainAccept) (bool, error) {
+	moved, err := n.ApplyDomainAccept(offer, accept)
+	if err != nil {
+		return false, err
+	}
+	b, err := cborMarshal(GossipDomainAccept{Offer: mustMarshal(offer), Accept: mustMarshal(accept)})
+	if err != nil {
+		return false, err
+	}
+	return moved, n.Topic.Publish(ctx, b)
+}
+
+func (n *Node) handleDomainOffer(rec *core.DomainOffer) error {
+	_, err := n.ApplyDomainOffer(rec)
+	if errors.Is(err, ErrOfferNotKept) {
+		return nil
+	}
+	if err != nil {
+		log.Printf("reject domain offer: %v", err)
+	}
+	return err
+}
+
+func (n *Node) handleDomainAccept(offer *core.DomainOffer, accept *core.DomainAccept) error {
+	_, err := n.ApplyDomainAccept(offer, accept)
+	if err != nil {
+		log.Printf("reject domain acceptance: %v", err)
+	}
+	return err
+}
@@ -0,0 +1,92 @@
+package p2p
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"alxnet/internal/core"
+)
+
+func TestDomainMarketplace(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	n := newTestNode(t, ctx)
+	sellerPub, sellerPriv, _ := ed25519.GenerateKey(rand.Reader)
+	buyerPub, buyerPriv, _ := ed25519.GenerateKey(rand.Reader)
+	_, otherPriv, _ := ed25519.GenerateKey(rand.Reader)
+	seller, buyer := core.SiteIDFromPub(sellerPub), core.SiteIDFromPub(buyerPub)
+	if err := n.Store.RegisterSiteName("shop", seller); err != nil {
+		t.Fatalf("RegisterSiteName: %v", err)
+	}
+	if err := n.Store.RegisterSiteName("elsewhere", strings.Repeat("c", 64)); err != nil {
+		t.Fatalf("RegisterSiteName: %v", err)
+	}
+	expires := time.Now().Add(24 * time.Hour)
+
+	offer, err := BuildDomainOffer(sellerPriv, "shop", buyer, "10 coffees", expires)
+	if err != nil {
+		t.Fatalf("BuildDomainOffer: %v", err)
+	}
+	cid, err := n.ApplyDomainOffer(offer)
+	if err != nil {
+		t.Fatalf("ApplyDomainOffer: %v", err)
+	}
+	if got, _, err := n.Store.GetDomainOffer("shop", cid); err != nil || got == nil || got.Note != "10 coffees" {
+		t.Fatalf("GetDomainOffer = %+v, %v", got, err)
+	}
+
+	notHeld, _ := BuildDomainOffer(sellerPriv, "elsewhere", buyer, "", expires)
+	if _, err := n.ApplyDomainOffer(notHeld); !errors.Is(err, ErrOfferNotKept) {
+		t.Errorf("offer of a name held by another site: err = %v, want ErrOfferNotKept", err)
+	}
+	tampered := *offer
+	tampered.Note = "free"
+	if _, err := n.ApplyDomainOffer(&tampered); err == nil {
+		t.Error("offer with a note not matching its signature was accepted")
+	}
+
+	wrongBuyer, _ := BuildDomainAccept(otherPriv, cid)
+	wrongOffer, _ := BuildDomainAccept(buyerPriv, strings.Repeat("d", 64))
+	forged, _ := BuildDomainAccept(buyerPriv, cid)
+	forged.TS++
+	for name, accept := range map[string]*core.DomainAccept{
+		"another key":   wrongBuyer,
+		"another offer": wrongOffer,
+		"bad signature": forged,
+	} {
+		if moved, err := n.ApplyDomainAccept(offer, accept); err == nil || moved {
+			t.Errorf("%s: ApplyDomainAccept = %v, %v, want error", name, moved, err)
+		}
+	}
+	if owner, _ := n.Store.ResolveDomain("shop"); owner != seller {
+		t.Fatalf("owner after rejected acceptances = %s", owner)
+	}
+
+	accept, err := BuildDomainAccept(buyerPriv, cid)
+	if err != nil {
+		t.Fatalf("BuildDomainAccept: %v", err)
+	}
+	if moved, err := n.ApplyDomainAccept(offer, accept); err != nil || !moved {
+		t.Fatalf("ApplyDomainAccept = %v, %v", moved, err)
+	}
+	if owner, _ := n.Store.ResolveDomain("shop"); owner != buyer {
+		t.Errorf("owner after transfer = %s, want %s", owner, buyer)
+	}
+	if got, _, _ := n.Store.GetDomainOffer("shop", cid); got != nil {
+		t.Error("accepted offer still open")
+	}
+	// Replaying the acceptance changes nothing
+	if moved, err := n.ApplyDomainAccept(offer, accept); err != nil || moved {
+		t.Errorf("replayed ApplyDomainAccept = %v, %v, want not moved", moved, err)
+	}
+	// Nor do offers the old holder made before the transfer
+	if _, err := n.ApplyDomainOffer(offer); err == nil {
+		t.Error("offer made before the transfer was kept")
+	}
+}
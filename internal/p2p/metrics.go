@@ -0,0 +1,76 @@
+package p2p
+
+import (
+	"context"
+	"time"
+
+	"alxnet/internal/store"
+
+	"go.uber.org/zap"
+)
+
+// MetricsInterval is how often the node samples its activity into the
+// store's metrics history.
+const MetricsInterval = time.Minute
+
+// Uptime returns how long the node has been running, or 0 before Start.
+func (n *Node) Uptime() time.Duration {
+	started := n.started.Load()
+	if started == 0 {
+		return 0
+	}
+	return time.Since(time.Unix(started, 0))
+}
+
+// metricsCounters are the running totals a sample is the difference of.
+type metricsCounters struct {
+	served, received, updates int64
+}
+
+// recordMetrics stores a sample every MetricsInterval until ctx is done.
+func (n *Node) recordMetrics(ctx context.Context) {
+	ticker := time.NewTicker(MetricsInterval)
+	defer ticker.Stop()
+
+	var last metricsCounters
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			sample, totals := n.metricSample(now, last)
+			if err := n.Store.PutMetricSample(sample); err != nil {
+				n.logger.Warn("failed to record metrics", zap.Error(err))
+				continue
+			}
+			last = totals
+		}
+	}
+}
+
+// metricSample takes a sample at now, counting traffic and updates since
+// the totals in last.
+func (n *Node) metricSample(now time.Time, last metricsCounters) (store.MetricSample, metricsCounters) {
+	bw := n.BandwidthStats()
+	totals := metricsCounters{served: bw.Served, received: bw.Received, updates: n.accepted.Load()}
+	sample := store.MetricSample{
+		Time:     now.Unix(),
+		Uptime:   int64(n.Uptime() / time.Second),
+		Peers:    len(n.Host.Network().Peers()),
+		Served:   totals.served - last.served,
+		Received: totals.received - last.received,
+		Updates:  totals.updates - last.updates,
+	}
+	if stats, err := n.Store.GetStats(); err == nil {
+		sample.StorageBytes = stats.ContentBytes
+		sample.Sites = stats.TotalSites
+	}
+	return sample, totals
+}
+
+// MetricsNow returns the node's gauges now and, in the counter fields, the
+// totals since it started, for exporters that push running totals.
+func (n *Node) MetricsNow() store.MetricSample {
+	sample, _ := n.metricSample(time.Now(), metricsCounters{})
+	return sample
+}
@@ -0,0 +1,33 @@
+package p2p
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMetricSample(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	n := newTestNode(t, ctx)
+	if n.Uptime() != 0 {
+		t.Errorf("Uptime before Start = %v", n.Uptime())
+	}
+	n.started.Store(time.Now().Add(-time.Hour).Unix())
+
+	n.bandwidth.served.Add(100)
+	n.accepted.Add(2)
+	now := time.Now()
+	first, totals := n.metricSample(now, metricsCounters{})
+	if first.Served != 100 || first.Updates != 2 || first.Uptime < 3600 || first.Time != now.Unix() {
+		t.Errorf("first sample = %+v", first)
+	}
+
+	// Later samples count only what happened since the previous one
+	n.bandwidth.served.Add(50)
+	n.bandwidth.received.Add(7)
+	second, _ := n.metricSample(now.Add(MetricsInterval), totals)
+	if second.Served != 50 || second.Received != 7 || second.Updates != 0 {
+		t.Errorf("second sample = %+v", second)
+	}
+}
@@ -0,0 +1,182 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"alxnet/internal/core"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Site names are registered first come, first served in each node's own
+// store and are not signed, so a name a peer resolves is only that peer's
+// claim. Nodes resolving names from peers keep the answers in memory and
+// never write them to their store.
+
+// ErrNameNotFound is returned when a peer has no site registered under a
+// name.
+var ErrNameNotFound = errors.New("name not found")
+
+// browseRespName answers resolve_name with the site registered under the
+// name.
+type browseRespName struct {
+	Ok     bool   `cbor:"ok"`
+	SiteID string `cbor:"s,omitempty"`
+}
+
+// serveName answers a resolve_name request from the local name registry.
+// Names of denied sites are not given out.
+func (n *Node) serveName(s network.Stream, from peer.ID, req browseReq) {
+	var resp browseRespName
+	if err := n.Store.ValidateSiteName(req.Name); err != nil {
+		n.audit(AuditEntry{Kind: AuditBrowse, Peer: from.String(), Reason: "invalid name: " + err.Error()})
+	} else if siteID, err := n.Store.ResolveDomain(req.Name); err == nil && isHexID(siteID) {
+		if n.Store.IsDenied(siteID) {
+			n.audit(AuditEntry{Kind: AuditBrowse, Peer: from.String(), SiteID: siteID, Reason: ErrDenied.Error()})
+		} else {
+			resp = browseRespName{Ok: true, SiteID: siteID}
+		}
+	}
+	b, _ := cborMarshal(resp)
+	if _, err := s.Write(b); err != nil {
+		log.Printf("handleBrowseStream: write failed: %v", err)
+	}
+}
+
+// RequestName asks p which site it has registered under name. It returns
+// ErrNameNotFound when p has none.
+func (n *Node) RequestName(ctx context.Context, p peer.AddrInfo, name string) (string, error) {
+	if err := n.Store.ValidateSiteName(name); err != nil {
+		return "", err
+	}
+	if err := n.Host.Connect(ctx, p); err != nil {
+		return "", err
+	}
+	s, err := n.Host.NewStream(ctx, p.ID, BrowseProto)
+	if err != nil {
+		return "", err
+	}
+	defer s.Close()
+	if err := s.SetWriteDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		return "", err
+	}
+	if err := s.SetReadDeadline(time.Now().Add(10 * time.Second)); err != nil {
+		return "", err
+	}
+	b, _ := cborMarshal(browseReq{Type: "resolve_name", Name: name})
+	if _, err := s.Write(b); err != nil {
+		return "", err
+	}
+	if closer, ok := s.(interface{ CloseWrite() error }); ok {
+		if err := closer.CloseWrite(); err != nil {
+			log.Printf("RequestName: failed to close write side: %v", err)
+		}
+	}
+
+	respBytes := readAllWithTimeout(s, 5*time.Second)
+	if len(respBytes) == 0 {
+		return "", errors.New("no response data")
+	}
+	var resp browseRespName
+	if err := core.UnmarshalCBOR(respBytes, &resp); err != nil {
+		return "", err
+	}
+	if !resp.Ok {
+		return "", ErrNameNotFound
+	}
+	if !isHexID(resp.SiteID) {
+		return "", fmt.Errorf("peer %s sent a malformed site ID", Short(p.ID.String()))
+	}
+	return resp.SiteID, nil
+}
+
+// DiscoverPeers dials the bootstrap peers and peers this node has
+// addresses for but is not connected to, up to max of them, and returns
+// the ones it connected to. It waits for the dials, bounded by ctx.
+func (n *Node) DiscoverPeers(ctx context.Context, max int) []peer.ID {
+	n.mu.RLock()
+	bootstrap := n.BootstrapAddrs
+	n.mu.RUnlock()
+
+	self := n.Host.ID()
+	seen := map[peer.ID]bool{self: true}
+	var candidates []peer.AddrInfo
+	add := func(ai peer.AddrInfo) {
+		if len(candidates) >= max || seen[ai.ID] || n.Host.Network().Connectedness(ai.ID) == network.Connected {
+			return
+		}
+		seen[ai.ID] = true
+		candidates = append(candidates, ai)
+	}
+	for _, m := range bootstrap {
+		if ai, err := peer.AddrInfoFromP2pAddr(m); err == nil {
+			add(*ai)
+		}
+	}
+	for _, id := range n.Host.Peerstore().PeersWithAddrs() {
+		if id == self || n.validatePeer(id) != nil {
+			continue
+		}
+		add(peer.AddrInfo{ID: id, Addrs: n.Host.Peerstore().Addrs(id)})
+	}
+
+	connected := make(chan peer.ID, len(candidates))
+	for _, ai := range candidates {
+		go func() {
+			if err := n.Host.Connect(ctx, ai); err != nil {
+				connected <- ""
+				return
+			}
+			connected <- ai.ID
+		}()
+	}
+	var peers []peer.ID
+	for range candidates {
+		if id := <-connected; id != "" {
+			peers = append(peers, id)
+		}
+	}
+	return peers
+}
+
+// AskPeersName asks peers at once which site they have registered under
+// name. Names are unsigned claims, so it returns the site only if every
+// peer knowing the name agrees, an error if they do not, and whether every
+// peer answered.
+func (n *Node) AskPeersName(ctx context.Context, peers []peer.ID, name string) (string, bool, error) {
+	type answer struct {
+		siteID string
+		err    error
+	}
+	answers := make(chan answer, len(peers))
+	for _, p := range peers {
+		go func() {
+			siteID, err := n.RequestName(ctx, peer.AddrInfo{ID: p}, name)
+			answers <- answer{siteID, err}
+		}()
+	}
+	var siteID string
+	var conflict bool
+	complete := true
+	for range peers {
+		a := <-answers
+		switch {
+		case errors.Is(a.err, ErrNameNotFound):
+		case a.err != nil:
+			complete = false
+		case siteID == "":
+			siteID = a.siteID
+		case siteID != a.siteID:
+			conflict = true
+		}
+	}
+	if conflict {
+		return "", false, fmt.Errorf("peers disagree on which site %q names", name)
+	}
+	return siteID, complete, nil
+}
@@ -0,0 +1,57 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestResolveNameFromPeer(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	seeder, client := newTestNode(t, ctx), newTestNode(t, ctx)
+	info := peer.AddrInfo{ID: seeder.Host.ID(), Addrs: seeder.Host.Addrs()}
+
+	const siteID = "aa00000000000000000000000000000000000000000000000000000000000000"
+	const deniedID = "bb00000000000000000000000000000000000000000000000000000000000000"
+	if err := seeder.Store.RegisterSiteName("blog", siteID); err != nil {
+		t.Fatalf("RegisterSiteName: %v", err)
+	}
+	if err := seeder.Store.RegisterSiteName("spam", deniedID); err != nil {
+		t.Fatalf("RegisterSiteName: %v", err)
+	}
+	if _, err := seeder.Store.Deny(deniedID, "test"); err != nil {
+		t.Fatalf("Deny: %v", err)
+	}
+
+	// The seeder is known but not connected until discovery dials it
+	client.Host.Peerstore().AddAddrs(info.ID, info.Addrs, time.Hour)
+	if found := client.DiscoverPeers(ctx, 8); len(found) != 1 || found[0] != info.ID {
+		t.Fatalf("DiscoverPeers = %v, want [%s]", found, info.ID)
+	}
+	if found := client.DiscoverPeers(ctx, 8); len(found) != 0 {
+		t.Errorf("DiscoverPeers dialed connected peers again: %v", found)
+	}
+
+	tests := []struct {
+		name, lookup string
+		want         string
+		wantErr      error
+	}{
+		{"registered", "blog", siteID, nil},
+		{"unknown", "nobody", "", ErrNameNotFound},
+		{"denied site", "spam", "", ErrNameNotFound},
+	}
+	for _, tt := range tests {
+		got, err := client.RequestName(ctx, info, tt.lookup)
+		if got != tt.want || !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: RequestName(%q) = %q, %v; want %q, %v", tt.name, tt.lookup, got, err, tt.want, tt.wantErr)
+		}
+	}
+	if _, err := client.RequestName(ctx, info, "../x"); err == nil {
+		t.Error("RequestName accepted an invalid name")
+	}
+}
@@ -9,12 +9,16 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"slices"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"alxnet/internal/core"
 	bncrypto "alxnet/internal/crypto"
 	"alxnet/internal/store"
+	"alxnet/internal/verify"
 
 	"github.com/fxamacker/cbor/v2"
 	libp2p "github.com/libp2p/go-libp2p"
@@ -22,6 +26,7 @@ import (
 	host "github.com/libp2p/go-libp2p/core/host"
 	network "github.com/libp2p/go-libp2p/core/network"
 	peer "github.com/libp2p/go-libp2p/core/peer"
+	pnet "github.com/libp2p/go-libp2p/core/pnet"
 	protocol "github.com/libp2p/go-libp2p/core/protocol"
 	mdns "github.com/libp2p/go-libp2p/p2p/discovery/mdns"
 	ping "github.com/libp2p/go-libp2p/p2p/protocol/ping"
@@ -44,8 +49,9 @@ const (
 )
 
 type GossipUpdate struct {
-	Record  []byte // canonical CBOR of UpdateRecord
-	Content []byte // optional content bytes (small)
+	Record   []byte // canonical CBOR of UpdateRecord
+	Content  []byte // optional content bytes, see GossipInlineLimit
+	Encoding string `cbor:",omitempty"` // "" (plain) or "zstd"
 }
 
 type GossipDelete struct {
@@ -54,9 +60,11 @@ type GossipDelete struct {
 
 // RateLimiter prevents abuse from individual peers
 type RateLimiter struct {
+	mu          sync.Mutex
 	requests    map[string][]time.Time
 	maxRequests int
 	window      time.Duration
+	disabled    bool
 }
 
 // PeerInfo tracks peer reputation and status
@@ -67,6 +75,13 @@ type PeerInfo struct {
 	BannedUntil  *time.Time
 	RequestCount int
 	ErrorCount   int
+	ProofsPassed int       // storage challenges this node put to the peer
+	ProofsFailed int       // answered missing or with a wrong digest
+	LastProof    time.Time // when the last challenge was answered
+
+	// Content bytes exchanged, see bandwidth.go
+	BytesServed   int64
+	BytesReceived int64
 }
 
 // Node represents a P2P network node with enhanced security
@@ -86,8 +101,50 @@ type Node struct {
 	bannedPeers map[peer.ID]time.Time
 
 	maxMemoryUsage int64
+	maxPeers       atomic.Int64
 	mu             sync.RWMutex
 
+	// Per-site replication telemetry for sites published through this node
+	telemetry telemetry
+
+	// Versions and features advertised by connected peers
+	caps capabilityTable
+
+	// Workers validating and applying gossip, see validation.go
+	validation *validationPool
+
+	// Latest GossipSub peer scores, see gossip.go
+	gossipScores *gossipScores
+
+	// Head announcements and the syncs they start, see announce.go
+	sync headSync
+
+	// Recently fetched heads of sites held by peers
+	heads headCache
+
+	// Content being fetched from peers, see wantlist.go
+	wants wantList
+
+	// Request slots by priority class, see priority.go
+	fetches *fetchScheduler
+
+	// Sites listed in this node's sitemap, see sitemap.go
+	sitemap sitemapCache
+
+	// Serialises quota checks of shards stored for others, see erasure.go
+	shardMu sync.Mutex
+
+	// Content traffic totals, see bandwidth.go
+	bandwidth bandwidthCounters
+
+	// Recent rejections, see audit.go
+	audits      auditLog
+	auditLogger *zap.Logger
+
+	// When Start ran and updates accepted since, see metrics.go
+	started  atomic.Int64
+	accepted atomic.Int64
+
 	// Logging
 	logger *zap.Logger
 
@@ -104,6 +161,86 @@ type NodeConfig struct {
 	MaxMemoryUsage       int64
 	EnablePeerValidation bool
 	EnableRateLimiting   bool
+
+	// Inline content of at least this many bytes is zstd-compressed in
+	// outgoing updates; 0 disables. Updates are only compressed while every
+	// mesh peer advertises FeatureGossipZstd, but relays forward them
+	// unchanged to their own peers, so it stays off by default.
+	GossipCompressThreshold int
+
+	// Content over this many bytes is left out of outgoing updates, for
+	// peers to fetch by CID; zero picks DefaultGossipInlineLimit.
+	GossipInlineLimit int
+
+	// Gossip validation workers and the queue length of each; zero picks
+	// DefaultValidationWorkers and DefaultValidationQueueSize.
+	ValidationWorkers   int
+	ValidationQueueSize int
+
+	// Logger for node events; nil uses a production logger. Passing the
+	// application's logger lets its level be changed at runtime.
+	Logger *zap.Logger
+
+	// AuditLogger receives every rejected message and request; nil logs
+	// them through Logger under the name "audit".
+	AuditLogger *zap.Logger
+
+	// ServeSitemap answers SitemapProto and advertises FeatureSitemap, so
+	// search indexers can list the sites this node seeds. Off by default:
+	// the sitemap tells any peer what this node stores.
+	ServeSitemap bool
+
+	// ShardQuota is how many bytes of erasure shards this node holds for
+	// other nodes; above zero it answers ShardProto and advertises
+	// FeatureShards. Off by default.
+	ShardQuota int64
+
+	// Content requests answered at once before the node counts as
+	// saturated; zero picks DefaultMaxConcurrentServes. While saturated,
+	// ServePolicy (nil for DefaultServePolicy) decides which peers are
+	// still answered.
+	MaxConcurrentServes int
+	ServePolicy         ServePolicy
+
+	// SOCKS5Proxy, host:port, routes every outbound connection through a
+	// SOCKS5 proxy such as Tor's 127.0.0.1:9050. The node then dials and
+	// listens on TCP only, leaves name resolution to the proxy, can dial
+	// peers' /onion3 addresses, and does not announce itself over mDNS.
+	// Empty dials directly.
+	SOCKS5Proxy string
+
+	// OnionService, if set, publishes the node's TCP listener as a Tor
+	// onion service and announces only its /onion3 address. Pair it with
+	// SOCKS5Proxy and a loopback listen address to keep the node's IP
+	// address to itself.
+	OnionService *OnionServiceConfig
+
+	// ListenAddrs are listened on besides New's listen address, such as
+	// an /ip6/ one alongside /ip4/; see WildcardListenAddrs.
+	ListenAddrs []string
+
+	// Interfaces, if set, keeps the node to these network interfaces:
+	// wildcard listen addresses are narrowed to their addresses, and
+	// others must be one of them.
+	Interfaces []string
+
+	// FetchSlots is how many requests for content and update records the
+	// node has in flight at once, zero for DefaultFetchSlots. FetchShares
+	// are the percent of them each priority class may hold; classes left
+	// out take DefaultFetchShares. See priority.go.
+	FetchSlots  int
+	FetchShares map[Priority]int
+
+	// PrivateNetworkKey, SwarmKeySize bytes, makes the node part of a
+	// private network: it only connects to nodes holding the same key.
+	// See LoadSwarmKey. Nil joins the public network.
+	PrivateNetworkKey []byte
+
+	// ActivationTimes move scheduled rule changes, by feature, away from
+	// the times in Activations; a zero time never activates the feature.
+	// Only for private and test networks: a node that activates a rule at
+	// another time than its peers disagrees with them about records.
+	ActivationTimes map[string]time.Time
 }
 
 // DefaultNodeConfig returns sensible defaults
@@ -116,6 +253,9 @@ func DefaultNodeConfig() *NodeConfig {
 		MaxMemoryUsage:       MaxMemoryUsage,
 		EnablePeerValidation: true,
 		EnableRateLimiting:   true,
+		ValidationWorkers:    DefaultValidationWorkers(),
+		ValidationQueueSize:  DefaultValidationQueueSize,
+		GossipInlineLimit:    DefaultGossipInlineLimit,
 	}
 }
 
@@ -125,9 +265,12 @@ func New(ctx context.Context, db *store.Store, listen string, bootstrap []string
 	}
 
 	// Initialize logger
-	logger, err := zap.NewProduction()
-	if err != nil {
-		return nil, fmt.Errorf("failed to create logger: %w", err)
+	logger := config.Logger
+	if logger == nil {
+		var err error
+		if logger, err = zap.NewProduction(); err != nil {
+			return nil, fmt.Errorf("failed to create logger: %w", err)
+		}
 	}
 
 	hostPub, hostPriv, err := ed25519.GenerateKey(rand.Reader)
@@ -135,27 +278,58 @@ func New(ctx context.Context, db *store.Store, listen string, bootstrap []string
 		return nil, fmt.Errorf("failed to generate host key: %w", err)
 	}
 
-	h, err := libp2p.New(
-		libp2p.ListenAddrStrings(listen),
-		libp2p.ResourceManager(nil), // We'll implement our own resource management
-	)
+	listenOn, err := listenAddrs(listen, config.ListenAddrs, config.Interfaces)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create libp2p host: %w", err)
+		return nil, err
 	}
-
-	ps, err := pubsub.NewGossipSub(ctx, h)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create pubsub: %w", err)
+	opts := []libp2p.Option{
+		libp2p.ListenAddrStrings(listenOn...),
+		libp2p.ResourceManager(nil), // We'll implement our own resource management
 	}
-
-	t, err := ps.Join(Topic)
+	if config.PrivateNetworkKey != nil {
+		if len(config.PrivateNetworkKey) != SwarmKeySize {
+			return nil, fmt.Errorf("private network key must be %d bytes, got %d", SwarmKeySize, len(config.PrivateNetworkKey))
+		}
+		opts = append(opts, libp2p.PrivateNetwork(pnet.PSK(config.PrivateNetworkKey)))
+	}
+	if config.SOCKS5Proxy != "" {
+		dialer, err := socksDialer(config.SOCKS5Proxy)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, proxyOptions(dialer)...)
+	}
+	// Until the onion service is up, the node announces no address at all
+	var onionAddr atomic.Pointer[ma.Multiaddr]
+	if config.OnionService != nil {
+		opts = append(opts, libp2p.AddrsFactory(func([]ma.Multiaddr) []ma.Multiaddr {
+			if a := onionAddr.Load(); a != nil {
+				return []ma.Multiaddr{*a}
+			}
+			return nil
+		}))
+	}
+	h, err := libp2p.New(opts...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to join topic: %w", err)
+		return nil, fmt.Errorf("failed to create libp2p host: %w", err)
+	}
+	if config.OnionService != nil {
+		a, err := startOnionService(ctx, config.OnionService, h.Network().ListenAddresses())
+		if err != nil {
+			h.Close()
+			return nil, fmt.Errorf("failed to start onion service: %w", err)
+		}
+		onionAddr.Store(&a)
+		logger.Info("onion service published", zap.String("addr", a.String()))
 	}
 
-	sub, err := t.Subscribe()
+	scores := &gossipScores{}
+	ps, err := pubsub.NewGossipSub(ctx, h,
+		pubsub.WithMessageSignaturePolicy(pubsub.StrictSign),
+		pubsub.WithPeerScore(gossipScoreParams(), gossipScoreThresholds()),
+		pubsub.WithPeerScoreInspect(pubsub.PeerScoreInspectFn(scores.update), gossipScoreInterval))
 	if err != nil {
-		return nil, fmt.Errorf("failed to subscribe: %w", err)
+		return nil, fmt.Errorf("failed to create pubsub: %w", err)
 	}
 
 	var maddrs []ma.Multiaddr
@@ -176,24 +350,53 @@ func New(ctx context.Context, db *store.Store, listen string, bootstrap []string
 		HostPub:        hostPub,
 		HostPriv:       hostPriv,
 		PubSub:         ps,
-		Topic:          t,
-		Sub:            sub,
 		Store:          db,
 		BootstrapAddrs: maddrs,
 		rateLimiter: &RateLimiter{
 			requests:    make(map[string][]time.Time),
 			maxRequests: config.MaxRequestsPerWindow,
 			window:      config.RateLimitWindow,
+			disabled:    !config.EnableRateLimiting,
 		},
 		peers:          make(map[peer.ID]*PeerInfo),
 		bannedPeers:    make(map[peer.ID]time.Time),
 		maxMemoryUsage: config.MaxMemoryUsage,
 		logger:         logger,
+		auditLogger:    config.AuditLogger,
 		config:         config,
+		validation:     newValidationPool(config.ValidationWorkers, config.ValidationQueueSize),
+		gossipScores:   scores,
+		fetches:        newFetchScheduler(config.FetchSlots, config.FetchShares),
+	}
+	n.maxPeers.Store(int64(config.MaxPeers))
+	if n.auditLogger == nil {
+		n.auditLogger = logger.Named("audit")
+	}
+
+	// The validator goes in before joining so no message gets past it
+	if err := ps.RegisterTopicValidator(Topic, n.validateGossip); err != nil {
+		return nil, fmt.Errorf("failed to register topic validator: %w", err)
+	}
+	if n.Topic, err = ps.Join(Topic); err != nil {
+		return nil, fmt.Errorf("failed to join topic: %w", err)
+	}
+	if n.Sub, err = n.Topic.Subscribe(); err != nil {
+		return nil, fmt.Errorf("failed to subscribe: %w", err)
 	}
 
 	// Register browse protocol handler
 	h.SetStreamHandler(BrowseProto, n.handleBrowseStream)
+	h.SetStreamHandler(HaveProto, n.handleHaveStream)
+	h.SetStreamHandler(HostingProto, n.handleHostingStream)
+	h.SetStreamHandler(HelloProto, n.handleHelloStream)
+	h.SetStreamHandler(DialbackProto, n.handleDialbackStream)
+	h.SetStreamHandler(ProofProto, n.handleProofStream)
+	if config.ServeSitemap {
+		h.SetStreamHandler(SitemapProto, n.handleSitemapStream)
+	}
+	if config.ShardQuota > 0 {
+		h.SetStreamHandler(ShardProto, n.handleShardStream)
+	}
 
 	// Set connection handlers
 	h.Network().Notify(&network.NotifyBundle{
@@ -204,7 +407,8 @@ func New(ctx context.Context, db *store.Store, listen string, bootstrap []string
 	logger.Info("node created successfully",
 		zap.String("host_id", h.ID().String()),
 		zap.String("listen_addr", listen),
-		zap.Int("bootstrap_peers", len(maddrs)))
+		zap.Int("bootstrap_peers", len(maddrs)),
+		zap.String("private_network", n.PrivateNetwork()))
 
 	return n, nil
 }
@@ -212,151 +416,200 @@ func New(ctx context.Context, db *store.Store, listen string, bootstrap []string
 // Start initializes and starts the node
 func (n *Node) Start(ctx context.Context) error {
 	n.logger.Info("starting node")
+	n.started.Store(time.Now().Unix())
 
 	// Start background goroutines
+	n.validation.start(ctx)
 	go n.consume(ctx)
 	go n.peerManagement(ctx)
 	go n.memoryManagement(ctx)
 	go n.cleanupBannedPeers(ctx)
+	go n.reseedPinned(ctx)
+	go n.recordMetrics(ctx)
 
-	// Start periodic tasks
-	ticker := time.NewTicker(30 * time.Second)
-	defer ticker.Stop()
-
-	go func() {
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-ticker.C:
-				_ = n.Topic.Publish(ctx, []byte("bn-alive"))
-			}
-		}
-	}()
-	// Enable mDNS advertise/respond on LAN so Browser auto-discovery can find this node
-	_ = mdns.NewMdnsService(n.Host, "alxnet-mdns", &mdnsNotifee{cb: func(pi peer.AddrInfo) {
-		log.Printf("mDNS: discovered peer %s", pi.ID)
-	}})
-	// Attempt to connect to bootstrap peers, if any
-	for _, m := range n.BootstrapAddrs {
-		if ai, err := peer.AddrInfoFromP2pAddr(m); err == nil {
-			go func(info peer.AddrInfo) {
-				cctx, cancel := context.WithTimeout(ctx, 5*time.Second)
-				defer cancel()
-				_ = n.Host.Connect(cctx, info)
-			}(*ai)
-		}
+	go n.announceLoop(ctx)
+	// Enable mDNS advertise/respond on LAN so Browser auto-discovery can
+	// find this node, unless it hides behind a proxy
+	if n.config.SOCKS5Proxy == "" && n.config.OnionService == nil {
+		_ = mdns.NewMdnsService(n.Host, "alxnet-mdns", &mdnsNotifee{cb: func(pi peer.AddrInfo) {
+			log.Printf("mDNS: discovered peer %s", pi.ID)
+		}})
 	}
+	// Attempt to connect to bootstrap peers, if any
+	n.mu.RLock()
+	bootstrap := n.BootstrapAddrs
+	n.mu.RUnlock()
+	n.connectBootstrap(ctx, bootstrap)
+	go n.reconnectKnownPeers(ctx)
 	n.logger.Info("node started successfully")
 	return nil
 }
 
+// consume applies messages the topic validator accepted; see gossip.go.
 func (n *Node) consume(ctx context.Context) {
 	for {
 		msg, err := n.Sub.Next(ctx)
 		if err != nil {
 			return
 		}
-		data := msg.GetData()
-		if string(data) == "bn-alive" {
-			continue
-		}
-		// Try update first
-		var u GossipUpdate
-		if err := cborUnmarshal(data, &u); err == nil && len(u.Record) > 0 {
-			n.handleEnvelope(u)
-			continue
-		}
-		// Then try delete
-		var d GossipDelete
-		if err := cborUnmarshal(data, &d); err == nil && len(d.Delete) > 0 {
-			n.handleDelete(d)
+		g, ok := msg.ValidatorData.(*gossipMsg)
+		if !ok {
 			continue
 		}
+		from := msg.ReceivedFrom
+		e := g.audit
+		e.Peer = from.String()
+		n.dispatch(ctx, e.SiteID, func() error {
+			return n.audited(e, n.applyGossip(ctx, from, g))
+		})
 	}
 }
 
-func cborMarshal(v any) ([]byte, error) {
-	enc, err := cbor.CanonicalEncOptions().EncMode()
-	if err != nil {
-		return nil, err
+// dispatch hands a decoded message to the validation pool, keyed by site so
+// that messages for one site are applied in order.
+func (n *Node) dispatch(ctx context.Context, siteID string, job validationJob) {
+	if !n.validation.submit(ctx, siteID, job) && ctx.Err() == nil {
+		n.logger.Warn("validation queue full, dropped gossip message", zap.String("site", Short(siteID)))
 	}
-	return enc.Marshal(v)
+}
+
+// gossipDecMode decodes wire messages, which must tag their times.
+var gossipDecMode, _ = cbor.DecOptions{TimeTag: cbor.DecTagRequired}.DecMode()
+
+func cborMarshal(v any) ([]byte, error) {
+	return core.MarshalCanonical(v)
 }
 func cborUnmarshal(b []byte, v any) error {
-	dec, err := cbor.DecOptions{TimeTag: cbor.DecTagRequired}.DecMode()
-	if err != nil {
-		return err
-	}
-	return dec.Unmarshal(b, v)
+	return gossipDecMode.Unmarshal(b, v)
 }
 
-func (n *Node) handleEnvelope(env GossipUpdate) {
-	var rec core.UpdateRecord
-	if err := cborUnmarshal(env.Record, &rec); err != nil {
-		return
-	}
-	if err := n.ValidateAndApply(&rec, env.Content); err != nil {
-		log.Printf("reject update: %v", err)
+// ErrNotSiteOwner rejects a delete whose signer does not control the site
+// a target belongs to.
+var ErrNotSiteOwner = errors.New("delete signer does not control the target's site")
+
+// ApplyDelete verifies a signed delete record and removes its targets from
+// the store, stepping the head back when it is the deleted record. The
+// signer must control the site of every target: a target record must be an
+// update of that site and target content must be used by it. Content other
+// sites use as well is kept, and targets this node does not hold are
+// skipped.
+func (n *Node) ApplyDelete(del core.DeleteRecord) error {
+	if err := verifyDelete(&del); err != nil {
+		return err
 	}
-}
+	siteID := core.SiteIDFromPub(del.SitePub)
 
-func (n *Node) handleDelete(env GossipDelete) {
-	var del core.DeleteRecord
-	if err := cborUnmarshal(env.Delete, &del); err != nil {
-		return
-	}
-	// Verify signature
-	pre := bncrypto.PreimageDelete(del.SitePub, del.TargetRec, del.TargetCont, del.TS)
-	if !ed25519.Verify(ed25519.PublicKey(del.SitePub), pre, del.Sig) {
-		log.Printf("reject delete: invalid signature")
-		return
-	}
-	// Apply
+	// Check every target before removing any, since removing the record
+	// changes which content the site uses
+	var rec *core.UpdateRecord
 	if del.TargetRec != "" {
-		// Resolve prefix if needed
-		if len(del.TargetRec) < 64 {
-			if full, err := n.Store.ResolveRecordCID(del.TargetRec); err == nil {
-				del.TargetRec = full
-			}
+		var err error
+		if del.TargetRec, rec, err = n.deleteTargetRecord(del.TargetRec); err != nil {
+			return err
 		}
-		// Load the record to verify ownership and to get PrevCID
-		recBytes, err := n.Store.GetRecord(del.TargetRec)
-		if err == nil && len(recBytes) > 0 {
-			var rec core.UpdateRecord
-			if cborUnmarshal(recBytes, &rec) == nil {
-				// Ensure delete key matches record site
-				if hex.EncodeToString(rec.SitePub) == hex.EncodeToString(del.SitePub) {
-					siteID := core.SiteIDFromPub(rec.SitePub)
-					// Adjust head if needed
-					if has, _ := n.Store.HasHead(siteID); has {
-						seq, headCID, _ := n.Store.GetHead(siteID)
-						if headCID == del.TargetRec {
-							if seq > 0 {
-								_ = n.Store.SetHead(siteID, seq-1, rec.PrevCID)
-							}
-						}
-					}
+		if rec != nil && !controlsSite(del.SitePub, core.SiteIDFromPub(rec.SitePub)) {
+			return fmt.Errorf("%w: record %s belongs to site %s", ErrNotSiteOwner, Short(del.TargetRec), Short(core.SiteIDFromPub(rec.SitePub)))
+		}
+	}
+	if del.TargetCont != "" {
+		if full, err := n.Store.ResolveContentCID(del.TargetCont); err == nil {
+			del.TargetCont = full
+		}
+		if has, _ := n.Store.HasContent(del.TargetCont); !has {
+			del.TargetCont = ""
+		} else if used, err := n.Store.SiteContent(siteID); err != nil {
+			return err
+		} else if !used[del.TargetCont] {
+			return fmt.Errorf("%w: content %s is not used by site %s", ErrNotSiteOwner, Short(del.TargetCont), Short(siteID))
+		}
+	}
+
+	if rec != nil {
+		// Adjust head if needed
+		if has, _ := n.Store.HasHead(siteID); has {
+			seq, headCID, _ := n.Store.GetHead(siteID)
+			if headCID == del.TargetRec {
+				_ = n.Store.DeleteHead(siteID, seq)
+				if seq > 1 {
+					_ = n.Store.SetHead(siteID, seq-1, rec.PrevCID)
 				}
+				n.heads.invalidate(siteID)
 			}
 		}
 		_ = n.Store.DeleteRecord(del.TargetRec)
 	}
 	if del.TargetCont != "" {
-		if len(del.TargetCont) < 64 {
-			if full, err := n.Store.ResolveContentCID(del.TargetCont); err == nil {
-				del.TargetCont = full
-			}
+		sites, err := n.Store.ContentSites(del.TargetCont)
+		if err != nil {
+			return err
+		}
+		if slices.ContainsFunc(sites, func(id string) bool { return id != siteID }) {
+			log.Printf("delete: keeping content %s, other sites use it", Short(del.TargetCont))
+			return nil
 		}
 		_ = n.Store.DeleteContent(del.TargetCont)
 	}
+	return nil
+}
+
+// verifyDelete checks structure and signature of a delete record.
+func verifyDelete(del *core.DeleteRecord) error {
+	if err := del.Validate(); err != nil {
+		return err
+	}
+	pre := bncrypto.PreimageDelete(del.SitePub, del.TargetRec, del.TargetCont, del.TS)
+	if !ed25519.Verify(ed25519.PublicKey(del.SitePub), pre, del.Sig) {
+		return errors.New("invalid delete signature")
+	}
+	return nil
+}
+
+// deleteTargetRecord resolves the record a delete targets, which may be
+// given as a CID prefix, and returns its full CID and the record, or a nil
+// record if this node does not hold it.
+func (n *Node) deleteTargetRecord(target string) (string, *core.UpdateRecord, error) {
+	full, err := n.Store.ResolveRecordCID(target)
+	if err != nil {
+		return target, nil, nil
+	}
+	data, err := n.Store.GetRecord(full)
+	if err != nil {
+		return target, nil, nil
+	}
+	var rec core.UpdateRecord
+	if err := cborUnmarshal(data, &rec); err != nil {
+		return "", nil, fmt.Errorf("target record %s: %w", Short(full), err)
+	}
+	return full, &rec, nil
+}
+
+// controlsSite reports whether key currently controls siteID. A site is
+// controlled by the key its ID is derived from; this is where a rotated
+// site key would be looked up.
+func controlsSite(key []byte, siteID string) bool {
+	return core.SiteIDFromPub(key) == siteID
 }
 
 func (n *Node) ValidateAndApply(r *core.UpdateRecord, content []byte) error {
-	if r.Version != "v1" {
-		return errors.New("bad version")
+	return n.validateAndApply(r, content, HeadInfo{})
+}
+
+// ValidateAndApplyOnto applies an update this node signed on top of base,
+// a head peers reported. When base is newer than the local head the update
+// is chained to base instead, so an owner can publish from a node that
+// missed earlier updates.
+func (n *Node) ValidateAndApplyOnto(r *core.UpdateRecord, content []byte, base HeadInfo) error {
+	return n.validateAndApply(r, content, base)
+}
+
+func (n *Node) validateAndApply(r *core.UpdateRecord, content []byte, base HeadInfo) error {
+	if err := n.checkRecordVersion(r); err != nil {
+		return err
 	}
 	siteID := core.SiteIDFromPub(r.SitePub)
+	if n.Store.IsDenied(siteID, r.ContentCID) {
+		return ErrDenied
+	}
 
 	if len(content) > 0 {
 		if core.CIDForContent(content) != r.ContentCID {
@@ -364,29 +617,44 @@ func (n *Node) ValidateAndApply(r *core.UpdateRecord, content []byte) error {
 		}
 	}
 
-	linkPre := bncrypto.PreimageLink(r.SitePub, r.UpdatePub, r.Seq, r.PrevCID, r.ContentCID, r.TS)
-	if !ed25519.Verify(ed25519.PublicKey(r.SitePub), linkPre, r.LinkSig) {
-		return errors.New("invalid link signature")
+	if err := verifyUpdateSigs(r); err != nil {
+		return err
 	}
 
-	bytesNoUS, err := core.CanonicalMarshalNoUpdateSig(r)
+	recBytes, err := core.CanonicalMarshal(r)
 	if err != nil {
 		return err
 	}
-	updPre := bncrypto.PreimageUpdate(bytesNoUS)
-	if !ed25519.Verify(ed25519.PublicKey(r.UpdatePub), updPre, r.UpdateSig) {
-		return errors.New("invalid update signature")
-	}
+	recCID := core.CIDForBytes(recBytes)
 
 	hasHead, err := n.Store.HasHead(siteID)
 	if err != nil {
 		return err
 	}
+	var seq uint64
+	var headCID string
 	if hasHead {
-		seq, headCID, err := n.Store.GetHead(siteID)
-		if err != nil {
+		if seq, headCID, err = n.Store.GetHead(siteID); err != nil {
 			return err
 		}
+	}
+	if base.Seq > seq {
+		hasHead, seq, headCID = true, base.Seq, base.HeadCID
+	}
+	if hasHead {
+		if r.Seq == seq && recCID == headCID {
+			// Re-announcement of the head we already hold; keep the
+			// content if we were missing it
+			if len(content) > 0 {
+				if has, _ := n.Store.HasContent(r.ContentCID); !has {
+					if err := n.Store.PutContent(r.ContentCID, content); err != nil {
+						return err
+					}
+					n.wants.deliver(r.ContentCID, content)
+				}
+			}
+			return nil
+		}
 		if r.Seq != seq+1 {
 			return errors.New("sequence mismatch")
 		}
@@ -399,33 +667,53 @@ func (n *Node) ValidateAndApply(r *core.UpdateRecord, content []byte) error {
 		}
 	}
 
-	if r.TS <= 0 {
-		return errors.New("bad timestamp")
+	if err := core.CheckTimestamp(r.TS); err != nil {
+		return err
 	}
 
-	recBytes, err := core.CanonicalMarshal(r)
-	if err != nil {
-		return err
+	w := store.UpdateWrite{SiteID: siteID, Seq: r.Seq, RecordCID: recCID, Record: recBytes, ContentCID: r.ContentCID}
+	if len(content) > 0 {
+		w.Content = content
 	}
-	recCID := core.CIDForBytes(recBytes)
-	if err := n.Store.PutRecord(recCID, recBytes); err != nil {
+	if err := n.Store.PutUpdate(w); err != nil {
 		return err
 	}
-	if len(content) > 0 {
-		if err := n.Store.PutContent(r.ContentCID, content); err != nil {
-			return err
-		}
+	if w.Content != nil {
+		n.wants.deliver(r.ContentCID, content)
 	}
-	if err := n.Store.SetHead(siteID, r.Seq, recCID); err != nil {
-		return err
+	n.heads.invalidate(siteID)
+	if _, err := n.Store.NoteSiteHead(siteID, r.Seq, recCID); err != nil {
+		log.Printf("ValidateAndApply: recording followed site update failed: %v", err)
 	}
 
+	n.accepted.Add(1)
 	log.Printf("accepted update site=%s seq=%d cid=%s content=%s",
 		Short(siteID), r.Seq, Short(recCID), Short(r.ContentCID))
 	return nil
 }
 
+// verifyUpdateSigs checks the site key's link signature and the update
+// key's signature of an update record.
+func verifyUpdateSigs(r *core.UpdateRecord) error {
+	linkPre := bncrypto.PreimageLink(r.SitePub, r.UpdatePub, r.Seq, r.PrevCID, r.ContentCID, r.TS)
+	if !verify.Signature(r.SitePub, linkPre, r.LinkSig) {
+		return errors.New("invalid link signature")
+	}
+	bytesNoUS, err := core.CanonicalMarshalNoUpdateSig(r)
+	if err != nil {
+		return err
+	}
+	if !verify.Signature(r.UpdatePub, bncrypto.PreimageUpdate(bytesNoUS), r.UpdateSig) {
+		return errors.New("invalid update signature")
+	}
+	return nil
+}
+
 func (n *Node) BroadcastUpdate(ctx context.Context, env GossipUpdate) error {
+	inlineGossip(&env, n.gossipInlineLimit())
+	if n.topicPeersSupport(FeatureGossipZstd) {
+		compressGossip(&env, n.config.GossipCompressThreshold)
+	}
 	b, err := cborMarshal(env)
 	if err != nil {
 		return err
@@ -433,6 +721,23 @@ func (n *Node) BroadcastUpdate(ctx context.Context, env GossipUpdate) error {
 	return n.Topic.Publish(ctx, b)
 }
 
+// BuildDelete signs a delete record for a record and/or content of the site
+// owning sitePriv.
+func BuildDelete(sitePriv ed25519.PrivateKey, targetRec, targetCont string) (*core.DeleteRecord, error) {
+	del := &core.DeleteRecord{
+		Version:    "v1",
+		SitePub:    sitePriv.Public().(ed25519.PublicKey),
+		TargetRec:  targetRec,
+		TargetCont: targetCont,
+		TS:         time.Now().Unix(),
+	}
+	del.Sig = ed25519.Sign(sitePriv, bncrypto.PreimageDelete(del.SitePub, del.TargetRec, del.TargetCont, del.TS))
+	if err := del.Validate(); err != nil {
+		return nil, err
+	}
+	return del, nil
+}
+
 func (n *Node) BroadcastDelete(ctx context.Context, del core.DeleteRecord) error {
 	brec, err := cborMarshal(GossipDelete{Delete: mustMarshal(del)})
 	if err != nil {
@@ -452,6 +757,10 @@ type browseReq struct {
 	Type   string `cbor:"t"`
 	SiteID string `cbor:"s,omitempty"`
 	CID    string `cbor:"c,omitempty"`
+	Path   string `cbor:"p,omitempty"` // get_file_record
+	Name   string `cbor:"n,omitempty"` // resolve_name
+
+	Filter *cidFilter `cbor:"f,omitempty"` // get_missing: records the sender holds
 }
 
 type browseRespHead struct {
@@ -464,6 +773,7 @@ type browseRespHead struct {
 type browseRespContent struct {
 	Ok      bool   `cbor:"ok"`
 	Content []byte `cbor:"ct,omitempty"`
+	Busy    bool   `cbor:"busy,omitempty"` // turned away by the serve policy
 }
 
 func (n *Node) handleBrowseStream(s network.Stream) {
@@ -475,13 +785,19 @@ func (n *Node) handleBrowseStream(s network.Stream) {
 		log.Printf("handleBrowseStream: failed to set read deadline: %v", err)
 		return
 	}
-
-	dec, _ := cbor.DecOptions{}.DecMode()
+	from := s.Conn().RemotePeer()
+	if !n.rateLimiter.allow(from.String(), time.Now()) {
+		log.Printf("handleBrowseStream: %s is over the rate limit", from)
+		n.updatePeerReputation(from, -1)
+		n.audit(AuditEntry{Kind: AuditBrowse, Peer: from.String(), Reason: "over the rate limit"})
+		return
+	}
 	reqBytes := readAllWithTimeout(s, 5*time.Second)
 	log.Printf("handleBrowseStream: read %d bytes", len(reqBytes))
 	var req browseReq
-	if err := dec.Unmarshal(reqBytes, &req); err != nil {
+	if err := core.UnmarshalCBOR(reqBytes, &req); err != nil {
 		log.Printf("handleBrowseStream: unmarshal failed: %v", err)
+		n.audit(AuditEntry{Kind: AuditBrowse, Peer: from.String(), Reason: "malformed request: " + err.Error()})
 		return
 	}
 	log.Printf("handleBrowseStream: got request type=%s siteID=%s cid=%s", req.Type, req.SiteID, req.CID)
@@ -495,11 +811,13 @@ func (n *Node) handleBrowseStream(s network.Stream) {
 	switch req.Type {
 	case "get_head":
 		var resp browseRespHead
-		if has, _ := n.Store.HasHead(req.SiteID); has {
+		if n.Store.IsDenied(req.SiteID) {
+			n.audit(AuditEntry{Kind: AuditBrowse, Peer: from.String(), SiteID: req.SiteID, Reason: ErrDenied.Error()})
+		} else if has, _ := n.Store.HasHead(req.SiteID); has {
 			seq, headCID, _ := n.Store.GetHead(req.SiteID)
 			if recBytes, err := n.Store.GetRecord(headCID); err == nil {
 				var rec core.UpdateRecord
-				if dec.Unmarshal(recBytes, &rec) == nil {
+				if core.UnmarshalCBOR(recBytes, &rec) == nil {
 					resp = browseRespHead{Ok: true, Seq: seq, HeadCID: headCID, ContentCID: rec.ContentCID}
 				}
 			}
@@ -511,14 +829,33 @@ func (n *Node) handleBrowseStream(s network.Stream) {
 		log.Printf("handleBrowseStream: sent response ok=%v", resp.Ok)
 	case "get_content":
 		var resp browseRespContent
-		if b, err := n.Store.GetContent(req.CID); err == nil {
-			resp = browseRespContent{Ok: true, Content: b}
+		if done, ok := n.admitServe(from); !ok {
+			resp.Busy = true
+		} else {
+			defer done()
+			if n.Store.IsDenied(req.CID) {
+				n.audit(AuditEntry{Kind: AuditBrowse, Peer: from.String(), ContentCID: req.CID, Reason: ErrDenied.Error()})
+			} else if b, err := n.Store.GetContent(req.CID); err == nil && b != nil {
+				resp = browseRespContent{Ok: true, Content: b}
+			}
 		}
 		bb, _ := cborMarshal(resp)
 		if _, err := s.Write(bb); err != nil {
 			log.Printf("handleBrowseStream: write failed: %v", err)
+		} else if resp.Ok {
+			n.addBandwidth(from, int64(len(resp.Content)), 0)
 		}
 		log.Printf("handleBrowseStream: sent content response ok=%v size=%d", resp.Ok, len(resp.Content))
+	case "get_manifest", "get_file_record":
+		n.serveSiteRecord(s, from, req)
+	case "resolve_name":
+		n.serveName(s, from, req)
+	case "get_record":
+		n.serveUpdateRecord(s, from, req)
+	case "get_missing":
+		n.serveMissingRecords(s, from, req)
+	default:
+		n.audit(AuditEntry{Kind: AuditBrowse, Peer: from.String(), Reason: "unknown request type " + strconv.Quote(req.Type)})
 	}
 }
 
@@ -656,22 +993,22 @@ func (n *Node) RequestHead(ctx context.Context, p peer.AddrInfo, siteID string)
 	}
 
 	log.Printf("RequestHead: reading response")
-	dec, _ := cbor.DecOptions{}.DecMode()
 	var resp browseRespHead
 	respBytes := readAllWithTimeout(s, 5*time.Second)
 	log.Printf("RequestHead: got %d bytes response", len(respBytes))
 	if len(respBytes) == 0 {
 		return 0, "", "", errors.New("no response data")
 	}
-	if err := dec.Unmarshal(respBytes, &resp); err != nil {
+	if err := core.UnmarshalCBOR(respBytes, &resp); err != nil {
 		log.Printf("RequestHead: unmarshal failed: %v", err)
 		return 0, "", "", err
 	}
 	if !resp.Ok {
 		log.Printf("RequestHead: server returned not ok")
-		return 0, "", "", errors.New("not found")
+		return 0, "", "", ErrHeadNotFound
 	}
 	log.Printf("RequestHead: success - seq=%d headCID=%s contentCID=%s", resp.Seq, resp.HeadCID, resp.ContentCID)
+	n.heads.put(siteID, HeadInfo{Seq: resp.Seq, HeadCID: resp.HeadCID, ContentCID: resp.ContentCID, Peer: p.ID})
 	return resp.Seq, resp.HeadCID, resp.ContentCID, nil
 }
 
@@ -708,38 +1045,53 @@ func (n *Node) RequestContent(ctx context.Context, p peer.AddrInfo, cid string)
 			log.Printf("RequestContent: failed to close write side: %v", err)
 		}
 	}
-
-	dec, _ := cbor.DecOptions{}.DecMode()
 	var resp browseRespContent
 	respBytes := readAllWithTimeout(s, 5*time.Second)
 	if len(respBytes) == 0 {
 		return nil, errors.New("no response data")
 	}
-	if err := dec.Unmarshal(respBytes, &resp); err != nil {
+	if err := core.UnmarshalCBOR(respBytes, &resp); err != nil {
 		return nil, err
 	}
+	if resp.Busy {
+		return nil, errors.New("peer busy")
+	}
 	if !resp.Ok {
 		return nil, errors.New("not found")
 	}
+	if core.CIDForContent(resp.Content) == cid {
+		n.addBandwidth(p.ID, 0, int64(len(resp.Content)))
+	}
 	return resp.Content, nil
 }
 
+// BuildUpdate signs update seq like SignUpdate, in record format v2 once
+// it is active.
 func (n *Node) BuildUpdate(sitePriv ed25519.PrivateKey, sitePub ed25519.PublicKey, content []byte, seq uint64, prevRecCID string) (*GossipUpdate, string, error) {
+	rec := core.UpdateRecord{Version: core.RecordV1, Seq: seq, PrevCID: prevRecCID, TS: time.Now().Unix()}
+	if n.Active(FeatureRecordV2, rec.TS) {
+		rec.Version = core.RecordV2
+	}
+	return signUpdate(sitePriv, content, rec)
+}
+
+// SignUpdate builds the gossip envelope of update seq of the site owning
+// sitePriv, chained to prevRecCID, and returns it with the record CID. The
+// record has format v1, which every node accepts.
+func SignUpdate(sitePriv ed25519.PrivateKey, content []byte, seq uint64, prevRecCID string) (*GossipUpdate, string, error) {
+	return signUpdate(sitePriv, content, core.UpdateRecord{Version: core.RecordV1, Seq: seq, PrevCID: prevRecCID, TS: time.Now().Unix()})
+}
+
+// signUpdate completes rec for content and signs it.
+func signUpdate(sitePriv ed25519.PrivateKey, content []byte, rec core.UpdateRecord) (*GossipUpdate, string, error) {
+	sitePub := sitePriv.Public().(ed25519.PublicKey)
 	upPub, upPriv, err := bncrypto.GenerateUpdateKey()
 	if err != nil {
 		return nil, "", err
 	}
-	ts := time.Now().Unix()
-	contentCID := core.CIDForContent(content)
-	rec := core.UpdateRecord{
-		Version:    "v1",
-		SitePub:    sitePub,
-		Seq:        seq,
-		PrevCID:    prevRecCID,
-		ContentCID: contentCID,
-		TS:         ts,
-		UpdatePub:  upPub,
-	}
+	rec.SitePub = sitePub
+	rec.ContentCID = core.CIDForContent(content)
+	rec.UpdatePub = upPub
 	linkPre := bncrypto.PreimageLink(sitePub, upPub, rec.Seq, rec.PrevCID, rec.ContentCID, rec.TS)
 	rec.LinkSig = ed25519.Sign(sitePriv, linkPre)
 	noUS, err := core.CanonicalMarshalNoUpdateSig(&rec)
@@ -779,13 +1131,10 @@ func (n *Node) validatePeer(peerID peer.ID) error {
 	n.mu.RLock()
 	defer n.mu.RUnlock()
 
-	// Check if peer is banned
-	if banTime, banned := n.bannedPeers[peerID]; banned {
-		if time.Now().Before(banTime) {
-			return fmt.Errorf("peer is banned until %v", banTime)
-		}
-		// Ban expired, remove it
-		delete(n.bannedPeers, peerID)
+	// Check if peer is banned; expired bans are removed by
+	// cleanupExpiredBans, this only holds the read lock
+	if banTime, banned := n.bannedPeers[peerID]; banned && time.Now().Before(banTime) {
+		return fmt.Errorf("peer is banned until %v", banTime)
 	}
 
 	// Check peer reputation
@@ -856,6 +1205,7 @@ func (n *Node) memoryManagement(ctx context.Context) {
 			return
 		case <-ticker.C:
 			n.cleanupOldContent()
+			n.rateLimiter.prune(time.Now())
 		}
 	}
 }
@@ -908,15 +1258,38 @@ func (n *Node) handlePeerConnected(net network.Network, conn network.Conn) {
 		n.logger.Warn("rejected connection from invalid peer",
 			zap.String("peer", peerID.String()),
 			zap.Error(err))
+		n.audit(AuditEntry{Kind: AuditConnection, Peer: peerID.String(), Reason: err.Error()})
+		conn.Close()
+		return
+	}
+
+	if n.overPeerLimit(net, peerID) {
+		n.logger.Warn("rejected connection over the peer limit",
+			zap.String("peer", peerID.String()),
+			zap.Int64("max_peers", n.maxPeers.Load()))
+		n.audit(AuditEntry{Kind: AuditConnection, Peer: peerID.String(), Reason: "over the peer limit"})
 		conn.Close()
 		return
 	}
 
 	n.updatePeerReputation(peerID, 1)
 	n.logger.Info("peer connected", zap.String("peer", peerID.String()))
+
+	go func() {
+		if err := n.helloPeer(context.Background(), peerID); err != nil {
+			n.logger.Warn("peer handshake failed",
+				zap.String("peer", peerID.String()),
+				zap.Error(err))
+			return
+		}
+		n.rememberPeer(peerID)
+	}()
 }
 
 func (n *Node) handlePeerDisconnected(net network.Network, conn network.Conn) {
 	peerID := conn.RemotePeer()
+	if net.Connectedness(peerID) != network.Connected {
+		n.forgetCapabilities(peerID)
+	}
 	n.logger.Info("peer disconnected", zap.String("peer", peerID.String()))
 }
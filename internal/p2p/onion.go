@@ -0,0 +1,316 @@
+package p2p
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// A node can publish its TCP listener as a Tor onion service, so peers on
+// Tor reach it without learning its IP address. The service is added over
+// Tor's control port with ADD_ONION and lasts as long as the control
+// connection, which the node keeps open until its context ends. The node
+// then announces only the /onion3 address.
+
+// DefaultTorControlAddr is where Tor's control port listens by default.
+const DefaultTorControlAddr = "127.0.0.1:9051"
+
+// torControlTimeout bounds each exchange with the control port.
+const torControlTimeout = 30 * time.Second
+
+// onionKeyPrefix starts a stored onion service key, in ADD_ONION's syntax.
+const onionKeyPrefix = "ED25519-V3:"
+
+// OnionServiceConfig publishes the node as an onion service.
+type OnionServiceConfig struct {
+	// ControlAddr is Tor's control port, DefaultTorControlAddr if empty
+	ControlAddr string
+	// ControlPassword authenticates with HashedControlPassword; if empty,
+	// the cookie file Tor names is read, or no authentication is used
+	// when Tor asks for none
+	ControlPassword string
+	// KeyFile keeps the service's private key so its address survives
+	// restarts; it is created on first use. Empty makes a new address
+	// each time.
+	KeyFile string
+	// Port is the onion service's port; zero uses the node's TCP port
+	Port int
+}
+
+// torControl is a connection to Tor's control port.
+type torControl struct {
+	conn net.Conn
+	text *textproto.Conn
+}
+
+func dialTorControl(ctx context.Context, addr string) (*torControl, error) {
+	if addr == "" {
+		addr = DefaultTorControlAddr
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("tor control port: %w", err)
+	}
+	return &torControl{conn: conn, text: textproto.NewConn(conn)}, nil
+}
+
+// cmd sends a command and returns the lines of its 250 reply without the
+// status code.
+func (c *torControl) cmd(format string, args ...interface{}) ([]string, error) {
+	if err := c.conn.SetDeadline(time.Now().Add(torControlTimeout)); err != nil {
+		return nil, err
+	}
+	defer c.conn.SetDeadline(time.Time{})
+	if _, err := c.text.Cmd(format, args...); err != nil {
+		return nil, err
+	}
+	_, msg, err := c.text.ReadResponse(250)
+	if err != nil {
+		return nil, fmt.Errorf("tor control port: %w", err)
+	}
+	return strings.Split(msg, "\n"), nil
+}
+
+func (c *torControl) Close() error { return c.text.Close() }
+
+// authenticate logs in with password if set, otherwise the way PROTOCOLINFO
+// offers: no authentication or the cookie file.
+func (c *torControl) authenticate(password string) error {
+	if password != "" {
+		_, err := c.cmd("AUTHENTICATE %s", quoteTorString(password))
+		return err
+	}
+	lines, err := c.cmd("PROTOCOLINFO 1")
+	if err != nil {
+		return err
+	}
+	methods, cookieFile := parseProtocolInfo(lines)
+	switch {
+	case methods["NULL"]:
+		_, err = c.cmd("AUTHENTICATE")
+		return err
+	case methods["COOKIE"] && cookieFile != "":
+		cookie, err := readTorCookie(cookieFile)
+		if err != nil {
+			return err
+		}
+		_, err = c.cmd("AUTHENTICATE %s", hex.EncodeToString(cookie))
+		return err
+	}
+	return errors.New("tor control port needs a password (HashedControlPassword)")
+}
+
+// parseProtocolInfo returns the authentication methods and cookie file of
+// a PROTOCOLINFO reply, whose AUTH line reads
+//
+//	AUTH METHODS=COOKIE,SAFECOOKIE COOKIEFILE="/run/tor/control.authcookie"
+func parseProtocolInfo(lines []string) (map[string]bool, string) {
+	methods := make(map[string]bool)
+	var cookieFile string
+	for _, line := range lines {
+		rest, ok := strings.CutPrefix(line, "AUTH ")
+		if !ok {
+			continue
+		}
+		if i := strings.Index(rest, "METHODS="); i >= 0 {
+			list, _, _ := strings.Cut(rest[i+len("METHODS="):], " ")
+			for _, m := range strings.Split(list, ",") {
+				methods[m] = true
+			}
+		}
+		if i := strings.Index(rest, "COOKIEFILE="); i >= 0 {
+			if f, err := strconv.Unquote(rest[i+len("COOKIEFILE="):]); err == nil {
+				cookieFile = f
+			}
+		}
+	}
+	return methods, cookieFile
+}
+
+// readTorCookie reads Tor's 32-byte authentication cookie.
+func readTorCookie(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("tor cookie: %w", err)
+	}
+	defer f.Close()
+	cookie := make([]byte, 33)
+	n, _ := f.Read(cookie)
+	if n != 32 {
+		return nil, fmt.Errorf("tor cookie %s is not 32 bytes", path)
+	}
+	return cookie[:n], nil
+}
+
+// quoteTorString quotes s as a control protocol QuotedString.
+func quoteTorString(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\r", `\r`, "\n", `\n`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// addOnion adds an onion service forwarding port to target and returns its
+// service ID and, when key was empty, the new key.
+func (c *torControl) addOnion(key string, port int, target string) (id, newKey string, err error) {
+	keyArg, flags := "NEW:ED25519-V3", ""
+	if key != "" {
+		keyArg, flags = key, " Flags=DiscardPK"
+	}
+	lines, err := c.cmd("ADD_ONION %s%s Port=%d,%s", keyArg, flags, port, target)
+	if err != nil {
+		return "", "", err
+	}
+	for _, line := range lines {
+		if v, ok := strings.CutPrefix(line, "ServiceID="); ok {
+			id = v
+		}
+		if v, ok := strings.CutPrefix(line, "PrivateKey="); ok {
+			newKey = v
+		}
+	}
+	if len(id) != 56 {
+		return "", "", fmt.Errorf("tor returned an invalid onion service ID %q", id)
+	}
+	if key == "" {
+		if err := checkOnionKey(newKey); err != nil {
+			return "", "", err
+		}
+	}
+	return id, newKey, nil
+}
+
+// checkOnionKey checks that key is an ED25519-V3 key in ADD_ONION's syntax:
+// the base64 of Tor's 64-byte expanded secret key.
+func checkOnionKey(key string) error {
+	b64, ok := strings.CutPrefix(key, onionKeyPrefix)
+	if !ok {
+		return errors.New("onion service key is not an ED25519-V3 key")
+	}
+	raw, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil || len(raw) != 64 {
+		return errors.New("onion service key is not a 64-byte base64 key")
+	}
+	return nil
+}
+
+// loadOnionKey returns the key in path, or "" if the file does not exist.
+func loadOnionKey(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	key := string(bytes.TrimSpace(b))
+	if err := checkOnionKey(key); err != nil {
+		return "", fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
+
+// saveOnionKey writes key to path, readable by the owner only.
+func saveOnionKey(path, key string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(key+"\n"), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// startOnionService publishes the TCP port of listenAddrs as an onion
+// service and returns its address. The service is removed when ctx ends.
+func startOnionService(ctx context.Context, cfg *OnionServiceConfig, listenAddrs []ma.Multiaddr) (ma.Multiaddr, error) {
+	target, localPort, err := onionTarget(listenAddrs)
+	if err != nil {
+		return nil, err
+	}
+	port := cfg.Port
+	if port == 0 {
+		port = localPort
+	}
+	if port < 1 || port > 65535 {
+		return nil, fmt.Errorf("invalid onion service port %d", port)
+	}
+	key, err := loadOnionKey(cfg.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	dialCtx, cancel := context.WithTimeout(ctx, torControlTimeout)
+	defer cancel()
+	c, err := dialTorControl(dialCtx, cfg.ControlAddr)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.authenticate(cfg.ControlPassword); err != nil {
+		c.Close()
+		return nil, err
+	}
+	id, newKey, err := c.addOnion(key, port, target)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	if key == "" && cfg.KeyFile != "" {
+		if err := saveOnionKey(cfg.KeyFile, newKey); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("saving onion service key: %w", err)
+		}
+	}
+	addr, err := ma.NewMultiaddr(fmt.Sprintf("/onion3/%s:%d", id, port))
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	go func() {
+		<-ctx.Done()
+		c.Close()
+	}()
+	return addr, nil
+}
+
+// onionTarget returns the loopback address Tor forwards the service to,
+// from the node's first TCP listen address.
+func onionTarget(listenAddrs []ma.Multiaddr) (string, int, error) {
+	for _, a := range listenAddrs {
+		port, err := a.ValueForProtocol(ma.P_TCP)
+		if err != nil {
+			continue
+		}
+		p, err := strconv.Atoi(port)
+		if err != nil {
+			continue
+		}
+		host := "127.0.0.1"
+		if ip, err := a.ValueForProtocol(ma.P_IP4); err == nil && ip != "0.0.0.0" {
+			host = ip
+		}
+		if ip, err := a.ValueForProtocol(ma.P_IP6); err == nil {
+			host = "::1"
+			if ip != "::" {
+				host = ip
+			}
+		}
+		return net.JoinHostPort(host, port), p, nil
+	}
+	return "", 0, errors.New("an onion service needs a TCP listen address")
+}
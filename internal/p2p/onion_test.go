@@ -0,0 +1,230 @@
+package p2p
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"alxnet/internal/store"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+func TestParseProtocolInfo(t *testing.T) {
+	tests := []struct {
+		name    string
+		lines   []string
+		methods []string
+		cookie  string
+	}{
+		{"cookie", []string{"PROTOCOLINFO 1", `AUTH METHODS=COOKIE,SAFECOOKIE COOKIEFILE="/run/tor/control.authcookie"`, `VERSION Tor="0.4.8.9"`, "OK"},
+			[]string{"COOKIE", "SAFECOOKIE"}, "/run/tor/control.authcookie"},
+		{"escaped path", []string{`AUTH METHODS=COOKIE COOKIEFILE="C:\\Tor\\cookie"`}, []string{"COOKIE"}, `C:\Tor\cookie`},
+		{"null", []string{"AUTH METHODS=NULL"}, []string{"NULL"}, ""},
+		{"password", []string{"AUTH METHODS=HASHEDPASSWORD"}, []string{"HASHEDPASSWORD"}, ""},
+		{"no auth line", []string{"PROTOCOLINFO 1", "OK"}, nil, ""},
+	}
+	for _, tt := range tests {
+		methods, cookie := parseProtocolInfo(tt.lines)
+		if len(methods) != len(tt.methods) || cookie != tt.cookie {
+			t.Errorf("%s: parseProtocolInfo = %v, %q; want %v, %q", tt.name, methods, cookie, tt.methods, tt.cookie)
+		}
+		for _, m := range tt.methods {
+			if !methods[m] {
+				t.Errorf("%s: method %s missing", tt.name, m)
+			}
+		}
+	}
+
+	if got := quoteTorString("pa\"ss\\word\n"); got != `"pa\"ss\\word\n"` {
+		t.Errorf("quoteTorString = %s", got)
+	}
+}
+
+func TestCheckOnionKey(t *testing.T) {
+	key := onionKeyPrefix + base64.StdEncoding.EncodeToString(make([]byte, 64))
+	tests := []struct {
+		key string
+		ok  bool
+	}{
+		{key, true},
+		{"RSA1024:" + base64.StdEncoding.EncodeToString(make([]byte, 64)), false},
+		{onionKeyPrefix + base64.StdEncoding.EncodeToString(make([]byte, 32)), false},
+		{onionKeyPrefix + "not base64!", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if err := checkOnionKey(tt.key); (err == nil) != tt.ok {
+			t.Errorf("checkOnionKey(%q) = %v, want ok %v", tt.key, err, tt.ok)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "onion_key")
+	if got, err := loadOnionKey(path); got != "" || err != nil {
+		t.Errorf("loadOnionKey(missing) = %q, %v", got, err)
+	}
+	if err := saveOnionKey(path, key); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := loadOnionKey(path); got != key || err != nil {
+		t.Errorf("loadOnionKey = %q, %v; want the saved key", got, err)
+	}
+	if fi, _ := os.Stat(path); fi.Mode().Perm() != 0600 {
+		t.Errorf("key file mode %v, want 0600", fi.Mode().Perm())
+	}
+	os.WriteFile(path, []byte("garbage"), 0600)
+	if _, err := loadOnionKey(path); err == nil {
+		t.Error("loadOnionKey accepted a corrupt key file")
+	}
+}
+
+// torControlServer speaks enough of Tor's control protocol to add onion
+// services, authenticating with a cookie file.
+type torControlServer struct {
+	ln     net.Listener
+	cookie []byte
+	mu     sync.Mutex
+	added  []string // ADD_ONION commands
+}
+
+func newTorControlServer(t *testing.T) *torControlServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &torControlServer{ln: ln, cookie: make([]byte, 32)}
+	rand.Read(s.cookie)
+	cookieFile := filepath.Join(t.TempDir(), "control.authcookie")
+	if err := os.WriteFile(cookieFile, s.cookie, 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c, cookieFile)
+		}
+	}()
+	return s
+}
+
+func (s *torControlServer) serve(c net.Conn, cookieFile string) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	authed := false
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd, args, _ := strings.Cut(line, " ")
+		switch {
+		case cmd == "PROTOCOLINFO":
+			fmt.Fprintf(c, "250-PROTOCOLINFO 1\r\n250-AUTH METHODS=COOKIE,SAFECOOKIE COOKIEFILE=%q\r\n250-VERSION Tor=\"0.4.8.9\"\r\n250 OK\r\n", cookieFile)
+		case cmd == "AUTHENTICATE":
+			if args != hex.EncodeToString(s.cookie) {
+				fmt.Fprintf(c, "515 Authentication failed: Wrong length on authentication cookie.\r\n")
+				return
+			}
+			authed = true
+			fmt.Fprintf(c, "250 OK\r\n")
+		case !authed:
+			fmt.Fprintf(c, "514 Authentication required.\r\n")
+			return
+		case cmd == "ADD_ONION":
+			s.mu.Lock()
+			s.added = append(s.added, args)
+			s.mu.Unlock()
+			fmt.Fprintf(c, "250-ServiceID=%s\r\n", testOnionID)
+			if strings.HasPrefix(args, "NEW:") {
+				key := make([]byte, 64)
+				rand.Read(key)
+				fmt.Fprintf(c, "250-PrivateKey=%s%s\r\n", onionKeyPrefix, base64.StdEncoding.EncodeToString(key))
+			}
+			fmt.Fprintf(c, "250 OK\r\n")
+		default:
+			fmt.Fprintf(c, "510 Unrecognized command\r\n")
+		}
+	}
+}
+
+func (s *torControlServer) lastAdded() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.added) == 0 {
+		return ""
+	}
+	return s.added[len(s.added)-1]
+}
+
+func TestOnionService(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	tor := newTorControlServer(t)
+	keyFile := filepath.Join(t.TempDir(), "onion_key")
+
+	db, err := store.NewMemory()
+	if err != nil {
+		t.Fatalf("store.NewMemory: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	cfg := DefaultNodeConfig()
+	cfg.OnionService = &OnionServiceConfig{ControlAddr: tor.ln.Addr().String(), KeyFile: keyFile, Port: 4001}
+	n, err := New(ctx, db, "/ip4/127.0.0.1/tcp/0", nil, cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { n.Host.Close() })
+
+	// The node announces the onion address only
+	if addrs := n.Host.Addrs(); len(addrs) != 1 || addrs[0].String() != "/onion3/"+testOnionID+":4001" {
+		t.Errorf("Addrs = %v, want the onion address only", addrs)
+	}
+	var localPort string
+	for _, a := range n.Host.Network().ListenAddresses() {
+		if p, err := a.ValueForProtocol(ma.P_TCP); err == nil {
+			localPort = p
+		}
+	}
+	if got, want := tor.lastAdded(), "NEW:ED25519-V3 Port=4001,127.0.0.1:"+localPort; got != want {
+		t.Errorf("ADD_ONION %s, want %s", got, want)
+	}
+
+	// The saved key brings the same service back
+	key, err := loadOnionKey(keyFile)
+	if err != nil || key == "" {
+		t.Fatalf("loadOnionKey = %q, %v", key, err)
+	}
+	if _, err := startOnionService(ctx, cfg.OnionService, n.Host.Network().ListenAddresses()); err != nil {
+		t.Fatalf("startOnionService: %v", err)
+	}
+	if got := tor.lastAdded(); !strings.HasPrefix(got, key+" Flags=DiscardPK Port=4001,") {
+		t.Errorf("ADD_ONION %s, want the saved key", got)
+	}
+	if b, _ := os.ReadFile(keyFile); !bytes.Equal(bytes.TrimSpace(b), []byte(key)) {
+		t.Error("restarting the service rewrote its key")
+	}
+
+	// A wrong cookie fails the node rather than leaving it unreachable
+	tor.cookie = make([]byte, 32)
+	cfg.OnionService.KeyFile = ""
+	if _, err := New(ctx, db, "/ip4/127.0.0.1/tcp/0", nil, cfg); err == nil || !strings.Contains(err.Error(), "onion") {
+		t.Errorf("New with a wrong cookie: %v", err)
+	}
+}
@@ -0,0 +1,163 @@
+package p2p
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"alxnet/internal/store"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+	"go.uber.org/zap"
+)
+
+// Peers the node completed a handshake with are remembered in the store
+// (see store.KnownPeer). At start, and while it has few connections, the
+// node dials the remembered peers it has reached most recently. A peer
+// whose dial fails is left alone for a backoff that doubles with every
+// failure, and one unreachable for KnownPeerTTL is forgotten.
+
+// Known peer dialling
+const (
+	KnownPeerDialInterval = 2 * time.Minute     // how often the connection count is checked
+	KnownPeerTarget       = 8                   // connections below which known peers are dialled
+	KnownPeerMinBackoff   = 30 * time.Second    // wait after the first failed dial
+	KnownPeerMaxBackoff   = 6 * time.Hour       // longest wait between dials
+	KnownPeerTTL          = 30 * 24 * time.Hour // unreachable this long and the peer is forgotten
+	knownPeerDialTimeout  = 10 * time.Second
+)
+
+// KnownPeerBackoff returns how long to wait before dialling a peer again
+// after failures failed dials in a row.
+func KnownPeerBackoff(failures int) time.Duration {
+	if failures <= 0 {
+		return 0
+	}
+	d := KnownPeerMinBackoff
+	for i := 1; i < failures && d < KnownPeerMaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, KnownPeerMaxBackoff)
+}
+
+// rememberPeer stores p's addresses as reached now.
+func (n *Node) rememberPeer(p peer.ID) {
+	var addrs []string
+	for _, a := range n.Host.Peerstore().Addrs(p) {
+		if len(addrs) == store.MaxKnownPeerAddrs {
+			break
+		}
+		addrs = append(addrs, a.String())
+	}
+	if len(addrs) == 0 {
+		return // nothing to dial it back on
+	}
+	kp := &store.KnownPeer{ID: p.String(), Addrs: addrs, LastSeen: time.Now().Unix()}
+	if err := n.Store.PutKnownPeer(kp); err != nil {
+		n.logger.Debug("failed to remember peer", zap.String("peer", Short(p.String())), zap.Error(err))
+	}
+}
+
+// dialKnownPeers dials remembered peers that are due, most recently
+// reached first, until the node would have KnownPeerTarget connections.
+// It returns how many it connected to.
+func (n *Node) dialKnownPeers(ctx context.Context) int {
+	want := KnownPeerTarget - len(n.Host.Network().Peers())
+	if want <= 0 {
+		return 0
+	}
+	known, err := n.Store.KnownPeers()
+	if err != nil {
+		n.logger.Warn("failed to read known peers", zap.Error(err))
+		return 0
+	}
+
+	now := time.Now()
+	var due []store.KnownPeer
+	for _, kp := range known {
+		if len(due) == want {
+			break
+		}
+		id, err := peer.Decode(kp.ID)
+		if err != nil {
+			_ = n.Store.DeleteKnownPeer(kp.ID)
+			continue
+		}
+		if id == n.Host.ID() || kp.NextDial > now.Unix() || n.validatePeer(id) != nil ||
+			n.Host.Network().Connectedness(id) == network.Connected {
+			continue
+		}
+		due = append(due, kp)
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	connected := 0
+	for _, kp := range due {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if n.dialKnownPeer(ctx, kp) {
+				mu.Lock()
+				connected++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return connected
+}
+
+// dialKnownPeer dials kp and records the outcome.
+func (n *Node) dialKnownPeer(ctx context.Context, kp store.KnownPeer) bool {
+	id, _ := peer.Decode(kp.ID)
+	info := peer.AddrInfo{ID: id}
+	for _, s := range kp.Addrs {
+		if a, err := ma.NewMultiaddr(s); err == nil {
+			info.Addrs = append(info.Addrs, a)
+		}
+	}
+	cctx, cancel := context.WithTimeout(ctx, knownPeerDialTimeout)
+	defer cancel()
+	err := n.Host.Connect(cctx, info)
+	if ctx.Err() != nil {
+		return false // shutting down, not the peer's fault
+	}
+	now := time.Now()
+	if err == nil {
+		kp.LastSeen, kp.Failures, kp.NextDial = now.Unix(), 0, 0
+	} else {
+		if now.Sub(time.Unix(kp.LastSeen, 0)) > KnownPeerTTL {
+			_ = n.Store.DeleteKnownPeer(kp.ID)
+			return false
+		}
+		kp.Failures++
+		kp.NextDial = now.Add(KnownPeerBackoff(kp.Failures)).Unix()
+		n.logger.Debug("known peer unreachable", zap.String("peer", Short(kp.ID)),
+			zap.Int("failures", kp.Failures), zap.Error(err))
+	}
+	if perr := n.Store.PutKnownPeer(&kp); perr != nil {
+		n.logger.Debug("failed to update known peer", zap.String("peer", Short(kp.ID)), zap.Error(perr))
+	}
+	return err == nil
+}
+
+// reconnectKnownPeers dials known peers at start and whenever the node
+// runs low on connections, until ctx is done.
+func (n *Node) reconnectKnownPeers(ctx context.Context) {
+	if c := n.dialKnownPeers(ctx); c > 0 {
+		n.logger.Info("reconnected to known peers", zap.Int("peers", c))
+	}
+	ticker := time.NewTicker(KnownPeerDialInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n.dialKnownPeers(ctx)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package p2p
+
+import (
+	"context"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"alxnet/internal/store"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestKnownPeerBackoff(t *testing.T) {
+	for _, tt := range []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{1, KnownPeerMinBackoff},
+		{2, 2 * KnownPeerMinBackoff},
+		{4, 8 * KnownPeerMinBackoff},
+		{40, KnownPeerMaxBackoff},
+	} {
+		if got := KnownPeerBackoff(tt.failures); got != tt.want {
+			t.Errorf("KnownPeerBackoff(%d) = %s, want %s", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestDialKnownPeers(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	live := newTestNode(t, ctx)
+	n := newTestNode(t, ctx)
+
+	// A peer this node talked to before is remembered with its addresses
+	if err := n.Host.Connect(ctx, peer.AddrInfo{ID: live.Host.ID(), Addrs: live.Host.Addrs()}); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	n.rememberPeer(live.Host.ID())
+	if kp, err := n.Store.GetKnownPeer(live.Host.ID().String()); err != nil || kp == nil || len(kp.Addrs) == 0 {
+		t.Fatalf("GetKnownPeer() after rememberPeer = %+v, %v", kp, err)
+	}
+	_ = n.Host.Network().ClosePeer(live.Host.ID())
+
+	// and one whose address is dead
+	_, pub, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadID, _ := peer.IDFromPublicKey(pub)
+	dead := &store.KnownPeer{ID: deadID.String(), Addrs: []string{"/ip4/127.0.0.1/tcp/1"}, LastSeen: time.Now().Add(-time.Hour).Unix()}
+	if err := n.Store.PutKnownPeer(dead); err != nil {
+		t.Fatalf("PutKnownPeer: %v", err)
+	}
+
+	if got := n.dialKnownPeers(ctx); got != 1 {
+		t.Errorf("dialKnownPeers() = %d, want 1", got)
+	}
+	if n.Host.Network().Connectedness(live.Host.ID()) != network.Connected {
+		t.Error("not reconnected to the live peer")
+	}
+	kp, _ := n.Store.GetKnownPeer(deadID.String())
+	if kp == nil || kp.Failures != 1 || kp.NextDial <= time.Now().Unix() {
+		t.Fatalf("dead peer after a failed dial = %+v, want 1 failure and a backoff", kp)
+	}
+
+	// Backing off, the dead peer is not dialled again
+	_ = n.Host.Network().ClosePeer(live.Host.ID())
+	n.dialKnownPeers(ctx)
+	if again, _ := n.Store.GetKnownPeer(deadID.String()); again.Failures != 1 {
+		t.Errorf("dead peer dialled during its backoff: %+v", again)
+	}
+
+	// and once unreachable for too long it is forgotten
+	kp.NextDial, kp.LastSeen = 0, time.Now().Add(-KnownPeerTTL-time.Hour).Unix()
+	if err := n.Store.PutKnownPeer(kp); err != nil {
+		t.Fatal(err)
+	}
+	n.dialKnownPeers(ctx)
+	if gone, _ := n.Store.GetKnownPeer(deadID.String()); gone != nil {
+		t.Errorf("peer unreachable past KnownPeerTTL kept: %+v", gone)
+	}
+}
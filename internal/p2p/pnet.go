@@ -0,0 +1,105 @@
+package p2p
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// A private network is a set of nodes sharing a 32-byte pre-shared key, in
+// the swarm.key format IPFS uses. Every connection is encrypted with the
+// key before the libp2p handshake, so nodes without it cannot connect at
+// all, let alone read gossip or fetch content. libp2p then runs TCP and
+// WebSocket only; QUIC and WebRTC do not support private networks.
+
+// SwarmKeySize is the length of a private network key.
+const SwarmKeySize = 32
+
+// maxSwarmKeyFile bounds swarm.key files; a base16 key takes 88 bytes.
+const maxSwarmKeyFile = 1024
+
+// GenerateSwarmKey returns a new random private network key.
+func GenerateSwarmKey() ([]byte, error) {
+	key := make([]byte, SwarmKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// EncodeSwarmKey returns key in the swarm.key file format, base16 encoded.
+func EncodeSwarmKey(key []byte) []byte {
+	return []byte("/key/swarm/psk/1.0.0/\n/base16/\n" + hex.EncodeToString(key) + "\n")
+}
+
+// DecodeSwarmKey parses a swarm.key file: a header line, an encoding line
+// (/base16/, /base64/ or /bin/) and the key in that encoding.
+func DecodeSwarmKey(data []byte) ([]byte, error) {
+	if len(data) > maxSwarmKeyFile {
+		return nil, fmt.Errorf("swarm key too large: %d bytes", len(data))
+	}
+	header, rest, _ := bytes.Cut(data, []byte("\n"))
+	encoding, body, _ := bytes.Cut(rest, []byte("\n"))
+	if string(bytes.TrimSuffix(header, []byte("\r"))) != "/key/swarm/psk/1.0.0/" {
+		return nil, errors.New("invalid swarm key: not a /key/swarm/psk/1.0.0/ file")
+	}
+	var key []byte
+	var err error
+	switch enc := string(bytes.TrimSuffix(encoding, []byte("\r"))); enc {
+	case "/base16/":
+		key, err = hex.DecodeString(string(bytes.TrimSpace(body)))
+	case "/base64/":
+		key, err = base64.StdEncoding.DecodeString(string(bytes.TrimSpace(body)))
+	case "/bin/":
+		key = body
+	default:
+		return nil, fmt.Errorf("invalid swarm key: unknown encoding %q", enc)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid swarm key: %w", err)
+	}
+	if len(key) != SwarmKeySize {
+		return nil, fmt.Errorf("invalid swarm key: %d bytes, want %d", len(key), SwarmKeySize)
+	}
+	return key, nil
+}
+
+// LoadSwarmKey reads the swarm.key file at path.
+func LoadSwarmKey(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxSwarmKeyFile+1))
+	if err != nil {
+		return nil, err
+	}
+	key, err := DecodeSwarmKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
+
+// SwarmKeyFingerprint identifies a private network without revealing its
+// key, so operators can compare the networks of two nodes.
+func SwarmKeyFingerprint(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// PrivateNetwork returns the fingerprint of the node's private network key,
+// or "" on the public network.
+func (n *Node) PrivateNetwork() string {
+	if len(n.config.PrivateNetworkKey) == 0 {
+		return ""
+	}
+	return SwarmKeyFingerprint(n.config.PrivateNetworkKey)
+}
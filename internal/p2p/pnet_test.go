@@ -0,0 +1,124 @@
+package p2p
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"alxnet/internal/store"
+
+	peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestDecodeSwarmKey(t *testing.T) {
+	key := bytes.Repeat([]byte{0xab}, SwarmKeySize)
+	const header = "/key/swarm/psk/1.0.0/\n"
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{"base16", string(EncodeSwarmKey(key)), ""},
+		{"base16 crlf", strings.ReplaceAll(string(EncodeSwarmKey(key)), "\n", "\r\n"), ""},
+		{"base64", header + "/base64/\n" + base64.StdEncoding.EncodeToString(key) + "\n", ""},
+		{"bin", header + "/bin/\n" + string(key), ""},
+		{"no newline", strings.TrimSuffix(string(EncodeSwarmKey(key)), "\n"), ""},
+		{"wrong header", "/key/swarm/psk/2.0.0/\n/base16/\n" + hex.EncodeToString(key), "invalid"},
+		{"unknown encoding", header + "/base32/\n" + hex.EncodeToString(key), "invalid"},
+		{"short key", header + "/base16/\n" + hex.EncodeToString(key[:16]), "invalid"},
+		{"not hex", header + "/base16/\n" + strings.Repeat("zz", SwarmKeySize), "invalid"},
+		{"trailing data", string(EncodeSwarmKey(key)) + "deadbeef\n", "invalid"},
+		{"too large", string(EncodeSwarmKey(key)) + strings.Repeat("\n", maxSwarmKeyFile), "too large"},
+		{"empty", "", "invalid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeSwarmKey([]byte(tt.data))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("DecodeSwarmKey error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || !bytes.Equal(got, key) {
+				t.Fatalf("DecodeSwarmKey = %x, %v; want %x", got, err, key)
+			}
+		})
+	}
+
+	generated, err := GenerateSwarmKey()
+	if err != nil || len(generated) != SwarmKeySize {
+		t.Fatalf("GenerateSwarmKey = %x, %v", generated, err)
+	}
+	path := filepath.Join(t.TempDir(), "swarm.key")
+	if err := os.WriteFile(path, EncodeSwarmKey(generated), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, err := LoadSwarmKey(path); err != nil || !bytes.Equal(loaded, generated) {
+		t.Errorf("LoadSwarmKey = %x, %v; want %x", loaded, err, generated)
+	}
+	if SwarmKeyFingerprint(generated) == SwarmKeyFingerprint(key) || len(SwarmKeyFingerprint(key)) != 16 {
+		t.Error("fingerprints do not tell keys apart")
+	}
+}
+
+func newPrivateNode(t *testing.T, ctx context.Context, key []byte) *Node {
+	t.Helper()
+	db, err := store.NewMemory()
+	if err != nil {
+		t.Fatalf("store.NewMemory: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	cfg := DefaultNodeConfig()
+	cfg.PrivateNetworkKey = key
+	n, err := New(ctx, db, "/ip4/127.0.0.1/tcp/0", nil, cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { n.Host.Close() })
+	return n
+}
+
+func TestPrivateNetwork(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	key, _ := GenerateSwarmKey()
+	otherKey, _ := GenerateSwarmKey()
+	member := newPrivateNode(t, ctx, key)
+
+	tests := []struct {
+		name string
+		key  []byte
+		ok   bool
+	}{
+		{"same key", key, true},
+		{"other key", otherKey, false},
+		{"public node", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newPrivateNode(t, ctx, tt.key)
+			cctx, ccancel := context.WithTimeout(ctx, 3*time.Second)
+			defer ccancel()
+			err := n.Host.Connect(cctx, peer.AddrInfo{ID: member.Host.ID(), Addrs: member.Host.Addrs()})
+			if (err == nil) != tt.ok {
+				t.Errorf("Connect = %v, want success %v", err, tt.ok)
+			}
+			if got, want := n.PrivateNetwork() == member.PrivateNetwork(), tt.ok; got != want {
+				t.Errorf("PrivateNetwork() = %q, member's %q", n.PrivateNetwork(), member.PrivateNetwork())
+			}
+		})
+	}
+
+	db, _ := store.NewMemory()
+	defer db.Close()
+	if _, err := New(ctx, db, "/ip4/127.0.0.1/tcp/0", nil, &NodeConfig{PrivateNetworkKey: key[:16]}); err == nil {
+		t.Error("New accepted a 16-byte private network key")
+	}
+}
@@ -0,0 +1,133 @@
+package p2p
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"log"
+	"time"
+
+	"alxnet/internal/core"
+	bncrypto "alxnet/internal/crypto"
+)
+
+// GossipPointer carries a pointer record.
+type GossipPointer struct {
+	Pointer []byte // canonical CBOR of PointerRecord
+}
+
+func init() {
+	RegisterRecordType(envelopedRecord("pointer", AuditPointer,
+		func(env *GossipPointer) []byte { return env.Pointer },
+		func(rec *core.PointerRecord, e *AuditEntry) {
+			e.SiteID, e.ContentCID = core.SiteIDFromPub(rec.OwnerPub), rec.Target
+		},
+		verifyPointer, (*Node).handlePointer))
+}
+
+// ErrPointerNotKept is returned for pointers of owners this node neither
+// stores nor follows; other nodes' pointers are not kept.
+var ErrPointerNotKept = errors.New("pointer owner not stored or followed")
+
+// BuildPointer signs a pointer from name to target with the owner's key.
+// seq must be higher than that of the owner's previous record for name.
+func BuildPointer(ownerPriv ed25519.PrivateKey, name, target string, seq uint64) (*core.PointerRecord, error) {
+	rec := &core.PointerRecord{
+		Version:  "v1",
+		OwnerPub: ownerPriv.Public().(ed25519.PublicKey),
+		Name:     name,
+		Target:   target,
+		Seq:      seq,
+		TS:       time.Now().Unix(),
+	}
+	b, err := core.CanonicalMarshalPointerNoSig(rec)
+	if err != nil {
+		return nil, err
+	}
+	rec.Sig = ed25519.Sign(ownerPriv, bncrypto.PreimagePointer(b))
+	return rec, rec.Validate()
+}
+
+// NextPointerSeq returns a sequence number for a new record of ownerID's
+// pointer name: the current time in milliseconds, or one past the stored
+// record if that is higher.
+func (n *Node) NextPointerSeq(ownerID, name string) (uint64, error) {
+	seq := uint64(time.Now().UnixMilli())
+	cur, err := n.Store.GetPointer(ownerID, name)
+	if err != nil {
+		return 0, err
+	}
+	if cur != nil && cur.Seq >= seq {
+		seq = cur.Seq + 1
+	}
+	return seq, nil
+}
+
+// verifyPointer checks structure and signature of a pointer record.
+func verifyPointer(rec *core.PointerRecord) error {
+	if err := rec.Validate(); err != nil {
+		return err
+	}
+	b, err := core.CanonicalMarshalPointerNoSig(rec)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(ed25519.PublicKey(rec.OwnerPub), bncrypto.PreimagePointer(b), rec.Sig) {
+		return errors.New("invalid pointer signature")
+	}
+	return nil
+}
+
+// ApplyPointer verifies and stores a pointer record whose owner this node
+// stores or follows. It reports whether the record replaced the stored one.
+func (n *Node) ApplyPointer(rec *core.PointerRecord) (bool, error) {
+	return n.applyPointer(rec, false)
+}
+
+func (n *Node) applyPointer(rec *core.PointerRecord, local bool) (bool, error) {
+	if err := verifyPointer(rec); err != nil {
+		return false, err
+	}
+	ownerID := core.SiteIDFromPub(rec.OwnerPub)
+	if !local && !n.storesSite(ownerID) {
+		f, err := n.Store.GetFollow(ownerID)
+		if err != nil {
+			return false, err
+		}
+		if f == nil {
+			return false, ErrPointerNotKept
+		}
+	}
+	b, err := core.MarshalCanonical(rec)
+	if err != nil {
+		return false, err
+	}
+	return n.Store.PutPointer(ownerID, rec, b)
+}
+
+// PublishPointer stores a pointer record locally and gossips it.
+func (n *Node) PublishPointer(ctx context.Context, rec *core.PointerRecord) error {
+	applied, err := n.applyPointer(rec, true)
+	if err != nil {
+		return err
+	}
+	if !applied {
+		return errors.New("a pointer record with the same or a higher sequence is already stored")
+	}
+	b, err := cborMarshal(GossipPointer{Pointer: mustMarshal(rec)})
+	if err != nil {
+		return err
+	}
+	return n.Topic.Publish(ctx, b)
+}
+
+func (n *Node) handlePointer(rec *core.PointerRecord) error {
+	_, err := n.ApplyPointer(rec)
+	if errors.Is(err, ErrPointerNotKept) {
+		return nil
+	}
+	if err != nil {
+		log.Printf("reject pointer: %v", err)
+	}
+	return err
+}
@@ -0,0 +1,96 @@
+package p2p
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"alxnet/internal/core"
+)
+
+func TestPointers(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	n := newTestNode(t, ctx)
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	ownerID := core.SiteIDFromPub(pub)
+	v1 := strings.Repeat("a", 64)
+	v2 := strings.Repeat("b", 64)
+
+	rec, err := BuildPointer(priv, "latest-release", v1, 1)
+	if err != nil {
+		t.Fatalf("BuildPointer: %v", err)
+	}
+	if _, err := n.ApplyPointer(rec); !errors.Is(err, ErrPointerNotKept) {
+		t.Fatalf("pointer of an unknown owner: err = %v, want ErrPointerNotKept", err)
+	}
+	if _, err := n.Store.FollowSite(ownerID, ""); err != nil {
+		t.Fatalf("FollowSite: %v", err)
+	}
+	if ok, err := n.ApplyPointer(rec); err != nil || !ok {
+		t.Fatalf("ApplyPointer = %v, %v", ok, err)
+	}
+
+	tampered := *rec
+	tampered.Target, tampered.Seq = v2, 5
+	if _, err := n.ApplyPointer(&tampered); err == nil {
+		t.Error("pointer with a target not matching its signature was accepted")
+	}
+
+	newer, _ := BuildPointer(priv, "latest-release", v2, 2)
+	if ok, err := n.ApplyPointer(newer); err != nil || !ok {
+		t.Fatalf("ApplyPointer(newer) = %v, %v", ok, err)
+	}
+	// Replaying the old record does not roll the pointer back
+	if ok, err := n.ApplyPointer(rec); err != nil || ok {
+		t.Errorf("ApplyPointer(replay) = %v, %v, want not applied", ok, err)
+	}
+	got, err := n.Store.GetPointer(ownerID, "latest-release")
+	if err != nil || got == nil || got.Target != v2 {
+		t.Fatalf("GetPointer = %+v, %v", got, err)
+	}
+
+	seq, err := n.NextPointerSeq(ownerID, "latest-release")
+	if err != nil || seq <= 2 {
+		t.Errorf("NextPointerSeq = %d, %v", seq, err)
+	}
+	status, _ := BuildPointer(priv, "status", v1, seq)
+	if ok, err := n.ApplyPointer(status); err != nil || !ok {
+		t.Fatalf("ApplyPointer(status) = %v, %v", ok, err)
+	}
+	list, err := n.Store.ListPointers(ownerID)
+	if err != nil || len(list) != 2 || list[0].Name != "latest-release" || list[1].Name != "status" {
+		t.Errorf("ListPointers = %+v, %v", list, err)
+	}
+}
+
+func TestPointerValidate(t *testing.T) {
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	target := strings.Repeat("a", 64)
+
+	tests := []struct {
+		name   string
+		pname  string
+		target string
+		seq    uint64
+	}{
+		{"empty name", "", target, 1},
+		{"uppercase name", "Latest", target, 1},
+		{"separator in name", "a:b", target, 1},
+		{"name too long", strings.Repeat("a", core.MaxPointerNameLength+1), target, 1},
+		{"bad target", "status", "not-a-cid", 1},
+		{"zero seq", "status", target, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := BuildPointer(priv, tt.pname, tt.target, tt.seq); err == nil {
+				t.Error("BuildPointer() succeeded")
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package p2p
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Requests this node sends for content and update records are scheduled by
+// priority. Each class may hold a share of the node's FetchSlots requests
+// in flight, and a class waiting for a free slot goes before every class
+// below it, so a page someone is looking at is fetched ahead of site
+// synchronisation, erasure repair and crawling. A background request
+// already in flight is left to finish; with its class held to a share,
+// page loads always find slots of their own.
+
+// Priority is the class of a request to a peer.
+type Priority int
+
+// Priority classes, most urgent first
+const (
+	PriorityInteractive Priority = iota // page loads and embedded fetches
+	PriorityBackground                  // sync, re-seeding, erasure repair and crawling
+	numPriorities
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityInteractive:
+		return "interactive"
+	case PriorityBackground:
+		return "background"
+	}
+	return fmt.Sprintf("priority(%d)", int(p))
+}
+
+// DefaultFetchSlots is the default for NodeConfig.FetchSlots.
+const DefaultFetchSlots = 16
+
+// DefaultFetchShares are the percentages of FetchSlots each class may
+// hold, the defaults for NodeConfig.FetchShares.
+var DefaultFetchShares = map[Priority]int{
+	PriorityInteractive: 100,
+	PriorityBackground:  25,
+}
+
+// FetchStats is a class's use of the node's request slots.
+type FetchStats struct {
+	Priority string `json:"priority"`
+	Limit    int    `json:"limit"`     // slots the class may hold
+	InFlight int    `json:"in_flight"` // requests sent and not yet answered
+	Queued   int    `json:"queued"`    // requests waiting for a slot
+}
+
+type fetchScheduler struct {
+	mu    sync.Mutex
+	slots int
+	limit [numPriorities]int
+	held  [numPriorities]int
+	queue [numPriorities][]chan struct{}
+}
+
+// newFetchScheduler returns a scheduler for slots requests in flight at
+// once, of which each class may hold its percentage in shares. Zero slots
+// or a missing share take the default.
+func newFetchScheduler(slots int, shares map[Priority]int) *fetchScheduler {
+	if slots <= 0 {
+		slots = DefaultFetchSlots
+	}
+	s := &fetchScheduler{slots: slots}
+	for p := range numPriorities {
+		share, ok := shares[p]
+		if !ok || share <= 0 {
+			share = DefaultFetchShares[p]
+		}
+		s.limit[p] = max(1, min(slots, slots*share/100))
+	}
+	return s
+}
+
+// acquire waits for a slot for a request of class p. The slot must be
+// handed back with release unless an error is returned.
+func (s *fetchScheduler) acquire(ctx context.Context, p Priority) error {
+	ready := make(chan struct{})
+	s.mu.Lock()
+	s.queue[p] = append(s.queue[p], ready)
+	s.grant()
+	s.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-ready:
+		// Granted meanwhile, pass it on
+		s.held[p]--
+		s.grant()
+	default:
+		for i, c := range s.queue[p] {
+			if c == ready {
+				s.queue[p] = append(s.queue[p][:i], s.queue[p][i+1:]...)
+				break
+			}
+		}
+	}
+	return ctx.Err()
+}
+
+// release hands back a slot of class p.
+func (s *fetchScheduler) release(p Priority) {
+	s.mu.Lock()
+	s.held[p]--
+	s.grant()
+	s.mu.Unlock()
+}
+
+// grant hands free slots to waiting requests, most urgent class first. A
+// class held back only by its own share does not keep the classes below it
+// waiting. s.mu must be held.
+func (s *fetchScheduler) grant() {
+	inFlight := 0
+	for _, h := range s.held {
+		inFlight += h
+	}
+	for p := range numPriorities {
+		for len(s.queue[p]) > 0 && s.held[p] < s.limit[p] && inFlight < s.slots {
+			close(s.queue[p][0])
+			s.queue[p] = s.queue[p][1:]
+			s.held[p]++
+			inFlight++
+		}
+		if len(s.queue[p]) > 0 && s.held[p] < s.limit[p] {
+			return // waiting for a slot, not for its share
+		}
+	}
+}
+
+// stats returns the use of slots by each class, most urgent first.
+func (s *fetchScheduler) stats() []FetchStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := make([]FetchStats, numPriorities)
+	for p := range numPriorities {
+		stats[p] = FetchStats{Priority: p.String(), Limit: s.limit[p], InFlight: s.held[p], Queued: len(s.queue[p])}
+	}
+	return stats
+}
+
+// FetchStats returns how each priority class uses the node's request
+// slots, most urgent first.
+func (n *Node) FetchStats() []FetchStats {
+	return n.fetches.stats()
+}
+
+// scheduled runs fn once a slot for class p is free.
+func (n *Node) scheduled(ctx context.Context, p Priority, fn func() error) error {
+	if err := n.fetches.acquire(ctx, p); err != nil {
+		return err
+	}
+	defer n.fetches.release(p)
+	return fn()
+}
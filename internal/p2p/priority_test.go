@@ -0,0 +1,81 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFetchSchedulerLimits(t *testing.T) {
+	s := newFetchScheduler(8, map[Priority]int{PriorityBackground: 25})
+	if s.limit[PriorityInteractive] != 8 || s.limit[PriorityBackground] != 2 {
+		t.Fatalf("limits = %v, want [8 2]", s.limit)
+	}
+	if s := newFetchScheduler(2, map[Priority]int{PriorityBackground: 10}); s.limit[PriorityBackground] != 1 {
+		t.Errorf("a small share = %d slots, want 1", s.limit[PriorityBackground])
+	}
+	if s := newFetchScheduler(0, nil); s.slots != DefaultFetchSlots {
+		t.Errorf("default slots = %d", s.slots)
+	}
+}
+
+func TestFetchSchedulerPreemptsBackground(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	s := newFetchScheduler(2, map[Priority]int{PriorityBackground: 50})
+
+	// Background holds its one slot, interactive the other
+	if err := s.acquire(ctx, PriorityBackground); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.acquire(ctx, PriorityInteractive); err != nil {
+		t.Fatal(err)
+	}
+
+	// Both classes queue; the freed slot goes to interactive
+	granted := make(chan Priority, 2)
+	for _, p := range []Priority{PriorityBackground, PriorityInteractive} {
+		go func() {
+			if err := s.acquire(ctx, p); err == nil {
+				granted <- p
+			}
+		}()
+		waitQueued(t, s, p)
+	}
+	s.release(PriorityBackground)
+	if got := <-granted; got != PriorityInteractive {
+		t.Fatalf("freed slot went to %s", got)
+	}
+	s.release(PriorityInteractive)
+	if got := <-granted; got != PriorityBackground {
+		t.Fatalf("second freed slot went to %s", got)
+	}
+
+	// A waiter that gives up leaves the queue
+	short, stop := context.WithCancel(ctx)
+	done := make(chan error)
+	go func() { done <- s.acquire(short, PriorityBackground) }()
+	waitQueued(t, s, PriorityBackground)
+	stop()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("abandoned acquire = %v", err)
+	}
+	stats := s.stats()
+	if stats[PriorityBackground].Queued != 0 || stats[PriorityBackground].InFlight != 1 ||
+		stats[PriorityInteractive].InFlight != 1 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+// waitQueued waits until a request of class p waits for a slot.
+func waitQueued(t *testing.T, s *fetchScheduler, p Priority) {
+	t.Helper()
+	for range 1000 {
+		if s.stats()[p].Queued > 0 {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("no %s request queued", p)
+}
@@ -0,0 +1,269 @@
+package p2p
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"io"
+	"log"
+	"math/big"
+	"time"
+
+	"alxnet/internal/core"
+	bncrypto "alxnet/internal/crypto"
+
+	"github.com/fxamacker/cbor/v2"
+	network "github.com/libp2p/go-libp2p/core/network"
+	peer "github.com/libp2p/go-libp2p/core/peer"
+	protocol "github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// ProofProto challenges a peer to prove it stores a blob: the challenger
+// names a random byte range of a CID it holds itself and a fresh nonce, and
+// the peer answers with StorageProofDigest over that range. A peer that
+// only claims to seed a site cannot answer without the bytes.
+const ProofProto protocol.ID = "/alxnet/proof/1.0.0"
+
+// ProofTimeout bounds a storage challenge round trip.
+const ProofTimeout = 10 * time.Second
+
+// MaxProofLength is the longest byte range a challenge may name.
+const MaxProofLength = 4096
+
+// Storage challenge outcomes.
+const (
+	ProofOK      = "ok"      // the digest matched
+	ProofMissing = "missing" // the peer says it does not hold the blob
+	ProofInvalid = "invalid" // the digest did not match
+)
+
+// Reputation changes for challenge outcomes. A wrong digest means the peer
+// claims data it cannot produce, which is worse than admitting it lacks it.
+const (
+	proofPassReputation    = 2
+	proofMissingReputation = -2
+	proofInvalidReputation = -20
+)
+
+const (
+	proofNonceSize = 16
+	// maxProofMessage caps requests (a CID, offset, length and nonce) and
+	// responses (a flag and a digest).
+	maxProofMessage = 512
+)
+
+var proofDecMode, _ = cbor.DecOptions{MaxMapPairs: 16}.DecMode()
+
+type proofReq struct {
+	CID    string `cbor:"c"`
+	Offset int64  `cbor:"o"`
+	Length int    `cbor:"l"`
+	Nonce  []byte `cbor:"n"`
+}
+
+type proofResp struct {
+	Held   bool   `cbor:"h"`
+	Digest []byte `cbor:"d,omitempty"`
+}
+
+// StorageProof is the outcome of one storage challenge.
+type StorageProof struct {
+	Peer   string        `cbor:"p" json:"peer"`
+	CID    string        `cbor:"c" json:"cid"`
+	Offset int64         `cbor:"o" json:"offset"`
+	Length int           `cbor:"l" json:"length"`
+	Result string        `cbor:"r" json:"result"` // ProofOK, ProofMissing or ProofInvalid
+	At     time.Time     `cbor:"at" json:"at"`
+	RTT    time.Duration `cbor:"rtt" json:"rtt"`
+}
+
+// ChallengeStorage asks p to prove it holds cid. This node must hold cid
+// too, to check the answer. The outcome is recorded in p's reputation; an
+// error means the challenge could not be put to p and records nothing.
+func (n *Node) ChallengeStorage(ctx context.Context, p peer.ID, cid string) (*StorageProof, error) {
+	if !isHexID(cid) {
+		return nil, errors.New("invalid CID")
+	}
+	content, err := n.Store.GetContent(cid)
+	if err != nil {
+		return nil, err
+	}
+	if content == nil {
+		return nil, errors.New("cannot challenge for content this node does not store")
+	}
+	req := proofReq{CID: cid, Nonce: make([]byte, proofNonceSize)}
+	if _, err := rand.Read(req.Nonce); err != nil {
+		return nil, err
+	}
+	req.Length = min(len(content), MaxProofLength)
+	if spread := len(content) - req.Length; spread > 0 {
+		off, err := rand.Int(rand.Reader, big.NewInt(int64(spread)+1))
+		if err != nil {
+			return nil, err
+		}
+		req.Offset = off.Int64()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, ProofTimeout)
+	defer cancel()
+	start := time.Now()
+	resp, err := n.sendProofReq(ctx, p, req)
+	if err != nil {
+		return nil, err
+	}
+
+	proof := &StorageProof{
+		Peer:   p.String(),
+		CID:    cid,
+		Offset: req.Offset,
+		Length: req.Length,
+		At:     time.Now().UTC(),
+		RTT:    time.Since(start),
+	}
+	want := bncrypto.StorageProofDigest(req.Nonce, cid, req.Offset, content[req.Offset:req.Offset+int64(req.Length)])
+	switch {
+	case !resp.Held:
+		proof.Result = ProofMissing
+	case bytes.Equal(resp.Digest, want):
+		proof.Result = ProofOK
+	default:
+		proof.Result = ProofInvalid
+		n.audit(AuditEntry{Kind: AuditProof, Peer: p.String(), ContentCID: cid, Reason: "wrong storage proof"})
+	}
+	n.recordProof(p, proof.Result)
+	return proof, nil
+}
+
+// ChallengeSite challenges p for a random blob of siteID among those this
+// node stores: a file of its current manifest, or the content of its head.
+func (n *Node) ChallengeSite(ctx context.Context, p peer.ID, siteID string) (*StorageProof, error) {
+	var cids []string
+	if n.Store.HasWebsiteManifest(siteID) {
+		data, err := n.Store.GetCurrentWebsiteManifest(siteID)
+		if err != nil {
+			return nil, err
+		}
+		var m core.WebsiteManifest
+		if err := core.UnmarshalCBOR(data, &m); err != nil {
+			return nil, err
+		}
+		for _, cid := range m.Files {
+			cids = append(cids, cid)
+		}
+	} else if _, headCID, err := n.Store.GetHead(siteID); err == nil && headCID != "" {
+		data, err := n.Store.GetRecord(headCID)
+		if err != nil {
+			return nil, err
+		}
+		var rec core.UpdateRecord
+		if err := core.UnmarshalCBOR(data, &rec); err != nil {
+			return nil, err
+		}
+		cids = append(cids, rec.ContentCID)
+	}
+
+	held := cids[:0]
+	for _, cid := range cids {
+		if ok, err := n.Store.HasContent(cid); err == nil && ok {
+			held = append(held, cid)
+		}
+	}
+	if len(held) == 0 {
+		return nil, errors.New("this node stores no content of the site to challenge for")
+	}
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(len(held))))
+	if err != nil {
+		return nil, err
+	}
+	return n.ChallengeStorage(ctx, p, held[i.Int64()])
+}
+
+// recordProof adjusts p's reputation and proof counts for a challenge
+// outcome.
+func (n *Node) recordProof(p peer.ID, result string) {
+	delta := proofPassReputation
+	switch result {
+	case ProofMissing:
+		delta = proofMissingReputation
+	case ProofInvalid:
+		delta = proofInvalidReputation
+	}
+	n.updatePeerReputation(p, delta)
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	info := n.peers[p]
+	if result == ProofOK {
+		info.ProofsPassed++
+	} else {
+		info.ProofsFailed++
+	}
+	info.LastProof = time.Now()
+}
+
+func (n *Node) sendProofReq(ctx context.Context, p peer.ID, req proofReq) (*proofResp, error) {
+	s, err := n.Host.NewStream(ctx, p, ProofProto)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+	if err := s.SetDeadline(time.Now().Add(ProofTimeout)); err != nil {
+		return nil, err
+	}
+	b, err := cborMarshal(req)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.Write(b); err != nil {
+		return nil, err
+	}
+	if err := s.CloseWrite(); err != nil {
+		return nil, err
+	}
+	var resp proofResp
+	if err := proofDecMode.NewDecoder(io.LimitReader(s, maxProofMessage)).Decode(&resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (n *Node) handleProofStream(s network.Stream) {
+	defer s.Close()
+	from := s.Conn().RemotePeer()
+	if err := s.SetDeadline(time.Now().Add(ProofTimeout)); err != nil {
+		return
+	}
+	if !n.rateLimiter.allow(from.String(), time.Now()) {
+		n.audit(AuditEntry{Kind: AuditBrowse, Peer: from.String(), Reason: "storage challenge over the rate limit"})
+		return
+	}
+
+	var req proofReq
+	if err := proofDecMode.NewDecoder(io.LimitReader(s, maxProofMessage)).Decode(&req); err != nil {
+		log.Printf("handleProofStream: bad request from %s: %v", from, err)
+		n.audit(AuditEntry{Kind: AuditBrowse, Peer: from.String(), Reason: "malformed storage challenge: " + err.Error()})
+		return
+	}
+	if !isHexID(req.CID) || len(req.Nonce) != proofNonceSize || req.Offset < 0 || req.Length < 0 || req.Length > MaxProofLength {
+		n.audit(AuditEntry{Kind: AuditBrowse, Peer: from.String(), ContentCID: req.CID, Reason: "invalid storage challenge"})
+		return
+	}
+
+	var resp proofResp
+	if content, err := n.Store.GetContent(req.CID); err == nil && content != nil {
+		if req.Offset > int64(len(content))-int64(req.Length) {
+			n.audit(AuditEntry{Kind: AuditBrowse, Peer: from.String(), ContentCID: req.CID, Reason: "storage challenge past the end of the content"})
+			return
+		}
+		resp.Held = true
+		resp.Digest = bncrypto.StorageProofDigest(req.Nonce, req.CID, req.Offset, content[req.Offset:req.Offset+int64(req.Length)])
+	}
+	b, err := cborMarshal(resp)
+	if err != nil {
+		return
+	}
+	if _, err := s.Write(b); err != nil {
+		log.Printf("handleProofStream: write failed: %v", err)
+	}
+}
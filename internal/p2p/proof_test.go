@@ -0,0 +1,158 @@
+package p2p
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"alxnet/internal/core"
+	bncrypto "alxnet/internal/crypto"
+
+	peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestStorageProofDigest(t *testing.T) {
+	const cid = "aa00000000000000000000000000000000000000000000000000000000000000"
+	nonce := []byte("0123456789abcdef")
+	base := bncrypto.StorageProofDigest(nonce, cid, 10, []byte("range"))
+	tests := []struct {
+		name   string
+		digest []byte
+	}{
+		{"nonce", bncrypto.StorageProofDigest([]byte("fedcba9876543210"), cid, 10, []byte("range"))},
+		{"cid", bncrypto.StorageProofDigest(nonce, "bb"+cid[2:], 10, []byte("range"))},
+		{"offset", bncrypto.StorageProofDigest(nonce, cid, 11, []byte("range"))},
+		{"data", bncrypto.StorageProofDigest(nonce, cid, 10, []byte("rangf"))},
+	}
+	for _, tt := range tests {
+		if bytes.Equal(tt.digest, base) {
+			t.Errorf("changing the %s keeps the digest", tt.name)
+		}
+	}
+}
+
+func TestChallengeStorage(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	challenger, holder, empty, liar := newTestNode(t, ctx), newTestNode(t, ctx), newTestNode(t, ctx), newTestNode(t, ctx)
+	content := bytes.Repeat([]byte("alxnet storage proof "), 1000) // longer than MaxProofLength
+	cid := core.CIDForContent(content)
+	small := []byte("<p>hi</p>")
+	smallCID := core.CIDForContent(small)
+	for _, n := range []*Node{challenger, holder} {
+		for c, data := range map[string][]byte{cid: content, smallCID: small} {
+			if err := n.Store.PutContent(c, data); err != nil {
+				t.Fatalf("PutContent: %v", err)
+			}
+		}
+	}
+	// The liar keeps other bytes under the CID
+	if err := liar.Store.PutContent(cid, bytes.ToUpper(content)); err != nil {
+		t.Fatalf("PutContent: %v", err)
+	}
+	for _, other := range []*Node{holder, empty, liar} {
+		if err := challenger.Host.Connect(ctx, peer.AddrInfo{ID: other.Host.ID(), Addrs: other.Host.Addrs()}); err != nil {
+			t.Fatalf("Connect: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		p      *Node
+		cid    string
+		result string
+	}{
+		{"holder", holder, cid, ProofOK},
+		{"small blob", holder, smallCID, ProofOK},
+		{"not stored", empty, cid, ProofMissing},
+		{"wrong bytes", liar, cid, ProofInvalid},
+	}
+	for _, tt := range tests {
+		proof, err := challenger.ChallengeStorage(ctx, tt.p.Host.ID(), tt.cid)
+		if err != nil {
+			t.Fatalf("%s: ChallengeStorage: %v", tt.name, err)
+		}
+		if proof.Result != tt.result {
+			t.Errorf("%s: result = %s, want %s", tt.name, proof.Result, tt.result)
+		}
+		if proof.Length > MaxProofLength || proof.Offset < 0 || proof.Offset+int64(proof.Length) > int64(len(content)) {
+			t.Errorf("%s: challenged range %d+%d out of bounds", tt.name, proof.Offset, proof.Length)
+		}
+	}
+
+	byPeer := make(map[peer.ID]PeerStatus)
+	for _, st := range challenger.PeerStatuses() {
+		byPeer[st.Peer] = st
+	}
+	if st := byPeer[holder.Host.ID()]; st.ProofsPassed != 2 || st.ProofsFailed != 0 || st.LastProof == nil {
+		t.Errorf("holder status = %+v, want two passed proofs", st)
+	}
+	if st := byPeer[liar.Host.ID()]; st.ProofsFailed != 1 || st.Reputation >= 0 {
+		t.Errorf("liar status = %+v, want a failed proof and negative reputation", st)
+	}
+	if got := challenger.Audit(AuditFilter{Kind: AuditProof, Peer: liar.Host.ID().String()}); len(got) != 1 {
+		t.Errorf("audit has %d proof entries for the liar, want 1", len(got))
+	}
+
+	if _, err := empty.ChallengeStorage(ctx, challenger.Host.ID(), cid); err == nil {
+		t.Error("ChallengeStorage() for content the challenger lacks should fail")
+	}
+	if _, err := challenger.ChallengeStorage(ctx, holder.Host.ID(), "not-a-cid"); err == nil {
+		t.Error("ChallengeStorage() accepted an invalid CID")
+	}
+
+	// Challenges the handler refuses get no answer
+	for _, req := range []proofReq{
+		{CID: cid, Offset: 0, Length: MaxProofLength + 1, Nonce: make([]byte, proofNonceSize)},
+		{CID: smallCID, Offset: 5, Length: len(small), Nonce: make([]byte, proofNonceSize)},
+		{CID: cid, Offset: -1, Length: 1, Nonce: make([]byte, proofNonceSize)},
+		{CID: cid, Length: 1, Nonce: []byte("short")},
+	} {
+		if resp, err := challenger.sendProofReq(ctx, holder.Host.ID(), req); err == nil {
+			t.Errorf("challenge %+v answered with %+v", req, resp)
+		}
+	}
+}
+
+func TestChallengeSite(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	challenger, holder := newTestNode(t, ctx), newTestNode(t, ctx)
+	const siteID = "aa00000000000000000000000000000000000000000000000000000000000000"
+	page := []byte("<h1>home</h1>")
+	m := core.WebsiteManifest{Version: "v1", Seq: 1, TS: 1, MainFile: "index.html", Files: map[string]string{
+		"index.html": core.CIDForContent(page),
+		"gone.css":   "cc00000000000000000000000000000000000000000000000000000000000000", // not stored by the challenger
+	}}
+	data, err := core.MarshalCanonical(&m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []*Node{challenger, holder} {
+		if err := n.Store.PutContent(m.Files["index.html"], page); err != nil {
+			t.Fatalf("PutContent: %v", err)
+		}
+	}
+	if err := challenger.Store.PutWebsiteManifest(siteID, core.CIDForBytes(data), data); err != nil {
+		t.Fatalf("PutWebsiteManifest: %v", err)
+	}
+	if err := challenger.Host.Connect(ctx, peer.AddrInfo{ID: holder.Host.ID(), Addrs: holder.Host.Addrs()}); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		proof, err := challenger.ChallengeSite(ctx, holder.Host.ID(), siteID)
+		if err != nil {
+			t.Fatalf("ChallengeSite: %v", err)
+		}
+		if proof.CID != m.Files["index.html"] || proof.Result != ProofOK {
+			t.Errorf("proof = %+v, want ok for the stored page", proof)
+		}
+	}
+	if _, err := challenger.ChallengeSite(ctx, holder.Host.ID(), "bb"+siteID[2:]); err == nil {
+		t.Error("ChallengeSite() for an unknown site should fail")
+	}
+}
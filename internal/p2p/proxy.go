@@ -0,0 +1,163 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/transport"
+	"github.com/libp2p/go-libp2p/p2p/transport/tcp"
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+	"golang.org/x/net/proxy"
+)
+
+// With NodeConfig.SOCKS5Proxy set, every outbound connection goes through
+// the proxy, typically Tor's SOCKS port. The host then runs the TCP
+// transport only, with its dials handed to the proxy, plus socksTransport
+// for addresses the proxy has to resolve: /dns, /dns4 and /dns6 names are
+// passed on unresolved so no DNS query leaves this machine, and /onion3
+// addresses reach onion services. QUIC, WebTransport and WebRTC cannot go
+// through SOCKS and are left out, as is mDNS, which would announce the node
+// on the local network.
+
+// socksDialer returns a dialer through the SOCKS5 proxy at addr, host:port.
+func socksDialer(addr string) (proxy.ContextDialer, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || host == "" {
+		return nil, fmt.Errorf("invalid SOCKS5 proxy address %q: want host:port", addr)
+	}
+	if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+		return nil, fmt.Errorf("invalid SOCKS5 proxy port %q", port)
+	}
+	d, err := proxy.SOCKS5("tcp", addr, nil, &net.Dialer{Timeout: PeerTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("SOCKS5 proxy %s: %w", addr, err)
+	}
+	cd, ok := d.(proxy.ContextDialer)
+	if !ok {
+		return nil, errors.New("SOCKS5 dialer does not take a context")
+	}
+	return cd, nil
+}
+
+// proxyOptions are the libp2p options routing dials through dialer.
+func proxyOptions(dialer proxy.ContextDialer) []libp2p.Option {
+	return []libp2p.Option{
+		libp2p.Transport(tcp.NewTCPTransport, tcp.WithDialerForAddr(func(ma.Multiaddr) (tcp.ContextDialer, error) {
+			return dialer, nil
+		})),
+		libp2p.Transport(func(u transport.Upgrader, rcmgr network.ResourceManager) *socksTransport {
+			return &socksTransport{dialer: dialer, upgrader: u, rcmgr: rcmgr}
+		}),
+	}
+}
+
+// socksTransport dials /dns*/tcp and /onion3 addresses through a SOCKS5
+// proxy, which resolves the name. It cannot listen: onion services are
+// reached through the TCP listener Tor forwards to, see onion.go.
+type socksTransport struct {
+	dialer   proxy.ContextDialer
+	upgrader transport.Upgrader
+	rcmgr    network.ResourceManager
+}
+
+var _ transport.Transport = (*socksTransport)(nil)
+var _ transport.SkipResolver = (*socksTransport)(nil)
+
+// socksTarget returns the host:port the proxy is asked to connect to for
+// addr, which must be a /dns*/tcp or /onion3 address and nothing more.
+func socksTarget(addr ma.Multiaddr) (string, bool) {
+	protos := addr.Protocols()
+	switch {
+	case len(protos) == 1 && protos[0].Code == ma.P_ONION3:
+		// The value is the 56-character service ID and the port
+		v, err := addr.ValueForProtocol(ma.P_ONION3)
+		if err != nil {
+			return "", false
+		}
+		id, port, err := net.SplitHostPort(v)
+		if err != nil {
+			return "", false
+		}
+		return net.JoinHostPort(id+".onion", port), true
+	case len(protos) == 2 && protos[1].Code == ma.P_TCP:
+		switch protos[0].Code {
+		case ma.P_DNS, ma.P_DNS4, ma.P_DNS6:
+			host, _ := addr.ValueForProtocol(protos[0].Code)
+			port, _ := addr.ValueForProtocol(ma.P_TCP)
+			return net.JoinHostPort(host, port), true
+		}
+	}
+	return "", false
+}
+
+func (t *socksTransport) CanDial(addr ma.Multiaddr) bool {
+	_, ok := socksTarget(addr)
+	return ok
+}
+
+// SkipResolve keeps the swarm from resolving names itself; the proxy does.
+func (t *socksTransport) SkipResolve(context.Context, ma.Multiaddr) bool {
+	return true
+}
+
+func (t *socksTransport) Dial(ctx context.Context, raddr ma.Multiaddr, p peer.ID) (transport.CapableConn, error) {
+	target, ok := socksTarget(raddr)
+	if !ok {
+		return nil, fmt.Errorf("cannot dial %s through a SOCKS5 proxy", raddr)
+	}
+	scope, err := t.rcmgr.OpenConnection(network.DirOutbound, true, raddr)
+	if err != nil {
+		return nil, err
+	}
+	if err := scope.SetPeer(p); err != nil {
+		scope.Done()
+		return nil, err
+	}
+	dialCtx, cancel := context.WithTimeout(ctx, PeerTimeout)
+	defer cancel()
+	c, err := t.dialer.DialContext(dialCtx, "tcp", target)
+	if err != nil {
+		scope.Done()
+		return nil, err
+	}
+	conn, err := t.upgrader.Upgrade(ctx, t, &proxiedConn{Conn: c, raddr: raddr}, network.DirOutbound, p, scope)
+	if err != nil {
+		// The upgrader closes the connection and the scope on failure
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (t *socksTransport) Listen(laddr ma.Multiaddr) (transport.Listener, error) {
+	return nil, fmt.Errorf("cannot listen on %s: SOCKS5 only dials", laddr)
+}
+
+func (t *socksTransport) Protocols() []int {
+	return []int{ma.P_DNS, ma.P_DNS4, ma.P_DNS6, ma.P_ONION3}
+}
+
+func (t *socksTransport) Proxy() bool { return true }
+
+// proxiedConn is a connection to the proxy that stands for one to raddr.
+type proxiedConn struct {
+	net.Conn
+	raddr ma.Multiaddr
+}
+
+var _ manet.Conn = (*proxiedConn)(nil)
+
+func (c *proxiedConn) LocalMultiaddr() ma.Multiaddr {
+	if a, err := manet.FromNetAddr(c.LocalAddr()); err == nil {
+		return a
+	}
+	return nil
+}
+
+func (c *proxiedConn) RemoteMultiaddr() ma.Multiaddr { return c.raddr }
@@ -0,0 +1,201 @@
+package p2p
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"alxnet/internal/store"
+
+	peer "github.com/libp2p/go-libp2p/core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+const testOnionID = "vww6ybal4bd7szmgncyruucpgfkqahzddi37ktceo3ah7ngmcopnpyyd"
+
+func TestSocksTarget(t *testing.T) {
+	tests := []struct {
+		addr string
+		want string // "" if the proxy transport cannot dial it
+	}{
+		{"/dns4/seed.example.org/tcp/4001", "seed.example.org:4001"},
+		{"/dns6/seed.example.org/tcp/4001", "seed.example.org:4001"},
+		{"/dns/seed.example.org/tcp/4001", "seed.example.org:4001"},
+		{"/onion3/" + testOnionID + ":4001", testOnionID + ".onion:4001"},
+		{"/ip4/192.0.2.1/tcp/4001", ""},                 // the TCP transport's
+		{"/dns4/seed.example.org/udp/4001/quic-v1", ""}, // not TCP
+		{"/dns4/seed.example.org/tcp/4001/ws", ""},
+	}
+	for _, tt := range tests {
+		got, ok := socksTarget(ma.StringCast(tt.addr))
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("socksTarget(%s) = %q, %v; want %q", tt.addr, got, ok, tt.want)
+		}
+	}
+
+	if _, err := socksDialer("localhost"); err == nil {
+		t.Error("socksDialer accepted an address without a port")
+	}
+	if _, err := socksDialer("127.0.0.1:0"); err == nil {
+		t.Error("socksDialer accepted port 0")
+	}
+}
+
+// socks5Server accepts CONNECT requests without authentication, records the
+// requested target and connects every one of them to upstream.
+type socks5Server struct {
+	ln       net.Listener
+	upstream string
+	mu       sync.Mutex
+	targets  []string
+}
+
+func newSOCKS5Server(t *testing.T, upstream string) *socks5Server {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &socks5Server{ln: ln, upstream: upstream}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c)
+		}
+	}()
+	return s
+}
+
+func (s *socks5Server) serve(c net.Conn) {
+	defer c.Close()
+	// Greeting: version, methods; answer "no authentication"
+	var hdr [2]byte
+	if _, err := io.ReadFull(c, hdr[:]); err != nil || hdr[0] != 5 {
+		return
+	}
+	if _, err := io.ReadFull(c, make([]byte, hdr[1])); err != nil {
+		return
+	}
+	c.Write([]byte{5, 0})
+	// Request: version, CONNECT, reserved, address type, address, port
+	var req [4]byte
+	if _, err := io.ReadFull(c, req[:]); err != nil || req[1] != 1 {
+		return
+	}
+	var host string
+	switch req[3] {
+	case 1, 4:
+		ip := make([]byte, 4)
+		if req[3] == 4 {
+			ip = make([]byte, 16)
+		}
+		if _, err := io.ReadFull(c, ip); err != nil {
+			return
+		}
+		host = net.IP(ip).String()
+	case 3:
+		var n [1]byte
+		if _, err := io.ReadFull(c, n[:]); err != nil {
+			return
+		}
+		name := make([]byte, n[0])
+		if _, err := io.ReadFull(c, name); err != nil {
+			return
+		}
+		host = string(name)
+	default:
+		return
+	}
+	var port [2]byte
+	if _, err := io.ReadFull(c, port[:]); err != nil {
+		return
+	}
+	s.mu.Lock()
+	s.targets = append(s.targets, net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port[:])))))
+	s.mu.Unlock()
+
+	up, err := net.Dial("tcp", s.upstream)
+	if err != nil {
+		c.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer up.Close()
+	c.Write([]byte{5, 0, 0, 1, 127, 0, 0, 1, 0, 0})
+	go io.Copy(up, c)
+	io.Copy(c, up)
+}
+
+func (s *socks5Server) lastTarget() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.targets) == 0 {
+		return ""
+	}
+	return s.targets[len(s.targets)-1]
+}
+
+func TestDialThroughSOCKS5(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	target := newTestNode(t, ctx)
+	var targetAddr string
+	for _, a := range target.Host.Addrs() {
+		if ip, err := a.ValueForProtocol(ma.P_IP4); err == nil {
+			port, _ := a.ValueForProtocol(ma.P_TCP)
+			targetAddr = net.JoinHostPort(ip, port)
+			break
+		}
+	}
+	proxy := newSOCKS5Server(t, targetAddr)
+
+	db, err := store.NewMemory()
+	if err != nil {
+		t.Fatalf("store.NewMemory: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	cfg := DefaultNodeConfig()
+	cfg.SOCKS5Proxy = proxy.ln.Addr().String()
+	client, err := New(ctx, db, "/ip4/127.0.0.1/tcp/0", nil, cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { client.Host.Close() })
+
+	_, port, _ := net.SplitHostPort(targetAddr)
+	tests := []struct {
+		addr, want string
+	}{
+		{"/ip4/127.0.0.1/tcp/" + port, "127.0.0.1:" + port},
+		// Names reach the proxy unresolved; this one has no DNS record
+		{"/dns4/alxnet-peer.invalid/tcp/" + port, "alxnet-peer.invalid:" + port},
+		{"/onion3/" + testOnionID + ":" + port, testOnionID + ".onion:" + port},
+	}
+	for _, tt := range tests {
+		client.Host.Peerstore().ClearAddrs(target.Host.ID())
+		info := peer.AddrInfo{ID: target.Host.ID(), Addrs: []ma.Multiaddr{ma.StringCast(tt.addr)}}
+		if err := client.Host.Connect(ctx, info); err != nil {
+			t.Errorf("Connect(%s): %v", tt.addr, err)
+			continue
+		}
+		if got := proxy.lastTarget(); got != tt.want {
+			t.Errorf("Connect(%s) asked the proxy for %q, want %q", tt.addr, got, tt.want)
+		}
+		client.Host.Network().ClosePeer(target.Host.ID())
+		for i := 0; i < 50 && len(client.Host.Network().ConnsToPeer(target.Host.ID())) > 0; i++ {
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+	if _, err := New(ctx, db, "/ip4/127.0.0.1/tcp/0", nil, &NodeConfig{SOCKS5Proxy: "no-port"}); err == nil || !strings.Contains(err.Error(), "SOCKS5") {
+		t.Errorf("New with a bad proxy address: %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package p2p
+
+import (
+	"context"
+	"fmt"
+
+	"alxnet/internal/core"
+
+	peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Each kind of record gossiped on the updates topic is a RecordType: a
+// feature registers its type from an init function in its own file, and
+// the topic validator and the validation pool find it in the registry.
+// Every message carries one record in an envelope of its own, and the
+// types are tried in the order they were registered; a type whose messages
+// also decode as another's, such as a domain acceptance carrying its
+// offer, registers first.
+
+// RecordType is a kind of record gossiped on the updates topic.
+type RecordType struct {
+	// Name identifies the type in the registry and must be unique.
+	Name string
+
+	// Decode recognises a message of this type and checks its record as
+	// far as that does not depend on the node's store: encoding,
+	// structure and signatures. It returns the record and the audit entry
+	// of the message, with its kind and site set. For a message of another
+	// type it returns a nil record and error; a message of this type that
+	// fails the checks returns an error and is rejected.
+	Decode func(data []byte) (rec any, e AuditEntry, err error)
+
+	// Check, if set, checks a record Decode returned against rules that
+	// depend on the node's configuration, such as scheduled rule changes.
+	// The topic validator rejects a record failing it, so nodes that
+	// disagree on those rules do not forward it either.
+	Check func(n *Node, rec any) error
+
+	// Apply applies a record Decode returned, on the validation worker
+	// of the record's site.
+	Apply func(ctx context.Context, n *Node, m GossipMessage) error
+}
+
+// GossipMessage is a decoded record to apply.
+type GossipMessage struct {
+	Record any     // as returned by Decode
+	From   peer.ID // the peer that sent the message
+	Origin peer.ID // the peer that published it
+}
+
+var recordTypes []RecordType
+
+// RegisterRecordType adds t to the record types gossip is decoded as. It
+// panics if a type of the same name is registered, and must be called
+// before nodes start, typically from init.
+func RegisterRecordType(t RecordType) {
+	if t.Name == "" || t.Decode == nil || t.Apply == nil {
+		panic("p2p: record type without a name, Decode or Apply")
+	}
+	for _, r := range recordTypes {
+		if r.Name == t.Name {
+			panic(fmt.Sprintf("p2p: record type %q registered twice", t.Name))
+		}
+	}
+	recordTypes = append(recordTypes, t)
+}
+
+// RecordTypes returns the names of the registered record types, in the
+// order messages are tried.
+func RecordTypes() []string {
+	names := make([]string, len(recordTypes))
+	for i, t := range recordTypes {
+		names[i] = t.Name
+	}
+	return names
+}
+
+// envelopedRecord returns the RecordType of records R gossiped as the CBOR
+// bytes in one field of an envelope E. audit adds a record's site, and
+// any content it names, to its audit entry; verify checks its signatures
+// and apply applies it.
+func envelopedRecord[E, R any](name, kind string, field func(*E) []byte, audit func(*R, *AuditEntry), verify func(*R) error, apply func(*Node, *R) error) RecordType {
+	return RecordType{
+		Name: name,
+		Decode: func(data []byte) (any, AuditEntry, error) {
+			var env E
+			if err := cborUnmarshal(data, &env); err != nil || len(field(&env)) == 0 {
+				return nil, AuditEntry{}, nil
+			}
+			e := AuditEntry{Kind: kind, RecordCID: core.CIDForBytes(field(&env))}
+			rec := new(R)
+			if err := cborUnmarshal(field(&env), rec); err != nil {
+				return nil, e, fmt.Errorf("malformed record: %w", err)
+			}
+			audit(rec, &e)
+			if err := verify(rec); err != nil {
+				return nil, e, err
+			}
+			return rec, e, nil
+		},
+		Apply: func(ctx context.Context, n *Node, m GossipMessage) error {
+			return apply(n, m.Record.(*R))
+		},
+	}
+}
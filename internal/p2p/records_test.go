@@ -0,0 +1,62 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// gossipNote is a record type registered by TestRegisterRecordType.
+type gossipNote struct {
+	Note string
+}
+
+func TestRegisterRecordType(t *testing.T) {
+	saved := recordTypes
+	defer func() { recordTypes = saved }()
+	recordTypes = slices.Clip(recordTypes)
+
+	names := RecordTypes()
+	if accept, offer := slices.Index(names, "domain-accept"), slices.Index(names, "domain-offer"); accept < 0 || accept > offer {
+		t.Fatalf("record types %v: acceptances must be tried before offers", names)
+	}
+
+	var applied []string
+	RegisterRecordType(RecordType{
+		Name: "note",
+		Decode: func(data []byte) (any, AuditEntry, error) {
+			var env gossipNote
+			if err := cborUnmarshal(data, &env); err != nil || env.Note == "" {
+				return nil, AuditEntry{}, nil
+			}
+			e := AuditEntry{Kind: "note"}
+			if env.Note == "forged" {
+				return nil, e, errors.New("invalid note")
+			}
+			return &env, e, nil
+		},
+		Apply: func(ctx context.Context, n *Node, m GossipMessage) error {
+			applied = append(applied, m.Record.(*gossipNote).Note)
+			return nil
+		},
+	})
+
+	g, err := decodeGossip(mustMarshal(gossipNote{Note: "hello"}))
+	if err != nil || g == nil || g.kind.Name != "note" {
+		t.Fatalf("decodeGossip = %+v, %v; want a note", g, err)
+	}
+	if err := (&Node{}).applyGossip(context.Background(), "", g); err != nil || !slices.Equal(applied, []string{"hello"}) {
+		t.Fatalf("applyGossip = %v, applied %v", err, applied)
+	}
+	if g, err := decodeGossip(mustMarshal(gossipNote{Note: "forged"})); err == nil || g.audit.Kind != "note" {
+		t.Errorf("forged note: decodeGossip = %+v, %v", g, err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a name twice did not panic")
+		}
+	}()
+	RegisterRecordType(RecordType{Name: "note", Decode: recordTypes[0].Decode, Apply: recordTypes[0].Apply})
+}
@@ -0,0 +1,85 @@
+package p2p
+
+import (
+	"context"
+	"time"
+
+	"alxnet/internal/core"
+
+	"go.uber.org/zap"
+)
+
+// Re-seeding keeps sites published through this node available: pinned sites
+// have their current head re-announced on the update topic so that peers
+// which missed the original broadcast, or joined later, pick it up.
+const ReannounceInterval = 10 * time.Minute
+
+// PinSite pins siteID in the store and announces its head right away.
+func (n *Node) PinSite(ctx context.Context, siteID string) error {
+	if err := n.Store.PinSite(siteID); err != nil {
+		return err
+	}
+	if err := n.announceHead(ctx, siteID); err != nil {
+		n.logger.Debug("initial announce failed", zap.String("site", Short(siteID)), zap.Error(err))
+	}
+	return nil
+}
+
+func (n *Node) reseedPinned(ctx context.Context) {
+	ticker := time.NewTicker(ReannounceInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n.maintainHosting(ctx)
+			n.maintainErasure(ctx)
+			n.reannouncePinned(ctx)
+		}
+	}
+}
+
+func (n *Node) reannouncePinned(ctx context.Context) {
+	sites, err := n.Store.ListPinnedSites()
+	if err != nil {
+		n.logger.Warn("failed to list pinned sites", zap.Error(err))
+		return
+	}
+	announced := 0
+	for _, siteID := range sites {
+		if err := n.announceHead(ctx, siteID); err != nil {
+			n.logger.Debug("re-announce failed", zap.String("site", Short(siteID)), zap.Error(err))
+			continue
+		}
+		announced++
+	}
+	n.logger.Debug("re-announced pinned sites", zap.Int("pinned", len(sites)), zap.Int("announced", announced))
+}
+
+// announceHead broadcasts the stored head record of siteID, with its content
+// inline when small enough.
+func (n *Node) announceHead(ctx context.Context, siteID string) error {
+	has, err := n.Store.HasHead(siteID)
+	if err != nil || !has {
+		return err
+	}
+	_, headCID, err := n.Store.GetHead(siteID)
+	if err != nil {
+		return err
+	}
+	recBytes, err := n.Store.GetRecord(headCID)
+	if err != nil {
+		return err
+	}
+
+	env := GossipUpdate{Record: recBytes}
+	var rec core.UpdateRecord
+	if cborUnmarshal(recBytes, &rec) == nil && rec.ContentCID != "" {
+		if content, err := n.Store.GetContent(rec.ContentCID); err == nil {
+			env.Content = content // BroadcastUpdate leaves it out when too large
+		}
+	}
+	return n.BroadcastUpdate(ctx, env)
+}
@@ -0,0 +1,86 @@
+package p2p
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"alxnet/internal/core"
+	bncrypto "alxnet/internal/crypto"
+)
+
+func TestValidateAndApplyReannouncement(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	n := newTestNode(t, ctx)
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	content := []byte("<h1>hello</h1>")
+	env, _, err := n.BuildUpdate(priv, pub, content, 1, "")
+	if err != nil {
+		t.Fatalf("BuildUpdate: %v", err)
+	}
+	var rec core.UpdateRecord
+	if err := cborUnmarshal(env.Record, &rec); err != nil {
+		t.Fatalf("unmarshal record: %v", err)
+	}
+
+	// First sighting: record only, as a peer would see a large site.
+	if err := n.ValidateAndApply(&rec, nil); err != nil {
+		t.Fatalf("first apply: %v", err)
+	}
+	if has, _ := n.Store.HasContent(rec.ContentCID); has {
+		t.Fatal("content stored without being sent")
+	}
+
+	// Re-announcement of the same head is accepted and fills in content.
+	if err := n.ValidateAndApply(&rec, content); err != nil {
+		t.Fatalf("re-announce: %v", err)
+	}
+	if has, _ := n.Store.HasContent(rec.ContentCID); !has {
+		t.Error("re-announce did not store missing content")
+	}
+
+	// A re-announcement with mismatching content is still rejected.
+	if err := n.ValidateAndApply(&rec, []byte("tampered")); err == nil {
+		t.Error("re-announce with tampered content was accepted")
+	}
+}
+
+func TestValidateAndApplyFutureTimestamp(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	t.Cleanup(func() { core.SetMaxClockSkew(0) })
+
+	n := newTestNode(t, ctx)
+	sitePub, sitePriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	upPub, upPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	rec := core.UpdateRecord{Version: core.RecordV1, SitePub: sitePub, Seq: 1, ContentCID: core.CIDForContent([]byte("later")),
+		TS: time.Now().Add(2 * time.Hour).Unix(), UpdatePub: upPub}
+	rec.LinkSig = ed25519.Sign(sitePriv, bncrypto.PreimageLink(sitePub, upPub, rec.Seq, rec.PrevCID, rec.ContentCID, rec.TS))
+	noUS, err := core.CanonicalMarshalNoUpdateSig(&rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.UpdateSig = ed25519.Sign(upPriv, bncrypto.PreimageUpdate(noUS))
+
+	if err := n.ValidateAndApply(&rec, nil); err == nil || !strings.Contains(err.Error(), "too far in future") {
+		t.Errorf("ValidateAndApply() of an update 2h ahead = %v, want too far in future", err)
+	}
+	core.SetMaxClockSkew(3 * time.Hour)
+	if err := n.ValidateAndApply(&rec, nil); err != nil {
+		t.Errorf("ValidateAndApply() with a 3h max skew: %v", err)
+	}
+}
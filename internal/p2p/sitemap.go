@@ -0,0 +1,342 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"alxnet/internal/core"
+	bncrypto "alxnet/internal/crypto"
+
+	"github.com/fxamacker/cbor/v2"
+	network "github.com/libp2p/go-libp2p/core/network"
+	peer "github.com/libp2p/go-libp2p/core/peer"
+	protocol "github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// SitemapProto serves the list of sites a node seeds, signed with the
+// node's libp2p key, to cooperative search indexers. Nodes only answer it
+// when NodeConfig.ServeSitemap is set, and leave out sites whose manifest
+// sets NoIndex.
+const SitemapProto protocol.ID = "/alxnet/sitemap/1.0.0"
+
+// SitemapTimeout bounds a single sitemap page round trip.
+const SitemapTimeout = 10 * time.Second
+
+// SitemapPageSize is the most entries a sitemap page carries.
+const SitemapPageSize = 256
+
+// MaxSitemapOffset bounds the offset a sitemap request may ask for.
+const MaxSitemapOffset = 100000
+
+const (
+	// sitemapCacheTTL is how long a built sitemap answers requests.
+	sitemapCacheTTL = time.Minute
+	// maxSitemapRequest caps requests, which only carry an offset.
+	maxSitemapRequest = 64
+	// maxSitemapMessage caps a page: entries are hex IDs, a path and a
+	// MIME type.
+	maxSitemapMessage = SitemapPageSize*1024 + 1024
+	// maxSitemapAge rejects pages signed too long ago, or in the future.
+	maxSitemapAge = time.Hour
+)
+
+var (
+	sitemapReqDecMode, _  = cbor.DecOptions{MaxMapPairs: 16}.DecMode()
+	sitemapPageDecMode, _ = cbor.DecOptions{MaxArrayElements: SitemapPageSize, MaxMapPairs: 16}.DecMode()
+)
+
+type sitemapReq struct {
+	Offset int `cbor:"o"`
+}
+
+// SitemapEntry is a site in a sitemap.
+type SitemapEntry struct {
+	SiteID   string `cbor:"s" json:"site_id"`
+	Seq      uint64 `cbor:"q" json:"seq"`
+	CID      string `cbor:"c" json:"cid"`  // manifest CID, or head record CID of a single-file site
+	TS       int64  `cbor:"t" json:"ts"`   // from the manifest or record
+	Main     string `cbor:"m" json:"main"` // content CID of the main page
+	MainPath string `cbor:"p" json:"main_path"`
+	MimeType string `cbor:"mt,omitempty" json:"mime_type,omitempty"`
+}
+
+// Sitemap is one page of a node's sitemap, signed by the node.
+type Sitemap struct {
+	Peer    string         `cbor:"p"` // libp2p peer ID of the signer
+	Time    int64          `cbor:"t"` // unix seconds
+	Offset  int            `cbor:"o"`
+	Total   int            `cbor:"n"` // entries in the whole sitemap
+	Entries []SitemapEntry `cbor:"e"`
+	Sig     []byte         `cbor:"sig,omitempty"`
+}
+
+// sitemapCache keeps the entries of this node's sitemap between requests
+// for its pages.
+type sitemapCache struct {
+	mu      sync.Mutex
+	built   time.Time
+	entries []SitemapEntry
+}
+
+// validate checks an entry's fields before an indexer uses them.
+func (e SitemapEntry) validate() error {
+	if !isHexID(e.SiteID) {
+		return errors.New("invalid site ID")
+	}
+	for _, cid := range []string{e.CID, e.Main} {
+		if !isHexID(cid) {
+			return fmt.Errorf("invalid CID %q", cid)
+		}
+	}
+	if err := core.ValidateFilePath(e.MainPath); err != nil {
+		return fmt.Errorf("invalid main path: %w", err)
+	}
+	if len(e.MimeType) > 128 {
+		return errors.New("MIME type too long")
+	}
+	return nil
+}
+
+// isHexID reports whether s is 64 lowercase hex digits, the form of site
+// IDs and CIDs.
+func isHexID(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func sitemapPreimage(page *Sitemap) ([]byte, error) {
+	tmp := *page
+	tmp.Sig = nil
+	b, err := core.MarshalCanonical(tmp)
+	if err != nil {
+		return nil, err
+	}
+	return bncrypto.PreimageSitemap(b), nil
+}
+
+// VerifySitemap checks the signature of a sitemap page against the key of
+// the peer it names, and the entries it carries.
+func VerifySitemap(page *Sitemap) error {
+	id, err := peer.Decode(page.Peer)
+	if err != nil {
+		return fmt.Errorf("invalid peer ID: %w", err)
+	}
+	pub, err := id.ExtractPublicKey()
+	if err != nil {
+		return fmt.Errorf("peer ID carries no public key: %w", err)
+	}
+	pre, err := sitemapPreimage(page)
+	if err != nil {
+		return err
+	}
+	if ok, err := pub.Verify(pre, page.Sig); err != nil || !ok {
+		return errors.New("invalid sitemap signature")
+	}
+	if len(page.Entries) > SitemapPageSize || page.Offset < 0 || page.Total < 0 {
+		return errors.New("malformed sitemap page")
+	}
+	for _, e := range page.Entries {
+		if err := e.validate(); err != nil {
+			return fmt.Errorf("sitemap entry: %w", err)
+		}
+	}
+	return nil
+}
+
+// signSitemap signs page with this node's libp2p key.
+func (n *Node) signSitemap(page *Sitemap) error {
+	priv := n.Host.Peerstore().PrivKey(n.Host.ID())
+	if priv == nil {
+		return errors.New("no private key for this host")
+	}
+	page.Peer = n.Host.ID().String()
+	pre, err := sitemapPreimage(page)
+	if err != nil {
+		return err
+	}
+	page.Sig, err = priv.Sign(pre)
+	return err
+}
+
+// SitemapEntries lists the sites this node would put in its sitemap: every
+// stored site with a manifest or head, except denied sites and those whose
+// manifest sets NoIndex, ordered by site ID.
+func (n *Node) SitemapEntries() ([]SitemapEntry, error) {
+	n.sitemap.mu.Lock()
+	defer n.sitemap.mu.Unlock()
+	if time.Since(n.sitemap.built) < sitemapCacheTTL {
+		return n.sitemap.entries, nil
+	}
+
+	sites, err := n.Store.ListSites()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(sites)
+	entries := make([]SitemapEntry, 0, len(sites))
+	for _, siteID := range sites {
+		if n.Store.IsDenied(siteID) {
+			continue
+		}
+		if e, ok := n.sitemapEntry(siteID); ok {
+			entries = append(entries, e)
+		}
+	}
+	n.sitemap.built, n.sitemap.entries = time.Now(), entries
+	return entries, nil
+}
+
+func (n *Node) sitemapEntry(siteID string) (SitemapEntry, bool) {
+	if n.Store.HasWebsiteManifest(siteID) {
+		data, err := n.Store.GetCurrentWebsiteManifest(siteID)
+		if err != nil {
+			return SitemapEntry{}, false
+		}
+		var m core.WebsiteManifest
+		if err := core.UnmarshalCBOR(data, &m); err != nil || m.NoIndex || m.Files[m.MainFile] == "" {
+			return SitemapEntry{}, false
+		}
+		e := SitemapEntry{SiteID: siteID, Seq: m.Seq, CID: core.CIDForBytes(data), TS: m.TS, Main: m.Files[m.MainFile], MainPath: m.MainFile}
+		if recData, err := n.Store.GetFileRecordByPath(siteID, m.MainFile); err == nil {
+			var rec core.FileRecord
+			if core.UnmarshalCBOR(recData, &rec) == nil && rec.ContentCID == e.Main {
+				e.MimeType = rec.MimeType
+			}
+		}
+		return e, e.validate() == nil
+	}
+
+	seq, headCID, err := n.Store.GetHead(siteID)
+	if err != nil || headCID == "" {
+		return SitemapEntry{}, false
+	}
+	data, err := n.Store.GetRecord(headCID)
+	if err != nil {
+		return SitemapEntry{}, false
+	}
+	var rec core.UpdateRecord
+	if err := core.UnmarshalCBOR(data, &rec); err != nil {
+		return SitemapEntry{}, false
+	}
+	e := SitemapEntry{SiteID: siteID, Seq: seq, CID: headCID, TS: rec.TS, Main: rec.ContentCID, MainPath: "index.html", MimeType: "text/html"}
+	return e, e.validate() == nil
+}
+
+// sitemapPage returns the signed page of this node's sitemap at offset.
+func (n *Node) sitemapPage(offset int) (*Sitemap, error) {
+	entries, err := n.SitemapEntries()
+	if err != nil {
+		return nil, err
+	}
+	page := &Sitemap{Time: time.Now().Unix(), Offset: offset, Total: len(entries), Entries: []SitemapEntry{}}
+	if offset < len(entries) {
+		page.Entries = entries[offset:min(offset+SitemapPageSize, len(entries))]
+	}
+	if err := n.signSitemap(page); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// SitemapPeers lists the connected peers that advertise FeatureSitemap.
+func (n *Node) SitemapPeers() []peer.ID {
+	var peers []peer.ID
+	for _, p := range n.Host.Network().Peers() {
+		if n.PeerSupports(p, FeatureSitemap) {
+			peers = append(peers, p)
+		}
+	}
+	return peers
+}
+
+// RequestSitemap fetches the sitemap page of peer p at offset and checks
+// that p signed it.
+func (n *Node) RequestSitemap(ctx context.Context, p peer.ID, offset int) (*Sitemap, error) {
+	if offset < 0 || offset > MaxSitemapOffset {
+		return nil, fmt.Errorf("offset must be between 0 and %d", MaxSitemapOffset)
+	}
+	ctx, cancel := context.WithTimeout(ctx, SitemapTimeout)
+	defer cancel()
+
+	s, err := n.Host.NewStream(ctx, p, SitemapProto)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+	if err := s.SetDeadline(time.Now().Add(SitemapTimeout)); err != nil {
+		return nil, err
+	}
+	b, err := cborMarshal(sitemapReq{Offset: offset})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.Write(b); err != nil {
+		return nil, err
+	}
+	if err := s.CloseWrite(); err != nil {
+		return nil, err
+	}
+
+	var page Sitemap
+	if err := sitemapPageDecMode.NewDecoder(io.LimitReader(s, maxSitemapMessage)).Decode(&page); err != nil {
+		return nil, err
+	}
+	if page.Peer != p.String() || page.Offset != offset {
+		return nil, errors.New("sitemap page does not answer the request")
+	}
+	if age := time.Since(time.Unix(page.Time, 0)); age > maxSitemapAge || age < -maxSitemapAge {
+		return nil, errors.New("sitemap page is stale or from the future")
+	}
+	if err := VerifySitemap(&page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+func (n *Node) handleSitemapStream(s network.Stream) {
+	defer s.Close()
+	from := s.Conn().RemotePeer()
+	if err := s.SetDeadline(time.Now().Add(SitemapTimeout)); err != nil {
+		return
+	}
+	if !n.rateLimiter.allow(from.String(), time.Now()) {
+		n.audit(AuditEntry{Kind: AuditBrowse, Peer: from.String(), Reason: "sitemap request over the rate limit"})
+		return
+	}
+
+	var req sitemapReq
+	if err := sitemapReqDecMode.NewDecoder(io.LimitReader(s, maxSitemapRequest)).Decode(&req); err != nil {
+		log.Printf("handleSitemapStream: bad request from %s: %v", from, err)
+		n.audit(AuditEntry{Kind: AuditBrowse, Peer: from.String(), Reason: "malformed sitemap request: " + err.Error()})
+		return
+	}
+	if req.Offset < 0 || req.Offset > MaxSitemapOffset {
+		n.audit(AuditEntry{Kind: AuditBrowse, Peer: from.String(), Reason: fmt.Sprintf("sitemap offset %d out of range", req.Offset)})
+		return
+	}
+	page, err := n.sitemapPage(req.Offset)
+	if err != nil {
+		log.Printf("handleSitemapStream: %v", err)
+		return
+	}
+	b, err := cborMarshal(page)
+	if err != nil {
+		return
+	}
+	if _, err := s.Write(b); err != nil {
+		log.Printf("handleSitemapStream: write failed: %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package p2p
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"alxnet/internal/core"
+	"alxnet/internal/store"
+
+	peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestSitemap(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	db, err := store.NewMemory()
+	if err != nil {
+		t.Fatalf("store.NewMemory: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	cfg := DefaultNodeConfig()
+	cfg.ServeSitemap = true
+	seeder, err := New(ctx, db, "/ip4/127.0.0.1/tcp/0", nil, cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { seeder.Host.Close() })
+	indexer, quiet := newTestNode(t, ctx), newTestNode(t, ctx)
+
+	const (
+		listed   = "aa00000000000000000000000000000000000000000000000000000000000000"
+		hidden   = "bb00000000000000000000000000000000000000000000000000000000000000"
+		single   = "cc00000000000000000000000000000000000000000000000000000000000000"
+		denied   = "dd00000000000000000000000000000000000000000000000000000000000000"
+		mainPage = "1100000000000000000000000000000000000000000000000000000000000000"
+	)
+	for _, site := range []struct {
+		id      string
+		noIndex bool
+	}{{listed, false}, {hidden, true}, {denied, false}} {
+		m := core.WebsiteManifest{Version: "v1", Seq: 3, TS: 100, MainFile: "home.html",
+			Files: map[string]string{"home.html": mainPage}, NoIndex: site.noIndex}
+		data, err := core.MarshalCanonical(&m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := db.PutWebsiteManifest(site.id, core.CIDForBytes(data), data); err != nil {
+			t.Fatalf("PutWebsiteManifest: %v", err)
+		}
+	}
+	rec := core.UpdateRecord{Version: core.RecordV1, Seq: 7, ContentCID: mainPage, TS: 200}
+	recData, err := core.MarshalCanonical(&rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.PutRecord(core.CIDForBytes(recData), recData); err != nil {
+		t.Fatalf("PutRecord: %v", err)
+	}
+	if err := db.SetHead(single, 7, core.CIDForBytes(recData)); err != nil {
+		t.Fatalf("SetHead: %v", err)
+	}
+	if _, err := db.Deny(denied, "test"); err != nil {
+		t.Fatalf("Deny: %v", err)
+	}
+
+	for _, other := range []*Node{seeder, quiet} {
+		if err := indexer.Host.Connect(ctx, peer.AddrInfo{ID: other.Host.ID(), Addrs: other.Host.Addrs()}); err != nil {
+			t.Fatalf("Connect: %v", err)
+		}
+		waitForCapabilities(t, indexer, other.Host.ID())
+	}
+	if got := indexer.SitemapPeers(); !slices.Equal(got, []peer.ID{seeder.Host.ID()}) {
+		t.Errorf("SitemapPeers() = %v, want only the seeder", got)
+	}
+
+	page, err := indexer.RequestSitemap(ctx, seeder.Host.ID(), 0)
+	if err != nil {
+		t.Fatalf("RequestSitemap: %v", err)
+	}
+	var sites []string
+	for _, e := range page.Entries {
+		sites = append(sites, e.SiteID)
+	}
+	if page.Total != 2 || !slices.Equal(sites, []string{listed, single}) {
+		t.Fatalf("sitemap lists %v of %d, want %s and %s", sites, page.Total, listed, single)
+	}
+	if e := page.Entries[0]; e.Seq != 3 || e.Main != mainPage || e.MainPath != "home.html" || e.TS != 100 {
+		t.Errorf("manifest entry = %+v", e)
+	}
+	if e := page.Entries[1]; e.Seq != 7 || e.CID != core.CIDForBytes(recData) || e.MimeType != "text/html" {
+		t.Errorf("head entry = %+v", e)
+	}
+
+	if page, err := indexer.RequestSitemap(ctx, seeder.Host.ID(), 5); err != nil || len(page.Entries) != 0 || page.Total != 2 {
+		t.Errorf("page past the end = %+v, %v", page, err)
+	}
+	if _, err := indexer.RequestSitemap(ctx, seeder.Host.ID(), MaxSitemapOffset+1); err == nil {
+		t.Error("RequestSitemap() accepted an offset out of range")
+	}
+	if _, err := indexer.RequestSitemap(ctx, quiet.Host.ID(), 0); err == nil {
+		t.Error("a node without ServeSitemap answered")
+	}
+
+	tests := []struct {
+		name   string
+		tamper func(p *Sitemap)
+	}{
+		{"entry", func(p *Sitemap) { p.Entries[0].Main = p.Entries[1].CID }},
+		{"dropped entry", func(p *Sitemap) { p.Entries = p.Entries[:1] }},
+		{"total", func(p *Sitemap) { p.Total = 1 }},
+		{"signer", func(p *Sitemap) { p.Peer = indexer.Host.ID().String() }},
+		{"signature", func(p *Sitemap) { p.Sig = nil }},
+		{"bad site ID", func(p *Sitemap) { p.Entries[0].SiteID = "AA" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := *page
+			c.Entries = slices.Clone(page.Entries)
+			tt.tamper(&c)
+			if err := VerifySitemap(&c); err == nil {
+				t.Error("tampered sitemap verified")
+			}
+		})
+	}
+}
@@ -0,0 +1,252 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"alxnet/internal/core"
+
+	"github.com/fxamacker/cbor/v2"
+	network "github.com/libp2p/go-libp2p/core/network"
+	peer "github.com/libp2p/go-libp2p/core/peer"
+	protocol "github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// HaveProto lets a publisher ask a peer whether it stores a site's head and,
+// optionally, a batch of content blobs.
+const HaveProto protocol.ID = "/alxnet/have/1.0.0"
+
+// HaveQueryTimeout bounds a single have/ack round trip.
+const HaveQueryTimeout = 5 * time.Second
+
+// MaxHaveCIDs is the most content CIDs a single have request may carry.
+const MaxHaveCIDs = 256
+
+// maxHaveMessage caps have/ack messages, which only carry hex IDs and flags.
+const maxHaveMessage = 32 * 1024
+
+var haveReqDecMode, _ = cbor.DecOptions{MaxArrayElements: MaxHaveCIDs, MaxMapPairs: 16}.DecMode()
+
+type haveReq struct {
+	SiteID  string   `cbor:"s"`
+	HeadCID string   `cbor:"h"`
+	CIDs    []string `cbor:"c,omitempty"` // content CIDs to check
+}
+
+type haveAck struct {
+	Ok      bool   `cbor:"ok"` // peer has some head for the site
+	Has     bool   `cbor:"has"`
+	Seq     uint64 `cbor:"seq,omitempty"`
+	HeadCID string `cbor:"h,omitempty"`
+	Content []bool `cbor:"cc,omitempty"` // one flag per requested CID
+}
+
+// SiteStats summarises how well a site's latest head has replicated.
+type SiteStats struct {
+	SiteID        string    `json:"site_id"`
+	Seq           uint64    `json:"seq"`
+	HeadCID       string    `json:"head_cid"`
+	ContentCID    string    `json:"content_cid,omitempty"` // content of the head record
+	QueriedPeers  int       `json:"queried_peers"`
+	AckedPeers    int       `json:"acked_peers"` // peers storing the latest head
+	Providers     int       `json:"providers"`   // peers storing any head for the site
+	LastPublished time.Time `json:"last_published"`
+	LastAck       time.Time `json:"last_ack"`
+}
+
+// siteTelemetry is the per-site state kept between queries.
+type siteTelemetry struct {
+	headCID       string
+	lastPublished time.Time
+	lastAck       time.Time
+	ackedBy       map[peer.ID]time.Time
+}
+
+type telemetry struct {
+	mu    sync.Mutex
+	sites map[string]*siteTelemetry
+}
+
+func (t *telemetry) site(siteID string) *siteTelemetry {
+	if t.sites == nil {
+		t.sites = make(map[string]*siteTelemetry)
+	}
+	st, ok := t.sites[siteID]
+	if !ok {
+		st = &siteTelemetry{ackedBy: make(map[peer.ID]time.Time)}
+		t.sites[siteID] = st
+	}
+	return st
+}
+
+// NotePublished records that this node just published a new head for siteID.
+// Acks collected for older heads are discarded.
+func (n *Node) NotePublished(siteID, headCID string) {
+	n.telemetry.mu.Lock()
+	defer n.telemetry.mu.Unlock()
+
+	st := n.telemetry.site(siteID)
+	if st.headCID != headCID {
+		st.headCID = headCID
+		st.ackedBy = make(map[peer.ID]time.Time)
+		st.lastAck = time.Time{}
+	}
+	st.lastPublished = time.Now()
+}
+
+func (n *Node) noteAck(siteID, headCID string, p peer.ID) {
+	n.telemetry.mu.Lock()
+	defer n.telemetry.mu.Unlock()
+
+	st := n.telemetry.site(siteID)
+	if st.headCID != headCID {
+		st.headCID = headCID
+		st.ackedBy = make(map[peer.ID]time.Time)
+	}
+	now := time.Now()
+	st.ackedBy[p] = now
+	st.lastAck = now
+}
+
+// SiteStats asks every connected peer whether it stores the current head of
+// siteID and returns the aggregated replication state. Peers that acked
+// earlier but are no longer reachable still count towards AckedPeers.
+func (n *Node) SiteStats(ctx context.Context, siteID string) (*SiteStats, error) {
+	has, err := n.Store.HasHead(siteID)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, errors.New("site has no local head")
+	}
+	seq, headCID, err := n.Store.GetHead(siteID)
+	if err != nil {
+		return nil, err
+	}
+
+	peers := n.Host.Network().Peers()
+	type result struct {
+		id  peer.ID
+		ack *haveAck
+	}
+	results := make(chan result, len(peers))
+	var wg sync.WaitGroup
+	for _, p := range peers {
+		wg.Add(1)
+		go func(p peer.ID) {
+			defer wg.Done()
+			ack, err := n.queryHave(ctx, p, haveReq{SiteID: siteID, HeadCID: headCID})
+			if err != nil {
+				return
+			}
+			results <- result{id: p, ack: ack}
+		}(p)
+	}
+	wg.Wait()
+	close(results)
+
+	stats := &SiteStats{
+		SiteID:       siteID,
+		Seq:          seq,
+		HeadCID:      headCID,
+		QueriedPeers: len(peers),
+	}
+	if recBytes, err := n.Store.GetRecord(headCID); err == nil {
+		var rec core.UpdateRecord
+		if cborUnmarshal(recBytes, &rec) == nil {
+			stats.ContentCID = rec.ContentCID
+		}
+	}
+	for r := range results {
+		if r.ack.Ok {
+			stats.Providers++
+		}
+		if r.ack.Has {
+			n.noteAck(siteID, headCID, r.id)
+		}
+	}
+
+	n.telemetry.mu.Lock()
+	st := n.telemetry.site(siteID)
+	if st.headCID == headCID {
+		stats.AckedPeers = len(st.ackedBy)
+		stats.LastAck = st.lastAck
+	}
+	stats.LastPublished = st.lastPublished
+	n.telemetry.mu.Unlock()
+
+	return stats, nil
+}
+
+// queryHave sends a single have request to peer p.
+func (n *Node) queryHave(ctx context.Context, p peer.ID, req haveReq) (*haveAck, error) {
+	ctx, cancel := context.WithTimeout(ctx, HaveQueryTimeout)
+	defer cancel()
+
+	s, err := n.Host.NewStream(ctx, p, HaveProto)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+
+	if err := s.SetDeadline(time.Now().Add(HaveQueryTimeout)); err != nil {
+		return nil, err
+	}
+	b, err := cborMarshal(req)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.Write(b); err != nil {
+		return nil, err
+	}
+	if err := s.CloseWrite(); err != nil {
+		return nil, err
+	}
+
+	var ack haveAck
+	if err := core.NewCBORDecoder(io.LimitReader(s, maxHaveMessage)).Decode(&ack); err != nil {
+		return nil, err
+	}
+	if len(req.CIDs) > 0 && len(ack.Content) != len(req.CIDs) {
+		return nil, errors.New("malformed have ack")
+	}
+	return &ack, nil
+}
+
+func (n *Node) handleHaveStream(s network.Stream) {
+	defer s.Close()
+
+	if err := s.SetDeadline(time.Now().Add(HaveQueryTimeout)); err != nil {
+		return
+	}
+
+	var req haveReq
+	if err := haveReqDecMode.NewDecoder(io.LimitReader(s, maxHaveMessage)).Decode(&req); err != nil {
+		log.Printf("handleHaveStream: bad request from %s: %v", s.Conn().RemotePeer(), err)
+		return
+	}
+	if len(req.CIDs) > MaxHaveCIDs {
+		return
+	}
+
+	var ack haveAck
+	if has, _ := n.Store.HasHead(req.SiteID); has {
+		if seq, headCID, err := n.Store.GetHead(req.SiteID); err == nil {
+			ack = haveAck{Ok: true, Has: headCID == req.HeadCID, Seq: seq, HeadCID: headCID}
+		}
+	}
+	if len(req.CIDs) > 0 {
+		ack.Content = make([]bool, len(req.CIDs))
+		for i, cid := range req.CIDs {
+			ack.Content[i], _ = n.Store.HasContent(cid)
+		}
+	}
+	b, _ := cborMarshal(ack)
+	if _, err := s.Write(b); err != nil {
+		log.Printf("handleHaveStream: write failed: %v", err)
+	}
+}
@@ -0,0 +1,119 @@
+package p2p
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"alxnet/internal/store"
+
+	peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+func newTestNode(t *testing.T, ctx context.Context) *Node {
+	t.Helper()
+	db, err := store.NewMemory()
+	if err != nil {
+		t.Fatalf("store.NewMemory: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	n, err := New(ctx, db, "/ip4/127.0.0.1/tcp/0", nil, nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { n.Host.Close() })
+	return n
+}
+
+func TestSiteStats(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	const siteID = "aa00000000000000000000000000000000000000000000000000000000000000"
+	const headCID = "bb00000000000000000000000000000000000000000000000000000000000000"
+	const staleCID = "cc00000000000000000000000000000000000000000000000000000000000000"
+
+	publisher := newTestNode(t, ctx)
+	current := newTestNode(t, ctx)
+	stale := newTestNode(t, ctx)
+	empty := newTestNode(t, ctx)
+
+	if err := publisher.Store.PutHead(siteID, 2, headCID); err != nil {
+		t.Fatalf("PutHead: %v", err)
+	}
+	publisher.NotePublished(siteID, headCID)
+	if err := current.Store.PutHead(siteID, 2, headCID); err != nil {
+		t.Fatalf("PutHead: %v", err)
+	}
+	if err := stale.Store.PutHead(siteID, 1, staleCID); err != nil {
+		t.Fatalf("PutHead: %v", err)
+	}
+
+	for _, other := range []*Node{current, stale, empty} {
+		info := peer.AddrInfo{ID: other.Host.ID(), Addrs: other.Host.Addrs()}
+		if err := publisher.Host.Connect(ctx, info); err != nil {
+			t.Fatalf("Connect: %v", err)
+		}
+	}
+
+	stats, err := publisher.SiteStats(ctx, siteID)
+	if err != nil {
+		t.Fatalf("SiteStats() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		got  int
+		want int
+	}{
+		{"queried peers", stats.QueriedPeers, 3},
+		{"acked peers", stats.AckedPeers, 1},
+		{"providers", stats.Providers, 2},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %d, want %d", tt.name, tt.got, tt.want)
+		}
+	}
+	if stats.Seq != 2 || stats.HeadCID != headCID {
+		t.Errorf("head = (%d, %s), want (2, %s)", stats.Seq, stats.HeadCID, headCID)
+	}
+	if stats.LastPublished.IsZero() || stats.LastAck.IsZero() {
+		t.Errorf("expected publish and ack times, got %v and %v", stats.LastPublished, stats.LastAck)
+	}
+}
+
+func TestNotePublishedResetsAcks(t *testing.T) {
+	var n Node
+	const siteID = "aa00000000000000000000000000000000000000000000000000000000000000"
+
+	n.NotePublished(siteID, "old")
+	n.noteAck(siteID, "old", peer.ID("peer-a"))
+	n.noteAck(siteID, "old", peer.ID("peer-b"))
+	if got := len(n.telemetry.sites[siteID].ackedBy); got != 2 {
+		t.Fatalf("acks for old head = %d, want 2", got)
+	}
+
+	// Re-publishing the same head keeps its acks.
+	n.NotePublished(siteID, "old")
+	if got := len(n.telemetry.sites[siteID].ackedBy); got != 2 {
+		t.Errorf("acks after re-publish = %d, want 2", got)
+	}
+
+	n.NotePublished(siteID, "new")
+	st := n.telemetry.sites[siteID]
+	if len(st.ackedBy) != 0 || !st.lastAck.IsZero() {
+		t.Errorf("acks after new head = %d (last %v), want none", len(st.ackedBy), st.lastAck)
+	}
+}
+
+func TestSiteStatsUnknownSite(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	n := newTestNode(t, ctx)
+	if _, err := n.SiteStats(ctx, "ee00000000000000000000000000000000000000000000000000000000000000"); err == nil {
+		t.Error("SiteStats() for a site without a head should fail")
+	}
+}
@@ -0,0 +1,151 @@
+package p2p
+
+import (
+	"context"
+	"hash/fnv"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Validation pool defaults. Gossip is validated off the subscription loop so
+// that one slow disk write does not hold up every other site.
+const (
+	DefaultValidationQueueSize = 64
+	maxValidationWorkers       = 8
+	// ValidationEnqueueTimeout is how long the subscription loop waits for
+	// room in a full queue before dropping the message.
+	ValidationEnqueueTimeout = time.Second
+)
+
+// DefaultValidationWorkers is one worker per CPU, up to eight.
+func DefaultValidationWorkers() int {
+	return min(runtime.NumCPU(), maxValidationWorkers)
+}
+
+// ValidationStats reports throughput and backpressure of the gossip
+// validation pool.
+type ValidationStats struct {
+	Workers       int    `json:"workers"`
+	QueueCapacity int    `json:"queue_capacity"` // per worker
+	Queued        int    `json:"queued"`         // waiting right now
+	MaxQueued     int    `json:"max_queued"`     // deepest single queue seen
+	Processed     uint64 `json:"processed"`
+	Rejected      uint64 `json:"rejected"`
+	Waits         uint64 `json:"waits"`   // enqueues that found the queue full
+	Dropped       uint64 `json:"dropped"` // messages dropped after waiting
+}
+
+type validationJob func() error
+
+// validationPool runs jobs on a fixed set of workers. Jobs for the same site
+// always hash to the same worker, whose queue is FIFO, so updates and
+// deletes of one site are applied in the order they arrived.
+type validationPool struct {
+	queues []chan validationJob
+	wg     sync.WaitGroup
+
+	processed atomic.Uint64
+	rejected  atomic.Uint64
+	waits     atomic.Uint64
+	dropped   atomic.Uint64
+	maxQueued atomic.Int64
+}
+
+func newValidationPool(workers, queueSize int) *validationPool {
+	if workers <= 0 {
+		workers = DefaultValidationWorkers()
+	}
+	if queueSize <= 0 {
+		queueSize = DefaultValidationQueueSize
+	}
+	p := &validationPool{queues: make([]chan validationJob, workers)}
+	for i := range p.queues {
+		p.queues[i] = make(chan validationJob, queueSize)
+	}
+	return p
+}
+
+// start launches the workers; they exit once ctx is done.
+func (p *validationPool) start(ctx context.Context) {
+	for _, q := range p.queues {
+		p.wg.Add(1)
+		go func(q chan validationJob) {
+			defer p.wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case job := <-q:
+					if err := job(); err != nil {
+						p.rejected.Add(1)
+					}
+					p.processed.Add(1)
+				}
+			}
+		}(q)
+	}
+}
+
+// submit queues job on the worker owning siteID. When that queue is full it
+// waits up to ValidationEnqueueTimeout and then drops the job; it reports
+// whether the job was queued.
+func (p *validationPool) submit(ctx context.Context, siteID string, job validationJob) bool {
+	q := p.queues[p.worker(siteID)]
+	select {
+	case q <- job:
+		p.noteDepth(len(q))
+		return true
+	default:
+	}
+
+	p.waits.Add(1)
+	timer := time.NewTimer(ValidationEnqueueTimeout)
+	defer timer.Stop()
+	select {
+	case q <- job:
+		p.noteDepth(len(q))
+		return true
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+	p.dropped.Add(1)
+	return false
+}
+
+func (p *validationPool) worker(siteID string) int {
+	h := fnv.New32a()
+	h.Write([]byte(siteID))
+	return int(h.Sum32() % uint32(len(p.queues)))
+}
+
+func (p *validationPool) noteDepth(depth int) {
+	for {
+		cur := p.maxQueued.Load()
+		if int64(depth) <= cur || p.maxQueued.CompareAndSwap(cur, int64(depth)) {
+			return
+		}
+	}
+}
+
+func (p *validationPool) stats() ValidationStats {
+	st := ValidationStats{
+		Workers:       len(p.queues),
+		QueueCapacity: cap(p.queues[0]),
+		MaxQueued:     int(p.maxQueued.Load()),
+		Processed:     p.processed.Load(),
+		Rejected:      p.rejected.Load(),
+		Waits:         p.waits.Load(),
+		Dropped:       p.dropped.Load(),
+	}
+	for _, q := range p.queues {
+		st.Queued += len(q)
+	}
+	return st
+}
+
+// ValidationStats returns the gossip validation pool counters.
+func (n *Node) ValidationStats() ValidationStats {
+	return n.validation.stats()
+}
@@ -0,0 +1,97 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestValidationPoolPerSiteOrder(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := newValidationPool(4, 8)
+	p.start(ctx)
+
+	var mu sync.Mutex
+	seen := make(map[string][]int)
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		site, seq := fmt.Sprintf("site-%d", i%5), i/5
+		wg.Add(1)
+		if !p.submit(ctx, site, func() error {
+			defer wg.Done()
+			mu.Lock()
+			seen[site] = append(seen[site], seq)
+			mu.Unlock()
+			if seq%10 == 0 {
+				return errors.New("rejected")
+			}
+			return nil
+		}) {
+			t.Fatal("submit() dropped a job")
+		}
+	}
+	wg.Wait()
+
+	for site, seqs := range seen {
+		for i, seq := range seqs {
+			if seq != i {
+				t.Fatalf("%s applied out of order: %v", site, seqs)
+			}
+		}
+	}
+	st := p.stats()
+	if st.Processed != 200 || st.Rejected != 20 || st.Dropped != 0 {
+		t.Errorf("stats = %+v, want 200 processed, 20 rejected, 0 dropped", st)
+	}
+}
+
+func TestValidationPoolBackpressure(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := newValidationPool(2, 1)
+
+	// Find two sites served by different workers
+	slow, fast := "slow", ""
+	for i := 0; fast == ""; i++ {
+		if s := fmt.Sprintf("site-%d", i); p.worker(s) != p.worker(slow) {
+			fast = s
+		}
+	}
+	p.start(ctx)
+
+	// Block the slow site's worker and fill its queue
+	release := make(chan struct{})
+	started := make(chan struct{})
+	p.submit(ctx, slow, func() error { close(started); <-release; return nil })
+	<-started
+	if !p.submit(ctx, slow, func() error { return nil }) {
+		t.Fatal("submit() dropped a job with room in the queue")
+	}
+
+	// Other sites keep flowing while the slow one is stuck
+	done := make(chan struct{})
+	p.submit(ctx, fast, func() error { close(done); return nil })
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a blocked site stalled another site's worker")
+	}
+
+	// A full queue waits, then drops
+	start := time.Now()
+	if p.submit(ctx, slow, func() error { return nil }) {
+		t.Fatal("submit() accepted a job into a full queue")
+	}
+	if waited := time.Since(start); waited < ValidationEnqueueTimeout {
+		t.Errorf("submit() gave up after %v, want %v", waited, ValidationEnqueueTimeout)
+	}
+	st := p.stats()
+	if st.Waits != 1 || st.Dropped != 1 || st.Queued != 1 || st.MaxQueued != 1 {
+		t.Errorf("stats = %+v, want 1 wait, 1 drop, 1 queued", st)
+	}
+	close(release)
+}
@@ -0,0 +1,237 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"alxnet/internal/core"
+
+	peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+// The want list is the node's one queue of content it is fetching from
+// peers. The browser and gateway, the search indexer and embedded nodes all
+// fetch through Want, so concurrent wants for the same CID share a single
+// fetch. A fetch asks up to WantPeers peers, WantFanout at a time, trying
+// the peers with the fewest of this node's requests in flight first, and
+// every waiter gets the first answer matching the CID. A want has the
+// priority of its most urgent waiter, and each of its requests waits for a
+// slot of that class (see priority.go). Content that arrives another way,
+// such as inline in gossip, completes a matching want as well. A fetch
+// stops when its last waiter gives up.
+
+// Want list settings
+const (
+	WantPeers  = 3 // peers asked for one CID at most
+	WantFanout = 2 // peers asked at once
+)
+
+// ErrNoPeers is returned by Want when the node has no connected peers.
+var ErrNoPeers = errors.New("no connected peers")
+
+// WantStatus is a CID the node is fetching.
+type WantStatus struct {
+	CID      string    `json:"cid"`
+	Priority string    `json:"priority"`
+	Waiters  int       `json:"waiters"`
+	Asked    int       `json:"peers_asked"`
+	Since    time.Time `json:"since"`
+}
+
+type want struct {
+	cid      string
+	priority Priority // of the most urgent waiter
+	since    time.Time
+	waiters  int
+	asked    int
+	cancel   context.CancelFunc
+
+	done chan struct{} // closed once data or err is set
+	data []byte
+	err  error
+}
+
+type wantList struct {
+	mu       sync.Mutex
+	wants    map[string]*want
+	inflight map[peer.ID]int // requests to each peer, across all wants
+}
+
+// join adds a waiter of priority p to the want for cid, raising its
+// priority if p is more urgent. For a new want it also returns the context
+// its fetch must run under.
+func (l *wantList) join(cid string, p Priority) (*want, context.Context) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if w := l.wants[cid]; w != nil {
+		w.waiters++
+		w.priority = min(w.priority, p)
+		return w, nil
+	}
+	if l.wants == nil {
+		l.wants = make(map[string]*want)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &want{cid: cid, priority: p, since: time.Now(), waiters: 1, cancel: cancel, done: make(chan struct{})}
+	l.wants[cid] = w
+	return w, ctx
+}
+
+// leave removes a waiter that gave up, stopping the fetch if it was the
+// last.
+func (l *wantList) leave(w *want) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	w.waiters--
+	if w.waiters == 0 && l.wants[w.cid] == w {
+		delete(l.wants, w.cid)
+		w.cancel()
+	}
+}
+
+// finish completes w, unless it was abandoned or already completed.
+func (l *wantList) finish(w *want, data []byte, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.wants[w.cid] != w {
+		return
+	}
+	delete(l.wants, w.cid)
+	w.data, w.err = data, err
+	close(w.done)
+	w.cancel()
+}
+
+// deliver completes a want for cid with content that arrived some other
+// way. data must match cid.
+func (l *wantList) deliver(cid string, data []byte) {
+	l.mu.Lock()
+	w := l.wants[cid]
+	l.mu.Unlock()
+	if w != nil {
+		l.finish(w, data, nil)
+	}
+}
+
+// busy adds delta to the requests in flight to p.
+func (l *wantList) busy(p peer.ID, delta int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inflight == nil {
+		l.inflight = make(map[peer.ID]int)
+	}
+	if l.inflight[p] += delta; l.inflight[p] <= 0 {
+		delete(l.inflight, p)
+	}
+}
+
+// Want returns the content of cid from peers, asking prefer first if it is
+// set, with requests of priority p. It waits until a peer sends content
+// matching cid, every peer asked fails, or ctx is done. Callers wanting the
+// same CID at once share one fetch, and the first caller's prefer is the
+// one used.
+func (n *Node) Want(ctx context.Context, cid string, prefer peer.ID, p Priority) ([]byte, error) {
+	w, fetchCtx := n.wants.join(cid, p)
+	if fetchCtx != nil {
+		go n.fetchWant(fetchCtx, w, prefer)
+	}
+	select {
+	case <-w.done:
+		return w.data, w.err
+	case <-ctx.Done():
+		n.wants.leave(w)
+		return nil, ctx.Err()
+	}
+}
+
+// WantList returns the CIDs being fetched, oldest first.
+func (n *Node) WantList() []WantStatus {
+	n.wants.mu.Lock()
+	list := make([]WantStatus, 0, len(n.wants.wants))
+	for _, w := range n.wants.wants {
+		list = append(list, WantStatus{CID: w.cid, Priority: w.priority.String(), Waiters: w.waiters, Asked: w.asked, Since: w.since})
+	}
+	n.wants.mu.Unlock()
+	slices.SortFunc(list, func(a, b WantStatus) int { return a.Since.Compare(b.Since) })
+	return list
+}
+
+// wantCandidates returns prefer and other connected peers, up to
+// WantPeers, the others by fewest requests in flight.
+func (n *Node) wantCandidates(prefer peer.ID) []peer.ID {
+	peers := n.Host.Network().Peers()
+	n.wants.mu.Lock()
+	slices.SortStableFunc(peers, func(a, b peer.ID) int { return n.wants.inflight[a] - n.wants.inflight[b] })
+	n.wants.mu.Unlock()
+
+	var candidates []peer.ID
+	if prefer != "" {
+		candidates = append(candidates, prefer)
+	}
+	for _, p := range peers {
+		if len(candidates) == WantPeers {
+			break
+		}
+		if p != prefer {
+			candidates = append(candidates, p)
+		}
+	}
+	return candidates
+}
+
+// fetchWant asks peers for w's CID in waves of WantFanout and completes w
+// with the first matching answer.
+func (n *Node) fetchWant(ctx context.Context, w *want, prefer peer.ID) {
+	candidates := n.wantCandidates(prefer)
+	if len(candidates) == 0 {
+		n.wants.finish(w, nil, ErrNoPeers)
+		return
+	}
+	type answer struct {
+		data []byte
+		err  error
+	}
+	var lastErr error
+	for i := 0; i < len(candidates) && ctx.Err() == nil; i += WantFanout {
+		wave := candidates[i:min(i+WantFanout, len(candidates))]
+		n.wants.mu.Lock()
+		w.asked += len(wave)
+		n.wants.mu.Unlock()
+
+		answers := make(chan answer, len(wave))
+		for _, p := range wave {
+			go func() {
+				n.wants.mu.Lock()
+				pri := w.priority
+				n.wants.mu.Unlock()
+				var data []byte
+				err := n.scheduled(ctx, pri, func() (err error) {
+					n.wants.busy(p, 1)
+					defer n.wants.busy(p, -1)
+					data, err = n.RequestContent(ctx, peer.AddrInfo{ID: p}, w.cid)
+					return err
+				})
+				if err == nil && core.CIDForContent(data) != w.cid {
+					err = fmt.Errorf("peer %s sent content not matching %s", p, w.cid)
+				}
+				answers <- answer{data, err}
+			}()
+		}
+		for range wave {
+			a := <-answers
+			if a.err == nil {
+				n.wants.finish(w, a.data, nil)
+				return
+			}
+			lastErr = a.err
+		}
+	}
+	if ctx.Err() != nil {
+		return // abandoned or completed by deliver
+	}
+	n.wants.finish(w, nil, lastErr)
+}
@@ -0,0 +1,91 @@
+package p2p
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"alxnet/internal/core"
+
+	peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestWantSharesFetches(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	provider := newTestNode(t, ctx)
+	empty := newTestNode(t, ctx)
+	n := newTestNode(t, ctx)
+
+	content := []byte("wanted blob")
+	cid := core.CIDForContent(content)
+	if err := provider.Store.PutContent(cid, content); err != nil {
+		t.Fatalf("PutContent: %v", err)
+	}
+
+	if _, err := n.Want(ctx, cid, "", PriorityInteractive); !errors.Is(err, ErrNoPeers) {
+		t.Fatalf("Want() without peers = %v, want ErrNoPeers", err)
+	}
+	for _, p := range []*Node{provider, empty} {
+		if err := n.Host.Connect(ctx, peer.AddrInfo{ID: p.Host.ID(), Addrs: p.Host.Addrs()}); err != nil {
+			t.Fatalf("Connect: %v", err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := n.Want(ctx, cid, empty.Host.ID(), PriorityInteractive)
+			if err != nil || !bytes.Equal(got, content) {
+				t.Errorf("Want() = %q, %v", got, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if wants := n.WantList(); len(wants) != 0 {
+		t.Errorf("WantList() after the fetch = %+v", wants)
+	}
+
+	missing := core.CIDForContent([]byte("nobody has this"))
+	if _, err := n.Want(ctx, missing, "", PriorityBackground); err == nil {
+		t.Error("Want() of content no peer has succeeded")
+	}
+}
+
+func TestWantListDeliverAndLeave(t *testing.T) {
+	var l wantList
+	content := []byte("gossiped")
+	cid := core.CIDForContent(content)
+
+	w, fetchCtx := l.join(cid, PriorityBackground)
+	if fetchCtx == nil {
+		t.Fatal("first join did not start a fetch")
+	}
+	if again, ctx := l.join(cid, PriorityInteractive); again != w || ctx != nil {
+		t.Fatal("second join did not share the want")
+	}
+	if w.priority != PriorityInteractive {
+		t.Errorf("want priority after an interactive join = %s", w.priority)
+	}
+	l.deliver(cid, content)
+	select {
+	case <-w.done:
+	default:
+		t.Fatal("deliver did not complete the want")
+	}
+	if !bytes.Equal(w.data, content) || fetchCtx.Err() == nil {
+		t.Errorf("delivered want = %q, fetch context %v", w.data, fetchCtx.Err())
+	}
+
+	w, fetchCtx = l.join(cid, PriorityInteractive)
+	l.leave(w)
+	if fetchCtx.Err() == nil || len(l.wants) != 0 {
+		t.Error("the last waiter leaving did not stop the fetch")
+	}
+}
@@ -0,0 +1,94 @@
+package p2p
+
+import (
+	"crypto/ed25519"
+	"time"
+
+	"alxnet/internal/core"
+	bncrypto "alxnet/internal/crypto"
+	"alxnet/internal/verify"
+)
+
+// BuildFileRecord signs a file record binding path to contentCID under the
+// site key: the site key links a fresh update key, which signs the
+// canonical record.
+func BuildFileRecord(sitePriv ed25519.PrivateKey, path, contentCID, mimeType string) (*core.FileRecord, error) {
+	upPub, upPriv, err := bncrypto.GenerateUpdateKey()
+	if err != nil {
+		return nil, err
+	}
+	sitePub := sitePriv.Public().(ed25519.PublicKey)
+	rec := &core.FileRecord{
+		Version:    "v1",
+		SitePub:    sitePub,
+		Path:       path,
+		ContentCID: contentCID,
+		MimeType:   mimeType,
+		TS:         time.Now().Unix(),
+		UpdatePub:  upPub,
+	}
+	rec.LinkSig = ed25519.Sign(sitePriv, bncrypto.PreimageFileLink(sitePub, upPub, rec.Path, rec.ContentCID, rec.TS))
+	noUS, err := core.CanonicalMarshalFileRecordNoUpdateSig(rec)
+	if err != nil {
+		return nil, err
+	}
+	rec.UpdateSig = ed25519.Sign(upPriv, bncrypto.PreimageUpdate(noUS))
+	return rec, rec.Validate()
+}
+
+// VerifyFileRecord checks structure and both signatures of a file record.
+func VerifyFileRecord(rec *core.FileRecord) error {
+	return verify.FileRecord(rec)
+}
+
+// BuildWebsiteManifest signs a manifest for seq, chained to the manifest
+// prevCID, the same way update records are signed. noIndex sets the
+// manifest's opt-out from sitemaps and indexers, and preload lists the
+// files nodes fetch first.
+func BuildWebsiteManifest(sitePriv ed25519.PrivateKey, seq uint64, prevCID, mainFile string, files map[string]string, noIndex bool, preload []string) (*core.WebsiteManifest, error) {
+	upPub, upPriv, err := bncrypto.GenerateUpdateKey()
+	if err != nil {
+		return nil, err
+	}
+	sitePub := sitePriv.Public().(ed25519.PublicKey)
+	m := &core.WebsiteManifest{
+		Version:   "v1",
+		SitePub:   sitePub,
+		Seq:       seq,
+		PrevCID:   prevCID,
+		TS:        time.Now().Unix(),
+		MainFile:  mainFile,
+		Files:     files,
+		UpdatePub: upPub,
+		NoIndex:   noIndex,
+		Preload:   preload,
+	}
+	filesCID, err := verify.ManifestFilesCID(files)
+	if err != nil {
+		return nil, err
+	}
+	m.LinkSig = ed25519.Sign(sitePriv, bncrypto.PreimageLink(sitePub, upPub, m.Seq, m.PrevCID, filesCID, m.TS))
+	noUS, err := core.CanonicalMarshalWebsiteManifestNoUpdateSig(m)
+	if err != nil {
+		return nil, err
+	}
+	m.UpdateSig = ed25519.Sign(upPriv, bncrypto.PreimageUpdate(noUS))
+	return m, m.Validate()
+}
+
+// VerifyWebsiteManifest checks structure and both signatures of a manifest.
+func VerifyWebsiteManifest(m *core.WebsiteManifest) error {
+	return verify.WebsiteManifest(m)
+}
+
+// VerifySiteFileRecord verifies a file record and checks that it belongs to
+// siteID and is stored under path.
+func VerifySiteFileRecord(rec *core.FileRecord, siteID, path string) error {
+	return verify.SiteFileRecord(rec, siteID, path)
+}
+
+// VerifySiteManifest verifies a manifest and checks that it belongs to
+// siteID.
+func VerifySiteManifest(m *core.WebsiteManifest, siteID string) error {
+	return verify.SiteManifest(m, siteID)
+}
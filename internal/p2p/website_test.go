@@ -0,0 +1,97 @@
+package p2p
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"strings"
+	"testing"
+
+	"alxnet/internal/core"
+)
+
+func TestFileRecordSignatures(t *testing.T) {
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	_, otherPriv, _ := ed25519.GenerateKey(rand.Reader)
+	cid := strings.Repeat("a", 64)
+
+	rec, err := BuildFileRecord(priv, "css/site.css", cid, "text/css")
+	if err != nil {
+		t.Fatalf("BuildFileRecord: %v", err)
+	}
+	if err := VerifyFileRecord(rec); err != nil {
+		t.Fatalf("VerifyFileRecord: %v", err)
+	}
+	// The record survives an encode/decode round trip
+	data, err := core.CanonicalMarshalFileRecord(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded core.FileRecord
+	if err := core.UnmarshalCBOR(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyFileRecord(&decoded); err != nil {
+		t.Errorf("VerifyFileRecord(decoded): %v", err)
+	}
+
+	other, _ := BuildFileRecord(otherPriv, "css/site.css", cid, "text/css")
+	tests := []struct {
+		name   string
+		tamper func(r *core.FileRecord)
+	}{
+		{"path", func(r *core.FileRecord) { r.Path = "css/evil.css" }},
+		{"content", func(r *core.FileRecord) { r.ContentCID = strings.Repeat("b", 64) }},
+		{"mime type", func(r *core.FileRecord) { r.MimeType = "text/html" }},
+		{"timestamp", func(r *core.FileRecord) { r.TS-- }},
+		{"site key", func(r *core.FileRecord) { r.SitePub = other.SitePub }},
+		{"update key", func(r *core.FileRecord) { r.UpdatePub, r.UpdateSig = other.UpdatePub, other.UpdateSig }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := *rec
+			tt.tamper(&r)
+			if err := VerifyFileRecord(&r); err == nil {
+				t.Error("tampered file record verified")
+			}
+		})
+	}
+}
+
+func TestWebsiteManifestSignatures(t *testing.T) {
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	files := map[string]string{"index.html": strings.Repeat("a", 64), "site.css": strings.Repeat("b", 64)}
+
+	m, err := BuildWebsiteManifest(priv, 2, strings.Repeat("c", 64), "index.html", files, false, []string{"site.css"})
+	if err != nil {
+		t.Fatalf("BuildWebsiteManifest: %v", err)
+	}
+	if _, err := BuildWebsiteManifest(priv, 2, "", "index.html", files, false, []string{"missing.js"}); err == nil {
+		t.Error("BuildWebsiteManifest() preloading a file the site lacks succeeded")
+	}
+	if err := VerifyWebsiteManifest(m); err != nil {
+		t.Fatalf("VerifyWebsiteManifest: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		tamper func(m *core.WebsiteManifest)
+	}{
+		{"added file", func(m *core.WebsiteManifest) {
+			m.Files = map[string]string{"index.html": files["index.html"], "site.css": files["site.css"], "x.js": files["site.css"]}
+		}},
+		{"seq", func(m *core.WebsiteManifest) { m.Seq = 3 }},
+		{"prev", func(m *core.WebsiteManifest) { m.PrevCID = strings.Repeat("d", 64) }},
+		{"main file", func(m *core.WebsiteManifest) { m.MainFile = "site.css" }},
+		{"no index", func(m *core.WebsiteManifest) { m.NoIndex = true }},
+		{"preload", func(m *core.WebsiteManifest) { m.Preload = nil }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := *m
+			tt.tamper(&c)
+			if err := VerifyWebsiteManifest(&c); err == nil {
+				t.Error("tampered manifest verified")
+			}
+		})
+	}
+}
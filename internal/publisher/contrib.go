@@ -0,0 +1,94 @@
+package publisher
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"time"
+
+	"alxnet/internal/core"
+	"alxnet/internal/p2p"
+	"alxnet/internal/store"
+)
+
+// A site owner lets others edit parts of a site by giving them a
+// ContributorGrant. A contributor signs a file record for their file with
+// their own key and hands the owner a Contribution; the owner checks it
+// against the grant and saves the file as a draft, which the next publish
+// signs with the site key into the manifest like any other draft. The site
+// key never leaves the owner.
+
+// Contribution is a file signed by a contributor, as exchanged with the
+// site owner.
+type Contribution struct {
+	Grant   string `json:"grant"`   // see p2p.EncodeContributorGrant
+	Record  []byte `json:"record"`  // canonical CBOR of the core.FileRecord
+	Content []byte `json:"content"` // the file
+}
+
+// Contribute signs content at path with contributorPriv for the site of
+// grant, which must be a current grant to that key covering path.
+func Contribute(contributorPriv ed25519.PrivateKey, grant, path string, content []byte, mimeType string) (*Contribution, error) {
+	g, err := p2p.DecodeContributorGrant(grant)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if err := p2p.VerifyContributorGrant(g, time.Now()); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if !contributorPriv.Public().(ed25519.PublicKey).Equal(ed25519.PublicKey(g.ContributorPub)) {
+		return nil, fmt.Errorf("%w: the grant is for another contributor key", ErrInvalid)
+	}
+	if err := core.ValidateFilePath(path); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if !g.Allows(path) {
+		return nil, fmt.Errorf("%w: the grant does not cover %s", ErrInvalid, path)
+	}
+	if len(content) > core.MaxContentSize {
+		return nil, fmt.Errorf("%w: file larger than %d bytes", ErrInvalid, core.MaxContentSize)
+	}
+	rec, err := p2p.BuildFileRecord(contributorPriv, path, core.CIDForContent(content), mimeType)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	recData, err := core.CanonicalMarshalFileRecord(rec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal file record: %v", err)
+	}
+	return &Contribution{Grant: grant, Record: recData, Content: content}, nil
+}
+
+// AcceptContribution checks c against its grant, which the site of
+// sitePriv must have signed, and saves its file as a draft of the site
+// marked with the contributor's key.
+func (p *Publisher) AcceptContribution(sitePriv ed25519.PrivateKey, c *Contribution) (*File, error) {
+	if c == nil {
+		return nil, fmt.Errorf("%w: empty contribution", ErrInvalid)
+	}
+	g, err := p2p.DecodeContributorGrant(c.Grant)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	var rec core.FileRecord
+	if err := core.UnmarshalCBOR(c.Record, &rec); err != nil {
+		return nil, fmt.Errorf("%w: invalid file record: %v", ErrInvalid, err)
+	}
+	siteID := siteIDOf(sitePriv)
+	if err := p2p.VerifyContribution(&rec, g, siteID, time.Now()); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if len(c.Content) > core.MaxContentSize {
+		return nil, fmt.Errorf("%w: file larger than %d bytes", ErrInvalid, core.MaxContentSize)
+	}
+	if core.CIDForContent(c.Content) != rec.ContentCID {
+		return nil, fmt.Errorf("%w: content does not match the signed file record", ErrInvalid)
+	}
+	if err := p.store.PutContent(rec.ContentCID, c.Content); err != nil {
+		return nil, fmt.Errorf("failed to store content: %v", err)
+	}
+	d := &store.Draft{Path: rec.Path, ContentCID: rec.ContentCID, MimeType: rec.MimeType, Contributor: p2p.PubHex(rec.SitePub)}
+	if err := p.putDraft(siteID, d); err != nil {
+		return nil, err
+	}
+	return &File{Path: rec.Path, ContentCID: rec.ContentCID, MimeType: rec.MimeType, Size: len(c.Content)}, nil
+}
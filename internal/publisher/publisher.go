@@ -0,0 +1,701 @@
+// Package publisher signs and stores what a site owner publishes: single-file
+// updates, file records, website manifests and deletions. Every front end
+// goes through it, so sequence numbers, chaining and signatures are built in
+// one place.
+package publisher
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"alxnet/internal/core"
+	"alxnet/internal/p2p"
+	"alxnet/internal/store"
+
+	"go.uber.org/zap"
+)
+
+// HeadLookupTimeout bounds the wait for peers' heads before an update.
+const HeadLookupTimeout = 5 * time.Second
+
+var (
+	// ErrInvalid wraps errors caused by the published data itself.
+	ErrInvalid = errors.New("invalid")
+	// ErrNoNode is returned for operations that must reach the network.
+	ErrNoNode = errors.New("publishing needs a running node")
+	// ErrNoFiles is returned when a manifest would list no files.
+	ErrNoFiles = errors.New("a website needs at least one file")
+	// ErrOnlyFile is returned when removing the last file of a website.
+	ErrOnlyFile = errors.New("cannot delete the only file of a published website")
+	// ErrFileNotFound is returned when removing a file the site does not have.
+	ErrFileNotFound = errors.New("file not found")
+)
+
+// Publisher publishes sites stored in a store. With a nil node, files and
+// manifests are stored locally only, and updates and deletes are refused.
+type Publisher struct {
+	store  *store.Store
+	node   *p2p.Node
+	logger *zap.Logger
+}
+
+// New returns a publisher writing to st and announcing through node.
+func New(st *store.Store, node *p2p.Node, logger *zap.Logger) *Publisher {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &Publisher{store: st, node: node, logger: logger}
+}
+
+// Update describes a published single-file update.
+type Update struct {
+	SiteID     string
+	Seq        uint64
+	RecordCID  string
+	ContentCID string
+}
+
+// File describes a saved file. RecordCID is empty for drafts, which are
+// signed when published.
+type File struct {
+	Path       string
+	ContentCID string
+	RecordCID  string
+	MimeType   string
+	Size       int
+}
+
+// Manifest is a stored website manifest and its CID.
+type Manifest struct {
+	*core.WebsiteManifest
+	CID string
+}
+
+func siteIDOf(sitePriv ed25519.PrivateKey) string {
+	return core.SiteIDFromPub(sitePriv.Public().(ed25519.PublicKey))
+}
+
+// PublishContent publishes content as the next update of the site owning
+// sitePriv and announces it. The update is chained to the newest head held
+// locally or by connected peers, so publishing from a second node, or
+// after a restore, continues the sequence instead of restarting it.
+func (p *Publisher) PublishContent(ctx context.Context, sitePriv ed25519.PrivateKey, content []byte) (*Update, error) {
+	if p.node == nil {
+		return nil, ErrNoNode
+	}
+	if len(content) > core.MaxContentSize {
+		return nil, fmt.Errorf("%w: content larger than %d bytes", ErrInvalid, core.MaxContentSize)
+	}
+	siteID := siteIDOf(sitePriv)
+	base, err := p.node.NextSeqBase(ctx, siteID, HeadLookupTimeout)
+	if err != nil {
+		return nil, err
+	}
+	seq := base.Seq + 1
+
+	env, recCID, err := p.node.BuildUpdate(sitePriv, sitePriv.Public().(ed25519.PublicKey), content, seq, base.HeadCID)
+	if err != nil {
+		return nil, err
+	}
+	var rec core.UpdateRecord
+	if err := core.UnmarshalCBOR(env.Record, &rec); err != nil {
+		return nil, err
+	}
+	if err := p.node.ValidateAndApplyOnto(&rec, content, base); err != nil {
+		return nil, fmt.Errorf("update rejected: %w", err)
+	}
+	p.node.NotePublished(siteID, recCID)
+	p.pin(ctx, siteID)
+	return &Update{SiteID: siteID, Seq: seq, RecordCID: recCID, ContentCID: rec.ContentCID}, nil
+}
+
+// SaveFile stores content as a draft of path: the site's working copy
+// changes, the live site does not. The file is signed and published with
+// the next manifest, so the returned File has no RecordCID.
+func (p *Publisher) SaveFile(sitePriv ed25519.PrivateKey, path string, content []byte, mimeType string) (*File, error) {
+	// The record is signed again when published; building it checks it
+	contentCID, _, err := p.storeFile(sitePriv, path, content, mimeType)
+	if err != nil {
+		return nil, err
+	}
+	d := &store.Draft{Path: path, ContentCID: contentCID, MimeType: mimeType}
+	if err := p.putDraft(siteIDOf(sitePriv), d); err != nil {
+		return nil, err
+	}
+	return &File{Path: path, ContentCID: contentCID, MimeType: mimeType, Size: len(content)}, nil
+}
+
+// putDraft saves d, pruning the content of the draft it replaces.
+func (p *Publisher) putDraft(siteID string, d *store.Draft) error {
+	old, err := p.store.GetDraft(siteID, d.Path)
+	if err != nil {
+		return fmt.Errorf("failed to read draft: %v", err)
+	}
+	if err := p.store.PutDraft(siteID, d); err != nil {
+		if errors.Is(err, store.ErrTooManyDrafts) {
+			return fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+		return fmt.Errorf("failed to store draft: %v", err)
+	}
+	if old != nil && old.ContentCID != "" && old.ContentCID != d.ContentCID {
+		p.prune(old.ContentCID)
+	}
+	return nil
+}
+
+// storeFile builds the file record of content at path and stores the
+// content, returning its CID and the record.
+func (p *Publisher) storeFile(sitePriv ed25519.PrivateKey, path string, content []byte, mimeType string) (string, *core.FileRecord, error) {
+	if err := core.ValidateFilePath(path); err != nil {
+		return "", nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if len(content) > core.MaxContentSize {
+		return "", nil, fmt.Errorf("%w: file larger than %d bytes", ErrInvalid, core.MaxContentSize)
+	}
+	contentCID := core.CIDForContent(content)
+	rec, err := p2p.BuildFileRecord(sitePriv, path, contentCID, mimeType)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if err := p.store.PutContent(contentCID, content); err != nil {
+		return "", nil, fmt.Errorf("failed to store content: %v", err)
+	}
+	return contentCID, rec, nil
+}
+
+// putRecord stores the file record of path in siteID, returning its CID.
+func (p *Publisher) putRecord(siteID, path string, rec *core.FileRecord) (string, error) {
+	recData, err := core.CanonicalMarshalFileRecord(rec)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal file record: %v", err)
+	}
+	recordCID := core.CIDForBytes(recData)
+	if err := p.store.PutFileRecord(siteID, path, recordCID, recData); err != nil {
+		return "", fmt.Errorf("failed to store file record: %v", err)
+	}
+	return recordCID, nil
+}
+
+// AddFile signs a file and publishes the next manifest with it, replacing
+// any draft of the path. Other drafts stay unpublished. Content the file
+// replaces is pruned once nothing references it.
+func (p *Publisher) AddFile(ctx context.Context, sitePriv ed25519.PrivateKey, path string, content []byte, mimeType string) (*File, *Manifest, error) {
+	contentCID, rec, err := p.storeFile(sitePriv, path, content, mimeType)
+	if err != nil {
+		return nil, nil, err
+	}
+	recordCID, err := p.putRecord(siteIDOf(sitePriv), path, rec)
+	if err != nil {
+		return nil, nil, err
+	}
+	var replaced string
+	m, err := p.publishChange(ctx, sitePriv, Settings{}, func(files map[string]string) {
+		replaced = files[path]
+		files[path] = contentCID
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	drafted := p.dropDraft(siteIDOf(sitePriv), path)
+	for _, cid := range []string{replaced, drafted} {
+		if cid != "" && cid != contentCID {
+			p.prune(cid)
+		}
+	}
+	return &File{Path: path, ContentCID: contentCID, RecordCID: recordCID, MimeType: mimeType, Size: len(content)}, m, nil
+}
+
+// RemoveFile removes a file from the site: its draft, its saved record and,
+// when it is published, its manifest entry. The returned manifest is nil if
+// the file was never published.
+func (p *Publisher) RemoveFile(ctx context.Context, sitePriv ed25519.PrivateKey, path string) (*Manifest, error) {
+	siteID := siteIDOf(sitePriv)
+	cur, err := p.CurrentManifest(siteID)
+	if err != nil {
+		return nil, err
+	}
+	var published string
+	if cur != nil {
+		published = cur.Files[path]
+		if published != "" && len(cur.Files) == 1 {
+			return nil, ErrOnlyFile
+		}
+	}
+	drafted, hadDraft, err := p.store.DeleteDraft(siteID, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete draft: %v", err)
+	}
+	saved, err := p.store.DeleteFileRecord(siteID, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete file record: %v", err)
+	}
+	if !hadDraft && saved == "" && published == "" {
+		return nil, ErrFileNotFound
+	}
+
+	var m *Manifest
+	if published != "" {
+		if m, err = p.publishChange(ctx, sitePriv, Settings{}, func(files map[string]string) {
+			delete(files, path)
+		}); err != nil {
+			return nil, err
+		}
+	}
+	for _, cid := range []string{drafted, saved, published} {
+		if cid != "" {
+			p.prune(cid)
+		}
+	}
+	return m, nil
+}
+
+// SaveRemoval saves the removal of path as a draft, so the file leaves the
+// site with the next published manifest. A file that only exists as a
+// draft is discarded instead.
+func (p *Publisher) SaveRemoval(sitePriv ed25519.PrivateKey, path string) error {
+	siteID := siteIDOf(sitePriv)
+	if _, err := p.store.GetFileRecordByPath(siteID, path); err != nil {
+		cur, err := p.CurrentManifest(siteID)
+		if err != nil {
+			return err
+		}
+		if cur == nil || cur.Files[path] == "" {
+			return p.DiscardDraft(sitePriv, path)
+		}
+	}
+	old, err := p.store.GetDraft(siteID, path)
+	if err != nil {
+		return fmt.Errorf("failed to read draft: %v", err)
+	}
+	if err := p.store.PutDraft(siteID, &store.Draft{Path: path, Deleted: true}); err != nil {
+		if errors.Is(err, store.ErrTooManyDrafts) {
+			return fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+		return fmt.Errorf("failed to store draft: %v", err)
+	}
+	if old != nil && old.ContentCID != "" {
+		p.prune(old.ContentCID)
+	}
+	return nil
+}
+
+// DiscardDraft drops the draft of path, so the working copy has the file
+// as published again. It returns ErrFileNotFound if path has no draft.
+func (p *Publisher) DiscardDraft(sitePriv ed25519.PrivateKey, path string) error {
+	cid, existed, err := p.store.DeleteDraft(siteIDOf(sitePriv), path)
+	if err != nil {
+		return fmt.Errorf("failed to delete draft: %v", err)
+	}
+	if !existed {
+		return ErrFileNotFound
+	}
+	if cid != "" {
+		p.prune(cid)
+	}
+	return nil
+}
+
+// dropDraft deletes the draft of path, returning the content it held.
+// Failures leave a stale draft behind, so they are logged.
+func (p *Publisher) dropDraft(siteID, path string) string {
+	cid, _, err := p.store.DeleteDraft(siteID, path)
+	if err != nil {
+		p.logger.Warn("failed to delete draft", zap.String("site_id", siteID), zap.String("path", path), zap.Error(err))
+	}
+	return cid
+}
+
+// Settings are the manifest fields a publish may change besides the files.
+// A nil field keeps the current manifest's value.
+type Settings struct {
+	// NoIndex asks to leave the site out of sitemaps and search indexers.
+	NoIndex *bool
+	// Preload lists the files nodes fetch first; empty and non-nil clears
+	// it. Preloaded files the site loses are dropped from the list.
+	Preload []string
+}
+
+// PublishWebsite publishes the site's working copy, its saved files with
+// every draft applied, as its next manifest. The site keeps its current
+// settings.
+func (p *Publisher) PublishWebsite(ctx context.Context, sitePriv ed25519.PrivateKey) (*Manifest, error) {
+	return p.PublishWebsiteProgress(ctx, sitePriv, Settings{}, nil)
+}
+
+// PublishWebsiteNoIndex is PublishWebsite that also sets whether the site
+// asks to be left out of sitemaps and search indexers.
+func (p *Publisher) PublishWebsiteNoIndex(ctx context.Context, sitePriv ed25519.PrivateKey, noIndex bool) (*Manifest, error) {
+	return p.PublishWebsiteProgress(ctx, sitePriv, Settings{NoIndex: &noIndex}, nil)
+}
+
+// Progress receives how many files of a working copy were verified or
+// signed so far and how many it has.
+type Progress func(done, total int)
+
+// PublishWebsiteProgress is PublishWebsite applying settings, reporting
+// each saved file it verifies and each draft it signs to progress. It
+// gives up when ctx ends before the manifest is signed, leaving the drafts
+// as they were.
+func (p *Publisher) PublishWebsiteProgress(ctx context.Context, sitePriv ed25519.PrivateKey, settings Settings, progress Progress) (*Manifest, error) {
+	siteID := siteIDOf(sitePriv)
+	drafts, err := p.store.Drafts(siteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list drafts: %v", err)
+	}
+	total := len(drafts)
+	files, err := p.workingCopy(ctx, siteID, func(done, records int) {
+		total = records + len(drafts)
+		if progress != nil {
+			progress(done, total)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	records := make(map[string]*core.FileRecord, len(drafts))
+	for i, d := range drafts {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if d.Deleted {
+			delete(files, d.Path)
+		} else {
+			rec, err := p2p.BuildFileRecord(sitePriv, d.Path, d.ContentCID, d.MimeType)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s: %v", ErrInvalid, d.Path, err)
+			}
+			records[d.Path] = rec
+			files[d.Path] = d.ContentCID
+		}
+		if progress != nil {
+			progress(total-len(drafts)+i+1, total)
+		}
+	}
+	if len(files) == 0 {
+		return nil, ErrNoFiles
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	for path, rec := range records {
+		if _, err := p.putRecord(siteID, path, rec); err != nil {
+			return nil, err
+		}
+	}
+	var replaced []string
+	m, err := p.publishChange(ctx, sitePriv, settings, func(cur map[string]string) {
+		for path, cid := range cur {
+			if files[path] != cid {
+				replaced = append(replaced, cid)
+			}
+		}
+		clear(cur)
+		for path, cid := range files {
+			cur[path] = cid
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range drafts {
+		if d.Deleted {
+			saved, err := p.store.DeleteFileRecord(siteID, d.Path)
+			if err != nil {
+				p.logger.Warn("failed to delete file record", zap.String("site_id", siteID), zap.String("path", d.Path), zap.Error(err))
+			}
+			replaced = append(replaced, saved)
+		}
+		p.dropDraft(siteID, d.Path)
+	}
+	for _, cid := range replaced {
+		if cid != "" {
+			p.prune(cid)
+		}
+	}
+	return m, nil
+}
+
+// Delete signs a delete record for a record and/or content of the site
+// owning sitePriv, then applies and broadcasts it.
+func (p *Publisher) Delete(ctx context.Context, sitePriv ed25519.PrivateKey, targetRec, targetCont string) (*core.DeleteRecord, error) {
+	del, err := p2p.BuildDelete(sitePriv, targetRec, targetCont)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if err := p.PublishDelete(ctx, del); err != nil {
+		return nil, err
+	}
+	return del, nil
+}
+
+// PublishDelete applies and broadcasts a delete record signed elsewhere,
+// such as by the wallet CLI.
+func (p *Publisher) PublishDelete(ctx context.Context, del *core.DeleteRecord) error {
+	if p.node == nil {
+		return ErrNoNode
+	}
+	if err := del.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if err := p.node.ApplyDelete(*del); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if err := p.node.BroadcastDelete(ctx, *del); err != nil {
+		return fmt.Errorf("failed to broadcast delete: %w", err)
+	}
+	return nil
+}
+
+// CurrentManifest returns the site's published manifest, or nil if the site
+// has none.
+func (p *Publisher) CurrentManifest(siteID string) (*Manifest, error) {
+	if !p.store.HasWebsiteManifest(siteID) {
+		return nil, nil
+	}
+	data, err := p.store.GetCurrentWebsiteManifest(siteID)
+	if err != nil {
+		return nil, err
+	}
+	var m core.WebsiteManifest
+	if err := core.UnmarshalCBOR(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse website manifest: %v", err)
+	}
+	return &Manifest{WebsiteManifest: &m, CID: core.CIDForBytes(data)}, nil
+}
+
+// WorkingCopy maps every file of a site's working copy, its saved files
+// with every draft applied, to its content CID. Every file record must
+// carry a valid signature by the site key.
+func (p *Publisher) WorkingCopy(siteID string) (map[string]string, error) {
+	files, err := p.workingCopy(context.Background(), siteID, nil)
+	if err != nil {
+		return nil, err
+	}
+	drafts, err := p.store.Drafts(siteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list drafts: %v", err)
+	}
+	for _, d := range drafts {
+		if d.Deleted {
+			delete(files, d.Path)
+		} else {
+			files[d.Path] = d.ContentCID
+		}
+	}
+	return files, nil
+}
+
+// Change statuses, comparing a working copy with the published manifest.
+const (
+	ChangeAdded    = "added"
+	ChangeModified = "modified"
+	ChangeRemoved  = "removed"
+)
+
+// Change is one file whose working copy differs from the published site.
+type Change struct {
+	Path         string
+	Status       string
+	PublishedCID string    // "" for added files
+	DraftCID     string    // "" for removed files
+	MimeType     string    // of drafted files
+	Saved        time.Time // of the draft, zero for older saved files
+	Contributor  string    // hex key of an accepted contribution's signer
+}
+
+// Changes lists by path what publishing the site would change.
+func (p *Publisher) Changes(siteID string) ([]Change, error) {
+	files, err := p.WorkingCopy(siteID)
+	if err != nil {
+		return nil, err
+	}
+	cur, err := p.CurrentManifest(siteID)
+	if err != nil {
+		return nil, err
+	}
+	published := map[string]string{}
+	if cur != nil {
+		published = cur.Files
+	}
+	drafts, err := p.store.Drafts(siteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list drafts: %v", err)
+	}
+	drafted := make(map[string]store.Draft, len(drafts))
+	for _, d := range drafts {
+		drafted[d.Path] = d
+	}
+
+	var changes []Change
+	for path, cid := range files {
+		old, ok := published[path]
+		if ok && old == cid {
+			continue
+		}
+		c := Change{Path: path, Status: ChangeModified, PublishedCID: old, DraftCID: cid}
+		if !ok {
+			c.Status = ChangeAdded
+		}
+		if d, ok := drafted[path]; ok {
+			c.MimeType, c.Saved, c.Contributor = d.MimeType, d.Saved, d.Contributor
+		}
+		changes = append(changes, c)
+	}
+	for path, cid := range published {
+		if _, ok := files[path]; !ok {
+			c := Change{Path: path, Status: ChangeRemoved, PublishedCID: cid}
+			if d, ok := drafted[path]; ok {
+				c.Saved = d.Saved
+			}
+			changes = append(changes, c)
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+func (p *Publisher) workingCopy(ctx context.Context, siteID string, progress Progress) (map[string]string, error) {
+	records, err := p.store.ListWebsiteFiles(siteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list website files: %v", err)
+	}
+	files := make(map[string]string, len(records))
+	for path, recordCID := range records {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		data, err := p.store.GetFileRecord(recordCID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get file record for %s: %v", path, err)
+		}
+		var rec core.FileRecord
+		if err := core.UnmarshalCBOR(data, &rec); err != nil {
+			return nil, fmt.Errorf("failed to parse file record for %s: %v", path, err)
+		}
+		if err := p2p.VerifySiteFileRecord(&rec, siteID, path); err != nil {
+			return nil, fmt.Errorf("%w: %s: %v (save the file again to sign it)", ErrInvalid, path, err)
+		}
+		files[path] = rec.ContentCID
+		if progress != nil {
+			progress(len(files), len(records))
+		}
+	}
+	return files, nil
+}
+
+// publishChange signs and stores the next manifest of a site: the current
+// file map with change applied, chained to the current manifest, with
+// settings applied over the current manifest's.
+func (p *Publisher) publishChange(ctx context.Context, sitePriv ed25519.PrivateKey, settings Settings, change func(files map[string]string)) (*Manifest, error) {
+	siteID := siteIDOf(sitePriv)
+	cur, err := p.CurrentManifest(siteID)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]string)
+	seq, prevCID, mainFile, hidden := uint64(1), "", "", false
+	var preload []string
+	if cur != nil {
+		for path, cid := range cur.Files {
+			files[path] = cid
+		}
+		seq, prevCID, mainFile, hidden = cur.Seq+1, cur.CID, cur.MainFile, cur.NoIndex
+		preload = cur.Preload
+	}
+	if settings.NoIndex != nil {
+		hidden = *settings.NoIndex
+	}
+	change(files)
+	if len(files) == 0 {
+		return nil, ErrNoFiles
+	}
+	if settings.Preload != nil {
+		if err := checkPreload(settings.Preload, files); err != nil {
+			return nil, err
+		}
+		preload = settings.Preload
+	} else {
+		preload = slices.DeleteFunc(slices.Clone(preload), func(path string) bool { return files[path] == "" })
+	}
+
+	m, err := p2p.BuildWebsiteManifest(sitePriv, seq, prevCID, pickMainFile(mainFile, files), files, hidden, preload)
+	if err != nil {
+		return nil, err
+	}
+	data, err := core.CanonicalMarshalWebsiteManifest(m)
+	if err != nil {
+		return nil, err
+	}
+	cid := core.CIDForBytes(data)
+	if err := p.store.PutWebsiteManifest(siteID, cid, data); err != nil {
+		return nil, err
+	}
+	p.pin(ctx, siteID)
+	return &Manifest{WebsiteManifest: m, CID: cid}, nil
+}
+
+// checkPreload checks that preload lists files of the site, each once.
+func checkPreload(preload []string, files map[string]string) error {
+	if len(preload) > core.MaxPreloadFiles {
+		return fmt.Errorf("%w: at most %d files can be preloaded", ErrInvalid, core.MaxPreloadFiles)
+	}
+	seen := make(map[string]bool, len(preload))
+	for _, path := range preload {
+		if files[path] == "" {
+			return fmt.Errorf("%w: preload file %s is not part of the site", ErrInvalid, path)
+		}
+		if seen[path] {
+			return fmt.Errorf("%w: preload file %s is listed twice", ErrInvalid, path)
+		}
+		seen[path] = true
+	}
+	return nil
+}
+
+// pin keeps our own sites seeded without manual pinning.
+func (p *Publisher) pin(ctx context.Context, siteID string) {
+	if p.node == nil {
+		return
+	}
+	if err := p.node.PinSite(ctx, siteID); err != nil {
+		p.logger.Warn("failed to pin published site", zap.String("site_id", siteID), zap.Error(err))
+	}
+}
+
+// prune deletes content no file references any more. Failures only leave
+// an orphan blob behind, so they are logged.
+func (p *Publisher) prune(cid string) {
+	if _, err := p.store.PruneContent(cid); err != nil {
+		p.logger.Warn("failed to prune content", zap.String("cid", cid), zap.Error(err))
+	}
+}
+
+// pickMainFile keeps the current main file if it is still part of the
+// site, and otherwise prefers index.html, then the first HTML file, then
+// the first file.
+func pickMainFile(current string, files map[string]string) string {
+	if _, ok := files[current]; ok && current != "" {
+		return current
+	}
+	if _, ok := files["index.html"]; ok {
+		return "index.html"
+	}
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if core.IsHTMLFile(path) {
+			return path
+		}
+	}
+	return paths[0]
+}
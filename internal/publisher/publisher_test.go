@@ -0,0 +1,407 @@
+package publisher
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"alxnet/internal/core"
+	"alxnet/internal/p2p"
+	"alxnet/internal/store"
+
+	peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+func openTestStore(t *testing.T) *store.Store {
+	t.Helper()
+	s, err := store.NewMemory()
+	if err != nil {
+		t.Fatalf("store.NewMemory: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestPickMainFile(t *testing.T) {
+	tests := []struct {
+		current string
+		files   []string
+		want    string
+	}{
+		{"home.html", []string{"home.html", "index.html"}, "home.html"},
+		{"gone.html", []string{"about.html", "index.html"}, "index.html"},
+		{"", []string{"z.css", "b.html", "a.html"}, "a.html"},
+		{"", []string{"b.css", "a.css"}, "a.css"},
+	}
+	for _, tt := range tests {
+		files := make(map[string]string)
+		for _, p := range tt.files {
+			files[p] = "c0ffee"
+		}
+		if got := pickMainFile(tt.current, files); got != tt.want {
+			t.Errorf("pickMainFile(%q, %v) = %q, want %q", tt.current, tt.files, got, tt.want)
+		}
+	}
+}
+
+func TestWebsiteLifecycle(t *testing.T) {
+	ctx := context.Background()
+	p := New(openTestStore(t), nil, nil)
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+
+	if _, err := p.PublishWebsite(ctx, priv); !errors.Is(err, ErrNoFiles) {
+		t.Fatalf("PublishWebsite() without files: err = %v, want ErrNoFiles", err)
+	}
+	if _, err := p.SaveFile(priv, "../escape.html", []byte("x"), "text/html"); !errors.Is(err, ErrInvalid) {
+		t.Errorf("SaveFile() with a bad path: err = %v, want ErrInvalid", err)
+	}
+	if _, err := p.SaveFile(priv, "index.html", []byte("<h1>hi</h1>"), "text/html"); err != nil {
+		t.Fatalf("SaveFile: %v", err)
+	}
+	first, err := p.PublishWebsite(ctx, priv)
+	if err != nil {
+		t.Fatalf("PublishWebsite: %v", err)
+	}
+	_, second, err := p.AddFile(ctx, priv, "site.css", []byte("h1{}"), "text/css")
+	if err != nil {
+		t.Fatalf("AddFile: %v", err)
+	}
+	if first.Seq != 1 || second.Seq != 2 || second.PrevCID != first.CID {
+		t.Errorf("chain: seq %d -> %d, prev %q, want 1 -> 2 and %q", first.Seq, second.Seq, second.PrevCID, first.CID)
+	}
+	if len(second.Files) != 2 || second.MainFile != "index.html" {
+		t.Errorf("second manifest files %v, main %q", second.Files, second.MainFile)
+	}
+	if err := p2p.VerifyWebsiteManifest(second.WebsiteManifest); err != nil {
+		t.Errorf("manifest does not verify: %v", err)
+	}
+
+	third, err := p.RemoveFile(ctx, priv, "site.css")
+	if err != nil {
+		t.Fatalf("RemoveFile: %v", err)
+	}
+	if third.Seq != 3 || len(third.Files) != 1 {
+		t.Errorf("after removal: seq %d, files %v", third.Seq, third.Files)
+	}
+	if _, err := p.RemoveFile(ctx, priv, "site.css"); !errors.Is(err, ErrFileNotFound) {
+		t.Errorf("RemoveFile() twice: err = %v, want ErrFileNotFound", err)
+	}
+	if _, err := p.RemoveFile(ctx, priv, "index.html"); !errors.Is(err, ErrOnlyFile) {
+		t.Errorf("RemoveFile() of the last file: err = %v, want ErrOnlyFile", err)
+	}
+
+	// The indexing opt-out sticks until it is published again
+	hidden, err := p.PublishWebsiteNoIndex(ctx, priv, true)
+	if err != nil {
+		t.Fatalf("PublishWebsiteNoIndex: %v", err)
+	}
+	_, next, err := p.AddFile(ctx, priv, "about.html", []byte("<p>about</p>"), "text/html")
+	if err != nil {
+		t.Fatalf("AddFile: %v", err)
+	}
+	if !hidden.NoIndex || !next.NoIndex {
+		t.Errorf("NoIndex = %v then %v, want true twice", hidden.NoIndex, next.NoIndex)
+	}
+	if err := p2p.VerifyWebsiteManifest(next.WebsiteManifest); err != nil {
+		t.Errorf("opted-out manifest does not verify: %v", err)
+	}
+	if shown, err := p.PublishWebsiteNoIndex(ctx, priv, false); err != nil || shown.NoIndex {
+		t.Errorf("PublishWebsiteNoIndex(false) = %v, %v", shown, err)
+	}
+
+	// The preload list sticks too, less the files the site loses
+	if _, err := p.PublishWebsiteProgress(ctx, priv, Settings{Preload: []string{"missing.css"}}, nil); !errors.Is(err, ErrInvalid) {
+		t.Errorf("preloading a missing file: err = %v, want ErrInvalid", err)
+	}
+	preloaded, err := p.PublishWebsiteProgress(ctx, priv, Settings{Preload: []string{"about.html"}}, nil)
+	if err != nil || !slices.Equal(preloaded.Preload, []string{"about.html"}) {
+		t.Fatalf("PublishWebsiteProgress() with preload = %v, %v", preloaded, err)
+	}
+	if kept, err := p.PublishWebsite(ctx, priv); err != nil || !slices.Equal(kept.Preload, []string{"about.html"}) {
+		t.Errorf("PublishWebsite() after preload = %v, %v", kept, err)
+	}
+	if dropped, err := p.RemoveFile(ctx, priv, "about.html"); err != nil || len(dropped.Preload) != 0 {
+		t.Errorf("RemoveFile() of a preloaded file = %v, %v", dropped, err)
+	}
+}
+
+func TestPublishWebsiteProgress(t *testing.T) {
+	p := New(openTestStore(t), nil, nil)
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	for i := 0; i < 3; i++ {
+		if _, err := p.SaveFile(priv, fmt.Sprintf("page%d.html", i), []byte("x"), "text/html"); err != nil {
+			t.Fatalf("SaveFile: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.PublishWebsiteProgress(ctx, priv, Settings{}, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("PublishWebsiteProgress() canceled: err = %v, want context.Canceled", err)
+	}
+	if m, _ := p.CurrentManifest(siteIDOf(priv)); m != nil {
+		t.Errorf("canceled publish stored manifest %s", m.CID)
+	}
+
+	var reports []string
+	m, err := p.PublishWebsiteProgress(context.Background(), priv, Settings{}, func(done, total int) {
+		reports = append(reports, fmt.Sprintf("%d/%d", done, total))
+	})
+	if err != nil {
+		t.Fatalf("PublishWebsiteProgress: %v", err)
+	}
+	if got := fmt.Sprint(reports); got != "[1/3 2/3 3/3]" || len(m.Files) != 3 {
+		t.Errorf("progress = %s with %d files, want [1/3 2/3 3/3] with 3", got, len(m.Files))
+	}
+}
+
+func TestDrafts(t *testing.T) {
+	ctx := context.Background()
+	p := New(openTestStore(t), nil, nil)
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	siteID := core.SiteIDFromPub(pub)
+	for _, path := range []string{"index.html", "about.html"} {
+		if _, _, err := p.AddFile(ctx, priv, path, []byte("<h1>"+path+"</h1>"), "text/html"); err != nil {
+			t.Fatalf("AddFile(%s): %v", path, err)
+		}
+	}
+	live, _ := p.CurrentManifest(siteID)
+
+	if _, err := p.SaveFile(priv, "index.html", []byte("<h1>edited</h1>"), "text/html"); err != nil {
+		t.Fatalf("SaveFile: %v", err)
+	}
+	if _, err := p.SaveFile(priv, "new.html", []byte("<h1>new</h1>"), "text/html"); err != nil {
+		t.Fatalf("SaveFile: %v", err)
+	}
+	if err := p.SaveRemoval(priv, "about.html"); err != nil {
+		t.Fatalf("SaveRemoval: %v", err)
+	}
+	if err := p.SaveRemoval(priv, "missing.html"); !errors.Is(err, ErrFileNotFound) {
+		t.Errorf("SaveRemoval() of a missing file: err = %v, want ErrFileNotFound", err)
+	}
+	if m, _ := p.CurrentManifest(siteID); m.CID != live.CID {
+		t.Fatal("saving drafts changed the live manifest")
+	}
+
+	changes, err := p.Changes(siteID)
+	if err != nil {
+		t.Fatalf("Changes: %v", err)
+	}
+	var got []string
+	for _, c := range changes {
+		got = append(got, c.Path+" "+c.Status)
+	}
+	if fmt.Sprint(got) != "[about.html removed index.html modified new.html added]" {
+		t.Errorf("Changes() = %v", got)
+	}
+
+	// Discarding a draft restores the published file
+	if err := p.DiscardDraft(priv, "new.html"); err != nil {
+		t.Fatalf("DiscardDraft: %v", err)
+	}
+	if err := p.DiscardDraft(priv, "new.html"); !errors.Is(err, ErrFileNotFound) {
+		t.Errorf("DiscardDraft() twice: err = %v, want ErrFileNotFound", err)
+	}
+
+	m, err := p.PublishWebsite(ctx, priv)
+	if err != nil {
+		t.Fatalf("PublishWebsite: %v", err)
+	}
+	if m.Seq != live.Seq+1 || len(m.Files) != 1 || m.Files["index.html"] != core.CIDForContent([]byte("<h1>edited</h1>")) {
+		t.Errorf("published manifest = seq %d %v", m.Seq, m.Files)
+	}
+	if changes, _ := p.Changes(siteID); len(changes) != 0 {
+		t.Errorf("Changes() after publishing = %+v", changes)
+	}
+	if files, err := p.WorkingCopy(siteID); err != nil || len(files) != 1 {
+		t.Errorf("WorkingCopy() after publishing = %v, %v", files, err)
+	}
+}
+
+func TestWorkingCopyRejectsForeignRecords(t *testing.T) {
+	s := openTestStore(t)
+	p := New(s, nil, nil)
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	_, otherPriv, _ := ed25519.GenerateKey(rand.Reader)
+	siteID := core.SiteIDFromPub(pub)
+
+	if _, err := p.SaveFile(priv, "index.html", []byte("<h1>hi</h1>"), "text/html"); err != nil {
+		t.Fatalf("SaveFile: %v", err)
+	}
+	if files, err := p.WorkingCopy(siteID); err != nil || len(files) != 1 {
+		t.Fatalf("WorkingCopy() = %v, %v", files, err)
+	}
+
+	rec, err := p2p.BuildFileRecord(otherPriv, "about.html", core.CIDForContent([]byte("x")), "text/html")
+	if err != nil {
+		t.Fatalf("BuildFileRecord: %v", err)
+	}
+	data, _ := core.CanonicalMarshalFileRecord(rec)
+	if err := s.PutFileRecord(siteID, "about.html", core.CIDForBytes(data), data); err != nil {
+		t.Fatalf("PutFileRecord: %v", err)
+	}
+	if _, err := p.WorkingCopy(siteID); !errors.Is(err, ErrInvalid) {
+		t.Errorf("WorkingCopy() with a foreign record: err = %v, want ErrInvalid", err)
+	}
+	if _, err := p.PublishWebsite(context.Background(), priv); err == nil {
+		t.Error("published a working copy with a foreign record")
+	}
+}
+
+func TestAcceptContribution(t *testing.T) {
+	ctx := context.Background()
+	p := New(openTestStore(t), nil, nil)
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	contribPub, contribPriv, _ := ed25519.GenerateKey(rand.Reader)
+	siteID := core.SiteIDFromPub(pub)
+	if _, _, err := p.AddFile(ctx, priv, "index.html", []byte("<h1>home</h1>"), "text/html"); err != nil {
+		t.Fatalf("AddFile: %v", err)
+	}
+	grant, err := p2p.BuildContributorGrant(priv, contribPub, []string{"blog/"}, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("BuildContributorGrant: %v", err)
+	}
+	proof := p2p.EncodeContributorGrant(grant)
+
+	if _, err := Contribute(contribPriv, proof, "index.html", []byte("<h1>mine</h1>"), "text/html"); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Contribute() outside the grant: err = %v, want ErrInvalid", err)
+	}
+	c, err := Contribute(contribPriv, proof, "blog/post.md", []byte("# Post"), "text/markdown")
+	if err != nil {
+		t.Fatalf("Contribute: %v", err)
+	}
+
+	tampered := *c
+	tampered.Content = []byte("# Other post")
+	if _, err := p.AcceptContribution(priv, &tampered); !errors.Is(err, ErrInvalid) {
+		t.Errorf("AcceptContribution() with other content: err = %v, want ErrInvalid", err)
+	}
+	_, otherPriv, _ := ed25519.GenerateKey(rand.Reader)
+	if _, err := p.AcceptContribution(otherPriv, c); !errors.Is(err, ErrInvalid) {
+		t.Errorf("AcceptContribution() by another site: err = %v, want ErrInvalid", err)
+	}
+	if _, err := p.AcceptContribution(priv, c); err != nil {
+		t.Fatalf("AcceptContribution: %v", err)
+	}
+	changes, _ := p.Changes(siteID)
+	if len(changes) != 1 || changes[0].Path != "blog/post.md" || changes[0].Contributor != p2p.PubHex(contribPub) {
+		t.Fatalf("Changes() = %+v", changes)
+	}
+
+	// Publishing signs the contributed file with the site key
+	m, err := p.PublishWebsite(ctx, priv)
+	if err != nil {
+		t.Fatalf("PublishWebsite: %v", err)
+	}
+	if m.Files["blog/post.md"] != core.CIDForContent([]byte("# Post")) {
+		t.Errorf("published files = %v", m.Files)
+	}
+	if _, err := p.WorkingCopy(siteID); err != nil {
+		t.Errorf("WorkingCopy() after publishing a contribution: %v", err)
+	}
+}
+
+func newTestNode(t *testing.T, ctx context.Context) *p2p.Node {
+	t.Helper()
+	n, err := p2p.New(ctx, openTestStore(t), "/ip4/127.0.0.1/tcp/0", nil, nil)
+	if err != nil {
+		t.Fatalf("p2p.New: %v", err)
+	}
+	t.Cleanup(func() { n.Host.Close() })
+	return n
+}
+
+func TestPublishContentAndDelete(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+
+	if _, err := New(openTestStore(t), nil, nil).PublishContent(ctx, priv, []byte("hi")); !errors.Is(err, ErrNoNode) {
+		t.Fatalf("PublishContent() without a node: err = %v, want ErrNoNode", err)
+	}
+
+	n := newTestNode(t, ctx)
+	p := New(n.Store, n, nil)
+	// Past 9 so that the decimal head keys stop sorting numerically
+	var prev *Update
+	for i := 1; i <= 12; i++ {
+		u, err := p.PublishContent(ctx, priv, []byte(fmt.Sprintf("<h1>v%d</h1>", i)))
+		if err != nil {
+			t.Fatalf("PublishContent #%d: %v", i, err)
+		}
+		if u.Seq != uint64(i) {
+			t.Fatalf("PublishContent #%d: seq %d", i, u.Seq)
+		}
+		prev = u
+	}
+	if seq, headCID, _ := n.Store.GetHead(prev.SiteID); seq != 12 || headCID != prev.RecordCID {
+		t.Errorf("head = %d, %s, want 12, %s", seq, headCID, prev.RecordCID)
+	}
+
+	if _, err := p.Delete(ctx, priv, prev.RecordCID, prev.ContentCID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if has, _ := n.Store.HasContent(prev.ContentCID); has {
+		t.Error("deleted content is still stored")
+	}
+	forged, err := p2p.BuildDelete(priv, prev.RecordCID, "")
+	if err != nil {
+		t.Fatalf("BuildDelete: %v", err)
+	}
+	forged.TargetCont = prev.ContentCID
+	if err := p.PublishDelete(ctx, forged); !errors.Is(err, ErrInvalid) {
+		t.Errorf("PublishDelete() with a target not covered by the signature: err = %v, want ErrInvalid", err)
+	}
+	if seq, _, _ := n.Store.GetHead(prev.SiteID); seq != 11 {
+		t.Errorf("head after deleting seq 12 = %d, want 11", seq)
+	}
+	if u, err := p.PublishContent(ctx, priv, []byte("<h1>again</h1>")); err != nil || u.Seq != 12 {
+		t.Errorf("PublishContent() after delete = %+v, %v, want seq 12", u, err)
+	}
+}
+
+func TestPublishContentContinuesPeerHead(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+
+	first := newTestNode(t, ctx)
+	for i := 1; i <= 3; i++ {
+		if _, err := New(first.Store, first, nil).PublishContent(ctx, priv, []byte(fmt.Sprintf("v%d", i))); err != nil {
+			t.Fatalf("PublishContent on the first node: %v", err)
+		}
+	}
+
+	_, firstHead, _ := first.Store.GetHead(core.SiteIDFromPub(priv.Public().(ed25519.PublicKey)))
+
+	// A second node that missed those updates, e.g. after a restore
+	second := newTestNode(t, ctx)
+	if err := second.Host.Connect(ctx, peer.AddrInfo{ID: first.Host.ID(), Addrs: first.Host.Addrs()}); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	u, err := New(second.Store, second, nil).PublishContent(ctx, priv, []byte("v4"))
+	if err != nil {
+		t.Fatalf("PublishContent on the second node: %v", err)
+	}
+	if u.Seq != 4 {
+		t.Errorf("seq = %d, want 4", u.Seq)
+	}
+	var rec core.UpdateRecord
+	data, _ := second.Store.GetRecord(u.RecordCID)
+	if err := core.UnmarshalCBOR(data, &rec); err != nil {
+		t.Fatalf("stored record: %v", err)
+	}
+	if rec.PrevCID != firstHead {
+		t.Errorf("prev = %s, want the first node's head %s", rec.PrevCID, firstHead)
+	}
+	// The first node accepts it as the next update (if gossip has not
+	// delivered it already)
+	if err := first.ValidateAndApply(&rec, []byte("v4")); err != nil {
+		t.Errorf("first node rejected the update: %v", err)
+	}
+}
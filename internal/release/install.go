@@ -0,0 +1,143 @@
+package release
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// PreviousSuffix names the binary an install replaced, kept next to the
+// executable for Rollback.
+const PreviousSuffix = ".old"
+
+// Download fetches b into a new file in dir, the executable's directory
+// so that Install can rename it into place, and checks its size and
+// SHA-256. It returns the file's path; the caller removes it if it is not
+// installed.
+func Download(ctx context.Context, client *http.Client, b Binary, dir string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.URL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("download %s: %s", b.URL, resp.Status)
+	}
+
+	f, err := os.CreateTemp(dir, ".alxnet-update-*")
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, h), io.LimitReader(resp.Body, b.Size+1))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	switch {
+	case err != nil:
+	case n != b.Size:
+		err = fmt.Errorf("download %s: got %d bytes, want %d", b.URL, n, b.Size)
+	case hex.EncodeToString(h.Sum(nil)) != b.SHA256:
+		err = fmt.Errorf("download %s: SHA-256 does not match the release manifest", b.URL)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// Install replaces exe with the binary at path, keeping exe's permissions
+// and the replaced binary as exe+PreviousSuffix. The replaced binary is
+// linked, or copied, there first and the new one renamed over exe, so exe
+// is always either the old or the new binary. If check, run on the
+// installed binary, fails, the old binary is put back.
+func Install(exe, path string, check func(exe string) error) error {
+	info, err := os.Stat(exe)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(path, info.Mode().Perm()); err != nil {
+		return err
+	}
+	previous := exe + PreviousSuffix
+	if err := keepCopy(exe, previous); err != nil {
+		return err
+	}
+	if err := os.Rename(path, exe); err != nil {
+		return err
+	}
+	if check == nil {
+		return nil
+	}
+	if err := check(exe); err != nil {
+		if rerr := os.Rename(previous, exe); rerr != nil {
+			return fmt.Errorf("new binary failed its check (%v) and could not be rolled back: %v", err, rerr)
+		}
+		return fmt.Errorf("new binary failed its check, rolled back: %w", err)
+	}
+	return nil
+}
+
+// Rollback swaps exe with the binary the last Install replaced, so that a
+// second Rollback undoes the first. Like Install it renames over exe.
+func Rollback(exe string) error {
+	previous := exe + PreviousSuffix
+	if _, err := os.Stat(previous); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("no previous binary at %s", previous)
+		}
+		return err
+	}
+	swap := filepath.Join(filepath.Dir(exe), "."+filepath.Base(exe)+".rollback")
+	if err := keepCopy(exe, swap); err != nil {
+		return err
+	}
+	if err := os.Rename(previous, exe); err != nil {
+		os.Remove(swap)
+		return err
+	}
+	return os.Rename(swap, previous)
+}
+
+// keepCopy makes dst a hard link to src, or a copy where the file system
+// cannot link, replacing any file at dst.
+func keepCopy(src, dst string) error {
+	if err := os.Remove(dst); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(dst)
+	}
+	return err
+}
@@ -0,0 +1,208 @@
+// Package release checks signed release manifests and installs the
+// binaries they list.
+//
+// A release channel is a URL serving a signed manifest: the JSON manifest
+// and an ed25519 signature over it, made with the release publisher's key.
+// Builds carry that key, so a node only installs binaries the publisher
+// listed, whatever server or mirror the manifest and binaries come from.
+package release
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PublisherKey is the hex ed25519 public key release manifests are signed
+// with, ChannelURL the default channel's URL and Channel its name, which
+// its manifests must carry. Release builds set them with -ldflags
+// "-X alxnet/internal/release.PublisherKey=...". A build without a key
+// cannot update itself.
+var (
+	PublisherKey = ""
+	ChannelURL   = ""
+	Channel      = "stable"
+)
+
+// Limits on what a channel serves
+const (
+	MaxManifestSize = 1 << 20   // bytes of a signed manifest
+	MaxBinarySize   = 512 << 20 // bytes of a binary
+)
+
+// signContext separates release signatures from other uses of the key.
+const signContext = "alxnet-release-v1\n"
+
+// Manifest describes one release.
+type Manifest struct {
+	Version  string    `json:"version"` // such as "1.2.0"
+	Channel  string    `json:"channel"` // such as "stable" or "beta"
+	Released time.Time `json:"released"`
+	Notes    string    `json:"notes,omitempty"`
+	Binaries []Binary  `json:"binaries"`
+}
+
+// Binary is the build of a release for one platform.
+type Binary struct {
+	OS     string `json:"os"`   // GOOS
+	Arch   string `json:"arch"` // GOARCH
+	URL    string `json:"url"`
+	SHA256 string `json:"sha256"` // hex
+	Size   int64  `json:"size"`
+}
+
+// Signed is a manifest as a channel serves it. The signature covers the
+// manifest's compact JSON, so reformatting the file does not break it.
+type Signed struct {
+	Manifest  json.RawMessage `json:"manifest"`
+	Signature string          `json:"signature"` // hex
+}
+
+// Sign signs m with the publisher's key and returns the signed manifest.
+func Sign(m *Manifest, priv ed25519.PrivateKey) ([]byte, error) {
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	sig := ed25519.Sign(priv, append([]byte(signContext), body...))
+	return json.MarshalIndent(Signed{Manifest: body, Signature: hex.EncodeToString(sig)}, "", "  ")
+}
+
+// Open verifies a signed manifest of channel against pub and returns the
+// manifest. A manifest signed for another channel is refused, so that a
+// mirror cannot serve one channel's release on another.
+func Open(data []byte, pub ed25519.PublicKey, channel string) (*Manifest, error) {
+	if len(pub) != ed25519.PublicKeySize {
+		return nil, errors.New("no release publisher key in this build")
+	}
+	if len(data) > MaxManifestSize {
+		return nil, fmt.Errorf("release manifest too large: %d bytes", len(data))
+	}
+	var s Signed
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("invalid release manifest: %v", err)
+	}
+	sig, err := hex.DecodeString(s.Signature)
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return nil, errors.New("invalid release manifest signature")
+	}
+	var body bytes.Buffer
+	if err := json.Compact(&body, s.Manifest); err != nil {
+		return nil, fmt.Errorf("invalid release manifest: %v", err)
+	}
+	if !ed25519.Verify(pub, append([]byte(signContext), body.Bytes()...), sig) {
+		return nil, errors.New("release manifest not signed by the release publisher")
+	}
+	var m Manifest
+	if err := json.Unmarshal(s.Manifest, &m); err != nil {
+		return nil, fmt.Errorf("invalid release manifest: %v", err)
+	}
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	if m.Channel != channel {
+		return nil, fmt.Errorf("release manifest is for channel %q, not %q", m.Channel, channel)
+	}
+	return &m, nil
+}
+
+// Validate checks that m names a version and well-formed binaries.
+func (m *Manifest) Validate() error {
+	if _, err := parseVersion(m.Version); err != nil {
+		return err
+	}
+	if m.Channel == "" {
+		return errors.New("release names no channel")
+	}
+	if len(m.Binaries) == 0 {
+		return errors.New("release lists no binaries")
+	}
+	for _, b := range m.Binaries {
+		if b.OS == "" || b.Arch == "" {
+			return errors.New("release binary without os or arch")
+		}
+		if !strings.HasPrefix(b.URL, "https://") && !strings.HasPrefix(b.URL, "http://") {
+			return fmt.Errorf("release binary for %s/%s has no http(s) URL", b.OS, b.Arch)
+		}
+		if h, err := hex.DecodeString(b.SHA256); err != nil || len(h) != 32 {
+			return fmt.Errorf("release binary for %s/%s has no SHA-256", b.OS, b.Arch)
+		}
+		if b.Size <= 0 || b.Size > MaxBinarySize {
+			return fmt.Errorf("release binary for %s/%s has size %d", b.OS, b.Arch, b.Size)
+		}
+	}
+	return nil
+}
+
+// Binary returns the build of m for goos and goarch.
+func (m *Manifest) Binary(goos, goarch string) (Binary, bool) {
+	for _, b := range m.Binaries {
+		if b.OS == goos && b.Arch == goarch {
+			return b, true
+		}
+	}
+	return Binary{}, false
+}
+
+// Publisher returns the public key release manifests must be signed with.
+func Publisher() (ed25519.PublicKey, error) {
+	if PublisherKey == "" {
+		return nil, errors.New("this build has no release publisher key; updates are disabled")
+	}
+	key, err := hex.DecodeString(PublisherKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, errors.New("this build's release publisher key is malformed")
+	}
+	return key, nil
+}
+
+// Newer reports whether version a is newer than version b. Both are
+// dotted numbers, optionally prefixed with "v".
+func Newer(a, b string) (bool, error) {
+	va, err := parseVersion(a)
+	if err != nil {
+		return false, err
+	}
+	vb, err := parseVersion(b)
+	if err != nil {
+		return false, err
+	}
+	for i := 0; i < max(len(va), len(vb)); i++ {
+		var x, y int
+		if i < len(va) {
+			x = va[i]
+		}
+		if i < len(vb) {
+			y = vb[i]
+		}
+		if x != y {
+			return x > y, nil
+		}
+	}
+	return false, nil
+}
+
+func parseVersion(v string) ([]int, error) {
+	parts := strings.Split(strings.TrimPrefix(v, "v"), ".")
+	if v == "" || len(parts) > 4 {
+		return nil, fmt.Errorf("invalid release version %q", v)
+	}
+	out := make([]int, len(parts))
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid release version %q", v)
+		}
+		out[i] = n
+	}
+	return out, nil
+}
@@ -0,0 +1,144 @@
+package release
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testManifest(url string, content []byte) *Manifest {
+	sum := sha256.Sum256(content)
+	return &Manifest{
+		Version:  "1.2.0",
+		Channel:  "stable",
+		Released: time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC),
+		Binaries: []Binary{{OS: "linux", Arch: "amd64", URL: url, SHA256: hex.EncodeToString(sum[:]), Size: int64(len(content))}},
+	}
+}
+
+func TestSignOpen(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPub, _, _ := ed25519.GenerateKey(rand.Reader)
+	signed, err := Sign(testManifest("https://example.com/alxnet", []byte("bin")), priv)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	m, err := Open(signed, pub, "stable")
+	if err != nil || m.Version != "1.2.0" {
+		t.Fatalf("Open = %+v, %v", m, err)
+	}
+	if b, ok := m.Binary("linux", "amd64"); !ok || b.Size != 3 {
+		t.Errorf("Binary(linux, amd64) = %+v, %v", b, ok)
+	}
+	if _, ok := m.Binary("darwin", "arm64"); ok {
+		t.Error("Binary(darwin, arm64) found")
+	}
+
+	if _, err := Open(signed, otherPub, "stable"); err == nil {
+		t.Error("Open with another key succeeded")
+	}
+	tampered := strings.Replace(string(signed), `1.2.0`, `9.9.9`, 1)
+	if _, err := Open([]byte(tampered), pub, "stable"); err == nil {
+		t.Error("Open of a tampered manifest succeeded")
+	}
+	if _, err := Open(signed, nil, "stable"); err == nil {
+		t.Error("Open without a key succeeded")
+	}
+	if _, err := Open(signed, pub, "beta"); err == nil || !strings.Contains(err.Error(), "channel") {
+		t.Errorf("Open of a stable release on the beta channel: %v", err)
+	}
+	if _, err := Sign(&Manifest{Version: "1.0"}, priv); err == nil {
+		t.Error("Sign of a manifest without binaries succeeded")
+	}
+}
+
+func TestNewer(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"1.0.1", "1.0.0", true},
+		{"1.10.0", "1.9.0", true},
+		{"v2", "1.9.9", true},
+		{"1.0", "1.0.0", false},
+		{"1.0.0", "1.0.1", false},
+	}
+	for _, tt := range tests {
+		if got, err := Newer(tt.a, tt.b); err != nil || got != tt.want {
+			t.Errorf("Newer(%s, %s) = %v, %v", tt.a, tt.b, got, err)
+		}
+	}
+	if _, err := Newer("1.0-beta", "1.0"); err == nil {
+		t.Error("Newer accepted 1.0-beta")
+	}
+}
+
+func TestDownloadInstallRollback(t *testing.T) {
+	content := []byte("new binary")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(content)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	exe := filepath.Join(dir, "alxnet")
+	if err := os.WriteFile(exe, []byte("old binary"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	read := func(p string) string {
+		b, _ := os.ReadFile(p)
+		return string(b)
+	}
+
+	b := testManifest(srv.URL, content).Binaries[0]
+	// A failed rename leaves the old binary in place
+	if err := Install(exe, t.TempDir(), nil); err == nil || read(exe) != "old binary" {
+		t.Fatalf("Install over a directory = %v, exe %q", err, read(exe))
+	}
+	bad := b
+	bad.SHA256 = strings.Repeat("0", 64)
+	if _, err := Download(context.Background(), srv.Client(), bad, dir); err == nil || !strings.Contains(err.Error(), "SHA-256") {
+		t.Errorf("Download with the wrong hash: %v", err)
+	}
+
+	// A binary failing its check is rolled back
+	path, err := Download(context.Background(), srv.Client(), b, dir)
+	if err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+	if err := Install(exe, path, func(string) error { return errors.New("crashed") }); err == nil || read(exe) != "old binary" {
+		t.Fatalf("Install of a failing binary = %v, exe %q", err, read(exe))
+	}
+
+	path, err = Download(context.Background(), srv.Client(), b, dir)
+	if err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+	if err := Install(exe, path, func(string) error { return nil }); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	if info, _ := os.Stat(exe); read(exe) != "new binary" || read(exe+PreviousSuffix) != "old binary" || info.Mode().Perm() != 0o755 {
+		t.Errorf("after Install: exe %q, previous %q", read(exe), read(exe+PreviousSuffix))
+	}
+
+	if err := Rollback(exe); err != nil || read(exe) != "old binary" || read(exe+PreviousSuffix) != "new binary" {
+		t.Errorf("Rollback = %v: exe %q, previous %q", err, read(exe), read(exe+PreviousSuffix))
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+		t.Errorf("%d files left in the directory, want 2", len(entries))
+	}
+}
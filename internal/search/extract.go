@@ -0,0 +1,223 @@
+package search
+
+import (
+	"bytes"
+	"mime"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// Extraction limits; pages are published by anyone, so every input is
+// bounded.
+const (
+	maxFileBytes    = 512 << 10 // larger files are not indexed
+	maxSnippetText  = 8 << 10   // plain text kept per site for previews
+	maxTags         = 16
+	maxTagLength    = 32
+	maxTitleLength  = 200
+	maxDescLength   = 500
+	maxTokenRunes   = 64
+	minTokenRunes   = 2
+	maxTermsPerSite = 5000
+)
+
+// page is what extract finds in one file.
+type page struct {
+	title       string
+	description string
+	tags        []string
+	text        string
+}
+
+// textKind reports how a file is indexed: "html", "text" or "" for not
+// at all. Files that declare a charset other than UTF-8 are skipped
+// rather than guessed at.
+func textKind(filePath, mimeType string) string {
+	mt, params, _ := mime.ParseMediaType(mimeType)
+	if cs := params["charset"]; cs != "" && !strings.EqualFold(cs, "utf-8") && !strings.EqualFold(cs, "utf8") {
+		return ""
+	}
+	switch {
+	case mt == "text/html" || mt == "application/xhtml+xml":
+		return "html"
+	case mt == "text/plain" || mt == "text/markdown":
+		return "text"
+	case mt != "":
+		return ""
+	}
+	switch strings.ToLower(path.Ext(filePath)) {
+	case ".html", ".htm":
+		return "html"
+	case ".txt", ".md", ".markdown":
+		return "text"
+	}
+	return ""
+}
+
+// extract returns the indexable parts of a file, or ok false when it is
+// too large or not valid UTF-8.
+func extract(kind string, content []byte) (p page, ok bool) {
+	if len(content) > maxFileBytes || !utf8.Valid(content) {
+		return page{}, false
+	}
+	if kind == "text" {
+		return page{text: string(content)}, true
+	}
+
+	z := html.NewTokenizer(bytes.NewReader(content))
+	var text strings.Builder
+	skip := 0 // depth inside script, style and similar elements
+	inTitle := false
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			p.text = text.String()
+			return p, true
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			switch string(name) {
+			case "script", "style", "noscript", "template", "svg":
+				skip++
+			case "title":
+				inTitle = true
+			case "meta":
+				if hasAttr {
+					p.meta(z)
+				}
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case "script", "style", "noscript", "template", "svg":
+				if skip > 0 {
+					skip--
+				}
+			case "title":
+				inTitle = false
+			}
+		case html.TextToken:
+			if skip > 0 {
+				continue
+			}
+			t := strings.Join(strings.Fields(string(z.Text())), " ")
+			if t == "" {
+				continue
+			}
+			if inTitle {
+				if p.title == "" {
+					p.title = clip(t, maxTitleLength)
+				}
+				continue
+			}
+			if text.Len() > 0 {
+				text.WriteByte(' ')
+			}
+			text.WriteString(t)
+		}
+	}
+}
+
+// meta picks the description and keywords out of a <meta> tag.
+func (p *page) meta(z *html.Tokenizer) {
+	var name, content string
+	for {
+		key, val, more := z.TagAttr()
+		switch strings.ToLower(string(key)) {
+		case "name":
+			name = strings.ToLower(string(val))
+		case "content":
+			content = string(val)
+		}
+		if !more {
+			break
+		}
+	}
+	switch name {
+	case "description":
+		if p.description == "" {
+			p.description = clip(strings.Join(strings.Fields(content), " "), maxDescLength)
+		}
+	case "keywords":
+		for _, tag := range strings.Split(content, ",") {
+			tag = strings.ToLower(strings.TrimSpace(tag))
+			if tag == "" || utf8.RuneCountInString(tag) > maxTagLength || len(p.tags) >= maxTags {
+				continue
+			}
+			p.tags = append(p.tags, tag)
+		}
+	}
+}
+
+// tokens calls fn with every lowercased word of s.
+func tokens(s string, fn func(string)) {
+	for _, w := range strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		n := utf8.RuneCountInString(w)
+		if n < minTokenRunes || n > maxTokenRunes {
+			continue
+		}
+		fn(strings.ToLower(w))
+	}
+}
+
+// clip cuts s to at most n runes.
+func clip(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	r := []rune(s)
+	return string(r[:n])
+}
+
+// snippet returns up to width runes of text around the first match of
+// any of terms, or the start of text when none match.
+func snippet(text string, terms []string, width int) string {
+	r := []rune(text)
+	lower := make([]rune, len(r))
+	for i, c := range r {
+		lower[i] = unicode.ToLower(c)
+	}
+	at := -1
+	for _, term := range terms {
+		if i := runeIndex(lower, []rune(term)); i >= 0 && (at < 0 || i < at) {
+			at = i
+		}
+	}
+	start := 0
+	if at > width/3 {
+		start = at - width/3
+	}
+	end := start + width
+	if end > len(r) {
+		end = len(r)
+	}
+	s := strings.TrimSpace(string(r[start:end]))
+	if start > 0 {
+		s = "…" + s
+	}
+	if end < len(r) {
+		s += "…"
+	}
+	return s
+}
+
+func runeIndex(s, sub []rune) int {
+	if len(sub) == 0 {
+		return -1
+	}
+outer:
+	for i := 0; i+len(sub) <= len(s); i++ {
+		for j := range sub {
+			if s[i+j] != sub[j] {
+				continue outer
+			}
+		}
+		return i
+	}
+	return -1
+}
@@ -0,0 +1,628 @@
+// Package search indexes the sites held by the local store so they can be
+// found by words in their pages.
+//
+// Each site gets a card (names, title, description, tags, freshness and
+// whether every file is held locally) and its UTF-8 text files are
+// tokenized into an inverted index. Nothing is fetched from peers: a site
+// is searchable once its files are stored here, or once an indexer hands
+// in the main page of a site it crawled from a peer's sitemap (AddCrawled).
+//
+// The index is rebuilt lazily. A search older than MaxAge refreshes it
+// first, and sites whose manifest or head has not changed since the last
+// refresh are not read again.
+package search
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"alxnet/internal/core"
+	"alxnet/internal/store"
+)
+
+const (
+	// MaxAge is how stale the index may be before a search refreshes it.
+	MaxAge = 30 * time.Second
+
+	// MaxQueryBytes, MaxQueryTerms, MaxLimit and MaxOffset bound a Query.
+	MaxQueryBytes = 256
+	MaxQueryTerms = 8
+	MaxLimit      = 50
+	MaxOffset     = 10000
+
+	// DefaultLimit is the page size when a Query leaves Limit at zero.
+	DefaultLimit = 20
+
+	maxTextFiles   = 64  // text files indexed per site, main file first
+	maxExpansions  = 50  // index terms a query prefix may stand for
+	minPrefixRunes = 3   // shorter query terms only match whole words
+	snippetRunes   = 160 // length of a result preview
+)
+
+const (
+	// MaxCrawled caps the sites kept from crawls; the least recently
+	// crawled site makes room for a new one.
+	MaxCrawled = 10000
+	// CrawledTTL is how long a crawled site stays searchable without being
+	// crawled again.
+	CrawledTTL = 24 * time.Hour
+)
+
+// Weights of the places a word can appear in.
+const (
+	weightName        = 5
+	weightTitle       = 5
+	weightTag         = 4
+	weightDescription = 2
+	weightBody        = 1
+	weightPrefix      = 0.5 // multiplier for a prefix rather than a whole-word match
+)
+
+// Values of Query.Available and Query.Sort.
+const (
+	AvailableComplete = "complete"
+	AvailablePinned   = "pinned"
+
+	SortRelevance = "relevance"
+	SortRecent    = "recent"
+)
+
+// Card describes a site in search results.
+type Card struct {
+	SiteID      string   `json:"site_id"`
+	Names       []string `json:"names"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+	Files       int      `json:"files"`
+	Updated     int64    `json:"updated"`        // unix seconds of the current manifest or head
+	Pinned      bool     `json:"pinned"`         // kept by this node
+	Complete    bool     `json:"complete"`       // every file is stored locally
+	Peer        string   `json:"peer,omitempty"` // for crawled sites, the peer whose sitemap listed it
+}
+
+// Crawled is the main page of a site an indexer fetched from a peer that
+// lists it in its sitemap. Content must already be checked against its CID.
+type Crawled struct {
+	SiteID   string
+	Peer     string
+	Version  string // manifest CID, or head record CID of a single-file site
+	Updated  int64
+	MainPath string
+	MimeType string
+	Content  []byte
+}
+
+// Result is a Card that matched a Query.
+type Result struct {
+	Card
+	Score   float64 `json:"score"`
+	Snippet string  `json:"snippet"`
+}
+
+// Query selects and orders results. Text is matched word by word, every
+// word has to match; empty Text lists every site, newest first.
+type Query struct {
+	Text      string
+	Tag       string    // only sites with this meta keyword
+	Since     time.Time // only sites updated at or after Since
+	Available string    // "", AvailableComplete or AvailablePinned
+	Sort      string    // "", SortRelevance or SortRecent
+	Limit     int
+	Offset    int
+}
+
+// Validate checks q against the query bounds.
+func (q Query) Validate() error {
+	if len(q.Text) > MaxQueryBytes {
+		return fmt.Errorf("query is longer than %d bytes", MaxQueryBytes)
+	}
+	if n := len(queryTerms(q.Text)); n > MaxQueryTerms {
+		return fmt.Errorf("query has %d words, at most %d are allowed", n, MaxQueryTerms)
+	}
+	if len(q.Tag) > maxTagLength*4 {
+		return errors.New("tag is too long")
+	}
+	switch q.Available {
+	case "", AvailableComplete, AvailablePinned:
+	default:
+		return fmt.Errorf("unknown availability %q", q.Available)
+	}
+	switch q.Sort {
+	case "", SortRelevance, SortRecent:
+	default:
+		return fmt.Errorf("unknown sort %q", q.Sort)
+	}
+	if q.Limit < 0 || q.Limit > MaxLimit {
+		return fmt.Errorf("limit must be between 0 and %d", MaxLimit)
+	}
+	if q.Offset < 0 || q.Offset > MaxOffset {
+		return fmt.Errorf("offset must be between 0 and %d", MaxOffset)
+	}
+	return nil
+}
+
+// Index is the search index over a store.
+type Index struct {
+	store  *store.Store
+	maxAge time.Duration
+	now    func() time.Time
+
+	refreshing sync.Mutex // one refresh at a time
+
+	mu    sync.RWMutex
+	docs  map[string]*doc               // site ID -> indexed site
+	terms map[string]map[string]float64 // term -> site ID -> weight
+	vocab []string                      // sorted keys of terms, for prefix matches
+	built time.Time
+
+	crawledMu sync.Mutex
+	crawled   map[string]*doc // site ID -> crawled site, for sites not stored here
+}
+
+// doc is an indexed site.
+type doc struct {
+	card    Card
+	crawled time.Time          // when a crawled site was handed in
+	version string             // CID of the manifest or head it was built from
+	content []string           // CIDs of its files, to check availability
+	text    string             // start of the body text, for snippets
+	weights map[string]float64 // term weights from the site's files
+}
+
+// New returns an empty index over s; the first search fills it.
+func New(s *store.Store) *Index {
+	return &Index{store: s, maxAge: MaxAge, now: time.Now, crawled: make(map[string]*doc)}
+}
+
+// AddCrawled indexes the main page of a site crawled from a peer. Sites
+// stored here take precedence over crawled ones, and crawled sites are
+// dropped after CrawledTTL unless crawled again.
+func (ix *Index) AddCrawled(c Crawled) error {
+	kind := textKind(c.MainPath, c.MimeType)
+	if kind == "" {
+		return errors.New("main page is not UTF-8 text")
+	}
+	p, ok := extract(kind, c.Content)
+	if !ok {
+		return errors.New("main page is too large or not valid UTF-8")
+	}
+	d := &doc{
+		card: Card{
+			SiteID:      c.SiteID,
+			Title:       p.title,
+			Description: p.description,
+			Updated:     c.Updated,
+			Files:       1,
+			Tags:        p.tags,
+			Peer:        c.Peer,
+		},
+		crawled: ix.now(),
+		version: c.Version,
+		text:    clip(p.text, maxSnippetText),
+		weights: make(map[string]float64),
+	}
+	addPage(d, p)
+
+	ix.crawledMu.Lock()
+	if _, ok := ix.crawled[c.SiteID]; !ok && len(ix.crawled) >= MaxCrawled {
+		oldest := ""
+		for siteID, od := range ix.crawled {
+			if oldest == "" || od.crawled.Before(ix.crawled[oldest].crawled) {
+				oldest = siteID
+			}
+		}
+		delete(ix.crawled, oldest)
+	}
+	ix.crawled[c.SiteID] = d
+	ix.crawledMu.Unlock()
+
+	// The next search rebuilds the term index with this site in it
+	ix.mu.Lock()
+	ix.built = time.Time{}
+	ix.mu.Unlock()
+	return nil
+}
+
+// CrawledVersion returns the version a crawled site was indexed at, or ""
+// if it was not crawled, so an indexer can skip sites that have not changed.
+func (ix *Index) CrawledVersion(siteID string) string {
+	ix.crawledMu.Lock()
+	defer ix.crawledMu.Unlock()
+	if d := ix.crawled[siteID]; d != nil && ix.now().Sub(d.crawled) < CrawledTTL {
+		return d.version
+	}
+	return ""
+}
+
+// Refresh brings the index up to date with the store.
+func (ix *Index) Refresh() error {
+	ix.refreshing.Lock()
+	defer ix.refreshing.Unlock()
+	return ix.refresh()
+}
+
+func (ix *Index) refresh() error {
+	sites, err := ix.store.ListSites()
+	if err != nil {
+		return err
+	}
+	domains, err := ix.store.ListDomains()
+	if err != nil {
+		return err
+	}
+	names := make(map[string][]string)
+	for name, siteID := range domains {
+		names[siteID] = append(names[siteID], name)
+	}
+
+	ix.mu.RLock()
+	old := ix.docs
+	ix.mu.RUnlock()
+
+	docs := make(map[string]*doc, len(sites))
+	for _, siteID := range sites {
+		if ix.store.IsDenied(siteID) {
+			continue
+		}
+		version, err := ix.siteVersion(siteID)
+		if err != nil {
+			continue // a site without a manifest or head has nothing to show
+		}
+		d := old[siteID]
+		if d == nil || d.version != version {
+			if d, err = ix.build(siteID, version); err != nil {
+				continue
+			}
+		} else {
+			copied := *d
+			d = &copied
+		}
+		d.card.Names = names[siteID]
+		sort.Strings(d.card.Names)
+		d.card.Pinned = ix.store.IsPinned(siteID)
+		d.card.Complete = ix.holdsAll(d.content)
+		docs[siteID] = d
+	}
+
+	ix.crawledMu.Lock()
+	for siteID, d := range ix.crawled {
+		if ix.now().Sub(d.crawled) >= CrawledTTL {
+			delete(ix.crawled, siteID)
+			continue
+		}
+		if _, ok := docs[siteID]; ok || ix.store.IsDenied(siteID) {
+			continue
+		}
+		copied := *d
+		copied.card.Names = names[siteID]
+		sort.Strings(copied.card.Names)
+		docs[siteID] = &copied
+	}
+	ix.crawledMu.Unlock()
+
+	terms := make(map[string]map[string]float64)
+	add := func(siteID, term string, w float64) {
+		postings := terms[term]
+		if postings == nil {
+			postings = make(map[string]float64)
+			terms[term] = postings
+		}
+		postings[siteID] += w
+	}
+	for siteID, d := range docs {
+		for term, w := range d.weights {
+			add(siteID, term, w)
+		}
+		for _, name := range d.card.Names {
+			tokens(name, func(term string) { add(siteID, term, weightName) })
+		}
+	}
+	vocab := make([]string, 0, len(terms))
+	for term := range terms {
+		vocab = append(vocab, term)
+	}
+	sort.Strings(vocab)
+
+	ix.mu.Lock()
+	ix.docs, ix.terms, ix.vocab, ix.built = docs, terms, vocab, ix.now()
+	ix.mu.Unlock()
+	return nil
+}
+
+// siteVersion identifies the current state of a site: the CID of its
+// manifest, or of its head record for single-file sites.
+func (ix *Index) siteVersion(siteID string) (string, error) {
+	if ix.store.HasWebsiteManifest(siteID) {
+		data, err := ix.store.GetCurrentWebsiteManifest(siteID)
+		if err != nil {
+			return "", err
+		}
+		return core.CIDForBytes(data), nil
+	}
+	_, headCID, err := ix.store.GetHead(siteID)
+	if err != nil {
+		return "", err
+	}
+	if headCID == "" {
+		return "", errors.New("site has no head")
+	}
+	return headCID, nil
+}
+
+// indexFile is a file of a site considered for indexing.
+type indexFile struct {
+	path, contentCID, mimeType string
+}
+
+// build reads and tokenizes the files of a site.
+func (ix *Index) build(siteID, version string) (*doc, error) {
+	var files []indexFile
+	var mainFile string
+	var updated int64
+	if ix.store.HasWebsiteManifest(siteID) {
+		data, err := ix.store.GetCurrentWebsiteManifest(siteID)
+		if err != nil {
+			return nil, err
+		}
+		var m core.WebsiteManifest
+		if err := core.UnmarshalCBOR(data, &m); err != nil {
+			return nil, err
+		}
+		mainFile, updated = m.MainFile, m.TS
+		for p, cid := range m.Files {
+			files = append(files, indexFile{path: p, contentCID: cid, mimeType: ix.mimeType(siteID, p, cid)})
+		}
+	} else {
+		data, err := ix.store.GetRecord(version)
+		if err != nil {
+			return nil, err
+		}
+		var rec core.UpdateRecord
+		if err := core.UnmarshalCBOR(data, &rec); err != nil {
+			return nil, err
+		}
+		mainFile, updated = "index.html", rec.TS
+		files = []indexFile{{path: mainFile, contentCID: rec.ContentCID, mimeType: "text/html"}}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if (files[i].path == mainFile) != (files[j].path == mainFile) {
+			return files[i].path == mainFile
+		}
+		return files[i].path < files[j].path
+	})
+
+	d := &doc{
+		card:    Card{SiteID: siteID, Files: len(files), Updated: updated},
+		version: version,
+		weights: make(map[string]float64),
+	}
+	var text strings.Builder
+	indexed := 0
+	for _, f := range files {
+		d.content = append(d.content, f.contentCID)
+		kind := textKind(f.path, f.mimeType)
+		if kind == "" || indexed >= maxTextFiles {
+			continue
+		}
+		content, err := ix.store.GetContent(f.contentCID)
+		if err != nil {
+			continue // not stored here; the card reports the site incomplete
+		}
+		p, ok := extract(kind, content)
+		if !ok {
+			continue
+		}
+		indexed++
+
+		if f.path == mainFile {
+			d.card.Title, d.card.Description = p.title, p.description
+		}
+		for _, tag := range p.tags {
+			if len(d.card.Tags) < maxTags && !slices.Contains(d.card.Tags, tag) {
+				d.card.Tags = append(d.card.Tags, tag)
+			}
+		}
+		addPage(d, p)
+		if text.Len() < maxSnippetText && p.text != "" {
+			if text.Len() > 0 {
+				text.WriteByte(' ')
+			}
+			text.WriteString(p.text)
+		}
+	}
+	d.text = clip(text.String(), maxSnippetText)
+	return d, nil
+}
+
+// addPage adds the words of an extracted page to the term weights of d.
+func addPage(d *doc, p page) {
+	add := func(s string, w float64) {
+		tokens(s, func(term string) {
+			if _, ok := d.weights[term]; !ok && len(d.weights) >= maxTermsPerSite {
+				return
+			}
+			d.weights[term] += w
+		})
+	}
+	add(p.title, weightTitle)
+	add(p.description, weightDescription)
+	add(strings.Join(p.tags, " "), weightTag)
+	add(p.text, weightBody)
+}
+
+// mimeType returns the type recorded for a manifest file, or "" when the
+// file record is missing or describes other content.
+func (ix *Index) mimeType(siteID, filePath, contentCID string) string {
+	data, err := ix.store.GetFileRecordByPath(siteID, filePath)
+	if err != nil {
+		return ""
+	}
+	var rec core.FileRecord
+	if err := core.UnmarshalCBOR(data, &rec); err != nil || rec.ContentCID != contentCID {
+		return ""
+	}
+	return rec.MimeType
+}
+
+func (ix *Index) holdsAll(cids []string) bool {
+	for _, cid := range cids {
+		if ok, err := ix.store.HasContent(cid); err != nil || !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Search returns one page of the sites matching q and the number of
+// matches in all pages.
+func (ix *Index) Search(q Query) ([]Result, int, error) {
+	if err := q.Validate(); err != nil {
+		return nil, 0, err
+	}
+	if err := ix.ensureFresh(); err != nil {
+		return nil, 0, err
+	}
+	if q.Limit == 0 {
+		q.Limit = DefaultLimit
+	}
+	words := queryTerms(q.Text)
+	tag := strings.ToLower(strings.TrimSpace(q.Tag))
+
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	var scores map[string]float64
+	if len(words) > 0 {
+		scores = ix.score(words)
+	}
+	var results []Result
+	for siteID, d := range ix.docs {
+		score := 0.0
+		if scores != nil {
+			var ok bool
+			if score, ok = scores[siteID]; !ok {
+				continue
+			}
+		}
+		if tag != "" && !slices.Contains(d.card.Tags, tag) {
+			continue
+		}
+		if !q.Since.IsZero() && d.card.Updated < q.Since.Unix() {
+			continue
+		}
+		if q.Available == AvailableComplete && !d.card.Complete || q.Available == AvailablePinned && !d.card.Pinned {
+			continue
+		}
+		results = append(results, Result{Card: d.card, Score: score})
+	}
+
+	byRecent := q.Sort == SortRecent || len(words) == 0
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if !byRecent && a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Updated != b.Updated {
+			return a.Updated > b.Updated
+		}
+		return a.SiteID < b.SiteID
+	})
+
+	total := len(results)
+	if q.Offset >= total {
+		return []Result{}, total, nil
+	}
+	results = results[q.Offset:]
+	if len(results) > q.Limit {
+		results = results[:q.Limit]
+	}
+	for i := range results {
+		d := ix.docs[results[i].SiteID]
+		results[i].Snippet = snippet(d.text, words, snippetRunes)
+		if results[i].Snippet == "" {
+			results[i].Snippet = d.card.Description
+		}
+	}
+	return results, total, nil
+}
+
+func (ix *Index) ensureFresh() error {
+	ix.mu.RLock()
+	fresh := !ix.built.IsZero() && ix.now().Sub(ix.built) < ix.maxAge
+	ix.mu.RUnlock()
+	if fresh {
+		return nil
+	}
+	ix.refreshing.Lock()
+	defer ix.refreshing.Unlock()
+	// Another search may have refreshed while this one waited
+	ix.mu.RLock()
+	fresh = !ix.built.IsZero() && ix.now().Sub(ix.built) < ix.maxAge
+	ix.mu.RUnlock()
+	if fresh {
+		return nil
+	}
+	return ix.refresh()
+}
+
+// score returns the sites matching every word with their tf-idf score.
+// Words of minPrefixRunes or more also match longer index terms they
+// start, at a lower weight. The caller holds ix.mu.
+func (ix *Index) score(words []string) map[string]float64 {
+	n := float64(len(ix.docs))
+	var total map[string]float64
+	for _, word := range words {
+		matched := make(map[string]float64)
+		match := func(term string, factor float64) {
+			postings := ix.terms[term]
+			idf := math.Log(1 + n/float64(len(postings)))
+			for siteID, w := range postings {
+				matched[siteID] += w * idf * factor
+			}
+		}
+		if _, ok := ix.terms[word]; ok {
+			match(word, 1)
+		}
+		if len([]rune(word)) >= minPrefixRunes {
+			i := sort.SearchStrings(ix.vocab, word)
+			for expanded := 0; i < len(ix.vocab) && expanded < maxExpansions && strings.HasPrefix(ix.vocab[i], word); i++ {
+				if ix.vocab[i] == word {
+					continue
+				}
+				match(ix.vocab[i], weightPrefix)
+				expanded++
+			}
+		}
+
+		if total == nil {
+			total = matched
+			continue
+		}
+		for siteID, s := range total {
+			if m, ok := matched[siteID]; ok {
+				total[siteID] = s + m
+			} else {
+				delete(total, siteID)
+			}
+		}
+	}
+	return total
+}
+
+// queryTerms splits query text into distinct words.
+func queryTerms(text string) []string {
+	var words []string
+	tokens(text, func(term string) {
+		if !slices.Contains(words, term) {
+			words = append(words, term)
+		}
+	})
+	return words
+}
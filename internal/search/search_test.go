@@ -0,0 +1,352 @@
+package search
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"alxnet/internal/core"
+	"alxnet/internal/store"
+)
+
+const (
+	siteA = "aa00000000000000000000000000000000000000000000000000000000000000"
+	siteB = "bb00000000000000000000000000000000000000000000000000000000000000"
+	siteC = "cc00000000000000000000000000000000000000000000000000000000000000"
+	siteD = "dd00000000000000000000000000000000000000000000000000000000000000"
+)
+
+func TestTextKind(t *testing.T) {
+	tests := []struct {
+		path, mimeType, want string
+	}{
+		{"index.html", "text/html; charset=utf-8", "html"},
+		{"index.html", "", "html"},
+		{"notes.md", "", "text"},
+		{"readme.txt", "text/plain", "text"},
+		{"page.xhtml", "application/xhtml+xml", "html"},
+		{"old.html", "text/html; charset=iso-8859-1", ""},
+		{"old.txt", "text/plain; charset=windows-1252", ""},
+		{"style.css", "text/css", ""},
+		{"logo.png", "", ""},
+		{"index.html", "image/png", ""},
+	}
+	for _, tt := range tests {
+		if got := textKind(tt.path, tt.mimeType); got != tt.want {
+			t.Errorf("textKind(%q, %q) = %q, want %q", tt.path, tt.mimeType, got, tt.want)
+		}
+	}
+}
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		name    string
+		kind    string
+		content string
+		ok      bool
+		want    page
+	}{
+		{
+			name: "html",
+			kind: "html",
+			content: `<html><head><title> Garden  Notes </title>
+				<meta name="description" content="Growing   tomatoes">
+				<meta name="Keywords" content="Gardening, food, ,vegetables">
+				<style>body { color: red }</style><script>var secret = 1</script></head>
+				<body><h1>Spring</h1><p>Plant seeds <b>early</b>.</p><noscript>enable js</noscript></body></html>`,
+			ok: true,
+			want: page{
+				title:       "Garden Notes",
+				description: "Growing tomatoes",
+				tags:        []string{"gardening", "food", "vegetables"},
+				text:        "Spring Plant seeds early .",
+			},
+		},
+		{
+			name:    "text",
+			kind:    "text",
+			content: "# Heading\nplain words",
+			ok:      true,
+			want:    page{text: "# Heading\nplain words"},
+		},
+		{
+			name:    "invalid utf-8",
+			kind:    "text",
+			content: "caf\xe9",
+		},
+		{
+			name:    "too large",
+			kind:    "text",
+			content: strings.Repeat("a", maxFileBytes+1),
+		},
+		{
+			name:    "long keywords are dropped",
+			kind:    "html",
+			content: `<meta name="keywords" content="ok,` + strings.Repeat("x", maxTagLength+1) + `">`,
+			ok:      true,
+			want:    page{tags: []string{"ok"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := extract(tt.kind, []byte(tt.content))
+			if ok != tt.ok {
+				t.Fatalf("extract ok = %v, want %v", ok, tt.ok)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("extract = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokens(t *testing.T) {
+	var got []string
+	tokens("Hello, WORLD! a über-cool x2 "+strings.Repeat("z", maxTokenRunes+1), func(s string) {
+		got = append(got, s)
+	})
+	want := []string{"hello", "world", "über", "cool", "x2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("tokens = %q, want %q", got, want)
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	text := strings.Repeat("filler ", 40) + "the Ünicode needle is here " + strings.Repeat("more ", 40)
+	got := snippet(text, []string{"ünicode"}, 60)
+	if !strings.Contains(got, "Ünicode needle") || !strings.HasPrefix(got, "…") || !strings.HasSuffix(got, "…") {
+		t.Errorf("snippet = %q", got)
+	}
+	if got := snippet("short text", []string{"absent"}, 60); got != "short text" {
+		t.Errorf("snippet without a match = %q", got)
+	}
+}
+
+func TestQueryValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		q    Query
+		ok   bool
+	}{
+		{"empty", Query{}, true},
+		{"full", Query{Text: "a b", Tag: "x", Available: AvailablePinned, Sort: SortRecent, Limit: MaxLimit, Offset: MaxOffset}, true},
+		{"long text", Query{Text: strings.Repeat("a", MaxQueryBytes+1)}, false},
+		{"too many words", Query{Text: "aa bb cc dd ee ff gg hh ii"}, false},
+		{"unknown availability", Query{Available: "soon"}, false},
+		{"unknown sort", Query{Sort: "random"}, false},
+		{"large limit", Query{Limit: MaxLimit + 1}, false},
+		{"negative offset", Query{Offset: -1}, false},
+		{"large offset", Query{Offset: MaxOffset + 1}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.q.Validate(); (err == nil) != tt.ok {
+			t.Errorf("%s: Validate() = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+// testStore holds three multi-file sites and a single-file one.
+func testStore(t *testing.T) *store.Store {
+	t.Helper()
+	s, err := store.NewMemory()
+	if err != nil {
+		t.Fatalf("NewMemory: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	put := func(content string) string {
+		cid := core.CIDForContent([]byte(content))
+		if err := s.PutContent(cid, []byte(content)); err != nil {
+			t.Fatalf("PutContent: %v", err)
+		}
+		return cid
+	}
+	manifest := func(siteID string, ts int64, files map[string]string) {
+		m := core.WebsiteManifest{Version: "v1", Seq: 1, TS: ts, MainFile: "index.html", Files: files}
+		data, err := core.MarshalCanonical(&m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.PutWebsiteManifest(siteID, core.CIDForBytes(data), data); err != nil {
+			t.Fatalf("PutWebsiteManifest: %v", err)
+		}
+	}
+
+	manifest(siteA, 100, map[string]string{
+		"index.html": put(`<title>Tomato Garden</title><meta name="keywords" content="gardening">
+			<p>How to grow tomatoes on a balcony.</p>`),
+		"notes.md": put("Watering schedule for peppers."),
+	})
+	manifest(siteB, 300, map[string]string{
+		"index.html": put(`<title>Bike Repair</title><meta name="keywords" content="cycling">
+			<p>Fixing a flat tire and the garden gate.</p>`),
+		"missing.css": core.CIDForContent([]byte("never stored")),
+	})
+	// Latin-1 bytes are not indexed rather than guessed at
+	manifest(siteC, 200, map[string]string{
+		"index.html": put("<title>Caf\xe9</title><p>tomato soup</p>"),
+	})
+
+	content := put("<title>Single Page</title><p>Tomatoes everywhere.</p>")
+	rec := core.UpdateRecord{Version: core.RecordV1, Seq: 1, ContentCID: content, TS: 50}
+	data, err := core.MarshalCanonical(&rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PutRecord(core.CIDForBytes(data), data); err != nil {
+		t.Fatalf("PutRecord: %v", err)
+	}
+	if err := s.SetHead(siteD, 1, core.CIDForBytes(data)); err != nil {
+		t.Fatalf("SetHead: %v", err)
+	}
+
+	if err := s.PutDomain("veggies", siteA); err != nil {
+		t.Fatalf("PutDomain: %v", err)
+	}
+	if err := s.PinSite(siteB); err != nil {
+		t.Fatalf("PinSite: %v", err)
+	}
+	return s
+}
+
+func TestSearch(t *testing.T) {
+	ix := New(testStore(t))
+
+	tests := []struct {
+		name string
+		q    Query
+		want []string
+	}{
+		{"empty lists newest first", Query{}, []string{siteB, siteC, siteA, siteD}},
+		{"word", Query{Text: "balcony"}, []string{siteA}},
+		{"every word must match", Query{Text: "tomatoes balcony"}, []string{siteA}},
+		{"no match", Query{Text: "tomatoes bike"}, nil},
+		{"title outranks body", Query{Text: "garden"}, []string{siteA, siteB}},
+		{"prefix", Query{Text: "tomat"}, []string{siteA, siteD}},
+		{"short words are not prefixes", Query{Text: "ga"}, nil},
+		{"site name", Query{Text: "veggies"}, []string{siteA}},
+		{"markdown", Query{Text: "peppers"}, []string{siteA}},
+		{"undeclared encoding is skipped", Query{Text: "soup"}, nil},
+		{"tag", Query{Tag: "Cycling"}, []string{siteB}},
+		{"since", Query{Since: time.Unix(150, 0)}, []string{siteB, siteC}},
+		{"pinned", Query{Available: AvailablePinned}, []string{siteB}},
+		{"complete", Query{Available: AvailableComplete}, []string{siteC, siteA, siteD}},
+		{"recent", Query{Text: "garden", Sort: SortRecent}, []string{siteB, siteA}},
+		{"page", Query{Limit: 2, Offset: 1}, []string{siteC, siteA}},
+		{"past the end", Query{Offset: 10}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, total, err := ix.Search(tt.q)
+			if err != nil {
+				t.Fatalf("Search: %v", err)
+			}
+			var got []string
+			for _, r := range results {
+				got = append(got, r.SiteID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Search(%+v) = %v, want %v", tt.q, got, tt.want)
+			}
+			if tt.q.Offset == 0 && tt.q.Limit == 0 && total != len(tt.want) {
+				t.Errorf("total = %d, want %d", total, len(tt.want))
+			}
+		})
+	}
+
+	results, _, err := ix.Search(Query{Text: "balcony"})
+	if err != nil || len(results) != 1 {
+		t.Fatalf("Search: %v, %v", results, err)
+	}
+	card := results[0]
+	if card.Title != "Tomato Garden" || !reflect.DeepEqual(card.Names, []string{"veggies"}) ||
+		!reflect.DeepEqual(card.Tags, []string{"gardening"}) || card.Files != 2 || !card.Complete {
+		t.Errorf("card = %+v", card.Card)
+	}
+	if !strings.Contains(card.Snippet, "balcony") {
+		t.Errorf("snippet = %q", card.Snippet)
+	}
+}
+
+func TestSearchRefresh(t *testing.T) {
+	s := testStore(t)
+	ix := New(s)
+	now := time.Unix(1000, 0)
+	ix.now = func() time.Time { return now }
+
+	if _, total, _ := ix.Search(Query{}); total != 4 {
+		t.Fatalf("total = %d, want 4", total)
+	}
+	if _, err := s.Deny(siteA, "test"); err != nil {
+		t.Fatalf("Deny: %v", err)
+	}
+	if _, total, _ := ix.Search(Query{}); total != 4 {
+		t.Errorf("fresh index changed: total = %d, want 4", total)
+	}
+	now = now.Add(MaxAge)
+	if _, total, _ := ix.Search(Query{}); total != 3 {
+		t.Errorf("denied site still listed after refresh: total = %d, want 3", total)
+	}
+}
+
+func TestAddCrawled(t *testing.T) {
+	s := testStore(t)
+	ix := New(s)
+	now := time.Unix(1000, 0)
+	ix.now = func() time.Time { return now }
+
+	const (
+		remote  = "ee00000000000000000000000000000000000000000000000000000000000000"
+		version = "1100000000000000000000000000000000000000000000000000000000000000"
+		peerID  = "12D3KooWRemote"
+	)
+	page := []byte(`<title>Remote Orchard</title><meta name="keywords" content="fruit"><p>Apple trees.</p>`)
+	tests := []struct {
+		name string
+		c    Crawled
+		ok   bool
+	}{
+		{"html", Crawled{SiteID: remote, Peer: peerID, Version: version, Updated: 900, MainPath: "index.html", Content: page}, true},
+		{"binary", Crawled{SiteID: remote, MainPath: "logo.png", MimeType: "image/png", Content: page}, false},
+		{"latin-1", Crawled{SiteID: remote, MainPath: "index.html", Content: []byte("<p>Caf\xe9</p>")}, false},
+		{"too large", Crawled{SiteID: remote, MainPath: "a.txt", Content: make([]byte, maxFileBytes+1)}, false},
+	}
+	for _, tt := range tests {
+		if err := ix.AddCrawled(tt.c); (err == nil) != tt.ok {
+			t.Errorf("%s: AddCrawled() error = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+	if got := ix.CrawledVersion(remote); got != version {
+		t.Errorf("CrawledVersion() = %q, want %q", got, version)
+	}
+
+	results, total, err := ix.Search(Query{Text: "apple"})
+	if err != nil || total != 1 {
+		t.Fatalf("Search(apple) = %d results, %v", total, err)
+	}
+	if r := results[0]; r.SiteID != remote || r.Peer != peerID || r.Title != "Remote Orchard" || r.Complete || r.Pinned ||
+		!reflect.DeepEqual(r.Tags, []string{"fruit"}) {
+		t.Errorf("crawled result = %+v", r)
+	}
+
+	// A crawl never replaces a site stored here
+	if err := ix.AddCrawled(Crawled{SiteID: siteA, Peer: peerID, Version: version, MainPath: "index.html", Content: page}); err != nil {
+		t.Fatal(err)
+	}
+	results, _, _ = ix.Search(Query{Text: "tomato garden"})
+	if len(results) != 1 || results[0].SiteID != siteA || results[0].Peer != "" {
+		t.Errorf("stored site shadowed by a crawl: %+v", results)
+	}
+	if _, total, _ := ix.Search(Query{Text: "apple"}); total != 1 {
+		t.Errorf("Search(apple) = %d results, want only the remote site", total)
+	}
+
+	now = now.Add(CrawledTTL)
+	if _, total, _ := ix.Search(Query{Text: "apple"}); total != 0 {
+		t.Errorf("crawled site kept past CrawledTTL")
+	}
+	if got := ix.CrawledVersion(remote); got != "" {
+		t.Errorf("CrawledVersion() after expiry = %q", got)
+	}
+}
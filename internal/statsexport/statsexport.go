@@ -0,0 +1,173 @@
+// Package statsexport pushes the node's statistics to InfluxDB or Graphite
+// at a fixed interval, for pipelines that collect by push rather than by
+// scraping.
+//
+// Each push carries one sample: the gauges as last read and the counters
+// as running totals since the node started, so a restart shows as a reset
+// to be handled like any other counter reset.
+package statsexport
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"alxnet/internal/store"
+
+	"go.uber.org/zap"
+)
+
+// Timeout bounds one push.
+const Timeout = 10 * time.Second
+
+// Exporter formats samples for one endpoint and sends them.
+type Exporter struct {
+	format   string // "influx" or "graphite"
+	endpoint string
+	token    string
+	prefix   string
+	tags     map[string]string // influx tags; graphite ignores them
+	client   *http.Client
+}
+
+// New returns an exporter writing format ("influx" or "graphite") to
+// endpoint: an InfluxDB write URL, with org and bucket or db in its query,
+// or a Graphite plaintext host:port. Token authorizes InfluxDB writes.
+// Prefix names the measurement or starts every Graphite path, and tags are
+// added to every InfluxDB point.
+func New(format, endpoint, token, prefix string, tags map[string]string) (*Exporter, error) {
+	if format != "influx" && format != "graphite" {
+		return nil, fmt.Errorf("unknown stats format %q", format)
+	}
+	if endpoint == "" {
+		return nil, errors.New("stats endpoint is required")
+	}
+	return &Exporter{
+		format:   format,
+		endpoint: endpoint,
+		token:    token,
+		prefix:   prefix,
+		tags:     tags,
+		client:   &http.Client{Timeout: Timeout},
+	}, nil
+}
+
+// fields returns the sample's values by name, in a fixed order.
+func fields(s store.MetricSample) [][2]interface{} {
+	return [][2]interface{}{
+		{"uptime_seconds", s.Uptime},
+		{"peers", s.Peers},
+		{"storage_bytes", s.StorageBytes},
+		{"sites", s.Sites},
+		{"bytes_served", s.Served},
+		{"bytes_received", s.Received},
+		{"updates", s.Updates},
+	}
+}
+
+// Encode returns the sample in the exporter's wire format.
+func (e *Exporter) Encode(s store.MetricSample) []byte {
+	var b bytes.Buffer
+	switch e.format {
+	case "influx":
+		// measurement,tag=v field=1i,... <ns>
+		b.WriteString(influxEscape(e.prefix))
+		for _, k := range slices.Sorted(maps.Keys(e.tags)) {
+			fmt.Fprintf(&b, ",%s=%s", influxEscape(k), influxEscape(e.tags[k]))
+		}
+		for i, f := range fields(s) {
+			sep := ","
+			if i == 0 {
+				sep = " "
+			}
+			fmt.Fprintf(&b, "%s%s=%di", sep, f[0], f[1])
+		}
+		fmt.Fprintf(&b, " %d\n", time.Unix(s.Time, 0).UnixNano())
+	case "graphite":
+		// prefix.name value <unix seconds>
+		for _, f := range fields(s) {
+			fmt.Fprintf(&b, "%s.%s %d %d\n", e.prefix, f[0], f[1], s.Time)
+		}
+	}
+	return b.Bytes()
+}
+
+// Push sends one sample.
+func (e *Exporter) Push(ctx context.Context, s store.MetricSample) error {
+	body := e.Encode(s)
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+
+	if e.format == "graphite" {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", e.endpoint)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		if deadline, ok := ctx.Deadline(); ok {
+			conn.SetWriteDeadline(deadline)
+		}
+		_, err = conn.Write(body)
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if e.token != "" {
+		req.Header.Set("Authorization", "Token "+e.token)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("influx answered %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// Run pushes a sample from source every interval until ctx is done. A
+// failed push is logged and the next one goes ahead as planned.
+func (e *Exporter) Run(ctx context.Context, interval time.Duration, source func() store.MetricSample, logger *zap.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	failing := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := e.Push(ctx, source())
+			switch {
+			case err != nil && !failing:
+				logger.Warn("failed to push stats", zap.String("format", e.format), zap.Error(err))
+			case err != nil:
+				logger.Debug("failed to push stats", zap.Error(err))
+			case failing:
+				logger.Info("pushing stats again")
+			}
+			failing = err != nil
+		}
+	}
+}
+
+// influxEscape escapes a measurement, tag key or tag value for the line
+// protocol.
+func influxEscape(s string) string {
+	return strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`).Replace(s)
+}
@@ -0,0 +1,84 @@
+package statsexport
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"alxnet/internal/store"
+)
+
+var sample = store.MetricSample{Time: 1700000000, Uptime: 60, Peers: 3, StorageBytes: 4096, Sites: 2, Served: 100, Received: 50, Updates: 1}
+
+func TestPushInflux(t *testing.T) {
+	var body, auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body, auth = string(b), r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	e, err := New("influx", srv.URL+"/api/v2/write?org=ops&bucket=alxnet", "tok", "alxnet", map[string]string{"peer": "12D3 Koo"})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if err := e.Push(context.Background(), sample); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	want := `alxnet,peer=12D3\ Koo uptime_seconds=60i,peers=3i,storage_bytes=4096i,sites=2i,bytes_served=100i,bytes_received=50i,updates=1i 1700000000000000000` + "\n"
+	if body != want {
+		t.Errorf("body = %q\nwant   %q", body, want)
+	}
+	if auth != "Token tok" {
+		t.Errorf("Authorization = %q", auth)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bucket not found", http.StatusNotFound)
+	}))
+	defer failing.Close()
+	e, _ = New("influx", failing.URL, "", "alxnet", nil)
+	if err := e.Push(context.Background(), sample); err == nil || !strings.Contains(err.Error(), "bucket not found") {
+		t.Errorf("Push to failing server = %v, want the server's answer", err)
+	}
+}
+
+func TestPushGraphite(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	lines := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var got []string
+		sc := bufio.NewScanner(conn)
+		for sc.Scan() {
+			got = append(got, sc.Text())
+		}
+		lines <- got
+	}()
+
+	e, err := New("graphite", ln.Addr().String(), "", "alxnet.edge1", nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if err := e.Push(context.Background(), sample); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	got := <-lines
+	if len(got) != 7 || got[0] != "alxnet.edge1.uptime_seconds 60 1700000000" || got[6] != "alxnet.edge1.updates 1 1700000000" {
+		t.Errorf("lines = %q", got)
+	}
+}
@@ -0,0 +1,166 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"alxnet/internal/core"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// The address book maps names the user picks to sites they visit often.
+// Unlike site names it is local to this node and needs no registration.
+// Each entry is kept as alias:<name>.
+
+// Address book limits
+const (
+	MaxAliases   = 1000
+	MaxAliasName = 32
+	MaxAliasNote = 280
+)
+
+// ErrTooManyAliases is returned when adding another entry would exceed
+// MaxAliases.
+var ErrTooManyAliases = errors.New("address book is full")
+
+// Alias is an address book entry. Target is a site ID or a registered site
+// name, resolved when the alias is browsed.
+type Alias struct {
+	Name   string    `cbor:"name" json:"name"`
+	Target string    `cbor:"target" json:"target"`
+	Note   string    `cbor:"note,omitempty" json:"note,omitempty"`
+	Added  time.Time `cbor:"added" json:"added"`
+}
+
+// ValidAliasName reports whether name can name an address book entry: 1 to
+// MaxAliasName lowercase letters, digits, '-', '_' and '.'.
+func ValidAliasName(name string) bool {
+	if name == "" || len(name) > MaxAliasName {
+		return false
+	}
+	for _, c := range name {
+		if !((c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidAliasTarget reports whether target is a site ID or a well-formed
+// site name.
+func ValidAliasTarget(target string) bool {
+	if len(target) == 64 {
+		for _, c := range target {
+			if !((c >= '0' && c <= '9') || (c >= 'a' && c <= 'f')) {
+				return false
+			}
+		}
+		return true
+	}
+	return core.ValidSiteName(target)
+}
+
+func aliasKey(name string) []byte { return []byte("alias:" + name) }
+
+// PutAlias adds a, replacing the entry of the same name. Added is kept from
+// the entry it replaces.
+func (s *Store) PutAlias(a *Alias) error {
+	if !ValidAliasName(a.Name) {
+		return fmt.Errorf("invalid alias name: %q", a.Name)
+	}
+	if !ValidAliasTarget(a.Target) {
+		return fmt.Errorf("alias target must be a site ID or site name: %q", a.Target)
+	}
+	if len(a.Note) > MaxAliasNote {
+		return fmt.Errorf("note too long: %d > %d", len(a.Note), MaxAliasNote)
+	}
+	return s.db.Update(func(txn kvTxn) error {
+		item, err := txn.Get(aliasKey(a.Name))
+		switch {
+		case errors.Is(err, badger.ErrKeyNotFound):
+			if countPrefix(txn, []byte("alias:")) >= MaxAliases {
+				return ErrTooManyAliases
+			}
+			a.Added = time.Now().UTC()
+		case err != nil:
+			return err
+		default:
+			var old Alias
+			if err := item.Value(func(v []byte) error { return core.UnmarshalCBOR(v, &old) }); err != nil {
+				return err
+			}
+			a.Added = old.Added
+		}
+		data, err := core.MarshalCanonical(a)
+		if err != nil {
+			return err
+		}
+		return txn.Set(aliasKey(a.Name), data)
+	})
+}
+
+// Aliases returns the address book by name.
+func (s *Store) Aliases() ([]Alias, error) {
+	var aliases []Alias
+	err := s.db.View(func(txn kvTxn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte("alias:")
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			var a Alias
+			if err := it.Item().Value(func(v []byte) error { return core.UnmarshalCBOR(v, &a) }); err != nil {
+				continue
+			}
+			aliases = append(aliases, a)
+		}
+		return nil
+	})
+	return aliases, err
+}
+
+// GetAlias returns the entry named name, or nil.
+func (s *Store) GetAlias(name string) (*Alias, error) {
+	if !ValidAliasName(name) {
+		return nil, fmt.Errorf("invalid alias name: %q", name)
+	}
+	var a *Alias
+	err := s.db.View(func(txn kvTxn) error {
+		item, err := txn.Get(aliasKey(name))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		a = new(Alias)
+		return item.Value(func(v []byte) error { return core.UnmarshalCBOR(v, a) })
+	})
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// DeleteAlias removes the entry named name and reports whether there was
+// one.
+func (s *Store) DeleteAlias(name string) (bool, error) {
+	if !ValidAliasName(name) {
+		return false, fmt.Errorf("invalid alias name: %q", name)
+	}
+	found := false
+	err := s.db.Update(func(txn kvTxn) error {
+		_, err := txn.Get(aliasKey(name))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		found = true
+		return txn.Delete(aliasKey(name))
+	})
+	return found, err
+}
@@ -0,0 +1,61 @@
+package store
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAliases(t *testing.T) {
+	s := openTestStore(t)
+	siteID := strings.Repeat("ab", 32)
+
+	if err := s.PutAlias(&Alias{Name: "news", Target: siteID, Note: "daily"}); err != nil {
+		t.Fatalf("PutAlias: %v", err)
+	}
+	first, err := s.GetAlias("news")
+	if err != nil || first == nil || first.Target != siteID || first.Added.IsZero() {
+		t.Fatalf("GetAlias() = %+v, %v", first, err)
+	}
+
+	// Replacing an entry keeps when it was added
+	if err := s.PutAlias(&Alias{Name: "news", Target: "mysite"}); err != nil {
+		t.Fatalf("PutAlias (replace): %v", err)
+	}
+	if err := s.PutAlias(&Alias{Name: "blog.old", Target: siteID}); err != nil {
+		t.Fatalf("PutAlias: %v", err)
+	}
+	aliases, err := s.Aliases()
+	if err != nil || len(aliases) != 2 || aliases[0].Name != "blog.old" || aliases[1].Target != "mysite" ||
+		aliases[1].Note != "" || !aliases[1].Added.Equal(first.Added) {
+		t.Errorf("Aliases() = %+v, %v", aliases, err)
+	}
+
+	if found, err := s.DeleteAlias("news"); err != nil || !found {
+		t.Errorf("DeleteAlias() = %v, %v", found, err)
+	}
+	if found, err := s.DeleteAlias("news"); err != nil || found {
+		t.Errorf("DeleteAlias() again = %v, %v", found, err)
+	}
+	if got, err := s.GetAlias("news"); err != nil || got != nil {
+		t.Errorf("GetAlias() after delete = %+v, %v", got, err)
+	}
+
+	tests := []struct {
+		name  string
+		alias Alias
+	}{
+		{"empty name", Alias{Target: siteID}},
+		{"uppercase name", Alias{Name: "News", Target: siteID}},
+		{"name with colon", Alias{Name: "a:b", Target: siteID}},
+		{"long name", Alias{Name: strings.Repeat("a", MaxAliasName+1), Target: siteID}},
+		{"no target", Alias{Name: "a"}},
+		{"uppercase site ID", Alias{Name: "a", Target: strings.ToUpper(siteID)}},
+		{"bad site name", Alias{Name: "a", Target: "x"}},
+		{"long note", Alias{Name: "a", Target: siteID, Note: strings.Repeat("n", MaxAliasNote+1)}},
+	}
+	for _, tt := range tests {
+		if err := s.PutAlias(&tt.alias); err == nil {
+			t.Errorf("%s: PutAlias accepted %+v", tt.name, tt.alias)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"alxnet/internal/core"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// API tokens give scripts and dashboards a role on the management APIs
+// without the admin token. Only a token's SHA-256 is kept, as
+// apitoken:<hash>, so the store cannot give the token back.
+
+// MaxAPITokens is how many API tokens a node keeps.
+const MaxAPITokens = 64
+
+// ErrAPITokensFull is returned when issuing another token would exceed
+// MaxAPITokens.
+var ErrAPITokensFull = errors.New("too many API tokens")
+
+// ErrAPITokenExists is returned when a token of the same name is issued.
+var ErrAPITokenExists = errors.New("an API token of that name exists")
+
+// APIToken is an issued token's name and role.
+type APIToken struct {
+	Name    string    `cbor:"name" json:"name"`
+	Role    string    `cbor:"role" json:"role"`
+	Created time.Time `cbor:"created" json:"created"`
+}
+
+func apiTokenKey(secret string) []byte {
+	sum := sha256.Sum256([]byte(secret))
+	return []byte("apitoken:" + hex.EncodeToString(sum[:]))
+}
+
+// PutAPIToken stores t as the token secret. Names are unique and follow
+// ValidRemoteNodeName's rules.
+func (s *Store) PutAPIToken(secret string, t *APIToken) error {
+	if !ValidRemoteNodeName(t.Name) {
+		return fmt.Errorf("invalid token name: %q", t.Name)
+	}
+	if t.Role == "" {
+		return errors.New("token has no role")
+	}
+	if secret == "" {
+		return errors.New("empty token")
+	}
+	data, err := core.MarshalCanonical(t)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(txn kvTxn) error {
+		key, n := findAPIToken(txn, t.Name)
+		if key != nil {
+			return ErrAPITokenExists
+		}
+		if n >= MaxAPITokens {
+			return ErrAPITokensFull
+		}
+		return txn.Set(apiTokenKey(secret), data)
+	})
+}
+
+// findAPIToken returns the key of the token named name, or nil, and how
+// many tokens there are.
+func findAPIToken(txn kvTxn, name string) ([]byte, int) {
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = []byte("apitoken:")
+	it := txn.NewIterator(opts)
+	defer it.Close()
+	var key []byte
+	n := 0
+	for it.Rewind(); it.Valid(); it.Next() {
+		n++
+		var t APIToken
+		if err := it.Item().Value(func(v []byte) error { return core.UnmarshalCBOR(v, &t) }); err != nil {
+			continue
+		}
+		if t.Name == name {
+			key = it.Item().KeyCopy(nil)
+		}
+	}
+	return key, n
+}
+
+// APITokenBySecret returns the token secret was issued as, or nil.
+func (s *Store) APITokenBySecret(secret string) (*APIToken, error) {
+	if secret == "" {
+		return nil, nil
+	}
+	var t *APIToken
+	err := s.db.View(func(txn kvTxn) error {
+		item, err := txn.Get(apiTokenKey(secret))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		t = new(APIToken)
+		return item.Value(func(v []byte) error { return core.UnmarshalCBOR(v, t) })
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// APITokens returns the issued tokens, oldest first.
+func (s *Store) APITokens() ([]APIToken, error) {
+	var tokens []APIToken
+	err := s.db.View(func(txn kvTxn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte("apitoken:")
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			var t APIToken
+			if err := it.Item().Value(func(v []byte) error { return core.UnmarshalCBOR(v, &t) }); err != nil {
+				continue
+			}
+			tokens = append(tokens, t)
+		}
+		return nil
+	})
+	sort.SliceStable(tokens, func(i, j int) bool { return tokens[i].Created.Before(tokens[j].Created) })
+	return tokens, err
+}
+
+// DeleteAPIToken revokes the token named name and reports whether there
+// was one.
+func (s *Store) DeleteAPIToken(name string) (bool, error) {
+	if !ValidRemoteNodeName(name) {
+		return false, fmt.Errorf("invalid token name: %q", name)
+	}
+	found := false
+	err := s.db.Update(func(txn kvTxn) error {
+		key, _ := findAPIToken(txn, name)
+		if key == nil {
+			return nil
+		}
+		found = true
+		return txn.Delete(key)
+	})
+	return found, err
+}
@@ -0,0 +1,47 @@
+package store
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAPITokens(t *testing.T) {
+	s := openTestStore(t)
+
+	now := time.Now().UTC()
+	if err := s.PutAPIToken("s3cret", &APIToken{Name: "grafana", Role: "viewer", Created: now}); err != nil {
+		t.Fatalf("PutAPIToken: %v", err)
+	}
+	if err := s.PutAPIToken("other", &APIToken{Name: "ci", Role: "publisher", Created: now.Add(time.Second)}); err != nil {
+		t.Fatalf("PutAPIToken: %v", err)
+	}
+	if err := s.PutAPIToken("again", &APIToken{Name: "grafana", Role: "admin"}); !errors.Is(err, ErrAPITokenExists) {
+		t.Errorf("PutAPIToken (same name) = %v, want ErrAPITokenExists", err)
+	}
+	if err := s.PutAPIToken("x", &APIToken{Name: "Bad:Name", Role: "viewer"}); err == nil {
+		t.Error("PutAPIToken accepted an invalid name")
+	}
+
+	got, err := s.APITokenBySecret("s3cret")
+	if err != nil || got == nil || got.Name != "grafana" || got.Role != "viewer" {
+		t.Fatalf("APITokenBySecret() = %+v, %v", got, err)
+	}
+	if got, err := s.APITokenBySecret("guess"); err != nil || got != nil {
+		t.Errorf("APITokenBySecret(unknown) = %+v, %v", got, err)
+	}
+	tokens, err := s.APITokens()
+	if err != nil || len(tokens) != 2 || tokens[0].Name != "grafana" || tokens[1].Name != "ci" {
+		t.Errorf("APITokens() = %+v, %v", tokens, err)
+	}
+
+	if found, err := s.DeleteAPIToken("grafana"); err != nil || !found {
+		t.Errorf("DeleteAPIToken() = %v, %v", found, err)
+	}
+	if found, err := s.DeleteAPIToken("grafana"); err != nil || found {
+		t.Errorf("DeleteAPIToken() twice = %v, %v", found, err)
+	}
+	if got, err := s.APITokenBySecret("s3cret"); err != nil || got != nil {
+		t.Errorf("APITokenBySecret() after revoking = %+v, %v", got, err)
+	}
+}
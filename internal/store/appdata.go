@@ -0,0 +1,115 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+
+	"alxnet/internal/core"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// MaxAppDataKeys bounds the app data keys kept for one site, deleted keys
+// included, so a site's app data stays within MaxAppDataKeys times
+// core.MaxAppDataValueSize.
+const MaxAppDataKeys = 256
+
+// ErrTooManyAppDataKeys is returned when a new app data key would exceed
+// MaxAppDataKeys.
+var ErrTooManyAppDataKeys = errors.New("too many app data keys for site")
+
+func appDataPrefix(siteID string) []byte { return []byte("appdata:" + siteID + ":") }
+
+// PutAppData stores a verified app data record unless a record with the
+// same or a higher Seq is already stored for its site and key. Deletions
+// are kept like other records, so older values cannot be replayed. It
+// reports whether the record was applied.
+func (s *Store) PutAppData(siteID string, rec *core.AppDataRecord, data []byte) (bool, error) {
+	if err := s.validateKeyValue(siteID, data); err != nil {
+		return false, fmt.Errorf("validation failed: %w", err)
+	}
+	if !core.ValidAppDataKey(rec.Key) {
+		return false, fmt.Errorf("invalid app data key: %q", rec.Key)
+	}
+
+	s.appDataMu.Lock()
+	defer s.appDataMu.Unlock()
+
+	applied := false
+	err := s.db.Update(func(txn kvTxn) error {
+		key := append(appDataPrefix(siteID), rec.Key...)
+		it, err := txn.Get(key)
+		switch {
+		case err == nil:
+			var cur core.AppDataRecord
+			if err := it.Value(func(v []byte) error {
+				return core.UnmarshalCBOR(v, &cur)
+			}); err != nil {
+				return err
+			}
+			if cur.Seq >= rec.Seq {
+				return nil
+			}
+		case err == badger.ErrKeyNotFound:
+			if countPrefix(txn, appDataPrefix(siteID)) >= MaxAppDataKeys {
+				return ErrTooManyAppDataKeys
+			}
+		default:
+			return err
+		}
+		applied = true
+		return txn.Set(key, data)
+	})
+	return applied, err
+}
+
+// GetAppData returns the app data record of siteID for key, which may be
+// a deletion, or nil if none is stored.
+func (s *Store) GetAppData(siteID, key string) (*core.AppDataRecord, error) {
+	if err := s.validateKey(siteID); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+	if !core.ValidAppDataKey(key) {
+		return nil, fmt.Errorf("invalid app data key: %q", key)
+	}
+	var rec *core.AppDataRecord
+	err := s.db.View(func(txn kvTxn) error {
+		it, err := txn.Get(append(appDataPrefix(siteID), key...))
+		if err == badger.ErrKeyNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return it.Value(func(v []byte) error {
+			rec = &core.AppDataRecord{}
+			return core.UnmarshalCBOR(v, rec)
+		})
+	})
+	return rec, err
+}
+
+// ListAppData returns the app data records of siteID sorted by key,
+// deletions included.
+func (s *Store) ListAppData(siteID string) ([]core.AppDataRecord, error) {
+	if err := s.validateKey(siteID); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+	var out []core.AppDataRecord
+	err := s.db.View(func(txn kvTxn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		prefix := appDataPrefix(siteID)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var rec core.AppDataRecord
+			if err := it.Item().Value(func(v []byte) error {
+				return core.UnmarshalCBOR(v, &rec)
+			}); err != nil {
+				return err
+			}
+			out = append(out, rec)
+		}
+		return nil
+	})
+	return out, err
+}
@@ -0,0 +1,127 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"alxnet/internal/core"
+
+	"github.com/dgraph-io/badger/v4"
+	"go.uber.org/zap"
+)
+
+// BlobBackend stores large content blobs outside Badger, for nodes whose
+// local disk is too small to hold everything they serve. Keys are content
+// CIDs; the store verifies fetched data against them.
+type BlobBackend interface {
+	Put(ctx context.Context, cid string, data []byte) error
+	Get(ctx context.Context, cid string) ([]byte, error) // ErrBlobNotFound if absent
+	Delete(ctx context.Context, cid string) error
+}
+
+// ErrBlobNotFound is returned by backends for unknown CIDs.
+var ErrBlobNotFound = errors.New("blob not found")
+
+// Blob backend defaults
+const (
+	DefaultBlobThreshold = 1024 * 1024 // content at least this large goes to the backend
+	DefaultBlobCacheTTL  = time.Hour   // how long fetched blobs stay on local disk
+	BlobTimeout          = 30 * time.Second
+)
+
+// SetBlobBackend moves content of at least threshold bytes to b. Badger
+// keeps a "blob:<cid>" marker with the size, and a local copy that expires
+// after cacheTTL; reads fetch the blob back through the backend when the
+// local copy has expired. Passing nil disables the backend for new writes.
+func (s *Store) SetBlobBackend(b BlobBackend, threshold int, cacheTTL time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if threshold <= 0 {
+		threshold = DefaultBlobThreshold
+	}
+	if cacheTTL <= 0 {
+		cacheTTL = DefaultBlobCacheTTL
+	}
+	s.blobs = b
+	s.blobThreshold = threshold
+	s.blobCacheTTL = cacheTTL
+}
+
+func (s *Store) blobBackend() (BlobBackend, int, time.Duration) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.blobs, s.blobThreshold, s.blobCacheTTL
+}
+
+// putBlob uploads data and records the marker plus a short-lived local copy.
+func (s *Store) putBlob(b BlobBackend, cacheTTL time.Duration, cid string, data []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), BlobTimeout)
+	defer cancel()
+	if err := b.Put(ctx, cid, data); err != nil {
+		return fmt.Errorf("blob backend: %w", err)
+	}
+	return s.update(func(txn *countedTxn) error {
+		if err := txn.Set([]byte("blob:"+cid), []byte(strconv.Itoa(len(data)))); err != nil {
+			return err
+		}
+		return txn.SetEntry(badger.NewEntry([]byte("content:"+cid), data).WithTTL(cacheTTL))
+	})
+}
+
+// fetchBlob reads a blob recorded by a marker from the backend and caches it
+// locally. It returns nil data when no marker exists.
+func (s *Store) fetchBlob(cid string) ([]byte, error) {
+	b, _, cacheTTL := s.blobBackend()
+	if b == nil || !s.hasBlobMarker(cid) {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), BlobTimeout)
+	defer cancel()
+	data, err := b.Get(ctx, cid)
+	if err != nil {
+		return nil, fmt.Errorf("blob backend: %w", err)
+	}
+	if core.CIDForContent(data) != cid {
+		return nil, fmt.Errorf("blob backend returned data not matching %s", cid)
+	}
+
+	if s.readOnly {
+		return data, nil
+	}
+	err = s.update(func(txn *countedTxn) error {
+		return txn.SetEntry(badger.NewEntry([]byte("content:"+cid), data).WithTTL(cacheTTL))
+	})
+	if err != nil {
+		s.logger.Warn("failed to cache blob locally", zap.String("cid", cid), zap.Error(err))
+	}
+	return data, nil
+}
+
+func (s *Store) hasBlobMarker(cid string) bool {
+	err := s.db.View(func(txn kvTxn) error {
+		_, err := txn.Get([]byte("blob:" + cid))
+		return err
+	})
+	return err == nil
+}
+
+// deleteBlob removes the marker and the remote copy, if any.
+func (s *Store) deleteBlob(cid string) error {
+	if !s.hasBlobMarker(cid) {
+		return nil
+	}
+	if b, _, _ := s.blobBackend(); b != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), BlobTimeout)
+		defer cancel()
+		if err := b.Delete(ctx, cid); err != nil && !errors.Is(err, ErrBlobNotFound) {
+			return fmt.Errorf("blob backend: %w", err)
+		}
+	}
+	return s.db.Update(func(txn kvTxn) error {
+		return txn.Delete([]byte("blob:" + cid))
+	})
+}
@@ -0,0 +1,132 @@
+package store
+
+import (
+	"container/list"
+	"sync"
+)
+
+// DefaultContentCacheSize is the default memory budget of the content cache.
+const DefaultContentCacheSize = 64 * 1024 * 1024 // 64MB
+
+// ContentCacheStats reports the state of the in-memory content cache.
+type ContentCacheStats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Entries   int    `json:"entries"`
+	Bytes     int64  `json:"bytes"`
+	MaxBytes  int64  `json:"max_bytes"`
+}
+
+type cacheItem struct {
+	cid  string
+	data []byte
+}
+
+// contentCache is a byte-bounded LRU of content blobs, keyed by CID. Since
+// content is addressed by its hash an entry can never go stale; it only
+// needs dropping when the blob is deleted.
+type contentCache struct {
+	mu       sync.Mutex
+	maxBytes int64
+	used     int64
+	ll       *list.List
+	items    map[string]*list.Element
+	stats    ContentCacheStats
+}
+
+func newContentCache(maxBytes int64) *contentCache {
+	return &contentCache{
+		maxBytes: maxBytes,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// get returns a copy of the cached blob.
+func (c *contentCache) get(cid string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[cid]
+	if !ok {
+		c.stats.Misses++
+		return nil, false
+	}
+	c.stats.Hits++
+	c.ll.MoveToFront(el)
+	return append([]byte{}, el.Value.(*cacheItem).data...), true
+}
+
+// add stores a copy of data. Blobs larger than an eighth of the budget are
+// not cached so that one large file cannot flush a whole site.
+func (c *contentCache) add(cid string, data []byte) {
+	size := int64(len(data))
+	if size == 0 || size > c.maxBytes/8 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[cid]; ok {
+		c.ll.MoveToFront(el)
+		return
+	}
+	c.items[cid] = c.ll.PushFront(&cacheItem{cid: cid, data: append([]byte{}, data...)})
+	c.used += size
+	for c.used > c.maxBytes {
+		c.removeElement(c.ll.Back())
+		c.stats.Evictions++
+	}
+}
+
+func (c *contentCache) remove(cid string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[cid]; ok {
+		c.removeElement(el)
+	}
+}
+
+func (c *contentCache) removeElement(el *list.Element) {
+	item := c.ll.Remove(el).(*cacheItem)
+	delete(c.items, item.cid)
+	c.used -= int64(len(item.data))
+}
+
+func (c *contentCache) snapshot() ContentCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st := c.stats
+	st.Entries = c.ll.Len()
+	st.Bytes = c.used
+	st.MaxBytes = c.maxBytes
+	return st
+}
+
+// SetContentCacheSize replaces the content cache with one of maxBytes.
+// Zero or a negative size disables caching.
+func (s *Store) SetContentCacheSize(maxBytes int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if maxBytes <= 0 {
+		s.cache = nil
+		return
+	}
+	s.cache = newContentCache(maxBytes)
+}
+
+// ContentCacheStats returns hit/miss counters and usage of the content
+// cache, or nil when caching is disabled.
+func (s *Store) ContentCacheStats() *ContentCacheStats {
+	c := s.contentCache()
+	if c == nil {
+		return nil
+	}
+	st := c.snapshot()
+	return &st
+}
+
+func (s *Store) contentCache() *contentCache {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cache
+}
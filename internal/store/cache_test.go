@@ -0,0 +1,78 @@
+package store
+
+import (
+	"bytes"
+	"testing"
+
+	"alxnet/internal/core"
+)
+
+func TestContentCacheLRU(t *testing.T) {
+	c := newContentCache(80) // blobs up to 10 bytes are cached
+	blob := func(b byte) []byte { return bytes.Repeat([]byte{b}, 10) }
+
+	for i := byte(0); i < 8; i++ {
+		c.add(string('a'+i), blob(i))
+	}
+	if _, ok := c.get("a"); !ok { // "a" becomes most recently used
+		t.Fatal("get(a) missed before the cache was full")
+	}
+	c.add("i", blob(8))
+
+	if _, ok := c.get("b"); ok {
+		t.Error("least recently used entry was not evicted")
+	}
+	if _, ok := c.get("a"); !ok {
+		t.Error("recently used entry was evicted")
+	}
+
+	c.add("big", bytes.Repeat([]byte{1}, 11))
+	if _, ok := c.get("big"); ok {
+		t.Error("blob above an eighth of the budget was cached")
+	}
+
+	got, _ := c.get("a")
+	got[0] = 0xff
+	if again, _ := c.get("a"); again[0] == 0xff {
+		t.Error("get() returned the cached slice instead of a copy")
+	}
+
+	st := c.snapshot()
+	if st.Entries != 8 || st.Bytes != 80 || st.Evictions != 1 {
+		t.Errorf("stats = %+v, want 8 entries, 80 bytes, 1 eviction", st)
+	}
+	if st.Hits != 4 || st.Misses != 2 {
+		t.Errorf("hits/misses = %d/%d, want 4/2", st.Hits, st.Misses)
+	}
+}
+
+func TestGetContentUsesCache(t *testing.T) {
+	s := openTestStore(t)
+	data := []byte("cached page")
+	cid := core.CIDForContent(data)
+	if err := s.PutContent(cid, data); err != nil {
+		t.Fatalf("PutContent: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if got, err := s.GetContent(cid); err != nil || !bytes.Equal(got, data) {
+			t.Fatalf("GetContent() = %q, %v", got, err)
+		}
+	}
+	st := s.ContentCacheStats()
+	if st == nil || st.Misses != 1 || st.Hits != 2 {
+		t.Fatalf("cache stats = %+v, want 1 miss then 2 hits", st)
+	}
+
+	if err := s.DeleteContent(cid); err != nil {
+		t.Fatalf("DeleteContent: %v", err)
+	}
+	if got, _ := s.GetContent(cid); got != nil {
+		t.Errorf("GetContent() after delete = %q, want nil", got)
+	}
+
+	s.SetContentCacheSize(0)
+	if s.ContentCacheStats() != nil {
+		t.Error("ContentCacheStats() should be nil when caching is disabled")
+	}
+}
@@ -0,0 +1,432 @@
+package store
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"alxnet/internal/core"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/ipfs/go-cid"
+	mh "github.com/multiformats/go-multihash"
+	"github.com/multiformats/go-varint"
+)
+
+// CAR (IPFS content archive, v1) support. AlxNet CIDs are hex SHA-256
+// digests of the stored bytes, so every block maps one-to-one to a CIDv1
+// with the raw codec and a sha2-256 multihash. Exported archives have a
+// single DAG-CBOR root block that links every block of the site and says
+// what each one is, so that `ipfs dag import` pins the whole site and an
+// AlxNet import can put each block back under the right key.
+
+// CAR limits
+const (
+	MaxCARBlocks     = 10 * core.MaxFileCount
+	maxCARHeader     = 4 * 1024
+	maxCARBlockSize  = core.MaxContentSize + 128 // block payload plus CID
+	carFormatVersion = 1
+	cidTagNumber     = 42
+)
+
+// carIndexDecMode allows as many map entries as an index can legitimately
+// link.
+var carIndexDecMode, _ = cbor.DecOptions{MaxMapPairs: 2 * MaxCARBlocks}.DecMode()
+
+// SiteExport describes which block plays which part in a site archive. All
+// values are AlxNet (hex) CIDs.
+type SiteExport struct {
+	SiteID      string            `json:"site_id"`
+	Head        string            `json:"head,omitempty"`         // UpdateRecord
+	Manifest    string            `json:"manifest,omitempty"`     // WebsiteManifest
+	FileRecords map[string]string `json:"file_records,omitempty"` // path -> FileRecord
+	Files       map[string]string `json:"files,omitempty"`        // path -> content
+}
+
+// CARImport reports the outcome of ImportCAR.
+type CARImport struct {
+	Site    *SiteExport       `json:"site,omitempty"` // nil for archives not made by AlxNet
+	Blocks  int               `json:"blocks"`
+	Skipped int               `json:"skipped"` // blocks that are not raw sha2-256
+	CIDs    map[string]string `json:"cids"`    // IPFS CID -> AlxNet CID
+}
+
+// carLink is an IPLD link, encoded as CBOR tag 42 over 0x00 + binary CID.
+type carLink struct{ cid.Cid }
+
+func (l carLink) MarshalCBOR() ([]byte, error) {
+	return cbor.Marshal(cbor.Tag{Number: cidTagNumber, Content: append([]byte{0}, l.Bytes()...)})
+}
+
+func (l *carLink) UnmarshalCBOR(b []byte) error {
+	var tag cbor.Tag
+	if err := cbor.Unmarshal(b, &tag); err != nil {
+		return err
+	}
+	raw, ok := tag.Content.([]byte)
+	if tag.Number != cidTagNumber || !ok || len(raw) < 2 || raw[0] != 0 {
+		return errors.New("malformed IPLD link")
+	}
+	c, err := cid.Cast(raw[1:])
+	if err != nil {
+		return err
+	}
+	l.Cid = c
+	return nil
+}
+
+type carHeader struct {
+	Roots   []carLink `cbor:"roots"`
+	Version uint64    `cbor:"version"`
+}
+
+// carIndex is the DAG-CBOR root block of an exported site.
+type carIndex struct {
+	Format      int                `cbor:"alxnet"`
+	SiteID      string             `cbor:"site"`
+	Head        *carLink           `cbor:"head,omitempty"`
+	Manifest    *carLink           `cbor:"manifest,omitempty"`
+	FileRecords map[string]carLink `cbor:"filerecords,omitempty"`
+	Files       map[string]carLink `cbor:"files,omitempty"`
+}
+
+// IPFSCID converts an AlxNet CID to the equivalent CIDv1 (raw, sha2-256).
+func IPFSCID(alxCID string) (cid.Cid, error) {
+	digest, err := hex.DecodeString(alxCID)
+	if err != nil || len(digest) != 32 {
+		return cid.Undef, fmt.Errorf("invalid CID: %q", alxCID)
+	}
+	m, err := mh.Encode(digest, mh.SHA2_256)
+	if err != nil {
+		return cid.Undef, err
+	}
+	return cid.NewCidV1(cid.Raw, m), nil
+}
+
+// AlxCID converts a sha2-256 IPFS CID to an AlxNet CID.
+func AlxCID(c cid.Cid) (string, error) {
+	decoded, err := mh.Decode(c.Hash())
+	if err != nil {
+		return "", err
+	}
+	if decoded.Code != mh.SHA2_256 || decoded.Length != 32 {
+		return "", fmt.Errorf("unsupported multihash %s", mh.Codes[decoded.Code])
+	}
+	return hex.EncodeToString(decoded.Digest), nil
+}
+
+// ExportSiteCAR writes the current head record, manifest, file records and
+// content of a site to w as a CARv1 archive. Every referenced block must be
+// stored locally.
+func (s *Store) ExportSiteCAR(w io.Writer, siteID string) (*SiteExport, error) {
+	exp := &SiteExport{
+		SiteID:      siteID,
+		FileRecords: make(map[string]string),
+		Files:       make(map[string]string),
+	}
+	blocks := make(map[string][]byte)
+	if has, _ := s.HasHead(siteID); has {
+		_, headCID, err := s.GetHead(siteID)
+		if err != nil {
+			return nil, err
+		}
+		data, err := s.GetRecord(headCID)
+		if err != nil {
+			return nil, fmt.Errorf("head record %s: %w", headCID, err)
+		}
+		var rec core.UpdateRecord
+		if err := core.UnmarshalCBOR(data, &rec); err != nil {
+			return nil, fmt.Errorf("head record %s: %w", headCID, err)
+		}
+		exp.Head = headCID
+		blocks[headCID] = data
+		if !s.HasWebsiteManifest(siteID) {
+			exp.Files["index.html"] = rec.ContentCID
+		}
+	}
+
+	if s.HasWebsiteManifest(siteID) {
+		data, err := s.GetCurrentWebsiteManifest(siteID)
+		if err != nil {
+			return nil, err
+		}
+		var manifest core.WebsiteManifest
+		if err := core.UnmarshalCBOR(data, &manifest); err != nil {
+			return nil, fmt.Errorf("manifest: %w", err)
+		}
+		exp.Manifest = core.CIDForBytes(data)
+		blocks[exp.Manifest] = data
+		for path, contentCID := range manifest.Files {
+			exp.Files[path] = contentCID
+		}
+
+		records, err := s.ListWebsiteFiles(siteID)
+		if err != nil {
+			return nil, err
+		}
+		for path, recordCID := range records {
+			data, err := s.GetFileRecord(recordCID)
+			if err != nil {
+				return nil, fmt.Errorf("file record for %s: %w", path, err)
+			}
+			exp.FileRecords[path] = recordCID
+			blocks[recordCID] = data
+		}
+	}
+
+	if exp.Head == "" && exp.Manifest == "" {
+		return nil, errors.New("site not found")
+	}
+	for path, contentCID := range exp.Files {
+		// GetContent reports a missing blob as nil data, not an error
+		data, err := s.GetContent(contentCID)
+		if err != nil || data == nil {
+			return nil, fmt.Errorf("content of %s (%s) is not stored locally", path, contentCID)
+		}
+		blocks[contentCID] = data
+	}
+
+	index := carIndex{Format: carFormatVersion, SiteID: siteID}
+	link := func(alxCID string) (carLink, error) {
+		c, err := IPFSCID(alxCID)
+		return carLink{c}, err
+	}
+	if exp.Head != "" {
+		l, err := link(exp.Head)
+		if err != nil {
+			return nil, err
+		}
+		index.Head = &l
+	}
+	if exp.Manifest != "" {
+		l, err := link(exp.Manifest)
+		if err != nil {
+			return nil, err
+		}
+		index.Manifest = &l
+	}
+	for _, m := range []struct {
+		src map[string]string
+		dst *map[string]carLink
+	}{{exp.FileRecords, &index.FileRecords}, {exp.Files, &index.Files}} {
+		if len(m.src) == 0 {
+			continue
+		}
+		*m.dst = make(map[string]carLink, len(m.src))
+		for path, alxCID := range m.src {
+			l, err := link(alxCID)
+			if err != nil {
+				return nil, err
+			}
+			(*m.dst)[path] = l
+		}
+	}
+
+	indexBytes, err := core.MarshalCanonical(index)
+	if err != nil {
+		return nil, err
+	}
+	indexHash, err := mh.Sum(indexBytes, mh.SHA2_256, -1)
+	if err != nil {
+		return nil, err
+	}
+	root := cid.NewCidV1(cid.DagCBOR, indexHash)
+
+	header, err := core.MarshalCanonical(carHeader{Roots: []carLink{{root}}, Version: 1})
+	if err != nil {
+		return nil, err
+	}
+	bw := bufio.NewWriter(w)
+	if err := writeCARSection(bw, header); err != nil {
+		return nil, err
+	}
+	if err := writeCARSection(bw, root.Bytes(), indexBytes); err != nil {
+		return nil, err
+	}
+
+	// Deterministic block order keeps archives of the same site identical
+	ids := make([]string, 0, len(blocks))
+	for id := range blocks {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		c, err := IPFSCID(id)
+		if err != nil {
+			return nil, err
+		}
+		if err := writeCARSection(bw, c.Bytes(), blocks[id]); err != nil {
+			return nil, err
+		}
+	}
+	return exp, bw.Flush()
+}
+
+// ImportCAR stores the blocks of a CARv1 archive. Every block is checked
+// against its CID. Archives exported by AlxNet put head records, manifests
+// and file records back under their own keys; for any other archive raw
+// sha2-256 blocks are stored as content and the rest are skipped.
+//
+// ImportCAR only stores blocks by CID. It does not move a site's head or
+// manifest pointer; callers decide whether to apply the returned SiteExport.
+func (s *Store) ImportCAR(r io.Reader) (*CARImport, error) {
+	br := bufio.NewReader(r)
+	header, err := readCARSection(br, maxCARHeader)
+	if err != nil {
+		return nil, fmt.Errorf("CAR header: %w", err)
+	}
+	var hdr carHeader
+	if err := cbor.Unmarshal(header, &hdr); err != nil {
+		return nil, fmt.Errorf("CAR header: %w", err)
+	}
+	if hdr.Version != 1 {
+		return nil, fmt.Errorf("unsupported CAR version %d", hdr.Version)
+	}
+
+	res := &CARImport{CIDs: make(map[string]string)}
+	kinds := make(map[string]string) // AlxNet CID -> key prefix, from the index
+	for {
+		section, err := readCARSection(br, maxCARBlockSize)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return res, err
+		}
+		if res.Blocks+res.Skipped >= MaxCARBlocks {
+			return res, fmt.Errorf("archive has more than %d blocks", MaxCARBlocks)
+		}
+		n, c, err := cid.CidFromBytes(section)
+		if err != nil {
+			return res, fmt.Errorf("block CID: %w", err)
+		}
+		data := section[n:]
+		sum, err := c.Prefix().Sum(data)
+		if err != nil {
+			res.Skipped++ // hash function we cannot verify
+			continue
+		}
+		if !sum.Equals(c) {
+			return res, fmt.Errorf("block %s does not match its CID", c)
+		}
+
+		// The index is the first block when the archive came from AlxNet
+		if res.Site == nil && res.Blocks == 0 && c.Type() == cid.DagCBOR && len(hdr.Roots) == 1 && hdr.Roots[0].Equals(c) {
+			if site, err := parseCARIndex(data, kinds); err == nil {
+				res.Site = site
+				continue
+			}
+		}
+
+		alxCID, err := AlxCID(c)
+		if err != nil || c.Type() != cid.Raw {
+			res.Skipped++
+			continue
+		}
+		prefix, ok := kinds[alxCID]
+		if !ok {
+			prefix = "content:"
+		}
+		if err := s.update(func(txn *countedTxn) error {
+			if err := txn.Set([]byte(prefix+alxCID), data); err != nil {
+				return err
+			}
+			if prefix == "record:" {
+				return indexRecord(txn, alxCID, data)
+			}
+			return nil
+		}); err != nil {
+			return res, err
+		}
+		res.CIDs[c.String()] = alxCID
+		res.Blocks++
+	}
+	return res, nil
+}
+
+// parseCARIndex decodes an AlxNet root block and records the key prefix
+// each linked block belongs under.
+func parseCARIndex(data []byte, kinds map[string]string) (*SiteExport, error) {
+	var index carIndex
+	if err := carIndexDecMode.Unmarshal(data, &index); err != nil {
+		return nil, err
+	}
+	if index.Format != carFormatVersion || !isValidHexString(index.SiteID) || len(index.SiteID) != 64 {
+		return nil, errors.New("not an AlxNet site index")
+	}
+
+	site := &SiteExport{
+		SiteID:      index.SiteID,
+		FileRecords: make(map[string]string),
+		Files:       make(map[string]string),
+	}
+	var err error
+	resolve := func(l carLink, prefix string) string {
+		id, e := AlxCID(l.Cid)
+		if e != nil {
+			err = e
+			return ""
+		}
+		kinds[id] = prefix
+		return id
+	}
+	for path, l := range index.Files {
+		site.Files[path] = resolve(l, "content:")
+	}
+	for path, l := range index.FileRecords {
+		site.FileRecords[path] = resolve(l, "filerecord:")
+	}
+	if index.Manifest != nil {
+		site.Manifest = resolve(*index.Manifest, "manifest:")
+	}
+	if index.Head != nil {
+		site.Head = resolve(*index.Head, "record:")
+	}
+	if err != nil {
+		for k := range kinds {
+			delete(kinds, k)
+		}
+		return nil, err
+	}
+	return site, nil
+}
+
+// writeCARSection writes a varint length prefix followed by the parts.
+func writeCARSection(w io.Writer, parts ...[]byte) error {
+	size := 0
+	for _, p := range parts {
+		size += len(p)
+	}
+	if _, err := w.Write(varint.ToUvarint(uint64(size))); err != nil {
+		return err
+	}
+	for _, p := range parts {
+		if _, err := w.Write(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readCARSection reads one length-prefixed section of at most limit bytes.
+// It returns io.EOF only at a clean section boundary.
+func readCARSection(r *bufio.Reader, limit int) ([]byte, error) {
+	if _, err := r.Peek(1); err == io.EOF {
+		return nil, io.EOF
+	}
+	size, err := varint.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if size == 0 || size > uint64(limit) {
+		return nil, fmt.Errorf("section size %d out of range", size)
+	}
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return buf, nil
+}
@@ -0,0 +1,217 @@
+package store
+
+import (
+	"bytes"
+	"testing"
+
+	"alxnet/internal/core"
+
+	"github.com/ipfs/go-cid"
+	mh "github.com/multiformats/go-multihash"
+)
+
+// putTestWebsite stores a two-file website with a manifest, file records and
+// a head record, and returns the manifest.
+func putTestWebsite(t *testing.T, s *Store, siteID string) *core.WebsiteManifest {
+	t.Helper()
+	files := map[string][]byte{
+		"index.html":    []byte("<h1>hello</h1>"),
+		"css/style.css": []byte("h1 { color: red }"),
+	}
+	manifest := &core.WebsiteManifest{Version: "1.0", Seq: 1, TS: 1, MainFile: "index.html", Files: map[string]string{}}
+	for path, data := range files {
+		contentCID := core.CIDForContent(data)
+		manifest.Files[path] = contentCID
+		if err := s.PutContent(contentCID, data); err != nil {
+			t.Fatalf("PutContent: %v", err)
+		}
+		rec, err := core.CanonicalMarshalFileRecord(&core.FileRecord{Version: "1.0", Path: path, ContentCID: contentCID, TS: 1})
+		if err != nil {
+			t.Fatalf("CanonicalMarshalFileRecord: %v", err)
+		}
+		if err := s.PutFileRecord(siteID, path, core.CIDForBytes(rec), rec); err != nil {
+			t.Fatalf("PutFileRecord: %v", err)
+		}
+	}
+	manifestData, err := core.CanonicalMarshalWebsiteManifest(manifest)
+	if err != nil {
+		t.Fatalf("CanonicalMarshalWebsiteManifest: %v", err)
+	}
+	if err := s.PutWebsiteManifest(siteID, core.CIDForBytes(manifestData), manifestData); err != nil {
+		t.Fatalf("PutWebsiteManifest: %v", err)
+	}
+
+	head, err := core.CanonicalMarshal(&core.UpdateRecord{Version: core.RecordV1, Seq: 1, ContentCID: manifest.Files["index.html"], TS: 1})
+	if err != nil {
+		t.Fatalf("CanonicalMarshal: %v", err)
+	}
+	headCID := core.CIDForBytes(head)
+	if err := s.PutRecord(headCID, head); err != nil {
+		t.Fatalf("PutRecord: %v", err)
+	}
+	if err := s.PutHead(siteID, 1, headCID); err != nil {
+		t.Fatalf("PutHead: %v", err)
+	}
+	return manifest
+}
+
+func TestCARRoundTrip(t *testing.T) {
+	src := openTestStore(t)
+	dst := openTestStore(t)
+	const siteID = "aa00000000000000000000000000000000000000000000000000000000000000"
+	manifest := putTestWebsite(t, src, siteID)
+
+	var buf bytes.Buffer
+	exp, err := src.ExportSiteCAR(&buf, siteID)
+	if err != nil {
+		t.Fatalf("ExportSiteCAR() error = %v", err)
+	}
+	var again bytes.Buffer
+	if _, err := src.ExportSiteCAR(&again, siteID); err != nil || !bytes.Equal(buf.Bytes(), again.Bytes()) {
+		t.Errorf("exports of the same site differ (err %v)", err)
+	}
+
+	res, err := dst.ImportCAR(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("ImportCAR() error = %v", err)
+	}
+	if res.Site == nil || res.Site.SiteID != siteID || res.Site.Head != exp.Head || res.Site.Manifest != exp.Manifest {
+		t.Fatalf("imported index = %+v, want %+v", res.Site, exp)
+	}
+	// head + manifest + 2 file records + 2 files
+	if res.Blocks != 6 || res.Skipped != 0 {
+		t.Errorf("blocks/skipped = %d/%d, want 6/0", res.Blocks, res.Skipped)
+	}
+
+	if _, err := dst.GetRecord(exp.Head); err != nil {
+		t.Errorf("head record not imported as a record: %v", err)
+	}
+	if _, err := dst.GetWebsiteManifest(exp.Manifest); err != nil {
+		t.Errorf("manifest not imported as a manifest: %v", err)
+	}
+	for path, recordCID := range exp.FileRecords {
+		if _, err := dst.GetFileRecord(recordCID); err != nil {
+			t.Errorf("file record for %s not imported: %v", path, err)
+		}
+	}
+	for path, contentCID := range manifest.Files {
+		if has, _ := dst.HasContent(contentCID); !has {
+			t.Errorf("content of %s not imported", path)
+		}
+		ipfs, err := IPFSCID(contentCID)
+		if err != nil {
+			t.Fatalf("IPFSCID: %v", err)
+		}
+		if res.CIDs[ipfs.String()] != contentCID {
+			t.Errorf("CID mapping for %s = %q, want %q", ipfs, res.CIDs[ipfs.String()], contentCID)
+		}
+	}
+	// Import only stores blocks; it must not move the site's pointers
+	if has, _ := dst.HasHead(siteID); has || dst.HasWebsiteManifest(siteID) {
+		t.Error("ImportCAR() changed site pointers")
+	}
+}
+
+func TestExportSiteCARMissingContent(t *testing.T) {
+	s := openTestStore(t)
+	const siteID = "aa00000000000000000000000000000000000000000000000000000000000000"
+	manifest := putTestWebsite(t, s, siteID)
+	if err := s.DeleteContent(manifest.Files["css/style.css"]); err != nil {
+		t.Fatalf("DeleteContent: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := s.ExportSiteCAR(&buf, siteID); err == nil {
+		t.Error("ExportSiteCAR() should fail when a file is not stored locally")
+	}
+	if _, err := s.ExportSiteCAR(&buf, "bb00000000000000000000000000000000000000000000000000000000000000"); err == nil {
+		t.Error("ExportSiteCAR() of an unknown site should fail")
+	}
+}
+
+func TestImportCARRejectsTampering(t *testing.T) {
+	src := openTestStore(t)
+	const siteID = "aa00000000000000000000000000000000000000000000000000000000000000"
+	putTestWebsite(t, src, siteID)
+
+	var buf bytes.Buffer
+	if _, err := src.ExportSiteCAR(&buf, siteID); err != nil {
+		t.Fatalf("ExportSiteCAR() error = %v", err)
+	}
+	data := buf.Bytes()
+
+	tests := []struct {
+		name string
+		car  []byte
+	}{
+		{"flipped byte in last block", append(append([]byte{}, data[:len(data)-1]...), data[len(data)-1]^0xff)},
+		{"truncated", data[:len(data)-3]},
+		{"oversized section", []byte{0xff, 0xff, 0xff, 0xff, 0x0f}},
+		{"empty", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := openTestStore(t).ImportCAR(bytes.NewReader(tt.car)); err == nil {
+				t.Error("ImportCAR() accepted a corrupt archive")
+			}
+		})
+	}
+}
+
+func TestImportForeignCAR(t *testing.T) {
+	s := openTestStore(t)
+	page := []byte("hello from IPFS")
+
+	rawHash, _ := mh.Sum(page, mh.SHA2_256, -1)
+	raw := cid.NewCidV1(cid.Raw, rawHash)
+	blakeHash, _ := mh.Sum([]byte("other"), mh.BLAKE2B_MIN+31, -1)
+	blake := cid.NewCidV1(cid.Raw, blakeHash)
+
+	var buf bytes.Buffer
+	header := []byte{0xa2, 0x65, 'r', 'o', 'o', 't', 's', 0x80, 0x67, 'v', 'e', 'r', 's', 'i', 'o', 'n', 0x01}
+	for _, section := range [][][]byte{{header}, {raw.Bytes(), page}, {blake.Bytes(), []byte("other")}} {
+		if err := writeCARSection(&buf, section...); err != nil {
+			t.Fatalf("writeCARSection: %v", err)
+		}
+	}
+
+	res, err := s.ImportCAR(&buf)
+	if err != nil {
+		t.Fatalf("ImportCAR() error = %v", err)
+	}
+	if res.Site != nil {
+		t.Error("foreign archive treated as an AlxNet site")
+	}
+	if res.Blocks != 1 || res.Skipped != 1 {
+		t.Errorf("blocks/skipped = %d/%d, want 1/1", res.Blocks, res.Skipped)
+	}
+	got, err := s.GetContent(core.CIDForContent(page))
+	if err != nil || !bytes.Equal(got, page) {
+		t.Errorf("GetContent() = %q, %v; want imported page", got, err)
+	}
+}
+
+func TestIPFSCIDMapping(t *testing.T) {
+	alx := core.CIDForContent([]byte("hello"))
+	c, err := IPFSCID(alx)
+	if err != nil {
+		t.Fatalf("IPFSCID() error = %v", err)
+	}
+	if c.Version() != 1 || c.Type() != cid.Raw {
+		t.Errorf("IPFSCID() = %s, want CIDv1 raw", c)
+	}
+	back, err := AlxCID(c)
+	if err != nil || back != alx {
+		t.Errorf("AlxCID() = %q, %v; want %q", back, err, alx)
+	}
+
+	for _, bad := range []string{"", "zz", alx[:62]} {
+		if _, err := IPFSCID(bad); err == nil {
+			t.Errorf("IPFSCID(%q) should fail", bad)
+		}
+	}
+	other, _ := mh.Sum([]byte("hello"), mh.SHA2_512, -1)
+	if _, err := AlxCID(cid.NewCidV1(cid.Raw, other)); err == nil {
+		t.Error("AlxCID() accepted a sha2-512 CID")
+	}
+}
@@ -0,0 +1,190 @@
+package store
+
+import (
+	"fmt"
+
+	"alxnet/internal/core"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// MaxCommentsPerSite bounds the comments kept for one site; the oldest are
+// dropped to make room.
+const MaxCommentsPerSite = 5000
+
+// CommentEntry is a stored comment and its moderation state.
+type CommentEntry struct {
+	CID     string             `json:"cid"`
+	Comment core.CommentRecord `json:"comment"`
+	Hidden  bool               `json:"hidden"`
+}
+
+// Comments are keyed by site and timestamp so that listing a site walks them
+// in order; commentcid: maps a CID back to that key.
+func commentKey(siteID string, ts int64, cid string) string {
+	return fmt.Sprintf("comment:%s:%016x:%s", siteID, uint64(ts), cid)
+}
+
+// PutComment stores a verified comment. It reports false if the comment was
+// already stored.
+func (s *Store) PutComment(cid string, rec *core.CommentRecord, data []byte) (bool, error) {
+	if err := s.validateKeyValue(cid, data); err != nil {
+		return false, fmt.Errorf("validation failed: %w", err)
+	}
+
+	s.commentMu.Lock()
+	defer s.commentMu.Unlock()
+
+	added := false
+	err := s.db.Update(func(txn kvTxn) error {
+		if _, err := txn.Get([]byte("commentcid:" + cid)); err == nil {
+			return nil
+		} else if err != badger.ErrKeyNotFound {
+			return err
+		}
+
+		// Make room by dropping the site's oldest comments
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		prefix := []byte("comment:" + rec.SiteID + ":")
+		var keys [][]byte
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			keys = append(keys, it.Item().KeyCopy(nil))
+		}
+		it.Close()
+		for len(keys) >= MaxCommentsPerSite {
+			oldest := string(keys[0])
+			oldCID := oldest[len(oldest)-64:]
+			for _, k := range []string{oldest, "commentcid:" + oldCID, "moderation:" + oldCID} {
+				if err := txn.Delete([]byte(k)); err != nil {
+					return err
+				}
+			}
+			keys = keys[1:]
+		}
+
+		key := commentKey(rec.SiteID, rec.TS, cid)
+		if err := txn.Set([]byte(key), data); err != nil {
+			return err
+		}
+		added = true
+		return txn.Set([]byte("commentcid:"+cid), []byte(key))
+	})
+	return added, err
+}
+
+// GetComment returns the comment with the given CID, or nil if it is not
+// stored.
+func (s *Store) GetComment(cid string) (*core.CommentRecord, error) {
+	if err := s.validateKey(cid); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+	var rec *core.CommentRecord
+	err := s.db.View(func(txn kvTxn) error {
+		idx, err := txn.Get([]byte("commentcid:" + cid))
+		if err == badger.ErrKeyNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		key, err := idx.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		it, err := txn.Get(key)
+		if err != nil {
+			return err
+		}
+		return it.Value(func(v []byte) error {
+			rec = &core.CommentRecord{}
+			return core.UnmarshalCBOR(v, rec)
+		})
+	})
+	return rec, err
+}
+
+// ListComments returns up to limit comments of siteID, newest first. Hidden
+// comments are skipped unless includeHidden is set.
+func (s *Store) ListComments(siteID string, limit int, includeHidden bool) ([]CommentEntry, error) {
+	if err := s.validateKey(siteID); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+	var out []CommentEntry
+	err := s.db.View(func(txn kvTxn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Reverse = true
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		prefix := []byte("comment:" + siteID + ":")
+		for it.Seek(append(prefix, 0xff)); it.ValidForPrefix(prefix) && len(out) < limit; it.Next() {
+			key := string(it.Item().Key())
+			e := CommentEntry{CID: key[len(key)-64:]}
+			if err := it.Item().Value(func(v []byte) error {
+				return core.UnmarshalCBOR(v, &e.Comment)
+			}); err != nil {
+				return err
+			}
+			hidden, err := moderationHidden(txn, e.CID)
+			if err != nil {
+				return err
+			}
+			if hidden && !includeHidden {
+				continue
+			}
+			e.Hidden = hidden
+			out = append(out, e)
+		}
+		return nil
+	})
+	return out, err
+}
+
+func moderationHidden(txn kvTxn, cid string) (bool, error) {
+	it, err := txn.Get([]byte("moderation:" + cid))
+	if err == badger.ErrKeyNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	var mod core.ModerationRecord
+	err = it.Value(func(v []byte) error {
+		return core.UnmarshalCBOR(v, &mod)
+	})
+	return mod.Hidden, err
+}
+
+// PutModeration stores a verified moderation record unless a newer one for
+// the same comment is already stored; it reports whether it was applied.
+func (s *Store) PutModeration(rec *core.ModerationRecord, data []byte) (bool, error) {
+	if err := s.validateKeyValue(rec.CommentCID, data); err != nil {
+		return false, fmt.Errorf("validation failed: %w", err)
+	}
+
+	s.commentMu.Lock()
+	defer s.commentMu.Unlock()
+
+	applied := false
+	err := s.db.Update(func(txn kvTxn) error {
+		key := []byte("moderation:" + rec.CommentCID)
+		if it, err := txn.Get(key); err == nil {
+			var cur core.ModerationRecord
+			if err := it.Value(func(v []byte) error {
+				return core.UnmarshalCBOR(v, &cur)
+			}); err != nil {
+				return err
+			}
+			if cur.TS >= rec.TS {
+				return nil
+			}
+		} else if err != badger.ErrKeyNotFound {
+			return err
+		}
+		applied = true
+		return txn.Set(key, data)
+	})
+	return applied, err
+}
@@ -0,0 +1,60 @@
+package store
+
+import (
+	"fmt"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Content entries written with compression carry metaZstd in Badger's
+// per-entry user metadata; entries without it are stored as-is, so
+// compressed and uncompressed content can live side by side.
+const metaZstd byte = 1 << 0
+
+// MinCompressSize is the smallest content worth compressing.
+const MinCompressSize = 512
+
+// EncodeAll and DecodeAll are safe for concurrent use on shared instances.
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+	zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderConcurrency(0), zstd.WithDecoderMaxMemory(MaxValueLength))
+)
+
+// SetCompression turns zstd compression of newly written content on or off.
+// Existing entries are read correctly either way.
+func (s *Store) SetCompression(enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.compress = enabled
+}
+
+func (s *Store) compressionEnabled() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.compress
+}
+
+// compressValue returns the bytes to store and their metadata flag. Data
+// that does not shrink by at least 10% is stored uncompressed.
+func compressValue(data []byte) ([]byte, byte) {
+	if len(data) < MinCompressSize {
+		return data, 0
+	}
+	c := zstdEncoder.EncodeAll(data, make([]byte, 0, len(data)/2))
+	if len(c) > len(data)*9/10 {
+		return data, 0
+	}
+	return c, metaZstd
+}
+
+// decodeValue reverses compressValue. The result never aliases v.
+func decodeValue(v []byte, meta byte) ([]byte, error) {
+	if meta&metaZstd == 0 {
+		return append([]byte{}, v...), nil
+	}
+	out, err := zstdDecoder.DecodeAll(v, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decompress content: %w", err)
+	}
+	return out, nil
+}
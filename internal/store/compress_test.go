@@ -0,0 +1,73 @@
+package store
+
+import (
+	"bytes"
+	"crypto/rand"
+	"strings"
+	"testing"
+
+	"alxnet/internal/core"
+)
+
+func TestContentCompression(t *testing.T) {
+	s := openTestStore(t)
+	s.SetContentCacheSize(0) // read back through Badger
+	s.SetCompression(true)
+
+	random := make([]byte, 4096)
+	if _, err := rand.Read(random); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name           string
+		data           []byte
+		wantCompressed bool
+	}{
+		{"text page", []byte(strings.Repeat("<p>hello alxnet</p>\n", 200)), true},
+		{"small page", []byte("<p>hi</p>"), false},
+		{"incompressible", random, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cid := core.CIDForContent(tt.data)
+			if err := s.PutContent(cid, tt.data); err != nil {
+				t.Fatalf("PutContent: %v", err)
+			}
+
+			var meta byte
+			var stored int
+			if err := s.db.View(func(txn kvTxn) error {
+				item, err := txn.Get([]byte("content:" + cid))
+				if err != nil {
+					return err
+				}
+				meta, stored = item.UserMeta(), int(item.ValueSize())
+				return nil
+			}); err != nil {
+				t.Fatalf("read entry: %v", err)
+			}
+			if got := meta&metaZstd != 0; got != tt.wantCompressed {
+				t.Errorf("compressed = %v, want %v", got, tt.wantCompressed)
+			}
+			if tt.wantCompressed && stored >= len(tt.data) {
+				t.Errorf("stored %d bytes for %d bytes of content", stored, len(tt.data))
+			}
+
+			got, err := s.GetContent(cid)
+			if err != nil || !bytes.Equal(got, tt.data) {
+				t.Errorf("GetContent() = %d bytes, %v; want original", len(got), err)
+			}
+		})
+	}
+
+	// Entries written before compression was enabled still read back
+	s.SetCompression(false)
+	plain := []byte(strings.Repeat("plain ", 200))
+	if err := s.PutContent(core.CIDForContent(plain), plain); err != nil {
+		t.Fatalf("PutContent: %v", err)
+	}
+	s.SetCompression(true)
+	if got, err := s.GetContent(core.CIDForContent(plain)); err != nil || !bytes.Equal(got, plain) {
+		t.Errorf("GetContent() of an uncompressed entry = %d bytes, %v", len(got), err)
+	}
+}
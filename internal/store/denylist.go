@@ -0,0 +1,123 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"alxnet/internal/core"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Denylist limits
+const (
+	MaxDenylistEntries = 10000
+	MaxDenyReason      = 256
+)
+
+// ErrDenylistFull is returned when denying another ID would exceed
+// MaxDenylistEntries.
+var ErrDenylistFull = errors.New("denylist is full")
+
+// DenyEntry is a site ID or content CID the operator refuses to store or
+// serve.
+type DenyEntry struct {
+	ID     string    `json:"id"`
+	Reason string    `json:"reason,omitempty"`
+	Added  time.Time `json:"added"`
+}
+
+func denyKey(id string) []byte { return []byte("deny:" + id) }
+
+// Deny adds a site ID or content CID to the denylist. Denying an ID again
+// only updates its reason.
+func (s *Store) Deny(id, reason string) (*DenyEntry, error) {
+	if err := s.validateKey(id); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+	if len(reason) > MaxDenyReason {
+		return nil, fmt.Errorf("reason too long: %d > %d", len(reason), MaxDenyReason)
+	}
+	e := &DenyEntry{ID: id, Reason: reason, Added: time.Now().UTC()}
+	data, err := core.MarshalCanonical(e)
+	if err != nil {
+		return nil, err
+	}
+	s.denyMu.Lock()
+	defer s.denyMu.Unlock()
+	err = s.db.Update(func(txn kvTxn) error {
+		if _, err := txn.Get(denyKey(id)); err == badger.ErrKeyNotFound {
+			if countPrefix(txn, []byte("deny:")) >= MaxDenylistEntries {
+				return ErrDenylistFull
+			}
+		} else if err != nil {
+			return err
+		}
+		return txn.Set(denyKey(id), data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Allow removes id from the denylist and reports whether it was denied.
+func (s *Store) Allow(id string) (bool, error) {
+	if err := s.validateKey(id); err != nil {
+		return false, fmt.Errorf("validation failed: %w", err)
+	}
+	s.denyMu.Lock()
+	defer s.denyMu.Unlock()
+	var found bool
+	err := s.db.Update(func(txn kvTxn) error {
+		if _, err := txn.Get(denyKey(id)); err == badger.ErrKeyNotFound {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		found = true
+		return txn.Delete(denyKey(id))
+	})
+	return found, err
+}
+
+// IsDenied reports whether any of ids is on the denylist. Empty IDs are
+// ignored.
+func (s *Store) IsDenied(ids ...string) bool {
+	denied := false
+	_ = s.db.View(func(txn kvTxn) error {
+		for _, id := range ids {
+			if id == "" {
+				continue
+			}
+			if _, err := txn.Get(denyKey(id)); err == nil {
+				denied = true
+				return nil
+			}
+		}
+		return nil
+	})
+	return denied
+}
+
+// ListDenied returns the denylist in ID order.
+func (s *Store) ListDenied() ([]DenyEntry, error) {
+	var out []DenyEntry
+	err := s.db.View(func(txn kvTxn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		prefix := []byte("deny:")
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var e DenyEntry
+			if err := it.Item().Value(func(v []byte) error {
+				return core.UnmarshalCBOR(v, &e)
+			}); err != nil {
+				return err
+			}
+			out = append(out, e)
+		}
+		return nil
+	})
+	return out, err
+}
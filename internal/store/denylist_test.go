@@ -0,0 +1,50 @@
+package store
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDenylist(t *testing.T) {
+	s := openTestStore(t)
+	const siteID = "aa00000000000000000000000000000000000000000000000000000000000000"
+	const cid = "bb00000000000000000000000000000000000000000000000000000000000000"
+
+	if s.IsDenied(siteID, cid) {
+		t.Fatal("IDs denied before Deny")
+	}
+	if _, err := s.Deny(cid, "malware"); err != nil {
+		t.Fatalf("Deny: %v", err)
+	}
+	if !s.IsDenied(siteID, cid) || s.IsDenied(siteID, "") {
+		t.Error("IsDenied() does not match the denylist")
+	}
+	entries, err := s.ListDenied()
+	if err != nil || len(entries) != 1 || entries[0].ID != cid || entries[0].Reason != "malware" {
+		t.Errorf("ListDenied() = %+v, %v", entries, err)
+	}
+	if found, err := s.Allow(cid); err != nil || !found {
+		t.Errorf("Allow() = %v, %v", found, err)
+	}
+	if found, _ := s.Allow(cid); found {
+		t.Error("Allow() of an allowed ID reported it denied")
+	}
+	if s.IsDenied(cid) {
+		t.Error("allowed ID still denied")
+	}
+
+	tests := []struct {
+		name   string
+		id     string
+		reason string
+	}{
+		{"not hex", "site-name", ""},
+		{"empty", "", ""},
+		{"reason too long", siteID, strings.Repeat("x", MaxDenyReason+1)},
+	}
+	for _, tt := range tests {
+		if _, err := s.Deny(tt.id, tt.reason); err == nil {
+			t.Errorf("%s: Deny() succeeded", tt.name)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"alxnet/internal/core"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Drafts are a site owner's unpublished edits of a website, kept apart from
+// the signed file records readers are served, so saving never changes the
+// live site. Each is kept as draft:<siteID>:<path> and holds a reference on
+// its content until it is published or discarded.
+
+// ErrTooManyDrafts is returned when a site would have more than
+// core.MaxFileCount drafts.
+var ErrTooManyDrafts = errors.New("too many unpublished changes")
+
+// Draft is an unpublished edit of one file: new content, or its removal.
+type Draft struct {
+	Path       string    `cbor:"path" json:"path"`
+	ContentCID string    `cbor:"content,omitempty" json:"content_cid,omitempty"`
+	MimeType   string    `cbor:"mime,omitempty" json:"mime_type,omitempty"`
+	Deleted    bool      `cbor:"deleted,omitempty" json:"deleted,omitempty"`
+	Saved      time.Time `cbor:"saved" json:"saved"`
+	// Contributor is the hex key of whoever signed an accepted
+	// contribution of the file, "" for the owner's own edits.
+	Contributor string `cbor:"contributor,omitempty" json:"contributor,omitempty"`
+}
+
+func draftPrefix(siteID string) []byte { return []byte("draft:" + siteID + ":") }
+
+func draftKey(siteID, filePath string) []byte {
+	return append(draftPrefix(siteID), filePath...)
+}
+
+// draftContentCID returns the content CID of the draft at key, or "" for
+// none or a removal.
+func draftContentCID(txn kvTxn, key []byte) (string, bool, error) {
+	item, err := txn.Get(key)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	var d Draft
+	if err := item.Value(func(v []byte) error { return core.UnmarshalCBOR(v, &d) }); err != nil {
+		return "", true, err
+	}
+	return d.ContentCID, true, nil
+}
+
+// PutDraft saves d as the draft of its path in siteID, replacing the one
+// before. The content it points at, already stored, gains a reference.
+func (s *Store) PutDraft(siteID string, d *Draft) error {
+	if err := s.validateKey(siteID); err != nil {
+		return fmt.Errorf("validation failed: %w", err)
+	}
+	if err := core.ValidateFilePath(d.Path); err != nil {
+		return err
+	}
+	if d.Deleted {
+		d.ContentCID, d.MimeType = "", ""
+	} else if d.ContentCID == "" {
+		return errors.New("draft has no content")
+	}
+	d.Saved = time.Now().UTC()
+	data, err := core.MarshalCanonical(d)
+	if err != nil {
+		return err
+	}
+	return s.update(func(txn *countedTxn) error {
+		key := draftKey(siteID, d.Path)
+		oldCID, exists, err := draftContentCID(txn.kvTxn, key)
+		if err != nil {
+			return err
+		}
+		if !exists && countPrefix(txn.kvTxn, draftPrefix(siteID)) >= core.MaxFileCount {
+			return ErrTooManyDrafts
+		}
+		if err := txn.Set(key, data); err != nil {
+			return err
+		}
+		if oldCID == d.ContentCID {
+			return nil
+		}
+		if d.ContentCID != "" {
+			if err := addContentRef(txn.kvTxn, d.ContentCID, 1); err != nil {
+				return err
+			}
+		}
+		if oldCID != "" {
+			return addContentRef(txn.kvTxn, oldCID, -1)
+		}
+		return nil
+	})
+}
+
+// GetDraft returns the draft of filePath in siteID, or nil.
+func (s *Store) GetDraft(siteID, filePath string) (*Draft, error) {
+	var d *Draft
+	err := s.db.View(func(txn kvTxn) error {
+		item, err := txn.Get(draftKey(siteID, filePath))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		d = new(Draft)
+		return item.Value(func(v []byte) error { return core.UnmarshalCBOR(v, d) })
+	})
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Drafts returns the drafts of siteID by path.
+func (s *Store) Drafts(siteID string) ([]Draft, error) {
+	var drafts []Draft
+	err := s.db.View(func(txn kvTxn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = draftPrefix(siteID)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			var d Draft
+			if err := it.Item().Value(func(v []byte) error { return core.UnmarshalCBOR(v, &d) }); err != nil {
+				continue
+			}
+			drafts = append(drafts, d)
+		}
+		return nil
+	})
+	return drafts, err
+}
+
+// DeleteDraft removes the draft of filePath in siteID and drops its content
+// reference. It returns the content CID, or "" for none or a removal, and
+// whether there was a draft.
+func (s *Store) DeleteDraft(siteID, filePath string) (string, bool, error) {
+	var (
+		cid    string
+		exists bool
+	)
+	err := s.update(func(txn *countedTxn) error {
+		key := draftKey(siteID, filePath)
+		var err error
+		if cid, exists, err = draftContentCID(txn.kvTxn, key); err != nil || !exists {
+			return err
+		}
+		if err := txn.Delete(key); err != nil {
+			return err
+		}
+		if cid == "" {
+			return nil
+		}
+		return addContentRef(txn.kvTxn, cid, -1)
+	})
+	return cid, exists, err
+}
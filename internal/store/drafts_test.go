@@ -0,0 +1,67 @@
+package store
+
+import (
+	"strings"
+	"testing"
+
+	"alxnet/internal/core"
+)
+
+func TestDrafts(t *testing.T) {
+	s := openTestStore(t)
+	siteID := strings.Repeat("ab", 32)
+	v1, v2 := []byte("<h1>one</h1>"), []byte("<h1>two</h1>")
+	cid1, cid2 := core.CIDForContent(v1), core.CIDForContent(v2)
+	for cid, data := range map[string][]byte{cid1: v1, cid2: v2} {
+		if err := s.PutContent(cid, data); err != nil {
+			t.Fatalf("PutContent: %v", err)
+		}
+	}
+
+	if err := s.PutDraft(siteID, &Draft{Path: "index.html", ContentCID: cid1, MimeType: "text/html"}); err != nil {
+		t.Fatalf("PutDraft: %v", err)
+	}
+	if err := s.PutDraft(siteID, &Draft{Path: "old.html", Deleted: true}); err != nil {
+		t.Fatalf("PutDraft (removal): %v", err)
+	}
+	if err := s.PutDraft(siteID, &Draft{Path: "../x.html", ContentCID: cid1}); err == nil {
+		t.Error("PutDraft() accepted a path escaping the site")
+	}
+	if n, _, _ := s.ContentRefs(cid1); n != 1 {
+		t.Errorf("refs of the draft's content = %d, want 1", n)
+	}
+
+	// A new draft of the same path moves the reference
+	if err := s.PutDraft(siteID, &Draft{Path: "index.html", ContentCID: cid2, MimeType: "text/html"}); err != nil {
+		t.Fatalf("PutDraft (replace): %v", err)
+	}
+	if n, _, _ := s.ContentRefs(cid1); n != 0 {
+		t.Errorf("refs of replaced content = %d, want 0", n)
+	}
+	if pruned, err := s.PruneContent(cid2); err != nil || pruned {
+		t.Errorf("PruneContent() of draft content = %v, %v", pruned, err)
+	}
+	drafts, err := s.Drafts(siteID)
+	if err != nil || len(drafts) != 2 || drafts[0].Path != "index.html" || drafts[0].ContentCID != cid2 || !drafts[1].Deleted || drafts[1].Saved.IsZero() {
+		t.Fatalf("Drafts() = %+v, %v", drafts, err)
+	}
+	if d, err := s.GetDraft(siteID, "index.html"); err != nil || d == nil || d.MimeType != "text/html" {
+		t.Errorf("GetDraft() = %+v, %v", d, err)
+	}
+	if d, err := s.GetDraft(siteID, "none.html"); err != nil || d != nil {
+		t.Errorf("GetDraft() of no draft = %+v, %v", d, err)
+	}
+
+	if cid, ok, err := s.DeleteDraft(siteID, "index.html"); err != nil || !ok || cid != cid2 {
+		t.Errorf("DeleteDraft() = %s, %v, %v", cid, ok, err)
+	}
+	if n, _, _ := s.ContentRefs(cid2); n != 0 {
+		t.Errorf("refs after DeleteDraft = %d, want 0", n)
+	}
+	if _, ok, err := s.DeleteDraft(siteID, "index.html"); err != nil || ok {
+		t.Errorf("DeleteDraft() twice = %v, %v", ok, err)
+	}
+	if drafts, _ := s.Drafts(strings.Repeat("cd", 32)); len(drafts) != 0 {
+		t.Errorf("Drafts() of another site = %+v", drafts)
+	}
+}
@@ -0,0 +1,178 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Erasure-coded pinning keeps two kinds of records. A node that pins a site
+// with erasure coding keeps its set (erasure:<site>), an opaque blob listing
+// the shards and the peers holding them. A node holding shards for others
+// keeps a shard record (shard:<cid>) per shard, which accounts the shard
+// against its quota and keeps its content from being cleaned up.
+
+// ShardRecord is a shard this node holds for another node. The shard bytes
+// are stored as ordinary content under CID.
+type ShardRecord struct {
+	CID    string    `json:"cid"`
+	SetID  string    `json:"set_id"`
+	SiteID string    `json:"site_id"`
+	Index  int       `json:"index"`
+	From   string    `json:"from"` // peer that stored it
+	Size   int64     `json:"size"`
+	Stored time.Time `json:"stored"`
+}
+
+func shardKey(cid string) []byte { return []byte("shard:" + cid) }
+
+// PutShard records a shard held for another node.
+func (s *Store) PutShard(r *ShardRecord) error {
+	if err := s.validateKey(r.CID); err != nil {
+		return fmt.Errorf("validation failed: %w", err)
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(txn kvTxn) error {
+		return txn.Set(shardKey(r.CID), data)
+	})
+}
+
+// GetShard returns the shard record for cid, or nil if there is none.
+func (s *Store) GetShard(cid string) (*ShardRecord, error) {
+	var r *ShardRecord
+	err := s.db.View(func(txn kvTxn) error {
+		it, err := txn.Get(shardKey(cid))
+		if err == badger.ErrKeyNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return it.Value(func(v []byte) error {
+			r = new(ShardRecord)
+			return json.Unmarshal(v, r)
+		})
+	})
+	return r, err
+}
+
+// ListShards returns the records of all shards held for other nodes.
+func (s *Store) ListShards() ([]ShardRecord, error) {
+	var out []ShardRecord
+	err := s.db.View(func(txn kvTxn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := []byte("shard:")
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var r ShardRecord
+			if err := it.Item().Value(func(v []byte) error { return json.Unmarshal(v, &r) }); err != nil {
+				return err
+			}
+			out = append(out, r)
+		}
+		return nil
+	})
+	return out, err
+}
+
+// ShardBytes is the total size of the shards held for other nodes.
+func (s *Store) ShardBytes() (int64, error) {
+	shards, err := s.ListShards()
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, r := range shards {
+		total += r.Size
+	}
+	return total, nil
+}
+
+// DeleteShard drops the shard record for cid and its content, unless a
+// stored file or pinned site uses the same content.
+func (s *Store) DeleteShard(cid string) error {
+	if err := s.validateKey(cid); err != nil {
+		return fmt.Errorf("validation failed: %w", err)
+	}
+	if err := s.db.Update(func(txn kvTxn) error {
+		return txn.Delete(shardKey(cid))
+	}); err != nil {
+		return err
+	}
+	if n, _, err := s.ContentRefs(cid); err != nil || n > 0 {
+		return err
+	}
+	keep, err := s.pinnedContent()
+	if err != nil || keep[cid] {
+		return err
+	}
+	return s.DeleteContent(cid)
+}
+
+// PutErasureSet stores the erasure set of a site this node pinned.
+func (s *Store) PutErasureSet(siteID string, data []byte) error {
+	if err := s.validateKeyValue(siteID, data); err != nil {
+		return fmt.Errorf("validation failed: %w", err)
+	}
+	return s.db.Update(func(txn kvTxn) error {
+		return txn.Set([]byte("erasure:"+siteID), data)
+	})
+}
+
+func (s *Store) GetErasureSet(siteID string) ([]byte, error) {
+	if err := s.validateKey(siteID); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+	var out []byte
+	err := s.db.View(func(txn kvTxn) error {
+		it, err := txn.Get([]byte("erasure:" + siteID))
+		if err != nil {
+			return err
+		}
+		return it.Value(func(v []byte) error {
+			out = append([]byte{}, v...)
+			return nil
+		})
+	})
+	return out, err
+}
+
+// ListErasureSets returns all erasure sets keyed by site ID.
+func (s *Store) ListErasureSets() (map[string][]byte, error) {
+	out := make(map[string][]byte)
+	err := s.db.View(func(txn kvTxn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := []byte("erasure:")
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			id := strings.TrimPrefix(string(item.Key()), string(prefix))
+			err := item.Value(func(v []byte) error {
+				out[id] = append([]byte{}, v...)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return out, err
+}
+
+func (s *Store) DeleteErasureSet(siteID string) error {
+	if err := s.validateKey(siteID); err != nil {
+		return fmt.Errorf("validation failed: %w", err)
+	}
+	return s.db.Update(func(txn kvTxn) error {
+		return txn.Delete([]byte("erasure:" + siteID))
+	})
+}
@@ -0,0 +1,63 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"alxnet/internal/core"
+)
+
+func TestShardRecords(t *testing.T) {
+	s := openTestStore(t)
+	shard := []byte("shard bytes")
+	shared := []byte("also a stored file")
+	shardCID, sharedCID := core.CIDForContent(shard), core.CIDForContent(shared)
+	for cid, data := range map[string][]byte{shardCID: shard, sharedCID: shared} {
+		if err := s.PutContent(cid, data); err != nil {
+			t.Fatalf("PutContent: %v", err)
+		}
+		if err := s.PutShard(&ShardRecord{CID: cid, SetID: "set", Size: int64(len(data)), Stored: time.Now()}); err != nil {
+			t.Fatalf("PutShard: %v", err)
+		}
+	}
+	rec := core.FileRecord{Version: "v1", Path: "a.txt", ContentCID: sharedCID, MimeType: "text/plain", TS: 1}
+	data, err := core.CanonicalMarshalFileRecord(&rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PutFileRecord("aa00000000000000000000000000000000000000000000000000000000000000", "a.txt", core.CIDForBytes(data), data); err != nil {
+		t.Fatalf("PutFileRecord: %v", err)
+	}
+
+	if total, err := s.ShardBytes(); err != nil || total != int64(len(shard)+len(shared)) {
+		t.Errorf("ShardBytes() = %d, %v", total, err)
+	}
+	if err := s.CleanupOldRecords(0); err != nil {
+		t.Fatalf("CleanupOldRecords: %v", err)
+	}
+	if ok, _ := s.HasContent(shardCID); !ok {
+		t.Fatal("cleanup removed a held shard")
+	}
+
+	for _, cid := range []string{shardCID, sharedCID} {
+		if err := s.DeleteShard(cid); err != nil {
+			t.Fatalf("DeleteShard: %v", err)
+		}
+		if r, err := s.GetShard(cid); err != nil || r != nil {
+			t.Errorf("GetShard() after delete = %+v, %v", r, err)
+		}
+	}
+	tests := []struct {
+		name string
+		cid  string
+		want bool
+	}{
+		{"dropped shard", shardCID, false},
+		{"shard used by a file", sharedCID, true},
+	}
+	for _, tt := range tests {
+		if got, _ := s.HasContent(tt.cid); got != tt.want {
+			t.Errorf("%s: HasContent = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package store
+
+// The store reports a few changes operators may want to act on as events,
+// which a node forwards to its webhooks. Events are sent after the change
+// is written and never fail it.
+
+// Event types.
+const (
+	EventSiteHead         = "site.head"         // a followed site has a new head
+	EventDomainRegistered = "domain.registered" // a site name was registered or moved
+	EventPinIncomplete    = "pin.incomplete"    // an erasure-coded pin lost shards
+)
+
+// EventTypes lists every event type, for validating subscriptions.
+var EventTypes = []string{EventSiteHead, EventDomainRegistered, EventPinIncomplete}
+
+// Event is one change reported to the event sink.
+type Event struct {
+	Type string
+	Data map[string]interface{}
+}
+
+// SetEventSink makes the store call sink with every event, from the
+// goroutine making the change; sink must not block. A nil sink drops
+// events.
+func (s *Store) SetEventSink(sink func(Event)) {
+	if sink == nil {
+		s.events.Store(nil)
+		return
+	}
+	s.events.Store(&sink)
+}
+
+// Emit sends an event to the event sink, if there is one. The node uses it
+// for events of state the store does not interpret.
+func (s *Store) Emit(typ string, data map[string]interface{}) {
+	if sink := s.events.Load(); sink != nil {
+		(*sink)(Event{Type: typ, Data: data})
+	}
+}
@@ -0,0 +1,46 @@
+package store
+
+import "testing"
+
+func TestEventSink(t *testing.T) {
+	s := openTestStore(t)
+	const siteA = "aa00000000000000000000000000000000000000000000000000000000000000"
+
+	var got []Event
+	s.SetEventSink(func(e Event) { got = append(got, e) })
+
+	if err := s.PutDomain("alpha", siteA); err != nil {
+		t.Fatalf("PutDomain: %v", err)
+	}
+	// Re-registering to the same site is not news
+	if err := s.PutDomain("alpha", siteA); err != nil {
+		t.Fatalf("PutDomain again: %v", err)
+	}
+	if _, err := s.FollowSite(siteA, "alpha"); err != nil {
+		t.Fatalf("FollowSite: %v", err)
+	}
+	if _, err := s.NoteSiteHead(siteA, 1, "head"); err != nil {
+		t.Fatalf("NoteSiteHead: %v", err)
+	}
+	if _, err := s.NoteSiteHead(siteA, 1, "head"); err != nil {
+		t.Fatalf("NoteSiteHead again: %v", err)
+	}
+
+	if len(got) != 2 {
+		t.Fatalf("got %d events, want 2: %+v", len(got), got)
+	}
+	if got[0].Type != EventDomainRegistered || got[0].Data["domain"] != "alpha" || got[0].Data["site_id"] != siteA {
+		t.Errorf("first event = %+v, want alpha registered", got[0])
+	}
+	if got[1].Type != EventSiteHead || got[1].Data["seq"] != uint64(1) {
+		t.Errorf("second event = %+v, want head 1", got[1])
+	}
+
+	s.SetEventSink(nil)
+	if _, err := s.NoteSiteHead(siteA, 2, "head2"); err != nil {
+		t.Fatalf("NoteSiteHead without sink: %v", err)
+	}
+	if len(got) != 2 {
+		t.Errorf("event sent after the sink was removed")
+	}
+}
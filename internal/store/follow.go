@@ -0,0 +1,334 @@
+package store
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"alxnet/internal/core"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Follow limits
+const (
+	MaxFollows        = 1000
+	MaxFollowLabel    = 64
+	MaxNotifications  = 500 // oldest are dropped beyond this
+	notificationIDLen = 8
+)
+
+// ErrTooManyFollows is returned when following another site would exceed
+// MaxFollows.
+var ErrTooManyFollows = errors.New("too many followed sites")
+
+// Follow is a site the user follows. LastSeq is the newest head sequence
+// seen, so that only later updates notify.
+type Follow struct {
+	SiteID  string    `json:"site_id"`
+	Label   string    `json:"label,omitempty"` // name the user followed it by
+	Muted   bool      `json:"muted"`
+	LastSeq uint64    `json:"last_seq"`
+	Since   time.Time `json:"since"`
+}
+
+// Notification reports a new head of a followed site.
+type Notification struct {
+	ID      uint64    `json:"id"`
+	SiteID  string    `json:"site_id"`
+	Seq     uint64    `json:"seq"`
+	HeadCID string    `json:"head_cid"`
+	Time    time.Time `json:"time"`
+	Read    bool      `json:"read"`
+}
+
+func followKey(siteID string) []byte { return []byte("follow:" + siteID) }
+
+func notificationKey(id uint64) []byte {
+	k := make([]byte, len("notif:")+notificationIDLen)
+	copy(k, "notif:")
+	binary.BigEndian.PutUint64(k[len("notif:"):], id) // sorts oldest first
+	return k
+}
+
+// FollowSite starts following siteID. Updates up to the head currently held
+// do not notify. Following a site again only changes its label.
+func (s *Store) FollowSite(siteID, label string) (*Follow, error) {
+	if err := s.validateKey(siteID); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+	if len(label) > MaxFollowLabel {
+		return nil, fmt.Errorf("label too long: %d > %d", len(label), MaxFollowLabel)
+	}
+
+	s.followMu.Lock()
+	defer s.followMu.Unlock()
+
+	f, err := s.GetFollow(siteID)
+	if err != nil {
+		return nil, err
+	}
+	if f == nil {
+		follows, err := s.ListFollows()
+		if err != nil {
+			return nil, err
+		}
+		if len(follows) >= MaxFollows {
+			return nil, ErrTooManyFollows
+		}
+		f = &Follow{SiteID: siteID, Since: time.Now().UTC()}
+		if has, _ := s.HasHead(siteID); has {
+			if seq, _, err := s.GetHead(siteID); err == nil {
+				f.LastSeq = seq
+			}
+		}
+	}
+	f.Label = label
+	return f, s.putFollow(f)
+}
+
+// UnfollowSite stops following siteID; it is not an error if the site was
+// not followed.
+func (s *Store) UnfollowSite(siteID string) error {
+	if err := s.validateKey(siteID); err != nil {
+		return fmt.Errorf("validation failed: %w", err)
+	}
+	s.followMu.Lock()
+	defer s.followMu.Unlock()
+	return s.db.Update(func(txn kvTxn) error {
+		return txn.Delete(followKey(siteID))
+	})
+}
+
+// SetFollowMuted mutes or unmutes notifications for a followed site.
+func (s *Store) SetFollowMuted(siteID string, muted bool) error {
+	s.followMu.Lock()
+	defer s.followMu.Unlock()
+
+	f, err := s.GetFollow(siteID)
+	if err != nil {
+		return err
+	}
+	if f == nil {
+		return errors.New("site not followed")
+	}
+	f.Muted = muted
+	return s.putFollow(f)
+}
+
+// GetFollow returns the follow entry of siteID, or nil if it is not
+// followed.
+func (s *Store) GetFollow(siteID string) (*Follow, error) {
+	if err := s.validateKey(siteID); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+	var f *Follow
+	err := s.db.View(func(txn kvTxn) error {
+		it, err := txn.Get(followKey(siteID))
+		if err == badger.ErrKeyNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return it.Value(func(v []byte) error {
+			f = &Follow{}
+			return core.UnmarshalCBOR(v, f)
+		})
+	})
+	return f, err
+}
+
+// ListFollows returns all followed sites.
+func (s *Store) ListFollows() ([]Follow, error) {
+	var follows []Follow
+	err := s.db.View(func(txn kvTxn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := []byte("follow:")
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var f Follow
+			if err := it.Item().Value(func(v []byte) error {
+				return core.UnmarshalCBOR(v, &f)
+			}); err != nil {
+				return err
+			}
+			follows = append(follows, f)
+		}
+		return nil
+	})
+	return follows, err
+}
+
+func (s *Store) putFollow(f *Follow) error {
+	data, err := core.MarshalCanonical(f)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(txn kvTxn) error {
+		return txn.Set(followKey(f.SiteID), data)
+	})
+}
+
+// NoteSiteHead records that siteID now has head headCID at seq. For a
+// followed site with a newer seq it advances LastSeq and, unless the site is
+// muted, adds a notification, which is returned. Other calls return nil.
+func (s *Store) NoteSiteHead(siteID string, seq uint64, headCID string) (*Notification, error) {
+	s.followMu.Lock()
+	defer s.followMu.Unlock()
+
+	f, err := s.GetFollow(siteID)
+	if err != nil || f == nil || seq <= f.LastSeq {
+		return nil, err
+	}
+	f.LastSeq = seq
+	if err := s.putFollow(f); err != nil {
+		return nil, err
+	}
+	if f.Muted {
+		return nil, nil
+	}
+
+	n := &Notification{SiteID: siteID, Seq: seq, HeadCID: headCID, Time: time.Now().UTC()}
+	err = s.db.Update(func(txn kvTxn) error {
+		last, err := s.lastNotificationID(txn)
+		if err != nil {
+			return err
+		}
+		n.ID = last + 1
+		data, err := core.MarshalCanonical(n)
+		if err != nil {
+			return err
+		}
+		return txn.Set(notificationKey(n.ID), data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.Emit(EventSiteHead, map[string]interface{}{"site_id": siteID, "seq": seq, "head_cid": headCID})
+	return n, s.trimNotifications()
+}
+
+// lastNotificationID returns the newest notification ID, 0 if none.
+func (s *Store) lastNotificationID(txn kvTxn) (uint64, error) {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	opts.Reverse = true
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	prefix := []byte("notif:")
+	// Seek past every ID so the reverse iterator lands on the newest
+	it.Seek(append(prefix, 0xff))
+	if !it.ValidForPrefix(prefix) {
+		return 0, nil
+	}
+	return binary.BigEndian.Uint64(it.Item().Key()[len(prefix):]), nil
+}
+
+// trimNotifications drops the oldest notifications beyond MaxNotifications.
+func (s *Store) trimNotifications() error {
+	return s.db.Update(func(txn kvTxn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Reverse = true
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		prefix := []byte("notif:")
+		var stale [][]byte
+		count := 0
+		for it.Seek(append(prefix, 0xff)); it.ValidForPrefix(prefix); it.Next() {
+			count++
+			if count > MaxNotifications {
+				stale = append(stale, it.Item().KeyCopy(nil))
+			}
+		}
+		for _, k := range stale {
+			if err := txn.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ListNotifications returns up to limit notifications, newest first. With
+// unreadOnly, read ones are skipped. It also returns the unread count.
+func (s *Store) ListNotifications(limit int, unreadOnly bool) ([]Notification, int, error) {
+	var out []Notification
+	unread := 0
+	err := s.db.View(func(txn kvTxn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Reverse = true
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		prefix := []byte("notif:")
+		for it.Seek(append(prefix, 0xff)); it.ValidForPrefix(prefix); it.Next() {
+			var n Notification
+			if err := it.Item().Value(func(v []byte) error {
+				return core.UnmarshalCBOR(v, &n)
+			}); err != nil {
+				return err
+			}
+			if !n.Read {
+				unread++
+			}
+			if (unreadOnly && n.Read) || len(out) >= limit {
+				continue
+			}
+			out = append(out, n)
+		}
+		return nil
+	})
+	return out, unread, err
+}
+
+// MarkNotificationsRead marks every notification with an ID up to and
+// including upTo as read.
+func (s *Store) MarkNotificationsRead(upTo uint64) error {
+	s.followMu.Lock()
+	defer s.followMu.Unlock()
+
+	return s.db.Update(func(txn kvTxn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := []byte("notif:")
+		type entry struct {
+			key  []byte
+			data []byte
+		}
+		var updates []entry
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			k := it.Item().Key()
+			if binary.BigEndian.Uint64(k[len(prefix):]) > upTo {
+				break
+			}
+			var n Notification
+			if err := it.Item().Value(func(v []byte) error {
+				return core.UnmarshalCBOR(v, &n)
+			}); err != nil {
+				return err
+			}
+			if n.Read {
+				continue
+			}
+			n.Read = true
+			data, err := core.MarshalCanonical(&n)
+			if err != nil {
+				return err
+			}
+			updates = append(updates, entry{it.Item().KeyCopy(nil), data})
+		}
+		for _, u := range updates {
+			if err := txn.Set(u.key, u.data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,132 @@
+package store
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFollowNotifications(t *testing.T) {
+	s := openTestStore(t)
+	const siteA = "aa00000000000000000000000000000000000000000000000000000000000000"
+	const siteB = "bb00000000000000000000000000000000000000000000000000000000000000"
+	const headCID = "cc00000000000000000000000000000000000000000000000000000000000000"
+
+	// A site already at seq 3 when followed only notifies from seq 4
+	if err := s.SetHead(siteA, 3, headCID); err != nil {
+		t.Fatalf("SetHead: %v", err)
+	}
+	f, err := s.FollowSite(siteA, "alpha")
+	if err != nil {
+		t.Fatalf("FollowSite: %v", err)
+	}
+	if f.LastSeq != 3 || f.Label != "alpha" {
+		t.Errorf("follow = %+v, want last seq 3, label alpha", f)
+	}
+	if _, err := s.FollowSite(siteB, ""); err != nil {
+		t.Fatalf("FollowSite: %v", err)
+	}
+
+	steps := []struct {
+		site   string
+		seq    uint64
+		notify bool
+	}{
+		{siteA, 3, false}, // already seen
+		{siteA, 4, true},
+		{siteA, 4, false}, // re-announcement
+		{siteA, 2, false}, // older
+		{siteB, 1, true},
+		{"dd00000000000000000000000000000000000000000000000000000000000000", 9, false}, // not followed
+	}
+	for _, st := range steps {
+		n, err := s.NoteSiteHead(st.site, st.seq, headCID)
+		if err != nil {
+			t.Fatalf("NoteSiteHead(%s, %d): %v", st.site[:2], st.seq, err)
+		}
+		if (n != nil) != st.notify {
+			t.Errorf("NoteSiteHead(%s, %d) notified = %v, want %v", st.site[:2], st.seq, n != nil, st.notify)
+		}
+	}
+
+	// Muted sites advance silently
+	if err := s.SetFollowMuted(siteB, true); err != nil {
+		t.Fatalf("SetFollowMuted: %v", err)
+	}
+	if n, _ := s.NoteSiteHead(siteB, 2, headCID); n != nil {
+		t.Error("muted site notified")
+	}
+	if f, _ := s.GetFollow(siteB); f.LastSeq != 2 {
+		t.Errorf("muted site last seq = %d, want 2", f.LastSeq)
+	}
+
+	list, unread, err := s.ListNotifications(10, false)
+	if err != nil {
+		t.Fatalf("ListNotifications: %v", err)
+	}
+	if len(list) != 2 || unread != 2 || list[0].SiteID != siteB || list[1].SiteID != siteA {
+		t.Fatalf("notifications = %+v (unread %d), want siteB then siteA, both unread", list, unread)
+	}
+
+	if err := s.MarkNotificationsRead(list[1].ID); err != nil {
+		t.Fatalf("MarkNotificationsRead: %v", err)
+	}
+	list, unread, _ = s.ListNotifications(10, true)
+	if len(list) != 1 || unread != 1 || list[0].SiteID != siteB {
+		t.Errorf("unread = %+v (%d), want only siteB", list, unread)
+	}
+
+	if err := s.UnfollowSite(siteA); err != nil {
+		t.Fatalf("UnfollowSite: %v", err)
+	}
+	if n, _ := s.NoteSiteHead(siteA, 10, headCID); n != nil {
+		t.Error("unfollowed site notified")
+	}
+	if err := s.SetFollowMuted(siteA, true); err == nil {
+		t.Error("SetFollowMuted accepted an unfollowed site")
+	}
+}
+
+func TestFollowValidation(t *testing.T) {
+	s := openTestStore(t)
+	const siteA = "aa00000000000000000000000000000000000000000000000000000000000000"
+
+	tests := []struct {
+		name   string
+		siteID string
+		label  string
+	}{
+		{"not hex", "not-a-site", ""},
+		{"empty", "", ""},
+		{"label too long", siteA, strings.Repeat("x", MaxFollowLabel+1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.FollowSite(tt.siteID, tt.label); err == nil {
+				t.Error("FollowSite() succeeded")
+			}
+		})
+	}
+}
+
+func TestNotificationsTrimmed(t *testing.T) {
+	s := openTestStore(t)
+	const siteA = "aa00000000000000000000000000000000000000000000000000000000000000"
+	if _, err := s.FollowSite(siteA, ""); err != nil {
+		t.Fatalf("FollowSite: %v", err)
+	}
+	for seq := uint64(1); seq <= MaxNotifications+5; seq++ {
+		if _, err := s.NoteSiteHead(siteA, seq, siteA); err != nil {
+			t.Fatalf("NoteSiteHead: %v", err)
+		}
+	}
+	list, unread, err := s.ListNotifications(MaxNotifications*2, false)
+	if err != nil {
+		t.Fatalf("ListNotifications: %v", err)
+	}
+	if len(list) != MaxNotifications || unread != MaxNotifications {
+		t.Fatalf("kept %d notifications (%d unread), want %d", len(list), unread, MaxNotifications)
+	}
+	if list[0].Seq != MaxNotifications+5 || list[len(list)-1].Seq != 6 {
+		t.Errorf("kept seqs %d..%d, want %d..6", list[0].Seq, list[len(list)-1].Seq, MaxNotifications+5)
+	}
+}
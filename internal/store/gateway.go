@@ -0,0 +1,106 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"alxnet/internal/core"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// A gateway keeps the authorizations site owners gave it, one per host and
+// site, as gateway:<host>:<site ID>.
+
+func gatewayPrefix(host string) []byte { return []byte("gateway:" + host + ":") }
+
+// GatewayAuthEntry is a stored gateway authorization and the site it is for.
+type GatewayAuthEntry struct {
+	SiteID string
+	Auth   core.GatewayAuth
+}
+
+// PutGatewayAuth stores a verified gateway authorization, unless one
+// signed later is stored for the same host and site. It reports whether
+// auth was stored.
+func (s *Store) PutGatewayAuth(auth *core.GatewayAuth, data []byte) (bool, error) {
+	if !core.ValidGatewayHost(auth.Host) {
+		return false, fmt.Errorf("invalid gateway host: %q", auth.Host)
+	}
+	siteID := core.SiteIDFromPub(auth.SitePub)
+	key := append(gatewayPrefix(auth.Host), siteID...)
+	stored := false
+	err := s.db.Update(func(txn kvTxn) error {
+		item, err := txn.Get(key)
+		switch {
+		case errors.Is(err, badger.ErrKeyNotFound):
+		case err != nil:
+			return err
+		default:
+			var old core.GatewayAuth
+			err := item.Value(func(v []byte) error { return core.UnmarshalCBOR(v, &old) })
+			if err == nil && old.TS > auth.TS {
+				return nil
+			}
+		}
+		stored = true
+		return txn.Set(key, data)
+	})
+	return stored, err
+}
+
+// GatewayAuths returns the authorizations stored for host, or for every
+// host if host is "".
+func (s *Store) GatewayAuths(host string) ([]GatewayAuthEntry, error) {
+	prefix := []byte("gateway:")
+	if host != "" {
+		if !core.ValidGatewayHost(host) {
+			return nil, fmt.Errorf("invalid gateway host: %q", host)
+		}
+		prefix = gatewayPrefix(host)
+	}
+	var entries []GatewayAuthEntry
+	err := s.db.View(func(txn kvTxn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			key := string(it.Item().Key())
+			var e GatewayAuthEntry
+			e.SiteID = key[strings.LastIndexByte(key, ':')+1:]
+			if err := it.Item().Value(func(v []byte) error { return core.UnmarshalCBOR(v, &e.Auth) }); err != nil {
+				continue
+			}
+			entries = append(entries, e)
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// DeleteGatewayAuth removes the authorization for host and siteID and
+// reports whether there was one.
+func (s *Store) DeleteGatewayAuth(host, siteID string) (bool, error) {
+	if err := s.validateKey(siteID); err != nil {
+		return false, fmt.Errorf("validation failed: %w", err)
+	}
+	if !core.ValidGatewayHost(host) {
+		return false, fmt.Errorf("invalid gateway host: %q", host)
+	}
+	key := append(gatewayPrefix(host), siteID...)
+	found := false
+	err := s.db.Update(func(txn kvTxn) error {
+		_, err := txn.Get(key)
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		found = true
+		return txn.Delete(key)
+	})
+	return found, err
+}
@@ -0,0 +1,119 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+
+	"alxnet/internal/core"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// MaxIntegrityProblems bounds the problems listed in an IntegrityReport;
+// ProblemCount still counts all of them.
+const MaxIntegrityProblems = 50
+
+// IntegrityReport summarises CheckIntegrity.
+type IntegrityReport struct {
+	Checked      int      `json:"checked"`
+	Records      int      `json:"records"`
+	Content      int      `json:"content"`
+	Manifests    int      `json:"manifests"`
+	FileRecords  int      `json:"file_records"`
+	Pointers     int      `json:"pointers"` // head, manifest and file path entries
+	ProblemCount int      `json:"problem_count"`
+	Problems     []string `json:"problems"`
+	Truncated    bool     `json:"truncated"` // stopped at the limit
+}
+
+func (r *IntegrityReport) problem(format string, args ...interface{}) {
+	r.ProblemCount++
+	if len(r.Problems) < MaxIntegrityProblems {
+		r.Problems = append(r.Problems, fmt.Sprintf(format, args...))
+	}
+}
+
+// CheckIntegrity verifies that stored records, manifests, file records and
+// content hash to their CIDs and that head, manifest and file path entries
+// point at stored objects. It stops after limit entries; 0 checks all.
+// Content offloaded to the blob backend is not fetched.
+func (s *Store) CheckIntegrity(limit int) (*IntegrityReport, error) {
+	r := &IntegrityReport{Problems: []string{}}
+	err := s.db.View(func(txn kvTxn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		exists := func(key string) bool {
+			_, err := txn.Get([]byte(key))
+			return err == nil
+		}
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			key := string(item.Key())
+			prefix, id, _ := strings.Cut(key, ":")
+			switch prefix {
+			case "record", "manifest", "filerecord", "content", "site":
+			default:
+				continue
+			}
+			if limit > 0 && r.Checked >= limit {
+				r.Truncated = true
+				return nil
+			}
+
+			switch prefix {
+			case "record", "manifest", "filerecord", "content":
+				v, err := item.ValueCopy(nil)
+				if err != nil {
+					return err
+				}
+				if prefix == "content" {
+					if v, err = decodeValue(v, item.UserMeta()); err != nil {
+						r.problem("%s: cannot decode: %v", key, err)
+						break
+					}
+				}
+				if core.CIDForBytes(v) != id {
+					r.problem("%s: stored bytes hash to %s", key, core.CIDForBytes(v))
+				}
+				switch prefix {
+				case "record":
+					r.Records++
+				case "manifest":
+					r.Manifests++
+				case "filerecord":
+					r.FileRecords++
+				default:
+					r.Content++
+				}
+			case "site":
+				parts := strings.SplitN(id, ":", 3)
+				if len(parts) < 2 {
+					continue
+				}
+				var target string
+				switch parts[1] {
+				case "head":
+					target = "record:"
+				case "manifest":
+					target = "manifest:"
+				case "file":
+					target = "filerecord:"
+				default:
+					continue
+				}
+				v, err := item.ValueCopy(nil)
+				if err != nil {
+					return err
+				}
+				r.Pointers++
+				if !exists(target + string(v)) {
+					r.problem("%s: points at missing %s%s", key, target, v)
+				}
+			}
+			r.Checked++
+		}
+		return nil
+	})
+	return r, err
+}
@@ -0,0 +1,67 @@
+package store
+
+import (
+	"strings"
+	"testing"
+
+	"alxnet/internal/core"
+)
+
+func TestCheckIntegrity(t *testing.T) {
+	const siteID = "aa00000000000000000000000000000000000000000000000000000000000000"
+	rec := []byte("record bytes")
+	content := []byte("<h1>hello</h1>")
+	recCID, contentCID := core.CIDForBytes(rec), core.CIDForContent(content)
+
+	tests := []struct {
+		name     string
+		setup    func(s *Store)
+		limit    int
+		problems []string // substrings of expected problems
+		trunc    bool
+	}{
+		{"consistent", func(s *Store) {}, 0, nil, false},
+		{"record under the wrong CID", func(s *Store) {
+			_ = s.PutRecord(strings.Repeat("b", 64), rec)
+		}, 0, []string{"record:" + strings.Repeat("b", 64)}, false},
+		{"head points at a missing record", func(s *Store) {
+			_ = s.SetHead(siteID, 2, strings.Repeat("c", 64))
+		}, 0, []string{"points at missing record:"}, false},
+		{"limit", func(s *Store) {}, 1, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := openTestStore(t)
+			s.SetCompression(true)
+			if err := s.PutRecord(recCID, rec); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.PutContent(contentCID, content); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.SetHead(siteID, 1, recCID); err != nil {
+				t.Fatal(err)
+			}
+			tt.setup(s)
+
+			r, err := s.CheckIntegrity(tt.limit)
+			if err != nil {
+				t.Fatalf("CheckIntegrity: %v", err)
+			}
+			if r.Truncated != tt.trunc {
+				t.Errorf("Truncated = %v, want %v", r.Truncated, tt.trunc)
+			}
+			if r.ProblemCount != len(tt.problems) {
+				t.Fatalf("problems = %v, want %d", r.Problems, len(tt.problems))
+			}
+			for i, want := range tt.problems {
+				if !strings.Contains(r.Problems[i], want) {
+					t.Errorf("problem %q does not mention %q", r.Problems[i], want)
+				}
+			}
+			if !tt.trunc && (r.Content != 1 || r.Pointers < 1) {
+				t.Errorf("report = %+v", r)
+			}
+		})
+	}
+}
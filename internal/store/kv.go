@@ -0,0 +1,94 @@
+package store
+
+import (
+	"errors"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// A Store keeps its data in a key-value database behind kvDB: Badger on
+// disk, or the sorted in-memory map of NewMemory. The interfaces follow
+// Badger's transactions, items and iterators, so Badger satisfies them
+// with thin wrappers and both take Badger's entries and iterator options.
+
+// errInMemory is returned by operations that need Badger's files, such as
+// snapshots and replication, on a store from NewMemory.
+var errInMemory = errors.New("not supported by an in-memory store")
+
+type kvDB interface {
+	View(fn func(txn kvTxn) error) error
+	Update(fn func(txn kvTxn) error) error
+	NewReadTxn() kvTxn // a read transaction the caller must Discard
+	Close() error
+}
+
+type kvTxn interface {
+	Get(key []byte) (kvItem, error)
+	Set(key, val []byte) error
+	SetEntry(e *badger.Entry) error
+	Delete(key []byte) error
+	NewIterator(opts badger.IteratorOptions) kvIterator
+	Discard()
+}
+
+type kvItem interface {
+	Key() []byte
+	KeyCopy(dst []byte) []byte
+	Value(fn func(val []byte) error) error
+	ValueCopy(dst []byte) ([]byte, error)
+	ValueSize() int64
+	UserMeta() byte
+}
+
+type kvIterator interface {
+	Rewind()
+	Seek(key []byte)
+	Valid() bool
+	ValidForPrefix(prefix []byte) bool
+	Next()
+	Item() kvItem
+	Close()
+}
+
+// badgerDB is a Badger database as a kvDB.
+type badgerDB struct{ *badger.DB }
+
+func (db badgerDB) View(fn func(txn kvTxn) error) error {
+	return db.DB.View(func(txn *badger.Txn) error { return fn(badgerTxn{txn}) })
+}
+
+func (db badgerDB) Update(fn func(txn kvTxn) error) error {
+	return db.DB.Update(func(txn *badger.Txn) error { return fn(badgerTxn{txn}) })
+}
+
+func (db badgerDB) NewReadTxn() kvTxn {
+	return badgerTxn{db.DB.NewTransaction(false)}
+}
+
+type badgerTxn struct{ *badger.Txn }
+
+func (t badgerTxn) Get(key []byte) (kvItem, error) {
+	item, err := t.Txn.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func (t badgerTxn) NewIterator(opts badger.IteratorOptions) kvIterator {
+	return badgerIterator{t.Txn.NewIterator(opts)}
+}
+
+type badgerIterator struct{ *badger.Iterator }
+
+func (it badgerIterator) Item() kvItem {
+	return it.Iterator.Item()
+}
+
+// onDisk returns the Badger database under s, or errInMemory.
+func (s *Store) onDisk() (*badger.DB, error) {
+	if db, ok := s.db.(badgerDB); ok {
+		return db.DB, nil
+	}
+	return nil, errInMemory
+}
@@ -0,0 +1,226 @@
+package store
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"alxnet/internal/core"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Site names change hands through signed offers and acceptances. Open
+// offers are kept as domainoffer:<name>:<offer CID>, and the time of the
+// offer a name last moved under as domainxfer:<name>, so that offers made
+// before a transfer cannot move the name again.
+
+// MaxDomainOffers bounds the open offers kept for one name.
+const MaxDomainOffers = 32
+
+// ErrTooManyOffers is returned when a new offer would exceed
+// MaxDomainOffers for its name.
+var ErrTooManyOffers = errors.New("too many open offers for domain")
+
+// ErrOfferSuperseded is returned for offers made before the name last
+// changed hands.
+var ErrOfferSuperseded = errors.New("domain changed hands after the offer was made")
+
+// DomainOfferEntry is a stored offer and its CID.
+type DomainOfferEntry struct {
+	CID   string
+	Offer core.DomainOffer
+}
+
+func domainOfferPrefix(domain string) []byte { return []byte("domainoffer:" + domain + ":") }
+
+func domainTransferKey(domain string) []byte { return []byte("domainxfer:" + domain) }
+
+// lastTransfer returns the timestamp of the offer domain last moved under,
+// or 0.
+func lastTransfer(txn kvTxn, domain string) (int64, error) {
+	item, err := txn.Get(domainTransferKey(domain))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	v, err := item.ValueCopy(nil)
+	if err != nil || len(v) != 8 {
+		return 0, err
+	}
+	return int64(binary.BigEndian.Uint64(v)), nil
+}
+
+// PutDomainOffer stores a verified offer as cid. Expired offers of the
+// same name make room when it has MaxDomainOffers open.
+func (s *Store) PutDomainOffer(cid string, offer *core.DomainOffer, data []byte) error {
+	if err := s.validateKeyValue(cid, data); err != nil {
+		return fmt.Errorf("validation failed: %w", err)
+	}
+	if !core.ValidSiteName(offer.Domain) {
+		return fmt.Errorf("invalid domain name: %q", offer.Domain)
+	}
+
+	s.domainMu.Lock()
+	defer s.domainMu.Unlock()
+
+	now := time.Now().Unix()
+	return s.db.Update(func(txn kvTxn) error {
+		last, err := lastTransfer(txn, offer.Domain)
+		if err != nil {
+			return err
+		}
+		if offer.TS <= last {
+			return ErrOfferSuperseded
+		}
+		key := append(domainOfferPrefix(offer.Domain), cid...)
+		if _, err := txn.Get(key); err == nil {
+			return nil
+		} else if !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+
+		var open int
+		var expired [][]byte
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		prefix := domainOfferPrefix(offer.Domain)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var o core.DomainOffer
+			err := it.Item().Value(func(v []byte) error { return core.UnmarshalCBOR(v, &o) })
+			if err != nil || o.Expires < now {
+				expired = append(expired, it.Item().KeyCopy(nil))
+				continue
+			}
+			open++
+		}
+		it.Close()
+		if open >= MaxDomainOffers {
+			return ErrTooManyOffers
+		}
+		for _, k := range expired {
+			if err := txn.Delete(k); err != nil {
+				return err
+			}
+		}
+		return txn.Set(key, data)
+	})
+}
+
+// GetDomainOffer returns the offer for domain stored as cid, or nil if it
+// is not stored.
+func (s *Store) GetDomainOffer(domain, cid string) (*core.DomainOffer, []byte, error) {
+	if err := s.validateKey(cid); err != nil {
+		return nil, nil, fmt.Errorf("validation failed: %w", err)
+	}
+	if !core.ValidSiteName(domain) {
+		return nil, nil, fmt.Errorf("invalid domain name: %q", domain)
+	}
+	var data []byte
+	err := s.db.View(func(txn kvTxn) error {
+		item, err := txn.Get(append(domainOfferPrefix(domain), cid...))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		data, err = item.ValueCopy(nil)
+		return err
+	})
+	if err != nil || data == nil {
+		return nil, nil, err
+	}
+	var offer core.DomainOffer
+	if err := core.UnmarshalCBOR(data, &offer); err != nil {
+		return nil, nil, err
+	}
+	return &offer, data, nil
+}
+
+// ListDomainOffers returns the open offers that have not expired by now,
+// oldest name first.
+func (s *Store) ListDomainOffers(now time.Time) ([]DomainOfferEntry, error) {
+	var out []DomainOfferEntry
+	err := s.db.View(func(txn kvTxn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		prefix := []byte("domainoffer:")
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			key := it.Item().Key()
+			var e DomainOfferEntry
+			if err := it.Item().Value(func(v []byte) error {
+				return core.UnmarshalCBOR(v, &e.Offer)
+			}); err != nil {
+				continue
+			}
+			if e.Offer.Expires < now.Unix() {
+				continue
+			}
+			e.CID = string(key[len(domainOfferPrefix(e.Offer.Domain)):])
+			out = append(out, e)
+		}
+		return nil
+	})
+	return out, err
+}
+
+// TransferDomain moves domain from the site fromSiteID to toSiteID under
+// an accepted offer made at offerTS, and drops the name's open offers. It
+// reports false, changing nothing, when domain is not registered to
+// fromSiteID here or has changed hands since the offer.
+func (s *Store) TransferDomain(domain, fromSiteID, toSiteID string, offerTS int64) (bool, error) {
+	if err := s.validateKey(toSiteID); err != nil {
+		return false, fmt.Errorf("validation failed: %w", err)
+	}
+	if !core.ValidSiteName(domain) {
+		return false, fmt.Errorf("invalid domain name: %q", domain)
+	}
+
+	s.domainMu.Lock()
+	defer s.domainMu.Unlock()
+
+	moved := false
+	err := s.update(func(txn *countedTxn) error {
+		item, err := txn.Get([]byte("domain:" + domain))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		owner, err := item.ValueCopy(nil)
+		if err != nil || string(owner) != fromSiteID {
+			return err
+		}
+		last, err := lastTransfer(txn.kvTxn, domain)
+		if err != nil || offerTS <= last {
+			return err
+		}
+
+		var offers [][]byte
+		it := txn.NewIterator(badger.IteratorOptions{Prefix: domainOfferPrefix(domain)})
+		for it.Rewind(); it.Valid(); it.Next() {
+			offers = append(offers, it.Item().KeyCopy(nil))
+		}
+		it.Close()
+		for _, k := range offers {
+			if err := txn.Delete(k); err != nil {
+				return err
+			}
+		}
+		var ts [8]byte
+		binary.BigEndian.PutUint64(ts[:], uint64(offerTS))
+		if err := txn.Set(domainTransferKey(domain), ts[:]); err != nil {
+			return err
+		}
+		moved = true
+		return txn.Set([]byte("domain:"+domain), []byte(toSiteID))
+	})
+	if moved && err == nil {
+		s.Emit(EventDomainRegistered, map[string]interface{}{"domain": domain, "site_id": toSiteID, "from_site_id": fromSiteID})
+	}
+	return moved, err
+}
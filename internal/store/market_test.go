@@ -0,0 +1,85 @@
+package store
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"alxnet/internal/core"
+)
+
+func TestDomainTransfer(t *testing.T) {
+	s, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer s.Close()
+	seller, buyer := strings.Repeat("a", 64), strings.Repeat("b", 64)
+	if err := s.RegisterSiteName("shop", seller); err != nil {
+		t.Fatalf("RegisterSiteName: %v", err)
+	}
+
+	now := time.Now().Unix()
+	offer := func(i int, ts, expires int64) string {
+		t.Helper()
+		o := &core.DomainOffer{Version: "v1", Domain: "shop", Buyer: buyer, TS: ts, Expires: expires, Note: strings.Repeat("x", i)}
+		data, err := core.MarshalCanonical(o)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cid := core.CIDForBytes(data)
+		if err := s.PutDomainOffer(cid, o, data); err != nil {
+			t.Fatalf("PutDomainOffer(%d): %v", i, err)
+		}
+		return cid
+	}
+	expired := offer(0, now-100, now-10)
+	for i := 1; i < MaxDomainOffers; i++ {
+		offer(i, now, now+3600)
+	}
+	// The expired offer makes room for one more, and then there is none
+	last := offer(MaxDomainOffers, now, now+3600)
+	o := &core.DomainOffer{Version: "v1", Domain: "shop", Buyer: buyer, TS: now, Expires: now + 3600, Note: "one too many"}
+	data, _ := core.MarshalCanonical(o)
+	if err := s.PutDomainOffer(core.CIDForBytes(data), o, data); !errors.Is(err, ErrTooManyOffers) {
+		t.Errorf("offer over the limit: err = %v, want ErrTooManyOffers", err)
+	}
+	if got, _, _ := s.GetDomainOffer("shop", expired); got != nil {
+		t.Error("expired offer kept")
+	}
+	if list, err := s.ListDomainOffers(time.Now()); err != nil || len(list) != MaxDomainOffers {
+		t.Errorf("ListDomainOffers = %d offers, %v; want %d", len(list), err, MaxDomainOffers)
+	}
+
+	tests := []struct {
+		name     string
+		from, to string
+		ts       int64
+		moved    bool
+		owner    string
+	}{
+		{"not the holder", buyer, seller, now, false, seller},
+		{"transfer", seller, buyer, now, true, buyer},
+		{"replayed", seller, buyer, now, false, buyer},
+		{"offer from before the transfer", buyer, seller, now - 1, false, buyer},
+		{"transfer back", buyer, seller, now + 1, true, seller},
+	}
+	for _, tt := range tests {
+		moved, err := s.TransferDomain("shop", tt.from, tt.to, tt.ts)
+		owner, _ := s.ResolveDomain("shop")
+		if err != nil || moved != tt.moved || owner != tt.owner {
+			t.Errorf("%s: TransferDomain = %v, %v, owner %s; want %v, owner %s", tt.name, moved, err, owner, tt.moved, tt.owner)
+		}
+	}
+	if got, _, _ := s.GetDomainOffer("shop", last); got != nil {
+		t.Error("open offers kept after the transfer")
+	}
+	o.TS = now
+	if err := s.PutDomainOffer(core.CIDForBytes(data), o, data); !errors.Is(err, ErrOfferSuperseded) {
+		t.Errorf("offer from before the last transfer: err = %v, want ErrOfferSuperseded", err)
+	}
+	if stats, _ := s.GetStats(); stats.TotalDomains != 1 {
+		t.Errorf("TotalDomains = %d, want 1", stats.TotalDomains)
+	}
+}
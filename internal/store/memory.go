@@ -0,0 +1,261 @@
+package store
+
+import (
+	"bytes"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// NewMemory returns a store that keeps everything in memory, in a sorted
+// map instead of Badger, and writes nothing to disk: tests and simulations
+// get a fresh store without a database directory. It has no data
+// directory, and snapshots, replication and garbage collection fail on
+// it.
+func NewMemory() (*Store, error) {
+	return newStore(newMemDB(), "", false)
+}
+
+// memDB is a kvDB in memory. Its committed entries are an immutable slice
+// sorted by key that each commit replaces, so a read transaction keeps the
+// entries committed when it began. Write transactions run one at a time
+// and never conflict.
+type memDB struct {
+	mu      sync.Mutex // held by the write transaction running
+	entries atomic.Pointer[[]memEntry]
+	closed  atomic.Bool
+}
+
+type memEntry struct {
+	key       string
+	value     []byte
+	meta      byte
+	expiresAt uint64 // Unix seconds, 0 for never
+	deleted   bool   // only in a transaction's writes
+}
+
+func (e *memEntry) expired() bool {
+	return e.expiresAt != 0 && e.expiresAt <= uint64(time.Now().Unix())
+}
+
+func newMemDB() *memDB {
+	db := &memDB{}
+	db.entries.Store(&[]memEntry{})
+	return db
+}
+
+func (db *memDB) View(fn func(txn kvTxn) error) error {
+	if db.closed.Load() {
+		return badger.ErrDBClosed
+	}
+	txn := db.NewReadTxn()
+	defer txn.Discard()
+	return fn(txn)
+}
+
+func (db *memDB) Update(fn func(txn kvTxn) error) error {
+	if db.closed.Load() {
+		return badger.ErrDBClosed
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	txn := &memTxn{entries: *db.entries.Load(), writes: make(map[string]memEntry)}
+	if err := fn(txn); err != nil {
+		return err
+	}
+	if len(txn.writes) > 0 {
+		writes := make([]memEntry, 0, len(txn.writes))
+		for _, e := range txn.writes {
+			writes = append(writes, e)
+		}
+		slices.SortFunc(writes, compareEntries)
+		merged := mergeEntries(txn.entries, writes)
+		db.entries.Store(&merged)
+	}
+	return nil
+}
+
+func (db *memDB) NewReadTxn() kvTxn {
+	return &memTxn{entries: *db.entries.Load()}
+}
+
+func (db *memDB) Close() error {
+	db.closed.Store(true)
+	return nil
+}
+
+func compareEntries(a, b memEntry) int {
+	return strings.Compare(a.key, b.key)
+}
+
+// mergeEntries returns the entries of a and b sorted by key, those of b
+// replacing a's of the same key, without deleted and expired ones.
+func mergeEntries(a, b []memEntry) []memEntry {
+	out := make([]memEntry, 0, len(a)+len(b))
+	for len(a) > 0 || len(b) > 0 {
+		var e memEntry
+		switch {
+		case len(b) == 0 || len(a) > 0 && a[0].key < b[0].key:
+			e, a = a[0], a[1:]
+		case len(a) == 0 || b[0].key < a[0].key:
+			e, b = b[0], b[1:]
+		default:
+			e, a, b = b[0], a[1:], b[1:]
+		}
+		if !e.deleted && !e.expired() {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// memTxn reads the entries committed when it began and, in a write
+// transaction, its own writes.
+type memTxn struct {
+	entries []memEntry
+	writes  map[string]memEntry // nil in read transactions
+}
+
+func (t *memTxn) Get(key []byte) (kvItem, error) {
+	if len(key) == 0 {
+		return nil, badger.ErrEmptyKey
+	}
+	e, ok := t.writes[string(key)]
+	if !ok {
+		i, found := slices.BinarySearchFunc(t.entries, memEntry{key: string(key)}, compareEntries)
+		if !found {
+			return nil, badger.ErrKeyNotFound
+		}
+		e = t.entries[i]
+	}
+	if e.deleted || e.expired() {
+		return nil, badger.ErrKeyNotFound
+	}
+	return memItem{&e}, nil
+}
+
+func (t *memTxn) Set(key, val []byte) error {
+	return t.SetEntry(badger.NewEntry(key, val))
+}
+
+func (t *memTxn) SetEntry(e *badger.Entry) error {
+	if t.writes == nil {
+		return badger.ErrReadOnlyTxn
+	}
+	if len(e.Key) == 0 {
+		return badger.ErrEmptyKey
+	}
+	t.writes[string(e.Key)] = memEntry{key: string(e.Key), value: bytes.Clone(e.Value), meta: e.UserMeta, expiresAt: e.ExpiresAt}
+	return nil
+}
+
+func (t *memTxn) Delete(key []byte) error {
+	if t.writes == nil {
+		return badger.ErrReadOnlyTxn
+	}
+	if len(key) == 0 {
+		return badger.ErrEmptyKey
+	}
+	t.writes[string(key)] = memEntry{key: string(key), deleted: true}
+	return nil
+}
+
+// NewIterator iterates over the entries under opts.Prefix as they are
+// when it is created, the transaction's writes included.
+func (t *memTxn) NewIterator(opts badger.IteratorOptions) kvIterator {
+	prefix := string(opts.Prefix)
+	lo := sort.Search(len(t.entries), func(i int) bool { return t.entries[i].key >= prefix })
+	hi := lo
+	for hi < len(t.entries) && strings.HasPrefix(t.entries[hi].key, prefix) {
+		hi++
+	}
+	var writes []memEntry
+	for k, e := range t.writes {
+		if strings.HasPrefix(k, prefix) {
+			writes = append(writes, e)
+		}
+	}
+	slices.SortFunc(writes, compareEntries)
+	entries := mergeEntries(t.entries[lo:hi], writes)
+	if opts.Reverse {
+		slices.Reverse(entries)
+	}
+	return &memIterator{entries: entries, reverse: opts.Reverse}
+}
+
+func (t *memTxn) Discard() {}
+
+type memIterator struct {
+	entries []memEntry // in iteration order
+	pos     int
+	reverse bool
+}
+
+func (it *memIterator) Rewind() {
+	it.pos = 0
+}
+
+// Seek moves to the first entry at or after key, or at or before it when
+// iterating in reverse. An empty key rewinds.
+func (it *memIterator) Seek(key []byte) {
+	if len(key) == 0 {
+		it.Rewind()
+		return
+	}
+	k := string(key)
+	it.pos = sort.Search(len(it.entries), func(i int) bool {
+		if it.reverse {
+			return it.entries[i].key <= k
+		}
+		return it.entries[i].key >= k
+	})
+}
+
+func (it *memIterator) Valid() bool {
+	return it.pos < len(it.entries)
+}
+
+func (it *memIterator) ValidForPrefix(prefix []byte) bool {
+	return it.Valid() && strings.HasPrefix(it.entries[it.pos].key, string(prefix))
+}
+
+func (it *memIterator) Next() {
+	it.pos++
+}
+
+func (it *memIterator) Item() kvItem {
+	return memItem{&it.entries[it.pos]}
+}
+
+func (it *memIterator) Close() {}
+
+type memItem struct{ e *memEntry }
+
+func (i memItem) Key() []byte {
+	return []byte(i.e.key)
+}
+
+func (i memItem) KeyCopy(dst []byte) []byte {
+	return append(dst[:0], i.e.key...)
+}
+
+func (i memItem) Value(fn func(val []byte) error) error {
+	return fn(i.e.value)
+}
+
+func (i memItem) ValueCopy(dst []byte) ([]byte, error) {
+	return append(dst[:0], i.e.value...), nil
+}
+
+func (i memItem) ValueSize() int64 {
+	return int64(len(i.e.value))
+}
+
+func (i memItem) UserMeta() byte {
+	return i.e.meta
+}
@@ -0,0 +1,111 @@
+package store
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+func TestMemDBTransactions(t *testing.T) {
+	db := newMemDB()
+	set := func(kv ...string) {
+		t.Helper()
+		if err := db.Update(func(txn kvTxn) error {
+			for i := 0; i < len(kv); i += 2 {
+				if err := txn.Set([]byte(kv[i]), []byte(kv[i+1])); err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	set("a:1", "one", "a:2", "two", "a:3", "three", "b:1", "other")
+
+	// A read transaction keeps what was committed when it began
+	old := db.NewReadTxn()
+	defer old.Discard()
+	set("a:2", "TWO")
+	if err := db.Update(func(txn kvTxn) error { return txn.Delete([]byte("a:3")) }); err != nil {
+		t.Fatal(err)
+	}
+	if got := memValue(t, old, "a:2"); got != "two" {
+		t.Errorf("old transaction reads a:2 = %q", got)
+	}
+
+	// A write transaction sees its own writes, in iterators too
+	err := db.Update(func(txn kvTxn) error {
+		if err := txn.Set([]byte("a:0"), []byte("zero")); err != nil {
+			return err
+		}
+		if got := memKeys(txn, badger.IteratorOptions{Prefix: []byte("a:")}, nil); got != "a:0 a:1 a:2" {
+			t.Errorf("forward = %q", got)
+		}
+		if got := memKeys(txn, badger.IteratorOptions{Prefix: []byte("a:"), Reverse: true}, []byte("a:1\xff")); got != "a:1 a:0" {
+			t.Errorf("reverse from a:1 = %q", got)
+		}
+		return errors.New("roll back")
+	})
+	if err == nil {
+		t.Fatal("Update() did not return fn's error")
+	}
+	err = db.View(func(txn kvTxn) error {
+		if _, err := txn.Get([]byte("a:0")); !errors.Is(err, badger.ErrKeyNotFound) {
+			t.Errorf("rolled back write: Get() = %v", err)
+		}
+		if _, err := txn.Get([]byte("a:3")); !errors.Is(err, badger.ErrKeyNotFound) {
+			t.Errorf("deleted key: Get() = %v", err)
+		}
+		if err := txn.Set([]byte("c"), nil); !errors.Is(err, badger.ErrReadOnlyTxn) {
+			t.Errorf("Set() in a read transaction = %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Entries with a TTL disappear once it passes
+	err = db.Update(func(txn kvTxn) error {
+		e := badger.NewEntry([]byte("a:9"), []byte("gone"))
+		e.ExpiresAt = uint64(time.Now().Add(-time.Second).Unix())
+		return txn.SetEntry(e)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.View(func(txn kvTxn) error {
+		if got := memKeys(txn, badger.IteratorOptions{}, nil); got != "a:1 a:2 b:1" {
+			t.Errorf("all keys = %q", got)
+		}
+		return nil
+	})
+}
+
+func memValue(t *testing.T, txn kvTxn, key string) string {
+	t.Helper()
+	item, err := txn.Get([]byte(key))
+	if err != nil {
+		t.Fatalf("Get(%s): %v", key, err)
+	}
+	v, _ := item.ValueCopy(nil)
+	return string(v)
+}
+
+// memKeys lists the keys an iterator with opts visits from seek, from the
+// start if seek is nil.
+func memKeys(txn kvTxn, opts badger.IteratorOptions, seek []byte) string {
+	it := txn.NewIterator(opts)
+	defer it.Close()
+	var keys string
+	for it.Seek(seek); it.Valid(); it.Next() {
+		if keys != "" {
+			keys += " "
+		}
+		keys += string(it.Item().Key())
+	}
+	return keys
+}
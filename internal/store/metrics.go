@@ -0,0 +1,163 @@
+package store
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"alxnet/internal/core"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// The node samples its activity every minute. Each sample is merged into a
+// bucket of every resolution, stored as metrics:<resolution>:<bucket start>
+// with the start as 8 big-endian bytes of unix seconds, and buckets older
+// than their resolution keeps are dropped as new samples arrive.
+
+// MetricResolution is one downsampling level of the metrics history.
+type MetricResolution struct {
+	Name string
+	Step time.Duration // bucket width
+	Keep time.Duration // how far back buckets are kept
+}
+
+// MetricResolutions are the stored resolutions, finest first.
+var MetricResolutions = []MetricResolution{
+	{"minute", time.Minute, 24 * time.Hour},
+	{"hour", time.Hour, 30 * 24 * time.Hour},
+	{"day", 24 * time.Hour, 365 * 24 * time.Hour},
+}
+
+// MetricSample is the node's activity in one bucket. Gauges hold the last
+// value sampled in the bucket, counters the total over it.
+type MetricSample struct {
+	Time         int64 `cbor:"0,keyasint" json:"time"`           // unix seconds, bucket start
+	Uptime       int64 `cbor:"1,keyasint" json:"uptime_seconds"` // gauge
+	Peers        int   `cbor:"2,keyasint" json:"peers"`          // gauge
+	StorageBytes int64 `cbor:"3,keyasint" json:"storage_bytes"`  // gauge
+	Sites        int64 `cbor:"4,keyasint" json:"sites"`          // gauge
+	Served       int64 `cbor:"5,keyasint" json:"bytes_served"`   // counter
+	Received     int64 `cbor:"6,keyasint" json:"bytes_received"` // counter
+	Updates      int64 `cbor:"7,keyasint" json:"updates"`        // counter, accepted updates
+}
+
+// merge folds a later sample of the same bucket into m.
+func (m *MetricSample) merge(later MetricSample) {
+	m.Uptime = later.Uptime
+	m.Peers = later.Peers
+	m.StorageBytes = later.StorageBytes
+	m.Sites = later.Sites
+	m.Served += later.Served
+	m.Received += later.Received
+	m.Updates += later.Updates
+}
+
+// MetricResolutionByName returns the resolution called name.
+func MetricResolutionByName(name string) (MetricResolution, bool) {
+	for _, r := range MetricResolutions {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return MetricResolution{}, false
+}
+
+func metricPrefix(res string) []byte { return []byte("metrics:" + res + ":") }
+
+func metricKey(res string, t int64) []byte {
+	return binary.BigEndian.AppendUint64(metricPrefix(res), uint64(t))
+}
+
+// PutMetricSample merges a sample taken at sample.Time into the buckets of
+// every resolution and drops buckets that have aged out.
+func (s *Store) PutMetricSample(sample MetricSample) error {
+	if sample.Time <= 0 {
+		return fmt.Errorf("invalid sample time: %d", sample.Time)
+	}
+	return s.db.Update(func(txn kvTxn) error {
+		for _, res := range MetricResolutions {
+			step := int64(res.Step / time.Second)
+			bucket := sample
+			bucket.Time = sample.Time - sample.Time%step
+			key := metricKey(res.Name, bucket.Time)
+			item, err := txn.Get(key)
+			switch {
+			case errors.Is(err, badger.ErrKeyNotFound):
+			case err != nil:
+				return err
+			default:
+				var stored MetricSample
+				if err := item.Value(func(v []byte) error { return core.UnmarshalCBOR(v, &stored) }); err == nil {
+					stored.merge(sample)
+					bucket = stored
+				}
+			}
+			data, err := core.MarshalCanonical(bucket)
+			if err != nil {
+				return err
+			}
+			if err := txn.Set(key, data); err != nil {
+				return err
+			}
+			if err := pruneMetrics(txn, res, sample.Time-int64(res.Keep/time.Second)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// pruneMetrics deletes the buckets of res that start before cutoff.
+func pruneMetrics(txn kvTxn, res MetricResolution, cutoff int64) error {
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = metricPrefix(res.Name)
+	opts.PrefetchValues = false
+	it := txn.NewIterator(opts)
+	var old [][]byte
+	for it.Rewind(); it.Valid(); it.Next() {
+		key := it.Item().Key()
+		if int64(binary.BigEndian.Uint64(key[len(opts.Prefix):])) >= cutoff {
+			break
+		}
+		old = append(old, it.Item().KeyCopy(nil))
+	}
+	it.Close()
+	for _, k := range old {
+		if err := txn.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MetricHistory returns the buckets of res from the one holding since
+// onward, oldest first.
+func (s *Store) MetricHistory(res MetricResolution, since time.Time) ([]MetricSample, error) {
+	if _, ok := MetricResolutionByName(res.Name); !ok {
+		return nil, fmt.Errorf("unknown metrics resolution: %q", res.Name)
+	}
+	prefix := metricPrefix(res.Name)
+	start := since.Unix()
+	if start < 0 {
+		start = 0
+	}
+	start -= start % int64(res.Step/time.Second)
+	var samples []MetricSample
+	err := s.db.View(func(txn kvTxn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Seek(metricKey(res.Name, start)); it.Valid(); it.Next() {
+			var m MetricSample
+			if err := it.Item().Value(func(v []byte) error { return core.UnmarshalCBOR(v, &m) }); err != nil {
+				continue
+			}
+			samples = append(samples, m)
+		}
+		return nil
+	})
+	return samples, err
+}
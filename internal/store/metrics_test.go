@@ -0,0 +1,70 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMetricHistory(t *testing.T) {
+	s, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer s.Close()
+
+	// Three samples in the first two minutes of a day, then one two days on
+	day := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC).Unix()
+	samples := []MetricSample{
+		{Time: day + 10, Peers: 2, StorageBytes: 100, Served: 5, Updates: 1},
+		{Time: day + 50, Peers: 4, StorageBytes: 120, Served: 7, Updates: 2},
+		{Time: day + 70, Peers: 3, StorageBytes: 130, Served: 1},
+		{Time: day + 2*86400, Peers: 1, StorageBytes: 200, Received: 9},
+	}
+	for _, m := range samples {
+		if err := s.PutMetricSample(m); err != nil {
+			t.Fatalf("PutMetricSample: %v", err)
+		}
+	}
+
+	tests := []struct {
+		res  string
+		want []MetricSample
+	}{
+		// The minutes of the first day have aged out
+		{"minute", []MetricSample{{Time: day + 2*86400, Peers: 1, StorageBytes: 200, Received: 9}}},
+		{"hour", []MetricSample{
+			{Time: day, Peers: 3, StorageBytes: 130, Served: 13, Updates: 3},
+			{Time: day + 2*86400, Peers: 1, StorageBytes: 200, Received: 9},
+		}},
+		{"day", []MetricSample{
+			{Time: day, Peers: 3, StorageBytes: 130, Served: 13, Updates: 3},
+			{Time: day + 2*86400, Peers: 1, StorageBytes: 200, Received: 9},
+		}},
+	}
+	for _, tt := range tests {
+		res, _ := MetricResolutionByName(tt.res)
+		got, err := s.MetricHistory(res, time.Unix(0, 0))
+		if err != nil {
+			t.Fatalf("MetricHistory(%s): %v", tt.res, err)
+		}
+		if len(got) != len(tt.want) {
+			t.Fatalf("MetricHistory(%s) = %+v, want %+v", tt.res, got, tt.want)
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("MetricHistory(%s)[%d] = %+v, want %+v", tt.res, i, got[i], tt.want[i])
+			}
+		}
+	}
+
+	res, _ := MetricResolutionByName("hour")
+	if got, _ := s.MetricHistory(res, time.Unix(day+1800, 0)); len(got) != 2 {
+		t.Errorf("MetricHistory from within the first bucket = %+v", got)
+	}
+	if got, _ := s.MetricHistory(res, time.Unix(day+3600, 0)); len(got) != 1 {
+		t.Errorf("MetricHistory from the second hour = %+v", got)
+	}
+	if err := s.PutMetricSample(MetricSample{}); err == nil {
+		t.Error("sample without a time accepted")
+	}
+}
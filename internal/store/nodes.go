@@ -0,0 +1,143 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"alxnet/internal/core"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Operators running several nodes register the others with one of them so
+// its node UI can show them all. Each is kept as remotenode:<name>.
+
+// Remote node limits
+const (
+	MaxRemoteNodes     = 64
+	MaxRemoteNodeName  = 32
+	MaxRemoteNodeURL   = 512
+	MaxRemoteNodeToken = 256
+)
+
+// ErrRemoteNodesFull is returned when registering another node would
+// exceed MaxRemoteNodes.
+var ErrRemoteNodesFull = errors.New("too many remote nodes")
+
+// RemoteNode is another node's management interface and its admin token.
+// The token is stored so the node can call it, but never encoded to JSON.
+type RemoteNode struct {
+	Name  string    `cbor:"name" json:"name"`
+	URL   string    `cbor:"url" json:"url"`
+	Token string    `cbor:"token" json:"-"`
+	Added time.Time `cbor:"added" json:"added"`
+}
+
+// ValidRemoteNodeName reports whether name can name a remote node: 1 to
+// MaxRemoteNodeName lowercase letters, digits, '-' and '_'.
+func ValidRemoteNodeName(name string) bool {
+	if name == "" || len(name) > MaxRemoteNodeName {
+		return false
+	}
+	for _, c := range name {
+		if !((c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+func remoteNodeKey(name string) []byte { return []byte("remotenode:" + name) }
+
+// PutRemoteNode registers n, replacing a node registered under the same
+// name.
+func (s *Store) PutRemoteNode(n *RemoteNode) error {
+	if !ValidRemoteNodeName(n.Name) {
+		return fmt.Errorf("invalid node name: %q", n.Name)
+	}
+	if n.URL == "" || len(n.URL) > MaxRemoteNodeURL {
+		return fmt.Errorf("node URL must be 1 to %d bytes", MaxRemoteNodeURL)
+	}
+	if len(n.Token) > MaxRemoteNodeToken {
+		return fmt.Errorf("token too long: %d > %d", len(n.Token), MaxRemoteNodeToken)
+	}
+	data, err := core.MarshalCanonical(n)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(txn kvTxn) error {
+		if _, err := txn.Get(remoteNodeKey(n.Name)); errors.Is(err, badger.ErrKeyNotFound) {
+			if countPrefix(txn, []byte("remotenode:")) >= MaxRemoteNodes {
+				return ErrRemoteNodesFull
+			}
+		} else if err != nil {
+			return err
+		}
+		return txn.Set(remoteNodeKey(n.Name), data)
+	})
+}
+
+// RemoteNodes returns the registered nodes by name.
+func (s *Store) RemoteNodes() ([]RemoteNode, error) {
+	var nodes []RemoteNode
+	err := s.db.View(func(txn kvTxn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte("remotenode:")
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			var n RemoteNode
+			if err := it.Item().Value(func(v []byte) error { return core.UnmarshalCBOR(v, &n) }); err != nil {
+				continue
+			}
+			nodes = append(nodes, n)
+		}
+		return nil
+	})
+	return nodes, err
+}
+
+// GetRemoteNode returns the node registered as name, or nil.
+func (s *Store) GetRemoteNode(name string) (*RemoteNode, error) {
+	if !ValidRemoteNodeName(name) {
+		return nil, fmt.Errorf("invalid node name: %q", name)
+	}
+	var n *RemoteNode
+	err := s.db.View(func(txn kvTxn) error {
+		item, err := txn.Get(remoteNodeKey(name))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		n = new(RemoteNode)
+		return item.Value(func(v []byte) error { return core.UnmarshalCBOR(v, n) })
+	})
+	if err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// DeleteRemoteNode removes the node registered as name and reports whether
+// there was one.
+func (s *Store) DeleteRemoteNode(name string) (bool, error) {
+	if !ValidRemoteNodeName(name) {
+		return false, fmt.Errorf("invalid node name: %q", name)
+	}
+	found := false
+	err := s.db.Update(func(txn kvTxn) error {
+		_, err := txn.Get(remoteNodeKey(name))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		found = true
+		return txn.Delete(remoteNodeKey(name))
+	})
+	return found, err
+}
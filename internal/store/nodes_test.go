@@ -0,0 +1,54 @@
+package store
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRemoteNodes(t *testing.T) {
+	s := openTestStore(t)
+
+	seeder := &RemoteNode{Name: "seeder-1", URL: "http://10.0.0.2:8082", Token: "secret"}
+	if err := s.PutRemoteNode(seeder); err != nil {
+		t.Fatalf("PutRemoteNode: %v", err)
+	}
+	got, err := s.GetRemoteNode("seeder-1")
+	if err != nil || got == nil || got.URL != seeder.URL || got.Token != "secret" {
+		t.Fatalf("GetRemoteNode() = %+v, %v", got, err)
+	}
+	if b, _ := json.Marshal(got); strings.Contains(string(b), "secret") {
+		t.Errorf("token encoded to JSON: %s", b)
+	}
+	seeder.URL = "http://10.0.0.3:8082"
+	if err := s.PutRemoteNode(seeder); err != nil {
+		t.Fatalf("PutRemoteNode (replace): %v", err)
+	}
+	nodes, err := s.RemoteNodes()
+	if err != nil || len(nodes) != 1 || nodes[0].URL != seeder.URL {
+		t.Errorf("RemoteNodes() = %+v, %v", nodes, err)
+	}
+	if found, err := s.DeleteRemoteNode("seeder-1"); err != nil || !found {
+		t.Errorf("DeleteRemoteNode() = %v, %v", found, err)
+	}
+	if got, err := s.GetRemoteNode("seeder-1"); err != nil || got != nil {
+		t.Errorf("GetRemoteNode() after delete = %+v, %v", got, err)
+	}
+
+	tests := []struct {
+		name string
+		node RemoteNode
+	}{
+		{"empty name", RemoteNode{URL: "http://a"}},
+		{"uppercase name", RemoteNode{Name: "Seeder", URL: "http://a"}},
+		{"name with colon", RemoteNode{Name: "a:b", URL: "http://a"}},
+		{"long name", RemoteNode{Name: strings.Repeat("a", MaxRemoteNodeName+1), URL: "http://a"}},
+		{"no URL", RemoteNode{Name: "a"}},
+		{"long token", RemoteNode{Name: "a", URL: "http://a", Token: strings.Repeat("t", MaxRemoteNodeToken+1)}},
+	}
+	for _, tt := range tests {
+		if err := s.PutRemoteNode(&tt.node); err == nil {
+			t.Errorf("%s: PutRemoteNode accepted %+v", tt.name, tt.node)
+		}
+	}
+}
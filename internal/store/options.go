@@ -0,0 +1,139 @@
+package store
+
+import (
+	"fmt"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/dgraph-io/badger/v4/options"
+)
+
+// Badger's defaults suit a node with memory to spare. A profile sizes its
+// memtables, caches and value log files for how the node is used, and
+// Options can override single settings on top of the profile.
+
+// Badger profiles
+const (
+	ProfileDefault = ""        // Badger's own defaults
+	ProfileSeeder  = "seeder"  // always-on nodes storing many sites: large caches, fewer stalls
+	ProfileGateway = "gateway" // read-mostly gateways on small machines
+	ProfileLaptop  = "laptop"  // desktop nodes sharing the machine with other programs
+)
+
+// Profiles lists the profile names Options accepts.
+var Profiles = []string{ProfileDefault, ProfileSeeder, ProfileGateway, ProfileLaptop}
+
+// Options tune the database OpenWithOptions opens. Zero fields keep the
+// profile's setting.
+type Options struct {
+	Profile          string
+	ValueLogFileSize int64  // bytes per value log file
+	MemTableSize     int64  // bytes per memtable
+	Compression      string // block compression: "none", "snappy" or "zstd"
+	InMemory         bool   // keep the database in memory only, for tests
+}
+
+// Validate checks that o names a known profile and compression.
+func (o Options) Validate() error {
+	if _, ok := profiles[o.Profile]; !ok {
+		return fmt.Errorf("unknown storage profile %q", o.Profile)
+	}
+	if _, err := compression(o.Compression); err != nil {
+		return err
+	}
+	if o.ValueLogFileSize < 0 || o.MemTableSize < 0 {
+		return fmt.Errorf("storage sizes must not be negative")
+	}
+	return nil
+}
+
+// OpenWithOptions opens or creates the store in dir with Badger tuned by
+// opts. An in-memory store writes nothing to dir.
+func OpenWithOptions(dir string, opts Options) (*Store, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	return open(dir, false, opts.tune)
+}
+
+// tune applies o to Badger's options.
+func (o Options) tune(opts badger.Options) badger.Options {
+	if p := profiles[o.Profile]; p != nil {
+		opts = p(opts)
+	}
+	if o.ValueLogFileSize > 0 {
+		opts = opts.WithValueLogFileSize(o.ValueLogFileSize)
+	}
+	if o.MemTableSize > 0 {
+		opts = opts.WithMemTableSize(o.MemTableSize)
+	}
+	if o.Compression != "" {
+		c, _ := compression(o.Compression)
+		opts = opts.WithCompression(c)
+	}
+	if o.InMemory {
+		opts = opts.WithDir("").WithValueDir("").WithInMemory(true)
+	}
+	return opts
+}
+
+var profiles = map[string]func(badger.Options) badger.Options{
+	ProfileDefault: nil,
+	ProfileSeeder:  seederOptions,
+	ProfileGateway: lowMemoryOptions,
+	ProfileLaptop:  laptopOptions,
+}
+
+// lowMemoryOptions shrinks Badger's defaults of five 64 MiB memtables and
+// a 256 MiB block cache for small machines, such as gateways on a small
+// VPS: it trades write throughput for a footprint of a few tens of
+// megabytes.
+func lowMemoryOptions(opts badger.Options) badger.Options {
+	return opts.
+		WithMemTableSize(8 << 20).
+		WithNumMemtables(2).
+		WithNumLevelZeroTables(2).
+		WithNumLevelZeroTablesStall(4).
+		WithBlockCacheSize(8 << 20).
+		WithIndexCacheSize(4 << 20).
+		WithValueLogFileSize(64 << 20).
+		WithNumCompactors(2)
+}
+
+// seederOptions grows the block cache and memtables so a node serving many
+// sites keeps more of them in memory, and compacts with more workers.
+func seederOptions(opts badger.Options) badger.Options {
+	return opts.
+		WithMemTableSize(128 << 20).
+		WithNumLevelZeroTables(8).
+		WithNumLevelZeroTablesStall(16).
+		WithBlockCacheSize(1 << 30).
+		WithIndexCacheSize(256 << 20).
+		WithValueLogFileSize(1 << 30).
+		WithNumCompactors(4)
+}
+
+// laptopOptions sits between Badger's defaults and lowMemoryOptions: a
+// footprint of around a hundred megabytes, with zstd blocks to spare disk.
+func laptopOptions(opts badger.Options) badger.Options {
+	return opts.
+		WithMemTableSize(16 << 20).
+		WithNumMemtables(3).
+		WithBlockCacheSize(32 << 20).
+		WithIndexCacheSize(16 << 20).
+		WithValueLogFileSize(128 << 20).
+		WithNumCompactors(2).
+		WithCompression(options.ZSTD)
+}
+
+// compression returns the block compression name stands for.
+func compression(name string) (options.CompressionType, error) {
+	switch name {
+	case "none":
+		return options.None, nil
+	case "", "snappy":
+		return options.Snappy, nil
+	case "zstd":
+		return options.ZSTD, nil
+	}
+	return 0, fmt.Errorf("unknown compression %q, want none, snappy or zstd", name)
+}
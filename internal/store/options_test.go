@@ -0,0 +1,50 @@
+package store
+
+import (
+	"os"
+	"testing"
+
+	"alxnet/internal/core"
+)
+
+func TestOpenWithOptionsInMemory(t *testing.T) {
+	dir := t.TempDir()
+	s, err := OpenWithOptions(dir, Options{Profile: ProfileLaptop, Compression: "zstd", InMemory: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := []byte("kept in memory")
+	cid := core.CIDForContent(content)
+	if err := s.PutContent(cid, content); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.GetContent(cid); err != nil || string(got) != string(content) {
+		t.Fatalf("GetContent() = %q, %v", got, err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("in-memory store wrote %d files to its directory", len(entries))
+	}
+}
+
+func TestOptionsValidate(t *testing.T) {
+	for _, p := range Profiles {
+		if err := (Options{Profile: p}).Validate(); err != nil {
+			t.Errorf("profile %q: %v", p, err)
+		}
+	}
+	for _, o := range []Options{
+		{Profile: "server"},
+		{Compression: "lz4"},
+		{MemTableSize: -1},
+	} {
+		if err := o.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded", o)
+		}
+		if _, err := OpenWithOptions(t.TempDir(), o); err == nil {
+			t.Errorf("OpenWithOptions(%+v) succeeded", o)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package store
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"alxnet/internal/core"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// The node remembers the addresses of peers it has talked to as
+// knownpeer:<peer ID>, with when it last reached each and how often dials
+// have failed since, so after a restart it can rejoin the network without
+// waiting for bootstrap peers and without redialling dead addresses.
+
+// Known peer limits
+const (
+	MaxKnownPeers     = 1000 // the peer reached longest ago makes room for a new one
+	MaxKnownPeerAddrs = 8
+	maxKnownPeerID    = 128
+	maxKnownPeerAddr  = 256
+)
+
+// KnownPeer is a peer's addresses and its dial history.
+type KnownPeer struct {
+	ID       string   `cbor:"id" json:"id"`
+	Addrs    []string `cbor:"addrs" json:"addrs"`         // multiaddrs without /p2p/
+	LastSeen int64    `cbor:"last_seen" json:"last_seen"` // unix seconds of the last connection
+	Failures int      `cbor:"failures" json:"failures"`   // failed dials since LastSeen
+	NextDial int64    `cbor:"next_dial" json:"next_dial"` // unix seconds before which it is not dialled
+}
+
+func knownPeerKey(id string) []byte { return []byte("knownpeer:" + id) }
+
+func validKnownPeer(p *KnownPeer) error {
+	if p.ID == "" || len(p.ID) > maxKnownPeerID || strings.ContainsAny(p.ID, ":/") {
+		return fmt.Errorf("invalid peer ID: %q", p.ID)
+	}
+	if len(p.Addrs) == 0 || len(p.Addrs) > MaxKnownPeerAddrs {
+		return fmt.Errorf("a known peer needs 1 to %d addresses", MaxKnownPeerAddrs)
+	}
+	for _, a := range p.Addrs {
+		if a == "" || len(a) > maxKnownPeerAddr {
+			return fmt.Errorf("invalid peer address: %q", a)
+		}
+	}
+	return nil
+}
+
+// PutKnownPeer stores p, replacing what was known of the same peer. When
+// MaxKnownPeers are known, the one reached longest ago is forgotten.
+func (s *Store) PutKnownPeer(p *KnownPeer) error {
+	if err := validKnownPeer(p); err != nil {
+		return err
+	}
+	data, err := core.MarshalCanonical(p)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(txn kvTxn) error {
+		if _, err := txn.Get(knownPeerKey(p.ID)); errors.Is(err, badger.ErrKeyNotFound) {
+			if countPrefix(txn, []byte("knownpeer:")) >= MaxKnownPeers {
+				if err := evictKnownPeer(txn); err != nil {
+					return err
+				}
+			}
+		} else if err != nil {
+			return err
+		}
+		return txn.Set(knownPeerKey(p.ID), data)
+	})
+}
+
+// evictKnownPeer deletes the known peer reached longest ago.
+func evictKnownPeer(txn kvTxn) error {
+	var oldest []byte
+	oldestSeen := int64(-1)
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = []byte("knownpeer:")
+	it := txn.NewIterator(opts)
+	for it.Rewind(); it.Valid(); it.Next() {
+		var p KnownPeer
+		if err := it.Item().Value(func(v []byte) error { return core.UnmarshalCBOR(v, &p) }); err != nil {
+			oldest = it.Item().KeyCopy(nil) // unreadable, drop it first
+			break
+		}
+		if oldestSeen < 0 || p.LastSeen < oldestSeen {
+			oldest, oldestSeen = it.Item().KeyCopy(nil), p.LastSeen
+		}
+	}
+	it.Close()
+	if oldest == nil {
+		return nil
+	}
+	return txn.Delete(oldest)
+}
+
+// GetKnownPeer returns what is known of the peer id, or nil.
+func (s *Store) GetKnownPeer(id string) (*KnownPeer, error) {
+	var p *KnownPeer
+	err := s.db.View(func(txn kvTxn) error {
+		item, err := txn.Get(knownPeerKey(id))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		p = new(KnownPeer)
+		return item.Value(func(v []byte) error { return core.UnmarshalCBOR(v, p) })
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// KnownPeers returns the known peers, most recently reached first.
+func (s *Store) KnownPeers() ([]KnownPeer, error) {
+	var peers []KnownPeer
+	err := s.db.View(func(txn kvTxn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte("knownpeer:")
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			var p KnownPeer
+			if err := it.Item().Value(func(v []byte) error { return core.UnmarshalCBOR(v, &p) }); err != nil {
+				continue
+			}
+			peers = append(peers, p)
+		}
+		return nil
+	})
+	slices.SortStableFunc(peers, func(a, b KnownPeer) int { return cmp.Compare(b.LastSeen, a.LastSeen) })
+	return peers, err
+}
+
+// DeleteKnownPeer forgets the peer id.
+func (s *Store) DeleteKnownPeer(id string) error {
+	return s.db.Update(func(txn kvTxn) error {
+		return txn.Delete(knownPeerKey(id))
+	})
+}
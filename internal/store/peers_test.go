@@ -0,0 +1,71 @@
+package store
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestKnownPeers(t *testing.T) {
+	s := openTestStore(t)
+
+	for i, seen := range []int64{100, 300, 200} {
+		p := &KnownPeer{ID: fmt.Sprintf("12D3KooPeer%d", i), Addrs: []string{"/ip4/10.0.0.1/tcp/4001"}, LastSeen: seen}
+		if err := s.PutKnownPeer(p); err != nil {
+			t.Fatalf("PutKnownPeer(%s): %v", p.ID, err)
+		}
+	}
+	peers, err := s.KnownPeers()
+	if err != nil || len(peers) != 3 {
+		t.Fatalf("KnownPeers() = %+v, %v", peers, err)
+	}
+	if peers[0].ID != "12D3KooPeer1" || peers[2].ID != "12D3KooPeer0" {
+		t.Errorf("KnownPeers() not most recent first: %+v", peers)
+	}
+
+	update := &KnownPeer{ID: "12D3KooPeer0", Addrs: []string{"/ip6/::1/tcp/4001"}, LastSeen: 100, Failures: 2, NextDial: 500}
+	if err := s.PutKnownPeer(update); err != nil {
+		t.Fatalf("PutKnownPeer (update): %v", err)
+	}
+	if got, err := s.GetKnownPeer("12D3KooPeer0"); err != nil || got == nil || got.Failures != 2 || got.Addrs[0] != "/ip6/::1/tcp/4001" {
+		t.Errorf("GetKnownPeer() = %+v, %v", got, err)
+	}
+	if err := s.DeleteKnownPeer("12D3KooPeer0"); err != nil {
+		t.Fatalf("DeleteKnownPeer: %v", err)
+	}
+	if got, err := s.GetKnownPeer("12D3KooPeer0"); err != nil || got != nil {
+		t.Errorf("GetKnownPeer() after delete = %+v, %v", got, err)
+	}
+
+	for _, p := range []KnownPeer{
+		{ID: "", Addrs: []string{"/ip4/10.0.0.1/tcp/4001"}},
+		{ID: "knownpeer:x", Addrs: []string{"/ip4/10.0.0.1/tcp/4001"}},
+		{ID: "12D3KooNoAddrs"},
+	} {
+		if err := s.PutKnownPeer(&p); err == nil {
+			t.Errorf("PutKnownPeer(%+v) accepted", p)
+		}
+	}
+}
+
+func TestKnownPeersEvictOldest(t *testing.T) {
+	s := openTestStore(t)
+	for i := range MaxKnownPeers {
+		p := &KnownPeer{ID: fmt.Sprintf("12D3KooPeer%d", i), Addrs: []string{"/ip4/10.0.0.1/tcp/4001"}, LastSeen: int64(1000 + i)}
+		if i == 500 {
+			p.LastSeen = 1 // reached longest ago
+		}
+		if err := s.PutKnownPeer(p); err != nil {
+			t.Fatalf("PutKnownPeer(%d): %v", i, err)
+		}
+	}
+	if err := s.PutKnownPeer(&KnownPeer{ID: "12D3KooNewcomer", Addrs: []string{"/ip4/10.0.0.2/tcp/4001"}, LastSeen: 5000}); err != nil {
+		t.Fatalf("PutKnownPeer (full): %v", err)
+	}
+	peers, _ := s.KnownPeers()
+	if len(peers) != MaxKnownPeers || peers[0].ID != "12D3KooNewcomer" {
+		t.Errorf("got %d peers, newest %s; want %d, newcomer first", len(peers), peers[0].ID, MaxKnownPeers)
+	}
+	if got, _ := s.GetKnownPeer("12D3KooPeer500"); got != nil {
+		t.Error("the peer reached longest ago was kept")
+	}
+}
@@ -0,0 +1,123 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+
+	"alxnet/internal/core"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// MaxPointersPerOwner bounds the pointer names kept for one owner key.
+const MaxPointersPerOwner = 100
+
+// ErrTooManyPointers is returned when a new pointer name would exceed
+// MaxPointersPerOwner.
+var ErrTooManyPointers = errors.New("too many pointers for owner")
+
+func pointerPrefix(ownerID string) []byte { return []byte("pointer:" + ownerID + ":") }
+
+// PutPointer stores a verified pointer record unless a record with the same
+// or a higher Seq is already stored for its owner and name. It reports
+// whether the record was applied.
+func (s *Store) PutPointer(ownerID string, rec *core.PointerRecord, data []byte) (bool, error) {
+	if err := s.validateKeyValue(ownerID, data); err != nil {
+		return false, fmt.Errorf("validation failed: %w", err)
+	}
+	if !core.ValidPointerName(rec.Name) {
+		return false, fmt.Errorf("invalid pointer name: %q", rec.Name)
+	}
+
+	s.pointerMu.Lock()
+	defer s.pointerMu.Unlock()
+
+	applied := false
+	err := s.db.Update(func(txn kvTxn) error {
+		key := append(pointerPrefix(ownerID), rec.Name...)
+		it, err := txn.Get(key)
+		switch {
+		case err == nil:
+			var cur core.PointerRecord
+			if err := it.Value(func(v []byte) error {
+				return core.UnmarshalCBOR(v, &cur)
+			}); err != nil {
+				return err
+			}
+			if cur.Seq >= rec.Seq {
+				return nil
+			}
+		case err == badger.ErrKeyNotFound:
+			if countPrefix(txn, pointerPrefix(ownerID)) >= MaxPointersPerOwner {
+				return ErrTooManyPointers
+			}
+		default:
+			return err
+		}
+		applied = true
+		return txn.Set(key, data)
+	})
+	return applied, err
+}
+
+func countPrefix(txn kvTxn, prefix []byte) int {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	it := txn.NewIterator(opts)
+	defer it.Close()
+	n := 0
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		n++
+	}
+	return n
+}
+
+// GetPointer returns the pointer record of ownerID named name, or nil if it
+// is not stored.
+func (s *Store) GetPointer(ownerID, name string) (*core.PointerRecord, error) {
+	if err := s.validateKey(ownerID); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+	if !core.ValidPointerName(name) {
+		return nil, fmt.Errorf("invalid pointer name: %q", name)
+	}
+	var rec *core.PointerRecord
+	err := s.db.View(func(txn kvTxn) error {
+		it, err := txn.Get(append(pointerPrefix(ownerID), name...))
+		if err == badger.ErrKeyNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return it.Value(func(v []byte) error {
+			rec = &core.PointerRecord{}
+			return core.UnmarshalCBOR(v, rec)
+		})
+	})
+	return rec, err
+}
+
+// ListPointers returns the pointer records of ownerID sorted by name.
+func (s *Store) ListPointers(ownerID string) ([]core.PointerRecord, error) {
+	if err := s.validateKey(ownerID); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+	var out []core.PointerRecord
+	err := s.db.View(func(txn kvTxn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		prefix := pointerPrefix(ownerID)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var rec core.PointerRecord
+			if err := it.Item().Value(func(v []byte) error {
+				return core.UnmarshalCBOR(v, &rec)
+			}); err != nil {
+				return err
+			}
+			out = append(out, rec)
+		}
+		return nil
+	})
+	return out, err
+}
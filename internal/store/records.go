@@ -0,0 +1,205 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+
+	"alxnet/internal/core"
+
+	"github.com/dgraph-io/badger/v4"
+	"go.uber.org/zap"
+)
+
+// Records are stored by CID, so walking a site's history by PrevCID takes
+// one read per update. Update records are therefore also indexed as
+// site:<id>:rec:<seq> -> record CID, with seq zero-padded to 20 digits so
+// the keys sort numerically and a site's records can be range-scanned.
+
+// MaxSiteRecords is the most records one SiteRecords call returns.
+const MaxSiteRecords = 1000
+
+// siteRecordIndexed marks a store whose records written before the index
+// existed have been indexed.
+const siteRecordIndexed = "index:site-records"
+
+// RecordRef is the record of a site at one sequence number.
+type RecordRef struct {
+	Seq uint64 `json:"seq"`
+	CID string `json:"cid"`
+}
+
+func siteRecordPrefix(siteID string) []byte {
+	return []byte("site:" + siteID + ":rec:")
+}
+
+func siteRecordKey(siteID string, seq uint64) []byte {
+	return fmt.Appendf(siteRecordPrefix(siteID), "%020d", seq)
+}
+
+// recordSite returns the site and sequence number of an update record, or
+// ok false for data that is not one.
+func recordSite(data []byte) (siteID string, seq uint64, ok bool) {
+	var rec core.UpdateRecord
+	if err := core.UnmarshalCBOR(data, &rec); err != nil || len(rec.SitePub) != 32 || rec.Seq == 0 {
+		return "", 0, false
+	}
+	return core.SiteIDFromPub(rec.SitePub), rec.Seq, true
+}
+
+// indexRecord adds the site index entry of the record stored as cid.
+func indexRecord(txn *countedTxn, cid string, data []byte) error {
+	siteID, seq, ok := recordSite(data)
+	if !ok {
+		return nil
+	}
+	return txn.Set(siteRecordKey(siteID, seq), []byte(cid))
+}
+
+// unindexRecord removes the site index entry of the record stored as cid,
+// unless the entry has moved on to another record.
+func unindexRecord(txn *countedTxn, cid string) error {
+	item, err := txn.Get([]byte("record:" + cid))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	data, err := item.ValueCopy(nil)
+	if err != nil {
+		return err
+	}
+	siteID, seq, ok := recordSite(data)
+	if !ok {
+		return nil
+	}
+	key := siteRecordKey(siteID, seq)
+	item, err = txn.Get(key)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	indexed, err := item.ValueCopy(nil)
+	if err != nil || string(indexed) != cid {
+		return err
+	}
+	return txn.Delete(key)
+}
+
+// SiteRecords returns up to limit records of siteID with sequence numbers
+// from from upward, oldest first.
+func (s *Store) SiteRecords(siteID string, from uint64, limit int) ([]RecordRef, error) {
+	return s.scanSiteRecords(siteID, from, limit, false)
+}
+
+// SiteRecordsBefore returns up to limit records of siteID with sequence
+// numbers below before, newest first. before 0 starts at the newest.
+func (s *Store) SiteRecordsBefore(siteID string, before uint64, limit int) ([]RecordRef, error) {
+	if before == 1 {
+		return nil, nil
+	}
+	start := before - 1 // before 0 wraps to the largest sequence number
+	return s.scanSiteRecords(siteID, start, limit, true)
+}
+
+func (s *Store) scanSiteRecords(siteID string, start uint64, limit int, reverse bool) ([]RecordRef, error) {
+	if err := s.validateKey(siteID); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+	if limit <= 0 || limit > MaxSiteRecords {
+		limit = MaxSiteRecords
+	}
+	prefix := siteRecordPrefix(siteID)
+	var refs []RecordRef
+	err := s.db.View(func(txn kvTxn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		opts.Reverse = reverse
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Seek(siteRecordKey(siteID, start)); it.Valid() && len(refs) < limit; it.Next() {
+			var seq uint64
+			if _, err := fmt.Sscanf(string(it.Item().Key()[len(prefix):]), "%d", &seq); err != nil {
+				continue
+			}
+			cid, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			refs = append(refs, RecordRef{Seq: seq, CID: string(cid)})
+		}
+		return nil
+	})
+	return refs, err
+}
+
+// indexStoredRecords indexes the records of a store written before the
+// site record index existed, once.
+func (s *Store) indexStoredRecords() error {
+	done := false
+	err := s.db.View(func(txn kvTxn) error {
+		_, err := txn.Get([]byte(siteRecordIndexed))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		done = err == nil
+		return err
+	})
+	if err != nil || done {
+		return err
+	}
+
+	// Index in batches, each read before it is written, so that neither
+	// transaction grows with the store
+	const batch = 1000
+	type entry struct {
+		cid  string
+		data []byte
+	}
+	indexed := 0
+	seek := []byte("record:")
+	for {
+		var entries []entry
+		err := s.db.View(func(txn kvTxn) error {
+			opts := badger.DefaultIteratorOptions
+			opts.Prefix = []byte("record:")
+			it := txn.NewIterator(opts)
+			defer it.Close()
+			for it.Seek(seek); it.Valid() && len(entries) < batch; it.Next() {
+				data, err := it.Item().ValueCopy(nil)
+				if err != nil {
+					return err
+				}
+				entries = append(entries, entry{string(it.Item().Key()[len("record:"):]), data})
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			break
+		}
+		err = s.update(func(txn *countedTxn) error {
+			for _, e := range entries {
+				if err := indexRecord(txn, e.cid, e.data); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		indexed += len(entries)
+		seek = append([]byte("record:"+entries[len(entries)-1].cid), 0)
+	}
+	if indexed > 0 {
+		s.logger.Info("indexed stored records by site", zap.Int("records", indexed))
+	}
+	return s.db.Update(func(txn kvTxn) error {
+		return txn.Set([]byte(siteRecordIndexed), []byte{1})
+	})
+}
@@ -0,0 +1,123 @@
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"alxnet/internal/core"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// putTestRecord stores an update record of the site with sitePub at seq.
+func putTestRecord(t *testing.T, s *Store, sitePub []byte, seq uint64) string {
+	t.Helper()
+	rec := core.UpdateRecord{Version: core.RecordV1, SitePub: sitePub, Seq: seq, ContentCID: "c0ffee", TS: int64(seq)}
+	data, err := core.MarshalCanonical(&rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cid := core.CIDForBytes(data)
+	if err := s.PutRecord(cid, data); err != nil {
+		t.Fatalf("PutRecord: %v", err)
+	}
+	return cid
+}
+
+func refsString(refs []RecordRef) string {
+	var b bytes.Buffer
+	for i, r := range refs {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprint(&b, r.Seq)
+	}
+	return b.String()
+}
+
+func TestSiteRecords(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	pubA, pubB := bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 32)
+	siteA := core.SiteIDFromPub(pubA)
+	cids := make(map[uint64]string)
+	// 9 and 10 check that records sort by seq, not key text
+	for _, seq := range []uint64{1, 2, 3, 9, 10} {
+		cids[seq] = putTestRecord(t, s, pubA, seq)
+	}
+	putTestRecord(t, s, pubB, 4)
+	if err := s.PutRecord(core.CIDForBytes([]byte("x")), []byte("not a record")); err != nil {
+		t.Fatalf("PutRecord: %v", err)
+	}
+
+	check := func(when string) {
+		t.Helper()
+		tests := []struct {
+			name string
+			list func() ([]RecordRef, error)
+			want string
+		}{
+			{"all", func() ([]RecordRef, error) { return s.SiteRecords(siteA, 0, 0) }, "1,2,3,9,10"},
+			{"from 3", func() ([]RecordRef, error) { return s.SiteRecords(siteA, 3, 2) }, "3,9"},
+			{"from past the head", func() ([]RecordRef, error) { return s.SiteRecords(siteA, 11, 10) }, ""},
+			{"newest", func() ([]RecordRef, error) { return s.SiteRecordsBefore(siteA, 0, 3) }, "10,9,3"},
+			{"before 9", func() ([]RecordRef, error) { return s.SiteRecordsBefore(siteA, 9, 10) }, "3,2,1"},
+			{"before 1", func() ([]RecordRef, error) { return s.SiteRecordsBefore(siteA, 1, 10) }, ""},
+			{"other site", func() ([]RecordRef, error) { return s.SiteRecords(core.SiteIDFromPub(pubB), 0, 0) }, "4"},
+		}
+		for _, tt := range tests {
+			refs, err := tt.list()
+			if err != nil {
+				t.Fatalf("%s, %s: %v", when, tt.name, err)
+			}
+			if got := refsString(refs); got != tt.want {
+				t.Errorf("%s, %s: seqs = %q, want %q", when, tt.name, got, tt.want)
+			}
+			for _, r := range refs {
+				if r.CID != cids[r.Seq] && tt.name != "other site" {
+					t.Errorf("%s, %s: seq %d has CID %s, want %s", when, tt.name, r.Seq, r.CID, cids[r.Seq])
+				}
+			}
+		}
+	}
+	check("written")
+
+	// Deleting a record drops its index entry
+	if err := s.DeleteRecord(cids[9]); err != nil {
+		t.Fatalf("DeleteRecord: %v", err)
+	}
+	if refs, _ := s.SiteRecordsBefore(siteA, 0, 1); refsString(refs) != "10" {
+		t.Errorf("newest after delete = %q", refsString(refs))
+	}
+	if refs, _ := s.SiteRecords(siteA, 4, 0); refsString(refs) != "10" {
+		t.Errorf("from 4 after delete = %q, want 10", refsString(refs))
+	}
+	cids[9] = putTestRecord(t, s, pubA, 9)
+
+	if _, err := s.SiteRecords("../x", 0, 0); err == nil {
+		t.Error("SiteRecords accepted an invalid site ID")
+	}
+
+	// A store from before the index is indexed on open
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	db, err := badger.Open(badger.DefaultOptions(dir).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.DropPrefix([]byte(siteRecordIndexed), siteRecordPrefix(siteA)); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	s, err = Open(dir)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer s.Close()
+	check("reindexed")
+}
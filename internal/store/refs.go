@@ -0,0 +1,231 @@
+package store
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"alxnet/internal/core"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Content references count the file path mappings (site:<id>:file:<path>)
+// pointing at a content CID, across all sites. A count that drops to zero is
+// kept, so that PruneContent can tell unreferenced content from content
+// stored before references were counted, which is never pruned.
+
+func contentRefKey(cid string) []byte { return []byte("contentref:" + cid) }
+
+func getContentRef(txn kvTxn, cid string) (uint64, bool, error) {
+	it, err := txn.Get(contentRefKey(cid))
+	if err == badger.ErrKeyNotFound {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	var n uint64
+	err = it.Value(func(v []byte) error {
+		if len(v) != 8 {
+			return fmt.Errorf("corrupt reference count for %s", cid)
+		}
+		n = binary.BigEndian.Uint64(v)
+		return nil
+	})
+	return n, true, err
+}
+
+// addContentRef adjusts the reference count of cid by delta. Decrementing
+// content that was never counted leaves it uncounted.
+func addContentRef(txn kvTxn, cid string, delta int) error {
+	n, tracked, err := getContentRef(txn, cid)
+	if err != nil {
+		return err
+	}
+	switch {
+	case delta < 0 && !tracked:
+		return nil
+	case delta < 0 && n < uint64(-delta):
+		n = 0
+	default:
+		n = uint64(int64(n) + int64(delta))
+	}
+	var v [8]byte
+	binary.BigEndian.PutUint64(v[:], n)
+	return txn.Set(contentRefKey(cid), v[:])
+}
+
+// fileContentCID returns the content CID of the file record a path mapping
+// points at, or "" if the mapping does not exist.
+func fileContentCID(txn kvTxn, mapping []byte) (string, error) {
+	it, err := txn.Get(mapping)
+	if err == badger.ErrKeyNotFound {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	recordCID, err := it.ValueCopy(nil)
+	if err != nil {
+		return "", err
+	}
+	recItem, err := txn.Get([]byte("filerecord:" + string(recordCID)))
+	if err == badger.ErrKeyNotFound {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	var rec core.FileRecord
+	err = recItem.Value(func(v []byte) error {
+		return core.UnmarshalCBOR(v, &rec)
+	})
+	return rec.ContentCID, err
+}
+
+// ContentRefs returns the reference count of cid, and false if the content
+// is not counted.
+func (s *Store) ContentRefs(cid string) (uint64, bool, error) {
+	var (
+		n       uint64
+		tracked bool
+	)
+	err := s.db.View(func(txn kvTxn) error {
+		var err error
+		n, tracked, err = getContentRef(txn, cid)
+		return err
+	})
+	return n, tracked, err
+}
+
+// DeleteFileRecord removes the path mapping of a website file and drops the
+// reference it held on its content. It returns the content CID, or "" if
+// the path had no file.
+func (s *Store) DeleteFileRecord(siteID, filePath string) (string, error) {
+	if err := s.validateKey(siteID); err != nil {
+		return "", fmt.Errorf("validation failed: %w", err)
+	}
+	var cid string
+	err := s.update(func(txn *countedTxn) error {
+		mapping := []byte("site:" + siteID + ":file:" + filePath)
+		var err error
+		if cid, err = fileContentCID(txn.kvTxn, mapping); err != nil {
+			return err
+		}
+		if err := txn.Delete(mapping); err != nil {
+			return err
+		}
+		if cid == "" {
+			return nil
+		}
+		return addContentRef(txn.kvTxn, cid, -1)
+	})
+	return cid, err
+}
+
+// PruneContent deletes the content cid if no file references it any more
+// and no pinned site's current manifest or head uses it. It reports whether
+// the content was deleted.
+func (s *Store) PruneContent(cid string) (bool, error) {
+	n, tracked, err := s.ContentRefs(cid)
+	if err != nil || !tracked || n > 0 {
+		return false, err
+	}
+	keep, err := s.pinnedContent()
+	if err != nil {
+		return false, err
+	}
+	if keep[cid] {
+		return false, nil
+	}
+	if err := s.DeleteContent(cid); err != nil {
+		return false, err
+	}
+	err = s.db.Update(func(txn kvTxn) error {
+		// A file may have been saved with this content meanwhile
+		if n, _, err := getContentRef(txn, cid); err != nil || n > 0 {
+			return err
+		}
+		return txn.Delete(contentRefKey(cid))
+	})
+	return true, err
+}
+
+// SiteContent returns the content CIDs that the stored update records, the
+// current manifest, the file records and the drafts of siteID point at.
+func (s *Store) SiteContent(siteID string) (map[string]bool, error) {
+	if err := s.validateKey(siteID); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+	cids := make(map[string]bool)
+	heads, err := s.ListHeads(siteID)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range heads {
+		data, err := s.GetRecord(h.CID)
+		if err != nil {
+			continue
+		}
+		var rec core.UpdateRecord
+		if core.UnmarshalCBOR(data, &rec) == nil {
+			cids[rec.ContentCID] = true
+		}
+	}
+	if data, err := s.GetCurrentWebsiteManifest(siteID); err == nil {
+		var manifest core.WebsiteManifest
+		if core.UnmarshalCBOR(data, &manifest) == nil {
+			for _, cid := range manifest.Files {
+				cids[cid] = true
+			}
+		}
+	}
+	err = s.db.View(func(txn kvTxn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		prefix := []byte("site:" + siteID + ":file:")
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			cid, err := fileContentCID(txn, it.Item().KeyCopy(nil))
+			if err != nil {
+				return err
+			}
+			if cid != "" {
+				cids[cid] = true
+			}
+		}
+		prefix = draftPrefix(siteID)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			cid, _, err := draftContentCID(txn, it.Item().KeyCopy(nil))
+			if err != nil {
+				return err
+			}
+			if cid != "" {
+				cids[cid] = true
+			}
+		}
+		return nil
+	})
+	return cids, err
+}
+
+// ContentSites returns the sites whose SiteContent includes cid.
+func (s *Store) ContentSites(cid string) ([]string, error) {
+	sites, err := s.ListSites()
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, siteID := range sites {
+		cids, err := s.SiteContent(siteID)
+		if err != nil {
+			return nil, err
+		}
+		if cids[cid] {
+			out = append(out, siteID)
+		}
+	}
+	return out, nil
+}
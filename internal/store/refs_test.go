@@ -0,0 +1,119 @@
+package store
+
+import (
+	"slices"
+	"testing"
+
+	"alxnet/internal/core"
+)
+
+func TestContentRefs(t *testing.T) {
+	s := openTestStore(t)
+	const siteA = "aa00000000000000000000000000000000000000000000000000000000000000"
+	const siteB = "bb00000000000000000000000000000000000000000000000000000000000000"
+
+	put := func(content string) string {
+		cid := core.CIDForContent([]byte(content))
+		if err := s.PutContent(cid, []byte(content)); err != nil {
+			t.Fatalf("PutContent: %v", err)
+		}
+		return cid
+	}
+	save := func(siteID, path, cid string) {
+		rec := core.FileRecord{Version: "v1", Path: path, ContentCID: cid, MimeType: "text/html", TS: 1}
+		data, err := core.CanonicalMarshalFileRecord(&rec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.PutFileRecord(siteID, path, core.CIDForBytes(data), data); err != nil {
+			t.Fatalf("PutFileRecord: %v", err)
+		}
+	}
+	refs := func(cid string) uint64 {
+		n, _, err := s.ContentRefs(cid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	shared, v1, v2 := put("shared"), put("v1"), put("v2")
+	save(siteA, "a.html", shared)
+	save(siteB, "b.html", shared)
+	save(siteA, "page.html", v1)
+	save(siteA, "page.html", v2) // replaces v1
+	save(siteA, "page.html", v2) // same content again
+	if refs(shared) != 2 || refs(v1) != 0 || refs(v2) != 1 {
+		t.Fatalf("refs = %d, %d, %d; want 2, 0, 1", refs(shared), refs(v1), refs(v2))
+	}
+
+	if deleted, err := s.PruneContent(v1); err != nil || !deleted {
+		t.Errorf("PruneContent(v1) = %v, %v", deleted, err)
+	}
+	if has, _ := s.HasContent(v1); has {
+		t.Error("unreferenced content was not deleted")
+	}
+
+	cid, err := s.DeleteFileRecord(siteA, "a.html")
+	if err != nil || cid != shared {
+		t.Fatalf("DeleteFileRecord = %q, %v", cid, err)
+	}
+	if deleted, _ := s.PruneContent(shared); deleted {
+		t.Error("content still used by another site was deleted")
+	}
+
+	// Content stored before references were counted is never pruned
+	legacy := put("legacy")
+	if deleted, _ := s.PruneContent(legacy); deleted {
+		t.Error("uncounted content was deleted")
+	}
+	if cid, err := s.DeleteFileRecord(siteA, "missing.html"); err != nil || cid != "" {
+		t.Errorf("DeleteFileRecord(missing) = %q, %v", cid, err)
+	}
+}
+
+func TestSiteContent(t *testing.T) {
+	s := openTestStore(t)
+	const siteA = "aa00000000000000000000000000000000000000000000000000000000000000"
+	const siteB = "bb00000000000000000000000000000000000000000000000000000000000000"
+	manifest := putTestWebsite(t, s, siteA)
+
+	// Site B has only an update record, sharing site A's stylesheet
+	shared := manifest.Files["css/style.css"]
+	head, err := core.CanonicalMarshal(&core.UpdateRecord{Version: core.RecordV1, Seq: 1, ContentCID: shared, TS: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PutRecord(core.CIDForBytes(head), head); err != nil {
+		t.Fatalf("PutRecord: %v", err)
+	}
+	if err := s.PutHead(siteB, 1, core.CIDForBytes(head)); err != nil {
+		t.Fatalf("PutHead: %v", err)
+	}
+
+	cids, err := s.SiteContent(siteA)
+	if err != nil {
+		t.Fatalf("SiteContent: %v", err)
+	}
+	if len(cids) != 2 || !cids[manifest.Files["index.html"]] || !cids[shared] {
+		t.Errorf("SiteContent(A) = %v, want the two files", cids)
+	}
+	tests := []struct {
+		cid  string
+		want []string
+	}{
+		{manifest.Files["index.html"], []string{siteA}},
+		{shared, []string{siteA, siteB}},
+		{core.CIDForContent([]byte("elsewhere")), nil},
+	}
+	for _, tt := range tests {
+		got, err := s.ContentSites(tt.cid)
+		if err != nil {
+			t.Fatalf("ContentSites: %v", err)
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("ContentSites(%s) = %v, want %v", tt.cid[:8], got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package store
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/dgraph-io/badger/v4/pb"
+)
+
+// Replication keeps follower stores in step with a leader node. The leader
+// writes the entries committed after a version in Badger's backup format:
+// length-prefixed KV lists carrying each entry's version. A follower loads
+// them as they are, so its versions match the leader's, and asks for the
+// entries after the newest version it loaded on its next pull. Deletions
+// travel as tombstones until the leader compacts them away, so a follower
+// that falls far behind may keep entries the leader has deleted.
+
+// maxChangeList bounds one list in a change stream. Badger's stream sends
+// lists of about 100 MB at most.
+const maxChangeList = 128 << 20
+
+// badgerDeleteBit marks a tombstone in a KV's meta byte.
+const badgerDeleteBit = 1 << 0
+
+// ErrNotEmpty is returned when a follower would load a leader's changes
+// into a store that already holds data of its own.
+var ErrNotEmpty = errors.New("store is not empty")
+
+// WriteChanges writes the entries committed after version since to w, all
+// of them when since is 0, and returns the version to pass next time.
+func (s *Store) WriteChanges(w io.Writer, since uint64) (uint64, error) {
+	db, err := s.onDisk()
+	if err != nil {
+		return 0, err
+	}
+	stream := db.NewStream()
+	stream.LogPrefix = "WriteChanges"
+	stream.SinceTs = since
+	// Followers count their own statistics
+	stream.ChooseKey = func(item *badger.Item) bool {
+		return string(item.Key()) != statsKey
+	}
+	last, err := stream.Backup(w, since)
+	if err != nil {
+		return 0, fmt.Errorf("failed to write changes: %w", err)
+	}
+	return max(last, since), nil
+}
+
+// ApplyChanges loads a change stream written by WriteChanges. Content
+// deleted by the stream is dropped from the content cache, and the
+// statistics are counted again a while later.
+func (s *Store) ApplyChanges(r io.Reader) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	db, err := s.onDisk()
+	if err != nil {
+		return err
+	}
+	cr := &changeReader{r: r}
+	err = db.Load(cr, 256)
+	if c := s.contentCache(); c != nil {
+		for _, cid := range cr.deleted {
+			c.remove(cid)
+		}
+	}
+	if cr.lists > 0 {
+		s.markStatsStale()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to apply changes: %w", err)
+	}
+	return nil
+}
+
+// Empty reports whether the store holds no entries besides its statistics
+// and index markers.
+func (s *Store) Empty() (bool, error) {
+	empty := true
+	err := s.db.View(func(txn kvTxn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			if key := string(it.Item().Key()); key != statsKey && key != siteRecordIndexed {
+				empty = false
+				break
+			}
+		}
+		return nil
+	})
+	return empty, err
+}
+
+// changeReader passes a change stream through, rejecting lists over
+// maxChangeList before Badger allocates them and noting deleted content.
+type changeReader struct {
+	r       io.Reader
+	buf     []byte // the rest of the current list, header included
+	lists   int
+	deleted []string
+}
+
+func (cr *changeReader) Read(p []byte) (int, error) {
+	if len(cr.buf) == 0 {
+		if err := cr.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, cr.buf)
+	cr.buf = cr.buf[n:]
+	return n, nil
+}
+
+// next reads the following list into buf.
+func (cr *changeReader) next() error {
+	var header [8]byte
+	if _, err := io.ReadFull(cr.r, header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return fmt.Errorf("truncated change stream: %w", err)
+		}
+		return err // io.EOF ends the stream
+	}
+	size := binary.LittleEndian.Uint64(header[:])
+	if size > maxChangeList {
+		return fmt.Errorf("change list of %d bytes exceeds %d", size, maxChangeList)
+	}
+	buf := make([]byte, 8+size)
+	copy(buf, header[:])
+	if _, err := io.ReadFull(cr.r, buf[8:]); err != nil {
+		return fmt.Errorf("truncated change stream: %w", io.ErrUnexpectedEOF)
+	}
+	var list pb.KVList
+	if err := list.Unmarshal(buf[8:]); err != nil {
+		return fmt.Errorf("invalid change list: %w", err)
+	}
+	for _, kv := range list.Kv {
+		if len(kv.Meta) > 0 && kv.Meta[0]&badgerDeleteBit != 0 {
+			if cid, ok := strings.CutPrefix(string(kv.Key), "content:"); ok {
+				cr.deleted = append(cr.deleted, cid)
+			}
+		}
+	}
+	cr.buf = buf
+	cr.lists++
+	return nil
+}
@@ -0,0 +1,95 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestReplication(t *testing.T) {
+	leader, follower := openBadgerTestStore(t), openBadgerTestStore(t)
+	const siteID = "aa00000000000000000000000000000000000000000000000000000000000000"
+	manifest := putTestWebsite(t, leader, siteID)
+
+	if empty, err := follower.Empty(); err != nil || !empty {
+		t.Fatalf("Empty() of a new store = %v, %v", empty, err)
+	}
+	sync := func(since uint64) uint64 {
+		t.Helper()
+		var buf bytes.Buffer
+		next, err := leader.WriteChanges(&buf, since)
+		if err != nil {
+			t.Fatalf("WriteChanges(%d): %v", since, err)
+		}
+		if err := follower.ApplyChanges(&buf); err != nil {
+			t.Fatalf("ApplyChanges: %v", err)
+		}
+		return next
+	}
+	next := sync(0)
+	if empty, _ := follower.Empty(); empty {
+		t.Fatal("follower still empty after the first sync")
+	}
+	if !follower.HasWebsiteManifest(siteID) {
+		t.Fatal("follower has no manifest")
+	}
+	var cid string
+	for _, c := range manifest.Files {
+		cid = c
+		break
+	}
+	if data, err := follower.GetContent(cid); err != nil || data == nil {
+		t.Fatalf("GetContent(%s) on the follower = %v, %v", cid, data, err)
+	}
+
+	if again := sync(next); again != next {
+		t.Errorf("sync without changes moved the version from %d to %d", next, again)
+	}
+	if err := leader.DeleteContent(cid); err != nil {
+		t.Fatalf("DeleteContent: %v", err)
+	}
+	sync(next)
+	if data, _ := follower.GetContent(cid); data != nil {
+		t.Error("content deleted on the leader is still served by the follower")
+	}
+}
+
+func TestApplyChangesLimits(t *testing.T) {
+	s := openBadgerTestStore(t)
+	header := func(size uint64) []byte {
+		return binary.LittleEndian.AppendUint64(nil, size)
+	}
+	tests := []struct {
+		name   string
+		stream []byte
+		want   string
+	}{
+		{"oversized list", header(maxChangeList + 1), "exceeds"},
+		{"truncated header", []byte{1, 2, 3}, "truncated"},
+		{"truncated list", append(header(100), 0, 0), "truncated"},
+		{"garbage list", append(header(4), 0xff, 0xff, 0xff, 0xff), "invalid"},
+	}
+	for _, tt := range tests {
+		err := s.ApplyChanges(bytes.NewReader(tt.stream))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: ApplyChanges() = %v, want error containing %q", tt.name, err, tt.want)
+		}
+	}
+
+	dir := t.TempDir()
+	if w, err := Open(dir); err != nil {
+		t.Fatalf("Open: %v", err)
+	} else {
+		w.Close()
+	}
+	ro, err := OpenReadOnly(dir)
+	if err != nil {
+		t.Fatalf("OpenReadOnly: %v", err)
+	}
+	defer ro.Close()
+	if err := ro.ApplyChanges(bytes.NewReader(nil)); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("ApplyChanges() on a read-only store = %v, want ErrReadOnly", err)
+	}
+}
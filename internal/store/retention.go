@@ -0,0 +1,395 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"alxnet/internal/core"
+
+	"github.com/dgraph-io/badger/v4"
+	"go.uber.org/zap"
+)
+
+// Retention prunes site history down to a core.RetentionPolicy. Pinned
+// sites and other sites each have a policy, and a pinned site can have its
+// own (retention:<id>). Pruning drops the oldest update records, with their
+// head entries, and the oldest manifests of the chain, then the content no
+// longer used by any site.
+//
+// Update records link to their predecessor by CID, so the oldest record
+// kept links to one that is gone. Each site pruned gets a checkpoint
+// (site:<id>:checkpoint) naming that record and its missing predecessor:
+// the records from the head down to the checkpoint still verify as a
+// chain, and a peer holding the full history can check that its records
+// lead up to the checkpoint.
+
+// maxRetentionRecords bounds the update records one retention run prunes;
+// the next run continues.
+const maxRetentionRecords = 10000
+
+// RetentionRules are the policies of the two classes of sites.
+type RetentionRules struct {
+	Default core.RetentionPolicy // sites not pinned
+	Pinned  core.RetentionPolicy // pinned sites without a policy of their own
+}
+
+// Checkpoint marks where the pruned history of a site ends.
+type Checkpoint struct {
+	Seq     uint64 `cbor:"seq" json:"seq"`       // oldest update kept
+	CID     string `cbor:"cid" json:"cid"`       // its record CID
+	PrevCID string `cbor:"prev" json:"prev_cid"` // the pruned record it links to
+	Pruned  uint64 `cbor:"pruned" json:"pruned"` // update records pruned so far
+	TS      int64  `cbor:"ts" json:"ts"`         // when it was last moved
+}
+
+// RetentionReport summarises one retention run.
+type RetentionReport struct {
+	Started   time.Time `json:"started"`
+	Sites     int       `json:"sites"` // sites pruned
+	Records   int       `json:"records"`
+	Manifests int       `json:"manifests"`
+	Content   int       `json:"content"`
+	Problems  []string  `json:"problems,omitempty"`
+	Truncated bool      `json:"truncated"` // stopped at the record limit
+}
+
+func retentionKey(siteID string) []byte { return []byte("retention:" + siteID) }
+
+func checkpointKey(siteID string) []byte { return []byte("site:" + siteID + ":checkpoint") }
+
+// SetSiteRetention gives a site a policy of its own, replacing the policy
+// of its class.
+func (s *Store) SetSiteRetention(siteID string, p core.RetentionPolicy) error {
+	if err := s.validateKey(siteID); err != nil {
+		return fmt.Errorf("validation failed: %w", err)
+	}
+	return s.db.Update(func(txn kvTxn) error {
+		return txn.Set(retentionKey(siteID), []byte(p.String()))
+	})
+}
+
+// ClearSiteRetention removes a site's own policy; it is not an error if it
+// had none.
+func (s *Store) ClearSiteRetention(siteID string) error {
+	if err := s.validateKey(siteID); err != nil {
+		return fmt.Errorf("validation failed: %w", err)
+	}
+	return s.db.Update(func(txn kvTxn) error {
+		return txn.Delete(retentionKey(siteID))
+	})
+}
+
+// SiteRetentions returns the sites with a policy of their own.
+func (s *Store) SiteRetentions() (map[string]core.RetentionPolicy, error) {
+	out := make(map[string]core.RetentionPolicy)
+	err := s.db.View(func(txn kvTxn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = []byte("retention:")
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			siteID := string(it.Item().Key()[len(opts.Prefix):])
+			if err := it.Item().Value(func(v []byte) error {
+				p, err := core.ParseRetentionPolicy(string(v))
+				if err != nil {
+					return fmt.Errorf("site %s: %w", siteID, err)
+				}
+				out[siteID] = p
+				return nil
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return out, err
+}
+
+// SiteRetention returns the policy that applies to a site under rules.
+func (s *Store) SiteRetention(siteID string, rules RetentionRules) (core.RetentionPolicy, error) {
+	own, err := s.SiteRetentions()
+	if err != nil {
+		return core.RetentionPolicy{}, err
+	}
+	if p, ok := own[siteID]; ok {
+		return p, nil
+	}
+	if s.IsPinned(siteID) {
+		return rules.Pinned, nil
+	}
+	return rules.Default, nil
+}
+
+// GetCheckpoint returns the checkpoint of a site, or nil if its history
+// was never pruned.
+func (s *Store) GetCheckpoint(siteID string) (*Checkpoint, error) {
+	var cp *Checkpoint
+	err := s.db.View(func(txn kvTxn) error {
+		item, err := txn.Get(checkpointKey(siteID))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		cp = new(Checkpoint)
+		return item.Value(func(v []byte) error {
+			return core.UnmarshalCBOR(v, cp)
+		})
+	})
+	return cp, err
+}
+
+// LastRetention returns the report of the last retention run, or nil.
+func (s *Store) LastRetention() *RetentionReport {
+	return s.retentionReport.Load()
+}
+
+// ApplyRetention prunes every site down to the policy that applies to it
+// under rules.
+func (s *Store) ApplyRetention(rules RetentionRules) (*RetentionReport, error) {
+	s.retentionMu.Lock()
+	defer s.retentionMu.Unlock()
+
+	r := &RetentionReport{Started: time.Now().UTC()}
+	own, err := s.SiteRetentions()
+	if err != nil {
+		return nil, err
+	}
+	pinned, err := s.ListPinnedSites()
+	if err != nil {
+		return nil, err
+	}
+	isPinned := make(map[string]bool, len(pinned))
+	for _, id := range pinned {
+		isPinned[id] = true
+	}
+	sites, err := s.ListSites()
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make(map[string]bool)
+	for _, siteID := range sites {
+		p, ok := own[siteID]
+		switch {
+		case ok:
+		case isPinned[siteID]:
+			p = rules.Pinned
+		default:
+			p = rules.Default
+		}
+		if p.Full() {
+			continue
+		}
+		if r.Records >= maxRetentionRecords {
+			r.Truncated = true
+			break
+		}
+		records, manifests, err := s.pruneSite(siteID, p.Keep, maxRetentionRecords-r.Records, candidates)
+		if err != nil {
+			r.Problems = append(r.Problems, fmt.Sprintf("site %s: %v", siteID, err))
+			continue
+		}
+		if records+manifests > 0 {
+			r.Sites++
+			r.Records += records
+			r.Manifests += manifests
+		}
+	}
+
+	if len(candidates) > 0 {
+		if r.Content, err = s.pruneUnusedContent(candidates); err != nil {
+			return nil, err
+		}
+	}
+	s.retentionReport.Store(r)
+	s.logger.Info("retention applied",
+		zap.Int("sites", r.Sites),
+		zap.Int("records", r.Records),
+		zap.Int("manifests", r.Manifests),
+		zap.Int("content", r.Content),
+		zap.Int("problems", len(r.Problems)))
+	return r, nil
+}
+
+// pruneSite removes the update records of siteID older than the newest
+// keep, at most limit of them, and the manifests beyond the newest keep of
+// its chain, and moves its checkpoint. The content the removed objects used
+// is added to candidates.
+func (s *Store) pruneSite(siteID string, keep, limit int, candidates map[string]bool) (records, manifests int, err error) {
+	heads, err := s.ListHeads(siteID)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(heads) > keep {
+		kept, drop := heads[:keep], heads[keep:]
+		if len(drop) > limit {
+			// Oldest first, so the checkpoint only moves forward
+			drop = drop[len(drop)-limit:]
+			kept = heads[:len(heads)-limit]
+		}
+		if err := s.pruneRecords(siteID, kept, drop, candidates); err != nil {
+			return 0, 0, err
+		}
+		records = len(drop)
+	}
+	manifests, err = s.pruneManifests(siteID, keep, candidates)
+	return records, manifests, err
+}
+
+// pruneRecords deletes the records of drop. The records of kept, newest
+// first, must link to each other, so the oldest of them anchors the chain
+// as the new checkpoint.
+func (s *Store) pruneRecords(siteID string, kept, drop []HeadEntry, candidates map[string]bool) error {
+	var oldest core.UpdateRecord
+	for i, h := range kept {
+		data, err := s.GetRecord(h.CID)
+		if err != nil {
+			return fmt.Errorf("update %d: %w", h.Seq, err)
+		}
+		var rec core.UpdateRecord
+		if err := core.UnmarshalCBOR(data, &rec); err != nil {
+			return fmt.Errorf("update %d: %w", h.Seq, err)
+		}
+		if i+1 < len(kept) && (rec.PrevCID != kept[i+1].CID || rec.Seq != kept[i+1].Seq+1) {
+			return fmt.Errorf("update %d does not link to update %d, not pruning", h.Seq, kept[i+1].Seq)
+		}
+		oldest = rec
+	}
+	cp, err := s.GetCheckpoint(siteID)
+	if err != nil {
+		return err
+	}
+	if cp == nil {
+		cp = new(Checkpoint)
+	}
+	last := kept[len(kept)-1]
+	cp.Seq, cp.CID, cp.PrevCID = last.Seq, last.CID, oldest.PrevCID
+	cp.Pruned += uint64(len(drop))
+	cp.TS = time.Now().Unix()
+	cpData, err := core.MarshalCanonical(cp)
+	if err != nil {
+		return err
+	}
+
+	return s.update(func(txn *countedTxn) error {
+		for _, h := range drop {
+			if item, err := txn.Get([]byte("record:" + h.CID)); err == nil {
+				_ = item.Value(func(v []byte) error {
+					var rec core.UpdateRecord
+					if core.UnmarshalCBOR(v, &rec) == nil && rec.ContentCID != "" {
+						candidates[rec.ContentCID] = true
+					}
+					return nil
+				})
+			}
+			if err := unindexRecord(txn, h.CID); err != nil {
+				return err
+			}
+			if err := txn.Delete([]byte("record:" + h.CID)); err != nil {
+				return err
+			}
+			if err := txn.Delete(fmt.Appendf(nil, "site:%s:head:%d", siteID, h.Seq)); err != nil {
+				return err
+			}
+		}
+		return txn.Set(checkpointKey(siteID), cpData)
+	})
+}
+
+// pruneManifests deletes the manifests of siteID's chain beyond the newest
+// keep.
+func (s *Store) pruneManifests(siteID string, keep int, candidates map[string]bool) (int, error) {
+	data, err := s.GetCurrentWebsiteManifest(siteID)
+	if err != nil {
+		return 0, nil // no website
+	}
+	var drop []string
+	for hops := 1; hops < maxManifestChain; hops++ {
+		var m core.WebsiteManifest
+		if core.UnmarshalCBOR(data, &m) != nil || m.PrevCID == "" {
+			break
+		}
+		if data, err = s.GetWebsiteManifest(m.PrevCID); err != nil {
+			break // pruned or never stored
+		}
+		if hops >= keep {
+			drop = append(drop, m.PrevCID)
+			var old core.WebsiteManifest
+			if core.UnmarshalCBOR(data, &old) == nil {
+				for _, cid := range old.Files {
+					candidates[cid] = true
+				}
+			}
+		}
+	}
+	if len(drop) == 0 {
+		return 0, nil
+	}
+	err = s.update(func(txn *countedTxn) error {
+		for _, cid := range drop {
+			if err := txn.Delete([]byte("manifest:" + cid)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return len(drop), err
+}
+
+// pruneUnusedContent deletes the content among candidates that no site,
+// file path or pinned object uses any more, and reports how much it
+// deleted.
+func (s *Store) pruneUnusedContent(candidates map[string]bool) (int, error) {
+	used, err := s.pinnedContent()
+	if err != nil {
+		return 0, err
+	}
+	sites, err := s.ListSites()
+	if err != nil {
+		return 0, err
+	}
+	for _, siteID := range sites {
+		cids, err := s.SiteContent(siteID)
+		if err != nil {
+			return 0, err
+		}
+		for cid := range cids {
+			used[cid] = true
+		}
+		// Older manifests still kept
+		data, err := s.GetCurrentWebsiteManifest(siteID)
+		for hops := 0; err == nil && hops < maxManifestChain; hops++ {
+			var m core.WebsiteManifest
+			if core.UnmarshalCBOR(data, &m) != nil {
+				break
+			}
+			for _, cid := range m.Files {
+				used[cid] = true
+			}
+			if m.PrevCID == "" {
+				break
+			}
+			data, err = s.GetWebsiteManifest(m.PrevCID)
+		}
+	}
+	deleted := 0
+	for cid := range candidates {
+		if used[cid] {
+			continue
+		}
+		if n, _, err := s.ContentRefs(cid); err != nil || n > 0 {
+			continue
+		}
+		if has, _ := s.HasContent(cid); !has {
+			continue
+		}
+		if err := s.DeleteContent(cid); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
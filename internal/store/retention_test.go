@@ -0,0 +1,182 @@
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"alxnet/internal/core"
+)
+
+// putTestChain stores updates 1..count of the site with sitePub as a chain,
+// each with its own content, and returns their record and content CIDs.
+func putTestChain(t *testing.T, s *Store, sitePub []byte, count int) (records, content []string) {
+	t.Helper()
+	siteID := core.SiteIDFromPub(sitePub)
+	prev := ""
+	for seq := 1; seq <= count; seq++ {
+		body := []byte(fmt.Sprintf("%s version %d", siteID[:8], seq))
+		ccid := core.CIDForContent(body)
+		if err := s.PutContent(ccid, body); err != nil {
+			t.Fatal(err)
+		}
+		rec := core.UpdateRecord{Version: core.RecordV1, SitePub: sitePub, Seq: uint64(seq), PrevCID: prev, ContentCID: ccid, TS: int64(seq)}
+		data, err := core.MarshalCanonical(&rec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		prev = core.CIDForBytes(data)
+		if err := s.PutRecord(prev, data); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.PutHead(siteID, uint64(seq), prev); err != nil {
+			t.Fatal(err)
+		}
+		records, content = append(records, prev), append(content, ccid)
+	}
+	return records, content
+}
+
+func TestApplyRetention(t *testing.T) {
+	s := openTestStore(t)
+	pubs := map[string][]byte{
+		"loose":    bytes.Repeat([]byte{1}, 32),
+		"pinned":   bytes.Repeat([]byte{2}, 32),
+		"own":      bytes.Repeat([]byte{3}, 32),
+		"unpinned": bytes.Repeat([]byte{4}, 32),
+	}
+	records, content := make(map[string][]string), make(map[string][]string)
+	for name, pub := range pubs {
+		records[name], content[name] = putTestChain(t, s, pub, 5)
+	}
+	id := func(name string) string { return core.SiteIDFromPub(pubs[name]) }
+	for _, name := range []string{"pinned", "own", "unpinned"} {
+		if err := s.PinSite(id(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lastTwo, _ := core.ParseRetentionPolicy("last:2")
+	if err := s.SetSiteRetention(id("own"), lastTwo); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetSiteRetention(id("unpinned"), lastTwo); err != nil {
+		t.Fatal(err)
+	}
+	// Unpinning drops the site's own policy, so it falls back to the default
+	if err := s.UnpinSite(id("unpinned")); err != nil {
+		t.Fatal(err)
+	}
+
+	rules := RetentionRules{Default: core.RetentionPolicy{Keep: 1}} // pinned: full
+	r, err := s.ApplyRetention(rules)
+	if err != nil {
+		t.Fatalf("ApplyRetention: %v", err)
+	}
+	if r.Sites != 3 || r.Records != 4+4+3 || r.Content != 11 || len(r.Problems) != 0 {
+		t.Errorf("report = %+v", r)
+	}
+	if s.LastRetention() != r {
+		t.Error("LastRetention does not return the last report")
+	}
+
+	tests := []struct {
+		site string
+		kept int
+	}{
+		{"loose", 1},
+		{"pinned", 5},
+		{"own", 2},
+		{"unpinned", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.site, func(t *testing.T) {
+			heads, err := s.ListHeads(id(tt.site))
+			if err != nil || len(heads) != tt.kept {
+				t.Fatalf("ListHeads = %d heads, %v; want %d", len(heads), err, tt.kept)
+			}
+			for i, cid := range records[tt.site] {
+				wantKept := i >= 5-tt.kept
+				if data, _ := s.GetRecord(cid); (data != nil) != wantKept {
+					t.Errorf("record of update %d kept = %v, want %v", i+1, data != nil, wantKept)
+				}
+				if has, _ := s.HasContent(content[tt.site][i]); has != wantKept {
+					t.Errorf("content of update %d kept = %v, want %v", i+1, has, wantKept)
+				}
+			}
+			if refs, _ := s.SiteRecords(id(tt.site), 0, 10); len(refs) != tt.kept {
+				t.Errorf("SiteRecords = %v, want %d", refs, tt.kept)
+			}
+
+			cp, err := s.GetCheckpoint(id(tt.site))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.kept == 5 {
+				if cp != nil {
+					t.Errorf("checkpoint %+v of an unpruned site", cp)
+				}
+				return
+			}
+			oldest := 5 - tt.kept
+			if cp == nil || cp.Seq != uint64(oldest+1) || cp.CID != records[tt.site][oldest] || cp.PrevCID != records[tt.site][oldest-1] || cp.Pruned != uint64(oldest) {
+				t.Errorf("checkpoint = %+v", cp)
+			}
+		})
+	}
+
+	// A later run prunes again and moves the checkpoint; storing the chain
+	// anew brings back the 4 records pruned before
+	more, _ := putTestChain(t, s, pubs["loose"], 7)
+	if r, err = s.ApplyRetention(rules); err != nil || r.Records != 6 {
+		t.Fatalf("second ApplyRetention = %+v, %v", r, err)
+	}
+	cp, _ := s.GetCheckpoint(id("loose"))
+	if cp == nil || cp.Seq != 7 || cp.CID != more[6] || cp.PrevCID != more[5] || cp.Pruned != 4+6 {
+		t.Errorf("checkpoint after second run = %+v", cp)
+	}
+}
+
+func TestApplyRetentionKeepsSharedContent(t *testing.T) {
+	s := openTestStore(t)
+	pubA, pubB := bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 32)
+	_, contentA := putTestChain(t, s, pubA, 3)
+
+	// Site B's current version uses the content of site A's first
+	body := []byte(fmt.Sprintf("%s version %d", core.SiteIDFromPub(pubA)[:8], 1))
+	rec := core.UpdateRecord{Version: core.RecordV1, SitePub: pubB, Seq: 1, ContentCID: contentA[0], TS: 1}
+	data, _ := core.MarshalCanonical(&rec)
+	if err := s.PutRecord(core.CIDForBytes(data), data); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PutHead(core.SiteIDFromPub(pubB), 1, core.CIDForBytes(data)); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := s.ApplyRetention(RetentionRules{Default: core.RetentionPolicy{Keep: 1}})
+	if err != nil || r.Records != 2 || r.Content != 1 {
+		t.Fatalf("ApplyRetention = %+v, %v", r, err)
+	}
+	if got, _ := s.GetContent(contentA[0]); !bytes.Equal(got, body) {
+		t.Error("content still used by another site was pruned")
+	}
+	if has, _ := s.HasContent(contentA[1]); has {
+		t.Error("unused content was kept")
+	}
+}
+
+func TestApplyRetentionSkipsBrokenChain(t *testing.T) {
+	s := openTestStore(t)
+	pub := bytes.Repeat([]byte{1}, 32)
+	records, _ := putTestChain(t, s, pub, 4)
+	// A kept record that does not link to the one before it
+	if err := s.PutHead(core.SiteIDFromPub(pub), 3, records[0]); err != nil {
+		t.Fatal(err)
+	}
+	r, err := s.ApplyRetention(RetentionRules{Default: core.RetentionPolicy{Keep: 2}})
+	if err != nil || r.Records != 0 || len(r.Problems) != 1 {
+		t.Fatalf("ApplyRetention = %+v, %v", r, err)
+	}
+	if heads, _ := s.ListHeads(core.SiteIDFromPub(pub)); len(heads) != 4 {
+		t.Errorf("%d heads left, want all 4", len(heads))
+	}
+}
@@ -0,0 +1,187 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Config configures an S3-compatible blob backend (AWS S3, MinIO, ...).
+type S3Config struct {
+	Endpoint        string // e.g. https://s3.us-east-1.amazonaws.com or http://localhost:9000
+	Region          string
+	Bucket          string
+	Prefix          string // optional key prefix, e.g. "alxnet/"
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+// S3Backend stores blobs as objects using path-style requests signed with
+// AWS Signature Version 4.
+type S3Backend struct {
+	endpoint *url.URL
+	cfg      S3Config
+	client   *http.Client
+	now      func() time.Time
+}
+
+// maxS3Object bounds how much of an object response is read.
+const maxS3Object = MaxValueLength
+
+// NewS3Backend validates cfg and returns a backend for it.
+func NewS3Backend(cfg S3Config) (*S3Backend, error) {
+	u, err := url.Parse(cfg.Endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint: %q", cfg.Endpoint)
+	}
+	if cfg.Bucket == "" || strings.ContainsAny(cfg.Bucket, "/?#") {
+		return nil, fmt.Errorf("invalid S3 bucket: %q", cfg.Bucket)
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, errors.New("S3 credentials are required")
+	}
+	if strings.Contains(cfg.Prefix, "..") || strings.HasPrefix(cfg.Prefix, "/") {
+		return nil, fmt.Errorf("invalid S3 key prefix: %q", cfg.Prefix)
+	}
+	return &S3Backend{
+		endpoint: u,
+		cfg:      cfg,
+		client:   &http.Client{Timeout: BlobTimeout},
+		now:      time.Now,
+	}, nil
+}
+
+func (b *S3Backend) Put(ctx context.Context, cid string, data []byte) error {
+	resp, err := b.do(ctx, http.MethodPut, cid, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return s3Error(resp)
+	}
+	return nil
+}
+
+func (b *S3Backend) Get(ctx context.Context, cid string) ([]byte, error) {
+	resp, err := b.do(ctx, http.MethodGet, cid, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrBlobNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, s3Error(resp)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxS3Object+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxS3Object {
+		return nil, errors.New("S3 object too large")
+	}
+	return data, nil
+}
+
+func (b *S3Backend) Delete(ctx context.Context, cid string) error {
+	resp, err := b.do(ctx, http.MethodDelete, cid, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// S3 answers 204 whether or not the object existed
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return s3Error(resp)
+	}
+	return nil
+}
+
+func (b *S3Backend) do(ctx context.Context, method, cid string, body []byte) (*http.Response, error) {
+	if !isValidHexString(cid) {
+		return nil, fmt.Errorf("invalid CID: %q", cid)
+	}
+	u := *b.endpoint
+	u.Path = strings.TrimRight(u.Path, "/") + "/" + b.cfg.Bucket + "/" + b.cfg.Prefix + cid
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	signV4(req, body, b.cfg.Region, b.cfg.AccessKeyID, b.cfg.SecretAccessKey, b.now())
+	return b.client.Do(req)
+}
+
+func s3Error(resp *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("S3 returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+}
+
+// signV4 adds AWS Signature Version 4 headers for the S3 service. Host and
+// every header already set on req are signed.
+func signV4(req *http.Request, body []byte, region, accessKey, secretKey string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	payloadHash := sha256.Sum256(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	scope := day + "/" + region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), day)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,145 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"go.uber.org/zap"
+)
+
+// Read-only stores let several gateway processes serve the same data.
+// Badger allows any number of read-only openers of a directory but none
+// while a node has it open for writing, so a running node instead writes
+// snapshots: complete copies of its store in numbered directories under a
+// snapshot root, with the name of the newest in the file CURRENT. Replicas
+// open the current snapshot read-only and switch when a newer one appears.
+
+// ErrReadOnly is returned by writes to a store opened with OpenReadOnly.
+var ErrReadOnly = badger.ErrReadOnlyTxn
+
+const (
+	snapshotCurrent = "CURRENT"
+	snapshotsKept   = 2 // the current snapshot and the one replicas may still use
+)
+
+// OpenReadOnly opens the store in dir for reading. It fails while another
+// process has the store open for writing.
+func OpenReadOnly(dir string) (*Store, error) {
+	return open(dir, true, nil)
+}
+
+// ReadOnly reports whether the store was opened with OpenReadOnly.
+func (s *Store) ReadOnly() bool {
+	return s.readOnly
+}
+
+// WriteSnapshot copies the store into a new snapshot under root, makes it
+// the current one and removes all but the newest snapshots. It returns the
+// snapshot's directory.
+func (s *Store) WriteSnapshot(root string) (string, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return "", err
+	}
+	name := strconv.FormatInt(time.Now().UnixNano(), 10)
+	dir := filepath.Join(root, name)
+	tmp := dir + ".tmp"
+	if err := s.copyTo(tmp); err != nil {
+		os.RemoveAll(tmp)
+		return "", err
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		os.RemoveAll(tmp)
+		return "", err
+	}
+	// Replace CURRENT atomically so replicas never read a partial name
+	cur := filepath.Join(root, snapshotCurrent)
+	if err := os.WriteFile(cur+".tmp", []byte(name+"\n"), 0o644); err != nil {
+		return "", err
+	}
+	if err := os.Rename(cur+".tmp", cur); err != nil {
+		return "", err
+	}
+	s.pruneSnapshots(root)
+	return dir, nil
+}
+
+// copyTo writes a consistent copy of the store to a new Badger directory.
+func (s *Store) copyTo(dir string) error {
+	db, err := s.onDisk()
+	if err != nil {
+		return err
+	}
+	dst, err := badger.Open(badger.DefaultOptions(dir).WithLogger(nil))
+	if err != nil {
+		return fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := db.Backup(pw, 0)
+		pw.CloseWithError(err)
+	}()
+	err = dst.Load(pr, 256)
+	pr.CloseWithError(err) // stops the backup if loading failed
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to copy store: %w", err)
+	}
+	return nil
+}
+
+// pruneSnapshots removes snapshot directories older than the newest
+// snapshotsKept, and leftovers of interrupted snapshots.
+func (s *Store) pruneSnapshots(root string) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return
+	}
+	var names []int64
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if strings.HasSuffix(e.Name(), ".tmp") {
+			os.RemoveAll(filepath.Join(root, e.Name()))
+			continue
+		}
+		if n, err := strconv.ParseInt(e.Name(), 10, 64); err == nil {
+			names = append(names, n)
+		}
+	}
+	slices.Sort(names)
+	for len(names) > snapshotsKept {
+		dir := filepath.Join(root, strconv.FormatInt(names[0], 10))
+		if err := os.RemoveAll(dir); err != nil {
+			s.logger.Warn("failed to remove old snapshot", zap.String("dir", dir), zap.Error(err))
+		}
+		names = names[1:]
+	}
+}
+
+// CurrentSnapshot returns the directory of the current snapshot under root,
+// or "" if root holds no snapshots.
+func CurrentSnapshot(root string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(root, snapshotCurrent))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	name := strings.TrimSpace(string(data))
+	if _, err := strconv.ParseInt(name, 10, 64); err != nil {
+		return "", fmt.Errorf("corrupt %s in %s", snapshotCurrent, root)
+	}
+	return filepath.Join(root, name), nil
+}
@@ -0,0 +1,76 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSnapshots(t *testing.T) {
+	s := openBadgerTestStore(t)
+	const siteID = "aa00000000000000000000000000000000000000000000000000000000000000"
+	manifest := putTestWebsite(t, s, siteID)
+
+	root := t.TempDir()
+	if dir, err := CurrentSnapshot(root); err != nil || dir != "" {
+		t.Fatalf("CurrentSnapshot() of an empty root = %q, %v", dir, err)
+	}
+	var dirs []string
+	for i := 0; i < 3; i++ {
+		dir, err := s.WriteSnapshot(root)
+		if err != nil {
+			t.Fatalf("WriteSnapshot: %v", err)
+		}
+		dirs = append(dirs, dir)
+	}
+	if cur, err := CurrentSnapshot(root); err != nil || cur != dirs[2] {
+		t.Fatalf("CurrentSnapshot() = %q, %v, want %q", cur, err, dirs[2])
+	}
+	if _, err := os.Stat(dirs[0]); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("oldest snapshot kept: %v", err)
+	}
+	if _, err := os.Stat(dirs[1]); err != nil {
+		t.Errorf("previous snapshot removed: %v", err)
+	}
+
+	// Several replicas can read the same snapshot
+	a, err := OpenReadOnly(dirs[2])
+	if err != nil {
+		t.Fatalf("OpenReadOnly: %v", err)
+	}
+	defer a.Close()
+	b, err := OpenReadOnly(dirs[2])
+	if err != nil {
+		t.Fatalf("second OpenReadOnly: %v", err)
+	}
+	defer b.Close()
+	if !a.ReadOnly() || s.ReadOnly() {
+		t.Error("ReadOnly() does not match how the stores were opened")
+	}
+	for path, cid := range manifest.Files {
+		data, err := b.GetContent(cid)
+		if err != nil || data == nil {
+			t.Errorf("GetContent(%s) from the snapshot = %v, %v", path, data, err)
+		}
+	}
+	if !b.HasWebsiteManifest(siteID) {
+		t.Error("snapshot has no manifest")
+	}
+	if err := a.PinSite(siteID); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("PinSite() on a read-only store = %v, want ErrReadOnly", err)
+	}
+
+	// A store open for writing cannot be opened read-only as well
+	if ro, err := OpenReadOnly(s.GetDataDir()); err == nil {
+		ro.Close()
+		t.Error("OpenReadOnly() succeeded while the store is open for writing")
+	}
+
+	if err := os.WriteFile(filepath.Join(root, snapshotCurrent), []byte("../etc\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CurrentSnapshot(root); err == nil {
+		t.Error("CurrentSnapshot() accepted a CURRENT naming another directory")
+	}
+}
@@ -0,0 +1,445 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"time"
+
+	"alxnet/internal/core"
+
+	"github.com/dgraph-io/badger/v4"
+	"go.uber.org/zap"
+)
+
+// Store statistics are counted by the writes that change them rather than
+// by scanning the keyspace whenever they are read. The counters are saved
+// under statsKey every statsSaveInterval and on Close. A store that has
+// none saved, or was not closed cleanly, counts its keys again in the
+// background on open. Keys that change without a counted write (blob cache
+// entries expiring, replicated changes) let the counters drift until the
+// next recount, which runs daily, soon after replicated changes, or on
+// request.
+
+const (
+	statsKey           = "stats:counters"
+	statsSaveInterval  = time.Minute
+	statsRecountDelay  = 5 * time.Minute // after replicated changes
+	statsRecountMaxAge = 24 * time.Hour
+	statsSitePrefix    = "site:"
+)
+
+// statsCounts are the counted totals.
+type statsCounts struct {
+	Records      int64 `json:"records"`
+	Content      int64 `json:"content"`
+	ContentBytes int64 `json:"content_bytes"`
+	Domains      int64 `json:"domains"`
+	Sites        int64 `json:"sites"`
+	Websites     int64 `json:"websites"`
+}
+
+func (c *statsCounts) add(d statsCounts) {
+	c.Records += d.Records
+	c.Content += d.Content
+	c.ContentBytes += d.ContentBytes
+	c.Domains += d.Domains
+	c.Sites += d.Sites
+	c.Websites += d.Websites
+}
+
+// savedStats is the value stored under statsKey.
+type savedStats struct {
+	Counts    statsCounts `json:"counts"`
+	CountedAt time.Time   `json:"counted_at"` // time of the last full count
+	Clean     bool        `json:"clean"`      // saved by Close
+}
+
+// storeStats holds a store's counters.
+type storeStats struct {
+	// gate lets a recount start its snapshot between counted writes:
+	// writers hold it shared from commit until they have counted.
+	gate      sync.RWMutex
+	recountMu sync.Mutex // one recount at a time
+
+	mu        sync.Mutex
+	counts    statsCounts
+	since     *statsCounts // counted since a running recount's snapshot
+	countedAt time.Time
+	stale     bool // changed by replication since countedAt
+	changed   bool // not saved since the last change
+	running   bool // a background recount is running
+
+	wg   sync.WaitGroup // background recounts and the saver
+	stop chan struct{}
+}
+
+func (st *storeStats) add(d statsCounts) {
+	if d == (statsCounts{}) {
+		return
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.counts.add(d)
+	if st.since != nil {
+		st.since.add(d)
+	}
+	st.changed = true
+}
+
+// countedTxn is a write transaction that counts how its sets and deletes
+// change the statistics.
+type countedTxn struct {
+	kvTxn
+	d statsCounts
+}
+
+func (t *countedTxn) Set(key, val []byte) error {
+	return t.SetEntry(badger.NewEntry(key, val))
+}
+
+func (t *countedTxn) SetEntry(e *badger.Entry) error {
+	existed, size, err := t.lookup(e.Key)
+	if err != nil {
+		return err
+	}
+	siteKey := !existed && t.isSiteKey(e.Key)
+	firstOfSite := siteKey && !t.siteHasKeys(e.Key)
+	if err := t.kvTxn.SetEntry(e); err != nil {
+		return err
+	}
+	if !existed {
+		t.count(e.Key, 1, firstOfSite)
+	}
+	if bytes.HasPrefix(e.Key, []byte("content:")) {
+		t.d.ContentBytes += int64(len(e.Value)) - size
+	}
+	return nil
+}
+
+func (t *countedTxn) Delete(key []byte) error {
+	existed, size, err := t.lookup(key)
+	if err != nil {
+		return err
+	}
+	if err := t.kvTxn.Delete(key); err != nil {
+		return err
+	}
+	if existed {
+		t.count(key, -1, t.isSiteKey(key) && !t.siteHasKeys(key))
+		if bytes.HasPrefix(key, []byte("content:")) {
+			t.d.ContentBytes -= size
+		}
+	}
+	return nil
+}
+
+// lookup reports whether key is set and the size of its value.
+func (t *countedTxn) lookup(key []byte) (bool, int64, error) {
+	item, err := t.kvTxn.Get(key)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return false, 0, nil
+	}
+	if err != nil {
+		return false, 0, err
+	}
+	return true, item.ValueSize(), nil
+}
+
+// count adds n for key's kind; lastOrFirst tells whether key is the only
+// key of its site.
+func (t *countedTxn) count(key []byte, n int64, lastOrFirst bool) {
+	switch {
+	case bytes.HasPrefix(key, []byte("record:")):
+		t.d.Records += n
+	case bytes.HasPrefix(key, []byte("content:")):
+		t.d.Content += n
+	case bytes.HasPrefix(key, []byte("domain:")):
+		t.d.Domains += n
+	case bytes.HasPrefix(key, []byte(statsSitePrefix)):
+		if isManifestKey(key) {
+			t.d.Websites += n
+		}
+		if lastOrFirst {
+			t.d.Sites += n
+		}
+	}
+}
+
+func (t *countedTxn) isSiteKey(key []byte) bool {
+	return siteOfKey(key) != nil
+}
+
+// siteHasKeys reports whether the site of key has any key in the
+// transaction's view.
+func (t *countedTxn) siteHasKeys(key []byte) bool {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	opts.Prefix = siteOfKey(key)
+	it := t.kvTxn.NewIterator(opts)
+	defer it.Close()
+	it.Rewind()
+	return it.Valid()
+}
+
+// siteOfKey returns the "site:<id>:" prefix of a site key, or nil.
+func siteOfKey(key []byte) []byte {
+	rest, ok := bytes.CutPrefix(key, []byte(statsSitePrefix))
+	if !ok {
+		return nil
+	}
+	i := bytes.IndexByte(rest, ':')
+	if i <= 0 {
+		return nil
+	}
+	return key[:len(statsSitePrefix)+i+1]
+}
+
+// isManifestKey reports whether key is a site's "site:<id>:manifest".
+func isManifestKey(key []byte) bool {
+	site := siteOfKey(key)
+	return site != nil && string(key[len(site):]) == "manifest"
+}
+
+// update runs fn in a write transaction and counts its changes.
+func (s *Store) update(fn func(txn *countedTxn) error) error {
+	s.stats.gate.RLock()
+	defer s.stats.gate.RUnlock()
+	var d statsCounts
+	err := s.db.Update(func(txn kvTxn) error {
+		ct := &countedTxn{kvTxn: txn}
+		if err := fn(ct); err != nil {
+			return err
+		}
+		d = ct.d
+		return nil
+	})
+	if err == nil {
+		s.stats.add(d)
+	}
+	return err
+}
+
+// GetStats returns the store's counters without scanning it.
+func (s *Store) GetStats() (*StoreStats, error) {
+	st := s.stats
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return &StoreStats{
+		TotalRecords:  st.counts.Records,
+		TotalContent:  st.counts.Content,
+		ContentBytes:  st.counts.ContentBytes,
+		TotalDomains:  st.counts.Domains,
+		TotalSites:    st.counts.Sites,
+		TotalWebsites: st.counts.Websites,
+		CountedAt:     st.countedAt,
+		Recounting:    st.since != nil,
+		Stale:         st.stale,
+		LastCleanup:   time.Now(),
+	}, nil
+}
+
+// Recount counts the store's keys again from a consistent snapshot and
+// replaces the counters, keeping the writes counted while it ran.
+func (s *Store) Recount() error {
+	st := s.stats
+	st.recountMu.Lock()
+	defer st.recountMu.Unlock()
+
+	st.gate.Lock()
+	txn := s.db.NewReadTxn()
+	st.mu.Lock()
+	st.since = &statsCounts{}
+	st.mu.Unlock()
+	st.gate.Unlock()
+	defer txn.Discard()
+
+	start := time.Now()
+	counts, err := countKeys(txn, st.stop)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if err == nil {
+		counts.add(*st.since)
+		st.counts = counts
+		st.countedAt = start
+		st.stale = false
+		st.changed = true
+	}
+	st.since = nil
+	return err
+}
+
+// StartRecount runs Recount in the background. It returns false if a
+// background recount is already running.
+func (s *Store) StartRecount() bool {
+	st := s.stats
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.running {
+		return false
+	}
+	st.running = true
+	st.wg.Add(1)
+	go func() {
+		defer st.wg.Done()
+		start := time.Now()
+		if err := s.Recount(); err != nil {
+			s.logger.Warn("store recount failed", zap.Error(err))
+		} else {
+			s.logger.Info("store recounted", zap.Duration("took", time.Since(start)))
+		}
+		st.mu.Lock()
+		st.running = false
+		st.mu.Unlock()
+	}()
+	return true
+}
+
+// errStatsStopped ends a recount when the store closes.
+var errStatsStopped = errors.New("store closing")
+
+// countKeys counts every key in txn's snapshot, giving up when stop closes.
+func countKeys(txn kvTxn, stop <-chan struct{}) (statsCounts, error) {
+	var c statsCounts
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	it := txn.NewIterator(opts)
+	defer it.Close()
+	var lastSite []byte
+	n := 0
+	for it.Rewind(); it.Valid(); it.Next() {
+		if n++; n%4096 == 0 {
+			select {
+			case <-stop:
+				return c, errStatsStopped
+			default:
+			}
+		}
+		item := it.Item()
+		k := item.Key()
+		switch {
+		case bytes.HasPrefix(k, []byte("record:")):
+			c.Records++
+		case bytes.HasPrefix(k, []byte("content:")):
+			c.Content++
+			c.ContentBytes += item.ValueSize()
+		case bytes.HasPrefix(k, []byte("domain:")):
+			c.Domains++
+		case bytes.HasPrefix(k, []byte(statsSitePrefix)):
+			if isManifestKey(k) {
+				c.Websites++
+			}
+			// Keys sort by site, so each site's keys are adjacent
+			if site := siteOfKey(k); site != nil && !bytes.Equal(site, lastSite) {
+				c.Sites++
+				lastSite = append(lastSite[:0], site...)
+			}
+		}
+	}
+	return c, nil
+}
+
+// markStatsStale notes changes the counters did not see.
+func (s *Store) markStatsStale() {
+	s.stats.mu.Lock()
+	s.stats.stale = true
+	s.stats.mu.Unlock()
+}
+
+// startStats loads the saved counters and starts saving them, counting
+// again when they cannot be trusted.
+func (s *Store) startStats() error {
+	st := &storeStats{stop: make(chan struct{})}
+	s.stats = st
+	var saved savedStats
+	found := false
+	err := s.db.View(func(txn kvTxn) error {
+		item, err := txn.Get([]byte(statsKey))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		found = true
+		return item.Value(func(v []byte) error {
+			return core.UnmarshalCBOR(v, &saved)
+		})
+	})
+	if err != nil {
+		return err
+	}
+	if found && saved.Clean {
+		st.counts, st.countedAt = saved.Counts, saved.CountedAt
+	} else {
+		// Stores from before the counters, snapshots and crashed stores
+		s.StartRecount()
+	}
+	if s.readOnly {
+		return nil
+	}
+	if found {
+		// Until Close saves them again a crash leaves them untrusted
+		if err := s.saveStats(false); err != nil {
+			return err
+		}
+	}
+	st.wg.Add(1)
+	go s.saveStatsLoop()
+	return nil
+}
+
+// saveStatsLoop saves changed counters and recounts stale ones until the
+// store closes.
+func (s *Store) saveStatsLoop() {
+	st := s.stats
+	defer st.wg.Done()
+	ticker := time.NewTicker(statsSaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-st.stop:
+			return
+		case <-ticker.C:
+		}
+		st.mu.Lock()
+		changed := st.changed
+		age := time.Since(st.countedAt)
+		recount := (st.stale && age >= statsRecountDelay) || age >= statsRecountMaxAge
+		st.mu.Unlock()
+		if recount {
+			s.StartRecount()
+		}
+		if changed {
+			if err := s.saveStats(false); err != nil {
+				s.logger.Warn("failed to save store statistics", zap.Error(err))
+			}
+		}
+	}
+}
+
+// saveStats writes the counters under statsKey.
+func (s *Store) saveStats(clean bool) error {
+	st := s.stats
+	st.mu.Lock()
+	saved := savedStats{Counts: st.counts, CountedAt: st.countedAt, Clean: clean}
+	st.changed = false
+	st.mu.Unlock()
+	data, err := core.MarshalCanonical(&saved)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(txn kvTxn) error {
+		return txn.Set([]byte(statsKey), data)
+	})
+}
+
+// stopStats waits for background counting and saves the counters as clean.
+func (s *Store) stopStats() error {
+	close(s.stats.stop)
+	s.stats.wg.Wait()
+	if s.readOnly {
+		return nil
+	}
+	return s.saveStats(true)
+}
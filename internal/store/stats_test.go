@@ -0,0 +1,123 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"alxnet/internal/core"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+func TestStatsCounters(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	const siteA = "aa00000000000000000000000000000000000000000000000000000000000000"
+	const siteB = "bb00000000000000000000000000000000000000000000000000000000000000"
+	manifest := putTestWebsite(t, s, siteA)
+	putTestWebsite(t, s, siteA) // rewriting the same keys counts nothing
+	if err := s.PutHead(siteB, 1, core.CIDForContent([]byte("b"))); err != nil {
+		t.Fatalf("PutHead: %v", err)
+	}
+	if err := s.PutDomain("example", siteA); err != nil {
+		t.Fatalf("PutDomain: %v", err)
+	}
+	if err := s.DeleteContent(manifest.Files["css/style.css"]); err != nil {
+		t.Fatalf("DeleteContent: %v", err)
+	}
+	if _, err := s.DeleteFileRecord(siteA, "css/style.css"); err != nil {
+		t.Fatalf("DeleteFileRecord: %v", err)
+	}
+
+	got, _ := s.GetStats()
+	want := StoreStats{TotalRecords: 1, TotalContent: 1, TotalDomains: 1, TotalSites: 2, TotalWebsites: 1}
+	check := func(when string, got *StoreStats) {
+		t.Helper()
+		if got.TotalRecords != want.TotalRecords || got.TotalContent != want.TotalContent ||
+			got.TotalDomains != want.TotalDomains || got.TotalSites != want.TotalSites ||
+			got.TotalWebsites != want.TotalWebsites {
+			t.Errorf("%s: stats = %+v, want %+v", when, *got, want)
+		}
+	}
+	check("counted", got)
+	if got.ContentBytes <= 0 {
+		t.Errorf("ContentBytes = %d", got.ContentBytes)
+	}
+
+	// Removing a site's last key removes the site
+	if err := s.DeleteHead(siteB, 1); err != nil {
+		t.Fatalf("DeleteHead: %v", err)
+	}
+	want.TotalSites = 1
+	got, _ = s.GetStats()
+	check("after deleting site B's head", got)
+
+	counted := *got
+	if err := s.Recount(); err != nil {
+		t.Fatalf("Recount: %v", err)
+	}
+	got, _ = s.GetStats()
+	check("recounted", got)
+	if got.CountedAt.IsZero() || got.Recounting || got.Stale {
+		t.Errorf("after Recount: %+v", *got)
+	}
+	if diff := got.ContentBytes - counted.ContentBytes; diff < -64 || diff > 64 {
+		t.Errorf("recounted ContentBytes = %d, counted %d", got.ContentBytes, counted.ContentBytes)
+	}
+
+	// Cleanly closed counters are trusted on open
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	s, err = Open(dir)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer s.Close()
+	got, _ = s.GetStats()
+	check("reopened", got)
+	if got.Recounting {
+		t.Error("cleanly closed store recounts on open")
+	}
+}
+
+func TestStatsRecountOnOpen(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	putTestWebsite(t, s, "aa00000000000000000000000000000000000000000000000000000000000000")
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	// A store from before the counters has none saved
+	db, err := badger.Open(badger.DefaultOptions(dir).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.DropPrefix([]byte(statsKey)); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	s, err = Open(dir)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer s.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got, _ := s.GetStats()
+		if got.TotalContent == 2 && got.TotalSites == 1 && !got.CountedAt.IsZero() {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("stats after open without saved counters = %+v", *got)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
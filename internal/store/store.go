@@ -2,18 +2,20 @@ package store
 
 import (
 	"bytes"
+	"cmp"
 	"errors"
 	"fmt"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"alxnet/internal/core"
 
 	"github.com/dgraph-io/badger/v4"
-	"github.com/fxamacker/cbor/v2"
 	"go.uber.org/zap"
 )
 
@@ -27,51 +29,110 @@ const (
 
 // Store represents a robust key-value store with enhanced security
 type Store struct {
-	db         *badger.DB
+	db         kvDB // see kv.go
 	dataDir    string
 	maxRetries int
 	retryDelay time.Duration
 	logger     *zap.Logger
 	mu         sync.RWMutex
+
+	// Optional backend for large content, see SetBlobBackend
+	blobs         BlobBackend
+	blobThreshold int
+	blobCacheTTL  time.Duration
+
+	cache    *contentCache // in-memory LRU in front of GetContent
+	compress bool          // zstd-compress new content entries
+	readOnly bool          // see OpenReadOnly
+	stats    *storeStats   // counters behind GetStats
+
+	followMu  sync.Mutex // serializes follow and notification updates
+	commentMu sync.Mutex // serializes comment and moderation writes
+	pointerMu sync.Mutex // serializes pointer writes
+	appDataMu sync.Mutex // serializes app data writes
+	domainMu  sync.Mutex // serializes domain offers and transfers
+	denyMu    sync.Mutex // serializes denylist writes
+
+	retentionMu     sync.Mutex // serializes retention runs
+	retentionReport atomic.Pointer[RetentionReport]
+
+	events atomic.Pointer[func(Event)] // see SetEventSink
+
+	updates updateBatch // see PutUpdate
 }
 
 // StoreStats tracks store performance and usage
 type StoreStats struct {
 	TotalRecords  int64
 	TotalContent  int64
+	ContentBytes  int64 // stored size of content, approximate
 	TotalDomains  int64
-	TotalWebsites int64
+	TotalSites    int64 // sites with any heads, files or manifest
+	TotalWebsites int64 // sites with a manifest
+	CountedAt     time.Time
+	Recounting    bool // a full count is running
+	Stale         bool // replicated changes are not counted yet
 	LastCleanup   time.Time
 	MemoryUsage   int64
 }
 
 func Open(dir string) (*Store, error) {
+	return open(dir, false, nil)
+}
+
+// open opens the store in dir; tune, if set, adjusts Badger's options.
+func open(dir string, readOnly bool, tune func(badger.Options) badger.Options) (*Store, error) {
 	cleanDir := filepath.Clean(dir)
-	db, err := badger.Open(badger.DefaultOptions(cleanDir))
+	opts := badger.DefaultOptions(cleanDir).WithReadOnly(readOnly)
+	if tune != nil {
+		opts = tune(opts)
+	}
+	db, err := badger.Open(opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
+	return newStore(badgerDB{db}, cleanDir, readOnly)
+}
 
+// newStore returns a store of db, closing db if it fails.
+func newStore(db kvDB, dir string, readOnly bool) (*Store, error) {
 	// Initialize logger
 	logger, err := zap.NewProduction()
 	if err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to create logger: %w", err)
 	}
 
 	s := &Store{
 		db:         db,
-		dataDir:    cleanDir,
+		dataDir:    dir,
 		maxRetries: DefaultMaxRetries,
 		retryDelay: DefaultRetryDelay,
 		logger:     logger,
+		cache:      newContentCache(DefaultContentCacheSize),
+		readOnly:   readOnly,
+	}
+	if err := s.startStats(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to load store statistics: %w", err)
+	}
+	if !readOnly {
+		if err := s.indexStoredRecords(); err != nil {
+			s.stopStats()
+			db.Close()
+			return nil, fmt.Errorf("failed to index records: %w", err)
+		}
 	}
 
-	logger.Info("store opened successfully", zap.String("dir", cleanDir))
+	logger.Info("store opened successfully", zap.String("dir", dir), zap.Bool("read_only", readOnly))
 	return s, nil
 }
 
 func (s *Store) Close() error {
 	s.logger.Info("closing store")
+	if err := s.stopStats(); err != nil {
+		s.logger.Warn("failed to save store statistics", zap.Error(err))
+	}
 	return s.db.Close()
 }
 
@@ -116,8 +177,11 @@ func (s *Store) PutRecord(cid string, data []byte) error {
 		return fmt.Errorf("validation failed: %w", err)
 	}
 
-	return s.db.Update(func(txn *badger.Txn) error {
-		return txn.Set([]byte("record:"+cid), data)
+	return s.update(func(txn *countedTxn) error {
+		if err := txn.Set([]byte("record:"+cid), data); err != nil {
+			return err
+		}
+		return indexRecord(txn, cid, data)
 	})
 }
 
@@ -127,7 +191,7 @@ func (s *Store) GetRecord(cid string) ([]byte, error) {
 	}
 
 	var out []byte
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.db.View(func(txn kvTxn) error {
 		it, err := txn.Get([]byte("record:" + cid))
 		if err != nil {
 			return err
@@ -145,7 +209,10 @@ func (s *Store) DeleteRecord(cid string) error {
 		return fmt.Errorf("validation failed: %w", err)
 	}
 
-	return s.db.Update(func(txn *badger.Txn) error {
+	return s.update(func(txn *countedTxn) error {
+		if err := unindexRecord(txn, cid); err != nil {
+			return err
+		}
 		return txn.Delete([]byte("record:" + cid))
 	})
 }
@@ -158,7 +225,7 @@ func (s *Store) ResolveRecordCID(prefix string) (string, error) {
 
 	var found string
 	var count int
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.db.View(func(txn kvTxn) error {
 		opts := badger.DefaultIteratorOptions
 		opts.PrefetchValues = false
 		it := txn.NewIterator(opts)
@@ -227,36 +294,96 @@ func (s *Store) PutContent(cid string, data []byte) error {
 		return fmt.Errorf("validation failed: %w", err)
 	}
 
-	return s.db.Update(func(txn *badger.Txn) error {
-		return txn.Set([]byte("content:"+cid), data)
+	if c := s.contentCache(); c != nil {
+		c.remove(cid)
+	}
+	if b, threshold, cacheTTL := s.blobBackend(); b != nil && len(data) >= threshold {
+		return s.putBlob(b, cacheTTL, cid, data)
+	}
+
+	entry := s.contentEntry(cid, data)
+	return s.update(func(txn *countedTxn) error {
+		return txn.SetEntry(entry)
 	})
 }
 
+// contentEntry returns the entry storing data under cid, compressed when
+// the store compresses content.
+func (s *Store) contentEntry(cid string, data []byte) *badger.Entry {
+	meta := byte(0)
+	if s.compressionEnabled() {
+		data, meta = compressValue(data)
+	}
+	return badger.NewEntry([]byte("content:"+cid), data).WithMeta(meta)
+}
+
 func (s *Store) GetContent(cid string) ([]byte, error) {
 	if err := s.validateKey(cid); err != nil {
 		return nil, fmt.Errorf("validation failed: %w", err)
 	}
 
+	c := s.contentCache()
+	if c != nil {
+		if data, ok := c.get(cid); ok {
+			return data, nil
+		}
+	}
+
 	var out []byte
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.db.View(func(txn kvTxn) error {
 		it, err := txn.Get([]byte("content:" + cid))
 		if err != nil {
 			return nil
 		}
 		return it.Value(func(v []byte) error {
-			out = append([]byte{}, v...)
-			return nil
+			var err error
+			out, err = decodeValue(v, it.UserMeta())
+			return err
 		})
 	})
+	if err == nil && out == nil {
+		// Large content may only live in the blob backend
+		out, err = s.fetchBlob(cid)
+	}
+	if err == nil && out != nil && c != nil {
+		c.add(cid, out)
+	}
 	return out, err
 }
 
+// HasContent reports whether a content blob is stored, without reading it.
+func (s *Store) HasContent(cid string) (bool, error) {
+	if err := s.validateKey(cid); err != nil {
+		return false, fmt.Errorf("validation failed: %w", err)
+	}
+
+	var found bool
+	err := s.db.View(func(txn kvTxn) error {
+		_, err := txn.Get([]byte("content:" + cid))
+		if err == badger.ErrKeyNotFound {
+			_, err = txn.Get([]byte("blob:" + cid))
+		}
+		if err == badger.ErrKeyNotFound {
+			return nil
+		}
+		found = err == nil
+		return err
+	})
+	return found, err
+}
+
 func (s *Store) DeleteContent(cid string) error {
 	if err := s.validateKey(cid); err != nil {
 		return fmt.Errorf("validation failed: %w", err)
 	}
 
-	return s.db.Update(func(txn *badger.Txn) error {
+	if c := s.contentCache(); c != nil {
+		c.remove(cid)
+	}
+	if err := s.deleteBlob(cid); err != nil {
+		return err
+	}
+	return s.update(func(txn *countedTxn) error {
 		return txn.Delete([]byte("content:" + cid))
 	})
 }
@@ -265,7 +392,7 @@ func (s *Store) DeleteContent(cid string) error {
 
 // PutWebsiteManifest stores a website manifest
 func (s *Store) PutWebsiteManifest(siteID string, manifestCID string, data []byte) error {
-	return s.db.Update(func(txn *badger.Txn) error {
+	return s.update(func(txn *countedTxn) error {
 		// Store the manifest data
 		if err := txn.Set([]byte("manifest:"+manifestCID), data); err != nil {
 			return err
@@ -278,7 +405,7 @@ func (s *Store) PutWebsiteManifest(siteID string, manifestCID string, data []byt
 // GetWebsiteManifest retrieves a website manifest by CID
 func (s *Store) GetWebsiteManifest(manifestCID string) ([]byte, error) {
 	var out []byte
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.db.View(func(txn kvTxn) error {
 		it, err := txn.Get([]byte("manifest:" + manifestCID))
 		if err != nil {
 			return err
@@ -294,7 +421,7 @@ func (s *Store) GetWebsiteManifest(manifestCID string) ([]byte, error) {
 // GetCurrentWebsiteManifest retrieves the current manifest for a site
 func (s *Store) GetCurrentWebsiteManifest(siteID string) ([]byte, error) {
 	var manifestCID []byte
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.db.View(func(txn kvTxn) error {
 		it, err := txn.Get([]byte("site:" + siteID + ":manifest"))
 		if err != nil {
 			return err
@@ -311,17 +438,36 @@ func (s *Store) GetCurrentWebsiteManifest(siteID string) ([]byte, error) {
 	return s.GetWebsiteManifest(string(manifestCID))
 }
 
-// PutFileRecord stores a file record for a website
+// PutFileRecord stores a file record for a website. The content it points
+// at gains a reference, and the content of the record it replaces loses one.
 func (s *Store) PutFileRecord(siteID string, filePath string, recordCID string, data []byte) error {
-	return s.db.Update(func(txn *badger.Txn) error {
+	var rec core.FileRecord
+	if err := core.UnmarshalCBOR(data, &rec); err != nil {
+		return fmt.Errorf("invalid file record: %w", err)
+	}
+	return s.update(func(txn *countedTxn) error {
+		mapping := []byte("site:" + siteID + ":file:" + filePath)
+		oldCID, err := fileContentCID(txn.kvTxn, mapping)
+		if err != nil {
+			return err
+		}
 		// Store the file record data
 		if err := txn.Set([]byte("filerecord:"+recordCID), data); err != nil {
 			return err
 		}
 		// Store the file path -> record CID mapping
-		if err := txn.Set([]byte("site:"+siteID+":file:"+filePath), []byte(recordCID)); err != nil {
+		if err := txn.Set(mapping, []byte(recordCID)); err != nil {
 			return err
 		}
+		if oldCID == rec.ContentCID {
+			return nil
+		}
+		if err := addContentRef(txn.kvTxn, rec.ContentCID, 1); err != nil {
+			return err
+		}
+		if oldCID != "" {
+			return addContentRef(txn.kvTxn, oldCID, -1)
+		}
 		return nil
 	})
 }
@@ -329,7 +475,7 @@ func (s *Store) PutFileRecord(siteID string, filePath string, recordCID string,
 // GetFileRecord retrieves a file record by CID
 func (s *Store) GetFileRecord(recordCID string) ([]byte, error) {
 	var out []byte
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.db.View(func(txn kvTxn) error {
 		it, err := txn.Get([]byte("filerecord:" + recordCID))
 		if err != nil {
 			return err
@@ -345,7 +491,7 @@ func (s *Store) GetFileRecord(recordCID string) ([]byte, error) {
 // GetFileRecordByPath retrieves a file record for a specific file path in a website
 func (s *Store) GetFileRecordByPath(siteID string, filePath string) ([]byte, error) {
 	var recordCID []byte
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.db.View(func(txn kvTxn) error {
 		it, err := txn.Get([]byte("site:" + siteID + ":file:" + filePath))
 		if err != nil {
 			return err
@@ -365,7 +511,7 @@ func (s *Store) GetFileRecordByPath(siteID string, filePath string) ([]byte, err
 // ListWebsiteFiles lists all files in a website
 func (s *Store) ListWebsiteFiles(siteID string) (map[string]string, error) {
 	files := make(map[string]string)
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.db.View(func(txn kvTxn) error {
 		opts := badger.DefaultIteratorOptions
 		opts.PrefetchValues = false
 		it := txn.NewIterator(opts)
@@ -404,8 +550,7 @@ func (s *Store) GetWebsiteInfo(siteID string) (*core.WebsiteInfo, error) {
 
 	// Parse manifest
 	var manifest core.WebsiteManifest
-	dec, _ := cbor.DecOptions{}.DecMode()
-	if err := dec.Unmarshal(manifestData, &manifest); err != nil {
+	if err := core.UnmarshalCBOR(manifestData, &manifest); err != nil {
 		return nil, err
 	}
 
@@ -419,7 +564,7 @@ func (s *Store) GetWebsiteInfo(siteID string) (*core.WebsiteInfo, error) {
 		}
 
 		var fileRecord core.FileRecord
-		if err := dec.Unmarshal(fileRecordData, &fileRecord); err != nil {
+		if err := core.UnmarshalCBOR(fileRecordData, &fileRecord); err != nil {
 			continue
 		}
 
@@ -445,12 +590,14 @@ func (s *Store) GetWebsiteInfo(siteID string) (*core.WebsiteInfo, error) {
 		Files:       files,
 		FileCount:   len(files),
 		LastUpdated: time.Unix(manifest.TS, 0),
+		NoIndex:     manifest.NoIndex,
+		Preload:     manifest.Preload,
 	}, nil
 }
 
 // Check if a site has a website manifest (multi-file website)
 func (s *Store) HasWebsiteManifest(siteID string) bool {
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.db.View(func(txn kvTxn) error {
 		_, err := txn.Get([]byte("site:" + siteID + ":manifest"))
 		return err
 	})
@@ -460,7 +607,7 @@ func (s *Store) HasWebsiteManifest(siteID string) bool {
 // Check if a site has a traditional single-file record
 func (s *Store) HasHead(siteID string) (bool, error) {
 	var found bool
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.db.View(func(txn kvTxn) error {
 		opts := badger.DefaultIteratorOptions
 		opts.PrefetchValues = false
 		it := txn.NewIterator(opts)
@@ -476,52 +623,92 @@ func (s *Store) HasHead(siteID string) (bool, error) {
 	return found, err
 }
 
-// GetHead retrieves the current head for a traditional single-file site
+// GetHead retrieves the current head for a traditional single-file site:
+// the stored head with the highest sequence number.
 func (s *Store) GetHead(siteID string) (uint64, string, error) {
 	var seq uint64
 	var headCID string
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.db.View(func(txn kvTxn) error {
 		opts := badger.DefaultIteratorOptions
 		opts.PrefetchValues = false
 		it := txn.NewIterator(opts)
 		defer it.Close()
 
+		// Keys hold the seq in decimal, so "site:x:head:10" sorts before
+		// "site:x:head:9"; every head key has to be looked at
 		prefix := []byte("site:" + siteID + ":head:")
-		it.Seek(prefix)
-		if it.ValidForPrefix(prefix) {
-			item := it.Item()
-			k := string(item.Key())
-			// Extract sequence from key "site:siteID:head:seq"
-			parts := strings.SplitN(k, ":", 4)
-			if len(parts) == 4 {
-				if seqStr := parts[3]; seqStr != "" {
-					if seqNum, err := strconv.ParseUint(seqStr, 10, 64); err == nil {
-						seq = seqNum
-					}
-				}
+		var newestKey []byte
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			seqNum, err := strconv.ParseUint(string(it.Item().Key()[len(prefix):]), 10, 64)
+			if err != nil {
+				continue
 			}
+			if newestKey == nil || seqNum > seq {
+				seq, newestKey = seqNum, it.Item().KeyCopy(nil)
+			}
+		}
+		if newestKey == nil {
+			return nil
+		}
+		item, err := txn.Get(newestKey)
+		if err != nil {
+			return err
+		}
+		return item.Value(func(v []byte) error {
+			headCID = string(v)
+			return nil
+		})
+	})
+	return seq, headCID, err
+}
 
-			// Get the head CID
-			err := item.Value(func(v []byte) error {
-				headCID = string(v)
-				return nil
-			})
+// HeadEntry is one accepted head of a site.
+type HeadEntry struct {
+	Seq uint64
+	CID string
+}
+
+// ListHeads returns every stored head of siteID, newest first.
+func (s *Store) ListHeads(siteID string) ([]HeadEntry, error) {
+	var heads []HeadEntry
+	err := s.db.View(func(txn kvTxn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := []byte("site:" + siteID + ":head:")
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			seq, err := strconv.ParseUint(string(it.Item().Key()[len(prefix):]), 10, 64)
 			if err != nil {
+				continue
+			}
+			if err := it.Item().Value(func(v []byte) error {
+				heads = append(heads, HeadEntry{Seq: seq, CID: string(v)})
+				return nil
+			}); err != nil {
 				return err
 			}
 		}
 		return nil
 	})
-	return seq, headCID, err
+	// Keys hold the seq in decimal, so they do not sort numerically
+	slices.SortFunc(heads, func(a, b HeadEntry) int { return cmp.Compare(b.Seq, a.Seq) })
+	return heads, err
 }
 
 // PutHead stores the current head for a traditional single-file site
 func (s *Store) PutHead(siteID string, seq uint64, headCID string) error {
-	return s.db.Update(func(txn *badger.Txn) error {
+	return s.update(func(txn *countedTxn) error {
 		return txn.Set([]byte(fmt.Sprintf("site:%s:head:%d", siteID, seq)), []byte(headCID))
 	})
 }
 
+// DeleteHead removes the head of siteID stored for seq.
+func (s *Store) DeleteHead(siteID string, seq uint64) error {
+	return s.update(func(txn *countedTxn) error {
+		return txn.Delete([]byte(fmt.Sprintf("site:%s:head:%d", siteID, seq)))
+	})
+}
+
 // SetHead is an alias for PutHead for backward compatibility
 func (s *Store) SetHead(siteID string, seq uint64, headCID string) error {
 	return s.PutHead(siteID, seq, headCID)
@@ -535,23 +722,24 @@ func (s *Store) ListSites() ([]string, error) {
 	var sites []string
 	siteMap := make(map[string]bool)
 
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.db.View(func(txn kvTxn) error {
 		opts := badger.DefaultIteratorOptions
 		opts.PrefetchSize = 10
 		it := txn.NewIterator(opts)
 		defer it.Close()
 
-		// Look for site manifest keys (site:<siteID>:manifest)
-		prefix := []byte("site:")
-		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
-			key := string(it.Item().Key())
-			// Parse key: site:<siteID>:manifest or site:<siteID>:file:...
-			parts := strings.Split(key, ":")
-			if len(parts) >= 3 && parts[0] == "site" {
-				siteID := parts[1]
-				if !siteMap[siteID] {
-					sites = append(sites, siteID)
-					siteMap[siteID] = true
+		// Look for site keys (site:<siteID>:manifest, site:<siteID>:file:...)
+		// and the drafts of sites not published yet (draft:<siteID>:...)
+		for _, prefix := range [][]byte{[]byte("site:"), []byte("draft:")} {
+			for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+				key := string(it.Item().Key())
+				parts := strings.Split(key, ":")
+				if len(parts) >= 3 {
+					siteID := parts[1]
+					if !siteMap[siteID] {
+						sites = append(sites, siteID)
+						siteMap[siteID] = true
+					}
 				}
 			}
 		}
@@ -572,7 +760,7 @@ func (s *Store) GetStorageUsage() (int64, error) {
 
 	var totalBytes int64
 
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.db.View(func(txn kvTxn) error {
 		opts := badger.DefaultIteratorOptions
 		opts.PrefetchSize = 10
 		it := txn.NewIterator(opts)
@@ -597,7 +785,7 @@ func (s *Store) GetContentFileCount() (int, error) {
 
 	var count int
 
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.db.View(func(txn kvTxn) error {
 		opts := badger.DefaultIteratorOptions
 		opts.PrefetchSize = 10
 		it := txn.NewIterator(opts)
@@ -619,7 +807,7 @@ func (s *Store) GetContentFileCount() (int, error) {
 func (s *Store) ResolveContentCID(prefix string) (string, error) {
 	var found string
 	var count int
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.db.View(func(txn kvTxn) error {
 		opts := badger.DefaultIteratorOptions
 		opts.PrefetchValues = false
 		it := txn.NewIterator(opts)
@@ -709,14 +897,20 @@ func (s *Store) PutDomain(domain string, siteID string) error {
 		return fmt.Errorf("domain '%s' is already registered to another site", domain)
 	}
 
-	return s.db.Update(func(txn *badger.Txn) error {
+	if err := s.update(func(txn *countedTxn) error {
 		return txn.Set([]byte("domain:"+domain), []byte(siteID))
-	})
+	}); err != nil {
+		return err
+	}
+	if existingSiteID != siteID {
+		s.Emit(EventDomainRegistered, map[string]interface{}{"domain": domain, "site_id": siteID})
+	}
+	return nil
 }
 
 func (s *Store) GetDomain(domain string) (string, error) {
 	var out []byte
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.db.View(func(txn kvTxn) error {
 		it, err := txn.Get([]byte("domain:" + domain))
 		if err != nil {
 			return err
@@ -738,7 +932,7 @@ func (s *Store) ResolveDomain(domain string) (string, error) {
 
 func (s *Store) ListDomains() (map[string]string, error) {
 	domains := make(map[string]string)
-	err := s.db.View(func(txn *badger.Txn) error {
+	err := s.db.View(func(txn kvTxn) error {
 		opts := badger.DefaultIteratorOptions
 		opts.PrefetchValues = false
 		it := txn.NewIterator(opts)
@@ -764,12 +958,6 @@ func (s *Store) ListDomains() (map[string]string, error) {
 	return domains, err
 }
 
-func (s *Store) TransferDomain(domain string, newOwnerPub []byte, signature []byte) error {
-	// TODO: Implement domain transfer with cryptographic proof
-	// For now, just return an error
-	return errors.New("domain transfer not yet implemented")
-}
-
 // ValidateSiteName validates a site name according to the rules:
 // - alphanumeric characters only (lowercase)
 // - can contain underscores and dashes
@@ -817,6 +1005,155 @@ func (s *Store) RegisterSiteName(siteName string, siteID string) error {
 	return s.PutDomain(siteName, siteID)
 }
 
+// Pinning methods
+
+// PinSite marks a site as pinned. Content of pinned sites is kept by
+// cleanup and their heads are periodically re-announced by the node.
+func (s *Store) PinSite(siteID string) error {
+	if err := s.validateKey(siteID); err != nil {
+		return fmt.Errorf("validation failed: %w", err)
+	}
+	return s.db.Update(func(txn kvTxn) error {
+		return txn.Set([]byte("pin:"+siteID), []byte(time.Now().UTC().Format(time.RFC3339)))
+	})
+}
+
+// UnpinSite removes a pin and the site's own retention policy; it is not
+// an error if the site was not pinned.
+func (s *Store) UnpinSite(siteID string) error {
+	if err := s.validateKey(siteID); err != nil {
+		return fmt.Errorf("validation failed: %w", err)
+	}
+	return s.db.Update(func(txn kvTxn) error {
+		// A site's own retention policy only applies while it is pinned
+		if err := txn.Delete(retentionKey(siteID)); err != nil {
+			return err
+		}
+		return txn.Delete([]byte("pin:" + siteID))
+	})
+}
+
+// IsPinned reports whether a site is pinned.
+func (s *Store) IsPinned(siteID string) bool {
+	err := s.db.View(func(txn kvTxn) error {
+		_, err := txn.Get([]byte("pin:" + siteID))
+		return err
+	})
+	return err == nil
+}
+
+// ListPinnedSites returns the IDs of all pinned sites.
+func (s *Store) ListPinnedSites() ([]string, error) {
+	var sites []string
+	err := s.db.View(func(txn kvTxn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		prefix := []byte("pin:")
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			sites = append(sites, strings.TrimPrefix(string(it.Item().Key()), "pin:"))
+		}
+		return nil
+	})
+	return sites, err
+}
+
+// pinnedContent collects the content CIDs referenced by pinned sites, from
+// their current manifest and head record, and the shards held for other
+// nodes.
+func (s *Store) pinnedContent() (map[string]bool, error) {
+	sites, err := s.ListPinnedSites()
+	if err != nil {
+		return nil, err
+	}
+	keep := make(map[string]bool)
+	shards, err := s.ListShards()
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range shards {
+		keep[r.CID] = true
+	}
+	for _, siteID := range sites {
+		if data, err := s.GetCurrentWebsiteManifest(siteID); err == nil {
+			var manifest core.WebsiteManifest
+			if core.UnmarshalCBOR(data, &manifest) == nil {
+				for _, cid := range manifest.Files {
+					keep[cid] = true
+				}
+			}
+		}
+		if has, _ := s.HasHead(siteID); has {
+			if _, headCID, err := s.GetHead(siteID); err == nil {
+				if data, err := s.GetRecord(headCID); err == nil {
+					var rec core.UpdateRecord
+					if core.UnmarshalCBOR(data, &rec) == nil {
+						keep[rec.ContentCID] = true
+					}
+				}
+			}
+		}
+	}
+	return keep, nil
+}
+
+// Hosting agreement methods. Agreements are opaque blobs keyed by
+// direction ("in" for sites this node hosts for others, "out" for requests
+// this node's wallet users sent) and agreement ID.
+
+func (s *Store) PutHostingAgreement(direction, id string, data []byte) error {
+	if err := s.validateKeyValue(id, data); err != nil {
+		return fmt.Errorf("validation failed: %w", err)
+	}
+	return s.db.Update(func(txn kvTxn) error {
+		return txn.Set([]byte("hosting:"+direction+":"+id), data)
+	})
+}
+
+func (s *Store) GetHostingAgreement(direction, id string) ([]byte, error) {
+	if err := s.validateKey(id); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+	var out []byte
+	err := s.db.View(func(txn kvTxn) error {
+		it, err := txn.Get([]byte("hosting:" + direction + ":" + id))
+		if err != nil {
+			return err
+		}
+		return it.Value(func(v []byte) error {
+			out = append([]byte{}, v...)
+			return nil
+		})
+	})
+	return out, err
+}
+
+// ListHostingAgreements returns all agreements in one direction keyed by ID.
+func (s *Store) ListHostingAgreements(direction string) (map[string][]byte, error) {
+	out := make(map[string][]byte)
+	err := s.db.View(func(txn kvTxn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		prefix := []byte("hosting:" + direction + ":")
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			id := strings.TrimPrefix(string(item.Key()), string(prefix))
+			err := item.Value(func(v []byte) error {
+				out[id] = append([]byte{}, v...)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return out, err
+}
+
 // Validation methods
 func (s *Store) validateKey(key string) error {
 	if key == "" {
@@ -872,39 +1209,28 @@ func (s *Store) SetRetryDelay(delay time.Duration) {
 	s.retryDelay = delay
 }
 
-// Statistics methods
-func (s *Store) GetStats() (*StoreStats, error) {
-	stats := &StoreStats{}
-
-	err := s.db.View(func(txn *badger.Txn) error {
-		opts := badger.DefaultIteratorOptions
-		opts.PrefetchValues = false
-		it := txn.NewIterator(opts)
-		defer it.Close()
-
-		for it.Rewind(); it.Valid(); it.Next() {
-			item := it.Item()
-			k := item.Key()
-
-			if bytes.HasPrefix(k, []byte("record:")) {
-				stats.TotalRecords++
-			} else if bytes.HasPrefix(k, []byte("content:")) {
-				stats.TotalContent++
-			} else if bytes.HasPrefix(k, []byte("domain:")) {
-				stats.TotalDomains++
-			} else if bytes.HasPrefix(k, []byte("website:")) {
-				stats.TotalWebsites++
-			}
-		}
-		return nil
-	})
-
+// CollectGarbage rewrites Badger value log files in which at least
+// discardRatio of the data is stale, until none is left, and returns how
+// many it rewrote.
+func (s *Store) CollectGarbage(discardRatio float64) (int, error) {
+	if discardRatio <= 0 || discardRatio >= 1 {
+		return 0, fmt.Errorf("discard ratio must be between 0 and 1, got %v", discardRatio)
+	}
+	db, err := s.onDisk()
 	if err != nil {
-		return nil, err
+		return 0, err
+	}
+	rewritten := 0
+	for {
+		err := db.RunValueLogGC(discardRatio)
+		if errors.Is(err, badger.ErrNoRewrite) || errors.Is(err, badger.ErrRejected) {
+			return rewritten, nil
+		}
+		if err != nil {
+			return rewritten, err
+		}
+		rewritten++
 	}
-
-	stats.LastCleanup = time.Now()
-	return stats, nil
 }
 
 // Cleanup methods
@@ -913,7 +1239,12 @@ func (s *Store) CleanupOldRecords(maxAge time.Duration) error {
 
 	deleted := 0
 
-	err := s.db.Update(func(txn *badger.Txn) error {
+	keep, err := s.pinnedContent()
+	if err != nil {
+		return err
+	}
+
+	err = s.update(func(txn *countedTxn) error {
 		opts := badger.DefaultIteratorOptions
 		opts.PrefetchValues = false
 		it := txn.NewIterator(opts)
@@ -921,10 +1252,11 @@ func (s *Store) CleanupOldRecords(maxAge time.Duration) error {
 
 		for it.Rewind(); it.Valid(); it.Next() {
 			item := it.Item()
-			k := item.Key()
+			k := item.KeyCopy(nil) // Delete keeps a reference to the key
 
-			// Only clean up content records, not metadata
-			if bytes.HasPrefix(k, []byte("content:")) {
+			// Only clean up content records, not metadata, and never
+			// content that belongs to a pinned site
+			if bytes.HasPrefix(k, []byte("content:")) && !keep[string(k[len("content:"):])] {
 				// Check if content is old enough to delete
 				// This is a simplified check - in practice you'd want to store timestamps
 				if deleted < 1000 { // Limit cleanup per run
@@ -934,6 +1266,9 @@ func (s *Store) CleanupOldRecords(maxAge time.Duration) error {
 							zap.Error(err))
 						continue
 					}
+					if c := s.contentCache(); c != nil {
+						c.remove(string(k[len("content:"):]))
+					}
 					deleted++
 				}
 			}
@@ -0,0 +1,140 @@
+package store
+
+import (
+	"fmt"
+	"testing"
+
+	"alxnet/internal/core"
+)
+
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := NewMemory()
+	if err != nil {
+		t.Fatalf("NewMemory: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// openBadgerTestStore opens a store on disk, for tests of snapshots and
+// replication.
+func openBadgerTestStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestPinSite(t *testing.T) {
+	s := openTestStore(t)
+	const siteA = "aa00000000000000000000000000000000000000000000000000000000000000"
+	const siteB = "bb00000000000000000000000000000000000000000000000000000000000000"
+
+	if s.IsPinned(siteA) {
+		t.Fatal("site pinned before PinSite")
+	}
+	for _, id := range []string{siteA, siteB} {
+		if err := s.PinSite(id); err != nil {
+			t.Fatalf("PinSite(%s): %v", id, err)
+		}
+	}
+	if err := s.UnpinSite(siteB); err != nil {
+		t.Fatalf("UnpinSite: %v", err)
+	}
+	if err := s.UnpinSite(siteB); err != nil {
+		t.Errorf("UnpinSite of an unpinned site: %v", err)
+	}
+
+	pins, err := s.ListPinnedSites()
+	if err != nil {
+		t.Fatalf("ListPinnedSites: %v", err)
+	}
+	if len(pins) != 1 || pins[0] != siteA {
+		t.Errorf("ListPinnedSites() = %v, want [%s]", pins, siteA)
+	}
+	if err := s.PinSite("not-hex"); err == nil {
+		t.Error("PinSite() accepted an invalid site ID")
+	}
+}
+
+func TestCleanupKeepsPinnedContent(t *testing.T) {
+	s := openTestStore(t)
+	const siteID = "aa00000000000000000000000000000000000000000000000000000000000000"
+
+	pinned := []byte("pinned page")
+	loose := []byte("unreferenced blob")
+	pinnedCID := core.CIDForContent(pinned)
+	looseCID := core.CIDForContent(loose)
+
+	for cid, data := range map[string][]byte{pinnedCID: pinned, looseCID: loose} {
+		if err := s.PutContent(cid, data); err != nil {
+			t.Fatalf("PutContent: %v", err)
+		}
+	}
+	rec := &core.UpdateRecord{Version: core.RecordV1, Seq: 1, ContentCID: pinnedCID, TS: 1}
+	recBytes, err := core.CanonicalMarshal(rec)
+	if err != nil {
+		t.Fatalf("CanonicalMarshal: %v", err)
+	}
+	recCID := core.CIDForBytes(recBytes)
+	if err := s.PutRecord(recCID, recBytes); err != nil {
+		t.Fatalf("PutRecord: %v", err)
+	}
+	if err := s.PutHead(siteID, 1, recCID); err != nil {
+		t.Fatalf("PutHead: %v", err)
+	}
+	if err := s.PinSite(siteID); err != nil {
+		t.Fatalf("PinSite: %v", err)
+	}
+
+	if err := s.CleanupOldRecords(0); err != nil {
+		t.Fatalf("CleanupOldRecords: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		cid  string
+		want bool
+	}{
+		{"pinned content", pinnedCID, true},
+		{"unpinned content", looseCID, false},
+	}
+	for _, tt := range tests {
+		got, err := s.HasContent(tt.cid)
+		if err != nil {
+			t.Fatalf("HasContent: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: HasContent = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGetHeadReturnsNewest(t *testing.T) {
+	s := openTestStore(t)
+	const siteID = "aa00000000000000000000000000000000000000000000000000000000000000"
+
+	// Up to 12 so that "head:10" sorts before "head:9" in key order
+	for seq := uint64(1); seq <= 12; seq++ {
+		if err := s.PutHead(siteID, seq, fmt.Sprintf("%064x", seq)); err != nil {
+			t.Fatalf("PutHead(%d): %v", seq, err)
+		}
+		gotSeq, gotCID, err := s.GetHead(siteID)
+		if err != nil || gotSeq != seq || gotCID != fmt.Sprintf("%064x", seq) {
+			t.Fatalf("GetHead() after seq %d = %d, %s, %v", seq, gotSeq, gotCID, err)
+		}
+	}
+	if err := s.DeleteHead(siteID, 12); err != nil {
+		t.Fatalf("DeleteHead: %v", err)
+	}
+	if seq, _, _ := s.GetHead(siteID); seq != 11 {
+		t.Errorf("GetHead() after DeleteHead = %d, want 11", seq)
+	}
+	if seq, cid, err := s.GetHead("bb00000000000000000000000000000000000000000000000000000000000000"); seq != 0 || cid != "" || err != nil {
+		t.Errorf("GetHead() of an unknown site = %d, %q, %v", seq, cid, err)
+	}
+}
@@ -0,0 +1,140 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// An accepted update writes its record, its content when it carries it,
+// and the site's new head. PutUpdate makes the three one transaction, so a
+// crash cannot leave a head pointing at a record that was never written,
+// and groups updates stored at the same time into one commit: while a
+// commit runs, updates arriving from other validation workers queue up and
+// go into the next one together.
+
+// MaxUpdateBatch is the most updates committed together.
+const MaxUpdateBatch = 64
+
+// UpdateWrite is what an accepted update stores.
+type UpdateWrite struct {
+	SiteID     string
+	Seq        uint64
+	RecordCID  string
+	Record     []byte
+	ContentCID string
+	Content    []byte // nil when the update does not carry it
+}
+
+type pendingUpdate struct {
+	w    UpdateWrite
+	wake chan struct{} // the update was committed, or its caller leads the next commit
+	done bool
+	err  error
+}
+
+// updateBatch queues updates for the caller running the commit, the
+// leader. There is no leader exactly when the queue is empty.
+type updateBatch struct {
+	mu      sync.Mutex
+	queue   []*pendingUpdate
+	leading bool
+}
+
+// PutUpdate stores an accepted update's record, content and head in one
+// transaction, shared with updates other callers store at the same time.
+// It returns once the update is committed.
+func (s *Store) PutUpdate(w UpdateWrite) error {
+	if w.SiteID == "" {
+		return errors.New("update without a site")
+	}
+	if err := s.validateKeyValue(w.RecordCID, w.Record); err != nil {
+		return fmt.Errorf("validation failed: %w", err)
+	}
+	if w.Content != nil {
+		if err := s.validateKeyValue(w.ContentCID, w.Content); err != nil {
+			return fmt.Errorf("validation failed: %w", err)
+		}
+		if c := s.contentCache(); c != nil {
+			c.remove(w.ContentCID)
+		}
+		// Offloaded content goes to the backend first, on its own
+		if b, threshold, cacheTTL := s.blobBackend(); b != nil && len(w.Content) >= threshold {
+			if err := s.putBlob(b, cacheTTL, w.ContentCID, w.Content); err != nil {
+				return err
+			}
+			w.Content = nil
+		}
+	}
+
+	p := &pendingUpdate{w: w, wake: make(chan struct{}, 1)}
+	b := &s.updates
+	b.mu.Lock()
+	b.queue = append(b.queue, p)
+	lead := !b.leading
+	b.leading = true
+	b.mu.Unlock()
+	if !lead {
+		if <-p.wake; p.done {
+			return p.err
+		}
+	}
+
+	// Leading: commit the front of the queue, which starts with p
+	b.mu.Lock()
+	batch := b.queue[:min(len(b.queue), MaxUpdateBatch)]
+	b.queue = b.queue[len(batch):]
+	b.mu.Unlock()
+	s.commitUpdates(batch)
+	for _, q := range batch[1:] {
+		q.wake <- struct{}{}
+	}
+
+	b.mu.Lock()
+	if len(b.queue) > 0 {
+		b.queue[0].wake <- struct{}{} // hand the lead on
+	} else {
+		b.leading = false
+	}
+	b.mu.Unlock()
+	return p.err
+}
+
+// commitUpdates writes batch in one transaction. If that fails, each
+// update is committed on its own so one bad write fails only its caller.
+func (s *Store) commitUpdates(batch []*pendingUpdate) {
+	err := s.update(func(txn *countedTxn) error {
+		for _, p := range batch {
+			if err := s.writeUpdate(txn, p.w); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil && len(batch) > 1 {
+		for _, p := range batch {
+			p.err = s.update(func(txn *countedTxn) error { return s.writeUpdate(txn, p.w) })
+			p.done = true
+		}
+		return
+	}
+	for _, p := range batch {
+		p.err, p.done = err, true
+	}
+}
+
+// writeUpdate adds w's writes to txn.
+func (s *Store) writeUpdate(txn *countedTxn, w UpdateWrite) error {
+	if err := txn.Set([]byte("record:"+w.RecordCID), w.Record); err != nil {
+		return err
+	}
+	if err := indexRecord(txn, w.RecordCID, w.Record); err != nil {
+		return err
+	}
+	if w.Content != nil {
+		if err := txn.SetEntry(s.contentEntry(w.ContentCID, w.Content)); err != nil {
+			return err
+		}
+	}
+	return txn.Set([]byte(fmt.Sprintf("site:%s:head:%d", w.SiteID, w.Seq)), []byte(w.RecordCID))
+}
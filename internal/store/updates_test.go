@@ -0,0 +1,67 @@
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+
+	"alxnet/internal/core"
+)
+
+func TestPutUpdateBatches(t *testing.T) {
+	s := openTestStore(t)
+
+	// More concurrent updates than fit one batch, one site each
+	const updates = MaxUpdateBatch + 36
+	writes := make([]UpdateWrite, updates)
+	for i := range writes {
+		record := []byte(fmt.Sprintf("record %d", i))
+		content := []byte(fmt.Sprintf("content %d", i))
+		writes[i] = UpdateWrite{
+			SiteID:     core.CIDForContent([]byte(fmt.Sprintf("site %d", i))),
+			Seq:        1,
+			RecordCID:  core.CIDForBytes(record),
+			Record:     record,
+			ContentCID: core.CIDForContent(content),
+		}
+		if i%2 == 0 {
+			writes[i].Content = content
+		}
+	}
+	var wg sync.WaitGroup
+	for _, w := range writes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.PutUpdate(w); err != nil {
+				t.Errorf("PutUpdate(%s): %v", w.SiteID[:8], err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i, w := range writes {
+		seq, head, err := s.GetHead(w.SiteID)
+		if err != nil || seq != 1 || head != w.RecordCID {
+			t.Errorf("update %d: head %d %s, %v", i, seq, head, err)
+		}
+		if rec, err := s.GetRecord(w.RecordCID); err != nil || !bytes.Equal(rec, w.Record) {
+			t.Errorf("update %d: record %q, %v", i, rec, err)
+		}
+		has, _ := s.HasContent(w.ContentCID)
+		if has != (w.Content != nil) {
+			t.Errorf("update %d: content stored %v, carried %v", i, has, w.Content != nil)
+		}
+	}
+	if s.updates.leading || len(s.updates.queue) != 0 {
+		t.Errorf("batch left behind: leading %v, %d queued", s.updates.leading, len(s.updates.queue))
+	}
+	if st, _ := s.GetStats(); st.TotalRecords != updates || st.TotalContent != updates/2 {
+		t.Errorf("stats: %d records, %d content, want %d and %d", st.TotalRecords, st.TotalContent, updates, updates/2)
+	}
+
+	if err := s.PutUpdate(UpdateWrite{Seq: 1, RecordCID: writes[0].RecordCID, Record: writes[0].Record}); err == nil {
+		t.Error("PutUpdate() without a site succeeded")
+	}
+}
@@ -0,0 +1,189 @@
+package store
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+
+	"alxnet/internal/core"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// maxManifestChain bounds how far back a site's manifest chain is followed
+// when its usage is measured.
+const maxManifestChain = 10000
+
+// SiteUsage is the local disk space a site takes up. Content shared with
+// other sites is attributed to each of them in equal parts, so the
+// attributed bytes of all sites add up, to rounding, to the content stored
+// for sites.
+// Sizes are Badger's value sizes, after compression, and do not include
+// blobs kept only by the blob backend.
+type SiteUsage struct {
+	SiteID        string `json:"site_id"`
+	Records       int    `json:"records"`        // update records, manifests and file records
+	RecordBytes   int64  `json:"record_bytes"`   // their total size
+	Content       int    `json:"content"`        // content blobs the site uses
+	ContentBytes  int64  `json:"content_bytes"`  // their total size, shared ones in full
+	SharedContent int    `json:"shared_content"` // content also used by other sites
+	// UniqueBytes is the content only this site uses, which removing the
+	// site would free.
+	UniqueBytes int64 `json:"unique_bytes"`
+	// AttributedBytes is ContentBytes with each shared blob divided among
+	// the sites using it.
+	AttributedBytes int64 `json:"attributed_bytes"`
+	TotalBytes      int64 `json:"total_bytes"` // RecordBytes + AttributedBytes
+}
+
+// SitesUsage measures the disk usage of every stored site, largest first.
+// Attributing shared content takes every site's content, so this walks
+// the whole store.
+func (s *Store) SitesUsage() ([]SiteUsage, error) {
+	sites, err := s.ListSites()
+	if err != nil {
+		return nil, err
+	}
+	contents := make([]map[string]bool, len(sites))
+	users := make(map[string]int) // content CID -> sites using it
+	for i, siteID := range sites {
+		if contents[i], err = s.SiteContent(siteID); err != nil {
+			return nil, err
+		}
+		for cid := range contents[i] {
+			users[cid]++
+		}
+	}
+	sizes, err := s.contentSizes(users)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := make([]SiteUsage, len(sites))
+	for i, siteID := range sites {
+		u := &usage[i]
+		u.SiteID = siteID
+		if u.Records, u.RecordBytes, err = s.siteRecordUsage(siteID); err != nil {
+			return nil, err
+		}
+		for cid := range contents[i] {
+			size, ok := sizes[cid]
+			if !ok {
+				continue // not stored here
+			}
+			u.Content++
+			u.ContentBytes += size
+			u.AttributedBytes += size / int64(users[cid])
+			if users[cid] > 1 {
+				u.SharedContent++
+			} else {
+				u.UniqueBytes += size
+			}
+		}
+		u.TotalBytes = u.RecordBytes + u.AttributedBytes
+	}
+	slices.SortFunc(usage, func(a, b SiteUsage) int {
+		return cmp.Or(cmp.Compare(b.TotalBytes, a.TotalBytes), cmp.Compare(a.SiteID, b.SiteID))
+	})
+	return usage, nil
+}
+
+// contentSizes returns the stored size of each of cids that is stored.
+func (s *Store) contentSizes(cids map[string]int) (map[string]int64, error) {
+	sizes := make(map[string]int64, len(cids))
+	err := s.db.View(func(txn kvTxn) error {
+		for cid := range cids {
+			item, err := txn.Get([]byte("content:" + cid))
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			sizes[cid] = item.ValueSize()
+		}
+		return nil
+	})
+	return sizes, err
+}
+
+// siteRecordUsage counts and sizes the update records, manifest chain and
+// file records stored for siteID.
+func (s *Store) siteRecordUsage(siteID string) (int, int64, error) {
+	if err := s.validateKey(siteID); err != nil {
+		return 0, 0, fmt.Errorf("validation failed: %w", err)
+	}
+	var count int
+	var bytes int64
+	counted := make(map[string]bool)
+	add := func(txn kvTxn, key string) (kvItem, error) {
+		if counted[key] {
+			return nil, nil
+		}
+		counted[key] = true
+		item, err := txn.Get([]byte(key))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		count++
+		bytes += item.ValueSize()
+		return item, nil
+	}
+	err := s.db.View(func(txn kvTxn) error {
+		// Heads point at records too, including ones the index skips
+		for _, p := range []struct{ prefix, target string }{
+			{string(siteRecordPrefix(siteID)), "record:"},
+			{"site:" + siteID + ":head:", "record:"},
+			{"site:" + siteID + ":file:", "filerecord:"},
+		} {
+			opts := badger.DefaultIteratorOptions
+			opts.Prefix = []byte(p.prefix)
+			it := txn.NewIterator(opts)
+			for it.Rewind(); it.Valid(); it.Next() {
+				cid, err := it.Item().ValueCopy(nil)
+				if err == nil {
+					_, err = add(txn, p.target+string(cid))
+				}
+				if err != nil {
+					it.Close()
+					return err
+				}
+			}
+			it.Close()
+		}
+
+		// Manifests are chained by PrevCID from the current one
+		item, err := txn.Get([]byte("site:" + siteID + ":manifest"))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		cid, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		for next, hops := string(cid), 0; next != "" && hops < maxManifestChain; hops++ {
+			item, err := add(txn, "manifest:"+next)
+			if err != nil || item == nil {
+				return err
+			}
+			data, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			var m core.WebsiteManifest
+			if core.UnmarshalCBOR(data, &m) != nil {
+				break
+			}
+			next = m.PrevCID
+		}
+		return nil
+	})
+	return count, bytes, err
+}
@@ -0,0 +1,66 @@
+package store
+
+import (
+	"testing"
+
+	"alxnet/internal/core"
+)
+
+func TestSitesUsage(t *testing.T) {
+	s := openTestStore(t)
+	const siteA = "aa00000000000000000000000000000000000000000000000000000000000000"
+	const siteB = "bb00000000000000000000000000000000000000000000000000000000000000"
+	// Both sites use the same two files
+	putTestWebsite(t, s, siteA)
+	putTestWebsite(t, s, siteB)
+	only := []byte("only site A uses this")
+	onlyCID := core.CIDForContent(only)
+	if err := s.PutContent(onlyCID, only); err != nil {
+		t.Fatalf("PutContent: %v", err)
+	}
+	rec, err := core.CanonicalMarshalFileRecord(&core.FileRecord{Version: "1.0", Path: "only.txt", ContentCID: onlyCID, TS: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PutFileRecord(siteA, "only.txt", core.CIDForBytes(rec), rec); err != nil {
+		t.Fatalf("PutFileRecord: %v", err)
+	}
+
+	usage, err := s.SitesUsage()
+	if err != nil {
+		t.Fatalf("SitesUsage: %v", err)
+	}
+	if len(usage) != 2 || usage[0].SiteID != siteA || usage[1].SiteID != siteB {
+		t.Fatalf("usage = %+v, want site A then site B", usage)
+	}
+	a, b := usage[0], usage[1]
+	tests := []struct {
+		name      string
+		got, want int
+	}{
+		{"A records", a.Records, 5}, // 3 file records, the manifest, the head record
+		{"B records", b.Records, 4},
+		{"A content", a.Content, 3},
+		{"B content", b.Content, 2},
+		{"A shared", a.SharedContent, 2},
+		{"B shared", b.SharedContent, 2},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %d, want %d", tt.name, tt.got, tt.want)
+		}
+	}
+	if a.UniqueBytes <= 0 || b.UniqueBytes != 0 {
+		t.Errorf("unique bytes = %d, %d; want only site A's", a.UniqueBytes, b.UniqueBytes)
+	}
+	if a.ContentBytes != b.ContentBytes+a.UniqueBytes {
+		t.Errorf("content bytes = %d, %d with %d unique to A", a.ContentBytes, b.ContentBytes, a.UniqueBytes)
+	}
+	// Shared content is split, so the sites add up to what is stored
+	if sum, stored := a.AttributedBytes+b.AttributedBytes, a.ContentBytes; sum < stored-2 || sum > stored {
+		t.Errorf("attributed bytes add up to %d, stored %d", sum, stored)
+	}
+	if a.TotalBytes != a.RecordBytes+a.AttributedBytes {
+		t.Errorf("total bytes = %d, want %d", a.TotalBytes, a.RecordBytes+a.AttributedBytes)
+	}
+}
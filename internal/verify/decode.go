@@ -0,0 +1,166 @@
+package verify
+
+import (
+	"errors"
+	"fmt"
+
+	"alxnet/internal/core"
+)
+
+// The Check functions decode a record as peers and stores hold it, in
+// canonical CBOR, verify it and describe it with keys and CIDs in hex, the
+// form front ends display. An empty siteID or path skips that check; the
+// returned SiteID then says whom the record belongs to.
+
+// ManifestInfo describes a website manifest.
+type ManifestInfo struct {
+	CID      string            `json:"cid"`
+	SiteID   string            `json:"site_id"`
+	Seq      uint64            `json:"seq"`
+	PrevCID  string            `json:"prev_cid,omitempty"`
+	TS       int64             `json:"ts"`
+	MainFile string            `json:"main_file"`
+	Files    map[string]string `json:"files"`
+	Root     string            `json:"root"` // ManifestFilesCID of Files
+	NoIndex  bool              `json:"no_index,omitempty"`
+	Preload  []string          `json:"preload,omitempty"`
+	Verified bool              `json:"verified"` // both signatures checked
+}
+
+// FileRecordInfo describes a file record.
+type FileRecordInfo struct {
+	CID        string `json:"cid"`
+	SiteID     string `json:"site_id"`
+	Path       string `json:"path"`
+	ContentCID string `json:"content_cid"`
+	MimeType   string `json:"mime_type,omitempty"`
+	TS         int64  `json:"ts"`
+	Verified   bool   `json:"verified"`
+}
+
+// UpdateInfo describes an update record.
+type UpdateInfo struct {
+	CID        string `json:"cid"`
+	SiteID     string `json:"site_id"`
+	Seq        uint64 `json:"seq"`
+	PrevCID    string `json:"prev_cid,omitempty"`
+	ContentCID string `json:"content_cid"`
+	TS         int64  `json:"ts"`
+	Verified   bool   `json:"verified"`
+}
+
+// decode decodes a record of at most core.MaxRecordSize bytes into v.
+func decode(data []byte, v interface{}, kind string) error {
+	if len(data) == 0 {
+		return fmt.Errorf("empty %s", kind)
+	}
+	if len(data) > core.MaxRecordSize {
+		return fmt.Errorf("%s too large: %d > %d bytes", kind, len(data), core.MaxRecordSize)
+	}
+	if err := core.UnmarshalCBOR(data, v); err != nil {
+		return fmt.Errorf("invalid %s: %w", kind, err)
+	}
+	return nil
+}
+
+// SiteID returns the site ID of a 32-byte ed25519 public key.
+func SiteID(pub []byte) (string, error) {
+	if len(pub) != 32 {
+		return "", fmt.Errorf("invalid public key length: %d (expected 32)", len(pub))
+	}
+	return core.SiteIDFromPub(pub), nil
+}
+
+// ParseManifest decodes a manifest without verifying it.
+func ParseManifest(data []byte) (*ManifestInfo, error) {
+	var m core.WebsiteManifest
+	if err := decode(data, &m, "manifest"); err != nil {
+		return nil, err
+	}
+	return manifestInfo(&m, data), nil
+}
+
+// CheckManifest decodes and verifies a manifest of siteID.
+func CheckManifest(data []byte, siteID string) (*ManifestInfo, error) {
+	var m core.WebsiteManifest
+	if err := decode(data, &m, "manifest"); err != nil {
+		return nil, err
+	}
+	var err error
+	if siteID == "" {
+		err = WebsiteManifest(&m)
+	} else {
+		err = SiteManifest(&m, siteID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	info := manifestInfo(&m, data)
+	info.Verified = true
+	return info, nil
+}
+
+func manifestInfo(m *core.WebsiteManifest, data []byte) *ManifestInfo {
+	root, _ := ManifestFilesCID(m.Files)
+	return &ManifestInfo{
+		CID:      core.CIDForBytes(data),
+		SiteID:   core.SiteIDFromPub(m.SitePub),
+		Seq:      m.Seq,
+		PrevCID:  m.PrevCID,
+		TS:       m.TS,
+		MainFile: m.MainFile,
+		Files:    m.Files,
+		Root:     root,
+		NoIndex:  m.NoIndex,
+		Preload:  m.Preload,
+	}
+}
+
+// CheckFileRecord decodes and verifies the file record of path in siteID.
+func CheckFileRecord(data []byte, siteID, path string) (*FileRecordInfo, error) {
+	var rec core.FileRecord
+	if err := decode(data, &rec, "file record"); err != nil {
+		return nil, err
+	}
+	if err := FileRecord(&rec); err != nil {
+		return nil, err
+	}
+	if siteID != "" && core.SiteIDFromPub(rec.SitePub) != siteID {
+		return nil, errors.New("file record signed for another site")
+	}
+	if path != "" && rec.Path != path {
+		return nil, fmt.Errorf("file record is for %q, not %q", rec.Path, path)
+	}
+	return &FileRecordInfo{
+		CID:        core.CIDForBytes(data),
+		SiteID:     core.SiteIDFromPub(rec.SitePub),
+		Path:       rec.Path,
+		ContentCID: rec.ContentCID,
+		MimeType:   rec.MimeType,
+		TS:         rec.TS,
+		Verified:   true,
+	}, nil
+}
+
+// CheckUpdateRecord decodes and verifies an update record of siteID.
+func CheckUpdateRecord(data []byte, siteID string) (*UpdateInfo, error) {
+	var r core.UpdateRecord
+	if err := decode(data, &r, "update record"); err != nil {
+		return nil, err
+	}
+	if err := UpdateRecord(&r); err != nil {
+		return nil, err
+	}
+	if siteID != "" && core.SiteIDFromPub(r.SitePub) != siteID {
+		return nil, errors.New("update record signed for another site")
+	}
+	return &UpdateInfo{
+		CID:        core.CIDForBytes(data),
+		SiteID:     core.SiteIDFromPub(r.SitePub),
+		Seq:        r.Seq,
+		PrevCID:    r.PrevCID,
+		ContentCID: r.ContentCID,
+		TS:         r.TS,
+		Verified:   true,
+	}, nil
+}
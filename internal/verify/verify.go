@@ -0,0 +1,125 @@
+// Package verify checks AlxNet records without a node or store: it decodes
+// update records, file records and website manifests and checks both of
+// their signatures. It depends only on core and crypto, so it also builds
+// for js/wasm, where cmd/alxnet-wasm exposes it to JavaScript.
+package verify
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+
+	"alxnet/internal/core"
+	bncrypto "alxnet/internal/crypto"
+)
+
+// Signature reports whether sig is pub's ed25519 signature of msg. Keys
+// and signatures of the wrong length do not verify.
+func Signature(pub, msg, sig []byte) bool {
+	if len(pub) != ed25519.PublicKeySize || len(sig) != ed25519.SignatureSize {
+		return false
+	}
+	return ed25519.Verify(pub, msg, sig)
+}
+
+// UpdateRecord checks structure and both signatures of an update record.
+func UpdateRecord(r *core.UpdateRecord) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	if !Signature(r.SitePub, bncrypto.PreimageLink(r.SitePub, r.UpdatePub, r.Seq, r.PrevCID, r.ContentCID, r.TS), r.LinkSig) {
+		return errors.New("invalid link signature")
+	}
+	noUS, err := core.CanonicalMarshalNoUpdateSig(r)
+	if err != nil {
+		return err
+	}
+	if !Signature(r.UpdatePub, bncrypto.PreimageUpdate(noUS), r.UpdateSig) {
+		return errors.New("invalid update signature")
+	}
+	return nil
+}
+
+// FileRecord checks structure and both signatures of a file record.
+func FileRecord(rec *core.FileRecord) error {
+	if err := rec.Validate(); err != nil {
+		return err
+	}
+	if !Signature(rec.SitePub, bncrypto.PreimageFileLink(rec.SitePub, rec.UpdatePub, rec.Path, rec.ContentCID, rec.TS), rec.LinkSig) {
+		return errors.New("invalid file record link signature")
+	}
+	noUS, err := core.CanonicalMarshalFileRecordNoUpdateSig(rec)
+	if err != nil {
+		return err
+	}
+	if !Signature(rec.UpdatePub, bncrypto.PreimageUpdate(noUS), rec.UpdateSig) {
+		return errors.New("invalid file record update signature")
+	}
+	return nil
+}
+
+// ManifestFilesCID digests a manifest's file map for its link signature.
+// The digest is also the site's root hash: it depends only on the paths and
+// contents of the files, not on the version or time of the manifest, so a
+// publisher can compute it from the site's directory and share it, and a
+// reader can check that a gateway serves exactly those files.
+func ManifestFilesCID(files map[string]string) (string, error) {
+	b, err := core.MarshalCanonical(files)
+	if err != nil {
+		return "", err
+	}
+	return core.CIDForBytes(b), nil
+}
+
+// WebsiteManifest checks structure and both signatures of a manifest.
+func WebsiteManifest(m *core.WebsiteManifest) error {
+	if err := m.Validate(); err != nil {
+		return err
+	}
+	filesCID, err := ManifestFilesCID(m.Files)
+	if err != nil {
+		return err
+	}
+	if !Signature(m.SitePub, bncrypto.PreimageLink(m.SitePub, m.UpdatePub, m.Seq, m.PrevCID, filesCID, m.TS), m.LinkSig) {
+		return errors.New("invalid manifest link signature")
+	}
+	noUS, err := core.CanonicalMarshalWebsiteManifestNoUpdateSig(m)
+	if err != nil {
+		return err
+	}
+	if !Signature(m.UpdatePub, bncrypto.PreimageUpdate(noUS), m.UpdateSig) {
+		return errors.New("invalid manifest update signature")
+	}
+	return nil
+}
+
+// SiteFileRecord verifies a file record and checks that it belongs to
+// siteID and is stored under path.
+func SiteFileRecord(rec *core.FileRecord, siteID, path string) error {
+	if err := FileRecord(rec); err != nil {
+		return err
+	}
+	if core.SiteIDFromPub(rec.SitePub) != siteID {
+		return errors.New("file record signed for another site")
+	}
+	if rec.Path != path {
+		return fmt.Errorf("file record is for %q, not %q", rec.Path, path)
+	}
+	return nil
+}
+
+// SiteManifest verifies a manifest and checks that it belongs to siteID.
+func SiteManifest(m *core.WebsiteManifest, siteID string) error {
+	if err := WebsiteManifest(m); err != nil {
+		return err
+	}
+	if core.SiteIDFromPub(m.SitePub) != siteID {
+		return errors.New("manifest signed for another site")
+	}
+	return nil
+}
+
+// Content reports whether data is the blob cid names.
+func Content(data []byte, cid string) bool {
+	return core.CIDForContent(data) == cid
+}
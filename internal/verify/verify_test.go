@@ -0,0 +1,186 @@
+package verify_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"strings"
+	"testing"
+
+	"alxnet/internal/core"
+	"alxnet/internal/p2p"
+	"alxnet/internal/verify"
+)
+
+func newSiteKey(t *testing.T) (string, ed25519.PrivateKey) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return core.SiteIDFromPub(pub), priv
+}
+
+func mustMarshal(t *testing.T, v interface{}) []byte {
+	t.Helper()
+	b, err := core.MarshalCanonical(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestCheckManifest(t *testing.T) {
+	siteID, priv := newSiteKey(t)
+	otherSite, _ := newSiteKey(t)
+	files := map[string]string{"index.html": core.CIDForContent([]byte("<h1>hi</h1>"))}
+	m, err := p2p.BuildWebsiteManifest(priv, 1, "", "index.html", files, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	good := mustMarshal(t, m)
+	tampered := *m
+	tampered.Files = map[string]string{"index.html": core.CIDForContent([]byte("<h1>evil</h1>"))}
+	bad := mustMarshal(t, &tampered)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		siteID  string
+		wantErr string
+	}{
+		{"valid", good, siteID, ""},
+		{"any site", good, "", ""},
+		{"other site", good, otherSite, "another site"},
+		{"tampered files", bad, siteID, "signature"},
+		{"empty", nil, siteID, "empty"},
+		{"garbage", []byte{0xff, 0x00}, siteID, "invalid manifest"},
+		{"too large", make([]byte, core.MaxRecordSize+1), siteID, "too large"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := verify.CheckManifest(tt.data, tt.siteID)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("CheckManifest error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CheckManifest: %v", err)
+			}
+			if !info.Verified || info.SiteID != siteID || info.CID != core.CIDForBytes(good) || info.Files["index.html"] != files["index.html"] {
+				t.Errorf("CheckManifest = %+v", info)
+			}
+		})
+	}
+
+	// Parsing alone does not vouch for the manifest
+	info, err := verify.ParseManifest(bad)
+	if err != nil || info.Verified || info.MainFile != "index.html" {
+		t.Errorf("ParseManifest(tampered) = %+v, %v", info, err)
+	}
+
+	// The root depends on the files only, not on the version
+	next, err := p2p.BuildWebsiteManifest(priv, 2, info.CID, "index.html", files, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, _ := verify.ParseManifest(good)
+	second, _ := verify.ParseManifest(mustMarshal(t, next))
+	if first.Root == "" || first.Root != second.Root || first.Root == info.Root {
+		t.Errorf("roots: first %q, second %q, tampered %q", first.Root, second.Root, info.Root)
+	}
+}
+
+func TestCheckFileRecord(t *testing.T) {
+	siteID, priv := newSiteKey(t)
+	otherSite, _ := newSiteKey(t)
+	rec, err := p2p.BuildFileRecord(priv, "css/site.css", core.CIDForContent([]byte("h1 {}")), "text/css")
+	if err != nil {
+		t.Fatal(err)
+	}
+	good := mustMarshal(t, rec)
+	tampered := *rec
+	tampered.Path = "index.html"
+	bad := mustMarshal(t, &tampered)
+
+	tests := []struct {
+		name         string
+		data         []byte
+		siteID, path string
+		wantErr      string
+	}{
+		{"valid", good, siteID, "css/site.css", ""},
+		{"no checks", good, "", "", ""},
+		{"other path", good, siteID, "index.html", "not"},
+		{"other site", good, otherSite, "", "another site"},
+		{"tampered path", bad, siteID, "index.html", "signature"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := verify.CheckFileRecord(tt.data, tt.siteID, tt.path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("CheckFileRecord error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CheckFileRecord: %v", err)
+			}
+			if info.SiteID != siteID || info.Path != "css/site.css" || info.MimeType != "text/css" {
+				t.Errorf("CheckFileRecord = %+v", info)
+			}
+		})
+	}
+}
+
+func TestCheckUpdateRecord(t *testing.T) {
+	siteID, priv := newSiteKey(t)
+	content := []byte("<h1>single file</h1>")
+	env, recCID, err := p2p.SignUpdate(priv, content, 1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := verify.CheckUpdateRecord(env.Record, siteID)
+	if err != nil {
+		t.Fatalf("CheckUpdateRecord: %v", err)
+	}
+	if info.CID != recCID || info.Seq != 1 || !verify.Content(content, info.ContentCID) {
+		t.Errorf("CheckUpdateRecord = %+v, want record %s for the content", info, recCID)
+	}
+
+	var rec core.UpdateRecord
+	if err := core.UnmarshalCBOR(env.Record, &rec); err != nil {
+		t.Fatal(err)
+	}
+	rec.ContentCID = core.CIDForContent([]byte("other"))
+	if _, err := verify.CheckUpdateRecord(mustMarshal(t, &rec), siteID); err == nil {
+		t.Error("CheckUpdateRecord accepted a record pointing at other content")
+	}
+}
+
+func TestSignature(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	msg := []byte("message")
+	sig := ed25519.Sign(priv, msg)
+	tests := []struct {
+		name          string
+		pub, msg, sig []byte
+		want          bool
+	}{
+		{"valid", pub, msg, sig, true},
+		{"other message", pub, []byte("other"), sig, false},
+		{"short key", pub[:31], msg, sig, false},
+		{"short signature", pub, msg, sig[:63], false},
+		{"nothing", nil, nil, nil, false},
+	}
+	for _, tt := range tests {
+		if got := verify.Signature(tt.pub, tt.msg, tt.sig); got != tt.want {
+			t.Errorf("%s: Signature = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if _, err := verify.SiteID(pub[:16]); err == nil {
+		t.Error("SiteID accepted a short key")
+	}
+}
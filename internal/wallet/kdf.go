@@ -0,0 +1,138 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Supported key derivation functions for wallet and keystore encryption
+const (
+	KDFArgon2id = "argon2id"
+	KDFScrypt   = "scrypt"
+)
+
+// Bounds on KDF parameters. They are enforced both when encrypting and when
+// reading parameters back from a file, so a crafted wallet or keystore can
+// neither panic the KDF nor force an unbounded allocation: whatever the
+// combination, a derivation uses at most maxKDFMemory.
+const (
+	maxKDFMemory   = 1 << 30 // bytes
+	minArgonTime   = 1
+	maxArgonTime   = 10
+	minArgonMemory = 8                  // MiB
+	maxArgonMemory = maxKDFMemory >> 20 // MiB
+	minThreads     = 1
+	maxThreads     = 16
+	minScryptLogN  = 14
+	maxScryptLogN  = 22
+	minScryptR     = 1
+	maxScryptR     = 32
+)
+
+// KDFParams selects the key derivation function and its cost parameters.
+// The JSON tags match the fields historically written to wallet files, so
+// legacy argon2id wallets decode into the same structure.
+type KDFParams struct {
+	KDF       string `json:"kdf"`
+	Time      uint32 `json:"t,omitempty"` // argon2id iterations
+	MemoryMiB uint32 `json:"m,omitempty"` // argon2id memory in MiB
+	Threads   uint8  `json:"p,omitempty"` // parallelism (argon2id lanes / scrypt p)
+	LogN      uint8  `json:"n,omitempty"` // scrypt cost, N = 2^LogN
+	R         uint32 `json:"r,omitempty"` // scrypt block size
+}
+
+// DefaultKDFParams returns the parameters used for newly encrypted wallets.
+func DefaultKDFParams() KDFParams {
+	return KDFParams{
+		KDF:       KDFArgon2id,
+		Time:      3,
+		MemoryMiB: 64,
+		Threads:   4,
+	}
+}
+
+// DefaultScryptParams returns reasonable scrypt parameters for callers that
+// prefer scrypt over argon2id.
+func DefaultScryptParams() KDFParams {
+	return KDFParams{
+		KDF:     KDFScrypt,
+		LogN:    17,
+		R:       8,
+		Threads: 1,
+	}
+}
+
+// Validate checks that the parameters are within supported bounds.
+func (p KDFParams) Validate() error {
+	if p.Threads < minThreads || p.Threads > maxThreads {
+		return fmt.Errorf("%s parallelism out of range: %d", p.KDF, p.Threads)
+	}
+	switch p.KDF {
+	case KDFArgon2id:
+		if p.Time < minArgonTime || p.Time > maxArgonTime {
+			return fmt.Errorf("argon2id iterations out of range: %d", p.Time)
+		}
+		if p.MemoryMiB < minArgonMemory || p.MemoryMiB > maxArgonMemory {
+			return fmt.Errorf("argon2id memory out of range: %d MiB", p.MemoryMiB)
+		}
+	case KDFScrypt:
+		if p.LogN < minScryptLogN || p.LogN > maxScryptLogN {
+			return fmt.Errorf("scrypt cost out of range: 2^%d", p.LogN)
+		}
+		if p.R < minScryptR || p.R > maxScryptR {
+			return fmt.Errorf("scrypt block size out of range: %d", p.R)
+		}
+		// scrypt works through 128·r·N bytes, p times
+		if mem := 128 * uint64(p.R) * (1 << p.LogN) * uint64(p.Threads); mem > maxKDFMemory {
+			return fmt.Errorf("scrypt parameters need %d MiB, more than the %d MiB allowed", mem>>20, maxKDFMemory>>20)
+		}
+	case "":
+		return errors.New("kdf is required")
+	default:
+		return fmt.Errorf("unsupported kdf: %s", p.KDF)
+	}
+	return nil
+}
+
+// WeakerThan reports whether p should be upgraded to reach target: either
+// the KDF differs or any cost parameter is lower.
+func (p KDFParams) WeakerThan(target KDFParams) bool {
+	if p.KDF != target.KDF {
+		return true
+	}
+	switch p.KDF {
+	case KDFArgon2id:
+		return p.Time < target.Time || p.MemoryMiB < target.MemoryMiB || p.Threads < target.Threads
+	case KDFScrypt:
+		return p.LogN < target.LogN || p.R < target.R || p.Threads < target.Threads
+	}
+	return false
+}
+
+// String renders the parameters for logs and CLI output.
+func (p KDFParams) String() string {
+	switch p.KDF {
+	case KDFArgon2id:
+		return fmt.Sprintf("argon2id(t=%d, m=%dMiB, p=%d)", p.Time, p.MemoryMiB, p.Threads)
+	case KDFScrypt:
+		return fmt.Sprintf("scrypt(N=2^%d, r=%d, p=%d)", p.LogN, p.R, p.Threads)
+	}
+	return p.KDF
+}
+
+// deriveKey stretches secret into a 32-byte key. Parameters are validated
+// first, so it is safe to call with values read from untrusted files.
+func (p KDFParams) deriveKey(secret, salt []byte) ([]byte, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	switch p.KDF {
+	case KDFScrypt:
+		return scrypt.Key(secret, salt, 1<<p.LogN, int(p.R), int(p.Threads), 32)
+	default:
+		return argon2.IDKey(secret, salt, p.Time, p.MemoryMiB*1024, p.Threads, 32), nil
+	}
+}
@@ -0,0 +1,141 @@
+package wallet
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestKDFParamsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  KDFParams
+		wantErr bool
+		errMsg  string
+	}{
+		{"default", DefaultKDFParams(), false, ""},
+		{"default scrypt", DefaultScryptParams(), false, ""},
+		{"legacy argon2id", KDFParams{KDF: KDFArgon2id, Time: 2, MemoryMiB: 64, Threads: 4}, false, ""},
+		{"missing kdf", KDFParams{Time: 2, MemoryMiB: 64, Threads: 4}, true, "kdf is required"},
+		{"unknown kdf", KDFParams{KDF: "pbkdf2", Threads: 1}, true, "unsupported kdf"},
+		{"argon2id zero time", KDFParams{KDF: KDFArgon2id, Time: 0, MemoryMiB: 64, Threads: 4}, true, "iterations out of range"},
+		{"argon2id huge memory", KDFParams{KDF: KDFArgon2id, Time: 2, MemoryMiB: 1 << 20, Threads: 4}, true, "memory out of range"},
+		{"zero threads", KDFParams{KDF: KDFArgon2id, Time: 2, MemoryMiB: 64, Threads: 0}, true, "parallelism out of range"},
+		{"scrypt low cost", KDFParams{KDF: KDFScrypt, LogN: 10, R: 8, Threads: 1}, true, "scrypt cost out of range"},
+		{"scrypt zero r", KDFParams{KDF: KDFScrypt, LogN: 15, R: 0, Threads: 1}, true, "block size out of range"},
+		{"scrypt at the memory bound", KDFParams{KDF: KDFScrypt, LogN: 20, R: 8, Threads: 1}, false, ""},
+		{"scrypt over the memory bound", KDFParams{KDF: KDFScrypt, LogN: 22, R: 32, Threads: 1}, true, "more than the 1024 MiB allowed"},
+		{"scrypt parallelism over the memory bound", KDFParams{KDF: KDFScrypt, LogN: 20, R: 8, Threads: 2}, true, "more than the 1024 MiB allowed"},
+		{"argon2id at the memory bound", KDFParams{KDF: KDFArgon2id, Time: 1, MemoryMiB: 1024, Threads: 4}, false, ""},
+		{"argon2id over the memory bound", KDFParams{KDF: KDFArgon2id, Time: 1, MemoryMiB: 1025, Threads: 4}, true, "memory out of range"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.params.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("Validate() error = %v, want it to contain %q", err, tt.errMsg)
+			}
+		})
+	}
+}
+
+func TestWalletEncryptionParams(t *testing.T) {
+	mnemonic, err := NewMnemonic()
+	if err != nil {
+		t.Fatalf("NewMnemonic: %v", err)
+	}
+
+	legacy := KDFParams{KDF: KDFArgon2id, Time: 2, MemoryMiB: 64, Threads: 4}
+	scryptParams := KDFParams{KDF: KDFScrypt, LogN: 14, R: 8, Threads: 1}
+
+	for _, params := range []KDFParams{legacy, scryptParams} {
+		t.Run(params.KDF, func(t *testing.T) {
+			enc, err := EncryptWalletWithParams(New(), mnemonic, "", params)
+			if err != nil {
+				t.Fatalf("EncryptWalletWithParams() error = %v", err)
+			}
+			got, err := WalletKDFParams(enc)
+			if err != nil {
+				t.Fatalf("WalletKDFParams() error = %v", err)
+			}
+			if got != params {
+				t.Errorf("WalletKDFParams() = %v, want %v", got, params)
+			}
+			if _, err := DecryptWallet(enc, mnemonic, ""); err != nil {
+				t.Fatalf("DecryptWallet() error = %v", err)
+			}
+		})
+	}
+
+	enc, err := EncryptWalletWithParams(New(), mnemonic, "", legacy)
+	if err != nil {
+		t.Fatalf("EncryptWalletWithParams() error = %v", err)
+	}
+	if !legacy.WeakerThan(DefaultKDFParams()) {
+		t.Error("legacy parameters should be weaker than the defaults")
+	}
+	upgraded, err := UpgradeWallet(enc, mnemonic, "", DefaultKDFParams())
+	if err != nil {
+		t.Fatalf("UpgradeWallet() error = %v", err)
+	}
+	got, err := WalletKDFParams(upgraded)
+	if err != nil {
+		t.Fatalf("WalletKDFParams() error = %v", err)
+	}
+	if got.WeakerThan(DefaultKDFParams()) {
+		t.Errorf("upgraded wallet still uses %v", got)
+	}
+	if _, err := DecryptWallet(upgraded, mnemonic, ""); err != nil {
+		t.Fatalf("DecryptWallet(upgraded) error = %v", err)
+	}
+
+	// A crafted file asking scrypt for 16 GiB is refused before deriving
+	enc, err = EncryptWalletWithParams(New(), mnemonic, "", scryptParams)
+	if err != nil {
+		t.Fatalf("EncryptWalletWithParams() error = %v", err)
+	}
+	var file map[string]any
+	if err := json.Unmarshal(enc, &file); err != nil {
+		t.Fatal(err)
+	}
+	file["n"], file["r"] = 22, 32
+	crafted, _ := json.Marshal(file)
+	if _, err := DecryptWallet(crafted, mnemonic, ""); err == nil || !strings.Contains(err.Error(), "MiB allowed") {
+		t.Errorf("DecryptWallet(crafted) error = %v", err)
+	}
+}
+
+func TestPassphraseIsolatesWallets(t *testing.T) {
+	mnemonic, err := NewMnemonic()
+	if err != nil {
+		t.Fatalf("NewMnemonic: %v", err)
+	}
+
+	plain, err := MasterKeyFromMnemonic(mnemonic, "")
+	if err != nil {
+		t.Fatalf("MasterKeyFromMnemonic() error = %v", err)
+	}
+	hidden, err := MasterKeyFromMnemonic(mnemonic, "hidden wallet")
+	if err != nil {
+		t.Fatalf("MasterKeyFromMnemonic() error = %v", err)
+	}
+	if string(plain) == string(hidden) {
+		t.Fatal("passphrase did not change the master key")
+	}
+
+	params := KDFParams{KDF: KDFArgon2id, Time: 1, MemoryMiB: 8, Threads: 1}
+	enc, err := EncryptWalletWithParams(New(), mnemonic, "hidden wallet", params)
+	if err != nil {
+		t.Fatalf("EncryptWalletWithParams() error = %v", err)
+	}
+	if _, err := DecryptWallet(enc, mnemonic, ""); err == nil {
+		t.Error("wallet encrypted with a passphrase opened without it")
+	}
+	if _, err := DecryptWallet(enc, mnemonic, "hidden wallet"); err != nil {
+		t.Errorf("DecryptWallet() with passphrase error = %v", err)
+	}
+}
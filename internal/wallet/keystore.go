@@ -0,0 +1,275 @@
+package wallet
+
+import (
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"alxnet/internal/core"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	keystoreVersion = 1
+	adKeystore      = "ax-keystore-v1"
+	adImported      = "ax-imported-v1"
+	keystoreSaltLen = 16
+)
+
+// ErrKeystorePassphrase is returned by ImportKeystore when the keystore
+// does not open with the passphrase.
+var ErrKeystorePassphrase = errors.New("bad passphrase or corrupted keystore")
+
+// Keystore is a portable, passphrase-encrypted export of a single site key.
+// The public half is kept in the clear so the file can be identified without
+// decrypting it; only the Ed25519 seed is sealed.
+type Keystore struct {
+	Version    int    `json:"v"`
+	Label      string `json:"label"`
+	SiteID     string `json:"site_id"`
+	SitePubHex string `json:"site_pub"`
+	KDFParams
+	SaltB64   string    `json:"salt"`
+	NonceB64  string    `json:"nonce"`
+	CipherB64 string    `json:"ct"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ExportKeystore seals a site private key with a passphrase using
+// argon2id and XChaCha20-Poly1305.
+func ExportKeystore(label string, priv ed25519.PrivateKey, passphrase string) ([]byte, error) {
+	if err := validateImportLabel(label); err != nil {
+		return nil, err
+	}
+	if len(priv) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("invalid private key length: %d", len(priv))
+	}
+	if err := ValidatePassphrase(passphrase, false); err != nil {
+		return nil, fmt.Errorf("invalid passphrase: %w", err)
+	}
+
+	pub := priv.Public().(ed25519.PublicKey)
+
+	salt := make([]byte, keystoreSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	params := DefaultKDFParams()
+	key, err := params.deriveKey([]byte(passphrase), salt)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, chacha20poly1305.NonceSizeX)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	// Bind the ciphertext to the public key so the clear-text header
+	// cannot be swapped onto another keystore's payload.
+	ad := append([]byte(adKeystore), pub...)
+	ct := aead.Seal(nil, nonce, priv.Seed(), ad)
+
+	ks := Keystore{
+		Version:    keystoreVersion,
+		Label:      label,
+		SiteID:     core.SiteIDFromPub(pub),
+		SitePubHex: hex.EncodeToString(pub),
+		KDFParams:  params,
+		SaltB64:    base64.StdEncoding.EncodeToString(salt),
+		NonceB64:   base64.StdEncoding.EncodeToString(nonce),
+		CipherB64:  base64.StdEncoding.EncodeToString(ct),
+		CreatedAt:  time.Now(),
+	}
+	return json.MarshalIndent(ks, "", "  ")
+}
+
+// ImportKeystore decrypts a keystore produced by ExportKeystore and returns
+// its label and private key.
+func ImportKeystore(data []byte, passphrase string) (string, ed25519.PrivateKey, error) {
+	if len(data) > MaxWalletSize {
+		return "", nil, errors.New("keystore too large")
+	}
+	var ks Keystore
+	if err := json.Unmarshal(data, &ks); err != nil {
+		return "", nil, fmt.Errorf("invalid keystore: %w", err)
+	}
+	if ks.Version != keystoreVersion {
+		return "", nil, errors.New("unsupported keystore format")
+	}
+	if err := validateImportLabel(ks.Label); err != nil {
+		return "", nil, fmt.Errorf("invalid keystore label: %w", err)
+	}
+	if err := ks.KDFParams.Validate(); err != nil {
+		return "", nil, fmt.Errorf("unsupported keystore format: %w", err)
+	}
+
+	pub, err := hex.DecodeString(ks.SitePubHex)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return "", nil, errors.New("invalid keystore public key")
+	}
+	salt, err := base64.StdEncoding.DecodeString(ks.SaltB64)
+	if err != nil || len(salt) != keystoreSaltLen {
+		return "", nil, errors.New("invalid keystore salt")
+	}
+	nonce, err := base64.StdEncoding.DecodeString(ks.NonceB64)
+	if err != nil || len(nonce) != chacha20poly1305.NonceSizeX {
+		return "", nil, errors.New("invalid keystore nonce")
+	}
+	ct, err := base64.StdEncoding.DecodeString(ks.CipherB64)
+	if err != nil {
+		return "", nil, errors.New("invalid keystore ciphertext")
+	}
+
+	key, err := ks.KDFParams.deriveKey([]byte(passphrase), salt)
+	if err != nil {
+		return "", nil, err
+	}
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return "", nil, err
+	}
+	ad := append([]byte(adKeystore), pub...)
+	seed, err := aead.Open(nil, nonce, ct, ad)
+	if err != nil {
+		return "", nil, ErrKeystorePassphrase
+	}
+	if len(seed) != ed25519.SeedSize {
+		return "", nil, errors.New("invalid keystore payload")
+	}
+
+	priv := ed25519.NewKeyFromSeed(seed)
+	if !priv.Public().(ed25519.PublicKey).Equal(ed25519.PublicKey(pub)) {
+		return "", nil, errors.New("keystore public key does not match private key")
+	}
+	return ks.Label, priv, nil
+}
+
+// ImportSiteKey stores an externally generated site key in the wallet under
+// label. The seed is sealed with a key derived from the wallet's master key,
+// so the wallet JSON never carries it in the clear. Imported keys take
+// precedence over mnemonic-derived keys in EnsureSite.
+func (w *Wallet) ImportSiteKey(master []byte, label string, priv ed25519.PrivateKey) (*SiteMeta, error) {
+	if err := validateImportLabel(label); err != nil {
+		return nil, err
+	}
+	if len(priv) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("invalid private key length: %d", len(priv))
+	}
+	if _, exists := w.Sites[label]; exists {
+		return nil, fmt.Errorf("site %s already exists in wallet", label)
+	}
+	if len(w.Sites) >= MaxSitesPerWallet {
+		return nil, fmt.Errorf("too many sites: %d", len(w.Sites))
+	}
+
+	aead, err := importAEAD(master, label)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, chacha20poly1305.NonceSizeX)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	sealed := aead.Seal(nonce, nonce, priv.Seed(), []byte(adImported))
+
+	if w.ImportedKeys == nil {
+		w.ImportedKeys = map[string]string{}
+	}
+	w.ImportedKeys[label] = base64.StdEncoding.EncodeToString(sealed)
+
+	pub := priv.Public().(ed25519.PublicKey)
+	now := time.Now()
+	meta := &SiteMeta{
+		Label:       label,
+		SiteID:      core.SiteIDFromPub(pub),
+		SitePubHex:  hex.EncodeToString(pub),
+		Seq:         1,
+		CreatedAt:   now,
+		LastUpdated: now,
+	}
+	w.Sites[label] = meta
+	return meta, nil
+}
+
+// UpdateKey returns the key used to sign update bodies for label. Imported
+// sites derive it from their own seed so that it stays tied to the imported
+// identity rather than to whichever mnemonic holds the wallet.
+func (w *Wallet) UpdateKey(master []byte, label string) (ed25519.PublicKey, ed25519.PrivateKey, error) {
+	_, sitePriv, imported, err := w.importedSiteKey(master, label)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !imported {
+		return DeriveSiteKey(master, label+"-update")
+	}
+
+	h := hkdf.New(sha256.New, sitePriv.Seed(), []byte("ax-update"), []byte(strings.ToLower(label)))
+	seed := make([]byte, ed25519.SeedSize)
+	if _, err := io.ReadFull(h, seed); err != nil {
+		return nil, nil, err
+	}
+	priv := ed25519.NewKeyFromSeed(seed)
+	return priv.Public().(ed25519.PublicKey), priv, nil
+}
+
+// importedSiteKey unseals the imported key for label, if the wallet has one.
+func (w *Wallet) importedSiteKey(master []byte, label string) (ed25519.PublicKey, ed25519.PrivateKey, bool, error) {
+	sealedB64, ok := w.ImportedKeys[label]
+	if !ok {
+		return nil, nil, false, nil
+	}
+	sealed, err := base64.StdEncoding.DecodeString(sealedB64)
+	if err != nil || len(sealed) < chacha20poly1305.NonceSizeX {
+		return nil, nil, true, fmt.Errorf("corrupted imported key for %s", label)
+	}
+	aead, err := importAEAD(master, label)
+	if err != nil {
+		return nil, nil, true, err
+	}
+	nonce, ct := sealed[:chacha20poly1305.NonceSizeX], sealed[chacha20poly1305.NonceSizeX:]
+	seed, err := aead.Open(nil, nonce, ct, []byte(adImported))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, nil, true, fmt.Errorf("cannot unseal imported key for %s", label)
+	}
+	priv := ed25519.NewKeyFromSeed(seed)
+	return priv.Public().(ed25519.PublicKey), priv, true, nil
+}
+
+func importAEAD(master []byte, label string) (cipher.AEAD, error) {
+	if len(master) != 32 {
+		return nil, fmt.Errorf("invalid master key length: %d", len(master))
+	}
+	h := hkdf.New(sha256.New, master, []byte(adImported), []byte(strings.ToLower(label)))
+	key := make([]byte, chacha20poly1305.KeySize)
+	if _, err := io.ReadFull(h, key); err != nil {
+		return nil, err
+	}
+	return chacha20poly1305.NewX(key)
+}
+
+func validateImportLabel(label string) error {
+	if label == "" {
+		return errors.New("label cannot be empty")
+	}
+	if len(label) > MaxLabelLength {
+		return fmt.Errorf("label too long: %d > %d", len(label), MaxLabelLength)
+	}
+	return nil
+}
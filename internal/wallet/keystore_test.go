@@ -0,0 +1,185 @@
+package wallet
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const testKeystorePassphrase = "correct horse battery"
+
+func newTestKeystore(t *testing.T) (ed25519.PrivateKey, []byte) {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	data, err := ExportKeystore("mysite", priv, testKeystorePassphrase)
+	if err != nil {
+		t.Fatalf("ExportKeystore() error = %v", err)
+	}
+	return priv, data
+}
+
+func TestKeystoreRoundTrip(t *testing.T) {
+	priv, data := newTestKeystore(t)
+
+	if strings.Contains(string(data), hex.EncodeToString(priv.Seed())) ||
+		strings.Contains(string(data), base64.StdEncoding.EncodeToString(priv.Seed())) {
+		t.Fatal("keystore contains the plaintext seed")
+	}
+
+	label, got, err := ImportKeystore(data, testKeystorePassphrase)
+	if err != nil {
+		t.Fatalf("ImportKeystore() error = %v", err)
+	}
+	if label != "mysite" {
+		t.Errorf("label = %q, want %q", label, "mysite")
+	}
+	if !got.Equal(priv) {
+		t.Error("ImportKeystore() returned a different key")
+	}
+}
+
+func TestImportKeystoreRejects(t *testing.T) {
+	_, data := newTestKeystore(t)
+
+	otherPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		mutate     func(m map[string]interface{})
+		passphrase string
+		errMsg     string
+	}{
+		{
+			name:       "wrong passphrase",
+			mutate:     func(m map[string]interface{}) {},
+			passphrase: "wrong passphrase",
+			errMsg:     "bad passphrase",
+		},
+		{
+			name:   "tampered site_pub",
+			mutate: func(m map[string]interface{}) { m["site_pub"] = hex.EncodeToString(otherPub) },
+			errMsg: "bad passphrase or corrupted keystore",
+		},
+		{
+			name:   "zero iterations",
+			mutate: func(m map[string]interface{}) { m["t"] = 0 },
+			errMsg: "iterations out of range",
+		},
+		{
+			name:   "zero parallelism",
+			mutate: func(m map[string]interface{}) { m["p"] = 0 },
+			errMsg: "parallelism out of range",
+		},
+		{
+			name:   "huge memory",
+			mutate: func(m map[string]interface{}) { m["m"] = 4194304 },
+			errMsg: "memory out of range",
+		},
+		{
+			name:   "short salt",
+			mutate: func(m map[string]interface{}) { m["salt"] = base64.StdEncoding.EncodeToString(make([]byte, 4)) },
+			errMsg: "invalid keystore salt",
+		},
+		{
+			name:   "short nonce",
+			mutate: func(m map[string]interface{}) { m["nonce"] = base64.StdEncoding.EncodeToString(make([]byte, 12)) },
+			errMsg: "invalid keystore nonce",
+		},
+		{
+			name:   "unknown kdf",
+			mutate: func(m map[string]interface{}) { m["kdf"] = "pbkdf2" },
+			errMsg: "unsupported kdf",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var m map[string]interface{}
+			if err := json.Unmarshal(data, &m); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			tt.mutate(m)
+			mutated, err := json.Marshal(m)
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+
+			passphrase := tt.passphrase
+			if passphrase == "" {
+				passphrase = testKeystorePassphrase
+			}
+			_, _, err = ImportKeystore(mutated, passphrase)
+			if err == nil {
+				t.Fatalf("expected error containing %q, got nil", tt.errMsg)
+			}
+			if !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.errMsg)
+			}
+		})
+	}
+}
+
+func TestImportSiteKeySealsSeed(t *testing.T) {
+	mnemonic, err := NewMnemonic()
+	if err != nil {
+		t.Fatalf("NewMnemonic: %v", err)
+	}
+	master, err := MasterKeyFromMnemonic(mnemonic, "")
+	if err != nil {
+		t.Fatalf("MasterKeyFromMnemonic: %v", err)
+	}
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	w := New()
+	if _, err := w.ImportSiteKey(master, "imported", priv); err != nil {
+		t.Fatalf("ImportSiteKey() error = %v", err)
+	}
+
+	raw, err := json.Marshal(w)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if strings.Contains(string(raw), hex.EncodeToString(priv.Seed())) {
+		t.Fatal("wallet JSON contains the plaintext imported seed")
+	}
+
+	_, _, got, err := w.EnsureSite(master, "imported")
+	if err != nil {
+		t.Fatalf("EnsureSite() error = %v", err)
+	}
+	if !got.Equal(priv) {
+		t.Error("EnsureSite() did not return the imported key")
+	}
+
+	// Update keys for imported sites come from the imported seed, not the
+	// wallet mnemonic.
+	updatePub, _, err := w.UpdateKey(master, "imported")
+	if err != nil {
+		t.Fatalf("UpdateKey() error = %v", err)
+	}
+	derivedPub, _, err := DeriveSiteKey(master, "imported-update")
+	if err != nil {
+		t.Fatalf("DeriveSiteKey: %v", err)
+	}
+	if updatePub.Equal(derivedPub) {
+		t.Error("UpdateKey() for an imported site derived from the wallet mnemonic")
+	}
+
+	otherMaster := make([]byte, 32)
+	if _, _, _, err := w.EnsureSite(otherMaster, "imported"); err == nil {
+		t.Error("EnsureSite() unsealed an imported key with the wrong master key")
+	}
+}
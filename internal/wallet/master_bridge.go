@@ -1,7 +1,9 @@
 package wallet
 
 // MasterKeyFromMnemonic exposes a stable 32B master key derivation from
-// a BIP-39 mnemonic for use by other packages.
-func MasterKeyFromMnemonic(mnemonic string) ([]byte, error) {
-	return masterKeyFromMnemonic(mnemonic)
+// a BIP-39 mnemonic and optional passphrase for use by other packages.
+// An empty passphrase yields the same key as wallets created before
+// passphrase support.
+func MasterKeyFromMnemonic(mnemonic, passphrase string) ([]byte, error) {
+	return masterKeyFromMnemonic(mnemonic, passphrase)
 }
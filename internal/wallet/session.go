@@ -0,0 +1,128 @@
+package wallet
+
+import (
+	"crypto/rand"
+	"errors"
+	"sync"
+)
+
+// ErrLocked is returned by a Session after Lock.
+var ErrLocked = errors.New("wallet is locked")
+
+// Session holds the keys of an unlocked wallet so that the mnemonic is only
+// needed once: the master key and the wallet encryption key are derived when
+// the session starts, and Lock zeroes both.
+//
+// A session can only open and re-encrypt the wallet it was unlocked with,
+// since the encryption key is bound to that wallet's salt and KDF settings.
+type Session struct {
+	mu     sync.Mutex
+	master []byte
+	key    []byte
+	salt   []byte
+	params KDFParams
+}
+
+// Unlock decrypts an encrypted wallet and returns it with a session holding
+// its keys.
+func Unlock(encBytes []byte, mnemonic, passphrase string) (*Wallet, *Session, error) {
+	sealed, err := parseWallet(encBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := walletKey(mnemonic, passphrase, sealed.KDFParams, sealed.salt)
+	if err != nil {
+		return nil, nil, err
+	}
+	w, err := sealed.open(key)
+	if err != nil {
+		clear(key)
+		return nil, nil, err
+	}
+	master, err := masterKeyFromMnemonic(mnemonic, passphrase)
+	if err != nil {
+		clear(key)
+		return nil, nil, err
+	}
+	return w, &Session{master: master, key: key, salt: sealed.salt, params: sealed.KDFParams}, nil
+}
+
+// NewSession encrypts a new wallet with the default KDF parameters and
+// returns the encrypted wallet with a session holding its keys.
+func NewSession(w *Wallet, mnemonic, passphrase string) ([]byte, *Session, error) {
+	params := DefaultKDFParams()
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, nil, err
+	}
+	key, err := walletKey(mnemonic, passphrase, params, salt)
+	if err != nil {
+		return nil, nil, err
+	}
+	enc, err := sealWallet(w, params, salt, key)
+	if err != nil {
+		clear(key)
+		return nil, nil, err
+	}
+	master, err := masterKeyFromMnemonic(mnemonic, passphrase)
+	if err != nil {
+		clear(key)
+		return nil, nil, err
+	}
+	return enc, &Session{master: master, key: key, salt: salt, params: params}, nil
+}
+
+// Decrypt opens the wallet this session was unlocked with, or a later
+// version of it written by Encrypt.
+func (s *Session) Decrypt(encBytes []byte) (*Wallet, error) {
+	sealed, err := parseWallet(encBytes)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.key == nil {
+		return nil, ErrLocked
+	}
+	if sealed.KDFParams != s.params || string(sealed.salt) != string(s.salt) {
+		return nil, errors.New("wallet does not belong to this session")
+	}
+	return sealed.open(s.key)
+}
+
+// Encrypt encrypts w with the session's key under a fresh nonce.
+func (s *Session) Encrypt(w *Wallet) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.key == nil {
+		return nil, ErrLocked
+	}
+	return sealWallet(w, s.params, s.salt, s.key)
+}
+
+// MasterKey returns a copy of the master key; callers should clear it when
+// done.
+func (s *Session) MasterKey() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.master == nil {
+		return nil, ErrLocked
+	}
+	return append([]byte(nil), s.master...), nil
+}
+
+// Lock zeroes the session's keys. It is safe to call more than once.
+func (s *Session) Lock() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.master)
+	clear(s.key)
+	s.master, s.key = nil, nil
+}
+
+// Locked reports whether Lock has been called.
+func (s *Session) Locked() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.key == nil
+}
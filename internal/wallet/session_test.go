@@ -0,0 +1,87 @@
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestSession(t *testing.T) {
+	mnemonic, err := NewMnemonic()
+	if err != nil {
+		t.Fatalf("NewMnemonic() error = %v", err)
+	}
+	w := New()
+	enc, s, err := NewSession(w, mnemonic, "extra")
+	if err != nil {
+		t.Fatalf("NewSession() error = %v", err)
+	}
+
+	want, err := MasterKeyFromMnemonic(mnemonic, "extra")
+	if err != nil {
+		t.Fatalf("MasterKeyFromMnemonic() error = %v", err)
+	}
+	master, err := s.MasterKey()
+	if err != nil || !bytes.Equal(master, want) {
+		t.Fatalf("MasterKey() = %x, %v, want %x", master, err, want)
+	}
+	if _, _, _, err := w.EnsureSite(master, "blog"); err != nil {
+		t.Fatalf("EnsureSite() error = %v", err)
+	}
+
+	// A wallet re-encrypted by the session still opens with the mnemonic.
+	updated, err := s.Encrypt(w)
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	got, err := DecryptWallet(updated, mnemonic, "extra")
+	if err != nil || got.Sites["blog"] == nil {
+		t.Fatalf("DecryptWallet() = %+v, %v, want site blog", got, err)
+	}
+	if got, err := s.Decrypt(enc); err != nil || len(got.Sites) != 0 {
+		t.Errorf("Decrypt(original) = %+v, %v", got, err)
+	}
+
+	// The session only opens its own wallet.
+	other, err := EncryptWallet(w, mnemonic, "extra")
+	if err != nil {
+		t.Fatalf("EncryptWallet() error = %v", err)
+	}
+	if _, err := s.Decrypt(other); err == nil {
+		t.Error("Decrypt() opened a wallet with another salt")
+	}
+
+	s.Lock()
+	if !s.Locked() {
+		t.Error("Locked() = false after Lock")
+	}
+	if _, err := s.MasterKey(); !errors.Is(err, ErrLocked) {
+		t.Errorf("MasterKey() after Lock error = %v, want ErrLocked", err)
+	}
+	if _, err := s.Decrypt(updated); !errors.Is(err, ErrLocked) {
+		t.Errorf("Decrypt() after Lock error = %v, want ErrLocked", err)
+	}
+	s.Lock()
+}
+
+func TestUnlock(t *testing.T) {
+	mnemonic, err := NewMnemonic()
+	if err != nil {
+		t.Fatalf("NewMnemonic() error = %v", err)
+	}
+	enc, err := EncryptWallet(New(), mnemonic, "")
+	if err != nil {
+		t.Fatalf("EncryptWallet() error = %v", err)
+	}
+	if _, _, err := Unlock(enc, mnemonic, "wrong"); err == nil {
+		t.Fatal("Unlock() with the wrong passphrase succeeded")
+	}
+	_, s, err := Unlock(enc, mnemonic, "")
+	if err != nil {
+		t.Fatalf("Unlock() error = %v", err)
+	}
+	defer s.Lock()
+	if _, err := s.Decrypt(enc); err != nil {
+		t.Errorf("Decrypt() error = %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"alxnet/internal/core"
+)
+
+// Site keys are derived from the master key and the site label, so a site
+// ID with a chosen prefix is found by trying labels base-0, base-1, ...
+// until one derives a matching key. Each extra hex character makes the
+// search 16 times longer.
+
+// MaxVanityPrefix bounds the hex prefix a vanity search accepts; longer
+// prefixes would take years.
+const MaxVanityPrefix = 10
+
+// VanityMatch is a site label whose key has a site ID with the wanted
+// prefix. Index is the number the label ends in.
+type VanityMatch struct {
+	Label  string
+	SiteID string
+	Index  uint64
+}
+
+// ValidVanityPrefix checks that prefix is 1 to MaxVanityPrefix lowercase
+// hex characters.
+func ValidVanityPrefix(prefix string) error {
+	if prefix == "" || len(prefix) > MaxVanityPrefix {
+		return fmt.Errorf("prefix must be 1 to %d hex characters", MaxVanityPrefix)
+	}
+	for _, c := range prefix {
+		if !((c >= '0' && c <= '9') || (c >= 'a' && c <= 'f')) {
+			return fmt.Errorf("prefix must be lowercase hex: %q", prefix)
+		}
+	}
+	return nil
+}
+
+// VanityAttempts is the expected number of labels to try before a site ID
+// starts with a prefix of n hex characters.
+func VanityAttempts(n int) uint64 {
+	return 1 << (4 * uint(n))
+}
+
+// FindVanityLabel tries labels base-<start>, base-<start+1>, ... on workers
+// goroutines until one derives a site key whose site ID starts with prefix,
+// and returns it. Every label tried is added to tried, if not nil, for
+// progress reporting. It stops with ctx's error when ctx is done.
+func FindVanityLabel(ctx context.Context, master []byte, base, prefix string, start uint64, workers int, tried *atomic.Uint64) (*VanityMatch, error) {
+	if err := ValidVanityPrefix(prefix); err != nil {
+		return nil, err
+	}
+	base = strings.ToLower(base)
+	if base == "" {
+		return nil, errors.New("label base cannot be empty")
+	}
+	// base, the dash and up to 20 digits
+	if len(base)+21 > MaxLabelLength {
+		return nil, fmt.Errorf("label base too long: %d > %d", len(base), MaxLabelLength-21)
+	}
+	if _, _, err := DeriveSiteKey(master, base); err != nil {
+		return nil, err
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		once  sync.Once
+		found *VanityMatch
+		wg    sync.WaitGroup
+	)
+	var next atomic.Uint64
+	next.Store(start)
+	const batch = 256 // labels a worker claims at a time
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			label := []byte(base + "-")
+			for ctx.Err() == nil {
+				first := next.Add(batch) - batch
+				for i := first; i < first+batch; i++ {
+					l := strconv.AppendUint(label, i, 10)
+					pub, _, err := DeriveSiteKey(master, string(l))
+					if err != nil {
+						continue
+					}
+					if siteID := core.SiteIDFromPub(pub); strings.HasPrefix(siteID, prefix) {
+						once.Do(func() {
+							found = &VanityMatch{Label: string(l), SiteID: siteID, Index: i}
+							cancel()
+						})
+						return
+					}
+				}
+				if tried != nil {
+					tried.Add(batch)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if found != nil {
+		return found, nil
+	}
+	return nil, ctx.Err()
+}
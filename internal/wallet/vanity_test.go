@@ -0,0 +1,54 @@
+package wallet
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"alxnet/internal/core"
+)
+
+func TestFindVanityLabel(t *testing.T) {
+	master := make([]byte, 32)
+	for i := range master {
+		master[i] = byte(i)
+	}
+
+	var tried atomic.Uint64
+	match, err := FindVanityLabel(context.Background(), master, "Shop", "ab", 0, 4, &tried)
+	if err != nil {
+		t.Fatalf("FindVanityLabel: %v", err)
+	}
+	if !strings.HasPrefix(match.Label, "shop-") || !strings.HasPrefix(match.SiteID, "ab") {
+		t.Fatalf("match = %+v", match)
+	}
+	pub, _, err := DeriveSiteKey(master, match.Label)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := core.SiteIDFromPub(pub); got != match.SiteID {
+		t.Errorf("label %s derives site %s, match says %s", match.Label, got, match.SiteID)
+	}
+
+	// Starting past the match finds another one
+	next, err := FindVanityLabel(context.Background(), master, "shop", "ab", match.Index+1, 1, nil)
+	if err != nil || next.Index <= match.Index {
+		t.Errorf("search from %d = %+v, %v", match.Index+1, next, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := FindVanityLabel(ctx, master, "shop", "ffffffffff", 0, 2, nil); err == nil {
+		t.Error("cancelled search returned no error")
+	}
+
+	for _, prefix := range []string{"", "CAFE", "xyz", strings.Repeat("a", MaxVanityPrefix+1)} {
+		if _, err := FindVanityLabel(context.Background(), master, "shop", prefix, 0, 1, nil); err == nil {
+			t.Errorf("prefix %q accepted", prefix)
+		}
+	}
+	if _, err := FindVanityLabel(context.Background(), master, strings.Repeat("l", MaxLabelLength), "a", 0, 1, nil); err == nil {
+		t.Error("over-long label base accepted")
+	}
+}
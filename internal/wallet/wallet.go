@@ -25,7 +25,6 @@ import (
 
 const (
 	walletVersion = 1
-	kdfName       = "argon2id"
 	adWallet      = "ax-wallet-v1"
 	adContent     = "ax-content-v1"
 	contentHdr    = "AXE1" // 4 bytes
@@ -110,6 +109,7 @@ type Wallet struct {
 	CreatedAt      time.Time            `json:"created_at"`
 	LastAccessed   time.Time            `json:"last_accessed"`
 	SecurityConfig *SecurityConfig      `json:"security_config,omitempty"`
+	ImportedKeys   map[string]string    `json:"imported_keys,omitempty"` // label -> seed sealed under the master key
 }
 
 // Validate performs comprehensive validation of Wallet
@@ -141,12 +141,9 @@ func (w *Wallet) Validate() error {
 }
 
 type encFile struct {
-	Version   int    `json:"v"`
-	KDF       string `json:"kdf"`
+	Version int `json:"v"`
+	KDFParams
 	SaltB64   string `json:"salt"`
-	T         uint32 `json:"t"`
-	MiB       uint32 `json:"m"`
-	P         uint8  `json:"p"`
 	NonceB64  string `json:"nonce"`
 	CipherB64 string `json:"ct"`
 }
@@ -232,12 +229,16 @@ func NewMnemonic() (string, error) {
 	return bip39.NewMnemonic(entropy)
 }
 
-func masterKeyFromMnemonic(mnemonic string) ([]byte, error) {
+func masterKeyFromMnemonic(mnemonic, passphrase string) ([]byte, error) {
 	if err := ValidateMnemonic(mnemonic); err != nil {
 		return nil, fmt.Errorf("invalid mnemonic: %w", err)
 	}
+	if len(passphrase) > MaxPassphraseLength {
+		return nil, fmt.Errorf("passphrase too long: %d > %d", len(passphrase), MaxPassphraseLength)
+	}
 
-	seed := bip39.NewSeed(mnemonic, "")
+	seed := bip39.NewSeed(mnemonic, passphrase)
+	defer clear(seed)
 	h := hkdf.New(sha256.New, seed, []byte("ax-wallet-v1"), []byte("master"))
 	key := make([]byte, 32)
 	if _, err := io.ReadFull(h, key); err != nil {
@@ -261,6 +262,24 @@ func DeriveSiteKey(master []byte, label string) (ed25519.PublicKey, ed25519.Priv
 
 	h := hkdf.New(sha256.New, master, []byte("ax-site"), []byte(strings.ToLower(label)))
 	seed := make([]byte, 32)
+	defer clear(seed)
+	if _, err := io.ReadFull(h, seed); err != nil {
+		return nil, nil, err
+	}
+	priv := ed25519.NewKeyFromSeed(seed)
+	return priv.Public().(ed25519.PublicKey), priv, nil
+}
+
+// DeriveIdentityKey derives the wallet's visitor identity, the key that
+// signs comments on other people's sites. It is derived apart from site
+// keys so that no site label yields it.
+func DeriveIdentityKey(master []byte) (ed25519.PublicKey, ed25519.PrivateKey, error) {
+	if len(master) != 32 {
+		return nil, nil, fmt.Errorf("invalid master key length: %d", len(master))
+	}
+	h := hkdf.New(sha256.New, master, []byte("ax-identity"), []byte("visitor"))
+	seed := make([]byte, 32)
+	defer clear(seed)
 	if _, err := io.ReadFull(h, seed); err != nil {
 		return nil, nil, err
 	}
@@ -268,27 +287,68 @@ func DeriveSiteKey(master []byte, label string) (ed25519.PublicKey, ed25519.Priv
 	return priv.Public().(ed25519.PublicKey), priv, nil
 }
 
-func EncryptWallet(w *Wallet, mnemonic string) ([]byte, error) {
+// walletSecret is the KDF input for wallet encryption. An empty BIP-39
+// passphrase keeps the secret identical to what older wallets used.
+func walletSecret(mnemonic, passphrase string) []byte {
+	if passphrase == "" {
+		return []byte(mnemonic)
+	}
+	return []byte(mnemonic + "\x00" + passphrase)
+}
+
+// EncryptWallet encrypts a wallet with the default KDF parameters. The
+// optional BIP-39 passphrase (the "25th word") is mixed into the key, so
+// the same mnemonic with different passphrases opens different wallets.
+func EncryptWallet(w *Wallet, mnemonic, passphrase string) ([]byte, error) {
+	return EncryptWalletWithParams(w, mnemonic, passphrase, DefaultKDFParams())
+}
+
+// EncryptWalletWithParams encrypts a wallet using the given KDF and cost
+// parameters. The parameters are stored alongside the ciphertext so that
+// DecryptWallet can open wallets written with any supported settings.
+func EncryptWalletWithParams(w *Wallet, mnemonic, passphrase string, params KDFParams) ([]byte, error) {
 	if err := w.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid wallet: %w", err)
 	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	key, err := walletKey(mnemonic, passphrase, params, salt)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(key)
+	return sealWallet(w, params, salt, key)
+}
 
+// walletKey derives the key that encrypts a wallet. The KDF input is
+// zeroed once the key is derived.
+func walletKey(mnemonic, passphrase string, params KDFParams, salt []byte) ([]byte, error) {
 	if err := ValidateMnemonic(mnemonic); err != nil {
 		return nil, fmt.Errorf("invalid mnemonic: %w", err)
 	}
+	if len(passphrase) > MaxPassphraseLength {
+		return nil, fmt.Errorf("passphrase too long: %d > %d", len(passphrase), MaxPassphraseLength)
+	}
+	if err := params.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid kdf parameters: %w", err)
+	}
+	secret := walletSecret(mnemonic, passphrase)
+	defer clear(secret)
+	return params.deriveKey(secret, salt)
+}
 
+// sealWallet encrypts w with a key derived under params and salt.
+func sealWallet(w *Wallet, params KDFParams, salt, key []byte) ([]byte, error) {
+	if err := w.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid wallet: %w", err)
+	}
 	raw, err := json.Marshal(w)
 	if err != nil {
 		return nil, err
 	}
-
-	salt := make([]byte, 16)
-	if _, err := rand.Read(salt); err != nil {
-		return nil, err
-	}
-
-	t, mMiB, p := uint32(2), uint32(64), uint8(4)
-	key := argon2.IDKey([]byte(mnemonic), salt, t, mMiB*1024, uint8(p), 32)
+	defer clear(raw)
 
 	aead, err := chacha20poly1305.NewX(key)
 	if err != nil {
@@ -302,47 +362,87 @@ func EncryptWallet(w *Wallet, mnemonic string) ([]byte, error) {
 	ct := aead.Seal(nil, nonce, raw, []byte(adWallet))
 	out := encFile{
 		Version:   walletVersion,
-		KDF:       kdfName,
+		KDFParams: params,
 		SaltB64:   base64.StdEncoding.EncodeToString(salt),
-		T:         t,
-		MiB:       mMiB,
-		P:         p,
 		NonceB64:  base64.StdEncoding.EncodeToString(nonce),
 		CipherB64: base64.StdEncoding.EncodeToString(ct),
 	}
 	return json.Marshal(out)
 }
 
-func DecryptWallet(encBytes []byte, mnemonic string) (*Wallet, error) {
+// WalletKDFParams reads the KDF parameters from an encrypted wallet without
+// decrypting it.
+func WalletKDFParams(encBytes []byte) (KDFParams, error) {
 	var ef encFile
 	if err := json.Unmarshal(encBytes, &ef); err != nil {
-		return nil, err
+		return KDFParams{}, err
 	}
-	if ef.KDF != kdfName || ef.Version != walletVersion {
-		return nil, errors.New("unsupported wallet format")
+	if ef.Version != walletVersion {
+		return KDFParams{}, errors.New("unsupported wallet format")
 	}
-	salt, err := base64.StdEncoding.DecodeString(ef.SaltB64)
+	return ef.KDFParams, nil
+}
+
+func DecryptWallet(encBytes []byte, mnemonic, passphrase string) (*Wallet, error) {
+	sealed, err := parseWallet(encBytes)
 	if err != nil {
 		return nil, err
 	}
-	nonce, err := base64.StdEncoding.DecodeString(ef.NonceB64)
+	key, err := walletKey(mnemonic, passphrase, sealed.KDFParams, sealed.salt)
 	if err != nil {
 		return nil, err
 	}
-	ct, err := base64.StdEncoding.DecodeString(ef.CipherB64)
-	if err != nil {
+	defer clear(key)
+	return sealed.open(key)
+}
+
+// sealedWallet is an encrypted wallet file with its fields decoded.
+type sealedWallet struct {
+	KDFParams
+	salt, nonce, ct []byte
+}
+
+func parseWallet(encBytes []byte) (*sealedWallet, error) {
+	if len(encBytes) > MaxWalletSize {
+		return nil, fmt.Errorf("wallet too large: %d > %d", len(encBytes), MaxWalletSize)
+	}
+	var ef encFile
+	if err := json.Unmarshal(encBytes, &ef); err != nil {
 		return nil, err
 	}
+	if ef.Version != walletVersion {
+		return nil, errors.New("unsupported wallet format")
+	}
+	if err := ef.KDFParams.Validate(); err != nil {
+		return nil, fmt.Errorf("unsupported wallet format: %w", err)
+	}
+	sw := &sealedWallet{KDFParams: ef.KDFParams}
+	var err error
+	if sw.salt, err = base64.StdEncoding.DecodeString(ef.SaltB64); err != nil {
+		return nil, err
+	}
+	if sw.nonce, err = base64.StdEncoding.DecodeString(ef.NonceB64); err != nil {
+		return nil, err
+	}
+	if len(sw.nonce) != chacha20poly1305.NonceSizeX {
+		return nil, errors.New("invalid wallet nonce")
+	}
+	if sw.ct, err = base64.StdEncoding.DecodeString(ef.CipherB64); err != nil {
+		return nil, err
+	}
+	return sw, nil
+}
 
-	key := argon2.IDKey([]byte(mnemonic), salt, ef.T, ef.MiB*1024, uint8(ef.P), 32)
+func (sw *sealedWallet) open(key []byte) (*Wallet, error) {
 	aead, err := chacha20poly1305.NewX(key)
 	if err != nil {
 		return nil, err
 	}
-	raw, err := aead.Open(nil, nonce, ct, []byte(adWallet))
+	raw, err := aead.Open(nil, sw.nonce, sw.ct, []byte(adWallet))
 	if err != nil {
-		return nil, errors.New("bad mnemonic or corrupted wallet")
+		return nil, errors.New("bad mnemonic, wrong passphrase or corrupted wallet")
 	}
+	defer clear(raw)
 	var w Wallet
 	if err := json.Unmarshal(raw, &w); err != nil {
 		return nil, err
@@ -350,6 +450,15 @@ func DecryptWallet(encBytes []byte, mnemonic string) (*Wallet, error) {
 	return &w, nil
 }
 
+// UpgradeWallet re-encrypts an existing wallet with new KDF parameters.
+func UpgradeWallet(encBytes []byte, mnemonic, passphrase string, params KDFParams) ([]byte, error) {
+	w, err := DecryptWallet(encBytes, mnemonic, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return EncryptWalletWithParams(w, mnemonic, passphrase, params)
+}
+
 func Save(path string, bytes []byte) error {
 	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
 		return err
@@ -372,10 +481,16 @@ func New() *Wallet {
 }
 
 func (w *Wallet) EnsureSite(master []byte, label string) (*SiteMeta, ed25519.PublicKey, ed25519.PrivateKey, error) {
-	pub, priv, err := DeriveSiteKey(master, label)
+	pub, priv, imported, err := w.importedSiteKey(master, label)
 	if err != nil {
 		return nil, nil, nil, err
 	}
+	if !imported {
+		pub, priv, err = DeriveSiteKey(master, label)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
 	siteID := core.SiteIDFromPub(pub)
 	if m, ok := w.Sites[label]; ok {
 		return m, pub, priv, nil
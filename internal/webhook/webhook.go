@@ -0,0 +1,243 @@
+// Package webhook posts node events to operator-configured URLs, so chat
+// and alerting tools can follow a node without polling it.
+//
+// Each delivery is a JSON POST of
+//
+//	{"id": "...", "type": "site.head", "time": "...", "data": {...}}
+//
+// signed with the hook's secret: the X-AlxNet-Signature header holds
+// "sha256=" and the hex HMAC-SHA256 of the body. Receivers should check it
+// with Verify, or the same computation, before trusting the payload.
+// Failed deliveries are retried with exponential backoff.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Delivery headers.
+const (
+	SignatureHeader = "X-AlxNet-Signature"
+	EventHeader     = "X-AlxNet-Event"
+	DeliveryHeader  = "X-AlxNet-Delivery"
+)
+
+// Delivery defaults
+const (
+	MaxAttempts    = 6                // first try and retries of one delivery
+	InitialBackoff = 2 * time.Second  // wait before the first retry, doubled after each
+	MaxBackoff     = 5 * time.Minute  // longest wait between retries
+	RequestTimeout = 10 * time.Second // bound on one POST
+	QueueSize      = 256              // deliveries waiting per hook; the oldest is dropped when full
+)
+
+// Hook is one webhook: where events go and which of them.
+type Hook struct {
+	URL    string
+	Secret string
+	Events []string // event types to send; empty sends all
+}
+
+// wants reports whether h subscribes to events of typ.
+func (h Hook) wants(typ string) bool {
+	return len(h.Events) == 0 || slices.Contains(h.Events, typ)
+}
+
+// Payload is the body of a delivery.
+type Payload struct {
+	ID   string      `json:"id"`
+	Type string      `json:"type"`
+	Time time.Time   `json:"time"`
+	Data interface{} `json:"data,omitempty"`
+}
+
+// Sign returns the X-AlxNet-Signature value of body under secret.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is the X-AlxNet-Signature of body
+// under secret.
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+// Dispatcher delivers events to hooks, each from a queue of its own so a
+// slow or failing receiver does not hold up the others.
+type Dispatcher struct {
+	logger *zap.Logger
+	client *http.Client
+
+	// retry waits; tests shorten them
+	backoff    time.Duration
+	maxBackoff time.Duration
+
+	mu      sync.Mutex
+	ctx     context.Context
+	workers []*worker
+}
+
+type worker struct {
+	hook   Hook
+	queue  chan []byte // encoded payloads
+	cancel context.CancelFunc
+}
+
+// New returns a dispatcher that sends nothing until Start is called.
+func New(logger *zap.Logger) *Dispatcher {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &Dispatcher{
+		logger:     logger,
+		client:     &http.Client{Timeout: RequestTimeout},
+		backoff:    InitialBackoff,
+		maxBackoff: MaxBackoff,
+	}
+}
+
+// Start delivers events to hooks until ctx ends.
+func (d *Dispatcher) Start(ctx context.Context, hooks []Hook) {
+	d.mu.Lock()
+	d.ctx = ctx
+	d.mu.Unlock()
+	d.SetHooks(hooks)
+}
+
+// SetHooks replaces the hooks, as on a configuration reload. Deliveries
+// still queued for the old hooks are dropped.
+func (d *Dispatcher) SetHooks(hooks []Hook) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, w := range d.workers {
+		w.cancel()
+	}
+	d.workers = nil
+	if d.ctx == nil {
+		return
+	}
+	for _, h := range hooks {
+		ctx, cancel := context.WithCancel(d.ctx)
+		w := &worker{hook: h, queue: make(chan []byte, QueueSize), cancel: cancel}
+		d.workers = append(d.workers, w)
+		go d.run(ctx, w)
+	}
+}
+
+// Send queues an event for every hook that wants its type. It never
+// blocks; a hook whose queue is full loses its oldest delivery.
+func (d *Dispatcher) Send(typ string, data interface{}) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var body []byte
+	for _, w := range d.workers {
+		if !w.hook.wants(typ) {
+			continue
+		}
+		if body == nil {
+			var err error
+			if body, err = json.Marshal(Payload{ID: newID(), Type: typ, Time: time.Now().UTC(), Data: data}); err != nil {
+				d.logger.Warn("webhook event not encoded", zap.String("type", typ), zap.Error(err))
+				return
+			}
+		}
+		for {
+			select {
+			case w.queue <- body:
+			default:
+				select {
+				case <-w.queue:
+					d.logger.Warn("webhook queue full, oldest delivery dropped", zap.String("url", w.hook.URL))
+				default:
+				}
+				continue
+			}
+			break
+		}
+	}
+}
+
+func (d *Dispatcher) run(ctx context.Context, w *worker) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case body := <-w.queue:
+			d.deliver(ctx, w.hook, body)
+		}
+	}
+}
+
+// deliver posts body to hook, retrying with backoff until it is accepted,
+// refused for good, MaxAttempts fail or ctx ends.
+func (d *Dispatcher) deliver(ctx context.Context, hook Hook, body []byte) {
+	wait := d.backoff
+	for attempt := 1; ; attempt++ {
+		retry, err := d.post(ctx, hook, body)
+		if err == nil {
+			return
+		}
+		if !retry || attempt == MaxAttempts {
+			d.logger.Warn("webhook delivery failed", zap.String("url", hook.URL), zap.Int("attempts", attempt), zap.Error(err))
+			return
+		}
+		d.logger.Debug("webhook delivery failed, retrying", zap.String("url", hook.URL), zap.Duration("in", wait), zap.Error(err))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		wait = min(2*wait, d.maxBackoff)
+	}
+}
+
+// post sends one delivery. It reports whether a failure is worth retrying:
+// network errors, server errors and 408 or 429 responses are.
+func (d *Dispatcher) post(ctx context.Context, hook Hook, body []byte) (bool, error) {
+	var p Payload
+	_ = json.Unmarshal(body, &p)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "AlxNet-Webhook")
+	req.Header.Set(EventHeader, p.Type)
+	req.Header.Set(DeliveryHeader, p.ID)
+	req.Header.Set(SignatureHeader, Sign(hook.Secret, body))
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode >= 500:
+		return true, fmt.Errorf("receiver answered %s", resp.Status)
+	default:
+		return false, fmt.Errorf("receiver answered %s", resp.Status)
+	}
+}
+
+// newID returns a random delivery ID, the same for every retry and hook.
+func newID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,93 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDispatcherSignsAndRetries(t *testing.T) {
+	const secret = "s3cret"
+	var calls atomic.Int32
+	got := make(chan Payload, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !Verify(secret, body, r.Header.Get(SignatureHeader)) {
+			t.Errorf("bad signature %q", r.Header.Get(SignatureHeader))
+		}
+		if calls.Add(1) == 1 {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return
+		}
+		var p Payload
+		if err := json.Unmarshal(body, &p); err != nil {
+			t.Errorf("payload: %v", err)
+		}
+		if r.Header.Get(EventHeader) != p.Type || r.Header.Get(DeliveryHeader) != p.ID {
+			t.Errorf("headers %v do not match payload %+v", r.Header, p)
+		}
+		got <- p
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	d := New(nil)
+	d.backoff = time.Millisecond
+	d.Start(ctx, []Hook{
+		{URL: srv.URL, Secret: secret, Events: []string{"site.head"}},
+	})
+
+	d.Send("pin.incomplete", map[string]interface{}{"site_id": "aa"}) // not subscribed
+	d.Send("site.head", map[string]interface{}{"site_id": "aa", "seq": 3})
+
+	select {
+	case p := <-got:
+		if p.Type != "site.head" || p.ID == "" {
+			t.Errorf("payload = %+v, want a site.head delivery", p)
+		}
+		if data, _ := p.Data.(map[string]interface{}); data["seq"] != float64(3) {
+			t.Errorf("data = %v, want seq 3", p.Data)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("event not delivered")
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("receiver called %d times, want 2", n)
+	}
+}
+
+func TestDispatcherGivesUpOnClientError(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, "gone", http.StatusGone)
+	}))
+	defer srv.Close()
+
+	d := New(nil)
+	d.backoff = time.Millisecond
+	if retry, err := d.post(context.Background(), Hook{URL: srv.URL, Secret: "s"}, []byte(`{}`)); err == nil || retry {
+		t.Errorf("post = %v, %v; want a final error", retry, err)
+	}
+	d.deliver(context.Background(), Hook{URL: srv.URL, Secret: "s"}, []byte(`{}`))
+	if n := calls.Load(); n != 2 {
+		t.Errorf("receiver called %d times, want 2", n)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"type":"site.head"}`)
+	sig := Sign("k", body)
+	if !Verify("k", body, sig) {
+		t.Error("own signature rejected")
+	}
+	if Verify("other", body, sig) || Verify("k", []byte(`{}`), sig) {
+		t.Error("signature accepted for another key or body")
+	}
+}
@@ -0,0 +1,96 @@
+package webserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"alxnet/internal/store"
+)
+
+// maxAliasRequestBody caps address book requests.
+const maxAliasRequestBody = 4 * 1024
+
+// handleAPIAddressBook lists the address book (GET) or adds an entry (POST
+// {"name": ..., "target": site-id-or-name, "note": optional}), replacing
+// one of the same name. Names are matched without regard to case.
+func (ws *WebServer) handleAPIAddressBook(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		aliases, err := ws.store.Aliases()
+		if err != nil {
+			http.Error(w, "Failed to list the address book", http.StatusInternalServerError)
+			return
+		}
+		if aliases == nil {
+			aliases = []store.Alias{}
+		}
+		writeJSON(w, map[string]interface{}{
+			"aliases": aliases,
+			"count":   len(aliases),
+		})
+
+	case http.MethodPost:
+		var request struct {
+			Name   string `json:"name"`
+			Target string `json:"target"`
+			Note   string `json:"note"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAliasRequestBody)).Decode(&request); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		a := &store.Alias{
+			Name:   strings.ToLower(strings.TrimSpace(request.Name)),
+			Target: strings.TrimSpace(request.Target),
+			Note:   strings.TrimSpace(request.Note),
+		}
+		if len(a.Target) == 64 && isHex(strings.ToLower(a.Target)) {
+			a.Target = strings.ToLower(a.Target)
+		}
+		err := ws.store.PutAlias(a)
+		switch {
+		case errors.Is(err, store.ErrTooManyAliases):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, map[string]interface{}{
+			"success": true,
+			"alias":   a,
+		})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAPIAddressBookDelete removes an address book entry: POST
+// {"name": ...}.
+func (ws *WebServer) handleAPIAddressBookDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var request struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAliasRequestBody)).Decode(&request); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	name := strings.ToLower(strings.TrimSpace(request.Name))
+	found, err := ws.store.DeleteAlias(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !found {
+		http.Error(w, "No address book entry named "+name, http.StatusNotFound)
+		return
+	}
+	writeJSON(w, map[string]interface{}{"success": true, "name": name})
+}
@@ -0,0 +1,53 @@
+package webserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"alxnet/internal/store"
+)
+
+func TestAddressBookHandlers(t *testing.T) {
+	s, err := store.NewMemory()
+	if err != nil {
+		t.Fatalf("NewMemory: %v", err)
+	}
+	defer s.Close()
+	ws := &WebServer{store: s}
+	siteID := strings.Repeat("AB", 32)
+
+	post := func(handler http.HandlerFunc, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+		return rec
+	}
+
+	if rec := post(ws.handleAPIAddressBook, `{"name":" News ","target":"`+siteID+`","note":"daily"}`); rec.Code != http.StatusOK {
+		t.Fatalf("add = %d %s", rec.Code, rec.Body)
+	}
+	if rec := post(ws.handleAPIAddressBook, `{"name":"bad name","target":"mysite"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("add with a space in the name = %d", rec.Code)
+	}
+
+	rec := httptest.NewRecorder()
+	ws.handleAPIAddressBook(rec, httptest.NewRequest(http.MethodGet, "/api/addressbook", nil))
+	var list struct {
+		Aliases []store.Alias `json:"aliases"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(list.Aliases) != 1 || list.Aliases[0].Name != "news" || list.Aliases[0].Target != strings.ToLower(siteID) {
+		t.Errorf("list = %+v", list.Aliases)
+	}
+
+	if rec := post(ws.handleAPIAddressBookDelete, `{"name":"NEWS"}`); rec.Code != http.StatusOK {
+		t.Errorf("delete = %d %s", rec.Code, rec.Body)
+	}
+	if rec := post(ws.handleAPIAddressBookDelete, `{"name":"news"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("delete again = %d", rec.Code)
+	}
+}
@@ -0,0 +1,395 @@
+package webserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"alxnet/internal/p2p"
+	"alxnet/internal/store"
+
+	peer "github.com/libp2p/go-libp2p/core/peer"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// gcDiscardRatio is the share of stale data that makes a value log file
+// worth rewriting on /api/admin/gc.
+const gcDiscardRatio = 0.5
+
+// defaultAuditLimit is how many audit entries /api/admin/audit returns
+// without a limit parameter.
+const defaultAuditLimit = 100
+
+// ReplicationNextHeader is the trailer of /api/admin/replication that
+// carries the version a follower passes as since on its next pull. A
+// stream without it was cut short.
+const ReplicationNextHeader = "Alxnet-Replication-Next"
+
+// SetAdminToken enables the /api/admin endpoints for requests carrying
+// "Authorization: Bearer <token>" and the issuing of API tokens. With no
+// token they answer 403.
+func (ws *WebServer) SetAdminToken(token string) {
+	ws.adminToken = token
+}
+
+// SetReloadFunc sets what /api/admin/config/reload runs. It returns the
+// settings it applied.
+func (ws *WebServer) SetReloadFunc(reload func() (map[string]interface{}, error)) {
+	ws.reload = reload
+}
+
+// SetLogLevel lets /api/admin/log/level read and change level.
+func (ws *WebServer) SetLogLevel(level zap.AtomicLevel) {
+	ws.logLevel = &level
+}
+
+// SetNTPServer sets the NTP server /api/admin/doctor compares the local
+// clock with; "" skips that check.
+func (ws *WebServer) SetNTPServer(server string) {
+	ws.ntpServer = server
+}
+
+// registerAdmin adds the administration endpoints, which need the admin
+// capability, to mux.
+func (ws *WebServer) registerAdmin(mux *http.ServeMux) {
+	ws.handleAPI(mux, "/api/admin/peers", ws.handleAdminPeers, apiDoc{Methods: "GET", Summary: "List connected and recently seen peers", Admin: true})
+	ws.handleAPI(mux, "/api/admin/bans", ws.handleAdminBans, apiDoc{Methods: "GET POST", Summary: "List bans, or ban or unban a peer", Admin: true})
+	ws.handleAPI(mux, "/api/admin/pins", ws.handleStoragePins, apiDoc{Methods: "GET POST", Summary: "List pinned sites, or pin or unpin one", Admin: true})
+	ws.handleAPI(mux, "/api/admin/gc", ws.handleAdminGC, apiDoc{Methods: "POST", Summary: "Reclaim space from the value log", Admin: true})
+	ws.handleAPI(mux, "/api/admin/denylist", ws.handleAdminDenylist, apiDoc{Methods: "GET POST", Summary: "List denied IDs, or deny or allow one", Admin: true})
+	ws.handleAPI(mux, "/api/admin/gateway", ws.handleAdminGateway, apiDoc{Methods: "GET POST", Summary: "List, install or remove gateway authorizations", Admin: true})
+	ws.handleAPI(mux, "/api/admin/nodes", ws.handleAdminNodes, apiDoc{Methods: "GET POST", Summary: "List, register or remove remote nodes", Admin: true})
+	ws.handleAPI(mux, "/api/admin/config/reload", ws.handleAdminReload, apiDoc{Methods: "POST", Summary: "Reload the configuration file", Admin: true})
+	ws.handleAPI(mux, "/api/admin/log/level", ws.handleAdminLogLevel, apiDoc{Methods: "GET POST", Summary: "Read or change the log level", Admin: true})
+	ws.handleAPI(mux, "/api/admin/audit", ws.handleAdminAudit, apiDoc{Methods: "GET", Summary: "List recent rejections", Query: []string{"kind", "peer", "site", "cid", "since", "limit"}, Admin: true})
+	ws.handleAPI(mux, "/api/admin/doctor", ws.handleAdminDoctor, apiDoc{Methods: "GET", Summary: "Run connectivity and storage diagnostics", Admin: true})
+	ws.handleAPI(mux, "/api/admin/proof", ws.handleAdminProof, apiDoc{Methods: "POST", Summary: "Challenge a peer to prove it stores a site or blob", Admin: true})
+	ws.handleAPI(mux, "/api/admin/replication", ws.handleAdminReplication, apiDoc{Methods: "GET", Summary: "Stream store changes to a follower", Query: []string{"since"}, Admin: true, Produces: "application/octet-stream"})
+	ws.handleAPI(mux, "/api/admin/tokens", ws.handleAdminTokens, apiDoc{Methods: "GET POST", Summary: "List, issue or revoke API tokens", Admin: true})
+	ws.handleAPI(mux, "/api/admin/stats/recount", ws.handleAdminRecount, apiDoc{Methods: "POST", Summary: "Count the store's keys again", Admin: true})
+}
+
+func (ws *WebServer) handleAdminPeers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	peers := ws.node.PeerStatuses()
+	writeJSON(w, map[string]interface{}{
+		"success": true,
+		"peers":   peers,
+		"count":   len(peers),
+	})
+}
+
+// handleAdminBans lists bans (GET) or bans or unbans a peer (POST
+// {"peer", "duration", "unban"}); duration is a Go duration such as "24h".
+func (ws *WebServer) handleAdminBans(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req struct {
+			Peer     string `json:"peer"`
+			Duration string `json:"duration"`
+			Unban    bool   `json:"unban"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		id, err := peer.Decode(req.Peer)
+		if err != nil {
+			http.Error(w, "Invalid peer ID", http.StatusBadRequest)
+			return
+		}
+		if req.Unban {
+			ws.node.UnbanPeer(id)
+			break
+		}
+		d, err := time.ParseDuration(req.Duration)
+		if err != nil {
+			http.Error(w, "Invalid duration", http.StatusBadRequest)
+			return
+		}
+		if _, err := ws.node.BanPeer(id, d); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	bans := ws.node.Bans()
+	writeJSON(w, map[string]interface{}{
+		"success": true,
+		"bans":    bans,
+		"count":   len(bans),
+	})
+}
+
+func (ws *WebServer) handleAdminGC(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	start := time.Now()
+	rewritten, err := ws.store.CollectGarbage(gcDiscardRatio)
+	if err != nil {
+		http.Error(w, "Garbage collection failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"success":   true,
+		"rewritten": rewritten,
+		"duration":  time.Since(start).String(),
+	})
+}
+
+// handleAdminReplication streams the store entries committed after version
+// since (GET ?since=N, 0 for all) to a follower; see store.WriteChanges.
+func (ws *WebServer) handleAdminReplication(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var since uint64
+	if v := r.URL.Query().Get("since"); v != "" {
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid since", http.StatusBadRequest)
+			return
+		}
+		since = n
+	}
+	// A first pull copies the whole store, which outlasts the write timeout
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Trailer", ReplicationNextHeader)
+	next, err := ws.store.WriteChanges(w, since)
+	if err != nil {
+		// Too late for an error status; the missing trailer tells the follower
+		ws.logger.Warn("replication stream failed", zap.Uint64("since", since), zap.Error(err))
+		return
+	}
+	w.Header().Set(ReplicationNextHeader, strconv.FormatUint(next, 10))
+}
+
+// handleAdminRecount starts counting the store's keys again in the
+// background (POST); /api/storage/stats shows when it is done.
+func (ws *WebServer) handleAdminRecount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"success": true,
+		"started": ws.store.StartRecount(),
+	})
+}
+
+// handleAdminProof challenges a peer to prove it stores a blob (POST
+// {"peer", "cid"}) or a random file of a site (POST {"peer", "site_id"}).
+// This node must store the blob too. The outcome also changes the peer's
+// reputation.
+func (ws *WebServer) handleAdminProof(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		Peer   string `json:"peer"`
+		SiteID string `json:"site_id"`
+		CID    string `json:"cid"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	id, err := peer.Decode(req.Peer)
+	if err != nil {
+		http.Error(w, "Invalid peer ID", http.StatusBadRequest)
+		return
+	}
+	if (req.SiteID == "") == (req.CID == "") {
+		http.Error(w, "Give either site_id or cid", http.StatusBadRequest)
+		return
+	}
+
+	var proof *p2p.StorageProof
+	if req.SiteID != "" {
+		if len(req.SiteID) != 64 || !isHex(req.SiteID) {
+			http.Error(w, "Invalid site ID", http.StatusBadRequest)
+			return
+		}
+		proof, err = ws.node.ChallengeSite(r.Context(), id, req.SiteID)
+	} else {
+		proof, err = ws.node.ChallengeStorage(r.Context(), id, req.CID)
+	}
+	if err != nil {
+		http.Error(w, "Challenge failed: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"success": true,
+		"proof":   proof,
+	})
+}
+
+// handleAdminDenylist lists denied IDs (GET) or adds or removes one (POST
+// {"id", "reason", "remove"}). IDs are site IDs or content CIDs.
+func (ws *WebServer) handleAdminDenylist(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req struct {
+			ID     string `json:"id"`
+			Reason string `json:"reason"`
+			Remove bool   `json:"remove"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if len(req.ID) != 64 || !isHex(req.ID) {
+			http.Error(w, "ID must be a 64-character site ID or content CID", http.StatusBadRequest)
+			return
+		}
+		var err error
+		if req.Remove {
+			_, err = ws.store.Allow(req.ID)
+		} else {
+			_, err = ws.store.Deny(req.ID, req.Reason)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	entries, err := ws.store.ListDenied()
+	if err != nil {
+		http.Error(w, "Failed to list denylist", http.StatusInternalServerError)
+		return
+	}
+	if entries == nil {
+		entries = []store.DenyEntry{}
+	}
+	writeJSON(w, map[string]interface{}{
+		"success": true,
+		"entries": entries,
+		"count":   len(entries),
+	})
+}
+
+func (ws *WebServer) handleAdminReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if ws.reload == nil {
+		http.Error(w, "Configuration reload is not supported by this node", http.StatusNotImplemented)
+		return
+	}
+	applied, err := ws.reload()
+	if err != nil {
+		http.Error(w, "Reload failed: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"success": true,
+		"applied": applied,
+	})
+}
+
+// handleAdminLogLevel reports the log level (GET) or changes it until the
+// next configuration reload (POST {"level": "debug"}).
+func (ws *WebServer) handleAdminLogLevel(w http.ResponseWriter, r *http.Request) {
+	if ws.logLevel == nil {
+		http.Error(w, "Log level control is not supported by this node", http.StatusNotImplemented)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req struct {
+			Level string `json:"level"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1024)).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		level, err := zapcore.ParseLevel(req.Level)
+		if err != nil || level < zapcore.DebugLevel || level > zapcore.ErrorLevel {
+			http.Error(w, "Level must be debug, info, warn or error", http.StatusBadRequest)
+			return
+		}
+		ws.logLevel.SetLevel(level)
+		ws.logger.Info("log level changed", zap.String("level", level.String()))
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"success": true,
+		"level":   ws.logLevel.Level().String(),
+	})
+}
+
+// handleAdminAudit lists recent rejections, newest first, filtered by the
+// kind, peer, site, cid, since (RFC 3339) and limit query parameters.
+func (ws *WebServer) handleAdminAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	f, err := auditFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	entries := ws.node.Audit(f)
+	writeJSON(w, map[string]interface{}{
+		"success": true,
+		"entries": entries,
+		"count":   len(entries),
+	})
+}
+
+// auditFilter parses the /api/admin/audit query.
+func auditFilter(q url.Values) (p2p.AuditFilter, error) {
+	f := p2p.AuditFilter{
+		Kind:   q.Get("kind"),
+		Peer:   q.Get("peer"),
+		SiteID: q.Get("site"),
+		CID:    q.Get("cid"),
+		Limit:  defaultAuditLimit,
+	}
+	switch f.Kind {
+	case "", p2p.AuditUpdate, p2p.AuditDelete, p2p.AuditComment, p2p.AuditModeration,
+		p2p.AuditPointer, p2p.AuditAppData, p2p.AuditBrowse, p2p.AuditConnection, p2p.AuditProof, p2p.AuditDomain, p2p.AuditAuth:
+	default:
+		return f, fmt.Errorf("unknown kind %q", f.Kind)
+	}
+	if v := q.Get("since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return f, errors.New("since must be an RFC 3339 time")
+		}
+		f.Since = t
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return f, errors.New("invalid limit")
+		}
+		f.Limit = min(n, p2p.AuditLogSize)
+	}
+	return f, nil
+}
@@ -0,0 +1,316 @@
+package webserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"alxnet/internal/core"
+	"alxnet/internal/p2p"
+	"alxnet/internal/store"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestAdminAuth(t *testing.T) {
+	s, err := store.NewMemory()
+	if err != nil {
+		t.Fatalf("NewMemory: %v", err)
+	}
+	defer s.Close()
+
+	tests := []struct {
+		name   string
+		token  string // configured on the server
+		header string
+		want   int
+	}{
+		{"admin API disabled", "", "Bearer anything", http.StatusForbidden},
+		{"no header", "secret", "", http.StatusUnauthorized},
+		{"wrong token", "secret", "Bearer guess", http.StatusUnauthorized},
+		{"not a bearer token", "secret", "secret", http.StatusUnauthorized},
+		{"valid token", "secret", "Bearer secret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := &WebServer{store: s}
+			ws.SetAdminToken(tt.token)
+			mux := http.NewServeMux()
+			ws.registerAdmin(mux)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/admin/denylist", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestAPITokenRoles(t *testing.T) {
+	s, err := store.NewMemory()
+	if err != nil {
+		t.Fatalf("NewMemory: %v", err)
+	}
+	defer s.Close()
+	ws := &WebServer{store: s, writeCap: capPublish}
+	ws.SetAdminToken("secret")
+	ws.SetRequireToken(true)
+	mux := http.NewServeMux()
+	ok := func(w http.ResponseWriter, r *http.Request) { writeJSON(w, map[string]bool{"success": true}) }
+	ws.handleAPI(mux, "/api/status", ok, apiDoc{Methods: "GET"})
+	ws.handleAPI(mux, "/api/wallet/publish", ok, apiDoc{Methods: "POST"})
+	ws.registerAdmin(mux)
+
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+	issue := func(name, role string) string {
+		t.Helper()
+		rec := do(http.MethodPost, "/api/admin/tokens", "secret", `{"name":"`+name+`","role":"`+role+`"}`)
+		var resp struct {
+			Token string `json:"token"`
+		}
+		if rec.Code != http.StatusOK || json.Unmarshal(rec.Body.Bytes(), &resp) != nil || resp.Token == "" {
+			t.Fatalf("issuing %s: %d %s", role, rec.Code, rec.Body)
+		}
+		return resp.Token
+	}
+	viewer, publisher := issue("grafana", RoleViewer), issue("ci", RolePublisher)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		want   int
+	}{
+		{"no token", http.MethodGet, "/api/status", "", http.StatusUnauthorized},
+		{"viewer reads", http.MethodGet, "/api/v1/status", viewer, http.StatusOK},
+		{"viewer publishes", http.MethodPost, "/api/wallet/publish", viewer, http.StatusForbidden},
+		{"publisher publishes", http.MethodPost, "/api/wallet/publish", publisher, http.StatusOK},
+		{"publisher administers", http.MethodGet, "/api/admin/tokens", publisher, http.StatusForbidden},
+		{"admin token", http.MethodGet, "/api/admin/tokens", "secret", http.StatusOK},
+		{"unknown token", http.MethodGet, "/api/status", "guess", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		if rec := do(tt.method, tt.path, tt.token, "{}"); rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.want)
+		}
+	}
+
+	if rec := do(http.MethodPost, "/api/admin/tokens", "secret", `{"name":"x","role":"root"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown role: status = %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/api/admin/tokens", "secret", `{"name":"grafana","revoke":true}`); rec.Code != http.StatusOK {
+		t.Fatalf("revoke: status = %d", rec.Code)
+	}
+	if rec := do(http.MethodGet, "/api/status", viewer, ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("revoked token: status = %d", rec.Code)
+	}
+
+	// Without SetRequireToken only /api/admin is checked
+	ws.SetRequireToken(false)
+	if rec := do(http.MethodPost, "/api/wallet/publish", "", "{}"); rec.Code != http.StatusOK {
+		t.Errorf("optional tokens: status = %d", rec.Code)
+	}
+}
+
+func TestAdminDenylist(t *testing.T) {
+	s, err := store.NewMemory()
+	if err != nil {
+		t.Fatalf("NewMemory: %v", err)
+	}
+	defer s.Close()
+	ws := &WebServer{store: s}
+	ws.SetAdminToken("secret")
+	mux := http.NewServeMux()
+	ws.registerAdmin(mux)
+
+	const id = "aa00000000000000000000000000000000000000000000000000000000000000"
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"deny", `{"id":"` + id + `","reason":"spam"}`, http.StatusOK},
+		{"short ID", `{"id":"aa00"}`, http.StatusBadRequest},
+		{"not hex", `{"id":"` + strings.Repeat("z", 64) + `"}`, http.StatusBadRequest},
+		{"reason too long", `{"id":"` + id + `","reason":"` + strings.Repeat("x", store.MaxDenyReason+1) + `"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/api/admin/denylist", strings.NewReader(tt.body))
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.name, rec.Code, tt.want, rec.Body)
+		}
+	}
+	if !s.IsDenied(id) {
+		t.Error("ID not denied after POST")
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/config/reload", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotImplemented {
+		t.Errorf("reload without a reload func: status = %d, want 501", rec.Code)
+	}
+}
+
+func TestAdminLogLevel(t *testing.T) {
+	level := zap.NewAtomicLevelAt(zap.InfoLevel)
+	ws := &WebServer{logger: zap.NewNop()}
+	ws.SetAdminToken("secret")
+	ws.SetLogLevel(level)
+	mux := http.NewServeMux()
+	ws.registerAdmin(mux)
+
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		want   int
+		level  zapcore.Level
+	}{
+		{"read", http.MethodGet, "", http.StatusOK, zap.InfoLevel},
+		{"set debug", http.MethodPost, `{"level":"debug"}`, http.StatusOK, zap.DebugLevel},
+		{"unknown level", http.MethodPost, `{"level":"loud"}`, http.StatusBadRequest, zap.DebugLevel},
+		{"fatal is not settable", http.MethodPost, `{"level":"fatal"}`, http.StatusBadRequest, zap.DebugLevel},
+		{"not JSON", http.MethodPost, `debug`, http.StatusBadRequest, zap.DebugLevel},
+		{"wrong method", http.MethodDelete, "", http.StatusMethodNotAllowed, zap.DebugLevel},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/api/admin/log/level", strings.NewReader(tt.body))
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.want)
+		}
+		if level.Level() != tt.level {
+			t.Errorf("%s: level = %s, want %s", tt.name, level.Level(), tt.level)
+		}
+	}
+}
+
+func TestAuditFilter(t *testing.T) {
+	tests := []struct {
+		query     string
+		wantErr   bool
+		wantLimit int
+	}{
+		{"", false, defaultAuditLimit},
+		{"kind=update&peer=12D3&site=aa&cid=bb", false, defaultAuditLimit},
+		{"limit=5", false, 5},
+		{"limit=1000000", false, p2p.AuditLogSize},
+		{"limit=0", true, 0},
+		{"limit=x", true, 0},
+		{"kind=everything", true, 0},
+		{"since=2026-10-16T10:00:00Z", false, defaultAuditLimit},
+		{"since=yesterday", true, 0},
+	}
+	for _, tt := range tests {
+		q, _ := url.ParseQuery(tt.query)
+		f, err := auditFilter(q)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: error = %v, wantErr %v", tt.query, err, tt.wantErr)
+			continue
+		}
+		if err == nil && f.Limit != tt.wantLimit {
+			t.Errorf("%q: limit = %d, want %d", tt.query, f.Limit, tt.wantLimit)
+		}
+	}
+}
+
+func TestAdminProofValidation(t *testing.T) {
+	const peerID = "12D3KooWD3eckifWpRn9wQpMG9R9hX3sD158z7EqHWmweQAJU5SA"
+	const siteID = "aa00000000000000000000000000000000000000000000000000000000000000"
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"not JSON", `{`},
+		{"bad peer", `{"peer": "nobody", "cid": "` + siteID + `"}`},
+		{"no target", `{"peer": "` + peerID + `"}`},
+		{"site and CID", `{"peer": "` + peerID + `", "site_id": "` + siteID + `", "cid": "` + siteID + `"}`},
+		{"bad site ID", `{"peer": "` + peerID + `", "site_id": "AA"}`},
+	}
+	ws := &WebServer{logger: zap.NewNop()}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		ws.handleAdminProof(rec, httptest.NewRequest(http.MethodPost, "/api/admin/proof", strings.NewReader(tt.body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", tt.name, rec.Code)
+		}
+	}
+}
+
+func TestAdminReplication(t *testing.T) {
+	leader, err := store.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer leader.Close()
+	follower, err := store.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer follower.Close()
+	ws := &WebServer{store: leader, logger: zap.NewNop()}
+	ws.SetAdminToken("secret")
+	mux := http.NewServeMux()
+	ws.registerAdmin(mux)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	pull := func(since string) (*http.Response, error) {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/admin/replication?since="+since, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		return http.DefaultClient.Do(req)
+	}
+	resp, err := pull("x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("since=x: status = %d, want 400", resp.StatusCode)
+	}
+
+	content := []byte("<p>replicated</p>")
+	cid := core.CIDForContent(content)
+	if err := leader.PutContent(cid, content); err != nil {
+		t.Fatalf("PutContent: %v", err)
+	}
+	resp, err = pull("0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if err := follower.ApplyChanges(resp.Body); err != nil {
+		t.Fatalf("ApplyChanges: %v", err)
+	}
+	if next := resp.Trailer.Get(ReplicationNextHeader); next == "" || next == "0" {
+		t.Errorf("next version trailer = %q", next)
+	}
+	if data, err := follower.GetContent(cid); err != nil || string(data) != string(content) {
+		t.Errorf("follower content = %q, %v", data, err)
+	}
+}
@@ -0,0 +1,180 @@
+package webserver
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Every JSON endpoint is registered through handleAPI, which serves it
+// both at its /api/ path and under /api/v1/, and records it for the
+// OpenAPI document at /api/openapi.json. The document is built from what
+// each server registered, so it cannot list endpoints the server does not
+// have. The unversioned paths stay for the bundled pages and existing
+// scripts; a breaking change would move to /api/v2/ and keep v1 serving.
+
+// APIVersion is the version of the JSON API served under /api/v1/.
+const APIVersion = "1"
+
+// apiV1Prefix is where version 1 of the API is served.
+const apiV1Prefix = "/api/v1/"
+
+// OpenAPIPath serves the OpenAPI document of a server's API.
+const OpenAPIPath = "/api/openapi.json"
+
+// apiDoc describes an endpoint for the OpenAPI document.
+type apiDoc struct {
+	Methods  string   // space-separated, such as "GET POST"
+	Summary  string   // one line
+	Path     string   // OpenAPI path template, such as "/site/{site}"; default: the registered path
+	Query    []string // query parameters
+	Admin    bool     // needs the admin capability
+	Body     string   // POST body media type; default application/json
+	Produces string   // response media type; default application/json
+}
+
+// apiRoute is a registered endpoint.
+type apiRoute struct {
+	path string // as registered, starting with /api/
+	doc  apiDoc
+}
+
+// handleAPI registers h at path, which starts with /api/, and at the same
+// path under /api/v1/, behind the rate limit, the same-origin check and
+// the capability check, and documents it. Requests to the v1 path reach h
+// with the unversioned path, so handlers keep parsing r.URL.Path as before.
+func (ws *WebServer) handleAPI(mux *http.ServeMux, path string, h http.HandlerFunc, doc apiDoc) {
+	h = ws.rateLimit(ws.sameOrigin(ws.authorize(h, doc)))
+	mux.HandleFunc(path, h)
+	mux.HandleFunc(apiV1Prefix+strings.TrimPrefix(path, "/api/"), stripAPIVersion(h))
+	ws.apiRoutes = append(ws.apiRoutes, apiRoute{path: path, doc: doc})
+}
+
+// registerOpenAPI serves the OpenAPI document; call it after the
+// endpoints are registered.
+func (ws *WebServer) registerOpenAPI(mux *http.ServeMux) {
+	mux.HandleFunc(OpenAPIPath, ws.handleOpenAPI)
+	mux.HandleFunc(apiV1Prefix+"openapi.json", ws.handleOpenAPI)
+}
+
+// stripAPIVersion passes requests on with /api/v1/ replaced by /api/.
+func stripAPIVersion(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r2 := new(http.Request)
+		*r2 = *r
+		u := *r.URL
+		u.Path = "/api/" + strings.TrimPrefix(r.URL.Path, apiV1Prefix)
+		u.RawPath = ""
+		r2.URL = &u
+		h(w, r2)
+	}
+}
+
+// apiPathParam matches the parameters of an OpenAPI path template.
+var apiPathParam = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// openAPIDocument builds the OpenAPI 3 document for the registered routes,
+// relative to the /api/v1 server URL.
+func (ws *WebServer) openAPIDocument() map[string]interface{} {
+	routes := append([]apiRoute(nil), ws.apiRoutes...)
+	sort.SliceStable(routes, func(i, j int) bool { return routes[i].path < routes[j].path })
+
+	paths := map[string]interface{}{}
+	for _, route := range routes {
+		template := route.doc.Path
+		if template == "" {
+			template = strings.TrimSuffix(strings.TrimPrefix(route.path, "/api"), "/")
+		}
+		var params []interface{}
+		for _, m := range apiPathParam.FindAllStringSubmatch(template, -1) {
+			params = append(params, map[string]interface{}{
+				"name": m[1], "in": "path", "required": true, "schema": map[string]string{"type": "string"},
+			})
+		}
+		for _, q := range route.doc.Query {
+			params = append(params, map[string]interface{}{
+				"name": q, "in": "query", "schema": map[string]string{"type": "string"},
+			})
+		}
+		produces := route.doc.Produces
+		if produces == "" {
+			produces = "application/json"
+		}
+		item, _ := paths[template].(map[string]interface{})
+		if item == nil {
+			item = map[string]interface{}{}
+			paths[template] = item
+		}
+		for _, method := range strings.Fields(route.doc.Methods) {
+			op := map[string]interface{}{
+				"operationId": operationID(method, template),
+				"summary":     route.doc.Summary,
+				"responses": map[string]interface{}{
+					"200": map[string]interface{}{
+						"description": "OK",
+						"content":     map[string]interface{}{produces: map[string]interface{}{}},
+					},
+				},
+			}
+			if len(params) > 0 {
+				op["parameters"] = params
+			}
+			if method == http.MethodPost {
+				body := route.doc.Body
+				if body == "" {
+					body = "application/json"
+				}
+				op["requestBody"] = map[string]interface{}{
+					"content": map[string]interface{}{body: map[string]interface{}{}},
+				}
+			}
+			if route.doc.Admin || ws.requireToken {
+				op["security"] = []interface{}{map[string]interface{}{"adminToken": []string{}}}
+				op["x-capability"] = ws.needs(method, route.doc).String()
+			}
+			item[strings.ToLower(method)] = op
+		}
+	}
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "AlxNet API",
+			"version": APIVersion,
+		},
+		"servers": []interface{}{map[string]string{"url": strings.TrimSuffix(apiV1Prefix, "/")}},
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"securitySchemes": map[string]interface{}{
+				"adminToken": map[string]string{"type": "http", "scheme": "bearer"},
+			},
+		},
+	}
+}
+
+// operationID names an operation after its method and path, such as
+// getNodeStatus for GET /node/status and getSiteBySite for GET /site/{site}.
+func operationID(method, template string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, seg := range strings.Split(template, "/") {
+		if name, ok := strings.CutPrefix(seg, "{"); ok {
+			b.WriteString("By")
+			seg = strings.TrimSuffix(name, "}")
+		}
+		for _, word := range strings.FieldsFunc(seg, func(r rune) bool { return r == '-' || r == '_' || r == '.' }) {
+			b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	return b.String()
+}
+
+// handleOpenAPI serves the OpenAPI document of this server's API.
+func (ws *WebServer) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	writeJSON(w, ws.openAPIDocument())
+}
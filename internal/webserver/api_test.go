@@ -0,0 +1,88 @@
+package webserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"alxnet/internal/store"
+
+	"go.uber.org/zap"
+)
+
+func TestAPIVersionAndOpenAPI(t *testing.T) {
+	db, err := store.NewMemory()
+	if err != nil {
+		t.Fatalf("NewMemory: %v", err)
+	}
+	defer db.Close()
+	browser := NewBrowserServer(db, nil, zap.NewNop(), 0).Handler()
+	node := NewNodeServer(db, nil, zap.NewNop(), 0).Handler()
+	get := func(h http.Handler, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	// v1 paths answer like the unversioned ones, including handlers that
+	// parse the rest of the path
+	for _, path := range []string{"/sitenames", "/records/" + strings.Repeat("ab", 32), "/records/not-a-site"} {
+		old, v1 := get(browser, "/api"+path), get(browser, "/api/v1"+path)
+		if old.Code != v1.Code || old.Body.String() != v1.Body.String() {
+			t.Errorf("%s: /api answered %d %q, /api/v1 %d %q", path, old.Code, old.Body, v1.Code, v1.Body)
+		}
+	}
+
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Servers []struct {
+			URL string `json:"url"`
+		} `json:"servers"`
+		Paths map[string]map[string]struct {
+			OperationID string                   `json:"operationId"`
+			Parameters  []map[string]interface{} `json:"parameters"`
+			Security    []map[string][]string    `json:"security"`
+		} `json:"paths"`
+	}
+	for _, path := range []string{OpenAPIPath, "/api/v1/openapi.json"} {
+		rec := get(node, path)
+		if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil || rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d, %v", path, rec.Code, err)
+		}
+	}
+	if doc.OpenAPI != "3.0.3" || len(doc.Servers) != 1 || doc.Servers[0].URL != "/api/v1" {
+		t.Errorf("document header = %q, %+v", doc.OpenAPI, doc.Servers)
+	}
+	tests := []struct {
+		path, method, operationID string
+		admin                     bool
+		params                    []string
+	}{
+		{"/node/status", "get", "getNodeStatus", false, nil},
+		{"/storage/usage", "get", "getStorageUsage", false, []string{"limit", "site"}},
+		{"/storage/pins", "post", "postStoragePins", false, nil},
+		{"/admin/bans", "post", "postAdminBans", true, nil},
+		{"/nodes/{name}/{endpoint}", "get", "getNodesByNameByEndpoint", false, []string{"name", "endpoint"}},
+	}
+	for _, tt := range tests {
+		op, ok := doc.Paths[tt.path][tt.method]
+		if !ok {
+			t.Errorf("%s %s not documented", tt.method, tt.path)
+			continue
+		}
+		if op.OperationID != tt.operationID || (len(op.Security) > 0) != tt.admin || len(op.Parameters) != len(tt.params) {
+			t.Errorf("%s %s = %+v", tt.method, tt.path, op)
+		}
+		for i, p := range tt.params {
+			if i < len(op.Parameters) && op.Parameters[i]["name"] != p {
+				t.Errorf("%s %s parameter %d = %v, want %s", tt.method, tt.path, i, op.Parameters[i]["name"], p)
+			}
+		}
+	}
+	// Each server documents only its own endpoints
+	if _, ok := doc.Paths["/search"]; ok {
+		t.Error("node server documents the browser server's /search")
+	}
+}
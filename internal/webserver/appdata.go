@@ -0,0 +1,150 @@
+package webserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"alxnet/internal/core"
+	"alxnet/internal/p2p"
+)
+
+// appDataJSON is how app data records are returned by the API. Values are
+// embedded as the JSON they hold.
+type appDataJSON struct {
+	SiteID  string          `json:"site_id"`
+	Key     string          `json:"key"`
+	Value   json.RawMessage `json:"value,omitempty"`
+	Deleted bool            `json:"deleted,omitempty"`
+	Seq     uint64          `json:"seq"`
+	Time    time.Time       `json:"time"`
+}
+
+func toAppDataJSON(siteID string, rec *core.AppDataRecord) appDataJSON {
+	return appDataJSON{
+		SiteID:  siteID,
+		Key:     rec.Key,
+		Value:   json.RawMessage(rec.Value),
+		Deleted: rec.Deleted(),
+		Seq:     rec.Seq,
+		Time:    time.Unix(rec.TS, 0).UTC(),
+	}
+}
+
+// handleAPIAppData serves a site's app data: GET /api/appdata/{siteID}
+// returns its keys and values as one JSON object, and
+// GET /api/appdata/{siteID}/{key} returns one record. Deleted keys are
+// left out.
+func (ws *WebServer) handleAPIAppData(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	siteID, key, _ := strings.Cut(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/appdata/"), "/"), "/")
+	if len(siteID) != 64 || !isHex(siteID) {
+		http.Error(w, "Invalid site ID", http.StatusBadRequest)
+		return
+	}
+
+	if key == "" {
+		recs, err := ws.store.ListAppData(siteID)
+		if err != nil {
+			http.Error(w, "Failed to list app data", http.StatusInternalServerError)
+			return
+		}
+		data := make(map[string]json.RawMessage, len(recs))
+		for _, rec := range recs {
+			if !rec.Deleted() {
+				data[rec.Key] = json.RawMessage(rec.Value)
+			}
+		}
+		writeJSON(w, map[string]interface{}{
+			"site_id": siteID,
+			"data":    data,
+		})
+		return
+	}
+
+	if !core.ValidAppDataKey(key) {
+		http.Error(w, "Invalid app data key", http.StatusBadRequest)
+		return
+	}
+	rec, err := ws.store.GetAppData(siteID, key)
+	if err != nil {
+		http.Error(w, "Failed to get app data", http.StatusInternalServerError)
+		return
+	}
+	if rec == nil || rec.Deleted() {
+		http.Error(w, "App data key not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, toAppDataJSON(siteID, rec))
+}
+
+// handleWalletAppData sets or deletes an app data key of one of the
+// wallet's sites, signed with that site's key, and publishes it.
+func (ws *WebServer) handleWalletAppData(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		WalletData         string          `json:"wallet_data"`
+		Mnemonic           string          `json:"mnemonic"`
+		MnemonicPassphrase string          `json:"mnemonic_passphrase"`
+		Label              string          `json:"label"`
+		Key                string          `json:"key"`
+		Value              json.RawMessage `json:"value"`
+		Delete             bool            `json:"delete"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxCommentRequestBody)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if !core.ValidAppDataKey(req.Key) {
+		writeWalletError(w, http.StatusBadRequest, "App data keys are 1-128 characters of a-z, 0-9, '.', '_', '-' and '/'")
+		return
+	}
+	var value []byte
+	if !req.Delete {
+		if len(req.Value) == 0 {
+			writeWalletError(w, http.StatusBadRequest, "A JSON value is required")
+			return
+		}
+		// Compact, so the signed value does not depend on the sender's formatting
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, req.Value); err != nil {
+			writeWalletError(w, http.StatusBadRequest, "The value is not JSON")
+			return
+		}
+		value = buf.Bytes()
+	}
+	siteID, priv, ok := ws.walletSiteKey(w, r, req.WalletData, req.Mnemonic, req.MnemonicPassphrase, req.Label)
+	if !ok {
+		return
+	}
+
+	seq, err := ws.node.NextAppDataSeq(siteID, req.Key)
+	if err != nil {
+		http.Error(w, "Failed to read app data", http.StatusInternalServerError)
+		return
+	}
+	rec, err := p2p.BuildAppData(priv, req.Key, value, seq)
+	if err != nil {
+		writeWalletError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	if err := ws.node.PublishAppData(ctx, rec); err != nil {
+		writeWalletError(w, http.StatusBadGateway, "Failed to publish app data: "+err.Error())
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"success":  true,
+		"app_data": toAppDataJSON(siteID, rec),
+	})
+}
@@ -0,0 +1,54 @@
+package webserver
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"alxnet/internal/fingerprint"
+)
+
+// immutableCacheControl lets browsers and HTTP caches keep content addressed
+// by CID for a year without revalidating; its bytes cannot change.
+const immutableCacheControl = "public, max-age=31536000, immutable"
+
+// handleContentByCID serves a content blob by CID, fetching it from peers
+// if needed: GET /_alxnet/cid/{cid}/{name}. Sites published with
+// fingerprinted assets reference their stylesheets, scripts and images this
+// way. The name gives the MIME type; HTML is served as plain text, since a
+// blob has no site to be a page of.
+func (ws *WebServer) handleContentByCID(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	cid, name, ok := fingerprint.ParsePath(r.URL.EscapedPath())
+	if ok {
+		name, _ = url.PathUnescape(name)
+	}
+	if !ok || len(cid) != 64 || !isHex(cid) {
+		http.Error(w, "Invalid content path", http.StatusBadRequest)
+		return
+	}
+	content, err := ws.fetchContent(r.Context(), cid)
+	if err != nil {
+		http.Error(w, "Content not found", http.StatusNotFound)
+		return
+	}
+
+	mimeType := ws.getMimeType(name)
+	if mediaType, _, _ := mime.ParseMediaType(mimeType); mediaType == "text/html" || strings.Contains(mediaType, "xhtml") {
+		mimeType = fallbackMimeType
+	}
+	w.Header().Set("Content-Type", mimeType)
+	w.Header().Set("Content-Security-Policy", sandboxCSP)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("X-AlxNet-Content-CID", cid)
+	w.Header().Set("ETag", `"`+cid+`"`)
+	w.Header().Set("Cache-Control", immutableCacheControl)
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(content))
+}
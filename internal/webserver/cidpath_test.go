@@ -0,0 +1,55 @@
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"alxnet/internal/core"
+	"alxnet/internal/fingerprint"
+	"alxnet/internal/store"
+)
+
+func TestContentByCID(t *testing.T) {
+	s, err := store.NewMemory()
+	if err != nil {
+		t.Fatalf("NewMemory: %v", err)
+	}
+	defer s.Close()
+	ws := &WebServer{store: s, assets: newAssetCache(1 << 20)}
+
+	css := []byte("h1 { color: red }")
+	cid := core.CIDForContent(css)
+	if err := s.PutContent(cid, css); err != nil {
+		t.Fatalf("PutContent: %v", err)
+	}
+	get := func(p, etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, p, nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		rec := httptest.NewRecorder()
+		ws.handleContentByCID(rec, req)
+		return rec
+	}
+
+	rec := get(fingerprint.AssetPath(cid, "site.css"), "")
+	if rec.Code != http.StatusOK || rec.Body.String() != string(css) || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/css") ||
+		rec.Header().Get("Cache-Control") != immutableCacheControl {
+		t.Fatalf("GET = %d %q %v", rec.Code, rec.Body, rec.Header())
+	}
+	if rec := get(fingerprint.AssetPath(cid, "site.css"), `"`+cid+`"`); rec.Code != http.StatusNotModified {
+		t.Errorf("GET with a matching ETag = %d", rec.Code)
+	}
+	// A blob named as a page is not served as one
+	if rec := get(fingerprint.AssetPath(cid, "page.html"), ""); rec.Header().Get("Content-Type") != fallbackMimeType {
+		t.Errorf("Content-Type of an HTML name = %s", rec.Header().Get("Content-Type"))
+	}
+	if rec := get(fingerprint.PathPrefix+"nothex/site.css", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("GET of an invalid CID = %d", rec.Code)
+	}
+	if rec := get(fingerprint.AssetPath(strings.Repeat("ab", 32), "x.css"), ""); rec.Code != http.StatusNotFound {
+		t.Errorf("GET of missing content = %d", rec.Code)
+	}
+}
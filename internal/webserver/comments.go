@@ -0,0 +1,213 @@
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"alxnet/internal/p2p"
+	"alxnet/internal/store"
+	"alxnet/internal/wallet"
+)
+
+// Comment listing limits
+const (
+	defaultCommentLimit = 50
+	maxCommentLimit     = 200
+	// maxCommentRequestBody leaves room for the encrypted wallet sent with
+	// posting and moderation requests.
+	maxCommentRequestBody = 1 << 20
+)
+
+// commentJSON is how comments are returned by the API. Bodies are
+// visitor-supplied text and must be escaped by whoever displays them.
+type commentJSON struct {
+	CID     string    `json:"cid"`
+	SiteID  string    `json:"site_id"`
+	Author  string    `json:"author"` // hex ed25519 public key
+	ReplyTo string    `json:"reply_to,omitempty"`
+	Body    string    `json:"body"`
+	Time    time.Time `json:"time"`
+	Hidden  bool      `json:"hidden"`
+}
+
+func commentsJSON(entries []store.CommentEntry) []commentJSON {
+	out := make([]commentJSON, 0, len(entries))
+	for _, e := range entries {
+		out = append(out, commentJSON{
+			CID:     e.CID,
+			SiteID:  e.Comment.SiteID,
+			Author:  p2p.PubHex(e.Comment.AuthorPub),
+			ReplyTo: e.Comment.ReplyTo,
+			Body:    e.Comment.Body,
+			Time:    time.Unix(e.Comment.TS, 0).UTC(),
+			Hidden:  e.Hidden,
+		})
+	}
+	return out
+}
+
+// listComments writes the comments of the site in the siteID query or path
+// parameter, newest first.
+func (ws *WebServer) listComments(w http.ResponseWriter, r *http.Request, siteID string, includeHidden bool) {
+	if len(siteID) != 64 || !isHex(siteID) {
+		http.Error(w, "Invalid site ID", http.StatusBadRequest)
+		return
+	}
+	limit := defaultCommentLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxCommentLimit)
+	}
+	entries, err := ws.store.ListComments(siteID, limit, includeHidden)
+	if err != nil {
+		http.Error(w, "Failed to list comments", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"site_id":  siteID,
+		"comments": commentsJSON(entries),
+	})
+}
+
+// handleAPIComments serves the visible comments of a site:
+// GET /api/comments/{siteID}?limit=1-200. Sites can fetch it to show a
+// guestbook.
+func (ws *WebServer) handleAPIComments(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ws.listComments(w, r, strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/comments/"), "/"), false)
+}
+
+// handleWalletComments lists a site's comments including hidden ones, for
+// its owner to moderate: GET /api/wallet/comments?site_id=...
+func (ws *WebServer) handleWalletComments(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ws.listComments(w, r, r.URL.Query().Get("site_id"), true)
+}
+
+// writeWalletError answers in the {"success": false, "error": ...} shape the
+// wallet UI expects.
+func writeWalletError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": false,
+		"error":   msg,
+	}); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// handleWalletComment signs a comment with the wallet's visitor identity and
+// publishes it.
+func (ws *WebServer) handleWalletComment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		WalletData         string `json:"wallet_data"`
+		Mnemonic           string `json:"mnemonic"`
+		MnemonicPassphrase string `json:"mnemonic_passphrase"`
+		SiteID             string `json:"site_id"`
+		ReplyTo            string `json:"reply_to"`
+		Body               string `json:"body"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxCommentRequestBody)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	_, master, ok := ws.unlockWallet(w, r, req.WalletData, req.Mnemonic, req.MnemonicPassphrase)
+	if !ok {
+		return
+	}
+	defer clear(master)
+	_, priv, err := wallet.DeriveIdentityKey(master)
+	if err != nil {
+		http.Error(w, "Failed to derive identity key", http.StatusInternalServerError)
+		return
+	}
+
+	rec, err := p2p.BuildComment(priv, req.SiteID, req.ReplyTo, req.Body)
+	if err != nil {
+		writeWalletError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	cid, err := ws.node.PublishComment(ctx, rec)
+	if err != nil {
+		writeWalletError(w, http.StatusBadGateway, "Failed to publish comment: "+err.Error())
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"success": true,
+		"cid":     cid,
+		"author":  p2p.PubHex(rec.AuthorPub),
+	})
+}
+
+// handleWalletModerate hides or shows a comment on one of the wallet's
+// sites, signed with that site's key.
+func (ws *WebServer) handleWalletModerate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		WalletData         string `json:"wallet_data"`
+		Mnemonic           string `json:"mnemonic"`
+		MnemonicPassphrase string `json:"mnemonic_passphrase"`
+		Label              string `json:"label"`
+		CommentCID         string `json:"comment_cid"`
+		Hidden             bool   `json:"hidden"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxCommentRequestBody)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	walletData, master, ok := ws.unlockWallet(w, r, req.WalletData, req.Mnemonic, req.MnemonicPassphrase)
+	if !ok {
+		return
+	}
+	defer clear(master)
+	if _, exists := walletData.Sites[req.Label]; !exists {
+		writeWalletError(w, http.StatusNotFound, "Site not found in wallet")
+		return
+	}
+	_, _, priv, err := walletData.EnsureSite(master, req.Label)
+	if err != nil {
+		http.Error(w, "Failed to get site", http.StatusInternalServerError)
+		return
+	}
+
+	rec, err := p2p.BuildModeration(priv, req.CommentCID, req.Hidden)
+	if err != nil {
+		writeWalletError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	if err := ws.node.PublishModeration(ctx, rec); err != nil {
+		writeWalletError(w, http.StatusBadRequest, "Failed to moderate comment: "+err.Error())
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"success":     true,
+		"comment_cid": req.CommentCID,
+		"hidden":      req.Hidden,
+	})
+}
@@ -0,0 +1,209 @@
+package webserver
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"alxnet/internal/core"
+	"alxnet/internal/p2p"
+	"alxnet/internal/publisher"
+	"alxnet/internal/wallet"
+)
+
+// Collaborative editing: a wallet's identity key, the one that signs its
+// comments, is also its contributor key. An owner grants it paths of a
+// site at /api/wallet/grant; the contributor signs files with it at
+// /api/wallet/contribute and sends the contribution to the owner, who
+// drafts it at /api/site/contribution.
+
+// handleContributorKey returns the wallet's contributor key, for the
+// contributor to give to a site owner: POST /api/wallet/contributor-key.
+func (ws *WebServer) handleContributorKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		WalletData         string `json:"wallet_data"`
+		Mnemonic           string `json:"mnemonic"`
+		MnemonicPassphrase string `json:"mnemonic_passphrase"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxCommentRequestBody)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	_, master, ok := ws.unlockWallet(w, r, req.WalletData, req.Mnemonic, req.MnemonicPassphrase)
+	if !ok {
+		return
+	}
+	defer clear(master)
+	pub, _, err := wallet.DeriveIdentityKey(master)
+	if err != nil {
+		http.Error(w, "Failed to derive identity key", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"success":     true,
+		"contributor": p2p.PubHex(pub),
+	})
+}
+
+// handleWalletGrant signs a grant letting a contributor key edit paths of
+// one of the wallet's sites. It is returned for the owner to give to the
+// contributor, not published: POST /api/wallet/grant.
+func (ws *WebServer) handleWalletGrant(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		WalletData         string   `json:"wallet_data"`
+		Mnemonic           string   `json:"mnemonic"`
+		MnemonicPassphrase string   `json:"mnemonic_passphrase"`
+		Label              string   `json:"label"`
+		Contributor        string   `json:"contributor"`
+		Paths              []string `json:"paths"`
+		Days               int      `json:"days"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxCommentRequestBody)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	maxDays := int(core.MaxContributorGrantDuration / (24 * time.Hour))
+	if req.Days < 1 || req.Days > maxDays {
+		writeWalletError(w, http.StatusBadRequest, fmt.Sprintf("Grants must be valid between 1 and %d days", maxDays))
+		return
+	}
+	contributor, err := hex.DecodeString(strings.TrimSpace(req.Contributor))
+	if err != nil || len(contributor) != 32 {
+		writeWalletError(w, http.StatusBadRequest, "Enter the contributor key as 64 hex characters")
+		return
+	}
+	var paths []string
+	for _, p := range req.Paths {
+		if p = strings.TrimPrefix(strings.TrimSpace(p), "/"); p != "" {
+			paths = append(paths, p)
+		}
+	}
+	_, priv, ok := ws.walletSiteKey(w, r, req.WalletData, req.Mnemonic, req.MnemonicPassphrase, req.Label)
+	if !ok {
+		return
+	}
+
+	grant, err := p2p.BuildContributorGrant(priv, contributor, paths, time.Now().Add(time.Duration(req.Days)*24*time.Hour))
+	if err != nil {
+		writeWalletError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"success":     true,
+		"site_id":     core.SiteIDFromPub(grant.SitePub),
+		"contributor": p2p.PubHex(grant.ContributorPub),
+		"paths":       grant.Paths,
+		"expires":     time.Unix(grant.Expires, 0).UTC(),
+		"grant":       p2p.EncodeContributorGrant(grant),
+	})
+}
+
+// handleWalletContribute signs a file with the wallet's contributor key
+// under a grant, returning the contribution for the contributor to send
+// to the site owner: POST /api/wallet/contribute.
+func (ws *WebServer) handleWalletContribute(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		WalletData         string `json:"wallet_data"`
+		Mnemonic           string `json:"mnemonic"`
+		MnemonicPassphrase string `json:"mnemonic_passphrase"`
+		Grant              string `json:"grant"`
+		FilePath           string `json:"file_path"`
+		Content            string `json:"content"` // base64
+		MimeType           string `json:"mime_type"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAddFileBody)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	filePath, ok := core.CanonicalFilePath(req.FilePath)
+	if !ok || filePath == "" {
+		writeWalletError(w, http.StatusBadRequest, "Invalid file path")
+		return
+	}
+	content, err := base64.StdEncoding.DecodeString(req.Content)
+	if err != nil {
+		writeWalletError(w, http.StatusBadRequest, "Content must be base64")
+		return
+	}
+	mimeType := strings.TrimSpace(req.MimeType)
+	if mimeType == "" {
+		var known bool
+		if mimeType, known = knownMimeType(filePath); !known {
+			mimeType = "application/octet-stream"
+		}
+	}
+	_, master, ok := ws.unlockWallet(w, r, req.WalletData, req.Mnemonic, req.MnemonicPassphrase)
+	if !ok {
+		return
+	}
+	defer clear(master)
+	_, priv, err := wallet.DeriveIdentityKey(master)
+	if err != nil {
+		http.Error(w, "Failed to derive identity key", http.StatusInternalServerError)
+		return
+	}
+
+	c, err := publisher.Contribute(priv, strings.TrimSpace(req.Grant), filePath, content, mimeType)
+	if err != nil {
+		publishError(w, err)
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"success":      true,
+		"contribution": c,
+	})
+}
+
+// handleAcceptContribution checks a contribution against its grant and
+// saves its file as a draft of the wallet site: POST /api/site/contribution.
+func (ws *WebServer) handleAcceptContribution(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		WalletData         string                  `json:"wallet_data"`
+		Mnemonic           string                  `json:"mnemonic"`
+		MnemonicPassphrase string                  `json:"mnemonic_passphrase"`
+		SiteLabel          string                  `json:"site_label"`
+		Contribution       *publisher.Contribution `json:"contribution"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAddFileBody)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	siteID, priv, ok := ws.walletSiteKey(w, r, req.WalletData, req.Mnemonic, req.MnemonicPassphrase, req.SiteLabel)
+	if !ok {
+		return
+	}
+	f, err := ws.publisher().AcceptContribution(priv, req.Contribution)
+	if err != nil {
+		publishError(w, err)
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"success":     true,
+		"site_id":     siteID,
+		"file_path":   f.Path,
+		"content_cid": f.ContentCID,
+		"mime_type":   f.MimeType,
+		"size":        f.Size,
+		"draft":       true,
+	})
+}
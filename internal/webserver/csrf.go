@@ -0,0 +1,99 @@
+package webserver
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// A page open in the user's browser can send requests to the wallet and
+// node UIs on localhost, and a form or fetch of another origin gets the
+// request through even though it cannot read the answer. Requests that
+// change state are therefore refused when the browser says they come from
+// another origin: Sec-Fetch-Site, sent by current browsers, must be
+// same-origin or none (typed or bookmarked), and otherwise the Origin
+// header, if any, must name the host the request was sent to. Requests
+// without either header come from scripts and the CLI, not from pages.
+// Another port of the same host is another origin, so a site open in the
+// browser interface cannot post to the wallet.
+//
+// Sites are served on the browser interface's own origin, next to its
+// address book, follows and name registration, so their files are served
+// as sandboxed documents (sandboxCSP): their scripts run with an opaque
+// origin, and what they send is cross-origin like any other site's. A page
+// of a domain its owner points at 127.0.0.1 (DNS rebinding) is same-origin
+// with the Host it sends, so state-changing requests from a browser must
+// also be sent to a host that cannot be rebound: an IP address, localhost
+// or a name configured with SetAllowedHosts.
+
+// sandboxCSP serves untrusted pages, published sites and editor previews,
+// as sandboxed documents with an opaque origin.
+const sandboxCSP = "sandbox allow-scripts allow-forms allow-popups"
+
+// crossOrigin reports whether a browser sent r from a page of another
+// origin.
+func crossOrigin(r *http.Request) bool {
+	switch r.Header.Get("Sec-Fetch-Site") {
+	case "same-origin", "none":
+		return false
+	case "":
+	default:
+		return true
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+	u, err := url.Parse(origin)
+	return err != nil || u.Host != r.Host
+}
+
+// sameOrigin refuses cross-origin requests other than GET, HEAD and
+// OPTIONS, and those a browser sent to a host that is not trusted.
+func (ws *WebServer) sameOrigin(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			if crossOrigin(r) {
+				http.Error(w, "Cross-origin request refused", http.StatusForbidden)
+				return
+			}
+			if fromBrowser(r) && !ws.trustedHost(r.Host) {
+				http.Error(w, "Request to an unknown host refused", http.StatusForbidden)
+				return
+			}
+		}
+		h(w, r)
+	}
+}
+
+// fromBrowser reports whether r carries the headers browsers add.
+func fromBrowser(r *http.Request) bool {
+	return r.Header.Get("Sec-Fetch-Site") != "" || r.Header.Get("Origin") != ""
+}
+
+// trustedHost reports whether host, with or without a port, is an IP
+// address, localhost or an allowed host name.
+func (ws *WebServer) trustedHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.TrimSuffix(strings.Trim(host, "[]"), "."))
+	if net.ParseIP(host) != nil || host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	return slices.Contains(ws.allowedHosts, host)
+}
+
+// SetAllowedHosts names the hosts, besides IP addresses and localhost,
+// that browsers may send state-changing requests to: the names the server
+// is reached by, such as a reverse proxy's.
+func (ws *WebServer) SetAllowedHosts(hosts []string) {
+	ws.allowedHosts = nil
+	for _, h := range hosts {
+		ws.allowedHosts = append(ws.allowedHosts, strings.ToLower(strings.TrimSuffix(h, ".")))
+	}
+}
@@ -0,0 +1,114 @@
+package webserver
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"alxnet/internal/core"
+	"alxnet/internal/publisher"
+	"alxnet/internal/store"
+
+	"go.uber.org/zap"
+)
+
+func TestSameOrigin(t *testing.T) {
+	ws := &WebServer{}
+	ws.SetAllowedHosts([]string{"alxnet.lan"})
+	mux := http.NewServeMux()
+	ws.handleAPI(mux, "/api/wallet/publish", func(w http.ResponseWriter, r *http.Request) {}, apiDoc{Methods: "GET POST"})
+
+	tests := []struct {
+		name    string
+		method  string
+		host    string
+		headers map[string]string
+		want    int
+	}{
+		{"script", http.MethodPost, "", nil, http.StatusOK},
+		{"same origin", http.MethodPost, "", map[string]string{"Sec-Fetch-Site": "same-origin", "Origin": "http://localhost:8081"}, http.StatusOK},
+		{"typed by the user", http.MethodPost, "", map[string]string{"Sec-Fetch-Site": "none"}, http.StatusOK},
+		{"cross site", http.MethodPost, "", map[string]string{"Sec-Fetch-Site": "cross-site", "Origin": "https://evil.example"}, http.StatusForbidden},
+		{"other port", http.MethodPost, "", map[string]string{"Sec-Fetch-Site": "same-site", "Origin": "http://localhost:8080"}, http.StatusForbidden},
+		{"old browser, same origin", http.MethodPost, "", map[string]string{"Origin": "http://localhost:8081"}, http.StatusOK},
+		{"old browser, other origin", http.MethodPost, "", map[string]string{"Origin": "https://evil.example"}, http.StatusForbidden},
+		{"opaque origin", http.MethodPost, "", map[string]string{"Origin": "null"}, http.StatusForbidden},
+		{"cross-site read", http.MethodGet, "", map[string]string{"Sec-Fetch-Site": "cross-site"}, http.StatusOK},
+		{"rebound name", http.MethodPost, "evil.example:8081", map[string]string{"Sec-Fetch-Site": "same-origin", "Origin": "http://evil.example:8081"}, http.StatusForbidden},
+		{"old browser, rebound name", http.MethodPost, "evil.example:8081", map[string]string{"Origin": "http://evil.example:8081"}, http.StatusForbidden},
+		{"script, any name", http.MethodPost, "evil.example:8081", nil, http.StatusOK},
+		{"IP address", http.MethodPost, "192.168.1.5:8081", map[string]string{"Sec-Fetch-Site": "same-origin", "Origin": "http://192.168.1.5:8081"}, http.StatusOK},
+		{"IPv6 loopback", http.MethodPost, "[::1]:8081", map[string]string{"Origin": "http://[::1]:8081"}, http.StatusOK},
+		{"allowed name", http.MethodPost, "ALXNET.lan:8081", map[string]string{"Sec-Fetch-Site": "same-origin", "Origin": "http://ALXNET.lan:8081"}, http.StatusOK},
+		{"localhost subdomain", http.MethodPost, "ui.localhost:8081", map[string]string{"Origin": "http://ui.localhost:8081"}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "http://localhost:8081/api/v1/wallet/publish", nil)
+		if tt.host != "" {
+			req.Host = tt.host
+		}
+		for k, v := range tt.headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.want)
+		}
+	}
+}
+
+// A published site is served on the browser interface's origin, next to
+// its address book, so its pages must not be able to change it.
+func TestSitePageCannotChangeAddressBook(t *testing.T) {
+	db, err := store.NewMemory()
+	if err != nil {
+		t.Fatalf("NewMemory: %v", err)
+	}
+	defer db.Close()
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	siteID := core.SiteIDFromPub(pub)
+	p := publisher.New(db, nil, nil)
+	page := `<script>fetch("/api/addressbook", {method: "POST", body: '{"name":"bank","target":"evil"}'})</script>`
+	if _, err := p.SaveFile(priv, "index.html", []byte(page), "text/html"); err != nil {
+		t.Fatalf("SaveFile: %v", err)
+	}
+	if _, err := p.PublishWebsite(context.Background(), priv); err != nil {
+		t.Fatalf("PublishWebsite: %v", err)
+	}
+	h := NewBrowserServer(db, nil, zap.NewNop(), 0).Handler()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://localhost:8080/"+siteID+"/", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Security-Policy") != sandboxCSP {
+		t.Fatalf("site page = %d with CSP %q, want it sandboxed", rec.Code, rec.Header().Get("Content-Security-Policy"))
+	}
+
+	// What the browser sends for the page's fetch: the sandbox gives the
+	// page an opaque origin, and a rebound name reaches the same server.
+	for name, headers := range map[string]map[string]string{
+		"sandboxed page":         {"Sec-Fetch-Site": "cross-site", "Origin": "null"},
+		"sandboxed, old browser": {"Origin": "null"},
+		"rebound name":           {"Host": "evil.example:8080", "Sec-Fetch-Site": "same-origin", "Origin": "http://evil.example:8080"},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "http://localhost:8080/api/addressbook", strings.NewReader(`{"name":"bank","target":"evil"}`))
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		if host := headers["Host"]; host != "" {
+			req.Host = host
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s: POST /api/addressbook = %d, want 403", name, rec.Code)
+		}
+	}
+	if aliases, _ := db.Aliases(); len(aliases) != 0 {
+		t.Errorf("address book = %v, want it unchanged", aliases)
+	}
+}
@@ -0,0 +1,40 @@
+package webserver
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"alxnet/internal/core"
+)
+
+// handleWalletDelete applies and broadcasts a delete record signed by a
+// site key elsewhere, such as by `alxnet wallet delete`. The record is
+// canonical CBOR, base64 encoded in JSON; the node never sees the key.
+func (ws *WebServer) handleWalletDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		Record []byte `json:"record"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxCommentRequestBody)).Decode(&req); err != nil {
+		writeWalletError(w, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	var del core.DeleteRecord
+	if err := core.UnmarshalCBOR(req.Record, &del); err != nil {
+		writeWalletError(w, http.StatusBadRequest, "Invalid delete record: "+err.Error())
+		return
+	}
+	if err := ws.publisher().PublishDelete(ws.ctx, &del); err != nil {
+		publishError(w, err)
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"success":        true,
+		"site_id":        core.SiteIDFromPub(del.SitePub),
+		"target_record":  del.TargetRec,
+		"target_content": del.TargetCont,
+	})
+}
@@ -0,0 +1,248 @@
+package webserver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"alxnet/internal/core"
+	"alxnet/internal/ntp"
+	"alxnet/internal/p2p"
+	"alxnet/internal/store"
+)
+
+// Diagnostics run by /api/admin/doctor.
+const (
+	doctorTimeout       = 30 * time.Second
+	doctorDialbackPeers = 3
+	doctorStoreLimit    = 200000 // entries checked for integrity
+	clockSkewWarn       = 5 * time.Second
+	clockSkewFail       = 60 * time.Second
+)
+
+// Doctor check outcomes.
+const (
+	checkOK   = "ok"
+	checkWarn = "warn"
+	checkFail = "fail"
+	checkSkip = "skip"
+)
+
+// doctorCheck is one diagnostic with a suggested fix when it did not pass.
+type doctorCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+	Fix    string `json:"fix,omitempty"`
+}
+
+// handleAdminDoctor runs connectivity and storage diagnostics on the node.
+func (ws *WebServer) handleAdminDoctor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), doctorTimeout)
+	defer cancel()
+
+	var checks []doctorCheck
+	peers := len(ws.node.Host.Network().Peers())
+	port := ws.node.ListenPort()
+	if peers == 0 {
+		checks = append(checks, doctorCheck{Name: "peers", Status: checkWarn,
+			Detail: "no connected peers",
+			Fix:    "start another node on the LAN or pass -bootstrap with a reachable peer's address"})
+	} else {
+		checks = append(checks, doctorCheck{Name: "peers", Status: checkOK, Detail: fmt.Sprintf("%d connected", peers)})
+	}
+
+	var dialbacks []p2p.DialbackResult
+	if port == 0 {
+		checks = append(checks, doctorCheck{Name: "port", Status: checkFail,
+			Detail: "node is not listening on TCP", Fix: "start the node with -node-port set to a free port"})
+	} else {
+		dialbacks, _ = ws.node.CheckReachability(ctx, port, doctorDialbackPeers)
+		checks = append(checks, reachabilityCheck(port, dialbacks))
+	}
+	checks = append(checks,
+		natCheck(port, ws.node.NATStatus(dialbacks)),
+		clockCheck(dialbacks),
+		ws.ntpCheck(ctx),
+		bootstrapCheck(ws.node.CheckBootstrap(ctx), peers),
+		storeCheck(ws.store))
+
+	writeJSON(w, map[string]interface{}{
+		"success": true,
+		"checks":  checks,
+	})
+}
+
+func reachabilityCheck(port int, results []p2p.DialbackResult) doctorCheck {
+	c := doctorCheck{Name: "port"}
+	if len(results) == 0 {
+		c.Status, c.Detail = checkSkip, fmt.Sprintf("TCP port %d: no peers to dial back", port)
+		return c
+	}
+	reachable, answered := 0, 0
+	var errs []string
+	for _, r := range results {
+		if r.Reachable {
+			reachable++
+		}
+		if r.RTT > 0 {
+			answered++
+		}
+		if r.Error != "" {
+			errs = append(errs, r.Error)
+		}
+	}
+	switch {
+	case reachable > 0:
+		c.Status = checkOK
+		c.Detail = fmt.Sprintf("TCP port %d reachable from %d of %d peers", port, reachable, len(results))
+	case answered == 0:
+		c.Status = checkSkip
+		c.Detail = fmt.Sprintf("TCP port %d: no peer answered a dial-back request (%s)", port, strings.Join(errs, "; "))
+	default:
+		c.Status = checkFail
+		c.Detail = fmt.Sprintf("TCP port %d not reachable from %d peers", port, answered)
+		c.Fix = fmt.Sprintf("allow inbound TCP %d in the firewall and forward it on the router", port)
+	}
+	return c
+}
+
+func natCheck(port int, status string) doctorCheck {
+	c := doctorCheck{Name: "nat", Detail: status}
+	switch status {
+	case "public":
+		c.Status = checkOK
+	case "firewalled":
+		c.Status = checkFail
+		c.Fix = fmt.Sprintf("the node has a public address but inbound TCP %d is blocked; open it in the firewall", port)
+	case "behind-nat":
+		c.Status = checkWarn
+		c.Fix = fmt.Sprintf("forward TCP %d on the router to this machine so peers can connect to it", port)
+	default:
+		c.Status = checkSkip
+	}
+	return c
+}
+
+func clockCheck(results []p2p.DialbackResult) doctorCheck {
+	c := doctorCheck{Name: "clock"}
+	var offsets []time.Duration
+	for _, r := range results {
+		if r.RTT > 0 {
+			offsets = append(offsets, r.ClockOffset)
+		}
+	}
+	if len(offsets) == 0 {
+		c.Status, c.Detail = checkSkip, "no peer clocks to compare with"
+		return c
+	}
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+	median := offsets[len(offsets)/2]
+	c.Detail = fmt.Sprintf("peers' clocks differ from ours by %s (median of %d)", median, len(offsets))
+	skew := median.Abs()
+	switch {
+	case skew >= clockSkewFail:
+		c.Status = checkFail
+	case skew >= clockSkewWarn:
+		c.Status = checkWarn
+	default:
+		c.Status = checkOK
+	}
+	if c.Status != checkOK {
+		c.Fix = "enable network time sync (NTP, systemd-timesyncd or the OS time settings)"
+	}
+	return c
+}
+
+// ntpCheck compares the local clock with the configured NTP server.
+func (ws *WebServer) ntpCheck(ctx context.Context) doctorCheck {
+	if ws.ntpServer == "" {
+		return doctorCheck{Name: "ntp", Status: checkSkip, Detail: "no NTP server configured (network.ntp_server)"}
+	}
+	res, err := ntp.Query(ctx, ws.ntpServer)
+	return ntpCheck(res, err, core.MaxClockSkew())
+}
+
+func ntpCheck(res ntp.Result, err error, maxSkew time.Duration) doctorCheck {
+	c := doctorCheck{Name: "ntp"}
+	if err != nil {
+		c.Status, c.Detail = checkSkip, fmt.Sprintf("%s did not answer: %v", res.Server, err)
+		return c
+	}
+	c.Detail = fmt.Sprintf("local clock differs from %s by %s", res.Server, res.Offset)
+	switch skew := res.Offset.Abs(); {
+	case skew >= maxSkew:
+		c.Status = checkFail
+		c.Detail += fmt.Sprintf(", more than the max clock skew of %s; peers reject records published here", maxSkew)
+	case skew >= clockSkewFail:
+		c.Status = checkFail
+	case skew >= clockSkewWarn:
+		c.Status = checkWarn
+	default:
+		c.Status = checkOK
+	}
+	if c.Status != checkOK {
+		c.Fix = "enable network time sync (NTP, systemd-timesyncd or the OS time settings)"
+	}
+	return c
+}
+
+func bootstrapCheck(results []p2p.BootstrapResult, peers int) doctorCheck {
+	c := doctorCheck{Name: "bootstrap"}
+	if len(results) == 0 {
+		c.Status, c.Detail = checkSkip, "no bootstrap peers configured"
+		if peers == 0 {
+			c.Status = checkWarn
+			c.Fix = "pass -bootstrap or set network.bootstrap_peers to a reachable node's address"
+		}
+		return c
+	}
+	var failed []string
+	for _, r := range results {
+		if !r.Reachable {
+			failed = append(failed, r.Addr+": "+r.Error)
+		}
+	}
+	if len(failed) == 0 {
+		c.Status, c.Detail = checkOK, fmt.Sprintf("all %d reachable", len(results))
+		return c
+	}
+	c.Status = checkWarn
+	if len(failed) == len(results) {
+		c.Status = checkFail
+	}
+	c.Detail = fmt.Sprintf("%d of %d unreachable: %s", len(failed), len(results), strings.Join(failed, "; "))
+	c.Fix = "check the addresses (they need a /p2p/<peer ID> suffix) and that those nodes are running"
+	return c
+}
+
+// storeCheck verifies stored objects against their CIDs.
+func storeCheck(s *store.Store) doctorCheck {
+	c := doctorCheck{Name: "store"}
+	report, err := s.CheckIntegrity(doctorStoreLimit)
+	if err != nil {
+		c.Status, c.Detail = checkFail, "integrity check failed: "+err.Error()
+		c.Fix = "stop the node and check the data directory's disk and permissions"
+		return c
+	}
+	c.Detail = fmt.Sprintf("%d records, %d content, %d manifests, %d file records checked",
+		report.Records, report.Content, report.Manifests, report.FileRecords)
+	if report.Truncated {
+		c.Detail += fmt.Sprintf(" (stopped after %d entries)", report.Checked)
+	}
+	if report.ProblemCount == 0 {
+		c.Status = checkOK
+		return c
+	}
+	c.Status = checkFail
+	c.Detail += fmt.Sprintf("; %d problems: %s", report.ProblemCount, strings.Join(report.Problems, "; "))
+	c.Fix = "back up the data directory, then republish the affected sites or fetch them again from peers"
+	return c
+}
@@ -0,0 +1,54 @@
+package webserver
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"alxnet/internal/ntp"
+	"alxnet/internal/p2p"
+)
+
+func TestDoctorChecks(t *testing.T) {
+	reachable := p2p.DialbackResult{Reachable: true, RTT: time.Millisecond}
+	refused := p2p.DialbackResult{Error: "dial failed", RTT: time.Millisecond}
+	silent := p2p.DialbackResult{Error: "protocol not supported"}
+	skewed := func(d time.Duration) p2p.DialbackResult {
+		return p2p.DialbackResult{Reachable: true, RTT: time.Millisecond, ClockOffset: d}
+	}
+
+	tests := []struct {
+		name  string
+		check doctorCheck
+		want  string
+	}{
+		{"port reachable", reachabilityCheck(4001, []p2p.DialbackResult{refused, reachable}), checkOK},
+		{"port refused", reachabilityCheck(4001, []p2p.DialbackResult{refused, silent}), checkFail},
+		{"port, no answers", reachabilityCheck(4001, []p2p.DialbackResult{silent}), checkSkip},
+		{"port, no peers", reachabilityCheck(4001, nil), checkSkip},
+		{"nat public", natCheck(4001, "public"), checkOK},
+		{"nat firewalled", natCheck(4001, "firewalled"), checkFail},
+		{"behind nat", natCheck(4001, "behind-nat"), checkWarn},
+		{"clock in sync", clockCheck([]p2p.DialbackResult{skewed(time.Second), skewed(-time.Second), silent}), checkOK},
+		{"clock drifting", clockCheck([]p2p.DialbackResult{skewed(10 * time.Second)}), checkWarn},
+		{"clock wrong", clockCheck([]p2p.DialbackResult{skewed(-2 * time.Minute), skewed(-3 * time.Minute), skewed(0)}), checkFail},
+		{"clock, no answers", clockCheck([]p2p.DialbackResult{silent}), checkSkip},
+		{"ntp in sync", ntpCheck(ntp.Result{Offset: 20 * time.Millisecond}, nil, time.Hour), checkOK},
+		{"ntp drifting", ntpCheck(ntp.Result{Offset: -10 * time.Second}, nil, time.Hour), checkWarn},
+		{"ntp wrong", ntpCheck(ntp.Result{Offset: 5 * time.Minute}, nil, time.Hour), checkFail},
+		{"ntp beyond max skew", ntpCheck(ntp.Result{Offset: -2 * time.Hour}, nil, time.Hour), checkFail},
+		{"ntp unreachable", ntpCheck(ntp.Result{}, errors.New("i/o timeout"), time.Hour), checkSkip},
+		{"no bootstrap, has peers", bootstrapCheck(nil, 2), checkSkip},
+		{"no bootstrap, no peers", bootstrapCheck(nil, 0), checkWarn},
+		{"bootstrap partly down", bootstrapCheck([]p2p.BootstrapResult{{Reachable: true}, {Error: "timeout"}}, 1), checkWarn},
+		{"bootstrap down", bootstrapCheck([]p2p.BootstrapResult{{Error: "timeout"}}, 0), checkFail},
+	}
+	for _, tt := range tests {
+		if tt.check.Status != tt.want {
+			t.Errorf("%s: status = %s, want %s (%s)", tt.name, tt.check.Status, tt.want, tt.check.Detail)
+		}
+		if (tt.check.Status == checkFail || tt.check.Status == checkWarn) && tt.check.Fix == "" {
+			t.Errorf("%s: no fix suggested", tt.name)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package webserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"alxnet/internal/core"
+	"alxnet/internal/publisher"
+)
+
+// maxDiffCells bounds the table a diff builds, the product of the changed
+// line counts of both versions. Larger diffs are reported as too large.
+const maxDiffCells = 4 << 20
+
+// Diff line operations.
+const (
+	diffSame    = " "
+	diffAdded   = "+"
+	diffRemoved = "-"
+)
+
+// diffLine is one line of a diff between a published file and its draft.
+type diffLine struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// changeJSON is the JSON form of a publisher.Change.
+func changeJSON(c publisher.Change) map[string]interface{} {
+	out := map[string]interface{}{
+		"path":   c.Path,
+		"status": c.Status,
+	}
+	if c.PublishedCID != "" {
+		out["published_cid"] = c.PublishedCID
+	}
+	if c.DraftCID != "" {
+		out["draft_cid"] = c.DraftCID
+	}
+	if c.MimeType != "" {
+		out["mime_type"] = c.MimeType
+	}
+	if !c.Saved.IsZero() {
+		out["saved"] = c.Saved.Format(time.RFC3339)
+	}
+	if c.Contributor != "" {
+		out["contributor"] = c.Contributor
+	}
+	return out
+}
+
+// draftRequest is the body of the draft endpoints.
+type draftRequest struct {
+	WalletData         string `json:"wallet_data"`
+	Mnemonic           string `json:"mnemonic"`
+	MnemonicPassphrase string `json:"mnemonic_passphrase"`
+	SiteLabel          string `json:"site_label"`
+	FilePath           string `json:"file_path"`
+}
+
+// draftSite decodes a draftRequest and returns the ID of its wallet site,
+// writing an error response if it cannot.
+func (ws *WebServer) draftSite(w http.ResponseWriter, r *http.Request) (*draftRequest, string, bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return nil, "", false
+	}
+	var req draftRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxCommentRequestBody)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return nil, "", false
+	}
+	walletData, ok := ws.openWallet(w, r, req.WalletData, req.Mnemonic, req.MnemonicPassphrase)
+	if !ok {
+		return nil, "", false
+	}
+	site, exists := walletData.Sites[req.SiteLabel]
+	if !exists {
+		writeWalletError(w, http.StatusNotFound, "Site not found in wallet")
+		return nil, "", false
+	}
+	return &req, site.SiteID, true
+}
+
+// handleSiteChanges lists the unpublished changes of a wallet site's
+// working copy: POST /api/site/changes.
+func (ws *WebServer) handleSiteChanges(w http.ResponseWriter, r *http.Request) {
+	_, siteID, ok := ws.draftSite(w, r)
+	if !ok {
+		return
+	}
+	changes, err := ws.publisher().Changes(siteID)
+	if err != nil {
+		publishError(w, err)
+		return
+	}
+	list := make([]map[string]interface{}, 0, len(changes))
+	for _, c := range changes {
+		list = append(list, changeJSON(c))
+	}
+	writeJSON(w, map[string]interface{}{
+		"success": true,
+		"site_id": siteID,
+		"changes": list,
+	})
+}
+
+// handleSiteDiff compares one file of a wallet site's working copy with its
+// published version, line by line: POST /api/site/diff.
+func (ws *WebServer) handleSiteDiff(w http.ResponseWriter, r *http.Request) {
+	req, siteID, ok := ws.draftSite(w, r)
+	if !ok {
+		return
+	}
+	filePath, ok := core.CanonicalFilePath(req.FilePath)
+	if !ok || filePath == "" {
+		writeWalletError(w, http.StatusBadRequest, "Invalid file path")
+		return
+	}
+	changes, err := ws.publisher().Changes(siteID)
+	if err != nil {
+		publishError(w, err)
+		return
+	}
+	var change *publisher.Change
+	for i := range changes {
+		if changes[i].Path == filePath {
+			change = &changes[i]
+		}
+	}
+	if change == nil {
+		writeWalletError(w, http.StatusNotFound, "File has no unpublished changes")
+		return
+	}
+
+	var versions [2][]byte // published, draft
+	for i, cid := range []string{change.PublishedCID, change.DraftCID} {
+		if cid == "" {
+			continue
+		}
+		if versions[i], err = ws.fetchContent(r.Context(), cid); err != nil {
+			writeWalletError(w, http.StatusNotFound, "File content not available")
+			return
+		}
+	}
+	resp := changeJSON(*change)
+	resp["success"] = true
+	if isBinary(versions[0]) || isBinary(versions[1]) {
+		resp["binary"] = true
+		writeJSON(w, resp)
+		return
+	}
+	lines, ok := diffLines(splitLines(versions[0]), splitLines(versions[1]))
+	if !ok {
+		resp["too_large"] = true
+		writeJSON(w, resp)
+		return
+	}
+	added, removed := 0, 0
+	for _, l := range lines {
+		switch l.Op {
+		case diffAdded:
+			added++
+		case diffRemoved:
+			removed++
+		}
+	}
+	resp["lines"], resp["added"], resp["removed"] = lines, added, removed
+	writeJSON(w, resp)
+}
+
+// handleDiscardDraft drops the draft of one file of a wallet site, so its
+// working copy has the published file again: POST /api/site/discard.
+func (ws *WebServer) handleDiscardDraft(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req draftRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxCommentRequestBody)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	filePath, ok := core.CanonicalFilePath(req.FilePath)
+	if !ok || filePath == "" {
+		writeWalletError(w, http.StatusBadRequest, "Invalid file path")
+		return
+	}
+	siteID, priv, ok := ws.walletSiteKey(w, r, req.WalletData, req.Mnemonic, req.MnemonicPassphrase, req.SiteLabel)
+	if !ok {
+		return
+	}
+	if err := ws.publisher().DiscardDraft(priv, filePath); err != nil {
+		publishError(w, err)
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"success":   true,
+		"site_id":   siteID,
+		"file_path": filePath,
+	})
+}
+
+// isBinary reports whether content cannot be shown as lines of text.
+func isBinary(content []byte) bool {
+	return !utf8.Valid(content) || bytes.IndexByte(content, 0) >= 0
+}
+
+// splitLines splits text into lines, without a last empty line for a
+// trailing newline.
+func splitLines(content []byte) []string {
+	if len(content) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+}
+
+// diffLines returns the lines of a and b as a shortest edit from a to b,
+// from their longest common subsequence. It reports false when the lines
+// between a common prefix and suffix are too many to compare.
+func diffLines(a, b []string) ([]diffLine, bool) {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	ma, mb := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	n, m := len(ma), len(mb)
+	if (n+1)*(m+1) > maxDiffCells {
+		return nil, false
+	}
+
+	// lcs[i*(m+1)+j] is the length of the LCS of ma[i:] and mb[j:]
+	lcs := make([]int32, (n+1)*(m+1))
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if ma[i] == mb[j] {
+				lcs[i*(m+1)+j] = lcs[(i+1)*(m+1)+j+1] + 1
+			} else {
+				lcs[i*(m+1)+j] = max(lcs[(i+1)*(m+1)+j], lcs[i*(m+1)+j+1])
+			}
+		}
+	}
+
+	out := make([]diffLine, 0, len(a)+m)
+	for _, l := range a[:prefix] {
+		out = append(out, diffLine{diffSame, l})
+	}
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && ma[i] == mb[j]:
+			out = append(out, diffLine{diffSame, ma[i]})
+			i, j = i+1, j+1
+		case i < n && (j == m || lcs[(i+1)*(m+1)+j] >= lcs[i*(m+1)+j+1]):
+			out = append(out, diffLine{diffRemoved, ma[i]})
+			i++
+		default:
+			out = append(out, diffLine{diffAdded, mb[j]})
+			j++
+		}
+	}
+	for _, l := range a[len(a)-suffix:] {
+		out = append(out, diffLine{diffSame, l})
+	}
+	return out, true
+}
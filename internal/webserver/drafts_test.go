@@ -0,0 +1,49 @@
+package webserver
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want string // ops and text, one line each
+	}{
+		{"", "", ""},
+		{"one\ntwo\n", "one\ntwo\n", " one  two"},
+		{"", "new\n", "+new"},
+		{"old\n", "", "-old"},
+		{"a\nb\nc\n", "a\nB\nc\n", " a -b +B  c"},
+		{"a\nb\nc\nd\n", "a\nc\nd\ne\n", " a -b  c  d +e"},
+		{"x\ny\n", "y\nx\n", "-x  y +x"},
+	}
+	for _, tt := range tests {
+		lines, ok := diffLines(splitLines([]byte(tt.a)), splitLines([]byte(tt.b)))
+		if !ok {
+			t.Errorf("diffLines(%q, %q) too large", tt.a, tt.b)
+			continue
+		}
+		var got []string
+		for _, l := range lines {
+			got = append(got, l.Op+l.Text)
+		}
+		if strings.Join(got, " ") != tt.want {
+			t.Errorf("diffLines(%q, %q) = %q, want %q", tt.a, tt.b, strings.Join(got, " "), tt.want)
+		}
+	}
+
+	// Only the lines between the common prefix and suffix count
+	big := strings.Repeat("same\n", 5000)
+	if _, ok := diffLines(splitLines([]byte(big+"a\n"+big)), splitLines([]byte(big+"b\n"+big))); !ok {
+		t.Error("diffLines() of a one-line change in a long file is too large")
+	}
+	var a, b strings.Builder
+	for i := 0; i < 3000; i++ {
+		a.WriteString("a" + strings.Repeat("x", i%7) + "\n")
+		b.WriteString("b" + strings.Repeat("x", i%5) + "\n")
+	}
+	if _, ok := diffLines(splitLines([]byte(a.String())), splitLines([]byte(b.String()))); ok {
+		t.Error("diffLines() of two unrelated long files was not too large")
+	}
+}
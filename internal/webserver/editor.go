@@ -0,0 +1,152 @@
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"alxnet/internal/core"
+)
+
+// draftFile is one file of a site as the editor sees it.
+type draftFile struct {
+	ContentCID string
+	MimeType   string
+}
+
+// draftFile resolves filePath in the site's working copy: its draft if
+// there is one, then the file record saved for the path, then the
+// published manifest.
+func (ws *WebServer) draftFile(siteID, filePath string) (*draftFile, error) {
+	d, err := ws.store.GetDraft(siteID, filePath)
+	if err != nil {
+		return nil, err
+	}
+	if d != nil {
+		if d.Deleted {
+			return nil, fmt.Errorf("file removed in drafts: %s", filePath)
+		}
+		mimeType := d.MimeType
+		if mimeType == "" {
+			mimeType = ws.getMimeType(filePath)
+		}
+		return &draftFile{ContentCID: d.ContentCID, MimeType: mimeType}, nil
+	}
+	if data, err := ws.store.GetFileRecordByPath(siteID, filePath); err == nil {
+		var rec core.FileRecord
+		if err := core.UnmarshalCBOR(data, &rec); err != nil {
+			return nil, fmt.Errorf("failed to parse file record: %v", err)
+		}
+		mimeType := rec.MimeType
+		if mimeType == "" {
+			mimeType = ws.getMimeType(filePath)
+		}
+		return &draftFile{ContentCID: rec.ContentCID, MimeType: mimeType}, nil
+	}
+
+	files, err := ws.siteContentCIDs(siteID)
+	if err != nil {
+		return nil, err
+	}
+	cid, ok := files[filePath]
+	if !ok {
+		return nil, fmt.Errorf("file not found in website: %s", filePath)
+	}
+	return &draftFile{ContentCID: cid, MimeType: ws.getMimeType(filePath)}, nil
+}
+
+// draftMainFile returns the main file of the site's published manifest, or
+// index.html for sites not published as a website yet.
+func (ws *WebServer) draftMainFile(siteID string) string {
+	if data, err := ws.store.GetCurrentWebsiteManifest(siteID); err == nil {
+		var m core.WebsiteManifest
+		if err := core.UnmarshalCBOR(data, &m); err == nil && m.MainFile != "" {
+			return m.MainFile
+		}
+	}
+	return "index.html"
+}
+
+// serveDraftFile writes a draft file's bytes as stored, with its MIME type.
+func (ws *WebServer) serveDraftFile(ctx context.Context, w http.ResponseWriter, siteID, filePath string) {
+	f, err := ws.draftFile(siteID, filePath)
+	if err != nil {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	content, err := ws.fetchContent(ctx, f.ContentCID)
+	if err != nil {
+		http.Error(w, "File content not available", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", f.MimeType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("X-AlxNet-Content-CID", f.ContentCID)
+	w.Header().Set("Cache-Control", "no-store")
+	if _, err := w.Write(content); err != nil {
+		http.Error(w, "Failed to write content", http.StatusInternalServerError)
+	}
+}
+
+// handleGetFile returns the bytes of one file of a wallet site, as saved in
+// the editor or last published: POST /api/site/get-file.
+func (ws *WebServer) handleGetFile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		WalletData         string `json:"wallet_data"`
+		Mnemonic           string `json:"mnemonic"`
+		MnemonicPassphrase string `json:"mnemonic_passphrase"`
+		SiteLabel          string `json:"site_label"`
+		FilePath           string `json:"file_path"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxCommentRequestBody)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	walletData, ok := ws.openWallet(w, r, req.WalletData, req.Mnemonic, req.MnemonicPassphrase)
+	if !ok {
+		return
+	}
+	site, exists := walletData.Sites[req.SiteLabel]
+	if !exists {
+		writeWalletError(w, http.StatusNotFound, "Site not found in wallet")
+		return
+	}
+	filePath, ok := core.CanonicalFilePath(req.FilePath)
+	if !ok || filePath == "" {
+		writeWalletError(w, http.StatusBadRequest, "Invalid file path")
+		return
+	}
+	ws.serveDraftFile(r.Context(), w, site.SiteID, filePath)
+}
+
+// handleSitePreview renders a site's working copy for the editor's preview
+// pane: GET /api/site/preview/{siteID}/{path}. Relative links resolve
+// against the same prefix, so pages load their saved styles and scripts.
+func (ws *WebServer) handleSitePreview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	siteID, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/site/preview/"), "/")
+	if len(siteID) != 64 || !isHex(siteID) {
+		http.Error(w, "Invalid site ID", http.StatusBadRequest)
+		return
+	}
+	filePath, ok := core.CanonicalFilePath(rest)
+	if !ok {
+		http.Error(w, "Invalid file path", http.StatusBadRequest)
+		return
+	}
+	if filePath == "" {
+		filePath = ws.draftMainFile(siteID)
+	}
+	// Draft pages must not reach the wallet's storage or APIs
+	w.Header().Set("Content-Security-Policy", sandboxCSP)
+	ws.serveDraftFile(r.Context(), w, siteID, filePath)
+}
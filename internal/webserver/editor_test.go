@@ -0,0 +1,89 @@
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"alxnet/internal/core"
+	"alxnet/internal/store"
+)
+
+func TestSitePreview(t *testing.T) {
+	s, err := store.NewMemory()
+	if err != nil {
+		t.Fatalf("NewMemory: %v", err)
+	}
+	defer s.Close()
+	ws := &WebServer{store: s}
+	const siteID = "aa00000000000000000000000000000000000000000000000000000000000000"
+
+	put := func(content string) string {
+		cid := core.CIDForContent([]byte(content))
+		if err := s.PutContent(cid, []byte(content)); err != nil {
+			t.Fatalf("PutContent: %v", err)
+		}
+		return cid
+	}
+
+	// Published: home.html and style.css
+	m := core.WebsiteManifest{Version: "v1", Seq: 1, TS: 1, MainFile: "home.html", Files: map[string]string{
+		"home.html": put("<h1>published</h1>"),
+		"style.css": put("h1 { color: red }"),
+	}}
+	data, err := core.MarshalCanonical(&m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PutWebsiteManifest(siteID, core.CIDForBytes(data), data); err != nil {
+		t.Fatalf("PutWebsiteManifest: %v", err)
+	}
+
+	// Saved in the editor since: a new home.html
+	rec := core.FileRecord{Version: "1", Path: "home.html", ContentCID: put("<h1>draft</h1>"), MimeType: "text/html; charset=utf-8", TS: 2}
+	recData, err := core.CanonicalMarshalFileRecord(&rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PutFileRecord(siteID, "home.html", core.CIDForBytes(recData), recData); err != nil {
+		t.Fatalf("PutFileRecord: %v", err)
+	}
+
+	// Drafts on top: a new page and the removal of style.css
+	if err := s.PutDraft(siteID, &store.Draft{Path: "about.html", ContentCID: put("<p>about</p>"), MimeType: "text/html"}); err != nil {
+		t.Fatalf("PutDraft: %v", err)
+	}
+	if err := s.PutDraft(siteID, &store.Draft{Path: "style.css", Deleted: true}); err != nil {
+		t.Fatalf("PutDraft: %v", err)
+	}
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/api/site/preview/" + siteID + "/", http.StatusOK, "<h1>draft</h1>"},
+		{"/api/site/preview/" + siteID + "/home.html", http.StatusOK, "<h1>draft</h1>"},
+		{"/api/site/preview/" + siteID + "/about.html", http.StatusOK, "<p>about</p>"},
+		{"/api/site/preview/" + siteID + "/style.css", http.StatusNotFound, ""},
+		{"/api/site/preview/" + siteID + "/missing.html", http.StatusNotFound, ""},
+		{"/api/site/preview/not-a-site/home.html", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		ws.handleSitePreview(rr, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rr.Code != tt.status {
+			t.Errorf("GET %s: status %d, want %d", tt.path, rr.Code, tt.status)
+			continue
+		}
+		if tt.status != http.StatusOK {
+			continue
+		}
+		if rr.Body.String() != tt.body {
+			t.Errorf("GET %s: body %q, want %q", tt.path, rr.Body.String(), tt.body)
+		}
+		if rr.Header().Get("Content-Security-Policy") != sandboxCSP {
+			t.Errorf("GET %s: preview served without the sandbox policy", tt.path)
+		}
+	}
+}
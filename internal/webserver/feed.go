@@ -0,0 +1,265 @@
+package webserver
+
+import (
+	"cmp"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"alxnet/internal/core"
+	"alxnet/internal/p2p"
+)
+
+// maxFeedEntries caps the entries of a site feed, newest first.
+const maxFeedEntries = 50
+
+// feedEntry is one accepted change of a site.
+type feedEntry struct {
+	ID      string
+	Title   string
+	Summary string
+	Link    string
+	Updated time.Time
+}
+
+// feed is a site feed before it is rendered as Atom or RSS.
+type feed struct {
+	ID      string
+	Title   string
+	Link    string
+	Entries []feedEntry
+}
+
+// updated is the time of the newest entry, or the Unix epoch for an empty
+// feed so that output does not change between requests.
+func (f *feed) updated() time.Time {
+	if len(f.Entries) == 0 {
+		return time.Unix(0, 0).UTC()
+	}
+	return f.Entries[0].Updated
+}
+
+// siteFeed builds the feed of siteID from the update records and website
+// manifests this node stores. link is the site's URL.
+func (ws *WebServer) siteFeed(siteID, title, link string) (*feed, error) {
+	if !ws.storesSite(siteID) {
+		return nil, fmt.Errorf("site not found")
+	}
+	f := &feed{ID: "urn:alxnet:site:" + siteID, Title: title, Link: link}
+
+	heads, err := ws.store.ListHeads(siteID)
+	if err != nil {
+		return nil, err
+	}
+	for _, head := range heads[:min(len(heads), maxFeedEntries)] {
+		data, err := ws.store.GetRecord(head.CID)
+		if err != nil || data == nil {
+			continue
+		}
+		var rec core.UpdateRecord
+		if err := core.UnmarshalCBOR(data, &rec); err != nil {
+			continue
+		}
+		f.Entries = append(f.Entries, feedEntry{
+			ID:      fmt.Sprintf("urn:alxnet:site:%s:update:%d", siteID, rec.Seq),
+			Title:   fmt.Sprintf("Update %d", rec.Seq),
+			Summary: "Content " + rec.ContentCID,
+			Link:    link,
+			Updated: time.Unix(rec.TS, 0).UTC(),
+		})
+	}
+
+	// Walk the manifest chain back from the current one, as far as it is
+	// stored here
+	data, err := ws.store.GetCurrentWebsiteManifest(siteID)
+	seen := make(map[string]bool)
+	for i := 0; err == nil && i < maxFeedEntries; i++ {
+		var m core.WebsiteManifest
+		if core.UnmarshalCBOR(data, &m) != nil {
+			break
+		}
+		f.Entries = append(f.Entries, feedEntry{
+			ID:      fmt.Sprintf("urn:alxnet:site:%s:manifest:%d", siteID, m.Seq),
+			Title:   fmt.Sprintf("Website update %d", m.Seq),
+			Summary: fmt.Sprintf("%d files, main page %s", len(m.Files), m.MainFile),
+			Link:    link,
+			Updated: time.Unix(m.TS, 0).UTC(),
+		})
+		if m.PrevCID == "" || seen[m.PrevCID] {
+			break
+		}
+		seen[m.PrevCID] = true
+		data, err = ws.store.GetWebsiteManifest(m.PrevCID)
+	}
+
+	slices.SortStableFunc(f.Entries, func(a, b feedEntry) int {
+		if c := b.Updated.Compare(a.Updated); c != 0 {
+			return c
+		}
+		return cmp.Compare(b.ID, a.ID)
+	})
+	if len(f.Entries) > maxFeedEntries {
+		f.Entries = f.Entries[:maxFeedEntries]
+	}
+	return f, nil
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	Title   string   `xml:"title"`
+	ID      string   `xml:"id"`
+	Updated string   `xml:"updated"`
+	Link    atomLink `xml:"link"`
+	Summary string   `xml:"summary"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Author  atomPerson  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+// renderAtom encodes f as an Atom 1.0 document; self is the feed's own URL.
+func renderAtom(f *feed, self string) ([]byte, error) {
+	out := atomFeed{
+		Title:   f.Title,
+		ID:      f.ID,
+		Updated: f.updated().Format(time.RFC3339),
+		Author:  atomPerson{Name: f.Title},
+		Links:   []atomLink{{Href: f.Link}, {Href: self, Rel: "self"}},
+	}
+	for _, e := range f.Entries {
+		out.Entries = append(out.Entries, atomEntry{
+			Title:   e.Title,
+			ID:      e.ID,
+			Updated: e.Updated.Format(time.RFC3339),
+			Link:    atomLink{Href: e.Link},
+			Summary: e.Summary,
+		})
+	}
+	b, err := xml.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), b...), nil
+}
+
+type rssGUID struct {
+	Value       string `xml:",chardata"`
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	Description string  `xml:"description"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+// renderRSS encodes f as an RSS 2.0 document.
+func renderRSS(f *feed) ([]byte, error) {
+	out := rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:         f.Title,
+			Link:          f.Link,
+			Description:   "Updates of " + f.Title + " on AlxNet",
+			LastBuildDate: f.updated().Format(time.RFC1123Z),
+		},
+	}
+	for _, e := range f.Entries {
+		out.Channel.Items = append(out.Channel.Items, rssItem{
+			Title:       e.Title,
+			Link:        e.Link,
+			Description: e.Summary,
+			GUID:        rssGUID{Value: e.ID},
+			PubDate:     e.Updated.Format(time.RFC1123Z),
+		})
+	}
+	b, err := xml.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), b...), nil
+}
+
+// handleAPIFeed serves a site's update history as a feed:
+// /api/feed/{siteID or siteName}?format=atom|rss, Atom by default.
+func (ws *WebServer) handleAPIFeed(w http.ResponseWriter, r *http.Request) {
+	idOrName := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/feed/"), "/")
+	siteID, title := idOrName, idOrName
+	if len(idOrName) != 64 || !isHex(idOrName) {
+		var err error
+		if siteID, err = ws.resolveSiteName(r.Context(), idOrName); err != nil {
+			http.Error(w, "Site name not found", http.StatusNotFound)
+			return
+		}
+	} else {
+		title = "Site " + p2p.Short(siteID)
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "atom"
+	}
+	if format != "atom" && format != "rss" {
+		http.Error(w, "format must be atom or rss", http.StatusBadRequest)
+		return
+	}
+
+	base := "http://" + r.Host
+	if r.TLS != nil {
+		base = "https://" + r.Host
+	}
+	f, err := ws.siteFeed(siteID, title, base+"/"+idOrName)
+	if err != nil {
+		http.Error(w, "Site not found", http.StatusNotFound)
+		return
+	}
+
+	var body []byte
+	if format == "rss" {
+		w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+		body, err = renderRSS(f)
+	} else {
+		w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+		body, err = renderAtom(f, base+r.URL.RequestURI())
+	}
+	if err != nil {
+		http.Error(w, "Failed to render feed", http.StatusInternalServerError)
+		return
+	}
+	if _, err := w.Write(body); err != nil {
+		http.Error(w, "Failed to write response", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,136 @@
+package webserver
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"alxnet/internal/core"
+	"alxnet/internal/store"
+)
+
+func TestSiteFeed(t *testing.T) {
+	s, err := store.NewMemory()
+	if err != nil {
+		t.Fatalf("NewMemory: %v", err)
+	}
+	defer s.Close()
+	ws := &WebServer{store: s}
+	const siteID = "aa00000000000000000000000000000000000000000000000000000000000000"
+
+	// Heads 1, 2 and 10 check that history sorts by seq, not key text
+	for _, seq := range []uint64{1, 2, 10} {
+		rec := core.UpdateRecord{Version: core.RecordV1, Seq: seq, ContentCID: "c0ffee", TS: int64(1000 * seq)}
+		data, err := core.MarshalCanonical(&rec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cid := core.CIDForBytes(data)
+		if err := s.PutRecord(cid, data); err != nil {
+			t.Fatalf("PutRecord: %v", err)
+		}
+		if err := s.SetHead(siteID, seq, cid); err != nil {
+			t.Fatalf("SetHead: %v", err)
+		}
+	}
+
+	// Two manifests linked by PrevCID
+	var prev string
+	for _, seq := range []uint64{1, 2} {
+		m := core.WebsiteManifest{Version: "v1", Seq: seq, PrevCID: prev, TS: int64(1500 * seq), MainFile: "<index>.html",
+			Files: map[string]string{"<index>.html": "c0ffee"}}
+		data, err := core.MarshalCanonical(&m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		prev = core.CIDForBytes(data)
+		if err := s.PutWebsiteManifest(siteID, prev, data); err != nil {
+			t.Fatalf("PutWebsiteManifest: %v", err)
+		}
+	}
+
+	f, err := ws.siteFeed(siteID, "Example & Co", "http://localhost:8080/"+siteID)
+	if err != nil {
+		t.Fatalf("siteFeed: %v", err)
+	}
+	var titles []string
+	for _, e := range f.Entries {
+		titles = append(titles, e.Title)
+	}
+	want := "Update 10,Website update 2,Update 2,Website update 1,Update 1"
+	if got := strings.Join(titles, ","); got != want {
+		t.Errorf("entries = %s, want %s", got, want)
+	}
+	if !f.updated().Equal(time.Unix(10000, 0)) {
+		t.Errorf("updated = %v, want the newest entry", f.updated())
+	}
+
+	if _, err := ws.siteFeed("bb00000000000000000000000000000000000000000000000000000000000000", "x", ""); err == nil {
+		t.Error("siteFeed succeeded for an unknown site")
+	}
+}
+
+func TestRenderFeeds(t *testing.T) {
+	f := &feed{
+		ID:    "urn:alxnet:site:aa",
+		Title: "Tom & <Jerry>",
+		Link:  "http://localhost:8080/aa",
+		Entries: []feedEntry{{
+			ID:      "urn:alxnet:site:aa:update:1",
+			Title:   "Update 1",
+			Summary: "main page <script>.html",
+			Link:    "http://localhost:8080/aa",
+			Updated: time.Unix(1700000000, 0).UTC(),
+		}},
+	}
+
+	tests := []struct {
+		name   string
+		render func() ([]byte, error)
+		parse  func([]byte) (title, entryID, summary string, err error)
+	}{
+		{
+			name:   "atom",
+			render: func() ([]byte, error) { return renderAtom(f, "http://localhost:8080/api/feed/aa") },
+			parse: func(b []byte) (string, string, string, error) {
+				var out atomFeed
+				err := xml.Unmarshal(b, &out)
+				if err != nil || len(out.Entries) != 1 {
+					return "", "", "", err
+				}
+				return out.Title, out.Entries[0].ID, out.Entries[0].Summary, nil
+			},
+		},
+		{
+			name:   "rss",
+			render: func() ([]byte, error) { return renderRSS(f) },
+			parse: func(b []byte) (string, string, string, error) {
+				var out rssFeed
+				err := xml.Unmarshal(b, &out)
+				if err != nil || len(out.Channel.Items) != 1 {
+					return "", "", "", err
+				}
+				return out.Channel.Title, out.Channel.Items[0].GUID.Value, out.Channel.Items[0].Description, nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := tt.render()
+			if err != nil {
+				t.Fatalf("render: %v", err)
+			}
+			if strings.Contains(string(b), "<script>") || strings.Contains(string(b), "<Jerry>") {
+				t.Errorf("markup not escaped:\n%s", b)
+			}
+			title, id, summary, err := tt.parse(b)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if title != f.Title || id != f.Entries[0].ID || summary != f.Entries[0].Summary {
+				t.Errorf("round trip = %q, %q, %q", title, id, summary)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package webserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"alxnet/internal/store"
+)
+
+// Notification feed limits
+const (
+	defaultNotificationLimit = 50
+	maxNotificationLimit     = 100
+	maxFollowRequestBody     = 4 * 1024
+)
+
+// handleAPIFollows lists followed sites (GET) or follows a site (POST
+// {"site": id-or-name, "label": optional}). A site followed by name keeps
+// that name as its label.
+func (ws *WebServer) handleAPIFollows(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		follows, err := ws.store.ListFollows()
+		if err != nil {
+			http.Error(w, "Failed to list followed sites", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, map[string]interface{}{
+			"follows": follows,
+			"count":   len(follows),
+		})
+
+	case http.MethodPost:
+		var request struct {
+			Site  string `json:"site"`
+			Label string `json:"label"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxFollowRequestBody)).Decode(&request); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		siteID := request.Site
+		if len(siteID) != 64 || !isHex(siteID) {
+			var err error
+			if siteID, err = ws.resolveSiteName(r.Context(), request.Site); err != nil {
+				http.Error(w, "Site name not found", http.StatusNotFound)
+				return
+			}
+			if request.Label == "" {
+				request.Label = request.Site
+			}
+		}
+		f, err := ws.store.FollowSite(siteID, request.Label)
+		switch {
+		case errors.Is(err, store.ErrTooManyFollows):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, map[string]interface{}{
+			"success": true,
+			"follow":  f,
+		})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAPIUnfollow stops following a site: POST {"site_id": ...}.
+func (ws *WebServer) handleAPIUnfollow(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var request struct {
+		SiteID string `json:"site_id"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxFollowRequestBody)).Decode(&request); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if len(request.SiteID) != 64 || !isHex(request.SiteID) {
+		http.Error(w, "Invalid site ID", http.StatusBadRequest)
+		return
+	}
+	if err := ws.store.UnfollowSite(request.SiteID); err != nil {
+		http.Error(w, "Failed to unfollow site", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]interface{}{"success": true, "site_id": request.SiteID})
+}
+
+// handleAPIFollowMute mutes or unmutes a followed site's notifications:
+// POST {"site_id": ..., "muted": true|false}.
+func (ws *WebServer) handleAPIFollowMute(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var request struct {
+		SiteID string `json:"site_id"`
+		Muted  bool   `json:"muted"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxFollowRequestBody)).Decode(&request); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if len(request.SiteID) != 64 || !isHex(request.SiteID) {
+		http.Error(w, "Invalid site ID", http.StatusBadRequest)
+		return
+	}
+	if err := ws.store.SetFollowMuted(request.SiteID, request.Muted); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"success": true,
+		"site_id": request.SiteID,
+		"muted":   request.Muted,
+	})
+}
+
+// handleAPINotifications returns the newest update notifications of
+// followed sites. Query parameters: limit (1-100, default 50) and unread=1
+// to skip read ones.
+func (ws *WebServer) handleAPINotifications(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	limit := defaultNotificationLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxNotificationLimit)
+	}
+	unreadOnly := r.URL.Query().Get("unread") == "1"
+
+	notifications, unread, err := ws.store.ListNotifications(limit, unreadOnly)
+	if err != nil {
+		http.Error(w, "Failed to list notifications", http.StatusInternalServerError)
+		return
+	}
+	if notifications == nil {
+		notifications = []store.Notification{}
+	}
+	writeJSON(w, map[string]interface{}{
+		"notifications": notifications,
+		"unread":        unread,
+	})
+}
+
+// handleAPINotificationsRead marks notifications read: POST {"up_to": id}.
+func (ws *WebServer) handleAPINotificationsRead(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var request struct {
+		UpTo uint64 `json:"up_to"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxFollowRequestBody)).Decode(&request); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := ws.store.MarkNotificationsRead(request.UpTo); err != nil {
+		http.Error(w, "Failed to update notifications", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]interface{}{"success": true})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
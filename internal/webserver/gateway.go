@@ -0,0 +1,239 @@
+package webserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"alxnet/internal/core"
+	"alxnet/internal/p2p"
+	"alxnet/internal/store"
+)
+
+// A site owner names the gateways that officially mirror the site by
+// signing a GatewayAuth for each gateway's host name. The gateway's
+// operator installs it through /api/admin/gateway; the gateway then sends
+// it with the site's pages on that host and lists it at GatewayAuthPath,
+// and anyone can check it against the site ID.
+
+// GatewayAuthHeader carries the gateway authorization of the site a page
+// belongs to, when the host it was requested from has one.
+const GatewayAuthHeader = "X-AlxNet-Gateway-Auth"
+
+// GatewayAuthPath lists the authorizations the requested host holds.
+const GatewayAuthPath = "/.well-known/alxnet-gateway"
+
+// gatewayAuthJSON is how gateway authorizations are returned by the API.
+type gatewayAuthJSON struct {
+	SiteID  string    `json:"site_id"`
+	Host    string    `json:"host"`
+	Expires time.Time `json:"expires"`
+	Time    time.Time `json:"time"`
+	Proof   string    `json:"proof"` // see p2p.EncodeGatewayAuth
+	Expired bool      `json:"expired,omitempty"`
+}
+
+func toGatewayAuthJSON(auth *core.GatewayAuth, now time.Time) gatewayAuthJSON {
+	return gatewayAuthJSON{
+		SiteID:  core.SiteIDFromPub(auth.SitePub),
+		Host:    auth.Host,
+		Expires: time.Unix(auth.Expires, 0),
+		Time:    time.Unix(auth.TS, 0),
+		Proof:   p2p.EncodeGatewayAuth(auth),
+		Expired: now.Unix() >= auth.Expires,
+	}
+}
+
+// gatewayHost returns the request's host name as authorizations name it,
+// or "" if it is not a DNS name.
+func gatewayHost(r *http.Request) string {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if !core.ValidGatewayHost(host) {
+		return ""
+	}
+	return host
+}
+
+// validGatewayAuths returns the current, verified authorizations for host.
+func (ws *WebServer) validGatewayAuths(host string) []store.GatewayAuthEntry {
+	if host == "" {
+		return nil
+	}
+	entries, err := ws.store.GatewayAuths(host)
+	if err != nil {
+		return nil
+	}
+	now := time.Now()
+	valid := entries[:0]
+	for _, e := range entries {
+		if p2p.VerifyGatewayAuth(&e.Auth, now) == nil {
+			valid = append(valid, e)
+		}
+	}
+	return valid
+}
+
+// advertiseGateway sets GatewayAuthHeader if the request's host is
+// authorized to mirror siteID.
+func (ws *WebServer) advertiseGateway(w http.ResponseWriter, r *http.Request, siteID string) {
+	for _, e := range ws.validGatewayAuths(gatewayHost(r)) {
+		if e.SiteID == siteID {
+			w.Header().Set(GatewayAuthHeader, p2p.EncodeGatewayAuth(&e.Auth))
+			return
+		}
+	}
+}
+
+// handleGatewayAuths lists the sites the requested host is authorized to
+// mirror, with the signed authorizations.
+func (ws *WebServer) handleGatewayAuths(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	host := gatewayHost(r)
+	now := time.Now()
+	auths := []gatewayAuthJSON{}
+	for _, e := range ws.validGatewayAuths(host) {
+		auths = append(auths, toGatewayAuthJSON(&e.Auth, now))
+	}
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	writeJSON(w, map[string]interface{}{
+		"host":           host,
+		"authorizations": auths,
+	})
+}
+
+// handleAdminGateway lists the gateway authorizations this node holds
+// (GET), installs one (POST {"proof"}) or removes one (POST {"host",
+// "site_id", "remove": true}).
+func (ws *WebServer) handleAdminGateway(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req struct {
+			Proof  string `json:"proof"`
+			Host   string `json:"host"`
+			SiteID string `json:"site_id"`
+			Remove bool   `json:"remove"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if req.Remove {
+			found, err := ws.store.DeleteGatewayAuth(req.Host, req.SiteID)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if !found {
+				http.Error(w, "No authorization for this host and site", http.StatusNotFound)
+				return
+			}
+			break
+		}
+		auth, err := p2p.DecodeGatewayAuth(strings.TrimSpace(req.Proof))
+		if err == nil {
+			err = p2p.VerifyGatewayAuth(auth, time.Now())
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		data, err := core.MarshalCanonical(auth)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		stored, err := ws.store.PutGatewayAuth(auth, data)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !stored {
+			http.Error(w, "A newer authorization is installed for this host and site", http.StatusConflict)
+			return
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	entries, err := ws.store.GatewayAuths("")
+	if err != nil {
+		http.Error(w, "Failed to list gateway authorizations", http.StatusInternalServerError)
+		return
+	}
+	now := time.Now()
+	auths := make([]gatewayAuthJSON, 0, len(entries))
+	for _, e := range entries {
+		auths = append(auths, toGatewayAuthJSON(&e.Auth, now))
+	}
+	writeJSON(w, map[string]interface{}{
+		"success":        true,
+		"authorizations": auths,
+	})
+}
+
+// handleWalletGatewayAuth signs an authorization for a gateway host with
+// the key of one of the wallet's sites. It is returned for the owner to
+// give to the gateway's operator, not published.
+func (ws *WebServer) handleWalletGatewayAuth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		WalletData         string `json:"wallet_data"`
+		Mnemonic           string `json:"mnemonic"`
+		MnemonicPassphrase string `json:"mnemonic_passphrase"`
+		Label              string `json:"label"`
+		Host               string `json:"host"`
+		Days               int    `json:"days"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxCommentRequestBody)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	maxDays := int(core.MaxGatewayAuthDuration / (24 * time.Hour))
+	if req.Days < 1 || req.Days > maxDays {
+		writeWalletError(w, http.StatusBadRequest, fmt.Sprintf("Authorizations must be valid between 1 and %d days", maxDays))
+		return
+	}
+	host := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(req.Host)), ".")
+	if !core.ValidGatewayHost(host) {
+		writeWalletError(w, http.StatusBadRequest, "Enter the gateway's host name, such as mirror.example.org, without a port")
+		return
+	}
+	walletData, master, ok := ws.unlockWallet(w, r, req.WalletData, req.Mnemonic, req.MnemonicPassphrase)
+	if !ok {
+		return
+	}
+	defer clear(master)
+	if _, exists := walletData.Sites[req.Label]; !exists {
+		writeWalletError(w, http.StatusNotFound, "Site not found in wallet")
+		return
+	}
+	_, _, priv, err := walletData.EnsureSite(master, req.Label)
+	if err != nil {
+		http.Error(w, "Failed to get site", http.StatusInternalServerError)
+		return
+	}
+
+	auth, err := p2p.BuildGatewayAuth(priv, host, time.Now().Add(time.Duration(req.Days)*24*time.Hour))
+	if err != nil {
+		writeWalletError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"success":       true,
+		"authorization": toGatewayAuthJSON(auth, time.Now()),
+	})
+}
@@ -0,0 +1,123 @@
+package webserver
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"alxnet/internal/core"
+	"alxnet/internal/p2p"
+	"alxnet/internal/publisher"
+	"alxnet/internal/store"
+
+	"go.uber.org/zap"
+)
+
+func TestGatewayAuthorization(t *testing.T) {
+	db, err := store.NewMemory()
+	if err != nil {
+		t.Fatalf("NewMemory: %v", err)
+	}
+	defer db.Close()
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	siteID := core.SiteIDFromPub(pub)
+	p := publisher.New(db, nil, nil)
+	if _, err := p.SaveFile(priv, "index.html", []byte("<h1>mirrored</h1>"), "text/html"); err != nil {
+		t.Fatalf("SaveFile: %v", err)
+	}
+	if _, err := p.PublishWebsite(context.Background(), priv); err != nil {
+		t.Fatalf("PublishWebsite: %v", err)
+	}
+
+	ws := NewBrowserServer(db, nil, zap.NewNop(), 0)
+	ws.dns = nil // hosts in this test are not bridged
+	ws.SetAdminToken("secret")
+	h := ws.Handler()
+	adminMux := http.NewServeMux()
+	ws.registerAdmin(adminMux)
+	admin := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/admin/gateway", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		adminMux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	auth, err := p2p.BuildGatewayAuth(priv, "mirror.example.org", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("BuildGatewayAuth: %v", err)
+	}
+	proof := p2p.EncodeGatewayAuth(auth)
+	_, otherPriv, _ := ed25519.GenerateKey(rand.Reader)
+	forged := *auth
+	forged.SitePub = otherPriv.Public().(ed25519.PublicKey)
+
+	installs := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"valid", `{"proof":"` + proof + `"}`, http.StatusOK},
+		{"garbage", `{"proof":"not a proof"}`, http.StatusBadRequest},
+		{"signed by another key", `{"proof":"` + p2p.EncodeGatewayAuth(&forged) + `"}`, http.StatusBadRequest},
+		{"remove unknown", `{"host":"other.example.org","site_id":"` + siteID + `","remove":true}`, http.StatusNotFound},
+	}
+	for _, tt := range installs {
+		if rec := admin(tt.body); rec.Code != tt.want {
+			t.Errorf("%s: status = %d %q, want %d", tt.name, rec.Code, rec.Body.String(), tt.want)
+		}
+	}
+
+	pages := []struct {
+		host       string
+		advertised bool
+	}{
+		{"mirror.example.org", true},
+		{"MIRROR.example.org:8080", true},
+		{"other.example.org", false},
+		{"localhost", false},
+	}
+	for _, tt := range pages {
+		req := httptest.NewRequest(http.MethodGet, "/"+siteID+"/", nil)
+		req.Host = tt.host
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("page on %s: status = %d", tt.host, rec.Code)
+		}
+		if got := rec.Header().Get(GatewayAuthHeader) == proof; got != tt.advertised {
+			t.Errorf("page on %s: %s = %q, want advertised %v", tt.host, GatewayAuthHeader, rec.Header().Get(GatewayAuthHeader), tt.advertised)
+		}
+
+		req = httptest.NewRequest(http.MethodGet, GatewayAuthPath, nil)
+		req.Host = tt.host
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		var listed struct {
+			Authorizations []gatewayAuthJSON `json:"authorizations"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &listed); err != nil {
+			t.Fatalf("%s on %s: %v", GatewayAuthPath, tt.host, err)
+		}
+		if got := len(listed.Authorizations) == 1 && listed.Authorizations[0].SiteID == siteID; got != tt.advertised {
+			t.Errorf("%s on %s = %s, want listed %v", GatewayAuthPath, tt.host, rec.Body.String(), tt.advertised)
+		}
+	}
+
+	if rec := admin(`{"host":"mirror.example.org","site_id":"` + siteID + `","remove":true}`); rec.Code != http.StatusOK {
+		t.Fatalf("remove: status = %d %q", rec.Code, rec.Body.String())
+	}
+	req := httptest.NewRequest(http.MethodGet, "/"+siteID+"/", nil)
+	req.Host = "mirror.example.org"
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if v := rec.Header().Get(GatewayAuthHeader); v != "" {
+		t.Errorf("removed authorization still advertised: %q", v)
+	}
+}
@@ -0,0 +1,29 @@
+package webserver
+
+import "time"
+
+// `alxnet gateway` runs a browser server in front of a node with no wallet
+// or node UI, to serve sites from peers on a small VPS or a phone. Such a
+// gateway only reads, keeps more of what peers sent and for longer, and
+// lets HTTP caches in front of it keep site files.
+
+// GatewayOptions tune a read-only gateway. Zero fields keep the browser
+// server's defaults.
+type GatewayOptions struct {
+	AssetCacheBytes int           // blobs fetched from peers kept in memory
+	ManifestTTL     time.Duration // how long a remote site's manifest is reused
+	NameTTL         time.Duration // how long a name resolved by peers is reused
+	MaxAge          time.Duration // Cache-Control max-age of site files; 0 sends none
+}
+
+// SetGateway makes the server a read-only gateway tuned by opts. Call it
+// before Start.
+func (ws *WebServer) SetGateway(opts GatewayOptions) {
+	if opts.AssetCacheBytes > 0 {
+		ws.assets = newAssetCache(opts.AssetCacheBytes)
+	}
+	ws.remote.ttl = opts.ManifestTTL
+	ws.names.ttl = opts.NameTTL
+	ws.maxAge = opts.MaxAge
+	ws.SetReadOnly()
+}
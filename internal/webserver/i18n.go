@@ -0,0 +1,144 @@
+package webserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+const (
+	// defaultLanguage is the catalog every other one falls back to.
+	defaultLanguage = "en"
+
+	// langCookie remembers a language picked with ?lang=.
+	langCookie = "alxnet_lang"
+
+	// maxAcceptLanguage bounds the Accept-Language header we parse.
+	maxAcceptLanguage = 512
+)
+
+// locales holds the message catalogs in ui/locales/<tag>.json. Each
+// catalog maps message keys to text; keys missing from a translation
+// fall back to English.
+type locales struct {
+	names    []string          // language tags, English first
+	labels   map[string]string // tag -> language name from its catalog
+	catalogs map[string]map[string]string
+	matcher  language.Matcher
+}
+
+// languageOption is an entry of the language picker.
+type languageOption struct {
+	Tag      string
+	Name     string // in its own language
+	Selected bool
+}
+
+func loadLocales(files fs.FS) (*locales, error) {
+	paths, err := fs.Glob(files, "locales/*.json")
+	if err != nil {
+		return nil, err
+	}
+	raw := make(map[string]map[string]string, len(paths))
+	for _, p := range paths {
+		name := strings.TrimSuffix(path.Base(p), ".json")
+		tag, err := language.Parse(name)
+		if err != nil || tag.String() != name {
+			return nil, fmt.Errorf("ui catalog %s: file name must be a language tag such as es or pt-BR", p)
+		}
+		b, err := fs.ReadFile(files, p)
+		if err != nil {
+			return nil, err
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(b, &messages); err != nil {
+			return nil, fmt.Errorf("ui catalog %s: %w", p, err)
+		}
+		raw[name] = messages
+	}
+	base, ok := raw[defaultLanguage]
+	if !ok {
+		return nil, fmt.Errorf("ui catalog locales/%s.json is missing", defaultLanguage)
+	}
+
+	l := &locales{
+		names:    []string{defaultLanguage},
+		labels:   make(map[string]string, len(raw)),
+		catalogs: make(map[string]map[string]string, len(raw)),
+	}
+	for name, messages := range raw {
+		if name != defaultLanguage {
+			l.names = append(l.names, name)
+		}
+		l.labels[name] = messages["language.name"]
+		if l.labels[name] == "" {
+			l.labels[name] = name
+		}
+	}
+	sort.Strings(l.names[1:])
+	tags := make([]language.Tag, len(l.names))
+	for i, name := range l.names {
+		tags[i] = language.MustParse(name)
+		merged := make(map[string]string, len(base))
+		for k, v := range base {
+			merged[k] = v
+		}
+		for k, v := range raw[name] {
+			if v != "" {
+				merged[k] = v
+			}
+		}
+		l.catalogs[name] = merged
+	}
+	l.matcher = language.NewMatcher(tags)
+	return l, nil
+}
+
+// negotiate picks the language for r: ?lang=, then the cookie set by an
+// earlier ?lang=, then Accept-Language. A ?lang= naming a catalog is
+// remembered in the cookie.
+func (l *locales) negotiate(w http.ResponseWriter, r *http.Request) string {
+	if lang := r.URL.Query().Get("lang"); lang != "" {
+		if _, ok := l.catalogs[lang]; ok {
+			http.SetCookie(w, &http.Cookie{
+				Name: langCookie, Value: lang, Path: "/",
+				MaxAge: 365 * 24 * 3600, SameSite: http.SameSiteLaxMode,
+			})
+			return lang
+		}
+	}
+	if c, err := r.Cookie(langCookie); err == nil {
+		if _, ok := l.catalogs[c.Value]; ok {
+			return c.Value
+		}
+	}
+
+	header := r.Header.Get("Accept-Language")
+	if header == "" || len(header) > maxAcceptLanguage {
+		return defaultLanguage
+	}
+	prefs, _, err := language.ParseAcceptLanguage(header)
+	if err != nil || len(prefs) == 0 {
+		return defaultLanguage
+	}
+	_, i, confidence := l.matcher.Match(prefs...)
+	if confidence == language.No {
+		return defaultLanguage
+	}
+	return l.names[i]
+}
+
+// options lists the languages for the picker with lang selected.
+func (l *locales) options(lang string) []languageOption {
+	opts := make([]languageOption, len(l.names))
+	for i, name := range l.names {
+		opts[i] = languageOption{Tag: name, Name: l.labels[name], Selected: name == lang}
+	}
+	return opts
+}
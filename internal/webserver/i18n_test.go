@@ -0,0 +1,151 @@
+package webserver
+
+import (
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"go.uber.org/zap"
+)
+
+func TestNegotiateLanguage(t *testing.T) {
+	l, err := loadLocales(fstest.MapFS{
+		"locales/en.json":    {Data: []byte(`{"language.name": "English", "hello": "Hello"}`)},
+		"locales/es.json":    {Data: []byte(`{"language.name": "Español", "hello": "Hola"}`)},
+		"locales/pt-BR.json": {Data: []byte(`{"hello": ""}`)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		cookie     string
+		accept     string
+		want       string
+		wantCookie bool
+	}{
+		{name: "default", want: "en"},
+		{name: "accept-language", accept: "es-MX,es;q=0.9,en;q=0.5", want: "es"},
+		{name: "accept-language weights", accept: "en;q=0.4, pt-BR;q=0.8", want: "pt-BR"},
+		{name: "unsupported", accept: "ja", want: "en"},
+		{name: "malformed", accept: "x;;;q=", want: "en"},
+		{name: "oversized", accept: "es," + strings.Repeat("de;q=0.1,", 100), want: "en"},
+		{name: "cookie", cookie: "es", accept: "en", want: "es"},
+		{name: "unknown cookie", cookie: "<script>", accept: "es", want: "es"},
+		{name: "query", query: "es", cookie: "en", want: "es", wantCookie: true},
+		{name: "unknown query", query: "xx", accept: "es", want: "es"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/?lang="+tt.query, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept-Language", tt.accept)
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: langCookie, Value: tt.cookie})
+			}
+			rec := httptest.NewRecorder()
+			if got := l.negotiate(rec, req); got != tt.want {
+				t.Errorf("negotiate() = %q, want %q", got, tt.want)
+			}
+			if set := rec.Header().Get("Set-Cookie") != ""; set != tt.wantCookie {
+				t.Errorf("Set-Cookie sent = %v, want %v", set, tt.wantCookie)
+			}
+		})
+	}
+
+	if got := l.catalogs["pt-BR"]["hello"]; got != "Hello" {
+		t.Errorf("empty translation = %q, want the English text", got)
+	}
+	if got := l.options("es"); len(got) != 3 || got[0].Tag != "en" || !got[1].Selected || got[2].Name != "pt-BR" {
+		t.Errorf("options() = %+v", got)
+	}
+
+	for name, files := range map[string]fstest.MapFS{
+		"no English":    {"locales/es.json": {Data: []byte(`{}`)}},
+		"bad tag":       {"locales/en.json": {Data: []byte(`{}`)}, "locales/spanish.json": {Data: []byte(`{}`)}},
+		"not a catalog": {"locales/en.json": {Data: []byte(`["hello"]`)}},
+	} {
+		if _, err := loadLocales(files); err == nil {
+			t.Errorf("%s: loadLocales() succeeded", name)
+		}
+	}
+}
+
+// TestCatalogs checks the shipped catalogs against the keys the pages use.
+func TestCatalogs(t *testing.T) {
+	files, err := fs.Sub(embeddedUI, "ui")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := loadLocales(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l.names) < 2 {
+		t.Fatalf("catalogs = %v, want English and at least one translation", l.names)
+	}
+
+	used := regexp.MustCompile(`\.T "([^"]+)"|\bt\('([^']+)'`)
+	en := l.catalogs[defaultLanguage]
+	for _, page := range append([]string{"partials.html"}, uiPages...) {
+		b, err := fs.ReadFile(files, page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range used.FindAllStringSubmatch(string(b), -1) {
+			key := m[1] + m[2]
+			if _, ok := en[key]; !ok {
+				t.Errorf("%s uses %q, which is not in locales/en.json", page, key)
+			}
+		}
+	}
+
+	placeholders := regexp.MustCompile(`\{[a-z]+\}`)
+	for _, name := range l.names[1:] {
+		raw, err := fs.ReadFile(files, "locales/"+name+".json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for key, text := range l.catalogs[name] {
+			if _, ok := en[key]; !ok {
+				t.Errorf("%s: key %q is not in locales/en.json", name, key)
+			}
+			want := placeholders.FindAllString(en[key], -1)
+			got := placeholders.FindAllString(text, -1)
+			sort.Strings(want)
+			sort.Strings(got)
+			if strings.Join(got, " ") != strings.Join(want, " ") {
+				t.Errorf("%s: %q has placeholders %v, English has %v", name, key, got, want)
+			}
+		}
+		if !strings.Contains(string(raw), `"language.name"`) {
+			t.Errorf("%s: no language.name", name)
+		}
+	}
+}
+
+func TestRenderPageLanguage(t *testing.T) {
+	ws := &WebServer{logger: zap.NewNop()}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Language", "es-ES,es;q=0.9")
+	rec := httptest.NewRecorder()
+	ws.renderPage(rec, req, "node.html")
+
+	body := rec.Body.String()
+	if rec.Header().Get("Content-Language") != "es" || !strings.Contains(body, `<html lang="es"`) {
+		t.Errorf("Content-Language %q, want es", rec.Header().Get("Content-Language"))
+	}
+	for _, want := range []string{"Gestión del nodo", `"node.unknown":"Desconocido"`, `<option value="es" selected>Español</option>`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("page is missing %q", want)
+		}
+	}
+}
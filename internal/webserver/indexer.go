@@ -0,0 +1,126 @@
+package webserver
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"alxnet/internal/core"
+	"alxnet/internal/p2p"
+	"alxnet/internal/search"
+
+	peer "github.com/libp2p/go-libp2p/core/peer"
+	"go.uber.org/zap"
+)
+
+const (
+	// crawlStartDelay gives the node time to find peers before the first
+	// crawl.
+	crawlStartDelay = time.Minute
+	// crawlMaxPages bounds the sitemap pages read from one peer per crawl.
+	crawlMaxPages = 40
+	// crawlMaxFetches bounds the main pages fetched per crawl; sites left
+	// over are picked up by the next one.
+	crawlMaxFetches = 500
+)
+
+// errCrawlSkipped reports a sitemap entry that needs no fetch.
+var errCrawlSkipped = errors.New("skipped")
+
+// EnableIndexer crawls the sitemaps of cooperating peers every interval and
+// adds the main pages of the sites they list to the search index, until
+// the server stops. Sites stored here are indexed from the store as usual.
+func (ws *WebServer) EnableIndexer(interval time.Duration) {
+	if ws.search == nil || ws.node == nil {
+		return
+	}
+	go func() {
+		wait := time.NewTimer(crawlStartDelay)
+		defer wait.Stop()
+		for {
+			select {
+			case <-ws.ctx.Done():
+				return
+			case <-wait.C:
+			}
+			ws.crawl(ws.ctx)
+			wait.Reset(interval)
+		}
+	}()
+}
+
+// crawl reads the sitemap of every connected peer that serves one.
+func (ws *WebServer) crawl(ctx context.Context) {
+	fetches, indexed := 0, 0
+	fetch := func(ctx context.Context, cid string, prefer peer.ID) ([]byte, error) {
+		return ws.fetchFromPeers(ctx, cid, prefer, p2p.PriorityBackground)
+	}
+	for _, p := range ws.node.SitemapPeers() {
+		offset := 0
+		for pages := 0; pages < crawlMaxPages && fetches < crawlMaxFetches; pages++ {
+			page, err := ws.node.RequestSitemap(ctx, p, offset)
+			if err != nil {
+				ws.logger.Debug("Sitemap request failed", zap.String("peer", p.String()), zap.Error(err))
+				break
+			}
+			for _, e := range page.Entries {
+				if fetches >= crawlMaxFetches {
+					break
+				}
+				err := ws.indexEntry(ctx, p, e, fetch)
+				if errors.Is(err, errCrawlSkipped) {
+					continue
+				}
+				fetches++
+				if err != nil {
+					ws.logger.Debug("Crawled site not indexed", zap.String("site", e.SiteID), zap.Error(err))
+					continue
+				}
+				indexed++
+			}
+			offset += len(page.Entries)
+			if len(page.Entries) == 0 || offset >= page.Total || offset > p2p.MaxSitemapOffset {
+				break
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+	ws.logger.Info("Crawl finished", zap.Int("fetched", fetches), zap.Int("indexed", indexed))
+}
+
+// indexEntry fetches the main page of a site listed in the sitemap of p and
+// hands it to the search index, unless the site is stored or denied here or
+// was already crawled at this version.
+func (ws *WebServer) indexEntry(ctx context.Context, p peer.ID, e p2p.SitemapEntry,
+	fetch func(context.Context, string, peer.ID) ([]byte, error)) error {
+	if ws.store.IsDenied(e.SiteID) || ws.search.CrawledVersion(e.SiteID) == e.CID {
+		return errCrawlSkipped
+	}
+	if ws.store.HasWebsiteManifest(e.SiteID) {
+		return errCrawlSkipped
+	}
+	if _, head, err := ws.store.GetHead(e.SiteID); err == nil && head != "" {
+		return errCrawlSkipped
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, remoteFetchTimeout)
+	defer cancel()
+	content, err := fetch(ctx, e.Main, p)
+	if err != nil {
+		return err
+	}
+	if core.CIDForContent(content) != e.Main {
+		return errors.New("main page does not match its CID")
+	}
+	return ws.search.AddCrawled(search.Crawled{
+		SiteID:   e.SiteID,
+		Peer:     p.String(),
+		Version:  e.CID,
+		Updated:  e.TS,
+		MainPath: e.MainPath,
+		MimeType: e.MimeType,
+		Content:  content,
+	})
+}
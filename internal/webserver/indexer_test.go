@@ -0,0 +1,86 @@
+package webserver
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"alxnet/internal/core"
+	"alxnet/internal/p2p"
+	"alxnet/internal/search"
+	"alxnet/internal/store"
+
+	peer "github.com/libp2p/go-libp2p/core/peer"
+	"go.uber.org/zap"
+)
+
+func TestIndexEntry(t *testing.T) {
+	s, err := store.NewMemory()
+	if err != nil {
+		t.Fatalf("NewMemory: %v", err)
+	}
+	defer s.Close()
+	const (
+		stored  = "aa00000000000000000000000000000000000000000000000000000000000000"
+		denied  = "bb00000000000000000000000000000000000000000000000000000000000000"
+		remote  = "cc00000000000000000000000000000000000000000000000000000000000000"
+		version = "1100000000000000000000000000000000000000000000000000000000000000"
+		p       = peer.ID("listing-peer")
+	)
+	m := core.WebsiteManifest{Version: "v1", Seq: 1, TS: 1, MainFile: "index.html", Files: map[string]string{"index.html": version}}
+	data, err := core.MarshalCanonical(&m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PutWebsiteManifest(stored, core.CIDForBytes(data), data); err != nil {
+		t.Fatalf("PutWebsiteManifest: %v", err)
+	}
+	if _, err := s.Deny(denied, "test"); err != nil {
+		t.Fatalf("Deny: %v", err)
+	}
+	ws := &WebServer{store: s, logger: zap.NewNop(), search: search.New(s)}
+
+	page := []byte(`<title>Listed Elsewhere</title><p>Harbour tides</p>`)
+	fetches := 0
+	fetch := func(_ context.Context, cid string, from peer.ID) ([]byte, error) {
+		fetches++
+		if from != p {
+			t.Errorf("fetched from %s, want the listing peer", from)
+		}
+		if cid == core.CIDForContent(page) {
+			return page, nil
+		}
+		return []byte("not what was asked for"), nil
+	}
+	entry := func(siteID, main string) p2p.SitemapEntry {
+		return p2p.SitemapEntry{SiteID: siteID, Seq: 1, CID: version, TS: 5, Main: main, MainPath: "index.html"}
+	}
+
+	tests := []struct {
+		name    string
+		e       p2p.SitemapEntry
+		skipped bool
+		ok      bool
+		fetches int
+	}{
+		{"stored here", entry(stored, core.CIDForContent(page)), true, false, 0},
+		{"denied", entry(denied, core.CIDForContent(page)), true, false, 0},
+		{"wrong content", entry(remote, version), false, false, 1},
+		{"indexed", entry(remote, core.CIDForContent(page)), false, true, 2},
+		{"same version", entry(remote, core.CIDForContent(page)), true, false, 2},
+	}
+	for _, tt := range tests {
+		err := ws.indexEntry(context.Background(), p, tt.e, fetch)
+		if skipped := errors.Is(err, errCrawlSkipped); skipped != tt.skipped || (err == nil) != tt.ok {
+			t.Errorf("%s: indexEntry() = %v, want skipped %v, ok %v", tt.name, err, tt.skipped, tt.ok)
+		}
+		if fetches != tt.fetches {
+			t.Errorf("%s: %d fetches, want %d", tt.name, fetches, tt.fetches)
+		}
+	}
+
+	results, _, err := ws.search.Search(search.Query{Text: "harbour"})
+	if err != nil || len(results) != 1 || results[0].SiteID != remote || results[0].Peer != p.String() {
+		t.Errorf("Search(harbour) = %+v, %v", results, err)
+	}
+}
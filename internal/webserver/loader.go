@@ -0,0 +1,376 @@
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"alxnet/internal/core"
+	"alxnet/internal/p2p"
+
+	peer "github.com/libp2p/go-libp2p/core/peer"
+	"go.uber.org/zap"
+)
+
+// Site loader settings. Opening a multi-file site resolves its file list
+// once and fetches every asset in parallel, so the browser's follow-up
+// requests for CSS, scripts and images are answered from memory instead of
+// each doing its own store or network round trip.
+const (
+	loaderConcurrency = 8
+	// prefetchTimeout bounds one whole site load.
+	prefetchTimeout = 30 * time.Second
+	// prefetchInterval is how often page views may trigger a background
+	// load of the same site.
+	prefetchInterval = time.Minute
+	// fetchPeers is how many peers are asked for a manifest, file record or
+	// name not stored locally; blobs go through the node's want list.
+	fetchPeers = 3
+	// maxAssetCacheBytes caps the memory held by blobs fetched from peers.
+	// They are verified against their CID but never written to the store,
+	// so browsing a site does not pin it.
+	maxAssetCacheBytes = 32 << 20
+)
+
+// Load event types
+const (
+	LoadEventManifest = "manifest" // file list resolved; Total is known
+	LoadEventFile     = "file"     // one blob loaded or failed
+	LoadEventDone     = "done"     // all blobs attempted
+)
+
+// Where a blob came from
+const (
+	sourceLocal   = "local"
+	sourceCache   = "cache"
+	sourceNetwork = "network"
+)
+
+// LoadEvent reports site loader progress.
+type LoadEvent struct {
+	Type   string `json:"type"`
+	Path   string `json:"path,omitempty"`
+	CID    string `json:"cid,omitempty"`
+	Source string `json:"source,omitempty"`
+	Bytes  int    `json:"bytes,omitempty"`
+	Error  string `json:"error,omitempty"`
+	Loaded int    `json:"loaded"`
+	Failed int    `json:"failed"`
+	Total  int    `json:"total"`
+}
+
+// assetCache keeps blobs fetched from peers in memory, evicting the oldest
+// once maxBytes is exceeded.
+type assetCache struct {
+	mu       sync.Mutex
+	maxBytes int
+	size     int
+	blobs    map[string][]byte
+	order    []string
+}
+
+func newAssetCache(maxBytes int) *assetCache {
+	return &assetCache{maxBytes: maxBytes, blobs: make(map[string][]byte)}
+}
+
+func (c *assetCache) get(cid string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, ok := c.blobs[cid]
+	return b, ok
+}
+
+// add caches data unless it would take more than a quarter of the cache.
+func (c *assetCache) add(cid string, data []byte) {
+	if len(data) > c.maxBytes/4 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.blobs[cid]; ok {
+		return
+	}
+	for c.size+len(data) > c.maxBytes && len(c.order) > 0 {
+		oldest := c.order[0]
+		c.order = c.order[1:]
+		c.size -= len(c.blobs[oldest])
+		delete(c.blobs, oldest)
+	}
+	c.blobs[cid] = data
+	c.order = append(c.order, cid)
+	c.size += len(data)
+}
+
+// siteLoader fetches blobs from the local store, then the asset cache, then
+// peers. Blobs from peers are checked against their CID before use.
+type siteLoader struct {
+	local       func(cid string) ([]byte, error)
+	remote      func(ctx context.Context, cid string) ([]byte, error)
+	cache       *assetCache
+	concurrency int
+}
+
+// fetch returns the blob for cid and where it came from.
+func (l *siteLoader) fetch(ctx context.Context, cid string) ([]byte, string, error) {
+	data, err := l.local(cid)
+	if err != nil {
+		return nil, "", err
+	}
+	if data != nil {
+		return data, sourceLocal, nil
+	}
+	if data, ok := l.cache.get(cid); ok {
+		return data, sourceCache, nil
+	}
+	if l.remote == nil {
+		return nil, "", errors.New("content not stored locally")
+	}
+	data, err = l.remote(ctx, cid)
+	if err != nil {
+		return nil, "", err
+	}
+	if core.CIDForContent(data) != cid {
+		return nil, "", fmt.Errorf("fetched content does not match %s", cid)
+	}
+	l.cache.add(cid, data)
+	return data, sourceNetwork, nil
+}
+
+// load fetches every blob in files (path -> CID) with bounded parallelism,
+// starting with the paths in first, such as a manifest's main file and
+// preload list. Paths sharing a CID are fetched once. emit is called from
+// one goroutine at a time; the final LoadEventDone is returned as well as
+// emitted.
+func (l *siteLoader) load(ctx context.Context, files map[string]string, first []string, emit func(LoadEvent)) LoadEvent {
+	// One path per CID, first ones first, then in a stable order
+	paths := make([]string, 0, len(files))
+	seen := make(map[string]bool, len(files))
+	all := make([]string, 0, len(files))
+	for path := range files {
+		all = append(all, path)
+	}
+	slices.Sort(all)
+	for _, path := range append(slices.Clone(first), all...) {
+		if cid, ok := files[path]; ok && !seen[cid] {
+			seen[cid] = true
+			paths = append(paths, path)
+		}
+	}
+
+	var mu sync.Mutex
+	progress := LoadEvent{Total: len(paths)}
+	report := func(ev LoadEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		if ev.Type == LoadEventFile {
+			if ev.Error == "" {
+				progress.Loaded++
+			} else {
+				progress.Failed++
+			}
+		}
+		ev.Loaded, ev.Failed, ev.Total = progress.Loaded, progress.Failed, progress.Total
+		emit(ev)
+	}
+	report(LoadEvent{Type: LoadEventManifest})
+
+	concurrency := l.concurrency
+	if concurrency <= 0 {
+		concurrency = loaderConcurrency
+	}
+	work := make(chan string)
+	var wg sync.WaitGroup
+	for range min(concurrency, len(paths)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range work {
+				cid := files[path]
+				ev := LoadEvent{Type: LoadEventFile, Path: path, CID: cid}
+				data, source, err := l.fetch(ctx, cid)
+				if err != nil {
+					ev.Error = err.Error()
+				} else {
+					ev.Source, ev.Bytes = source, len(data)
+				}
+				report(ev)
+			}
+		}()
+	}
+	for _, path := range paths {
+		work <- path
+	}
+	close(work)
+	wg.Wait()
+
+	report(LoadEvent{Type: LoadEventDone})
+	done := progress
+	done.Type = LoadEventDone
+	return done
+}
+
+// siteLoader returns a loader that asks prefer first when fetching from
+// peers.
+func (ws *WebServer) siteLoader(prefer peer.ID) *siteLoader {
+	return &siteLoader{
+		local: ws.store.GetContent,
+		remote: func(ctx context.Context, cid string) ([]byte, error) {
+			return ws.fetchFromPeers(ctx, cid, prefer, p2p.PriorityInteractive)
+		},
+		cache: ws.assets,
+	}
+}
+
+// fetchContent returns the blob for cid from the store, the asset cache or
+// peers.
+func (ws *WebServer) fetchContent(ctx context.Context, cid string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, remoteFetchTimeout)
+	defer cancel()
+	data, _, err := ws.siteLoader("").fetch(ctx, cid)
+	return data, err
+}
+
+// fetchFromPeers fetches cid through the node's want list at priority pri,
+// asking prefer first.
+func (ws *WebServer) fetchFromPeers(ctx context.Context, cid string, prefer peer.ID, pri p2p.Priority) ([]byte, error) {
+	if ws.node == nil {
+		return nil, p2p.ErrNoPeers
+	}
+	return ws.node.Want(ctx, cid, prefer, pri)
+}
+
+// peerCandidates returns prefer and other connected peers, up to
+// fetchPeers in total, to ask for something this node does not store.
+func (ws *WebServer) peerCandidates(prefer peer.ID) []peer.ID {
+	if ws.node == nil {
+		return nil
+	}
+	var candidates []peer.ID
+	if prefer != "" {
+		candidates = append(candidates, prefer)
+	}
+	for _, p := range ws.node.Host.Network().Peers() {
+		if len(candidates) >= fetchPeers {
+			break
+		}
+		if p != prefer {
+			candidates = append(candidates, p)
+		}
+	}
+	return candidates
+}
+
+// siteFiles returns the files to load for siteID and those to load first.
+// Sites this node does not store are loaded from the manifest peers send,
+// or from their head if they have none; the peer to ask first is returned.
+func (ws *WebServer) siteFiles(ctx context.Context, siteID string) (map[string]string, []string, peer.ID, error) {
+	if ws.storesSite(siteID) {
+		files, err := ws.siteContentCIDs(siteID)
+		return files, ws.localCriticalFiles(siteID), "", err
+	}
+	head, headErr := ws.lookupHead(ctx, siteID)
+	if m, err := ws.remoteManifest(ctx, siteID, head.Peer); err == nil {
+		return m.manifest.Files, criticalFiles(m.manifest), m.peer, nil
+	}
+	if headErr != nil {
+		return nil, nil, "", headErr
+	}
+	return map[string]string{"index.html": head.ContentCID}, nil, head.Peer, nil
+}
+
+// criticalFiles returns m's main file and preload list, the files a site
+// load fetches first.
+func criticalFiles(m *core.WebsiteManifest) []string {
+	return append([]string{m.MainFile}, m.Preload...)
+}
+
+// localCriticalFiles returns the critical files of siteID's stored
+// manifest, if it has one.
+func (ws *WebServer) localCriticalFiles(siteID string) []string {
+	data, err := ws.store.GetCurrentWebsiteManifest(siteID)
+	if err != nil {
+		return nil
+	}
+	var m core.WebsiteManifest
+	if err := core.UnmarshalCBOR(data, &m); err != nil {
+		return nil
+	}
+	return criticalFiles(&m)
+}
+
+// loadSite resolves siteID's files once and fetches all of them, critical
+// files first.
+func (ws *WebServer) loadSite(ctx context.Context, siteID string, emit func(LoadEvent)) (LoadEvent, error) {
+	files, first, prefer, err := ws.siteFiles(ctx, siteID)
+	if err != nil {
+		return LoadEvent{}, err
+	}
+	return ws.siteLoader(prefer).load(ctx, files, first, emit), nil
+}
+
+// prefetchSite loads siteID in the background, at most once per
+// prefetchInterval, so the assets a page references are ready when the
+// browser asks for them.
+func (ws *WebServer) prefetchSite(siteID string) {
+	if last, ok := ws.prefetched.Load(siteID); ok && time.Since(last.(time.Time)) < prefetchInterval {
+		return
+	}
+	ws.prefetched.Store(siteID, time.Now())
+	go func() {
+		ctx, cancel := context.WithTimeout(ws.ctx, prefetchTimeout)
+		defer cancel()
+		done, err := ws.loadSite(ctx, siteID, func(LoadEvent) {})
+		if err != nil {
+			ws.logger.Debug("site prefetch failed", zap.String("site_id", siteID), zap.Error(err))
+			return
+		}
+		ws.logger.Debug("site prefetched",
+			zap.String("site_id", siteID),
+			zap.Int("loaded", done.Loaded),
+			zap.Int("failed", done.Failed),
+			zap.Int("total", done.Total))
+	}()
+}
+
+// handleAPILoad loads a site and streams progress as server-sent events,
+// one JSON LoadEvent per message. The stream ends after the done event, or
+// with an "error" event when the site cannot be resolved.
+func (ws *WebServer) handleAPILoad(w http.ResponseWriter, r *http.Request) {
+	idOrName := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/load/"), "/")
+	siteID := idOrName
+	if len(idOrName) != 64 || !isHex(idOrName) {
+		var err error
+		if siteID, err = ws.resolveSiteName(r.Context(), idOrName); err != nil {
+			http.Error(w, "Site name not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	rc := http.NewResponseController(w)
+	// The server's write timeout is too short for a slow load
+	_ = rc.SetWriteDeadline(time.Now().Add(prefetchTimeout + 5*time.Second))
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	send := func(ev LoadEvent) {
+		b, _ := json.Marshal(ev)
+		fmt.Fprintf(w, "data: %s\n\n", b)
+		_ = rc.Flush()
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), prefetchTimeout)
+	defer cancel()
+	if _, err := ws.loadSite(ctx, siteID, send); err != nil {
+		msg := "site unreachable"
+		if errors.Is(err, p2p.ErrHeadNotFound) {
+			msg = "site not found"
+		}
+		send(LoadEvent{Type: "error", Error: msg})
+	}
+}
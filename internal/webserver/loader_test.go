@@ -0,0 +1,165 @@
+package webserver
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"alxnet/internal/core"
+)
+
+func TestSiteLoaderLoad(t *testing.T) {
+	css := []byte("body { color: red }")
+	js := []byte("console.log(1)")
+	page := []byte("<html></html>")
+	local := map[string][]byte{core.CIDForContent(page): page}
+	remote := map[string][]byte{
+		core.CIDForContent(css): css,
+		core.CIDForContent(js):  []byte("tampered"),
+	}
+
+	var remoteCalls atomic.Int32
+	l := &siteLoader{
+		local: func(cid string) ([]byte, error) { return local[cid], nil },
+		remote: func(ctx context.Context, cid string) ([]byte, error) {
+			remoteCalls.Add(1)
+			if b, ok := remote[cid]; ok {
+				return b, nil
+			}
+			return nil, errors.New("not found")
+		},
+		cache:       newAssetCache(1 << 20),
+		concurrency: 2,
+	}
+
+	files := map[string]string{
+		"index.html":     core.CIDForContent(page),
+		"style.css":      core.CIDForContent(css),
+		"copy/style.css": core.CIDForContent(css), // same blob, fetched once
+		"app.js":         core.CIDForContent(js),
+		"missing.png":    core.CIDForContent([]byte("png")),
+	}
+
+	var mu sync.Mutex
+	var events []LoadEvent
+	done := l.load(context.Background(), files, nil, func(ev LoadEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, ev)
+	})
+
+	if done.Type != LoadEventDone || done.Total != 4 || done.Loaded != 2 || done.Failed != 2 {
+		t.Fatalf("done = %+v, want 4 total, 2 loaded, 2 failed", done)
+	}
+	if got := remoteCalls.Load(); got != 3 {
+		t.Errorf("remote fetches = %d, want 3", got)
+	}
+	if len(events) != 6 || events[0].Type != LoadEventManifest || events[5].Type != LoadEventDone {
+		t.Fatalf("events = %+v, want manifest, 4 files, done", events)
+	}
+
+	sources := map[string]string{}
+	for _, ev := range events[1:5] {
+		if ev.Type != LoadEventFile {
+			t.Fatalf("event %+v, want a file event", ev)
+		}
+		sources[ev.Path] = ev.Source
+		if ev.Source == "" && ev.Error == "" {
+			t.Errorf("event %+v has neither source nor error", ev)
+		}
+	}
+	if sources["index.html"] != sourceLocal || sources["copy/style.css"] != sourceNetwork {
+		t.Errorf("sources = %v", sources)
+	}
+	if sources["app.js"] != "" {
+		t.Errorf("tampered app.js was accepted from %q", sources["app.js"])
+	}
+
+	// A second load is served from the asset cache
+	remoteCalls.Store(0)
+	_, source, err := l.fetch(context.Background(), core.CIDForContent(css))
+	if err != nil || source != sourceCache {
+		t.Errorf("second fetch: source %q, err %v; want cache", source, err)
+	}
+	if remoteCalls.Load() != 0 {
+		t.Errorf("cached blob was fetched again")
+	}
+	if _, ok := l.cache.get(core.CIDForContent(js)); ok {
+		t.Errorf("tampered blob was cached")
+	}
+}
+
+func TestSiteLoaderCriticalFirst(t *testing.T) {
+	l := &siteLoader{
+		local: func(string) ([]byte, error) { return []byte("x"), nil },
+		cache: newAssetCache(1 << 20),
+		// One worker, so files load in order
+		concurrency: 1,
+	}
+	files := map[string]string{}
+	for _, path := range []string{"a.png", "b.png", "index.html", "site.css", "z.js"} {
+		files[path] = core.CIDForContent([]byte(path))
+	}
+
+	var order []string
+	l.load(context.Background(), files, []string{"index.html", "z.js", "gone.js"}, func(ev LoadEvent) {
+		if ev.Type == LoadEventFile {
+			order = append(order, ev.Path)
+		}
+	})
+	want := []string{"index.html", "z.js", "a.png", "b.png", "site.css"}
+	if !slices.Equal(order, want) {
+		t.Errorf("load order = %v, want %v", order, want)
+	}
+}
+
+func TestSiteLoaderEmpty(t *testing.T) {
+	l := &siteLoader{
+		local: func(string) ([]byte, error) { return nil, nil },
+		cache: newAssetCache(1 << 20),
+	}
+	done := l.load(context.Background(), nil, nil, func(LoadEvent) {})
+	if done.Total != 0 || done.Loaded != 0 || done.Failed != 0 {
+		t.Errorf("done = %+v, want all zero", done)
+	}
+	if _, _, err := l.fetch(context.Background(), "cid"); err == nil {
+		t.Errorf("fetch without remote succeeded for a missing blob")
+	}
+}
+
+func TestAssetCacheEviction(t *testing.T) {
+	tests := []struct {
+		name    string
+		adds    []int // blob sizes, added as "0", "1", ...
+		present []string
+		absent  []string
+	}{
+		{"fits", []int{10, 10}, []string{"0", "1"}, nil},
+		{"evicts oldest", []int{20, 20, 20, 20, 20, 20}, []string{"1", "2", "3", "4", "5"}, []string{"0"}},
+		{"too large to cache", []int{30}, nil, []string{"0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newAssetCache(100)
+			for i, size := range tt.adds {
+				c.add(string(rune('0'+i)), make([]byte, size))
+			}
+			for _, cid := range tt.present {
+				if _, ok := c.get(cid); !ok {
+					t.Errorf("%s evicted", cid)
+				}
+			}
+			for _, cid := range tt.absent {
+				if _, ok := c.get(cid); ok {
+					t.Errorf("%s still cached", cid)
+				}
+			}
+			if c.size > c.maxBytes {
+				t.Errorf("size %d over limit %d", c.size, c.maxBytes)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package webserver
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"alxnet/internal/logging"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Log stream limits
+const (
+	// defaultLogBacklog and maxLogBacklog bound how many recent entries a
+	// new /api/logs/stream connection is sent first.
+	defaultLogBacklog = 100
+	maxLogBacklog     = 1000
+	// logStreamBuffer is how many entries a slow viewer may fall behind
+	// before entries are dropped for it.
+	logStreamBuffer = 256
+	// maxLogModule bounds the module filter.
+	maxLogModule = 64
+	// logPingInterval keeps idle connections through proxies.
+	logPingInterval = 30 * time.Second
+	// logWriteTimeout drops viewers that stop reading.
+	logWriteTimeout = 10 * time.Second
+)
+
+// SetLogStream lets /api/logs/stream follow the node's log.
+func (ws *WebServer) SetLogStream(stream *logging.Stream) {
+	ws.logs = stream
+}
+
+// logUpgrader accepts WebSocket connections from the node UI's own origin
+// only, so other sites a browser visits cannot read the log.
+var logUpgrader = websocket.Upgrader{ReadBufferSize: 1024, WriteBufferSize: 4096}
+
+// parseLogFilter reads the level, module and backlog parameters of a log
+// stream request.
+func parseLogFilter(r *http.Request) (logging.Filter, int, error) {
+	q := r.URL.Query()
+	f := logging.Filter{Level: zapcore.InfoLevel, Module: q.Get("module")}
+	if s := q.Get("level"); s != "" {
+		level, err := zapcore.ParseLevel(s)
+		if err != nil {
+			return f, 0, errors.New("level must be debug, info, warn, error, dpanic, panic or fatal")
+		}
+		f.Level = level
+	}
+	if len(f.Module) > maxLogModule {
+		return f, 0, errors.New("module too long")
+	}
+	for _, c := range f.Module {
+		if !((c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '.' || c == '-' || c == '_') {
+			return f, 0, errors.New("module must be a logger name such as p2p or p2p.audit")
+		}
+	}
+	backlog := defaultLogBacklog
+	if s := q.Get("backlog"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 || n > maxLogBacklog {
+			return f, 0, errors.New("backlog must be between 0 and " + strconv.Itoa(maxLogBacklog))
+		}
+		backlog = n
+	}
+	return f, backlog, nil
+}
+
+// handleLogStream upgrades to a WebSocket and sends the newest backlog log
+// entries matching ?level= (default info) and ?module=, then each new one,
+// as JSON messages. When the viewer falls behind, dropped entries are
+// reported in a warning entry from the "logs" logger.
+func (ws *WebServer) handleLogStream(w http.ResponseWriter, r *http.Request) {
+	if ws.logs == nil {
+		http.Error(w, "Log stream not available", http.StatusNotFound)
+		return
+	}
+	filter, backlog, err := parseLogFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	conn, err := logUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // the upgrader has answered
+	}
+	defer conn.Close()
+
+	// Subscribe before reading the backlog so nothing falls in between
+	sub := ws.logs.Subscribe(filter, logStreamBuffer)
+	defer sub.Close()
+
+	// The viewer sends nothing; reading notices when it goes away
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	send := func(e logging.Entry) error {
+		if err := conn.SetWriteDeadline(time.Now().Add(logWriteTimeout)); err != nil {
+			return err
+		}
+		return conn.WriteJSON(e)
+	}
+	var last uint64
+	sent := false
+	for _, e := range ws.logs.Recent(backlog, filter) {
+		if err := send(e); err != nil {
+			return
+		}
+		last, sent = e.Seq, true
+	}
+
+	ping := time.NewTicker(logPingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case <-closed:
+			return
+		case <-ws.ctx.Done():
+			return
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(logWriteTimeout)); err != nil {
+				return
+			}
+		case e := <-sub.C():
+			if sent && e.Seq <= last {
+				continue // already sent with the backlog
+			}
+			if n := sub.Dropped(); n > 0 {
+				notice := logging.Entry{
+					Time:    time.Now(),
+					Level:   zapcore.WarnLevel.String(),
+					Logger:  "logs",
+					Message: "viewer fell behind; entries dropped",
+					Fields:  map[string]interface{}{"dropped": n},
+				}
+				if err := send(notice); err != nil {
+					return
+				}
+			}
+			if err := send(e); err != nil {
+				ws.logger.Debug("log stream closed", zap.Error(err))
+				return
+			}
+			last, sent = e.Seq, true
+		}
+	}
+}
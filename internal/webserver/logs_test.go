@@ -0,0 +1,69 @@
+package webserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"alxnet/internal/logging"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+func TestLogStream(t *testing.T) {
+	stream := logging.NewStream(100)
+	logger := zap.New(stream.Core(zap.DebugLevel))
+	logger.Named("p2p").Info("old p2p entry")
+	logger.Named("wallet").Info("old wallet entry")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ws := &WebServer{ctx: ctx, logger: zap.NewNop()}
+	ws.SetLogStream(stream)
+	srv := httptest.NewServer(http.HandlerFunc(ws.handleLogStream))
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	rejected := []struct {
+		name   string
+		query  string
+		header http.Header
+		status int
+	}{
+		{"unknown level", "?level=loud", nil, http.StatusBadRequest},
+		{"bad module", "?module=p2p%20x", nil, http.StatusBadRequest},
+		{"backlog too large", "?backlog=1001", nil, http.StatusBadRequest},
+		{"other origin", "", http.Header{"Origin": {"http://evil.example"}}, http.StatusForbidden},
+	}
+	for _, tt := range rejected {
+		_, resp, err := websocket.DefaultDialer.Dial(url+tt.query, tt.header)
+		if err == nil || resp == nil || resp.StatusCode != tt.status {
+			t.Errorf("%s: err = %v, response %v, want status %d", tt.name, err, resp, tt.status)
+		}
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(url+"?module=p2p&level=info", nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+	if err := conn.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	var e logging.Entry
+	if err := conn.ReadJSON(&e); err != nil || e.Message != "old p2p entry" {
+		t.Fatalf("backlog entry = %+v, %v", e, err)
+	}
+
+	// Entries logged after connecting follow, filtered
+	logger.Named("p2p").Debug("too detailed")
+	logger.Named("wallet").Warn("other module")
+	logger.Named("p2p").Warn("new p2p entry", zap.Int("peers", 3))
+	if err := conn.ReadJSON(&e); err != nil || e.Message != "new p2p entry" || e.Fields["peers"] != float64(3) {
+		t.Fatalf("live entry = %+v, %v", e, err)
+	}
+}
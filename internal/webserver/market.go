@@ -0,0 +1,226 @@
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"alxnet/internal/core"
+	"alxnet/internal/p2p"
+)
+
+// maxOfferSites bounds the site IDs one offer listing filters by.
+const maxOfferSites = 256
+
+// domainOfferJSON is how domain offers are returned by the API.
+type domainOfferJSON struct {
+	CID     string    `json:"cid"`
+	Domain  string    `json:"domain"`
+	Seller  string    `json:"seller"` // site ID holding the name
+	Buyer   string    `json:"buyer"`
+	Note    string    `json:"note,omitempty"`
+	Expires time.Time `json:"expires"`
+	Time    time.Time `json:"time"`
+}
+
+func toDomainOfferJSON(cid string, rec *core.DomainOffer) domainOfferJSON {
+	return domainOfferJSON{
+		CID:     cid,
+		Domain:  rec.Domain,
+		Seller:  core.SiteIDFromPub(rec.SellerPub),
+		Buyer:   rec.Buyer,
+		Note:    rec.Note,
+		Expires: time.Unix(rec.Expires, 0).UTC(),
+		Time:    time.Unix(rec.TS, 0).UTC(),
+	}
+}
+
+// handleDomainOffers lists the open offers to and from the given sites:
+// POST /api/domains/offers {"site_ids": [...]}.
+func (ws *WebServer) handleDomainOffers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		SiteIDs []string `json:"site_ids"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxCommentRequestBody)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if len(req.SiteIDs) > maxOfferSites {
+		http.Error(w, "Too many site IDs", http.StatusBadRequest)
+		return
+	}
+	mine := make(map[string]bool, len(req.SiteIDs))
+	for _, id := range req.SiteIDs {
+		mine[id] = true
+	}
+
+	entries, err := ws.store.ListDomainOffers(time.Now())
+	if err != nil {
+		http.Error(w, "Failed to list offers", http.StatusInternalServerError)
+		return
+	}
+	incoming, outgoing := []domainOfferJSON{}, []domainOfferJSON{}
+	for i := range entries {
+		o := toDomainOfferJSON(entries[i].CID, &entries[i].Offer)
+		if mine[o.Buyer] {
+			incoming = append(incoming, o)
+		}
+		if mine[o.Seller] {
+			outgoing = append(outgoing, o)
+		}
+	}
+	for _, list := range [][]domainOfferJSON{incoming, outgoing} {
+		sort.Slice(list, func(i, j int) bool { return list[i].Time.After(list[j].Time) })
+	}
+	writeJSON(w, map[string]interface{}{
+		"success":  true,
+		"incoming": incoming,
+		"outgoing": outgoing,
+	})
+}
+
+// handleWalletDomainOffer offers a name registered to one of the wallet's
+// sites to another site, signed with the wallet site's key, and publishes
+// the offer.
+func (ws *WebServer) handleWalletDomainOffer(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		WalletData         string `json:"wallet_data"`
+		Mnemonic           string `json:"mnemonic"`
+		MnemonicPassphrase string `json:"mnemonic_passphrase"`
+		Label              string `json:"label"`
+		Domain             string `json:"domain"`
+		Buyer              string `json:"buyer"` // site ID
+		Note               string `json:"note"`
+		Days               int    `json:"days"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxCommentRequestBody)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	maxDays := int(core.MaxDomainOfferDuration / (24 * time.Hour))
+	if req.Days < 1 || req.Days > maxDays {
+		writeWalletError(w, http.StatusBadRequest, fmt.Sprintf("Offers must stay open between 1 and %d days", maxDays))
+		return
+	}
+	walletData, master, ok := ws.unlockWallet(w, r, req.WalletData, req.Mnemonic, req.MnemonicPassphrase)
+	if !ok {
+		return
+	}
+	defer clear(master)
+	if _, exists := walletData.Sites[req.Label]; !exists {
+		writeWalletError(w, http.StatusNotFound, "Site not found in wallet")
+		return
+	}
+	meta, _, priv, err := walletData.EnsureSite(master, req.Label)
+	if err != nil {
+		http.Error(w, "Failed to get site", http.StatusInternalServerError)
+		return
+	}
+	if owner, err := ws.store.ResolveDomain(req.Domain); err != nil || owner != meta.SiteID {
+		writeWalletError(w, http.StatusBadRequest, "This site name is not registered to the selected site")
+		return
+	}
+
+	expires := time.Now().Add(time.Duration(req.Days) * 24 * time.Hour)
+	rec, err := p2p.BuildDomainOffer(priv, req.Domain, strings.ToLower(req.Buyer), req.Note, expires)
+	if err != nil {
+		writeWalletError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	cid, err := ws.node.PublishDomainOffer(ctx, rec)
+	if err != nil {
+		writeWalletError(w, http.StatusBadGateway, "Failed to publish offer: "+err.Error())
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"success": true,
+		"offer":   toDomainOfferJSON(cid, rec),
+	})
+}
+
+// handleWalletDomainAccept accepts an offer made to one of the wallet's
+// sites, signed with that site's key, and publishes the acceptance.
+func (ws *WebServer) handleWalletDomainAccept(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		WalletData         string `json:"wallet_data"`
+		Mnemonic           string `json:"mnemonic"`
+		MnemonicPassphrase string `json:"mnemonic_passphrase"`
+		Label              string `json:"label"`
+		Domain             string `json:"domain"`
+		OfferCID           string `json:"offer_cid"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxCommentRequestBody)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if !core.ValidSiteName(req.Domain) || len(req.OfferCID) != 64 || !isHex(req.OfferCID) {
+		writeWalletError(w, http.StatusBadRequest, "Invalid offer")
+		return
+	}
+	offer, _, err := ws.store.GetDomainOffer(req.Domain, req.OfferCID)
+	if err != nil {
+		http.Error(w, "Failed to read offer", http.StatusInternalServerError)
+		return
+	}
+	if offer == nil {
+		writeWalletError(w, http.StatusNotFound, "Offer not found or no longer open")
+		return
+	}
+	walletData, master, ok := ws.unlockWallet(w, r, req.WalletData, req.Mnemonic, req.MnemonicPassphrase)
+	if !ok {
+		return
+	}
+	defer clear(master)
+	if _, exists := walletData.Sites[req.Label]; !exists {
+		writeWalletError(w, http.StatusNotFound, "Site not found in wallet")
+		return
+	}
+	meta, _, priv, err := walletData.EnsureSite(master, req.Label)
+	if err != nil {
+		http.Error(w, "Failed to get site", http.StatusInternalServerError)
+		return
+	}
+	if meta.SiteID != offer.Buyer {
+		writeWalletError(w, http.StatusForbidden, "This offer is made to another site")
+		return
+	}
+
+	accept, err := p2p.BuildDomainAccept(priv, req.OfferCID)
+	if err != nil {
+		writeWalletError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	moved, err := ws.node.PublishDomainAccept(ctx, offer, accept)
+	if err != nil {
+		writeWalletError(w, http.StatusBadGateway, "Failed to publish acceptance: "+err.Error())
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"success": true,
+		"domain":  offer.Domain,
+		"site_id": meta.SiteID,
+		// Whether the name moved on this node; others apply it as the
+		// acceptance reaches them
+		"transferred": moved,
+	})
+}
@@ -0,0 +1,89 @@
+package webserver
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"alxnet/internal/store"
+)
+
+// defaultMetricsRange is the history /api/metrics/history returns without
+// a range parameter.
+const defaultMetricsRange = 24 * time.Hour
+
+// parseMetricsRange parses a Go duration or a number of days such as "7d",
+// between a minute and the longest history kept.
+func parseMetricsRange(s string) (time.Duration, error) {
+	if s == "" {
+		return defaultMetricsRange, nil
+	}
+	var d time.Duration
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid range %q", s)
+		}
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		if d, err = time.ParseDuration(s); err != nil {
+			return 0, fmt.Errorf("invalid range %q", s)
+		}
+	}
+	longest := store.MetricResolutions[len(store.MetricResolutions)-1].Keep
+	if d < time.Minute || d > longest {
+		return 0, fmt.Errorf("range must be between 1m and %dd", longest/(24*time.Hour))
+	}
+	return d, nil
+}
+
+// metricsResolution returns the finest resolution that keeps span.
+func metricsResolution(span time.Duration) store.MetricResolution {
+	for _, res := range store.MetricResolutions {
+		if span <= res.Keep {
+			return res
+		}
+	}
+	return store.MetricResolutions[len(store.MetricResolutions)-1]
+}
+
+// handleMetricsHistory returns the node's sampled activity over
+// ?range= (default 24h), at the finest resolution kept that long.
+func (ws *WebServer) handleMetricsHistory(w http.ResponseWriter, r *http.Request) {
+	ws.serveMetricsHistory(w, r, time.Now())
+}
+
+// serveMetricsHistory answers a history request for the range ending now.
+func (ws *WebServer) serveMetricsHistory(w http.ResponseWriter, r *http.Request, now time.Time) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	span, err := parseMetricsRange(r.URL.Query().Get("range"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	res := metricsResolution(span)
+	samples, err := ws.store.MetricHistory(res, now.Add(-span))
+	if err != nil {
+		http.Error(w, "Failed to read metrics history", http.StatusInternalServerError)
+		return
+	}
+	if samples == nil {
+		samples = []store.MetricSample{}
+	}
+	resp := map[string]interface{}{
+		"success":      true,
+		"resolution":   res.Name,
+		"step_seconds": int64(res.Step / time.Second),
+		"samples":      samples,
+	}
+	if ws.node != nil {
+		resp["uptime_seconds"] = int64(ws.node.Uptime() / time.Second)
+	}
+	writeJSON(w, resp)
+}
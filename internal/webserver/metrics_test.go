@@ -0,0 +1,63 @@
+package webserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"alxnet/internal/store"
+)
+
+func TestMetricsHistory(t *testing.T) {
+	db, err := store.NewMemory()
+	if err != nil {
+		t.Fatalf("NewMemory: %v", err)
+	}
+	defer db.Close()
+	// Noon UTC keeps the two recent samples in the same day
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	for _, ago := range []time.Duration{30 * time.Minute, 3 * time.Hour, 10 * 24 * time.Hour} {
+		if err := db.PutMetricSample(store.MetricSample{Time: now.Add(-ago).Unix(), Peers: 1, Updates: 1}); err != nil {
+			t.Fatalf("PutMetricSample: %v", err)
+		}
+	}
+	ws := &WebServer{store: db}
+
+	tests := []struct {
+		query      string
+		status     int
+		resolution string
+		samples    int
+	}{
+		{"", http.StatusOK, "minute", 2},
+		{"?range=1h", http.StatusOK, "minute", 1},
+		{"?range=7d", http.StatusOK, "hour", 2},
+		{"?range=365d", http.StatusOK, "day", 2},
+		{"?range=30s", http.StatusBadRequest, "", 0},
+		{"?range=366d", http.StatusBadRequest, "", 0},
+		{"?range=week", http.StatusBadRequest, "", 0},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		ws.serveMetricsHistory(rec, httptest.NewRequest(http.MethodGet, "/api/metrics/history"+tt.query, nil), now)
+		if rec.Code != tt.status {
+			t.Errorf("%q: status = %d %q, want %d", tt.query, rec.Code, rec.Body.String(), tt.status)
+			continue
+		}
+		if tt.status != http.StatusOK {
+			continue
+		}
+		var resp struct {
+			Resolution string               `json:"resolution"`
+			Samples    []store.MetricSample `json:"samples"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%q: %v", tt.query, err)
+		}
+		if resp.Resolution != tt.resolution || len(resp.Samples) != tt.samples {
+			t.Errorf("%q: %s with %d samples, want %s with %d", tt.query, resp.Resolution, len(resp.Samples), tt.resolution, tt.samples)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package webserver
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"alxnet/internal/dnslink"
+)
+
+// Site names resolve through a pipeline: the local registry, DNS TXT
+// records for DNS names, the connected peers and, when none of those know
+// the name, peers found by dialing the bootstrap list and peers this node
+// has seen before. Names registered on other nodes are unsigned claims, so
+// a name is only taken from peers when all of them that know it agree, and
+// it is cached in memory rather than registered locally.
+const (
+	// namePeerTimeout bounds asking the connected peers.
+	namePeerTimeout = 3 * time.Second
+	// nameDiscoveryTimeout bounds dialing new peers and asking them.
+	nameDiscoveryTimeout = 5 * time.Second
+	// discoverPeers is how many new peers a lookup dials at most.
+	discoverPeers = 8
+	// nameTTL is how long a name resolved by peers is used, and
+	// nameMissTTL how long a name no peer knows is remembered as unknown.
+	nameTTL     = 5 * time.Minute
+	nameMissTTL = 30 * time.Second
+	// maxCachedNames caps the names kept from peers.
+	maxCachedNames = 1024
+)
+
+// Where a site name was resolved.
+const (
+	nameSourceLocal = "local"
+	nameSourceDNS   = "dns"
+	nameSourcePeers = "peers"
+)
+
+var errNameNotFound = errors.New("site name not found")
+
+// cachedName is a name resolved by peers; siteID is empty for a name none
+// of them knew.
+type cachedName struct {
+	siteID  string
+	expires time.Time
+}
+
+// nameCache caches site names resolved by peers.
+type nameCache struct {
+	mu    sync.Mutex
+	names map[string]cachedName
+	ttl   time.Duration // for names peers knew; nameTTL if zero
+}
+
+func (c *nameCache) get(name string, now time.Time) (cachedName, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n, ok := c.names[name]
+	if !ok || now.After(n.expires) {
+		return cachedName{}, false
+	}
+	return n, true
+}
+
+func (c *nameCache) put(name, siteID string, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.names == nil {
+		c.names = make(map[string]cachedName)
+	}
+	if _, ok := c.names[name]; !ok && len(c.names) >= maxCachedNames {
+		for k, old := range c.names {
+			if now.After(old.expires) {
+				delete(c.names, k)
+			}
+		}
+		for k := range c.names {
+			if len(c.names) < maxCachedNames {
+				break
+			}
+			delete(c.names, k)
+		}
+	}
+	ttl := c.ttl
+	if ttl == 0 {
+		ttl = nameTTL
+	}
+	if siteID == "" {
+		ttl = nameMissTTL
+	}
+	c.names[name] = cachedName{siteID: siteID, expires: now.Add(ttl)}
+}
+
+// resolveSiteName resolves a registered name (with or without the .bn
+// suffix) or a DNS name through its _alxnet TXT record.
+func (ws *WebServer) resolveSiteName(ctx context.Context, name string) (string, error) {
+	siteID, _, err := ws.resolveName(ctx, name)
+	return siteID, err
+}
+
+// resolveName resolves name through the pipeline and reports where it was
+// found.
+func (ws *WebServer) resolveName(ctx context.Context, name string) (string, string, error) {
+	siteName := strings.TrimSuffix(name, ".bn")
+	if siteID, err := ws.store.ResolveDomain(siteName); err == nil {
+		return siteID, nameSourceLocal, nil
+	}
+	if dnslink.IsDomain(name) {
+		if ws.dns == nil {
+			return "", "", errNameNotFound
+		}
+		siteID, err := ws.dns.Resolve(ctx, name)
+		return siteID, nameSourceDNS, err
+	}
+	if ws.node == nil || ws.store.ValidateSiteName(siteName) != nil {
+		return "", "", errNameNotFound
+	}
+
+	if n, ok := ws.names.get(siteName, time.Now()); ok {
+		if n.siteID == "" {
+			return "", "", errNameNotFound
+		}
+		return n.siteID, nameSourcePeers, nil
+	}
+
+	peerCtx, cancel := context.WithTimeout(ctx, namePeerTimeout)
+	peers := ws.peerCandidates("")
+	siteID, complete, err := ws.node.AskPeersName(peerCtx, peers, siteName)
+	cancel()
+	asked := len(peers)
+	if siteID == "" && err == nil {
+		// Nobody connected knows the name, as on a node that just started
+		discoverCtx, cancel := context.WithTimeout(ctx, nameDiscoveryTimeout)
+		found := ws.node.DiscoverPeers(discoverCtx, discoverPeers)
+		var more bool
+		siteID, more, err = ws.node.AskPeersName(discoverCtx, found, siteName)
+		complete = complete && more
+		asked += len(found)
+		cancel()
+	}
+	if err != nil {
+		return "", "", err
+	}
+	if siteID == "" {
+		// Only remember a miss every peer asked answered for
+		if complete && asked > 0 {
+			ws.names.put(siteName, "", time.Now())
+		}
+		return "", "", errNameNotFound
+	}
+	if ws.store.IsDenied(siteID) {
+		return "", "", errNameNotFound
+	}
+	ws.names.put(siteName, siteID, time.Now())
+	return siteID, nameSourcePeers, nil
+}
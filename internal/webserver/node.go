@@ -1,15 +1,23 @@
 package webserver
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
+	"alxnet/internal/core"
+	"alxnet/internal/erasure"
 	"alxnet/internal/p2p"
 	"alxnet/internal/store"
 
+	protocol "github.com/libp2p/go-libp2p/core/protocol"
 	"go.uber.org/zap"
 )
 
@@ -18,25 +26,49 @@ func NewNodeServer(store *store.Store, node *p2p.Node, logger *zap.Logger, port
 	ctx, cancel := context.WithCancel(context.Background())
 
 	ws := &WebServer{
-		store:  store,
-		node:   node,
-		logger: logger,
-		port:   port,
-		ctx:    ctx,
-		cancel: cancel,
+		store:    store,
+		node:     node,
+		logger:   logger,
+		port:     port,
+		ctx:      ctx,
+		cancel:   cancel,
+		tasks:    newTaskRunner(logger),
+		writeCap: capAdmin,
+		limits:   newClientLimits(),
 	}
 
 	mux := http.NewServeMux()
 
 	// Node management endpoints
 	mux.HandleFunc("/", ws.handleNodeHomepage)
-	mux.HandleFunc("/api/node/status", ws.handleNodeStatus)
-	mux.HandleFunc("/api/node/peers", ws.handleNodePeers)
-	mux.HandleFunc("/api/node/info", ws.handleNodeInfo)
-	mux.HandleFunc("/api/storage/stats", ws.handleStorageStats)
-	mux.HandleFunc("/api/storage/sites", ws.handleStorageSites)
-	mux.HandleFunc("/api/storage/domains", ws.handleStorageDomains)
-	mux.HandleFunc("/api/network/bootstrap", ws.handleNetworkBootstrap)
+	mux.HandleFunc("/_alxnet/ui/", ws.handleUIStatic)
+	ws.handleAPI(mux, "/api/node/status", ws.handleNodeStatus, apiDoc{Methods: "GET", Summary: "Node ID, uptime, addresses, validation and bandwidth totals"})
+	ws.handleAPI(mux, "/api/node/peers", ws.handleNodePeers, apiDoc{Methods: "GET", Summary: "Connected peers with their protocol and traffic"})
+	ws.handleAPI(mux, "/api/node/wants", ws.handleNodeWants, apiDoc{Methods: "GET", Summary: "Content being fetched from peers, and request slots by priority class"})
+	ws.handleAPI(mux, "/api/node/info", ws.handleNodeInfo, apiDoc{Methods: "GET", Summary: "Node ID, version, protocols, features and scheduled rule changes"})
+	ws.handleAPI(mux, "/api/metrics/history", ws.handleMetricsHistory, apiDoc{Methods: "GET", Summary: "Sampled node activity over a time range", Query: []string{"range"}})
+	ws.handleAPI(mux, "/api/logs/stream", ws.handleLogStream, apiDoc{Methods: "GET", Summary: "Follow the node log over a WebSocket", Query: []string{"level", "module", "backlog"}})
+	ws.handleAPI(mux, "/api/nodes", ws.handleNodes, apiDoc{Methods: "GET", Summary: "This node and the registered remote nodes, with totals"})
+	ws.handleAPI(mux, "/api/nodes/", ws.handleNodeProxy, apiDoc{Methods: "GET", Summary: "Read a node UI endpoint of a registered remote node", Path: "/nodes/{name}/{endpoint}"})
+	ws.handleAPI(mux, "/api/storage/stats", ws.handleStorageStats, apiDoc{Methods: "GET", Summary: "Storage counters and content cache statistics"})
+	ws.handleAPI(mux, "/api/storage/sites", ws.handleStorageSites, apiDoc{Methods: "GET", Summary: "Sites stored on this node"})
+	ws.handleAPI(mux, "/api/storage/usage", ws.handleStorageUsage, apiDoc{Methods: "GET", Summary: "Disk used per stored site, largest first", Query: []string{"limit", "site"}})
+	ws.handleAPI(mux, "/api/storage/domains", ws.handleStorageDomains, apiDoc{Methods: "GET", Summary: "Registered site names"})
+	ws.handleAPI(mux, "/api/storage/pins", ws.handleStoragePins, apiDoc{Methods: "GET POST", Summary: "List pinned sites, or pin or unpin one"})
+	ws.handleAPI(mux, "/api/storage/retention", ws.handleStorageRetention, apiDoc{Methods: "GET POST", Summary: "Retention policies and the last pruning run, or set a pinned site's policy"})
+	ws.handleAPI(mux, "/api/storage/erasure", ws.handleStorageErasure, apiDoc{Methods: "GET POST", Summary: "List, create or remove erasure-coded pins"})
+	ws.handleAPI(mux, "/api/storage/erasure/recover", ws.handleStorageErasureRecover, apiDoc{Methods: "POST", Summary: "Rebuild a site from its erasure shards"})
+	ws.handleAPI(mux, "/api/hosting/incoming", ws.handleHostingIncoming, apiDoc{Methods: "GET", Summary: "Hosting requests other publishers sent to this node"})
+	ws.handleAPI(mux, "/api/hosting/respond", ws.handleHostingRespond, apiDoc{Methods: "POST", Summary: "Accept or reject a hosting request"})
+	ws.handleAPI(mux, "/api/network/bootstrap", ws.handleNetworkBootstrap, apiDoc{Methods: "GET", Summary: "Bootstrap peers"})
+	ws.handleAPI(mux, "/api/network/check", ws.handleNetworkCheck, apiDoc{Methods: "GET", Summary: "Ask random peers which of a site's files they hold", Query: []string{"domain", "peers"}})
+	ws.handleAPI(mux, "/api/storage/car/export", ws.handleCARExport, apiDoc{Methods: "GET", Summary: "Export a site as a CAR archive", Query: []string{"domain"}, Produces: "application/vnd.ipld.car"})
+	ws.handleAPI(mux, "/api/storage/car/import", ws.handleCARImport, apiDoc{Methods: "POST", Summary: "Import a CAR archive", Body: "application/vnd.ipld.car"})
+	ws.handleAPI(mux, "/api/tasks", ws.handleTasks, apiDoc{Methods: "GET", Summary: "Background tasks with their progress, newest first", Query: []string{"id"}})
+	ws.handleAPI(mux, "/api/tasks/cancel", ws.handleTaskCancel, apiDoc{Methods: "POST", Summary: "Cancel a background task"})
+	ws.handleAPI(mux, "/api/tasks/sync", ws.handleTaskSync, apiDoc{Methods: "POST", Summary: "Announce the heads of all pinned sites as a background task"})
+	ws.registerAdmin(mux)
+	ws.registerOpenAPI(mux)
 
 	ws.server = &http.Server{
 		Addr:         fmt.Sprintf(":%d", port),
@@ -51,332 +83,7 @@ func NewNodeServer(store *store.Store, node *p2p.Node, logger *zap.Logger, port
 
 // handleNodeHomepage serves the node management interface
 func (ws *WebServer) handleNodeHomepage(w http.ResponseWriter, r *http.Request) {
-	homepage := `<!DOCTYPE html>
-<html lang="en">
-<head>
-    <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>AlxNet Node Management</title>
-    <style>
-        * { margin: 0; padding: 0; box-sizing: border-box; }
-        body { 
-            font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif;
-            background: linear-gradient(135deg, #667eea 0%, #764ba2 100%);
-            min-height: 100vh;
-            color: white;
-        }
-        .container { max-width: 1200px; margin: 0 auto; padding: 2rem; }
-        .header { text-align: center; margin-bottom: 3rem; }
-        .header h1 { font-size: 3rem; margin-bottom: 1rem; }
-        .header p { font-size: 1.2rem; opacity: 0.9; }
-        .section {
-            background: rgba(255,255,255,0.1);
-            padding: 2rem;
-            border-radius: 10px;
-            margin-bottom: 2rem;
-            backdrop-filter: blur(10px);
-        }
-        .section h2 { margin-bottom: 1rem; }
-        .grid { display: grid; grid-template-columns: repeat(auto-fit, minmax(400px, 1fr)); gap: 2rem; }
-        .metric {
-            background: rgba(0,0,0,0.3);
-            padding: 1rem;
-            border-radius: 5px;
-            margin-bottom: 1rem;
-        }
-        .metric-label { font-size: 0.9rem; opacity: 0.8; }
-        .metric-value { font-size: 1.5rem; font-weight: bold; }
-        .status-indicator {
-            display: inline-block;
-            width: 12px;
-            height: 12px;
-            border-radius: 50%;
-            margin-right: 0.5rem;
-        }
-        .status-online { background: #22c55e; }
-        .status-offline { background: #ef4444; }
-        .peer-list {
-            max-height: 300px;
-            overflow-y: auto;
-            background: rgba(0,0,0,0.3);
-            padding: 1rem;
-            border-radius: 5px;
-        }
-        .peer-item {
-            padding: 0.5rem;
-            border-bottom: 1px solid rgba(255,255,255,0.1);
-            font-family: monospace;
-            font-size: 0.9rem;
-        }
-        .refresh-btn {
-            padding: 0.5rem 1rem;
-            background: #4c51bf;
-            color: white;
-            border: none;
-            border-radius: 5px;
-            cursor: pointer;
-            margin-bottom: 1rem;
-        }
-        .refresh-btn:hover { background: #3730a3; }
-        .auto-refresh {
-            margin-left: 1rem;
-        }
-        .chart {
-            height: 200px;
-            background: rgba(0,0,0,0.3);
-            border-radius: 5px;
-            display: flex;
-            align-items: center;
-            justify-content: center;
-            color: rgba(255,255,255,0.6);
-        }
-    </style>
-</head>
-<body>
-    <div class="container">
-        <div class="header">
-            <h1>🔗 Node Management</h1>
-            <p>Monitor and manage your AlxNet P2P node</p>
-        </div>
-        
-        <div class="section">
-            <h2>Node Status</h2>
-            <div class="grid">
-                <div>
-                    <div class="metric">
-                        <div class="metric-label">Status</div>
-                        <div class="metric-value" id="nodeStatus">
-                            <span class="status-indicator status-online"></span>Online
-                        </div>
-                    </div>
-                    <div class="metric">
-                        <div class="metric-label">Node ID</div>
-                        <div class="metric-value" id="nodeId" style="font-size: 1rem; font-family: monospace;">Loading...</div>
-                    </div>
-                    <div class="metric">
-                        <div class="metric-label">Uptime</div>
-                        <div class="metric-value" id="uptime">Loading...</div>
-                    </div>
-                </div>
-                <div>
-                    <div class="metric">
-                        <div class="metric-label">Connected Peers</div>
-                        <div class="metric-value" id="peerCount">0</div>
-                    </div>
-                    <div class="metric">
-                        <div class="metric-label">Listen Addresses</div>
-                        <div class="metric-value" id="listenAddrs" style="font-size: 0.9rem; font-family: monospace;">Loading...</div>
-                    </div>
-                    <div class="metric">
-                        <div class="metric-label">Protocol Version</div>
-                        <div class="metric-value" id="protocolVersion">1.0.0</div>
-                    </div>
-                </div>
-            </div>
-        </div>
-        
-        <div class="grid">
-            <div class="section">
-                <h2>Connected Peers</h2>
-                <button class="refresh-btn" onclick="loadPeers()">Refresh</button>
-                <label class="auto-refresh">
-                    <input type="checkbox" id="autoRefreshPeers" onchange="toggleAutoRefresh()"> Auto-refresh (10s)
-                </label>
-                <div class="peer-list" id="peerList">
-                    <div>Loading peers...</div>
-                </div>
-            </div>
-            
-            <div class="section">
-                <h2>Storage Statistics</h2>
-                <button class="refresh-btn" onclick="loadStorageStats()">Refresh</button>
-                <div id="storageStats">
-                    <div class="metric">
-                        <div class="metric-label">Total Sites</div>
-                        <div class="metric-value" id="totalSites">0</div>
-                    </div>
-                    <div class="metric">
-                        <div class="metric-label">Total Domains</div>
-                        <div class="metric-value" id="totalDomains">0</div>
-                    </div>
-                    <div class="metric">
-                        <div class="metric-label">Storage Used</div>
-                        <div class="metric-value" id="storageUsed">0 MB</div>
-                    </div>
-                    <div class="metric">
-                        <div class="metric-label">Content Files</div>
-                        <div class="metric-value" id="contentFiles">0</div>
-                    </div>
-                </div>
-            </div>
-        </div>
-        
-        <div class="grid">
-            <div class="section">
-                <h2>Recent Activity</h2>
-                <div class="chart">
-                    <div>Activity chart placeholder</div>
-                </div>
-            </div>
-            
-            <div class="section">
-                <h2>Network Health</h2>
-                <div class="chart">
-                    <div>Network health chart placeholder</div>
-                </div>
-            </div>
-        </div>
-        
-        <div class="section">
-            <h2>Recent Sites</h2>
-            <button class="refresh-btn" onclick="loadRecentSites()">Refresh</button>
-            <div id="recentSites">
-                <div>Loading recent sites...</div>
-            </div>
-        </div>
-    </div>
-    
-    <script>
-        let autoRefreshInterval = null;
-        
-        // Load initial data
-        document.addEventListener('DOMContentLoaded', function() {
-            loadNodeStatus();
-            loadPeers();
-            loadStorageStats();
-            loadRecentSites();
-        });
-        
-        async function apiCall(endpoint) {
-            try {
-                const response = await fetch(endpoint);
-                return await response.json();
-            } catch (error) {
-                console.error('API call failed:', error);
-                return null;
-            }
-        }
-        
-        async function loadNodeStatus() {
-            const status = await apiCall('/api/node/status');
-            if (status) {
-                document.getElementById('nodeId').textContent = status.node_id || 'Unknown';
-                document.getElementById('uptime').textContent = formatUptime(status.uptime_seconds || 0);
-                
-                if (status.listen_addresses) {
-                    document.getElementById('listenAddrs').innerHTML = 
-                        status.listen_addresses.map(addr => '<div>' + addr + '</div>').join('');
-                }
-            }
-        }
-        
-        async function loadPeers() {
-            const peers = await apiCall('/api/node/peers');
-            if (peers) {
-                document.getElementById('peerCount').textContent = peers.count || 0;
-                
-                const peerList = document.getElementById('peerList');
-                if (peers.peers && peers.peers.length > 0) {
-                    peerList.innerHTML = peers.peers.map(peer => 
-                        '<div class="peer-item">' + 
-                        '<div><strong>ID:</strong> ' + peer.id + '</div>' +
-                        '<div><strong>Addr:</strong> ' + (peer.address || 'Unknown') + '</div>' +
-                        '<div><strong>Connected:</strong> ' + formatTime(peer.connected_at) + '</div>' +
-                        '</div>'
-                    ).join('');
-                } else {
-                    peerList.innerHTML = '<div>No peers connected</div>';
-                }
-            }
-        }
-        
-        async function loadStorageStats() {
-            const stats = await apiCall('/api/storage/stats');
-            if (stats) {
-                document.getElementById('totalSites').textContent = stats.total_sites || 0;
-                document.getElementById('totalDomains').textContent = stats.total_domains || 0;
-                document.getElementById('storageUsed').textContent = formatBytes(stats.storage_bytes || 0);
-                document.getElementById('contentFiles').textContent = stats.content_files || 0;
-            }
-        }
-        
-        async function loadRecentSites() {
-            const sites = await apiCall('/api/storage/sites');
-            const recentSitesDiv = document.getElementById('recentSites');
-            
-            if (sites && sites.sites && sites.sites.length > 0) {
-                recentSitesDiv.innerHTML = sites.sites.slice(0, 10).map(site => 
-                    '<div class="peer-item">' +
-                    '<div><strong>Site ID:</strong> ' + site.id + '</div>' +
-                    '<div><strong>Last Updated:</strong> ' + formatTime(site.last_updated) + '</div>' +
-                    '<div><strong>Files:</strong> ' + (site.file_count || 'N/A') + '</div>' +
-                    '</div>'
-                ).join('');
-            } else {
-                recentSitesDiv.innerHTML = '<div>No sites found</div>';
-            }
-        }
-        
-        function toggleAutoRefresh() {
-            const checkbox = document.getElementById('autoRefreshPeers');
-            if (checkbox.checked) {
-                autoRefreshInterval = setInterval(() => {
-                    loadPeers();
-                    loadNodeStatus();
-                    loadStorageStats();
-                }, 10000);
-            } else {
-                if (autoRefreshInterval) {
-                    clearInterval(autoRefreshInterval);
-                    autoRefreshInterval = null;
-                }
-            }
-        }
-        
-        function formatUptime(seconds) {
-            if (seconds < 60) return seconds + 's';
-            if (seconds < 3600) return Math.floor(seconds / 60) + 'm';
-            if (seconds < 86400) return Math.floor(seconds / 3600) + 'h';
-            return Math.floor(seconds / 86400) + 'd';
-        }
-        
-        function formatBytes(bytes) {
-            if (bytes === 0) return '0 B';
-            const k = 1024;
-            const sizes = ['B', 'KB', 'MB', 'GB'];
-            const i = Math.floor(Math.log(bytes) / Math.log(k));
-            return parseFloat((bytes / Math.pow(k, i)).toFixed(2)) + ' ' + sizes[i];
-        }
-        
-        function formatTime(timestamp) {
-            if (!timestamp || timestamp === 'Unknown') return 'Unknown';
-            
-            // Handle both Unix timestamp (number) and ISO string formats
-            let date;
-            if (typeof timestamp === 'number') {
-                date = new Date(timestamp * 1000);
-            } else if (typeof timestamp === 'string') {
-                date = new Date(timestamp);
-            } else {
-                return 'Unknown';
-            }
-            
-            // Check if date is valid
-            if (isNaN(date.getTime())) {
-                return 'Unknown';
-            }
-            
-            return date.toLocaleString();
-        }
-    </script>
-</body>
-</html>`
-
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	if _, err := w.Write([]byte(homepage)); err != nil {
-		http.Error(w, "Failed to write response", http.StatusInternalServerError)
-		return
-	}
+	ws.renderPage(w, r, "node.html")
 }
 
 // API Handlers for node management
@@ -393,10 +100,15 @@ func (ws *WebServer) handleNodeStatus(w http.ResponseWriter, r *http.Request) {
 		"server":           "alxnet-node-ui",
 		"version":          "1.0.0",
 		"timestamp":        time.Now().Unix(),
-		"uptime_seconds":   time.Since(time.Now()).Seconds(), // This would need proper tracking
+		"uptime_seconds":   int64(ws.node.Uptime() / time.Second),
 		"node_id":          ws.node.Host.ID().String(),
 		"listen_addresses": addrs,
 		"status":           "online",
+		"validation":       ws.node.ValidationStats(),
+		"bandwidth":        ws.node.BandwidthStats(),
+	}
+	if fp := ws.node.PrivateNetwork(); fp != "" {
+		status["private_network"] = fp
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -422,6 +134,16 @@ func (ws *WebServer) handleNodePeers(w http.ResponseWriter, r *http.Request) {
 		if len(conns) > 0 {
 			peerInfo["address"] = conns[0].RemoteMultiaddr().String()
 		}
+		if caps, ok := ws.node.PeerCapabilities(peerID); ok {
+			peerInfo["protocol_version"] = caps.Version
+			peerInfo["features"] = caps.Features
+			peerInfo["agent"] = caps.Agent
+			peerInfo["legacy"] = caps.Legacy
+		}
+		bw := ws.node.Bandwidth(peerID)
+		peerInfo["bytes_served"] = bw.Served
+		peerInfo["bytes_received"] = bw.Received
+		peerInfo["leecher"] = bw.Leecher()
 
 		peerInfos[i] = peerInfo
 	}
@@ -439,13 +161,32 @@ func (ws *WebServer) handleNodePeers(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleNodeWants lists the CIDs the node's want list is fetching and how
+// each priority class uses the node's request slots.
+func (ws *WebServer) handleNodeWants(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	wants := ws.node.WantList()
+	writeJSON(w, map[string]interface{}{
+		"success":    true,
+		"wants":      wants,
+		"count":      len(wants),
+		"priorities": ws.node.FetchStats(),
+	})
+}
+
 func (ws *WebServer) handleNodeInfo(w http.ResponseWriter, r *http.Request) {
 	info := map[string]interface{}{
-		"node_id":    ws.node.Host.ID().String(),
-		"agent_name": "alxnet",
-		"version":    "1.0.0",
-		"protocols":  []string{"/alxnet/1.0.0"},
-		"public_key": ws.node.Host.ID().String(), // This would be the actual public key
+		"node_id":          ws.node.Host.ID().String(),
+		"agent_name":       "alxnet",
+		"version":          strings.TrimPrefix(p2p.AgentVersion, "alxnet/"),
+		"protocol_version": p2p.ProtocolVersion,
+		"protocols":        []protocol.ID{p2p.BrowseProto, p2p.HaveProto, p2p.HostingProto, p2p.HelloProto},
+		"features":         ws.node.Features(),
+		"activations":      ws.node.ActivationStatus(),
+		"public_key":       ws.node.Host.ID().String(), // This would be the actual public key
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -455,39 +196,29 @@ func (ws *WebServer) handleNodeInfo(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleStorageStats reports the store's counters; they are kept as the
+// store writes, so this does not scan it.
 func (ws *WebServer) handleStorageStats(w http.ResponseWriter, r *http.Request) {
-	// Get domain count
-	domains, err := ws.store.ListDomains()
-	domainCount := 0
-	if err == nil {
-		domainCount = len(domains)
-	}
-
-	// Get site count
-	sites, err := ws.store.ListSites()
-	siteCount := 0
-	if err == nil {
-		siteCount = len(sites)
-	}
-
-	// Get storage usage
-	storageBytes, err := ws.store.GetStorageUsage()
-	if err != nil {
-		storageBytes = 0
-	}
-
-	// Get content file count
-	contentFiles, err := ws.store.GetContentFileCount()
+	counts, err := ws.store.GetStats()
 	if err != nil {
-		contentFiles = 0
+		http.Error(w, "Failed to read storage stats", http.StatusInternalServerError)
+		return
 	}
 
 	stats := map[string]interface{}{
 		"success":       true,
-		"total_sites":   siteCount,
-		"total_domains": domainCount,
-		"storage_bytes": storageBytes,
-		"content_files": contentFiles,
+		"total_sites":   counts.TotalSites,
+		"total_domains": counts.TotalDomains,
+		"storage_bytes": counts.ContentBytes,
+		"content_files": counts.TotalContent,
+		"records":       counts.TotalRecords,
+		"websites":      counts.TotalWebsites,
+		"counted_at":    counts.CountedAt,
+		"recounting":    counts.Recounting,
+		"stale":         counts.Stale,
+	}
+	if cache := ws.store.ContentCacheStats(); cache != nil {
+		stats["content_cache"] = cache
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -497,6 +228,56 @@ func (ws *WebServer) handleStorageStats(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+// Limits of the per-site usage listing
+const (
+	defaultUsageLimit = 20
+	maxUsageLimit     = 1000
+)
+
+// handleStorageUsage reports the disk usage of stored sites, largest first:
+// GET /api/storage/usage?limit=1-1000 (default 20), or ?site={siteID} for
+// one site. Measuring walks the whole store.
+func (ws *WebServer) handleStorageUsage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	site := q.Get("site")
+	if site != "" && (len(site) != 64 || !isHex(site)) {
+		http.Error(w, "Invalid site ID", http.StatusBadRequest)
+		return
+	}
+	limit := defaultUsageLimit
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxUsageLimit)
+	}
+
+	usage, err := ws.store.SitesUsage()
+	if err != nil {
+		http.Error(w, "Failed to measure storage usage", http.StatusInternalServerError)
+		return
+	}
+	total := len(usage)
+	if site != "" {
+		usage = slices.DeleteFunc(usage, func(u store.SiteUsage) bool { return u.SiteID != site })
+		if len(usage) == 0 {
+			http.Error(w, "Site not found", http.StatusNotFound)
+			return
+		}
+	}
+	writeJSON(w, map[string]interface{}{
+		"success": true,
+		"sites":   usage[:min(len(usage), limit)],
+		"count":   total,
+	})
+}
+
 func (ws *WebServer) handleStorageSites(w http.ResponseWriter, r *http.Request) {
 	siteIDs, err := ws.store.ListSites()
 	if err != nil {
@@ -559,6 +340,8 @@ func (ws *WebServer) handleStorageSites(w http.ResponseWriter, r *http.Request)
 			}
 		}
 
+		siteInfo["pinned"] = ws.store.IsPinned(siteID)
+
 		sites = append(sites, siteInfo)
 	}
 
@@ -603,6 +386,464 @@ func (ws *WebServer) handleStorageDomains(w http.ResponseWriter, r *http.Request
 	}
 }
 
+// handleStoragePins lists pinned sites (GET) or pins/unpins one (POST).
+func (ws *WebServer) handleStoragePins(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req struct {
+			SiteID string `json:"site_id"`
+			Pinned bool   `json:"pinned"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if len(req.SiteID) != 64 || !isHex(req.SiteID) {
+			http.Error(w, "Invalid site ID", http.StatusBadRequest)
+			return
+		}
+		var err error
+		if req.Pinned {
+			err = ws.node.PinSite(ws.ctx, req.SiteID)
+		} else {
+			err = ws.store.UnpinSite(req.SiteID)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	pins, err := ws.store.ListPinnedSites()
+	if err != nil {
+		http.Error(w, "Failed to list pinned sites", http.StatusInternalServerError)
+		return
+	}
+	if pins == nil {
+		pins = []string{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"pins":    pins,
+		"count":   len(pins),
+	}); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// SetRetention sets the retention policies of pinned and other sites that
+// /api/storage/retention reports and runs.
+func (ws *WebServer) SetRetention(rules store.RetentionRules) {
+	ws.retention = rules
+}
+
+// siteRetention is a site's own retention policy and where its history was
+// pruned.
+type siteRetention struct {
+	SiteID     string               `json:"site_id"`
+	Policy     core.RetentionPolicy `json:"policy"`
+	Checkpoint *store.Checkpoint    `json:"checkpoint,omitempty"`
+}
+
+// handleStorageRetention lists the retention policies and the last run
+// (GET). POST {"site_id", "policy"} gives a pinned site its own policy,
+// {"site_id", "remove": true} removes it, and {"run": true} prunes now.
+func (ws *WebServer) handleStorageRetention(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req struct {
+			SiteID string `json:"site_id"`
+			Policy string `json:"policy"`
+			Remove bool   `json:"remove"`
+			Run    bool   `json:"run"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if req.Run {
+			if _, err := ws.store.ApplyRetention(ws.retention); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			break
+		}
+		if len(req.SiteID) != 64 || !isHex(req.SiteID) {
+			http.Error(w, "Invalid site ID", http.StatusBadRequest)
+			return
+		}
+		var err error
+		if req.Remove {
+			err = ws.store.ClearSiteRetention(req.SiteID)
+		} else {
+			policy, perr := core.ParseRetentionPolicy(req.Policy)
+			if perr != nil {
+				http.Error(w, perr.Error(), http.StatusBadRequest)
+				return
+			}
+			if !ws.store.IsPinned(req.SiteID) {
+				http.Error(w, "Site is not pinned", http.StatusBadRequest)
+				return
+			}
+			err = ws.store.SetSiteRetention(req.SiteID, policy)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	own, err := ws.store.SiteRetentions()
+	if err != nil {
+		http.Error(w, "Failed to list retention policies", http.StatusInternalServerError)
+		return
+	}
+	sites := make([]siteRetention, 0, len(own))
+	for siteID, policy := range own {
+		cp, _ := ws.store.GetCheckpoint(siteID)
+		sites = append(sites, siteRetention{SiteID: siteID, Policy: policy, Checkpoint: cp})
+	}
+	sort.Slice(sites, func(i, j int) bool { return sites[i].SiteID < sites[j].SiteID })
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"default":  ws.retention.Default,
+		"pinned":   ws.retention.Pinned,
+		"sites":    sites,
+		"last_run": ws.store.LastRetention(),
+	}); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// handleStorageErasure lists the sites pinned with erasure coding and the
+// shards this node holds for others (GET), or pins a site with erasure
+// coding across connected peers, or removes its set (POST).
+func (ws *WebServer) handleStorageErasure(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req struct {
+			SiteID string `json:"site_id"`
+			Data   int    `json:"data"`
+			Parity int    `json:"parity"`
+			Remove bool   `json:"remove"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if len(req.SiteID) != 64 || !isHex(req.SiteID) {
+			http.Error(w, "Invalid site ID", http.StatusBadRequest)
+			return
+		}
+		if req.Remove {
+			if err := ws.node.RemoveErasureSet(ws.ctx, req.SiteID); err != nil {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			break
+		}
+		if req.Data < 1 || req.Data > erasure.MaxDataShards || req.Parity < 1 || req.Parity > erasure.MaxParityShards {
+			http.Error(w, fmt.Sprintf("Data and parity shards must be between 1 and %d", erasure.MaxDataShards), http.StatusBadRequest)
+			return
+		}
+		if _, err := ws.node.ErasurePin(ws.ctx, req.SiteID, req.Data, req.Parity); err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sets, err := ws.node.ErasureSets()
+	if err != nil {
+		http.Error(w, "Failed to list erasure sets", http.StatusInternalServerError)
+		return
+	}
+	shards, err := ws.store.ListShards()
+	if err != nil {
+		http.Error(w, "Failed to list held shards", http.StatusInternalServerError)
+		return
+	}
+	if shards == nil {
+		shards = []store.ShardRecord{}
+	}
+	var held int64
+	for _, s := range shards {
+		held += s.Size
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":     true,
+		"sets":        sets,
+		"shards":      shards,
+		"shard_bytes": held,
+		"shard_quota": ws.node.ShardQuota(),
+	}); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// handleStorageErasureRecover rebuilds a site pinned with erasure coding
+// from the shards its holders still have, and imports it like a CAR
+// upload.
+func (ws *WebServer) handleStorageErasureRecover(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		SiteID string `json:"site_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if len(req.SiteID) != 64 || !isHex(req.SiteID) {
+		http.Error(w, "Invalid site ID", http.StatusBadRequest)
+		return
+	}
+	car, err := ws.node.RecoverErasureSet(ws.ctx, req.SiteID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Recovery failed: %v", err), http.StatusBadGateway)
+		return
+	}
+	res, err := ws.store.ImportCAR(bytes.NewReader(car))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Import failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(ws.applyImport(res)); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// handleHostingIncoming lists hosting requests other publishers sent to
+// this node.
+func (ws *WebServer) handleHostingIncoming(w http.ResponseWriter, r *http.Request) {
+	agreements, err := ws.node.HostingAgreements(p2p.HostingIncoming)
+	if err != nil {
+		http.Error(w, "Failed to list hosting requests", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"agreements": agreements,
+		"count":      len(agreements),
+	}); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// handleHostingRespond accepts or rejects a pending hosting request.
+func (ws *WebServer) handleHostingRespond(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		ID     string `json:"id"`
+		Accept bool   `json:"accept"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if len(req.ID) != 64 || !isHex(req.ID) {
+		http.Error(w, "Invalid agreement ID", http.StatusBadRequest)
+		return
+	}
+
+	agreement, err := ws.node.RespondHosting(r.Context(), req.ID, req.Accept)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":   true,
+		"agreement": agreement,
+	}); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// maxCARUpload bounds the body of a CAR import.
+const maxCARUpload = 256 << 20
+
+// handleCARExport streams a site as an IPFS CAR archive.
+func (ws *WebServer) handleCARExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := r.URL.Query().Get("domain")
+	if name == "" {
+		http.Error(w, "domain is required", http.StatusBadRequest)
+		return
+	}
+	siteID := name
+	if len(name) != 64 || !isHex(name) {
+		resolved, err := ws.store.ResolveDomain(name)
+		if err != nil {
+			http.Error(w, "Site name not found", http.StatusNotFound)
+			return
+		}
+		siteID = resolved
+	}
+
+	// Build the archive first so that a missing block becomes an error
+	// response instead of a truncated download
+	var buf bytes.Buffer
+	if _, err := ws.store.ExportSiteCAR(&buf, siteID); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.ipld.car; version=1")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", siteID+".car"))
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		ws.logger.Warn("CAR export write failed", zap.Error(err))
+	}
+}
+
+// handleCARImport stores the blocks of an uploaded CAR archive. For archives
+// exported by AlxNet the head record is applied through normal update
+// validation, and the manifest is installed if the site has none yet.
+func (ws *WebServer) handleCARImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	res, err := ws.store.ImportCAR(http.MaxBytesReader(w, r.Body, maxCARUpload))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Import failed: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(ws.applyImport(res)); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// applyImport applies the site of an imported archive and describes the
+// outcome for API responses.
+func (ws *WebServer) applyImport(res *store.CARImport) map[string]interface{} {
+	response := map[string]interface{}{
+		"success": true,
+		"blocks":  res.Blocks,
+		"skipped": res.Skipped,
+		"cids":    res.CIDs,
+	}
+	if res.Site != nil {
+		applied, reason := ws.applyImportedHead(res.Site)
+		response["site_id"] = res.Site.SiteID
+		response["head_applied"] = applied
+		if reason != "" {
+			response["head_error"] = reason
+		}
+		manifestApplied, manifestReason := ws.applyImportedManifest(res.Site)
+		response["manifest_applied"] = manifestApplied
+		if manifestReason != "" {
+			response["manifest_error"] = manifestReason
+		}
+	}
+	return response
+}
+
+// applyImportedHead validates an imported head record like a gossiped
+// update. It returns whether the head was applied and why not.
+func (ws *WebServer) applyImportedHead(site *store.SiteExport) (bool, string) {
+	if site.Head == "" {
+		return false, ""
+	}
+	data, err := ws.store.GetRecord(site.Head)
+	if err != nil {
+		return false, err.Error()
+	}
+	var rec core.UpdateRecord
+	if err := core.UnmarshalCBOR(data, &rec); err != nil {
+		return false, err.Error()
+	}
+	if core.SiteIDFromPub(rec.SitePub) != site.SiteID {
+		return false, "head record belongs to another site"
+	}
+	if err := ws.node.ValidateAndApply(&rec, nil); err != nil {
+		return false, err.Error()
+	}
+	return true, ""
+}
+
+// applyImportedManifest points a site without a manifest at the imported
+// one, after checking the signatures of the manifest and every file record
+// against the site. It returns whether the manifest was applied and why not.
+func (ws *WebServer) applyImportedManifest(site *store.SiteExport) (bool, string) {
+	if site.Manifest == "" || ws.store.HasWebsiteManifest(site.SiteID) {
+		return false, ""
+	}
+	data, err := ws.store.GetWebsiteManifest(site.Manifest)
+	if err != nil {
+		return false, err.Error()
+	}
+	var m core.WebsiteManifest
+	if err := core.UnmarshalCBOR(data, &m); err != nil {
+		return false, err.Error()
+	}
+	if err := p2p.VerifySiteManifest(&m, site.SiteID); err != nil {
+		return false, err.Error()
+	}
+	records := make(map[string][]byte, len(site.FileRecords))
+	for path, recordCID := range site.FileRecords {
+		record, err := ws.store.GetFileRecord(recordCID)
+		if err != nil {
+			return false, err.Error()
+		}
+		var rec core.FileRecord
+		if err := core.UnmarshalCBOR(record, &rec); err != nil {
+			return false, err.Error()
+		}
+		if err := p2p.VerifySiteFileRecord(&rec, site.SiteID, path); err != nil {
+			return false, path + ": " + err.Error()
+		}
+		if m.Files[path] != rec.ContentCID {
+			return false, path + ": file record does not match the manifest"
+		}
+		records[path] = record
+	}
+	for path, record := range records {
+		if err := ws.store.PutFileRecord(site.SiteID, path, site.FileRecords[path], record); err != nil {
+			return false, err.Error()
+		}
+	}
+	if err := ws.store.PutWebsiteManifest(site.SiteID, site.Manifest, data); err != nil {
+		return false, err.Error()
+	}
+	return true, ""
+}
+
 func (ws *WebServer) handleNetworkBootstrap(w http.ResponseWriter, r *http.Request) {
 	// Return information about bootstrap nodes
 	response := map[string]interface{}{
@@ -617,3 +858,113 @@ func (ws *WebServer) handleNetworkBootstrap(w http.ResponseWriter, r *http.Reque
 		return
 	}
 }
+
+// Defaults and limits for availability checks
+const (
+	defaultCheckPeers = 5
+	maxCheckPeers     = 50
+)
+
+// handleNetworkCheck resolves a site by name or ID, walks its files and asks
+// a random sample of peers which of them they store.
+func (ws *WebServer) handleNetworkCheck(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := r.URL.Query().Get("domain")
+	if name == "" {
+		http.Error(w, "domain is required", http.StatusBadRequest)
+		return
+	}
+	samplePeers := defaultCheckPeers
+	if v := r.URL.Query().Get("peers"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxCheckPeers {
+			http.Error(w, fmt.Sprintf("peers must be between 1 and %d", maxCheckPeers), http.StatusBadRequest)
+			return
+		}
+		samplePeers = n
+	}
+
+	siteID := name
+	if len(name) != 64 || !isHex(name) {
+		resolved, err := ws.store.ResolveDomain(name)
+		if err != nil {
+			http.Error(w, "Site name not found", http.StatusNotFound)
+			return
+		}
+		siteID = resolved
+	}
+
+	files, err := ws.siteContentCIDs(siteID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	cids := make([]string, 0, len(files))
+	for _, cid := range files {
+		cids = append(cids, cid)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 20*time.Second)
+	defer cancel()
+	report, err := ws.node.CheckAvailability(ctx, siteID, cids, samplePeers)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	fileList := make([]map[string]interface{}, 0, len(files))
+	missing := 0
+	for path, cid := range files {
+		local, _ := ws.store.HasContent(cid)
+		holders := report.Holders[cid]
+		if holders == 0 {
+			missing++
+		}
+		fileList = append(fileList, map[string]interface{}{
+			"path":    path,
+			"cid":     cid,
+			"holders": holders,
+			"local":   local,
+		})
+	}
+	sort.Slice(fileList, func(i, j int) bool {
+		return fileList[i]["path"].(string) < fileList[j]["path"].(string)
+	})
+
+	status := "fully available"
+	switch {
+	case report.Responded == 0:
+		status = "no peers responded"
+	case missing > 0:
+		status = "partially missing files"
+	}
+
+	response := map[string]interface{}{
+		"success":   true,
+		"domain":    name,
+		"site_id":   siteID,
+		"queried":   report.Queried,
+		"responded": report.Responded,
+		"missing":   missing,
+		"status":    status,
+		"files":     fileList,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if !((c >= '0' && c <= '9') || (c >= 'a' && c <= 'f')) {
+			return false
+		}
+	}
+	return true
+}
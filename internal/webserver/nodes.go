@@ -0,0 +1,360 @@
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"alxnet/internal/store"
+
+	"go.uber.org/zap"
+)
+
+// Operators running several nodes register the others with one of them
+// through /api/admin/nodes, giving each node's management interface and
+// admin token. The node UI then shows the totals of all of them at
+// /api/nodes and reads any one's page through /api/nodes/<name>/, so a
+// single page can switch between them.
+
+// Remote node limits
+const (
+	// remoteNodeTimeout bounds each call to a remote node.
+	remoteNodeTimeout = 5 * time.Second
+	// maxRemoteNodeResponse caps what is read back from a remote node.
+	maxRemoteNodeResponse = 4 << 20
+)
+
+// localNodeName names this node in /api/nodes; remote nodes cannot use it.
+const localNodeName = "local"
+
+// remoteNodeReadPaths are the node UI endpoints read through
+// /api/nodes/<name>/. Only GET requests are passed on, so a node cannot be
+// changed from another node's UI.
+var remoteNodeReadPaths = map[string]bool{
+	"/api/node/status":      true,
+	"/api/node/peers":       true,
+	"/api/node/info":        true,
+	"/api/node/wants":       true,
+	"/api/metrics/history":  true,
+	"/api/storage/stats":    true,
+	"/api/storage/sites":    true,
+	"/api/storage/usage":    true,
+	"/api/storage/domains":  true,
+	"/api/storage/erasure":  true,
+	"/api/hosting/incoming": true,
+	"/api/tasks":            true,
+}
+
+// remoteNodeClient calls remote nodes. It does not follow redirects, so
+// the admin token is only ever sent to the registered URL.
+var remoteNodeClient = &http.Client{
+	Timeout:       remoteNodeTimeout,
+	CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+}
+
+// normalizeNodeURL checks that raw is an http(s) URL without credentials,
+// query or fragment and returns it without a trailing slash.
+func normalizeNodeURL(raw string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", errors.New("node URL must be an http or https URL such as http://10.0.0.2:8082")
+	}
+	if u.User != nil || u.RawQuery != "" || u.Fragment != "" {
+		return "", errors.New("node URL must not carry credentials, a query or a fragment")
+	}
+	s := strings.TrimRight(u.String(), "/")
+	if len(s) > store.MaxRemoteNodeURL {
+		return "", fmt.Errorf("node URL longer than %d bytes", store.MaxRemoteNodeURL)
+	}
+	return s, nil
+}
+
+// remoteNodeGet sends a GET for path and query to n with its admin token.
+// The caller closes the body.
+func remoteNodeGet(ctx context.Context, n *store.RemoteNode, path, query string) (*http.Response, error) {
+	target := n.URL + path
+	if query != "" {
+		target += "?" + query
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	if n.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.Token)
+	}
+	return remoteNodeClient.Do(req)
+}
+
+// remoteNodeJSON reads path from n into out.
+func remoteNodeJSON(ctx context.Context, n *store.RemoteNode, path string, out interface{}) error {
+	resp, err := remoteNodeGet(ctx, n, path, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", path, resp.Status)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxRemoteNodeResponse)).Decode(out); err != nil {
+		return fmt.Errorf("invalid response from %s: %w", path, err)
+	}
+	return nil
+}
+
+// nodeSummary is one node's line in the multi-node view.
+type nodeSummary struct {
+	Name          string `json:"name"`
+	URL           string `json:"url,omitempty"`
+	Local         bool   `json:"local,omitempty"`
+	Online        bool   `json:"online"`
+	Error         string `json:"error,omitempty"`
+	NodeID        string `json:"node_id,omitempty"`
+	UptimeSeconds int64  `json:"uptime_seconds"`
+	Peers         int    `json:"peers"`
+	Sites         int64  `json:"sites"`
+	StorageBytes  int64  `json:"storage_bytes"`
+	BytesServed   int64  `json:"bytes_served"`
+	BytesReceived int64  `json:"bytes_received"`
+}
+
+// localNodeSummary summarizes this node.
+func (ws *WebServer) localNodeSummary() nodeSummary {
+	s := nodeSummary{Name: localNodeName, Local: true, Online: true}
+	if ws.node != nil {
+		s.NodeID = ws.node.Host.ID().String()
+		s.UptimeSeconds = int64(ws.node.Uptime() / time.Second)
+		s.Peers = len(ws.node.Host.Network().Peers())
+		bw := ws.node.BandwidthStats()
+		s.BytesServed, s.BytesReceived = bw.Served, bw.Received
+	}
+	if counts, err := ws.store.GetStats(); err == nil {
+		s.Sites, s.StorageBytes = counts.TotalSites, counts.ContentBytes
+	}
+	return s
+}
+
+// remoteNodeSummary asks n for its status, peers and storage statistics.
+func remoteNodeSummary(ctx context.Context, n *store.RemoteNode) nodeSummary {
+	s := nodeSummary{Name: n.Name, URL: n.URL}
+	var status struct {
+		NodeID    string `json:"node_id"`
+		Uptime    int64  `json:"uptime_seconds"`
+		Bandwidth struct {
+			Served   int64 `json:"bytes_served"`
+			Received int64 `json:"bytes_received"`
+		} `json:"bandwidth"`
+	}
+	var peers struct {
+		Count int `json:"count"`
+	}
+	var stats struct {
+		Sites        int64 `json:"total_sites"`
+		StorageBytes int64 `json:"storage_bytes"`
+	}
+	for _, call := range []struct {
+		path string
+		out  interface{}
+	}{
+		{"/api/node/status", &status},
+		{"/api/node/peers", &peers},
+		{"/api/storage/stats", &stats},
+	} {
+		if err := remoteNodeJSON(ctx, n, call.path, call.out); err != nil {
+			s.Error = err.Error()
+			return s
+		}
+	}
+	s.Online = true
+	s.NodeID, s.UptimeSeconds, s.Peers = status.NodeID, status.Uptime, peers.Count
+	s.BytesServed, s.BytesReceived = status.Bandwidth.Served, status.Bandwidth.Received
+	s.Sites, s.StorageBytes = stats.Sites, stats.StorageBytes
+	return s
+}
+
+// handleNodes lists this node and the registered remote nodes with their
+// status, asking every remote node at once, and their totals. Sites are
+// summed per node, so a site stored on two nodes counts twice.
+func (ws *WebServer) handleNodes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	remotes, err := ws.store.RemoteNodes()
+	if err != nil {
+		http.Error(w, "Failed to list nodes", http.StatusInternalServerError)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), remoteNodeTimeout)
+	defer cancel()
+	nodes := make([]nodeSummary, len(remotes)+1)
+	nodes[0] = ws.localNodeSummary()
+	var wg sync.WaitGroup
+	for i := range remotes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			nodes[i+1] = remoteNodeSummary(ctx, &remotes[i])
+		}()
+	}
+	wg.Wait()
+
+	var totals struct {
+		Nodes         int   `json:"nodes"`
+		Online        int   `json:"online"`
+		Peers         int   `json:"peers"`
+		Sites         int64 `json:"sites"`
+		StorageBytes  int64 `json:"storage_bytes"`
+		BytesServed   int64 `json:"bytes_served"`
+		BytesReceived int64 `json:"bytes_received"`
+	}
+	for _, n := range nodes {
+		totals.Nodes++
+		if !n.Online {
+			continue
+		}
+		totals.Online++
+		totals.Peers += n.Peers
+		totals.Sites += n.Sites
+		totals.StorageBytes += n.StorageBytes
+		totals.BytesServed += n.BytesServed
+		totals.BytesReceived += n.BytesReceived
+	}
+	writeJSON(w, map[string]interface{}{
+		"success": true,
+		"nodes":   nodes,
+		"totals":  totals,
+	})
+}
+
+// handleNodeProxy passes GET /api/nodes/<name>/<path> on to the registered
+// node's <path>, if it is one of remoteNodeReadPaths.
+func (ws *WebServer) handleNodeProxy(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name, path, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/nodes/"), "/")
+	path = "/" + path
+	if !store.ValidRemoteNodeName(name) {
+		http.Error(w, "Invalid node name", http.StatusBadRequest)
+		return
+	}
+	if !remoteNodeReadPaths[path] {
+		http.Error(w, "Not available for remote nodes", http.StatusNotFound)
+		return
+	}
+	n, err := ws.store.GetRemoteNode(name)
+	if err != nil {
+		http.Error(w, "Failed to look up node", http.StatusInternalServerError)
+		return
+	}
+	if n == nil {
+		http.Error(w, "Unknown node", http.StatusNotFound)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), remoteNodeTimeout)
+	defer cancel()
+	resp, err := remoteNodeGet(ctx, n, path, r.URL.RawQuery)
+	if err != nil {
+		http.Error(w, "Node unreachable: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "" {
+		w.Header().Set("Content-Type", ct)
+	}
+	w.WriteHeader(resp.StatusCode)
+	if _, err := io.Copy(w, io.LimitReader(resp.Body, maxRemoteNodeResponse)); err != nil {
+		ws.logger.Debug("Remote node response cut short", zap.String("node", name), zap.Error(err))
+	}
+}
+
+// handleAdminNodes lists the registered remote nodes (GET), registers one
+// (POST {"name", "url", "token"}) after checking that it accepts the token,
+// or removes one (POST {"name", "remove": true}). Tokens are never
+// returned.
+func (ws *WebServer) handleAdminNodes(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req struct {
+			Name   string `json:"name"`
+			URL    string `json:"url"`
+			Token  string `json:"token"`
+			Remove bool   `json:"remove"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if !store.ValidRemoteNodeName(req.Name) || req.Name == localNodeName {
+			http.Error(w, fmt.Sprintf("Node names are 1 to %d lowercase letters, digits, - and _, other than %q", store.MaxRemoteNodeName, localNodeName), http.StatusBadRequest)
+			return
+		}
+		if req.Remove {
+			found, err := ws.store.DeleteRemoteNode(req.Name)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if !found {
+				http.Error(w, "Unknown node", http.StatusNotFound)
+				return
+			}
+			break
+		}
+		nodeURL, err := normalizeNodeURL(req.URL)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		token := strings.TrimSpace(req.Token)
+		if token == "" || len(token) > store.MaxRemoteNodeToken {
+			http.Error(w, "The node's admin token is required", http.StatusBadRequest)
+			return
+		}
+		n := &store.RemoteNode{Name: req.Name, URL: nodeURL, Token: token, Added: time.Now().UTC()}
+		// Listing peers needs the admin token, so this checks both the URL
+		// and the token
+		ctx, cancel := context.WithTimeout(r.Context(), remoteNodeTimeout)
+		defer cancel()
+		var peers struct {
+			Count int `json:"count"`
+		}
+		if err := remoteNodeJSON(ctx, n, "/api/admin/peers", &peers); err != nil {
+			http.Error(w, "Node did not accept the URL and token: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := ws.store.PutRemoteNode(n); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, store.ErrRemoteNodesFull) {
+				status = http.StatusConflict
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	nodes, err := ws.store.RemoteNodes()
+	if err != nil {
+		http.Error(w, "Failed to list nodes", http.StatusInternalServerError)
+		return
+	}
+	if nodes == nil {
+		nodes = []store.RemoteNode{}
+	}
+	writeJSON(w, map[string]interface{}{
+		"success": true,
+		"nodes":   nodes,
+	})
+}
@@ -0,0 +1,106 @@
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestRemoteNodes(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	seederNode := newRemoteTestNode(t, ctx)
+	seeder := NewNodeServer(seederNode.Store, seederNode, zap.NewNop(), 0)
+	seeder.SetAdminToken("seeder-secret")
+	remote := httptest.NewServer(seeder.Handler())
+	defer remote.Close()
+
+	local := newRemoteTestNode(t, ctx)
+	ws := NewNodeServer(local.Store, local, zap.NewNop(), 0)
+	ws.SetAdminToken("local-secret")
+	h := ws.Handler()
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rejected := []struct {
+		name   string
+		token  string
+		body   string
+		status int
+	}{
+		{"no admin token", "", `{"name":"seeder","url":"` + remote.URL + `","token":"seeder-secret"}`, http.StatusUnauthorized},
+		{"wrong node token", "local-secret", `{"name":"seeder","url":"` + remote.URL + `","token":"guess"}`, http.StatusBadRequest},
+		{"no node token", "local-secret", `{"name":"seeder","url":"` + remote.URL + `"}`, http.StatusBadRequest},
+		{"reserved name", "local-secret", `{"name":"local","url":"` + remote.URL + `","token":"seeder-secret"}`, http.StatusBadRequest},
+		{"bad name", "local-secret", `{"name":"Seeder 1","url":"` + remote.URL + `","token":"seeder-secret"}`, http.StatusBadRequest},
+		{"not http", "local-secret", `{"name":"seeder","url":"file:///etc/passwd","token":"seeder-secret"}`, http.StatusBadRequest},
+		{"credentials in URL", "local-secret", `{"name":"seeder","url":"http://a:b@example.org","token":"seeder-secret"}`, http.StatusBadRequest},
+		{"unknown node removed", "local-secret", `{"name":"seeder","remove":true}`, http.StatusNotFound},
+	}
+	for _, tt := range rejected {
+		if rec := do(http.MethodPost, "/api/admin/nodes", tt.token, tt.body); rec.Code != tt.status {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, rec.Code, tt.status, rec.Body)
+		}
+	}
+
+	rec := do(http.MethodPost, "/api/admin/nodes", "local-secret", `{"name":"seeder","url":"`+remote.URL+`/","token":"seeder-secret"}`)
+	if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "seeder-secret") || !strings.Contains(rec.Body.String(), `"url":"`+remote.URL+`"`) {
+		t.Fatalf("register: status %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = do(http.MethodGet, "/api/nodes", "", "")
+	var resp struct {
+		Nodes []nodeSummary `json:"nodes"`
+		Total struct {
+			Nodes  int `json:"nodes"`
+			Online int `json:"online"`
+		} `json:"totals"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("/api/nodes: status %d, %v: %s", rec.Code, err, rec.Body)
+	}
+	if len(resp.Nodes) != 2 || !resp.Nodes[0].Local || resp.Nodes[1].Name != "seeder" || !resp.Nodes[1].Online ||
+		resp.Nodes[1].NodeID != seeder.node.Host.ID().String() || resp.Total.Nodes != 2 || resp.Total.Online != 2 {
+		t.Errorf("/api/nodes = %+v", resp)
+	}
+
+	proxied := []struct {
+		method string
+		path   string
+		status int
+	}{
+		{http.MethodGet, "/api/nodes/seeder/api/node/info", http.StatusOK},
+		{http.MethodGet, "/api/nodes/seeder/api/admin/peers", http.StatusNotFound},
+		{http.MethodPost, "/api/nodes/seeder/api/hosting/incoming", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/api/nodes/other/api/node/info", http.StatusNotFound},
+	}
+	for _, tt := range proxied {
+		rec := do(tt.method, tt.path, "", "")
+		if rec.Code != tt.status {
+			t.Errorf("%s %s: status %d, want %d", tt.method, tt.path, rec.Code, tt.status)
+		}
+		if tt.status == http.StatusOK && !strings.Contains(rec.Body.String(), seeder.node.Host.ID().String()) {
+			t.Errorf("%s: answer is not the remote node's: %s", tt.path, rec.Body)
+		}
+	}
+
+	if rec := do(http.MethodPost, "/api/admin/nodes", "local-secret", `{"name":"seeder","remove":true}`); rec.Code != http.StatusOK {
+		t.Errorf("remove: status %d: %s", rec.Code, rec.Body)
+	}
+	if rec := do(http.MethodGet, "/api/nodes/seeder/api/node/info", "", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("removed node still proxied: status %d", rec.Code)
+	}
+}
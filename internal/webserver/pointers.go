@@ -0,0 +1,137 @@
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"alxnet/internal/core"
+	"alxnet/internal/p2p"
+)
+
+// pointerJSON is how pointer records are returned by the API.
+type pointerJSON struct {
+	Owner  string    `json:"owner"` // owner ID (site ID of the owner key)
+	Name   string    `json:"name"`
+	Target string    `json:"target"`
+	Seq    uint64    `json:"seq"`
+	Time   time.Time `json:"time"`
+}
+
+func toPointerJSON(ownerID string, rec *core.PointerRecord) pointerJSON {
+	return pointerJSON{
+		Owner:  ownerID,
+		Name:   rec.Name,
+		Target: rec.Target,
+		Seq:    rec.Seq,
+		Time:   time.Unix(rec.TS, 0).UTC(),
+	}
+}
+
+// handleAPIPointers serves an owner's pointers: GET /api/pointers/{ownerID}
+// lists them and GET /api/pointers/{ownerID}/{name} returns one.
+func (ws *WebServer) handleAPIPointers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ownerID, name, _ := strings.Cut(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/pointers/"), "/"), "/")
+	if len(ownerID) != 64 || !isHex(ownerID) {
+		http.Error(w, "Invalid owner ID", http.StatusBadRequest)
+		return
+	}
+
+	if name == "" {
+		recs, err := ws.store.ListPointers(ownerID)
+		if err != nil {
+			http.Error(w, "Failed to list pointers", http.StatusInternalServerError)
+			return
+		}
+		out := make([]pointerJSON, 0, len(recs))
+		for i := range recs {
+			out = append(out, toPointerJSON(ownerID, &recs[i]))
+		}
+		writeJSON(w, map[string]interface{}{
+			"owner":    ownerID,
+			"pointers": out,
+		})
+		return
+	}
+
+	if !core.ValidPointerName(name) {
+		http.Error(w, "Invalid pointer name", http.StatusBadRequest)
+		return
+	}
+	rec, err := ws.store.GetPointer(ownerID, name)
+	if err != nil {
+		http.Error(w, "Failed to get pointer", http.StatusInternalServerError)
+		return
+	}
+	if rec == nil {
+		http.Error(w, "Pointer not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, toPointerJSON(ownerID, rec))
+}
+
+// handleWalletPointer sets a pointer owned by one of the wallet's sites,
+// signed with that site's key, and publishes it.
+func (ws *WebServer) handleWalletPointer(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		WalletData         string `json:"wallet_data"`
+		Mnemonic           string `json:"mnemonic"`
+		MnemonicPassphrase string `json:"mnemonic_passphrase"`
+		Label              string `json:"label"`
+		Name               string `json:"name"`
+		Target             string `json:"target"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxCommentRequestBody)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	walletData, master, ok := ws.unlockWallet(w, r, req.WalletData, req.Mnemonic, req.MnemonicPassphrase)
+	if !ok {
+		return
+	}
+	defer clear(master)
+	if _, exists := walletData.Sites[req.Label]; !exists {
+		writeWalletError(w, http.StatusNotFound, "Site not found in wallet")
+		return
+	}
+	if !core.ValidPointerName(req.Name) {
+		writeWalletError(w, http.StatusBadRequest, "Pointer names are 1-64 characters of a-z, 0-9, '.', '_' and '-'")
+		return
+	}
+	meta, _, priv, err := walletData.EnsureSite(master, req.Label)
+	if err != nil {
+		http.Error(w, "Failed to get site", http.StatusInternalServerError)
+		return
+	}
+
+	seq, err := ws.node.NextPointerSeq(meta.SiteID, req.Name)
+	if err != nil {
+		http.Error(w, "Failed to read pointer", http.StatusInternalServerError)
+		return
+	}
+	rec, err := p2p.BuildPointer(priv, req.Name, strings.ToLower(req.Target), seq)
+	if err != nil {
+		writeWalletError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	if err := ws.node.PublishPointer(ctx, rec); err != nil {
+		writeWalletError(w, http.StatusBadGateway, "Failed to publish pointer: "+err.Error())
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"success": true,
+		"pointer": toPointerJSON(meta.SiteID, rec),
+	})
+}
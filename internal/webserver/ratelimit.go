@@ -0,0 +1,206 @@
+package webserver
+
+import (
+	"container/list"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"alxnet/internal/p2p"
+
+	"go.uber.org/zap"
+)
+
+// Every API request counts against its client's address, and a client
+// over the request limit of the minute is answered 429. Failed logins, a
+// wrong mnemonic, keystore passphrase or token, count separately: after
+// maxFailures of them the client is locked out of logging in for
+// baseLockout, doubling with every further failure up to maxLockout, and
+// each failure is audited. Failures are only forgotten with time, one per
+// failureDecay without another: a successful login does not clear them,
+// since it may be to the attacker's own wallet. Clients are told apart by
+// their IP address only, so behind a reverse proxy they share the proxy's.
+// The limiter tracks at most maxTrackedClients, forgetting the least
+// recently seen first.
+
+// API limits
+const (
+	DefaultAPIRateLimit     = 600 // requests per minute per client
+	DefaultMaxLoginFailures = 5   // failed logins before a lockout
+)
+
+const (
+	baseLockout       = 30 * time.Second
+	maxLockout        = time.Hour
+	failureDecay      = 10 * time.Minute // quiet time that forgets one failure
+	maxTrackedClients = 10000            // forget the least recently seen beyond this
+)
+
+// clientLimits keeps the request count and failed logins of each client.
+// A nil *clientLimits allows everything.
+type clientLimits struct {
+	mu          sync.Mutex
+	perMinute   int // 0 for no request limit
+	maxFailures int
+	clients     map[string]*list.Element // of *clientState, in order
+	order       *list.List               // most recently seen first
+}
+
+type clientState struct {
+	addr        string
+	window      time.Time // start of the minute requests are counted in
+	requests    int
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+func newClientLimits() *clientLimits {
+	return &clientLimits{
+		perMinute:   DefaultAPIRateLimit,
+		maxFailures: DefaultMaxLoginFailures,
+		clients:     make(map[string]*list.Element),
+		order:       list.New(),
+	}
+}
+
+// state returns the state of client, forgetting the least recently seen
+// client if there is no room for it. The caller holds l.mu.
+func (l *clientLimits) state(client string) *clientState {
+	if e, ok := l.clients[client]; ok {
+		l.order.MoveToFront(e)
+		return e.Value.(*clientState)
+	}
+	for len(l.clients) >= maxTrackedClients {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.clients, oldest.Value.(*clientState).addr)
+	}
+	c := &clientState{addr: client}
+	l.clients[client] = l.order.PushFront(c)
+	return c
+}
+
+// allow counts a request from client and returns how long it must wait
+// if it is over the limit.
+func (l *clientLimits) allow(client string, now time.Time) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.perMinute <= 0 {
+		return true, 0
+	}
+	c := l.state(client)
+	if now.Sub(c.window) >= time.Minute {
+		c.window, c.requests = now, 0
+	}
+	if c.requests >= l.perMinute {
+		return false, c.window.Add(time.Minute).Sub(now)
+	}
+	c.requests++
+	return true, 0
+}
+
+// lockedOut returns how long client may not log in for.
+func (l *clientLimits) lockedOut(client string, now time.Time) time.Duration {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e, ok := l.clients[client]; ok && now.Before(e.Value.(*clientState).lockedUntil) {
+		return e.Value.(*clientState).lockedUntil.Sub(now)
+	}
+	return 0
+}
+
+// fail counts a failed login of client and returns the lockout it starts,
+// if any.
+func (l *clientLimits) fail(client string, now time.Time) time.Duration {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	c := l.state(client)
+	if c.failures > 0 {
+		c.failures = max(c.failures-int(now.Sub(c.lastFailure)/failureDecay), 0)
+	}
+	c.failures++
+	c.lastFailure = now
+	if c.failures < l.maxFailures {
+		return 0
+	}
+	d := maxLockout
+	if n := c.failures - l.maxFailures; n < 7 {
+		d = min(baseLockout<<n, maxLockout)
+	}
+	c.lockedUntil = now.Add(d)
+	return d
+}
+
+// SetAPILimits sets how many API requests a client may make per minute, 0
+// for no limit, and after how many failed logins it is locked out.
+func (ws *WebServer) SetAPILimits(perMinute, maxFailures int) {
+	if ws.limits == nil {
+		ws.limits = newClientLimits()
+	}
+	ws.limits.mu.Lock()
+	ws.limits.perMinute, ws.limits.maxFailures = perMinute, max(maxFailures, 1)
+	ws.limits.mu.Unlock()
+}
+
+// clientAddr returns the IP address a request came from.
+func clientAddr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// rateLimit answers requests over their client's limit with 429.
+func (ws *WebServer) rateLimit(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if ok, wait := ws.limits.allow(clientAddr(r), time.Now()); !ok {
+			setRetryAfter(w, wait)
+			http.Error(w, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// loginLockedOut answers a login attempt from a locked out client and
+// reports whether it did.
+func (ws *WebServer) loginLockedOut(w http.ResponseWriter, r *http.Request) bool {
+	wait := ws.limits.lockedOut(clientAddr(r), time.Now())
+	if wait <= 0 {
+		return false
+	}
+	setRetryAfter(w, wait)
+	writeWalletError(w, http.StatusTooManyRequests, fmt.Sprintf("Too many failed attempts. Try again in %s.", wait.Round(time.Second)))
+	return true
+}
+
+// loginFailed counts and audits a failed login.
+func (ws *WebServer) loginFailed(r *http.Request, reason string) {
+	client := clientAddr(r)
+	if d := ws.limits.fail(client, time.Now()); d > 0 {
+		reason = fmt.Sprintf("%s; locked out for %s", reason, d)
+	}
+	if ws.node != nil {
+		ws.node.RecordAudit(p2p.AuditEntry{Kind: p2p.AuditAuth, Client: client, Reason: reason + " at " + r.URL.Path})
+	} else if ws.logger != nil {
+		ws.logger.Warn("login failed", zap.String("client", client), zap.String("path", r.URL.Path), zap.String("reason", reason))
+	}
+}
+
+func setRetryAfter(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int((wait+time.Second-1)/time.Second)))
+}
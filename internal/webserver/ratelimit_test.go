@@ -0,0 +1,109 @@
+package webserver
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientLimits(t *testing.T) {
+	l := newClientLimits()
+	l.perMinute, l.maxFailures = 3, 2
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.allow("10.0.0.1", now); !ok {
+			t.Fatalf("request %d refused", i+1)
+		}
+	}
+	if ok, wait := l.allow("10.0.0.1", now.Add(10*time.Second)); ok || wait != 50*time.Second {
+		t.Errorf("4th request = %v, wait %s", ok, wait)
+	}
+	if ok, _ := l.allow("10.0.0.2", now); !ok {
+		t.Error("other client refused")
+	}
+	if ok, _ := l.allow("10.0.0.1", now.Add(time.Minute)); !ok {
+		t.Error("request in the next minute refused")
+	}
+
+	// Lockouts start at maxFailures and double from there
+	tests := []time.Duration{0, baseLockout, 2 * baseLockout, 4 * baseLockout}
+	for i, want := range tests {
+		if got := l.fail("10.0.0.3", now); got != want {
+			t.Errorf("failure %d: lockout %s, want %s", i+1, got, want)
+		}
+	}
+	if got := l.lockedOut("10.0.0.3", now.Add(time.Minute)); got != time.Minute {
+		t.Errorf("lockedOut() = %s", got)
+	}
+	for i := 0; i < 20; i++ {
+		l.fail("10.0.0.3", now)
+	}
+	if got := l.lockedOut("10.0.0.3", now); got != maxLockout {
+		t.Errorf("lockout after many failures = %s, want %s", got, maxLockout)
+	}
+	// Only quiet time forgets failures, one per failureDecay
+	later := now.Add(maxLockout)
+	if got := l.fail("10.0.0.3", later); got == 0 {
+		t.Error("failures forgotten before they decayed")
+	}
+	if got := l.fail("10.0.0.3", later.Add(30*failureDecay)); got != 0 {
+		t.Errorf("first failure after the others decayed locked out for %s", got)
+	}
+}
+
+func TestClientLimitsBounded(t *testing.T) {
+	l := newClientLimits()
+	now := time.Now()
+	l.fail("first", now)
+	for i := 0; i < maxTrackedClients; i++ {
+		if i == maxTrackedClients/2 {
+			l.fail("first", now) // seen again, so not the oldest
+		}
+		l.allow(fmt.Sprintf("10.%d.%d.%d", i>>16, i>>8&0xff, i&0xff), now)
+	}
+	if len(l.clients) != maxTrackedClients || l.order.Len() != maxTrackedClients {
+		t.Fatalf("tracking %d clients (%d in order), want %d", len(l.clients), l.order.Len(), maxTrackedClients)
+	}
+	if _, ok := l.clients["first"]; !ok {
+		t.Error("a recently seen client was forgotten")
+	}
+	if _, ok := l.clients["10.0.0.0"]; ok {
+		t.Error("the least recently seen client was kept")
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	ws := &WebServer{limits: newClientLimits()}
+	ws.SetAdminToken("secret")
+	ws.SetAPILimits(100, 3)
+	mux := http.NewServeMux()
+	ws.handleAPI(mux, "/api/admin/peers", func(w http.ResponseWriter, r *http.Request) {}, apiDoc{Methods: "GET", Admin: true})
+
+	do := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/admin/peers", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+	for i := 0; i < 3; i++ {
+		if rec := do("guess"); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("guess %d: status %d", i+1, rec.Code)
+		}
+	}
+	rec := do("secret")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "30" {
+		t.Errorf("locked out: status %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	// Over the request limit every endpoint answers 429
+	ws.limits = newClientLimits()
+	ws.SetAPILimits(1, 3)
+	do("secret")
+	if rec := do("secret"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("over the rate limit: status %d", rec.Code)
+	}
+}
@@ -0,0 +1,113 @@
+package webserver
+
+import (
+	"bytes"
+	"html/template"
+	"mime"
+	"path"
+
+	"alxnet/internal/core"
+	"alxnet/internal/markdown"
+)
+
+// readerParam is the MIME type parameter that opts a text file into reader
+// mode: a file saved as "text/markdown; render=html" is served as a styled
+// HTML page instead of its source. The parameter is part of the signed file
+// record, so only the site owner can turn it on.
+const readerParam = "render"
+
+// readerTemplate is the page a reader-mode file is served in.
+var readerTemplate = template.Must(template.New("reader").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="UTF-8">
+<meta name="viewport" content="width=device-width, initial-scale=1.0">
+<meta http-equiv="Content-Security-Policy" content="default-src 'none'; style-src 'unsafe-inline'; img-src 'self' data:{{if .RemoteImages}} http: https:{{end}}; base-uri 'none'; form-action 'none'">
+<title>{{.Title}}</title>
+<style>
+:root { color-scheme: light dark; --text: #1f2328; --muted: #59636e; --border: #d1d9e0; --inset: #f6f8fa; --link: #0969da; }
+@media (prefers-color-scheme: dark) {
+  :root { --text: #e6edf3; --muted: #9198a1; --border: #3d444d; --inset: #151b23; --link: #4493f8; }
+  body { background: #0d1117; }
+}
+body { margin: 0; color: var(--text); font: 17px/1.65 Georgia, 'Times New Roman', serif; }
+main { max-width: 42rem; margin: 0 auto; padding: 2.5rem 1.25rem 4rem; }
+h1, h2, h3, h4, h5, h6 { font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif; line-height: 1.25; margin: 1.8em 0 0.6em; }
+h1 { font-size: 2em; margin-top: 0; }
+h2 { font-size: 1.5em; border-bottom: 1px solid var(--border); padding-bottom: 0.3em; }
+p, ul, ol, blockquote, pre, table { margin: 0 0 1em; }
+a { color: var(--link); }
+a.remote-image::before { content: "\1F5BC\FE0E  "; }
+img { max-width: 100%; }
+hr { border: 0; border-top: 1px solid var(--border); margin: 2em 0; }
+blockquote { color: var(--muted); border-left: 4px solid var(--border); padding: 0 1em; }
+code, pre { font: 0.85em/1.5 ui-monospace, SFMono-Regular, Menlo, Consolas, monospace; background: var(--inset); border-radius: 6px; }
+code { padding: 0.15em 0.35em; }
+pre { padding: 1em; overflow-x: auto; }
+pre code { padding: 0; background: none; font-size: 1em; }
+pre.text { white-space: pre-wrap; word-wrap: break-word; background: none; padding: 0; font-size: 0.95em; }
+table { border-collapse: collapse; display: block; overflow-x: auto; }
+th, td { border: 1px solid var(--border); padding: 0.4em 0.8em; }
+</style>
+</head>
+<body>
+<main>
+{{.Body}}</main>
+</body>
+</html>
+`))
+
+// readerMediaType returns the media type of a file record's MIME type if it
+// opts into reader mode.
+func readerMediaType(mimeType string) (string, bool) {
+	mediaType, params, err := mime.ParseMediaType(mimeType)
+	if err != nil || params[readerParam] != "html" {
+		return "", false
+	}
+	switch mediaType {
+	case "text/markdown", "text/x-markdown", "text/plain":
+		return mediaType, true
+	}
+	return "", false
+}
+
+// readerFile reports whether the file at filePath is served in reader mode,
+// and its media type. The file's signed record decides; drafts get one
+// only when they are published.
+func (ws *WebServer) readerFile(siteID, filePath string) (string, bool) {
+	data, err := ws.store.GetFileRecordByPath(siteID, filePath)
+	if err != nil {
+		return "", false // sites synced from peers may lack file records
+	}
+	var rec core.FileRecord
+	if err := core.UnmarshalCBOR(data, &rec); err != nil {
+		return "", false
+	}
+	return readerMediaType(rec.MimeType)
+}
+
+// renderReader renders a Markdown or plain text file as an HTML page, titled
+// by its first heading or its file name. Its content security policy keeps
+// it from running scripts or loading anything but its own site's files,
+// and images from other hosts if remoteImages is set.
+func renderReader(mediaType, filePath string, content []byte, remoteImages bool) ([]byte, error) {
+	page := struct {
+		Title        string
+		Body         template.HTML
+		RemoteImages bool
+	}{Title: path.Base(filePath), RemoteImages: remoteImages}
+	if mediaType == "text/plain" {
+		page.Body = template.HTML(markdown.Text(content))
+	} else {
+		doc := markdown.RenderWith(content, markdown.Options{RemoteImages: remoteImages})
+		if doc.Title != "" {
+			page.Title = doc.Title
+		}
+		page.Body = template.HTML(doc.HTML)
+	}
+	var b bytes.Buffer
+	if err := readerTemplate.Execute(&b, page); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
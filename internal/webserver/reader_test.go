@@ -0,0 +1,75 @@
+package webserver
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"strings"
+	"testing"
+
+	"alxnet/internal/core"
+	"alxnet/internal/publisher"
+	"alxnet/internal/store"
+
+	"go.uber.org/zap"
+)
+
+func TestReaderMode(t *testing.T) {
+	s, err := store.NewMemory()
+	if err != nil {
+		t.Fatalf("NewMemory: %v", err)
+	}
+	defer s.Close()
+	ws := &WebServer{store: s}
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	p := publisher.New(s, nil, zap.NewNop())
+	files := []struct{ path, content, mimeType string }{
+		{"index.md", "# My <Notes>\n\nSee [the list](list.md).\n\n![logo](https://tracker.example/logo.png)", "text/markdown; render=html"},
+		{"list.md", "- one\n- two", "text/markdown"},
+		{"todo.txt", "buy <milk>", "text/plain; charset=utf-8; render=html"},
+	}
+	for _, f := range files {
+		if _, _, err := p.AddFile(ctx, priv, f.path, []byte(f.content), f.mimeType); err != nil {
+			t.Fatalf("AddFile(%s): %v", f.path, err)
+		}
+	}
+	siteID := core.SiteIDFromPub(pub)
+
+	content, mimeType, err := ws.getWebsiteFile(ctx, siteID, "index.md")
+	if err != nil || mimeType != "text/html; charset=utf-8" ||
+		!strings.Contains(string(content), "<title>My &lt;Notes&gt;</title>") ||
+		!strings.Contains(string(content), `<a href="list.md">the list</a>`) {
+		t.Errorf("index.md = %q %s %v", content, mimeType, err)
+	}
+	// Remote images are linked, not loaded, and the policy forbids them
+	if strings.Contains(string(content), `<img`) || !strings.Contains(string(content), `img-src 'self' data:;`) {
+		t.Errorf("index.md loads remote images: %q", content)
+	}
+	ws.ui = &ui{remoteImages: true}
+	if content, _, _ := ws.getWebsiteFile(ctx, siteID, "index.md"); !strings.Contains(string(content), `<img src="https://tracker.example/logo.png"`) ||
+		!strings.Contains(string(content), `img-src 'self' data: http: https:;`) {
+		t.Errorf("index.md with remote images = %q", content)
+	}
+	ws.ui = nil
+	if content, mimeType, _ := ws.getWebsiteFile(ctx, siteID, "todo.txt"); mimeType != "text/html; charset=utf-8" ||
+		!strings.Contains(string(content), "<title>todo.txt</title>") || !strings.Contains(string(content), "buy &lt;milk&gt;") {
+		t.Errorf("todo.txt = %q %s", content, mimeType)
+	}
+	// Without the parameter the source is served as is
+	if content, _, _ := ws.getWebsiteFile(ctx, siteID, "list.md"); string(content) != "- one\n- two" {
+		t.Errorf("list.md = %q, want its source", content)
+	}
+
+	// Saving an edit keeps the published page rendered
+	if _, err := p.SaveFile(priv, "index.md", []byte("# Draft"), "text/markdown; render=html"); err != nil {
+		t.Fatalf("SaveFile: %v", err)
+	}
+	if content, _, _ := ws.getWebsiteFile(ctx, siteID, "index.md"); !strings.Contains(string(content), "<title>My &lt;Notes&gt;</title>") {
+		t.Errorf("index.md with a draft = %q", content)
+	}
+}
@@ -0,0 +1,81 @@
+package webserver
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"alxnet/internal/store"
+)
+
+// defaultRecordLimit is how many records a site record listing returns
+// without a limit parameter.
+const defaultRecordLimit = 100
+
+// handleAPIRecords lists the update records stored for a site by sequence
+// number: GET /api/records/{siteID}?from=N lists from seq N upward,
+// ?before=N lists below seq N newest first, and without either the newest
+// come first. limit is 1-1000 (default 100).
+func (ws *WebServer) handleAPIRecords(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	siteID := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/records/"), "/")
+	if len(siteID) != 64 || !isHex(siteID) {
+		http.Error(w, "Invalid site ID", http.StatusBadRequest)
+		return
+	}
+	q := r.URL.Query()
+	limit := defaultRecordLimit
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, store.MaxSiteRecords)
+	}
+	if q.Has("from") && q.Has("before") {
+		http.Error(w, "from and before are exclusive", http.StatusBadRequest)
+		return
+	}
+	seq := func(name string) (uint64, bool) {
+		n, err := strconv.ParseUint(q.Get(name), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid "+name, http.StatusBadRequest)
+			return 0, false
+		}
+		return n, true
+	}
+
+	var records []store.RecordRef
+	var err error
+	switch {
+	case q.Has("from"):
+		from, ok := seq("from")
+		if !ok {
+			return
+		}
+		records, err = ws.store.SiteRecords(siteID, from, limit)
+	case q.Has("before"):
+		before, ok := seq("before")
+		if !ok {
+			return
+		}
+		records, err = ws.store.SiteRecordsBefore(siteID, before, limit)
+	default:
+		records, err = ws.store.SiteRecordsBefore(siteID, 0, limit)
+	}
+	if err != nil {
+		http.Error(w, "Failed to list records", http.StatusInternalServerError)
+		return
+	}
+	if records == nil {
+		records = []store.RecordRef{}
+	}
+	writeJSON(w, map[string]interface{}{
+		"site_id": siteID,
+		"records": records,
+	})
+}
@@ -0,0 +1,216 @@
+package webserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"alxnet/internal/core"
+	"alxnet/internal/p2p"
+
+	peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Sites this node does not store are rendered from peers: a page load asks
+// them for the site's manifest, and other files resolve through the
+// manifest or, when it is not cached, their signed file record.
+const (
+	// remoteManifestTTL is how long a verified manifest from peers is
+	// used before they are asked again.
+	remoteManifestTTL = time.Minute
+	// maxRemoteManifests caps the manifests kept for remote sites.
+	maxRemoteManifests = 256
+)
+
+// remoteManifest is a verified manifest of a site this node does not store.
+type remoteManifest struct {
+	manifest *core.WebsiteManifest // nil if no peer asked had one
+	peer     peer.ID               // who sent it
+	fetched  time.Time
+}
+
+// remoteManifests caches manifests of remote sites by site ID.
+type remoteManifests struct {
+	mu    sync.Mutex
+	sites map[string]remoteManifest
+	ttl   time.Duration // remoteManifestTTL if zero
+}
+
+// maxAge returns how long a manifest is used.
+func (c *remoteManifests) maxAge() time.Duration {
+	if c.ttl == 0 {
+		return remoteManifestTTL
+	}
+	return c.ttl
+}
+
+func (c *remoteManifests) get(siteID string, now time.Time) (remoteManifest, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m, ok := c.sites[siteID]
+	if !ok || now.Sub(m.fetched) > c.maxAge() {
+		return remoteManifest{}, false
+	}
+	return m, true
+}
+
+func (c *remoteManifests) put(siteID string, m remoteManifest) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.sites == nil {
+		c.sites = make(map[string]remoteManifest)
+	}
+	if _, ok := c.sites[siteID]; !ok && len(c.sites) >= maxRemoteManifests {
+		for id, old := range c.sites {
+			if m.fetched.Sub(old.fetched) > c.maxAge() {
+				delete(c.sites, id)
+			}
+		}
+		for id := range c.sites {
+			if len(c.sites) < maxRemoteManifests {
+				break
+			}
+			delete(c.sites, id)
+		}
+	}
+	c.sites[siteID] = m
+}
+
+// remoteManifest returns the current manifest of a site this node does not
+// store. It asks prefer and other connected peers, up to fetchPeers, at
+// once and keeps the verified manifest with the highest sequence number,
+// so one peer holding an old version cannot roll the site back.
+func (ws *WebServer) remoteManifest(ctx context.Context, siteID string, prefer peer.ID) (remoteManifest, error) {
+	if m, ok := ws.remote.get(siteID, time.Now()); ok {
+		if m.manifest == nil {
+			return remoteManifest{}, p2p.ErrRecordNotFound
+		}
+		return m, nil
+	}
+	candidates := ws.peerCandidates(prefer)
+	if len(candidates) == 0 {
+		return remoteManifest{}, errors.New("no connected peers")
+	}
+
+	type answer struct {
+		m    *core.WebsiteManifest
+		peer peer.ID
+		err  error
+	}
+	answers := make(chan answer, len(candidates))
+	for _, p := range candidates {
+		go func() {
+			m, err := ws.node.RequestManifest(ctx, peer.AddrInfo{ID: p}, siteID)
+			answers <- answer{m, p, err}
+		}()
+	}
+	var best remoteManifest
+	var lastErr error
+	notFound := 0
+	for range candidates {
+		a := <-answers
+		if a.err != nil {
+			lastErr = a.err
+			if errors.Is(a.err, p2p.ErrRecordNotFound) {
+				notFound++
+			}
+			continue
+		}
+		if best.manifest == nil || a.m.Seq > best.manifest.Seq {
+			best = remoteManifest{manifest: a.m, peer: a.peer}
+		}
+	}
+	best.fetched = time.Now()
+	if best.manifest == nil {
+		// Remember single-file sites too, so their pages do not wait for
+		// peers to answer again
+		if notFound == len(candidates) {
+			ws.remote.put(siteID, best)
+		}
+		return remoteManifest{}, lastErr
+	}
+	ws.remote.put(siteID, best)
+	return best, nil
+}
+
+// getRemoteFile fetches filePath of a site this node does not store. The
+// main page of a site without a manifest is the content its head points at.
+func (ws *WebServer) getRemoteFile(ctx context.Context, siteID, filePath string) ([]byte, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, remoteFetchTimeout)
+	defer cancel()
+
+	// Websites published without an update record have no head
+	head, headErr := ws.lookupHead(ctx, siteID)
+	page := filePath == "" || filePath == "/" || filePath == "index.html"
+
+	// Pages fetch the manifest; other files use it only if it is cached
+	var m remoteManifest
+	if page {
+		m, _ = ws.remoteManifest(ctx, siteID, head.Peer)
+	} else {
+		m, _ = ws.remote.get(siteID, time.Now())
+	}
+
+	var contentCID string
+	var err error
+	prefer := head.Peer
+	switch {
+	case m.manifest != nil:
+		prefer = m.peer
+		if page {
+			filePath = m.manifest.MainFile
+		}
+		if cid, ok := m.manifest.Files[filePath]; ok {
+			contentCID = cid
+			break
+		}
+		// The cached manifest may predate the file
+		if contentCID, err = ws.remoteFileCID(ctx, siteID, filePath, prefer); err != nil {
+			return nil, "", err
+		}
+	case page && headErr != nil:
+		return nil, "", headErr
+	case page:
+		// Single-file site, or peers that do not serve manifests
+		content, _, err := ws.siteLoader(head.Peer).fetch(ctx, head.ContentCID)
+		if err != nil {
+			return nil, "", err
+		}
+		return content, "text/html", nil
+	default:
+		if contentCID, err = ws.remoteFileCID(ctx, siteID, filePath, prefer); err != nil {
+			return nil, "", err
+		}
+	}
+
+	content, _, err := ws.siteLoader(prefer).fetch(ctx, contentCID)
+	if err != nil {
+		return nil, "", err
+	}
+	return content, ws.getMimeType(filePath), nil
+}
+
+// remoteFileCID resolves one file of a remote site through its signed file
+// record, asking prefer and then other connected peers.
+func (ws *WebServer) remoteFileCID(ctx context.Context, siteID, filePath string, prefer peer.ID) (string, error) {
+	if err := core.ValidateFilePath(filePath); err != nil {
+		return "", err
+	}
+	lastErr := errors.New("no connected peers")
+	for _, p := range ws.peerCandidates(prefer) {
+		rec, err := ws.node.RequestFileRecord(ctx, peer.AddrInfo{ID: p}, siteID, filePath)
+		if err == nil {
+			return rec.ContentCID, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if errors.Is(lastErr, p2p.ErrRecordNotFound) {
+		return "", fmt.Errorf("file not found in website: %s", filePath)
+	}
+	return "", lastErr
+}
@@ -0,0 +1,143 @@
+package webserver
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"alxnet/internal/core"
+	"alxnet/internal/p2p"
+	"alxnet/internal/publisher"
+	"alxnet/internal/store"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"go.uber.org/zap"
+)
+
+func newRemoteTestNode(t *testing.T, ctx context.Context) *p2p.Node {
+	t.Helper()
+	db, err := store.NewMemory()
+	if err != nil {
+		t.Fatalf("NewMemory: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	n, err := p2p.New(ctx, db, "/ip4/127.0.0.1/tcp/0", nil, nil)
+	if err != nil {
+		t.Fatalf("p2p.New: %v", err)
+	}
+	t.Cleanup(func() { n.Host.Close() })
+	return n
+}
+
+func TestRemoteWebsite(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	seeder, client := newRemoteTestNode(t, ctx), newRemoteTestNode(t, ctx)
+	if err := client.Host.Connect(ctx, peer.AddrInfo{ID: seeder.Host.ID(), Addrs: seeder.Host.Addrs()}); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	// A website published without an update record, so peers know no head
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	siteID := core.SiteIDFromPub(pub)
+	p := publisher.New(seeder.Store, nil, nil)
+	for _, f := range []struct{ path, body, mimeType string }{
+		{"index.html", "<h1>remote page</h1>", "text/html"},
+		{"css/site.css", "h1 { color: red }", "text/css"},
+	} {
+		if _, err := p.SaveFile(priv, f.path, []byte(f.body), f.mimeType); err != nil {
+			t.Fatalf("SaveFile(%s): %v", f.path, err)
+		}
+	}
+	if _, err := p.PublishWebsite(ctx, priv); err != nil {
+		t.Fatalf("PublishWebsite: %v", err)
+	}
+	if _, err := p.SaveFile(priv, "draft.html", []byte("<h1>draft</h1>"), "text/html"); err != nil {
+		t.Fatalf("SaveFile: %v", err)
+	}
+
+	ws := NewBrowserServer(client.Store, client, zap.NewNop(), 0)
+	t.Cleanup(ws.cancel)
+	h := ws.Handler()
+	tests := []struct {
+		name, path string
+		status     int
+		body       string
+	}{
+		// Before the page, the asset resolves through its file record
+		{"asset", "/" + siteID + "/css/site.css", http.StatusOK, "color: red"},
+		{"page", "/" + siteID + "/", http.StatusOK, "remote page"},
+		{"unpublished file", "/" + siteID + "/draft.html", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != tt.status || !strings.Contains(rec.Body.String(), tt.body) {
+			t.Errorf("%s: GET %s = %d %q, want %d containing %q", tt.name, tt.path, rec.Code, rec.Body.String(), tt.status, tt.body)
+		}
+		if tt.status == http.StatusOK && rec.Header().Get("X-AlxNet-Source") != "remote" {
+			t.Errorf("%s: not served from peers", tt.name)
+		}
+	}
+	if m, ok := ws.remote.get(siteID, time.Now()); !ok || m.manifest == nil || len(m.manifest.Files) != 2 {
+		t.Errorf("cached manifest = %+v, %v", m, ok)
+	}
+	if client.Store.HasWebsiteManifest(siteID) {
+		t.Error("browsing a remote site stored its manifest")
+	}
+}
+
+func TestResolveNameFromPeers(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	seeder, other, client := newRemoteTestNode(t, ctx), newRemoteTestNode(t, ctx), newRemoteTestNode(t, ctx)
+	const siteID = "aa00000000000000000000000000000000000000000000000000000000000000"
+	const otherID = "bb00000000000000000000000000000000000000000000000000000000000000"
+	for _, r := range []struct {
+		n      *p2p.Node
+		name   string
+		siteID string
+	}{
+		{seeder, "blog", siteID},
+		{seeder, "shop", siteID},
+		{other, "shop", otherID},
+	} {
+		if err := r.n.Store.RegisterSiteName(r.name, r.siteID); err != nil {
+			t.Fatalf("RegisterSiteName: %v", err)
+		}
+	}
+	// Only known from the peerstore, as after a restart
+	for _, n := range []*p2p.Node{seeder, other} {
+		client.Host.Peerstore().AddAddrs(n.Host.ID(), n.Host.Addrs(), time.Hour)
+	}
+
+	ws := NewBrowserServer(client.Store, client, zap.NewNop(), 0)
+	t.Cleanup(ws.cancel)
+	tests := []struct {
+		name, lookup string
+		want         string
+		source       string
+	}{
+		{"found by discovery", "blog.bn", siteID, nameSourcePeers},
+		{"cached", "blog", siteID, nameSourcePeers},
+		{"peers disagree", "shop", "", ""},
+		{"unknown", "nobody", "", ""},
+	}
+	for _, tt := range tests {
+		got, source, err := ws.resolveName(ctx, tt.lookup)
+		if got != tt.want || source != tt.source || (err == nil) != (tt.want != "") {
+			t.Errorf("%s: resolveName(%q) = %q, %q, %v; want %q, %q", tt.name, tt.lookup, got, source, err, tt.want, tt.source)
+		}
+	}
+	if n, ok := ws.names.get("nobody", time.Now()); !ok || n.siteID != "" {
+		t.Errorf("unknown name cached as %+v, %v", n, ok)
+	}
+	if _, err := client.Store.ResolveDomain("blog"); err == nil {
+		t.Error("a name resolved by peers was registered locally")
+	}
+}
@@ -0,0 +1,195 @@
+package webserver
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"alxnet/internal/store"
+)
+
+// Requests to the management APIs are checked against a capability: GET
+// requests need read, other requests need the server's write capability
+// (publish on the wallet server, admin on the node server), and the
+// /api/admin endpoints need admin. The admin token has every capability;
+// tokens issued at /api/admin/tokens have those of their role. Only
+// /api/admin needs a token unless SetRequireToken is on.
+
+// capability is what a request may do. Each includes the ones before it.
+type capability int
+
+const (
+	capRead    capability = iota // status, listings and statistics
+	capPublish                   // wallet operations: sign, draft and publish
+	capAdmin                     // node operations and /api/admin
+)
+
+func (c capability) String() string {
+	switch c {
+	case capRead:
+		return "read"
+	case capPublish:
+		return "publish"
+	}
+	return "admin"
+}
+
+// Roles of issued tokens
+const (
+	RoleViewer    = "viewer"    // read
+	RolePublisher = "publisher" // read and publish
+	RoleAdmin     = "admin"     // everything, like the admin token
+)
+
+// roleCapability is the highest capability of each role.
+var roleCapability = map[string]capability{
+	RoleViewer:    capRead,
+	RolePublisher: capPublish,
+	RoleAdmin:     capAdmin,
+}
+
+// SetRequireToken makes every API endpoint of the server require a token
+// whose role grants the request's capability; otherwise only /api/admin
+// does.
+func (ws *WebServer) SetRequireToken(require bool) {
+	ws.requireToken = require
+}
+
+// needs returns the capability a request with method to an endpoint
+// documented as doc needs.
+func (ws *WebServer) needs(method string, doc apiDoc) capability {
+	switch {
+	case doc.Admin:
+		return capAdmin
+	case method == http.MethodGet || method == http.MethodHead:
+		return capRead
+	}
+	return ws.writeCap
+}
+
+// authorize passes requests on to h if they carry a token with the
+// capability they need.
+func (ws *WebServer) authorize(h http.HandlerFunc, doc apiDoc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !doc.Admin && !ws.requireToken {
+			h(w, r)
+			return
+		}
+		if ws.adminToken == "" {
+			http.Error(w, "Admin API disabled", http.StatusForbidden)
+			return
+		}
+		if wait := ws.limits.lockedOut(clientAddr(r), time.Now()); wait > 0 {
+			setRetryAfter(w, wait)
+			http.Error(w, "Too many failed attempts", http.StatusTooManyRequests)
+			return
+		}
+		have, ok := ws.tokenCapability(r)
+		if !ok {
+			if r.Header.Get("Authorization") != "" {
+				ws.loginFailed(r, "invalid API token")
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="alxnet-admin"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if need := ws.needs(r.Method, doc); have < need {
+			http.Error(w, fmt.Sprintf("Token lacks the %s capability", need), http.StatusForbidden)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// tokenCapability returns the capability of the request's bearer token.
+func (ws *WebServer) tokenCapability(r *http.Request) (capability, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return 0, false
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(ws.adminToken)) == 1 {
+		return capAdmin, true
+	}
+	if ws.store == nil {
+		return 0, false
+	}
+	t, err := ws.store.APITokenBySecret(token)
+	if err != nil || t == nil {
+		return 0, false
+	}
+	c, ok := roleCapability[t.Role]
+	return c, ok
+}
+
+// handleAdminTokens lists the issued tokens (GET), issues one (POST
+// {"name", "role"}) and answers with its secret, or revokes one (POST
+// {"name", "revoke": true}). Secrets are shown once and not kept.
+func (ws *WebServer) handleAdminTokens(w http.ResponseWriter, r *http.Request) {
+	resp := map[string]interface{}{"success": true}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req struct {
+			Name   string `json:"name"`
+			Role   string `json:"role"`
+			Revoke bool   `json:"revoke"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if !store.ValidRemoteNodeName(req.Name) {
+			http.Error(w, fmt.Sprintf("Token names are 1 to %d lowercase letters, digits, - and _", store.MaxRemoteNodeName), http.StatusBadRequest)
+			return
+		}
+		if req.Revoke {
+			found, err := ws.store.DeleteAPIToken(req.Name)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if !found {
+				http.Error(w, "Unknown token", http.StatusNotFound)
+				return
+			}
+			break
+		}
+		if _, ok := roleCapability[req.Role]; !ok {
+			http.Error(w, "Role must be viewer, publisher or admin", http.StatusBadRequest)
+			return
+		}
+		raw := make([]byte, 32)
+		if _, err := rand.Read(raw); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		secret := hex.EncodeToString(raw)
+		t := &store.APIToken{Name: req.Name, Role: req.Role, Created: time.Now().UTC()}
+		if err := ws.store.PutAPIToken(secret, t); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, store.ErrAPITokenExists) || errors.Is(err, store.ErrAPITokensFull) {
+				status = http.StatusConflict
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		resp["token"] = secret
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	tokens, err := ws.store.APITokens()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp["tokens"] = tokens
+	resp["count"] = len(tokens)
+	writeJSON(w, resp)
+}
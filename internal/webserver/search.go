@@ -0,0 +1,84 @@
+package webserver
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"alxnet/internal/search"
+
+	"go.uber.org/zap"
+)
+
+// maxSearchDays bounds the days= recency filter of /api/search.
+const maxSearchDays = 3650
+
+// handleSearchPage serves the search page. It lives under /_alxnet/ so
+// that it cannot shadow a site named "search".
+func (ws *WebServer) handleSearchPage(w http.ResponseWriter, r *http.Request) {
+	ws.renderPage(w, r, "search.html")
+}
+
+// handleAPISearch searches the sites stored here:
+// GET /api/search?q=&tag=&days=&available=complete|pinned&sort=relevance|recent&limit=&offset=
+func (ws *WebServer) handleAPISearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if ws.search == nil {
+		http.Error(w, "Search is not available", http.StatusNotFound)
+		return
+	}
+	params := r.URL.Query()
+	q := search.Query{
+		Text:      params.Get("q"),
+		Tag:       params.Get("tag"),
+		Available: params.Get("available"),
+		Sort:      params.Get("sort"),
+	}
+	for _, p := range []struct {
+		name string
+		max  int
+		dst  *int
+	}{
+		{"limit", search.MaxLimit, &q.Limit},
+		{"offset", search.MaxOffset, &q.Offset},
+	} {
+		if v := params.Get(p.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 || n > p.max {
+				http.Error(w, fmt.Sprintf("%s must be between 0 and %d", p.name, p.max), http.StatusBadRequest)
+				return
+			}
+			*p.dst = n
+		}
+	}
+	if v := params.Get("days"); v != "" {
+		days, err := strconv.Atoi(v)
+		if err != nil || days < 1 || days > maxSearchDays {
+			http.Error(w, fmt.Sprintf("days must be between 1 and %d", maxSearchDays), http.StatusBadRequest)
+			return
+		}
+		q.Since = time.Now().Add(-time.Duration(days) * 24 * time.Hour)
+	}
+	if err := q.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results, total, err := ws.search.Search(q)
+	if err != nil {
+		ws.logger.Error("Search failed", zap.Error(err))
+		http.Error(w, "Search failed", http.StatusInternalServerError)
+		return
+	}
+	if results == nil {
+		results = []search.Result{}
+	}
+	writeJSON(w, map[string]interface{}{
+		"results": results,
+		"total":   total,
+	})
+}
@@ -0,0 +1,88 @@
+package webserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"alxnet/internal/core"
+	"alxnet/internal/search"
+	"alxnet/internal/store"
+
+	"go.uber.org/zap"
+)
+
+func TestAPISearch(t *testing.T) {
+	s, err := store.NewMemory()
+	if err != nil {
+		t.Fatalf("NewMemory: %v", err)
+	}
+	defer s.Close()
+	const siteID = "aa00000000000000000000000000000000000000000000000000000000000000"
+	page := []byte(`<title>Tomato <b>Garden</b></title><p>Grow tomatoes</p>`)
+	m := core.WebsiteManifest{Version: "v1", Seq: 1, TS: 1, MainFile: "index.html", Files: map[string]string{
+		"index.html": core.CIDForContent(page),
+	}}
+	if err := s.PutContent(m.Files["index.html"], page); err != nil {
+		t.Fatalf("PutContent: %v", err)
+	}
+	data, err := core.MarshalCanonical(&m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PutWebsiteManifest(siteID, core.CIDForBytes(data), data); err != nil {
+		t.Fatalf("PutWebsiteManifest: %v", err)
+	}
+	ws := &WebServer{store: s, logger: zap.NewNop(), search: search.New(s)}
+
+	tests := []struct {
+		query  string
+		status int
+		total  int
+	}{
+		{"", http.StatusOK, 1},
+		{"q=tomatoes", http.StatusOK, 1},
+		{"q=bicycle", http.StatusOK, 0},
+		{"q=tomatoes&available=complete&sort=recent&limit=5&offset=0", http.StatusOK, 1},
+		{"days=1", http.StatusOK, 0},
+		{"q=" + strings.Repeat("a", search.MaxQueryBytes+1), http.StatusBadRequest, 0},
+		{"q=a1+a2+a3+a4+a5+a6+a7+a8+a9", http.StatusBadRequest, 0},
+		{"limit=51", http.StatusBadRequest, 0},
+		{"limit=x", http.StatusBadRequest, 0},
+		{"offset=-1", http.StatusBadRequest, 0},
+		{"offset=10001", http.StatusBadRequest, 0},
+		{"days=0", http.StatusBadRequest, 0},
+		{"days=99999", http.StatusBadRequest, 0},
+		{"available=soon", http.StatusBadRequest, 0},
+		{"sort=random", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		ws.handleAPISearch(rr, httptest.NewRequest(http.MethodGet, "/api/search?"+tt.query, nil))
+		if rr.Code != tt.status {
+			t.Errorf("GET ?%s: status %d, want %d: %s", tt.query, rr.Code, tt.status, rr.Body.String())
+			continue
+		}
+		if tt.status != http.StatusOK {
+			continue
+		}
+		var got struct {
+			Results []search.Result `json:"results"`
+			Total   int             `json:"total"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+			t.Fatalf("GET ?%s: %v", tt.query, err)
+		}
+		if got.Total != tt.total || len(got.Results) != tt.total {
+			t.Errorf("GET ?%s: %d results of %d, want %d", tt.query, len(got.Results), got.Total, tt.total)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	ws.handleSearchPage(rr, httptest.NewRequest(http.MethodGet, "/_alxnet/search", nil))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `id="searchForm"`) {
+		t.Errorf("search page: status %d", rr.Code)
+	}
+}
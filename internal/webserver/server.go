@@ -3,18 +3,24 @@ package webserver
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"mime"
+	"net"
 	"net/http"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"alxnet/internal/core"
+	"alxnet/internal/dnslink"
+	"alxnet/internal/fingerprint"
+	"alxnet/internal/logging"
 	"alxnet/internal/p2p"
+	"alxnet/internal/search"
 	"alxnet/internal/store"
 
-	"github.com/fxamacker/cbor/v2"
 	"go.uber.org/zap"
 )
 
@@ -27,6 +33,30 @@ type WebServer struct {
 	port   int
 	ctx    context.Context
 	cancel context.CancelFunc
+	dns    *dnslink.Resolver // nil disables DNS TXT bridging
+	search *search.Index     // nil disables /api/search
+
+	assets     *assetCache     // blobs fetched from peers
+	remote     remoteManifests // manifests of sites this node does not store
+	names      nameCache       // site names resolved by peers
+	prefetched sync.Map        // site ID -> time of its last background load
+	tasks      *taskRunner     // background tasks of the wallet and node UIs
+	wallets    *walletSessions // unlocked wallets of the wallet UI
+
+	adminToken   string                                 // enables /api/admin, see SetAdminToken
+	requireToken bool                                   // tokens on every endpoint, see SetRequireToken
+	limits       *clientLimits                          // requests and failed logins per client, see SetAPILimits
+	writeCap     capability                             // needed by requests other than GET, see needs
+	reload       func() (map[string]interface{}, error) // see SetReloadFunc
+	logLevel     *zap.AtomicLevel                       // see SetLogLevel
+	logs         *logging.Stream                        // see SetLogStream
+	ntpServer    string                                 // checked by the doctor, see SetNTPServer
+	retention    store.RetentionRules                   // policies of the site classes, see SetRetention
+	ui           *ui                                    // theme and branding, see SetUI
+	readOnly     bool                                   // only GET and HEAD, see SetReadOnly
+	maxAge       time.Duration                          // Cache-Control max-age of site files, see SetGateway
+	allowedHosts []string                               // trusted for state-changing requests, see SetAllowedHosts
+	apiRoutes    []apiRoute                             // documented at /api/openapi.json, see handleAPI
 }
 
 // NewBrowserServer creates a new browser web server instance
@@ -40,17 +70,46 @@ func NewBrowserServer(store *store.Store, node *p2p.Node, logger *zap.Logger, po
 		port:   port,
 		ctx:    ctx,
 		cancel: cancel,
+		dns:    dnslink.NewResolver(),
+		assets: newAssetCache(maxAssetCacheBytes),
+		search: search.New(store),
+		tasks:  newTaskRunner(logger),
+		limits: newClientLimits(),
 	}
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", ws.handleWebsite)
-	mux.HandleFunc("/api/sites", ws.handleAPISites)
-	mux.HandleFunc("/api/site/", ws.handleAPISite)
-	mux.HandleFunc("/api/browse/", ws.handleAPIBrowse)
-	mux.HandleFunc("/api/sitenames", ws.handleAPISiteNames)
-	mux.HandleFunc("/api/sitename/register", ws.handleAPISiteNameRegister)
-	mux.HandleFunc("/api/sitename/resolve/", ws.handleAPISiteNameResolve)
+	ws.handleAPI(mux, "/api/sites", ws.handleAPISites, apiDoc{Methods: "GET", Summary: "Sites available on this node"})
+	ws.handleAPI(mux, "/api/site/", ws.handleAPISite, apiDoc{Methods: "GET", Summary: "Information about a site", Path: "/site/{site}"})
+	ws.handleAPI(mux, "/api/site/verify/", ws.handleAPISiteVerify, apiDoc{Methods: "GET", Summary: "Check a stored site's signatures, content hashes and providers", Path: "/site/verify/{site}", Query: []string{"peers", "root"}})
+	ws.handleAPI(mux, "/api/browse/", ws.handleAPIBrowse, apiDoc{Methods: "GET", Summary: "Browse a site's content", Path: "/browse/{site}"})
+	ws.handleAPI(mux, "/api/records/", ws.handleAPIRecords, apiDoc{Methods: "GET", Summary: "A site's update records by sequence number", Path: "/records/{site}", Query: []string{"from", "before", "limit"}})
+	ws.handleAPI(mux, "/api/load/", ws.handleAPILoad, apiDoc{Methods: "GET", Summary: "Load a site from peers, reporting progress as server-sent events", Path: "/load/{site}", Produces: "text/event-stream"})
+	ws.handleAPI(mux, "/api/tasks", ws.handleTasks, apiDoc{Methods: "GET", Summary: "Background tasks with their progress, newest first", Query: []string{"id"}})
+	ws.handleAPI(mux, "/api/tasks/cancel", ws.handleTaskCancel, apiDoc{Methods: "POST", Summary: "Cancel a background task"})
+	ws.handleAPI(mux, "/api/tasks/fetch", ws.handleTaskFetch, apiDoc{Methods: "POST", Summary: "Load a site's files from peers as a background task"})
+	ws.handleAPI(mux, "/api/follows", ws.handleAPIFollows, apiDoc{Methods: "GET POST", Summary: "List followed sites, or follow one"})
+	ws.handleAPI(mux, "/api/follows/unfollow", ws.handleAPIUnfollow, apiDoc{Methods: "POST", Summary: "Stop following a site"})
+	ws.handleAPI(mux, "/api/follows/mute", ws.handleAPIFollowMute, apiDoc{Methods: "POST", Summary: "Mute or unmute a followed site"})
+	ws.handleAPI(mux, "/api/addressbook", ws.handleAPIAddressBook, apiDoc{Methods: "GET POST", Summary: "List the local address book, or add an entry"})
+	ws.handleAPI(mux, "/api/addressbook/delete", ws.handleAPIAddressBookDelete, apiDoc{Methods: "POST", Summary: "Remove an address book entry"})
+	ws.handleAPI(mux, "/api/notifications", ws.handleAPINotifications, apiDoc{Methods: "GET", Summary: "Newest updates of followed sites", Query: []string{"limit", "unread"}})
+	ws.handleAPI(mux, "/api/notifications/read", ws.handleAPINotificationsRead, apiDoc{Methods: "POST", Summary: "Mark notifications read"})
+	ws.handleAPI(mux, "/api/feed/", ws.handleAPIFeed, apiDoc{Methods: "GET", Summary: "A site's update history as an Atom or RSS feed", Path: "/feed/{site}", Query: []string{"format"}, Produces: "application/atom+xml"})
+	ws.handleAPI(mux, "/api/comments/", ws.handleAPIComments, apiDoc{Methods: "GET", Summary: "Visible comments of a site", Path: "/comments/{site}", Query: []string{"limit"}})
+	ws.handleAPI(mux, "/api/pointers/", ws.handleAPIPointers, apiDoc{Methods: "GET", Summary: "An owner's pointers", Path: "/pointers/{owner}"})
+	ws.handleAPI(mux, "/api/appdata/", ws.handleAPIAppData, apiDoc{Methods: "GET", Summary: "A site's app data as JSON", Path: "/appdata/{site}"})
+	ws.handleAPI(mux, "/api/sitenames", ws.handleAPISiteNames, apiDoc{Methods: "GET", Summary: "Registered site names"})
+	ws.handleAPI(mux, "/api/sitename/register", ws.handleAPISiteNameRegister, apiDoc{Methods: "POST", Summary: "Register a site name"})
+	ws.handleAPI(mux, "/api/sitename/resolve/", ws.handleAPISiteNameResolve, apiDoc{Methods: "GET", Summary: "Resolve a site name to a site ID", Path: "/sitename/resolve/{name}"})
+	ws.handleAPI(mux, "/api/search", ws.handleAPISearch, apiDoc{Methods: "GET", Summary: "Search the sites stored here", Query: []string{"q", "tag", "days", "available", "sort", "limit", "offset"}})
+	ws.registerOpenAPI(mux)
+	mux.HandleFunc("/_alxnet/search", ws.handleSearchPage)
+	mux.HandleFunc("/_alxnet/verify", ws.handleVerifyPage)
 	mux.HandleFunc("/_alxnet/status", ws.handleStatus)
+	mux.HandleFunc(GatewayAuthPath, ws.handleGatewayAuths)
+	mux.HandleFunc("/_alxnet/ui/", ws.handleUIStatic)
+	mux.HandleFunc(fingerprint.PathPrefix, ws.handleContentByCID)
 
 	ws.server = &http.Server{
 		Addr:         fmt.Sprintf(":%d", port),
@@ -59,10 +118,43 @@ func NewBrowserServer(store *store.Store, node *p2p.Node, logger *zap.Logger, po
 		WriteTimeout: 10 * time.Second,
 		IdleTimeout:  60 * time.Second,
 	}
+	if store.ReadOnly() {
+		ws.SetReadOnly()
+	}
 
 	return ws
 }
 
+// SetReadOnly makes the server answer only GET and HEAD requests. Servers
+// over a store opened with store.OpenReadOnly are read-only from the start;
+// followers of a leader node call it since only replication writes their
+// store.
+func (ws *WebServer) SetReadOnly() {
+	if !ws.readOnly {
+		ws.readOnly = true
+		ws.server.Handler = readOnly(ws.server.Handler)
+	}
+}
+
+// readOnly answers only GET and HEAD requests, for servers on a read-only
+// store replica and read-only gateways.
+func readOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "This gateway is read-only", http.StatusMethodNotAllowed)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Handler returns the server's HTTP handler, for serving it from another
+// listener.
+func (ws *WebServer) Handler() http.Handler {
+	return ws.server.Handler
+}
+
 // Start starts the web server
 func (ws *WebServer) Start() error {
 	go func() {
@@ -80,13 +172,25 @@ func (ws *WebServer) Start() error {
 // Stop stops the web server
 func (ws *WebServer) Stop() error {
 	ws.cancel()
+	ws.wallets.lockAll()
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	return ws.server.Shutdown(ctx)
 }
 
-// handleWebsite serves websites by site ID or site name
+// handleWebsite serves websites by site ID or site name. Requests whose Host
+// is a DNS name with an _alxnet TXT record serve that site from the root.
 func (ws *WebServer) handleWebsite(w http.ResponseWriter, r *http.Request) {
+	if siteID, ok := ws.siteForHost(r); ok {
+		filePath := strings.Trim(r.URL.Path, "/")
+		if filePath == "" {
+			filePath = "index.html"
+		}
+		ws.advertiseGateway(w, r, siteID)
+		ws.serveSiteFile(r.Context(), w, siteID, r.Host, filePath)
+		return
+	}
+
 	// Parse the URL to extract site ID/name and file path
 	urlParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
 
@@ -102,9 +206,6 @@ func (ws *WebServer) handleWebsite(w http.ResponseWriter, r *http.Request) {
 		filePath = strings.Join(urlParts[1:], "/")
 	}
 
-	var siteID string
-	var err error
-
 	// Check if it's a 64-character site ID or a site name
 	if len(siteIDOrName) == 64 {
 		// Validate as hex string
@@ -114,23 +215,73 @@ func (ws *WebServer) handleWebsite(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 		}
-		siteID = siteIDOrName
-	} else {
-		// Try to resolve as site name
-		siteID, err = ws.store.ResolveDomain(siteIDOrName)
-		if err != nil {
-			http.Error(w, "Site name not found", http.StatusNotFound)
-			return
+		ws.advertiseGateway(w, r, siteIDOrName)
+		ws.serveSiteFile(r.Context(), w, siteIDOrName, siteIDOrName, filePath)
+		return
+	}
+
+	siteID, err := ws.resolveSiteName(r.Context(), siteIDOrName)
+	if err != nil {
+		http.Error(w, "Site name not found", http.StatusNotFound)
+		return
+	}
+	ws.advertiseGateway(w, r, siteID)
+	ws.serveSiteFile(r.Context(), w, siteID, siteIDOrName, filePath)
+}
+
+// siteForHost resolves the request's Host header through DNS TXT bridging.
+// Plain hostnames, IP addresses and hosts without a record are not bridged.
+func (ws *WebServer) siteForHost(r *http.Request) (string, bool) {
+	if ws.dns == nil {
+		return "", false
+	}
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if !dnslink.IsDomain(host) {
+		return "", false
+	}
+	siteID, err := ws.dns.Resolve(r.Context(), host)
+	if err != nil {
+		if !errors.Is(err, dnslink.ErrNoRecord) {
+			ws.logger.Debug("DNS TXT lookup failed", zap.String("host", host), zap.Error(err))
 		}
+		return "", false
 	}
+	return siteID, true
+}
 
+// serveSiteFile writes one file of a site; siteName is only used for logging.
+func (ws *WebServer) serveSiteFile(ctx context.Context, w http.ResponseWriter, siteID, siteName, filePath string) {
+	filePath, ok := core.CanonicalFilePath(filePath)
+	if !ok {
+		http.Error(w, "Invalid file path", http.StatusBadRequest)
+		return
+	}
+	if filePath == "" {
+		filePath = "index.html"
+	}
 	ws.logger.Info("serving website content",
 		zap.String("site_id", siteID),
-		zap.String("site_name", siteIDOrName),
+		zap.String("site_name", siteName),
 		zap.String("file_path", filePath))
 
 	// Try to get the website content
-	content, mimeType, err := ws.getWebsiteFile(siteID, filePath)
+	content, mimeType, err := ws.getWebsiteFile(ctx, siteID, filePath)
+	if err == nil && filePath == "index.html" && ws.store.HasWebsiteManifest(siteID) {
+		// Have the page's assets ready before the browser asks for them
+		ws.prefetchSite(siteID)
+	}
+	if err != nil && !ws.storesSite(siteID) {
+		content, mimeType, err = ws.getRemoteFile(ctx, siteID, filePath)
+		if err == nil {
+			w.Header().Set("X-AlxNet-Source", "remote")
+			if filePath == "index.html" {
+				ws.prefetchSite(siteID)
+			}
+		}
+	}
 	if err != nil {
 		ws.logger.Error("failed to get website file",
 			zap.String("site_id", siteID),
@@ -142,9 +293,14 @@ func (ws *WebServer) handleWebsite(w http.ResponseWriter, r *http.Request) {
 
 	// Set appropriate headers
 	w.Header().Set("Content-Type", mimeType)
+	w.Header().Set("Content-Security-Policy", sandboxCSP)
 	w.Header().Set("X-AlxNet-Site-ID", siteID)
 	w.Header().Set("X-AlxNet-File-Path", filePath)
 
+	if ws.maxAge > 0 {
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(ws.maxAge/time.Second)))
+	}
+
 	// Enable CORS for API access
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
@@ -158,10 +314,10 @@ func (ws *WebServer) handleWebsite(w http.ResponseWriter, r *http.Request) {
 }
 
 // getWebsiteFile retrieves a file from a website
-func (ws *WebServer) getWebsiteFile(siteID, filePath string) ([]byte, string, error) {
+func (ws *WebServer) getWebsiteFile(ctx context.Context, siteID, filePath string) ([]byte, string, error) {
 	// Check if it's a multi-file website
 	if ws.store.HasWebsiteManifest(siteID) {
-		return ws.getWebsiteManifestFile(siteID, filePath)
+		return ws.getWebsiteManifestFile(ctx, siteID, filePath)
 	}
 
 	// Check if it's a traditional single-file site (fallback)
@@ -171,14 +327,72 @@ func (ws *WebServer) getWebsiteFile(siteID, filePath string) ([]byte, string, er
 	}
 
 	if hasHead && filePath == "index.html" {
-		return ws.getSingleFileContent(siteID)
+		return ws.getSingleFileContent(ctx, siteID)
 	}
 
 	return nil, "", fmt.Errorf("site not found")
 }
 
+// storesSite reports whether this node holds a head or manifest for siteID.
+func (ws *WebServer) storesSite(siteID string) bool {
+	if ws.store.HasWebsiteManifest(siteID) {
+		return true
+	}
+	has, _ := ws.store.HasHead(siteID)
+	return has
+}
+
+// remoteFetchTimeout bounds fetching a page from peers.
+const remoteFetchTimeout = 10 * time.Second
+
+// lookupHead asks peers for siteID's head. Replicas without a node know
+// only the sites in their store.
+func (ws *WebServer) lookupHead(ctx context.Context, siteID string) (p2p.HeadInfo, error) {
+	if ws.node == nil {
+		return p2p.HeadInfo{}, p2p.ErrHeadNotFound
+	}
+	return ws.node.LookupHead(ctx, siteID)
+}
+
+// siteContentCIDs maps every file of a site to its content CID, walking the
+// manifest for multi-file websites or the head record for single-file sites.
+func (ws *WebServer) siteContentCIDs(siteID string) (map[string]string, error) {
+	if ws.store.HasWebsiteManifest(siteID) {
+		manifestData, err := ws.store.GetCurrentWebsiteManifest(siteID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get website manifest: %v", err)
+		}
+		var manifest core.WebsiteManifest
+		if err := core.UnmarshalCBOR(manifestData, &manifest); err != nil {
+			return nil, fmt.Errorf("failed to parse website manifest: %v", err)
+		}
+		return manifest.Files, nil
+	}
+
+	hasHead, err := ws.store.HasHead(siteID)
+	if err != nil {
+		return nil, err
+	}
+	if !hasHead {
+		return nil, fmt.Errorf("site not found")
+	}
+	_, headCID, err := ws.store.GetHead(siteID)
+	if err != nil {
+		return nil, err
+	}
+	recordData, err := ws.store.GetRecord(headCID)
+	if err != nil {
+		return nil, err
+	}
+	var record core.UpdateRecord
+	if err := core.UnmarshalCBOR(recordData, &record); err != nil {
+		return nil, err
+	}
+	return map[string]string{"index.html": record.ContentCID}, nil
+}
+
 // getWebsiteManifestFile retrieves a file from a multi-file website
-func (ws *WebServer) getWebsiteManifestFile(siteID, filePath string) ([]byte, string, error) {
+func (ws *WebServer) getWebsiteManifestFile(ctx context.Context, siteID, filePath string) ([]byte, string, error) {
 	// Get website manifest
 	manifestData, err := ws.store.GetCurrentWebsiteManifest(siteID)
 	if err != nil {
@@ -187,8 +401,7 @@ func (ws *WebServer) getWebsiteManifestFile(siteID, filePath string) ([]byte, st
 
 	// Parse manifest
 	var manifest core.WebsiteManifest
-	dec, _ := cbor.DecOptions{}.DecMode()
-	if err := dec.Unmarshal(manifestData, &manifest); err != nil {
+	if err := core.UnmarshalCBOR(manifestData, &manifest); err != nil {
 		return nil, "", fmt.Errorf("failed to parse website manifest: %v", err)
 	}
 
@@ -203,12 +416,21 @@ func (ws *WebServer) getWebsiteManifestFile(siteID, filePath string) ([]byte, st
 		return nil, "", fmt.Errorf("file not found in website: %s", filePath)
 	}
 
-	// Get file content
-	content, err := ws.store.GetContent(contentCID)
+	// Get file content, from peers if the blob is missing locally
+	content, err := ws.fetchContent(ctx, contentCID)
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to get file content: %v", err)
 	}
 
+	// Render text files published for reader mode as HTML pages
+	if mediaType, ok := ws.readerFile(siteID, filePath); ok {
+		page, err := renderReader(mediaType, filePath, content, ws.currentUI().remoteImages)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to render %s: %v", filePath, err)
+		}
+		return page, "text/html; charset=utf-8", nil
+	}
+
 	// Determine MIME type
 	mimeType := ws.getMimeType(filePath)
 
@@ -216,7 +438,7 @@ func (ws *WebServer) getWebsiteManifestFile(siteID, filePath string) ([]byte, st
 }
 
 // getSingleFileContent retrieves content from a traditional single-file site
-func (ws *WebServer) getSingleFileContent(siteID string) ([]byte, string, error) {
+func (ws *WebServer) getSingleFileContent(ctx context.Context, siteID string) ([]byte, string, error) {
 	_, headCID, err := ws.store.GetHead(siteID)
 	if err != nil {
 		return nil, "", err
@@ -228,12 +450,11 @@ func (ws *WebServer) getSingleFileContent(siteID string) ([]byte, string, error)
 	}
 
 	var record core.UpdateRecord
-	dec, _ := cbor.DecOptions{}.DecMode()
-	if err := dec.Unmarshal(recordData, &record); err != nil {
+	if err := core.UnmarshalCBOR(recordData, &record); err != nil {
 		return nil, "", err
 	}
 
-	content, err := ws.store.GetContent(record.ContentCID)
+	content, err := ws.fetchContent(ctx, record.ContentCID)
 	if err != nil {
 		return nil, "", err
 	}
@@ -241,188 +462,54 @@ func (ws *WebServer) getSingleFileContent(siteID string) ([]byte, string, error)
 	return content, "text/html", nil
 }
 
+// fallbackMimeType is served for files whose extension is not recognized.
+const fallbackMimeType = "text/plain; charset=utf-8"
+
 // getMimeType determines the MIME type for a file path
 func (ws *WebServer) getMimeType(filePath string) string {
+	if mimeType, ok := knownMimeType(filePath); ok {
+		return mimeType
+	}
+	return fallbackMimeType
+}
+
+// knownMimeType returns the MIME type for a file path's extension, if the
+// extension is recognized.
+func knownMimeType(filePath string) (string, bool) {
 	ext := filepath.Ext(filePath)
 	mimeType := mime.TypeByExtension(ext)
 
 	if mimeType == "" {
 		switch ext {
 		case ".html", ".htm":
-			return "text/html; charset=utf-8"
+			return "text/html; charset=utf-8", true
 		case ".css":
-			return "text/css; charset=utf-8"
+			return "text/css; charset=utf-8", true
 		case ".js":
-			return "application/javascript; charset=utf-8"
+			return "application/javascript; charset=utf-8", true
 		case ".json":
-			return "application/json; charset=utf-8"
+			return "application/json; charset=utf-8", true
 		case ".png":
-			return "image/png"
+			return "image/png", true
 		case ".jpg", ".jpeg":
-			return "image/jpeg"
+			return "image/jpeg", true
 		case ".gif":
-			return "image/gif"
+			return "image/gif", true
 		case ".svg":
-			return "image/svg+xml"
+			return "image/svg+xml", true
 		case ".ico":
-			return "image/x-icon"
+			return "image/x-icon", true
 		default:
-			return "text/plain; charset=utf-8"
+			return "", false
 		}
 	}
 
-	return mimeType
+	return mimeType, true
 }
 
 // serveAlxNetHomepage serves the AlxNet homepage
 func (ws *WebServer) serveAlxNetHomepage(w http.ResponseWriter, r *http.Request) {
-	homepage := `<!DOCTYPE html>
-<html lang="en">
-<head>
-    <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>AlxNet - Decentralized Web Browser</title>
-    <style>
-        * { margin: 0; padding: 0; box-sizing: border-box; }
-        body { 
-            font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif;
-            background: linear-gradient(135deg, #667eea 0%, #764ba2 100%);
-            min-height: 100vh;
-            color: white;
-        }
-        .container { max-width: 800px; margin: 0 auto; padding: 2rem; }
-        .header { text-align: center; margin-bottom: 3rem; }
-        .header h1 { font-size: 3rem; margin-bottom: 1rem; }
-        .header p { font-size: 1.2rem; opacity: 0.9; }
-        .search-box { 
-            background: rgba(255,255,255,0.1);
-            padding: 2rem;
-            border-radius: 10px;
-            margin-bottom: 2rem;
-            backdrop-filter: blur(10px);
-        }
-        .search-box input {
-            width: 100%;
-            padding: 1rem;
-            font-size: 1.1rem;
-            border: none;
-            border-radius: 5px;
-            margin-bottom: 1rem;
-        }
-        .search-box button {
-            padding: 1rem 2rem;
-            background: #4c51bf;
-            color: white;
-            border: none;
-            border-radius: 5px;
-            cursor: pointer;
-            font-size: 1rem;
-        }
-        .search-box button:hover { background: #3730a3; }
-        .features {
-            display: grid;
-            grid-template-columns: repeat(auto-fit, minmax(250px, 1fr));
-            gap: 2rem;
-            margin-bottom: 3rem;
-        }
-        .feature {
-            background: rgba(255,255,255,0.1);
-            padding: 1.5rem;
-            border-radius: 10px;
-            backdrop-filter: blur(10px);
-        }
-        .feature h3 { margin-bottom: 0.5rem; }
-        .api-info {
-            background: rgba(255,255,255,0.1);
-            padding: 1.5rem;
-            border-radius: 10px;
-            backdrop-filter: blur(10px);
-        }
-    </style>
-</head>
-<body>
-    <div class="container">
-        <div class="header">
-            <h1>🌐 AlxNet</h1>
-            <p>Decentralized Web Browser & Platform</p>
-        </div>
-        
-        <div class="search-box">
-            <h2>Browse a Decentralized Website</h2>
-            <input type="text" id="siteInput" placeholder="Enter site ID (64 characters) or site name" maxlength="64">
-            <button onclick="browseSite()">Browse Site</button>
-            <p><small>Examples: b36c5d32ed19dce14f8f1f279aeede1e2c2ab397e44e8b5d31f89c9320096b33 or mysite</small></p>
-        </div>
-        
-        <div class="features">
-            <div class="feature">
-                <h3>🔗 P2P Network</h3>
-                <p>Content distributed across peer-to-peer network without central servers</p>
-            </div>
-            <div class="feature">
-                <h3>🌐 Full Websites</h3>
-                <p>Complete websites with HTML, CSS, JavaScript, and images</p>
-            </div>
-            <div class="feature">
-                <h3>🔒 Cryptographic Security</h3>
-                <p>Content verified using cryptographic signatures</p>
-            </div>
-            <div class="feature">
-                <h3>🚀 Modern Web Standards</h3>
-                <p>Support for modern HTML5, CSS3, and JavaScript features</p>
-            </div>
-        </div>
-        
-        <div class="api-info">
-            <h3>API Endpoints</h3>
-            <ul>
-                <li><code>/api/sites</code> - List all available sites</li>
-                <li><code>/api/site/{siteID}</code> - Get site information</li>
-                <li><code>/api/sitenames</code> - List all registered site names</li>
-                <li><code>/api/sitename/register</code> - Register a new site name</li>
-                <li><code>/api/sitename/resolve/{siteName}</code> - Resolve site name to ID</li>
-                <li><code>/{siteID or siteName}/{filepath}</code> - Browse site content</li>
-                <li><code>/_alxnet/status</code> - Server status</li>
-            </ul>
-        </div>
-    </div>
-    
-    <script>
-        function browseSite() {
-            const input = document.getElementById('siteInput').value.trim();
-            
-            if (!input) {
-                alert('Please enter a site ID or site name');
-                return;
-            }
-            
-            // Check if it's a 64-character hex site ID
-            if (input.length === 64 && /^[a-fA-F0-9]+$/.test(input)) {
-                window.location.href = '/' + input;
-            } 
-            // Check if it's a valid site name format
-            else if (input.length >= 3 && input.length <= 32 && /^[a-z0-9][a-z0-9_-]*$/.test(input)) {
-                window.location.href = '/' + input;
-            } 
-            else {
-                alert('Please enter a valid site ID (64-character hex) or site name (3-32 chars, lowercase, alphanumeric, _, -)');
-            }
-        }
-        
-        document.getElementById('siteInput').addEventListener('keypress', function(e) {
-            if (e.key === 'Enter') {
-                browseSite();
-            }
-        });
-    </script>
-</body>
-</html>`
-
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	if _, err := w.Write([]byte(homepage)); err != nil {
-		http.Error(w, "Failed to write response", http.StatusInternalServerError)
-		return
-	}
+	ws.renderPage(w, r, "browser.html")
 }
 
 // handleStatus serves server status information
@@ -432,7 +519,11 @@ func (ws *WebServer) handleStatus(w http.ResponseWriter, r *http.Request) {
 		"version":   "1.0.0",
 		"timestamp": time.Now().Unix(),
 		"uptime":    time.Since(time.Now()).String(), // This would need proper tracking
-		"node_id":   ws.node.Host.ID().String(),
+		"read_only": ws.readOnly,
+	}
+	if ws.node != nil {
+		status["node_id"] = ws.node.Host.ID().String()
+		status["head_cache"] = ws.node.HeadCacheStats()
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -491,7 +582,7 @@ func (ws *WebServer) handleAPISite(w http.ResponseWriter, r *http.Request) {
 func (ws *WebServer) handleAPIBrowse(w http.ResponseWriter, r *http.Request) {
 	siteID := strings.TrimPrefix(r.URL.Path, "/api/browse/")
 
-	if len(siteID) != 64 {
+	if len(siteID) != 64 || !isHex(siteID) {
 		http.Error(w, "Invalid site ID", http.StatusBadRequest)
 		return
 	}
@@ -502,6 +593,21 @@ func (ws *WebServer) handleAPIBrowse(w http.ResponseWriter, r *http.Request) {
 		"url":     fmt.Sprintf("http://localhost:%d/%s", ws.port, siteID),
 		"status":  "available",
 	}
+	if !ws.storesSite(siteID) {
+		ctx, cancel := context.WithTimeout(r.Context(), remoteFetchTimeout)
+		defer cancel()
+		head, err := ws.lookupHead(ctx, siteID)
+		switch {
+		case err == nil:
+			result["status"] = "remote"
+			result["seq"] = head.Seq
+			result["head_cid"] = head.HeadCID
+		case errors.Is(err, p2p.ErrHeadNotFound):
+			result["status"] = "not_found"
+		default:
+			result["status"] = "unreachable"
+		}
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(result); err != nil {
@@ -582,7 +688,7 @@ func (ws *WebServer) handleAPISiteNameResolve(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	siteID, err := ws.store.ResolveDomain(siteName)
+	siteID, source, err := ws.resolveName(r.Context(), siteName)
 	if err != nil {
 		http.Error(w, "Site name not found", http.StatusNotFound)
 		return
@@ -592,6 +698,7 @@ func (ws *WebServer) handleAPISiteNameResolve(w http.ResponseWriter, r *http.Req
 	if err := json.NewEncoder(w).Encode(map[string]interface{}{
 		"site_name": siteName,
 		"site_id":   siteID,
+		"source":    source,
 		"url":       fmt.Sprintf("http://localhost:%d/%s", ws.port, siteName),
 	}); err != nil {
 		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
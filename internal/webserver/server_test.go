@@ -0,0 +1,332 @@
+package webserver
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"alxnet/internal/core"
+	"alxnet/internal/publisher"
+	"alxnet/internal/store"
+	"alxnet/internal/wallet"
+
+	"go.uber.org/zap"
+)
+
+func TestReadOnlyReplica(t *testing.T) {
+	src, err := store.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer src.Close()
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	siteID := core.SiteIDFromPub(pub)
+	p := publisher.New(src, nil, nil)
+	if _, err := p.SaveFile(priv, "index.html", []byte("<h1>replicated</h1>"), "text/html"); err != nil {
+		t.Fatalf("SaveFile: %v", err)
+	}
+	if _, err := p.PublishWebsite(context.Background(), priv); err != nil {
+		t.Fatalf("PublishWebsite: %v", err)
+	}
+	dir, err := src.WriteSnapshot(t.TempDir())
+	if err != nil {
+		t.Fatalf("WriteSnapshot: %v", err)
+	}
+	db, err := store.OpenReadOnly(dir)
+	if err != nil {
+		t.Fatalf("OpenReadOnly: %v", err)
+	}
+	defer db.Close()
+	h := NewBrowserServer(db, nil, zap.NewNop(), 0).Handler()
+
+	tests := []struct {
+		method, path string
+		status       int
+		body         string
+	}{
+		{http.MethodGet, "/" + siteID + "/", http.StatusOK, "replicated"},
+		{http.MethodGet, "/api/browse/" + strings.Repeat("bb", 32), http.StatusOK, "not_found"},
+		{http.MethodGet, "/_alxnet/status", http.StatusOK, `"read_only":true`},
+		{http.MethodPost, "/api/follows", http.StatusMethodNotAllowed, "read-only"},
+		{http.MethodPost, "/api/comments/" + siteID, http.StatusMethodNotAllowed, "read-only"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, strings.NewReader("{}")))
+		if rec.Code != tt.status || !strings.Contains(rec.Body.String(), tt.body) {
+			t.Errorf("%s %s = %d %q, want %d containing %q", tt.method, tt.path, rec.Code, rec.Body.String(), tt.status, tt.body)
+		}
+	}
+
+	var status map[string]interface{}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/_alxnet/status", nil))
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil || status["node_id"] != nil {
+		t.Errorf("replica status = %v, %v; want no node", status, err)
+	}
+}
+
+func TestGatewayMode(t *testing.T) {
+	db, err := store.NewMemory()
+	if err != nil {
+		t.Fatalf("NewMemory: %v", err)
+	}
+	defer db.Close()
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	siteID := core.SiteIDFromPub(pub)
+	p := publisher.New(db, nil, nil)
+	if _, err := p.SaveFile(priv, "index.html", []byte("<h1>gateway</h1>"), "text/html"); err != nil {
+		t.Fatalf("SaveFile: %v", err)
+	}
+	if _, err := p.PublishWebsite(context.Background(), priv); err != nil {
+		t.Fatalf("PublishWebsite: %v", err)
+	}
+
+	ws := NewBrowserServer(db, nil, zap.NewNop(), 0)
+	ws.SetGateway(GatewayOptions{AssetCacheBytes: 1 << 20, ManifestTTL: time.Hour, NameTTL: 2 * time.Hour, MaxAge: 90 * time.Second})
+	h := ws.Handler()
+
+	tests := []struct {
+		method, path string
+		status       int
+		cacheControl string
+	}{
+		{http.MethodGet, "/" + siteID + "/", http.StatusOK, "public, max-age=90"},
+		{http.MethodGet, "/_alxnet/status", http.StatusOK, ""},
+		{http.MethodPost, "/api/follows", http.StatusMethodNotAllowed, ""},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, strings.NewReader("{}")))
+		if rec.Code != tt.status || rec.Header().Get("Cache-Control") != tt.cacheControl {
+			t.Errorf("%s %s = %d, Cache-Control %q; want %d, %q", tt.method, tt.path, rec.Code, rec.Header().Get("Cache-Control"), tt.status, tt.cacheControl)
+		}
+	}
+
+	// Manifests and names from peers outlive the defaults
+	now := time.Now()
+	ws.remote.put(siteID, remoteManifest{fetched: now})
+	if _, ok := ws.remote.get(siteID, now.Add(remoteManifestTTL+time.Minute)); !ok {
+		t.Error("remote manifest expired with the default TTL")
+	}
+	ws.names.put("gateway", siteID, now)
+	if _, ok := ws.names.get("gateway", now.Add(nameTTL+time.Minute)); !ok {
+		t.Error("resolved name expired with the default TTL")
+	}
+	if ws.assets.maxBytes != 1<<20 {
+		t.Errorf("asset cache holds %d bytes, want %d", ws.assets.maxBytes, 1<<20)
+	}
+}
+
+func TestAPIRecords(t *testing.T) {
+	db, err := store.NewMemory()
+	if err != nil {
+		t.Fatalf("NewMemory: %v", err)
+	}
+	defer db.Close()
+	pub, _, _ := ed25519.GenerateKey(rand.Reader)
+	siteID := core.SiteIDFromPub(pub)
+	for seq := uint64(1); seq <= 3; seq++ {
+		data, err := core.MarshalCanonical(&core.UpdateRecord{Version: core.RecordV1, SitePub: pub, Seq: seq, ContentCID: "c0ffee", TS: int64(seq)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := db.PutRecord(core.CIDForBytes(data), data); err != nil {
+			t.Fatalf("PutRecord: %v", err)
+		}
+	}
+	h := NewBrowserServer(db, nil, zap.NewNop(), 0).Handler()
+
+	tests := []struct {
+		query  string
+		status int
+		seqs   []uint64
+	}{
+		{"", http.StatusOK, []uint64{3, 2, 1}},
+		{"?from=2", http.StatusOK, []uint64{2, 3}},
+		{"?before=3&limit=1", http.StatusOK, []uint64{2}},
+		{"?from=9", http.StatusOK, nil},
+		{"?from=1&before=3", http.StatusBadRequest, nil},
+		{"?from=-1", http.StatusBadRequest, nil},
+		{"?limit=0", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/records/"+siteID+tt.query, nil))
+		if rec.Code != tt.status {
+			t.Errorf("%q: status %d, want %d", tt.query, rec.Code, tt.status)
+			continue
+		}
+		if rec.Code != http.StatusOK {
+			continue
+		}
+		var resp struct {
+			Records []store.RecordRef `json:"records"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%q: %v", tt.query, err)
+		}
+		var seqs []uint64
+		for _, r := range resp.Records {
+			seqs = append(seqs, r.Seq)
+		}
+		if fmt.Sprint(seqs) != fmt.Sprint(tt.seqs) {
+			t.Errorf("%q: seqs %v, want %v", tt.query, seqs, tt.seqs)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/records/nothex", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid site ID: status %d", rec.Code)
+	}
+}
+
+func TestStorageUsage(t *testing.T) {
+	db, err := store.NewMemory()
+	if err != nil {
+		t.Fatalf("NewMemory: %v", err)
+	}
+	defer db.Close()
+	ws := &WebServer{store: db}
+	var sites []string
+	for i := 0; i < 3; i++ {
+		pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+		sites = append(sites, core.SiteIDFromPub(pub))
+		if _, err := publisher.New(db, nil, nil).SaveFile(priv, "index.html", []byte(fmt.Sprintf("<h1>site %d</h1>", i)), "text/html"); err != nil {
+			t.Fatalf("SaveFile: %v", err)
+		}
+	}
+
+	tests := []struct {
+		query  string
+		status int
+		sites  int
+	}{
+		{"", http.StatusOK, 3},
+		{"?limit=2", http.StatusOK, 2},
+		{"?site=" + sites[1], http.StatusOK, 1},
+		{"?site=" + strings.Repeat("cc", 32), http.StatusNotFound, 0},
+		{"?site=../x", http.StatusBadRequest, 0},
+		{"?limit=0", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		ws.handleStorageUsage(rec, httptest.NewRequest(http.MethodGet, "/api/storage/usage"+tt.query, nil))
+		if rec.Code != tt.status {
+			t.Errorf("%q: status %d, want %d", tt.query, rec.Code, tt.status)
+			continue
+		}
+		if rec.Code != http.StatusOK {
+			continue
+		}
+		var resp struct {
+			Sites []store.SiteUsage `json:"sites"`
+			Count int               `json:"count"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%q: %v", tt.query, err)
+		}
+		if len(resp.Sites) != tt.sites || resp.Count != 3 {
+			t.Errorf("%q: %d sites of %d, want %d of 3", tt.query, len(resp.Sites), resp.Count, tt.sites)
+		}
+		for _, u := range resp.Sites {
+			if u.Content != 1 || u.TotalBytes <= 0 {
+				t.Errorf("%q: usage %+v", tt.query, u)
+			}
+		}
+	}
+}
+
+func TestStorageRetention(t *testing.T) {
+	db, err := store.NewMemory()
+	if err != nil {
+		t.Fatalf("NewMemory: %v", err)
+	}
+	defer db.Close()
+	ws := &WebServer{store: db}
+	ws.SetRetention(store.RetentionRules{Pinned: core.RetentionPolicy{Keep: 3}})
+	pinned, loose := strings.Repeat("aa", 32), strings.Repeat("bb", 32)
+	if err := db.PinSite(pinned); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		own    int // sites with their own policy afterwards
+	}{
+		{"set", `{"site_id":"` + pinned + `","policy":"last:5"}`, http.StatusOK, 1},
+		{"unknown policy", `{"site_id":"` + pinned + `","policy":"newest"}`, http.StatusBadRequest, 1},
+		{"not pinned", `{"site_id":"` + loose + `","policy":"heads"}`, http.StatusBadRequest, 1},
+		{"bad site ID", `{"site_id":"../x","policy":"heads"}`, http.StatusBadRequest, 1},
+		{"run", `{"run":true}`, http.StatusOK, 1},
+		{"remove", `{"site_id":"` + pinned + `","remove":true}`, http.StatusOK, 0},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		ws.handleStorageRetention(rec, httptest.NewRequest(http.MethodPost, "/api/storage/retention", strings.NewReader(tt.body)))
+		if rec.Code != tt.status {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, rec.Code, tt.status, rec.Body)
+			continue
+		}
+		if own, _ := db.SiteRetentions(); len(own) != tt.own {
+			t.Errorf("%s: %d sites with their own policy, want %d", tt.name, len(own), tt.own)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	ws.handleStorageRetention(rec, httptest.NewRequest(http.MethodGet, "/api/storage/retention", nil))
+	var resp struct {
+		Default string                 `json:"default"`
+		Pinned  string                 `json:"pinned"`
+		LastRun *store.RetentionReport `json:"last_run"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.Default != "full" || resp.Pinned != "last:3" || resp.LastRun == nil {
+		t.Errorf("GET = %s, %v", rec.Body, err)
+	}
+}
+
+func TestWalletSites(t *testing.T) {
+	db, err := store.NewMemory()
+	if err != nil {
+		t.Fatalf("NewMemory: %v", err)
+	}
+	defer db.Close()
+	ws := &WebServer{store: db}
+	blog, shop, other := strings.Repeat("aa", 32), strings.Repeat("bb", 32), strings.Repeat("cc", 32)
+	w := wallet.New()
+	w.Sites["shop"] = &wallet.SiteMeta{Label: "shop", SiteID: shop}
+	w.Sites["blog"] = &wallet.SiteMeta{Label: "blog", SiteID: blog}
+	for domain, siteID := range map[string]string{"myblog": blog, "blog2": blog, "elsewhere": other} {
+		if err := db.PutDomain(domain, siteID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	walletData, _ := json.Marshal(w)
+	body, _ := json.Marshal(map[string]string{"wallet_data": string(walletData), "mnemonic": "m"})
+
+	rec := httptest.NewRecorder()
+	ws.handleWalletSites(rec, httptest.NewRequest(http.MethodPost, "/api/wallet/sites", strings.NewReader(string(body))))
+	var resp struct {
+		Sites []struct {
+			Label   string   `json:"label"`
+			Domains []string `json:"domains"`
+		} `json:"sites"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("%d %s: %v", rec.Code, rec.Body, err)
+	}
+	got := fmt.Sprint(resp.Sites)
+	if want := "[{blog [blog2 myblog]} {shop []}]"; got != want {
+		t.Errorf("sites = %s, want %s", got, want)
+	}
+}
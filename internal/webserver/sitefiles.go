@@ -0,0 +1,184 @@
+package webserver
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"alxnet/internal/core"
+	"alxnet/internal/publisher"
+)
+
+// maxAddFileBody fits a base64 encoded file of core.MaxContentSize plus the
+// encrypted wallet.
+const maxAddFileBody = core.MaxContentSize/3*4 + 4 + 1<<20
+
+// publisher returns the publisher for the sites in the store.
+func (ws *WebServer) publisher() *publisher.Publisher {
+	return publisher.New(ws.store, ws.node, ws.logger)
+}
+
+// publishError writes the response for a failed publisher call: invalid
+// input and missing files are the caller's fault, the rest is ours.
+func publishError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, publisher.ErrFileNotFound):
+		writeWalletError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, publisher.ErrNoNode):
+		writeWalletError(w, http.StatusServiceUnavailable, err.Error())
+	case errors.Is(err, publisher.ErrOnlyFile), errors.Is(err, publisher.ErrNoFiles), errors.Is(err, publisher.ErrInvalid):
+		writeWalletError(w, http.StatusBadRequest, err.Error())
+	default:
+		writeWalletError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// walletSiteKey decrypts the wallet in a request and returns the ID and key
+// of the site labelled label, writing an error response if it cannot.
+func (ws *WebServer) walletSiteKey(w http.ResponseWriter, r *http.Request, walletJSON, mnemonic, passphrase, label string) (string, ed25519.PrivateKey, bool) {
+	walletData, master, ok := ws.unlockWallet(w, r, walletJSON, mnemonic, passphrase)
+	if !ok {
+		return "", nil, false
+	}
+	defer clear(master)
+	if _, exists := walletData.Sites[label]; !exists {
+		writeWalletError(w, http.StatusNotFound, "Site not found in wallet")
+		return "", nil, false
+	}
+	meta, _, priv, err := walletData.EnsureSite(master, label)
+	if err != nil {
+		http.Error(w, "Failed to get site", http.StatusInternalServerError)
+		return "", nil, false
+	}
+	return meta.SiteID, priv, true
+}
+
+// handleAddWebsiteFile adds or replaces one file of a published website: it
+// stores the content, a file record signed with the site key, and the next
+// manifest. Content is sent base64 encoded.
+func (ws *WebServer) handleAddWebsiteFile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		WalletData         string `json:"wallet_data"`
+		Mnemonic           string `json:"mnemonic"`
+		MnemonicPassphrase string `json:"mnemonic_passphrase"`
+		SiteLabel          string `json:"site_label"`
+		FilePath           string `json:"file_path"`
+		Content            string `json:"content"` // base64
+		MimeType           string `json:"mime_type"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAddFileBody)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	content, err := base64.StdEncoding.DecodeString(req.Content)
+	if err != nil {
+		writeWalletError(w, http.StatusBadRequest, "Content must be base64 encoded")
+		return
+	}
+	if len(content) > core.MaxContentSize {
+		writeWalletError(w, http.StatusBadRequest, fmt.Sprintf("File larger than %d bytes", core.MaxContentSize))
+		return
+	}
+	filePath, ok := core.CanonicalFilePath(req.FilePath)
+	if !ok || core.ValidateFilePath(filePath) != nil {
+		writeWalletError(w, http.StatusBadRequest, "Invalid file path")
+		return
+	}
+	mimeType := strings.TrimSpace(req.MimeType)
+	if mimeType == "" {
+		var known bool
+		if mimeType, known = knownMimeType(filePath); !known {
+			mimeType = "application/octet-stream"
+		}
+	}
+
+	siteID, priv, ok := ws.walletSiteKey(w, r, req.WalletData, req.Mnemonic, req.MnemonicPassphrase, req.SiteLabel)
+	if !ok {
+		return
+	}
+
+	f, m, err := ws.publisher().AddFile(ws.ctx, priv, filePath, content, mimeType)
+	if err != nil {
+		publishError(w, err)
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"success":      true,
+		"site_id":      siteID,
+		"file_path":    f.Path,
+		"content_cid":  f.ContentCID,
+		"record_cid":   f.RecordCID,
+		"mime_type":    f.MimeType,
+		"size":         f.Size,
+		"manifest_cid": m.CID,
+		"seq":          m.Seq,
+	})
+}
+
+// handleDeleteFileFromSite removes a file from a website: its path mapping
+// and content reference, then publishes a manifest without it. Content no
+// other file references is deleted. With "draft" set, the removal is saved
+// as a draft instead and published with the site's other drafts.
+func (ws *WebServer) handleDeleteFileFromSite(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		WalletData         string `json:"wallet_data"`
+		Mnemonic           string `json:"mnemonic"`
+		MnemonicPassphrase string `json:"mnemonic_passphrase"`
+		SiteLabel          string `json:"site_label"`
+		FilePath           string `json:"file_path"`
+		Draft              bool   `json:"draft"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxCommentRequestBody)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	filePath, ok := core.CanonicalFilePath(req.FilePath)
+	if !ok || filePath == "" {
+		writeWalletError(w, http.StatusBadRequest, "Invalid file path")
+		return
+	}
+	siteID, priv, ok := ws.walletSiteKey(w, r, req.WalletData, req.Mnemonic, req.MnemonicPassphrase, req.SiteLabel)
+	if !ok {
+		return
+	}
+
+	if req.Draft {
+		if err := ws.publisher().SaveRemoval(priv, filePath); err != nil {
+			publishError(w, err)
+			return
+		}
+		writeJSON(w, map[string]interface{}{
+			"success":   true,
+			"site_id":   siteID,
+			"file_path": filePath,
+			"draft":     true,
+		})
+		return
+	}
+	m, err := ws.publisher().RemoveFile(ws.ctx, priv, filePath)
+	if err != nil {
+		publishError(w, err)
+		return
+	}
+	resp := map[string]interface{}{
+		"success":   true,
+		"site_id":   siteID,
+		"file_path": filePath,
+	}
+	if m != nil {
+		resp["manifest_cid"], resp["seq"] = m.CID, m.Seq
+	}
+	writeJSON(w, resp)
+}
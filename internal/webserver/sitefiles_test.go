@@ -0,0 +1,78 @@
+package webserver
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+
+	"alxnet/internal/core"
+	"alxnet/internal/p2p"
+	"alxnet/internal/publisher"
+	"alxnet/internal/store"
+)
+
+func TestImportVerifiesFileRecords(t *testing.T) {
+	tests := []struct {
+		name    string
+		foreign bool // re-sign about.html with another site's key
+	}{
+		{"signed by the site", false},
+		{"signed by another site", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, err := store.NewMemory()
+			if err != nil {
+				t.Fatalf("NewMemory: %v", err)
+			}
+			defer src.Close()
+			pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+			_, otherPriv, _ := ed25519.GenerateKey(rand.Reader)
+			siteID := core.SiteIDFromPub(pub)
+
+			p := publisher.New(src, nil, nil)
+			for path, body := range map[string]string{"index.html": "<h1>hi</h1>", "about.html": "<p>about</p>"} {
+				if _, err := p.SaveFile(priv, path, []byte(body), "text/html"); err != nil {
+					t.Fatalf("SaveFile(%s): %v", path, err)
+				}
+			}
+			if _, err := p.PublishWebsite(context.Background(), priv); err != nil {
+				t.Fatalf("PublishWebsite: %v", err)
+			}
+			if tt.foreign {
+				rec, err := p2p.BuildFileRecord(otherPriv, "about.html", core.CIDForContent([]byte("<p>about</p>")), "text/html")
+				if err != nil {
+					t.Fatalf("BuildFileRecord: %v", err)
+				}
+				data, _ := core.CanonicalMarshalFileRecord(rec)
+				if err := src.PutFileRecord(siteID, "about.html", core.CIDForBytes(data), data); err != nil {
+					t.Fatalf("PutFileRecord: %v", err)
+				}
+			}
+
+			var car bytes.Buffer
+			if _, err := src.ExportSiteCAR(&car, siteID); err != nil {
+				t.Fatalf("ExportSiteCAR: %v", err)
+			}
+			dst, err := store.NewMemory()
+			if err != nil {
+				t.Fatalf("NewMemory: %v", err)
+			}
+			defer dst.Close()
+			res, err := dst.ImportCAR(&car)
+			if err != nil {
+				t.Fatalf("ImportCAR: %v", err)
+			}
+			ws := &WebServer{store: dst}
+			applied, reason := ws.applyImportedManifest(res.Site)
+			if applied == tt.foreign {
+				t.Errorf("applyImportedManifest() = %v, %q", applied, reason)
+			}
+			if dst.HasWebsiteManifest(siteID) != applied {
+				t.Error("manifest stored state does not match the result")
+			}
+		})
+	}
+}
@@ -0,0 +1,302 @@
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Background tasks. Publishing a large website, fetching a site from peers
+// or re-announcing every pinned site can take longer than a request should
+// block, so these run as tasks: the request that starts one returns its ID
+// at once, and the UI polls /api/tasks for progress and can cancel it.
+const (
+	// maxFinishedTasks is how many finished tasks are kept for the UI to
+	// collect their results; the oldest are dropped first.
+	maxFinishedTasks = 50
+	// taskTimeout bounds one task.
+	taskTimeout = 30 * time.Minute
+)
+
+// Task states
+const (
+	TaskRunning  = "running"
+	TaskDone     = "done"
+	TaskFailed   = "failed"
+	TaskCanceled = "canceled"
+)
+
+// Task is a background operation and its progress.
+type Task struct {
+	ID       string      `json:"id"`
+	Kind     string      `json:"kind"` // publish, sync or fetch
+	Title    string      `json:"title"`
+	State    string      `json:"state"`
+	Done     int         `json:"done"`
+	Total    int         `json:"total"` // 0 while unknown
+	Message  string      `json:"message,omitempty"`
+	Error    string      `json:"error,omitempty"`
+	Result   interface{} `json:"result,omitempty"`
+	Started  time.Time   `json:"started"`
+	Finished *time.Time  `json:"finished,omitempty"`
+}
+
+// taskProgress reports how much of a task is done.
+type taskProgress func(done, total int, message string)
+
+// taskFunc does the work of a task and returns its result.
+type taskFunc func(ctx context.Context, progress taskProgress) (interface{}, error)
+
+// taskRunner runs tasks in their own goroutines and keeps their state.
+type taskRunner struct {
+	mu     sync.Mutex
+	tasks  map[string]*runningTask
+	order  []string // IDs, oldest first
+	nextID int
+	logger *zap.Logger
+}
+
+type runningTask struct {
+	task   Task
+	cancel context.CancelFunc
+}
+
+func newTaskRunner(logger *zap.Logger) *taskRunner {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &taskRunner{tasks: make(map[string]*runningTask), logger: logger}
+}
+
+// start runs fn in the background under ctx and returns the new task.
+func (tr *taskRunner) start(ctx context.Context, kind, title string, fn taskFunc) Task {
+	ctx, cancel := context.WithTimeout(ctx, taskTimeout)
+
+	tr.mu.Lock()
+	tr.nextID++
+	rt := &runningTask{
+		task: Task{
+			ID:      fmt.Sprintf("%s-%d", kind, tr.nextID),
+			Kind:    kind,
+			Title:   title,
+			State:   TaskRunning,
+			Started: time.Now().UTC(),
+		},
+		cancel: cancel,
+	}
+	tr.tasks[rt.task.ID] = rt
+	tr.order = append(tr.order, rt.task.ID)
+	tr.evictLocked()
+	snapshot := rt.task
+	tr.mu.Unlock()
+
+	go func() {
+		defer cancel()
+		result, err := fn(ctx, func(done, total int, message string) {
+			tr.mu.Lock()
+			rt.task.Done, rt.task.Total, rt.task.Message = done, total, message
+			tr.mu.Unlock()
+		})
+
+		tr.mu.Lock()
+		defer tr.mu.Unlock()
+		now := time.Now().UTC()
+		rt.task.Finished = &now
+		switch {
+		case err != nil && errors.Is(ctx.Err(), context.Canceled):
+			rt.task.State = TaskCanceled
+		case err != nil:
+			rt.task.State, rt.task.Error = TaskFailed, err.Error()
+		default:
+			rt.task.State, rt.task.Result = TaskDone, result
+		}
+		tr.logger.Info("task finished",
+			zap.String("id", rt.task.ID),
+			zap.String("state", rt.task.State),
+			zap.Duration("took", now.Sub(rt.task.Started)),
+			zap.Error(err))
+	}()
+	return snapshot
+}
+
+// evictLocked drops the oldest finished tasks beyond maxFinishedTasks.
+func (tr *taskRunner) evictLocked() {
+	finished := 0
+	for _, id := range tr.order {
+		if tr.tasks[id].task.State != TaskRunning {
+			finished++
+		}
+	}
+	kept := tr.order[:0]
+	for _, id := range tr.order {
+		if finished > maxFinishedTasks && tr.tasks[id].task.State != TaskRunning {
+			delete(tr.tasks, id)
+			finished--
+			continue
+		}
+		kept = append(kept, id)
+	}
+	tr.order = kept
+}
+
+// get returns a copy of the task with id.
+func (tr *taskRunner) get(id string) (Task, bool) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	rt, ok := tr.tasks[id]
+	if !ok {
+		return Task{}, false
+	}
+	return rt.task, true
+}
+
+// list returns copies of all tasks, newest first.
+func (tr *taskRunner) list() []Task {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	out := make([]Task, 0, len(tr.order))
+	for i := len(tr.order) - 1; i >= 0; i-- {
+		out = append(out, tr.tasks[tr.order[i]].task)
+	}
+	return out
+}
+
+// cancel stops a running task; it reports false for unknown IDs.
+func (tr *taskRunner) cancel(id string) bool {
+	tr.mu.Lock()
+	rt, ok := tr.tasks[id]
+	tr.mu.Unlock()
+	if ok {
+		rt.cancel()
+	}
+	return ok
+}
+
+// startTask runs fn as a background task of this server and answers the
+// request with it.
+func (ws *WebServer) startTask(w http.ResponseWriter, kind, title string, fn taskFunc) {
+	task := ws.tasks.start(ws.ctx, kind, title, fn)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"task":    task,
+	}); err != nil {
+		ws.logger.Debug("failed to encode task", zap.Error(err))
+	}
+}
+
+// handleTasks lists the background tasks, newest first, or returns the one
+// named by ?id=.
+func (ws *WebServer) handleTasks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if id := r.URL.Query().Get("id"); id != "" {
+		task, ok := ws.tasks.get(id)
+		if !ok {
+			http.Error(w, "Task not found", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, map[string]interface{}{"success": true, "task": task})
+		return
+	}
+	writeJSON(w, map[string]interface{}{"success": true, "tasks": ws.tasks.list()})
+}
+
+// handleTaskCancel cancels a running task: POST {"id": "…"}. Cancelling a
+// finished task does nothing.
+func (ws *WebServer) handleTaskCancel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1024)).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if !ws.tasks.cancel(req.ID) {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
+	task, _ := ws.tasks.get(req.ID)
+	writeJSON(w, map[string]interface{}{"success": true, "task": task})
+}
+
+// handleTaskSync announces the head of every pinned site, one after
+// another, as a background task, so peers catch up without waiting for
+// the next re-announcement.
+func (ws *WebServer) handleTaskSync(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if ws.node == nil {
+		http.Error(w, "P2P node not available", http.StatusServiceUnavailable)
+		return
+	}
+	ws.startTask(w, "sync", "Announce pinned sites", func(ctx context.Context, progress taskProgress) (interface{}, error) {
+		sites, err := ws.store.ListPinnedSites()
+		if err != nil {
+			return nil, err
+		}
+		for i, siteID := range sites {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			progress(i, len(sites), siteID)
+			if err := ws.node.PinSite(ctx, siteID); err != nil {
+				return nil, fmt.Errorf("site %s: %w", siteID, err)
+			}
+		}
+		progress(len(sites), len(sites), "")
+		return map[string]int{"sites": len(sites)}, nil
+	})
+}
+
+// handleTaskFetch loads every file of a site into the asset cache as a
+// background task: POST {"site": "<site ID or name>"}. Unlike /api/load it
+// goes on when the page that started it is closed.
+func (ws *WebServer) handleTaskFetch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		Site string `json:"site"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1024)).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	siteID := req.Site
+	if len(siteID) != 64 || !isHex(siteID) {
+		var err error
+		if siteID, err = ws.resolveSiteName(r.Context(), req.Site); err != nil {
+			http.Error(w, "Site name not found", http.StatusNotFound)
+			return
+		}
+	}
+	ws.startTask(w, "fetch", "Fetch "+req.Site, func(ctx context.Context, progress taskProgress) (interface{}, error) {
+		done, err := ws.loadSite(ctx, siteID, func(ev LoadEvent) {
+			progress(ev.Loaded+ev.Failed, ev.Total, ev.Path)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return done, nil
+	})
+}
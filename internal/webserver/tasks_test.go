@@ -0,0 +1,131 @@
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// waitTask polls tr until the task with id leaves the running state.
+func waitTask(t *testing.T, tr *taskRunner, id string) Task {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if task, ok := tr.get(id); ok && task.State != TaskRunning {
+			return task
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("task %s still running", id)
+	return Task{}
+}
+
+func TestTaskRunner(t *testing.T) {
+	tr := newTaskRunner(nil)
+	release := make(chan struct{})
+
+	done := tr.start(context.Background(), "publish", "ok", func(ctx context.Context, progress taskProgress) (interface{}, error) {
+		progress(1, 2, "half")
+		<-release
+		progress(2, 2, "")
+		return "result", nil
+	})
+	failed := tr.start(context.Background(), "fetch", "fails", func(context.Context, taskProgress) (interface{}, error) {
+		return nil, errors.New("no peers")
+	})
+	canceled := tr.start(context.Background(), "sync", "canceled", func(ctx context.Context, _ taskProgress) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	if !tr.cancel(canceled.ID) || tr.cancel("sync-999") {
+		t.Error("cancel() of a known and an unknown task")
+	}
+	close(release)
+
+	tests := []struct {
+		id, state, err string
+		done           int
+	}{
+		{done.ID, TaskDone, "", 2},
+		{failed.ID, TaskFailed, "no peers", 0},
+		{canceled.ID, TaskCanceled, "", 0},
+	}
+	for _, tt := range tests {
+		got := waitTask(t, tr, tt.id)
+		if got.State != tt.state || got.Error != tt.err || got.Done != tt.done || got.Finished == nil {
+			t.Errorf("task %s = %+v, want state %s, error %q, done %d", tt.id, got, tt.state, tt.err, tt.done)
+		}
+	}
+	if got, _ := tr.get(done.ID); got.Result != "result" {
+		t.Errorf("result = %v, want result", got.Result)
+	}
+	if list := tr.list(); len(list) != 3 || list[0].ID != canceled.ID {
+		t.Errorf("list() = %+v, want 3 tasks, newest first", list)
+	}
+}
+
+func TestTaskRunnerEviction(t *testing.T) {
+	tr := newTaskRunner(nil)
+	noop := func(context.Context, taskProgress) (interface{}, error) { return nil, nil }
+	first := tr.start(context.Background(), "sync", "first", noop)
+	waitTask(t, tr, first.ID)
+	for i := 0; i < maxFinishedTasks; i++ {
+		waitTask(t, tr, tr.start(context.Background(), "sync", "more", noop).ID)
+	}
+	tr.start(context.Background(), "sync", "last", noop)
+	if _, ok := tr.get(first.ID); ok {
+		t.Error("oldest finished task kept")
+	}
+	if n := len(tr.list()); n != maxFinishedTasks+1 {
+		t.Errorf("%d tasks kept, want %d", n, maxFinishedTasks+1)
+	}
+}
+
+func TestTaskHandlers(t *testing.T) {
+	ws := &WebServer{ctx: context.Background(), logger: zap.NewNop(), tasks: newTaskRunner(nil)}
+	rec := httptest.NewRecorder()
+	ws.startTask(rec, "sync", "wait", func(ctx context.Context, _ taskProgress) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	var started struct {
+		Task Task `json:"task"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &started); err != nil || rec.Code != http.StatusAccepted || started.Task.State != TaskRunning {
+		t.Fatalf("start = %d %s, %v", rec.Code, rec.Body, err)
+	}
+
+	tests := []struct {
+		method, target, body string
+		status               int
+	}{
+		{http.MethodGet, "/api/tasks?id=" + started.Task.ID, "", http.StatusOK},
+		{http.MethodGet, "/api/tasks?id=sync-999", "", http.StatusNotFound},
+		{http.MethodPost, "/api/tasks/cancel", `{"id":"sync-999"}`, http.StatusNotFound},
+		{http.MethodPost, "/api/tasks/cancel", `{"id":"` + started.Task.ID + `"}`, http.StatusOK},
+		{http.MethodGet, "/api/tasks/cancel", "", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+		if strings.HasPrefix(tt.target, "/api/tasks/cancel") {
+			ws.handleTaskCancel(rec, req)
+		} else {
+			ws.handleTasks(rec, req)
+		}
+		if rec.Code != tt.status {
+			t.Errorf("%s %s = %d, want %d: %s", tt.method, tt.target, rec.Code, tt.status, rec.Body)
+		}
+	}
+	if got := waitTask(t, ws.tasks, started.Task.ID); got.State != TaskCanceled {
+		t.Errorf("state after cancel = %s, want %s", got.State, TaskCanceled)
+	}
+}
@@ -0,0 +1,187 @@
+package webserver
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"os"
+
+	"alxnet/internal/config"
+
+	"go.uber.org/zap"
+)
+
+// embeddedUI holds the pages of the browser, wallet and node interfaces,
+// the partials they share and the static files under static/.
+//
+//go:embed ui
+var embeddedUI embed.FS
+
+// uiPages are the templates rendered by renderPage.
+var uiPages = []string{"browser.html", "search.html", "verify.html", "wallet.html", "node.html"}
+
+// pageData is what the page templates see.
+type pageData struct {
+	Brand     string
+	Theme     string
+	Logo      string
+	Accent    string
+	Lang      string
+	Languages []languageOption
+	Messages  map[string]string // the catalog of Lang, for t() in page scripts
+}
+
+// T returns the text of message key in the page's language.
+func (d pageData) T(key string) string {
+	if s, ok := d.Messages[key]; ok {
+		return s
+	}
+	return key
+}
+
+// ui renders the interface pages from the embedded files, or from an
+// operator's assets directory laid out like internal/webserver/ui, where a
+// file replaces the embedded one of the same name.
+type ui struct {
+	files        fs.FS
+	data         pageData
+	bundle       *uiBundle // nil reloads on every request, see newUI
+	remoteImages bool      // reader-mode pages load images from other hosts
+}
+
+// uiBundle is the parsed templates and message catalogs.
+type uiBundle struct {
+	pages   *template.Template
+	locales *locales
+}
+
+// overlayFS opens files from top and falls back to base.
+type overlayFS struct {
+	top, base fs.FS
+}
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	f, err := o.top.Open(name)
+	if err == nil {
+		return f, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return o.base.Open(name)
+}
+
+// newUI checks cfg against the available themes and templates. Templates
+// from an assets directory are re-read on every request, so pages can be
+// edited without restarting the node.
+func newUI(cfg config.UIConfig) (*ui, error) {
+	files, err := fs.Sub(embeddedUI, "ui")
+	if err != nil {
+		return nil, err
+	}
+	u := &ui{
+		data:         pageData{Brand: cfg.Brand, Theme: cfg.Theme, Logo: cfg.LogoURL, Accent: cfg.Accent},
+		remoteImages: cfg.ReaderRemoteImages,
+	}
+	if cfg.AssetsDir != "" {
+		if info, err := os.Stat(cfg.AssetsDir); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("ui assets directory %s is not a directory", cfg.AssetsDir)
+		}
+		files = overlayFS{top: os.DirFS(cfg.AssetsDir), base: files}
+	}
+	u.files = files
+
+	if _, err := fs.Stat(files, "static/themes/"+cfg.Theme+".css"); err != nil {
+		return nil, fmt.Errorf("unknown ui theme %q: no static/themes/%s.css", cfg.Theme, cfg.Theme)
+	}
+	bundle, err := u.load()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.AssetsDir == "" {
+		u.bundle = bundle
+	}
+	return u, nil
+}
+
+func (u *ui) load() (*uiBundle, error) {
+	t, err := template.ParseFS(u.files, "partials.html")
+	if err != nil {
+		return nil, fmt.Errorf("ui partials: %w", err)
+	}
+	for _, name := range uiPages {
+		if _, err := t.New(name).ParseFS(u.files, name); err != nil {
+			return nil, fmt.Errorf("ui page %s: %w", name, err)
+		}
+	}
+	l, err := loadLocales(u.files)
+	if err != nil {
+		return nil, err
+	}
+	return &uiBundle{pages: t, locales: l}, nil
+}
+
+// defaultUI is the embedded interface with the default theme and brand.
+var defaultUI = func() *ui {
+	u, err := newUI(config.DefaultConfig().UI)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}()
+
+// SetUI applies the theme and branding in cfg to the server's pages.
+func (ws *WebServer) SetUI(cfg config.UIConfig) error {
+	u, err := newUI(cfg)
+	if err != nil {
+		return err
+	}
+	ws.ui = u
+	return nil
+}
+
+func (ws *WebServer) currentUI() *ui {
+	if ws.ui != nil {
+		return ws.ui
+	}
+	return defaultUI
+}
+
+// renderPage writes the interface page name in the language negotiated
+// for r.
+func (ws *WebServer) renderPage(w http.ResponseWriter, r *http.Request, name string) {
+	u := ws.currentUI()
+	bundle := u.bundle
+	if bundle == nil {
+		var err error
+		if bundle, err = u.load(); err != nil {
+			ws.logger.Error("Failed to load ui templates", zap.Error(err))
+			http.Error(w, "Failed to load page", http.StatusInternalServerError)
+			return
+		}
+	}
+	data := u.data
+	data.Lang = bundle.locales.negotiate(w, r)
+	data.Languages = bundle.locales.options(data.Lang)
+	data.Messages = bundle.locales.catalogs[data.Lang]
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Language", data.Lang)
+	w.Header().Add("Vary", "Accept-Language, Cookie")
+	if err := bundle.pages.ExecuteTemplate(w, name, data); err != nil {
+		ws.logger.Error("Failed to render page", zap.String("page", name), zap.Error(err))
+	}
+}
+
+// handleUIStatic serves themes and images under /_alxnet/ui/.
+func (ws *WebServer) handleUIStatic(w http.ResponseWriter, r *http.Request) {
+	static, err := fs.Sub(ws.currentUI().files, "static")
+	if err != nil {
+		http.Error(w, "Failed to load assets", http.StatusInternalServerError)
+		return
+	}
+	http.StripPrefix("/_alxnet/ui/", http.FileServer(http.FS(static))).ServeHTTP(w, r)
+}
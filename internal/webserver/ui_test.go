@@ -0,0 +1,123 @@
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"alxnet/internal/config"
+
+	"go.uber.org/zap"
+)
+
+func TestRenderPage(t *testing.T) {
+	tests := []struct {
+		name string
+		ui   config.UIConfig
+		want []string
+		deny []string
+	}{
+		{
+			name: "default",
+			ui:   config.DefaultConfig().UI,
+			want: []string{`data-theme="classic"`, `href="/_alxnet/ui/themes/classic.css"`, "AlxNet"},
+			deny: []string{"brand-logo\" src", "--accent:"},
+		},
+		{
+			name: "branded",
+			ui:   config.UIConfig{Theme: "dark", Brand: "Acme <Net>", LogoURL: "/_alxnet/ui/img/logo.svg", Accent: "#00aa00"},
+			want: []string{`themes/dark.css`, "Acme &lt;Net&gt;", `src="/_alxnet/ui/img/logo.svg"`, "--accent: #00aa00"},
+			deny: []string{"Acme <Net>"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := &WebServer{logger: zap.NewNop()}
+			if err := ws.SetUI(tt.ui); err != nil {
+				t.Fatal(err)
+			}
+			for _, page := range uiPages {
+				rec := httptest.NewRecorder()
+				ws.renderPage(rec, httptest.NewRequest(http.MethodGet, "/", nil), page)
+				body := rec.Body.String()
+				if rec.Code != http.StatusOK || !strings.Contains(body, "</html>") {
+					t.Fatalf("%s: status %d, %d bytes", page, rec.Code, len(body))
+				}
+				for _, s := range tt.want {
+					if !strings.Contains(body, s) {
+						t.Errorf("%s: missing %q", page, s)
+					}
+				}
+				for _, s := range tt.deny {
+					if strings.Contains(body, s) {
+						t.Errorf("%s: contains %q", page, s)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestUIAssetsDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "static", "themes"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "static", "themes", "acme.css"), []byte(":root { --accent: #123456; }"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	page := filepath.Join(dir, "node.html")
+	if err := os.WriteFile(page, []byte(`<html>{{.Brand}} v1</html>`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := (&WebServer{}).SetUI(config.UIConfig{Theme: "acme", Brand: "Acme"}); err == nil {
+		t.Error("theme missing from the embedded files was accepted")
+	}
+	if err := (&WebServer{}).SetUI(config.UIConfig{Theme: "classic", Brand: "Acme", AssetsDir: filepath.Join(dir, "missing")}); err == nil {
+		t.Error("missing assets directory was accepted")
+	}
+
+	ws := &WebServer{logger: zap.NewNop()}
+	if err := ws.SetUI(config.UIConfig{Theme: "acme", Brand: "Acme", AssetsDir: dir}); err != nil {
+		t.Fatal(err)
+	}
+	render := func(name string) string {
+		rec := httptest.NewRecorder()
+		ws.renderPage(rec, httptest.NewRequest(http.MethodGet, "/", nil), name)
+		return rec.Body.String()
+	}
+	if got := render("node.html"); got != "<html>Acme v1</html>" {
+		t.Errorf("node.html = %q", got)
+	}
+	// Pages from an assets directory are re-read, so edits show up at once
+	if err := os.WriteFile(page, []byte(`<html>{{.Brand}} v2</html>`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := render("node.html"); got != "<html>Acme v2</html>" {
+		t.Errorf("edited node.html = %q", got)
+	}
+	if got := render("wallet.html"); !strings.Contains(got, "themes/acme.css") {
+		t.Error("embedded wallet.html does not fall back from the assets directory")
+	}
+
+	for _, tt := range []struct {
+		path string
+		want int
+	}{
+		{"/_alxnet/ui/themes/acme.css", http.StatusOK},
+		{"/_alxnet/ui/themes/dark.css", http.StatusOK},
+		{"/_alxnet/ui/../node.html", http.StatusNotFound},
+		{"/_alxnet/ui/%2e%2e/partials.html", http.StatusNotFound},
+		{"/_alxnet/ui/themes/none.css", http.StatusNotFound},
+	} {
+		rec := httptest.NewRecorder()
+		ws.handleUIStatic(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != tt.want {
+			t.Errorf("GET %s: status %d, want %d", tt.path, rec.Code, tt.want)
+		}
+	}
+}
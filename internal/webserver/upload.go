@@ -0,0 +1,239 @@
+package webserver
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"alxnet/internal/core"
+	"alxnet/internal/fingerprint"
+)
+
+// Upload limits. Each file is also bounded by core.MaxContentSize and the
+// number of files by core.MaxFileCount.
+const (
+	maxUploadBytes       = 100 << 20 // request body, and unpacked zip contents
+	uploadMemory         = 32 << 20  // multipart parts beyond this go to temp files
+	uploadFieldFiles     = "files"
+	uploadFieldPaths     = "paths"
+	uploadFieldArchive   = "archive"
+	uploadFieldSiteLabel = "site_label"
+	// uploadFieldFingerprint asks for the folder's asset references to be
+	// rewritten to CID-addressed paths before it is saved.
+	uploadFieldFingerprint = "fingerprint"
+)
+
+// uploadSet collects the files of an upload, enforcing the upload limits.
+type uploadSet struct {
+	files map[string][]byte
+	total int64
+}
+
+func newUploadSet() *uploadSet {
+	return &uploadSet{files: make(map[string][]byte)}
+}
+
+// add reads one file of at most core.MaxContentSize bytes into the set.
+func (u *uploadSet) add(name string, r io.Reader) error {
+	p, ok := core.CanonicalFilePath(name)
+	if !ok || p == "" || p == "." {
+		return fmt.Errorf("invalid file path: %q", name)
+	}
+	if err := core.ValidateFilePath(p); err != nil {
+		return fmt.Errorf("%s: %v", p, err)
+	}
+	if _, dup := u.files[p]; dup {
+		return fmt.Errorf("duplicate file path: %s", p)
+	}
+	if len(u.files) >= core.MaxFileCount {
+		return fmt.Errorf("too many files (maximum %d)", core.MaxFileCount)
+	}
+	data, err := io.ReadAll(io.LimitReader(r, core.MaxContentSize+1))
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", p, err)
+	}
+	if len(data) > core.MaxContentSize {
+		return fmt.Errorf("%s is larger than %d bytes", p, core.MaxContentSize)
+	}
+	if u.total += int64(len(data)); u.total > maxUploadBytes {
+		return fmt.Errorf("upload larger than %d bytes", maxUploadBytes)
+	}
+	u.files[p] = data
+	return nil
+}
+
+// addZip adds every regular file of a zip archive. Sizes are checked while
+// reading, not trusted from the archive headers.
+func (u *uploadSet) addZip(r io.ReaderAt, size int64) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return fmt.Errorf("invalid zip archive: %v", err)
+	}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || skipUploadPath(f.Name) {
+			continue
+		}
+		if !f.Mode().IsRegular() {
+			return fmt.Errorf("unsupported zip entry: %s", f.Name)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %v", f.Name, err)
+		}
+		err = u.add(f.Name, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// skipUploadPath reports operating system litter that should not become
+// part of a site.
+func skipUploadPath(p string) bool {
+	base := path.Base(p)
+	return strings.HasPrefix(p, "__MACOSX/") || base == ".DS_Store" || base == "Thumbs.db"
+}
+
+// stripCommonRoot removes a top-level folder shared by every file, so that
+// dropping "mysite/" or a zip of it yields "index.html" rather than
+// "mysite/index.html".
+func (u *uploadSet) stripCommonRoot() {
+	var root string
+	for p := range u.files {
+		dir, _, ok := strings.Cut(p, "/")
+		if !ok || (root != "" && dir != root) {
+			return
+		}
+		root = dir
+	}
+	if root == "" {
+		return
+	}
+	stripped := make(map[string][]byte, len(u.files))
+	for p, data := range u.files {
+		stripped[strings.TrimPrefix(p, root+"/")] = data
+	}
+	u.files = stripped
+}
+
+// parseUpload reads the files of an upload request: either "files" parts
+// with their relative paths in matching "paths" values (browsers only send
+// base names as part file names), or one zip "archive".
+func parseUpload(form *multipart.Form) (*uploadSet, error) {
+	u := newUploadSet()
+	if archives := form.File[uploadFieldArchive]; len(archives) > 0 {
+		if len(archives) > 1 || len(form.File[uploadFieldFiles]) > 0 {
+			return nil, errors.New("send either one archive or files, not both")
+		}
+		f, err := archives[0].Open()
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		if err := u.addZip(f, archives[0].Size); err != nil {
+			return nil, err
+		}
+	} else {
+		files := form.File[uploadFieldFiles]
+		paths := form.Value[uploadFieldPaths]
+		if len(files) == 0 {
+			return nil, errors.New("no files uploaded")
+		}
+		if len(paths) != len(files) {
+			return nil, errors.New("each uploaded file needs a path")
+		}
+		for i, fh := range files {
+			if skipUploadPath(paths[i]) {
+				continue
+			}
+			f, err := fh.Open()
+			if err != nil {
+				return nil, err
+			}
+			err = u.add(paths[i], f)
+			f.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(u.files) == 0 {
+		return nil, errors.New("no files uploaded")
+	}
+	u.stripCommonRoot()
+	return u, nil
+}
+
+// handleUploadFiles saves a whole website folder, sent as multipart files
+// or a zip archive, into a wallet site's working copy: POST /api/site/upload.
+// With fingerprint=true, its HTML and CSS reference unchanged assets by
+// CID, see package fingerprint.
+func (ws *WebServer) handleUploadFiles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadBytes)
+	if err := r.ParseMultipartForm(uploadMemory); err != nil {
+		writeWalletError(w, http.StatusBadRequest, "Invalid upload: "+err.Error())
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	siteID, priv, ok := ws.walletSiteKey(w, r, r.FormValue("wallet_data"), r.FormValue("mnemonic"), r.FormValue("mnemonic_passphrase"), r.FormValue(uploadFieldSiteLabel))
+	if !ok {
+		return
+	}
+
+	upload, err := parseUpload(r.MultipartForm)
+	if err != nil {
+		writeWalletError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	rewritten := 0
+	if fp, _ := strconv.ParseBool(r.FormValue(uploadFieldFingerprint)); fp {
+		upload.files, rewritten = fingerprint.Rewrite(upload.files)
+	}
+
+	paths := make([]string, 0, len(upload.files))
+	for p := range upload.files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	pub := ws.publisher()
+	saved := make([]map[string]interface{}, 0, len(paths))
+	for _, p := range paths {
+		// Unknown extensions are served as opaque bytes, never assumed text
+		mimeType, ok := knownMimeType(p)
+		if !ok {
+			mimeType = "application/octet-stream"
+		}
+		f, err := pub.SaveFile(priv, p, upload.files[p], mimeType)
+		if err != nil {
+			publishError(w, err)
+			return
+		}
+		saved = append(saved, map[string]interface{}{
+			"path":        p,
+			"content_cid": f.ContentCID,
+			"mime_type":   mimeType,
+			"size":        len(upload.files[p]),
+		})
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"success":       true,
+		"site_id":       siteID,
+		"files":         saved,
+		"fingerprinted": rewritten,
+	})
+}
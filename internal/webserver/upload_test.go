@@ -0,0 +1,122 @@
+package webserver
+
+import (
+	"archive/zip"
+	"bytes"
+	"mime/multipart"
+	"sort"
+	"strings"
+	"testing"
+
+	"alxnet/internal/core"
+)
+
+func zipArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestUploadZip(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		want    []string
+		wantErr string
+	}{
+		{
+			name:  "folder root stripped",
+			files: map[string]string{"mysite/index.html": "<h1>hi</h1>", "mysite/css/site.css": "h1{}", "__MACOSX/mysite/._index.html": "x", "mysite/.DS_Store": "x"},
+			want:  []string{"css/site.css", "index.html"},
+		},
+		{
+			name:  "flat archive kept",
+			files: map[string]string{"index.html": "a", "img/logo.png": "b"},
+			want:  []string{"img/logo.png", "index.html"},
+		},
+		{
+			name:    "path escaping the site",
+			files:   map[string]string{"../evil.html": "x"},
+			wantErr: "invalid file path",
+		},
+		{
+			name:    "file over the content limit",
+			files:   map[string]string{"big.txt": strings.Repeat("x", core.MaxContentSize+1)},
+			wantErr: "larger than",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archive := zipArchive(t, tt.files)
+			u := newUploadSet()
+			err := u.addZip(bytes.NewReader(archive), int64(len(archive)))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("addZip() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("addZip: %v", err)
+			}
+			u.stripCommonRoot()
+			var got []string
+			for p := range u.files {
+				got = append(got, p)
+			}
+			sort.Strings(got)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("paths = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseUploadFiles(t *testing.T) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, f := range []struct{ path, content string }{
+		{"site/index.html", "<p>home</p>"},
+		{"site/js/app.js", "alert(1)"},
+	} {
+		part, err := mw.CreateFormFile(uploadFieldFiles, f.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write([]byte(f.content))
+		mw.WriteField(uploadFieldPaths, f.path)
+	}
+	mw.Close()
+
+	form, err := multipart.NewReader(&body, mw.Boundary()).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer form.RemoveAll()
+	u, err := parseUpload(form)
+	if err != nil {
+		t.Fatalf("parseUpload: %v", err)
+	}
+	if string(u.files["index.html"]) != "<p>home</p>" || string(u.files["js/app.js"]) != "alert(1)" || len(u.files) != 2 {
+		t.Errorf("files = %v", u.files)
+	}
+
+	// Paths are required: part file names lose their directories
+	delete(form.Value, uploadFieldPaths)
+	if _, err := parseUpload(form); err == nil {
+		t.Error("parseUpload accepted files without paths")
+	}
+}
@@ -0,0 +1,273 @@
+package webserver
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"alxnet/internal/core"
+	"alxnet/internal/verify"
+)
+
+// verifyProvidersTimeout bounds asking peers which files of a site they
+// hold.
+const verifyProvidersTimeout = 10 * time.Second
+
+// siteVerification is what the browser's verification panel shows about a
+// stored site, like a padlock does for a TLS connection: who signed it, the
+// version held here, whether every signature and content hash checks out,
+// and how many peers can serve it.
+type siteVerification struct {
+	SiteID      string            `json:"site_id"`
+	Name        string            `json:"name,omitempty"` // as requested, if not a site ID
+	SitePub     string            `json:"site_pub,omitempty"`
+	Seq         uint64            `json:"seq"`
+	ManifestCID string            `json:"manifest_cid,omitempty"`
+	HeadCID     string            `json:"head_cid,omitempty"`
+	Root        string            `json:"root,omitempty"`         // verify.ManifestFilesCID of Files
+	RootMatches *bool             `json:"root_matches,omitempty"` // against ?root=
+	Files       map[string]string `json:"files,omitempty"`        // path -> content CID
+	Published   time.Time         `json:"published"`
+	Signatures  signatureCheck    `json:"signatures"`
+	Content     contentCheck      `json:"content"`
+	Providers   *providerCheck    `json:"providers,omitempty"`
+	// Verified is set when every signature is valid and every stored file
+	// matches its CID. Files not stored here do not count against it.
+	Verified  bool      `json:"verified"`
+	Problems  []string  `json:"problems,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// signatureCheck counts the records whose signatures were checked: the
+// manifest or head record, and the file record of each file.
+type signatureCheck struct {
+	Valid   bool `json:"valid"`
+	Checked int  `json:"checked"`
+	Invalid int  `json:"invalid"`
+}
+
+// contentCheck reports the files of the site whose content was hashed.
+type contentCheck struct {
+	Files      int      `json:"files"`
+	Matched    int      `json:"matched"`
+	Mismatched []string `json:"mismatched,omitempty"`
+	Missing    []string `json:"missing,omitempty"` // not stored here
+}
+
+// providerCheck reports how many sampled peers hold the site's files.
+// Providers counts the peers holding the least-held file, so at most that
+// many can serve the whole site.
+type providerCheck struct {
+	Queried   int `json:"queried"`
+	Responded int `json:"responded"`
+	Providers int `json:"providers"`
+}
+
+func (v *siteVerification) problem(format string, args ...interface{}) {
+	v.Problems = append(v.Problems, fmt.Sprintf(format, args...))
+}
+
+// handleVerifyPage serves the verification panel of the site named by
+// ?site=. Like the search page it lives under /_alxnet/.
+func (ws *WebServer) handleVerifyPage(w http.ResponseWriter, r *http.Request) {
+	ws.renderPage(w, r, "verify.html")
+}
+
+// handleAPISiteVerify checks a stored site: GET /api/site/verify/{site},
+// where site is a site ID or name. Unless ?peers=0, up to ?peers=N
+// (default 5) connected peers are asked which of its files they hold. A
+// site whose root differs from ?root=, if given, is not verified.
+func (ws *WebServer) handleAPISiteVerify(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, "/api/site/verify/")
+	if name == "" {
+		http.Error(w, "Site ID or name is required", http.StatusBadRequest)
+		return
+	}
+	samplePeers := defaultCheckPeers
+	if v := r.URL.Query().Get("peers"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > maxCheckPeers {
+			http.Error(w, fmt.Sprintf("peers must be between 0 and %d", maxCheckPeers), http.StatusBadRequest)
+			return
+		}
+		samplePeers = n
+	}
+	wantRoot := strings.ToLower(r.URL.Query().Get("root"))
+	if wantRoot != "" && (len(wantRoot) != 64 || !isHex(wantRoot)) {
+		http.Error(w, "root must be 64 hex characters", http.StatusBadRequest)
+		return
+	}
+
+	siteID := name
+	if len(name) != 64 || !isHex(name) {
+		var err error
+		if siteID, err = ws.resolveSiteName(r.Context(), name); err != nil {
+			http.Error(w, "Site name not found", http.StatusNotFound)
+			return
+		}
+	}
+	if !ws.storesSite(siteID) {
+		http.Error(w, "Site is not stored on this node", http.StatusNotFound)
+		return
+	}
+
+	v, files := ws.verifySite(siteID)
+	if name != siteID {
+		v.Name = name
+	}
+	if wantRoot != "" {
+		matches := v.Root == wantRoot
+		v.RootMatches = &matches
+		if !matches {
+			v.Verified = false
+			v.problem("site root %s is not the expected %s", v.Root, wantRoot)
+		}
+	}
+	if samplePeers > 0 && ws.node != nil && len(files) > 0 {
+		ctx, cancel := context.WithTimeout(r.Context(), verifyProvidersTimeout)
+		defer cancel()
+		cids := make([]string, 0, len(files))
+		for _, cid := range files {
+			cids = append(cids, cid)
+		}
+		if report, err := ws.node.CheckAvailability(ctx, siteID, cids, samplePeers); err == nil {
+			p := &providerCheck{Queried: report.Queried, Responded: report.Responded, Providers: -1}
+			for _, n := range report.Holders {
+				if p.Providers < 0 || n < p.Providers {
+					p.Providers = n
+				}
+			}
+			p.Providers = max(p.Providers, 0)
+			v.Providers = p
+		}
+	}
+	writeJSON(w, v)
+}
+
+// verifySite checks the signatures and content of a stored site. It also
+// returns the site's files with their content CIDs.
+func (ws *WebServer) verifySite(siteID string) (*siteVerification, map[string]string) {
+	v := &siteVerification{SiteID: siteID, CheckedAt: time.Now().UTC()}
+	var files map[string]string
+
+	if ws.store.HasWebsiteManifest(siteID) {
+		files = ws.verifyManifest(v)
+	} else {
+		files = ws.verifyHead(v)
+	}
+	v.Signatures.Valid = v.Signatures.Checked > 0 && v.Signatures.Invalid == 0
+
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	v.Content.Files = len(paths)
+	for _, path := range paths {
+		cid := files[path]
+		if has, _ := ws.store.HasContent(cid); !has {
+			v.Content.Missing = append(v.Content.Missing, path)
+			continue
+		}
+		data, err := ws.store.GetContent(cid)
+		if err != nil || !verify.Content(data, cid) {
+			v.Content.Mismatched = append(v.Content.Mismatched, path)
+			v.problem("%s does not match its content CID", path)
+			continue
+		}
+		v.Content.Matched++
+	}
+
+	v.Verified = v.Signatures.Valid && len(v.Content.Mismatched) == 0
+	if files != nil {
+		v.Files = files
+		v.Root, _ = verify.ManifestFilesCID(files)
+	}
+	return v, files
+}
+
+// verifyManifest checks the current manifest of v's site and the file
+// record of each of its files, returning the files.
+func (ws *WebServer) verifyManifest(v *siteVerification) map[string]string {
+	data, err := ws.store.GetCurrentWebsiteManifest(v.SiteID)
+	if err != nil {
+		v.problem("manifest unreadable: %v", err)
+		return nil
+	}
+	var m core.WebsiteManifest
+	if err := core.UnmarshalCBOR(data, &m); err != nil {
+		v.problem("manifest undecodable: %v", err)
+		return nil
+	}
+	v.SitePub = hex.EncodeToString(m.SitePub)
+	v.Seq = m.Seq
+	v.ManifestCID = core.CIDForBytes(data)
+	v.Published = time.Unix(m.TS, 0).UTC()
+	v.Signatures.Checked++
+	if err := verify.SiteManifest(&m, v.SiteID); err != nil {
+		v.Signatures.Invalid++
+		v.problem("manifest: %v", err)
+	}
+
+	for path, cid := range m.Files {
+		// The manifest signs the file map; file records add the MIME type.
+		// A record for other content is not published yet.
+		recData, err := ws.store.GetFileRecordByPath(v.SiteID, path)
+		if err != nil {
+			continue // sites synced from peers may lack file records
+		}
+		var rec core.FileRecord
+		if err := core.UnmarshalCBOR(recData, &rec); err == nil && rec.ContentCID != cid {
+			continue
+		}
+		v.Signatures.Checked++
+		if _, err := verify.CheckFileRecord(recData, v.SiteID, path); err != nil {
+			v.Signatures.Invalid++
+			v.problem("file record of %s: %v", path, err)
+		}
+	}
+	return m.Files
+}
+
+// verifyHead checks the head record of v's single-file site, returning its
+// one file.
+func (ws *WebServer) verifyHead(v *siteVerification) map[string]string {
+	seq, headCID, err := ws.store.GetHead(v.SiteID)
+	if err != nil {
+		v.problem("head unreadable: %v", err)
+		return nil
+	}
+	v.Seq, v.HeadCID = seq, headCID
+	data, err := ws.store.GetRecord(headCID)
+	if err != nil {
+		v.problem("head record unreadable: %v", err)
+		return nil
+	}
+	var rec core.UpdateRecord
+	if err := core.UnmarshalCBOR(data, &rec); err != nil {
+		v.problem("head record undecodable: %v", err)
+		return nil
+	}
+	v.SitePub = hex.EncodeToString(rec.SitePub)
+	v.Published = time.Unix(rec.TS, 0).UTC()
+	v.Signatures.Checked++
+	err = verify.UpdateRecord(&rec)
+	if err == nil && core.SiteIDFromPub(rec.SitePub) != v.SiteID {
+		err = errors.New("signed for another site")
+	}
+	if err != nil {
+		v.Signatures.Invalid++
+		v.problem("head record: %v", err)
+	}
+	return map[string]string{"index.html": rec.ContentCID}
+}
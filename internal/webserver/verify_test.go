@@ -0,0 +1,88 @@
+package webserver
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"alxnet/internal/core"
+	"alxnet/internal/publisher"
+	"alxnet/internal/store"
+	"alxnet/internal/verify"
+
+	"go.uber.org/zap"
+)
+
+func TestSiteVerify(t *testing.T) {
+	s, err := store.NewMemory()
+	if err != nil {
+		t.Fatalf("NewMemory: %v", err)
+	}
+	defer s.Close()
+	ws := &WebServer{store: s}
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := publisher.New(s, nil, zap.NewNop())
+	if _, _, err := p.AddFile(context.Background(), priv, "index.html", []byte("<h1>hello</h1>"), "text/html"); err != nil {
+		t.Fatalf("AddFile: %v", err)
+	}
+	style, m, err := p.AddFile(context.Background(), priv, "style.css", []byte("h1 { color: red }"), "text/css")
+	if err != nil {
+		t.Fatalf("AddFile: %v", err)
+	}
+	// A saved but unpublished edit is not a problem
+	if _, err := p.SaveFile(priv, "index.html", []byte("<h1>draft</h1>"), "text/html"); err != nil {
+		t.Fatalf("SaveFile: %v", err)
+	}
+
+	get := func(site string) (int, siteVerification) {
+		rec := httptest.NewRecorder()
+		ws.handleAPISiteVerify(rec, httptest.NewRequest(http.MethodGet, "/api/site/verify/"+site, nil))
+		var v siteVerification
+		json.Unmarshal(rec.Body.Bytes(), &v)
+		return rec.Code, v
+	}
+
+	siteID := core.SiteIDFromPub(pub)
+	code, v := get(siteID)
+	if code != http.StatusOK || !v.Verified || v.SitePub != hex.EncodeToString(pub) || v.Seq != 2 || v.ManifestCID != m.CID ||
+		v.Signatures.Checked != 3 || v.Content.Matched != 2 || len(v.Problems) != 0 {
+		t.Fatalf("verify = %d %+v", code, v)
+	}
+
+	// The root covers the manifest's files and can be checked against one
+	// shared by the publisher
+	if root, _ := verify.ManifestFilesCID(m.Files); v.Root != root || len(v.Files) != 2 {
+		t.Errorf("root = %s, files %v, want %s", v.Root, v.Files, root)
+	}
+	if _, v := get(siteID + "?root=" + v.Root); !v.Verified || v.RootMatches == nil || !*v.RootMatches {
+		t.Errorf("verify with the right root = %+v", v)
+	}
+	if _, v := get(siteID + "?root=" + strings.Repeat("0", 64)); v.Verified || v.RootMatches == nil || *v.RootMatches {
+		t.Errorf("verify with another root = %+v", v)
+	}
+	if code, _ := get(siteID + "?root=xyz"); code != http.StatusBadRequest {
+		t.Errorf("verify with a malformed root = %d", code)
+	}
+
+	// Content that no longer matches its CID fails verification
+	if err := s.PutContent(style.ContentCID, []byte("h1 { color: blue }")); err != nil {
+		t.Fatalf("PutContent: %v", err)
+	}
+	if _, v := get(siteID); v.Verified || len(v.Content.Mismatched) != 1 || v.Content.Mismatched[0] != "style.css" {
+		t.Errorf("verify of tampered content = %+v", v)
+	}
+
+	if code, _ := get("aa00000000000000000000000000000000000000000000000000000000000000"); code != http.StatusNotFound {
+		t.Errorf("verify of an unknown site = %d", code)
+	}
+}
@@ -2,23 +2,27 @@ package webserver
 
 import (
 	"context"
-	"crypto/ed25519"
-	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
 	"alxnet/internal/core"
+	"alxnet/internal/fingerprint"
 	"alxnet/internal/p2p"
+	"alxnet/internal/publisher"
 	"alxnet/internal/store"
 	"alxnet/internal/wallet"
 
-	"github.com/fxamacker/cbor/v2"
+	peer "github.com/libp2p/go-libp2p/core/peer"
+	ma "github.com/multiformats/go-multiaddr"
 	"go.uber.org/zap"
 )
 
@@ -27,39 +31,77 @@ func NewWalletServer(store *store.Store, node *p2p.Node, logger *zap.Logger, por
 	ctx, cancel := context.WithCancel(context.Background())
 
 	ws := &WebServer{
-		store:  store,
-		node:   node,
-		logger: logger,
-		port:   port,
-		ctx:    ctx,
-		cancel: cancel,
+		store:    store,
+		node:     node,
+		logger:   logger,
+		port:     port,
+		ctx:      ctx,
+		cancel:   cancel,
+		tasks:    newTaskRunner(logger),
+		wallets:  newWalletSessions(walletIdleTimeout),
+		writeCap: capPublish,
+		limits:   newClientLimits(),
 	}
 
 	mux := http.NewServeMux()
 
 	// Wallet management endpoints
 	mux.HandleFunc("/", ws.handleWalletHomepage)
-	mux.HandleFunc("/api/wallet/new", ws.handleCreateWallet)
-	mux.HandleFunc("/api/wallet/load", ws.handleLoadWallet)
-	mux.HandleFunc("/api/wallet/load-file", ws.handleLoadWalletFile)
-	mux.HandleFunc("/api/wallet/list", ws.handleListWallets)
-	mux.HandleFunc("/api/wallet/save", ws.handleSaveWallet)
-	mux.HandleFunc("/api/wallet/encrypt", ws.handleEncryptWallet)
-	mux.HandleFunc("/api/wallet/sites", ws.handleWalletSites)
-	mux.HandleFunc("/api/wallet/add-site", ws.handleAddSite)
-	mux.HandleFunc("/api/site/files", ws.handleGetSiteFiles)
-	mux.HandleFunc("/api/site/save-file", ws.handleSaveFileToSite)
-	mux.HandleFunc("/api/site/delete-file", ws.handleDeleteFileFromSite)
-	mux.HandleFunc("/api/wallet/publish", ws.handlePublishContent)
-	mux.HandleFunc("/api/wallet/publish-website", ws.handlePublishWebsite)
-	mux.HandleFunc("/api/wallet/add-file", ws.handleAddWebsiteFile)
-	mux.HandleFunc("/api/wallet/export-key", ws.handleExportKey)
-	mux.HandleFunc("/api/domains/register", ws.handleRegisterDomain)
-	mux.HandleFunc("/api/domains/list", ws.handleListDomains)
-	mux.HandleFunc("/api/domains/list-wallet", ws.handleListWalletDomains)
-	mux.HandleFunc("/api/domains/resolve", ws.handleResolveDomain)
-	mux.HandleFunc("/api/websites/info", ws.handleGetWebsiteInfo)
-	mux.HandleFunc("/api/status", ws.handleWalletStatus)
+	mux.HandleFunc("/_alxnet/ui/", ws.handleUIStatic)
+	mux.HandleFunc(fingerprint.PathPrefix, ws.handleContentByCID) // fingerprinted assets in previews
+	ws.handleAPI(mux, "/api/wallet/new", ws.handleCreateWallet, apiDoc{Methods: "POST", Summary: "Create a wallet"})
+	ws.handleAPI(mux, "/api/wallet/load", ws.handleLoadWallet, apiDoc{Methods: "POST", Summary: "Open a wallet"})
+	ws.handleAPI(mux, "/api/wallet/load-file", ws.handleLoadWalletFile, apiDoc{Methods: "POST", Summary: "Open a wallet file"})
+	ws.handleAPI(mux, "/api/wallet/lock", ws.handleWalletLock, apiDoc{Methods: "POST", Summary: "Lock the wallet session, zeroing its keys"})
+	ws.handleAPI(mux, "/api/wallet/session", ws.handleWalletSession, apiDoc{Methods: "GET", Summary: "Whether the wallet session is unlocked"})
+	ws.handleAPI(mux, "/api/wallet/list", ws.handleListWallets, apiDoc{Methods: "GET", Summary: "Wallet files in the data directory"})
+	ws.handleAPI(mux, "/api/wallet/save", ws.handleSaveWallet, apiDoc{Methods: "POST", Summary: "Save a wallet"})
+	ws.handleAPI(mux, "/api/wallet/encrypt", ws.handleEncryptWallet, apiDoc{Methods: "POST", Summary: "Encrypt a wallet"})
+	ws.handleAPI(mux, "/api/wallet/sites", ws.handleWalletSites, apiDoc{Methods: "POST", Summary: "A wallet's sites"})
+	ws.handleAPI(mux, "/api/wallet/add-site", ws.handleAddSite, apiDoc{Methods: "POST", Summary: "Add a site to a wallet"})
+	ws.handleAPI(mux, "/api/site/files", ws.handleGetSiteFiles, apiDoc{Methods: "POST", Summary: "Files of a wallet site"})
+	ws.handleAPI(mux, "/api/site/save-file", ws.handleSaveFileToSite, apiDoc{Methods: "POST", Summary: "Save a file to a site's drafts"})
+	ws.handleAPI(mux, "/api/site/delete-file", ws.handleDeleteFileFromSite, apiDoc{Methods: "POST", Summary: "Remove a file from a website, or save its removal as a draft"})
+	ws.handleAPI(mux, "/api/site/changes", ws.handleSiteChanges, apiDoc{Methods: "POST", Summary: "Unpublished changes of a site's working copy"})
+	ws.handleAPI(mux, "/api/site/diff", ws.handleSiteDiff, apiDoc{Methods: "POST", Summary: "Diff of a drafted file against its published version"})
+	ws.handleAPI(mux, "/api/site/discard", ws.handleDiscardDraft, apiDoc{Methods: "POST", Summary: "Discard the draft of a file"})
+	ws.handleAPI(mux, "/api/site/contribution", ws.handleAcceptContribution, apiDoc{Methods: "POST", Summary: "Draft a file signed by a granted contributor"})
+	ws.handleAPI(mux, "/api/site/get-file", ws.handleGetFile, apiDoc{Methods: "POST", Summary: "Read a file of a wallet site"})
+	ws.handleAPI(mux, "/api/site/upload", ws.handleUploadFiles, apiDoc{Methods: "POST", Summary: "Upload a website folder or zip archive", Body: "multipart/form-data"})
+	ws.handleAPI(mux, "/api/site/preview/", ws.handleSitePreview, apiDoc{Methods: "GET", Summary: "Preview a site's working copy", Path: "/site/preview/{site}/{path}", Produces: "text/html"})
+	ws.handleAPI(mux, "/api/wallet/publish", ws.handlePublishContent, apiDoc{Methods: "POST", Summary: "Publish single-file content"})
+	ws.handleAPI(mux, "/api/wallet/publish-website", ws.handlePublishWebsite, apiDoc{Methods: "POST", Summary: "Publish a website"})
+	ws.handleAPI(mux, "/api/wallet/add-file", ws.handleAddWebsiteFile, apiDoc{Methods: "POST", Summary: "Add or replace a file of a published website"})
+	ws.handleAPI(mux, "/api/wallet/delete", ws.handleWalletDelete, apiDoc{Methods: "POST", Summary: "Apply a delete record signed elsewhere"})
+	ws.handleAPI(mux, "/api/wallet/export-key", ws.handleExportKey, apiDoc{Methods: "POST", Summary: "Export a site key in plaintext (deprecated)"})
+	ws.handleAPI(mux, "/api/wallet/export-keystore", ws.handleExportKeystore, apiDoc{Methods: "POST", Summary: "Export a site key as an encrypted keystore"})
+	ws.handleAPI(mux, "/api/wallet/import-keystore", ws.handleImportKeystore, apiDoc{Methods: "POST", Summary: "Import a keystore into a wallet"})
+	ws.handleAPI(mux, "/api/wallet/site-stats", ws.handleSiteStats, apiDoc{Methods: "GET", Summary: "Replication telemetry of sites", Query: []string{"site_id"}})
+	ws.handleAPI(mux, "/api/wallet/hosting/request", ws.handleHostingRequest, apiDoc{Methods: "POST", Summary: "Ask a node to host a site"})
+	ws.handleAPI(mux, "/api/wallet/hosting/list", ws.handleHostingList, apiDoc{Methods: "GET", Summary: "Hosting requests sent from this node"})
+	ws.handleAPI(mux, "/api/wallet/comment", ws.handleWalletComment, apiDoc{Methods: "POST", Summary: "Sign and publish a comment"})
+	ws.handleAPI(mux, "/api/wallet/comments", ws.handleWalletComments, apiDoc{Methods: "GET", Summary: "A site's comments including hidden ones", Query: []string{"site_id"}})
+	ws.handleAPI(mux, "/api/wallet/moderate", ws.handleWalletModerate, apiDoc{Methods: "POST", Summary: "Hide or show a comment"})
+	ws.handleAPI(mux, "/api/wallet/pointer", ws.handleWalletPointer, apiDoc{Methods: "POST", Summary: "Set and publish a pointer"})
+	ws.handleAPI(mux, "/api/wallet/gateway/authorize", ws.handleWalletGatewayAuth, apiDoc{Methods: "POST", Summary: "Sign a gateway authorization"})
+	ws.handleAPI(mux, "/api/wallet/contributor-key", ws.handleContributorKey, apiDoc{Methods: "POST", Summary: "The wallet's contributor key"})
+	ws.handleAPI(mux, "/api/wallet/grant", ws.handleWalletGrant, apiDoc{Methods: "POST", Summary: "Grant a contributor key paths of a site"})
+	ws.handleAPI(mux, "/api/wallet/contribute", ws.handleWalletContribute, apiDoc{Methods: "POST", Summary: "Sign a file for a site under a contributor grant"})
+	ws.handleAPI(mux, "/api/pointers/", ws.handleAPIPointers, apiDoc{Methods: "GET", Summary: "An owner's pointers", Path: "/pointers/{owner}"})
+	ws.handleAPI(mux, "/api/wallet/appdata", ws.handleWalletAppData, apiDoc{Methods: "POST", Summary: "Set or delete a site's app data key"})
+	ws.handleAPI(mux, "/api/appdata/", ws.handleAPIAppData, apiDoc{Methods: "GET", Summary: "A site's app data as JSON", Path: "/appdata/{site}"})
+	ws.handleAPI(mux, "/api/domains/register", ws.handleRegisterDomain, apiDoc{Methods: "POST", Summary: "Register a site name"})
+	ws.handleAPI(mux, "/api/domains/list", ws.handleListDomains, apiDoc{Methods: "GET", Summary: "Registered site names"})
+	ws.handleAPI(mux, "/api/domains/list-wallet", ws.handleListWalletDomains, apiDoc{Methods: "POST", Summary: "Site names registered to a wallet's sites"})
+	ws.handleAPI(mux, "/api/domains/resolve", ws.handleResolveDomain, apiDoc{Methods: "GET", Summary: "Resolve a site name", Query: []string{"domain"}})
+	ws.handleAPI(mux, "/api/domains/offers", ws.handleDomainOffers, apiDoc{Methods: "POST", Summary: "Open name offers to and from sites"})
+	ws.handleAPI(mux, "/api/wallet/domains/offer", ws.handleWalletDomainOffer, apiDoc{Methods: "POST", Summary: "Offer a site name to another site"})
+	ws.handleAPI(mux, "/api/wallet/domains/accept", ws.handleWalletDomainAccept, apiDoc{Methods: "POST", Summary: "Accept a site name offer"})
+	ws.handleAPI(mux, "/api/websites/info", ws.handleGetWebsiteInfo, apiDoc{Methods: "POST", Summary: "Information about a wallet's website"})
+	ws.handleAPI(mux, "/api/status", ws.handleWalletStatus, apiDoc{Methods: "GET", Summary: "Wallet server status"})
+	ws.handleAPI(mux, "/api/tasks", ws.handleTasks, apiDoc{Methods: "GET", Summary: "Background tasks with their progress, newest first", Query: []string{"id"}})
+	ws.handleAPI(mux, "/api/tasks/cancel", ws.handleTaskCancel, apiDoc{Methods: "POST", Summary: "Cancel a background task"})
+	ws.registerOpenAPI(mux)
 
 	ws.server = &http.Server{
 		Addr:         fmt.Sprintf(":%d", port),
@@ -74,1058 +116,7 @@ func NewWalletServer(store *store.Store, node *p2p.Node, logger *zap.Logger, por
 
 // handleWalletHomepage serves the enhanced wallet management interface
 func (ws *WebServer) handleWalletHomepage(w http.ResponseWriter, r *http.Request) {
-	homepage := `<!DOCTYPE html>
-<html lang="en">
-<head>
-    <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>AlxNet Wallet Management</title>
-    <style>
-        * { margin: 0; padding: 0; box-sizing: border-box; }
-        body { 
-            font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif;
-            background: linear-gradient(135deg, #667eea 0%, #764ba2 100%);
-            min-height: 100vh;
-            color: white;
-        }
-        .container { max-width: 1400px; margin: 0 auto; padding: 2rem; }
-        .header { text-align: center; margin-bottom: 2rem; }
-        .header h1 { font-size: 2.5rem; margin-bottom: 0.5rem; }
-        .header p { font-size: 1.1rem; opacity: 0.9; }
-        
-        /* Navigation */
-        .nav { 
-            display: flex; 
-            gap: 1rem; 
-            margin-bottom: 2rem; 
-            justify-content: center;
-        }
-        .nav button {
-            padding: 0.8rem 1.5rem;
-            background: rgba(255,255,255,0.2);
-            color: white;
-            border: none;
-            border-radius: 5px;
-            cursor: pointer;
-            font-size: 1rem;
-            transition: background 0.3s;
-        }
-        .nav button:hover { background: rgba(255,255,255,0.3); }
-        .nav button.active { background: #4c51bf; }
-        
-        /* Screen containers */
-        .screen {
-            background: rgba(255,255,255,0.1);
-            padding: 2rem;
-            border-radius: 10px;
-            backdrop-filter: blur(10px);
-            display: none;
-        }
-        .screen.active { display: block; }
-        
-        /* Form styles */
-        .form-group { margin-bottom: 1.5rem; }
-        .form-group label { 
-            display: block; 
-            margin-bottom: 0.5rem; 
-            font-weight: bold; 
-            font-size: 0.95rem;
-        }
-        .form-group input, .form-group textarea, .form-group select {
-            width: 100%;
-            padding: 0.8rem;
-            border: none;
-            border-radius: 5px;
-            font-size: 1rem;
-            background: rgba(255,255,255,0.9);
-            color: #333;
-        }
-        .form-group button {
-            padding: 1rem 2rem;
-            background: #4c51bf;
-            color: white;
-            border: none;
-            border-radius: 5px;
-            cursor: pointer;
-            font-size: 1rem;
-            margin-right: 1rem;
-            margin-bottom: 0.5rem;
-            transition: background 0.3s;
-        }
-        .form-group button:hover { background: #3730a3; }
-        .form-group button.secondary {
-            background: #6b7280;
-        }
-        .form-group button.secondary:hover { background: #4b5563; }
-        
-        /* Grid layouts */
-        .grid-2 { display: grid; grid-template-columns: 1fr 1fr; gap: 2rem; }
-        .grid-3 { display: grid; grid-template-columns: 300px 1fr; gap: 2rem; }
-        
-        /* Status and results */
-        .status {
-            background: rgba(0,0,0,0.3);
-            padding: 1rem;
-            border-radius: 5px;
-            margin-top: 1rem;
-            border-left: 4px solid #3b82f6;
-        }
-        .status.success { border-left-color: #10b981; }
-        .status.error { border-left-color: #ef4444; }
-        .status.warning { border-left-color: #f59e0b; }
-        
-        /* Lists */
-        .list { 
-            background: rgba(0,0,0,0.2); 
-            border-radius: 5px; 
-            max-height: 300px;
-            overflow-y: auto;
-        }
-        .list-item {
-            padding: 1rem;
-            border-bottom: 1px solid rgba(255,255,255,0.1);
-            cursor: pointer;
-            transition: background 0.3s;
-        }
-        .list-item:hover { background: rgba(255,255,255,0.1); }
-        .list-item:last-child { border-bottom: none; }
-        .list-item.selected { background: rgba(79, 70, 229, 0.3); }
-        
-        /* File editor */
-        .editor-container {
-            background: rgba(0,0,0,0.3);
-            border-radius: 5px;
-            height: 400px;
-            display: flex;
-            flex-direction: column;
-        }
-        .editor-toolbar {
-            background: rgba(0,0,0,0.2);
-            padding: 0.5rem;
-            display: flex;
-            gap: 0.5rem;
-            align-items: center;
-        }
-        .editor-toolbar input {
-            background: rgba(255,255,255,0.9);
-            border: none;
-            padding: 0.4rem;
-            border-radius: 3px;
-            font-size: 0.9rem;
-        }
-        .editor-toolbar button {
-            padding: 0.4rem 0.8rem;
-            background: #4c51bf;
-            color: white;
-            border: none;
-            border-radius: 3px;
-            cursor: pointer;
-            font-size: 0.9rem;
-        }
-        .editor-content {
-            flex: 1;
-        }
-        .editor-content textarea {
-            width: 100%;
-            height: 100%;
-            background: rgba(0,0,0,0.5);
-            color: white;
-            border: none;
-            padding: 1rem;
-            font-family: 'Courier New', monospace;
-            font-size: 0.9rem;
-            resize: none;
-        }
-        
-        /* File tree */
-        .file-tree {
-            background: rgba(0,0,0,0.3);
-            border-radius: 5px;
-            padding: 1rem;
-            height: 400px;
-            overflow-y: auto;
-        }
-        .file-item {
-            padding: 0.5rem;
-            cursor: pointer;
-            border-radius: 3px;
-            margin-bottom: 2px;
-            font-size: 0.9rem;
-        }
-        .file-item:hover { background: rgba(255,255,255,0.1); }
-        .file-item.selected { background: rgba(79, 70, 229, 0.3); }
-        .file-item.directory { font-weight: bold; }
-        
-        /* Utility classes */
-        .hidden { display: none !important; }
-        .text-center { text-align: center; }
-        .mb-2 { margin-bottom: 1rem; }
-        .mt-2 { margin-top: 1rem; }
-        
-        /* Loading spinner */
-        .loading {
-            display: inline-block;
-            width: 20px;
-            height: 20px;
-            border: 2px solid rgba(255,255,255,0.3);
-            border-radius: 50%;
-            border-top-color: white;
-            animation: spin 1s linear infinite;
-        }
-        @keyframes spin {
-            to { transform: rotate(360deg); }
-        }
-        
-        /* Responsive */
-        @media (max-width: 768px) {
-            .grid-2, .grid-3 { grid-template-columns: 1fr; }
-            .nav { flex-wrap: wrap; }
-        }
-    </style>
-</head>
-<body>
-    <div class="container">
-        <div class="header">
-            <h1>💰 AlxNet Wallet</h1>
-            <p>Manage wallets, sites, and decentralized content</p>
-        </div>
-        
-        <!-- Navigation -->
-        <div class="nav">
-            <button id="nav-wallet" class="active" onclick="showScreen('wallet')">Wallet</button>
-            <button id="nav-sites" onclick="showScreen('sites')" disabled>Sites</button>
-            <button id="nav-domains" onclick="showScreen('domains')" disabled>Site Names</button>
-            <button id="nav-editor" onclick="showScreen('editor')" disabled>Editor</button>
-        </div>
-        
-        <!-- Current Status -->
-        <div id="current-status" class="status hidden">
-            <strong>Current:</strong> <span id="status-text">No wallet selected</span>
-        </div>
-        
-        <!-- Wallet Selection Screen -->
-        <div id="screen-wallet" class="screen active">
-            <h2>Wallet Management</h2>
-            <div class="grid-2">
-                <div>
-                    <h3>Create New Wallet</h3>
-                    <div class="form-group">
-                        <button onclick="createWallet()">Create New Wallet</button>
-                    </div>
-                    <div id="wallet-result" class="status hidden"></div>
-                </div>
-                
-                <div>
-                    <h3>Load Existing Wallet</h3>
-                    <div class="form-group">
-                        <label>Saved Wallets:</label>
-                        <select id="saved-wallets">
-                            <option value="">Loading saved wallets...</option>
-                        </select>
-                        <button onclick="loadSavedWallet()" style="margin-left: 0.5rem;">Load Selected</button>
-                    </div>
-                    <div class="form-group">
-                        <label>Or Upload Wallet File (JSON):</label>
-                        <input type="file" id="walletFile" accept=".json">
-                    </div>
-                    <div class="form-group">
-                        <button onclick="loadWallet()">Load Wallet File</button>
-                    </div>
-                </div>
-            </div>
-        </div>
-        
-        <!-- Site Management Screen -->
-        <div id="screen-sites" class="screen">
-            <h2>Site Management</h2>
-            <div class="grid-2">
-                <div>
-                    <h3>Your Sites</h3>
-                    <div id="sites-list" class="list">
-                        <div class="text-center" style="padding: 2rem;">
-                            <p>No sites found. Create your first site!</p>
-                        </div>
-                    </div>
-                </div>
-                
-                <div>
-                    <h3>Site Actions</h3>
-                    <div class="form-group">
-                        <label>Site Label:</label>
-                        <input type="text" id="new-site-label" placeholder="my-awesome-site">
-                    </div>
-                    <div class="form-group">
-                        <button onclick="createSite()">Create New Site</button>
-                    </div>
-                    <div id="site-result" class="status hidden"></div>
-                </div>
-            </div>
-        </div>
-        
-        <!-- Site Names Management Screen -->
-        <div id="screen-domains" class="screen">
-            <h2>Site Names Management</h2>
-            <div class="grid-2">
-                <div>
-                    <h3>Your Site Names</h3>
-                    <div id="domains-list" class="list">
-                        <div class="text-center" style="padding: 2rem;">
-                            <p>No site names registered yet.</p>
-                        </div>
-                    </div>
-                </div>
-                
-                <div>
-                    <h3>Register New Site Name</h3>
-                    <div class="form-group">
-                        <label>Site Name:</label>
-                        <input type="text" id="new-domain-name" placeholder="mysite" 
-                               style="text-transform: lowercase;" 
-                               onkeypress="return validateDomainInput(event)"
-                               oninput="this.value = this.value.toLowerCase(); validateDomainName()">
-                        <small style="opacity: 0.8; font-size: 0.85rem;">
-                            3-32 characters, letters/numbers only, can include _ and -, cannot start/end with _ or -
-                        </small>
-                    </div>
-                    <div class="form-group">
-                        <label>Select Site:</label>
-                        <select id="domain-site-select">
-                            <option value="">Choose a site...</option>
-                        </select>
-                    </div>
-                    <div class="form-group">
-                        <button onclick="registerDomain()" id="register-domain-btn" disabled>Register Site Name</button>
-                    </div>
-                    <div id="domain-result" class="status hidden"></div>
-                    
-                    <div style="margin-top: 2rem; padding-top: 1rem; border-top: 1px solid rgba(255,255,255,0.2);">
-                        <h4>How to Use Site Names</h4>
-                        <p style="font-size: 0.9rem; opacity: 0.9; line-height: 1.5;">
-                            Once registered, your site name can be used instead of the site ID:<br>
-                            • Access directly: <code>http://localhost:8080/yourname</code><br>
-                            • API resolve: <code>/api/domains/resolve?domain=yourname</code><br>
-                            • Site names are unique and cannot be changed once registered.
-                        </p>
-                    </div>
-                </div>
-            </div>
-        </div>
-        
-        <!-- File Editor Screen -->
-        <div id="screen-editor" class="screen">
-            <h2>Website Editor</h2>
-            <div class="grid-3">
-                <div>
-                    <h3>File Tree</h3>
-                    <div class="file-tree" id="file-tree">
-                        <div class="text-center" style="padding: 2rem;">
-                            <p>No files yet</p>
-                        </div>
-                    </div>
-                    <div class="form-group mt-2">
-                        <button onclick="addFile()" style="font-size: 0.9rem;">Add File</button>
-                        <button onclick="publishSite()" style="font-size: 0.9rem;">Publish Site</button>
-                    </div>
-                </div>
-                
-                <div>
-                    <h3>File Editor</h3>
-                    <div class="editor-container">
-                        <div class="editor-toolbar">
-                            <input type="text" id="current-file-path" placeholder="No file selected" readonly>
-                            <button onclick="saveFile()">Save</button>
-                            <button onclick="deleteFile()">Delete</button>
-                        </div>
-                        <div class="editor-content">
-                            <textarea id="file-editor" placeholder="Select a file to edit or create a new one..."></textarea>
-                        </div>
-                    </div>
-                    <div id="editor-result" class="status hidden"></div>
-                </div>
-            </div>
-        </div>
-    </div>
-    
-    <script>
-        // Global state
-        let currentWallet = null;
-        let currentMnemonic = null;
-        let currentWalletName = null;
-        let currentSite = null;
-        let siteFiles = {};
-        let selectedFile = null;
-        
-        // Initialize
-        document.addEventListener('DOMContentLoaded', function() {
-            updateStatus();
-            loadWalletList();
-        });
-        
-        // Navigation
-        function showScreen(screenName) {
-            // Hide all screens
-            document.querySelectorAll('.screen').forEach(s => s.classList.remove('active'));
-            document.querySelectorAll('.nav button').forEach(b => b.classList.remove('active'));
-            
-            // Show selected screen
-            document.getElementById('screen-' + screenName).classList.add('active');
-            document.getElementById('nav-' + screenName).classList.add('active');
-            
-            // Load screen data
-            if (screenName === 'sites' && currentWallet) {
-                loadSites();
-            } else if (screenName === 'domains' && currentWallet) {
-                loadDomains();
-                loadSitesForDomains();
-            } else if (screenName === 'editor' && currentSite) {
-                loadSiteFiles();
-            }
-        }
-        
-        // Status management
-        function updateStatus() {
-            const statusEl = document.getElementById('current-status');
-            const statusText = document.getElementById('status-text');
-            
-            let text = 'No wallet selected';
-            if (currentWallet && currentSite) {
-                text = 'Wallet loaded, Site: ' + currentSite.label;
-            } else if (currentWallet) {
-                text = 'Wallet loaded, no site selected';
-            }
-            
-            statusText.textContent = text;
-            statusEl.classList.remove('hidden');
-            
-            // Enable/disable navigation
-            document.getElementById('nav-sites').disabled = !currentWallet;
-            document.getElementById('nav-domains').disabled = !currentSite;
-            document.getElementById('nav-editor').disabled = !currentSite;
-        }
-        
-        // Utility functions
-        function showResult(elementId, content, type = 'success') {
-            const element = document.getElementById(elementId);
-            // Convert newlines to HTML breaks for proper display
-            element.innerHTML = content.replace(/\n/g, '<br>');
-            element.className = 'status ' + type;
-            element.classList.remove('hidden');
-        }
-        
-        async function apiCall(endpoint, method = 'GET', data = null) {
-            try {
-                const options = {
-                    method,
-                    headers: { 'Content-Type': 'application/json' }
-                };
-                if (data) {
-                    options.body = JSON.stringify(data);
-                }
-                
-                const response = await fetch(endpoint, options);
-                const result = await response.json();
-                
-                if (!response.ok) {
-                    throw new Error(result.error || 'API call failed');
-                }
-                
-                return result;
-            } catch (error) {
-                throw new Error('Network error: ' + error.message);
-            }
-        }
-        
-        // Helper function to save wallet to file after updates
-        async function saveWalletToFile() {
-            if (!currentWallet || !currentMnemonic || !currentWalletName) {
-                console.warn('Cannot save wallet: missing wallet data or name');
-                return;
-            }
-            
-            try {
-                // Re-encrypt the wallet with the current mnemonic
-                const encryptedWallet = await apiCall('/api/wallet/encrypt', 'POST', {
-                    wallet_data: currentWallet,
-                    mnemonic: currentMnemonic
-                });
-                
-                // Save the encrypted wallet to file
-                await apiCall('/api/wallet/save', 'POST', {
-                    wallet_data: encryptedWallet.encrypted_wallet,
-                    name: currentWalletName
-                });
-                
-                console.log('Wallet saved successfully');
-            } catch (error) {
-                console.error('Failed to save wallet:', error.message);
-                // Don't throw - this is a background operation
-            }
-        }
-        
-        // Wallet functions
-        async function createWallet() {
-            try {
-                const result = await apiCall('/api/wallet/new', 'POST');
-                currentWallet = result.wallet;
-                currentMnemonic = result.mnemonic;
-                
-                showResult('wallet-result', 
-                    'Wallet created successfully!\n\n' +
-                    'IMPORTANT: Save this mnemonic phrase safely:\n' +
-                    result.mnemonic + '\n\n' +
-                    'Wallet automatically saved to: ' + result.saved_path
-                );
-                
-                updateStatus();
-                
-                // Refresh wallet list to show the new wallet
-                loadWalletList();
-                
-            } catch (error) {
-                showResult('wallet-result', 'Error: ' + error.message, 'error');
-            }
-        }
-        
-        async function loadWallet() {
-            const fileInput = document.getElementById('walletFile');
-            
-            if (!fileInput.files[0]) {
-                showResult('wallet-result', 'Please select a wallet file', 'error');
-                return;
-            }
-            
-            const mnemonic = prompt('Enter your mnemonic phrase:');
-            if (!mnemonic) {
-                showResult('wallet-result', 'Mnemonic phrase is required', 'error');
-                return;
-            }
-            
-            try {
-                const walletData = await fileInput.files[0].text();
-                const result = await apiCall('/api/wallet/load', 'POST', {
-                    wallet_data: walletData,
-                    mnemonic: mnemonic
-                });
-                
-                currentWallet = result.wallet;
-                currentMnemonic = mnemonic;
-                
-                showResult('wallet-result', 
-                    'Wallet loaded successfully!\nSites found: ' + result.sites.length
-                );
-                
-                updateStatus();
-                
-            } catch (error) {
-                showResult('wallet-result', 'Error: ' + error.message, 'error');
-            }
-        }
-        
-        // Load wallet list from saved files
-        async function loadWalletList() {
-            try {
-                const result = await apiCall('/api/wallet/list');
-                const walletSelect = document.getElementById('saved-wallets');
-                
-                if (result.wallets.length === 0) {
-                    walletSelect.innerHTML = '<option value="">No saved wallets found</option>';
-                } else {
-                    walletSelect.innerHTML = '<option value="">Select a saved wallet...</option>' +
-                        result.wallets.map(wallet => 
-                            '<option value="' + wallet.filename + '">' + wallet.name + ' (' + wallet.created + ')</option>'
-                        ).join('');
-                }
-            } catch (error) {
-                console.error('Failed to load wallet list:', error);
-            }
-        }
-        
-        // Load wallet from saved file
-        async function loadSavedWallet() {
-            const walletSelect = document.getElementById('saved-wallets');
-            
-            if (!walletSelect.value) {
-                showResult('wallet-result', 'Please select a wallet', 'error');
-                return;
-            }
-            
-            const mnemonic = prompt('Enter your mnemonic phrase:');
-            if (!mnemonic) {
-                showResult('wallet-result', 'Mnemonic phrase is required', 'error');
-                return;
-            }
-            
-            try {
-                const result = await apiCall('/api/wallet/load-file', 'POST', {
-                    filename: walletSelect.value,
-                    mnemonic: mnemonic
-                });
-                
-                currentWallet = result.wallet;
-                currentMnemonic = mnemonic;
-                // Extract wallet name from filename (remove .wallet extension)
-                currentWalletName = walletSelect.value.replace('.wallet', '');
-                
-                showResult('wallet-result', 
-                    'Wallet loaded successfully from saved file!\n' +
-                    'Sites found: ' + Object.keys(result.wallet.sites || {}).length
-                );
-                
-                updateStatus();
-                
-            } catch (error) {
-                showResult('wallet-result', 'Error: ' + error.message, 'error');
-            }
-        }
-        
-        // Site functions
-        async function loadSites() {
-            if (!currentWallet || !currentMnemonic) return;
-            
-            try {
-                const result = await apiCall('/api/wallet/sites', 'POST', {
-                    wallet_data: JSON.stringify(currentWallet),
-                    mnemonic: currentMnemonic
-                });
-                
-                const sitesList = document.getElementById('sites-list');
-                if (result.sites.length === 0) {
-                    sitesList.innerHTML = '<div class="text-center" style="padding: 2rem;"><p>No sites found. Create your first site!</p></div>';
-                } else {
-                    sitesList.innerHTML = result.sites.map(site => 
-                        '<div class="list-item" onclick="selectSite(\'' + site.label + '\')" data-label="' + site.label + '">' +
-                            '<strong>' + site.label + '</strong><br>' +
-                            '<small>ID: ' + site.site_id + '</small><br>' +
-                            '<small>Updated: ' + new Date(site.last_updated).toLocaleString() + '</small>' +
-                        '</div>'
-                    ).join('');
-                }
-            } catch (error) {
-                showResult('site-result', 'Error loading sites: ' + error.message, 'error');
-            }
-        }
-        
-        function selectSite(label) {
-            if (!label) {
-                const selected = document.querySelector('#sites-list .list-item.selected');
-                if (!selected) {
-                    showResult('site-result', 'Please select a site first', 'error');
-                    return;
-                }
-                label = selected.dataset.label;
-            }
-            
-            // Remove previous selection and highlight current site
-            document.querySelectorAll('#sites-list .list-item').forEach(item => {
-                item.classList.remove('selected');
-            });
-            document.querySelector('#sites-list .list-item[data-label="' + label + '"]').classList.add('selected');
-            
-            currentSite = currentWallet.sites[label];
-            if (!currentSite) {
-                showResult('site-result', 'Site not found', 'error');
-                return;
-            }
-            
-            currentSite.label = label; // Store label for reference
-            updateStatus();
-            showResult('site-result', 'Site "' + label + '" selected. You can now edit files.');
-        }
-        
-        async function createSite() {
-            const label = document.getElementById('new-site-label').value.trim();
-            if (!label) {
-                showResult('site-result', 'Please enter a site label', 'error');
-                return;
-            }
-            
-            if (!currentWallet || !currentMnemonic) {
-                showResult('site-result', 'Please load a wallet first', 'error');
-                return;
-            }
-            
-            try {
-                const result = await apiCall('/api/wallet/add-site', 'POST', {
-                    wallet_data: JSON.stringify(currentWallet),
-                    mnemonic: currentMnemonic,
-                    label: label
-                });
-                
-                currentWallet = result.wallet;
-                
-                // Save the updated wallet to file
-                await saveWalletToFile();
-                
-                showResult('site-result', 'Site "' + label + '" created successfully!');
-                document.getElementById('new-site-label').value = '';
-                loadSites();
-                
-            } catch (error) {
-                showResult('site-result', 'Error: ' + error.message, 'error');
-            }
-        }
-        
-        // File editor functions
-        async function loadSiteFiles() {
-            if (!currentSite || !currentWallet || !currentMnemonic) return;
-            
-            try {
-                const result = await apiCall('/api/site/files', 'POST', {
-                    wallet_data: JSON.stringify(currentWallet),
-                    mnemonic: currentMnemonic,
-                    site_label: currentSite.label
-                });
-                
-                siteFiles = result.files || {};
-                updateFileTree();
-                
-            } catch (error) {
-                showResult('editor-result', 'Error loading files: ' + error.message, 'error');
-            }
-        }
-        
-        function updateFileTree() {
-            const fileTree = document.getElementById('file-tree');
-            
-            if (Object.keys(siteFiles).length === 0) {
-                fileTree.innerHTML = '<div class="text-center" style="padding: 2rem;"><p>No files yet.<br>Click "Add File" to create your first file.</p></div>';
-                return;
-            }
-            
-            const fileItems = Object.keys(siteFiles).map(path => 
-                '<div class="file-item" onclick="selectFile(\'' + path + '\')" data-path="' + path + '">' +
-                    '📄 ' + path +
-                '</div>'
-            );
-            
-            fileTree.innerHTML = fileItems.join('');
-        }
-        
-        function selectFile(path) {
-            selectedFile = path;
-            
-            // Update UI
-            document.querySelectorAll('.file-item').forEach(item => {
-                item.classList.remove('selected');
-            });
-            document.querySelector('[data-path="' + path + '"]').classList.add('selected');
-            
-            // Load file content (placeholder for now)
-            document.getElementById('current-file-path').value = path;
-            document.getElementById('file-editor').value = '// File content would be loaded here\\n// Placeholder content for: ' + path;
-        }
-        
-        function addFile() {
-            const fileName = prompt('Enter file name (e.g., index.html, style.css):');
-            if (!fileName) return;
-            
-            // Validate file name
-            if (siteFiles[fileName]) {
-                alert('File already exists!');
-                return;
-            }
-            
-            // Add to file list
-            siteFiles[fileName] = {
-                path: fileName,
-                content_cid: 'new',
-                mime_type: getMimeType(fileName),
-                size: 0,
-                last_updated: new Date()
-            };
-            
-            updateFileTree();
-            selectFile(fileName);
-            showResult('editor-result', 'File "' + fileName + '" added. Edit and save to persist.');
-        }
-        
-        function getMimeType(fileName) {
-            const ext = fileName.split('.').pop().toLowerCase();
-            const mimeTypes = {
-                'html': 'text/html',
-                'css': 'text/css', 
-                'js': 'application/javascript',
-                'json': 'application/json',
-                'txt': 'text/plain',
-                'md': 'text/markdown',
-                'png': 'image/png',
-                'jpg': 'image/jpeg',
-                'jpeg': 'image/jpeg',
-                'gif': 'image/gif',
-                'svg': 'image/svg+xml'
-            };
-            return mimeTypes[ext] || 'text/plain';
-        }
-        
-        async function saveFile() {
-            if (!selectedFile) {
-                showResult('editor-result', 'No file selected', 'error');
-                return;
-            }
-            
-            const content = document.getElementById('file-editor').value;
-            
-            try {
-                const result = await apiCall('/api/site/save-file', 'POST', {
-                    wallet_data: JSON.stringify(currentWallet),
-                    mnemonic: currentMnemonic,
-                    site_label: currentSite.label,
-                    file_path: selectedFile,
-                    content: content,
-                    mime_type: getMimeType(selectedFile)
-                });
-                
-                showResult('editor-result', 'File "' + selectedFile + '" saved successfully!');
-                
-            } catch (error) {
-                showResult('editor-result', 'Error saving file: ' + error.message, 'error');
-            }
-        }
-        
-        async function deleteFile() {
-            if (!selectedFile) {
-                showResult('editor-result', 'No file selected', 'error');
-                return;
-            }
-            
-            if (!confirm('Delete file "' + selectedFile + '"?')) return;
-            
-            try {
-                await apiCall('/api/site/delete-file', 'POST', {
-                    wallet_data: JSON.stringify(currentWallet),
-                    mnemonic: currentMnemonic,
-                    site_label: currentSite.label,
-                    file_path: selectedFile
-                });
-                
-                delete siteFiles[selectedFile];
-                updateFileTree();
-                document.getElementById('current-file-path').value = '';
-                document.getElementById('file-editor').value = '';
-                selectedFile = null;
-                
-                showResult('editor-result', 'File deleted successfully!');
-                
-            } catch (error) {
-                showResult('editor-result', 'Error deleting file: ' + error.message, 'error');
-            }
-        }
-        
-        async function publishSite() {
-            if (!currentSite || Object.keys(siteFiles).length === 0) {
-                showResult('editor-result', 'No files to publish', 'error');
-                return;
-            }
-            
-            try {
-                const result = await apiCall('/api/wallet/publish-website', 'POST', {
-                    wallet_data: JSON.stringify(currentWallet),
-                    mnemonic: currentMnemonic,
-                    site_label: currentSite.label
-                });
-                
-                showResult('editor-result', 
-                    'Site "' + currentSite.label + '" published successfully!\\n' +
-                    'Site ID: ' + result.site_id + '\\n' +
-                    'Files: ' + result.files + '\\n' +
-                    'Manifest CID: ' + result.manifest_cid + '\\n' +
-                    'Your site is now available on the AlxNet network!'
-                );
-                
-            } catch (error) {
-                showResult('editor-result', 'Error publishing site: ' + error.message, 'error');
-            }
-        }
-
-        // Domain Management Functions
-        function validateDomainInput(event) {
-            const char = event.key;
-            const input = event.target;
-            const value = input.value + char;
-            
-            // Allow backspace, delete, etc
-            if (event.keyCode < 32) return true;
-            
-            // Only allow letters, numbers, underscore, dash
-            if (!/[a-z0-9_-]/.test(char)) {
-                event.preventDefault();
-                return false;
-            }
-            
-            // First character must be letter or number
-            if (input.value.length === 0 && !/[a-z0-9]/.test(char)) {
-                event.preventDefault();
-                return false;
-            }
-            
-            return true;
-        }
-
-        function validateDomainName() {
-            const input = document.getElementById('new-domain-name');
-            const button = document.getElementById('register-domain-btn');
-            const value = input.value;
-            
-            let isValid = false;
-            
-            if (value.length >= 3 && value.length <= 32) {
-                // Check format
-                if (/^[a-z0-9][a-z0-9_-]*[a-z0-9]$|^[a-z0-9]$/.test(value)) {
-                    // Check no consecutive special chars
-                    if (!/[_-]{2,}/.test(value)) {
-                        isValid = true;
-                    }
-                }
-            }
-            
-            button.disabled = !isValid || !document.getElementById('domain-site-select').value;
-            
-            if (value.length > 0 && !isValid) {
-                input.style.borderColor = '#ff6b6b';
-            } else {
-                input.style.borderColor = '';
-            }
-        }
-
-        async function loadDomains() {
-            try {
-                if (!currentWallet || !currentWallet.sites) {
-                    const domainsList = document.getElementById('domains-list');
-                    domainsList.innerHTML = '<div class="text-center" style="padding: 2rem;"><p>No wallet loaded.</p></div>';
-                    return;
-                }
-
-                // Get all site IDs from the current wallet
-                const siteIds = Object.values(currentWallet.sites).map(site => site.site_id);
-                
-                const response = await fetch('/api/domains/list-wallet', {
-                    method: 'POST',
-                    headers: { 'Content-Type': 'application/json' },
-                    body: JSON.stringify({
-                        site_ids: siteIds
-                    })
-                });
-                
-                const data = await response.json();
-                
-                const domainsList = document.getElementById('domains-list');
-                
-                if (data.success && data.count > 0) {
-                    domainsList.innerHTML = Object.entries(data.domains).map(([domain, siteId]) => 
-                        '<div class="list-item">' +
-                            '<div><strong>' + domain + '</strong></div>' +
-                            '<div style="font-size: 0.85rem; opacity: 0.8;">Site: ' + siteId.substring(0, 16) + '...</div>' +
-                        '</div>'
-                    ).join('');
-                } else {
-                    domainsList.innerHTML = '<div class="text-center" style="padding: 2rem;"><p>No site names registered yet.</p></div>';
-                }
-            } catch (error) {
-                showResult('domain-result', 'Error loading domains: ' + error.message, 'error');
-            }
-        }
-
-        async function loadSitesForDomains() {
-            const select = document.getElementById('domain-site-select');
-            select.innerHTML = '<option value="">Choose a site...</option>';
-            
-            if (!currentWallet) return;
-            
-            try {
-                const response = await fetch('/api/wallet/sites', {
-                    method: 'POST',
-                    headers: { 'Content-Type': 'application/json' },
-                    body: JSON.stringify({
-                        wallet_data: JSON.stringify(currentWallet),
-                        mnemonic: currentMnemonic
-                    })
-                });
-                
-                const data = await response.json();
-                
-                if (data.success && data.sites) {
-                    data.sites.forEach(site => {
-                        const option = document.createElement('option');
-                        option.value = site.label;
-                        option.textContent = site.label + ' (' + site.site_id.substring(0, 16) + '...)';
-                        select.appendChild(option);
-                    });
-                }
-                
-                // Update button state
-                validateDomainName();
-                
-            } catch (error) {
-                showResult('domain-result', 'Error loading sites: ' + error.message, 'error');
-            }
-        }
-
-        async function registerDomain() {
-            const domainName = document.getElementById('new-domain-name').value;
-            const siteLabel = document.getElementById('domain-site-select').value;
-            
-            if (!domainName || !siteLabel || !currentWallet) {
-                showResult('domain-result', 'Please fill in all fields', 'error');
-                return;
-            }
-            
-            // Find the site ID for the selected label
-            const site = currentWallet.sites[siteLabel];
-            if (!site) {
-                showResult('domain-result', 'Selected site not found', 'error');
-                return;
-            }
-            
-            try {
-                const response = await fetch('/api/domains/register', {
-                    method: 'POST',
-                    headers: { 'Content-Type': 'application/json' },
-                    body: JSON.stringify({
-                        domain: domainName,
-                        site_id: site.site_id
-                    })
-                });
-                
-                const data = await response.json();
-                
-                if (data.success) {
-                    showResult('domain-result', 
-                        'Site name "' + domainName + '" registered successfully!\\n' +
-                        'You can now access your site at: http://localhost:8080/' + domainName, 
-                        'success'
-                    );
-                    
-                    // Clear form
-                    document.getElementById('new-domain-name').value = '';
-                    document.getElementById('domain-site-select').value = '';
-                    document.getElementById('register-domain-btn').disabled = true;
-                    
-                    // Reload domains list
-                    loadDomains();
-                } else {
-                    throw new Error(data.error || 'Registration failed');
-                }
-                
-            } catch (error) {
-                showResult('domain-result', 'Error registering site name: ' + error.message, 'error');
-            }
-        }
-
-        // Update navigation enable/disable logic
-        function enableNavigation() {
-            document.getElementById('nav-sites').disabled = false;
-            document.getElementById('nav-domains').disabled = false;
-            document.getElementById('nav-editor').disabled = false;
-        }
-    </script>
-</body>
-</html>\`
-
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	if _, err := w.Write([]byte(homepage)); err != nil {
-		http.Error(w, "Failed to write response", http.StatusInternalServerError)
-		return
-	}
+	ws.renderPage(w, r, "wallet.html")
 }
 
 // API Handlers for wallet operations
@@ -1136,6 +127,15 @@ func (ws *WebServer) handleCreateWallet(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// The request body is optional; it only carries the BIP-39 passphrase
+	var req struct {
+		MnemonicPassphrase string `json:"mnemonic_passphrase"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
 	// Create new wallet
 	mnemonic, err := wallet.NewMnemonic()
 	if err != nil {
@@ -1144,11 +144,16 @@ func (ws *WebServer) handleCreateWallet(w http.ResponseWriter, r *http.Request)
 	}
 
 	walletData := wallet.New()
-	encryptedWallet, err := wallet.EncryptWallet(walletData, mnemonic)
+	encryptedWallet, session, err := wallet.NewSession(walletData, mnemonic, req.MnemonicPassphrase)
 	if err != nil {
 		http.Error(w, "Failed to encrypt wallet", http.StatusInternalServerError)
 		return
 	}
+	token, err := ws.startWalletSession(session)
+	if err != nil {
+		http.Error(w, "Failed to unlock wallet", http.StatusInternalServerError)
+		return
+	}
 
 	// Generate wallet filename with timestamp
 	timestamp := time.Now().Format("2006-01-02_15-04")
@@ -1169,9 +174,12 @@ func (ws *WebServer) handleCreateWallet(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// The mnemonic is only ever returned here, for the user to write down;
+	// later requests use the session.
 	response := map[string]interface{}{
 		"success":     true,
 		"mnemonic":    mnemonic,
+		"session":     token,
 		"wallet":      walletData,
 		"wallet_name": walletName,
 		"saved_path":  walletPath,
@@ -1192,8 +200,9 @@ func (ws *WebServer) handleLoadWallet(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		WalletData string `json:"wallet_data"`
-		Mnemonic   string `json:"mnemonic"`
+		WalletData         string `json:"wallet_data"`
+		Mnemonic           string `json:"mnemonic"`
+		MnemonicPassphrase string `json:"mnemonic_passphrase"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -1201,23 +210,14 @@ func (ws *WebServer) handleLoadWallet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Parse wallet data
-	encryptedWallet := []byte(req.WalletData)
-	walletData, err := wallet.DecryptWallet(encryptedWallet, req.Mnemonic)
-	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(map[string]interface{}{
-			"success": false,
-			"error":   "Incorrect mnemonic phrase. Please check your mnemonic and try again.",
-		}); err != nil {
-			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
-		}
+	walletData, token, ok := ws.unlockWalletSession(w, r, []byte(req.WalletData), req.Mnemonic, req.MnemonicPassphrase)
+	if !ok {
 		return
 	}
 
 	response := map[string]interface{}{
 		"success": true,
+		"session": token,
 		"wallet":  walletData,
 		"sites":   walletData.Sites,
 	}
@@ -1236,8 +236,9 @@ func (ws *WebServer) handleWalletSites(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		WalletData string `json:"wallet_data"`
-		Mnemonic   string `json:"mnemonic"`
+		WalletData         string `json:"wallet_data"`
+		Mnemonic           string `json:"mnemonic"`
+		MnemonicPassphrase string `json:"mnemonic_passphrase"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -1252,27 +253,43 @@ func (ws *WebServer) handleWalletSites(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate that the mnemonic is provided (for security)
-	if req.Mnemonic == "" {
-		http.Error(w, "Mnemonic is required", http.StatusUnauthorized)
+	// Validate that the wallet is unlocked (for security)
+	if !ws.walletAuthorized(w, r, req.Mnemonic) {
 		return
 	}
 
-	// Convert sites map to array for frontend
-	var sitesArray []map[string]interface{}
-	if walletData.Sites != nil {
-		for label, siteData := range walletData.Sites {
-			sitesArray = append(sitesArray, map[string]interface{}{
-				"label":        label,
-				"site_id":      siteData.SiteID,
-				"last_updated": siteData.LastUpdated,
-			})
-		}
+	// Names registered in the store, by site
+	allDomains, err := ws.store.ListDomains()
+	if err != nil {
+		http.Error(w, "Failed to list domains", http.StatusInternalServerError)
+		return
 	}
-
-	// Ensure we always return an array, even if empty
-	if sitesArray == nil {
-		sitesArray = make([]map[string]interface{}, 0)
+	siteDomains := make(map[string][]string)
+	for domain, siteID := range allDomains {
+		siteDomains[siteID] = append(siteDomains[siteID], domain)
+	}
+
+	// Convert sites map to array for frontend, sorted by label so the list
+	// keeps its order between refreshes
+	labels := make([]string, 0, len(walletData.Sites))
+	for label := range walletData.Sites {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	sitesArray := make([]map[string]interface{}, 0, len(labels))
+	for _, label := range labels {
+		siteData := walletData.Sites[label]
+		domains := siteDomains[siteData.SiteID]
+		sort.Strings(domains)
+		if domains == nil {
+			domains = []string{}
+		}
+		sitesArray = append(sitesArray, map[string]interface{}{
+			"label":        label,
+			"site_id":      siteData.SiteID,
+			"last_updated": siteData.LastUpdated,
+			"domains":      domains,
+		})
 	}
 
 	response := map[string]interface{}{
@@ -1294,9 +311,10 @@ func (ws *WebServer) handleAddSite(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		WalletData string `json:"wallet_data"`
-		Mnemonic   string `json:"mnemonic"`
-		Label      string `json:"label"`
+		WalletData         string `json:"wallet_data"`
+		Mnemonic           string `json:"mnemonic"`
+		MnemonicPassphrase string `json:"mnemonic_passphrase"`
+		Label              string `json:"label"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -1311,18 +329,11 @@ func (ws *WebServer) handleAddSite(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate that the mnemonic is provided (for security)
-	if req.Mnemonic == "" {
-		http.Error(w, "Mnemonic is required", http.StatusUnauthorized)
-		return
-	}
-
-	// Generate master key
-	master, err := wallet.MasterKeyFromMnemonic(req.Mnemonic)
-	if err != nil {
-		http.Error(w, "Failed to generate master key", http.StatusInternalServerError)
+	master, ok := ws.walletMaster(w, r, req.Mnemonic, req.MnemonicPassphrase)
+	if !ok {
 		return
 	}
+	defer clear(master)
 
 	// Add site
 	meta, _, _, err := walletData.EnsureSite(master, req.Label)
@@ -1351,96 +362,143 @@ func (ws *WebServer) handlePublishContent(w http.ResponseWriter, r *http.Request
 	}
 
 	var req struct {
-		WalletData string `json:"wallet_data"`
-		Mnemonic   string `json:"mnemonic"`
-		Label      string `json:"label"`
-		Content    string `json:"content"`
+		WalletData         string `json:"wallet_data"`
+		Mnemonic           string `json:"mnemonic"`
+		MnemonicPassphrase string `json:"mnemonic_passphrase"`
+		Label              string `json:"label"`
+		Content            string `json:"content"`
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAddFileBody)).Decode(&req); err != nil {
 		http.Error(w, "Invalid request", http.StatusBadRequest)
 		return
 	}
 
-	// Decrypt wallet
-	walletData, err := wallet.DecryptWallet([]byte(req.WalletData), req.Mnemonic)
-	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(map[string]interface{}{
-			"success": false,
-			"error":   "Incorrect mnemonic phrase. Please check your mnemonic and try again.",
-		}); err != nil {
-			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
-		}
+	siteID, priv, ok := ws.walletSiteKey(w, r, req.WalletData, req.Mnemonic, req.MnemonicPassphrase, req.Label)
+	if !ok {
 		return
 	}
 
-	// Generate master key
-	master, err := wallet.MasterKeyFromMnemonic(req.Mnemonic)
+	u, err := ws.publisher().PublishContent(ws.ctx, priv, []byte(req.Content))
 	if err != nil {
-		http.Error(w, "Failed to generate master key", http.StatusInternalServerError)
+		publishError(w, err)
 		return
 	}
 
-	// Ensure site exists
-	meta, pub, _, err := walletData.EnsureSite(master, req.Label)
-	if err != nil {
-		http.Error(w, "Failed to get site", http.StatusInternalServerError)
+	writeJSON(w, map[string]interface{}{
+		"success":     true,
+		"site_id":     siteID,
+		"seq":         u.Seq,
+		"content_cid": u.ContentCID,
+		"record_cid":  u.RecordCID,
+	})
+}
+
+func (ws *WebServer) handlePublishWebsite(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	contentBytes := []byte(req.Content)
+	var req struct {
+		WalletData         string   `json:"wallet_data"`
+		Mnemonic           string   `json:"mnemonic"`
+		MnemonicPassphrase string   `json:"mnemonic_passphrase"`
+		SiteLabel          string   `json:"site_label"`
+		NoIndex            *bool    `json:"no_index"`   // omitted keeps the site's current setting
+		Preload            []string `json:"preload"`    // files nodes fetch first; omitted keeps the current list, [] clears it
+		Background         bool     `json:"background"` // answer with a task to poll at /api/tasks
+	}
 
-	// Create update record
-	record := &core.UpdateRecord{
-		Version:    "1.0",
-		SitePub:    pub,
-		Seq:        1,
-		PrevCID:    "",
-		ContentCID: core.CIDForContent(contentBytes),
-		TS:         core.NowTS(),
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxCommentRequestBody)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
 	}
 
-	// Generate ephemeral key for this update
-	updatePub, updatePriv, err := wallet.DeriveSiteKey(master, req.Label+"-update")
-	if err != nil {
-		http.Error(w, "Failed to derive update key", http.StatusInternalServerError)
+	siteID, priv, ok := ws.walletSiteKey(w, r, req.WalletData, req.Mnemonic, req.MnemonicPassphrase, req.SiteLabel)
+	if !ok {
 		return
 	}
-	record.UpdatePub = updatePub
 
-	// Sign record
-	recordData, err := core.CanonicalMarshalNoUpdateSig(record)
+	publish := func(ctx context.Context, progress publisher.Progress) (map[string]interface{}, error) {
+		settings := publisher.Settings{NoIndex: req.NoIndex, Preload: req.Preload}
+		m, err := ws.publisher().PublishWebsiteProgress(ctx, priv, settings, progress)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"success":      true,
+			"site_id":      siteID,
+			"manifest_cid": m.CID,
+			"seq":          m.Seq,
+			"files":        len(m.Files),
+			"no_index":     m.NoIndex,
+			"preload":      m.Preload,
+			"message":      "Site published successfully to AlxNet network",
+		}, nil
+	}
+
+	if req.Background {
+		ws.startTask(w, "publish", "Publish "+req.SiteLabel, func(ctx context.Context, progress taskProgress) (interface{}, error) {
+			return publish(ctx, func(done, total int) {
+				progress(done, total, "verifying files")
+			})
+		})
+		return
+	}
+	result, err := publish(ws.ctx, nil)
 	if err != nil {
-		http.Error(w, "Failed to marshal record", http.StatusInternalServerError)
+		publishError(w, err)
 		return
 	}
-	record.UpdateSig = ed25519.Sign(updatePriv, recordData)
+	writeJSON(w, result)
+}
 
-	// Store content and record
-	if err := ws.store.PutContent(record.ContentCID, contentBytes); err != nil {
-		http.Error(w, "Failed to store content", http.StatusInternalServerError)
+// handleExportKey returns a site private key in plaintext.
+//
+// Deprecated: use /api/wallet/export-keystore, which returns the key sealed
+// with a passphrase.
+func (ws *WebServer) handleExportKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	recordCID := core.CIDForBytes(recordData)
-	if err := ws.store.PutRecord(recordCID, recordData); err != nil {
-		http.Error(w, "Failed to store record", http.StatusInternalServerError)
+	ws.logger.Warn("Plaintext key export is deprecated, use /api/wallet/export-keystore")
+	w.Header().Set("Deprecation", "true")
+	w.Header().Set("Link", "</api/wallet/export-keystore>; rel=\"successor-version\"")
+
+	var req struct {
+		WalletData         string `json:"wallet_data"`
+		Mnemonic           string `json:"mnemonic"`
+		MnemonicPassphrase string `json:"mnemonic_passphrase"`
+		Label              string `json:"label"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
 		return
 	}
 
-	// Update site head
-	if err := ws.store.PutHead(meta.SiteID, record.Seq, recordCID); err != nil {
-		http.Error(w, "Failed to update site head", http.StatusInternalServerError)
+	walletData, master, ok := ws.unlockWallet(w, r, req.WalletData, req.Mnemonic, req.MnemonicPassphrase)
+	if !ok {
+		return
+	}
+	defer clear(master)
+
+	// Get site private key
+	_, _, priv, err := walletData.EnsureSite(master, req.Label)
+	if err != nil {
+		http.Error(w, "Failed to get site", http.StatusInternalServerError)
 		return
 	}
 
+	keyData := base64.StdEncoding.EncodeToString(priv)
+
 	response := map[string]interface{}{
 		"success":     true,
-		"site_id":     meta.SiteID,
-		"content_cid": record.ContentCID,
-		"record_cid":  recordCID,
+		"label":       req.Label,
+		"private_key": keyData,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -1450,174 +508,187 @@ func (ws *WebServer) handlePublishContent(w http.ResponseWriter, r *http.Request
 	}
 }
 
-func (ws *WebServer) handlePublishWebsite(w http.ResponseWriter, r *http.Request) {
+// handleExportKeystore exports a site key as a passphrase-encrypted keystore file
+func (ws *WebServer) handleExportKeystore(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
 	if r.Method != "POST" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		if err := json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   "Method not allowed",
+		}); err != nil {
+			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		}
 		return
 	}
 
 	var req struct {
-		WalletData string `json:"wallet_data"`
-		Mnemonic   string `json:"mnemonic"`
-		SiteLabel  string `json:"site_label"`
+		WalletData         string `json:"wallet_data"`
+		Mnemonic           string `json:"mnemonic"`
+		MnemonicPassphrase string `json:"mnemonic_passphrase"`
+		Label              string `json:"label"`
+		Passphrase         string `json:"passphrase"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request", http.StatusBadRequest)
-		return
-	}
-
-	// Parse wallet data (already decrypted from frontend)
-	var walletData wallet.Wallet
-	if err := json.Unmarshal([]byte(req.WalletData), &walletData); err != nil {
-		http.Error(w, "Failed to parse wallet data", http.StatusBadRequest)
-		return
-	}
-
-	// Get site from wallet
-	site, exists := walletData.Sites[req.SiteLabel]
-	if !exists {
-		http.Error(w, "Site not found", http.StatusNotFound)
+		w.WriteHeader(http.StatusBadRequest)
+		if err := json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   "Invalid request",
+		}); err != nil {
+			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		}
 		return
 	}
 
-	// Get all files for the site from data store
-	fileRecordCIDs, err := ws.store.ListWebsiteFiles(site.SiteID)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to list website files: %v", err), http.StatusInternalServerError)
+	if err := wallet.ValidatePassphrase(req.Passphrase, false); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		if err := json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   fmt.Sprintf("Invalid keystore passphrase: %v", err),
+		}); err != nil {
+			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		}
 		return
 	}
 
-	// If no files found, return error
-	if len(fileRecordCIDs) == 0 {
-		http.Error(w, "No files found for site - save some files first", http.StatusBadRequest)
+	walletData, master, ok := ws.unlockWallet(w, r, req.WalletData, req.Mnemonic, req.MnemonicPassphrase)
+	if !ok {
 		return
 	}
+	defer clear(master)
 
-	// Retrieve actual file contents and collect CIDs
-	fileCIDs := make(map[string]string)
-	for filePath, recordCID := range fileRecordCIDs {
-		// Get the file record
-		fileRecordData, err := ws.store.GetFileRecord(recordCID)
-		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to get file record for %s: %v", filePath, err), http.StatusInternalServerError)
-			return
-		}
-
-		// Unmarshal the file record to get content CID
-		var fileRecord core.FileRecord
-		if err := cbor.Unmarshal(fileRecordData, &fileRecord); err != nil {
-			http.Error(w, fmt.Sprintf("Failed to unmarshal file record for %s: %v", filePath, err), http.StatusInternalServerError)
-			return
+	if _, exists := walletData.Sites[req.Label]; !exists {
+		w.WriteHeader(http.StatusNotFound)
+		if err := json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   fmt.Sprintf("Site %s not found in wallet", req.Label),
+		}); err != nil {
+			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 		}
-
-		fileCIDs[filePath] = fileRecord.ContentCID
-	}
-
-	// Create website manifest
-	manifest := &core.WebsiteManifest{
-		Version:   "1.0",
-		SitePub:   make([]byte, 32), // Placeholder - should be actual site public key
-		Seq:       1,
-		PrevCID:   "",
-		TS:        time.Now().Unix(),
-		MainFile:  "index.html",
-		Files:     fileCIDs,
-		UpdatePub: make([]byte, 32), // Placeholder
-		LinkSig:   make([]byte, 64), // Placeholder
-		UpdateSig: make([]byte, 64), // Placeholder
+		return
 	}
 
-	// Marshal manifest
-	manifestData, err := core.CanonicalMarshalWebsiteManifest(manifest)
+	meta, _, priv, err := walletData.EnsureSite(master, req.Label)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to marshal manifest: %v", err), http.StatusInternalServerError)
+		http.Error(w, "Failed to get site", http.StatusInternalServerError)
 		return
 	}
 
-	// Generate manifest CID
-	manifestCID := fmt.Sprintf("%x", sha256.Sum256(manifestData))
-
-	// Store manifest in data store
-	if err := ws.store.PutWebsiteManifest(site.SiteID, manifestCID, manifestData); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to store manifest: %v", err), http.StatusInternalServerError)
+	keystore, err := wallet.ExportKeystore(req.Label, priv, req.Passphrase)
+	if err != nil {
+		ws.logger.Error("Failed to export keystore", zap.String("label", req.Label), zap.Error(err))
+		http.Error(w, "Failed to export keystore", http.StatusInternalServerError)
 		return
 	}
 
 	response := map[string]interface{}{
-		"success":      true,
-		"site_id":      site.SiteID,
-		"manifest_cid": manifestCID,
-		"files":        len(fileCIDs),
-		"message":      "Site published successfully to AlxNet network",
+		"success":  true,
+		"label":    req.Label,
+		"site_id":  meta.SiteID,
+		"filename": fmt.Sprintf("%s.keystore.json", req.Label),
+		"keystore": string(keystore),
 	}
 
-	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 		return
 	}
 }
 
-func (ws *WebServer) handleAddWebsiteFile(w http.ResponseWriter, r *http.Request) {
-	// TODO: Implement individual file addition to websites
-	http.Error(w, "Add website file not yet implemented", http.StatusNotImplemented)
-}
+// handleImportKeystore decrypts a keystore file and adds its site key to the wallet
+func (ws *WebServer) handleImportKeystore(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
 
-func (ws *WebServer) handleExportKey(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		if err := json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   "Method not allowed",
+		}); err != nil {
+			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		}
 		return
 	}
 
 	var req struct {
-		WalletData string `json:"wallet_data"`
-		Mnemonic   string `json:"mnemonic"`
-		Label      string `json:"label"`
+		WalletData         string `json:"wallet_data"`
+		Mnemonic           string `json:"mnemonic"`
+		MnemonicPassphrase string `json:"mnemonic_passphrase"`
+		Keystore           string `json:"keystore"`
+		Passphrase         string `json:"passphrase"`
+		Label              string `json:"label"` // optional override of the keystore label
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request", http.StatusBadRequest)
+		w.WriteHeader(http.StatusBadRequest)
+		if err := json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   "Invalid request",
+		}); err != nil {
+			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	walletData, master, ok := ws.unlockWallet(w, r, req.WalletData, req.Mnemonic, req.MnemonicPassphrase)
+	if !ok {
 		return
 	}
+	defer clear(master)
 
-	// Decrypt wallet
-	walletData, err := wallet.DecryptWallet([]byte(req.WalletData), req.Mnemonic)
+	if ws.loginLockedOut(w, r) {
+		return
+	}
+	label, priv, err := wallet.ImportKeystore([]byte(req.Keystore), req.Passphrase)
 	if err != nil {
+		if errors.Is(err, wallet.ErrKeystorePassphrase) {
+			ws.loginFailed(r, "wrong keystore passphrase")
+		}
 		w.WriteHeader(http.StatusUnauthorized)
-		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(map[string]interface{}{
 			"success": false,
-			"error":   "Incorrect mnemonic phrase. Please check your mnemonic and try again.",
+			"error":   fmt.Sprintf("Failed to open keystore: %v", err),
 		}); err != nil {
 			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 		}
 		return
 	}
+	if req.Label != "" {
+		label = req.Label
+	}
 
-	// Generate master key
-	master, err := wallet.MasterKeyFromMnemonic(req.Mnemonic)
+	meta, err := walletData.ImportSiteKey(master, label, priv)
 	if err != nil {
-		http.Error(w, "Failed to generate master key", http.StatusInternalServerError)
+		w.WriteHeader(http.StatusConflict)
+		if err := json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+		}); err != nil {
+			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		}
 		return
 	}
 
-	// Get site private key
-	_, _, priv, err := walletData.EnsureSite(master, req.Label)
+	encrypted, err := ws.encryptWallet(r, walletData, req.Mnemonic, req.MnemonicPassphrase)
 	if err != nil {
-		http.Error(w, "Failed to get site", http.StatusInternalServerError)
+		http.Error(w, "Failed to encrypt wallet", http.StatusInternalServerError)
 		return
 	}
 
-	keyData := base64.StdEncoding.EncodeToString(priv)
+	ws.logger.Info("Imported site key from keystore", zap.String("label", label), zap.String("site_id", meta.SiteID))
 
+	// The imported seed is sealed under the master key inside the wallet,
+	// so neither form of the wallet returned here exposes it.
 	response := map[string]interface{}{
-		"success":     true,
-		"label":       req.Label,
-		"private_key": keyData,
+		"success":          true,
+		"label":            label,
+		"site_id":          meta.SiteID,
+		"wallet":           walletData,
+		"encrypted_wallet": string(encrypted),
 	}
 
-	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 		return
@@ -1759,14 +830,14 @@ func (ws *WebServer) handleListWalletDomains(w http.ResponseWriter, r *http.Requ
 	}
 
 	// Filter domains that belong to the provided site IDs
+	walletSites := make(map[string]bool, len(req.SiteIDs))
+	for _, siteID := range req.SiteIDs {
+		walletSites[siteID] = true
+	}
 	walletDomains := make(map[string]string)
 	for domain, siteID := range allDomains {
-		// Check if this siteID is in the provided list
-		for _, walletSiteID := range req.SiteIDs {
-			if siteID == walletSiteID {
-				walletDomains[domain] = siteID
-				break
-			}
+		if walletSites[siteID] {
+			walletDomains[domain] = siteID
 		}
 	}
 
@@ -1795,7 +866,7 @@ func (ws *WebServer) handleResolveDomain(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	siteID, err := ws.store.ResolveDomain(domain)
+	siteID, source, err := ws.resolveName(r.Context(), domain)
 	if err != nil {
 		http.Error(w, "Failed to resolve domain", http.StatusNotFound)
 		return
@@ -1805,6 +876,7 @@ func (ws *WebServer) handleResolveDomain(w http.ResponseWriter, r *http.Request)
 		"success": true,
 		"domain":  domain,
 		"site_id": siteID,
+		"source":  source,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -1816,9 +888,10 @@ func (ws *WebServer) handleResolveDomain(w http.ResponseWriter, r *http.Request)
 
 func (ws *WebServer) handleGetWebsiteInfo(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		WalletData string `json:"wallet_data"`
-		Mnemonic   string `json:"mnemonic"`
-		Label      string `json:"label"`
+		WalletData         string `json:"wallet_data"`
+		Mnemonic           string `json:"mnemonic"`
+		MnemonicPassphrase string `json:"mnemonic_passphrase"`
+		Label              string `json:"label"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -1826,26 +899,11 @@ func (ws *WebServer) handleGetWebsiteInfo(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Decrypt wallet
-	walletData, err := wallet.DecryptWallet([]byte(req.WalletData), req.Mnemonic)
-	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(map[string]interface{}{
-			"success": false,
-			"error":   "Incorrect mnemonic phrase. Please check your mnemonic and try again.",
-		}); err != nil {
-			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
-		}
-		return
-	}
-
-	// Generate master key
-	master, err := wallet.MasterKeyFromMnemonic(req.Mnemonic)
-	if err != nil {
-		http.Error(w, "Failed to generate master key", http.StatusInternalServerError)
+	walletData, master, ok := ws.unlockWallet(w, r, req.WalletData, req.Mnemonic, req.MnemonicPassphrase)
+	if !ok {
 		return
 	}
+	defer clear(master)
 
 	// Get site
 	meta, _, _, err := walletData.EnsureSite(master, req.Label)
@@ -1909,6 +967,165 @@ func (ws *WebServer) handleWalletStatus(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+// handleSiteStats reports replication telemetry for one or more sites,
+// given as repeated site_id query parameters.
+func (ws *WebServer) handleSiteStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	siteIDs := r.URL.Query()["site_id"]
+	if len(siteIDs) == 0 {
+		http.Error(w, "site_id is required", http.StatusBadRequest)
+		return
+	}
+	if len(siteIDs) > wallet.MaxSitesPerWallet {
+		http.Error(w, "Too many sites", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), p2p.HaveQueryTimeout+time.Second)
+	defer cancel()
+
+	stats := make([]interface{}, 0, len(siteIDs))
+	for _, siteID := range siteIDs {
+		if len(siteID) != 64 {
+			http.Error(w, "Invalid site ID", http.StatusBadRequest)
+			return
+		}
+		st, err := ws.node.SiteStats(ctx, siteID)
+		if err != nil {
+			stats = append(stats, map[string]interface{}{
+				"site_id": siteID,
+				"error":   err.Error(),
+			})
+			continue
+		}
+		stats = append(stats, st)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"stats":   stats,
+	}); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// handleHostingRequest signs a hosting request with a site key and sends it
+// to the node given by its full multiaddr (including /p2p/<peerID>).
+func (ws *WebServer) handleHostingRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		WalletData         string `json:"wallet_data"`
+		Mnemonic           string `json:"mnemonic"`
+		MnemonicPassphrase string `json:"mnemonic_passphrase"`
+		Label              string `json:"label"`
+		Host               string `json:"host"`
+		Days               int    `json:"days"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	maddr, err := ma.NewMultiaddr(req.Host)
+	if err != nil {
+		http.Error(w, "Invalid host address", http.StatusBadRequest)
+		return
+	}
+	host, err := peer.AddrInfoFromP2pAddr(maddr)
+	if err != nil {
+		http.Error(w, "Host address must end in /p2p/<peerID>", http.StatusBadRequest)
+		return
+	}
+	duration := time.Duration(req.Days) * 24 * time.Hour
+	if req.Days < 1 || duration > core.MaxHostingDuration {
+		http.Error(w, "days must be between 1 and 365", http.StatusBadRequest)
+		return
+	}
+
+	walletData, master, ok := ws.unlockWallet(w, r, req.WalletData, req.Mnemonic, req.MnemonicPassphrase)
+	if !ok {
+		return
+	}
+	defer clear(master)
+	if _, exists := walletData.Sites[req.Label]; !exists {
+		w.WriteHeader(http.StatusNotFound)
+		if err := json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   fmt.Sprintf("Site %s not found in wallet", req.Label),
+		}); err != nil {
+			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	_, _, priv, err := walletData.EnsureSite(master, req.Label)
+	if err != nil {
+		http.Error(w, "Failed to get site", http.StatusInternalServerError)
+		return
+	}
+
+	hostingReq, err := p2p.BuildHostingRequest(priv, host.ID, duration)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+	defer cancel()
+	agreement, err := ws.node.RequestHosting(ctx, *host, hostingReq)
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		if err := json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   fmt.Sprintf("Hosting request failed: %v", err),
+		}); err != nil {
+			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":   true,
+		"agreement": agreement,
+	}); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// handleHostingList lists hosting requests sent from this node and the
+// availability their hosts last reported.
+func (ws *WebServer) handleHostingList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	agreements, err := ws.node.HostingAgreements(p2p.HostingOutgoing)
+	if err != nil {
+		http.Error(w, "Failed to list hosting agreements", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"agreements": agreements,
+		"count":      len(agreements),
+	}); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
 // New API handlers for enhanced wallet flow
 
 func (ws *WebServer) handleListWallets(w http.ResponseWriter, r *http.Request) {
@@ -2042,8 +1259,9 @@ func (ws *WebServer) handleEncryptWallet(w http.ResponseWriter, r *http.Request)
 	}
 
 	var req struct {
-		WalletData interface{} `json:"wallet_data"`
-		Mnemonic   string      `json:"mnemonic"`
+		WalletData         interface{} `json:"wallet_data"`
+		Mnemonic           string      `json:"mnemonic"`
+		MnemonicPassphrase string      `json:"mnemonic_passphrase"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -2083,7 +1301,11 @@ func (ws *WebServer) handleEncryptWallet(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Encrypt wallet
-	encryptedWallet, err := wallet.EncryptWallet(&walletData, req.Mnemonic)
+	encryptedWallet, err := ws.encryptWallet(r, &walletData, req.Mnemonic, req.MnemonicPassphrase)
+	if errors.Is(err, wallet.ErrLocked) {
+		writeWalletLocked(w)
+		return
+	}
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		if err := json.NewEncoder(w).Encode(map[string]interface{}{
@@ -2119,8 +1341,9 @@ func (ws *WebServer) handleLoadWalletFile(w http.ResponseWriter, r *http.Request
 	}
 
 	var req struct {
-		Filename string `json:"filename"`
-		Mnemonic string `json:"mnemonic"`
+		Filename           string `json:"filename"`
+		Mnemonic           string `json:"mnemonic"`
+		MnemonicPassphrase string `json:"mnemonic_passphrase"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -2157,21 +1380,14 @@ func (ws *WebServer) handleLoadWalletFile(w http.ResponseWriter, r *http.Request
 
 	// Decrypt wallet if mnemonic provided
 	if req.Mnemonic != "" {
-		decryptedWallet, err := wallet.DecryptWallet(walletData, req.Mnemonic)
-		if err != nil {
-			w.WriteHeader(http.StatusUnauthorized)
-			w.Header().Set("Content-Type", "application/json")
-			if err := json.NewEncoder(w).Encode(map[string]interface{}{
-				"success": false,
-				"error":   "Incorrect mnemonic phrase. Please check your mnemonic and try again.",
-			}); err != nil {
-				http.Error(w, "Failed to encode response", http.StatusInternalServerError)
-			}
+		decryptedWallet, token, ok := ws.unlockWalletSession(w, r, walletData, req.Mnemonic, req.MnemonicPassphrase)
+		if !ok {
 			return
 		}
 
 		response := map[string]interface{}{
 			"success": true,
+			"session": token,
 			"wallet":  decryptedWallet,
 			"message": "Wallet loaded successfully",
 		}
@@ -2204,9 +1420,10 @@ func (ws *WebServer) handleGetSiteFiles(w http.ResponseWriter, r *http.Request)
 	}
 
 	var req struct {
-		WalletData string `json:"wallet_data"`
-		Mnemonic   string `json:"mnemonic"`
-		SiteLabel  string `json:"site_label"`
+		WalletData         string `json:"wallet_data"`
+		Mnemonic           string `json:"mnemonic"`
+		MnemonicPassphrase string `json:"mnemonic_passphrase"`
+		SiteLabel          string `json:"site_label"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -2221,9 +1438,8 @@ func (ws *WebServer) handleGetSiteFiles(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Validate that the mnemonic is provided (for security)
-	if req.Mnemonic == "" {
-		http.Error(w, "Mnemonic is required", http.StatusUnauthorized)
+	// Validate that the wallet is unlocked (for security)
+	if !ws.walletAuthorized(w, r, req.Mnemonic) {
 		return
 	}
 
@@ -2256,6 +1472,8 @@ func (ws *WebServer) handleGetSiteFiles(w http.ResponseWriter, r *http.Request)
 		"site_id":   site.SiteID,
 		"main_file": websiteInfo.MainFile,
 		"files":     websiteInfo.Files,
+		"no_index":  websiteInfo.NoIndex,
+		"preload":   websiteInfo.Preload,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -2272,131 +1490,40 @@ func (ws *WebServer) handleSaveFileToSite(w http.ResponseWriter, r *http.Request
 	}
 
 	var req struct {
-		WalletData string `json:"wallet_data"`
-		Mnemonic   string `json:"mnemonic"`
-		SiteLabel  string `json:"site_label"`
-		FilePath   string `json:"file_path"`
-		Content    string `json:"content"`
-		MimeType   string `json:"mime_type"`
+		WalletData         string `json:"wallet_data"`
+		Mnemonic           string `json:"mnemonic"`
+		MnemonicPassphrase string `json:"mnemonic_passphrase"`
+		SiteLabel          string `json:"site_label"`
+		FilePath           string `json:"file_path"`
+		Content            string `json:"content"`
+		MimeType           string `json:"mime_type"`
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAddFileBody)).Decode(&req); err != nil {
 		http.Error(w, "Invalid request", http.StatusBadRequest)
 		return
 	}
 
-	// Parse wallet data (already decrypted from frontend)
-	var walletData wallet.Wallet
-	if err := json.Unmarshal([]byte(req.WalletData), &walletData); err != nil {
-		http.Error(w, "Failed to parse wallet data", http.StatusBadRequest)
-		return
-	}
-
-	// Get site from wallet
-	site, exists := walletData.Sites[req.SiteLabel]
-	if !exists {
-		http.Error(w, "Site not found", http.StatusNotFound)
-		return
-	}
-
-	// Store the file content in the data store
-	content := []byte(req.Content)
-	contentCID := fmt.Sprintf("%x", sha256.Sum256(content))
-
-	if err := ws.store.PutContent(contentCID, content); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to store content: %v", err), http.StatusInternalServerError)
+	siteID, priv, ok := ws.walletSiteKey(w, r, req.WalletData, req.Mnemonic, req.MnemonicPassphrase, req.SiteLabel)
+	if !ok {
 		return
 	}
 
-	// Create FileRecord
-	fileRecord := &core.FileRecord{
-		Version:    "1",
-		SitePub:    []byte{}, // TODO: Get actual site public key
-		Path:       req.FilePath,
-		ContentCID: contentCID,
-		MimeType:   req.MimeType,
-		TS:         time.Now().Unix(),
-		UpdatePub:  []byte{}, // TODO: Generate ephemeral key
-		LinkSig:    []byte{}, // TODO: Generate signature
-		UpdateSig:  []byte{}, // TODO: Generate signature
-	}
-
-	// Marshal FileRecord to bytes
-	fileRecordData, err := core.CanonicalMarshalFileRecord(fileRecord)
+	f, err := ws.publisher().SaveFile(priv, req.FilePath, []byte(req.Content), req.MimeType)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to marshal file record: %v", err), http.StatusInternalServerError)
-		return
-	}
-
-	// Store file record
-	recordCID := fmt.Sprintf("%x", sha256.Sum256(fileRecordData))
-	if err := ws.store.PutFileRecord(site.SiteID, req.FilePath, recordCID, fileRecordData); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to store file record: %v", err), http.StatusInternalServerError)
+		publishError(w, err)
 		return
 	}
 
 	response := map[string]interface{}{
 		"success":     true,
-		"site_id":     site.SiteID,
-		"file_path":   req.FilePath,
-		"content_cid": contentCID,
-		"size":        len(content),
-		"mime_type":   req.MimeType,
-		"message":     "File saved successfully",
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
-		return
-	}
-}
-
-func (ws *WebServer) handleDeleteFileFromSite(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "POST" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	var req struct {
-		WalletData string `json:"wallet_data"`
-		Mnemonic   string `json:"mnemonic"`
-		SiteLabel  string `json:"site_label"`
-		FilePath   string `json:"file_path"`
-	}
-
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request", http.StatusBadRequest)
-		return
-	}
-
-	// Decrypt wallet
-	walletData, err := wallet.DecryptWallet([]byte(req.WalletData), req.Mnemonic)
-	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(map[string]interface{}{
-			"success": false,
-			"error":   "Incorrect mnemonic phrase. Please check your mnemonic and try again.",
-		}); err != nil {
-			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
-		}
-		return
-	}
-
-	// Get site from wallet
-	site, exists := walletData.Sites[req.SiteLabel]
-	if !exists {
-		http.Error(w, "Site not found", http.StatusNotFound)
-		return
-	}
-
-	// For now, return success (full implementation would delete from store)
-	response := map[string]interface{}{
-		"success":   true,
-		"site_id":   site.SiteID,
-		"file_path": req.FilePath,
-		"message":   "File delete functionality not fully implemented yet",
+		"site_id":     siteID,
+		"file_path":   f.Path,
+		"content_cid": f.ContentCID,
+		"size":        f.Size,
+		"mime_type":   f.MimeType,
+		"draft":       true,
+		"message":     "Draft saved",
 	}
 
 	w.Header().Set("Content-Type", "application/json")
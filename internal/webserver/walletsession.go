@@ -0,0 +1,341 @@
+package webserver
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"alxnet/internal/wallet"
+)
+
+// Wallet sessions. Opening a wallet in the wallet UI unlocks it once: the
+// keys derived from the mnemonic stay on the server behind a random token
+// that the page sends in the X-Wallet-Session header, so the mnemonic is
+// not kept in the page or sent with every request. A session locks itself,
+// zeroing its keys, after walletIdleTimeout without use.
+//
+// Requests without the header still authenticate with the mnemonic fields
+// of their body.
+const (
+	walletSessionHeader = "X-Wallet-Session"
+	walletIdleTimeout   = 15 * time.Minute
+	// maxWalletSessions bounds the unlocked wallets of one server; opening
+	// another locks the least recently used.
+	maxWalletSessions = 16
+)
+
+// walletLockedMessage is answered to requests whose session has locked.
+const walletLockedMessage = "Wallet is locked. Unlock it with your mnemonic to continue."
+
+type walletSessions struct {
+	mu       sync.Mutex
+	sessions map[string]*walletSession
+	idle     time.Duration
+}
+
+type walletSession struct {
+	session  *wallet.Session
+	lastUsed time.Time
+	timer    *time.Timer
+}
+
+func newWalletSessions(idle time.Duration) *walletSessions {
+	return &walletSessions{sessions: make(map[string]*walletSession), idle: idle}
+}
+
+// add keeps s until it is locked or idle and returns its token.
+func (ss *walletSessions) add(s *wallet.Session) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf)
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if len(ss.sessions) >= maxWalletSessions {
+		oldest := ""
+		for t, ws := range ss.sessions {
+			if oldest == "" || ws.lastUsed.Before(ss.sessions[oldest].lastUsed) {
+				oldest = t
+			}
+		}
+		ss.lockLocked(oldest)
+	}
+	ss.sessions[token] = &walletSession{
+		session:  s,
+		lastUsed: time.Now(),
+		timer:    time.AfterFunc(ss.idle, func() { ss.lock(token) }),
+	}
+	return token, nil
+}
+
+// get returns the session of token and restarts its idle timer.
+func (ss *walletSessions) get(token string) (*wallet.Session, bool) {
+	if ss == nil || token == "" {
+		return nil, false
+	}
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ws, ok := ss.sessions[token]
+	if !ok {
+		return nil, false
+	}
+	ws.lastUsed = time.Now()
+	ws.timer.Reset(ss.idle)
+	return ws.session, true
+}
+
+// expires reports when the session of token locks unless it is used.
+func (ss *walletSessions) expires(token string) (time.Time, bool) {
+	if ss == nil || token == "" {
+		return time.Time{}, false
+	}
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ws, ok := ss.sessions[token]
+	if !ok {
+		return time.Time{}, false
+	}
+	return ws.lastUsed.Add(ss.idle), true
+}
+
+// lock zeroes the keys of the session of token and forgets it.
+func (ss *walletSessions) lock(token string) bool {
+	if ss == nil {
+		return false
+	}
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	return ss.lockLocked(token)
+}
+
+func (ss *walletSessions) lockLocked(token string) bool {
+	ws, ok := ss.sessions[token]
+	if !ok {
+		return false
+	}
+	ws.timer.Stop()
+	ws.session.Lock()
+	delete(ss.sessions, token)
+	return true
+}
+
+// lockAll locks every session; the server calls it when it stops.
+func (ss *walletSessions) lockAll() {
+	if ss == nil {
+		return
+	}
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	for token := range ss.sessions {
+		ss.lockLocked(token)
+	}
+}
+
+// startWalletSession keeps s as a session of this server and returns its
+// token.
+func (ws *WebServer) startWalletSession(s *wallet.Session) (string, error) {
+	if ws.wallets == nil {
+		s.Lock()
+		return "", errors.New("wallet sessions are not available")
+	}
+	return ws.wallets.add(s)
+}
+
+// unlockWalletSession decrypts an encrypted wallet with its mnemonic and
+// keeps its keys as a new session, returning the wallet and the session's
+// token. It writes an error response and reports false on failure.
+func (ws *WebServer) unlockWalletSession(w http.ResponseWriter, r *http.Request, encrypted []byte, mnemonic, passphrase string) (*wallet.Wallet, string, bool) {
+	if ws.loginLockedOut(w, r) {
+		return nil, "", false
+	}
+	walletData, session, err := wallet.Unlock(encrypted, mnemonic, passphrase)
+	if err != nil {
+		ws.loginFailed(r, "wrong wallet mnemonic")
+		writeWalletError(w, http.StatusUnauthorized, "Incorrect mnemonic phrase. Please check your mnemonic and try again.")
+		return nil, "", false
+	}
+	token, err := ws.startWalletSession(session)
+	if err != nil {
+		http.Error(w, "Failed to unlock wallet", http.StatusInternalServerError)
+		return nil, "", false
+	}
+	return walletData, token, true
+}
+
+// walletSession returns the session named by the request header. It writes
+// an error response and reports false if the header names a session that has
+// been locked; without the header it returns a nil session.
+func (ws *WebServer) walletSession(w http.ResponseWriter, r *http.Request) (*wallet.Session, bool) {
+	token := r.Header.Get(walletSessionHeader)
+	if token == "" {
+		return nil, true
+	}
+	s, ok := ws.wallets.get(token)
+	if !ok {
+		writeWalletLocked(w)
+		return nil, false
+	}
+	return s, true
+}
+
+func writeWalletLocked(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	writeJSON(w, map[string]interface{}{
+		"success": false,
+		"locked":  true,
+		"error":   walletLockedMessage,
+	})
+}
+
+// openWallet decrypts the encrypted wallet of a request with the request's
+// session or, without one, with its mnemonic. It writes an error response
+// and reports false on failure.
+func (ws *WebServer) openWallet(w http.ResponseWriter, r *http.Request, walletJSON, mnemonic, passphrase string) (*wallet.Wallet, bool) {
+	s, ok := ws.walletSession(w, r)
+	if !ok {
+		return nil, false
+	}
+	var walletData *wallet.Wallet
+	var err error
+	if s != nil {
+		walletData, err = s.Decrypt([]byte(walletJSON))
+	} else {
+		if ws.loginLockedOut(w, r) {
+			return nil, false
+		}
+		walletData, err = wallet.DecryptWallet([]byte(walletJSON), mnemonic, passphrase)
+	}
+	switch {
+	case errors.Is(err, wallet.ErrLocked):
+		writeWalletLocked(w)
+		return nil, false
+	case err != nil:
+		if s == nil {
+			ws.loginFailed(r, "wrong wallet mnemonic")
+		}
+		writeWalletError(w, http.StatusUnauthorized, "Incorrect mnemonic phrase. Please check your mnemonic and try again.")
+		return nil, false
+	}
+	return walletData, true
+}
+
+// unlockWallet is openWallet that also returns the master key. The caller
+// clears the key when done.
+func (ws *WebServer) unlockWallet(w http.ResponseWriter, r *http.Request, walletJSON, mnemonic, passphrase string) (*wallet.Wallet, []byte, bool) {
+	walletData, ok := ws.openWallet(w, r, walletJSON, mnemonic, passphrase)
+	if !ok {
+		return nil, nil, false
+	}
+	master, ok := ws.walletMaster(w, r, mnemonic, passphrase)
+	if !ok {
+		return nil, nil, false
+	}
+	return walletData, master, true
+}
+
+// walletMaster returns the master key of the request's session or, without
+// one, of its mnemonic, for requests that carry the wallet already
+// decrypted. The caller clears the key when done.
+func (ws *WebServer) walletMaster(w http.ResponseWriter, r *http.Request, mnemonic, passphrase string) ([]byte, bool) {
+	s, ok := ws.walletSession(w, r)
+	if !ok {
+		return nil, false
+	}
+	if s == nil && mnemonic == "" {
+		http.Error(w, "Mnemonic is required", http.StatusUnauthorized)
+		return nil, false
+	}
+	master, err := masterKey(s, mnemonic, passphrase)
+	if !writeMasterKeyError(w, err) {
+		return nil, false
+	}
+	return master, true
+}
+
+func masterKey(s *wallet.Session, mnemonic, passphrase string) ([]byte, error) {
+	if s != nil {
+		return s.MasterKey()
+	}
+	return wallet.MasterKeyFromMnemonic(mnemonic, passphrase)
+}
+
+// writeMasterKeyError answers a failure to get the master key and reports
+// whether there was none.
+func writeMasterKeyError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, wallet.ErrLocked):
+		writeWalletLocked(w)
+	default:
+		http.Error(w, "Failed to generate master key", http.StatusInternalServerError)
+	}
+	return false
+}
+
+// walletAuthorized reports whether a request that carries the wallet
+// already decrypted has an unlocked session or a mnemonic, writing an error
+// response if not.
+func (ws *WebServer) walletAuthorized(w http.ResponseWriter, r *http.Request, mnemonic string) bool {
+	s, ok := ws.walletSession(w, r)
+	if !ok {
+		return false
+	}
+	if s == nil && mnemonic == "" {
+		http.Error(w, "Mnemonic is required", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// encryptWallet encrypts walletData with the request's session or, without
+// one, with its mnemonic.
+func (ws *WebServer) encryptWallet(r *http.Request, walletData *wallet.Wallet, mnemonic, passphrase string) ([]byte, error) {
+	if s, ok := ws.wallets.get(r.Header.Get(walletSessionHeader)); ok {
+		return s.Encrypt(walletData)
+	}
+	if r.Header.Get(walletSessionHeader) != "" {
+		return nil, wallet.ErrLocked
+	}
+	return wallet.EncryptWallet(walletData, mnemonic, passphrase)
+}
+
+// handleWalletLock locks the session named by the request header, zeroing
+// its keys. Locking a session that has already locked succeeds.
+func (ws *WebServer) handleWalletLock(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	locked := ws.wallets.lock(r.Header.Get(walletSessionHeader))
+	if locked {
+		ws.logger.Info("Wallet locked")
+	}
+	writeJSON(w, map[string]interface{}{"success": true, "locked": true})
+}
+
+// handleWalletSession reports whether the session named by the request
+// header is unlocked and when it locks if left idle. It does not count as
+// use of the session.
+func (ws *WebServer) handleWalletSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	expires, ok := ws.wallets.expires(r.Header.Get(walletSessionHeader))
+	resp := map[string]interface{}{
+		"success":      true,
+		"unlocked":     ok,
+		"idle_timeout": int(walletIdleTimeout / time.Second),
+	}
+	if ok {
+		resp["expires"] = expires.UTC()
+	}
+	writeJSON(w, resp)
+}
@@ -0,0 +1,149 @@
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"alxnet/internal/store"
+	"alxnet/internal/wallet"
+
+	"go.uber.org/zap"
+)
+
+func TestWalletSessions(t *testing.T) {
+	ss := newWalletSessions(20 * time.Millisecond)
+	mnemonic, err := wallet.NewMnemonic()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, used, err := wallet.NewSession(wallet.New(), mnemonic, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, idle, err := wallet.NewSession(wallet.New(), mnemonic, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	usedToken, _ := ss.add(used)
+	idleToken, _ := ss.add(idle)
+
+	// Using a session keeps it unlocked past the idle timeout.
+	for i := 0; i < 6; i++ {
+		time.Sleep(5 * time.Millisecond)
+		if _, ok := ss.get(usedToken); !ok {
+			t.Fatal("session in use locked")
+		}
+	}
+	if _, ok := ss.get(idleToken); ok || !idle.Locked() {
+		t.Error("idle session still unlocked")
+	}
+	if !ss.lock(usedToken) || !used.Locked() {
+		t.Error("lock() left the session unlocked")
+	}
+	if _, ok := ss.get(usedToken); ok {
+		t.Error("get() returned a locked session")
+	}
+}
+
+func TestWalletSessionHandlers(t *testing.T) {
+	db, err := store.NewMemory()
+	if err != nil {
+		t.Fatalf("NewMemory: %v", err)
+	}
+	defer db.Close()
+	ws := &WebServer{ctx: context.Background(), store: db, logger: zap.NewNop(), wallets: newWalletSessions(walletIdleTimeout)}
+	mnemonic, err := wallet.NewMnemonic()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := wallet.New()
+	enc, err := wallet.EncryptWallet(w, mnemonic, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	call := func(handler http.HandlerFunc, token string, body interface{}) (*httptest.ResponseRecorder, map[string]interface{}) {
+		t.Helper()
+		data, _ := json.Marshal(body)
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(string(data)))
+		if token != "" {
+			req.Header.Set(walletSessionHeader, token)
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		var resp map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec, resp
+	}
+
+	rec, resp := call(ws.handleLoadWallet, "", map[string]string{"wallet_data": "{}", "mnemonic": mnemonic})
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("load of a bad wallet = %d", rec.Code)
+	}
+	rec, resp = call(ws.handleLoadWallet, "", map[string]string{"wallet_data": string(enc), "mnemonic": mnemonic})
+	token, _ := resp["session"].(string)
+	if rec.Code != http.StatusOK || token == "" {
+		t.Fatalf("load = %d %s", rec.Code, rec.Body)
+	}
+
+	// The session stands in for the mnemonic.
+	rec, resp = call(ws.handleAddSite, token, map[string]interface{}{"wallet_data": mustJSON(t, w), "label": "blog"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("add-site = %d %s", rec.Code, rec.Body)
+	}
+	rec, resp = call(ws.handleEncryptWallet, token, map[string]interface{}{"wallet_data": resp["wallet"]})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("encrypt = %d %s", rec.Code, rec.Body)
+	}
+	updated, _ := resp["encrypted_wallet"].(string)
+	if got, err := wallet.DecryptWallet([]byte(updated), mnemonic, ""); err != nil || got.Sites["blog"] == nil {
+		t.Errorf("re-encrypted wallet = %+v, %v", got, err)
+	}
+	rec, _ = call(ws.handleGetWebsiteInfo, token, map[string]string{"wallet_data": updated, "label": "blog"})
+	if rec.Code == http.StatusUnauthorized {
+		t.Errorf("website info with a session = %d %s", rec.Code, rec.Body)
+	}
+
+	rec, _ = call(ws.handleWalletLock, token, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("lock = %d", rec.Code)
+	}
+	locked := []struct {
+		name    string
+		handler http.HandlerFunc
+		wallet  interface{}
+	}{
+		{"add-site", ws.handleAddSite, mustJSON(t, w)},
+		{"sites", ws.handleWalletSites, mustJSON(t, w)},
+		{"encrypt", ws.handleEncryptWallet, w},
+		{"website info", ws.handleGetWebsiteInfo, updated},
+	}
+	for _, tt := range locked {
+		rec, resp = call(tt.handler, token, map[string]interface{}{"wallet_data": tt.wallet, "label": "blog"})
+		if rec.Code != http.StatusUnauthorized || resp["locked"] != true {
+			t.Errorf("%s after lock = %d %s, want locked", tt.name, rec.Code, rec.Body)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/wallet/session", nil)
+	req.Header.Set(walletSessionHeader, token)
+	rec = httptest.NewRecorder()
+	ws.handleWalletSession(rec, req)
+	if !strings.Contains(rec.Body.String(), `"unlocked":false`) {
+		t.Errorf("session after lock = %s", rec.Body)
+	}
+}
+
+func mustJSON(t *testing.T, v interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -0,0 +1,220 @@
+// Package alxnet embeds AlxNet in other Go programs. OpenStore opens a
+// node's database and NewNode joins the network with it; a Publisher signs
+// and announces sites, a Resolver turns names into site IDs and a Fetcher
+// reads sites from the store or, when it does not hold them, from peers.
+//
+// A program that only reads sites it already stores, or publishes without
+// announcing, can pass a nil *Node:
+//
+//	st, err := alxnet.OpenStore("data")
+//	...
+//	defer st.Close()
+//	node, err := alxnet.NewNode(ctx, st, alxnet.NodeOptions{})
+//	...
+//	defer node.Close()
+//	siteID, err := alxnet.NewResolver(st, node).Resolve(ctx, "example.bn")
+//	...
+//	page, err := alxnet.NewFetcher(st, node).File(ctx, siteID, "")
+//
+// The package wraps the internal packages the alxnet command is built
+// from, so embedded nodes speak the same protocol and store the same
+// records as the command.
+package alxnet
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"strings"
+
+	"alxnet/internal/core"
+	"alxnet/internal/p2p"
+	"alxnet/internal/publisher"
+	"alxnet/internal/store"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+	"go.uber.org/zap"
+)
+
+// DefaultListen listens on every IPv4 address at a port the system picks.
+const DefaultListen = "/ip4/0.0.0.0/tcp/0"
+
+var (
+	// ErrNotFound is returned when neither the store nor any peer asked
+	// has what was requested.
+	ErrNotFound = errors.New("alxnet: not found")
+	// ErrNoNode is returned for operations that must reach the network
+	// when there is no node.
+	ErrNoNode = publisher.ErrNoNode
+)
+
+// Store is a node's database of sites, records and content. It must not be
+// opened by two processes at once.
+type Store struct {
+	db *store.Store
+}
+
+// OpenStore opens or creates the store in dir.
+func OpenStore(dir string) (*Store, error) {
+	db, err := store.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the store. Close the node using it first.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// RegisterName registers name, with or without the .bn suffix, for siteID
+// in this store. Registrations are local: other nodes learn of them only by
+// asking.
+func (s *Store) RegisterName(name, siteID string) error {
+	if !validSiteID(siteID) {
+		return fmt.Errorf("invalid site ID: %q", siteID)
+	}
+	return s.db.PutDomain(strings.TrimSuffix(name, ".bn"), siteID)
+}
+
+// NodeOptions configure a node. The zero value listens on DefaultListen
+// without bootstrap peers.
+type NodeOptions struct {
+	Listen    string      // multiaddr to listen on; default DefaultListen
+	Bootstrap []string    // multiaddrs with /p2p/ IDs of peers to join through
+	Logger    *zap.Logger // default: no logging
+
+	// SOCKS5Proxy, host:port, sends every connection through a SOCKS5
+	// proxy such as Tor's 127.0.0.1:9050; peers' /onion3 and /dns
+	// addresses are then resolved by the proxy.
+	SOCKS5Proxy string
+
+	// SwarmKey joins the private network of this 32-byte key, see
+	// LoadSwarmKey; nil joins the public network.
+	SwarmKey []byte
+}
+
+// LoadSwarmKey reads a private network key from a swarm.key file, as
+// written by `alxnet keytool swarm-key`.
+func LoadSwarmKey(path string) ([]byte, error) {
+	return p2p.LoadSwarmKey(path)
+}
+
+// Node is a running peer of the network.
+type Node struct {
+	node   *p2p.Node
+	cancel context.CancelFunc
+}
+
+// NewNode starts a node storing what it receives in st. It runs until
+// Close is called or ctx is done.
+func NewNode(ctx context.Context, st *Store, opts NodeOptions) (*Node, error) {
+	if opts.Listen == "" {
+		opts.Listen = DefaultListen
+	}
+	config := p2p.DefaultNodeConfig()
+	config.Logger = opts.Logger
+	config.SOCKS5Proxy = opts.SOCKS5Proxy
+	config.PrivateNetworkKey = opts.SwarmKey
+	if config.Logger == nil {
+		config.Logger = zap.NewNop()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	n, err := p2p.New(ctx, st.db, opts.Listen, opts.Bootstrap, config)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if err := n.Start(ctx); err != nil {
+		cancel()
+		n.Host.Close()
+		return nil, err
+	}
+	return &Node{node: n, cancel: cancel}, nil
+}
+
+// ID returns the node's peer ID.
+func (n *Node) ID() string {
+	return n.node.Host.ID().String()
+}
+
+// Addrs returns the addresses other nodes can connect to, each ending in
+// /p2p/ and the node's ID.
+func (n *Node) Addrs() []string {
+	suffix := "/p2p/" + n.ID()
+	var addrs []string
+	for _, a := range n.node.Host.Addrs() {
+		addrs = append(addrs, a.String()+suffix)
+	}
+	return addrs
+}
+
+// Connect connects to the peer at addr, a multiaddr ending in /p2p/ and
+// the peer's ID.
+func (n *Node) Connect(ctx context.Context, addr string) error {
+	m, err := ma.NewMultiaddr(addr)
+	if err != nil {
+		return fmt.Errorf("invalid address %q: %w", addr, err)
+	}
+	ai, err := peer.AddrInfoFromP2pAddr(m)
+	if err != nil {
+		return fmt.Errorf("invalid address %q: %w", addr, err)
+	}
+	return n.node.Host.Connect(ctx, *ai)
+}
+
+// Peers returns the IDs of the connected peers.
+func (n *Node) Peers() []string {
+	var ids []string
+	for _, p := range n.node.Host.Network().Peers() {
+		ids = append(ids, p.String())
+	}
+	return ids
+}
+
+// Close stops the node.
+func (n *Node) Close() error {
+	n.cancel()
+	return n.node.Host.Close()
+}
+
+// p2pNode returns the node n wraps, or nil for a nil n.
+func (n *Node) p2pNode() *p2p.Node {
+	if n == nil {
+		return nil
+	}
+	return n.node
+}
+
+// connectedPeers returns up to max connected peers, or none for a nil n.
+func (n *Node) connectedPeers(max int) []peer.ID {
+	if n == nil {
+		return nil
+	}
+	peers := n.node.Host.Network().Peers()
+	if len(peers) > max {
+		peers = peers[:max]
+	}
+	return peers
+}
+
+// SiteID returns the ID of the site whose public key is key.
+func SiteID(key ed25519.PublicKey) string {
+	return core.SiteIDFromPub(key)
+}
+
+// validSiteID reports whether s is a site ID: 64 lowercase hex digits.
+func validSiteID(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	for _, c := range s {
+		if !((c >= '0' && c <= '9') || (c >= 'a' && c <= 'f')) {
+			return false
+		}
+	}
+	return true
+}
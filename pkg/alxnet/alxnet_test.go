@@ -0,0 +1,154 @@
+package alxnet
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+)
+
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+	st, err := OpenStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("OpenStore: %v", err)
+	}
+	t.Cleanup(func() { st.Close() })
+	return st
+}
+
+// publishTestSite publishes a two-file website to st only and registers it
+// as "sdk-site".
+func publishTestSite(t *testing.T, ctx context.Context, st *Store) (string, *Manifest) {
+	t.Helper()
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	p := NewPublisher(st, nil)
+	for _, f := range []struct{ path, body, mimeType string }{
+		{"index.html", "<h1>sdk page</h1>", "text/html"},
+		{"css/site.css", "h1 { color: red }", "text/css"},
+	} {
+		if _, err := p.SaveFile(priv, f.path, []byte(f.body), f.mimeType); err != nil {
+			t.Fatalf("SaveFile(%s): %v", f.path, err)
+		}
+	}
+	m, err := p.PublishWebsite(ctx, priv)
+	if err != nil {
+		t.Fatalf("PublishWebsite: %v", err)
+	}
+	siteID := SiteID(pub)
+	if m.SiteID != siteID || len(m.Files) != 2 {
+		t.Fatalf("PublishWebsite = %+v, want site %s with 2 files", m, siteID)
+	}
+	if err := st.RegisterName("sdk-site", siteID); err != nil {
+		t.Fatalf("RegisterName: %v", err)
+	}
+	return siteID, m
+}
+
+func TestPublishContentNeedsNode(t *testing.T) {
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	_, err := NewPublisher(openTestStore(t), nil).PublishContent(context.Background(), priv, []byte("hello"))
+	if !errors.Is(err, ErrNoNode) {
+		t.Fatalf("PublishContent without a node = %v, want ErrNoNode", err)
+	}
+}
+
+func TestRegisterNameRejectsBadSiteID(t *testing.T) {
+	if err := openTestStore(t).RegisterName("sdk-site", "not-a-site"); err == nil {
+		t.Fatal("RegisterName accepted a malformed site ID")
+	}
+}
+
+func TestLocalSite(t *testing.T) {
+	ctx := context.Background()
+	st := openTestStore(t)
+	siteID, published := publishTestSite(t, ctx, st)
+
+	r := NewResolver(st, nil)
+	for _, tt := range []struct {
+		name string
+		want string
+		err  error
+	}{
+		{siteID, siteID, nil},
+		{"sdk-site", siteID, nil},
+		{"sdk-site.bn", siteID, nil},
+		{"unknown-site", "", ErrNotFound},
+	} {
+		got, err := r.Resolve(ctx, tt.name)
+		if got != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("Resolve(%q) = %q, %v; want %q, %v", tt.name, got, err, tt.want, tt.err)
+		}
+	}
+
+	f := NewFetcher(st, nil)
+	m, err := f.Manifest(ctx, siteID)
+	if err != nil {
+		t.Fatalf("Manifest: %v", err)
+	}
+	if m.CID != published.CID || m.Seq != published.Seq || m.MainFile != "index.html" {
+		t.Errorf("Manifest = %+v, want %+v", m, published)
+	}
+	for _, tt := range []struct {
+		path string
+		want string
+		err  error
+	}{
+		{"", "<h1>sdk page</h1>", nil},
+		{"css/site.css", "h1 { color: red }", nil},
+		{"missing.html", "", ErrNotFound},
+	} {
+		got, err := f.File(ctx, siteID, tt.path)
+		if string(got) != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("File(%q) = %q, %v; want %q, %v", tt.path, got, err, tt.want, tt.err)
+		}
+	}
+	if _, err := f.File(ctx, siteID, "../index.html"); err == nil {
+		t.Error("File accepted a path leaving the site")
+	}
+	if _, err := f.Manifest(ctx, SiteID(make(ed25519.PublicKey, ed25519.PublicKeySize))); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Manifest of an unknown site = %v, want ErrNotFound", err)
+	}
+}
+
+func TestRemoteSite(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	seederStore, readerStore := openTestStore(t), openTestStore(t)
+	siteID, published := publishTestSite(t, ctx, seederStore)
+
+	opts := NodeOptions{Listen: "/ip4/127.0.0.1/tcp/0"}
+	seeder, err := NewNode(ctx, seederStore, opts)
+	if err != nil {
+		t.Fatalf("NewNode: %v", err)
+	}
+	t.Cleanup(func() { seeder.Close() })
+	reader, err := NewNode(ctx, readerStore, opts)
+	if err != nil {
+		t.Fatalf("NewNode: %v", err)
+	}
+	t.Cleanup(func() { reader.Close() })
+	if err := reader.Connect(ctx, seeder.Addrs()[0]); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	got, err := NewResolver(readerStore, reader).Resolve(ctx, "sdk-site.bn")
+	if err != nil || got != siteID {
+		t.Fatalf("Resolve through peers = %q, %v; want %q", got, err, siteID)
+	}
+
+	f := NewFetcher(readerStore, reader)
+	m, err := f.Manifest(ctx, siteID)
+	if err != nil {
+		t.Fatalf("Manifest from peers: %v", err)
+	}
+	if m.CID != published.CID {
+		t.Errorf("Manifest CID = %s, want %s", m.CID, published.CID)
+	}
+	page, err := f.File(ctx, siteID, "css/site.css")
+	if err != nil || string(page) != "h1 { color: red }" {
+		t.Errorf("File from peers = %q, %v", page, err)
+	}
+}
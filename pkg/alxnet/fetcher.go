@@ -0,0 +1,198 @@
+package alxnet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"alxnet/internal/core"
+	"alxnet/internal/p2p"
+	"alxnet/internal/store"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Fetcher reads published sites.
+type Fetcher interface {
+	// Manifest returns the current manifest of a website, or ErrNotFound
+	// for single-file sites and sites no source has.
+	Manifest(ctx context.Context, siteID string) (*Manifest, error)
+
+	// File returns one file of a site; "" is its main file, which is the
+	// whole content of a single-file site.
+	File(ctx context.Context, siteID, path string) ([]byte, error)
+
+	// Content returns the blob whose CID is cid.
+	Content(ctx context.Context, cid string) ([]byte, error)
+}
+
+// fetchPeers is how many connected peers a manifest fetch asks at most,
+// as the browser does; content goes through the node's want list.
+const fetchPeers = 3
+
+// NewFetcher returns a fetcher that reads from st and, with a node, asks
+// its connected peers for what st does not hold. Everything taken from
+// peers is verified: manifests against the site's key, blobs against
+// their CID. Of the manifests peers send, the newest wins, so one peer
+// holding an old version cannot roll a site back. Single-file sites are
+// read from st only, which a node fills from the updates it receives.
+// Nothing fetched from peers is stored.
+func NewFetcher(st *Store, node *Node) Fetcher {
+	return &siteFetcher{db: st.db, node: node}
+}
+
+type siteFetcher struct {
+	db   *store.Store
+	node *Node
+}
+
+func (f *siteFetcher) Manifest(ctx context.Context, siteID string) (*Manifest, error) {
+	if !validSiteID(siteID) {
+		return nil, fmt.Errorf("invalid site ID: %q", siteID)
+	}
+	m, data, err := f.localManifest(siteID)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		if m, data, err = f.remoteManifest(ctx, siteID); err != nil {
+			return nil, err
+		}
+	}
+	return &Manifest{
+		SiteID:    siteID,
+		CID:       core.CIDForBytes(data),
+		Seq:       m.Seq,
+		Published: time.Unix(m.TS, 0),
+		MainFile:  m.MainFile,
+		Files:     m.Files,
+		NoIndex:   m.NoIndex,
+		Preload:   m.Preload,
+	}, nil
+}
+
+// localManifest returns the stored manifest of siteID and its encoding,
+// or nil if there is none.
+func (f *siteFetcher) localManifest(siteID string) (*core.WebsiteManifest, []byte, error) {
+	if !f.db.HasWebsiteManifest(siteID) {
+		return nil, nil, nil
+	}
+	data, err := f.db.GetCurrentWebsiteManifest(siteID)
+	if err != nil {
+		return nil, nil, err
+	}
+	var m core.WebsiteManifest
+	if err := core.UnmarshalCBOR(data, &m); err != nil {
+		return nil, nil, fmt.Errorf("invalid stored manifest: %w", err)
+	}
+	if err := p2p.VerifySiteManifest(&m, siteID); err != nil {
+		return nil, nil, fmt.Errorf("invalid stored manifest: %w", err)
+	}
+	return &m, data, nil
+}
+
+// remoteManifest asks up to fetchPeers connected peers at once for the
+// manifest of siteID and returns the newest one.
+func (f *siteFetcher) remoteManifest(ctx context.Context, siteID string) (*core.WebsiteManifest, []byte, error) {
+	peers := f.node.connectedPeers(fetchPeers)
+	if len(peers) == 0 {
+		return nil, nil, fmt.Errorf("%w: site %s", ErrNotFound, siteID)
+	}
+	type answer struct {
+		m   *core.WebsiteManifest
+		err error
+	}
+	answers := make(chan answer, len(peers))
+	for _, p := range peers {
+		go func() {
+			m, err := f.node.node.RequestManifest(ctx, peer.AddrInfo{ID: p}, siteID)
+			answers <- answer{m, err}
+		}()
+	}
+	var best *core.WebsiteManifest
+	var lastErr error
+	for range peers {
+		a := <-answers
+		switch {
+		case errors.Is(a.err, p2p.ErrRecordNotFound):
+		case a.err != nil:
+			lastErr = a.err
+		case best == nil || a.m.Seq > best.Seq:
+			best = a.m
+		}
+	}
+	if best == nil {
+		if lastErr != nil {
+			return nil, nil, lastErr
+		}
+		return nil, nil, fmt.Errorf("%w: site %s", ErrNotFound, siteID)
+	}
+	data, err := core.MarshalCanonical(best)
+	if err != nil {
+		return nil, nil, err
+	}
+	return best, data, nil
+}
+
+func (f *siteFetcher) File(ctx context.Context, siteID, path string) ([]byte, error) {
+	if path != "" {
+		if err := core.ValidateFilePath(path); err != nil {
+			return nil, err
+		}
+	}
+	m, err := f.Manifest(ctx, siteID)
+	switch {
+	case err == nil:
+		if path == "" {
+			path = m.MainFile
+		}
+		cid, ok := m.Files[path]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s in site %s", ErrNotFound, path, siteID)
+		}
+		return f.Content(ctx, cid)
+	case !errors.Is(err, ErrNotFound) || path != "":
+		return nil, err
+	}
+
+	// A single-file site
+	ok, err := f.db.HasHead(siteID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: site %s", ErrNotFound, siteID)
+	}
+	_, headCID, err := f.db.GetHead(siteID)
+	if err != nil {
+		return nil, err
+	}
+	data, err := f.db.GetRecord(headCID)
+	if err != nil {
+		return nil, err
+	}
+	var rec core.UpdateRecord
+	if err := core.UnmarshalCBOR(data, &rec); err != nil {
+		return nil, fmt.Errorf("invalid stored record: %w", err)
+	}
+	return f.Content(ctx, rec.ContentCID)
+}
+
+func (f *siteFetcher) Content(ctx context.Context, cid string) ([]byte, error) {
+	data, err := f.db.GetContent(cid)
+	if err != nil {
+		return nil, err
+	}
+	if data != nil {
+		return data, nil
+	}
+	if f.node == nil {
+		return nil, fmt.Errorf("%w: content %s", ErrNotFound, cid)
+	}
+	data, err = f.node.node.Want(ctx, cid, "", p2p.PriorityInteractive)
+	if errors.Is(err, p2p.ErrNoPeers) {
+		return nil, fmt.Errorf("%w: content %s", ErrNotFound, cid)
+	}
+	return data, err
+}
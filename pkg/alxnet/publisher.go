@@ -0,0 +1,130 @@
+package alxnet
+
+import (
+	"context"
+	"crypto/ed25519"
+	"time"
+
+	"alxnet/internal/publisher"
+
+	"go.uber.org/zap"
+)
+
+// Publisher signs what a site owner publishes with the site's key, stores
+// it and, with a node, announces it to peers.
+type Publisher interface {
+	// PublishContent publishes content as the whole of a single-file site.
+	// It needs a node.
+	PublishContent(ctx context.Context, key ed25519.PrivateKey, content []byte) (*Update, error)
+
+	// SaveFile stores one file of a website as a draft. Readers see it
+	// once the website is published, which signs it.
+	SaveFile(key ed25519.PrivateKey, path string, content []byte, mimeType string) (*File, error)
+
+	// AddFile saves one file and publishes the website with it.
+	AddFile(ctx context.Context, key ed25519.PrivateKey, path string, content []byte, mimeType string) (*File, *Manifest, error)
+
+	// RemoveFile publishes the website without path.
+	RemoveFile(ctx context.Context, key ed25519.PrivateKey, path string) (*Manifest, error)
+
+	// PublishWebsite signs every draft and a manifest of every saved file,
+	// stores them and announces the manifest when there is a node.
+	PublishWebsite(ctx context.Context, key ed25519.PrivateKey) (*Manifest, error)
+}
+
+// Update is a published single-file update.
+type Update struct {
+	SiteID     string
+	Seq        uint64
+	RecordCID  string
+	ContentCID string
+}
+
+// File is a saved file of a website. RecordCID is empty for drafts.
+type File struct {
+	Path       string
+	ContentCID string
+	RecordCID  string
+	MimeType   string
+	Size       int
+}
+
+// Manifest is a published version of a website.
+type Manifest struct {
+	SiteID    string
+	CID       string
+	Seq       uint64
+	Published time.Time
+	MainFile  string
+	Files     map[string]string // path -> content CID
+	NoIndex   bool
+	Preload   []string // files fetched before the rest
+}
+
+// NewPublisher returns a publisher writing to st and announcing through
+// node. With a nil node, websites are published to st only and
+// PublishContent returns ErrNoNode.
+func NewPublisher(st *Store, node *Node) Publisher {
+	return &sitePublisher{pub: publisher.New(st.db, node.p2pNode(), zap.NewNop())}
+}
+
+type sitePublisher struct {
+	pub *publisher.Publisher
+}
+
+func (p *sitePublisher) PublishContent(ctx context.Context, key ed25519.PrivateKey, content []byte) (*Update, error) {
+	u, err := p.pub.PublishContent(ctx, key, content)
+	if err != nil {
+		return nil, err
+	}
+	return &Update{SiteID: u.SiteID, Seq: u.Seq, RecordCID: u.RecordCID, ContentCID: u.ContentCID}, nil
+}
+
+func (p *sitePublisher) SaveFile(key ed25519.PrivateKey, path string, content []byte, mimeType string) (*File, error) {
+	f, err := p.pub.SaveFile(key, path, content, mimeType)
+	if err != nil {
+		return nil, err
+	}
+	return fileOf(f), nil
+}
+
+func (p *sitePublisher) AddFile(ctx context.Context, key ed25519.PrivateKey, path string, content []byte, mimeType string) (*File, *Manifest, error) {
+	f, m, err := p.pub.AddFile(ctx, key, path, content, mimeType)
+	if err != nil {
+		return nil, nil, err
+	}
+	return fileOf(f), manifestOf(m), nil
+}
+
+func (p *sitePublisher) RemoveFile(ctx context.Context, key ed25519.PrivateKey, path string) (*Manifest, error) {
+	m, err := p.pub.RemoveFile(ctx, key, path)
+	if err != nil {
+		return nil, err
+	}
+	return manifestOf(m), nil
+}
+
+func (p *sitePublisher) PublishWebsite(ctx context.Context, key ed25519.PrivateKey) (*Manifest, error) {
+	m, err := p.pub.PublishWebsite(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return manifestOf(m), nil
+}
+
+func fileOf(f *publisher.File) *File {
+	return &File{Path: f.Path, ContentCID: f.ContentCID, RecordCID: f.RecordCID, MimeType: f.MimeType, Size: f.Size}
+}
+
+func manifestOf(m *publisher.Manifest) *Manifest {
+	return &Manifest{
+		SiteID:    SiteID(m.SitePub),
+		CID:       m.CID,
+		Seq:       m.Seq,
+		Published: time.Unix(m.TS, 0),
+		MainFile:  m.MainFile,
+		Files:     m.Files,
+		NoIndex:   m.NoIndex,
+		Preload:   m.Preload,
+	}
+}
@@ -0,0 +1,82 @@
+package alxnet
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"alxnet/internal/dnslink"
+	"alxnet/internal/store"
+)
+
+// Resolver turns the name of a site into its ID.
+type Resolver interface {
+	// Resolve returns the site ID name stands for. name is a site ID, a
+	// registered name with or without the .bn suffix, or a DNS name
+	// publishing a _alxnet TXT record. It returns ErrNotFound when no
+	// source knows the name.
+	Resolve(ctx context.Context, name string) (string, error)
+}
+
+// Resolver timeouts and limits, as the browser uses them.
+const (
+	resolvePeerTimeout      = 3 * time.Second
+	resolveDiscoveryTimeout = 5 * time.Second
+	resolveDiscoverPeers    = 8
+	resolveAskPeers         = 8
+)
+
+// NewResolver returns a resolver that looks names up in st's registry,
+// then in DNS for DNS names and, with a node, asks its peers. Registered
+// names are unsigned claims, so a name is taken from peers only when every
+// peer knowing it agrees, and sites on st's denylist are not returned.
+// Answers from peers are not cached.
+func NewResolver(st *Store, node *Node) Resolver {
+	return &nameResolver{db: st.db, node: node, dns: dnslink.NewResolver()}
+}
+
+type nameResolver struct {
+	db   *store.Store
+	node *Node
+	dns  *dnslink.Resolver
+}
+
+func (r *nameResolver) Resolve(ctx context.Context, name string) (string, error) {
+	if validSiteID(name) {
+		return name, nil
+	}
+	siteName := strings.TrimSuffix(name, ".bn")
+	if siteID, err := r.db.ResolveDomain(siteName); err == nil {
+		return siteID, nil
+	}
+	if dnslink.IsDomain(name) {
+		siteID, err := r.dns.Resolve(ctx, name)
+		if err != nil {
+			return "", fmt.Errorf("%w: %s: %v", ErrNotFound, name, err)
+		}
+		return siteID, nil
+	}
+	if r.node == nil || r.db.ValidateSiteName(siteName) != nil {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+
+	n := r.node.node
+	peerCtx, cancel := context.WithTimeout(ctx, resolvePeerTimeout)
+	siteID, _, err := n.AskPeersName(peerCtx, r.node.connectedPeers(resolveAskPeers), siteName)
+	cancel()
+	if siteID == "" && err == nil {
+		// Nobody connected knows the name, as on a node that just started
+		discoverCtx, cancel := context.WithTimeout(ctx, resolveDiscoveryTimeout)
+		found := n.DiscoverPeers(discoverCtx, resolveDiscoverPeers)
+		siteID, _, err = n.AskPeersName(discoverCtx, found, siteName)
+		cancel()
+	}
+	if err != nil {
+		return "", err
+	}
+	if siteID == "" || r.db.IsDenied(siteID) {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return siteID, nil
+}
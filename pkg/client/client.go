@@ -0,0 +1,113 @@
+// Package client calls the JSON API that AlxNet's browser, wallet and node
+// servers serve under /api/v1. Each server describes its endpoints at
+// /api/openapi.json; Get and Post reach any of them, and the typed methods
+// cover the common ones.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// APIPrefix is where the servers serve version 1 of the API.
+const APIPrefix = "/api/v1"
+
+// maxErrorBody bounds the error message read from a failed response.
+const maxErrorBody = 4096
+
+// Client calls one server, such as the node UI at http://localhost:8082.
+type Client struct {
+	BaseURL string       // the server's address, without /api/v1
+	Token   string       // admin token for /admin endpoints; sent as a bearer token if set
+	HTTP    *http.Client // defaults to a client with a one minute timeout
+}
+
+// New returns a client for the server at baseURL.
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		HTTP:    &http.Client{Timeout: time.Minute},
+	}
+}
+
+// Error is a response with a status other than 200 OK.
+type Error struct {
+	StatusCode int
+	Message    string // the response body, trimmed
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("alxnet: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// Get reads path, relative to /api/v1 such as "/node/status", with the
+// query parameters q into out.
+func (c *Client) Get(ctx context.Context, path string, q url.Values, out interface{}) error {
+	if len(q) > 0 {
+		path += "?" + q.Encode()
+	}
+	return c.do(ctx, http.MethodGet, path, nil, out)
+}
+
+// Post sends in as JSON to path, relative to /api/v1, and decodes the
+// answer into out, which may be nil.
+func (c *Client) Post(ctx context.Context, path string, in, out interface{}) error {
+	b, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return c.do(ctx, http.MethodPost, path, b, out)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body []byte, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+APIPrefix+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	hc := c.HTTP
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return &Error{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(msg))}
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("alxnet: invalid response from %s: %w", path, err)
+	}
+	return nil
+}
+
+// OpenAPI returns the server's OpenAPI document.
+func (c *Client) OpenAPI(ctx context.Context) (map[string]interface{}, error) {
+	var doc map[string]interface{}
+	if err := c.Get(ctx, "/openapi.json", nil, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
@@ -0,0 +1,60 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/node/status":
+			json.NewEncoder(w).Encode(map[string]interface{}{"node_id": "12D3KooW", "uptime_seconds": 90})
+		case "/api/v1/search":
+			if r.URL.Query().Get("q") != "cats" || r.URL.Query().Get("limit") != "5" {
+				http.Error(w, "bad query "+r.URL.RawQuery, http.StatusBadRequest)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"results": []map[string]interface{}{{"site_id": "aa", "title": "Cats", "score": 1.5}},
+				"total":   7,
+			})
+		case "/api/v1/admin/gc":
+			if r.Method != http.MethodPost || r.Header.Get("Authorization") != "Bearer secret" {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+	c := New(srv.URL + "/")
+
+	status, err := c.NodeStatus(ctx)
+	if err != nil || status.NodeID != "12D3KooW" || status.UptimeSeconds != 90 {
+		t.Errorf("NodeStatus() = %+v, %v", status, err)
+	}
+	results, total, err := c.Search(ctx, "cats", 5)
+	if err != nil || total != 7 || len(results) != 1 || results[0].Title != "Cats" || results[0].Score != 1.5 {
+		t.Errorf("Search() = %+v, %d, %v", results, total, err)
+	}
+
+	var apiErr *Error
+	if err := c.Post(ctx, "/admin/gc", struct{}{}, nil); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Post without token = %v", err)
+	}
+	c.Token = "secret"
+	if err := c.Post(ctx, "/admin/gc", struct{}{}, nil); err != nil {
+		t.Errorf("Post with token = %v", err)
+	}
+	if _, err := c.ResolveName(ctx, "missing"); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("ResolveName() error = %v", err)
+	}
+}
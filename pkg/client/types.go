@@ -0,0 +1,117 @@
+package client
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+)
+
+// NodeStatus is the node UI's /node/status.
+type NodeStatus struct {
+	NodeID          string   `json:"node_id"`
+	UptimeSeconds   int64    `json:"uptime_seconds"`
+	ListenAddresses []string `json:"listen_addresses"`
+	Status          string   `json:"status"`
+	Bandwidth       struct {
+		Served   int64 `json:"bytes_served"`
+		Received int64 `json:"bytes_received"`
+	} `json:"bandwidth"`
+}
+
+// NodeStatus reports the node's ID, uptime, addresses and traffic.
+func (c *Client) NodeStatus(ctx context.Context) (*NodeStatus, error) {
+	var s NodeStatus
+	if err := c.Get(ctx, "/node/status", nil, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// StorageStats is the node UI's /storage/stats.
+type StorageStats struct {
+	TotalSites   int64 `json:"total_sites"`
+	TotalDomains int64 `json:"total_domains"`
+	StorageBytes int64 `json:"storage_bytes"`
+	ContentFiles int64 `json:"content_files"`
+	Records      int64 `json:"records"`
+	Websites     int64 `json:"websites"`
+}
+
+// StorageStats reports what the node stores.
+func (c *Client) StorageStats(ctx context.Context) (*StorageStats, error) {
+	var s StorageStats
+	if err := c.Get(ctx, "/storage/stats", nil, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// NodeSummary is one node in the node UI's /nodes.
+type NodeSummary struct {
+	Name          string `json:"name"`
+	URL           string `json:"url"`
+	Local         bool   `json:"local"`
+	Online        bool   `json:"online"`
+	Error         string `json:"error"`
+	NodeID        string `json:"node_id"`
+	UptimeSeconds int64  `json:"uptime_seconds"`
+	Peers         int    `json:"peers"`
+	Sites         int64  `json:"sites"`
+	StorageBytes  int64  `json:"storage_bytes"`
+	BytesServed   int64  `json:"bytes_served"`
+	BytesReceived int64  `json:"bytes_received"`
+}
+
+// Nodes lists the node and the remote nodes registered with it, the local
+// node first.
+func (c *Client) Nodes(ctx context.Context) ([]NodeSummary, error) {
+	var resp struct {
+		Nodes []NodeSummary `json:"nodes"`
+	}
+	if err := c.Get(ctx, "/nodes", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Nodes, nil
+}
+
+// SearchResult is one site found by the browser server's /search.
+type SearchResult struct {
+	SiteID      string   `json:"site_id"`
+	Names       []string `json:"names"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+	Updated     int64    `json:"updated"` // unix seconds
+	Score       float64  `json:"score"`
+	Snippet     string   `json:"snippet"`
+}
+
+// Search searches the sites the browser server's node stores for query,
+// returning up to limit results (0 for the server's default) and the
+// total number of matches.
+func (c *Client) Search(ctx context.Context, query string, limit int) ([]SearchResult, int, error) {
+	q := url.Values{"q": {query}}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	var resp struct {
+		Results []SearchResult `json:"results"`
+		Total   int            `json:"total"`
+	}
+	if err := c.Get(ctx, "/search", q, &resp); err != nil {
+		return nil, 0, err
+	}
+	return resp.Results, resp.Total, nil
+}
+
+// ResolveName returns the site ID a site name points at, through the
+// wallet server's /domains/resolve.
+func (c *Client) ResolveName(ctx context.Context, name string) (string, error) {
+	var resp struct {
+		SiteID string `json:"site_id"`
+	}
+	if err := c.Get(ctx, "/domains/resolve", url.Values{"domain": {name}}, &resp); err != nil {
+		return "", err
+	}
+	return resp.SiteID, nil
+}